@@ -0,0 +1,156 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// REQUEST BODY LIMITS
+// Oversized or mistyped bodies are rejected here instead of being proxied
+// =============================================================================
+
+// BodyLimit caps request bodies and restricts their content type for the
+// routes matching Pattern. In a pattern, a ":name" segment matches any one
+// path segment and a trailing "*" matches the rest of the path.
+type BodyLimit struct {
+	Methods      []string // empty matches every method that carries a body
+	Pattern      string
+	MaxBytes     int64
+	ContentTypes []string // accepted media types; empty accepts any
+}
+
+const (
+	chapterMaxBodyBytes = 2 << 20
+	commentMaxBodyBytes = 16 << 10
+	defaultMaxBodyBytes = 1 << 20
+)
+
+var (
+	jsonBody = []string{"application/json"}
+	// OAuth token and revoke endpoints take form-encoded bodies
+	authBody = []string{"application/json", "application/x-www-form-urlencoded"}
+)
+
+// defaultBodyLimits returns the gateway's limits, most specific first. The
+// catch-all size can be changed with GATEWAY_MAX_BODY_BYTES.
+func defaultBodyLimits() []BodyLimit {
+	maxBody := int64(defaultMaxBodyBytes)
+	if v, err := strconv.ParseInt(getEnv("GATEWAY_MAX_BODY_BYTES", ""), 10, 64); err == nil && v > 0 {
+		maxBody = v
+	}
+
+	return []BodyLimit{
+		// Chapters carry the full text of a work
+		{Pattern: "/api/v1/works/:work_id/chapters", MaxBytes: chapterMaxBodyBytes, ContentTypes: jsonBody},
+		{Pattern: "/api/v1/works/:work_id/chapters/:chapter_id", MaxBytes: chapterMaxBodyBytes, ContentTypes: jsonBody},
+		// A new work is posted together with its first chapter
+		{Methods: []string{http.MethodPost}, Pattern: "/api/v1/works", MaxBytes: chapterMaxBodyBytes, ContentTypes: jsonBody},
+
+		{Pattern: "/api/v1/works/:work_id/comments", MaxBytes: commentMaxBodyBytes, ContentTypes: jsonBody},
+		{Pattern: "/api/v1/work/:work_id/comments", MaxBytes: commentMaxBodyBytes, ContentTypes: jsonBody},
+		{Pattern: "/api/v1/comments/*", MaxBytes: commentMaxBodyBytes, ContentTypes: jsonBody},
+
+		{Pattern: "/api/v1/auth/*", MaxBytes: 16 << 10, ContentTypes: authBody},
+		{Pattern: "/api/v1/bookmarks/*", MaxBytes: 64 << 10, ContentTypes: jsonBody},
+		{Pattern: "/api/v1/bookmark-lists/*", MaxBytes: 64 << 10, ContentTypes: jsonBody},
+		{Pattern: "/graphql", MaxBytes: 256 << 10, ContentTypes: jsonBody},
+
+		{Pattern: "/api/v1/*", MaxBytes: maxBody, ContentTypes: jsonBody},
+	}
+}
+
+// BodyLimitMiddleware enforces the first matching limit: a declared length
+// over the cap gets 413 straight away, a disallowed content type gets 415,
+// and bodies without a declared length are cut off at the cap while read.
+func BodyLimitMiddleware(limits []BodyLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !methodHasBody(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		limit, ok := matchBodyLimit(limits, c.Request.Method, c.Request.URL.Path)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit.MaxBytes {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":     "Request body too large",
+				"max_bytes": limit.MaxBytes,
+			})
+			return
+		}
+
+		if c.Request.ContentLength != 0 && len(limit.ContentTypes) > 0 {
+			mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+			if err != nil || !containsString(limit.ContentTypes, mediaType) {
+				c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+					"error":    "Unsupported content type",
+					"accepted": limit.ContentTypes,
+				})
+				return
+			}
+		}
+
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit.MaxBytes)
+		}
+		c.Next()
+	}
+}
+
+// matchBodyLimit returns the first limit that applies to a request
+func matchBodyLimit(limits []BodyLimit, method, path string) (BodyLimit, bool) {
+	for _, limit := range limits {
+		if len(limit.Methods) > 0 && !containsString(limit.Methods, method) {
+			continue
+		}
+		if matchPathPattern(limit.Pattern, path) {
+			return limit, true
+		}
+	}
+	return BodyLimit{}, false
+}
+
+// matchPathPattern matches a path against a BodyLimit pattern
+func matchPathPattern(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range patternParts {
+		if part == "*" && i == len(patternParts)-1 {
+			return len(pathParts) >= i
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if strings.HasPrefix(part, ":") {
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+	return len(pathParts) == len(patternParts)
+}
+
+// methodHasBody reports whether requests with this method carry a body
+func methodHasBody(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
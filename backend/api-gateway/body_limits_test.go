@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMatchBodyLimit(t *testing.T) {
+	limits := defaultBodyLimits()
+
+	cases := []struct {
+		method string
+		path   string
+		max    int64
+	}{
+		{"POST", "/api/v1/works/123/chapters", chapterMaxBodyBytes},
+		{"PUT", "/api/v1/works/123/chapters/456", chapterMaxBodyBytes},
+		{"POST", "/api/v1/works", chapterMaxBodyBytes},
+		{"PUT", "/api/v1/works/123", defaultMaxBodyBytes},
+		{"POST", "/api/v1/works/123/comments", commentMaxBodyBytes},
+		{"PUT", "/api/v1/comments/123", commentMaxBodyBytes},
+		{"POST", "/api/v1/series/123/works", defaultMaxBodyBytes},
+	}
+	for _, tc := range cases {
+		limit, ok := matchBodyLimit(limits, tc.method, tc.path)
+		if !ok {
+			t.Errorf("%s %s: expected a limit", tc.method, tc.path)
+			continue
+		}
+		if limit.MaxBytes != tc.max {
+			t.Errorf("%s %s: expected %d bytes, got %d", tc.method, tc.path, tc.max, limit.MaxBytes)
+		}
+	}
+
+	if _, ok := matchBodyLimit(limits, "POST", "/health"); ok {
+		t.Error("Expected no limit outside the API")
+	}
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(BodyLimitMiddleware([]BodyLimit{{Pattern: "/api/v1/*", MaxBytes: 10, ContentTypes: jsonBody}}))
+	r.POST("/api/v1/comments", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	cases := []struct {
+		name        string
+		body        string
+		contentType string
+		status      int
+	}{
+		{"within limit", `{"a":1}`, "application/json", http.StatusOK},
+		{"charset parameter", `{"a":1}`, "application/json; charset=utf-8", http.StatusOK},
+		{"too large", `{"text":"far too long"}`, "application/json", http.StatusRequestEntityTooLarge},
+		{"wrong content type", `a=1`, "text/plain", http.StatusUnsupportedMediaType},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("POST", "/api/v1/comments", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", tc.contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.status, w.Code)
+		}
+	}
+}
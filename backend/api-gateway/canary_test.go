@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCanaryRouteSelects(t *testing.T) {
+	route := &CanaryRoute{
+		Upstream: &ServiceClient{Name: "search-service-canary"},
+		Percent:  10,
+		Header:   defaultCanaryHeader,
+		Users:    map[string]bool{"cohort-user": true},
+	}
+
+	if !route.selects("true", "", 99) {
+		t.Error("the canary header should force the canary")
+	}
+	if route.selects("never", "cohort-user", 0) {
+		t.Error("opting out by header should win over the cohort")
+	}
+	if !route.selects("", "cohort-user", 99) {
+		t.Error("cohort users should always reach the canary")
+	}
+	if !route.selects("", "", 5) || route.selects("", "", 50) {
+		t.Error("anonymous requests should be split by the roll")
+	}
+
+	// A signed-in user lands on the same side every time, whatever the roll
+	want := canaryBucket(route.Upstream.Name, "some-user") < route.Percent
+	for _, roll := range []float64{0, 50, 99.9} {
+		if got := route.selects("", "some-user", roll); got != want {
+			t.Errorf("selects(roll=%v) = %v, want %v", roll, got, want)
+		}
+	}
+
+	route.Percent = 0
+	if route.selects("", "", 0) {
+		t.Error("a 0% canary should take no percentage traffic")
+	}
+}
+
+func TestCanaryBucket(t *testing.T) {
+	inCanary := 0
+	for i := 0; i < 10000; i++ {
+		bucket := canaryBucket("work-service-canary", "user-"+strconv.Itoa(i))
+		if bucket < 0 || bucket >= 100 {
+			t.Fatalf("bucket %v out of range", bucket)
+		}
+		if bucket < 25 {
+			inCanary++
+		}
+	}
+	if inCanary < 2000 || inCanary > 3000 {
+		t.Errorf("expected about a quarter of users under 25%%, got %d of 10000", inCanary)
+	}
+}
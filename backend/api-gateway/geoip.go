@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"log"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// =============================================================================
+// GEOIP
+// The gateway is the only place a client address is seen. It looks up the
+// country and forwards just the two-letter code to the services, so hit
+// statistics can be broken down by country without storing addresses.
+// =============================================================================
+
+// clientCountryHeader carries the client's country to the services. It is
+// always set by the gateway, never passed through from the client.
+const clientCountryHeader = "X-Client-Country"
+
+// countryRange is a block of addresses assigned to one country
+type countryRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// CountryDB maps addresses to countries from an IP range database
+type CountryDB struct {
+	ranges []countryRange
+}
+
+// loadCountryDB reads a CSV country database of "first address, last
+// address, country code" rows, such as DB-IP's free IP to Country Lite, for
+// both IPv4 and IPv6. Rows that don't parse are skipped.
+func loadCountryDB(r io.Reader) (*CountryDB, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	db := &CountryDB{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 3 {
+			continue
+		}
+		start, err := netip.ParseAddr(strings.TrimSpace(record[0]))
+		if err != nil {
+			continue
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(record[1]))
+		if err != nil || start.Is4() != end.Is4() || end.Less(start) {
+			continue
+		}
+		country := strings.ToUpper(strings.TrimSpace(record[2]))
+		if len(country) != 2 {
+			continue
+		}
+		db.ranges = append(db.ranges, countryRange{start: start, end: end, country: country})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool { return db.ranges[i].start.Less(db.ranges[j].start) })
+	return db, nil
+}
+
+// openCountryDB loads the database at GEOIP_COUNTRY_DB. Without one the
+// gateway forwards no country and hits are counted as unknown.
+func openCountryDB() *CountryDB {
+	path := getEnv("GEOIP_COUNTRY_DB", "")
+	if path == "" {
+		log.Println("GEOIP_COUNTRY_DB not set, client countries will not be looked up")
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("Failed to open GeoIP database: %v", err)
+		return nil
+	}
+	defer f.Close()
+
+	db, err := loadCountryDB(f)
+	if err != nil {
+		log.Printf("Failed to load GeoIP database: %v", err)
+		return nil
+	}
+	log.Printf("Loaded %d GeoIP country ranges", len(db.ranges))
+	return db
+}
+
+// Lookup returns the country code of an address, or "" when it is unknown
+func (db *CountryDB) Lookup(ip string) string {
+	if db == nil {
+		return ""
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	// The last range starting at or before the address is the only one
+	// that can hold it
+	i := sort.Search(len(db.ranges), func(i int) bool { return addr.Less(db.ranges[i].start) })
+	if i == 0 {
+		return ""
+	}
+	r := db.ranges[i-1]
+	if r.start.Is4() != addr.Is4() || r.end.Less(addr) {
+		return ""
+	}
+	return r.country
+}
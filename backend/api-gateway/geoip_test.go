@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountryDBLookup(t *testing.T) {
+	db, err := loadCountryDB(strings.NewReader(strings.Join([]string{
+		"2.0.0.0,2.15.255.255,FR",
+		"1.0.0.0,1.0.0.255,AU",
+		"1.0.4.0,1.0.7.255,au",
+		"not,an,address",
+		"9.9.9.9,9.9.9.0,XX",
+		"2001:200::,2001:200:ffff:ffff:ffff:ffff:ffff:ffff,JP",
+	}, "\n")))
+	if err != nil {
+		t.Fatalf("loadCountryDB: %v", err)
+	}
+	if len(db.ranges) != 4 {
+		t.Fatalf("expected 4 valid ranges, got %d", len(db.ranges))
+	}
+
+	cases := map[string]string{
+		"1.0.0.0":         "AU",
+		"1.0.0.255":       "AU",
+		"1.0.2.1":         "",
+		"1.0.5.9":         "AU",
+		"2.3.4.5":         "FR",
+		"::ffff:2.3.4.5":  "FR",
+		"2001:200::1":     "JP",
+		"2001:201::1":     "",
+		"0.0.0.1":         "",
+		"255.255.255.255": "",
+		"not an address":  "",
+		"9.9.9.5":         "",
+	}
+	for ip, want := range cases {
+		if got := db.Lookup(ip); got != want {
+			t.Errorf("Lookup(%q) = %q, want %q", ip, got, want)
+		}
+	}
+
+	var missing *CountryDB
+	if missing.Lookup("1.0.0.1") != "" {
+		t.Error("a missing database should know no countries")
+	}
+}
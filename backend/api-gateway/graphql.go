@@ -237,7 +237,7 @@ func (schema *GraphQLSchema) handleTagsQuery(ctx context.Context, req GraphQLReq
 // handleSearchQuery handles search-related queries
 func (schema *GraphQLSchema) handleSearchQuery(ctx context.Context, req GraphQLRequest) GraphQLResponse {
 	// Proxy to search service
-	response, err := schema.gateway.proxyToService("search", "GET", "/api/v1/search/works", nil)
+	response, err := schema.gateway.proxyToService("search", "GET", workSearchPath(req.Variables), nil)
 	if err != nil {
 		return GraphQLResponse{
 			Errors: []GraphQLError{{
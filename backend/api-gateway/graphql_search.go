@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/url"
+)
+
+// graphQLRatings maps the GraphQL Rating enum onto the rating names works
+// are stored and indexed with
+var graphQLRatings = map[string]string{
+	"GENERAL_AUDIENCES": "General Audiences",
+	"TEEN_AND_UP":       "Teen And Up Audiences",
+	"MATURE":            "Mature",
+	"EXPLICIT":          "Explicit",
+	"NOT_RATED":         "Not Rated",
+}
+
+// workSearchParams maps WorkFilters list fields onto the search service's
+// query parameters, includes first, then exclusions
+var workSearchParams = []struct {
+	field string
+	param string
+}{
+	{"fandoms", "fandom"},
+	{"characters", "character"},
+	{"relationships", "relationship"},
+	{"additionalTags", "tag"},
+	{"warnings", "warning"},
+	{"categories", "category"},
+	{"ratings", "rating"},
+	{"languages", "language"},
+	{"excludeFandoms", "exclude_fandom"},
+	{"excludeCharacters", "exclude_character"},
+	{"excludeRelationships", "exclude_relationship"},
+	{"excludeAdditionalTags", "exclude_tag"},
+	{"excludeWarnings", "exclude_warning"},
+	{"excludeRatings", "exclude_rating"},
+}
+
+// workSearchPath turns a search query's SearchInput variable into a
+// search-service works search path
+func workSearchPath(variables map[string]interface{}) string {
+	params := url.Values{}
+	input, _ := variables["input"].(map[string]interface{})
+
+	if query, ok := input["query"].(string); ok && query != "" {
+		params.Set("q", query)
+	}
+
+	filters, _ := input["filters"].(map[string]interface{})
+	works, _ := filters["works"].(map[string]interface{})
+	for _, p := range workSearchParams {
+		values, _ := works[p.field].([]interface{})
+		for _, v := range values {
+			value, ok := v.(string)
+			if !ok || value == "" {
+				continue
+			}
+			if p.field == "ratings" || p.field == "excludeRatings" {
+				if name, ok := graphQLRatings[value]; ok {
+					value = name
+				}
+			}
+			params.Add(p.param, value)
+		}
+	}
+
+	path := "/api/v1/search/works"
+	if encoded := params.Encode(); encoded != "" {
+		path += "?" + encoded
+	}
+	return path
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWorkSearchPathExclusions(t *testing.T) {
+	variables := map[string]interface{}{
+		"input": map[string]interface{}{
+			"query": "found family",
+			"filters": map[string]interface{}{
+				"works": map[string]interface{}{
+					"fandoms":               []interface{}{"Good Omens"},
+					"excludeAdditionalTags": []interface{}{"Major Character Death", "Angst"},
+					"excludeRatings":        []interface{}{"EXPLICIT"},
+					"excludeWarnings":       []interface{}{"Underage Sex"},
+				},
+			},
+		},
+	}
+
+	path := workSearchPath(variables)
+	if !strings.HasPrefix(path, "/api/v1/search/works?") {
+		t.Fatalf("path = %q", path)
+	}
+	params, err := url.ParseQuery(strings.SplitN(path, "?", 2)[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := url.Values{
+		"q":               {"found family"},
+		"fandom":          {"Good Omens"},
+		"exclude_tag":     {"Major Character Death", "Angst"},
+		"exclude_rating":  {"Explicit"},
+		"exclude_warning": {"Underage Sex"},
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %v, want %v", params, want)
+	}
+}
+
+func TestWorkSearchPathWithoutInput(t *testing.T) {
+	if got := workSearchPath(nil); got != "/api/v1/search/works" {
+		t.Errorf("workSearchPath(nil) = %q", got)
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/middleware"
 )
 
 // =============================================================================
@@ -84,9 +86,11 @@ type APIGateway struct {
 	workService   *ServiceClient
 	tagService    *ServiceClient
 	searchService *ServiceClient
+	statsService  *ServiceClient
 
 	// Infrastructure
 	redis *redis.Client
+	geoip *CountryDB
 
 	// Performance & Monitoring
 	metrics     *GatewayMetrics
@@ -103,6 +107,7 @@ type ServiceClient struct {
 	HTTPClient *http.Client
 	Name       string
 	Health     ServiceHealthStatus
+	Canary     *CanaryRoute // optional alternate upstream for part of the traffic
 }
 
 // ServiceHealthStatus tracks service availability
@@ -146,6 +151,19 @@ func NewAPIGateway() *APIGateway {
 		Name:       "search-service",
 	}
 
+	statsService := &ServiceClient{
+		BaseURL:    getEnv("STATS_SERVICE_URL", "http://localhost:8087"),
+		HTTPClient: createOptimizedHTTPClient(),
+		Name:       "stats-service",
+	}
+
+	// Canaries take a share of a service's traffic during a rollout
+	authService.Canary = loadCanaryRoute("AUTH_SERVICE", authService.Name)
+	workService.Canary = loadCanaryRoute("WORK_SERVICE", workService.Name)
+	tagService.Canary = loadCanaryRoute("TAG_SERVICE", tagService.Name)
+	searchService.Canary = loadCanaryRoute("SEARCH_SERVICE", searchService.Name)
+	statsService.Canary = loadCanaryRoute("STATS_SERVICE", statsService.Name)
+
 	// Initialize performance components
 	metrics := initializeMetrics()
 	rateLimiter := NewRateLimiter(redis)
@@ -159,7 +177,9 @@ func NewAPIGateway() *APIGateway {
 		workService:   workService,
 		tagService:    tagService,
 		searchService: searchService,
+		statsService:  statsService,
 		redis:         redis,
+		geoip:         openCountryDB(),
 		metrics:       metrics,
 		rateLimiter:   rateLimiter,
 		cache:         cache,
@@ -243,6 +263,10 @@ func setupRouter(gateway *APIGateway) *gin.Engine {
 	r.Use(LoggingMiddleware())
 	r.Use(SecurityHeadersMiddleware())
 	r.Use(MetricsMiddleware(gateway.metrics))
+	r.Use(BodyLimitMiddleware(defaultBodyLimits()))
+	// GraphQL queries arrive as POSTs, so GraphQL is let through and the
+	// services reject any mutation it forwards
+	r.Use(middleware.NewReadOnlyMode(gateway.redis).Middleware("/graphql", "/api/v1/search/"))
 
 	// Health check endpoint
 	r.GET("/health", gateway.HealthCheck)
@@ -275,7 +299,8 @@ func setupRouter(gateway *APIGateway) *gin.Engine {
 			auth.Any("/*path", gateway.ProxyToAuth)
 		}
 
-		// Works - handle both exact match and sub-paths
+		// Works - handle both exact match and sub-paths. Work stats and
+		// trending works go to the stats service.
 		works := api.Group("/works")
 		{
 			works.Any("", gateway.ProxyToWork)       // Exact match /works
@@ -294,6 +319,13 @@ func setupRouter(gateway *APIGateway) *gin.Engine {
 			search.Any("/*path", gateway.ProxyToSearch)
 		}
 
+		// Saved searches and their alerts - proxy to search service
+		savedSearches := api.Group("/saved-searches")
+		{
+			savedSearches.Any("", gateway.ProxyToSearch)
+			savedSearches.Any("/*path", gateway.ProxyToSearch)
+		}
+
 		// My endpoints - proxy to work service (user-specific endpoints)
 		my := api.Group("/my")
 		{
@@ -324,6 +356,13 @@ func setupRouter(gateway *APIGateway) *gin.Engine {
 			bookmarks.Any("/*path", gateway.ProxyToWork)
 		}
 
+		// Bookmark lists - proxy to work service
+		bookmarkLists := api.Group("/bookmark-lists")
+		{
+			bookmarkLists.Any("", gateway.ProxyToWork)
+			bookmarkLists.Any("/*path", gateway.ProxyToWork)
+		}
+
 		// Comments endpoints - proxy to work service
 		comments := api.Group("/comments")
 		{
@@ -335,6 +374,9 @@ func setupRouter(gateway *APIGateway) *gin.Engine {
 		{
 			pseuds.Any("/*path", gateway.ProxyToWork)
 		}
+
+		// Share target and bookmarklet ingestion - proxy to work service
+		api.POST("/share", gateway.ProxyToWork)
 	}
 
 	return r
@@ -349,7 +391,7 @@ func (gw *APIGateway) HealthCheck(c *gin.Context) {
 	// Count unhealthy services
 	unhealthyCount := 0
 	totalServices := 0
-	for _, service := range []*ServiceClient{gw.authService, gw.workService, gw.tagService, gw.searchService} {
+	for _, service := range []*ServiceClient{gw.authService, gw.workService, gw.tagService, gw.searchService, gw.statsService} {
 		totalServices++
 		if !service.Health.IsHealthy {
 			unhealthyCount++
@@ -387,10 +429,25 @@ func (gw *APIGateway) ServiceStatus(c *gin.Context) {
 		"work-service":   gw.workService.Health,
 		"tag-service":    gw.tagService.Health,
 		"search-service": gw.searchService.Health,
+		"stats-service":  gw.statsService.Health,
+	}
+
+	canaries := map[string]interface{}{}
+	for _, service := range []*ServiceClient{gw.authService, gw.workService, gw.tagService, gw.searchService, gw.statsService} {
+		if service.Canary != nil {
+			canaries[service.Name] = gin.H{
+				"url":     service.Canary.Upstream.BaseURL,
+				"percent": service.Canary.Percent,
+				"header":  service.Canary.Header,
+				"users":   len(service.Canary.Users),
+				"health":  service.Canary.Upstream.Health,
+			}
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"services": services,
+		"canaries": canaries,
 		"redis": gin.H{
 			"connected": gw.redis != nil,
 		},
@@ -400,10 +457,13 @@ func (gw *APIGateway) ServiceStatus(c *gin.Context) {
 
 // checkServiceHealth performs health checks on all services
 func (gw *APIGateway) checkServiceHealth() {
-	services := []*ServiceClient{gw.authService, gw.workService, gw.tagService, gw.searchService}
+	services := []*ServiceClient{gw.authService, gw.workService, gw.tagService, gw.searchService, gw.statsService}
 
 	for _, service := range services {
 		go gw.healthCheckService(service)
+		if service.Canary != nil {
+			go gw.healthCheckService(service.Canary.Upstream)
+		}
 	}
 }
 
@@ -453,6 +513,7 @@ func (gw *APIGateway) getServiceHealthSummary() map[string]string {
 		"work-service":   gw.getServiceStatusString(gw.workService),
 		"tag-service":    gw.getServiceStatusString(gw.tagService),
 		"search-service": gw.getServiceStatusString(gw.searchService),
+		"stats-service":  gw.getServiceStatusString(gw.statsService),
 	}
 }
 
@@ -20,6 +20,8 @@ type GatewayMetrics struct {
 	CacheHits         *prometheus.CounterVec
 	RateLimitHits     prometheus.Counter
 	GraphQLOperations *prometheus.CounterVec
+	UpstreamRequests  *prometheus.CounterVec
+	UpstreamDuration  *prometheus.HistogramVec
 }
 
 // initializeMetrics creates and registers all Prometheus metrics
@@ -75,6 +77,25 @@ func initializeMetrics() *GatewayMetrics {
 			},
 			[]string{"operation_type", "operation_name"},
 		),
+
+		// Split by upstream so a canary's errors and latency can be
+		// compared with the primary's
+		UpstreamRequests: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_upstream_requests_total",
+				Help: "Total number of proxied requests by service and upstream (primary or canary)",
+			},
+			[]string{"service", "upstream", "status_code"},
+		),
+
+		UpstreamDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "gateway_upstream_request_duration_seconds",
+				Help:    "Proxied request duration in seconds by service and upstream",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"service", "upstream"},
+		),
 	}
 }
 
@@ -110,6 +131,13 @@ func (m *GatewayMetrics) RecordGraphQLOperation(operationType, operationName str
 	m.GraphQLOperations.WithLabelValues(operationType, operationName).Inc()
 }
 
+// RecordUpstreamRequest records a proxied request against the upstream
+// that served it
+func (m *GatewayMetrics) RecordUpstreamRequest(service, upstream string, statusCode int, duration time.Duration) {
+	m.UpstreamRequests.WithLabelValues(service, upstream, getStatusClass(statusCode)).Inc()
+	m.UpstreamDuration.WithLabelValues(service, upstream).Observe(duration.Seconds())
+}
+
 // getStatusClass converts HTTP status code to class for metrics
 func getStatusClass(statusCode int) string {
 	switch {
@@ -48,7 +48,7 @@ func CORSMiddleware() gin.HandlerFunc {
 
 		// Set comprehensive CORS headers for all requests
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With, X-API-Key")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With, X-API-Key, If-Match, X-Adult-Content-Acknowledged")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS, HEAD")
 		c.Header("Access-Control-Max-Age", "86400") // 24 hours
 		c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Range")
@@ -414,6 +414,8 @@ func isPublicEndpoint(path string) bool {
 		"/api/v1/auth/register",
 		"/api/v1/auth/token",
 		"/api/v1/auth/refresh",
+		"/api/v1/auth/invite-queue",
+		"/api/v1/auth/invite-codes",
 		"/api/v1/auth/jwks",
 		"/api/v1/auth/oauth",
 		"/api/v1/tags/search", // Public tag search
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -59,11 +60,34 @@ func (gw *APIGateway) ProxyToAuth(c *gin.Context) {
 	gw.proxyRequest(c, gw.authService, "/api/v1/auth")
 }
 
-// ProxyToWork forwards requests to the work service
+// ProxyToWork forwards requests to the work service, except for the work
+// stats routes the stats service serves
 func (gw *APIGateway) ProxyToWork(c *gin.Context) {
+	if isStatsPath(c.Request.URL.Path) {
+		gw.proxyRequest(c, gw.statsService, "/api/v1/works")
+		return
+	}
 	gw.proxyRequest(c, gw.workService, "/api/v1/works")
 }
 
+// isStatsPath reports whether a works path belongs to the stats service:
+// /api/v1/works/:work_id/stats, /api/v1/works/trending and
+// /api/v1/works/milestone-settings
+func isStatsPath(path string) bool {
+	rest, ok := strings.CutPrefix(path, "/api/v1/works/")
+	if !ok {
+		return false
+	}
+	parts := strings.Split(strings.TrimSuffix(rest, "/"), "/")
+	switch len(parts) {
+	case 1:
+		return parts[0] == "trending" || parts[0] == "milestone-settings"
+	case 2:
+		return parts[0] != "" && parts[1] == "stats"
+	}
+	return false
+}
+
 // ProxyToTag forwards requests to the tag service
 func (gw *APIGateway) ProxyToTag(c *gin.Context) {
 	gw.proxyRequest(c, gw.tagService, "/api/v1/tags")
@@ -78,6 +102,16 @@ func (gw *APIGateway) ProxyToSearch(c *gin.Context) {
 func (gw *APIGateway) proxyRequest(c *gin.Context, service *ServiceClient, basePath string) {
 	start := time.Now()
 
+	// Send the request to the service's canary if it's selected, and count
+	// the outcome against whichever upstream served it
+	serviceName := service.Name
+	service, upstream := gw.pickUpstream(c, service)
+	if gw.metrics != nil {
+		defer func() {
+			gw.metrics.RecordUpstreamRequest(serviceName, upstream, c.Writer.Status(), time.Since(start))
+		}()
+	}
+
 	// Check service health
 	if !service.Health.IsHealthy {
 		gw.handleServiceUnavailable(c, service.Name)
@@ -88,13 +122,19 @@ func (gw *APIGateway) proxyRequest(c *gin.Context, service *ServiceClient, baseP
 	var targetURL string
 	requestPath := c.Request.URL.Path
 
-	// For /my, /users, /series, /collections, /bookmarks, /comments, /pseuds routes,
-	// we want to preserve the full API path structure
-	if strings.HasPrefix(requestPath, "/api/v1/my/") ||
+	// For /my, /users, /series, /collections, /bookmarks, /bookmark-lists,
+	// /comments, /pseuds, /saved-searches and /share routes, we want to
+	// preserve the full API path structure
+	if requestPath == "/api/v1/share" ||
+		requestPath == "/api/v1/saved-searches" ||
+		strings.HasPrefix(requestPath, "/api/v1/saved-searches/") ||
+		strings.HasPrefix(requestPath, "/api/v1/my/") ||
 		strings.HasPrefix(requestPath, "/api/v1/users/") ||
 		strings.HasPrefix(requestPath, "/api/v1/series/") ||
 		strings.HasPrefix(requestPath, "/api/v1/collections/") ||
 		strings.HasPrefix(requestPath, "/api/v1/bookmarks/") ||
+		requestPath == "/api/v1/bookmark-lists" ||
+		strings.HasPrefix(requestPath, "/api/v1/bookmark-lists/") ||
 		strings.HasPrefix(requestPath, "/api/v1/comments/") ||
 		strings.HasPrefix(requestPath, "/api/v1/pseuds/") {
 		// Use the full request path for these routes
@@ -137,6 +177,11 @@ func (gw *APIGateway) proxyRequest(c *gin.Context, service *ServiceClient, baseP
 		var err error
 		bodyBytes, err = io.ReadAll(c.Request.Body)
 		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large", "max_bytes": tooLarge.Limit})
+				return
+			}
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
 			return
 		}
@@ -159,6 +204,12 @@ func (gw *APIGateway) proxyRequest(c *gin.Context, service *ServiceClient, baseP
 	req.Header.Set("X-Forwarded-Host", c.Request.Host)
 	req.Header.Set("X-Gateway-Request-ID", c.GetHeader("X-Request-ID"))
 
+	// Clients can't claim a country; only the gateway's lookup is forwarded
+	req.Header.Del(clientCountryHeader)
+	if country := gw.geoip.Lookup(c.ClientIP()); country != "" {
+		req.Header.Set(clientCountryHeader, country)
+	}
+
 	// Forward user context if available
 	if userID := c.GetHeader("X-User-ID"); userID != "" {
 		req.Header.Set("X-User-ID", userID)
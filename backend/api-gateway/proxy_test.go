@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestIsStatsPath(t *testing.T) {
+	cases := map[string]bool{
+		"/api/v1/works/trending":           true,
+		"/api/v1/works/milestone-settings": true,
+		"/api/v1/works/123/stats":          true,
+		"/api/v1/works/123/stats/":         true,
+		"/api/v1/works":                    false,
+		"/api/v1/works/123":                false,
+		"/api/v1/works//stats":             false,
+		"/api/v1/works/123/chapters":       false,
+		"/api/v1/works/123/chapters/stats": false,
+		"/api/v1/works/trending/123":       false,
+		"/api/v1/search/works/123/stats":   false,
+	}
+	for path, want := range cases {
+		if got := isStatsPath(path); got != want {
+			t.Errorf("isStatsPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
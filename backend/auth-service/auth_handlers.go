@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
+	"nuclear-ao3/shared/authsession"
 	"nuclear-ao3/shared/models"
 
 	"github.com/gin-gonic/gin"
@@ -28,23 +31,59 @@ func (as *AuthService) Register(c *gin.Context) {
 		return
 	}
 
+	// While registration is invite-only, a code is required
+	ctx := c.Request.Context()
+	settings, err := loadInvitationSettings(ctx, as.db, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	if settings.InviteRequired && req.InviteCode == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invite_required"})
+		return
+	}
+
 	// Create user
 	userID := uuid.New()
 	now := time.Now()
 
+	tx, err := as.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	defer tx.Rollback()
+
 	// Insert user into database
 	query := `
 		INSERT INTO users (id, username, email, password_hash, display_name, is_active, is_verified, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, true, false, $6, $7)`
 
-	_, err = as.db.Exec(query, userID, req.Username, req.Email, string(hashedPassword), req.DisplayName, now, now)
+	_, err = tx.ExecContext(ctx, query, userID, req.Username, req.Email, string(hashedPassword), req.DisplayName, now, now)
 	if err != nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "user_exists"})
 		return
 	}
 
-	// Generate tokens
-	accessToken, err := as.jwt.GenerateToken(userID, "nuclear-ao3", []string{"user"}, 30*24*time.Hour) // 30 days
+	// The invitation is used up with the account, so a failed
+	// registration doesn't spend it
+	if req.InviteCode != "" {
+		if err := redeemInvitation(ctx, tx, req.InviteCode, userID); err != nil {
+			if errors.Is(err, errInvalidInvitation) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "invalid_invite"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	// Sign the new user in on this device
+	tokens, err := as.startSession(c, userID, false)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "token_generation_failed"})
 		return
@@ -62,13 +101,8 @@ func (as *AuthService) Register(c *gin.Context) {
 		UpdatedAt:   now,
 	}
 
-	c.JSON(http.StatusCreated, models.AuthResponse{
-		User:         user,
-		AccessToken:  accessToken,
-		RefreshToken: "dummy_refresh_token", // For testing
-		TokenType:    "Bearer",
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour).Unix(), // 30 days
-	})
+	tokens.User = user
+	c.JSON(http.StatusCreated, tokens)
 }
 
 // Login handles user authentication
@@ -101,26 +135,28 @@ func (as *AuthService) Login(c *gin.Context) {
 		return
 	}
 
-	// Generate access token
-	accessToken, err := as.jwt.GenerateToken(user.ID, "nuclear-ao3", []string{"user"}, 30*24*time.Hour) // 30 days
+	if !user.IsActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "account_disabled"})
+		return
+	}
+
+	// Each login is its own session, so it can be listed and revoked
+	tokens, err := as.startSession(c, user.ID, req.Remember)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "token_generation_failed"})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.AuthResponse{
-		User:         &user,
-		AccessToken:  accessToken,
-		RefreshToken: "dummy_refresh_token",
-		TokenType:    "Bearer",
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour).Unix(), // 30 days
-	})
+	tokens.User = &user
+	c.JSON(http.StatusOK, tokens)
 }
 
-// RefreshToken handles token refresh for session extension
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. The old refresh token stops working; presenting it again
+// is treated as theft and signs the session out.
 func (as *AuthService) RefreshToken(c *gin.Context) {
 	var req struct {
-		RefreshToken string `json:"refresh_token"`
+		RefreshToken string `json:"refresh_token" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -128,29 +164,35 @@ func (as *AuthService) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// For now, since we're using dummy refresh tokens, we'll implement a simple validation
-	// In production, refresh tokens should be stored in database with expiration
-	if req.RefreshToken != "dummy_refresh_token" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_refresh_token"})
+	ctx := c.Request.Context()
+	session, refreshToken, err := as.sessions.Refresh(ctx, req.RefreshToken, clientOf(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, authsession.ErrRefreshTokenReused):
+			log.Printf("Refresh token reuse detected from %s; session revoked", c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh_token_reused"})
+		case errors.Is(err, authsession.ErrInvalidRefreshToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_refresh_token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		}
 		return
 	}
 
-	// Extract user ID from the current token context (simplified)
-	// In production, you'd validate the refresh token against the database
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	// A disabled account can't keep its sessions alive
+	var isActive bool
+	if err := as.db.QueryRowContext(ctx, "SELECT is_active FROM users WHERE id = $1", session.UserID).Scan(&isActive); err != nil || !isActive {
+		as.sessions.Revoke(ctx, session.UserID, session.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_refresh_token"})
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr.(string))
+	roles, err := as.activeRoles(ctx, session.UserID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
 		return
 	}
-
-	// Generate new access token (shorter TTL since it can be refreshed)
-	accessToken, err := as.jwt.GenerateToken(userID, "nuclear-ao3", []string{"user"}, 15*time.Minute)
+	accessToken, err := as.jwt.GenerateSessionToken(session.UserID, session.ID, "nuclear-ao3", []string{"user"}, roles, accessTokenTTL)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "token_generation_failed"})
 		return
@@ -158,9 +200,9 @@ func (as *AuthService) RefreshToken(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"access_token":  accessToken,
-		"refresh_token": "dummy_refresh_token", // In production, generate new refresh token
+		"refresh_token": refreshToken,
 		"token_type":    "Bearer",
-		"expires_in":    900, // 15 minutes in seconds
+		"expires_in":    int(accessTokenTTL.Seconds()),
 	})
 }
 
@@ -180,13 +222,27 @@ func (as *AuthService) ResendVerification(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "verification resent"})
 }
 
+// Logout ends the session the request was made with
 func (as *AuthService) Logout(c *gin.Context) {
+	if sid, err := uuid.Parse(c.GetString("session_id")); err == nil {
+		userID := c.MustGet("user_id").(uuid.UUID)
+		if _, err := as.sessions.Revoke(c.Request.Context(), userID, sid); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+			return
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
 
+// GetProfile reports who the token belongs to. Other services validate
+// tokens here, and enforce roles and scopes from the response.
 func (as *AuthService) GetProfile(c *gin.Context) {
 	userID, _ := c.Get("user_id")
-	c.JSON(http.StatusOK, gin.H{"user_id": userID})
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": userID,
+		"roles":   c.GetStringSlice("roles"),
+		"scopes":  c.GetStringSlice("scopes"),
+	})
 }
 
 func (as *AuthService) UpdateProfile(c *gin.Context) {
@@ -197,14 +253,6 @@ func (as *AuthService) ChangePassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "password changed"})
 }
 
-func (as *AuthService) GetSessions(c *gin.Context) {
-	c.JSON(http.StatusOK, []models.UserSession{})
-}
-
-func (as *AuthService) RevokeSession(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
-}
-
 func (as *AuthService) GetSecurityEvents(c *gin.Context) {
 	c.JSON(http.StatusOK, []models.SecurityEvent{})
 }
@@ -221,14 +269,6 @@ func (as *AuthService) UpdateUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "user updated"})
 }
 
-func (as *AuthService) GrantRole(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "role granted"})
-}
-
-func (as *AuthService) RevokeRole(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "role revoked"})
-}
-
 func (as *AuthService) GetAllSecurityEvents(c *gin.Context) {
 	c.JSON(http.StatusOK, []models.SecurityEvent{})
 }
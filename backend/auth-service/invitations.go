@@ -0,0 +1,820 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/middleware"
+)
+
+// Invitations: while invite_required is on, registering takes an invitation
+// code. People without one join the request queue, which is issued codes in
+// batches on the admin-controlled schedule in invitation_settings
+// (migration 063). Users whose accounts are old enough can invite friends,
+// a few a month, and admins can issue codes directly. auth-service doesn't
+// send email itself: codes addressed to an email wait at the internal
+// undelivered endpoint until the mailer marks them delivered.
+
+// errInvalidInvitation is returned when a code can't be redeemed
+var errInvalidInvitation = errors.New("invitation is invalid, expired or already used")
+
+// inviteCodeEncoding spells codes in unambiguous upper case
+var inviteCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+const (
+	// userInviteWindow is the period user_invites_per_month counts over
+	userInviteWindow = 30 * 24 * time.Hour
+	// adminInviteMax bounds the codes an admin issues in one request
+	adminInviteMax = 500
+)
+
+// InvitationSettings is the single row of invitation_settings
+type InvitationSettings struct {
+	InviteRequired      bool       `json:"invite_required"`
+	QueueEnabled        bool       `json:"queue_enabled"`
+	QueueBatchSize      int        `json:"queue_batch_size"`
+	QueueIntervalHours  int        `json:"queue_interval_hours"`
+	InviteExpiryDays    int        `json:"invite_expiry_days"`
+	UserInvitesEnabled  bool       `json:"user_invites_enabled"`
+	UserMinAccountDays  int        `json:"user_min_account_days"`
+	UserInvitesPerMonth int        `json:"user_invites_per_month"`
+	LastQueueIssueAt    *time.Time `json:"last_queue_issue_at,omitempty"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// nextQueueIssue is when the queue is next due a batch
+func (s *InvitationSettings) nextQueueIssue() time.Time {
+	if s.LastQueueIssueAt == nil {
+		return time.Time{}
+	}
+	return s.LastQueueIssueAt.Add(time.Duration(s.QueueIntervalHours) * time.Hour)
+}
+
+// Invitation is one invitation code
+type Invitation struct {
+	ID           uuid.UUID  `json:"id"`
+	Code         string     `json:"code"`
+	Source       string     `json:"source"`
+	CreatorID    *uuid.UUID `json:"creator_id,omitempty"`
+	InviteeEmail string     `json:"invitee_email,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+	RedeemedAt   *time.Time `json:"redeemed_at,omitempty"`
+	RedeemedBy   *uuid.UUID `json:"redeemed_by,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	Status       string     `json:"status"`
+}
+
+// invitationStatus is active, redeemed, revoked or expired
+func invitationStatus(inv *Invitation, now time.Time) string {
+	switch {
+	case inv.RedeemedAt != nil:
+		return "redeemed"
+	case inv.RevokedAt != nil:
+		return "revoked"
+	case !now.Before(inv.ExpiresAt):
+		return "expired"
+	default:
+		return "active"
+	}
+}
+
+// inviteAllowance is whether a user can invite friends, and how many more
+type inviteAllowance struct {
+	Eligible   bool       `json:"eligible"`
+	Reason     string     `json:"reason,omitempty"`
+	EligibleAt *time.Time `json:"eligible_at,omitempty"`
+	Remaining  int        `json:"remaining"`
+	PerMonth   int        `json:"per_month"`
+}
+
+// userInviteAllowance works out a user's allowance from when they joined
+// and how many invitations they've created in the last 30 days
+func userInviteAllowance(settings *InvitationSettings, joinedAt time.Time, usedThisMonth int, now time.Time) inviteAllowance {
+	allowance := inviteAllowance{PerMonth: settings.UserInvitesPerMonth}
+	if !settings.UserInvitesEnabled {
+		allowance.Reason = "Inviting friends is turned off"
+		return allowance
+	}
+	if eligibleAt := joinedAt.AddDate(0, 0, settings.UserMinAccountDays); now.Before(eligibleAt) {
+		allowance.Reason = "Your account is too new to invite friends"
+		allowance.EligibleAt = &eligibleAt
+		return allowance
+	}
+	allowance.Remaining = settings.UserInvitesPerMonth - usedThisMonth
+	if allowance.Remaining <= 0 {
+		allowance.Remaining = 0
+		allowance.Reason = "You've used this month's invitations"
+		return allowance
+	}
+	allowance.Eligible = true
+	return allowance
+}
+
+// generateInviteCode returns a random 16-character code
+func generateInviteCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return inviteCodeEncoding.EncodeToString(b), nil
+}
+
+// normalizeInviteCode forgives case and stray whitespace in a typed code
+func normalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// invitationQuerier is a *sql.DB or *sql.Tx
+type invitationQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+const invitationSettingsColumns = `invite_required, queue_enabled, queue_batch_size, queue_interval_hours,
+	invite_expiry_days, user_invites_enabled, user_min_account_days, user_invites_per_month,
+	last_queue_issue_at, updated_at`
+
+const invitationColumns = `id, code, source, creator_id, COALESCE(invitee_email, ''), created_at, expires_at,
+	delivered_at, redeemed_at, redeemed_by, revoked_at`
+
+// loadInvitationSettings reads the settings, locking the row when forUpdate
+func loadInvitationSettings(ctx context.Context, q invitationQuerier, forUpdate bool) (*InvitationSettings, error) {
+	query := "SELECT " + invitationSettingsColumns + " FROM invitation_settings WHERE id"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var s InvitationSettings
+	err := q.QueryRowContext(ctx, query).Scan(&s.InviteRequired, &s.QueueEnabled, &s.QueueBatchSize,
+		&s.QueueIntervalHours, &s.InviteExpiryDays, &s.UserInvitesEnabled, &s.UserMinAccountDays,
+		&s.UserInvitesPerMonth, &s.LastQueueIssueAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func scanInvitation(row interface{ Scan(...interface{}) error }) (*Invitation, error) {
+	var inv Invitation
+	err := row.Scan(&inv.ID, &inv.Code, &inv.Source, &inv.CreatorID, &inv.InviteeEmail, &inv.CreatedAt,
+		&inv.ExpiresAt, &inv.DeliveredAt, &inv.RedeemedAt, &inv.RedeemedBy, &inv.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	inv.Status = invitationStatus(&inv, time.Now())
+	return &inv, nil
+}
+
+func queryInvitations(ctx context.Context, q invitationQuerier, query string, args ...interface{}) ([]*Invitation, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// createInvitation issues a code that expires after the configured days
+func createInvitation(ctx context.Context, q invitationQuerier, source string, creatorID *uuid.UUID, email string, expiryDays int) (*Invitation, error) {
+	code, err := generateInviteCode()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	inv := &Invitation{
+		ID:           uuid.New(),
+		Code:         code,
+		Source:       source,
+		CreatorID:    creatorID,
+		InviteeEmail: email,
+		CreatedAt:    now,
+		ExpiresAt:    now.AddDate(0, 0, expiryDays),
+		Status:       "active",
+	}
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO invitations (id, code, source, creator_id, invitee_email, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)`,
+		inv.ID, inv.Code, inv.Source, inv.CreatorID, inv.InviteeEmail, inv.CreatedAt, inv.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// redeemInvitation uses up a code for a new account, as part of the
+// registration transaction
+func redeemInvitation(ctx context.Context, tx *sql.Tx, code string, userID uuid.UUID) error {
+	var id uuid.UUID
+	err := tx.QueryRowContext(ctx, `
+		UPDATE invitations SET redeemed_at = NOW(), redeemed_by = $2
+		WHERE code = $1 AND redeemed_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING id`, normalizeInviteCode(code), userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return errInvalidInvitation
+	}
+	return err
+}
+
+// issueQueuedInvitations gives the oldest waiting requests a code each,
+// returning how many it issued. A count of 0 means the configured batch
+// size. With onlyIfDue it does nothing unless the queue is enabled and a
+// batch is due; the settings row is locked so replicas don't both issue.
+func (as *AuthService) issueQueuedInvitations(ctx context.Context, count int, onlyIfDue bool) (int, error) {
+	tx, err := as.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	settings, err := loadInvitationSettings(ctx, tx, true)
+	if err != nil {
+		return 0, err
+	}
+	if onlyIfDue && (!settings.QueueEnabled || time.Now().Before(settings.nextQueueIssue())) {
+		return 0, nil
+	}
+	if count <= 0 {
+		count = settings.QueueBatchSize
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, email FROM invite_requests
+		WHERE invited_at IS NULL
+		ORDER BY requested_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, count)
+	if err != nil {
+		return 0, err
+	}
+	type waitingRequest struct {
+		id    uuid.UUID
+		email string
+	}
+	waiting := []waitingRequest{}
+	for rows.Next() {
+		var r waitingRequest
+		if err := rows.Scan(&r.id, &r.email); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		waiting = append(waiting, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range waiting {
+		inv, err := createInvitation(ctx, tx, "queue", nil, r.email, settings.InviteExpiryDays)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE invite_requests SET invitation_id = $2, invited_at = NOW() WHERE id = $1", r.id, inv.ID); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE invitation_settings SET last_queue_issue_at = NOW() WHERE id"); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(waiting), nil
+}
+
+// startInviteQueueIssuer checks every interval whether the queue is due a
+// batch, until ctx is cancelled
+func (as *AuthService) startInviteQueueIssuer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Invitation queue issuer started, checking every %s", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Invitation queue issuer stopped")
+			return
+		case <-ticker.C:
+			issued, err := as.issueQueuedInvitations(ctx, 0, true)
+			if err != nil {
+				log.Printf("Invitation queue issue failed: %v", err)
+			} else if issued > 0 {
+				log.Printf("Issued %d invitations from the queue", issued)
+			}
+		}
+	}
+}
+
+// queuePosition is a waiting request's place in the queue, from 1
+func (as *AuthService) queuePosition(ctx context.Context, requestedAt time.Time) (int, error) {
+	var ahead int
+	err := as.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM invite_requests WHERE invited_at IS NULL AND requested_at < $1", requestedAt).Scan(&ahead)
+	return ahead + 1, err
+}
+
+// inviteQueueStatus describes a request: its position and estimated
+// invitation date while waiting, or when it was invited
+func (as *AuthService) inviteQueueStatus(ctx context.Context, settings *InvitationSettings, email string) (gin.H, error) {
+	var requestedAt time.Time
+	var invitedAt *time.Time
+	err := as.db.QueryRowContext(ctx,
+		"SELECT requested_at, invited_at FROM invite_requests WHERE LOWER(email) = LOWER($1)", email).Scan(&requestedAt, &invitedAt)
+	if err != nil {
+		return nil, err
+	}
+	if invitedAt != nil {
+		return gin.H{"email": email, "status": "invited", "requested_at": requestedAt, "invited_at": invitedAt}, nil
+	}
+
+	position, err := as.queuePosition(ctx, requestedAt)
+	if err != nil {
+		return nil, err
+	}
+	status := gin.H{"email": email, "status": "waiting", "requested_at": requestedAt, "position": position}
+	if settings.QueueEnabled && settings.QueueBatchSize > 0 {
+		// Batches still to come before this request's, the next included
+		batches := (position - 1) / settings.QueueBatchSize
+		next := settings.nextQueueIssue()
+		if next.Before(time.Now()) {
+			next = time.Now()
+		}
+		status["estimated_invite_at"] = next.Add(time.Duration(batches*settings.QueueIntervalHours) * time.Hour)
+	}
+	return status, nil
+}
+
+type inviteQueueRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// RequestInvitation joins the invitation queue:
+// POST /api/v1/auth/invite-queue
+func (as *AuthService) RequestInvitation(c *gin.Context) {
+	var req inviteQueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid email address is required"})
+		return
+	}
+	ctx := c.Request.Context()
+	email := strings.TrimSpace(req.Email)
+
+	settings, err := loadInvitationSettings(ctx, as.db, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation settings"})
+		return
+	}
+	if !settings.QueueEnabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The invitation queue is closed"})
+		return
+	}
+
+	var registered bool
+	if err := as.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = LOWER($1))", email).Scan(&registered); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join the queue"})
+		return
+	}
+	if registered {
+		c.JSON(http.StatusConflict, gin.H{"error": "An account already uses this email address"})
+		return
+	}
+
+	result, err := as.db.ExecContext(ctx, `
+		INSERT INTO invite_requests (email) VALUES ($1)
+		ON CONFLICT ((LOWER(email))) DO NOTHING`, email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join the queue"})
+		return
+	}
+	joined, _ := result.RowsAffected()
+
+	status, err := as.inviteQueueStatus(ctx, settings, email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up queue position"})
+		return
+	}
+	code := http.StatusOK
+	if joined > 0 {
+		code = http.StatusCreated
+	}
+	c.JSON(code, status)
+}
+
+// GetInviteQueueStatus reports where an email is in the queue:
+// GET /api/v1/auth/invite-queue?email=
+func (as *AuthService) GetInviteQueueStatus(c *gin.Context) {
+	email := strings.TrimSpace(c.Query("email"))
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+		return
+	}
+	ctx := c.Request.Context()
+	settings, err := loadInvitationSettings(ctx, as.db, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation settings"})
+		return
+	}
+	status, err := as.inviteQueueStatus(ctx, settings, email)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This email address isn't in the queue"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up queue position"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// CheckInvitation tells the registration form whether a code can be used:
+// GET /api/v1/auth/invite-codes/:code
+func (as *AuthService) CheckInvitation(c *gin.Context) {
+	ctx := c.Request.Context()
+	settings, err := loadInvitationSettings(ctx, as.db, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation settings"})
+		return
+	}
+	inv, err := scanInvitation(as.db.QueryRowContext(ctx,
+		"SELECT "+invitationColumns+" FROM invitations WHERE code = $1", normalizeInviteCode(c.Param("code"))))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, gin.H{"valid": false, "invite_required": settings.InviteRequired})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up invitation"})
+		return
+	}
+	response := gin.H{"valid": inv.Status == "active", "status": inv.Status, "invite_required": settings.InviteRequired}
+	if inv.Status == "active" {
+		response["expires_at"] = inv.ExpiresAt
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// allowanceFor loads a user's invitation allowance
+func (as *AuthService) allowanceFor(ctx context.Context, settings *InvitationSettings, userID uuid.UUID) (inviteAllowance, error) {
+	var joinedAt time.Time
+	var used int
+	err := as.db.QueryRowContext(ctx, `
+		SELECT u.created_at, (
+			SELECT COUNT(*) FROM invitations
+			WHERE creator_id = u.id AND source = 'user' AND revoked_at IS NULL AND created_at > $2
+		)
+		FROM users u WHERE u.id = $1`, userID, time.Now().Add(-userInviteWindow)).Scan(&joinedAt, &used)
+	if err != nil {
+		return inviteAllowance{}, err
+	}
+	return userInviteAllowance(settings, joinedAt, used, time.Now()), nil
+}
+
+// GetMyInvitations lists the invitations a user has created and how many
+// more they can send: GET /api/v1/auth/invitations
+func (as *AuthService) GetMyInvitations(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	ctx := c.Request.Context()
+
+	settings, err := loadInvitationSettings(ctx, as.db, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation settings"})
+		return
+	}
+	allowance, err := as.allowanceFor(ctx, settings, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation allowance"})
+		return
+	}
+	invitations, err := queryInvitations(ctx, as.db,
+		"SELECT "+invitationColumns+" FROM invitations WHERE creator_id = $1 AND source = 'user' ORDER BY created_at DESC LIMIT 100",
+		userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations, "allowance": allowance})
+}
+
+type userInvitationRequest struct {
+	Email string `json:"email" binding:"omitempty,email"`
+}
+
+// CreateMyInvitation invites a friend, optionally by email:
+// POST /api/v1/auth/invitations
+func (as *AuthService) CreateMyInvitation(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	var req userInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+
+	settings, err := loadInvitationSettings(ctx, as.db, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation settings"})
+		return
+	}
+	allowance, err := as.allowanceFor(ctx, settings, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation allowance"})
+		return
+	}
+	if !allowance.Eligible {
+		c.JSON(http.StatusForbidden, gin.H{"error": allowance.Reason, "allowance": allowance})
+		return
+	}
+
+	inv, err := createInvitation(ctx, as.db, "user", &userID, strings.TrimSpace(req.Email), settings.InviteExpiryDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+	allowance.Remaining--
+	c.JSON(http.StatusCreated, gin.H{"invitation": inv, "allowance": allowance})
+}
+
+// RevokeMyInvitation withdraws an unused invitation, returning it to the
+// user's allowance: DELETE /api/v1/auth/invitations/:id
+func (as *AuthService) RevokeMyInvitation(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+	result, err := as.db.ExecContext(c.Request.Context(), `
+		UPDATE invitations SET revoked_at = NOW()
+		WHERE id = $1 AND creator_id = $2 AND source = 'user' AND redeemed_at IS NULL AND revoked_at IS NULL`,
+		id, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No unused invitation with this ID"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revoked": true})
+}
+
+// GetInvitationSettings returns the invitation settings:
+// GET /api/v1/auth/admin/invitations/settings
+func (as *AuthService) GetInvitationSettings(c *gin.Context) {
+	settings, err := loadInvitationSettings(c.Request.Context(), as.db, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"settings": settings, "next_queue_issue_at": settings.nextQueueIssue()})
+}
+
+type invitationSettingsRequest struct {
+	InviteRequired      *bool `json:"invite_required"`
+	QueueEnabled        *bool `json:"queue_enabled"`
+	QueueBatchSize      *int  `json:"queue_batch_size" binding:"omitempty,min=0,max=10000"`
+	QueueIntervalHours  *int  `json:"queue_interval_hours" binding:"omitempty,min=1,max=720"`
+	InviteExpiryDays    *int  `json:"invite_expiry_days" binding:"omitempty,min=1,max=365"`
+	UserInvitesEnabled  *bool `json:"user_invites_enabled"`
+	UserMinAccountDays  *int  `json:"user_min_account_days" binding:"omitempty,min=0,max=3650"`
+	UserInvitesPerMonth *int  `json:"user_invites_per_month" binding:"omitempty,min=0,max=100"`
+}
+
+// UpdateInvitationSettings changes the settings given:
+// PUT /api/v1/auth/admin/invitations/settings
+func (as *AuthService) UpdateInvitationSettings(c *gin.Context) {
+	var req invitationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+
+	_, err := as.db.ExecContext(ctx, `
+		UPDATE invitation_settings SET
+			invite_required = COALESCE($1, invite_required),
+			queue_enabled = COALESCE($2, queue_enabled),
+			queue_batch_size = COALESCE($3, queue_batch_size),
+			queue_interval_hours = COALESCE($4, queue_interval_hours),
+			invite_expiry_days = COALESCE($5, invite_expiry_days),
+			user_invites_enabled = COALESCE($6, user_invites_enabled),
+			user_min_account_days = COALESCE($7, user_min_account_days),
+			user_invites_per_month = COALESCE($8, user_invites_per_month),
+			updated_at = NOW(), updated_by = $9
+		WHERE id`,
+		req.InviteRequired, req.QueueEnabled, req.QueueBatchSize, req.QueueIntervalHours, req.InviteExpiryDays,
+		req.UserInvitesEnabled, req.UserMinAccountDays, req.UserInvitesPerMonth, actingAdmin(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invitation settings"})
+		return
+	}
+	settings, err := loadInvitationSettings(ctx, as.db, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"settings": settings, "next_queue_issue_at": settings.nextQueueIssue()})
+}
+
+// ListInviteQueue lists waiting requests oldest first:
+// GET /api/v1/auth/admin/invite-queue
+func (as *AuthService) ListInviteQueue(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	ctx := c.Request.Context()
+
+	var waiting int
+	if err := as.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM invite_requests WHERE invited_at IS NULL").Scan(&waiting); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the queue"})
+		return
+	}
+	rows, err := as.db.QueryContext(ctx, `
+		SELECT id, email, requested_at FROM invite_requests
+		WHERE invited_at IS NULL
+		ORDER BY requested_at
+		LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the queue"})
+		return
+	}
+	defer rows.Close()
+
+	requests := []gin.H{}
+	for rows.Next() {
+		var id uuid.UUID
+		var email string
+		var requestedAt time.Time
+		if err := rows.Scan(&id, &email, &requestedAt); err != nil {
+			continue
+		}
+		requests = append(requests, gin.H{
+			"id": id, "email": email, "requested_at": requestedAt, "position": offset + len(requests) + 1,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"waiting": waiting, "requests": requests, "limit": limit, "offset": offset})
+}
+
+type issueQueueRequest struct {
+	Count int `json:"count" binding:"omitempty,min=1,max=10000"`
+}
+
+// IssueQueueInvitations issues a batch to the queue now, the configured
+// batch size unless count is given: POST /api/v1/auth/admin/invite-queue/issue
+// With ?dry_run=true it only counts who would be invited.
+func (as *AuthService) IssueQueueInvitations(c *gin.Context) {
+	var req issueQueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+
+	if middleware.IsDryRun(c) {
+		settings, err := loadInvitationSettings(ctx, as.db, false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation settings"})
+			return
+		}
+		count := req.Count
+		if count == 0 {
+			count = settings.QueueBatchSize
+		}
+		var waiting int
+		if err := as.db.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM invite_requests WHERE invited_at IS NULL").Scan(&waiting); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the queue"})
+			return
+		}
+		if waiting > count {
+			waiting = count
+		}
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "issued": waiting})
+		return
+	}
+
+	issued, err := as.issueQueuedInvitations(ctx, req.Count, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue invitations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"issued": issued})
+}
+
+type adminInvitationRequest struct {
+	Count int    `json:"count" binding:"omitempty,min=1,max=500"`
+	Email string `json:"email" binding:"omitempty,email"`
+}
+
+// AdminCreateInvitations issues codes directly, one for an email or count
+// unaddressed ones: POST /api/v1/auth/admin/invitations
+func (as *AuthService) AdminCreateInvitations(c *gin.Context) {
+	var req adminInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	count := req.Count
+	if count == 0 || req.Email != "" {
+		count = 1
+	}
+	if count > adminInviteMax {
+		count = adminInviteMax
+	}
+	ctx := c.Request.Context()
+
+	settings, err := loadInvitationSettings(ctx, as.db, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitation settings"})
+		return
+	}
+	tx, err := as.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitations"})
+		return
+	}
+	defer tx.Rollback()
+
+	invitations := make([]*Invitation, 0, count)
+	for i := 0; i < count; i++ {
+		inv, err := createInvitation(ctx, tx, "admin", actingAdmin(c), strings.TrimSpace(req.Email), settings.InviteExpiryDays)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitations"})
+			return
+		}
+		invitations = append(invitations, inv)
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitations"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"invitations": invitations})
+}
+
+// GetUndeliveredInvitations lists live codes addressed to an email that
+// haven't been sent yet, for the mailer:
+// GET /api/v1/internal/invitations/undelivered
+func (as *AuthService) GetUndeliveredInvitations(c *gin.Context) {
+	invitations, err := queryInvitations(c.Request.Context(), as.db, `
+		SELECT `+invitationColumns+` FROM invitations
+		WHERE delivered_at IS NULL AND invitee_email IS NOT NULL AND revoked_at IS NULL
+			AND redeemed_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at
+		LIMIT 100`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invitations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// MarkInvitationDelivered records that a code has been emailed:
+// POST /api/v1/internal/invitations/:id/delivered
+func (as *AuthService) MarkInvitationDelivered(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+	result, err := as.db.ExecContext(c.Request.Context(),
+		"UPDATE invitations SET delivered_at = COALESCE(delivered_at, NOW()) WHERE id = $1", id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invitation"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"delivered": true})
+}
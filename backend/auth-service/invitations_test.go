@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserInviteAllowance(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	settings := &InvitationSettings{UserInvitesEnabled: true, UserMinAccountDays: 30, UserInvitesPerMonth: 3}
+
+	newAccount := userInviteAllowance(settings, now.AddDate(0, 0, -10), 0, now)
+	assert.False(t, newAccount.Eligible)
+	require.NotNil(t, newAccount.EligibleAt)
+	assert.Equal(t, now.AddDate(0, 0, 20), *newAccount.EligibleAt)
+
+	established := userInviteAllowance(settings, now.AddDate(-1, 0, 0), 1, now)
+	assert.True(t, established.Eligible)
+	assert.Equal(t, 2, established.Remaining)
+
+	usedUp := userInviteAllowance(settings, now.AddDate(-1, 0, 0), 3, now)
+	assert.False(t, usedUp.Eligible)
+	assert.Equal(t, 0, usedUp.Remaining)
+
+	settings.UserInvitesEnabled = false
+	assert.False(t, userInviteAllowance(settings, now.AddDate(-1, 0, 0), 0, now).Eligible)
+}
+
+func TestInvitationStatus(t *testing.T) {
+	now := time.Now()
+	inv := &Invitation{ExpiresAt: now.Add(time.Hour)}
+	assert.Equal(t, "active", invitationStatus(inv, now))
+	assert.Equal(t, "expired", invitationStatus(inv, now.Add(2*time.Hour)))
+
+	inv.RevokedAt = &now
+	assert.Equal(t, "revoked", invitationStatus(inv, now))
+	inv.RedeemedAt = &now
+	assert.Equal(t, "redeemed", invitationStatus(inv, now))
+}
+
+func TestInviteCodes(t *testing.T) {
+	code, err := generateInviteCode()
+	require.NoError(t, err)
+	assert.Len(t, code, 16)
+	assert.Equal(t, strings.ToUpper(code), code)
+	assert.Equal(t, code, normalizeInviteCode("  "+strings.ToLower(code)+"\n"))
+
+	other, err := generateInviteCode()
+	require.NoError(t, err)
+	assert.NotEqual(t, code, other)
+}
+
+func TestNextQueueIssue(t *testing.T) {
+	settings := &InvitationSettings{QueueIntervalHours: 24}
+	assert.True(t, settings.nextQueueIssue().IsZero())
+
+	last := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	settings.LastQueueIssueAt = &last
+	assert.Equal(t, last.Add(24*time.Hour), settings.nextQueueIssue())
+}
@@ -140,9 +140,43 @@ func (jm *JWTManager) GenerateToken(userID uuid.UUID, audience string, scopes []
 	return token.SignedString(jm.privateKey)
 }
 
+// GenerateSessionToken creates an access token for a signed-in session,
+// which stops being accepted once the session is revoked. roles are the
+// user's roles when it was issued, for clients deciding what to show.
+func (jm *JWTManager) GenerateSessionToken(userID, sessionID uuid.UUID, audience string, scopes, roles []string, expiresIn time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   jm.issuer,
+		"sub":   userID.String(),
+		"aud":   audience,
+		"exp":   now.Add(expiresIn).Unix(),
+		"iat":   now.Unix(),
+		"nbf":   now.Unix(),
+		"jti":   uuid.New().String(),
+		"sid":   sessionID.String(),
+		"scope": scopes,
+		"roles": roles,
+		"typ":   "Bearer",
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = jm.keyID
+
+	return token.SignedString(jm.privateKey)
+}
+
+// TokenClaims are the claims read back from a token
+type TokenClaims struct {
+	jwt.RegisteredClaims
+	// SessionID is set on tokens issued for a signed-in session
+	SessionID string   `json:"sid,omitempty"`
+	Scope     []string `json:"scope,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
 // ValidateToken validates and parses a JWT token
-func (jm *JWTManager) ValidateToken(tokenString string) (*jwt.RegisteredClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+func (jm *JWTManager) ValidateToken(tokenString string) (*TokenClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
@@ -157,7 +191,7 @@ func (jm *JWTManager) ValidateToken(tokenString string) (*jwt.RegisteredClaims,
 		return nil, fmt.Errorf("invalid token")
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*TokenClaims)
 	if !ok {
 		return nil, fmt.Errorf("invalid token claims")
 	}
@@ -16,6 +16,13 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/audit"
+	"nuclear-ao3/shared/authsession"
+	"nuclear-ao3/shared/database"
+	"nuclear-ao3/shared/metrics"
+	"nuclear-ao3/shared/middleware"
+	"nuclear-ao3/shared/schema"
 )
 
 func main() {
@@ -28,9 +35,22 @@ func main() {
 	authService := NewAuthService()
 	defer authService.Close()
 
+	// `main migrate [up|status|baseline N]` manages the shared schema and
+	// exits; otherwise pending migrations are applied before serving
+	if done, err := schema.Ready(context.Background(), authService.db, os.Args[1:]); err != nil {
+		log.Fatal("Schema is not ready: ", err)
+	} else if done {
+		return
+	}
+
 	// Setup router
 	router := setupRouter(authService)
 
+	// Issue invitations to the queue on the admin-set schedule
+	issuerCtx, stopIssuer := context.WithCancel(context.Background())
+	defer stopIssuer()
+	go authService.startInviteQueueIssuer(issuerCtx, time.Minute)
+
 	// Setup server
 	srv := &http.Server{
 		Addr:           ":" + getEnv("PORT", "8081"),
@@ -81,6 +101,12 @@ func setupRouter(authService *AuthService) *gin.Engine {
 	r.Use(LoggingMiddleware())
 	r.Use(RateLimitMiddleware(authService.redis))
 	r.Use(SecurityHeadersMiddleware())
+	readOnly := middleware.NewReadOnlyMode(authService.redis)
+	r.Use(readOnly.Middleware())
+	if authService.sessions == nil {
+		// Tests assemble the service by hand
+		authService.sessions = authsession.NewStore(authService.redis)
+	}
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
@@ -106,6 +132,9 @@ func setupRouter(authService *AuthService) *gin.Engine {
 		api.POST("/reset-password/confirm", authService.ConfirmPasswordReset)
 		api.POST("/verify-email", authService.VerifyEmail)
 		api.POST("/resend-verification", authService.ResendVerification)
+		api.POST("/invite-queue", authService.RequestInvitation)
+		api.GET("/invite-queue", authService.GetInviteQueueStatus)
+		api.GET("/invite-codes/:code", authService.CheckInvitation)
 
 		// Protected endpoints (require authentication)
 		protected := api.Group("")
@@ -117,13 +146,21 @@ func setupRouter(authService *AuthService) *gin.Engine {
 			protected.POST("/change-password", authService.ChangePassword)
 			protected.GET("/sessions", authService.GetSessions)
 			protected.DELETE("/sessions/:session_id", authService.RevokeSession)
+			protected.GET("/my/sessions", authService.GetSessions)
+			protected.DELETE("/my/sessions", authService.RevokeAllSessions)
+			protected.DELETE("/my/sessions/:session_id", authService.RevokeSession)
 			protected.GET("/security-events", authService.GetSecurityEvents)
+			protected.GET("/invitations", authService.GetMyInvitations)
+			protected.POST("/invitations", authService.CreateMyInvitation)
+			protected.DELETE("/invitations/:id", authService.RevokeMyInvitation)
 		}
 
 		// Admin endpoints
 		admin := api.Group("/admin")
 		admin.Use(JWTAuthMiddleware(authService))
 		admin.Use(RequireRoleMiddleware("admin"))
+		admin.Use(middleware.RequireScopeMiddleware(middleware.ScopeAdmin))
+		admin.Use(audit.Middleware(authService.db, auditService))
 		{
 			admin.GET("/users", authService.ListUsers)
 			admin.GET("/users/:user_id", authService.GetUser)
@@ -133,6 +170,13 @@ func setupRouter(authService *AuthService) *gin.Engine {
 			admin.GET("/security-events", authService.GetAllSecurityEvents)
 			admin.GET("/metrics", authService.GetAuthMetrics)
 
+			// Invitations
+			admin.GET("/invitations/settings", authService.GetInvitationSettings)
+			admin.PUT("/invitations/settings", authService.UpdateInvitationSettings)
+			admin.POST("/invitations", authService.AdminCreateInvitations)
+			admin.GET("/invite-queue", authService.ListInviteQueue)
+			admin.POST("/invite-queue/issue", authService.IssueQueueInvitations)
+
 			// OAuth2 client management
 			admin.GET("/oauth/clients", authService.AdminListClients)
 			admin.GET("/oauth/clients/:client_id", authService.AdminGetClient)
@@ -144,6 +188,17 @@ func setupRouter(authService *AuthService) *gin.Engine {
 		}
 	}
 
+	// Internal operational endpoints (service token, used by nuclearctl)
+	internal := r.Group("/api/v1/internal")
+	internal.Use(middleware.ServiceTokenMiddleware())
+	{
+		internal.POST("/users/:user_id/roles", authService.InternalGrantRole)
+		internal.GET("/invitations/undelivered", authService.GetUndeliveredInvitations)
+		internal.POST("/invitations/:id/delivered", authService.MarkInvitationDelivered)
+		internal.GET("/read-only", readOnly.GetReadOnlyStatus)  // GET /api/v1/internal/read-only
+		internal.POST("/read-only", readOnly.SetReadOnlyStatus) // POST /api/v1/internal/read-only {"enabled": true, "message": "..."}
+	}
+
 	// OAuth2/OIDC Discovery endpoints
 	r.GET("/.well-known/openid-configuration", authService.WellKnownOIDC)
 	r.GET("/.well-known/oauth-authorization-server", authService.WellKnownOAuth2)
@@ -192,11 +247,15 @@ func setupRouter(authService *AuthService) *gin.Engine {
 	return r
 }
 
+// auditService names this service in the audit log
+const auditService = "auth-service"
+
 // AuthService holds all dependencies for authentication
 type AuthService struct {
-	db    *sql.DB
-	redis *redis.Client
-	jwt   *JWTManager
+	db       *sql.DB
+	redis    *redis.Client
+	jwt      *JWTManager
+	sessions *authsession.Store
 }
 
 func NewAuthService() *AuthService {
@@ -217,10 +276,13 @@ func NewAuthService() *AuthService {
 		log.Fatal("Failed to ping database:", err)
 	}
 
-	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
+	// Set connection pool settings (DB_MAX_OPEN_CONNS etc. override)
+	database.PoolConfigFromEnv(database.PoolConfig{
+		MaxOpenConns:    25,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: time.Hour,
+	}).Apply(db)
+	metrics.InstrumentDBPool("auth-service", db)
 
 	// Redis connection - use test URL in test mode
 	var redisURL string
@@ -259,9 +321,10 @@ func NewAuthService() *AuthService {
 	log.Println("Auth service initialized successfully")
 
 	return &AuthService{
-		db:    db,
-		redis: rdb,
-		jwt:   jwtManager,
+		db:       db,
+		redis:    rdb,
+		jwt:      jwtManager,
+		sessions: authsession.NewStore(rdb),
 	}
 }
 
@@ -12,6 +12,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/middleware"
 )
 
 // Rate limiting types and constants
@@ -310,17 +312,58 @@ func JWTAuthMiddleware(authService *AuthService) gin.HandlerFunc {
 			return
 		}
 
+		// Tokens issued for a session stop working once it's revoked
+		if claims.SessionID != "" {
+			active, err := authService.sessions.SessionActive(c.Request.Context(), claims.SessionID)
+			if err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error":             "server_error",
+					"error_description": "Session check failed",
+				})
+				c.Abort()
+				return
+			}
+			if !active {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error":             "invalid_token",
+					"error_description": "Session has been revoked",
+				})
+				c.Abort()
+				return
+			}
+			c.Set("session_id", claims.SessionID)
+		}
+
+		// Roles are read fresh so grants and revocations apply at once
+		roles, err := authService.activeRoles(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":             "server_error",
+				"error_description": "Role lookup failed",
+			})
+			c.Abort()
+			return
+		}
+		// The user's own sessions carry the scopes of their roles; tokens
+		// issued to OAuth clients only what the user consented to
+		scopes := claims.Scope
+		if claims.SessionID != "" {
+			scopes = middleware.ScopesForRoles(roles)
+		}
+
 		c.Set("user_id", userID)
+		c.Set("roles", roles)
+		c.Set("scopes", scopes)
 		c.Set("token_claims", claims)
 		c.Next()
 	}
 }
 
-// RequireRoleMiddleware checks if user has required role
-func RequireRoleMiddleware(requiredRole string) gin.HandlerFunc {
+// RequireRoleMiddleware lets through users holding any of the given roles,
+// or admin, which can do everything
+func RequireRoleMiddleware(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		_, exists := c.Get("user_id")
-		if !exists {
+		if _, exists := c.Get("user_id"); !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":             "unauthorized",
 				"error_description": "User authentication required",
@@ -329,19 +372,16 @@ func RequireRoleMiddleware(requiredRole string) gin.HandlerFunc {
 			return
 		}
 
-		// TODO: Check user roles from database
-		// For now, just allow admin users for testing
-		if requiredRole == "admin" {
-			// In a real implementation, you'd query the database for user roles
-			c.Next()
+		if !middleware.HasAnyRole(c.GetStringSlice("roles"), roles...) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":             "insufficient_permissions",
+				"error_description": fmt.Sprintf("Role '%s' required", strings.Join(roles, "' or '")),
+			})
+			c.Abort()
 			return
 		}
 
-		c.JSON(http.StatusForbidden, gin.H{
-			"error":             "insufficient_permissions",
-			"error_description": fmt.Sprintf("Role '%s' required", requiredRole),
-		})
-		c.Abort()
+		c.Next()
 	}
 }
 
@@ -615,7 +615,7 @@ func (as *AuthService) updateTokenLastUsed(tokenID uuid.UUID) {
 }
 
 func (as *AuthService) getUserRoles(userID uuid.UUID) ([]string, error) {
-	query := `SELECT role FROM user_roles WHERE user_id = $1`
+	query := `SELECT role FROM user_roles WHERE user_id = $1 AND revoked_at IS NULL`
 	rows, err := as.db.Query(query, userID)
 	if err != nil {
 		return nil, err
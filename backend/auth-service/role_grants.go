@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/audit"
+	"nuclear-ao3/shared/middleware"
+)
+
+// grantableRoles are the roles user_roles accepts
+var grantableRoles = map[string]bool{
+	"user":         true,
+	"tag_wrangler": true,
+	"moderator":    true,
+	"admin":        true,
+}
+
+// activeRoles lists the roles a user currently holds
+func (as *AuthService) activeRoles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := as.db.QueryContext(ctx,
+		"SELECT role FROM user_roles WHERE user_id = $1 AND revoked_at IS NULL ORDER BY role", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+type roleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// grantRole gives a user a role, reinstating it if it was revoked. It
+// reports false when the user already held the role.
+func (as *AuthService) grantRole(ctx context.Context, userID uuid.UUID, role string, grantedBy *uuid.UUID) (bool, error) {
+	result, err := as.db.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role, granted_at, granted_by)
+		VALUES ($1, $2, NOW(), $3)
+		ON CONFLICT (user_id, role) DO UPDATE
+		SET granted_at = NOW(), granted_by = EXCLUDED.granted_by, revoked_at = NULL, revoked_by = NULL
+		WHERE user_roles.revoked_at IS NOT NULL`, userID, role, grantedBy)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// revokeRole takes a role from a user, keeping the row as a record. It
+// reports false when the user didn't hold the role.
+func (as *AuthService) revokeRole(ctx context.Context, userID uuid.UUID, role string, revokedBy *uuid.UUID) (bool, error) {
+	result, err := as.db.ExecContext(ctx, `
+		UPDATE user_roles SET revoked_at = NOW(), revoked_by = $3
+		WHERE user_id = $1 AND role = $2 AND revoked_at IS NULL`, userID, role, revokedBy)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// roleTarget parses the user and role of a role change request and checks
+// the user exists, writing the error response when they don't
+func (as *AuthService) roleTarget(c *gin.Context, role string) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return uuid.Nil, false
+	}
+	if !grantableRoles[role] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role", "role": role})
+		return uuid.Nil, false
+	}
+
+	var exists bool
+	if err := as.db.QueryRowContext(c.Request.Context(),
+		"SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up user"})
+		return uuid.Nil, false
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// actingAdmin is the authenticated admin making a change, recorded against it
+func actingAdmin(c *gin.Context) *uuid.UUID {
+	if userID, ok := c.Get("user_id"); ok {
+		if id, ok := userID.(uuid.UUID); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// recordRoleChange writes a role grant or revocation to the audit log. The
+// change has already been made, so a failure to record it is only logged.
+func (as *AuthService) recordRoleChange(c *gin.Context, action string, userID uuid.UUID, role string, heldBefore, heldAfter bool) {
+	err := audit.RecordRequest(c, as.db, auditService, audit.Entry{
+		Action: action, TargetType: "user", TargetID: userID.String(),
+	}, gin.H{"role": role, "held": heldBefore}, gin.H{"role": role, "held": heldAfter})
+	if err != nil {
+		log.Printf("Failed to record %s of %s for %s: %v", action, role, userID, err)
+	}
+}
+
+// GrantRole gives a user a role: POST /api/v1/auth/admin/users/:user_id/roles
+func (as *AuthService) GrantRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	userID, ok := as.roleTarget(c, req.Role)
+	if !ok {
+		return
+	}
+
+	granted, err := as.grantRole(c.Request.Context(), userID, req.Role, actingAdmin(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant role"})
+		return
+	}
+	as.recordRoleChange(c, "role.grant", userID, req.Role, !granted, true)
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": req.Role, "granted": granted})
+}
+
+// RevokeRole takes a role from a user:
+// DELETE /api/v1/auth/admin/users/:user_id/roles/:role
+func (as *AuthService) RevokeRole(c *gin.Context) {
+	role := c.Param("role")
+	userID, ok := as.roleTarget(c, role)
+	if !ok {
+		return
+	}
+	revoked, err := as.revokeRole(c.Request.Context(), userID, role, actingAdmin(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke role"})
+		return
+	}
+	as.recordRoleChange(c, "role.revoke", userID, role, revoked, false)
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": role, "revoked": revoked})
+}
+
+// InternalGrantRole gives a user a role from operational tooling, where
+// there is no admin account to record as the grantor:
+// POST /api/v1/internal/users/:user_id/roles
+func (as *AuthService) InternalGrantRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	userID, ok := as.roleTarget(c, req.Role)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	if middleware.IsDryRun(c) {
+		var held bool
+		err := as.db.QueryRowContext(ctx, `
+			SELECT EXISTS(SELECT 1 FROM user_roles WHERE user_id = $1 AND role = $2 AND revoked_at IS NULL)`,
+			userID, req.Role).Scan(&held)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up roles"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "user_id": userID, "role": req.Role, "granted": !held})
+		return
+	}
+
+	granted, err := as.grantRole(ctx, userID, req.Role, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant role"})
+		return
+	}
+	as.recordRoleChange(c, "role.grant", userID, req.Role, !granted, true)
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": req.Role, "granted": granted})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"nuclear-ao3/shared/authsession"
+	"nuclear-ao3/shared/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// accessTokenTTL is kept short; clients refresh to stay signed in
+	accessTokenTTL = 15 * time.Minute
+	// sessionLifetime is how long a session survives without a refresh
+	sessionLifetime = 24 * time.Hour
+	// rememberedSessionLifetime applies when the user asked to be remembered
+	rememberedSessionLifetime = 30 * 24 * time.Hour
+)
+
+// clientOf describes the device making a request
+func clientOf(c *gin.Context) authsession.Client {
+	return authsession.Client{UserAgent: c.Request.UserAgent(), IPAddress: c.ClientIP()}
+}
+
+// startSession signs a user in on the requesting device, returning the
+// token half of the auth response
+func (as *AuthService) startSession(c *gin.Context, userID uuid.UUID, remember bool) (models.AuthResponse, error) {
+	lifetime := sessionLifetime
+	if remember {
+		lifetime = rememberedSessionLifetime
+	}
+	session, refreshToken, err := as.sessions.Create(c.Request.Context(), userID, clientOf(c), lifetime)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+	roles, err := as.activeRoles(c.Request.Context(), userID)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+	accessToken, err := as.jwt.GenerateSessionToken(userID, session.ID, "nuclear-ao3", []string{"user"}, roles, accessTokenTTL)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+	return models.AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresAt:    time.Now().Add(accessTokenTTL).Unix(),
+	}, nil
+}
+
+// GetSessions lists the user's signed-in devices: GET /api/v1/auth/my/sessions
+func (as *AuthService) GetSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	sessions, err := as.sessions.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sessions"})
+		return
+	}
+	current := c.GetString("session_id")
+	for _, session := range sessions {
+		session.Current = session.ID.String() == current
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions, "count": len(sessions)})
+}
+
+// RevokeSession signs one device out: DELETE /api/v1/auth/my/sessions/:session_id
+func (as *AuthService) RevokeSession(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	sessionID, err := uuid.Parse(c.Param("session_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	revoked, err := as.sessions.Revoke(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeAllSessions signs every device out: DELETE /api/v1/auth/my/sessions.
+// With ?except_current=true the session making the request stays signed in.
+func (as *AuthService) RevokeAllSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	keep := uuid.Nil
+	if c.Query("except_current") == "true" {
+		if sid, err := uuid.Parse(c.GetString("session_id")); err == nil {
+			keep = sid
+		}
+	}
+
+	revoked, err := as.sessions.RevokeAll(c.Request.Context(), userID, keep)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked", "revoked": revoked})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// service names a backend nuclearctl talks to
+type service string
+
+const (
+	authService   service = "auth"
+	workService   service = "work"
+	tagService    service = "tag"
+	exportService service = "export"
+)
+
+// serviceDefaults are each service's address variable and local default,
+// matching the API gateway's
+var serviceDefaults = map[service][2]string{
+	authService:   {"AUTH_SERVICE_URL", "http://localhost:8081"},
+	workService:   {"WORK_SERVICE_URL", "http://localhost:8082"},
+	tagService:    {"TAG_SERVICE_URL", "http://localhost:8083"},
+	exportService: {"EXPORT_SERVICE_URL", "http://localhost:8086"},
+}
+
+func serviceURLsFromEnv() map[service]string {
+	urls := make(map[service]string, len(serviceDefaults))
+	for svc, def := range serviceDefaults {
+		url := os.Getenv(def[0])
+		if url == "" {
+			url = def[1]
+		}
+		urls[svc] = strings.TrimRight(url, "/")
+	}
+	return urls
+}
+
+// client calls the internal APIs and prints their responses
+type client struct {
+	services map[service]string
+	token    string
+	dryRun   bool
+	timeout  time.Duration
+	http     *http.Client
+	out      io.Writer
+}
+
+// internalURL is the address of an internal endpoint, with dry_run set when
+// requested
+func (cl *client) internalURL(svc service, path string) string {
+	url := cl.services[svc] + "/api/v1/internal" + path
+	if cl.dryRun {
+		url += "?dry_run=true"
+	}
+	return url
+}
+
+// post sends body as JSON to an internal endpoint and prints the response.
+// Responses outside 2xx are returned as errors.
+func (cl *client) post(svc service, path string, body interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cl.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cl.internalURL(svc, path), payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Service-Token", cl.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := cl.http
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s service: %w", svc, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s service: reading response: %w", svc, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s service returned %s: %s", svc, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, data, "", "  ") == nil {
+		data = pretty.Bytes()
+	}
+	fmt.Fprintln(cl.out, string(data))
+	return nil
+}
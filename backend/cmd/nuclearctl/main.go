@@ -0,0 +1,256 @@
+// Command nuclearctl runs operational tasks against the services' internal
+// APIs: reindexing works, purging caches, replaying the search outbox,
+// expiring exports, granting roles, reconciling work and tag counts and
+// switching the archive into read-only maintenance.
+//
+// Every request carries the shared service token from INTERNAL_SERVICE_TOKEN
+// (or --token). Pass --dry-run to see what a command would change without
+// changing it. Service addresses come from the same *_SERVICE_URL variables
+// the API gateway uses.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+)
+
+// command is one nuclearctl subcommand
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(cl *client, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"reindex-work", "<work-id>...", "Queue works for search reindexing", runReindexWork},
+	{"purge-cache", "--work <work-id> | --tag <tag-id>", "Drop the cached entries for a work or tag", runPurgeCache},
+	{"replay-outbox", "[--dead-letters] [--all] [work-id...]", "Replay search outbox events", runReplayOutbox},
+	{"expire-export", "<export-id>...", "Expire exports now and remove their files", runExpireExport},
+	{"grant-role", "<user-id> <role>", "Grant a role (user, tag_wrangler, moderator, admin)", runGrantRole},
+	{"reconcile-counts", "[--all] [work-id...]", "Recompute kudos, comment and bookmark counts", runReconcileCounts},
+	{"reconcile-tag-counts", "[--all] [tag-id...]", "Recompute tag use counts from their works", runReconcileTagCounts},
+	{"rebuild-autocomplete", "", "Rebuild the tag autocomplete index now", runRebuildAutocomplete},
+	{"read-only", "on [--message <text>] | off", "Switch sitewide read-only maintenance on or off", runReadOnly},
+}
+
+// errUsage marks argument errors, which print the command's usage
+var errUsage = errors.New("usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stderr)
+		return 2
+	}
+
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		fmt.Fprintf(stderr, "nuclearctl: unknown command %q\n\n", args[0])
+		printUsage(stderr)
+		return 2
+	}
+
+	cl := &client{services: serviceURLsFromEnv(), out: stdout}
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&cl.token, "token", os.Getenv("INTERNAL_SERVICE_TOKEN"), "service token (default $INTERNAL_SERVICE_TOKEN)")
+	fs.BoolVar(&cl.dryRun, "dry-run", false, "report what would change without changing it")
+	fs.DurationVar(&cl.timeout, "timeout", 30*time.Second, "timeout for each request")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: nuclearctl %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.usage, cmd.summary)
+		fs.PrintDefaults()
+	}
+
+	err := cmd.run(cl, fs, args[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 2
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "nuclearctl %s: %v\n\n", cmd.name, err)
+		fs.Usage()
+		return 2
+	default:
+		fmt.Fprintf(stderr, "nuclearctl %s: %v\n", cmd.name, err)
+		return 1
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: nuclearctl <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-21s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Every command accepts --token, --dry-run and --timeout.")
+	fmt.Fprintln(w, "Service addresses come from AUTH_SERVICE_URL, WORK_SERVICE_URL, TAG_SERVICE_URL and EXPORT_SERVICE_URL.")
+}
+
+// parse parses a command's flags and checks it got between min and max
+// positional arguments; max < 0 means no limit
+func parse(cl *client, fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if cl.token == "" {
+		return nil, fmt.Errorf("%w: no service token, set INTERNAL_SERVICE_TOKEN or pass --token", errUsage)
+	}
+	rest := fs.Args()
+	if len(rest) < min || (max >= 0 && len(rest) > max) {
+		return nil, fmt.Errorf("%w: wrong number of arguments", errUsage)
+	}
+	return rest, nil
+}
+
+func runReindexWork(cl *client, fs *flag.FlagSet, args []string) error {
+	workIDs, err := parse(cl, fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	for _, workID := range workIDs {
+		if err := cl.post(workService, "/works/"+url.PathEscape(workID)+"/reindex", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runPurgeCache(cl *client, fs *flag.FlagSet, args []string) error {
+	workID := fs.String("work", "", "work ID whose caches to purge")
+	tagID := fs.String("tag", "", "tag ID whose caches to purge")
+	if _, err := parse(cl, fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	switch {
+	case *workID != "" && *tagID != "":
+		return fmt.Errorf("%w: choose one of --work or --tag", errUsage)
+	case *workID != "":
+		return cl.post(workService, "/works/"+url.PathEscape(*workID)+"/cache/purge", nil)
+	case *tagID != "":
+		return cl.post(tagService, "/tags/"+url.PathEscape(*tagID)+"/cache/purge", nil)
+	default:
+		return fmt.Errorf("%w: --work or --tag is required", errUsage)
+	}
+}
+
+func runReplayOutbox(cl *client, fs *flag.FlagSet, args []string) error {
+	deadLetters := fs.Bool("dead-letters", false, "retry dead-lettered events")
+	all := fs.Bool("all", false, "reindex every work")
+	workIDs, err := parse(cl, fs, args, 0, -1)
+	if err != nil {
+		return err
+	}
+	if !*deadLetters && !*all && len(workIDs) == 0 {
+		return fmt.Errorf("%w: pass --dead-letters, --all or work IDs", errUsage)
+	}
+
+	body := map[string]interface{}{"dead_letters": *deadLetters, "all": *all}
+	if len(workIDs) > 0 {
+		body["work_ids"] = workIDs
+	}
+	return cl.post(workService, "/search-outbox/replay", body)
+}
+
+func runExpireExport(cl *client, fs *flag.FlagSet, args []string) error {
+	exportIDs, err := parse(cl, fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	for _, exportID := range exportIDs {
+		if err := cl.post(exportService, "/exports/"+url.PathEscape(exportID)+"/expire", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runGrantRole(cl *client, fs *flag.FlagSet, args []string) error {
+	rest, err := parse(cl, fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	return cl.post(authService, "/users/"+url.PathEscape(rest[0])+"/roles", map[string]string{"role": rest[1]})
+}
+
+func runReconcileCounts(cl *client, fs *flag.FlagSet, args []string) error {
+	all := fs.Bool("all", false, "reconcile every work")
+	workIDs, err := parse(cl, fs, args, 0, -1)
+	if err != nil {
+		return err
+	}
+	if *all == (len(workIDs) > 0) {
+		return fmt.Errorf("%w: pass either --all or work IDs", errUsage)
+	}
+
+	body := map[string]interface{}{"all": *all}
+	if len(workIDs) > 0 {
+		body["work_ids"] = workIDs
+	}
+	return cl.post(workService, "/counts/reconcile", body)
+}
+
+func runReconcileTagCounts(cl *client, fs *flag.FlagSet, args []string) error {
+	all := fs.Bool("all", false, "reconcile every tag")
+	tagIDs, err := parse(cl, fs, args, 0, -1)
+	if err != nil {
+		return err
+	}
+	if *all == (len(tagIDs) > 0) {
+		return fmt.Errorf("%w: pass either --all or tag IDs", errUsage)
+	}
+
+	body := map[string]interface{}{"all": *all}
+	if len(tagIDs) > 0 {
+		body["tag_ids"] = tagIDs
+	}
+	return cl.post(tagService, "/tags/use-counts/reconcile", body)
+}
+
+func runRebuildAutocomplete(cl *client, fs *flag.FlagSet, args []string) error {
+	if _, err := parse(cl, fs, args, 0, 0); err != nil {
+		return err
+	}
+	return cl.post(tagService, "/tags/autocomplete/rebuild", nil)
+}
+
+func runReadOnly(cl *client, fs *flag.FlagSet, args []string) error {
+	message := fs.String("message", "", "message shown to users while the archive is read-only")
+	rest, err := parse(cl, fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	switch rest[0] {
+	case "on":
+		return cl.post(authService, "/read-only", map[string]interface{}{"enabled": true, "message": *message})
+	case "off":
+		if *message != "" {
+			return fmt.Errorf("%w: --message only applies to on", errUsage)
+		}
+		return cl.post(authService, "/read-only", map[string]interface{}{"enabled": false})
+	default:
+		return fmt.Errorf("%w: expected on or off", errUsage)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type recordedRequest struct {
+	Path  string
+	Query string
+	Token string
+	Body  map[string]interface{}
+}
+
+// fakeServices points every service at one test server and records the
+// requests it receives
+func fakeServices(t *testing.T, status int) *[]recordedRequest {
+	var requests []recordedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := recordedRequest{Path: r.URL.Path, Query: r.URL.RawQuery, Token: r.Header.Get("X-Service-Token")}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			json.Unmarshal(data, &rec.Body)
+		}
+		requests = append(requests, rec)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	for _, def := range serviceDefaults {
+		t.Setenv(def[0], srv.URL+"/")
+	}
+	t.Setenv("INTERNAL_SERVICE_TOKEN", "s3cret")
+	return &requests
+}
+
+func TestCommandsCallInternalEndpoints(t *testing.T) {
+	cases := []struct {
+		args  []string
+		path  string
+		query string
+		body  map[string]interface{}
+	}{
+		{[]string{"reindex-work", "w1"}, "/api/v1/internal/works/w1/reindex", "", nil},
+		{[]string{"purge-cache", "--tag", "t1"}, "/api/v1/internal/tags/t1/cache/purge", "", nil},
+		{[]string{"purge-cache", "--dry-run", "--work", "w1"}, "/api/v1/internal/works/w1/cache/purge", "dry_run=true", nil},
+		{[]string{"replay-outbox", "--dead-letters"}, "/api/v1/internal/search-outbox/replay", "",
+			map[string]interface{}{"dead_letters": true, "all": false}},
+		{[]string{"expire-export", "--dry-run", "e1"}, "/api/v1/internal/exports/e1/expire", "dry_run=true", nil},
+		{[]string{"grant-role", "u1", "tag_wrangler"}, "/api/v1/internal/users/u1/roles", "",
+			map[string]interface{}{"role": "tag_wrangler"}},
+		{[]string{"reconcile-counts", "w1", "w2"}, "/api/v1/internal/counts/reconcile", "",
+			map[string]interface{}{"all": false, "work_ids": []interface{}{"w1", "w2"}}},
+		{[]string{"reconcile-tag-counts", "--dry-run", "--all"}, "/api/v1/internal/tags/use-counts/reconcile", "dry_run=true",
+			map[string]interface{}{"all": true}},
+		{[]string{"rebuild-autocomplete"}, "/api/v1/internal/tags/autocomplete/rebuild", "", nil},
+		{[]string{"read-only", "--message", "Back at 14:00", "on"}, "/api/v1/internal/read-only", "",
+			map[string]interface{}{"enabled": true, "message": "Back at 14:00"}},
+		{[]string{"read-only", "off"}, "/api/v1/internal/read-only", "",
+			map[string]interface{}{"enabled": false}},
+	}
+
+	for _, tc := range cases {
+		requests := fakeServices(t, http.StatusOK)
+		var stdout, stderr bytes.Buffer
+		if code := run(tc.args, &stdout, &stderr); code != 0 {
+			t.Errorf("%v: exit %d: %s", tc.args, code, stderr.String())
+			continue
+		}
+		if len(*requests) != 1 {
+			t.Errorf("%v: expected one request, got %d", tc.args, len(*requests))
+			continue
+		}
+		got := (*requests)[0]
+		if got.Path != tc.path || got.Query != tc.query || got.Token != "s3cret" {
+			t.Errorf("%v: got %s?%s token %q", tc.args, got.Path, got.Query, got.Token)
+		}
+		gotBody, _ := json.Marshal(got.Body)
+		wantBody, _ := json.Marshal(tc.body)
+		if !bytes.Equal(gotBody, wantBody) {
+			t.Errorf("%v: body %s, want %s", tc.args, gotBody, wantBody)
+		}
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	fakeServices(t, http.StatusOK)
+	usage := [][]string{
+		{"unknown-command"},
+		{"grant-role", "u1"},
+		{"purge-cache"},
+		{"purge-cache", "--work", "w1", "--tag", "t1"},
+		{"replay-outbox"},
+		{"reconcile-counts", "--all", "w1"},
+		{"reconcile-tag-counts"},
+		{"read-only"},
+		{"read-only", "maybe"},
+		{"read-only", "--message", "hi", "off"},
+	}
+	for _, args := range usage {
+		if code := run(args, io.Discard, io.Discard); code != 2 {
+			t.Errorf("%v: exit %d, want usage error", args, code)
+		}
+	}
+
+	t.Setenv("INTERNAL_SERVICE_TOKEN", "")
+	if code := run([]string{"reindex-work", "w1"}, io.Discard, io.Discard); code != 2 {
+		t.Errorf("a missing token should be a usage error, got exit %d", code)
+	}
+}
+
+func TestFailedRequestExitsNonZero(t *testing.T) {
+	fakeServices(t, http.StatusNotFound)
+	var stderr bytes.Buffer
+	if code := run([]string{"reindex-work", "missing"}, io.Discard, &stderr); code != 1 {
+		t.Errorf("exit %d, want 1", code)
+	}
+	if !bytes.Contains(stderr.Bytes(), []byte("404")) {
+		t.Errorf("expected the status in the error, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,11 @@
+// Package e2e contains the cross-service end-to-end test suite.
+//
+// The suite talks to a running stack over HTTP and to its Postgres instance
+// directly for fixture seeding. It is skipped unless E2E=1 is set. With
+// E2E_COMPOSE=1 the harness also boots docker-compose.e2e.yml before the run
+// and tears it down afterwards; otherwise it expects the stack to already be
+// up (see the header of docker-compose.e2e.yml).
+//
+// Endpoints can be overridden individually, e.g. E2E_WORK_URL or
+// E2E_DATABASE_URL, to point the suite at a different deployment.
+package e2e
@@ -0,0 +1,128 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Session is an authenticated fixture user
+type Session struct {
+	UserID      string
+	Username    string
+	Email       string
+	AccessToken string
+}
+
+// Fixtures is the seeded data set shared by the flows in one run
+type Fixtures struct {
+	Author     *Session
+	Subscriber *Session
+	Reader     *Session
+
+	Fandom       string
+	Character    string
+	Relationship string
+	Freeform     string
+}
+
+// Seed creates fixture users through the auth API (so they get real tokens
+// and default pseuds) and canonical tags directly in Postgres, since fandom
+// tags cannot be created through the posting form. Names carry a run suffix
+// so repeated runs against a long-lived stack do not collide.
+func (h *Harness) Seed(ctx context.Context) (*Fixtures, error) {
+	run := uuid.New().String()[:8]
+
+	fx := &Fixtures{
+		Fandom:       "E2E Fandom " + run,
+		Character:    "E2E Character " + run,
+		Relationship: "E2E Character " + run + "/Other " + run,
+		Freeform:     "E2E Fluff " + run,
+	}
+
+	var err error
+	if fx.Author, err = h.RegisterUser("e2e_author_" + run); err != nil {
+		return nil, err
+	}
+	if fx.Subscriber, err = h.RegisterUser("e2e_subscriber_" + run); err != nil {
+		return nil, err
+	}
+	if fx.Reader, err = h.RegisterUser("e2e_reader_" + run); err != nil {
+		return nil, err
+	}
+
+	tags := []struct{ name, tagType string }{
+		{fx.Fandom, "fandom"},
+		{fx.Character, "character"},
+		{fx.Relationship, "relationship"},
+		{fx.Freeform, "freeform"},
+	}
+	for _, tag := range tags {
+		_, err := h.DB.ExecContext(ctx, `
+			INSERT INTO tags (id, name, type, is_canonical, is_filterable, created_at, updated_at)
+			VALUES ($1, $2, $3, true, true, NOW(), NOW())
+			ON CONFLICT (name) DO NOTHING`,
+			uuid.New(), tag.name, tag.tagType)
+		if err != nil {
+			return nil, fmt.Errorf("seed tag %q: %w", tag.name, err)
+		}
+	}
+
+	return fx, nil
+}
+
+// RegisterUser registers a user via auth-service and returns its session
+func (h *Harness) RegisterUser(username string) (*Session, error) {
+	email := username + "@e2e.nuclear-ao3.test"
+	resp, err := h.Do(http.MethodPost, h.Endpoints.Auth+"/api/v1/auth/register", nil, map[string]interface{}{
+		"username":         username,
+		"email":            email,
+		"password":         "e2e-Password-123!",
+		"confirm_password": "e2e-Password-123!",
+		"display_name":     username,
+		"accept_tos":       true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("register %s: %w", username, err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("register %s: status %d: %s", username, resp.StatusCode, resp.Raw)
+	}
+
+	token, _ := resp.Body["access_token"].(string)
+	user, _ := resp.Body["user"].(map[string]interface{})
+	userID, _ := user["id"].(string)
+	if token == "" || userID == "" {
+		return nil, fmt.Errorf("register %s: response missing token or user id", username)
+	}
+
+	return &Session{
+		UserID:      userID,
+		Username:    username,
+		Email:       email,
+		AccessToken: token,
+	}, nil
+}
+
+// Cleanup removes rows created by Seed. Works and everything hanging off
+// them cascade from users; tags are removed by name.
+func (h *Harness) Cleanup(ctx context.Context, fx *Fixtures) {
+	if fx == nil || h.DB == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	for _, s := range []*Session{fx.Author, fx.Subscriber, fx.Reader} {
+		if s != nil {
+			h.DB.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, s.UserID)
+		}
+	}
+	h.DB.ExecContext(ctx, `DELETE FROM tags WHERE name = ANY($1)`,
+		pq.Array([]string{fx.Fandom, fx.Character, fx.Relationship, fx.Freeform}))
+}
@@ -0,0 +1,184 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	harness  *Harness
+	fixtures *Fixtures
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("E2E") != "1" {
+		log.Println("E2E not set - skipping end-to-end suite")
+		os.Exit(0)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	harness = NewHarness(os.Getenv("E2E_COMPOSE") == "1")
+
+	if err := harness.Start(ctx); err != nil {
+		log.Printf("failed to start e2e stack: %v", err)
+		harness.Stop(context.Background())
+		cancel()
+		os.Exit(1)
+	}
+
+	var err error
+	fixtures, err = harness.Seed(ctx)
+	if err != nil {
+		log.Printf("failed to seed fixtures: %v", err)
+		harness.Stop(context.Background())
+		cancel()
+		os.Exit(1)
+	}
+	cancel()
+
+	code := m.Run()
+
+	harness.Cleanup(context.Background(), fixtures)
+	harness.Stop(context.Background())
+	os.Exit(code)
+}
+
+// TestPostWorkFlow follows a work from posting through every downstream
+// consumer: indexing, search, subscriber notification and export.
+func TestPostWorkFlow(t *testing.T) {
+	ep := harness.Endpoints
+	title := fmt.Sprintf("E2E Flow Work %d", time.Now().UnixNano())
+
+	// Subscriber follows the author before anything is posted
+	resp, err := harness.Do(http.MethodPost, ep.Work+"/api/v1/subscriptions", fixtures.Subscriber, map[string]interface{}{
+		"type":      "author",
+		"target_id": fixtures.Author.UserID,
+		"frequency": "immediate",
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(resp.Raw))
+
+	var workID string
+	t.Run("PostWork", func(t *testing.T) {
+		resp, err := harness.Do(http.MethodPost, ep.Work+"/api/v1/works", fixtures.Author, map[string]interface{}{
+			"title":           title,
+			"summary":         "Posted by the end-to-end suite",
+			"language":        "en",
+			"rating":          "General Audiences",
+			"category":        []string{"Gen"},
+			"warnings":        []string{"No Archive Warnings Apply"},
+			"fandoms":         []string{fixtures.Fandom},
+			"characters":      []string{fixtures.Character},
+			"relationships":   []string{fixtures.Relationship},
+			"freeform_tags":   []string{fixtures.Freeform},
+			"chapter_title":   "Chapter 1",
+			"chapter_content": "The first chapter of an end-to-end test work.",
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode, string(resp.Raw))
+
+		work, ok := resp.Body["work"].(map[string]interface{})
+		require.True(t, ok, "response should contain work object")
+		workID, _ = work["id"].(string)
+		require.NotEmpty(t, workID)
+	})
+	require.NotEmpty(t, workID, "later steps need the posted work")
+
+	t.Run("IndexedAndSearchable", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		searchURL := ep.Search + "/api/v1/search/works?q=" + url.QueryEscape(title)
+		err := Eventually(ctx, time.Second, func() (bool, error) {
+			resp, err := harness.Do(http.MethodGet, searchURL, nil, nil)
+			if err != nil || resp.StatusCode != http.StatusOK {
+				return false, nil
+			}
+			results, _ := resp.Body["results"].([]interface{})
+			for _, r := range results {
+				doc, _ := r.(map[string]interface{})
+				if doc["id"] == workID || doc["work_id"] == workID {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+		assert.NoError(t, err, "work %s should become searchable", workID)
+	})
+
+	t.Run("SubscriberNotified", func(t *testing.T) {
+		resp, err := harness.Do(http.MethodPost, fmt.Sprintf("%s/api/v1/works/%s/chapters", ep.Work, workID), fixtures.Author, map[string]interface{}{
+			"title":   "Chapter 2",
+			"content": "A second chapter so subscribers have something to hear about.",
+			"status":  "posted",
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode, string(resp.Raw))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		err = Eventually(ctx, time.Second, func() (bool, error) {
+			var count int
+			err := harness.DB.QueryRowContext(ctx, `
+				SELECT COUNT(*) FROM notifications
+				WHERE user_id = $1 AND source_id = $2`,
+				fixtures.Subscriber.UserID, workID).Scan(&count)
+			if err != nil {
+				return false, nil
+			}
+			return count > 0, nil
+		})
+		assert.NoError(t, err, "subscriber should receive a notification for work %s", workID)
+	})
+
+	t.Run("Exported", func(t *testing.T) {
+		resp, err := harness.Do(http.MethodPost, ep.Export+"/api/v1/export", fixtures.Reader, map[string]interface{}{
+			"work_id": workID,
+			"format":  "epub",
+			"user_id": fixtures.Reader.UserID,
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode, string(resp.Raw))
+
+		exportID, _ := resp.Body["export_id"].(string)
+		require.NotEmpty(t, exportID)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		var status string
+		err = Eventually(ctx, 2*time.Second, func() (bool, error) {
+			resp, err := harness.Do(http.MethodGet, ep.Export+"/api/v1/export/"+exportID, fixtures.Reader, nil)
+			if err != nil || resp.StatusCode != http.StatusOK {
+				return false, nil
+			}
+			status, _ = resp.Body["status"].(string)
+			if status == "failed" {
+				return false, fmt.Errorf("export failed: %s", resp.Raw)
+			}
+			return status == "completed", nil
+		})
+		assert.NoError(t, err, "export should complete (last status %q)", status)
+	})
+}
+
+// TestServicesHealthy is a cheap smoke check that the harness wiring itself
+// works; it fails fast with a clear message when a service is missing.
+func TestServicesHealthy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for name, base := range harness.serviceURLs() {
+		assert.NoError(t, harness.WaitForHealthy(ctx, name, base))
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Export completion callbacks: reader apps register a URL and secret on
+// CreateExport and get the final status POSTed to them instead of polling.
+const (
+	CALLBACK_TIMEOUT       = 10 * time.Second
+	MIN_CALLBACK_SECRET    = 16
+	MAX_CALLBACK_URL_BYTES = 2048
+)
+
+// callbackRetryDelays is the wait before each delivery attempt
+var callbackRetryDelays = []time.Duration{0, 30 * time.Second, 2 * time.Minute, 10 * time.Minute, 30 * time.Minute}
+
+// ExportCallback is the body POSTed to a registered callback URL
+type ExportCallback struct {
+	Event       string     `json:"event"` // export.completed, export.failed
+	ExportID    string     `json:"export_id"`
+	WorkID      string     `json:"work_id"`
+	Format      string     `json:"format"`
+	Status      string     `json:"status"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	SentAt      time.Time  `json:"sent_at"`
+}
+
+// downloadSigningKey signs download URLs handed to callbacks. Without
+// EXPORT_URL_SIGNING_KEY a random key is used, so signed URLs stop working
+// when the service restarts.
+var downloadSigningKey = func() []byte {
+	if key := getEnv("EXPORT_URL_SIGNING_KEY", ""); key != "" {
+		return []byte(key)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+// Callback URLs come from unauthenticated requests, so export-service must
+// never be talked into POSTing inside its own network. Hosts are resolved
+// on registration and refused unless every address is public, and the
+// delivery client checks the address it actually connects to, so a name
+// that rebinds to an internal address after registration is still refused.
+// Redirects are not followed. EXPORT_CALLBACK_ALLOW_PRIVATE lifts the
+// address check for local development.
+
+// nonPublicNets are the ranges callbacks may not reach beyond what
+// net.IP's own classifiers cover: shared address space, benchmarking,
+// IETF protocol assignments and the reserved 240/4
+var nonPublicNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4", "64:ff9b::/96"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// lookupCallbackHost resolves a callback host; tests replace it
+var lookupCallbackHost = net.DefaultResolver.LookupIPAddr
+
+// allowPrivateCallbacks reports whether callbacks may reach non-public
+// addresses (EXPORT_CALLBACK_ALLOW_PRIVATE, for local development only)
+func allowPrivateCallbacks() bool {
+	return getEnv("EXPORT_CALLBACK_ALLOW_PRIVATE", "false") == "true"
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address
+func isPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkCallbackHost refuses a callback host that is, or resolves to, a
+// non-public address
+func checkCallbackHost(ctx context.Context, host string) error {
+	if allowPrivateCallbacks() {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return fmt.Errorf("callback_url must point to a public address")
+		}
+		return nil
+	}
+	addrs, err := lookupCallbackHost(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("callback_url host could not be resolved")
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("callback_url must point to a public address")
+		}
+	}
+	return nil
+}
+
+// refuseNonPublicDial is the dialer Control hook for callback delivery. It
+// sees the address being connected to after resolution, so it holds even
+// if DNS changes between registration and delivery.
+func refuseNonPublicDial(network, address string, _ syscall.RawConn) error {
+	if allowPrivateCallbacks() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublicIP(net.ParseIP(host)) {
+		return fmt.Errorf("callback to non-public address %s refused", host)
+	}
+	return nil
+}
+
+// newCallbackClient is the HTTP client callbacks are delivered with: no
+// proxy, no redirects, and only public addresses dialed
+func newCallbackClient() *http.Client {
+	dialer := &net.Dialer{Timeout: CALLBACK_TIMEOUT, Control: refuseNonPublicDial}
+	return &http.Client{
+		Timeout: CALLBACK_TIMEOUT,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: CALLBACK_TIMEOUT,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return fmt.Errorf("callback redirected to %s; redirects are not followed", req.URL.Redacted())
+		},
+	}
+}
+
+// validateCallback checks a callback registration before the export is
+// queued; both fields are optional but come together
+func validateCallback(ctx context.Context, callbackURL, secret string) error {
+	if callbackURL == "" && secret == "" {
+		return nil
+	}
+	if callbackURL == "" {
+		return fmt.Errorf("callback_secret given without callback_url")
+	}
+	if len(callbackURL) > MAX_CALLBACK_URL_BYTES {
+		return fmt.Errorf("callback_url is too long")
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("callback_url must be an absolute URL")
+	}
+	allowHTTP := getEnv("EXPORT_CALLBACK_ALLOW_HTTP", "false") == "true"
+	if u.Scheme != "https" && !(u.Scheme == "http" && allowHTTP) {
+		return fmt.Errorf("callback_url must use https")
+	}
+	if len(secret) < MIN_CALLBACK_SECRET {
+		return fmt.Errorf("callback_secret must be at least %d characters", MIN_CALLBACK_SECRET)
+	}
+	return checkCallbackHost(ctx, u.Hostname())
+}
+
+// signCallback is the X-Export-Signature value for a callback body:
+// HMAC-SHA256 over "<timestamp>.<body>" with the registered secret
+func signCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// downloadSignature signs an export id and expiry for a download URL
+func downloadSignature(exportID string, expires int64) string {
+	mac := hmac.New(sha256.New, downloadSigningKey)
+	mac.Write([]byte(exportID + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signedDownloadURL is an absolute download URL valid until the export expires
+func signedDownloadURL(exportID string, expiresAt time.Time) string {
+	base := strings.TrimSuffix(getEnv("EXPORT_PUBLIC_URL", "http://localhost:8085"), "/")
+	expires := expiresAt.Unix()
+	return fmt.Sprintf("%s/api/v1/export/%s/download?expires=%d&signature=%s",
+		base, exportID, expires, downloadSignature(exportID, expires))
+}
+
+// verifyDownloadSignature checks the expires/signature pair of a signed
+// download URL
+func verifyDownloadSignature(exportID, expiresParam, signature string, now time.Time) bool {
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil || now.Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(downloadSignature(exportID, expires)))
+}
+
+// deliverCallback POSTs an export's final status to its callback URL, if it
+// registered one, retrying with backoff until it is accepted or the
+// attempts run out. Each attempt is recorded on the export.
+func (s *ExportService) deliverCallback(exportID string) {
+	var callbackURL, secret sql.NullString
+	var payload ExportCallback
+	var errorMsg sql.NullString
+	var completedAt sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT callback_url, callback_secret, work_id, format, status, error_message, completed_at, expires_at
+		FROM export_status WHERE id = $1`, exportID).Scan(
+		&callbackURL, &secret, &payload.WorkID, &payload.Format, &payload.Status,
+		&errorMsg, &completedAt, &payload.ExpiresAt)
+	if err != nil {
+		log.Printf("Failed to load export %s for callback: %v", exportID, err)
+		return
+	}
+	if !callbackURL.Valid || callbackURL.String == "" {
+		return
+	}
+
+	payload.ExportID = exportID
+	switch payload.Status {
+	case "completed":
+		payload.Event = "export.completed"
+		payload.DownloadURL = signedDownloadURL(exportID, payload.ExpiresAt)
+		if completedAt.Valid {
+			payload.CompletedAt = &completedAt.Time
+		}
+	case "failed":
+		payload.Event = "export.failed"
+		payload.Error = errorMsg.String
+	default:
+		return
+	}
+
+	client := newCallbackClient()
+	for attempt, delay := range callbackRetryDelays {
+		time.Sleep(delay)
+
+		payload.SentAt = time.Now().UTC()
+		body, _ := json.Marshal(payload)
+		err := postCallback(client, callbackURL.String, secret.String, body)
+
+		status, lastError := "delivered", ""
+		if err != nil {
+			status, lastError = "retrying", err.Error()
+			if attempt == len(callbackRetryDelays)-1 {
+				status = "failed"
+			}
+		}
+		s.db.Exec(`
+			UPDATE export_status
+			SET callback_status = $1, callback_attempts = $2, callback_last_error = NULLIF($3, '')
+			WHERE id = $4`, status, attempt+1, lastError, exportID)
+
+		if err == nil {
+			return
+		}
+		log.Printf("Callback for export %s failed (attempt %d/%d): %v", exportID, attempt+1, len(callbackRetryDelays), err)
+	}
+}
+
+// postCallback makes one signed delivery attempt; any 2xx is success
+func postCallback(client *http.Client, callbackURL, secret string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "NuclearAO3-Export-Callback/1.0")
+	req.Header.Set("X-Export-Timestamp", timestamp)
+	req.Header.Set("X-Export-Signature", signCallback(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCallbackSecret = "0123456789abcdef"
+
+func TestSignCallback(t *testing.T) {
+	body := []byte(`{"event":"export.completed"}`)
+
+	assert.Equal(t,
+		"sha256=b54f68540460a4e949ad8183a1caf226d50becb41166680698c10f06ec60d68e",
+		signCallback(testCallbackSecret, "1700000000", body))
+	assert.NotEqual(t, signCallback(testCallbackSecret, "1700000000", body), signCallback(testCallbackSecret, "1700000001", body),
+		"the timestamp is signed, so a replayed body can't be re-dated")
+	assert.NotEqual(t, signCallback(testCallbackSecret, "1700000000", body), signCallback("another-secret-value", "1700000000", body))
+}
+
+func TestVerifyDownloadSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(time.Hour).Unix()
+	expiresParam := strconv.FormatInt(expires, 10)
+	signature := downloadSignature("export-1", expires)
+
+	assert.True(t, verifyDownloadSignature("export-1", expiresParam, signature, now))
+	assert.False(t, verifyDownloadSignature("export-2", expiresParam, signature, now), "signed for another export")
+	assert.False(t, verifyDownloadSignature("export-1", strconv.FormatInt(expires+3600, 10), signature, now), "expiry pushed back")
+	assert.False(t, verifyDownloadSignature("export-1", expiresParam, signature, now.Add(2*time.Hour)), "expired")
+	assert.False(t, verifyDownloadSignature("export-1", "soon", signature, now))
+	assert.False(t, verifyDownloadSignature("export-1", expiresParam, "", now))
+}
+
+func TestSignedDownloadURLVerifies(t *testing.T) {
+	t.Setenv("EXPORT_PUBLIC_URL", "https://exports.example.org/")
+	expiresAt := time.Now().Add(time.Hour)
+
+	u := signedDownloadURL("export-1", expiresAt)
+	expected := fmt.Sprintf("https://exports.example.org/api/v1/export/export-1/download?expires=%d&signature=%s",
+		expiresAt.Unix(), downloadSignature("export-1", expiresAt.Unix()))
+	assert.Equal(t, expected, u)
+}
+
+// stubCallbackLookup answers host lookups from a fixed table for the test
+func stubCallbackLookup(t *testing.T, hosts map[string][]string) {
+	original := lookupCallbackHost
+	lookupCallbackHost = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		ips, ok := hosts[host]
+		if !ok {
+			return nil, fmt.Errorf("no such host %s", host)
+		}
+		var addrs []net.IPAddr
+		for _, ip := range ips {
+			addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+		}
+		return addrs, nil
+	}
+	t.Cleanup(func() { lookupCallbackHost = original })
+}
+
+func TestValidateCallback(t *testing.T) {
+	t.Setenv("EXPORT_CALLBACK_ALLOW_HTTP", "")
+	t.Setenv("EXPORT_CALLBACK_ALLOW_PRIVATE", "")
+	stubCallbackLookup(t, map[string][]string{
+		"reader.example.com":  {"93.184.216.34"},
+		"rebind.example.com":  {"93.184.216.34", "10.0.0.5"},
+		"work-service":        {"172.18.0.4"},
+		"metadata.example.io": {"169.254.169.254"},
+	})
+	ctx := context.Background()
+
+	cases := []struct {
+		url, secret string
+		ok          bool
+	}{
+		{"", "", true},
+		{"https://reader.example.com/hook", testCallbackSecret, true},
+		{"https://93.184.216.34/hook", testCallbackSecret, true},
+		{"", testCallbackSecret, false},
+		{"https://reader.example.com/hook", "short", false},
+		{"http://reader.example.com/hook", testCallbackSecret, false},
+		{"/relative/hook", testCallbackSecret, false},
+		{"https://127.0.0.1/hook", testCallbackSecret, false},
+		{"https://[::1]/hook", testCallbackSecret, false},
+		{"https://10.1.2.3/hook", testCallbackSecret, false},
+		{"https://192.168.1.1/hook", testCallbackSecret, false},
+		{"https://100.64.0.1/hook", testCallbackSecret, false},
+		{"https://169.254.169.254/latest/meta-data", testCallbackSecret, false},
+		{"https://[fd00:ec2::254]/hook", testCallbackSecret, false},
+		{"https://[::ffff:127.0.0.1]/hook", testCallbackSecret, false},
+		{"https://0.0.0.0/hook", testCallbackSecret, false},
+		{"https://work-service/hook", testCallbackSecret, false},
+		{"https://metadata.example.io/hook", testCallbackSecret, false},
+		{"https://rebind.example.com/hook", testCallbackSecret, false},
+		{"https://unknown.example.com/hook", testCallbackSecret, false},
+	}
+	for _, tc := range cases {
+		err := validateCallback(ctx, tc.url, tc.secret)
+		if tc.ok {
+			assert.NoError(t, err, tc.url)
+		} else {
+			assert.Error(t, err, tc.url)
+		}
+	}
+
+	t.Setenv("EXPORT_CALLBACK_ALLOW_HTTP", "true")
+	t.Setenv("EXPORT_CALLBACK_ALLOW_PRIVATE", "true")
+	assert.NoError(t, validateCallback(ctx, "http://localhost:9000/hook", testCallbackSecret), "local development")
+}
+
+func TestCallbackClientRefusesNonPublicAddresses(t *testing.T) {
+	t.Setenv("EXPORT_CALLBACK_ALLOW_PRIVATE", "")
+	delivered := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered = true
+	}))
+	defer server.Close()
+
+	err := postCallback(newCallbackClient(), server.URL, testCallbackSecret, []byte(`{}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "non-public address")
+	assert.False(t, delivered)
+}
+
+func TestCallbackClientDoesNotFollowRedirects(t *testing.T) {
+	t.Setenv("EXPORT_CALLBACK_ALLOW_PRIVATE", "true")
+	redirected := false
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer internal.Close()
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusTemporaryRedirect)
+	}))
+	defer hook.Close()
+
+	err := postCallback(newCallbackClient(), hook.URL, testCallbackSecret, []byte(`{}`))
+	require.Error(t, err)
+	assert.False(t, redirected)
+}
+
+func TestPostCallbackSignsTheBody(t *testing.T) {
+	t.Setenv("EXPORT_CALLBACK_ALLOW_PRIVATE", "true")
+	body := []byte(`{"event":"export.failed"}`)
+	var gotSignature, gotTimestamp string
+	var gotBody bytes.Buffer
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSignature = r.Header.Get("X-Export-Signature")
+		gotTimestamp = r.Header.Get("X-Export-Timestamp")
+		gotBody.ReadFrom(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	require.NoError(t, postCallback(newCallbackClient(), server.URL, testCallbackSecret, body))
+	assert.Equal(t, body, gotBody.Bytes())
+	assert.Equal(t, signCallback(testCallbackSecret, gotTimestamp, body), gotSignature)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Post-conversion: some formats are produced by converting a generated
+// export with an external tool, typically calibre's ebook-convert in a
+// throwaway container. The tool only ever sees a scratch directory holding
+// the source file, runs under a timeout with an empty environment, and its
+// output is checked before it replaces anything. When conversion is not
+// configured or fails, the export falls back to the source format.
+const (
+	DEFAULT_CONVERSION_TIMEOUT = 2 * time.Minute
+	MAX_CONVERTED_BYTES        = 100 << 20
+	MAX_CONVERTER_OUTPUT       = 4 << 10
+)
+
+// POST_CONVERTED_FORMATS maps each format made by post-conversion to the
+// format it is converted from
+var POST_CONVERTED_FORMATS = map[string]string{
+	"azw3": "epub",
+	"mobi": "epub",
+	"pdf":  "epub",
+}
+
+// convertedOutputValidators check the start of each post-converted format
+var convertedOutputValidators = map[string]func([]byte) error{
+	"azw3": validateAZW3,
+	"mobi": validateMOBI,
+	"pdf":  validatePDF,
+}
+
+// PostConverter turns a generated export into another format
+type PostConverter interface {
+	Convert(ctx context.Context, sourcePath, outputPath string) error
+}
+
+// commandConverter runs a configured command. The args may contain the
+// placeholders {dir}, {input} and {output}: the scratch directory and the
+// names of the source and result files inside it.
+type commandConverter struct {
+	args     []string
+	timeout  time.Duration
+	validate func([]byte) error
+}
+
+// newPostConverters builds the converters configured in the environment.
+// EXPORT_AZW3_CONVERTER is the command line for EPUB to AZW3, for example
+//
+//	docker run --rm --network none --read-only --cap-drop ALL --memory 512m
+//	  --user 65534:65534 -v {dir}:/work calibre-image
+//	  ebook-convert /work/{input} /work/{output}
+//
+// and EXPORT_MOBI_CONVERTER and EXPORT_PDF_CONVERTER the same for MOBI and
+// PDF (ebook-convert picks the output format from the file extension).
+// EXPORT_CONVERSION_TIMEOUT bounds each run.
+func newPostConverters() map[string]PostConverter {
+	timeout := DEFAULT_CONVERSION_TIMEOUT
+	if d, err := time.ParseDuration(getEnv("EXPORT_CONVERSION_TIMEOUT", "")); err == nil && d > 0 {
+		timeout = d
+	}
+
+	converters := map[string]PostConverter{}
+	for format, sourceFormat := range POST_CONVERTED_FORMATS {
+		name := strings.ToUpper(format)
+		if args := strings.Fields(getEnv("EXPORT_"+name+"_CONVERTER", "")); len(args) > 0 {
+			converters[format] = &commandConverter{args: args, timeout: timeout, validate: convertedOutputValidators[format]}
+			log.Printf("%s exports will be converted with %s (timeout %v)", name, args[0], timeout)
+		} else {
+			log.Printf("EXPORT_%s_CONVERTER not set, %s exports will fall back to %s", name, name, strings.ToUpper(sourceFormat))
+		}
+	}
+	return converters
+}
+
+// Convert copies the source into a fresh scratch directory, runs the
+// command there and moves the checked result to outputPath
+func (c *commandConverter) Convert(ctx context.Context, sourcePath, outputPath string) error {
+	dir, err := os.MkdirTemp("", "export-convert-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// Containers running as nobody need to write the result
+	if err := os.Chmod(dir, 0o777); err != nil {
+		return err
+	}
+
+	input := "input" + filepath.Ext(sourcePath)
+	output := "output" + filepath.Ext(outputPath)
+	if err := copyFile(sourcePath, filepath.Join(dir, input)); err != nil {
+		return fmt.Errorf("failed to stage source: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	args := expandConverterArgs(c.args, dir, input, output)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir}
+	var combined limitedBuffer
+	combined.limit = MAX_CONVERTER_OUTPUT
+	cmd.Stdout, cmd.Stderr = &combined, &combined
+	// Give the tool a moment to exit after being killed before giving up on it
+	cmd.WaitDelay = 5 * time.Second
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("converter timed out after %v", c.timeout)
+		}
+		return fmt.Errorf("converter failed: %v: %s", err, strings.TrimSpace(combined.String()))
+	}
+
+	result := filepath.Join(dir, output)
+	info, err := os.Stat(result)
+	if err != nil {
+		return fmt.Errorf("converter produced no output")
+	}
+	if info.Size() == 0 || info.Size() > MAX_CONVERTED_BYTES {
+		return fmt.Errorf("converter output has an unexpected size of %d bytes", info.Size())
+	}
+	if c.validate != nil {
+		header := make([]byte, 68)
+		f, err := os.Open(result)
+		if err != nil {
+			return err
+		}
+		n, _ := io.ReadFull(f, header)
+		f.Close()
+		if err := c.validate(header[:n]); err != nil {
+			return err
+		}
+	}
+	return copyFile(result, outputPath)
+}
+
+// expandConverterArgs fills in the command placeholders
+func expandConverterArgs(args []string, dir, input, output string) []string {
+	replacer := strings.NewReplacer("{dir}", dir, "{input}", input, "{output}", output)
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		expanded[i] = replacer.Replace(arg)
+	}
+	return expanded
+}
+
+// validateAZW3 checks for the PalmDB header of a KF8 book, so a converter
+// that silently writes something else isn't served as AZW3
+func validateAZW3(header []byte) error {
+	if len(header) < 68 || !bytes.Equal(header[60:68], []byte("BOOKMOBI")) {
+		return fmt.Errorf("converter output is not an AZW3 file")
+	}
+	return nil
+}
+
+// validateMOBI checks for the same PalmDB header, which MOBI books share
+func validateMOBI(header []byte) error {
+	if len(header) < 68 || !bytes.Equal(header[60:68], []byte("BOOKMOBI")) {
+		return fmt.Errorf("converter output is not a MOBI file")
+	}
+	return nil
+}
+
+// validatePDF checks for the PDF signature
+func validatePDF(header []byte) error {
+	if !bytes.HasPrefix(header, []byte("%PDF-")) {
+		return fmt.Errorf("converter output is not a PDF file")
+	}
+	return nil
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// limitedBuffer keeps the first limit bytes written to it, enough of a
+// converter's output to explain a failure
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// postConvert produces a post-converted format from its source file. On
+// any failure the export is switched to the source format, with the
+// reason recorded, rather than failing outright.
+func (s *ExportService) postConvert(exportID, format string) {
+	sourceFormat, ok := POST_CONVERTED_FORMATS[format]
+	if !ok {
+		return
+	}
+	sourcePath := fmt.Sprintf("./exports/%s.%s", exportID, sourceFormat)
+	outputPath := fmt.Sprintf("./exports/%s.%s", exportID, format)
+
+	err := fmt.Errorf("no %s converter is configured", format)
+	if converter := s.converters[format]; converter != nil {
+		err = converter.Convert(context.Background(), sourcePath, outputPath)
+	}
+	if err == nil {
+		os.Remove(sourcePath)
+		return
+	}
+
+	log.Printf("Export %s: %s conversion failed, falling back to %s: %v", exportID, format, sourceFormat, err)
+	os.Remove(outputPath)
+	_, dbErr := s.db.Exec(`
+		UPDATE export_status SET format = $1, requested_format = $2, conversion_error = $3
+		WHERE id = $4`, sourceFormat, format, err.Error(), exportID)
+	if dbErr != nil {
+		log.Printf("Failed to record fallback for export %s: %v", exportID, dbErr)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary stand in for ebook-convert (converters run
+// with an empty environment, so it's asked for by argument): it checks its
+// input is an EPUB and writes a book with the PalmDB header AZW3 and MOBI
+// share
+func TestMain(m *testing.M) {
+	if len(os.Args) == 4 && os.Args[1] == "fake-ebook-convert" {
+		if err := fakeEbookConvert(os.Args[2], os.Args[3]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func fakeEbookConvert(input, output string) error {
+	book, err := readEPUB(input)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 68)
+	copy(header, "converted")
+	copy(header[60:], "BOOKMOBI")
+	return os.WriteFile(output, append(header, book["OEBPS/content.opf"]...), 0o644)
+}
+
+// readEPUB opens an EPUB and checks its container, returning its files
+func readEPUB(path string) (map[string][]byte, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	if len(r.File) == 0 || r.File[0].Name != "mimetype" || r.File[0].Method != zip.Store {
+		return nil, fmt.Errorf("mimetype must be the first entry, stored")
+	}
+	files := map[string][]byte{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = data
+	}
+	if string(files["mimetype"]) != "application/epub+zip" {
+		return nil, fmt.Errorf("unexpected mimetype %q", files["mimetype"])
+	}
+	for name, data := range files {
+		if strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".opf") || strings.HasSuffix(name, ".xhtml") {
+			if err := wellFormed(data); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return files, nil
+}
+
+func wellFormed(data []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func testExportWork() *exportWork {
+	return &exportWork{
+		ID:        "5f0c1f1e-8d8a-4c55-9d1b-6c1f2f7f0a11",
+		Title:     "Tea & <Sympathy>",
+		Author:    "fic_writer",
+		Summary:   "<p>Two people, one kettle.<br>Trouble.</p>",
+		Language:  "en",
+		Rating:    "General Audiences",
+		Tags:      []string{"Original Work", "Fluff"},
+		UpdatedAt: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		Chapters: []exportChapter{
+			{Number: 1, Title: "The Kettle", Content: `<p onclick="steal()">It <em>whistled</em>.<img src="k.png"></p><script>alert(1)</script><p>Unclosed <b>bold`},
+			{Number: 2, Content: "<p>Tea was had &amp; enjoyed.</p>", EndNotes: "<p>Thanks for reading!</p>"},
+		},
+	}
+}
+
+func TestWriteEPUB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "work.epub")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, writeEPUB(f, testExportWork(), ExportOptions{IncludeMetadata: true, IncludeTags: true}))
+	require.NoError(t, f.Close())
+
+	files, err := readEPUB(path)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(files["META-INF/container.xml"]), `full-path="OEBPS/content.opf"`)
+	opf := string(files["OEBPS/content.opf"])
+	assert.Contains(t, opf, "<dc:title>Tea &amp; &lt;Sympathy&gt;</dc:title>")
+	assert.Contains(t, opf, "2026-03-04T05:06:07Z")
+	assert.Less(t, strings.Index(opf, `idref="chapter-1"`), strings.Index(opf, `idref="chapter-2"`))
+	assert.Contains(t, string(files["OEBPS/nav.xhtml"]), "Chapter 1: The Kettle")
+	assert.Contains(t, string(files["OEBPS/title.xhtml"]), "Fluff")
+
+	first := string(files["OEBPS/chapter-1.xhtml"])
+	assert.Contains(t, first, "<em>whistled</em>")
+	assert.Contains(t, first, `<img src="k.png"/>`)
+	assert.Contains(t, first, "<b>bold</b>")
+	assert.NotContains(t, first, "onclick")
+	assert.NotContains(t, first, "alert(1)")
+	assert.Contains(t, string(files["OEBPS/chapter-2.xhtml"]), "Thanks for reading!")
+}
+
+func TestToXHTML(t *testing.T) {
+	for fragment, want := range map[string]string{
+		"<p>a<br>b</p>":                    "<p>a<br/>b</p>",
+		"plain & simple":                   "plain &amp; simple",
+		"<p title='x\"y'>q</p>":            `<p title="x&#34;y">q</p>`,
+		"<ul><li>one<li>two</ul>":          "<ul><li>one</li><li>two</li></ul>",
+		"<p>x<!-- hidden --></p>":          "<p>x</p>",
+		`<div data-x="1" 1bad="2">y</div>`: `<div data-x="1">y</div>`,
+	} {
+		got, err := toXHTML(fragment)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, fragment)
+	}
+}
+
+func TestPostConvertConvertsAGeneratedEPUB(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "export.epub")
+	require.NoError(t, generateEPUBFile(source, testExportWork()))
+
+	self, err := os.Executable()
+	require.NoError(t, err)
+	converter := &commandConverter{
+		args:     []string{self, "fake-ebook-convert", "{input}", "{output}"},
+		timeout:  30 * time.Second,
+		validate: validateAZW3,
+	}
+
+	output := filepath.Join(dir, "export.azw3")
+	require.NoError(t, converter.Convert(context.Background(), source, output))
+	converted, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.NoError(t, validateAZW3(converted))
+	assert.Contains(t, string(converted), "Tea &amp; &lt;Sympathy&gt;", "converted from the generated book")
+
+	// A converter writing something else is refused
+	converter.validate = validatePDF
+	assert.Error(t, converter.Convert(context.Background(), source, filepath.Join(dir, "export.pdf")))
+}
+
+// TestEbookConvertReadsAGeneratedEPUB runs calibre on a generated book
+// where it's installed
+func TestEbookConvertReadsAGeneratedEPUB(t *testing.T) {
+	ebookConvert, err := exec.LookPath("ebook-convert")
+	if err != nil {
+		t.Skip("ebook-convert is not installed")
+	}
+	dir := t.TempDir()
+	source := filepath.Join(dir, "export.epub")
+	require.NoError(t, generateEPUBFile(source, testExportWork()))
+
+	converter := &commandConverter{
+		args:     []string{ebookConvert, "{input}", "{output}"},
+		timeout:  2 * time.Minute,
+		validate: validateAZW3,
+	}
+	assert.NoError(t, converter.Convert(context.Background(), source, filepath.Join(dir, "export.azw3")))
+}
+
+func generateEPUBFile(path string, work *exportWork) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeEPUB(f, work, ExportOptions{}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func TestCreateExportRejectsKFX(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &ExportService{}
+	r := gin.New()
+	r.POST("/api/v1/export", s.CreateExport)
+
+	for format, message := range map[string]string{"kfx": "KFX export is not supported", "KFX": "KFX export is not supported", "docx": "unsupported export format"} {
+		body := fmt.Sprintf(`{"work_id": "5f0c1f1e-8d8a-4c55-9d1b-6c1f2f7f0a11", "format": %q}`, format)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/export", strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, format)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Contains(t, response["error"], message, format)
+		assert.Contains(t, response["supported_formats"], "azw3")
+	}
+}
+
+func TestCheckExportFormat(t *testing.T) {
+	for _, format := range EXPORT_FORMATS {
+		assert.NoError(t, checkExportFormat(format))
+		if sourceFormat, ok := POST_CONVERTED_FORMATS[format]; ok {
+			assert.Equal(t, "epub", sourceFormat)
+			assert.NotNil(t, convertedOutputValidators[format], format)
+		}
+	}
+	assert.Error(t, checkExportFormat("kfx"))
+}
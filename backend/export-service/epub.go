@@ -0,0 +1,342 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// EPUB generation: every export starts as an EPUB 3 built from the work's
+// posted chapters, and other formats are post-converted from it (see
+// conversion.go). Chapter HTML is re-serialized as XHTML, since e-readers
+// and converters reject content that isn't well-formed XML.
+
+// exportWork is what goes into an export
+type exportWork struct {
+	ID        string
+	Title     string
+	Author    string
+	Summary   string
+	Notes     string
+	Language  string
+	Rating    string
+	Tags      []string
+	UpdatedAt time.Time
+	Chapters  []exportChapter
+}
+
+type exportChapter struct {
+	Number   int
+	Title    string
+	Notes    string
+	Content  string
+	EndNotes string
+}
+
+// loadExportWork reads a work and its posted chapters. Trashed works and
+// works without a posted chapter can't be exported.
+func (s *ExportService) loadExportWork(workID string) (*exportWork, error) {
+	work := &exportWork{ID: workID}
+	var fandoms, characters, relationships, freeforms string
+	err := s.db.QueryRow(`
+		SELECT w.title, COALESCE(u.username, ''), COALESCE(w.summary, ''), COALESCE(w.notes, ''),
+			COALESCE(w.language, 'en'), COALESCE(w.rating, ''), w.updated_at,
+			array_to_json(COALESCE(w.fandoms, '{}')), array_to_json(COALESCE(w.characters, '{}')),
+			array_to_json(COALESCE(w.relationships, '{}')), array_to_json(COALESCE(w.freeform_tags, '{}'))
+		FROM works w
+		LEFT JOIN users u ON u.id = w.user_id
+		WHERE w.id::text = $1 AND w.deleted_at IS NULL`, workID).Scan(
+		&work.Title, &work.Author, &work.Summary, &work.Notes, &work.Language, &work.Rating, &work.UpdatedAt,
+		&fandoms, &characters, &relationships, &freeforms)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load work %s: %w", workID, err)
+	}
+	for _, list := range []string{fandoms, relationships, characters, freeforms} {
+		var tags []string
+		if err := json.Unmarshal([]byte(list), &tags); err == nil {
+			work.Tags = append(work.Tags, tags...)
+		}
+	}
+
+	rows, err := s.db.Query(`
+		SELECT chapter_number, COALESCE(title, ''), COALESCE(notes, ''), content, COALESCE(end_notes, '')
+		FROM chapters
+		WHERE work_id::text = $1 AND COALESCE(is_draft, false) = false
+		ORDER BY chapter_number`, workID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chapters of work %s: %w", workID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ch exportChapter
+		if err := rows.Scan(&ch.Number, &ch.Title, &ch.Notes, &ch.Content, &ch.EndNotes); err != nil {
+			return nil, err
+		}
+		work.Chapters = append(work.Chapters, ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(work.Chapters) == 0 {
+		return nil, fmt.Errorf("work %s has no posted chapters", workID)
+	}
+	return work, nil
+}
+
+// generateEPUB writes the export's EPUB to path, through a temporary file
+// so a failed run never leaves a partial book to download
+func generateEPUB(path string, work *exportWork, opts ExportOptions) error {
+	if err := os.MkdirAll("./exports", 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := writeEPUB(f, work, opts); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeEPUB writes work as an EPUB 3 container
+func writeEPUB(w io.Writer, work *exportWork, opts ExportOptions) error {
+	zw := zip.NewWriter(w)
+
+	// The mimetype comes first and uncompressed, so readers can sniff it
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []struct{ name, body string }{
+		{"META-INF/container.xml", epubContainer},
+		{"OEBPS/content.opf", epubPackage(work)},
+		{"OEBPS/nav.xhtml", epubNav(work)},
+	}
+	titlePage, err := epubTitlePage(work, opts)
+	if err != nil {
+		return err
+	}
+	files = append(files, struct{ name, body string }{"OEBPS/title.xhtml", titlePage})
+	for _, ch := range work.Chapters {
+		page, err := epubChapter(work, ch)
+		if err != nil {
+			return fmt.Errorf("chapter %d: %w", ch.Number, err)
+		}
+		files = append(files, struct{ name, body string }{"OEBPS/" + chapterFile(ch), page})
+	}
+
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, file.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+func chapterFile(ch exportChapter) string {
+	return fmt.Sprintf("chapter-%d.xhtml", ch.Number)
+}
+
+func chapterHeading(ch exportChapter) string {
+	if ch.Title != "" {
+		return fmt.Sprintf("Chapter %d: %s", ch.Number, ch.Title)
+	}
+	return fmt.Sprintf("Chapter %d", ch.Number)
+}
+
+// xmlText escapes s for XML text and attribute values
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func epubPackage(work *exportWork) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="work-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&b, "    <dc:identifier id=\"work-id\">urn:nuclear-ao3:work:%s</dc:identifier>\n", xmlText(work.ID))
+	fmt.Fprintf(&b, "    <dc:title>%s</dc:title>\n", xmlText(work.Title))
+	fmt.Fprintf(&b, "    <dc:language>%s</dc:language>\n", xmlText(work.Language))
+	if work.Author != "" {
+		fmt.Fprintf(&b, "    <dc:creator>%s</dc:creator>\n", xmlText(work.Author))
+	}
+	for _, tag := range work.Tags {
+		fmt.Fprintf(&b, "    <dc:subject>%s</dc:subject>\n", xmlText(tag))
+	}
+	fmt.Fprintf(&b, "    <meta property=\"dcterms:modified\">%s</meta>\n", work.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z"))
+	b.WriteString("  </metadata>\n  <manifest>\n")
+	b.WriteString("    <item id=\"nav\" href=\"nav.xhtml\" media-type=\"application/xhtml+xml\" properties=\"nav\"/>\n")
+	b.WriteString("    <item id=\"title\" href=\"title.xhtml\" media-type=\"application/xhtml+xml\"/>\n")
+	for _, ch := range work.Chapters {
+		fmt.Fprintf(&b, "    <item id=\"chapter-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", ch.Number, chapterFile(ch))
+	}
+	b.WriteString("  </manifest>\n  <spine>\n    <itemref idref=\"title\"/>\n")
+	for _, ch := range work.Chapters {
+		fmt.Fprintf(&b, "    <itemref idref=\"chapter-%d\"/>\n", ch.Number)
+	}
+	b.WriteString("  </spine>\n</package>\n")
+	return b.String()
+}
+
+// xhtmlPage wraps a body in an XHTML document
+func xhtmlPage(work *exportWork, title, body string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%s" lang="%s">
+<head><title>%s</title></head>
+<body>
+%s
+</body>
+</html>
+`, xmlText(work.Language), xmlText(work.Language), xmlText(title), body)
+}
+
+func epubNav(work *exportWork) string {
+	var b strings.Builder
+	b.WriteString("<nav epub:type=\"toc\" id=\"toc\"><h1>Contents</h1><ol>\n")
+	for _, ch := range work.Chapters {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", chapterFile(ch), xmlText(chapterHeading(ch)))
+	}
+	b.WriteString("</ol></nav>")
+	return xhtmlPage(work, work.Title, b.String())
+}
+
+func epubTitlePage(work *exportWork, opts ExportOptions) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", xmlText(work.Title))
+	if work.Author != "" {
+		fmt.Fprintf(&b, "<p>by %s</p>\n", xmlText(work.Author))
+	}
+	if opts.IncludeMetadata && work.Rating != "" {
+		fmt.Fprintf(&b, "<p>Rating: %s</p>\n", xmlText(work.Rating))
+	}
+	if opts.IncludeTags && len(work.Tags) > 0 {
+		fmt.Fprintf(&b, "<p>Tags: %s</p>\n", xmlText(strings.Join(work.Tags, ", ")))
+	}
+	for _, section := range []struct{ heading, content string }{{"Summary", work.Summary}, {"Notes", work.Notes}} {
+		if section.content == "" {
+			continue
+		}
+		body, err := toXHTML(section.content)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "<h2>%s</h2>\n<div>%s</div>\n", section.heading, body)
+	}
+	return xhtmlPage(work, work.Title, b.String()), nil
+}
+
+func epubChapter(work *exportWork, ch exportChapter) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h2>%s</h2>\n", xmlText(chapterHeading(ch)))
+	for _, part := range []struct{ class, content string }{{"notes", ch.Notes}, {"content", ch.Content}, {"end-notes", ch.EndNotes}} {
+		if part.content == "" {
+			continue
+		}
+		body, err := toXHTML(part.content)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "<div class=\"%s\">%s</div>\n", part.class, body)
+	}
+	return xhtmlPage(work, chapterHeading(ch), b.String()), nil
+}
+
+// toXHTML parses an HTML fragment the way a browser would and writes it
+// back as well-formed XHTML. Scripts, styles and comments are dropped.
+func toXHTML(fragment string) (string, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), context)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		writeXHTML(&b, n)
+	}
+	return b.String(), nil
+}
+
+func writeXHTML(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(xmlText(n.Data))
+	case html.ElementNode:
+		if n.DataAtom == atom.Script || n.DataAtom == atom.Style {
+			return
+		}
+		b.WriteString("<" + n.Data)
+		for _, attr := range n.Attr {
+			if attr.Namespace != "" || strings.HasPrefix(attr.Key, "on") || !xmlName(attr.Key) {
+				continue
+			}
+			fmt.Fprintf(b, " %s=\"%s\"", attr.Key, xmlText(attr.Val))
+		}
+		if n.FirstChild == nil && voidElements[n.DataAtom] {
+			b.WriteString("/>")
+			return
+		}
+		b.WriteString(">")
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeXHTML(b, c)
+		}
+		b.WriteString("</" + n.Data + ">")
+	}
+}
+
+var voidElements = map[atom.Atom]bool{
+	atom.Area: true, atom.Br: true, atom.Col: true, atom.Embed: true, atom.Hr: true,
+	atom.Img: true, atom.Input: true, atom.Source: true, atom.Track: true, atom.Wbr: true,
+}
+
+// xmlName reports whether an attribute name is usable in XML, which HTML
+// parsing doesn't guarantee
+func xmlName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r == '_':
+		case i > 0 && (r >= '0' && r <= '9' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"nuclear-ao3/shared/middleware"
+)
+
+// InternalExpireExport expires an export now rather than at the end of its
+// TTL, removing its file, for takedowns that can't wait for cleanup:
+// POST /api/v1/internal/exports/:id/expire
+func (s *ExportService) InternalExpireExport(c *gin.Context) {
+	exportID := c.Param("id")
+
+	var status, format string
+	var expiresAt time.Time
+	err := s.db.QueryRow(`SELECT status, format, expires_at FROM export_status WHERE id = $1`, exportID).
+		Scan(&status, &format, &expiresAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up export"})
+		return
+	}
+
+	filePath := fmt.Sprintf("./exports/%s.%s", exportID, format)
+	_, statErr := os.Stat(filePath)
+	hasFile := statErr == nil
+
+	if middleware.IsDryRun(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":     true,
+			"export_id":   exportID,
+			"status":      status,
+			"expires_at":  expiresAt,
+			"remove_file": hasFile,
+		})
+		return
+	}
+
+	if _, err := s.db.Exec(`
+		UPDATE export_status SET status = 'expired', expires_at = LEAST(expires_at, CURRENT_TIMESTAMP)
+		WHERE id = $1`, exportID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to expire export"})
+		return
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing file %s: %v", filePath, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Export expired but its file could not be removed"})
+		return
+	}
+
+	log.Printf("Export %s force-expired (was %s)", exportID, status)
+	c.JSON(http.StatusOK, gin.H{
+		"export_id":       exportID,
+		"previous_status": status,
+		"file_removed":    hasFile,
+		"message":         "Export expired",
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
@@ -17,6 +18,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"nuclear-ao3/shared/database"
+	"nuclear-ao3/shared/metrics"
+	"nuclear-ao3/shared/middleware"
+	"nuclear-ao3/shared/migrate"
 )
 
 // TTL Configuration - Conservative Security Model
@@ -31,15 +38,35 @@ const (
 type ExportService struct {
 	db          *sql.DB
 	redisClient *redis.Client
+	converters  map[string]PostConverter
 }
 
 type ExportRequest struct {
 	WorkID      string        `json:"work_id" binding:"required"`
-	Format      string        `json:"format" binding:"required,oneof=epub mobi pdf"`
+	Format      string        `json:"format" binding:"required"` // one of EXPORT_FORMATS
 	Options     ExportOptions `json:"options"`
 	UserID      string        `json:"user_id"`
 	RequestedAt time.Time     `json:"requested_at"`
 	TTL         time.Duration `json:"ttl,omitempty"` // Optional custom TTL
+
+	// Optional completion callback, POSTed and HMAC-signed with the secret
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackSecret string `json:"callback_secret,omitempty"`
+}
+
+// EXPORT_FORMATS are the formats exports can be requested in. EPUB is
+// generated directly and the others are post-converted from it.
+var EXPORT_FORMATS = []string{"epub", "azw3", "mobi", "pdf"}
+
+// checkExportFormat explains why format can't be requested
+func checkExportFormat(format string) error {
+	switch format {
+	case "epub", "azw3", "mobi", "pdf":
+		return nil
+	case "kfx":
+		return fmt.Errorf("KFX export is not supported: KFX can only be written by Amazon's own tools. Request azw3, which every current Kindle reads")
+	}
+	return fmt.Errorf("unsupported export format %q: use one of %s", format, strings.Join(EXPORT_FORMATS, ", "))
 }
 
 type ExportOptions struct {
@@ -92,6 +119,26 @@ func main() {
 		log.Fatal("Failed to ping database:", err)
 	}
 
+	database.PoolConfigFromEnv(database.DefaultPoolConfig()).Apply(db)
+	metrics.InstrumentDBPool("export-service", db)
+
+	// `main migrate [up|status]` manages the schema and exits; otherwise
+	// pending migrations are applied before serving (MIGRATE_ON_START=false
+	// refuses to start with any pending instead)
+	migrations, err := migrate.NewRunner(db, "export-service", migrationFiles, "migrations")
+	if err != nil {
+		log.Fatal("Failed to load migrations:", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrations.Command(context.Background(), os.Args[2:], os.Stdout); err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		return
+	}
+	if err := migrations.Gate(context.Background(), migrate.OnStart(true), log.Printf); err != nil {
+		log.Fatal("Schema is not ready: ", err)
+	}
+
 	// Redis connection
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:     getEnv("REDIS_URL", "localhost:6379"),
@@ -99,12 +146,10 @@ func main() {
 		DB:       0,
 	})
 
-	// Create export table if it doesn't exist
-	createExportTable(db)
-
 	service := &ExportService{
 		db:          db,
 		redisClient: redisClient,
+		converters:  newPostConverters(),
 	}
 
 	// Start cleanup routine
@@ -123,12 +168,16 @@ func main() {
 	config.AllowCredentials = true
 	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
 	r.Use(cors.New(config))
+	r.Use(middleware.NewReadOnlyModeFromEnv().Middleware())
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy", "service": "export-service"})
 	})
 
+	// Metrics endpoint for monitoring
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	// Export endpoints
 	v1 := r.Group("/api/v1")
 	{
@@ -141,46 +190,34 @@ func main() {
 		v1.POST("/exports/cleanup", service.ManualCleanup) // Manual cleanup endpoint
 	}
 
+	// Internal operational endpoints (service token, used by nuclearctl)
+	internal := r.Group("/api/v1/internal")
+	internal.Use(middleware.ServiceTokenMiddleware())
+	{
+		internal.POST("/exports/:id/expire", service.InternalExpireExport) // Force-expire an export
+	}
+
 	port := getEnv("PORT", "8085")
 	log.Printf("Export service starting on port %s", port)
 	log.Printf("Export TTL settings: Default=%v, Max=%v, Min=%v", DEFAULT_EXPORT_TTL, MAX_EXPORT_TTL, MIN_EXPORT_TTL)
 	log.Fatal(r.Run(":" + port))
 }
 
-func createExportTable(db *sql.DB) {
-	query := `
-	CREATE TABLE IF NOT EXISTS export_status (
-		id VARCHAR(255) PRIMARY KEY,
-		work_id VARCHAR(255) NOT NULL,
-		user_id VARCHAR(255),
-		format VARCHAR(10) NOT NULL,
-		status VARCHAR(20) NOT NULL DEFAULT 'pending',
-		progress INTEGER DEFAULT 0,
-		download_url TEXT,
-		error_message TEXT,
-		options TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		completed_at TIMESTAMP,
-		expires_at TIMESTAMP NOT NULL,
-		ttl_seconds BIGINT NOT NULL
-	);
-	
-	CREATE INDEX IF NOT EXISTS idx_export_status_expires_at ON export_status(expires_at);
-	CREATE INDEX IF NOT EXISTS idx_export_status_user_id ON export_status(user_id);
-	CREATE INDEX IF NOT EXISTS idx_export_status_work_id ON export_status(work_id);
-	`
-
-	if _, err := db.Exec(query); err != nil {
-		log.Fatal("Failed to create export table:", err)
-	}
-}
-
 func (s *ExportService) CreateExport(c *gin.Context) {
 	var req ExportRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.Format = strings.ToLower(strings.TrimSpace(req.Format))
+	if err := checkExportFormat(req.Format); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "supported_formats": EXPORT_FORMATS})
+		return
+	}
+	if err := validateCallback(c.Request.Context(), req.CallbackURL, req.CallbackSecret); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Validate work exists and user has access
 	if !s.validateWorkAccess(req.WorkID, req.UserID) {
@@ -221,12 +258,18 @@ func (s *ExportService) CreateExport(c *gin.Context) {
 	expiresAt := time.Now().Add(ttl)
 
 	query := `
-		INSERT INTO export_status (id, work_id, user_id, format, status, progress, options, expires_at, ttl_seconds)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO export_status (id, work_id, user_id, format, status, progress, options, expires_at, ttl_seconds,
+			callback_url, callback_secret, callback_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), $12)
 	`
 
+	var callbackStatus sql.NullString
+	if req.CallbackURL != "" {
+		callbackStatus = sql.NullString{String: "pending", Valid: true}
+	}
 	_, err = s.db.Exec(query, exportID, req.WorkID, req.UserID, req.Format, "pending", 0,
-		string(optionsJSON), expiresAt, int64(ttl.Seconds()))
+		string(optionsJSON), expiresAt, int64(ttl.Seconds()),
+		req.CallbackURL, req.CallbackSecret, callbackStatus)
 
 	if err != nil {
 		log.Printf("Failed to create export: %v", err)
@@ -235,7 +278,7 @@ func (s *ExportService) CreateExport(c *gin.Context) {
 	}
 
 	// Queue export job
-	go s.processExport(exportID)
+	go s.processExport(exportID, req.Format)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"export_id":      exportID,
@@ -244,6 +287,7 @@ func (s *ExportService) CreateExport(c *gin.Context) {
 		"expires_at":     expiresAt,
 		"ttl_seconds":    int64(ttl.Seconds()),
 		"refresh_url":    fmt.Sprintf("/api/v1/export/%s/refresh", exportID),
+		"callback":       req.CallbackURL != "",
 	})
 }
 
@@ -252,18 +296,19 @@ func (s *ExportService) GetExportStatus(c *gin.Context) {
 
 	query := `
 		SELECT id, work_id, user_id, format, status, progress, download_url, error_message, 
-		       options, created_at, completed_at, expires_at, ttl_seconds
+		       options, created_at, completed_at, expires_at, ttl_seconds, requested_format, conversion_error
 		FROM export_status WHERE id = $1
 	`
 
 	var export ExportStatus
 	var completedAt sql.NullTime
-	var downloadURL, errorMsg sql.NullString
+	var downloadURL, errorMsg, requestedFormat, conversionError sql.NullString
 
 	err := s.db.QueryRow(query, exportID).Scan(
 		&export.ID, &export.WorkID, &export.UserID, &export.Format, &export.Status,
 		&export.Progress, &downloadURL, &errorMsg, &export.Options,
 		&export.CreatedAt, &completedAt, &export.ExpiresAt, &export.TTL,
+		&requestedFormat, &conversionError,
 	)
 
 	if err != nil {
@@ -323,6 +368,13 @@ func (s *ExportService) GetExportStatus(c *gin.Context) {
 		response["error"] = export.Error
 	}
 
+	// The requested format couldn't be produced and the source format was
+	// delivered instead
+	if requestedFormat.Valid {
+		response["requested_format"] = requestedFormat.String
+		response["conversion_error"] = conversionError.String
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
@@ -390,6 +442,15 @@ func (s *ExportService) RefreshExport(c *gin.Context) {
 func (s *ExportService) DownloadExport(c *gin.Context) {
 	exportID := c.Param("id")
 
+	// Signed URLs come from completion callbacks; reject them once tampered
+	// with or past their expiry
+	if signature := c.Query("signature"); signature != "" {
+		if !verifyDownloadSignature(exportID, c.Query("expires"), signature, time.Now()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired download signature"})
+			return
+		}
+	}
+
 	query := `
 		SELECT status, expires_at, format, work_id FROM export_status 
 		WHERE id = $1 AND status = 'completed'
@@ -506,26 +567,77 @@ func (s *ExportService) checkExistingExport(workID, userID, format string) (stri
 	return existingID, err
 }
 
-// Implement remaining helper methods...
-func (s *ExportService) processExport(exportID string) {
-	// TODO: Implement actual export processing
-	// For now, simulate processing
-	time.Sleep(2 * time.Second)
+// processExport generates the export's EPUB, post-converts it when another
+// format was requested, and reports the result to the callback
+func (s *ExportService) processExport(exportID, format string) {
+	if err := s.generateExport(exportID, format); err != nil {
+		log.Printf("Export %s failed: %v", exportID, err)
+		s.db.Exec(`UPDATE export_status SET status = 'failed', error_message = $1 WHERE id = $2`, err.Error(), exportID)
+		s.deliverCallback(exportID)
+		return
+	}
+
+	// Formats like AZW3 are converted from the generated EPUB
+	s.db.Exec(`UPDATE export_status SET progress = 60 WHERE id = $1`, exportID)
+	s.postConvert(exportID, format)
 
 	query := `UPDATE export_status SET status = 'completed', progress = 100, completed_at = CURRENT_TIMESTAMP WHERE id = $1`
-	s.db.Exec(query, exportID)
+	if _, err := s.db.Exec(query, exportID); err != nil {
+		log.Printf("Failed to complete export %s: %v", exportID, err)
+		s.db.Exec(`UPDATE export_status SET status = 'failed', error_message = $1 WHERE id = $2`, err.Error(), exportID)
+	}
+
+	s.deliverCallback(exportID)
 }
 
+// generateExport writes ./exports/<id>.epub from the work, the file every
+// format is made from
+func (s *ExportService) generateExport(exportID, format string) error {
+	var workID, optionsJSON string
+	err := s.db.QueryRow(`
+		UPDATE export_status SET status = 'processing', progress = 10
+		WHERE id = $1 AND status = 'pending'
+		RETURNING work_id, COALESCE(options, '{}')`, exportID).Scan(&workID, &optionsJSON)
+	if err != nil {
+		return fmt.Errorf("export is no longer pending: %w", err)
+	}
+	var opts ExportOptions
+	if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+		return fmt.Errorf("invalid export options: %w", err)
+	}
+
+	work, err := s.loadExportWork(workID)
+	if err != nil {
+		return err
+	}
+	sourceFormat := format
+	if from, ok := POST_CONVERTED_FORMATS[format]; ok {
+		sourceFormat = from
+	}
+	if sourceFormat != "epub" {
+		return fmt.Errorf("%s exports can't be generated", format)
+	}
+	return generateEPUB(fmt.Sprintf("./exports/%s.epub", exportID), work, opts)
+}
+
+// validateWorkAccess checks the requester may read the work, with the same
+// rules as reading it on the site
 func (s *ExportService) validateWorkAccess(workID, userID string) bool {
-	// TODO: Implement proper work access validation
-	return true
+	var allowed bool
+	err := s.db.QueryRow(`
+		SELECT can_user_view_work(w.id, NULLIF($2, '')::uuid)
+		FROM works w WHERE w.id::text = $1 AND w.deleted_at IS NULL`, workID, userID).Scan(&allowed)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to check access to work %s: %v", workID, err)
+	}
+	return err == nil && allowed
 }
 
 func (s *ExportService) estimateProcessingTime(format string) string {
 	switch format {
 	case "epub":
 		return "2-5 minutes"
-	case "mobi":
+	case "mobi", "azw3":
 		return "3-7 minutes"
 	case "pdf":
 		return "1-3 minutes"
@@ -535,8 +647,11 @@ func (s *ExportService) estimateProcessingTime(format string) string {
 }
 
 func (s *ExportService) getWorkTitle(workID string) string {
-	// TODO: Fetch actual work title from database
-	return "Untitled Work"
+	var title string
+	if err := s.db.QueryRow(`SELECT title FROM works WHERE id::text = $1`, workID).Scan(&title); err != nil || title == "" {
+		return "Untitled Work"
+	}
+	return title
 }
 
 func (s *ExportService) getMimeType(format string) string {
@@ -545,6 +660,8 @@ func (s *ExportService) getMimeType(format string) string {
 		return "application/epub+zip"
 	case "mobi":
 		return "application/x-mobipocket-ebook"
+	case "azw3":
+		return "application/vnd.amazon.ebook"
 	case "pdf":
 		return "application/pdf"
 	default:
@@ -0,0 +1,9 @@
+package main
+
+import "embed"
+
+// migrationFiles are export-service's schema migrations, applied by
+// shared/migrate at startup or with `main migrate up`
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
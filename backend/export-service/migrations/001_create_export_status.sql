@@ -0,0 +1,25 @@
+-- Nuclear AO3: Export Status
+-- Tracks export jobs from request to download. IF NOT EXISTS lets databases
+-- that predate versioned migrations record this as applied.
+
+CREATE TABLE IF NOT EXISTS export_status (
+    id VARCHAR(255) PRIMARY KEY,
+    work_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255),
+    format VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    progress INTEGER DEFAULT 0,
+    download_url TEXT,
+    error_message TEXT,
+    options TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    ttl_seconds BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_status_expires_at ON export_status(expires_at);
+CREATE INDEX IF NOT EXISTS idx_export_status_user_id ON export_status(user_id);
+CREATE INDEX IF NOT EXISTS idx_export_status_work_id ON export_status(work_id);
+
+COMMENT ON TABLE export_status IS 'Export jobs and their downloadable results, removed after ttl_seconds';
@@ -0,0 +1,13 @@
+-- Nuclear AO3: Export Callbacks and Conversion
+-- Completion callbacks and formats converted from the generated file.
+
+ALTER TABLE export_status ADD COLUMN IF NOT EXISTS callback_url TEXT;
+ALTER TABLE export_status ADD COLUMN IF NOT EXISTS callback_secret TEXT;
+ALTER TABLE export_status ADD COLUMN IF NOT EXISTS callback_status VARCHAR(20);
+ALTER TABLE export_status ADD COLUMN IF NOT EXISTS callback_attempts INTEGER DEFAULT 0;
+ALTER TABLE export_status ADD COLUMN IF NOT EXISTS callback_last_error TEXT;
+ALTER TABLE export_status ADD COLUMN IF NOT EXISTS requested_format VARCHAR(10);
+ALTER TABLE export_status ADD COLUMN IF NOT EXISTS conversion_error TEXT;
+
+COMMENT ON COLUMN export_status.callback_url IS 'Where to POST a signed notice when the export finishes';
+COMMENT ON COLUMN export_status.requested_format IS 'Format asked for when it is converted from the generated one';
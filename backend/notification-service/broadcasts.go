@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"nuclear-ao3/shared/middleware"
+	"nuclear-ao3/shared/models"
+)
+
+// =============================================================================
+// BROADCAST ANNOUNCEMENTS
+// Admins compose site-wide announcements for every active account or a
+// segment of them (by role, locale or how recently they logged in), to go
+// out at once or at a scheduled time. The dispatcher fans each one out a
+// batch of users per tick, so a big audience doesn't flood the database or
+// the mail provider: every recipient gets an in-app notification and, if
+// the broadcast asks for it and they haven't switched email off, an email.
+// Broadcasts are stored in migration 062's broadcasts table.
+// =============================================================================
+
+const (
+	broadcastTitleMax   = 200
+	broadcastBodyMax    = 10000
+	broadcastSourceType = "broadcast"
+	// broadcastMaxDays bounds a segment's activity window
+	broadcastMaxDays = 3650
+)
+
+// broadcastRoles are the roles a broadcast can be aimed at
+var broadcastRoles = map[string]bool{"user": true, "tag_wrangler": true, "moderator": true, "admin": true}
+
+// BroadcastSegment picks who a broadcast goes to. Every condition that is
+// set must hold; an empty segment is everyone with an active account.
+type BroadcastSegment struct {
+	// Roles matches users holding any of these roles
+	Roles []string `json:"roles,omitempty"`
+	// Locales matches users' default language
+	Locales []string `json:"locales,omitempty"`
+	// ActiveWithinDays matches users who logged in this recently
+	ActiveWithinDays int `json:"active_within_days,omitempty"`
+	// InactiveForDays matches users who haven't logged in for this long
+	InactiveForDays int `json:"inactive_for_days,omitempty"`
+}
+
+// validate rejects unknown roles and out-of-range activity windows
+func (s BroadcastSegment) validate() error {
+	for _, role := range s.Roles {
+		if !broadcastRoles[role] {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	for _, locale := range s.Locales {
+		if locale == "" || len(locale) > 10 {
+			return fmt.Errorf("invalid locale %q", locale)
+		}
+	}
+	if s.ActiveWithinDays < 0 || s.ActiveWithinDays > broadcastMaxDays {
+		return fmt.Errorf("active_within_days must be between 0 and %d", broadcastMaxDays)
+	}
+	if s.InactiveForDays < 0 || s.InactiveForDays > broadcastMaxDays {
+		return fmt.Errorf("inactive_for_days must be between 0 and %d", broadcastMaxDays)
+	}
+	return nil
+}
+
+// where returns the SQL condition on users u for the segment, numbering its
+// placeholders after args and returning args with its values appended
+func (s BroadcastSegment) where(args []interface{}) (string, []interface{}) {
+	conditions := []string{"u.is_active = TRUE"}
+	if len(s.Roles) > 0 {
+		args = append(args, pq.Array(s.Roles))
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM user_roles r WHERE r.user_id = u.id AND r.role = ANY($%d) AND r.revoked_at IS NULL)", len(args)))
+	}
+	if len(s.Locales) > 0 {
+		args = append(args, pq.Array(s.Locales))
+		conditions = append(conditions, fmt.Sprintf("u.default_language = ANY($%d)", len(args)))
+	}
+	if s.ActiveWithinDays > 0 {
+		args = append(args, s.ActiveWithinDays)
+		conditions = append(conditions, fmt.Sprintf("u.last_login_at >= NOW() - make_interval(days => $%d)", len(args)))
+	}
+	if s.InactiveForDays > 0 {
+		args = append(args, s.InactiveForDays)
+		conditions = append(conditions, fmt.Sprintf(
+			"(u.last_login_at IS NULL OR u.last_login_at < NOW() - make_interval(days => $%d))", len(args)))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// Broadcast is an announcement and how far its delivery has got
+type Broadcast struct {
+	ID          uuid.UUID        `json:"id"`
+	Title       string           `json:"title"`
+	Body        string           `json:"body"`
+	ActionURL   string           `json:"action_url,omitempty"`
+	SendEmail   bool             `json:"send_email"`
+	Segment     BroadcastSegment `json:"segment"`
+	Status      string           `json:"status"`
+	ScheduledAt time.Time        `json:"scheduled_at"`
+	StartedAt   *time.Time       `json:"started_at,omitempty"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	CreatedBy   *uuid.UUID       `json:"created_by,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Metrics     BroadcastMetrics `json:"metrics"`
+}
+
+// BroadcastMetrics counts a broadcast's deliveries. Read is only filled in
+// when a single broadcast is fetched.
+type BroadcastMetrics struct {
+	Recipients  int `json:"recipients"`
+	Emailed     int `json:"emailed"`
+	EmailFailed int `json:"email_failed"`
+	Read        int `json:"read"`
+}
+
+// broadcastRecipient is one user a batch delivered to
+type broadcastRecipient struct {
+	UserID         uuid.UUID
+	NotificationID uuid.UUID
+	EmailEnabled   bool
+}
+
+type broadcastRequest struct {
+	Title       string           `json:"title" binding:"required"`
+	Body        string           `json:"body" binding:"required"`
+	ActionURL   string           `json:"action_url"`
+	SendEmail   bool             `json:"send_email"`
+	Segment     BroadcastSegment `json:"segment"`
+	ScheduledAt *time.Time       `json:"scheduled_at"`
+}
+
+// requireAdmin lets only users with an active admin role through
+func (s *NotificationService) requireAdmin(c *gin.Context) {
+	userID, err := getUserUUID(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	isAdmin, err := s.broadcasts.IsAdmin(c.Request.Context(), userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
+		return
+	}
+	if !isAdmin {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+		return
+	}
+	c.Next()
+}
+
+// createBroadcast schedules an announcement. With ?dry_run=true it only
+// reports how many users the segment reaches.
+func (s *NotificationService) createBroadcast(c *gin.Context) {
+	userID, err := getUserUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var req broadcastRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title and body are required"})
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Title == "" || len(req.Title) > broadcastTitleMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("title must be 1 to %d characters", broadcastTitleMax)})
+		return
+	}
+	if req.Body == "" || len(req.Body) > broadcastBodyMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("body must be 1 to %d characters", broadcastBodyMax)})
+		return
+	}
+	if err := req.Segment.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	audience, err := s.broadcasts.CountAudience(ctx, req.Segment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count audience"})
+		return
+	}
+	if middleware.IsDryRun(c) {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "audience": audience})
+		return
+	}
+
+	broadcast := &Broadcast{
+		ID:          uuid.New(),
+		Title:       req.Title,
+		Body:        req.Body,
+		ActionURL:   strings.TrimSpace(req.ActionURL),
+		SendEmail:   req.SendEmail,
+		Segment:     req.Segment,
+		Status:      "scheduled",
+		ScheduledAt: time.Now(),
+		CreatedBy:   &userID,
+		CreatedAt:   time.Now(),
+	}
+	if req.ScheduledAt != nil && req.ScheduledAt.After(broadcast.ScheduledAt) {
+		broadcast.ScheduledAt = *req.ScheduledAt
+	}
+	if err := s.broadcasts.Create(ctx, broadcast); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create broadcast"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"broadcast": broadcast, "audience": audience})
+}
+
+// listBroadcasts returns broadcasts newest first, optionally by status
+func (s *NotificationService) listBroadcasts(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	broadcasts, err := s.broadcasts.List(c.Request.Context(), c.Query("status"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list broadcasts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"broadcasts": broadcasts, "limit": limit, "offset": offset})
+}
+
+// getBroadcast returns a broadcast with its delivery metrics
+func (s *NotificationService) getBroadcast(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid broadcast ID"})
+		return
+	}
+	broadcast, err := s.broadcasts.Get(c.Request.Context(), id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "broadcast not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get broadcast"})
+		return
+	}
+	c.JSON(http.StatusOK, broadcast)
+}
+
+// cancelBroadcast stops a broadcast that hasn't finished. Users it already
+// reached keep their notification.
+func (s *NotificationService) cancelBroadcast(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid broadcast ID"})
+		return
+	}
+	cancelled, err := s.broadcasts.Cancel(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel broadcast"})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "broadcast not found or already finished"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// startBroadcastDispatcher delivers one batch of a due broadcast every
+// interval until ctx is cancelled; the batch size and interval set the
+// fan-out rate
+func (s *NotificationService) startBroadcastDispatcher(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Broadcast dispatcher started, %d users every %s", batchSize, interval)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Broadcast dispatcher stopped")
+			return
+		case <-ticker.C:
+			if err := s.dispatchBroadcastBatch(ctx, batchSize); err != nil {
+				log.Printf("Broadcast dispatch failed: %v", err)
+			}
+		}
+	}
+}
+
+// dispatchBroadcastBatch delivers the next batch of the earliest due
+// broadcast, then pushes it to connected users and sends the emails
+func (s *NotificationService) dispatchBroadcastBatch(ctx context.Context, batchSize int) error {
+	broadcast, recipients, done, err := s.broadcasts.DeliverBatch(ctx, batchSize)
+	if err != nil || broadcast == nil {
+		return err
+	}
+
+	for _, recipient := range recipients {
+		s.ws.sendToUser(ctx, recipient.UserID.String(), WSMessage{Type: "announcement", Payload: gin.H{
+			"notification_id": recipient.NotificationID,
+			"broadcast_id":    broadcast.ID,
+			"title":           broadcast.Title,
+			"body":            broadcast.Body,
+			"action_url":      broadcast.ActionURL,
+		}})
+	}
+
+	if broadcast.SendEmail {
+		emailed, failed := s.emailBroadcast(ctx, broadcast, recipients)
+		if err := s.broadcasts.RecordEmails(ctx, broadcast.ID, emailed, failed); err != nil {
+			log.Printf("Failed to record email counts for broadcast %s: %v", broadcast.ID, err)
+		}
+	}
+	if done {
+		log.Printf("Broadcast %s sent", broadcast.ID)
+	}
+	return nil
+}
+
+// emailBroadcast emails the recipients who have email switched on,
+// returning how many were sent and how many failed
+func (s *NotificationService) emailBroadcast(ctx context.Context, broadcast *Broadcast, recipients []broadcastRecipient) (int, int) {
+	if s.messagingService == nil {
+		return 0, 0
+	}
+	channels := []models.DeliveryChannel{models.ChannelEmail}
+	emailed, failed := 0, 0
+	for _, recipient := range recipients {
+		if !recipient.EmailEnabled {
+			continue
+		}
+		message := &models.Message{
+			Type: models.MessageSystemAlert,
+			Content: models.MessageContent{
+				Subject:   broadcast.Title,
+				PlainText: broadcast.Body,
+				HTML:      broadcast.Body,
+				ActionURL: broadcast.ActionURL,
+				Variables: map[string]interface{}{
+					"title":        broadcast.Title,
+					"description":  broadcast.Body,
+					"action_url":   broadcast.ActionURL,
+					"broadcast_id": broadcast.ID.String(),
+				},
+			},
+			Metadata: map[string]interface{}{models.MessageMetaNotificationEvent: string(models.EventSystemAlert)},
+			Recipients: []models.Recipient{{
+				UserID:   recipient.UserID,
+				Channels: channels,
+				Preferences: models.UserNotificationSettings{
+					UserID:        recipient.UserID,
+					GlobalEnabled: true,
+					Channels:      map[models.DeliveryChannel]models.ChannelConfig{models.ChannelEmail: {Enabled: true}},
+					MessageTypes: map[models.MessageType]models.MessageTypeConfig{
+						models.MessageSystemAlert: {Enabled: true, Channels: channels, Frequency: models.FrequencyImmediate},
+					},
+					UpdatedAt: time.Now(),
+				},
+			}},
+		}
+		if err := s.messagingService.SendMessage(ctx, message); err != nil {
+			failed++
+			continue
+		}
+		emailed++
+	}
+	return emailed, failed
+}
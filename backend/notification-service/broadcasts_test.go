@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcastSegmentWhere(t *testing.T) {
+	where, args := BroadcastSegment{}.where(nil)
+	assert.Equal(t, "u.is_active = TRUE", where)
+	assert.Empty(t, args)
+
+	segment := BroadcastSegment{
+		Roles:            []string{"moderator", "tag_wrangler"},
+		Locales:          []string{"en", "fr"},
+		ActiveWithinDays: 90,
+		InactiveForDays:  30,
+	}
+	where, args = segment.where([]interface{}{"cursor", 500})
+	assert.Len(t, args, 6)
+	assert.Contains(t, where, "r.role = ANY($3) AND r.revoked_at IS NULL")
+	assert.Contains(t, where, "u.default_language = ANY($4)")
+	assert.Contains(t, where, "u.last_login_at >= NOW() - make_interval(days => $5)")
+	assert.Contains(t, where, "u.last_login_at < NOW() - make_interval(days => $6)")
+	assert.Equal(t, 90, args[4])
+	assert.Equal(t, 30, args[5])
+}
+
+func TestBroadcastSegmentValidate(t *testing.T) {
+	assert.NoError(t, BroadcastSegment{}.validate())
+	assert.NoError(t, BroadcastSegment{Roles: []string{"admin"}, Locales: []string{"pt-BR"}, ActiveWithinDays: 7}.validate())
+
+	invalid := map[string]BroadcastSegment{
+		"unknown role":      {Roles: []string{"superuser"}},
+		"empty locale":      {Locales: []string{""}},
+		"negative activity": {ActiveWithinDays: -1},
+		"window too long":   {InactiveForDays: broadcastMaxDays + 1},
+	}
+	for name, segment := range invalid {
+		assert.Error(t, segment.validate(), name)
+	}
+}
+
+func TestCreateBroadcastRejectsInvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := &NotificationService{}
+	router := gin.New()
+	router.POST("/admin/broadcasts", func(c *gin.Context) {
+		c.Set("user_id", "6f1c1e7a-3b9e-4c55-9a53-2d6e0f1b7c11")
+		service.createBroadcast(c)
+	})
+
+	bodies := []string{
+		`{"body":"No title"}`,
+		`{"title":"   ","body":"Blank title"}`,
+		`{"title":"` + strings.Repeat("x", broadcastTitleMax+1) + `","body":"Long title"}`,
+		`{"title":"Maintenance","body":"Tonight","segment":{"roles":["superuser"]}}`,
+	}
+	for _, body := range bodies {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/admin/broadcasts", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/messaging/deliverability"
+)
+
+// =============================================================================
+// EMAIL DELIVERABILITY
+// Amazon SES (through SNS) and SendGrid post bounces and spam complaints to
+// the webhooks here. Hard bounces, complaints and repeated soft bounces put
+// the address on the suppression list and switch email off for the account;
+// a user can see why and lift the suppression once their mailbox is fixed.
+// The webhooks are off unless EMAIL_WEBHOOK_TOKEN is set, and the provider
+// must send it as the token query parameter.
+// =============================================================================
+
+// maxWebhookBody bounds a provider's webhook post
+const maxWebhookBody = 1 << 20
+
+// setupDeliverability creates the deliverability service and has the
+// messaging service skip suppressed addresses
+func setupDeliverability(store *EmailDeliverabilityRepository) *deliverability.Service {
+	return deliverability.NewService(store, store, nil, deliverability.Config{
+		SoftBounceLimit:  getEnvInt("SOFT_BOUNCE_LIMIT", 5),
+		SoftBounceWindow: time.Duration(getEnvInt("SOFT_BOUNCE_WINDOW_DAYS", 7)) * 24 * time.Hour,
+	})
+}
+
+// readWebhook checks the webhook token and reads the body, writing the
+// error response itself when either fails
+func (s *NotificationService) readWebhook(c *gin.Context) ([]byte, bool) {
+	if s.deliverability == nil || s.emailWebhookToken == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "email webhooks are not configured"})
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(s.emailWebhookToken)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid webhook token"})
+		return nil, false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return nil, false
+	}
+	return body, true
+}
+
+// applyDeliverabilityEvents applies each event, logging rather than
+// failing on one that can't be applied so the provider doesn't resend
+// the whole batch
+func (s *NotificationService) applyDeliverabilityEvents(ctx context.Context, events []deliverability.Event) int {
+	applied := 0
+	for _, event := range events {
+		if err := s.deliverability.HandleEvent(ctx, event); err != nil {
+			log.Printf("Failed to apply %s %s event: %v", event.Provider, event.Type, err)
+			continue
+		}
+		applied++
+	}
+	return applied
+}
+
+// handleSESWebhook receives SES notifications from an SNS subscription
+func (s *NotificationService) handleSESWebhook(c *gin.Context) {
+	body, ok := s.readWebhook(c)
+	if !ok {
+		return
+	}
+	events, subscribeURL, err := deliverability.ParseSESNotification(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if subscribeURL != "" {
+		if err := confirmSNSSubscription(c.Request.Context(), subscribeURL); err != nil {
+			log.Printf("Failed to confirm SNS subscription: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to confirm subscription"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"confirmed": true})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"processed": s.applyDeliverabilityEvents(c.Request.Context(), events)})
+}
+
+// confirmSNSSubscription fetches the confirmation URL SNS sent, which the
+// parser has already checked is an AWS URL
+func confirmSNSSubscription(ctx context.Context, subscribeURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subscribeURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("SNS returned %s", resp.Status)
+	}
+	return nil
+}
+
+// handleSendGridWebhook receives SendGrid's event webhook. When
+// SENDGRID_WEBHOOK_PUBLIC_KEY is set the post must also be signed.
+func (s *NotificationService) handleSendGridWebhook(c *gin.Context) {
+	body, ok := s.readWebhook(c)
+	if !ok {
+		return
+	}
+	if s.sendGridPublicKey != "" {
+		if err := deliverability.VerifySendGridSignature(s.sendGridPublicKey,
+			c.GetHeader("X-Twilio-Email-Event-Webhook-Signature"),
+			c.GetHeader("X-Twilio-Email-Event-Webhook-Timestamp"), body); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+			return
+		}
+	}
+
+	events, err := deliverability.ParseSendGridEvents(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"processed": s.applyDeliverabilityEvents(c.Request.Context(), events)})
+}
+
+// getEmailStatus tells a user whether email to them is suppressed, and why
+func (s *NotificationService) getEmailStatus(c *gin.Context) {
+	userID, err := getUserUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	suppression, err := s.emailDeliverability.SuppressionForUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get email status"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"suppressed": suppression != nil, "suppression": suppression})
+}
+
+// deleteEmailSuppression lets email go to the user's address again. Email
+// stays off in their preferences until they turn it back on.
+func (s *NotificationService) deleteEmailSuppression(c *gin.Context) {
+	userID, err := getUserUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	suppression, err := s.emailDeliverability.SuppressionForUser(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get email status"})
+		return
+	}
+	if suppression == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "email is not suppressed"})
+		return
+	}
+	if err := s.deliverability.Unsuppress(ctx, suppression.Address); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to lift suppression"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// getMessageDeliveries returns the delivery status of the user's emails
+// for a message
+func (s *NotificationService) getMessageDeliveries(c *gin.Context) {
+	userID, err := getUserUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	messageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message ID"})
+		return
+	}
+
+	all, err := s.deliverability.DeliveriesForMessage(c.Request.Context(), messageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get deliveries"})
+		return
+	}
+	deliveries := []*deliverability.Delivery{}
+	for _, d := range all {
+		if d.UserID == userID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message_id": messageID, "deliveries": deliveries})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"nuclear-ao3/shared/messaging/deliverability"
+)
+
+func TestSendGridWebhookSuppressesBouncedAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := deliverability.NewService(deliverability.NewMemoryStore(), nil, nil, deliverability.Config{})
+	service := &NotificationService{deliverability: svc, emailWebhookToken: "secret"}
+	router := gin.New()
+	router.POST("/webhooks/email/sendgrid", service.handleSendGridWebhook)
+
+	body := `[{"email":"Gone@example.com","event":"bounce","type":"bounce","status":"5.1.1","reason":"user unknown","timestamp":1772697600}]`
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/webhooks/email/sendgrid?token=wrong", strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/webhooks/email/sendgrid?token=secret", strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	suppressed, err := svc.IsSuppressed(context.Background(), "gone@example.com")
+	assert.NoError(t, err)
+	assert.True(t, suppressed)
+}
+
+func TestEmailWebhooksOffWithoutToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := &NotificationService{deliverability: deliverability.NewService(deliverability.NewMemoryStore(), nil, nil, deliverability.Config{})}
+	router := gin.New()
+	router.POST("/webhooks/email/ses", service.handleSESWebhook)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/webhooks/email/ses?token=", strings.NewReader("{}"))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
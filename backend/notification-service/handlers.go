@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -49,7 +50,11 @@ func (s *NotificationService) handleWebSocket(c *gin.Context) {
 		return
 	}
 
-	userIDStr := userID.(string)
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID format"})
+		return
+	}
 
 	// Upgrade connection
 	conn, err := s.wsUpgrader.Upgrade(c.Writer, c.Request, nil)
@@ -57,54 +62,44 @@ func (s *NotificationService) handleWebSocket(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to upgrade connection"})
 		return
 	}
-	defer conn.Close()
-
-	// Store connection
-	s.wsClients[userIDStr] = conn
 
-	// Clean up on disconnect
-	defer func() {
-		delete(s.wsClients, userIDStr)
-	}()
+	client := &wsConn{
+		userID: userUUID.String(),
+		conn:   conn,
+		send:   make(chan []byte, wsSendBuffer),
+		done:   make(chan struct{}),
+	}
+	if !s.ws.register(client) {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too many connections"),
+			time.Now().Add(wsWriteWait))
+		conn.Close()
+		return
+	}
+	defer s.ws.unregister(client)
+	go client.writePump()
 
 	// Send initial notification count
-	count, err := s.notificationSvc.GetUnreadCount(context.Background(), uuid.MustParse(userIDStr))
-	if err == nil {
-		conn.WriteJSON(WSMessage{
-			Type: "unread_count",
-			Payload: gin.H{
-				"count": count,
-			},
-		})
+	if message, err := s.unreadCountMessage(context.Background(), userUUID); err == nil {
+		if payload, err := json.Marshal(message); err == nil {
+			s.ws.enqueue(client, payload)
+		}
 	}
 
 	// Keep connection alive
-	for {
-		_, _, err := conn.ReadMessage()
-		if err != nil {
-			break
-		}
-	}
+	client.readPump()
 }
 
-func (s *NotificationService) handleWebSocketBroadcast() {
-	for {
-		select {
-		case message := <-s.wsBroadcast:
-			// Broadcast to all connected clients
-			for userID, conn := range s.wsClients {
-				err := conn.WriteMessage(websocket.TextMessage, message)
-				if err != nil {
-					// Connection is dead, remove it
-					delete(s.wsClients, userID)
-					conn.Close()
-				}
-			}
-		}
-	}
-}
+// maxInboxPageSize caps the limit on a page of the inbox
+const maxInboxPageSize = 100
 
 // Notification handlers
+
+// getUserNotifications lists the inbox, newest first. type (comma-separated
+// events), category, unread=true, and since and until (RFC 3339) filter
+// it; archived=true lists the archive instead; and group=true collapses
+// kudos and bookmarks on the same work into one entry such as "12 kudos on
+// Work X", with limit and offset counting entries.
 func (s *NotificationService) getUserNotifications(c *gin.Context) {
 	userUUID, err := getUserUUID(c)
 	if err != nil {
@@ -125,6 +120,9 @@ func (s *NotificationService) getUserNotifications(c *gin.Context) {
 			limit = parsedLimit
 		}
 	}
+	if limit > maxInboxPageSize {
+		limit = maxInboxPageSize
+	}
 
 	if offsetStr := c.Query("offset"); offsetStr != "" {
 		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
@@ -132,17 +130,100 @@ func (s *NotificationService) getUserNotifications(c *gin.Context) {
 		}
 	}
 
-	// Temporary fix: Return empty notifications while debugging database schema issues
-	// Use userUUID to avoid unused variable error
-	_ = userUUID
+	filter, err := parseInboxFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("group") == "true" {
+		groups, err := s.notificationSvc.ListNotificationGroups(c.Request.Context(), userUUID, filter, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get notifications"})
+			return
+		}
+		if groups == nil {
+			groups = []*models.NotificationGroup{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"groups": groups,
+			"limit":  limit,
+			"offset": offset,
+		})
+		return
+	}
+
+	items, err := s.notificationSvc.ListNotifications(c.Request.Context(), userUUID, filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get notifications"})
+		return
+	}
+	if items == nil {
+		items = []*models.NotificationItem{}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"notifications": []interface{}{}, // Empty array for now
+		"notifications": items,
 		"limit":         limit,
 		"offset":        offset,
 	})
 }
 
+// parseInboxFilter reads a NotificationInboxFilter from the query string
+func parseInboxFilter(c *gin.Context) (models.NotificationInboxFilter, error) {
+	var filter models.NotificationInboxFilter
+	var err error
+	filter.Events = parseEventList(c.Query("type"))
+	if filter.Category, err = parseCategory(c.Query("category")); err != nil {
+		return filter, err
+	}
+	filter.UnreadOnly = c.Query("unread") == "true"
+	filter.Archived = c.Query("archived") == "true"
+	if filter.Since, err = parseTimeParam(c, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseTimeParam(c, "until"); err != nil {
+		return filter, err
+	}
+	if filter.Since != nil && filter.Until != nil && filter.Until.Before(*filter.Since) {
+		return filter, fmt.Errorf("until must not be before since")
+	}
+	return filter, nil
+}
+
+// parseEventList splits a comma-separated list of events
+func parseEventList(raw string) []models.NotificationEvent {
+	var events []models.NotificationEvent
+	for _, event := range strings.Split(raw, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			events = append(events, models.NotificationEvent(event))
+		}
+	}
+	return events
+}
+
+// parseCategory checks an optional category parameter
+func parseCategory(raw string) (models.NotificationCategory, error) {
+	category := models.NotificationCategory(raw)
+	if category != "" && !models.IsNotificationCategory(category) {
+		return "", fmt.Errorf("invalid category")
+	}
+	return category, nil
+}
+
+// parseTimeParam reads an optional RFC 3339 timestamp from the query string
+func parseTimeParam(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return &t, nil
+}
+
 func (s *NotificationService) markNotificationRead(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -165,18 +246,235 @@ func (s *NotificationService) markNotificationRead(c *gin.Context) {
 		return
 	}
 
-	// Broadcast updated unread count
-	count, _ := s.notificationSvc.GetUnreadCount(context.Background(), userUUID)
-	s.broadcastToUser(userID.(string), WSMessage{
+	s.syncReadState(context.Background(), userUUID, gin.H{"ids": []uuid.UUID{notificationUUID}})
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// markNotificationsRead marks every unread notification matching the
+// source_id, source_type, type (comma-separated events), category and
+// before query parameters as read in a single update. At least one filter
+// is required so a stray request can't clear the whole inbox; use
+// read-all for that.
+func (s *NotificationService) markNotificationsRead(c *gin.Context) {
+	s.markFilteredRead(c, false)
+}
+
+// markAllNotificationsRead marks the whole inbox read, or with before set
+// everything up to that time, so a client only marks what it has shown.
+// The other filters of markNotificationsRead apply too.
+func (s *NotificationService) markAllNotificationsRead(c *gin.Context) {
+	s.markFilteredRead(c, true)
+}
+
+func (s *NotificationService) markFilteredRead(c *gin.Context, allowAll bool) {
+	userUUID, err := getUserUUID(c)
+	if err != nil {
+		if err.Error() == "unauthorized" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	filter, err := parseReadFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.IsEmpty() && !allowAll {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source_id, source_type, type, category or before is required"})
+		return
+	}
+
+	marked, count, err := s.notificationSvc.MarkNotificationsRead(c.Request.Context(), userUUID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark notifications as read"})
+		return
+	}
+
+	if marked > 0 {
+		s.syncReadState(c.Request.Context(), userUUID, gin.H{"filter": readFilterPayload(filter)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"marked":       marked,
+		"unread_count": count,
+	})
+}
+
+// parseReadFilter reads a NotificationReadFilter from the query string
+func parseReadFilter(c *gin.Context) (models.NotificationReadFilter, error) {
+	var filter models.NotificationReadFilter
+	var err error
+	if sourceID := c.Query("source_id"); sourceID != "" {
+		id, err := uuid.Parse(sourceID)
+		if err != nil {
+			return filter, fmt.Errorf("invalid source_id")
+		}
+		filter.SourceID = &id
+	}
+	filter.SourceType = strings.TrimSpace(c.Query("source_type"))
+	filter.Events = parseEventList(c.Query("type"))
+	if filter.Category, err = parseCategory(c.Query("category")); err != nil {
+		return filter, err
+	}
+	if filter.Before, err = parseTimeParam(c, "before"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// readFilterPayload describes a filter to other tabs so they can mark the
+// same notifications read locally
+func readFilterPayload(filter models.NotificationReadFilter) gin.H {
+	payload := gin.H{"all": filter.IsEmpty()}
+	if len(filter.IDs) > 0 {
+		payload["ids"] = filter.IDs
+	}
+	if filter.SourceID != nil {
+		payload["source_id"] = filter.SourceID
+	}
+	if filter.SourceType != "" {
+		payload["source_type"] = filter.SourceType
+	}
+	if len(filter.Events) > 0 {
+		payload["events"] = filter.Events
+	}
+	if filter.Category != "" {
+		payload["category"] = filter.Category
+	}
+	if filter.Before != nil {
+		payload["before"] = filter.Before
+	}
+	return payload
+}
+
+// syncReadState tells every tab the user has open which notifications were
+// read and what is left unread, so they stay in step without refetching
+func (s *NotificationService) syncReadState(ctx context.Context, userID uuid.UUID, read gin.H) {
+	read["read_at"] = time.Now()
+	s.broadcastToUser(userID.String(), WSMessage{Type: "notifications_read", Payload: read})
+	if message, err := s.unreadCountMessage(ctx, userID); err == nil {
+		s.broadcastToUser(userID.String(), message)
+	}
+}
+
+// unreadCountMessage is the unread_count event, with the total and the
+// count in each category
+func (s *NotificationService) unreadCountMessage(ctx context.Context, userID uuid.UUID) (WSMessage, error) {
+	categories, err := s.notificationSvc.GetUnreadCountsByCategory(ctx, userID)
+	if err != nil {
+		return WSMessage{}, err
+	}
+	return WSMessage{
 		Type: "unread_count",
 		Payload: gin.H{
-			"count": count,
+			"count":      totalUnread(categories),
+			"categories": categories,
 		},
-	})
+	}, nil
+}
+
+func totalUnread(categories map[models.NotificationCategory]int) int {
+	total := 0
+	for _, count := range categories {
+		total += count
+	}
+	return total
+}
+
+// archiveNotification moves one notification out of the inbox into the
+// archive, marking it read
+func (s *NotificationService) archiveNotification(c *gin.Context) {
+	s.setArchived(c, true)
+}
 
+// unarchiveNotification moves an archived notification back to the inbox
+func (s *NotificationService) unarchiveNotification(c *gin.Context) {
+	s.setArchived(c, false)
+}
+
+func (s *NotificationService) setArchived(c *gin.Context, archived bool) {
+	userUUID, err := getUserUUID(c)
+	if err != nil {
+		if err.Error() == "unauthorized" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	notificationUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification ID"})
+		return
+	}
+
+	filter := models.NotificationReadFilter{IDs: []uuid.UUID{notificationUUID}}
+	changed, err := s.notificationSvc.SetNotificationsArchived(c.Request.Context(), userUUID, filter, archived)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update notification"})
+		return
+	}
+	if changed == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+		return
+	}
+
+	s.syncArchiveState(c.Request.Context(), userUUID, filter, archived)
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// archiveNotifications archives every inbox notification matching the
+// filters of markNotificationsRead, so a grouped entry is archived with
+// its source_id and type. At least one filter is required.
+func (s *NotificationService) archiveNotifications(c *gin.Context) {
+	userUUID, err := getUserUUID(c)
+	if err != nil {
+		if err.Error() == "unauthorized" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	filter, err := parseReadFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source_id, source_type, type, category or before is required"})
+		return
+	}
+
+	archived, err := s.notificationSvc.SetNotificationsArchived(c.Request.Context(), userUUID, filter, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to archive notifications"})
+		return
+	}
+	if archived > 0 {
+		s.syncArchiveState(c.Request.Context(), userUUID, filter, true)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"archived": archived})
+}
+
+// syncArchiveState tells every tab the user has open which notifications
+// moved in or out of the archive, then the unread counts archiving changed
+func (s *NotificationService) syncArchiveState(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter, archived bool) {
+	payload := readFilterPayload(filter)
+	payload["archived"] = archived
+	s.broadcastToUser(userID.String(), WSMessage{Type: "notifications_archived", Payload: payload})
+	if message, err := s.unreadCountMessage(ctx, userID); err == nil {
+		s.broadcastToUser(userID.String(), message)
+	}
+}
+
 func (s *NotificationService) deleteNotification(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -199,13 +497,9 @@ func (s *NotificationService) deleteNotification(c *gin.Context) {
 
 	// Broadcast updated unread count
 	userUUID := uuid.MustParse(userID.(string))
-	count, _ := s.notificationSvc.GetUnreadCount(context.Background(), userUUID)
-	s.broadcastToUser(userID.(string), WSMessage{
-		Type: "unread_count",
-		Payload: gin.H{
-			"count": count,
-		},
-	})
+	if message, err := s.unreadCountMessage(context.Background(), userUUID); err == nil {
+		s.broadcastToUser(userID.(string), message)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -232,6 +526,27 @@ func (s *NotificationService) getUnreadCount(c *gin.Context) {
 	})
 }
 
+// getUnreadCounts returns the unread total and the count in each category
+// (comments, kudos, subscriptions, other)
+func (s *NotificationService) getUnreadCounts(c *gin.Context) {
+	userUUID, err := getUserUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	categories, err := s.notificationSvc.GetUnreadCountsByCategory(c.Request.Context(), userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get unread counts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":      totalUnread(categories),
+		"categories": categories,
+	})
+}
+
 // Preference handlers
 func (s *NotificationService) getNotificationPreferences(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -263,6 +578,10 @@ func (s *NotificationService) updateNotificationPreferences(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
+	if err := notifications.ValidateDigestLayout(&preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	userUUID := uuid.MustParse(userID.(string))
 	preferences.UserID = userUUID
@@ -274,6 +593,72 @@ func (s *NotificationService) updateNotificationPreferences(c *gin.Context) {
 		return
 	}
 
+	// Saving preferences doesn't change a pause; report the one in effect
+	preferences.PausedUntil = nil
+	if current, err := s.notificationSvc.GetUserPreferences(context.Background(), userUUID); err == nil {
+		preferences.PausedUntil = current.PausedUntil
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// pauseNotifications suppresses all but critical notifications for a number
+// of days; what arrives meanwhile is sent as one digest when the pause ends
+func (s *NotificationService) pauseNotifications(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req struct {
+		Days int `json:"days" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Days < 1 || req.Days > notifications.MaxPauseDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", notifications.MaxPauseDays)})
+		return
+	}
+
+	userUUID := uuid.MustParse(userID.(string))
+	duration := time.Duration(req.Days) * 24 * time.Hour
+	if err := s.notificationSvc.DisableNotifications(context.Background(), userUUID.String(), duration); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to pause notifications"})
+		return
+	}
+
+	preferences, err := s.notificationSvc.GetUserPreferences(context.Background(), userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// resumeNotifications ends a pause early and sends what it held back
+func (s *NotificationService) resumeNotifications(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	userUUID := uuid.MustParse(userID.(string))
+	if err := s.notificationSvc.ResumeNotifications(context.Background(), userUUID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resume notifications"})
+		return
+	}
+
+	preferences, err := s.notificationSvc.GetUserPreferences(context.Background(), userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get preferences"})
+		return
+	}
+
 	c.JSON(http.StatusOK, preferences)
 }
 
@@ -450,10 +835,7 @@ func (s *NotificationService) processEvent(c *gin.Context) {
 
 // Helper methods
 func (s *NotificationService) broadcastToUser(userID string, message WSMessage) {
-	if conn, exists := s.wsClients[userID]; exists {
-		messageBytes, _ := json.Marshal(message)
-		conn.WriteMessage(websocket.TextMessage, messageBytes)
-	}
+	s.ws.sendToUser(context.Background(), userID, message)
 }
 
 // Helper methods for WebSocket and notification management are defined in main.go
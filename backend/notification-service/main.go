@@ -17,9 +17,17 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"nuclear-ao3/shared/database"
 	"nuclear-ao3/shared/messaging"
+	"nuclear-ao3/shared/messaging/deliverability"
+	"nuclear-ao3/shared/messaging/push"
+	"nuclear-ao3/shared/metrics"
+	"nuclear-ao3/shared/middleware"
 	"nuclear-ao3/shared/models"
 	"nuclear-ao3/shared/notifications"
+	"nuclear-ao3/shared/schema"
 )
 
 type NotificationService struct {
@@ -27,8 +35,23 @@ type NotificationService struct {
 	notificationSvc  *NotificationServiceExtended
 	messagingService messaging.MessageService
 	wsUpgrader       websocket.Upgrader
-	wsClients        map[string]*websocket.Conn // userID -> connection
-	wsBroadcast      chan []byte
+	ws               *wsHub
+
+	pushDevices   *PushDeviceRepository
+	pushProviders map[models.PushPlatform]messaging.ChannelProvider
+	webPush       *push.WebPushProvider
+
+	deliverability      *deliverability.Service
+	emailDeliverability *EmailDeliverabilityRepository
+	emailWebhookToken   string
+	sendGridPublicKey   string
+
+	unsubscribeTokens *messaging.UnsubscribeTokens
+
+	eventStream   *redis.Client
+	eventReceipts *EventReceiptRepository
+
+	broadcasts *BroadcastRepository
 }
 
 // NotificationServiceExtended adds additional methods to the notification service
@@ -43,6 +66,31 @@ func (ns *NotificationServiceExtended) GetUnreadCount(ctx context.Context, userI
 	return ns.notificationRepo.GetUnreadCount(ctx, userID)
 }
 
+// GetUnreadCountsByCategory counts unread notifications in each category
+func (ns *NotificationServiceExtended) GetUnreadCountsByCategory(ctx context.Context, userID uuid.UUID) (map[models.NotificationCategory]int, error) {
+	byEvent, err := ns.notificationRepo.GetUnreadCountsByEvent(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return models.UnreadCountsByCategory(byEvent), nil
+}
+
+func (ns *NotificationServiceExtended) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter) (int, int, error) {
+	return ns.notificationRepo.MarkNotificationsRead(ctx, userID, filter)
+}
+
+func (ns *NotificationServiceExtended) ListNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationItem, error) {
+	return ns.notificationRepo.ListNotifications(ctx, userID, filter, limit, offset)
+}
+
+func (ns *NotificationServiceExtended) ListNotificationGroups(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationGroup, error) {
+	return ns.notificationRepo.ListNotificationGroups(ctx, userID, filter, limit, offset)
+}
+
+func (ns *NotificationServiceExtended) SetNotificationsArchived(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter, archived bool) (int, error) {
+	return ns.notificationRepo.SetNotificationsArchived(ctx, userID, filter, archived)
+}
+
 func (ns *NotificationServiceExtended) DeleteNotification(ctx context.Context, notificationID uuid.UUID) error {
 	return ns.notificationRepo.DeleteNotification(ctx, notificationID)
 }
@@ -55,6 +103,12 @@ func (ns *NotificationServiceExtended) UpdateUserPreferences(ctx context.Context
 	return ns.preferenceRepo.UpdatePreferences(ctx, preferences)
 }
 
+// SaveUserPreferences saves preferences, creating them for users who never
+// saved any
+func (ns *NotificationServiceExtended) SaveUserPreferences(ctx context.Context, preferences *models.NotificationPreferences) error {
+	return ns.preferenceRepo.CreatePreferences(ctx, preferences)
+}
+
 func (ns *NotificationServiceExtended) GetUserSubscriptions(ctx context.Context, userID uuid.UUID) ([]*models.Subscription, error) {
 	return ns.subscriptionRepo.FindByUser(ctx, userID)
 }
@@ -84,6 +138,17 @@ func main() {
 		log.Fatal("Failed to ping database:", err)
 	}
 
+	database.PoolConfigFromEnv(database.DefaultPoolConfig()).Apply(db)
+	metrics.InstrumentDBPool("notification-service", db)
+
+	// `main migrate [up|status|baseline N]` manages the shared schema and
+	// exits; otherwise pending migrations are applied before serving
+	if done, err := schema.Ready(context.Background(), db, os.Args[1:]); err != nil {
+		log.Fatal("Schema is not ready: ", err)
+	} else if done {
+		return
+	}
+
 	// Initialize messaging service
 	messagingService := messaging.NewUniversalMessageService(
 		nil, // telemetry
@@ -95,10 +160,16 @@ func main() {
 	)
 
 	// Initialize repositories
-	subscriptionRepo := NewSubscriptionRepository(db)
+	subscriptionRepo := notifications.NewPostgresSubscriptionRepository(db)
 	notificationRepo := NewNotificationRepository(db)
 	digestRepo := NewDigestRepository(db)
 	preferenceRepo := NewPreferenceRepository(db)
+	pushDevices := NewPushDeviceRepository(db)
+	webPush, pushProviders := setupPushProviders(messagingService, pushDevices)
+	emailDeliverability := NewEmailDeliverabilityRepository(db)
+	deliverabilitySvc := setupDeliverability(emailDeliverability)
+	messagingService.SetDeliverabilityTracker(deliverabilitySvc)
+	unsubscribeTokens := setupUnsubscribeTokens(messagingService)
 
 	// Initialize notification service
 	coreNotificationSvc := notifications.NewNotificationService(
@@ -112,6 +183,14 @@ func main() {
 			BatchIntervalMinutes: getEnvInt("BATCH_INTERVAL_MINUTES", 60),
 			MaxBatchSize:         getEnvInt("MAX_BATCH_SIZE", 50),
 			EnableSmartFiltering: getEnvBool("ENABLE_SMART_FILTERING", true),
+
+			EnableDigestScheduler: getEnvBool("ENABLE_DIGEST_SCHEDULER", true),
+			DigestScheduler: notifications.DigestSchedulerConfig{
+				Hour:          getEnvInt("DIGEST_HOUR", 8),
+				Weekday:       getEnvWeekday("DIGEST_WEEKDAY", time.Monday),
+				CheckInterval: time.Duration(getEnvInt("DIGEST_CHECK_INTERVAL_MINUTES", 15)) * time.Minute,
+			},
+			Blocks: NewUserBlockRepository(db),
 		},
 	)
 
@@ -151,8 +230,22 @@ func main() {
 		notificationSvc:  extendedNotificationSvc,
 		messagingService: messagingService,
 		wsUpgrader:       wsUpgrader,
-		wsClients:        make(map[string]*websocket.Conn),
-		wsBroadcast:      make(chan []byte),
+		ws:               newWSHub(connectRealtimeRedis()),
+		pushDevices:      pushDevices,
+		pushProviders:    pushProviders,
+		webPush:          webPush,
+
+		deliverability:      deliverabilitySvc,
+		emailDeliverability: emailDeliverability,
+		emailWebhookToken:   getEnv("EMAIL_WEBHOOK_TOKEN", ""),
+		sendGridPublicKey:   getEnv("SENDGRID_WEBHOOK_PUBLIC_KEY", ""),
+
+		unsubscribeTokens: unsubscribeTokens,
+
+		eventStream:   connectEventStreamRedis(),
+		eventReceipts: NewEventReceiptRepository(db),
+
+		broadcasts: NewBroadcastRepository(db),
 	}
 
 	// Setup HTTP server
@@ -167,6 +260,7 @@ func main() {
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
+	router.Use(middleware.NewReadOnlyModeFromEnv().Middleware())
 
 	// Temporary simple auth middleware - accepts any Bearer token with valid X-User-ID
 	authMiddleware := func(c *gin.Context) {
@@ -194,6 +288,17 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy", "service": "notification-service"})
 	})
 
+	// Metrics endpoint for monitoring
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// Email provider webhooks authenticate with EMAIL_WEBHOOK_TOKEN
+	router.POST("/webhooks/email/ses", service.handleSESWebhook)
+	router.POST("/webhooks/email/sendgrid", service.handleSendGridWebhook)
+
+	// Unsubscribe links authenticate with their signed token, not a login
+	router.GET("/unsubscribe", service.describeUnsubscribe)
+	router.POST("/unsubscribe", service.unsubscribe)
+
 	// WebSocket endpoint for real-time notifications - use query param auth
 	router.GET("/ws", func(c *gin.Context) {
 		token := c.Query("token")
@@ -220,13 +325,33 @@ func main() {
 	{
 		// Notifications
 		api.GET("/notifications", service.getUserNotifications)
+		api.PUT("/notifications/read", service.markNotificationsRead)
+		api.PUT("/notifications/read-all", service.markAllNotificationsRead)
+		api.PUT("/notifications/archive", service.archiveNotifications)
+		api.PUT("/notifications/:id/archive", service.archiveNotification)
+		api.DELETE("/notifications/:id/archive", service.unarchiveNotification)
 		api.PUT("/notifications/:id/read", service.markNotificationRead)
 		api.DELETE("/notifications/:id", service.deleteNotification)
 		api.GET("/notifications/unread-count", service.getUnreadCount)
+		api.GET("/notifications/unread-counts", service.getUnreadCounts)
 
 		// Preferences
 		api.GET("/preferences", service.getNotificationPreferences)
 		api.PUT("/preferences", service.updateNotificationPreferences)
+		api.POST("/preferences/pause", service.pauseNotifications)
+		api.DELETE("/preferences/pause", service.resumeNotifications)
+		api.PUT("/preferences/channels/:channel", service.setChannelEnabled)
+
+		// Push devices
+		api.GET("/push/config", service.getPushConfig)
+		api.GET("/push/devices", service.getPushDevices)
+		api.POST("/push/devices", service.registerPushDevice)
+		api.DELETE("/push/devices/:id", service.deletePushDevice)
+
+		// Email deliverability
+		api.GET("/email/status", service.getEmailStatus)
+		api.DELETE("/email/suppression", service.deleteEmailSuppression)
+		api.GET("/messages/:id/deliveries", service.getMessageDeliveries)
 
 		// Subscriptions
 		api.GET("/subscriptions", service.getUserSubscriptions)
@@ -245,8 +370,35 @@ func main() {
 		api.POST("/process-event", service.processEvent)
 	}
 
-	// Start WebSocket broadcaster
-	go service.handleWebSocketBroadcast()
+	// Admin broadcast announcements
+	admin := router.Group("/api/v1/admin")
+	admin.Use(authMiddleware, service.requireAdmin)
+	{
+		admin.POST("/broadcasts", service.createBroadcast)
+		admin.GET("/broadcasts", service.listBroadcasts)
+		admin.GET("/broadcasts/:id", service.getBroadcast)
+		admin.DELETE("/broadcasts/:id", service.cancelBroadcast)
+	}
+
+	// Internal operational endpoints (service token)
+	internal := router.Group("/api/v1/internal")
+	internal.Use(middleware.ServiceTokenMiddleware())
+	{
+		internal.GET("/notification-events/dead-letters", service.getNotificationEventDeadLetters)
+	}
+
+	// Fan WebSocket events in from other replicas
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	go service.ws.run(hubCtx)
+
+	// Turn the events work-service publishes into notifications
+	go service.startNotificationEventConsumer(hubCtx)
+
+	// Fan admin announcements out a batch at a time
+	go service.startBroadcastDispatcher(hubCtx,
+		time.Duration(getEnvInt("BROADCAST_BATCH_INTERVAL_SECONDS", 2))*time.Second,
+		getEnvInt("BROADCAST_BATCH_SIZE", 500))
 
 	// Start HTTP server
 	port := getEnv("PORT", "8004")
@@ -299,6 +451,18 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvWeekday reads a day name such as "monday"
+func getEnvWeekday(key string, defaultValue time.Weekday) time.Weekday {
+	if value := strings.ToLower(os.Getenv(key)); value != "" {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.ToLower(day.String()) == value {
+				return day
+			}
+		}
+	}
+	return defaultValue
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"nuclear-ao3/shared/notificationstream"
+)
+
+// =============================================================================
+// NOTIFICATION EVENT STREAM
+// work-service queues notification events in its outbox and publishes them
+// to a Redis stream; this consumer group turns them into notifications.
+// Delivery is at least once: an event is acknowledged only after it has been
+// processed, and one left pending by a failure or a dead replica is claimed
+// again once idle. Its idempotency key is recorded after processing and
+// checked first, so an event delivered twice notifies once. Events that
+// can't be decoded are dead-lettered.
+// =============================================================================
+
+const (
+	notificationEventBatchSize       = 50
+	notificationEventBlock           = 5 * time.Second
+	notificationEventRetryIdle       = time.Minute
+	notificationEventReclaimInterval = 30 * time.Second
+	notificationDeadLetterMaxLen     = 10000
+
+	// eventReceiptRetention is how long processed keys are remembered; far
+	// longer than work-service keeps an event it might publish again
+	eventReceiptRetention     = 30 * 24 * time.Hour
+	eventReceiptPurgeInterval = time.Hour
+)
+
+// connectEventStreamRedis connects to the Redis database the notification
+// stream lives in, work-service's, returning nil when it can't be reached
+func connectEventStreamRedis() *redis.Client {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:         getEnv("REDIS_URL", "localhost:6379"),
+		Password:     getEnv("REDIS_PASSWORD", ""),
+		DB:           1, // Shared with work service
+		PoolSize:     5,
+		MinIdleConns: 1,
+		MaxRetries:   3,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		log.Printf("Redis unavailable, notification events will not be consumed: %v", err)
+		rdb.Close()
+		return nil
+	}
+	return rdb
+}
+
+// startNotificationEventConsumer reads the notification stream until ctx is
+// cancelled
+func (s *NotificationService) startNotificationEventConsumer(ctx context.Context) {
+	if s.eventStream == nil {
+		log.Println("Notification event consumer disabled: no Redis connection")
+		return
+	}
+	consumer := notificationEventConsumerName()
+
+	for s.ensureNotificationEventGroup(ctx) != nil {
+		if !sleepContext(ctx, 5*time.Second) {
+			return
+		}
+	}
+	log.Printf("Notification event consumer %s started", consumer)
+
+	lastReclaim, lastPurge := time.Now(), time.Time{}
+	for ctx.Err() == nil {
+		streams, err := s.eventStream.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    notificationstream.ConsumerGroup,
+			Consumer: consumer,
+			Streams:  []string{notificationstream.Stream, ">"},
+			Count:    notificationEventBatchSize,
+			Block:    notificationEventBlock,
+		}).Result()
+		switch {
+		case err == redis.Nil:
+		case err != nil:
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Reading notification events failed: %v", err)
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				s.ensureNotificationEventGroup(ctx)
+			}
+			sleepContext(ctx, time.Second)
+		default:
+			for _, stream := range streams {
+				for _, msg := range stream.Messages {
+					s.processNotificationEventMessage(ctx, msg)
+				}
+			}
+		}
+
+		if time.Since(lastReclaim) >= notificationEventReclaimInterval {
+			s.reclaimNotificationEvents(ctx, consumer)
+			lastReclaim = time.Now()
+		}
+		if time.Since(lastPurge) >= eventReceiptPurgeInterval {
+			if _, err := s.eventReceipts.PurgeBefore(ctx, time.Now().Add(-eventReceiptRetention)); err != nil {
+				log.Printf("Failed to purge notification event receipts: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+	}
+	log.Println("Notification event consumer stopped")
+}
+
+// ensureNotificationEventGroup creates the consumer group, reading the
+// stream from the start so events published before the first deploy
+// aren't skipped
+func (s *NotificationService) ensureNotificationEventGroup(ctx context.Context) error {
+	err := s.eventStream.XGroupCreateMkStream(ctx, notificationstream.Stream, notificationstream.ConsumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		log.Printf("Failed to create notification event consumer group: %v", err)
+		return err
+	}
+	return nil
+}
+
+func notificationEventConsumerName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "notification-service"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// sleepContext waits d, returning false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// processNotificationEventMessage processes one event and acknowledges it.
+// A failed event stays pending for reclaimNotificationEvents; one that
+// can't be decoded is dead-lettered straight away.
+func (s *NotificationService) processNotificationEventMessage(ctx context.Context, msg redis.XMessage) {
+	event, err := notificationstream.ParseEvent(msg.Values)
+	if err != nil {
+		log.Printf("Dead-lettering malformed notification event %s: %v", msg.ID, err)
+		s.deadLetterNotificationEvent(ctx, msg, err.Error())
+		return
+	}
+
+	processed, err := s.eventReceipts.Processed(ctx, event.Key)
+	if err != nil {
+		log.Printf("Failed to check notification event %s, will retry: %v", event.Key, err)
+		return
+	}
+	if !processed {
+		if err := s.notificationSvc.ProcessEvent(ctx, &event.Data); err != nil {
+			log.Printf("Notification event %s (%s) failed, will retry: %v", event.Key, event.Data.Type, err)
+			return
+		}
+		// Should this fail the event is processed again when redelivered
+		if err := s.eventReceipts.Record(ctx, event.Key, event.Data.Type); err != nil {
+			log.Printf("Failed to record notification event %s: %v", event.Key, err)
+		}
+	}
+	if err := s.eventStream.XAck(ctx, notificationstream.Stream, notificationstream.ConsumerGroup, msg.ID).Err(); err != nil {
+		log.Printf("Failed to acknowledge notification event %s: %v", msg.ID, err)
+	}
+}
+
+// reclaimNotificationEvents retries events left pending past
+// notificationEventRetryIdle, including those of consumers that died
+func (s *NotificationService) reclaimNotificationEvents(ctx context.Context, consumer string) {
+	msgs, _, err := s.eventStream.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   notificationstream.Stream,
+		Group:    notificationstream.ConsumerGroup,
+		Consumer: consumer,
+		MinIdle:  notificationEventRetryIdle,
+		Start:    "0",
+		Count:    notificationEventBatchSize,
+	}).Result()
+	if err != nil {
+		log.Printf("Failed to reclaim pending notification events: %v", err)
+		return
+	}
+	for _, msg := range msgs {
+		s.processNotificationEventMessage(ctx, msg)
+	}
+}
+
+// deadLetterNotificationEvent moves an event to the dead-letter stream
+func (s *NotificationService) deadLetterNotificationEvent(ctx context.Context, msg redis.XMessage, reason string) {
+	values := map[string]interface{}{}
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["original_id"] = msg.ID
+	values["error"] = reason
+	values["dead_lettered_at"] = time.Now().UTC().Format(time.RFC3339)
+
+	err := s.eventStream.XAdd(ctx, &redis.XAddArgs{
+		Stream: notificationstream.DeadLetterStream,
+		MaxLen: notificationDeadLetterMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		log.Printf("Failed to dead-letter notification event %s: %v", msg.ID, err)
+		return
+	}
+	s.eventStream.XAck(ctx, notificationstream.Stream, notificationstream.ConsumerGroup, msg.ID)
+}
+
+// getNotificationEventDeadLetters lists dead-lettered notification events,
+// newest first, with the consumer's backlog:
+// GET /api/v1/internal/notification-events/dead-letters
+func (s *NotificationService) getNotificationEventDeadLetters(c *gin.Context) {
+	if s.eventStream == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "notification event stream is not connected"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	msgs, err := s.eventStream.XRevRangeN(ctx, notificationstream.DeadLetterStream, "+", "-", 100).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load dead letters"})
+		return
+	}
+	deadLetters := make([]gin.H, 0, len(msgs))
+	for _, msg := range msgs {
+		entry := gin.H{"id": msg.ID}
+		for _, field := range []string{"key", "type", "original_id", "error", "dead_lettered_at"} {
+			entry[field] = msg.Values[field]
+		}
+		deadLetters = append(deadLetters, entry)
+	}
+
+	response := gin.H{"dead_letters": deadLetters}
+	if total, err := s.eventStream.XLen(ctx, notificationstream.DeadLetterStream).Result(); err == nil {
+		response["total"] = total
+	}
+	if pending, err := s.eventStream.XPending(ctx, notificationstream.Stream, notificationstream.ConsumerGroup).Result(); err == nil {
+		response["pending"] = pending.Count
+	}
+	c.JSON(http.StatusOK, response)
+}
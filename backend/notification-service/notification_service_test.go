@@ -13,9 +13,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"nuclear-ao3/shared/messaging"
 	"nuclear-ao3/shared/models"
 )
 
@@ -44,8 +44,8 @@ func (suite *NotificationServiceTestSuite) SetupTest() {
 			notificationRepo: &MockNotificationRepository{},
 			preferenceRepo:   &MockPreferenceRepository{},
 		},
-		wsClients:   make(map[string]*websocket.Conn),
-		wsBroadcast: make(chan []byte, 10),
+		ws:                newWSHub(nil),
+		unsubscribeTokens: messaging.NewUnsubscribeTokens("test-secret", "http://localhost/unsubscribe"),
 	}
 
 	// Setup router
@@ -60,10 +60,19 @@ func (suite *NotificationServiceTestSuite) setupRoutes() {
 		c.Next()
 	}
 
+	suite.router.GET("/unsubscribe", suite.service.describeUnsubscribe)
+	suite.router.POST("/unsubscribe", suite.service.unsubscribe)
+
 	api := suite.router.Group("/api/v1")
 	api.Use(authMiddleware)
 	{
 		api.GET("/notifications", suite.service.getUserNotifications)
+		api.PUT("/notifications/read", suite.service.markNotificationsRead)
+		api.PUT("/notifications/read-all", suite.service.markAllNotificationsRead)
+		api.PUT("/notifications/archive", suite.service.archiveNotifications)
+		api.PUT("/notifications/:id/archive", suite.service.archiveNotification)
+		api.DELETE("/notifications/:id/archive", suite.service.unarchiveNotification)
+		api.GET("/notifications/unread-counts", suite.service.getUnreadCounts)
 		api.PUT("/notifications/:id/read", suite.service.markNotificationRead)
 		api.DELETE("/notifications/:id", suite.service.deleteNotification)
 		api.GET("/notifications/unread-count", suite.service.getUnreadCount)
@@ -89,6 +98,93 @@ func (suite *NotificationServiceTestSuite) TestGetUserNotifications_Success() {
 	assert.Contains(suite.T(), response, "notifications")
 }
 
+func (suite *NotificationServiceTestSuite) TestGetUserNotifications_Filters() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/notifications?type=comment_received&unread=true&since=2026-01-01T00:00:00Z&archived=true", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	for _, query := range []string{"since=last-week", "category=fanart", "since=2026-02-01T00:00:00Z&until=2026-01-01T00:00:00Z"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/api/v1/notifications?"+query, nil)
+		suite.router.ServeHTTP(w, req)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func (suite *NotificationServiceTestSuite) TestGetUserNotifications_Grouped() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/notifications?group=true", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var response struct {
+		Groups []models.NotificationGroup `json:"groups"`
+	}
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(suite.T(), response.Groups, 1) {
+		assert.Equal(suite.T(), "12 kudos on Test Work", response.Groups[0].Summary)
+		assert.Equal(suite.T(), 3, response.Groups[0].UnreadCount)
+	}
+}
+
+func (suite *NotificationServiceTestSuite) TestArchiveNotifications() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/notifications/"+uuid.New().String()+"/archive", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/v1/notifications/"+uuid.New().String()+"/archive", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/v1/notifications/not-a-uuid/archive", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	// A whole group is archived by its source and event
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/v1/notifications/archive?source_id="+suite.testWorkID.String()+"&type=kudos_received", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	var response map[string]interface{}
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), float64(12), response["archived"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/v1/notifications/archive", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+func (suite *NotificationServiceTestSuite) TestUnsubscribeLink() {
+	token := suite.service.unsubscribeTokens.Generate(messaging.UnsubscribeClaims{
+		UserID: suite.testUserID,
+		Scope:  messaging.UnsubscribeEvent,
+		Event:  models.EventCommentReceived,
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/unsubscribe?token="+token, nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"event":"comment_received"`)
+
+	// One-click unsubscribe from a mail client
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/unsubscribe?token="+token, strings.NewReader("List-Unsubscribe=One-Click"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/unsubscribe?token="+token+"x", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
 func (suite *NotificationServiceTestSuite) TestGetUnreadCount_Success() {
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/api/v1/notifications/unread-count", nil)
@@ -116,6 +212,64 @@ func (suite *NotificationServiceTestSuite) TestMarkNotificationRead_Success() {
 	assert.Equal(suite.T(), true, response["success"])
 }
 
+func (suite *NotificationServiceTestSuite) TestMarkNotificationsRead_BySource() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/notifications/read?source_id="+suite.testWorkID.String()+"&type=comment_received,kudos_received", nil)
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), float64(2), response["marked"])
+	assert.Equal(suite.T(), float64(1), response["unread_count"])
+}
+
+func (suite *NotificationServiceTestSuite) TestMarkNotificationsRead_RequiresFilter() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/notifications/read", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/v1/notifications/read?source_id=not-a-uuid", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+func (suite *NotificationServiceTestSuite) TestMarkAllNotificationsRead_UpTo() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/notifications/read-all?before=2026-01-02T15:04:05Z", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/v1/notifications/read-all?before=yesterday", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/v1/notifications/read-all?category=fanart", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+func (suite *NotificationServiceTestSuite) TestGetUnreadCounts_ByCategory() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/notifications/unread-counts", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var response struct {
+		Count      int            `json:"count"`
+		Categories map[string]int `json:"categories"`
+	}
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), 1, response.Count)
+	assert.Equal(suite.T(), map[string]int{"comments": 1, "kudos": 0, "subscriptions": 0, "other": 0}, response.Categories)
+}
+
 func (suite *NotificationServiceTestSuite) TestCreateSubscription_Success() {
 	subscription := map[string]interface{}{
 		"type":      "work",
@@ -300,6 +454,43 @@ func (m *MockNotificationRepository) GetUnreadCount(ctx context.Context, userID
 	return 3, nil
 }
 
+func (m *MockNotificationRepository) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter) (int, int, error) {
+	return 2, 1, nil
+}
+
+func (m *MockNotificationRepository) GetUnreadCountsByEvent(ctx context.Context, userID uuid.UUID) (map[models.NotificationEvent]int, error) {
+	return map[models.NotificationEvent]int{models.EventCommentReceived: 1}, nil
+}
+
+func (m *MockNotificationRepository) ListNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationItem, error) {
+	return m.GetUserNotifications(ctx, userID, limit, offset)
+}
+
+func (m *MockNotificationRepository) ListNotificationGroups(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationGroup, error) {
+	latest := &models.NotificationItem{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Event:      models.EventKudosReceived,
+		SourceID:   uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+		SourceType: "work",
+		Title:      "New kudos",
+		ExtraData:  map[string]interface{}{"work_title": "Test Work"},
+		CreatedAt:  time.Now(),
+	}
+	return []*models.NotificationGroup{models.NewNotificationGroup(latest, 12, 3)}, nil
+}
+
+func (m *MockNotificationRepository) SetNotificationsArchived(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter, archived bool) (int, error) {
+	if len(filter.IDs) > 0 {
+		return len(filter.IDs), nil
+	}
+	return 12, nil
+}
+
+func (m *MockNotificationRepository) GetUsersAwaitingDigest(ctx context.Context, frequency models.NotificationFrequency) ([]uuid.UUID, error) {
+	return nil, nil
+}
+
 func (m *MockNotificationRepository) GetNotificationsForBatch(ctx context.Context, userID uuid.UUID, frequency models.NotificationFrequency) ([]*models.NotificationItem, error) {
 	return []*models.NotificationItem{}, nil
 }
@@ -319,6 +510,10 @@ func (m *MockPreferenceRepository) CreatePreferences(ctx context.Context, prefer
 	return nil
 }
 
+func (m *MockPreferenceRepository) SetPausedUntil(ctx context.Context, userID uuid.UUID, until *time.Time) error {
+	return nil
+}
+
 func TestNotificationServiceTestSuite(t *testing.T) {
 	suite.Run(t, new(NotificationServiceTestSuite))
 }
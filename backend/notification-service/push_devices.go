@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/messaging"
+	"nuclear-ao3/shared/messaging/push"
+	"nuclear-ao3/shared/models"
+)
+
+// =============================================================================
+// PUSH DEVICES
+// Browsers subscribe through the Push API with the VAPID public key and
+// register the subscription here; mobile apps register their FCM or APNs
+// token. Each kind of push is switched on separately in preferences, and a
+// notification is pushed when its event's channels include that push
+// channel.
+// =============================================================================
+
+// maxDevicesPerUser stops one account filling the table with tokens
+const maxDevicesPerUser = 20
+
+// setupPushProviders registers whichever push providers are configured
+// with the messaging service. Web Push needs VAPID_PRIVATE_KEY and
+// VAPID_SUBJECT; mobile push needs FCM_SERVICE_ACCOUNT_FILE, or
+// APNS_KEY_FILE with APNS_KEY_ID, APNS_TEAM_ID and APNS_BUNDLE_ID, or both.
+func setupPushProviders(messagingService messaging.MessageService, devices push.DeviceStore) (*push.WebPushProvider, map[models.PushPlatform]messaging.ChannelProvider) {
+	providers := map[models.PushPlatform]messaging.ChannelProvider{}
+
+	var webPush *push.WebPushProvider
+	if key := getEnv("VAPID_PRIVATE_KEY", ""); key != "" {
+		provider, err := push.NewWebPushProvider(push.WebPushConfig{
+			VAPIDPrivateKey: key,
+			Subject:         getEnv("VAPID_SUBJECT", ""),
+		}, devices)
+		if err != nil {
+			log.Printf("Web push disabled: %v", err)
+		} else {
+			messagingService.RegisterChannelProvider(provider)
+			webPush = provider
+			providers[models.PushPlatformWeb] = provider
+		}
+	}
+
+	var fcmConfig *push.FCMConfig
+	if file := getEnv("FCM_SERVICE_ACCOUNT_FILE", ""); file != "" {
+		fcmConfig = &push.FCMConfig{ServiceAccountFile: file}
+	}
+	var apnsConfig *push.APNsConfig
+	if file := getEnv("APNS_KEY_FILE", ""); file != "" {
+		apnsConfig = &push.APNsConfig{
+			KeyFile:  file,
+			KeyID:    getEnv("APNS_KEY_ID", ""),
+			TeamID:   getEnv("APNS_TEAM_ID", ""),
+			BundleID: getEnv("APNS_BUNDLE_ID", ""),
+			Sandbox:  getEnvBool("APNS_SANDBOX", false),
+		}
+	}
+	if fcmConfig != nil || apnsConfig != nil {
+		provider, err := push.NewMobilePushProvider(fcmConfig, apnsConfig, devices)
+		if err != nil {
+			log.Printf("Mobile push disabled: %v", err)
+		} else {
+			messagingService.RegisterChannelProvider(provider)
+			for _, platform := range provider.Platforms() {
+				providers[platform] = provider
+			}
+		}
+	}
+
+	return webPush, providers
+}
+
+// pushPlatforms lists the platforms devices can be registered on
+func (s *NotificationService) pushPlatforms() []models.PushPlatform {
+	platforms := make([]models.PushPlatform, 0, len(s.pushProviders))
+	for platform := range s.pushProviders {
+		platforms = append(platforms, platform)
+	}
+	sort.Slice(platforms, func(i, j int) bool { return platforms[i] < platforms[j] })
+	return platforms
+}
+
+// getPushConfig tells clients which push platforms work and gives browsers
+// the VAPID key to subscribe with
+func (s *NotificationService) getPushConfig(c *gin.Context) {
+	config := gin.H{"platforms": s.pushPlatforms()}
+	if s.webPush != nil {
+		config["vapid_public_key"] = s.webPush.PublicKey()
+	}
+	c.JSON(http.StatusOK, config)
+}
+
+// registerPushDevice registers a browser subscription or app token:
+//
+//	{"platform": "web", "subscription": {"endpoint": "...", "keys": {"p256dh": "...", "auth": "..."}}, "name": "Firefox"}
+//	{"platform": "fcm" | "apns", "token": "...", "name": "Pixel 8"}
+func (s *NotificationService) registerPushDevice(c *gin.Context) {
+	userID, err := getUserUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req struct {
+		Platform     models.PushPlatform `json:"platform" binding:"required"`
+		Token        string              `json:"token"`
+		Name         string              `json:"name"`
+		Subscription struct {
+			Endpoint string `json:"endpoint"`
+			Keys     struct {
+				P256dh string `json:"p256dh"`
+				Auth   string `json:"auth"`
+			} `json:"keys"`
+		} `json:"subscription"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	provider, ok := s.pushProviders[req.Platform]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "push platform not available", "platforms": s.pushPlatforms()})
+		return
+	}
+	if len(req.Name) > 100 {
+		req.Name = req.Name[:100]
+	}
+
+	device := &models.PushDevice{UserID: userID, Platform: req.Platform, Token: req.Token, Name: req.Name}
+	if req.Platform == models.PushPlatformWeb {
+		sub := req.Subscription
+		if sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "web push subscriptions need p256dh and auth keys"})
+			return
+		}
+		device.Token, device.P256dh, device.Auth = sub.Endpoint, sub.Keys.P256dh, sub.Keys.Auth
+	}
+	if err := provider.ValidateAddress(device.Token); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	existing, err := s.pushDevices.DevicesForUser(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register device"})
+		return
+	}
+	if len(existing) >= maxDevicesPerUser && !hasDeviceToken(existing, device) {
+		c.JSON(http.StatusConflict, gin.H{"error": "too many push devices; remove one first", "max": maxDevicesPerUser})
+		return
+	}
+	if err := s.pushDevices.RegisterDevice(ctx, device); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register device"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, device)
+}
+
+// hasDeviceToken reports whether a device is already among a user's
+// devices, so re-registering it doesn't count against the limit
+func hasDeviceToken(devices []*models.PushDevice, device *models.PushDevice) bool {
+	for _, d := range devices {
+		if d.Platform == device.Platform && d.Token == device.Token {
+			return true
+		}
+	}
+	return false
+}
+
+// getPushDevices lists the user's registered devices
+func (s *NotificationService) getPushDevices(c *gin.Context) {
+	userID, err := getUserUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	devices, err := s.pushDevices.DevicesForUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get devices"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"devices": devices})
+}
+
+// deletePushDevice unregisters one of the user's devices, as on sign-out
+func (s *NotificationService) deletePushDevice(c *gin.Context) {
+	userID, err := getUserUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	deviceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device ID"})
+		return
+	}
+	deleted, err := s.pushDevices.DeleteUserDevice(c.Request.Context(), userID, deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete device"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "device removed"})
+}
+
+// toggleableChannels are the channels a user can switch on and off
+var toggleableChannels = map[models.DeliveryChannel]bool{
+	models.ChannelEmail:   true,
+	models.ChannelInApp:   true,
+	models.ChannelPush:    true,
+	models.ChannelWebPush: true,
+}
+
+// setChannelEnabled switches one delivery channel on or off without
+// resending the rest of the preferences:
+// PUT /api/v1/preferences/channels/:channel {"enabled": true}
+func (s *NotificationService) setChannelEnabled(c *gin.Context) {
+	userID, err := getUserUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	channel := models.DeliveryChannel(c.Param("channel"))
+	if !toggleableChannels[channel] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown channel"})
+		return
+	}
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	ctx := context.Background()
+	preferences, err := s.notificationSvc.GetUserPreferences(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get preferences"})
+		return
+	}
+	switch channel {
+	case models.ChannelEmail:
+		preferences.EmailEnabled = *req.Enabled
+	case models.ChannelInApp:
+		preferences.WebEnabled = *req.Enabled
+	case models.ChannelPush:
+		preferences.PushEnabled = *req.Enabled
+	case models.ChannelWebPush:
+		preferences.WebPushEnabled = *req.Enabled
+	}
+	now := time.Now()
+	if preferences.CreatedAt.IsZero() {
+		preferences.CreatedAt = now
+	}
+	preferences.UpdatedAt = now
+	if err := s.notificationSvc.SaveUserPreferences(ctx, preferences); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+// =============================================================================
+// REAL-TIME FAN-OUT
+// A user's sockets can be open on any replica, so events are published to a
+// Redis channel per user and every replica holding one of that user's
+// sockets subscribes to it. A replica subscribes when a user's first socket
+// connects and unsubscribes when their last one closes. Without Redis the
+// hub delivers in-process, which is enough for a single replica.
+// =============================================================================
+
+const (
+	// wsUserChannelPrefix + user ID is the channel carrying a user's events
+	wsUserChannelPrefix = "notifications:ws:user:"
+	// wsBroadcastChannel carries events for everyone connected
+	wsBroadcastChannel = "notifications:ws:broadcast"
+
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	// wsSendBuffer is how many events a socket can fall behind before it's
+	// dropped as too slow
+	wsSendBuffer = 32
+	// maxSocketsPerUser bounds tabs and devices per user on one replica
+	maxSocketsPerUser = 10
+)
+
+var wsConnections = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "nuclear_notification_websockets",
+	Help: "WebSockets open on this replica",
+})
+
+// wsConn is one open socket and the queue of events waiting to be written
+type wsConn struct {
+	userID string
+	conn   *websocket.Conn
+	send   chan []byte
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (c *wsConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// wsHub tracks the sockets open on this replica and moves events between
+// them and Redis
+type wsHub struct {
+	mu     sync.Mutex
+	conns  map[string]map[*wsConn]struct{} // userID -> sockets
+	redis  *redis.Client
+	pubsub *redis.PubSub
+}
+
+// newWSHub creates a hub; with a nil client events stay in this process
+func newWSHub(rdb *redis.Client) *wsHub {
+	h := &wsHub{conns: make(map[string]map[*wsConn]struct{}), redis: rdb}
+	if rdb != nil {
+		h.pubsub = rdb.Subscribe(context.Background(), wsBroadcastChannel)
+	}
+	return h
+}
+
+// connectRealtimeRedis connects to Redis for fan-out, returning nil (and
+// in-process delivery) when it can't be reached
+func connectRealtimeRedis() *redis.Client {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:         getEnv("REDIS_URL", "localhost:6379"),
+		Password:     getEnv("REDIS_PASSWORD", ""),
+		DB:           4, // Use DB 4 for notification service
+		PoolSize:     10,
+		MinIdleConns: 2,
+		MaxRetries:   3,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		log.Printf("Redis unavailable, WebSocket events will only reach this replica: %v", err)
+		rdb.Close()
+		return nil
+	}
+	return rdb
+}
+
+// run delivers events arriving from Redis to local sockets until ctx ends
+func (h *wsHub) run(ctx context.Context) {
+	if h.pubsub == nil {
+		return
+	}
+	defer h.pubsub.Close()
+
+	messages := h.pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			if msg.Channel == wsBroadcastChannel {
+				h.deliverAll([]byte(msg.Payload))
+			} else if len(msg.Channel) > len(wsUserChannelPrefix) {
+				h.deliverLocal(msg.Channel[len(wsUserChannelPrefix):], []byte(msg.Payload))
+			}
+		}
+	}
+}
+
+// register adds a socket, subscribing to the user's channel if it's their
+// first on this replica. It returns false when the user has too many open.
+func (h *wsHub) register(c *wsConn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sockets := h.conns[c.userID]
+	if len(sockets) >= maxSocketsPerUser {
+		return false
+	}
+	if sockets == nil {
+		sockets = make(map[*wsConn]struct{})
+		h.conns[c.userID] = sockets
+		// Subscribing under the lock keeps it ordered with the matching
+		// unsubscribe when sockets come and go quickly
+		if h.pubsub != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := h.pubsub.Subscribe(ctx, wsUserChannelPrefix+c.userID); err != nil {
+				log.Printf("Failed to subscribe to events for user %s: %v", c.userID, err)
+			}
+			cancel()
+		}
+	}
+	sockets[c] = struct{}{}
+	wsConnections.Inc()
+	return true
+}
+
+// unregister removes a socket, unsubscribing from the user's channel once
+// none of their sockets are left on this replica
+func (h *wsHub) unregister(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sockets, ok := h.conns[c.userID]
+	if !ok {
+		return
+	}
+	if _, ok := sockets[c]; !ok {
+		return
+	}
+	delete(sockets, c)
+	wsConnections.Dec()
+	if len(sockets) > 0 {
+		return
+	}
+	delete(h.conns, c.userID)
+	if h.pubsub != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := h.pubsub.Unsubscribe(ctx, wsUserChannelPrefix+c.userID); err != nil {
+			log.Printf("Failed to unsubscribe from events for user %s: %v", c.userID, err)
+		}
+		cancel()
+	}
+}
+
+// sendToUser sends an event to every socket the user has open, on any
+// replica
+func (h *wsHub) sendToUser(ctx context.Context, userID string, message WSMessage) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	if h.redis != nil {
+		err := h.redis.Publish(ctx, wsUserChannelPrefix+userID, payload).Err()
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to publish event for user %s, delivering locally: %v", userID, err)
+	}
+	h.deliverLocal(userID, payload)
+}
+
+// sendToAll sends an event to everyone connected, on any replica
+func (h *wsHub) sendToAll(ctx context.Context, message WSMessage) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	if h.redis != nil {
+		err := h.redis.Publish(ctx, wsBroadcastChannel, payload).Err()
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to publish broadcast, delivering locally: %v", err)
+	}
+	h.deliverAll(payload)
+}
+
+// deliverLocal queues an event on the user's sockets on this replica
+func (h *wsHub) deliverLocal(userID string, payload []byte) {
+	h.mu.Lock()
+	sockets := make([]*wsConn, 0, len(h.conns[userID]))
+	for c := range h.conns[userID] {
+		sockets = append(sockets, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range sockets {
+		h.enqueue(c, payload)
+	}
+}
+
+// deliverAll queues an event on every socket on this replica
+func (h *wsHub) deliverAll(payload []byte) {
+	h.mu.Lock()
+	var sockets []*wsConn
+	for _, userSockets := range h.conns {
+		for c := range userSockets {
+			sockets = append(sockets, c)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, c := range sockets {
+		h.enqueue(c, payload)
+	}
+}
+
+// enqueue hands an event to a socket's writer, dropping the socket if it
+// has fallen too far behind
+func (h *wsHub) enqueue(c *wsConn, payload []byte) {
+	select {
+	case <-c.done:
+	case c.send <- payload:
+	default:
+		log.Printf("Dropping slow WebSocket for user %s", c.userID)
+		c.close()
+	}
+}
+
+// writePump writes queued events and heartbeat pings until the socket
+// closes. It is the only writer on the connection.
+func (c *wsConn) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.close()
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case payload := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readPump reads until the socket closes, keeping it alive on pongs and
+// answering application-level pings from clients that can't see protocol
+// ones
+func (c *wsConn) readPump() {
+	defer c.close()
+
+	c.conn.SetReadLimit(4096)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var msg WSMessage
+		if json.Unmarshal(data, &msg) == nil && msg.Type == "ping" {
+			if pong, err := json.Marshal(WSMessage{Type: "pong", Payload: time.Now().Unix()}); err == nil {
+				select {
+				case c.send <- pong:
+				default:
+				}
+			}
+		}
+	}
+}
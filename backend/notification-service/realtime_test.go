@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSocket(userID string) *wsConn {
+	return &wsConn{userID: userID, send: make(chan []byte, wsSendBuffer), done: make(chan struct{})}
+}
+
+func TestWSHubDeliversToEachOfAUsersSockets(t *testing.T) {
+	hub := newWSHub(nil)
+	tab1, tab2, other := newTestSocket("alice"), newTestSocket("alice"), newTestSocket("bob")
+	for _, c := range []*wsConn{tab1, tab2, other} {
+		require.True(t, hub.register(c))
+	}
+
+	hub.sendToUser(context.Background(), "alice", WSMessage{Type: "unread_count", Payload: map[string]int{"count": 3}})
+
+	for _, c := range []*wsConn{tab1, tab2} {
+		require.Len(t, c.send, 1)
+		var msg WSMessage
+		require.NoError(t, json.Unmarshal(<-c.send, &msg))
+		assert.Equal(t, "unread_count", msg.Type)
+	}
+	assert.Empty(t, other.send)
+
+	hub.unregister(tab1)
+	hub.unregister(tab2)
+	hub.sendToUser(context.Background(), "alice", WSMessage{Type: "unread_count"})
+	assert.Empty(t, tab1.send)
+	assert.NotContains(t, hub.conns, "alice")
+
+	hub.sendToAll(context.Background(), WSMessage{Type: "announcement"})
+	assert.Len(t, other.send, 1)
+}
+
+func TestWSHubLimitsSocketsPerUser(t *testing.T) {
+	hub := newWSHub(nil)
+	for i := 0; i < maxSocketsPerUser; i++ {
+		require.True(t, hub.register(newTestSocket("alice")))
+	}
+	assert.False(t, hub.register(newTestSocket("alice")))
+	assert.True(t, hub.register(newTestSocket("bob")))
+}
@@ -4,193 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"nuclear-ao3/shared/messaging/deliverability"
 	"nuclear-ao3/shared/models"
 	"nuclear-ao3/shared/notifications"
 )
 
-// SubscriptionRepositoryImpl implements the SubscriptionRepository interface
-type SubscriptionRepositoryImpl struct {
-	db *sql.DB
-}
-
-func NewSubscriptionRepository(db *sql.DB) notifications.SubscriptionRepository {
-	return &SubscriptionRepositoryImpl{db: db}
-}
-
-func (r *SubscriptionRepositoryImpl) CreateSubscription(ctx context.Context, subscription *models.Subscription) error {
-	eventsJSON, _ := json.Marshal(subscription.Events)
-	filterRatingJSON, _ := json.Marshal(subscription.FilterRating)
-	filterTagsJSON, _ := json.Marshal(subscription.FilterTags)
-
-	query := `
-		INSERT INTO content_subscriptions 
-		(id, user_id, target_type, target_id, events, is_active, created_at, updated_at,
-		 filter_completed, filter_rating, filter_tags, min_word_count, max_word_count)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
-	`
-	_, err := r.db.ExecContext(ctx, query,
-		subscription.ID, subscription.UserID, subscription.Type, subscription.TargetID,
-		eventsJSON, subscription.IsActive, subscription.CreatedAt, subscription.UpdatedAt,
-		subscription.FilterCompleted, filterRatingJSON, filterTagsJSON,
-		subscription.MinWordCount, subscription.MaxWordCount,
-	)
-	return err
-}
-
-func (r *SubscriptionRepositoryImpl) GetSubscription(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
-	query := `
-		SELECT id, user_id, target_type, target_id, events, is_active, created_at, updated_at,
-		       filter_completed, filter_rating, filter_tags, min_word_count, max_word_count
-		FROM content_subscriptions WHERE id = $1
-	`
-	var subscription models.Subscription
-	var eventsJSON, filterRatingJSON, filterTagsJSON []byte
-
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&subscription.ID, &subscription.UserID, &subscription.Type, &subscription.TargetID,
-		&eventsJSON, &subscription.IsActive, &subscription.CreatedAt, &subscription.UpdatedAt,
-		&subscription.FilterCompleted, &filterRatingJSON, &filterTagsJSON,
-		&subscription.MinWordCount, &subscription.MaxWordCount,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	json.Unmarshal(eventsJSON, &subscription.Events)
-	json.Unmarshal(filterRatingJSON, &subscription.FilterRating)
-	json.Unmarshal(filterTagsJSON, &subscription.FilterTags)
-
-	return &subscription, nil
-}
-
-func (r *SubscriptionRepositoryImpl) UpdateSubscription(ctx context.Context, subscription *models.Subscription) error {
-	eventsJSON, _ := json.Marshal(subscription.Events)
-	filterRatingJSON, _ := json.Marshal(subscription.FilterRating)
-	filterTagsJSON, _ := json.Marshal(subscription.FilterTags)
-
-	query := `
-		UPDATE content_subscriptions 
-		SET events = $1, is_active = $2, updated_at = $3, filter_completed = $4,
-		    filter_rating = $5, filter_tags = $6, min_word_count = $7, max_word_count = $8
-		WHERE id = $9
-	`
-	_, err := r.db.ExecContext(ctx, query,
-		eventsJSON, subscription.IsActive, time.Now(), subscription.FilterCompleted,
-		filterRatingJSON, filterTagsJSON, subscription.MinWordCount, subscription.MaxWordCount,
-		subscription.ID,
-	)
-	return err
-}
-
-func (r *SubscriptionRepositoryImpl) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM content_subscriptions WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
-}
-
-func (r *SubscriptionRepositoryImpl) FindByUser(ctx context.Context, userID uuid.UUID) ([]*models.Subscription, error) {
-	query := `
-		SELECT id, user_id, target_type, target_id, events, is_active, created_at, updated_at,
-		       filter_completed, filter_rating, filter_tags, min_word_count, max_word_count
-		FROM content_subscriptions WHERE user_id = $1 ORDER BY created_at DESC
-	`
-	rows, err := r.db.QueryContext(ctx, query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var subscriptions []*models.Subscription
-	for rows.Next() {
-		var subscription models.Subscription
-		var eventsJSON, filterRatingJSON, filterTagsJSON []byte
-
-		err := rows.Scan(
-			&subscription.ID, &subscription.UserID, &subscription.Type, &subscription.TargetID,
-			&eventsJSON, &subscription.IsActive, &subscription.CreatedAt, &subscription.UpdatedAt,
-			&subscription.FilterCompleted, &filterRatingJSON, &filterTagsJSON,
-			&subscription.MinWordCount, &subscription.MaxWordCount,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		json.Unmarshal(eventsJSON, &subscription.Events)
-		json.Unmarshal(filterRatingJSON, &subscription.FilterRating)
-		json.Unmarshal(filterTagsJSON, &subscription.FilterTags)
-
-		subscriptions = append(subscriptions, &subscription)
-	}
-
-	return subscriptions, nil
-}
-
-func (r *SubscriptionRepositoryImpl) FindByTarget(ctx context.Context, targetType models.SubscriptionType, targetID uuid.UUID) ([]*models.Subscription, error) {
-	query := `
-		SELECT id, user_id, target_type, target_id, events, is_active, created_at, updated_at,
-		       filter_completed, filter_rating, filter_tags, min_word_count, max_word_count
-		FROM content_subscriptions WHERE target_type = $1 AND target_id = $2 AND is_active = true
-	`
-	rows, err := r.db.QueryContext(ctx, query, targetType, targetID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var subscriptions []*models.Subscription
-	for rows.Next() {
-		var subscription models.Subscription
-		var eventsJSON, filterRatingJSON, filterTagsJSON []byte
-
-		err := rows.Scan(
-			&subscription.ID, &subscription.UserID, &subscription.Type, &subscription.TargetID,
-			&eventsJSON, &subscription.IsActive, &subscription.CreatedAt, &subscription.UpdatedAt,
-			&subscription.FilterCompleted, &filterRatingJSON, &filterTagsJSON,
-			&subscription.MinWordCount, &subscription.MaxWordCount,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		json.Unmarshal(eventsJSON, &subscription.Events)
-		json.Unmarshal(filterRatingJSON, &subscription.FilterRating)
-		json.Unmarshal(filterTagsJSON, &subscription.FilterTags)
-
-		subscriptions = append(subscriptions, &subscription)
-	}
-
-	return subscriptions, nil
-}
-
-func (r *SubscriptionRepositoryImpl) FindByUserAndTarget(ctx context.Context, userID, targetID uuid.UUID, targetType models.SubscriptionType) (*models.Subscription, error) {
-	query := `
-		SELECT id, user_id, target_type, target_id, events, is_active, created_at, updated_at,
-		       filter_completed, filter_rating, filter_tags, min_word_count, max_word_count
-		FROM content_subscriptions WHERE user_id = $1 AND target_id = $2 AND target_type = $3
-	`
-	var subscription models.Subscription
-	var eventsJSON, filterRatingJSON, filterTagsJSON []byte
-
-	err := r.db.QueryRowContext(ctx, query, userID, targetID, targetType).Scan(
-		&subscription.ID, &subscription.UserID, &subscription.Type, &subscription.TargetID,
-		&eventsJSON, &subscription.IsActive, &subscription.CreatedAt, &subscription.UpdatedAt,
-		&subscription.FilterCompleted, &filterRatingJSON, &filterTagsJSON,
-		&subscription.MinWordCount, &subscription.MaxWordCount,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	json.Unmarshal(eventsJSON, &subscription.Events)
-	json.Unmarshal(filterRatingJSON, &subscription.FilterRating)
-	json.Unmarshal(filterTagsJSON, &subscription.FilterTags)
-
-	return &subscription, nil
-}
-
 // NotificationRepositoryImpl implements the NotificationRepository interface
 type NotificationRepositoryImpl struct {
 	db *sql.DB
@@ -206,15 +29,15 @@ func (r *NotificationRepositoryImpl) CreateNotification(ctx context.Context, not
 	query := `
 		INSERT INTO notification_items 
 		(id, user_id, event, priority, source_id, source_type, title, description, action_url,
-		 actor_id, actor_name, extra_data, is_read, is_delivered, created_at, read_at, delivered_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		 actor_id, actor_name, extra_data, is_read, is_delivered, created_at, read_at, delivered_at, digest_frequency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, NULLIF($18, ''))
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		notification.ID, notification.UserID, notification.Event, notification.Priority,
 		notification.SourceID, notification.SourceType, notification.Title, notification.Description,
 		notification.ActionURL, notification.ActorID, notification.ActorName, extraDataJSON,
 		notification.IsRead, notification.IsDelivered, notification.CreatedAt,
-		notification.ReadAt, notification.DeliveredAt,
+		notification.ReadAt, notification.DeliveredAt, string(notification.DigestFrequency),
 	)
 	return err
 }
@@ -266,13 +89,85 @@ func (r *NotificationRepositoryImpl) DeleteNotification(ctx context.Context, id
 }
 
 func (r *NotificationRepositoryImpl) GetUserNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.NotificationItem, error) {
-	query := `
-		SELECT id, user_id, event, priority, source_id, source_type, title, message, action_url,
-		       actor_id, actor_name, extra_data, is_read, is_delivered, created_at, read_at, delivered_at
-		FROM notification_items WHERE user_id = $1 
-		ORDER BY created_at DESC LIMIT $2 OFFSET $3
-	`
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	return r.ListNotifications(ctx, userID, models.NotificationInboxFilter{}, limit, offset)
+}
+
+// notificationColumns are the columns scanNotification reads, in order
+const notificationColumns = `id, user_id, event, priority, source_id, source_type, title, description, action_url,
+	actor_id, actor_name, extra_data, is_read, is_delivered, created_at, read_at, delivered_at, archived_at`
+
+// scanNotification scans a row of notificationColumns followed by extra
+func scanNotification(scan func(dest ...interface{}) error, extra ...interface{}) (*models.NotificationItem, error) {
+	var notification models.NotificationItem
+	var extraDataJSON []byte
+	dest := []interface{}{
+		&notification.ID, &notification.UserID, &notification.Event, &notification.Priority,
+		&notification.SourceID, &notification.SourceType, &notification.Title, &notification.Description,
+		&notification.ActionURL, &notification.ActorID, &notification.ActorName, &extraDataJSON,
+		&notification.IsRead, &notification.IsDelivered, &notification.CreatedAt,
+		&notification.ReadAt, &notification.DeliveredAt, &notification.ArchivedAt,
+	}
+	if err := scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	json.Unmarshal(extraDataJSON, &notification.ExtraData)
+	return &notification, nil
+}
+
+// inboxConditions builds the WHERE clause for an inbox filter, with the
+// user as $1
+func inboxConditions(userID uuid.UUID, filter models.NotificationInboxFilter) (string, []interface{}) {
+	conditions := "user_id = $1"
+	args := []interface{}{userID}
+	if filter.Archived {
+		conditions += " AND archived_at IS NOT NULL"
+	} else {
+		conditions += " AND archived_at IS NULL"
+	}
+	if filter.UnreadOnly {
+		conditions += " AND is_read = false"
+	}
+	if len(filter.Events) > 0 {
+		args = append(args, pq.Array(eventStrings(filter.Events)))
+		conditions += fmt.Sprintf(" AND event = ANY($%d)", len(args))
+	}
+	if filter.Category != "" {
+		categoryEvents, include := models.CategoryEvents(filter.Category)
+		args = append(args, pq.Array(eventStrings(categoryEvents)))
+		if include {
+			conditions += fmt.Sprintf(" AND event = ANY($%d)", len(args))
+		} else {
+			conditions += fmt.Sprintf(" AND event <> ALL($%d)", len(args))
+		}
+	}
+	if filter.Since != nil {
+		args = append(args, *filter.Since)
+		conditions += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if filter.Until != nil {
+		args = append(args, *filter.Until)
+		conditions += fmt.Sprintf(" AND created_at <= $%d", len(args))
+	}
+	return conditions, args
+}
+
+func eventStrings(events []models.NotificationEvent) []string {
+	strs := make([]string, len(events))
+	for i, event := range events {
+		strs[i] = string(event)
+	}
+	return strs
+}
+
+func (r *NotificationRepositoryImpl) ListNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationItem, error) {
+	conditions, args := inboxConditions(userID, filter)
+	args = append(args, limit, offset)
+	query := fmt.Sprintf(`
+		SELECT %s FROM notification_items
+		WHERE %s
+		ORDER BY created_at DESC LIMIT $%d OFFSET $%d`,
+		notificationColumns, conditions, len(args)-1, len(args))
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -280,27 +175,112 @@ func (r *NotificationRepositoryImpl) GetUserNotifications(ctx context.Context, u
 
 	var notifications []*models.NotificationItem
 	for rows.Next() {
-		var notification models.NotificationItem
-		var extraDataJSON []byte
-		var message string // Temporary variable for the message column
+		notification, err := scanNotification(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
 
-		err := rows.Scan(
-			&notification.ID, &notification.UserID, &notification.Event, &notification.Priority,
-			&notification.SourceID, &notification.SourceType, &notification.Title, &message,
-			&notification.ActionURL, &notification.ActorID, &notification.ActorName, &extraDataJSON,
-			&notification.IsRead, &notification.IsDelivered, &notification.CreatedAt,
-			&notification.ReadAt, &notification.DeliveredAt,
+// ListNotificationGroups groups in SQL so limit and offset count groups,
+// not notifications, and a work with thousands of kudos is one row.
+func (r *NotificationRepositoryImpl) ListNotificationGroups(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationGroup, error) {
+	conditions, args := inboxConditions(userID, filter)
+	args = append(args, pq.Array(eventStrings(models.GroupedNotificationEvents)))
+	groupedArg := len(args)
+	args = append(args, limit, offset)
+	query := fmt.Sprintf(`
+		WITH keyed AS (
+			SELECT *, CASE WHEN event = ANY($%d) AND source_id IS NOT NULL
+			               THEN event || ':' || source_id::text ELSE id::text END AS group_key
+			FROM notification_items
+			WHERE %s
+		), ranked AS (
+			SELECT *,
+			       ROW_NUMBER() OVER (PARTITION BY group_key ORDER BY created_at DESC) AS group_rank,
+			       COUNT(*) OVER (PARTITION BY group_key) AS group_count,
+			       COUNT(*) FILTER (WHERE is_read = false) OVER (PARTITION BY group_key) AS group_unread
+			FROM keyed
 		)
+		SELECT %s, group_count, group_unread FROM ranked
+		WHERE group_rank = 1
+		ORDER BY created_at DESC LIMIT $%d OFFSET $%d`,
+		groupedArg, conditions, notificationColumns, len(args)-1, len(args))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*models.NotificationGroup
+	for rows.Next() {
+		var count, unread int
+		latest, err := scanNotification(rows.Scan, &count, &unread)
 		if err != nil {
 			return nil, err
 		}
+		groups = append(groups, models.NewNotificationGroup(latest, count, unread))
+	}
+	return groups, rows.Err()
+}
 
-		notification.Description = message // Map message to Description
-		json.Unmarshal(extraDataJSON, &notification.ExtraData)
-		notifications = append(notifications, &notification)
+// readFilterConditions builds the WHERE clause for a read filter after
+// the conditions and args already given
+func readFilterConditions(conditions string, args []interface{}, filter models.NotificationReadFilter) (string, []interface{}) {
+	if len(filter.IDs) > 0 {
+		ids := make([]string, len(filter.IDs))
+		for i, id := range filter.IDs {
+			ids[i] = id.String()
+		}
+		args = append(args, pq.Array(ids))
+		conditions += fmt.Sprintf(" AND id = ANY($%d::uuid[])", len(args))
+	}
+	if filter.SourceID != nil {
+		args = append(args, *filter.SourceID)
+		conditions += fmt.Sprintf(" AND source_id = $%d", len(args))
 	}
+	if filter.SourceType != "" {
+		args = append(args, filter.SourceType)
+		conditions += fmt.Sprintf(" AND source_type = $%d", len(args))
+	}
+	if len(filter.Events) > 0 {
+		args = append(args, pq.Array(eventStrings(filter.Events)))
+		conditions += fmt.Sprintf(" AND event = ANY($%d)", len(args))
+	}
+	if filter.Category != "" {
+		categoryEvents, include := models.CategoryEvents(filter.Category)
+		args = append(args, pq.Array(eventStrings(categoryEvents)))
+		if include {
+			conditions += fmt.Sprintf(" AND event = ANY($%d)", len(args))
+		} else {
+			conditions += fmt.Sprintf(" AND event <> ALL($%d)", len(args))
+		}
+	}
+	if filter.Before != nil {
+		args = append(args, *filter.Before)
+		conditions += fmt.Sprintf(" AND created_at <= $%d", len(args))
+	}
+	return conditions, args
+}
 
-	return notifications, nil
+func (r *NotificationRepositoryImpl) SetNotificationsArchived(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter, archived bool) (int, error) {
+	var conditions, set string
+	if archived {
+		conditions = "user_id = $1 AND archived_at IS NULL"
+		set = "archived_at = NOW(), is_read = true, read_at = COALESCE(read_at, NOW())"
+	} else {
+		conditions = "user_id = $1 AND archived_at IS NOT NULL"
+		set = "archived_at = NULL"
+	}
+	conditions, args := readFilterConditions(conditions, []interface{}{userID}, filter)
+	result, err := r.db.ExecContext(ctx, "UPDATE notification_items SET "+set+" WHERE "+conditions, args...)
+	if err != nil {
+		return 0, err
+	}
+	changed, err := result.RowsAffected()
+	return int(changed), err
 }
 
 func (r *NotificationRepositoryImpl) GetUnreadCount(ctx context.Context, userID uuid.UUID) (int, error) {
@@ -310,16 +290,59 @@ func (r *NotificationRepositoryImpl) GetUnreadCount(ctx context.Context, userID
 	return count, err
 }
 
+func (r *NotificationRepositoryImpl) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter) (int, int, error) {
+	conditions, args := readFilterConditions("user_id = $1 AND is_read = false", []interface{}{userID}, filter)
+
+	// Both counts come from the same statement. The outer SELECT sees the
+	// table as it was before the UPDATE, so the rows just marked are
+	// subtracted from the old unread total.
+	query := `
+		WITH updated AS (
+			UPDATE notification_items SET is_read = true, read_at = NOW()
+			WHERE ` + conditions + `
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM updated),
+		       (SELECT COUNT(*) FROM notification_items WHERE user_id = $1 AND is_read = false)
+		       - (SELECT COUNT(*) FROM updated)`
+	var marked, unread int
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&marked, &unread)
+	return marked, unread, err
+}
+
+func (r *NotificationRepositoryImpl) GetUnreadCountsByEvent(ctx context.Context, userID uuid.UUID) (map[models.NotificationEvent]int, error) {
+	query := `
+		SELECT event, COUNT(*) FROM notification_items
+		WHERE user_id = $1 AND is_read = false
+		GROUP BY event`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[models.NotificationEvent]int)
+	for rows.Next() {
+		var event models.NotificationEvent
+		var count int
+		if err := rows.Scan(&event, &count); err != nil {
+			return nil, err
+		}
+		counts[event] = count
+	}
+	return counts, rows.Err()
+}
+
 func (r *NotificationRepositoryImpl) GetNotificationsForBatch(ctx context.Context, userID uuid.UUID, frequency models.NotificationFrequency) ([]*models.NotificationItem, error) {
-	// Get undelivered notifications for batching
+	// Get undelivered notifications waiting for this digest
 	query := `
 		SELECT id, user_id, event, priority, source_id, source_type, title, description, action_url,
 		       actor_id, actor_name, extra_data, is_read, is_delivered, created_at, read_at, delivered_at
 		FROM notification_items 
-		WHERE user_id = $1 AND is_delivered = false
+		WHERE user_id = $1 AND is_delivered = false AND digest_frequency = $2
 		ORDER BY created_at ASC
 	`
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID, string(frequency))
 	if err != nil {
 		return nil, err
 	}
@@ -342,12 +365,34 @@ func (r *NotificationRepositoryImpl) GetNotificationsForBatch(ctx context.Contex
 		}
 
 		json.Unmarshal(extraDataJSON, &notification.ExtraData)
+		notification.DigestFrequency = frequency
 		notifications = append(notifications, &notification)
 	}
 
 	return notifications, nil
 }
 
+func (r *NotificationRepositoryImpl) GetUsersAwaitingDigest(ctx context.Context, frequency models.NotificationFrequency) ([]uuid.UUID, error) {
+	query := `
+		SELECT DISTINCT user_id FROM notification_items
+		WHERE is_delivered = false AND digest_frequency = $1`
+	rows, err := r.db.QueryContext(ctx, query, string(frequency))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
 // DigestRepositoryImpl implements the DigestRepository interface
 type DigestRepositoryImpl struct {
 	db *sql.DB
@@ -362,13 +407,16 @@ func (r *DigestRepositoryImpl) CreateDigest(ctx context.Context, digest *models.
 
 	query := `
 		INSERT INTO notification_digests 
-		(id, user_id, digest_type, notifications, created_at, sent_at, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		(id, user_id, digest_type, notifications, created_at, sent_at, status, scheduled_for)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		digest.ID, digest.UserID, digest.DigestType, notificationsJSON,
-		digest.CreatedAt, digest.SentAt, digest.Status,
+		digest.CreatedAt, digest.SentAt, digest.Status, digest.ScheduledFor,
 	)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return notifications.ErrDigestExists
+	}
 	return err
 }
 
@@ -398,11 +446,11 @@ func (r *DigestRepositoryImpl) UpdateDigest(ctx context.Context, digest *models.
 
 	query := `
 		UPDATE notification_digests 
-		SET sent_at = $1, status = $2, notifications = $3
-		WHERE id = $4
+		SET sent_at = $1, status = $2, notifications = $3, error = NULLIF($4, '')
+		WHERE id = $5
 	`
 	_, err := r.db.ExecContext(ctx, query,
-		digest.SentAt, digest.Status, notificationsJSON, digest.ID,
+		digest.SentAt, digest.Status, notificationsJSON, digest.Error, digest.ID,
 	)
 	return err
 }
@@ -451,20 +499,23 @@ func NewPreferenceRepository(db *sql.DB) notifications.PreferenceRepository {
 
 func (r *PreferenceRepositoryImpl) GetPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error) {
 	query := `
-		SELECT user_id, email_enabled, web_enabled, push_enabled, quiet_hours_start, quiet_hours_end, timezone,
+		SELECT user_id, email_enabled, web_enabled, push_enabled, web_push_enabled, quiet_hours_start, quiet_hours_end, timezone,
 		       event_preferences, enable_batching, batch_frequency, max_notifications_per_hour, 
-		       min_time_between_similar, created_at, updated_at
+		       min_time_between_similar, digest_section_order, COALESCE(digest_section_limit, 0),
+		       paused_until, created_at, updated_at
 		FROM user_notification_preferences WHERE user_id = $1
 	`
 	var preferences models.NotificationPreferences
-	var eventPreferencesJSON []byte
+	var eventPreferencesJSON, digestSectionOrderJSON []byte
 	var minTimeBetweenSimilarNs int64
+	var pausedUntil sql.NullTime
 
 	err := r.db.QueryRowContext(ctx, query, userID).Scan(
 		&preferences.UserID, &preferences.EmailEnabled, &preferences.WebEnabled, &preferences.PushEnabled,
-		&preferences.QuietHoursStart, &preferences.QuietHoursEnd, &preferences.Timezone, &eventPreferencesJSON,
+		&preferences.WebPushEnabled, &preferences.QuietHoursStart, &preferences.QuietHoursEnd, &preferences.Timezone, &eventPreferencesJSON,
 		&preferences.EnableBatching, &preferences.BatchFrequency, &preferences.MaxNotificationsPerHour,
-		&minTimeBetweenSimilarNs, &preferences.CreatedAt, &preferences.UpdatedAt,
+		&minTimeBetweenSimilarNs, &digestSectionOrderJSON, &preferences.DigestSectionLimit,
+		&pausedUntil, &preferences.CreatedAt, &preferences.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -477,12 +528,20 @@ func (r *PreferenceRepositoryImpl) GetPreferences(ctx context.Context, userID uu
 
 	preferences.MinTimeBetweenSimilar = time.Duration(minTimeBetweenSimilarNs)
 	json.Unmarshal(eventPreferencesJSON, &preferences.EventPreferences)
+	if len(digestSectionOrderJSON) > 0 {
+		json.Unmarshal(digestSectionOrderJSON, &preferences.DigestSectionOrder)
+	}
+	// A pause that has run out is left in the row; don't report it
+	if pausedUntil.Valid && pausedUntil.Time.After(time.Now()) {
+		preferences.PausedUntil = &pausedUntil.Time
+	}
 
 	return &preferences, nil
 }
 
 func (r *PreferenceRepositoryImpl) UpdatePreferences(ctx context.Context, preferences *models.NotificationPreferences) error {
 	eventPreferencesJSON, _ := json.Marshal(preferences.EventPreferences)
+	digestSectionOrderJSON, _ := json.Marshal(preferences.DigestSectionOrder)
 	minTimeBetweenSimilarNs := int64(preferences.MinTimeBetweenSimilar)
 
 	query := `
@@ -490,32 +549,37 @@ func (r *PreferenceRepositoryImpl) UpdatePreferences(ctx context.Context, prefer
 		SET email_enabled = $1, web_enabled = $2, push_enabled = $3, quiet_hours_start = $4, 
 		    quiet_hours_end = $5, timezone = $6, event_preferences = $7, enable_batching = $8,
 		    batch_frequency = $9, max_notifications_per_hour = $10, min_time_between_similar = $11,
-		    updated_at = $12
-		WHERE user_id = $13
+		    digest_section_order = $12, digest_section_limit = $13, updated_at = $14, web_push_enabled = $16
+		WHERE user_id = $15
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		preferences.EmailEnabled, preferences.WebEnabled, preferences.PushEnabled,
 		preferences.QuietHoursStart, preferences.QuietHoursEnd, preferences.Timezone,
 		eventPreferencesJSON, preferences.EnableBatching, preferences.BatchFrequency,
-		preferences.MaxNotificationsPerHour, minTimeBetweenSimilarNs, time.Now(), preferences.UserID,
+		preferences.MaxNotificationsPerHour, minTimeBetweenSimilarNs,
+		digestSectionOrderJSON, preferences.DigestSectionLimit, time.Now(), preferences.UserID,
+		preferences.WebPushEnabled,
 	)
 	return err
 }
 
 func (r *PreferenceRepositoryImpl) CreatePreferences(ctx context.Context, preferences *models.NotificationPreferences) error {
 	eventPreferencesJSON, _ := json.Marshal(preferences.EventPreferences)
+	digestSectionOrderJSON, _ := json.Marshal(preferences.DigestSectionOrder)
 	minTimeBetweenSimilarNs := int64(preferences.MinTimeBetweenSimilar)
 
 	query := `
 		INSERT INTO user_notification_preferences 
 		(user_id, email_enabled, web_enabled, push_enabled, quiet_hours_start, quiet_hours_end, 
 		 timezone, event_preferences, enable_batching, batch_frequency, max_notifications_per_hour,
-		 min_time_between_similar, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		 min_time_between_similar, digest_section_order, digest_section_limit, created_at, updated_at,
+		 web_push_enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 		ON CONFLICT (user_id) DO UPDATE SET
 		email_enabled = EXCLUDED.email_enabled,
 		web_enabled = EXCLUDED.web_enabled,
 		push_enabled = EXCLUDED.push_enabled,
+		web_push_enabled = EXCLUDED.web_push_enabled,
 		quiet_hours_start = EXCLUDED.quiet_hours_start,
 		quiet_hours_end = EXCLUDED.quiet_hours_end,
 		timezone = EXCLUDED.timezone,
@@ -524,13 +588,541 @@ func (r *PreferenceRepositoryImpl) CreatePreferences(ctx context.Context, prefer
 		batch_frequency = EXCLUDED.batch_frequency,
 		max_notifications_per_hour = EXCLUDED.max_notifications_per_hour,
 		min_time_between_similar = EXCLUDED.min_time_between_similar,
+		digest_section_order = EXCLUDED.digest_section_order,
+		digest_section_limit = EXCLUDED.digest_section_limit,
 		updated_at = EXCLUDED.updated_at
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		preferences.UserID, preferences.EmailEnabled, preferences.WebEnabled, preferences.PushEnabled,
 		preferences.QuietHoursStart, preferences.QuietHoursEnd, preferences.Timezone, eventPreferencesJSON,
 		preferences.EnableBatching, preferences.BatchFrequency, preferences.MaxNotificationsPerHour,
-		minTimeBetweenSimilarNs, preferences.CreatedAt, preferences.UpdatedAt,
+		minTimeBetweenSimilarNs, digestSectionOrderJSON, preferences.DigestSectionLimit,
+		preferences.CreatedAt, preferences.UpdatedAt, preferences.WebPushEnabled,
 	)
 	return err
 }
+
+// SetPausedUntil sets or (with nil) clears a user's pause, creating default
+// preferences for users who never saved any
+func (r *PreferenceRepositoryImpl) SetPausedUntil(ctx context.Context, userID uuid.UUID, until *time.Time) error {
+	query := `UPDATE user_notification_preferences SET paused_until = $1, updated_at = $2 WHERE user_id = $3`
+	result, err := r.db.ExecContext(ctx, query, until, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	defaults := models.DefaultNotificationPreferences(userID)
+	defaults.CreatedAt = time.Now()
+	defaults.UpdatedAt = defaults.CreatedAt
+	if err := r.CreatePreferences(ctx, &defaults); err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, query, until, time.Now(), userID)
+	return err
+}
+
+// PushDeviceRepository stores the browsers and app installs registered for
+// push notifications. It is the push providers' device store.
+type PushDeviceRepository struct {
+	db *sql.DB
+}
+
+func NewPushDeviceRepository(db *sql.DB) *PushDeviceRepository {
+	return &PushDeviceRepository{db: db}
+}
+
+const pushDeviceColumns = "id, user_id, platform, token, p256dh, auth, name, created_at, last_used_at"
+
+func scanPushDevices(rows *sql.Rows) ([]*models.PushDevice, error) {
+	defer rows.Close()
+	devices := []*models.PushDevice{}
+	for rows.Next() {
+		var d models.PushDevice
+		if err := rows.Scan(&d.ID, &d.UserID, &d.Platform, &d.Token, &d.P256dh, &d.Auth, &d.Name,
+			&d.CreatedAt, &d.LastUsedAt); err != nil {
+			return nil, err
+		}
+		devices = append(devices, &d)
+	}
+	return devices, rows.Err()
+}
+
+// RegisterDevice saves a device, or refreshes it when its token is already
+// registered, moving it to this user
+func (r *PushDeviceRepository) RegisterDevice(ctx context.Context, device *models.PushDevice) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO push_devices (id, user_id, platform, token, p256dh, auth, name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (platform, token) DO UPDATE SET
+			user_id = EXCLUDED.user_id, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth, name = EXCLUDED.name
+		RETURNING id, created_at, last_used_at`,
+		uuid.New(), device.UserID, device.Platform, device.Token, device.P256dh, device.Auth, device.Name,
+	).Scan(&device.ID, &device.CreatedAt, &device.LastUsedAt)
+}
+
+// DevicesForUser returns a user's devices, on the given platforms if any
+func (r *PushDeviceRepository) DevicesForUser(ctx context.Context, userID uuid.UUID, platforms ...models.PushPlatform) ([]*models.PushDevice, error) {
+	names := make([]string, len(platforms))
+	for i, p := range platforms {
+		names[i] = string(p)
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+pushDeviceColumns+` FROM push_devices
+		WHERE user_id = $1 AND (cardinality($2::text[]) = 0 OR platform = ANY($2::text[]))
+		ORDER BY created_at`, userID, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	return scanPushDevices(rows)
+}
+
+// DeleteUserDevice removes one of a user's devices, reporting whether it
+// was theirs
+func (r *PushDeviceRepository) DeleteUserDevice(ctx context.Context, userID, deviceID uuid.UUID) (bool, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM push_devices WHERE id = $1 AND user_id = $2", deviceID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// RemoveDevice drops a device the push service no longer knows
+func (r *PushDeviceRepository) RemoveDevice(ctx context.Context, deviceID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM push_devices WHERE id = $1", deviceID)
+	return err
+}
+
+// MarkDeviceUsed records a successful delivery to a device
+func (r *PushDeviceRepository) MarkDeviceUsed(ctx context.Context, deviceID uuid.UUID, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE push_devices SET last_used_at = $2 WHERE id = $1", deviceID, at)
+	return err
+}
+
+// EmailDeliverabilityRepository keeps the email suppression list, bounces
+// and delivery statuses. It is the deliverability service's store, and
+// switches email off in the preferences of accounts whose address is
+// suppressed.
+type EmailDeliverabilityRepository struct {
+	db *sql.DB
+}
+
+func NewEmailDeliverabilityRepository(db *sql.DB) *EmailDeliverabilityRepository {
+	return &EmailDeliverabilityRepository{db: db}
+}
+
+func (r *EmailDeliverabilityRepository) GetSuppression(ctx context.Context, address string) (*deliverability.Suppression, error) {
+	var s deliverability.Suppression
+	err := r.db.QueryRowContext(ctx, `
+		SELECT address, reason, detail, provider, created_at FROM email_suppressions WHERE address = $1`,
+		address).Scan(&s.Address, &s.Reason, &s.Detail, &s.Provider, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SuppressionForUser returns the suppression on a user's account address,
+// or nil
+func (r *EmailDeliverabilityRepository) SuppressionForUser(ctx context.Context, userID uuid.UUID) (*deliverability.Suppression, error) {
+	var s deliverability.Suppression
+	err := r.db.QueryRowContext(ctx, `
+		SELECT s.address, s.reason, s.detail, s.provider, s.created_at
+		FROM email_suppressions s JOIN users u ON s.address = lower(u.email::text)
+		WHERE u.id = $1`, userID).Scan(&s.Address, &s.Reason, &s.Detail, &s.Provider, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *EmailDeliverabilityRepository) Suppress(ctx context.Context, s *deliverability.Suppression) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO email_suppressions (address, reason, detail, provider, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (address) DO UPDATE SET
+			reason = EXCLUDED.reason, detail = EXCLUDED.detail, provider = EXCLUDED.provider, created_at = EXCLUDED.created_at`,
+		s.Address, s.Reason, s.Detail, s.Provider, s.CreatedAt)
+	return err
+}
+
+func (r *EmailDeliverabilityRepository) Unsuppress(ctx context.Context, address string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM email_suppressions WHERE address = $1", address)
+	return err
+}
+
+func (r *EmailDeliverabilityRepository) RecordBounce(ctx context.Context, b *deliverability.Bounce) error {
+	var deliveryID *uuid.UUID
+	if b.DeliveryID != uuid.Nil {
+		deliveryID = &b.DeliveryID
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO email_bounces (id, address, bounce_type, error_type, diagnostic, provider, delivery_id, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		uuid.New(), b.Address, b.Type, b.ErrorType, b.Diagnostic, b.Provider, deliveryID, b.OccurredAt)
+	return err
+}
+
+func (r *EmailDeliverabilityRepository) CountBounces(ctx context.Context, address string, bounceType deliverability.BounceType, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM email_bounces WHERE address = $1 AND bounce_type = $2 AND occurred_at >= $3`,
+		address, bounceType, since).Scan(&count)
+	return count, err
+}
+
+func (r *EmailDeliverabilityRepository) SaveDelivery(ctx context.Context, d *deliverability.Delivery) error {
+	errorJSON, err := deliveryErrorJSON(d.Error)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO email_deliveries (id, message_id, user_id, address, status, error, attempted_at, updated_at, delivered_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status, error = EXCLUDED.error, updated_at = EXCLUDED.updated_at, delivered_at = EXCLUDED.delivered_at`,
+		d.ID, d.MessageID, d.UserID, d.Address, d.Status, errorJSON, d.AttemptedAt, d.UpdatedAt, d.DeliveredAt)
+	return err
+}
+
+func (r *EmailDeliverabilityRepository) UpdateDeliveryStatus(ctx context.Context, id uuid.UUID, status models.DeliveryStatus, deliveryErr *models.DeliveryError, at time.Time) error {
+	errorJSON, err := deliveryErrorJSON(deliveryErr)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE email_deliveries SET
+			status = $2, error = COALESCE($3, error), updated_at = $4,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN $4 ELSE delivered_at END
+		WHERE id = $1`, id, status, errorJSON, at)
+	return err
+}
+
+func (r *EmailDeliverabilityRepository) ListDeliveries(ctx context.Context, messageID uuid.UUID) ([]*deliverability.Delivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, message_id, user_id, address, status, error, attempted_at, updated_at, delivered_at
+		FROM email_deliveries WHERE message_id = $1 ORDER BY attempted_at`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*deliverability.Delivery{}
+	for rows.Next() {
+		var d deliverability.Delivery
+		var errorJSON []byte
+		if err := rows.Scan(&d.ID, &d.MessageID, &d.UserID, &d.Address, &d.Status, &errorJSON,
+			&d.AttemptedAt, &d.UpdatedAt, &d.DeliveredAt); err != nil {
+			return nil, err
+		}
+		if len(errorJSON) > 0 {
+			json.Unmarshal(errorJSON, &d.Error)
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
+
+// DisableEmail switches email off for the accounts registered with an
+// address. A user turns it back on in their preferences once the address
+// is off the suppression list.
+func (r *EmailDeliverabilityRepository) DisableEmail(ctx context.Context, address string, reason deliverability.SuppressionReason) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE user_notification_preferences SET email_enabled = false, updated_at = NOW()
+		WHERE user_id IN (SELECT id FROM users WHERE lower(email::text) = $1)`, address)
+	return err
+}
+
+func deliveryErrorJSON(deliveryErr *models.DeliveryError) ([]byte, error) {
+	if deliveryErr == nil {
+		return nil, nil
+	}
+	return json.Marshal(deliveryErr)
+}
+
+// EventReceiptRepository records the idempotency keys of stream events
+// that have been processed
+type EventReceiptRepository struct {
+	db *sql.DB
+}
+
+func NewEventReceiptRepository(db *sql.DB) *EventReceiptRepository {
+	return &EventReceiptRepository{db: db}
+}
+
+// Processed reports whether the event with this key has been processed
+func (r *EventReceiptRepository) Processed(ctx context.Context, key uuid.UUID) (bool, error) {
+	var processed bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM notification_event_receipts WHERE idempotency_key = $1)", key).Scan(&processed)
+	return processed, err
+}
+
+// Record marks the event with this key processed
+func (r *EventReceiptRepository) Record(ctx context.Context, key uuid.UUID, eventType models.NotificationEvent) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO notification_event_receipts (idempotency_key, event_type) VALUES ($1, $2)
+		ON CONFLICT (idempotency_key) DO NOTHING`, key, string(eventType))
+	return err
+}
+
+// PurgeBefore forgets receipts older than before, returning how many
+func (r *EventReceiptRepository) PurgeBefore(ctx context.Context, before time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM notification_event_receipts WHERE processed_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// UserBlockRepository reads the user_blocks table work-service maintains
+type UserBlockRepository struct {
+	db *sql.DB
+}
+
+func NewUserBlockRepository(db *sql.DB) *UserBlockRepository {
+	return &UserBlockRepository{db: db}
+}
+
+// BlockersOf returns which of userIDs block actorID, whatever the kind of
+// block
+func (r *UserBlockRepository) BlockersOf(ctx context.Context, actorID uuid.UUID, userIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	ids := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		ids[i] = id.String()
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT blocker_id FROM user_blocks
+		WHERE blocked_id = $1 AND blocker_id = ANY($2::uuid[])`, actorID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blockers := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		blockers[id] = true
+	}
+	return blockers, rows.Err()
+}
+
+// BroadcastRepository stores admin broadcasts and delivers them in batches
+type BroadcastRepository struct {
+	db *sql.DB
+}
+
+func NewBroadcastRepository(db *sql.DB) *BroadcastRepository {
+	return &BroadcastRepository{db: db}
+}
+
+const broadcastColumns = `id, title, body, COALESCE(action_url, ''), send_email, segment, status, scheduled_at,
+	started_at, completed_at, created_by, created_at, recipient_count, email_count, email_failed_count`
+
+// IsAdmin reports whether the user holds an active admin role
+func (r *BroadcastRepository) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var isAdmin bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM user_roles WHERE user_id = $1 AND role = 'admin' AND revoked_at IS NULL)`,
+		userID).Scan(&isAdmin)
+	return isAdmin, err
+}
+
+// CountAudience counts the users a segment reaches
+func (r *BroadcastRepository) CountAudience(ctx context.Context, segment BroadcastSegment) (int, error) {
+	where, args := segment.where(nil)
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users u WHERE "+where, args...).Scan(&count)
+	return count, err
+}
+
+func (r *BroadcastRepository) Create(ctx context.Context, b *Broadcast) error {
+	segmentJSON, err := json.Marshal(b.Segment)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO broadcasts (id, title, body, action_url, send_email, segment, status, scheduled_at, created_by, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10)`,
+		b.ID, b.Title, b.Body, b.ActionURL, b.SendEmail, segmentJSON, b.Status, b.ScheduledAt, b.CreatedBy, b.CreatedAt)
+	return err
+}
+
+// Get returns a broadcast with its read count, or sql.ErrNoRows
+func (r *BroadcastRepository) Get(ctx context.Context, id uuid.UUID) (*Broadcast, error) {
+	b, err := scanBroadcast(r.db.QueryRowContext(ctx, "SELECT "+broadcastColumns+" FROM broadcasts WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM notification_items WHERE source_type = $1 AND source_id = $2 AND is_read = TRUE`,
+		broadcastSourceType, id).Scan(&b.Metrics.Read)
+	return b, err
+}
+
+// List returns broadcasts newest first; an empty status lists them all
+func (r *BroadcastRepository) List(ctx context.Context, status string, limit, offset int) ([]*Broadcast, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+broadcastColumns+` FROM broadcasts
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC LIMIT $2 OFFSET $3`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	broadcasts := []*Broadcast{}
+	for rows.Next() {
+		b, err := scanBroadcast(rows)
+		if err != nil {
+			return nil, err
+		}
+		broadcasts = append(broadcasts, b)
+	}
+	return broadcasts, rows.Err()
+}
+
+// Cancel stops a broadcast that hasn't finished, reporting whether it did
+func (r *BroadcastRepository) Cancel(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE broadcasts SET status = 'cancelled', completed_at = NOW()
+		WHERE id = $1 AND status IN ('scheduled', 'sending')`, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// DeliverBatch creates in-app notifications for the next batchSize users of
+// the earliest due broadcast and advances its cursor, all in one
+// transaction. The broadcast row is locked with SKIP LOCKED, so replicas
+// deliver different broadcasts and never the same batch twice. It returns a
+// nil broadcast when none is due, and done once the audience is exhausted.
+func (r *BroadcastRepository) DeliverBatch(ctx context.Context, batchSize int) (*Broadcast, []broadcastRecipient, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer tx.Rollback()
+
+	var cursor uuid.NullUUID
+	var segmentJSON []byte
+	b := &Broadcast{}
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, title, body, COALESCE(action_url, ''), send_email, segment, last_user_id FROM broadcasts
+		WHERE status IN ('scheduled', 'sending') AND scheduled_at <= NOW()
+		ORDER BY scheduled_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`).Scan(&b.ID, &b.Title, &b.Body, &b.ActionURL, &b.SendEmail, &segmentJSON, &cursor)
+	if err == sql.ErrNoRows {
+		return nil, nil, false, nil
+	}
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if err := json.Unmarshal(segmentJSON, &b.Segment); err != nil {
+		return nil, nil, false, fmt.Errorf("broadcast %s has an invalid segment: %w", b.ID, err)
+	}
+
+	extraData, _ := json.Marshal(map[string]interface{}{"broadcast_id": b.ID})
+	args := []interface{}{
+		cursor.UUID, batchSize, string(models.EventSystemAlert), string(models.PriorityMedium),
+		b.ID, broadcastSourceType, b.Title, b.Body, b.ActionURL, string(extraData),
+	}
+	where, args := b.Segment.where(args)
+	rows, err := tx.QueryContext(ctx, `
+		WITH batch AS (
+			SELECT u.id, COALESCE(p.email_enabled, TRUE) AS email_enabled
+			FROM users u
+			LEFT JOIN user_notification_preferences p ON p.user_id = u.id
+			WHERE u.id > $1 AND `+where+`
+			ORDER BY u.id
+			LIMIT $2
+		), inserted AS (
+			INSERT INTO notification_items
+			(id, user_id, event, priority, source_id, source_type, title, description, action_url,
+			 actor_name, extra_data, is_read, is_delivered, created_at, delivered_at)
+			SELECT uuid_generate_v4(), batch.id, $3, $4, $5::uuid, $6, $7, $8, $9, '', $10::jsonb, FALSE, TRUE, NOW(), NOW()
+			FROM batch
+			RETURNING id, user_id
+		)
+		SELECT batch.id, inserted.id, batch.email_enabled
+		FROM batch JOIN inserted ON inserted.user_id = batch.id
+		ORDER BY batch.id`, args...)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	recipients := []broadcastRecipient{}
+	for rows.Next() {
+		var recipient broadcastRecipient
+		if err := rows.Scan(&recipient.UserID, &recipient.NotificationID, &recipient.EmailEnabled); err != nil {
+			rows.Close()
+			return nil, nil, false, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, false, err
+	}
+
+	done := len(recipients) < batchSize
+	var lastUserID interface{}
+	if len(recipients) > 0 {
+		lastUserID = recipients[len(recipients)-1].UserID
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE broadcasts SET
+			status = CASE WHEN $2 THEN 'sent' ELSE 'sending' END,
+			started_at = COALESCE(started_at, NOW()),
+			completed_at = CASE WHEN $2 THEN NOW() END,
+			last_user_id = COALESCE($3, last_user_id),
+			recipient_count = recipient_count + $4
+		WHERE id = $1`, b.ID, done, lastUserID, len(recipients))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, false, err
+	}
+	return b, recipients, done, nil
+}
+
+// RecordEmails adds a batch's email results to a broadcast's counts
+func (r *BroadcastRepository) RecordEmails(ctx context.Context, id uuid.UUID, emailed, failed int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE broadcasts SET email_count = email_count + $2, email_failed_count = email_failed_count + $3
+		WHERE id = $1`, id, emailed, failed)
+	return err
+}
+
+type broadcastScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBroadcast(row broadcastScanner) (*Broadcast, error) {
+	var b Broadcast
+	var segmentJSON []byte
+	err := row.Scan(&b.ID, &b.Title, &b.Body, &b.ActionURL, &b.SendEmail, &segmentJSON, &b.Status, &b.ScheduledAt,
+		&b.StartedAt, &b.CompletedAt, &b.CreatedBy, &b.CreatedAt,
+		&b.Metrics.Recipients, &b.Metrics.Emailed, &b.Metrics.EmailFailed)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(segmentJSON, &b.Segment)
+	return &b, nil
+}
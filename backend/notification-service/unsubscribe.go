@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"nuclear-ao3/shared/messaging"
+)
+
+// =============================================================================
+// UNSUBSCRIBE LINKS
+// Every email carries a signed unsubscribe link, in its footer and in the
+// List-Unsubscribe header. The link works without logging in: GET describes
+// what it turns off so the frontend can ask for confirmation, and POST (also
+// what mail clients send for one-click unsubscribe) turns it off. Links are
+// only added when UNSUBSCRIBE_SECRET is set.
+// =============================================================================
+
+// setupUnsubscribeTokens signs the links in outgoing email, or returns nil
+// when no secret is configured
+func setupUnsubscribeTokens(messagingService *messaging.UniversalMessageService) *messaging.UnsubscribeTokens {
+	secret := getEnv("UNSUBSCRIBE_SECRET", "")
+	if secret == "" {
+		log.Println("UNSUBSCRIBE_SECRET not set; emails will go out without unsubscribe links")
+		return nil
+	}
+	tokens := messaging.NewUnsubscribeTokens(secret, getEnv("UNSUBSCRIBE_URL", "http://localhost:8004/unsubscribe"))
+	messagingService.SetUnsubscribeTokens(tokens)
+	return tokens
+}
+
+// unsubscribeClaims verifies the token query parameter, writing the error
+// response itself when it is missing or invalid
+func (s *NotificationService) unsubscribeClaims(c *gin.Context) (*messaging.UnsubscribeClaims, bool) {
+	if s.unsubscribeTokens == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unsubscribe links are not configured"})
+		return nil, false
+	}
+	claims, err := s.unsubscribeTokens.Verify(c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return claims, true
+}
+
+// describeUnsubscribe says what an unsubscribe link turns off, without
+// changing anything; link scanners that prefetch email links use GET
+func (s *NotificationService) describeUnsubscribe(c *gin.Context) {
+	claims, ok := s.unsubscribeClaims(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"scope": claims.Scope, "event": claims.Event})
+}
+
+// unsubscribe turns off what the link covers in the user's preferences
+func (s *NotificationService) unsubscribe(c *gin.Context) {
+	claims, ok := s.unsubscribeClaims(c)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	preferences, err := s.notificationSvc.GetUserPreferences(ctx, claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get preferences"})
+		return
+	}
+	if claims.Apply(preferences) {
+		now := time.Now()
+		if preferences.CreatedAt.IsZero() {
+			preferences.CreatedAt = now
+		}
+		preferences.UpdatedAt = now
+		if err := s.notificationSvc.SaveUserPreferences(ctx, preferences); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update preferences"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "scope": claims.Scope, "event": claims.Event})
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// BOOKMARK INDEX
+// =============================================================================
+
+// bookmarksIndex holds one document per public bookmark so bookmark activity
+// can be aggregated by fandom and time ("most bookmarked this month") and
+// public bookmarks can be searched by their notes and tags.
+const bookmarksIndex = "bookmarks"
+
+// Bookmark event types sent by the work service
+const (
+	BookmarkEventCreated = "bookmark_created"
+	BookmarkEventUpdated = "bookmark_updated"
+	BookmarkEventDeleted = "bookmark_deleted"
+)
+
+// BookmarkEvent reports a bookmark change. WorkBookmarks is the work's
+// current public bookmark count, so replaying or reordering events can't
+// leave the index with a drifting count.
+type BookmarkEvent struct {
+	Type          string    `json:"type"`
+	BookmarkID    string    `json:"bookmark_id"`
+	WorkID        string    `json:"work_id"`
+	Fandoms       []string  `json:"fandoms"`
+	IsPrivate     bool      `json:"is_private"`
+	CreatedAt     time.Time `json:"created_at"`
+	WorkBookmarks int       `json:"work_bookmarks"`
+
+	// The bookmark itself, for bookmark search
+	UserID     string   `json:"user_id"`
+	Bookmarker string   `json:"bookmarker"`
+	Notes      string   `json:"notes"`
+	Tags       []string `json:"tags"`
+	IsRec      bool     `json:"is_rec"`
+	ListIDs    []string `json:"list_ids"` // Bookmark lists it is in
+}
+
+// BookmarkIndexDocument is a public bookmark as stored in the bookmarks index
+type BookmarkIndexDocument struct {
+	BookmarkID string    `json:"bookmark_id"`
+	WorkID     string    `json:"work_id"`
+	Fandoms    []string  `json:"fandoms"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	UserID     string   `json:"user_id,omitempty"`
+	Bookmarker string   `json:"bookmarker,omitempty"`
+	Notes      string   `json:"notes,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	IsRec      bool     `json:"is_rec"`
+	ListIDs    []string `json:"list_ids,omitempty"`
+}
+
+// MostBookmarkedWork is one entry of a most-bookmarked listing
+type MostBookmarkedWork struct {
+	WorkID            string                 `json:"work_id"`
+	BookmarksInPeriod int                    `json:"bookmarks_in_period"`
+	Work              map[string]interface{} `json:"work,omitempty"`
+}
+
+// ProcessBookmarkEvent keeps a work's bookmark count and the bookmarks index
+// in step with bookmark changes in the work service
+func (ss *SearchService) ProcessBookmarkEvent(c *gin.Context) {
+	var event BookmarkEvent
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark event", "details": err.Error()})
+		return
+	}
+
+	if event.BookmarkID == "" || event.WorkID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bookmark_id and work_id are required"})
+		return
+	}
+	switch event.Type {
+	case BookmarkEventCreated, BookmarkEventUpdated, BookmarkEventDeleted:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown bookmark event type"})
+		return
+	}
+
+	if err := ss.updateWorkBookmarkCount(event.WorkID, event.WorkBookmarks); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark count", "details": err.Error()})
+		return
+	}
+
+	// Private bookmarks are not counted and must not show up in listings
+	var err error
+	if event.Type == BookmarkEventDeleted || event.IsPrivate {
+		err = ss.deleteBookmarkFromIndex(event.BookmarkID)
+	} else {
+		err = ss.indexBookmark(BookmarkIndexDocument{
+			BookmarkID: event.BookmarkID,
+			WorkID:     event.WorkID,
+			Fandoms:    event.Fandoms,
+			CreatedAt:  event.CreatedAt,
+			UserID:     event.UserID,
+			Bookmarker: event.Bookmarker,
+			Notes:      event.Notes,
+			Tags:       event.Tags,
+			IsRec:      event.IsRec,
+			ListIDs:    event.ListIDs,
+		})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark index", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark event processed", "work_id": event.WorkID})
+}
+
+// MostBookmarkedWorks lists the works bookmarked most often within a period,
+// optionally limited to one fandom
+func (ss *SearchService) MostBookmarkedWorks(c *gin.Context) {
+	period := c.DefaultQuery("period", "month")
+	since, ok := bookmarkPeriodStart(period, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be one of day, week, month, year"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	fandom := c.Query("fandom")
+
+	start := time.Now()
+	counts, err := ss.aggregateBookmarkCounts(buildMostBookmarkedQuery(fandom, since, limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed", "details": err.Error()})
+		return
+	}
+
+	works, err := ss.fetchWorkSources(counts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load works", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":        works,
+		"fandom":         fandom,
+		"period":         period,
+		"since":          since,
+		"search_time_ms": time.Since(start).Milliseconds(),
+	})
+}
+
+// bookmarkPeriodStart returns the start of a named period ending at now
+func bookmarkPeriodStart(period string, now time.Time) (time.Time, bool) {
+	switch period {
+	case "day":
+		return now.AddDate(0, 0, -1), true
+	case "week":
+		return now.AddDate(0, 0, -7), true
+	case "month":
+		return now.AddDate(0, -1, 0), true
+	case "year":
+		return now.AddDate(-1, 0, 0), true
+	}
+	return time.Time{}, false
+}
+
+// buildMostBookmarkedQuery aggregates bookmarks made since the given time by
+// work, most bookmarked first
+func buildMostBookmarkedQuery(fandom string, since time.Time, size int) map[string]interface{} {
+	filter := []map[string]interface{}{
+		{"range": map[string]interface{}{
+			"created_at": map[string]interface{}{"gte": since.Format(time.RFC3339)},
+		}},
+	}
+	if fandom != "" {
+		filter = append(filter, map[string]interface{}{
+			"term": map[string]interface{}{"fandoms.keyword": fandom},
+		})
+	}
+
+	return map[string]interface{}{
+		"size":  0,
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filter}},
+		"aggs": map[string]interface{}{
+			"works": map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": "work_id.keyword",
+					"size":  size,
+					"order": map[string]interface{}{"_count": "desc"},
+				},
+			},
+		},
+	}
+}
+
+// aggregateBookmarkCounts runs a most-bookmarked query against the bookmarks
+// index and returns the buckets in order
+func (ss *SearchService) aggregateBookmarkCounts(query map[string]interface{}) ([]MostBookmarkedWork, error) {
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := ss.es.Search(
+		ss.es.Search.WithContext(ctx),
+		ss.es.Search.WithIndex(bookmarksIndex),
+		ss.es.Search.WithBody(bytes.NewReader(queryJSON)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("search returned error: %s", res.String())
+	}
+
+	var esResponse struct {
+		Aggregations struct {
+			Works struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int    `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"works"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&esResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	counts := make([]MostBookmarkedWork, 0, len(esResponse.Aggregations.Works.Buckets))
+	for _, bucket := range esResponse.Aggregations.Works.Buckets {
+		counts = append(counts, MostBookmarkedWork{WorkID: bucket.Key, BookmarksInPeriod: bucket.DocCount})
+	}
+	return counts, nil
+}
+
+// fetchWorkSources fills in the work documents for a most-bookmarked list,
+// dropping works that are no longer in the works index
+func (ss *SearchService) fetchWorkSources(counts []MostBookmarkedWork) ([]MostBookmarkedWork, error) {
+	if len(counts) == 0 {
+		return counts, nil
+	}
+
+	ids := make([]string, len(counts))
+	for i, entry := range counts {
+		ids[i] = entry.WorkID
+	}
+	sources, err := ss.workSourcesByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]MostBookmarkedWork, 0, len(counts))
+	for _, entry := range counts {
+		if source, ok := sources[entry.WorkID]; ok {
+			entry.Work = source
+			results = append(results, entry)
+		}
+	}
+	return results, nil
+}
+
+// workSourcesByID loads work documents from the works index by ID. Works
+// that aren't indexed (drafts, unrevealed, deleted) are missing from the map.
+func (ss *SearchService) workSourcesByID(ids []string) (map[string]map[string]interface{}, error) {
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"size":  len(ids),
+		"query": map[string]interface{}{"ids": map[string]interface{}{"values": ids}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := ss.es.Search(
+		ss.es.Search.WithContext(ctx),
+		ss.es.Search.WithIndex("works"),
+		ss.es.Search.WithBody(bytes.NewReader(queryJSON)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("search returned error: %s", res.String())
+	}
+
+	var esResponse struct {
+		Hits struct {
+			Hits []struct {
+				ID     string                 `json:"_id"`
+				Source map[string]interface{} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&esResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	sources := make(map[string]map[string]interface{}, len(esResponse.Hits.Hits))
+	for _, hit := range esResponse.Hits.Hits {
+		sources[hit.ID] = hit.Source
+	}
+	return sources, nil
+}
+
+// updateWorkBookmarkCount sets the bookmark count on an indexed work. Works
+// that aren't indexed (drafts, unrevealed) are skipped.
+func (ss *SearchService) updateWorkBookmarkCount(workID string, count int) error {
+	if count < 0 {
+		count = 0
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"doc": map[string]interface{}{"bookmarks": count},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal update: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := ss.es.Update(
+		"works",
+		workID,
+		bytes.NewReader(body),
+		ss.es.Update.WithContext(ctx),
+		ss.es.Update.WithRetryOnConflict(3),
+	)
+	if err != nil {
+		return fmt.Errorf("update request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != 404 {
+		return fmt.Errorf("update request returned error: %s", res.String())
+	}
+	return nil
+}
+
+// indexBookmark adds or replaces a public bookmark in the bookmarks index
+func (ss *SearchService) indexBookmark(doc BookmarkIndexDocument) error {
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now()
+	}
+	docJSON, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := ss.es.Index(
+		bookmarksIndex,
+		bytes.NewReader(docJSON),
+		ss.es.Index.WithContext(ctx),
+		ss.es.Index.WithDocumentID(doc.BookmarkID),
+	)
+	if err != nil {
+		return fmt.Errorf("index request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("index request returned error: %s", res.String())
+	}
+	return nil
+}
+
+// deleteBookmarkFromIndex removes a bookmark from the bookmarks index
+func (ss *SearchService) deleteBookmarkFromIndex(bookmarkID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := ss.es.Delete(
+		bookmarksIndex,
+		bookmarkID,
+		ss.es.Delete.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("delete request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != 404 {
+		return fmt.Errorf("delete request returned error: %s", res.String())
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBookmarkPeriodStart(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]time.Time{
+		"day":   time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC),
+		"week":  time.Date(2024, 3, 24, 12, 0, 0, 0, time.UTC),
+		"month": now.AddDate(0, -1, 0),
+		"year":  time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC),
+	}
+	for period, want := range cases {
+		got, ok := bookmarkPeriodStart(period, now)
+		if !ok || !got.Equal(want) {
+			t.Errorf("bookmarkPeriodStart(%q) = %v, %v; want %v", period, got, ok, want)
+		}
+	}
+
+	if _, ok := bookmarkPeriodStart("fortnight", now); ok {
+		t.Error("Expected unknown period to be rejected")
+	}
+}
+
+func TestBuildMostBookmarkedQuery(t *testing.T) {
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	query := buildMostBookmarkedQuery("Good Omens", since, 10)
+
+	filter := query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]map[string]interface{})
+	if len(filter) != 2 {
+		t.Fatalf("Expected date and fandom filters, got %d filters", len(filter))
+	}
+	dateRange := filter[0]["range"].(map[string]interface{})["created_at"].(map[string]interface{})
+	if dateRange["gte"] != "2024-03-01T00:00:00Z" {
+		t.Errorf("Expected range to start at period start, got %v", dateRange["gte"])
+	}
+	if filter[1]["term"].(map[string]interface{})["fandoms.keyword"] != "Good Omens" {
+		t.Errorf("Expected fandom term filter, got %v", filter[1])
+	}
+
+	terms := query["aggs"].(map[string]interface{})["works"].(map[string]interface{})["terms"].(map[string]interface{})
+	if terms["size"] != 10 {
+		t.Errorf("Expected aggregation size 10, got %v", terms["size"])
+	}
+	if query["size"] != 0 {
+		t.Error("Expected aggregation-only query to return no hits")
+	}
+
+	// Without a fandom only the date filter applies
+	query = buildMostBookmarkedQuery("", since, 10)
+	filter = query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]map[string]interface{})
+	if len(filter) != 1 {
+		t.Errorf("Expected only the date filter, got %d filters", len(filter))
+	}
+}
+
+func TestBuildSortClauseBookmarks(t *testing.T) {
+	ss := &SearchService{}
+
+	sort := ss.buildSortClause("bookmarks", "")
+	if len(sort) == 0 {
+		t.Fatal("Expected a sort clause for bookmarks")
+	}
+	order, ok := sort[0]["bookmarks"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected to sort on the indexed bookmarks field, got %v", sort[0])
+	}
+	if order["order"] != "desc" {
+		t.Errorf("Expected most bookmarked first by default, got %v", order["order"])
+	}
+}
+
+func TestBuildBookmarkSearchQuery(t *testing.T) {
+	query := buildBookmarkSearchQuery(BookmarkSearchRequest{
+		Query:   "slow burn",
+		Tags:    []string{"comfort read", "reread"},
+		Fandoms: []string{"Good Omens"},
+		Page:    3,
+		Limit:   10,
+	})
+
+	boolQuery := query["query"].(map[string]interface{})["bool"].(map[string]interface{})
+	must := boolQuery["must"].([]map[string]interface{})
+	if len(must) != 1 || must[0]["multi_match"].(map[string]interface{})["query"] != "slow burn" {
+		t.Errorf("Expected a text match on the query, got %v", must)
+	}
+	filter := boolQuery["filter"].([]map[string]interface{})
+	if len(filter) != 3 {
+		t.Fatalf("Expected a filter per bookmark tag and one for fandoms, got %v", filter)
+	}
+	if filter[1]["term"].(map[string]interface{})["tags.keyword"] != "reread" {
+		t.Errorf("Expected every bookmark tag to be required, got %v", filter[1])
+	}
+	if query["from"] != 20 || query["size"] != 10 {
+		t.Errorf("Expected page 3 of 10, got from %v size %v", query["from"], query["size"])
+	}
+	if sort := query["sort"].([]interface{}); sort[0] != "_score" {
+		t.Errorf("Expected relevance first with a query, got %v", sort)
+	}
+
+	// Without a query the newest bookmarks come first
+	query = buildBookmarkSearchQuery(BookmarkSearchRequest{WorkID: "w1", Page: 1, Limit: 20})
+	if sort := query["sort"].([]interface{}); len(sort) != 1 {
+		t.Errorf("Expected newest first without a query, got %v", sort)
+	}
+	if len(query["query"].(map[string]interface{})["bool"].(map[string]interface{})["must"].([]map[string]interface{})) != 0 {
+		t.Error("Expected no text match without a query")
+	}
+
+	// A list's recs
+	query = buildBookmarkSearchQuery(BookmarkSearchRequest{RecsOnly: true, ListID: "l1", Page: 1, Limit: 20})
+	filter = query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]map[string]interface{})
+	if len(filter) != 2 {
+		t.Fatalf("Expected a rec filter and a list filter, got %v", filter)
+	}
+	if filter[0]["term"].(map[string]interface{})["is_rec"] != true {
+		t.Errorf("Expected only recs, got %v", filter[0])
+	}
+	if filter[1]["term"].(map[string]interface{})["list_ids.keyword"] != "l1" {
+		t.Errorf("Expected only bookmarks in the list, got %v", filter[1])
+	}
+}
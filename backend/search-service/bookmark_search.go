@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// BOOKMARK SEARCH
+// Public bookmarks are searchable by their notes and bookmark tags. Private
+// bookmarks never reach the bookmarks index, and bookmarks of works that
+// aren't in the works index are dropped from results.
+// =============================================================================
+
+// BookmarkSearchRequest is a search over public bookmarks
+type BookmarkSearchRequest struct {
+	// Query matches words in the notes and bookmark tags
+	Query string
+	// Tags are bookmark tags every result must have
+	Tags       []string
+	Fandoms    []string
+	WorkID     string
+	Bookmarker string
+	// RecsOnly limits results to bookmarks flagged as recs
+	RecsOnly bool
+	// ListID limits results to one bookmark list
+	ListID string
+	Page   int
+	Limit  int
+}
+
+// BookmarkSearchResult is a public bookmark with the work it is of
+type BookmarkSearchResult struct {
+	BookmarkIndexDocument
+	Work map[string]interface{} `json:"work"`
+}
+
+// SearchBookmarks searches public bookmarks, best matches first when there
+// is a query and newest first otherwise
+func (ss *SearchService) SearchBookmarks(c *gin.Context) {
+	start := time.Now()
+
+	req := BookmarkSearchRequest{
+		Query:      c.Query("q"),
+		Tags:       c.QueryArray("tag"),
+		Fandoms:    c.QueryArray("fandom"),
+		WorkID:     c.Query("work_id"),
+		Bookmarker: c.Query("bookmarker"),
+		RecsOnly:   c.Query("rec") == "true",
+		ListID:     c.Query("list_id"),
+		Page:       1,
+		Limit:      20,
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		req.Page = page
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= 100 {
+		req.Limit = limit
+	}
+
+	bookmarks, total, err := ss.searchBookmarkIndex(buildBookmarkSearchQuery(req))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed", "details": err.Error()})
+		return
+	}
+
+	ids := make([]string, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		ids = append(ids, b.WorkID)
+	}
+	works := map[string]map[string]interface{}{}
+	if len(ids) > 0 {
+		if works, err = ss.workSourcesByID(ids); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load works", "details": err.Error()})
+			return
+		}
+	}
+
+	// Restricted works are only shown to signed-in readers
+	_, signedIn := requestUserID(c)
+	results := make([]BookmarkSearchResult, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		work, ok := works[b.WorkID]
+		if !ok {
+			continue
+		}
+		if restricted, _ := work["is_restricted"].(bool); restricted && !signedIn {
+			continue
+		}
+		results = append(results, BookmarkSearchResult{BookmarkIndexDocument: b, Work: work})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bookmarks":      results,
+		"total":          total,
+		"page":           req.Page,
+		"limit":          req.Limit,
+		"pages":          (total + req.Limit - 1) / req.Limit,
+		"search_time_ms": time.Since(start).Milliseconds(),
+	})
+}
+
+// buildBookmarkSearchQuery turns a bookmark search into a bookmarks index
+// query. Bookmark tags weigh more than notes when matching words.
+func buildBookmarkSearchQuery(req BookmarkSearchRequest) map[string]interface{} {
+	must := []map[string]interface{}{}
+	if req.Query != "" {
+		must = append(must, map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":    req.Query,
+				"fields":   []string{"notes", "tags^2"},
+				"operator": "and",
+			},
+		})
+	}
+
+	filter := []map[string]interface{}{}
+	for _, tag := range req.Tags {
+		filter = append(filter, map[string]interface{}{
+			"term": map[string]interface{}{"tags.keyword": tag},
+		})
+	}
+	if len(req.Fandoms) > 0 {
+		filter = append(filter, map[string]interface{}{
+			"terms": map[string]interface{}{"fandoms.keyword": req.Fandoms},
+		})
+	}
+	if req.WorkID != "" {
+		filter = append(filter, map[string]interface{}{
+			"term": map[string]interface{}{"work_id.keyword": req.WorkID},
+		})
+	}
+	if req.Bookmarker != "" {
+		filter = append(filter, map[string]interface{}{
+			"term": map[string]interface{}{"bookmarker.keyword": req.Bookmarker},
+		})
+	}
+	if req.RecsOnly {
+		filter = append(filter, map[string]interface{}{
+			"term": map[string]interface{}{"is_rec": true},
+		})
+	}
+	if req.ListID != "" {
+		filter = append(filter, map[string]interface{}{
+			"term": map[string]interface{}{"list_ids.keyword": req.ListID},
+		})
+	}
+
+	sort := []interface{}{map[string]interface{}{"created_at": map[string]interface{}{"order": "desc"}}}
+	if req.Query != "" {
+		sort = append([]interface{}{"_score"}, sort...)
+	}
+
+	return map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{"must": must, "filter": filter},
+		},
+		"sort": sort,
+		"from": (req.Page - 1) * req.Limit,
+		"size": req.Limit,
+	}
+}
+
+// searchBookmarkIndex runs a query against the bookmarks index and returns
+// the matching bookmarks in order with the total number of matches
+func (ss *SearchService) searchBookmarkIndex(query map[string]interface{}) ([]BookmarkIndexDocument, int, error) {
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := ss.es.Search(
+		ss.es.Search.WithContext(ctx),
+		ss.es.Search.WithIndex(bookmarksIndex),
+		ss.es.Search.WithBody(bytes.NewReader(queryJSON)),
+		ss.es.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, 0, fmt.Errorf("search returned error: %s", res.String())
+	}
+
+	var esResponse struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source BookmarkIndexDocument `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&esResponse); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	bookmarks := make([]BookmarkIndexDocument, 0, len(esResponse.Hits.Hits))
+	for _, hit := range esResponse.Hits.Hits {
+		bookmarks = append(bookmarks, hit.Source)
+	}
+	return bookmarks, esResponse.Hits.Total.Value, nil
+}
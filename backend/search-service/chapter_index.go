@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// =============================================================================
+// CHAPTER CONTENT INDEX
+// Posted chapter text is indexed one document per chapter, analysed for the
+// work's language. Only the analysed terms are kept: content is left out of
+// _source, so snippets for matches are cut from Postgres at query time.
+// =============================================================================
+
+// chaptersIndex holds one document per posted chapter
+const chaptersIndex = "chapters"
+
+const (
+	// contentSearchMaxChapters caps the chapter hits used to widen a search
+	contentSearchMaxChapters = 500
+	// contentMatchesPerWork caps the chapters highlighted for one work
+	contentMatchesPerWork = 3
+	// contentSnippetsPerChapter caps the snippets cut from one chapter
+	contentSnippetsPerChapter = 2
+	// contentSnippetRadius is roughly how many characters of context a
+	// snippet keeps on each side of a match
+	contentSnippetRadius = 80
+)
+
+// contentFieldForLanguage picks the analysed content field for a language
+// code such as "en" or "pt-BR". Languages without an analyzer of their own
+// go to the plain "content" field.
+func contentFieldForLanguage(language string) string {
+	if key := languageAnalysisKey(language); key != "" {
+		return "content_" + key
+	}
+	return "content"
+}
+
+// contentMaxBytes caps how much of one chapter is indexed
+// (SEARCH_CONTENT_MAX_BYTES, default 512KB); text past it isn't searchable
+func contentMaxBytes() int {
+	if n, err := strconv.Atoi(getEnv("SEARCH_CONTENT_MAX_BYTES", "")); err == nil && n > 0 {
+		return n
+	}
+	return 512 * 1024
+}
+
+var contentTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// plainContent strips chapter HTML down to text
+func plainContent(content string) string {
+	text := contentTagPattern.ReplaceAllString(content, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// truncateContent cuts text to at most max bytes without splitting a rune
+func truncateContent(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	for max > 0 && !utf8.RuneStart(text[max]) {
+		max--
+	}
+	return text[:max]
+}
+
+// ChapterIndexRequest is a posted chapter sent by the work service
+type ChapterIndexRequest struct {
+	WorkID   string `json:"work_id" binding:"required"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// chapterIndexDocument builds the stored document: ids for grouping and the
+// text under its language's field
+func chapterIndexDocument(chapterID string, req ChapterIndexRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"chapter_id":                          chapterID,
+		"work_id":                             req.WorkID,
+		"language":                            strings.ToLower(req.Language),
+		contentFieldForLanguage(req.Language): truncateContent(plainContent(req.Content), contentMaxBytes()),
+		"indexed_at":                          time.Now(),
+	}
+}
+
+// IndexChapter adds or replaces a posted chapter's text in the content index
+func (ss *SearchService) IndexChapter(c *gin.Context) {
+	chapterID := c.Param("id")
+	var req ChapterIndexRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chapter data", "details": err.Error()})
+		return
+	}
+
+	body, err := json.Marshal(chapterIndexDocument(chapterID, req))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode chapter"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	res, err := ss.es.Index(chaptersIndex, bytes.NewReader(body),
+		ss.es.Index.WithContext(ctx),
+		ss.es.Index.WithDocumentID(chapterID),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to index chapter", "details": err.Error()})
+		return
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to index chapter", "details": res.String()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Chapter indexed", "chapter_id": chapterID})
+}
+
+// DeleteChapterFromIndex drops a chapter's text, e.g. when it is deleted or
+// returned to draft
+func (ss *SearchService) DeleteChapterFromIndex(c *gin.Context) {
+	chapterID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	res, err := ss.es.Delete(chaptersIndex, chapterID, ss.es.Delete.WithContext(ctx))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chapter", "details": err.Error()})
+		return
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chapter", "details": res.String()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Chapter removed from index", "chapter_id": chapterID})
+}
+
+// deleteWorkChapters drops every chapter of a work from the content index
+func (ss *SearchService) deleteWorkChapters(workID string) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{"work_id": workID},
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	res, err := ss.es.DeleteByQuery([]string{chaptersIndex}, bytes.NewReader(body),
+		ss.es.DeleteByQuery.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("delete chapters request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("delete chapters returned error: %s", res.String())
+	}
+	return nil
+}
+
+// contentMatch is one chapter whose text matched a search
+type contentMatch struct {
+	ChapterID string
+	Score     float64
+}
+
+// ContentHighlight is returned with a search result whose chapter text
+// matched, one per matching chapter
+type ContentHighlight struct {
+	ChapterID     string   `json:"chapter_id"`
+	ChapterNumber int      `json:"chapter_number"`
+	Snippets      []string `json:"snippets"`
+}
+
+// searchChapterContent finds chapters matching query in every language's
+// field and groups them by work, best matches first
+func (ss *SearchService) searchChapterContent(ctx context.Context, query string) (map[string][]contentMatch, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"size":    contentSearchMaxChapters,
+		"_source": []string{"work_id", "chapter_id"},
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  query,
+				"fields": []string{"content", "content_*"},
+				"type":   "best_fields",
+			},
+		},
+	})
+
+	res, err := ss.es.Search(
+		ss.es.Search.WithContext(ctx),
+		ss.es.Search.WithIndex(chaptersIndex),
+		ss.es.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("content search failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("content search returned error: %s", res.String())
+	}
+
+	var parsed struct {
+		Hits struct {
+			Hits []struct {
+				Score  float64 `json:"_score"`
+				Source struct {
+					WorkID    string `json:"work_id"`
+					ChapterID string `json:"chapter_id"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse content search: %w", err)
+	}
+
+	matches := make(map[string][]contentMatch)
+	for _, hit := range parsed.Hits.Hits {
+		workID := hit.Source.WorkID
+		if workID == "" || len(matches[workID]) >= contentMatchesPerWork {
+			continue
+		}
+		matches[workID] = append(matches[workID], contentMatch{ChapterID: hit.Source.ChapterID, Score: hit.Score})
+	}
+	return matches, nil
+}
+
+// applyContentSearch looks up chapter matches for a search that asked to
+// include work text. A failure only narrows the search back to metadata.
+func (ss *SearchService) applyContentSearch(ctx context.Context, req *WorkSearchRequest) {
+	text := req.Query
+	if req.parsedQuery != nil {
+		text = req.parsedQuery.freeText()
+	}
+	if !req.SearchContent || strings.TrimSpace(text) == "" {
+		return
+	}
+	matches, err := ss.searchChapterContent(ctx, text)
+	if err != nil {
+		log.Printf("Content search failed, searching metadata only: %v", err)
+		return
+	}
+	req.contentMatches = matches
+}
+
+// attachContentHighlights adds _content_highlight to results matched by
+// their text, cutting snippets from the posted chapters in Postgres
+func (ss *SearchService) attachContentHighlights(ctx context.Context, response *SearchResponse, req WorkSearchRequest) {
+	if len(req.contentMatches) == 0 || response == nil {
+		return
+	}
+
+	chapterIDs := []string{}
+	for _, result := range response.Results {
+		for _, m := range req.contentMatches[resultWorkID(result)] {
+			chapterIDs = append(chapterIDs, m.ChapterID)
+		}
+	}
+	if len(chapterIDs) == 0 || ss.db == nil {
+		return
+	}
+
+	rows, err := ss.readDB().QueryContext(ctx, `
+		SELECT id, chapter_number, content FROM chapters
+		WHERE id = ANY($1::uuid[]) AND is_draft = false`, pq.Array(chapterIDs))
+	if err != nil {
+		log.Printf("Failed to load chapters for highlighting: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	highlights := make(map[string]ContentHighlight)
+	for rows.Next() {
+		var h ContentHighlight
+		var content string
+		if err := rows.Scan(&h.ChapterID, &h.ChapterNumber, &content); err != nil {
+			continue
+		}
+		h.Snippets = contentSnippets(plainContent(content), req.Query, contentSnippetsPerChapter)
+		if len(h.Snippets) > 0 {
+			highlights[h.ChapterID] = h
+		}
+	}
+
+	for _, result := range response.Results {
+		var workHighlights []ContentHighlight
+		for _, m := range req.contentMatches[resultWorkID(result)] {
+			if h, ok := highlights[m.ChapterID]; ok {
+				workHighlights = append(workHighlights, h)
+			}
+		}
+		if len(workHighlights) > 0 {
+			result["_content_highlight"] = workHighlights
+		}
+	}
+}
+
+// contentMatchedWorkIDs lists the works a content search matched
+func contentMatchedWorkIDs(matches map[string][]contentMatch) []string {
+	ids := make([]string, 0, len(matches))
+	for id := range matches {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// resultWorkID reads a work's id from a search hit, whichever field the
+// document was indexed with
+func resultWorkID(result map[string]interface{}) string {
+	if id, ok := result["work_id"].(string); ok && id != "" {
+		return id
+	}
+	id, _ := result["id"].(string)
+	return id
+}
+
+// contentSnippets cuts up to max snippets of text around words matching the
+// query, wrapping matches in <em> as Elasticsearch highlights do. Words are
+// matched on a shared prefix so simple inflections ("running" for "run")
+// still light up, roughly as the stemmed search matched them.
+func contentSnippets(text, query string, max int) []string {
+	stems := []string{}
+	for _, term := range strings.FieldsFunc(strings.ToLower(query), isNotWordRune) {
+		if utf8.RuneCountInString(term) < 2 {
+			continue
+		}
+		stems = append(stems, snippetStem(term))
+	}
+	if len(stems) == 0 || text == "" {
+		return nil
+	}
+
+	type span struct{ start, end int }
+	var matches []span
+	inWord, start := false, 0
+	for i, r := range text + " " {
+		if !isNotWordRune(r) {
+			if !inWord {
+				inWord, start = true, i
+			}
+			continue
+		}
+		if inWord {
+			inWord = false
+			word := strings.ToLower(text[start:i])
+			for _, stem := range stems {
+				if strings.HasPrefix(word, stem) {
+					matches = append(matches, span{start, i})
+					break
+				}
+			}
+		}
+	}
+
+	snippets := []string{}
+	for i := 0; i < len(matches) && len(snippets) < max; {
+		from := snippetBoundary(text, matches[i].start-contentSnippetRadius, false)
+		to := snippetBoundary(text, matches[i].end+contentSnippetRadius, true)
+
+		var b strings.Builder
+		if from > 0 {
+			b.WriteString("…")
+		}
+		pos := from
+		for i < len(matches) && matches[i].end <= to {
+			b.WriteString(html.EscapeString(text[pos:matches[i].start]))
+			b.WriteString("<em>" + html.EscapeString(text[matches[i].start:matches[i].end]) + "</em>")
+			pos = matches[i].end
+			i++
+		}
+		b.WriteString(html.EscapeString(text[pos:to]))
+		if to < len(text) {
+			b.WriteString("…")
+		}
+		snippets = append(snippets, b.String())
+	}
+	return snippets
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+}
+
+// snippetStem trims a query term to the prefix matched in the text
+func snippetStem(term string) string {
+	runes := []rune(term)
+	if len(runes) > 5 {
+		runes = runes[:len(runes)-2]
+	}
+	return string(runes)
+}
+
+// snippetBoundary moves pos to the nearest space (forwards or backwards) so
+// snippets don't start or end mid-word
+func snippetBoundary(text string, pos int, forward bool) int {
+	if pos <= 0 {
+		return 0
+	}
+	if pos >= len(text) {
+		return len(text)
+	}
+	if forward {
+		if i := strings.IndexByte(text[pos:], ' '); i >= 0 {
+			return pos + i
+		}
+		return len(text)
+	}
+	if i := strings.LastIndexByte(text[:pos], ' '); i >= 0 {
+		return i + 1
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContentFieldForLanguage(t *testing.T) {
+	cases := map[string]string{
+		"en":    "content_en",
+		"pt-BR": "content_pt",
+		"ZH":    "content_cjk",
+		"ja":    "content_cjk",
+		"tlh":   "content",
+		"":      "content",
+	}
+	for language, want := range cases {
+		if got := contentFieldForLanguage(language); got != want {
+			t.Errorf("contentFieldForLanguage(%q) = %q, want %q", language, got, want)
+		}
+	}
+}
+
+func TestChapterIndexDocumentStripsAndTruncates(t *testing.T) {
+	t.Setenv("SEARCH_CONTENT_MAX_BYTES", "12")
+
+	doc := chapterIndexDocument("ch1", ChapterIndexRequest{
+		WorkID:   "w1",
+		Language: "fr",
+		Content:  "<p>Café&nbsp;au   lait</p><p>et croissants</p>",
+	})
+
+	if doc["work_id"] != "w1" || doc["language"] != "fr" {
+		t.Errorf("unexpected ids: %v", doc)
+	}
+	if _, ok := doc["content"]; ok {
+		t.Error("French text should go to content_fr, not content")
+	}
+	if got := doc["content_fr"]; got != "Café au lai" {
+		t.Errorf("content_fr = %q, want tags stripped and cut at a rune boundary", got)
+	}
+}
+
+func TestContentSnippets(t *testing.T) {
+	text := "The dragon slept. " + strings.Repeat("filler words here ", 20) + "Then the dragons woke & <roared>."
+
+	snippets := contentSnippets(text, "Dragon", 5)
+	if len(snippets) != 2 {
+		t.Fatalf("got %d snippets, want 2: %v", len(snippets), snippets)
+	}
+	if !strings.HasPrefix(snippets[0], "The <em>dragon</em> slept.") || !strings.HasSuffix(snippets[0], "…") {
+		t.Errorf("first snippet = %q", snippets[0])
+	}
+	if !strings.Contains(snippets[1], "<em>dragons</em> woke &amp; &lt;roared&gt;.") || !strings.HasPrefix(snippets[1], "…") {
+		t.Errorf("second snippet = %q, want the inflected match highlighted and the rest escaped", snippets[1])
+	}
+
+	if got := contentSnippets(text, "dragon", 1); len(got) != 1 {
+		t.Errorf("expected snippets capped at 1, got %d", len(got))
+	}
+	if got := contentSnippets(text, "unicorn", 3); len(got) != 0 {
+		t.Errorf("expected no snippets without a match, got %v", got)
+	}
+}
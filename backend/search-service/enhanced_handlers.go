@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -63,6 +64,7 @@ type IndexingQueueStatus struct {
 	QueueBacklog       time.Duration              `json:"queue_backlog"`
 	WorkerStatuses     []IndexingWorkerStatus     `json:"worker_statuses"`
 	PerformanceMetrics IndexingPerformanceMetrics `json:"performance_metrics"`
+	Rebuild            *IndexRebuildStatus        `json:"rebuild,omitempty"`
 }
 
 // IndexingWorkerStatus represents the status of an individual indexing worker
@@ -117,6 +119,10 @@ type WorkIndexDocument struct {
 	IndexedAt        time.Time `json:"indexed_at"`
 	Version          int       `json:"version"`
 
+	// Title and summary again, under the analyzer for the work's language
+	TitleLang   map[string]string `json:"title_lang,omitempty"`
+	SummaryLang map[string]string `json:"summary_lang,omitempty"`
+
 	// Performance optimization fields
 	PopularityScore     float64        `json:"popularity_score"`
 	RecentActivityScore float64        `json:"recent_activity_score"`
@@ -1000,13 +1006,13 @@ func (ss *SearchService) getSearchFields(req EnhancedWorkSearchRequest) []string
 	}
 
 	// Default search fields with weights
-	return []string{
+	return localizedFields([]string{
 		"title^3",
 		"summary^2",
 		"content^1",
 		"tags^1.5",
 		"author^1.2",
-	}
+	})
 }
 
 func (ss *SearchService) buildAdvancedSortClause(req EnhancedWorkSearchRequest) []map[string]interface{} {
@@ -1068,8 +1074,8 @@ func (ss *SearchService) buildSourceFields(req EnhancedWorkSearchRequest) []stri
 	return []string{
 		"id", "title", "summary", "author", "author_id",
 		"fandoms", "characters", "relationships", "freeform_tags",
-		"rating", "category", "warnings", "language", "status",
-		"word_count", "chapter_count", "is_complete",
+		"rating", "category", "categories", "warnings", "language", "status",
+		"word_count", "chapter_count", "is_complete", "completion_status",
 		"published_at", "updated_at",
 		"hits", "kudos", "comments", "bookmarks",
 	}
@@ -1387,7 +1393,7 @@ func (ss *SearchService) executeAdvancedSearch(query map[string]interface{}, req
 			source["_highlight"] = highlight
 		}
 
-		results = append(results, source)
+		results = append(results, withReadingTime(withRequiredTags(source)))
 	}
 
 	// Extract facets
@@ -1662,6 +1668,9 @@ func (ss *SearchService) DeleteWorkFromIndex(c *gin.Context) {
 		})
 		return
 	}
+	if err := ss.deleteWorkChapters(workID); err != nil {
+		log.Printf("Failed to delete chapters of work %s from index: %v", workID, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Work deleted from index",
@@ -1669,23 +1678,18 @@ func (ss *SearchService) DeleteWorkFromIndex(c *gin.Context) {
 	})
 }
 
-// GetIndexingStatus returns the current status of the indexing queue
+// GetIndexingStatus returns the current status of the indexing queue and
+// the progress of the latest index rebuild
 func (ss *SearchService) GetIndexingStatus(c *gin.Context) {
 	status := ss.getIndexingQueueStatus()
+	rebuild, err := ss.loadRebuildStatus(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to load index rebuild status: %v", err)
+	}
+	status.Rebuild = rebuild
 	c.JSON(http.StatusOK, status)
 }
 
-// EnhancedRebuildIndex triggers a complete index rebuild (admin operation)
-func (ss *SearchService) EnhancedRebuildIndex(c *gin.Context) {
-	// This should be protected with proper authentication in production
-	go ss.rebuildSearchIndex()
-
-	c.JSON(http.StatusAccepted, gin.H{
-		"message": "Enhanced index rebuild started",
-		"status":  "processing",
-	})
-}
-
 // =============================================================================
 // INDEXING PIPELINE CORE IMPLEMENTATION
 // =============================================================================
@@ -1854,6 +1858,9 @@ func (ss *SearchService) enhanceWorkDocument(doc *WorkIndexDocument) {
 	// Calculate recent activity score
 	doc.RecentActivityScore = ss.calculateRecentActivityScore(doc)
 
+	// Analyse title and summary for the work's language
+	localizeWorkText(doc)
+
 	// Create combined searchable text
 	doc.SearchableText = ss.createSearchableText(doc)
 
@@ -1989,20 +1996,6 @@ func (ss *SearchService) getIndexingQueueStatus() IndexingQueueStatus {
 	}
 }
 
-// rebuildSearchIndex performs a complete rebuild of the search index
-func (ss *SearchService) rebuildSearchIndex() {
-	// In a real implementation, this would:
-	// 1. Create a new index with updated mappings
-	// 2. Reindex all works from the database
-	// 3. Switch to the new index atomically
-	// 4. Delete the old index
-
-	// For now, this is a placeholder that demonstrates the concept
-	fmt.Println("Index rebuild started...")
-	time.Sleep(5 * time.Second) // Simulate rebuild time
-	fmt.Println("Index rebuild completed")
-}
-
 // =============================================================================
 // ENHANCED FILTERING & FACETS IMPLEMENTATION - TASK 3
 // =============================================================================
@@ -2050,12 +2043,9 @@ func (ss *SearchService) SmartFilteredSearch(c *gin.Context) {
 	query := ss.buildWorkSearchQuery(workReq)
 
 	// Execute search using working basic infrastructure
-	response, err := ss.executeWorkSearch(query, workReq)
+	response, err := ss.executeWorkSearch(c.Request.Context(), query, workReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Smart filtered search failed",
-			"details": err.Error(),
-		})
+		respondSearchError(c, "Smart filtered search failed", err)
 		return
 	}
 
@@ -2154,7 +2144,7 @@ func (ss *SearchService) buildSmartFilterQuery(req AdvancedFilterRequest) map[st
 		boolQuery["must"] = append(boolQuery["must"].([]map[string]interface{}), map[string]interface{}{
 			"multi_match": map[string]interface{}{
 				"query":  req.Query,
-				"fields": []string{"title^3", "summary^2", "content_text"},
+				"fields": localizedFields([]string{"title^3", "summary^2", "content_text"}),
 				"type":   "best_fields",
 			},
 		})
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -37,6 +39,13 @@ type WorkSearchRequest struct {
 	Limit             int      `json:"limit,omitempty"`
 	RelationshipCount string   `json:"relationship_count,omitempty"` // '1-2', '3-5', '6-10', '10+'
 	TagProminence     string   `json:"tag_prominence,omitempty"`     // 'primary', 'secondary', 'any'
+	// Exclusion filters: works carrying any of these are left out
+	ExcludeFandoms       []string `json:"exclude_fandoms,omitempty"`
+	ExcludeCharacters    []string `json:"exclude_characters,omitempty"`
+	ExcludeRelationships []string `json:"exclude_relationships,omitempty"`
+	ExcludeTags          []string `json:"exclude_tags,omitempty"`
+	ExcludeWarnings      []string `json:"exclude_warnings,omitempty"`
+	ExcludeRatings       []string `json:"exclude_ratings,omitempty"`
 	// Content filtering
 	BlockedTags         []string `json:"blocked_tags,omitempty"`
 	HideIncomplete      bool     `json:"hide_incomplete,omitempty"`
@@ -51,6 +60,30 @@ type WorkSearchRequest struct {
 	MinComments  *int `json:"min_comments,omitempty"`
 	MinBookmarks *int `json:"min_bookmarks,omitempty"`
 	HideOrphaned bool `json:"hide_orphaned,omitempty"`
+	// SearchContent also matches the query against chapter text
+	SearchContent bool `json:"search_content,omitempty"`
+	// ExactTags turns off expanding tags to their synonyms and sub-tags
+	ExactTags bool `json:"exact_tags,omitempty"`
+	// PreferredLanguages rank works in these languages higher
+	PreferredLanguages []string `json:"preferred_languages,omitempty"`
+	// Ranking is "personalized" or "neutral"; empty follows the reader's
+	// preference
+	Ranking string `json:"ranking,omitempty"`
+	// Cursor pages the results by cursor instead of page number; an empty
+	// cursor asks for the first page
+	Cursor *string `json:"cursor,omitempty"`
+
+	// searchAfter are the sort values Cursor continues after
+	searchAfter []json.RawMessage
+	// contentMatches holds the chapters a content search matched, by work
+	contentMatches map[string][]contentMatch
+	// parsedQuery is q parsed as the search query language
+	parsedQuery *queryNode
+	// readingAffinities are the reader's most read tags, when ranking is
+	// personalized
+	readingAffinities readingAffinities
+	// mutedUserIDs are the users the reader muted, whose works are left out
+	mutedUserIDs []string
 }
 
 type SearchResponse struct {
@@ -61,6 +94,19 @@ type SearchResponse struct {
 	Pages      int                      `json:"pages"`
 	SearchTime int64                    `json:"search_time_ms"`
 	Facets     map[string]interface{}   `json:"facets,omitempty"`
+	// Parsed echoes how a work search query was understood
+	Parsed string `json:"parsed,omitempty"`
+	// Ranking is the ranking a work search used, personalized or neutral
+	Ranking string `json:"ranking,omitempty"`
+	// ResultsPartial, FacetsTruncated and HighlightsTruncated mark stages
+	// of the search that ran over their time budget
+	ResultsPartial      bool `json:"results_partial,omitempty"`
+	FacetsTruncated     bool `json:"facets_truncated,omitempty"`
+	HighlightsTruncated bool `json:"highlights_truncated,omitempty"`
+	// NextCursor continues a search paged by cursor; HasMore is false on
+	// its last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more,omitempty"`
 }
 
 // Work search handlers
@@ -90,6 +136,12 @@ func (ss *SearchService) SearchWorks(c *gin.Context) {
 	req.Category = c.QueryArray("category")
 	req.Warnings = c.QueryArray("warning")
 	req.Language = c.QueryArray("language")
+	req.ExcludeFandoms = c.QueryArray("exclude_fandom")
+	req.ExcludeCharacters = c.QueryArray("exclude_character")
+	req.ExcludeRelationships = c.QueryArray("exclude_relationship")
+	req.ExcludeTags = c.QueryArray("exclude_tag")
+	req.ExcludeWarnings = c.QueryArray("exclude_warning")
+	req.ExcludeRatings = c.QueryArray("exclude_rating")
 
 	// Parse integers
 	if page := c.Query("page"); page != "" {
@@ -153,23 +205,62 @@ func (ss *SearchService) SearchWorks(c *gin.Context) {
 		}
 	}
 	req.HideOrphaned = c.Query("hide_orphaned") == "true"
+	req.SearchContent = c.Query("search_content") == "true"
+	req.ExactTags = c.Query("exact_tags") == "true"
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		req.Cursor = &cursor
+	}
+	if err := applyCursor(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err := validateWorkDateRanges(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	preferred, err := normalizePreferredLanguages(c.QueryArray("preferred_language"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.PreferredLanguages = preferred
+	ss.applyPreferredLanguages(c, &req)
+	req.Ranking = c.Query("ranking")
+	if err := validateRanking(req.Ranking); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ss.applyPersonalizedRanking(c, &req)
+	if err := parseRequestQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, queryParseErrorResponse(req.Query, err))
+		return
+	}
+	ss.applyContentSearch(c.Request.Context(), &req)
+	ss.applyTagExpansion(c.Request.Context(), &req)
+	ss.applyMutedUsers(c, &req)
 
 	// Build Elasticsearch query
 	log.Printf("Building query for request: %+v", req)
 	esQuery := ss.buildWorkSearchQuery(req)
 
 	// Execute search
-	response, err := ss.executeWorkSearch(esQuery, req)
+	response, err := ss.executeWorkSearch(c.Request.Context(), esQuery, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Search failed",
-			"details": err.Error(),
-		})
+		respondSearchError(c, "Search failed", err)
 		return
 	}
 
+	response.HighlightsTruncated = ss.hydrateWithinBudget(c.Request.Context(), "works", func(ctx context.Context) {
+		ss.attachContentHighlights(ctx, response, req)
+	})
+	if req.parsedQuery != nil {
+		response.Parsed = req.parsedQuery.String()
+	}
+	response.Ranking = req.rankingMode()
+
 	// Record search analytics
 	go ss.recordSearch(c.Request.Context(), req.Query, "works", response.Total)
+	ss.recordSearchEvent(c, "works", req.Query, workSearchFilters(req), response.Total, 0)
 
 	response.SearchTime = time.Since(start).Milliseconds()
 	c.JSON(http.StatusOK, response)
@@ -203,22 +294,55 @@ func (ss *SearchService) AdvancedWorkSearch(c *gin.Context) {
 	if req.SortOrder == "" {
 		req.SortOrder = "desc"
 	}
+	if err := applyCursor(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err := validateWorkDateRanges(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	preferred, err := normalizePreferredLanguages(req.PreferredLanguages)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.PreferredLanguages = preferred
+	ss.applyPreferredLanguages(c, &req)
+	if err := validateRanking(req.Ranking); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ss.applyPersonalizedRanking(c, &req)
+	if err := parseRequestQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, queryParseErrorResponse(req.Query, err))
+		return
+	}
+	ss.applyContentSearch(c.Request.Context(), &req)
+	ss.applyTagExpansion(c.Request.Context(), &req)
+	ss.applyMutedUsers(c, &req)
 
 	// Build Elasticsearch query
 	esQuery := ss.buildWorkSearchQuery(req)
 
 	// Execute search
-	response, err := ss.executeWorkSearch(esQuery, req)
+	response, err := ss.executeWorkSearch(c.Request.Context(), esQuery, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Advanced search failed",
-			"details": err.Error(),
-		})
+		respondSearchError(c, "Advanced search failed", err)
 		return
 	}
 
+	response.HighlightsTruncated = ss.hydrateWithinBudget(c.Request.Context(), "works", func(ctx context.Context) {
+		ss.attachContentHighlights(ctx, response, req)
+	})
+	if req.parsedQuery != nil {
+		response.Parsed = req.parsedQuery.String()
+	}
+	response.Ranking = req.rankingMode()
+
 	// Record search analytics
 	go ss.recordSearch(c.Request.Context(), req.Query, "works_advanced", response.Total)
+	ss.recordSearchEvent(c, "works_advanced", req.Query, workSearchFilters(req), response.Total, 0)
 
 	response.SearchTime = time.Since(start).Milliseconds()
 	c.JSON(http.StatusOK, response)
@@ -234,6 +358,7 @@ func (ss *SearchService) buildWorkSearchQuery(req WorkSearchRequest) map[string]
 
 	must := query["bool"].(map[string]interface{})["must"].([]map[string]interface{})
 	filter := query["bool"].(map[string]interface{})["filter"].([]map[string]interface{})
+	mustNot := workExclusionClauses(req)
 
 	// Always filter by status if specified
 	if req.Status != "" && req.Status != "all" {
@@ -256,15 +381,30 @@ func (ss *SearchService) buildWorkSearchQuery(req WorkSearchRequest) map[string]
 	}
 
 	// Text search queries
-	if req.Query != "" {
-		must = append(must, map[string]interface{}{
+	if req.parsedQuery != nil && !req.parsedQuery.isPlainText() {
+		must = append(must, req.parsedQuery.toES(req.contentMatches))
+	} else if req.Query != "" {
+		textQuery := map[string]interface{}{
 			"multi_match": map[string]interface{}{
 				"query":    req.Query,
-				"fields":   []string{"title^3", "summary^2", "content_text", "fandoms", "characters", "relationships", "freeform_tags"},
+				"fields":   workTextFields,
 				"type":     "best_fields",
 				"operator": "or",
 			},
-		})
+		}
+		// Works whose chapter text matched count as matches too
+		if len(req.contentMatches) > 0 {
+			textQuery = map[string]interface{}{
+				"bool": map[string]interface{}{
+					"should": []map[string]interface{}{
+						textQuery,
+						{"ids": map[string]interface{}{"values": contentMatchedWorkIDs(req.contentMatches)}},
+					},
+					"minimum_should_match": 1,
+				},
+			}
+		}
+		must = append(must, textQuery)
 	}
 
 	if req.Title != "" {
@@ -371,21 +511,8 @@ func (ss *SearchService) buildWorkSearchQuery(req WorkSearchRequest) map[string]
 		})
 	}
 
-	// Date range filters
-	if req.UpdatedAfter != "" || req.UpdatedBefore != "" {
-		rangeQuery := map[string]interface{}{}
-		if req.UpdatedAfter != "" {
-			rangeQuery["gte"] = req.UpdatedAfter
-		}
-		if req.UpdatedBefore != "" {
-			rangeQuery["lte"] = req.UpdatedBefore
-		}
-		filter = append(filter, map[string]interface{}{
-			"range": map[string]interface{}{
-				"updated_at": rangeQuery,
-			},
-		})
-	}
+	// Published/updated between
+	filter = append(filter, workDateRangeClauses(req)...)
 
 	// Relationship count filter
 	if req.RelationshipCount != "" {
@@ -521,7 +648,7 @@ func (ss *SearchService) buildWorkSearchQuery(req WorkSearchRequest) map[string]
 		if days > 0 {
 			filter = append(filter, map[string]interface{}{
 				"range": map[string]interface{}{
-					"updated_at": map[string]interface{}{
+					workDateFields["updated"]: map[string]interface{}{
 						"gte": fmt.Sprintf("now-%dd", days),
 					},
 				},
@@ -553,7 +680,7 @@ func (ss *SearchService) buildWorkSearchQuery(req WorkSearchRequest) map[string]
 	if req.MinBookmarks != nil && *req.MinBookmarks > 0 {
 		filter = append(filter, map[string]interface{}{
 			"range": map[string]interface{}{
-				"bookmarks_count": map[string]interface{}{
+				"bookmarks": map[string]interface{}{
 					"gte": *req.MinBookmarks,
 				},
 			},
@@ -561,49 +688,36 @@ func (ss *SearchService) buildWorkSearchQuery(req WorkSearchRequest) map[string]
 	}
 
 	// Blocked tags filtering
-	if len(req.BlockedTags) > 0 {
-		for _, blockedTag := range req.BlockedTags {
-			// Add must_not clause for each blocked tag
-			mustNot := query["bool"].(map[string]interface{})["must_not"]
-			if mustNot == nil {
-				query["bool"].(map[string]interface{})["must_not"] = []map[string]interface{}{}
-				mustNot = query["bool"].(map[string]interface{})["must_not"]
-			}
-
-			mustNotSlice := mustNot.([]map[string]interface{})
-			mustNotSlice = append(mustNotSlice, map[string]interface{}{
-				"multi_match": map[string]interface{}{
-					"query":  blockedTag,
-					"fields": []string{"fandoms", "characters", "relationships", "freeform_tags"},
-				},
-			})
-			query["bool"].(map[string]interface{})["must_not"] = mustNotSlice
-		}
+	for _, blockedTag := range req.BlockedTags {
+		mustNot = append(mustNot, map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  blockedTag,
+				"fields": []string{"fandoms", "characters", "relationships", "freeform_tags"},
+			},
+		})
 	}
 
 	// If no search conditions, use match_all to return all documents
-	if len(must) == 0 && len(filter) == 0 {
+	if len(must) == 0 && len(filter) == 0 && len(mustNot) == 0 {
 		query = map[string]interface{}{
 			"match_all": map[string]interface{}{},
 		}
-	} else if len(must) == 0 {
-		// If only filters but no search text, use match_all with filters
-		query = map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must": []map[string]interface{}{
-					{"match_all": map[string]interface{}{}},
-				},
-				"filter": filter,
-			},
-		}
 	} else {
-		// Update the query with the built filters
+		// If only filters but no search text, match everything they allow
+		if len(must) == 0 {
+			must = []map[string]interface{}{
+				{"match_all": map[string]interface{}{}},
+			}
+		}
 		query["bool"].(map[string]interface{})["must"] = must
 		query["bool"].(map[string]interface{})["filter"] = filter
+		if len(mustNot) > 0 {
+			query["bool"].(map[string]interface{})["must_not"] = mustNot
+		}
 	}
 
 	result := map[string]interface{}{
-		"query": query,
+		"query": withReadingAffinities(withPreferredLanguages(query, req.PreferredLanguages), req.readingAffinities),
 		"sort":  ss.buildSortClause(req.SortBy, req.SortOrder),
 		"size":  req.Limit,
 		"from":  (req.Page - 1) * req.Limit,
@@ -612,7 +726,44 @@ func (ss *SearchService) buildWorkSearchQuery(req WorkSearchRequest) map[string]
 
 	// Query logging can be enabled for debugging if needed
 
-	return result
+	return withCursor(result, req)
+}
+
+// workExclusionClauses builds the must_not clauses for a search's excluded
+// fandoms, characters, relationships, tags, warnings and ratings, each
+// matched against the same field its include filter uses
+func workExclusionClauses(req WorkSearchRequest) []map[string]interface{} {
+	exclusions := []struct {
+		field  string
+		values []string
+	}{
+		{"fandoms", req.ExcludeFandoms},
+		{"characters", req.ExcludeCharacters},
+		{"relationships", req.ExcludeRelationships},
+		{"freeform_tags", req.ExcludeTags},
+		{"warnings", req.ExcludeWarnings},
+		{"rating", req.ExcludeRatings},
+		{"author_ids", req.mutedUserIDs},
+	}
+
+	clauses := []map[string]interface{}{}
+	for _, exclusion := range exclusions {
+		values := []string{}
+		for _, v := range exclusion.values {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		clauses = append(clauses, map[string]interface{}{
+			"terms": map[string]interface{}{
+				exclusion.field: values,
+			},
+		})
+	}
+	return clauses
 }
 
 func (ss *SearchService) buildSortClause(sortBy, sortOrder string) []map[string]interface{} {
@@ -626,18 +777,10 @@ func (ss *SearchService) buildSortClause(sortBy, sortOrder string) []map[string]
 		return []map[string]interface{}{
 			{"_score": map[string]interface{}{"order": "desc"}},
 		}
-	case "updated_at":
-		return []map[string]interface{}{
-			{"updated_at": map[string]interface{}{"order": sortOrder}},
-		}
-	case "published_at":
-		return []map[string]interface{}{
-			{"published_at": map[string]interface{}{"order": sortOrder}},
-		}
-	case "word_count":
-		return []map[string]interface{}{
-			{"word_count": map[string]interface{}{"order": sortOrder}},
-		}
+	case "updated_at", "published_at", "word_count", "hits", "kudos", "comments", "bookmarks":
+		return workFieldSort(sortBy, sortOrder)
+	case "kudos_per_hit":
+		return kudosPerHitSort(sortOrder)
 	case "title":
 		return []map[string]interface{}{
 			{"title.keyword": map[string]interface{}{"order": sortOrder}},
@@ -646,23 +789,6 @@ func (ss *SearchService) buildSortClause(sortBy, sortOrder string) []map[string]
 		return []map[string]interface{}{
 			{"author.keyword": map[string]interface{}{"order": sortOrder}},
 		}
-	// Traditional engagement metrics (gaming-prone)
-	case "hits":
-		return []map[string]interface{}{
-			{"hits_count": map[string]interface{}{"order": sortOrder}},
-		}
-	case "kudos":
-		return []map[string]interface{}{
-			{"kudos_count": map[string]interface{}{"order": sortOrder}},
-		}
-	case "comments":
-		return []map[string]interface{}{
-			{"comments_count": map[string]interface{}{"order": sortOrder}},
-		}
-	case "bookmarks":
-		return []map[string]interface{}{
-			{"bookmarks_count": map[string]interface{}{"order": sortOrder}},
-		}
 	// Smart anti-gaming engagement metrics
 	case "quality_score":
 		// Balanced quality score that resists gaming
@@ -778,9 +904,7 @@ func (ss *SearchService) buildSortClause(sortBy, sortOrder string) []map[string]
 			},
 		}
 	default:
-		return []map[string]interface{}{
-			{"updated_at": map[string]interface{}{"order": "desc"}},
-		}
+		return workFieldSort("updated_at", "desc")
 	}
 }
 
@@ -830,33 +954,29 @@ func (ss *SearchService) buildWorksFacets() map[string]interface{} {
 	}
 }
 
-func (ss *SearchService) executeWorkSearch(query map[string]interface{}, req WorkSearchRequest) (*SearchResponse, error) {
-	// Convert query to JSON
-	queryJSON, err := json.Marshal(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal query: %w", err)
-	}
+// executeWorkSearch runs a work search's hits and facets side by side, each
+// within its budget. Facets that don't arrive in time are left out and the
+// response marked facets_truncated; hits that don't are errSearchTimeout.
+func (ss *SearchService) executeWorkSearch(ctx context.Context, query map[string]interface{}, req WorkSearchRequest) (*SearchResponse, error) {
+	searchRequests.WithLabelValues("works").Inc()
+	hitsQuery, facetQuery := splitFacetQuery(query, ss.budgets)
 
-	// Execute search
-	res, err := ss.es.Search(
-		ss.es.Search.WithContext(context.Background()),
-		ss.es.Search.WithIndex("works"),
-		ss.es.Search.WithBody(bytes.NewReader(queryJSON)),
-		ss.es.Search.WithTrackTotalHits(true),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("search request failed: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return nil, fmt.Errorf("search returned error: %s", res.String())
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var facetsDone chan facetResult
+	if facetQuery != nil {
+		facetsDone = make(chan facetResult, 1)
+		go func() { facetsDone <- ss.runFacetQuery(ctx, facetQuery) }()
 	}
 
-	// Parse response
-	var esResponse map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&esResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	queryCtx, cancelQuery := withBudget(ctx, ss.budgets.Query)
+	defer cancelQuery()
+	esResponse, err := ss.runWorksQuery(queryCtx, hitsQuery, true)
+	if err != nil {
+		if errors.Is(err, errSearchTimeout) {
+			searchPartialResults.WithLabelValues("works", searchStageQuery).Inc()
+		}
+		return nil, err
 	}
 
 	// Extract results
@@ -864,9 +984,11 @@ func (ss *SearchService) executeWorkSearch(query map[string]interface{}, req Wor
 	total := int(hits["total"].(map[string]interface{})["value"].(float64))
 
 	results := []map[string]interface{}{}
+	sortValues := []interface{}{}
 	for _, hit := range hits["hits"].([]interface{}) {
 		hitMap := hit.(map[string]interface{})
 		source := hitMap["_source"].(map[string]interface{})
+		sortValues = append(sortValues, hitMap["sort"])
 
 		// Add score and highlight if available
 		if score, ok := hitMap["_score"]; ok {
@@ -876,155 +998,38 @@ func (ss *SearchService) executeWorkSearch(query map[string]interface{}, req Wor
 			source["_highlight"] = highlight
 		}
 
-		results = append(results, source)
-	}
-
-	// Extract facets
-	facets := map[string]interface{}{}
-	if aggs, ok := esResponse["aggregations"]; ok {
-		facets = aggs.(map[string]interface{})
+		results = append(results, withReadingTime(withRequiredTags(source)))
 	}
 
 	pages := (total + req.Limit - 1) / req.Limit
 
-	return &SearchResponse{
+	response := &SearchResponse{
 		Results: results,
 		Total:   total,
 		Page:    req.Page,
 		Limit:   req.Limit,
 		Pages:   pages,
-		Facets:  facets,
-	}, nil
-}
-
-// Suggestion and autocomplete handlers
-
-func (ss *SearchService) GetSuggestions(c *gin.Context) {
-	query := c.Query("q")
-	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
-		return
-	}
-
-	suggestType := c.DefaultQuery("type", "all") // all, works, tags, authors
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if limit > 50 {
-		limit = 50
-	}
-
-	// Check cache first
-	cacheKey := fmt.Sprintf("suggestions:%s:%s:%d", query, suggestType, limit)
-	cached, err := ss.redis.Get(c.Request.Context(), cacheKey).Result()
-	if err == nil {
-		var suggestions map[string]interface{}
-		if json.Unmarshal([]byte(cached), &suggestions) == nil {
-			c.JSON(http.StatusOK, suggestions)
-			return
-		}
-	}
-
-	// Build suggestions query
-	suggestQuery := map[string]interface{}{
-		"suggest": map[string]interface{}{
-			"work_title_suggest": map[string]interface{}{
-				"prefix": query,
-				"completion": map[string]interface{}{
-					"field": "title_suggest",
-					"size":  limit,
-				},
-			},
-			"tag_suggest": map[string]interface{}{
-				"prefix": query,
-				"completion": map[string]interface{}{
-					"field": "tag_suggest",
-					"size":  limit,
-				},
-			},
-			"author_suggest": map[string]interface{}{
-				"prefix": query,
-				"completion": map[string]interface{}{
-					"field": "author_suggest",
-					"size":  limit,
-				},
-			},
-		},
-	}
-
-	queryJSON, _ := json.Marshal(suggestQuery)
-
-	res, err := ss.es.Search(
-		ss.es.Search.WithContext(context.Background()),
-		ss.es.Search.WithIndex("works,tags,users"),
-		ss.es.Search.WithBody(bytes.NewReader(queryJSON)),
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Suggestion request failed"})
-		return
-	}
-	defer res.Body.Close()
-
-	var esResponse map[string]interface{}
-	json.NewDecoder(res.Body).Decode(&esResponse)
-
-	// Extract suggestions
-	suggestions := map[string]interface{}{
-		"works":   []string{},
-		"tags":    []string{},
-		"authors": []string{},
-	}
-
-	if suggest, ok := esResponse["suggest"]; ok {
-		suggestMap := suggest.(map[string]interface{})
-
-		// Extract work title suggestions
-		if workSuggest, ok := suggestMap["work_title_suggest"]; ok {
-			workList := workSuggest.([]interface{})
-			if len(workList) > 0 {
-				options := workList[0].(map[string]interface{})["options"].([]interface{})
-				workTitles := []string{}
-				for _, opt := range options {
-					text := opt.(map[string]interface{})["text"].(string)
-					workTitles = append(workTitles, text)
-				}
-				suggestions["works"] = workTitles
-			}
-		}
-
-		// Extract tag suggestions
-		if tagSuggest, ok := suggestMap["tag_suggest"]; ok {
-			tagList := tagSuggest.([]interface{})
-			if len(tagList) > 0 {
-				options := tagList[0].(map[string]interface{})["options"].([]interface{})
-				tags := []string{}
-				for _, opt := range options {
-					text := opt.(map[string]interface{})["text"].(string)
-					tags = append(tags, text)
-				}
-				suggestions["tags"] = tags
-			}
-		}
-
-		// Extract author suggestions
-		if authorSuggest, ok := suggestMap["author_suggest"]; ok {
-			authorList := authorSuggest.([]interface{})
-			if len(authorList) > 0 {
-				options := authorList[0].(map[string]interface{})["options"].([]interface{})
-				authors := []string{}
-				for _, opt := range options {
-					text := opt.(map[string]interface{})["text"].(string)
-					authors = append(authors, text)
-				}
-				suggestions["authors"] = authors
-			}
+		Facets:  map[string]interface{}{},
+	}
+	if req.Cursor != nil {
+		response.Results, response.NextCursor = nextWorkCursor(req, results, sortValues)
+		response.HasMore = response.NextCursor != ""
+	}
+	// Elasticsearch answers with the hits it found in time when shards run
+	// over the query's own timeout
+	if timedOut, _ := esResponse["timed_out"].(bool); timedOut {
+		response.ResultsPartial = true
+		searchPartialResults.WithLabelValues("works", searchStageQuery).Inc()
+	}
+	if facetsDone != nil {
+		facets := <-facetsDone
+		response.Facets = facets.facets
+		if facets.truncated {
+			response.FacetsTruncated = true
+			searchPartialResults.WithLabelValues("works", searchStageFacets).Inc()
 		}
 	}
-
-	// Cache result
-	if suggJSON, err := json.Marshal(suggestions); err == nil {
-		ss.redis.Set(c.Request.Context(), cacheKey, suggJSON, time.Minute*15)
-	}
-
-	c.JSON(http.StatusOK, suggestions)
+	return response, nil
 }
 
 // Analytics helper
@@ -1567,18 +1572,6 @@ func (ss *SearchService) OptimizeIndex(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Index optimization started"})
 }
 
-func (ss *SearchService) GetSearchStats(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"stats": gin.H{}})
-}
-
-func (ss *SearchService) GetPopularTerms(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"terms": []gin.H{}})
-}
-
-func (ss *SearchService) GetZeroResultTerms(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"terms": []gin.H{}})
-}
-
 func (ss *SearchService) GetSearchPerformance(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"performance": gin.H{}})
 }
@@ -1591,22 +1584,6 @@ func (ss *SearchService) ClearSearchHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Search history cleared"})
 }
 
-func (ss *SearchService) SaveSearch(c *gin.Context) {
-	c.JSON(http.StatusCreated, gin.H{"search": gin.H{}})
-}
-
-func (ss *SearchService) GetSavedSearches(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"searches": []gin.H{}})
-}
-
-func (ss *SearchService) DeleteSavedSearch(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted"})
-}
-
-func (ss *SearchService) CreateSearchAlert(c *gin.Context) {
-	c.JSON(http.StatusCreated, gin.H{"alert": gin.H{}})
-}
-
 func (ss *SearchService) GetFandomFilters(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"filters": []gin.H{}})
 }
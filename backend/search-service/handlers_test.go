@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildWorkSearchQueryExclusions(t *testing.T) {
+	ss := &SearchService{}
+	req := WorkSearchRequest{
+		Page:                 1,
+		Limit:                20,
+		ExcludeTags:          []string{"Major Character Death", " "},
+		ExcludeRatings:       []string{"Explicit"},
+		ExcludeRelationships: []string{"Aziraphale/Crowley (Good Omens)"},
+	}
+
+	query := ss.buildWorkSearchQuery(req)["query"].(map[string]interface{})
+	boolQuery, ok := query["bool"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Exclusions alone should still build a bool query, got %v", query)
+	}
+
+	mustNot, _ := boolQuery["must_not"].([]map[string]interface{})
+	want := []map[string]interface{}{
+		{"terms": map[string]interface{}{"relationships": []string{"Aziraphale/Crowley (Good Omens)"}}},
+		{"terms": map[string]interface{}{"freeform_tags": []string{"Major Character Death"}}},
+		{"terms": map[string]interface{}{"rating": []string{"Explicit"}}},
+	}
+	if !reflect.DeepEqual(mustNot, want) {
+		t.Errorf("must_not = %v, want %v", mustNot, want)
+	}
+}
+
+func TestBuildWorkSearchQueryKeepsBlockedTagsWithoutText(t *testing.T) {
+	ss := &SearchService{}
+	req := WorkSearchRequest{Page: 1, Limit: 20, Fandoms: []string{"Good Omens"}, BlockedTags: []string{"Angst"}}
+
+	query := ss.buildWorkSearchQuery(req)["query"].(map[string]interface{})
+	mustNot, _ := query["bool"].(map[string]interface{})["must_not"].([]map[string]interface{})
+	if len(mustNot) != 1 {
+		t.Errorf("Expected the blocked tag to survive a filter-only search, got %v", query)
+	}
+}
+
+func TestBuildWorkSearchQueryExcludesMutedAuthors(t *testing.T) {
+	ss := &SearchService{}
+	req := WorkSearchRequest{Page: 1, Limit: 20, mutedUserIDs: []string{"9b2f7c1e-0000-4000-8000-000000000001"}}
+
+	query := ss.buildWorkSearchQuery(req)["query"].(map[string]interface{})
+	mustNot, _ := query["bool"].(map[string]interface{})["must_not"].([]map[string]interface{})
+	want := []map[string]interface{}{
+		{"terms": map[string]interface{}{"author_ids": []string{"9b2f7c1e-0000-4000-8000-000000000001"}}},
+	}
+	if !reflect.DeepEqual(mustNot, want) {
+		t.Errorf("must_not = %v, want %v", mustNot, want)
+	}
+}
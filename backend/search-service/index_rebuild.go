@@ -0,0 +1,699 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+
+	esindex "nuclear-ao3/shared/elasticsearch"
+)
+
+// Zero-downtime rebuild of the works index. Searches and writes go through
+// the "works" alias, which points at one versioned index (works_v...). A
+// rebuild creates a fresh index from the shared mappings, bulk-loads every
+// searchable work from Postgres with parallel workers, then moves the alias
+// in a single request and drops the old index.
+//
+// While the load runs the work event consumer writes to the new index too.
+// Loaded documents carry the outbox position taken when the load began as
+// their external version, so they never overwrite a newer event, and
+// delete tombstones are kept for the whole rebuild so a loaded document
+// can't bring back a work deleted mid-load.
+
+const (
+	worksAlias       = "works"
+	rebuildBatchSize = 500
+	rebuildStateKey  = "search:index_rebuild"
+	rebuildLockKey   = "search:index_rebuild:lock"
+	// rebuildLockTTL bounds a rebuild; the lock of a crashed one expires
+	rebuildLockTTL = 6 * time.Hour
+	// rebuildTargetRefresh is how long consumers may take to notice a rebuild
+	rebuildTargetRefresh    = 5 * time.Second
+	rebuildProgressInterval = 2 * time.Second
+)
+
+// Rebuild states
+const (
+	rebuildCreating = "creating"
+	rebuildLoading  = "loading"
+	rebuildSwapping = "swapping"
+	rebuildComplete = "complete"
+	rebuildFailed   = "failed"
+)
+
+var errRebuildRunning = errors.New("an index rebuild is already running")
+
+// searchableWorksSQL matches the works work-service publishes as upserts
+const searchableWorksSQL = `w.status NOT IN ('draft', 'hidden', 'deleted', 'unlisted')
+	AND NOT COALESCE(w.in_unrevealed_collection, false)`
+
+// IndexRebuildStatus is the progress of the latest works index rebuild
+type IndexRebuildStatus struct {
+	State           string     `json:"state"`
+	Index           string     `json:"index"`
+	PreviousIndices []string   `json:"previous_indices,omitempty"`
+	SnapshotVersion int64      `json:"snapshot_version"`
+	Workers         int        `json:"workers"`
+	TotalWorks      int64      `json:"total_works"`
+	IndexedWorks    int64      `json:"indexed_works"`
+	FailedWorks     int64      `json:"failed_works"`
+	PercentDone     float64    `json:"percent_done"`
+	WorksPerSecond  float64    `json:"works_per_second"`
+	ETASeconds      *int64     `json:"eta_seconds,omitempty"`
+	StartedAt       time.Time  `json:"started_at"`
+	LoadStartedAt   *time.Time `json:"load_started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// active reports whether work events should also be written to the
+// rebuild's index
+func (s IndexRebuildStatus) active() bool {
+	return s.State == rebuildLoading || s.State == rebuildSwapping
+}
+
+// withProgress fills in the percentage, rate and ETA as of now
+func (s IndexRebuildStatus) withProgress(now time.Time) IndexRebuildStatus {
+	done := s.IndexedWorks + s.FailedWorks
+	if s.TotalWorks > 0 {
+		s.PercentDone = math.Round(math.Min(100, float64(done)*100/float64(s.TotalWorks))*10) / 10
+	}
+	if s.LoadStartedAt == nil || done == 0 {
+		return s
+	}
+	end := now
+	if s.FinishedAt != nil {
+		end = *s.FinishedAt
+	}
+	elapsed := end.Sub(*s.LoadStartedAt).Seconds()
+	if elapsed <= 0 {
+		return s
+	}
+	rate := float64(done) / elapsed
+	s.WorksPerSecond = math.Round(rate*10) / 10
+	if s.State == rebuildLoading && done < s.TotalWorks {
+		eta := int64(math.Ceil(float64(s.TotalWorks-done) / rate))
+		s.ETASeconds = &eta
+	}
+	return s
+}
+
+// rebuildIndexName names the index a rebuild started at now loads into
+func rebuildIndexName(now time.Time) string {
+	return worksAlias + "_v" + now.UTC().Format("20060102150405")
+}
+
+// rebuildWorkers is the number of parallel loaders (SEARCH_REBUILD_WORKERS)
+func rebuildWorkers() int {
+	if n, err := strconv.Atoi(getEnv("SEARCH_REBUILD_WORKERS", "4")); err == nil && n > 0 {
+		return n
+	}
+	return 4
+}
+
+// loadSettings are the index settings used while bulk-loading: no
+// refreshes or replicas, and tombstones kept for the whole rebuild
+func loadSettings(settings map[string]interface{}) map[string]interface{} {
+	load := make(map[string]interface{}, len(settings)+3)
+	for k, v := range settings {
+		load[k] = v
+	}
+	load["refresh_interval"] = "-1"
+	load["number_of_replicas"] = 0
+	load["gc_deletes"] = fmt.Sprintf("%dm", int(rebuildLockTTL.Minutes()))
+	return load
+}
+
+// liveSettings restores what loadSettings changed, from the definition
+func liveSettings(settings map[string]interface{}) map[string]interface{} {
+	live := map[string]interface{}{
+		"refresh_interval":   "1s",
+		"number_of_replicas": 1,
+		"gc_deletes":         "60s",
+	}
+	for _, k := range []string{"refresh_interval", "number_of_replicas"} {
+		if v, ok := settings[k]; ok {
+			live[k] = v
+		}
+	}
+	return live
+}
+
+// aliasSwapActions moves alias from the current indices to newIndex in one
+// request. A concrete index still named after the alias, from before the
+// works index was aliased, is deleted in the same request.
+func aliasSwapActions(alias, newIndex string, current []string, concrete bool) []map[string]interface{} {
+	actions := []map[string]interface{}{}
+	if concrete {
+		actions = append(actions, map[string]interface{}{"remove_index": map[string]interface{}{"index": alias}})
+	}
+	for _, index := range current {
+		actions = append(actions, map[string]interface{}{"remove": map[string]interface{}{"index": index, "alias": alias}})
+	}
+	return append(actions, map[string]interface{}{"add": map[string]interface{}{"index": newIndex, "alias": alias}})
+}
+
+// rebuildBulkBody is a bulk request indexing docs into index at version
+func rebuildBulkBody(index string, version int64, docs []WorkIndexDocument) ([]byte, error) {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		action, err := json.Marshal(map[string]interface{}{
+			"index": map[string]interface{}{
+				"_index":       index,
+				"_id":          doc.WorkID,
+				"version":      version,
+				"version_type": "external",
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		source, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(source)
+		body.WriteByte('\n')
+	}
+	return body.Bytes(), nil
+}
+
+// countBulkResults counts the indexed and failed items of a bulk response.
+// A version conflict means a newer event already indexed the work.
+func countBulkResults(r io.Reader) (indexed, failed int64, err error) {
+	var response struct {
+		Items []map[string]struct {
+			Status int `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse bulk response: %w", err)
+	}
+	for _, item := range response.Items {
+		for _, result := range item {
+			if (result.Status >= 200 && result.Status < 300) || result.Status == http.StatusConflict {
+				indexed++
+			} else {
+				failed++
+			}
+		}
+	}
+	return indexed, failed, nil
+}
+
+// EnhancedRebuildIndex rebuilds the works index into a new versioned index
+// and swaps the alias over once it's loaded. Progress is reported by
+// GET /api/v1/index/status.
+func (ss *SearchService) EnhancedRebuildIndex(c *gin.Context) {
+	status, err := ss.startWorksIndexRebuild(c.Request.Context())
+	if errors.Is(err, errRebuildRunning) {
+		current, _ := ss.loadRebuildStatus(c.Request.Context())
+		c.JSON(http.StatusConflict, gin.H{"error": "An index rebuild is already running", "rebuild": current})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start index rebuild", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Index rebuild started",
+		"status":  "processing",
+		"rebuild": status,
+	})
+}
+
+// startWorksIndexRebuild takes the rebuild lock and starts a rebuild in
+// the background
+func (ss *SearchService) startWorksIndexRebuild(ctx context.Context) (IndexRebuildStatus, error) {
+	if ss.redis == nil || ss.db == nil {
+		return IndexRebuildStatus{}, errors.New("index rebuilds need Redis and Postgres")
+	}
+	locked, err := ss.redis.SetNX(ctx, rebuildLockKey, time.Now().Unix(), rebuildLockTTL).Result()
+	if err != nil {
+		return IndexRebuildStatus{}, err
+	}
+	if !locked {
+		return IndexRebuildStatus{}, errRebuildRunning
+	}
+
+	now := time.Now()
+	status := IndexRebuildStatus{
+		State:     rebuildCreating,
+		Index:     rebuildIndexName(now),
+		Workers:   rebuildWorkers(),
+		StartedAt: now,
+	}
+	if err := ss.saveRebuildStatus(ctx, status); err != nil {
+		ss.redis.Del(ctx, rebuildLockKey)
+		return IndexRebuildStatus{}, err
+	}
+
+	go ss.rebuildSearchIndex(context.Background(), status)
+	return status, nil
+}
+
+// rebuildSearchIndex runs a rebuild to completion, dropping the new index
+// if it fails before going live
+func (ss *SearchService) rebuildSearchIndex(ctx context.Context, status IndexRebuildStatus) {
+	defer ss.redis.Del(context.Background(), rebuildLockKey)
+
+	log.Printf("Rebuilding works index into %s with %d workers", status.Index, status.Workers)
+	err := ss.runWorksIndexRebuild(ctx, &status)
+	finished := time.Now()
+	status.FinishedAt = &finished
+
+	if err != nil {
+		log.Printf("Works index rebuild into %s failed: %v", status.Index, err)
+		status.State, status.Error = rebuildFailed, err.Error()
+		if err := ss.saveRebuildStatus(ctx, status); err != nil {
+			log.Printf("Failed to save index rebuild status: %v", err)
+		}
+		// Wait for consumers to stop writing to the index so a late write
+		// can't recreate it after it's dropped
+		time.Sleep(rebuildTargetRefresh)
+		if err := ss.deleteIndices(ctx, status.Index); err != nil {
+			log.Printf("Failed to delete abandoned index %s: %v", status.Index, err)
+		}
+		return
+	}
+
+	status.State = rebuildComplete
+	if err := ss.saveRebuildStatus(ctx, status); err != nil {
+		log.Printf("Failed to save index rebuild status: %v", err)
+	}
+	log.Printf("Works index rebuilt into %s: %d works in %s",
+		status.Index, status.IndexedWorks, finished.Sub(status.StartedAt).Round(time.Second))
+}
+
+// runWorksIndexRebuild creates, loads and swaps in the new index. Errors
+// returned leave the current index live.
+func (ss *SearchService) runWorksIndexRebuild(ctx context.Context, status *IndexRebuildStatus) error {
+	definition, err := esindex.Definition(worksAlias)
+	if err != nil {
+		return err
+	}
+	if err := ss.createIndex(ctx, status.Index, loadSettings(definition.Settings), definition.Mappings); err != nil {
+		return err
+	}
+
+	status.State = rebuildLoading
+	if err := ss.saveRebuildStatus(ctx, *status); err != nil {
+		return err
+	}
+	// Let every consumer start writing to the new index before the snapshot
+	if !sleepContext(ctx, rebuildTargetRefresh+time.Second) {
+		return ctx.Err()
+	}
+
+	if err := ss.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM search_outbox").Scan(&status.SnapshotVersion); err != nil {
+		return fmt.Errorf("failed to read outbox position: %w", err)
+	}
+	if err := ss.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM works w WHERE "+searchableWorksSQL).Scan(&status.TotalWorks); err != nil {
+		return fmt.Errorf("failed to count works: %w", err)
+	}
+	loadStarted := time.Now()
+	status.LoadStartedAt = &loadStarted
+	if err := ss.saveRebuildStatus(ctx, *status); err != nil {
+		return err
+	}
+
+	if err := ss.loadWorksIntoIndex(ctx, status); err != nil {
+		return err
+	}
+	if status.FailedWorks > 0 {
+		return fmt.Errorf("%d works failed to index; keeping the current index", status.FailedWorks)
+	}
+
+	status.State = rebuildSwapping
+	if err := ss.saveRebuildStatus(ctx, *status); err != nil {
+		return err
+	}
+	if err := ss.putIndexSettings(ctx, status.Index, liveSettings(definition.Settings)); err != nil {
+		return err
+	}
+	if err := ss.refreshIndex(ctx, status.Index); err != nil {
+		return err
+	}
+
+	current, concrete, err := ss.aliasIndices(ctx, worksAlias)
+	if err != nil {
+		return err
+	}
+	status.PreviousIndices = current
+	if concrete {
+		status.PreviousIndices = []string{worksAlias}
+	}
+	if err := ss.updateAliases(ctx, aliasSwapActions(worksAlias, status.Index, current, concrete)); err != nil {
+		return err
+	}
+
+	// The new index is live now, so failing to drop the old one is only logged
+	if len(current) > 0 {
+		if err := ss.deleteIndices(ctx, current...); err != nil {
+			log.Printf("Failed to delete previous works index %v: %v", current, err)
+		}
+	}
+	return nil
+}
+
+// loadWorksIntoIndex pages through the searchable works in id order and
+// indexes them in batches with status.Workers parallel workers
+func (ss *SearchService) loadWorksIntoIndex(ctx context.Context, status *IndexRebuildStatus) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var indexed, failed atomic.Int64
+	var firstErr error
+	var errOnce sync.Once
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	batches := make(chan []string)
+	var wg sync.WaitGroup
+	for i := 0; i < status.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ids := range batches {
+				ok, bad, err := ss.indexWorkBatch(ctx, status.Index, status.SnapshotVersion, ids)
+				if err != nil {
+					fail(err)
+					return
+				}
+				indexed.Add(ok)
+				failed.Add(bad)
+			}
+		}()
+	}
+
+	progressDone, progressStopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(progressStopped)
+		ticker := time.NewTicker(rebuildProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-progressDone:
+				return
+			case <-ticker.C:
+				progress := *status
+				progress.IndexedWorks, progress.FailedWorks = indexed.Load(), failed.Load()
+				if err := ss.saveRebuildStatus(ctx, progress); err != nil {
+					log.Printf("Failed to save index rebuild progress: %v", err)
+				}
+			}
+		}
+	}()
+
+	lastID := "00000000-0000-0000-0000-000000000000"
+	for ctx.Err() == nil {
+		ids, err := ss.searchableWorkIDsAfter(ctx, lastID, rebuildBatchSize)
+		if err != nil {
+			fail(fmt.Errorf("failed to list works: %w", err))
+			break
+		}
+		if len(ids) == 0 {
+			break
+		}
+		select {
+		case batches <- ids:
+		case <-ctx.Done():
+		}
+		lastID = ids[len(ids)-1]
+	}
+	close(batches)
+	wg.Wait()
+	close(progressDone)
+	<-progressStopped
+
+	status.IndexedWorks, status.FailedWorks = indexed.Load(), failed.Load()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// searchableWorkIDsAfter is the next page of searchable work ids after afterID
+func (ss *SearchService) searchableWorkIDsAfter(ctx context.Context, afterID string, limit int) ([]string, error) {
+	rows, err := ss.db.QueryContext(ctx, `
+		SELECT w.id FROM works w
+		WHERE w.id > $1::uuid AND `+searchableWorksSQL+`
+		ORDER BY w.id
+		LIMIT $2`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0, limit)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// indexWorkBatch loads the given works and bulk-indexes them. Works that
+// stopped being searchable since they were listed are skipped.
+func (ss *SearchService) indexWorkBatch(ctx context.Context, index string, version int64, ids []string) (int64, int64, error) {
+	docs, err := ss.loadWorkDocuments(ctx, ids)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load works: %w", err)
+	}
+	if len(docs) == 0 {
+		return 0, 0, nil
+	}
+	ss.refreshWorkSuggestions(ctx, version, docs)
+	body, err := rebuildBulkBody(index, version, docs)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	res, err := ss.es.Bulk(bytes.NewReader(body), ss.es.Bulk.WithContext(ctx))
+	if err != nil {
+		return 0, 0, fmt.Errorf("bulk request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, 0, fmt.Errorf("bulk request returned error: %s", res.String())
+	}
+	return countBulkResults(res.Body)
+}
+
+// loadWorkDocuments builds the search documents of the given works from
+// Postgres, matching what work-service publishes for them
+func (ss *SearchService) loadWorkDocuments(ctx context.Context, ids []string) ([]WorkIndexDocument, error) {
+	rows, err := ss.db.QueryContext(ctx, `
+		SELECT w.id, w.title, COALESCE(w.summary, ''), COALESCE(w.rating, ''), COALESCE(w.language, ''),
+			COALESCE(w.fandoms, '{}'), COALESCE(w.characters, '{}'), COALESCE(w.relationships, '{}'),
+			COALESCE(w.freeform_tags, '{}'), COALESCE(w.warnings, '{}'), COALESCE(w.category, '{}'),
+			COALESCE(w.word_count, 0), COALESCE(w.chapter_count, 0), w.status, w.created_at, w.updated_at,
+			w.user_id, COALESCE(w.is_anonymous, false) OR COALESCE(w.in_anon_collection, false),
+			COALESCE(w.restricted_to_users, false),
+			COALESCE(ws.hits, 0), COALESCE(ws.kudos, 0), COALESCE(ws.comments, 0), COALESCE(ws.bookmarks, 0)
+		FROM works w
+		LEFT JOIN work_statistics ws ON ws.work_id = w.id
+		WHERE w.id = ANY($1::uuid[]) AND `+searchableWorksSQL, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := make([]WorkIndexDocument, 0, len(ids))
+	for rows.Next() {
+		var doc WorkIndexDocument
+		var fandoms, characters, relationships, freeform, warnings, categories pq.StringArray
+		var authorID string
+		if err := rows.Scan(&doc.WorkID, &doc.Title, &doc.Summary, &doc.Rating, &doc.Language,
+			&fandoms, &characters, &relationships, &freeform, &warnings, &categories,
+			&doc.WordCount, &doc.ChapterCount, &doc.CompletionStatus, &doc.PublishedDate, &doc.UpdatedDate,
+			&authorID, &doc.IsAnonymous, &doc.IsRestricted,
+			&doc.Hits, &doc.Kudos, &doc.Comments, &doc.Bookmarks); err != nil {
+			return nil, err
+		}
+		doc.Fandoms, doc.Characters, doc.Relationships = fandoms, characters, relationships
+		doc.AdditionalTags, doc.Warnings, doc.Categories = freeform, warnings, categories
+		// Anonymous works must not be findable by their creators
+		doc.AuthorIDs = []string{authorID}
+		if doc.IsAnonymous {
+			doc.AuthorIDs = []string{}
+		}
+		doc.AuthorNames, doc.Collections, doc.Series = []string{}, []string{}, []string{}
+		ss.enhanceWorkDocument(&doc)
+		doc.IndexedAt = time.Now()
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// saveRebuildStatus records the rebuild's progress for GET /index/status
+// and for consumers deciding where to write
+func (ss *SearchService) saveRebuildStatus(ctx context.Context, status IndexRebuildStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return ss.redis.Set(ctx, rebuildStateKey, data, 0).Err()
+}
+
+// loadRebuildStatus is the latest rebuild's status, nil if there hasn't been one
+func (ss *SearchService) loadRebuildStatus(ctx context.Context) (*IndexRebuildStatus, error) {
+	if ss.redis == nil {
+		return nil, nil
+	}
+	data, err := ss.redis.Get(ctx, rebuildStateKey).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var status IndexRebuildStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+	status = status.withProgress(time.Now())
+	return &status, nil
+}
+
+// rebuildTargetCache remembers, for up to rebuildTargetRefresh, which
+// index a running rebuild is loading
+type rebuildTargetCache struct {
+	mu        sync.Mutex
+	index     string
+	checkedAt time.Time
+}
+
+// rebuildTargetIndex is the index of the running rebuild that work events
+// must also be written to, or "" when none is running
+func (ss *SearchService) rebuildTargetIndex(ctx context.Context) string {
+	ss.rebuildTarget.mu.Lock()
+	defer ss.rebuildTarget.mu.Unlock()
+	if time.Since(ss.rebuildTarget.checkedAt) < rebuildTargetRefresh {
+		return ss.rebuildTarget.index
+	}
+
+	status, err := ss.loadRebuildStatus(ctx)
+	if err != nil {
+		// Keep the last answer until Redis is back
+		log.Printf("Failed to check for an index rebuild: %v", err)
+		return ss.rebuildTarget.index
+	}
+	ss.rebuildTarget.index = ""
+	if status != nil && status.active() {
+		ss.rebuildTarget.index = status.Index
+	}
+	ss.rebuildTarget.checkedAt = time.Now()
+	return ss.rebuildTarget.index
+}
+
+// createIndex creates index with the given settings and mappings
+func (ss *SearchService) createIndex(ctx context.Context, index string, settings, mappings map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"settings": settings, "mappings": mappings})
+	if err != nil {
+		return err
+	}
+	res, err := ss.es.Indices.Create(index, ss.es.Indices.Create.WithBody(bytes.NewReader(body)), ss.es.Indices.Create.WithContext(ctx))
+	return esResult(res, err, "create index "+index)
+}
+
+// putIndexSettings updates the dynamic settings of index
+func (ss *SearchService) putIndexSettings(ctx context.Context, index string, settings map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"index": settings})
+	if err != nil {
+		return err
+	}
+	res, err := ss.es.Indices.PutSettings(bytes.NewReader(body),
+		ss.es.Indices.PutSettings.WithIndex(index), ss.es.Indices.PutSettings.WithContext(ctx))
+	return esResult(res, err, "update settings of "+index)
+}
+
+// refreshIndex makes everything loaded into index searchable
+func (ss *SearchService) refreshIndex(ctx context.Context, index string) error {
+	res, err := ss.es.Indices.Refresh(ss.es.Indices.Refresh.WithIndex(index), ss.es.Indices.Refresh.WithContext(ctx))
+	return esResult(res, err, "refresh "+index)
+}
+
+// aliasIndices lists the indices behind alias. concrete is true when alias
+// is instead the name of an index, as before the works index was aliased.
+func (ss *SearchService) aliasIndices(ctx context.Context, alias string) (indices []string, concrete bool, err error) {
+	res, err := ss.es.Indices.GetAlias(ss.es.Indices.GetAlias.WithName(alias), ss.es.Indices.GetAlias.WithContext(ctx))
+	if err != nil {
+		return nil, false, fmt.Errorf("get alias %s failed: %w", alias, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		exists, err := ss.es.Indices.Exists([]string{alias}, ss.es.Indices.Exists.WithContext(ctx))
+		if err != nil {
+			return nil, false, fmt.Errorf("index exists check for %s failed: %w", alias, err)
+		}
+		defer exists.Body.Close()
+		return nil, exists.StatusCode == http.StatusOK, nil
+	}
+	if res.IsError() {
+		return nil, false, fmt.Errorf("get alias %s returned error: %s", alias, res.String())
+	}
+
+	var byIndex map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&byIndex); err != nil {
+		return nil, false, fmt.Errorf("failed to parse alias response: %w", err)
+	}
+	for index := range byIndex {
+		indices = append(indices, index)
+	}
+	return indices, false, nil
+}
+
+// updateAliases applies alias actions atomically
+func (ss *SearchService) updateAliases(ctx context.Context, actions []map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return err
+	}
+	res, err := ss.es.Indices.UpdateAliases(bytes.NewReader(body), ss.es.Indices.UpdateAliases.WithContext(ctx))
+	return esResult(res, err, "update aliases")
+}
+
+// deleteIndices drops the given indices
+func (ss *SearchService) deleteIndices(ctx context.Context, indices ...string) error {
+	res, err := ss.es.Indices.Delete(indices, ss.es.Indices.Delete.WithContext(ctx))
+	return esResult(res, err, fmt.Sprintf("delete %v", indices))
+}
+
+// esResult turns a response into an error, closing its body
+func esResult(res *esapi.Response, err error, what string) error {
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", what, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("%s returned error: %s", what, res.String())
+	}
+	return nil
+}
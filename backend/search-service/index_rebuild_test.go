@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRebuildProgress(t *testing.T) {
+	loadStarted := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	status := IndexRebuildStatus{
+		State:         rebuildLoading,
+		TotalWorks:    1000,
+		IndexedWorks:  240,
+		FailedWorks:   10,
+		LoadStartedAt: &loadStarted,
+	}
+
+	progress := status.withProgress(loadStarted.Add(10 * time.Second))
+	if progress.PercentDone != 25 {
+		t.Errorf("PercentDone = %v, want 25", progress.PercentDone)
+	}
+	if progress.WorksPerSecond != 25 {
+		t.Errorf("WorksPerSecond = %v, want 25", progress.WorksPerSecond)
+	}
+	if progress.ETASeconds == nil || *progress.ETASeconds != 30 {
+		t.Errorf("ETASeconds = %v, want 30", progress.ETASeconds)
+	}
+
+	status.State = rebuildComplete
+	if status.withProgress(loadStarted.Add(time.Minute)).ETASeconds != nil {
+		t.Error("a finished rebuild has no ETA")
+	}
+}
+
+func TestAliasSwapActions(t *testing.T) {
+	actions := aliasSwapActions("works", "works_v2", []string{"works_v1"}, false)
+	got, _ := json.Marshal(actions)
+	want := `[{"remove":{"alias":"works","index":"works_v1"}},{"add":{"alias":"works","index":"works_v2"}}]`
+	if string(got) != want {
+		t.Errorf("actions = %s, want %s", got, want)
+	}
+
+	// Before the first rebuild "works" is a plain index, dropped in the same request
+	actions = aliasSwapActions("works", "works_v2", nil, true)
+	got, _ = json.Marshal(actions)
+	want = `[{"remove_index":{"index":"works"}},{"add":{"alias":"works","index":"works_v2"}}]`
+	if string(got) != want {
+		t.Errorf("actions = %s, want %s", got, want)
+	}
+}
+
+func TestRebuildBulkBody(t *testing.T) {
+	body, err := rebuildBulkBody("works_v2", 42, []WorkIndexDocument{{WorkID: "a"}, {WorkID: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want an action and a source per work", len(lines))
+	}
+	var action map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &action); err != nil {
+		t.Fatal(err)
+	}
+	index := action["index"]
+	if index["_index"] != "works_v2" || index["_id"] != "a" || index["version"] != float64(42) || index["version_type"] != "external" {
+		t.Errorf("action = %v", index)
+	}
+}
+
+func TestCountBulkResults(t *testing.T) {
+	response := `{"items":[{"index":{"status":201}},{"index":{"status":409}},{"index":{"status":400}}]}`
+	indexed, failed, err := countBulkResults(bytes.NewReader([]byte(response)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexed != 2 || failed != 1 {
+		t.Errorf("indexed, failed = %d, %d; want 2, 1 (a version conflict means a newer event won)", indexed, failed)
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	settings := map[string]interface{}{"number_of_shards": 1, "refresh_interval": "30s", "number_of_replicas": 1}
+	load := loadSettings(settings)
+	if load["refresh_interval"] != "-1" || load["number_of_replicas"] != 0 || load["number_of_shards"] != 1 {
+		t.Errorf("loadSettings = %v", load)
+	}
+	if load["gc_deletes"] != "360m" {
+		t.Errorf("gc_deletes = %v, want tombstones kept for the rebuild", load["gc_deletes"])
+	}
+	if settings["refresh_interval"] != "30s" {
+		t.Error("loadSettings must not change the definition")
+	}
+
+	live := liveSettings(settings)
+	if live["refresh_interval"] != "30s" || live["number_of_replicas"] != 1 || live["gc_deletes"] != "60s" {
+		t.Errorf("liveSettings = %v", live)
+	}
+}
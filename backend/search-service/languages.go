@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// LANGUAGE ANALYSIS AND PREFERENCES
+// Work titles and summaries are indexed a second time under the analyzer
+// for the work's language, in title_lang and summary_lang, next to the
+// language-neutral title and summary. Readers can keep a list of preferred
+// languages; searches rank works in those languages higher without hiding
+// the rest.
+// =============================================================================
+
+const (
+	// maxPreferredLanguages caps the languages a reader can prefer
+	maxPreferredLanguages = 5
+	// preferredLanguageBoost is added to the score of works in a preferred
+	// language, enough to lift them over comparable matches
+	preferredLanguageBoost = 2.0
+	// otherLanguageAnalysis is the ICU-analysed field for languages without
+	// an analyzer of their own
+	otherLanguageAnalysis = "other"
+)
+
+// languageAnalysis maps work languages to the per-language field suffix
+// analysed for them; the chapters and works mappings have a matching
+// analyzer for each. Chinese, Japanese and Korean share the CJK analyzer.
+var languageAnalysis = map[string]string{
+	"en": "en",
+	"es": "es",
+	"fr": "fr",
+	"de": "de",
+	"it": "it",
+	"pt": "pt",
+	"nl": "nl",
+	"sv": "sv",
+	"ru": "ru",
+	"id": "id",
+	"ar": "ar",
+	"el": "el",
+	"hi": "hi",
+	"th": "th",
+	"zh": "cjk",
+	"ja": "cjk",
+	"ko": "cjk",
+}
+
+// localizedTextFields are the work text fields also indexed per language
+var localizedTextFields = map[string]string{
+	"title":   "title_lang",
+	"summary": "summary_lang",
+}
+
+var languageCodePattern = regexp.MustCompile(`^[a-z]{2}$`)
+
+// languageCode reduces a language such as "pt-BR" to its lowercase code
+func languageCode(language string) string {
+	code := strings.ToLower(strings.TrimSpace(language))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// languageAnalysisKey picks the per-language field suffix for a language,
+// or "" when it has no analyzer of its own
+func languageAnalysisKey(language string) string {
+	return languageAnalysis[languageCode(language)]
+}
+
+// localizeWorkText fills in the title and summary under the analyzer for
+// the work's language
+func localizeWorkText(doc *WorkIndexDocument) {
+	key := languageAnalysisKey(doc.Language)
+	if key == "" {
+		key = otherLanguageAnalysis
+	}
+	doc.TitleLang = map[string]string{key: doc.Title}
+	doc.SummaryLang = nil
+	if doc.Summary != "" {
+		doc.SummaryLang = map[string]string{key: doc.Summary}
+	}
+}
+
+// localizedFields adds the per-language variants of title and summary to a
+// list of search fields, keeping any boost
+func localizedFields(fields []string) []string {
+	result := make([]string, 0, len(fields)+len(localizedTextFields))
+	for _, field := range fields {
+		result = append(result, field)
+		name, boost, _ := strings.Cut(field, "^")
+		if localized, ok := localizedTextFields[name]; ok {
+			if boost != "" {
+				result = append(result, localized+".*^"+boost)
+			} else {
+				result = append(result, localized+".*")
+			}
+		}
+	}
+	return result
+}
+
+// normalizePreferredLanguages lowercases and de-duplicates language codes,
+// rejecting anything that isn't a two-letter code
+func normalizePreferredLanguages(languages []string) ([]string, error) {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, value := range languages {
+		for _, part := range strings.Split(value, ",") {
+			code := languageCode(part)
+			if code == "" || seen[code] {
+				continue
+			}
+			if !languageCodePattern.MatchString(code) {
+				return nil, fmt.Errorf("invalid language code %q", strings.TrimSpace(part))
+			}
+			seen[code] = true
+			result = append(result, code)
+		}
+	}
+	if len(result) > maxPreferredLanguages {
+		return nil, fmt.Errorf("at most %d preferred languages are allowed", maxPreferredLanguages)
+	}
+	return result, nil
+}
+
+// withPreferredLanguages scores works in the preferred languages higher.
+// It only changes relevance, so sorting by any other field is unaffected.
+func withPreferredLanguages(query map[string]interface{}, languages []string) map[string]interface{} {
+	if len(languages) == 0 {
+		return query
+	}
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must": []map[string]interface{}{query},
+			"should": []map[string]interface{}{
+				{"terms": map[string]interface{}{"language": languages, "boost": preferredLanguageBoost}},
+			},
+			"minimum_should_match": 0,
+		},
+	}
+}
+
+// applyPreferredLanguages fills in the signed-in reader's stored preferred
+// languages when the search didn't name any
+func (ss *SearchService) applyPreferredLanguages(c *gin.Context, req *WorkSearchRequest) {
+	if len(req.PreferredLanguages) > 0 {
+		return
+	}
+	userID, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	prefs, err := ss.searchPreferences(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to load preferred languages for %s: %v", userID, err)
+		return
+	}
+	req.PreferredLanguages = prefs.PreferredLanguages
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocalizeWorkText(t *testing.T) {
+	doc := WorkIndexDocument{Title: "Le Petit Prince", Summary: "Un conte", Language: "fr"}
+	localizeWorkText(&doc)
+	if doc.TitleLang["fr"] != "Le Petit Prince" || doc.SummaryLang["fr"] != "Un conte" {
+		t.Errorf("expected French fields, got %v %v", doc.TitleLang, doc.SummaryLang)
+	}
+
+	doc = WorkIndexDocument{Title: "花", Language: "zh-TW"}
+	localizeWorkText(&doc)
+	if doc.TitleLang["cjk"] != "花" || doc.SummaryLang != nil {
+		t.Errorf("expected a CJK title and no summary, got %v %v", doc.TitleLang, doc.SummaryLang)
+	}
+
+	doc = WorkIndexDocument{Title: "Sen", Language: "tr"}
+	localizeWorkText(&doc)
+	if doc.TitleLang[otherLanguageAnalysis] != "Sen" {
+		t.Errorf("languages without an analyzer should use the ICU field, got %v", doc.TitleLang)
+	}
+}
+
+func TestLocalizedFields(t *testing.T) {
+	got := localizedFields([]string{"title^3", "summary", "fandoms"})
+	want := []string{"title^3", "title_lang.*^3", "summary", "summary_lang.*", "fandoms"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("localizedFields = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizePreferredLanguages(t *testing.T) {
+	got, err := normalizePreferredLanguages([]string{"EN, fr", "pt-BR", "en"})
+	if err != nil || !reflect.DeepEqual(got, []string{"en", "fr", "pt"}) {
+		t.Errorf("normalizePreferredLanguages = %v, %v", got, err)
+	}
+	if _, err := normalizePreferredLanguages([]string{"english"}); err == nil {
+		t.Error("expected an error for a name instead of a code")
+	}
+	if _, err := normalizePreferredLanguages([]string{"en,fr,de,es,it,pt"}); err == nil {
+		t.Error("expected an error for too many languages")
+	}
+}
+
+func TestWithPreferredLanguages(t *testing.T) {
+	base := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if got := withPreferredLanguages(base, nil); !reflect.DeepEqual(got, base) {
+		t.Error("the query should be unchanged without preferred languages")
+	}
+
+	boosted := withPreferredLanguages(base, []string{"es"})["bool"].(map[string]interface{})
+	if boosted["minimum_should_match"] != 0 {
+		t.Error("preferred languages must not filter results")
+	}
+	should := boosted["should"].([]map[string]interface{})
+	terms := should[0]["terms"].(map[string]interface{})
+	if !reflect.DeepEqual(terms["language"], []string{"es"}) || terms["boost"] != preferredLanguageBoost {
+		t.Errorf("unexpected boost clause %v", terms)
+	}
+}
+
+func TestLocalizedFieldQuery(t *testing.T) {
+	node, err := parseWorkQuery(`title:"petit prince"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := node.toES(nil)["multi_match"].(map[string]interface{})
+	if match["type"] != "phrase" || !reflect.DeepEqual(match["fields"], []string{"title", "title_lang.*"}) {
+		t.Errorf("unexpected title query %v", match)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -16,6 +17,12 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/database"
+	"nuclear-ao3/shared/metrics"
+	"nuclear-ao3/shared/middleware"
+	"nuclear-ao3/shared/mutes"
+	"nuclear-ao3/shared/schema"
 )
 
 func main() {
@@ -28,6 +35,21 @@ func main() {
 	searchService := NewSearchService()
 	defer searchService.Close()
 
+	// `main migrate [up|status|baseline N]` manages the shared schema and
+	// exits; otherwise pending migrations are applied before serving
+	if done, err := schema.Ready(context.Background(), searchService.db, os.Args[1:]); err != nil {
+		log.Fatal("Schema is not ready: ", err)
+	} else if done {
+		return
+	}
+
+	// Reindex works as work-service publishes their changes
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	go searchService.startWorkEventConsumer(consumerCtx)
+	go searchService.startSuggestionSync(consumerCtx)
+	go searchService.startSearchAlertScheduler(consumerCtx)
+	go searchService.startSearchAnalyticsWriter(consumerCtx)
+
 	// Setup router
 	router := setupRouter(searchService)
 
@@ -55,6 +77,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopConsumer()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -81,6 +104,9 @@ func setupRouter(searchService *SearchService) *gin.Engine {
 	r.Use(LoggingMiddleware())
 	r.Use(RateLimitMiddleware(searchService.redis))
 	r.Use(SecurityHeadersMiddleware())
+	// Searches and index updates don't write to the database, so they keep
+	// working during maintenance
+	r.Use(middleware.NewReadOnlyModeFromEnv().Middleware("/api/v1/search/", "/api/v1/index/"))
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
@@ -102,6 +128,15 @@ func setupRouter(searchService *SearchService) *gin.Engine {
 	// Metrics endpoint
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Searches have their own budget on top of the service-wide limit, with a
+	// bigger one for signed-in readers
+	limits := middleware.NewRouteRateLimiter(searchService.redis, "search-service", middleware.RateLimitPoliciesFromEnv())
+	limits.Identify = func(c *gin.Context) (string, bool) {
+		userID, ok := requestUserID(c)
+		return userID.String(), ok
+	}
+	searchLimit := limits.Limit(middleware.RateLimitSearch)
+
 	// API endpoints
 	api := r.Group("/api/v1")
 	{
@@ -109,31 +144,41 @@ func setupRouter(searchService *SearchService) *gin.Engine {
 		search := api.Group("/search")
 		{
 			// General search
-			search.GET("/works", searchService.SearchWorks)             // GET /api/v1/search/works?q=harry+potter
-			search.GET("/tags", searchService.SearchTags)               // GET /api/v1/search/tags?q=angst
-			search.GET("/users", searchService.SearchUsers)             // GET /api/v1/search/users?q=author_name
-			search.GET("/collections", searchService.SearchCollections) // GET /api/v1/search/collections?q=prompt_fest
-			search.GET("/series", searchService.SearchSeries)           // GET /api/v1/search/series?q=trilogy
+			search.GET("/works", searchLimit, searchService.SearchWorks)             // GET /api/v1/search/works?q=harry+potter
+			search.GET("/tags", searchLimit, searchService.SearchTags)               // GET /api/v1/search/tags?q=angst
+			search.GET("/users", searchLimit, searchService.SearchUsers)             // GET /api/v1/search/users?q=author_name
+			search.GET("/collections", searchLimit, searchService.SearchCollections) // GET /api/v1/search/collections?q=prompt_fest
+			search.GET("/series", searchLimit, searchService.SearchSeries)           // GET /api/v1/search/series?q=trilogy
 
 			// Advanced/filtered search
-			search.POST("/works/advanced", searchService.AdvancedWorkSearch) // POST /api/v1/search/works/advanced
-			search.POST("/tags/advanced", searchService.AdvancedTagSearch)   // POST /api/v1/search/tags/advanced
+			search.POST("/works/advanced", searchLimit, searchService.AdvancedWorkSearch) // POST /api/v1/search/works/advanced
+			search.POST("/tags/advanced", searchLimit, searchService.AdvancedTagSearch)   // POST /api/v1/search/tags/advanced
 
 			// Enhanced smart filtering (Task 3)
-			search.POST("/works/smart", searchService.SmartFilteredSearch)   // POST /api/v1/search/works/smart
-			search.POST("/facets/smart", searchService.GetSmartFacets)       // POST /api/v1/search/facets/smart
-			search.POST("/quality/analyze", searchService.AnalyzeTagQuality) // POST /api/v1/search/quality/analyze
+			search.POST("/works/smart", searchLimit, searchService.SmartFilteredSearch) // POST /api/v1/search/works/smart
+			search.POST("/facets/smart", searchLimit, searchService.GetSmartFacets)     // POST /api/v1/search/facets/smart
+			search.POST("/quality/analyze", searchService.AnalyzeTagQuality)            // POST /api/v1/search/quality/analyze
 
 			// Autocomplete/suggestions
 			search.GET("/suggestions", searchService.GetSuggestions)   // GET /api/v1/search/suggestions?q=har
 			search.GET("/popular", searchService.GetPopularSearches)   // GET /api/v1/search/popular
 			search.GET("/trending", searchService.GetTrendingSearches) // GET /api/v1/search/trending
+			search.POST("/clicks", searchService.RecordSearchClick)    // POST /api/v1/search/clicks
+
+			// Engagement listings
+			search.GET("/works/most-bookmarked", searchLimit, searchService.MostBookmarkedWorks) // GET /api/v1/search/works/most-bookmarked?fandom=Good+Omens&period=month
+			search.GET("/bookmarks", searchLimit, searchService.SearchBookmarks)                 // GET /api/v1/search/bookmarks?q=slow+burn&tag=comfort+read
+
+			// Reader search preferences
+			search.GET("/preferences", searchService.GetSearchPreferences)    // GET /api/v1/search/preferences
+			search.PUT("/preferences", searchService.UpdateSearchPreferences) // PUT /api/v1/search/preferences
 		}
 
 		// Indexing operations (internal/admin only)
 		index := api.Group("/index")
-		index.Use(JWTAuthMiddleware())
-		index.Use(RequireRoleMiddleware("admin", "indexer"))
+		index.Use(IndexerAuthMiddleware())
+		index.Use(middleware.RequireRoleMiddleware("admin", "indexer"))
+		index.Use(middleware.RequireScopeMiddleware(middleware.ScopeSearchIndex))
 		{
 			// Enhanced work indexing operations
 			index.POST("/works", searchService.EnhancedIndexWork)           // POST /api/v1/index/works
@@ -141,6 +186,17 @@ func setupRouter(searchService *SearchService) *gin.Engine {
 			index.DELETE("/works/:id", searchService.DeleteWorkFromIndex)   // DELETE /api/v1/index/works/123
 			index.POST("/works/bulk", searchService.EnhancedBulkIndexWorks) // POST /api/v1/index/works/bulk
 
+			// Chapter content indexing
+			index.PUT("/chapters/:id", searchService.IndexChapter)              // PUT /api/v1/index/chapters/123
+			index.DELETE("/chapters/:id", searchService.DeleteChapterFromIndex) // DELETE /api/v1/index/chapters/123
+
+			// Engagement events from the work service
+			index.POST("/events/bookmarks", searchService.ProcessBookmarkEvent) // POST /api/v1/index/events/bookmarks
+
+			// Work events the reindexing consumer gave up on
+			index.GET("/events/dead-letters", searchService.GetWorkEventDeadLetters)            // GET /api/v1/index/events/dead-letters
+			index.POST("/events/dead-letters/replay", searchService.ReplayWorkEventDeadLetters) // POST /api/v1/index/events/dead-letters/replay
+
 			// Legacy tag indexing (to be enhanced)
 			index.POST("/tags", searchService.IndexTag)                 // POST /api/v1/index/tags
 			index.PUT("/tags/:tag_id", searchService.UpdateTagIndex)    // PUT /api/v1/index/tags/123
@@ -164,7 +220,8 @@ func setupRouter(searchService *SearchService) *gin.Engine {
 		// Analytics and insights
 		analytics := api.Group("/analytics")
 		analytics.Use(JWTAuthMiddleware())
-		analytics.Use(RequireRoleMiddleware("admin"))
+		analytics.Use(middleware.RequireRoleMiddleware("admin"))
+		analytics.Use(middleware.RequireScopeMiddleware(middleware.ScopeAdmin))
 		{
 			// Legacy analytics endpoints
 			analytics.GET("/search-stats", searchService.GetSearchStats)      // GET /api/v1/analytics/search-stats
@@ -212,6 +269,28 @@ type SearchService struct {
 	db    *sql.DB
 	redis *redis.Client
 	es    *elasticsearch.Client
+
+	// workEventErrors keeps the last failure of each pending work event,
+	// for the dead-letter record
+	workEventErrors sync.Map
+	// rebuildTarget is the index of a running rebuild, which work events
+	// are also written to
+	rebuildTarget rebuildTargetCache
+
+	// analyticsEvents buffers anonymized search events for the analytics
+	// writer, sampled and thresholded as analyticsConfig says
+	analyticsEvents chan searchEvent
+	analyticsConfig searchAnalyticsConfig
+
+	// budgets bounds each stage of a work search
+	budgets searchBudgets
+
+	// mutes looks up who readers have muted
+	mutes *mutes.Filter
+
+	// reads routes highlighting, personalization and suggestion reads to
+	// DATABASE_REPLICA_URL
+	reads *database.Replica
 }
 
 func NewSearchService() *SearchService {
@@ -227,10 +306,14 @@ func NewSearchService() *SearchService {
 		log.Fatal("Failed to ping database:", err)
 	}
 
-	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
+	// Set connection pool settings (DB_MAX_OPEN_CONNS etc. override)
+	pool := database.PoolConfig{
+		MaxOpenConns:    25,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: time.Hour,
+	}
+	database.PoolConfigFromEnv(pool).Apply(db)
+	metrics.InstrumentDBPool("search-service", db)
 
 	// Redis connection
 	redisURL := getEnv("REDIS_URL", "localhost:6379")
@@ -275,13 +358,30 @@ func NewSearchService() *SearchService {
 	log.Println("Search service initialized successfully")
 
 	return &SearchService{
-		db:    db,
-		redis: rdb,
-		es:    es,
+		db:              db,
+		redis:           rdb,
+		es:              es,
+		analyticsEvents: make(chan searchEvent, analyticsBufferSize),
+		analyticsConfig: searchAnalyticsConfigFromEnv(),
+		budgets:         searchBudgetsFromEnv(),
+		mutes:           mutes.NewFilter(db, rdb, muteFilterTTLFromEnv()),
+		reads:           database.OpenReplica(context.Background(), "search-service", db, pool),
 	}
 }
 
+// readDB is the database for reads that may lag behind writes: the read
+// replica while it is usable, and the primary otherwise
+func (ss *SearchService) readDB() *sql.DB {
+	if ss.reads == nil {
+		return ss.db
+	}
+	return ss.reads.Reader()
+}
+
 func (ss *SearchService) Close() {
+	if ss.reads != nil {
+		ss.reads.Close()
+	}
 	if ss.db != nil {
 		ss.db.Close()
 	}
@@ -347,16 +447,21 @@ func LoggingMiddleware() gin.HandlerFunc {
 	return gin.Logger()
 }
 
+// indexerIdentity is who internal callers presenting the service token,
+// such as work-service pushing chapters and bookmark events, act as
+var indexerIdentity = &middleware.Identity{
+	Roles:  []string{"indexer"},
+	Scopes: []string{middleware.ScopeSearchIndex},
+}
+
+// JWTAuthMiddleware validates the bearer token with auth-service, which
+// also reports the user's roles and scopes for the analytics routes
 func JWTAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// JWT validation - would integrate with auth service
-		c.Next()
-	}
+	return middleware.AuthServiceMiddleware(getEnv("AUTH_SERVICE_URL", "http://localhost:8081"), nil)
 }
 
-func RequireRoleMiddleware(roles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Role validation - would integrate with auth service
-		c.Next()
-	}
+// IndexerAuthMiddleware is JWTAuthMiddleware for the index routes, which
+// internal callers may also reach with the service token
+func IndexerAuthMiddleware() gin.HandlerFunc {
+	return middleware.AuthServiceMiddleware(getEnv("AUTH_SERVICE_URL", "http://localhost:8081"), indexerIdentity)
 }
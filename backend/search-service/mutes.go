@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/mutes"
+)
+
+// =============================================================================
+// MUTED USERS
+// Work searches leave out works by users the reader has muted, as a terms
+// exclusion on author_ids. Anonymous works are indexed without authors, so
+// they stay visible. Mute lists are cached here for muteFilterTTL;
+// work-service, where mutes change, can't reach this cache, so a new mute
+// takes up to that long to show in search.
+// =============================================================================
+
+const defaultMuteFilterTTL = time.Minute
+
+// muteFilterTTLFromEnv reads MUTE_FILTER_TTL
+func muteFilterTTLFromEnv() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("MUTE_FILTER_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return defaultMuteFilterTTL
+}
+
+// applyMutedUsers excludes works by users the signed-in reader muted
+func (ss *SearchService) applyMutedUsers(c *gin.Context, req *WorkSearchRequest) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	ss.applyMutedUsersOf(c.Request.Context(), userID, req)
+}
+
+// applyMutedUsersOf excludes works by users userID muted
+func (ss *SearchService) applyMutedUsersOf(ctx context.Context, userID uuid.UUID, req *WorkSearchRequest) {
+	muted, err := ss.mutes.MutedUserIDs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load muted users for %s: %v", userID, err)
+		return
+	}
+	req.mutedUserIDs = mutes.Strings(muted)
+}
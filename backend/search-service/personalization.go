@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// PERSONALIZED RANKING
+// Readers who opt in get works from the fandoms and tags they read most
+// ranked higher, using the reading history the work service keeps. Like the
+// language boost it only reorders: nothing is hidden, and anonymous readers
+// and readers who haven't opted in always get neutral ranking. A search can
+// ask for either mode with ranking=personalized or ranking=neutral.
+// =============================================================================
+
+const (
+	rankingPersonalized = "personalized"
+	rankingNeutral      = "neutral"
+
+	// readingAffinityWindow is how far back reading history counts
+	readingAffinityWindow = "180 days"
+	// maxAffinityTags caps the tags boosted per field
+	maxAffinityTags = 10
+	// minAffinityReads is how many works with a tag a reader must have read
+	// for it to count as one they read frequently
+	minAffinityReads = 2
+	// readingAffinitiesCacheTTL is how long derived affinities are cached.
+	// The work service drops the cache when a reader clears their history.
+	readingAffinitiesCacheTTL = 30 * time.Minute
+)
+
+// affinityBoosts weights each tag field's boost; a shared fandom says more
+// about what a reader wants than a shared freeform tag
+var affinityBoosts = []struct {
+	field string
+	boost float64
+}{
+	{"fandoms", 1.5},
+	{"relationships", 1.0},
+	{"characters", 0.5},
+	{"freeform_tags", 0.5},
+}
+
+// readingAffinities maps a tag field to the tags a reader reads most in it
+type readingAffinities map[string][]string
+
+func (a readingAffinities) empty() bool {
+	for _, tags := range a {
+		if len(tags) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRanking checks a requested ranking mode; "" defers to the
+// reader's preference
+func validateRanking(ranking string) error {
+	switch ranking {
+	case "", rankingPersonalized, rankingNeutral:
+		return nil
+	}
+	return fmt.Errorf("ranking must be %q or %q", rankingPersonalized, rankingNeutral)
+}
+
+func readingAffinitiesCacheKey(userID uuid.UUID) string {
+	return "search_reading_affinities:" + userID.String()
+}
+
+// loadReadingAffinities finds the fandoms, relationships, characters and
+// freeform tags of the works in a reader's recent history, keeping those
+// read at least minAffinityReads times, most read first
+func (ss *SearchService) loadReadingAffinities(ctx context.Context, userID uuid.UUID) (readingAffinities, error) {
+	key := readingAffinitiesCacheKey(userID)
+	if ss.redis != nil {
+		if cached, err := ss.redis.Get(ctx, key).Result(); err == nil {
+			var affinities readingAffinities
+			if json.Unmarshal([]byte(cached), &affinities) == nil {
+				return affinities, nil
+			}
+		}
+	}
+
+	rows, err := ss.readDB().QueryContext(ctx, `
+		SELECT field, tag FROM (
+			SELECT t.field, t.tag, COUNT(*) AS reads,
+				ROW_NUMBER() OVER (PARTITION BY t.field ORDER BY COUNT(*) DESC, t.tag) AS rank
+			FROM reading_history h
+			JOIN works w ON w.id = h.work_id
+			CROSS JOIN LATERAL (
+				SELECT 'fandoms', unnest(w.fandoms)
+				UNION ALL SELECT 'relationships', unnest(w.relationships)
+				UNION ALL SELECT 'characters', unnest(w.characters)
+				UNION ALL SELECT 'freeform_tags', unnest(w.freeform_tags)
+			) AS t(field, tag)
+			WHERE h.user_id = $1 AND h.last_read_at > NOW() - $2::interval
+			GROUP BY t.field, t.tag
+		) ranked
+		WHERE reads >= $3 AND rank <= $4
+		ORDER BY field, rank`, userID, readingAffinityWindow, minAffinityReads, maxAffinityTags)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	affinities := readingAffinities{}
+	for rows.Next() {
+		var field, tag string
+		if err := rows.Scan(&field, &tag); err != nil {
+			return nil, err
+		}
+		affinities[field] = append(affinities[field], tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if ss.redis != nil {
+		if data, err := json.Marshal(affinities); err == nil {
+			ss.redis.Set(ctx, key, data, readingAffinitiesCacheTTL)
+		}
+	}
+	return affinities, nil
+}
+
+// applyPersonalizedRanking loads the reading affinities of a signed-in
+// reader who opted in, by preference or for this search
+func (ss *SearchService) applyPersonalizedRanking(c *gin.Context, req *WorkSearchRequest) {
+	if req.Ranking == rankingNeutral {
+		return
+	}
+	userID, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	if req.Ranking == "" {
+		prefs, err := ss.searchPreferences(ctx, userID)
+		if err != nil {
+			log.Printf("Failed to load search preferences for %s: %v", userID, err)
+			return
+		}
+		if !prefs.PersonalizedRanking {
+			return
+		}
+	}
+
+	affinities, err := ss.loadReadingAffinities(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load reading affinities for %s: %v", userID, err)
+		return
+	}
+	req.readingAffinities = affinities
+}
+
+// rankingMode reports which ranking a search got
+func (req WorkSearchRequest) rankingMode() string {
+	if req.readingAffinities.empty() {
+		return rankingNeutral
+	}
+	return rankingPersonalized
+}
+
+// withReadingAffinities scores works sharing the reader's most read tags
+// higher. It only changes relevance, so sorting by any other field is
+// unaffected.
+func withReadingAffinities(query map[string]interface{}, affinities readingAffinities) map[string]interface{} {
+	if affinities.empty() {
+		return query
+	}
+
+	should := []map[string]interface{}{}
+	for _, a := range affinityBoosts {
+		if tags := affinities[a.field]; len(tags) > 0 {
+			should = append(should, map[string]interface{}{
+				"terms": map[string]interface{}{a.field: tags, "boost": a.boost},
+			})
+		}
+	}
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":                 []map[string]interface{}{query},
+			"should":               should,
+			"minimum_should_match": 0,
+		},
+	}
+}
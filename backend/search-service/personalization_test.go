@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateRanking(t *testing.T) {
+	for _, ranking := range []string{"", rankingPersonalized, rankingNeutral} {
+		if err := validateRanking(ranking); err != nil {
+			t.Errorf("validateRanking(%q) = %v", ranking, err)
+		}
+	}
+	if validateRanking("popular") == nil {
+		t.Error("expected an error for an unknown ranking mode")
+	}
+}
+
+func TestWithReadingAffinities(t *testing.T) {
+	base := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if got := withReadingAffinities(base, nil); !reflect.DeepEqual(got, base) {
+		t.Error("the query should be unchanged without affinities")
+	}
+	if got := withReadingAffinities(base, readingAffinities{"fandoms": {}}); !reflect.DeepEqual(got, base) {
+		t.Error("the query should be unchanged when no field has tags")
+	}
+
+	boosted := withReadingAffinities(base, readingAffinities{
+		"freeform_tags": {"fluff"},
+		"fandoms":       {"star trek", "good omens"},
+	})["bool"].(map[string]interface{})
+	if boosted["minimum_should_match"] != 0 {
+		t.Error("affinities must not filter results")
+	}
+	should := boosted["should"].([]map[string]interface{})
+	if len(should) != 2 {
+		t.Fatalf("expected a clause per field with tags, got %v", should)
+	}
+	fandoms := should[0]["terms"].(map[string]interface{})
+	if !reflect.DeepEqual(fandoms["fandoms"], []string{"star trek", "good omens"}) || fandoms["boost"] != 1.5 {
+		t.Errorf("unexpected fandom clause %v", fandoms)
+	}
+	if _, ok := should[1]["terms"].(map[string]interface{})["freeform_tags"]; !ok {
+		t.Errorf("expected the freeform clause second, got %v", should[1])
+	}
+}
+
+func TestRankingMode(t *testing.T) {
+	if mode := (WorkSearchRequest{}).rankingMode(); mode != rankingNeutral {
+		t.Errorf("a search without affinities is %q, want neutral", mode)
+	}
+	req := WorkSearchRequest{readingAffinities: readingAffinities{"relationships": {"Aziraphale/Crowley"}}}
+	if mode := req.rankingMode(); mode != rankingPersonalized {
+		t.Errorf("a search with affinities is %q, want personalized", mode)
+	}
+}
@@ -0,0 +1,734 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Work search query language. The q parameter accepts, besides plain text:
+//
+//	fandom:"Harry Potter"        field prefixes, quoted when the value has spaces
+//	-tag:angst, NOT tag:angst    exclusion
+//	a OR b, a AND b, (a OR b) c  boolean operators and grouping; AND is implied
+//	words:>50000, kudos:100..500 comparisons and ranges on numbers
+//	updated:>=2024-01-01         and on dates
+//	complete:true                flags
+//
+// Plain text queries are searched exactly as before.
+
+const maxQueryDepth = 20
+
+type queryFieldKind int
+
+const (
+	keywordQueryField queryFieldKind = iota
+	textQueryField
+	numberQueryField
+	dateQueryField
+	boolQueryField
+)
+
+// queryField is a field prefix the query language knows
+type queryField struct {
+	name    string // canonical prefix, echoed back in parsed
+	esField string
+	kind    queryFieldKind
+	// expand marks tag fields widened to synonyms and sub-tags
+	expand bool
+}
+
+var queryFields = map[string]queryField{
+	"fandom":       {"fandom", "fandoms", keywordQueryField, true},
+	"character":    {"character", "characters", keywordQueryField, true},
+	"relationship": {"relationship", "relationships", keywordQueryField, true},
+	"ship":         {"relationship", "relationships", keywordQueryField, true},
+	"tag":          {"tag", "freeform_tags", keywordQueryField, true},
+	"rating":       {"rating", "rating", keywordQueryField, false},
+	"warning":      {"warning", "warnings", keywordQueryField, false},
+	"category":     {"category", "categories", keywordQueryField, false},
+	"language":     {"language", "language", keywordQueryField, false},
+	"title":        {"title", "title", textQueryField, false},
+	"summary":      {"summary", "summary", textQueryField, false},
+	"author":       {"author", "author", textQueryField, false},
+	"words":        {"words", "word_count", numberQueryField, false},
+	"chapters":     {"chapters", "chapter_count", numberQueryField, false},
+	"kudos":        {"kudos", "kudos", numberQueryField, false},
+	"hits":         {"hits", "hits", numberQueryField, false},
+	"comments":     {"comments", "comments", numberQueryField, false},
+	"bookmarks":    {"bookmarks", "bookmarks", numberQueryField, false},
+	"published":    {"published", workDateFields["published"], dateQueryField, false},
+	"updated":      {"updated", workDateFields["updated"], dateQueryField, false},
+	"complete":     {"complete", "is_complete", boolQueryField, false},
+}
+
+// workTextFields are the fields free text is matched against
+var workTextFields = localizedFields([]string{"title^3", "summary^2", "content_text", "fandoms", "characters", "relationships", "freeform_tags"})
+
+// QueryParseError points at the character of q the parser gave up on
+type QueryParseError struct {
+	Position int    `json:"position"` // 1-based character offset
+	Message  string `json:"message"`
+}
+
+func (e *QueryParseError) Error() string {
+	return fmt.Sprintf("%s (at character %d)", e.Message, e.Position)
+}
+
+type queryNodeKind int
+
+const (
+	textQueryNode queryNodeKind = iota
+	fieldQueryNode
+	andQueryNode
+	orQueryNode
+	notQueryNode
+)
+
+// queryNode is one node of a parsed query
+type queryNode struct {
+	kind   queryNodeKind
+	field  queryField
+	op     string // "", ">", ">=", "<", "<=" or ".."
+	value  string // text, field value, or lower bound of a range
+	upper  string // upper bound of a range
+	phrase bool
+	// values is what a tag field matches once expanded
+	values   []string
+	children []*queryNode
+}
+
+// parseWorkQuery parses q into a query tree
+func parseWorkQuery(q string) (*queryNode, error) {
+	tokens, err := lexWorkQuery([]rune(q))
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	node, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != eofToken {
+		if tok.kind == rparenToken {
+			return nil, &QueryParseError{tok.pos, "unmatched ')'"}
+		}
+		return nil, &QueryParseError{tok.pos, fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	return node, nil
+}
+
+// parseRequestQuery parses the request's q, leaving the tree for the query
+// builder
+func parseRequestQuery(req *WorkSearchRequest) error {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil
+	}
+	node, err := parseWorkQuery(req.Query)
+	if err != nil {
+		return err
+	}
+	req.parsedQuery = node
+	return nil
+}
+
+// queryParseErrorResponse is the 400 body for a query that doesn't parse
+func queryParseErrorResponse(query string, err error) map[string]interface{} {
+	var perr *QueryParseError
+	if errors.As(err, &perr) {
+		return map[string]interface{}{
+			"error":    "Invalid search query",
+			"details":  perr.Message,
+			"position": perr.Position,
+			"query":    query,
+		}
+	}
+	return map[string]interface{}{"error": "Invalid search query", "details": err.Error(), "query": query}
+}
+
+// =============================================================================
+// LEXER
+// =============================================================================
+
+type queryTokenKind int
+
+const (
+	eofToken queryTokenKind = iota
+	wordToken
+	phraseToken
+	fieldToken
+	notToken
+	lparenToken
+	rparenToken
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	text string
+	pos  int
+}
+
+func lexWorkQuery(q []rune) ([]queryToken, error) {
+	tokens := []queryToken{}
+	i := 0
+	for i < len(q) {
+		r := q[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{lparenToken, "(", i + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{rparenToken, ")", i + 1})
+			i++
+		case r == '-':
+			if i+1 >= len(q) || unicode.IsSpace(q[i+1]) {
+				return nil, &QueryParseError{i + 1, "'-' must be followed by the term to exclude"}
+			}
+			tokens = append(tokens, queryToken{notToken, "-", i + 1})
+			i++
+		case r == '"':
+			phrase, next, err := lexPhrase(q, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{phraseToken, phrase, i + 1})
+			i = next
+		default:
+			start := i
+			for i < len(q) && !isQueryDelimiter(q[i]) && q[i] != ':' {
+				i++
+			}
+			if i < len(q) && q[i] == ':' && isFieldName(q[start:i]) {
+				name := strings.ToLower(string(q[start:i]))
+				field, ok := queryFields[name]
+				if !ok {
+					return nil, &QueryParseError{start + 1, fmt.Sprintf(
+						"unknown field %q; known fields are %s, or quote the term to search for it as text",
+						name, strings.Join(queryFieldNames(), ", "))}
+				}
+				tokens = append(tokens, queryToken{fieldToken, field.name, start + 1})
+				i++
+
+				// The value follows the colon directly
+				switch {
+				case i >= len(q) || unicode.IsSpace(q[i]) || q[i] == '(' || q[i] == ')':
+					return nil, &QueryParseError{i + 1, fmt.Sprintf("missing value after %s:", name)}
+				case q[i] == '"':
+					phrase, next, err := lexPhrase(q, i)
+					if err != nil {
+						return nil, err
+					}
+					tokens = append(tokens, queryToken{phraseToken, phrase, i + 1})
+					i = next
+				default:
+					valueStart := i
+					for i < len(q) && !isQueryDelimiter(q[i]) {
+						i++
+					}
+					tokens = append(tokens, queryToken{wordToken, string(q[valueStart:i]), valueStart + 1})
+				}
+				continue
+			}
+			// Not a field: colons are part of the word
+			for i < len(q) && !isQueryDelimiter(q[i]) {
+				i++
+			}
+			tokens = append(tokens, queryToken{wordToken, string(q[start:i]), start + 1})
+		}
+	}
+	return append(tokens, queryToken{eofToken, "", len(q) + 1}), nil
+}
+
+// lexPhrase reads the quoted phrase opening at q[start], returning it and
+// the index just past the closing quote
+func lexPhrase(q []rune, start int) (string, int, error) {
+	for i := start + 1; i < len(q); i++ {
+		if q[i] == '"' {
+			return string(q[start+1 : i]), i + 1, nil
+		}
+	}
+	return "", 0, &QueryParseError{start + 1, "unterminated quote"}
+}
+
+func isQueryDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"'
+}
+
+func isFieldName(name []rune) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// queryFieldNames lists the field prefixes for error messages
+func queryFieldNames() []string {
+	names := make([]string, 0, len(queryFields))
+	for name := range queryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// =============================================================================
+// PARSER
+// =============================================================================
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != eofToken {
+		p.pos++
+	}
+	return tok
+}
+
+func isOperator(tok queryToken, op string) bool {
+	return tok.kind == wordToken && tok.text == op
+}
+
+// parseOr: and ( OR and )*
+func (p *queryParser) parseOr(depth int) (*queryNode, error) {
+	if depth > maxQueryDepth {
+		return nil, &QueryParseError{p.peek().pos, "query is nested too deeply"}
+	}
+	first, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	children := []*queryNode{first}
+	for isOperator(p.peek(), "OR") {
+		p.next()
+		next, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &queryNode{kind: orQueryNode, children: children}, nil
+}
+
+// parseAnd: unary ( [AND] unary )*
+func (p *queryParser) parseAnd(depth int) (*queryNode, error) {
+	children := []*queryNode{}
+	for {
+		tok := p.peek()
+		if tok.kind == eofToken || tok.kind == rparenToken || isOperator(tok, "OR") {
+			break
+		}
+		if isOperator(tok, "AND") {
+			if len(children) == 0 {
+				return nil, &QueryParseError{tok.pos, "AND needs a term on each side"}
+			}
+			p.next()
+			if after := p.peek(); after.kind == eofToken || after.kind == rparenToken || isOperator(after, "OR") || isOperator(after, "AND") {
+				return nil, &QueryParseError{after.pos, "AND needs a term on each side"}
+			}
+			continue
+		}
+		child, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	if len(children) == 0 {
+		tok := p.peek()
+		switch {
+		case isOperator(tok, "OR"):
+			return nil, &QueryParseError{tok.pos, "OR needs a term on each side"}
+		case tok.kind == rparenToken:
+			return nil, &QueryParseError{tok.pos, "empty parentheses or unmatched ')'"}
+		default:
+			return nil, &QueryParseError{tok.pos, "expected a search term"}
+		}
+	}
+	children = mergeTextNodes(children)
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &queryNode{kind: andQueryNode, children: children}, nil
+}
+
+// parseUnary: ( - | NOT ) unary | primary
+func (p *queryParser) parseUnary(depth int) (*queryNode, error) {
+	tok := p.peek()
+	if tok.kind == notToken || isOperator(tok, "NOT") {
+		p.next()
+		if after := p.peek(); after.kind == eofToken || after.kind == rparenToken || isOperator(after, "OR") || isOperator(after, "AND") {
+			return nil, &QueryParseError{after.pos, "expected a term to exclude after " + tok.text}
+		}
+		if depth+1 > maxQueryDepth {
+			return nil, &QueryParseError{tok.pos, "query is nested too deeply"}
+		}
+		child, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &queryNode{kind: notQueryNode, children: []*queryNode{child}}, nil
+	}
+	return p.parsePrimary(depth)
+}
+
+// parsePrimary: ( or ) | field value | word | phrase
+func (p *queryParser) parsePrimary(depth int) (*queryNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case lparenToken:
+		node, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != rparenToken {
+			return nil, &QueryParseError{tok.pos, "missing closing ')'"}
+		}
+		p.next()
+		return node, nil
+	case fieldToken:
+		value := p.next()
+		return parseFieldValue(queryFields[tok.text], value)
+	case wordToken:
+		return &queryNode{kind: textQueryNode, value: tok.text}, nil
+	case phraseToken:
+		if strings.TrimSpace(tok.text) == "" {
+			return nil, &QueryParseError{tok.pos, "empty quotes"}
+		}
+		return &queryNode{kind: textQueryNode, value: tok.text, phrase: true}, nil
+	case rparenToken:
+		return nil, &QueryParseError{tok.pos, "unmatched ')'"}
+	default:
+		return nil, &QueryParseError{tok.pos, "expected a search term"}
+	}
+}
+
+// mergeTextNodes folds the plain words of an AND group into one free text
+// node, so "hermione time travel words:>1000" matches the words the way a
+// plain query would
+func mergeTextNodes(children []*queryNode) []*queryNode {
+	merged := []*queryNode{}
+	var text *queryNode
+	for _, child := range children {
+		if child.kind == textQueryNode && !child.phrase {
+			if text == nil {
+				text = &queryNode{kind: textQueryNode, value: child.value}
+				merged = append(merged, text)
+			} else {
+				text.value += " " + child.value
+			}
+			continue
+		}
+		merged = append(merged, child)
+	}
+	return merged
+}
+
+// parseFieldValue checks a field's value against the field's kind
+func parseFieldValue(field queryField, tok queryToken) (*queryNode, error) {
+	node := &queryNode{kind: fieldQueryNode, field: field, value: tok.text, phrase: tok.kind == phraseToken}
+	if strings.TrimSpace(tok.text) == "" {
+		return nil, &QueryParseError{tok.pos, fmt.Sprintf("missing value after %s:", field.name)}
+	}
+
+	switch field.kind {
+	case numberQueryField, dateQueryField:
+		node.op, node.value, node.upper = splitComparison(tok.text)
+		bounds := []string{node.value, node.upper}
+		if node.op != ".." {
+			bounds = bounds[:1]
+		} else if node.value == "" && node.upper == "" {
+			return nil, &QueryParseError{tok.pos, fmt.Sprintf("%s: range needs at least one bound", field.name)}
+		}
+		for i, bound := range bounds {
+			if bound == "" {
+				if node.op == ".." {
+					continue
+				}
+				return nil, &QueryParseError{tok.pos, fmt.Sprintf("%s: missing value after %s", field.name, node.op)}
+			}
+			if field.kind == numberQueryField {
+				n, err := parseQueryNumber(bound)
+				if err != nil {
+					return nil, &QueryParseError{tok.pos, fmt.Sprintf("%s: expected a number like 5000 or 5k, got %q", field.name, bound)}
+				}
+				bounds[i] = strconv.Itoa(n)
+			} else if _, err := parseSearchDate(bound); err != nil {
+				return nil, &QueryParseError{tok.pos, fmt.Sprintf("%s: expected a date like 2024-01-31, got %q", field.name, bound)}
+			}
+		}
+		node.value = bounds[0]
+		if node.op == ".." {
+			node.upper = bounds[1]
+		}
+	case boolQueryField:
+		switch strings.ToLower(tok.text) {
+		case "true", "yes":
+			node.value = "true"
+		case "false", "no":
+			node.value = "false"
+		default:
+			return nil, &QueryParseError{tok.pos, fmt.Sprintf("%s: expected true or false, got %q", field.name, tok.text)}
+		}
+	}
+	return node, nil
+}
+
+// splitComparison splits ">=5000" into (">=", "5000", "") and "1..5" into
+// ("..", "1", "5")
+func splitComparison(value string) (op, lower, upper string) {
+	for _, prefix := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(value, prefix) {
+			return prefix, strings.TrimPrefix(value, prefix), ""
+		}
+	}
+	if i := strings.Index(value, ".."); i >= 0 {
+		return "..", value[:i], value[i+2:]
+	}
+	return "", value, ""
+}
+
+// parseQueryNumber reads a count, allowing a k suffix for thousands
+func parseQueryNumber(value string) (int, error) {
+	multiplier := 1
+	if strings.HasSuffix(strings.ToLower(value), "k") {
+		multiplier = 1000
+		value = value[:len(value)-1]
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(value, ",", ""))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return n * multiplier, nil
+}
+
+// =============================================================================
+// TRANSLATION
+// =============================================================================
+
+// isPlainText reports whether the query is free text only, searched the way
+// q always has been
+func (n *queryNode) isPlainText() bool {
+	return n.kind == textQueryNode && !n.phrase
+}
+
+// freeText is the text the query searches for, leaving out field terms and
+// anything excluded; content search looks for it in chapter text
+func (n *queryNode) freeText() string {
+	switch n.kind {
+	case textQueryNode:
+		return n.value
+	case andQueryNode, orQueryNode:
+		parts := []string{}
+		for _, child := range n.children {
+			if text := child.freeText(); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
+
+// tagFieldNodes lists the field terms whose values are expanded like tag
+// filters
+func (n *queryNode) tagFieldNodes() []*queryNode {
+	if n.kind == fieldQueryNode {
+		if n.field.expand {
+			return []*queryNode{n}
+		}
+		return nil
+	}
+	nodes := []*queryNode{}
+	for _, child := range n.children {
+		nodes = append(nodes, child.tagFieldNodes()...)
+	}
+	return nodes
+}
+
+var rangeOperators = map[string]string{">": "gt", ">=": "gte", "<": "lt", "<=": "lte"}
+
+// toES translates the node to Elasticsearch query DSL. Works whose chapter
+// text matched count as free text matches.
+func (n *queryNode) toES(contentMatches map[string][]contentMatch) map[string]interface{} {
+	switch n.kind {
+	case textQueryNode:
+		textQuery := map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":    n.value,
+				"fields":   workTextFields,
+				"type":     "best_fields",
+				"operator": "or",
+			},
+		}
+		if n.phrase {
+			textQuery["multi_match"].(map[string]interface{})["type"] = "phrase"
+			delete(textQuery["multi_match"].(map[string]interface{}), "operator")
+		}
+		if len(contentMatches) > 0 {
+			textQuery = map[string]interface{}{
+				"bool": map[string]interface{}{
+					"should": []map[string]interface{}{
+						textQuery,
+						{"ids": map[string]interface{}{"values": contentMatchedWorkIDs(contentMatches)}},
+					},
+					"minimum_should_match": 1,
+				},
+			}
+		}
+		return textQuery
+
+	case fieldQueryNode:
+		return n.fieldQuery()
+
+	case notQueryNode:
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must_not": []map[string]interface{}{n.children[0].toES(nil)},
+			},
+		}
+	}
+
+	clauses := []map[string]interface{}{}
+	for _, child := range n.children {
+		clauses = append(clauses, child.toES(contentMatches))
+	}
+	if n.kind == orQueryNode {
+		return map[string]interface{}{
+			"bool": map[string]interface{}{"should": clauses, "minimum_should_match": 1},
+		}
+	}
+	return map[string]interface{}{"bool": map[string]interface{}{"must": clauses}}
+}
+
+func (n *queryNode) fieldQuery() map[string]interface{} {
+	f := n.field.esField
+	switch n.field.kind {
+	case textQueryField:
+		// Title and summary also match in the work's language
+		if _, ok := localizedTextFields[f]; ok {
+			match := map[string]interface{}{"query": n.value, "fields": localizedFields([]string{f})}
+			if n.phrase {
+				match["type"] = "phrase"
+			} else {
+				match["operator"] = "and"
+			}
+			return map[string]interface{}{"multi_match": match}
+		}
+		if n.phrase {
+			return map[string]interface{}{"match_phrase": map[string]interface{}{f: n.value}}
+		}
+		return map[string]interface{}{
+			"match": map[string]interface{}{f: map[string]interface{}{"query": n.value, "operator": "and"}},
+		}
+
+	case boolQueryField:
+		return map[string]interface{}{"term": map[string]interface{}{f: n.value == "true"}}
+
+	case numberQueryField, dateQueryField:
+		bound := func(v string) interface{} {
+			if n.field.kind == numberQueryField {
+				i, _ := strconv.Atoi(v)
+				return i
+			}
+			// Date math rounding makes a bare date cover the whole day
+			// whichever way it's compared
+			if len(v) == len("2006-01-02") {
+				return v + "||/d"
+			}
+			return v
+		}
+		rangeQuery := map[string]interface{}{}
+		switch n.op {
+		case "":
+			if n.field.kind == numberQueryField {
+				return map[string]interface{}{"term": map[string]interface{}{f: bound(n.value)}}
+			}
+			rangeQuery["gte"] = bound(n.value)
+			rangeQuery["lte"] = bound(n.value)
+		case "..":
+			if n.value != "" {
+				rangeQuery["gte"] = bound(n.value)
+			}
+			if n.upper != "" {
+				rangeQuery["lte"] = bound(n.upper)
+			}
+		default:
+			rangeQuery[rangeOperators[n.op]] = bound(n.value)
+		}
+		return map[string]interface{}{"range": map[string]interface{}{f: rangeQuery}}
+	}
+
+	values := n.values
+	if len(values) == 0 {
+		values = []string{n.value}
+	}
+	if len(values) == 1 {
+		return map[string]interface{}{"term": map[string]interface{}{f: values[0]}}
+	}
+	return map[string]interface{}{"terms": map[string]interface{}{f: values}}
+}
+
+// String renders the query the way it was understood, for the parsed echo
+func (n *queryNode) String() string {
+	return n.format(true)
+}
+
+func (n *queryNode) format(top bool) string {
+	switch n.kind {
+	case textQueryNode:
+		if n.phrase {
+			return strconv.Quote(n.value)
+		}
+		return n.value
+	case fieldQueryNode:
+		value := n.value
+		switch n.op {
+		case "..":
+			value = n.value + ".." + n.upper
+		case "":
+			if strings.ContainsAny(value, " \t\"():") || n.phrase {
+				value = strconv.Quote(value)
+			}
+		default:
+			value = n.op + value
+		}
+		return n.field.name + ":" + value
+	case notQueryNode:
+		return "NOT " + n.children[0].format(false)
+	}
+
+	parts := []string{}
+	for _, child := range n.children {
+		parts = append(parts, child.format(false))
+	}
+	joiner := " AND "
+	if n.kind == orQueryNode {
+		joiner = " OR "
+	}
+	if top {
+		return strings.Join(parts, joiner)
+	}
+	return "(" + strings.Join(parts, joiner) + ")"
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseWorkQueryEcho(t *testing.T) {
+	cases := map[string]string{
+		`fandom:"Harry Potter" -tag:angst words:>50000 complete:true`: `fandom:"Harry Potter" AND NOT tag:angst AND words:>50000 AND complete:true`,
+		`hermione time travel`:                        `hermione time travel`,
+		`hermione ship:Drarry time travel`:            `hermione time travel AND relationship:Drarry`,
+		`(fandom:Naruto OR fandom:Bleach) kudos:1k..`: `(fandom:Naruto OR fandom:Bleach) AND kudos:1000..`,
+		`updated:>=2024-01-01 NOT complete:no`:        `updated:>=2024-01-01 AND NOT complete:false`,
+		`"slow burn" AND title:love`:                  `"slow burn" AND title:love`,
+	}
+	for q, want := range cases {
+		node, err := parseWorkQuery(q)
+		if err != nil {
+			t.Errorf("%q: %v", q, err)
+			continue
+		}
+		if got := node.String(); got != want {
+			t.Errorf("%q parsed as %q, want %q", q, got, want)
+		}
+	}
+}
+
+func TestParseWorkQueryErrors(t *testing.T) {
+	cases := []struct {
+		q        string
+		position int
+	}{
+		{`fandom:"Harry Potter`, 8},
+		{`fandmo:Naruto`, 1},
+		{`words:>lots`, 7},
+		{`complete:maybe`, 10},
+		{`(fandom:Naruto`, 1},
+		{`angst )`, 7},
+		{`OR fluff`, 1},
+		{`fluff AND`, 10},
+		{`tag: fluff`, 5},
+		{`fluff -`, 7},
+		{`published:2024-13-45`, 11},
+	}
+	for _, c := range cases {
+		_, err := parseWorkQuery(c.q)
+		var perr *QueryParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%q: expected a parse error, got %v", c.q, err)
+			continue
+		}
+		if perr.Position != c.position {
+			t.Errorf("%q: error %q at %d, want %d", c.q, perr.Message, perr.Position, c.position)
+		}
+	}
+}
+
+func TestParsedQueryToES(t *testing.T) {
+	node, err := parseWorkQuery(`fandom:"Harry Potter" -tag:angst words:>50000 complete:true updated:2024-05-01`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.tagFieldNodes()[0].values = []string{"Harry Potter", "HP"}
+
+	got, _ := json.Marshal(node.toES(nil))
+	want := `{"bool":{"must":[` +
+		`{"terms":{"fandoms":["Harry Potter","HP"]}},` +
+		`{"bool":{"must_not":[{"term":{"freeform_tags":"angst"}}]}},` +
+		`{"range":{"word_count":{"gt":50000}}},` +
+		`{"term":{"is_complete":true}},` +
+		`{"range":{"updated_date":{"gte":"2024-05-01||/d","lte":"2024-05-01||/d"}}}]}}`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestPlainQueryBuildsAsBefore(t *testing.T) {
+	ss := &SearchService{}
+	req := WorkSearchRequest{Query: "time travel", Status: "all"}
+	if err := parseRequestQuery(&req); err != nil {
+		t.Fatal(err)
+	}
+	plain := ss.buildWorkSearchQuery(req)
+	req.parsedQuery = nil
+	if unparsed := ss.buildWorkSearchQuery(req); !reflect.DeepEqual(plain, unparsed) {
+		t.Errorf("plain text query changed:\n%v\n%v", plain, unparsed)
+	}
+
+	structured := WorkSearchRequest{Query: "time travel -tag:angst"}
+	if err := parseRequestQuery(&structured); err != nil {
+		t.Fatal(err)
+	}
+	if got := structured.parsedQuery.freeText(); got != "time travel" {
+		t.Errorf("free text = %q, want the words without field terms", got)
+	}
+}
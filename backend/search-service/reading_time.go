@@ -0,0 +1,14 @@
+package main
+
+import (
+	"nuclear-ao3/shared/models"
+)
+
+// withReadingTime adds estimated_reading_minutes to a work search hit,
+// computed the same way as on the work page
+func withReadingTime(source map[string]interface{}) map[string]interface{} {
+	wordCount, _ := source["word_count"].(float64)
+	language, _ := source["language"].(string)
+	source["estimated_reading_minutes"] = models.EstimateReadingMinutes(int(wordCount), language)
+	return source
+}
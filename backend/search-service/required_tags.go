@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+
+	"nuclear-ao3/shared/models"
+)
+
+// withRequiredTags adds required_tags to a work search hit so result blurbs
+// render the rating, warnings, categories and completion the same way the
+// work page does. Older documents use category/is_complete where newer ones
+// use categories/completion_status, so both are read.
+func withRequiredTags(source map[string]interface{}) map[string]interface{} {
+	rating, _ := source["rating"].(string)
+
+	categories := sourceStrings(source["categories"])
+	if len(categories) == 0 {
+		categories = sourceStrings(source["category"])
+	}
+
+	complete, ok := source["is_complete"].(bool)
+	if !ok {
+		status, _ := source["completion_status"].(string)
+		complete = strings.EqualFold(status, "complete")
+	}
+
+	source["required_tags"] = models.BuildRequiredTags(rating, sourceStrings(source["warnings"]), categories, complete)
+	return source
+}
+
+// sourceStrings reads a string or list of strings out of a decoded _source
+func sourceStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
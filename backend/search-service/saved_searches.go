@@ -0,0 +1,568 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+)
+
+// Saved searches and their alerts. An alert reruns its saved search on a
+// schedule, restricted to works updated since the previous run, and records
+// each matching work in saved_search_alert_hits. Works that weren't there
+// before are the new results; they are announced to the owner together, as
+// one notification per run, through the notification service.
+
+const (
+	maxSavedSearches = 100
+	// alertBatchSize is how many due alerts one scheduler pass claims
+	alertBatchSize = 50
+	// alertResultLimit caps the works one run looks at; more new works than
+	// this are picked up by the next run
+	alertResultLimit = 100
+	// alertLease holds a claimed alert so other instances skip it; a run that
+	// dies is retried once the lease passes
+	alertLease = 15 * time.Minute
+	// alertOverlap reaches back before the last run to cover works that
+	// were indexed late
+	alertOverlap = time.Hour
+	// alertHitRetention is how long announced works are remembered
+	alertHitRetention = 90 * 24 * time.Hour
+	// alertTitlesShown is how many work titles a notification lists
+	alertTitlesShown = 10
+)
+
+// alertFrequencies are the intervals an alert can run at
+var alertFrequencies = map[string]time.Duration{
+	"hourly": time.Hour,
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// SavedSearch is a work search a reader saved, with its alert settings
+type SavedSearch struct {
+	ID             uuid.UUID         `json:"id"`
+	UserID         uuid.UUID         `json:"user_id"`
+	Name           string            `json:"name"`
+	Query          WorkSearchRequest `json:"query"`
+	AlertFrequency string            `json:"alert_frequency"`
+	AlertLastRunAt *time.Time        `json:"alert_last_run_at,omitempty"`
+	AlertNextRunAt *time.Time        `json:"alert_next_run_at,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+// alertMatch is a work an alert found
+type alertMatch struct {
+	WorkID uuid.UUID
+	Title  string
+}
+
+// validAlertFrequency accepts "off" and the scheduled frequencies
+func validAlertFrequency(frequency string) bool {
+	_, ok := alertFrequencies[frequency]
+	return ok || frequency == "off"
+}
+
+// validateSavedQuery checks a search before it's saved, so alerts don't
+// fail on every run
+func validateSavedQuery(query WorkSearchRequest) error {
+	if err := validateWorkDateRanges(query); err != nil {
+		return err
+	}
+	return parseRequestQuery(&query)
+}
+
+// requestUserID is the signed-in user, as forwarded by the API gateway
+func requestUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID := c.GetHeader("X-User-ID")
+	if value, exists := c.Get("user_id"); exists {
+		if s, ok := value.(string); ok {
+			userID = s
+		}
+	}
+	id, err := uuid.Parse(userID)
+	return id, err == nil
+}
+
+// SaveSearch saves a work search, optionally with an alert.
+// POST /api/v1/saved-searches
+func (ss *SearchService) SaveSearch(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req struct {
+		Name           string            `json:"name" binding:"required,max=255"`
+		Query          WorkSearchRequest `json:"query"`
+		AlertFrequency string            `json:"alert_frequency"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.AlertFrequency == "" {
+		req.AlertFrequency = "off"
+	}
+	if !validAlertFrequency(req.AlertFrequency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "alert_frequency must be off, hourly, daily or weekly"})
+		return
+	}
+	if err := validateSavedQuery(req.Query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Query.Page, req.Query.Limit = 0, 0
+	queryJSON, err := json.Marshal(req.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query"})
+		return
+	}
+
+	var count int
+	if err := ss.db.QueryRowContext(c.Request.Context(),
+		`SELECT COUNT(*) FROM saved_searches WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save search"})
+		return
+	}
+	if count >= maxSavedSearches {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("You can save up to %d searches", maxSavedSearches)})
+		return
+	}
+
+	// An alert starts from now: works already matching aren't announced
+	saved := SavedSearch{UserID: userID, Name: strings.TrimSpace(req.Name), Query: req.Query, AlertFrequency: req.AlertFrequency}
+	if interval, ok := alertFrequencies[saved.AlertFrequency]; ok {
+		now := time.Now()
+		next := now.Add(interval)
+		saved.AlertLastRunAt, saved.AlertNextRunAt = &now, &next
+	}
+	err = ss.db.QueryRowContext(c.Request.Context(), `
+		INSERT INTO saved_searches (user_id, name, query, alert_frequency, alert_last_run_at, alert_next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		userID, saved.Name, queryJSON, saved.AlertFrequency, saved.AlertLastRunAt, saved.AlertNextRunAt,
+	).Scan(&saved.ID, &saved.CreatedAt)
+	if err != nil {
+		log.Printf("Failed to save search for %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save search"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"search": saved})
+}
+
+// GetSavedSearches lists the user's saved searches, newest first.
+// GET /api/v1/saved-searches
+func (ss *SearchService) GetSavedSearches(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	rows, err := ss.db.QueryContext(c.Request.Context(), `
+		SELECT id, user_id, name, query, alert_frequency, alert_last_run_at, alert_next_run_at, created_at
+		FROM saved_searches
+		WHERE user_id = $1
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load saved searches"})
+		return
+	}
+	defer rows.Close()
+
+	searches := []SavedSearch{}
+	for rows.Next() {
+		saved, err := scanSavedSearch(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load saved searches"})
+			return
+		}
+		searches = append(searches, saved)
+	}
+	c.JSON(http.StatusOK, gin.H{"searches": searches})
+}
+
+// DeleteSavedSearch removes one of the user's saved searches and its alert.
+// DELETE /api/v1/saved-searches/:search_id
+func (ss *SearchService) DeleteSavedSearch(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	searchID, err := uuid.Parse(c.Param("search_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return
+	}
+
+	result, err := ss.db.ExecContext(c.Request.Context(),
+		`DELETE FROM saved_searches WHERE id = $1 AND user_id = $2`, searchID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete saved search"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted"})
+}
+
+// CreateSearchAlert turns a saved search's alert on, changes its frequency,
+// or turns it off. POST /api/v1/saved-searches/:search_id/alert
+func (ss *SearchService) CreateSearchAlert(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	searchID, err := uuid.Parse(c.Param("search_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return
+	}
+	var req struct {
+		Frequency string `json:"frequency"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Frequency == "" {
+		req.Frequency = "daily"
+	}
+	if !validAlertFrequency(req.Frequency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "frequency must be off, hourly, daily or weekly"})
+		return
+	}
+
+	// Turning an alert on starts it from now; changing the frequency of a
+	// running alert keeps its place so nothing is skipped
+	row := ss.db.QueryRowContext(c.Request.Context(), `
+		UPDATE saved_searches SET
+			alert_frequency = $3,
+			alert_last_run_at = CASE
+				WHEN $3 = 'off' THEN alert_last_run_at
+				WHEN alert_frequency = 'off' OR alert_last_run_at IS NULL THEN NOW()
+				ELSE alert_last_run_at END,
+			alert_next_run_at = CASE
+				WHEN $3 = 'off' THEN NULL
+				ELSE COALESCE(CASE WHEN alert_frequency = 'off' THEN NULL ELSE alert_last_run_at END, NOW())
+					+ $4 * INTERVAL '1 second' END,
+			updated_at = NOW()
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, user_id, name, query, alert_frequency, alert_last_run_at, alert_next_run_at, created_at`,
+		searchID, userID, req.Frequency, alertFrequencies[req.Frequency].Seconds())
+	saved, err := scanSavedSearch(row)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update alert of saved search %s: %v", searchID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert"})
+		return
+	}
+
+	status := http.StatusOK
+	if saved.AlertFrequency != "off" {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{"alert": gin.H{
+		"search_id":   saved.ID,
+		"frequency":   saved.AlertFrequency,
+		"last_run_at": saved.AlertLastRunAt,
+		"next_run_at": saved.AlertNextRunAt,
+	}})
+}
+
+// scanSavedSearch reads a saved search row in the column order used above
+func scanSavedSearch(row interface{ Scan(...interface{}) error }) (SavedSearch, error) {
+	var saved SavedSearch
+	var queryJSON []byte
+	var lastRun, nextRun sql.NullTime
+	if err := row.Scan(&saved.ID, &saved.UserID, &saved.Name, &queryJSON, &saved.AlertFrequency,
+		&lastRun, &nextRun, &saved.CreatedAt); err != nil {
+		return saved, err
+	}
+	if err := json.Unmarshal(queryJSON, &saved.Query); err != nil {
+		return saved, fmt.Errorf("invalid saved query: %w", err)
+	}
+	if lastRun.Valid {
+		saved.AlertLastRunAt = &lastRun.Time
+	}
+	if nextRun.Valid {
+		saved.AlertNextRunAt = &nextRun.Time
+	}
+	return saved, nil
+}
+
+// startSearchAlertScheduler runs due alerts every SEARCH_ALERT_INTERVAL
+// until ctx is cancelled. Alerts are claimed with a lease, so several
+// instances can run it side by side.
+func (ss *SearchService) startSearchAlertScheduler(ctx context.Context) {
+	if ss.db == nil {
+		log.Println("Search alert scheduler disabled: no database connection")
+		return
+	}
+	interval := 5 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("SEARCH_ALERT_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	log.Printf("Search alert scheduler started, running every %s", interval)
+
+	for sleepContext(ctx, interval) {
+		for {
+			ran, err := ss.runDueSearchAlerts(ctx)
+			if err != nil {
+				log.Printf("Search alert run failed: %v", err)
+				break
+			}
+			if ran < alertBatchSize {
+				break
+			}
+		}
+		if _, err := ss.db.ExecContext(ctx, `DELETE FROM saved_search_alert_hits WHERE notified_at < $1`,
+			time.Now().Add(-alertHitRetention)); err != nil {
+			log.Printf("Failed to prune search alert hits: %v", err)
+		}
+	}
+}
+
+// runDueSearchAlerts claims a batch of due alerts and runs them, returning
+// how many were claimed
+func (ss *SearchService) runDueSearchAlerts(ctx context.Context) (int, error) {
+	rows, err := ss.db.QueryContext(ctx, `
+		UPDATE saved_searches SET alert_next_run_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM saved_searches
+			WHERE alert_frequency <> 'off' AND alert_next_run_at <= NOW()
+			ORDER BY alert_next_run_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, user_id, name, query, alert_frequency, alert_last_run_at, alert_next_run_at, created_at`,
+		alertBatchSize, alertLease.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	var due []SavedSearch
+	for rows.Next() {
+		saved, err := scanSavedSearch(rows)
+		if err != nil {
+			log.Printf("Skipping unreadable saved search: %v", err)
+			continue
+		}
+		due = append(due, saved)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, saved := range due {
+		if err := ss.runSearchAlert(ctx, saved); err != nil {
+			// The lease expires and the alert is retried
+			log.Printf("Search alert %s failed: %v", saved.ID, err)
+		}
+	}
+	return len(due), nil
+}
+
+// runSearchAlert reruns a saved search, notifies its owner of works it
+// hasn't announced before, and schedules the next run
+func (ss *SearchService) runSearchAlert(ctx context.Context, saved SavedSearch) error {
+	started := time.Now()
+	lastRun := started
+	if saved.AlertLastRunAt != nil {
+		lastRun = *saved.AlertLastRunAt
+	}
+
+	req := alertSearchRequest(saved.Query, lastRun)
+	if err := parseRequestQuery(&req); err != nil {
+		return fmt.Errorf("saved query no longer parses: %w", err)
+	}
+	ss.applyContentSearch(ctx, &req)
+	ss.applyTagExpansion(ctx, &req)
+	ss.applyMutedUsersOf(ctx, saved.UserID, &req)
+	response, err := ss.executeWorkSearch(ctx, ss.buildWorkSearchQuery(req), req)
+	if err != nil {
+		return err
+	}
+	matches := alertMatches(response.Results)
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	fresh, err := recordAlertHits(ctx, tx, saved.ID, matches)
+	if err != nil {
+		return err
+	}
+	// Hits are only kept once the owner has been told about them
+	if len(fresh) > 0 {
+		if err := sendNotificationEvent(ctx, searchAlertEvent(saved, fresh)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE saved_searches
+		SET alert_last_run_at = $2, alert_next_run_at = $3
+		WHERE id = $1 AND alert_frequency <> 'off'`,
+		saved.ID, started, started.Add(alertFrequencies[saved.AlertFrequency])); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// alertSearchRequest is a saved query narrowed to works updated since the
+// last run, most recently updated first
+func alertSearchRequest(query WorkSearchRequest, lastRun time.Time) WorkSearchRequest {
+	req := query
+	req.UpdatedAfter = lastRun.Add(-alertOverlap).UTC().Format(time.RFC3339)
+	req.UpdatedBefore = ""
+	req.UpdatedWithin = ""
+	req.SortBy, req.SortOrder = "updated_at", "desc"
+	req.Page, req.Limit = 1, alertResultLimit
+	return req
+}
+
+// alertMatches reads the works out of search results
+func alertMatches(results []map[string]interface{}) []alertMatch {
+	matches := make([]alertMatch, 0, len(results))
+	for _, result := range results {
+		idValue, _ := result["work_id"].(string)
+		id, err := uuid.Parse(idValue)
+		if err != nil {
+			continue
+		}
+		title, _ := result["title"].(string)
+		matches = append(matches, alertMatch{WorkID: id, Title: title})
+	}
+	return matches
+}
+
+// recordAlertHits records the matches against the alert and returns those
+// it hadn't seen before, in their original order
+func recordAlertHits(ctx context.Context, tx *sql.Tx, searchID uuid.UUID, matches []alertMatch) ([]alertMatch, error) {
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.WorkID.String()
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO saved_search_alert_hits (saved_search_id, work_id)
+		SELECT $1, w.id FROM works w WHERE w.id = ANY($2::uuid[])
+		ON CONFLICT DO NOTHING
+		RETURNING work_id`, searchID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inserted := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		inserted[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var fresh []alertMatch
+	for _, match := range matches {
+		if inserted[match.WorkID] {
+			fresh = append(fresh, match)
+		}
+	}
+	return fresh, nil
+}
+
+// searchAlertEvent is the one notification announcing a run's new works
+func searchAlertEvent(saved SavedSearch, works []alertMatch) notifications.EventData {
+	titles := make([]string, 0, alertTitlesShown)
+	workIDs := make([]string, len(works))
+	for i, work := range works {
+		workIDs[i] = work.WorkID.String()
+		if i < alertTitlesShown {
+			titles = append(titles, work.Title)
+		}
+	}
+
+	event := notifications.EventData{
+		Type:         models.EventSavedSearchMatch,
+		SourceID:     saved.ID,
+		SourceType:   "saved_search",
+		RecipientIDs: []uuid.UUID{saved.UserID},
+		ExtraData: map[string]interface{}{
+			"saved_search_name": saved.Name,
+			"work_ids":          workIDs,
+			"work_titles":       titles,
+		},
+	}
+	if len(works) == 1 {
+		event.Title = fmt.Sprintf("New work matching %q", saved.Name)
+		event.Description = works[0].Title
+		event.ActionURL = fmt.Sprintf("/works/%s", works[0].WorkID)
+		return event
+	}
+	event.Title = fmt.Sprintf("%d new works matching %q", len(works), saved.Name)
+	event.Description = strings.Join(titles, ", ")
+	if len(works) > len(titles) {
+		event.Description += fmt.Sprintf(" and %d more", len(works)-len(titles))
+	}
+	event.ActionURL = fmt.Sprintf("/search/saved/%s", saved.ID)
+	return event
+}
+
+// sendNotificationEvent hands an event to the notification service
+func sendNotificationEvent(ctx context.Context, event notifications.EventData) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	url := getEnv("NOTIFICATION_SERVICE_URL", "http://localhost:8004") + "/api/v1/process-event"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notification service unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("notification service returned " + resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestAlertSearchRequest(t *testing.T) {
+	saved := WorkSearchRequest{
+		Query:         "fluff",
+		Fandoms:       []string{"Good Omens"},
+		UpdatedWithin: "week",
+		SortBy:        "kudos",
+		Page:          4,
+	}
+	lastRun := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	req := alertSearchRequest(saved, lastRun)
+	if req.UpdatedAfter != "2024-05-01T11:00:00Z" {
+		t.Errorf("UpdatedAfter = %q, want an hour before the last run", req.UpdatedAfter)
+	}
+	if req.UpdatedWithin != "" || req.SortBy != "updated_at" || req.Page != 1 || req.Limit != alertResultLimit {
+		t.Errorf("unexpected alert request %+v", req)
+	}
+	if req.Query != "fluff" || len(req.Fandoms) != 1 {
+		t.Error("the saved filters must be kept")
+	}
+	if err := validateWorkDateRanges(req); err != nil {
+		t.Errorf("alert request has an invalid date range: %v", err)
+	}
+}
+
+func TestAlertMatches(t *testing.T) {
+	id := uuid.New()
+	matches := alertMatches([]map[string]interface{}{
+		{"work_id": id.String(), "title": "Found"},
+		{"work_id": "not-a-uuid", "title": "Broken"},
+		{"title": "Missing"},
+	})
+	if len(matches) != 1 || matches[0].WorkID != id || matches[0].Title != "Found" {
+		t.Errorf("alertMatches = %+v", matches)
+	}
+}
+
+func TestSearchAlertEvent(t *testing.T) {
+	saved := SavedSearch{ID: uuid.New(), UserID: uuid.New(), Name: "Crowley/Aziraphale"}
+
+	single := searchAlertEvent(saved, []alertMatch{{WorkID: uuid.New(), Title: "Only One"}})
+	if single.Type != models.EventSavedSearchMatch || single.SourceType != "saved_search" {
+		t.Errorf("unexpected event %+v", single)
+	}
+	if len(single.RecipientIDs) != 1 || single.RecipientIDs[0] != saved.UserID {
+		t.Error("the alert's owner must be the only recipient")
+	}
+	if !strings.HasPrefix(single.ActionURL, "/works/") || single.Description != "Only One" {
+		t.Errorf("a single match should link to the work, got %+v", single)
+	}
+
+	var works []alertMatch
+	for i := 0; i < alertTitlesShown+3; i++ {
+		works = append(works, alertMatch{WorkID: uuid.New(), Title: "Work"})
+	}
+	batch := searchAlertEvent(saved, works)
+	if !strings.HasPrefix(batch.Title, "13 new works") {
+		t.Errorf("batch title = %q", batch.Title)
+	}
+	if !strings.HasSuffix(batch.Description, "and 3 more") {
+		t.Errorf("batch description = %q", batch.Description)
+	}
+	if ids := batch.ExtraData["work_ids"].([]string); len(ids) != len(works) {
+		t.Errorf("expected every work id in the event, got %d", len(ids))
+	}
+}
+
+func TestValidAlertFrequency(t *testing.T) {
+	for _, frequency := range []string{"off", "hourly", "daily", "weekly"} {
+		if !validAlertFrequency(frequency) {
+			t.Errorf("%s should be valid", frequency)
+		}
+	}
+	if validAlertFrequency("monthly") {
+		t.Error("monthly should be rejected")
+	}
+}
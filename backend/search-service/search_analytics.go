@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// =============================================================================
+// SEARCH ANALYTICS
+// Searches are recorded as anonymized events: a hash of the normalized query,
+// the names of the filters used, the result count and, reported by the
+// client, the position of a clicked result. No user ID or IP address is
+// kept; each searcher becomes a hash salted per day, used only to count
+// distinct searchers. A term is reported once at least minSearchers people
+// searched for it, and its text is only stored from then on. Events are
+// sampled and written to Redis in batches by a background writer.
+// =============================================================================
+
+const (
+	// analyticsBufferSize is how many events can wait for the writer;
+	// events beyond it are dropped rather than slowing searches down
+	analyticsBufferSize = 1000
+	analyticsFlushSize  = 200
+	analyticsFlushEvery = 10 * time.Second
+	// analyticsRetention is how long daily analytics keys are kept
+	analyticsRetention = 30 * 24 * time.Hour
+	// maxAnalyticsDays is the longest window a report can cover
+	maxAnalyticsDays = 30
+	// defaultMinSearchers is the default k-anonymity threshold
+	defaultMinSearchers = 5
+)
+
+// searchEvent is one anonymized search, or a click on one of its results
+type searchEvent struct {
+	QueryHash string
+	// Query is the normalized query, stored only once the term has been
+	// searched by enough people
+	Query      string
+	SearchType string
+	Filters    []string
+	Results    int
+	// ClickedPosition is the 1-based position of a clicked result; 0 for
+	// the search itself
+	ClickedPosition int
+	Searcher        string
+	// Weight scales sampled events back up to an estimate of all events
+	Weight float64
+	At     time.Time
+}
+
+// searchAnalyticsConfig controls sampling and the k-anonymity threshold
+type searchAnalyticsConfig struct {
+	// SampleRate is the share of searches recorded, from 0 to 1
+	SampleRate float64
+	// MinSearchers is how many distinct searchers a term needs before it
+	// is reported
+	MinSearchers int
+	// salt keys the daily searcher hashes; replicas share it through
+	// SEARCH_ANALYTICS_SALT so they count a searcher once
+	salt string
+}
+
+// searchAnalyticsConfigFromEnv reads SEARCH_ANALYTICS_SAMPLE_RATE,
+// SEARCH_ANALYTICS_MIN_SEARCHERS and SEARCH_ANALYTICS_SALT
+func searchAnalyticsConfigFromEnv() searchAnalyticsConfig {
+	config := searchAnalyticsConfig{
+		SampleRate:   1,
+		MinSearchers: defaultMinSearchers,
+		salt:         os.Getenv("SEARCH_ANALYTICS_SALT"),
+	}
+	if rate, err := strconv.ParseFloat(os.Getenv("SEARCH_ANALYTICS_SAMPLE_RATE"), 64); err == nil && rate >= 0 && rate <= 1 {
+		config.SampleRate = rate
+	}
+	if k, err := strconv.Atoi(os.Getenv("SEARCH_ANALYTICS_MIN_SEARCHERS")); err == nil && k > 0 {
+		config.MinSearchers = k
+	}
+	if config.salt == "" {
+		salt := make([]byte, 16)
+		rand.Read(salt)
+		config.salt = hex.EncodeToString(salt)
+	}
+	return config
+}
+
+// normalizeSearchQuery folds case and whitespace so the same search always
+// hashes the same
+func normalizeSearchQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+func hashSearchQuery(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:16])
+}
+
+// searcherHash stands in for a searcher for one day. The date is part of the
+// hash, so the same person can't be followed from day to day.
+func searcherHash(salt, date, searcher string) string {
+	sum := sha256.Sum256([]byte(salt + "|" + date + "|" + searcher))
+	return hex.EncodeToString(sum[:16])
+}
+
+// workSearchFilters names the filters a work search used, without values
+func workSearchFilters(req WorkSearchRequest) []string {
+	filters := []string{}
+	add := func(name string, used bool) {
+		if used {
+			filters = append(filters, name)
+		}
+	}
+	add("fandom", len(req.Fandoms) > 0)
+	add("character", len(req.Characters) > 0)
+	add("relationship", len(req.Relationships) > 0)
+	add("tag", len(req.Tags) > 0)
+	add("rating", len(req.Rating) > 0)
+	add("category", len(req.Category) > 0)
+	add("warning", len(req.Warnings) > 0)
+	add("language", len(req.Language) > 0)
+	add("exclude", len(req.ExcludeFandoms)+len(req.ExcludeCharacters)+len(req.ExcludeRelationships)+
+		len(req.ExcludeTags)+len(req.ExcludeWarnings)+len(req.ExcludeRatings) > 0)
+	add("word_count", req.WordCountMin != nil || req.WordCountMax != nil)
+	add("status", req.Status != "" && req.Status != "all")
+	return filters
+}
+
+// recordSearchEvent queues an anonymized search or click event for the
+// analytics writer. It never blocks: with the buffer full the event is
+// dropped.
+func (ss *SearchService) recordSearchEvent(c *gin.Context, searchType, query string, filters []string, results, clickedPosition int) {
+	normalized := normalizeSearchQuery(query)
+	if normalized == "" || ss.analyticsEvents == nil {
+		return
+	}
+	rate := ss.analyticsConfig.SampleRate
+	if rate <= 0 || mathrand.Float64() >= rate {
+		return
+	}
+
+	searcher := c.ClientIP()
+	if userID, ok := requestUserID(c); ok {
+		searcher = userID.String()
+	}
+	now := time.Now().UTC()
+	event := searchEvent{
+		QueryHash:       hashSearchQuery(normalized),
+		Query:           normalized,
+		SearchType:      searchType,
+		Filters:         filters,
+		Results:         results,
+		ClickedPosition: clickedPosition,
+		Searcher:        searcherHash(ss.analyticsConfig.salt, now.Format("2006-01-02"), searcher),
+		Weight:          1 / rate,
+		At:              now,
+	}
+
+	select {
+	case ss.analyticsEvents <- event:
+	default:
+	}
+}
+
+// startSearchAnalyticsWriter writes queued events to Redis in batches until
+// ctx is cancelled, then writes what is left
+func (ss *SearchService) startSearchAnalyticsWriter(ctx context.Context) {
+	if ss.redis == nil || ss.analyticsEvents == nil {
+		log.Println("Search analytics disabled: no Redis connection")
+		return
+	}
+	ticker := time.NewTicker(analyticsFlushEvery)
+	defer ticker.Stop()
+
+	batch := make([]searchEvent, 0, analyticsFlushSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := ss.writeSearchEvents(flushCtx, batch); err != nil {
+			log.Printf("Failed to write %d search analytics events: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case event := <-ss.analyticsEvents:
+			batch = append(batch, event)
+			if len(batch) >= analyticsFlushSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-ss.analyticsEvents:
+					batch = append(batch, event)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func analyticsKey(kind, date string) string {
+	return fmt.Sprintf("search_analytics:%s:%s", kind, date)
+}
+
+func searchersKey(date, queryHash string) string {
+	return fmt.Sprintf("search_analytics:searchers:%s:%s", date, queryHash)
+}
+
+// writeSearchEvents adds a batch of events to the daily counters, then
+// stores the text of terms that have reached the searcher threshold
+func (ss *SearchService) writeSearchEvents(ctx context.Context, events []searchEvent) error {
+	pipe := ss.redis.Pipeline()
+	terms := map[string]searchEvent{}
+	dates := map[string]bool{}
+	for _, e := range events {
+		date := e.At.Format("2006-01-02")
+		dates[date] = true
+		if e.ClickedPosition > 0 {
+			pipe.ZIncrBy(ctx, analyticsKey("clicks", date), e.Weight, e.QueryHash)
+			pipe.HIncrByFloat(ctx, analyticsKey("click_positions", date), strconv.Itoa(e.ClickedPosition), e.Weight)
+		} else {
+			pipe.ZIncrBy(ctx, analyticsKey("terms", date), e.Weight, e.QueryHash)
+			if e.Results == 0 {
+				pipe.ZIncrBy(ctx, analyticsKey("zero_results", date), e.Weight, e.QueryHash)
+			}
+			for _, filter := range e.Filters {
+				pipe.HIncrByFloat(ctx, analyticsKey("filters", date), filter, e.Weight)
+			}
+			pipe.HIncrByFloat(ctx, analyticsKey("searches", date), e.SearchType, e.Weight)
+		}
+		pipe.PFAdd(ctx, searchersKey(date, e.QueryHash), e.Searcher)
+		pipe.Expire(ctx, searchersKey(date, e.QueryHash), analyticsRetention)
+		terms[date+"|"+e.QueryHash] = e
+	}
+	for date := range dates {
+		for _, kind := range []string{"terms", "zero_results", "clicks", "click_positions", "filters", "searches"} {
+			pipe.Expire(ctx, analyticsKey(kind, date), analyticsRetention)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	for _, e := range terms {
+		date := e.At.Format("2006-01-02")
+		searchers, err := ss.redis.PFCount(ctx, analyticsSearcherKeys(e.QueryHash, e.At, maxAnalyticsDays)...).Result()
+		if err != nil {
+			return err
+		}
+		if searchers >= int64(ss.analyticsConfig.MinSearchers) {
+			ss.redis.HSetNX(ctx, analyticsKey("texts", date), e.QueryHash, e.Query)
+			ss.redis.Expire(ctx, analyticsKey("texts", date), analyticsRetention)
+		}
+	}
+	return nil
+}
+
+// analyticsDates lists the days of a report window ending at end, newest
+// first
+func analyticsDates(end time.Time, days int) []string {
+	dates := make([]string, days)
+	for i := range dates {
+		dates[i] = end.AddDate(0, 0, -i).Format("2006-01-02")
+	}
+	return dates
+}
+
+func analyticsSearcherKeys(queryHash string, end time.Time, days int) []string {
+	dates := analyticsDates(end, days)
+	keys := make([]string, len(dates))
+	for i, date := range dates {
+		keys[i] = searchersKey(date, queryHash)
+	}
+	return keys
+}
+
+// analyticsDays reads the days parameter of a report, 7 by default
+func analyticsDays(c *gin.Context) (int, bool) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days < 1 || days > maxAnalyticsDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxAnalyticsDays)})
+		return 0, false
+	}
+	return days, true
+}
+
+// ReportedTerm is a search term that passed the searcher threshold
+type ReportedTerm struct {
+	Term     string  `json:"term"`
+	Searches float64 `json:"searches"`
+	Clicks   float64 `json:"clicks,omitempty"`
+}
+
+// reportTerms ranks the terms in one kind of daily counter over a window,
+// keeping only terms enough distinct people searched for
+func (ss *SearchService) reportTerms(ctx context.Context, kind string, days, limit int) ([]ReportedTerm, error) {
+	end := time.Now().UTC()
+	dates := analyticsDates(end, days)
+
+	totals := map[string]float64{}
+	for _, date := range dates {
+		scores, err := ss.redis.ZRevRangeWithScores(ctx, analyticsKey(kind, date), 0, int64(limit*10-1)).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		for _, z := range scores {
+			totals[z.Member.(string)] += z.Score
+		}
+	}
+	hashes := make([]string, 0, len(totals))
+	for hash := range totals {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		if totals[hashes[i]] != totals[hashes[j]] {
+			return totals[hashes[i]] > totals[hashes[j]]
+		}
+		return hashes[i] < hashes[j]
+	})
+
+	terms := []ReportedTerm{}
+	for _, hash := range hashes {
+		if len(terms) >= limit {
+			break
+		}
+		searchers, err := ss.redis.PFCount(ctx, analyticsSearcherKeys(hash, end, days)...).Result()
+		if err != nil {
+			return nil, err
+		}
+		if searchers < int64(ss.analyticsConfig.MinSearchers) {
+			continue
+		}
+		text := ""
+		for _, date := range dates {
+			if t, err := ss.redis.HGet(ctx, analyticsKey("texts", date), hash).Result(); err == nil {
+				text = t
+				break
+			}
+		}
+		if text == "" {
+			continue
+		}
+
+		term := ReportedTerm{Term: text, Searches: totals[hash]}
+		if kind == "terms" {
+			for _, date := range dates {
+				clicks, _ := ss.redis.ZScore(ctx, analyticsKey("clicks", date), hash).Result()
+				term.Clicks += clicks
+			}
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// sumAnalyticsHash adds up a daily hash of counters over a window
+func (ss *SearchService) sumAnalyticsHash(ctx context.Context, kind string, days int) (map[string]float64, error) {
+	sums := map[string]float64{}
+	for _, date := range analyticsDates(time.Now().UTC(), days) {
+		values, err := ss.redis.HGetAll(ctx, analyticsKey(kind, date)).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		for field, value := range values {
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				sums[field] += n
+			}
+		}
+	}
+	return sums, nil
+}
+
+// RecordSearchClick records which result of a search a reader opened.
+// POST /api/v1/search/clicks
+func (ss *SearchService) RecordSearchClick(c *gin.Context) {
+	var req struct {
+		Query      string `json:"q" binding:"required"`
+		Position   int    `json:"position" binding:"required"`
+		SearchType string `json:"search_type"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Position < 1 || req.Position > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "position must be between 1 and 1000"})
+		return
+	}
+	if req.SearchType == "" {
+		req.SearchType = "works"
+	}
+
+	ss.recordSearchEvent(c, req.SearchType, req.Query, nil, 0, req.Position)
+	c.Status(http.StatusAccepted)
+}
+
+// GetSearchStats reports search volume by type, how often each filter was
+// used and which result positions readers click, over the last days days.
+// GET /api/v1/analytics/search-stats
+func (ss *SearchService) GetSearchStats(c *gin.Context) {
+	days, ok := analyticsDays(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	stats := gin.H{"days": days, "sample_rate": ss.analyticsConfig.SampleRate}
+	for _, kind := range []string{"searches", "filters", "click_positions"} {
+		sums, err := ss.sumAnalyticsHash(ctx, kind, days)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search stats"})
+			return
+		}
+		stats[kind] = sums
+	}
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
+
+// GetPopularTerms lists the most searched terms with their result clicks.
+// GET /api/v1/analytics/popular-terms
+func (ss *SearchService) GetPopularTerms(c *gin.Context) {
+	ss.serveReportedTerms(c, "terms")
+}
+
+// GetZeroResultTerms lists the most searched terms that found nothing.
+// GET /api/v1/analytics/zero-results
+func (ss *SearchService) GetZeroResultTerms(c *gin.Context) {
+	ss.serveReportedTerms(c, "zero_results")
+}
+
+func (ss *SearchService) serveReportedTerms(c *gin.Context, kind string) {
+	days, ok := analyticsDays(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	terms, err := ss.reportTerms(c.Request.Context(), kind, days, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search terms"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"terms":         terms,
+		"days":          days,
+		"min_searchers": ss.analyticsConfig.MinSearchers,
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizeSearchQuery(t *testing.T) {
+	if got := normalizeSearchQuery("  Slow   BURN\tfluff "); got != "slow burn fluff" {
+		t.Errorf("normalizeSearchQuery = %q", got)
+	}
+	if hashSearchQuery("slow burn") != hashSearchQuery(normalizeSearchQuery("Slow  Burn")) {
+		t.Error("the same search should hash the same")
+	}
+}
+
+func TestSearcherHashRotatesDaily(t *testing.T) {
+	today := searcherHash("salt", "2026-10-16", "10.0.0.1")
+	if today != searcherHash("salt", "2026-10-16", "10.0.0.1") {
+		t.Error("a searcher should hash the same within a day")
+	}
+	if today == searcherHash("salt", "2026-10-17", "10.0.0.1") {
+		t.Error("a searcher should not be linkable across days")
+	}
+	if today == searcherHash("other", "2026-10-16", "10.0.0.1") {
+		t.Error("the salt should change the hash")
+	}
+}
+
+func TestWorkSearchFilters(t *testing.T) {
+	min := 1000
+	req := WorkSearchRequest{
+		Fandoms:      []string{"Good Omens"},
+		ExcludeTags:  []string{"Major Character Death"},
+		WordCountMin: &min,
+		Status:       "all",
+	}
+	want := []string{"fandom", "exclude", "word_count"}
+	if got := workSearchFilters(req); !reflect.DeepEqual(got, want) {
+		t.Errorf("workSearchFilters = %v, want %v", got, want)
+	}
+}
+
+func TestRecordSearchEventSamplingAndAnonymity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/v1/search/works?q=fluff", nil)
+
+	ss := &SearchService{
+		analyticsEvents: make(chan searchEvent, 1),
+		analyticsConfig: searchAnalyticsConfig{SampleRate: 1, MinSearchers: 5, salt: "salt"},
+	}
+	ss.recordSearchEvent(c, "works", "  Fluff ", []string{"fandom"}, 0, 0)
+	ss.recordSearchEvent(c, "works", "dropped when the buffer is full", nil, 3, 0)
+
+	event := <-ss.analyticsEvents
+	if event.Query != "fluff" || event.QueryHash != hashSearchQuery("fluff") || event.Weight != 1 {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if event.Searcher == c.ClientIP() || event.Searcher == "" {
+		t.Errorf("the searcher should be hashed, got %q", event.Searcher)
+	}
+
+	ss.analyticsConfig.SampleRate = 0
+	ss.recordSearchEvent(c, "works", "fluff", nil, 0, 0)
+	ss.recordSearchEvent(c, "works", "   ", nil, 0, 0)
+	select {
+	case e := <-ss.analyticsEvents:
+		t.Errorf("nothing should be recorded, got %+v", e)
+	default:
+	}
+}
+
+func TestAnalyticsDates(t *testing.T) {
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	want := []string{"2026-03-01", "2026-02-28", "2026-02-27"}
+	if got := analyticsDates(end, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("analyticsDates = %v, want %v", got, want)
+	}
+}
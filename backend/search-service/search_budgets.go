@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// =============================================================================
+// SEARCH TIMEOUT BUDGETS
+// A work search runs in stages, each with its own time budget: the query
+// for the page of hits, the facet aggregations, and hydration from the
+// database (content highlights). The hits are the only stage a search can't
+// do without. Facets run alongside them as a separate request, so a slow
+// aggregation returns the hits with facets_truncated rather than failing
+// the search; hydration that runs over is likewise dropped and flagged.
+// =============================================================================
+
+// Search stages, as labelled in metrics
+const (
+	searchStageQuery     = "query"
+	searchStageFacets    = "facets"
+	searchStageHydration = "hydration"
+)
+
+// searchBudgets bounds each stage of a search. A zero budget leaves the
+// stage to the request's own deadline.
+type searchBudgets struct {
+	Query     time.Duration
+	Facets    time.Duration
+	Hydration time.Duration
+}
+
+// defaultSearchBudgets fit inside the server's 15s write timeout with room
+// to spare
+var defaultSearchBudgets = searchBudgets{
+	Query:     5 * time.Second,
+	Facets:    time.Second,
+	Hydration: 500 * time.Millisecond,
+}
+
+// errSearchTimeout is a search whose hits didn't arrive within budget
+var errSearchTimeout = errors.New("search timed out")
+
+var (
+	searchRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nuclear_search_requests_total",
+		Help: "Searches run, by search type",
+	}, []string{"type"})
+	searchPartialResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nuclear_search_partial_results_total",
+		Help: "Searches answered without part of their results because a stage ran over its budget, by type and stage",
+	}, []string{"type", "stage"})
+)
+
+// searchBudgetsFromEnv reads SEARCH_QUERY_TIMEOUT, SEARCH_FACETS_TIMEOUT and
+// SEARCH_HYDRATION_TIMEOUT, keeping the default for any unset or invalid
+func searchBudgetsFromEnv() searchBudgets {
+	budgets := defaultSearchBudgets
+	for env, budget := range map[string]*time.Duration{
+		"SEARCH_QUERY_TIMEOUT":     &budgets.Query,
+		"SEARCH_FACETS_TIMEOUT":    &budgets.Facets,
+		"SEARCH_HYDRATION_TIMEOUT": &budgets.Hydration,
+	} {
+		if d, err := time.ParseDuration(os.Getenv(env)); err == nil && d > 0 {
+			*budget = d
+		}
+	}
+	return budgets
+}
+
+// withBudget bounds ctx by budget, if there is one
+func withBudget(ctx context.Context, budget time.Duration) (context.Context, context.CancelFunc) {
+	if budget <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, budget)
+}
+
+// esTimeout formats a budget as an Elasticsearch time value
+func esTimeout(budget time.Duration) string {
+	return fmt.Sprintf("%dms", budget.Milliseconds())
+}
+
+// splitFacetQuery separates a search's aggregations into a query of their
+// own. The hits query keeps everything else; the facet query has the same
+// query clause and no hits. facets is nil when the search has none.
+func splitFacetQuery(query map[string]interface{}, budgets searchBudgets) (hits, facets map[string]interface{}) {
+	hits = make(map[string]interface{}, len(query))
+	for k, v := range query {
+		if k != "aggs" {
+			hits[k] = v
+		}
+	}
+	if budgets.Query > 0 {
+		hits["timeout"] = esTimeout(budgets.Query)
+	}
+
+	aggs, ok := query["aggs"]
+	if !ok {
+		return hits, nil
+	}
+	facets = map[string]interface{}{
+		"size":             0,
+		"track_total_hits": false,
+		"aggs":             aggs,
+	}
+	if q, ok := query["query"]; ok {
+		facets["query"] = q
+	}
+	if budgets.Facets > 0 {
+		facets["timeout"] = esTimeout(budgets.Facets)
+	}
+	return hits, facets
+}
+
+// runWorksQuery sends one query to the works index and decodes the response
+func (ss *SearchService) runWorksQuery(ctx context.Context, query map[string]interface{}, trackTotal bool) (map[string]interface{}, error) {
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	res, err := ss.es.Search(
+		ss.es.Search.WithContext(ctx),
+		ss.es.Search.WithIndex("works"),
+		ss.es.Search.WithBody(bytes.NewReader(queryJSON)),
+		ss.es.Search.WithTrackTotalHits(trackTotal),
+	)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, errSearchTimeout
+		}
+		return nil, fmt.Errorf("search request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("search returned error: %s", res.String())
+	}
+
+	var esResponse map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&esResponse); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, errSearchTimeout
+		}
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return esResponse, nil
+}
+
+// facetResult is the outcome of a search's facet query
+type facetResult struct {
+	facets    map[string]interface{}
+	truncated bool
+}
+
+// runFacetQuery runs a facet query within its budget. Any failure, not
+// only a timeout, truncates the facets rather than the search.
+func (ss *SearchService) runFacetQuery(ctx context.Context, query map[string]interface{}) facetResult {
+	ctx, cancel := withBudget(ctx, ss.budgets.Facets)
+	defer cancel()
+
+	esResponse, err := ss.runWorksQuery(ctx, query, false)
+	if err != nil {
+		return facetResult{facets: map[string]interface{}{}, truncated: true}
+	}
+	facets, _ := esResponse["aggregations"].(map[string]interface{})
+	if facets == nil {
+		facets = map[string]interface{}{}
+	}
+	timedOut, _ := esResponse["timed_out"].(bool)
+	return facetResult{facets: facets, truncated: timedOut}
+}
+
+// hydrateWithinBudget runs hydrate under the hydration budget and reports
+// whether it ran over, leaving whatever it hadn't finished out
+func (ss *SearchService) hydrateWithinBudget(ctx context.Context, searchType string, hydrate func(context.Context)) bool {
+	ctx, cancel := withBudget(ctx, ss.budgets.Hydration)
+	defer cancel()
+
+	hydrate(ctx)
+	if ctx.Err() == context.DeadlineExceeded {
+		searchPartialResults.WithLabelValues(searchType, searchStageHydration).Inc()
+		return true
+	}
+	return false
+}
+
+// respondSearchError answers a failed search: 504 when the hits ran over
+// their budget, 500 otherwise
+func respondSearchError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, errSearchTimeout) {
+		status = http.StatusGatewayTimeout
+	}
+	c.JSON(status, gin.H{"error": message, "details": err.Error()})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSplitFacetQuery(t *testing.T) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{"match_all": map[string]interface{}{}},
+		"size":  20,
+		"from":  40,
+		"aggs":  map[string]interface{}{"fandoms": map[string]interface{}{}},
+	}
+	budgets := searchBudgets{Query: 2 * time.Second, Facets: 750 * time.Millisecond}
+
+	hits, facets := splitFacetQuery(query, budgets)
+	if _, ok := hits["aggs"]; ok {
+		t.Error("hits query still has aggregations")
+	}
+	if hits["size"] != 20 || hits["from"] != 40 || hits["timeout"] != "2000ms" {
+		t.Errorf("hits query = %v", hits)
+	}
+	if _, ok := query["aggs"]; !ok {
+		t.Error("splitting modified the original query")
+	}
+
+	if facets == nil {
+		t.Fatal("no facet query")
+	}
+	if facets["size"] != 0 || facets["timeout"] != "750ms" {
+		t.Errorf("facet query = %v", facets)
+	}
+	if _, ok := facets["from"]; ok {
+		t.Error("facet query is paginated")
+	}
+	if facets["query"] == nil || facets["aggs"] == nil {
+		t.Errorf("facet query lost its query or aggregations: %v", facets)
+	}
+}
+
+func TestSplitFacetQueryWithoutAggregations(t *testing.T) {
+	hits, facets := splitFacetQuery(map[string]interface{}{"size": 10}, searchBudgets{})
+	if facets != nil {
+		t.Errorf("facets = %v, want none", facets)
+	}
+	if _, ok := hits["timeout"]; ok {
+		t.Error("hits query has a timeout with no budget")
+	}
+}
+
+func TestSearchBudgetsFromEnv(t *testing.T) {
+	t.Setenv("SEARCH_QUERY_TIMEOUT", "3s")
+	t.Setenv("SEARCH_FACETS_TIMEOUT", "not a duration")
+	t.Setenv("SEARCH_HYDRATION_TIMEOUT", "-1s")
+
+	budgets := searchBudgetsFromEnv()
+	if budgets.Query != 3*time.Second {
+		t.Errorf("Query = %v, want 3s", budgets.Query)
+	}
+	if budgets.Facets != defaultSearchBudgets.Facets || budgets.Hydration != defaultSearchBudgets.Hydration {
+		t.Errorf("invalid budgets weren't defaulted: %+v", budgets)
+	}
+}
+
+func TestHydrateWithinBudget(t *testing.T) {
+	ss := &SearchService{budgets: searchBudgets{Hydration: 10 * time.Millisecond}}
+
+	if ss.hydrateWithinBudget(context.Background(), "works", func(context.Context) {}) {
+		t.Error("fast hydration reported as truncated")
+	}
+	slow := func(ctx context.Context) { <-ctx.Done() }
+	if !ss.hydrateWithinBudget(context.Background(), "works", slow) {
+		t.Error("hydration over budget not reported as truncated")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+
+	"nuclear-ao3/shared/pagination"
+)
+
+// Work search results page by cursor as well as by page number. A cursor
+// carries the sort values of the last result of a page, which
+// Elasticsearch continues after with search_after, so deep pages cost the
+// same as the first and works indexed in between don't repeat results.
+
+// workCursorSort names the order a work search cursor continues
+func workCursorSort(req WorkSearchRequest) string {
+	return "search:works:" + req.SortBy + ":" + req.SortOrder
+}
+
+// applyCursor decodes a search's cursor into the sort values to continue
+// after. An empty cursor asks for the first page.
+func applyCursor(req *WorkSearchRequest) error {
+	if req.Cursor == nil || *req.Cursor == "" {
+		return nil
+	}
+	var after []json.RawMessage
+	if err := pagination.Decode(*req.Cursor, workCursorSort(*req), &after); err != nil || len(after) == 0 {
+		return pagination.ErrInvalidCursor
+	}
+	req.searchAfter = after
+	return nil
+}
+
+// withCursor pages a work query by search_after when the request has a
+// cursor. Ties are broken by work ID so every position is unique, and one
+// extra result tells whether there's a next page.
+func withCursor(query map[string]interface{}, req WorkSearchRequest) map[string]interface{} {
+	if req.Cursor == nil {
+		return query
+	}
+	sort, _ := query["sort"].([]map[string]interface{})
+	query["sort"] = append(append([]map[string]interface{}{}, sort...),
+		map[string]interface{}{"id": map[string]interface{}{"order": "asc", "unmapped_type": "keyword"}})
+	query["size"] = req.Limit + 1
+	delete(query, "from")
+	if len(req.searchAfter) > 0 {
+		query["search_after"] = req.searchAfter
+	}
+	return query
+}
+
+// nextWorkCursor trims a cursor page's extra result and returns the cursor
+// continuing after the page, or "" on the last page. sortValues are the
+// sort values of each result.
+func nextWorkCursor(req WorkSearchRequest, results []map[string]interface{}, sortValues []interface{}) ([]map[string]interface{}, string) {
+	if len(results) <= req.Limit {
+		return results, ""
+	}
+	return results[:req.Limit], pagination.Encode(workCursorSort(req), sortValues[req.Limit-1])
+}
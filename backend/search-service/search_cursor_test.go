@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"nuclear-ao3/shared/pagination"
+)
+
+func TestBuildWorkSearchQueryWithCursor(t *testing.T) {
+	ss := &SearchService{}
+	req := WorkSearchRequest{Page: 3, Limit: 20, SortBy: "kudos", SortOrder: "desc"}
+
+	if _, ok := ss.buildWorkSearchQuery(req)["from"]; !ok {
+		t.Fatal("Without a cursor a search should keep paging by offset")
+	}
+
+	first := ""
+	req.Cursor = &first
+	query := ss.buildWorkSearchQuery(req)
+	if _, ok := query["from"]; ok {
+		t.Error("A cursor search shouldn't page by offset")
+	}
+	if query["size"] != 21 {
+		t.Errorf("size = %v, want one extra result to detect a next page", query["size"])
+	}
+	if _, ok := query["search_after"]; ok {
+		t.Error("An empty cursor should start at the first result")
+	}
+	sort := query["sort"].([]map[string]interface{})
+	if _, ok := sort[len(sort)-1]["id"]; !ok {
+		t.Errorf("Cursor sorts should break ties by ID, got %v", sort)
+	}
+
+	cursor := pagination.Encode(workCursorSort(req), []interface{}{120, "9b2f7c1e-0000-4000-8000-000000000001"})
+	req.Cursor = &cursor
+	if err := applyCursor(&req); err != nil {
+		t.Fatalf("applyCursor: %v", err)
+	}
+	after, _ := json.Marshal(ss.buildWorkSearchQuery(req)["search_after"])
+	if string(after) != `[120,"9b2f7c1e-0000-4000-8000-000000000001"]` {
+		t.Errorf("search_after = %s", after)
+	}
+
+	req.SortOrder = "asc"
+	if err := applyCursor(&req); !errors.Is(err, pagination.ErrInvalidCursor) {
+		t.Errorf("A cursor shouldn't continue another order, got %v", err)
+	}
+}
+
+func TestNextWorkCursor(t *testing.T) {
+	req := WorkSearchRequest{Limit: 2, SortBy: "updated_at", SortOrder: "desc"}
+	results := []map[string]interface{}{{"id": "a"}, {"id": "b"}, {"id": "c"}}
+	sortValues := []interface{}{[]interface{}{3, "a"}, []interface{}{2, "b"}, []interface{}{1, "c"}}
+
+	page, next := nextWorkCursor(req, results, sortValues)
+	if len(page) != 2 || next == "" {
+		t.Fatalf("Expected a trimmed page and a next cursor, got %d results and %q", len(page), next)
+	}
+	var after []json.RawMessage
+	if err := pagination.Decode(next, workCursorSort(req), &after); err != nil || string(after[1]) != `"b"` {
+		t.Errorf("The next cursor should continue after the last result shown, got %s (%v)", after, err)
+	}
+
+	if _, next := nextWorkCursor(req, results[:2], sortValues[:2]); next != "" {
+		t.Error("The last page shouldn't have a next cursor")
+	}
+}
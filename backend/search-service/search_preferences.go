@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// searchPrefsCacheTTL is how long a reader's preferences are cached
+const searchPrefsCacheTTL = 10 * time.Minute
+
+// SearchPreferences is how a reader wants their work searches ranked
+type SearchPreferences struct {
+	// PreferredLanguages rank works in these languages higher
+	PreferredLanguages []string `json:"preferred_languages"`
+	// PersonalizedRanking ranks works from the reader's most read fandoms
+	// and tags higher; off means neutral ranking
+	PersonalizedRanking bool `json:"personalized_ranking"`
+}
+
+func searchPrefsCacheKey(userID uuid.UUID) string {
+	return "search_prefs:" + userID.String()
+}
+
+// searchPreferences loads a reader's preferences, cached in Redis. Readers
+// who never saved any get the defaults: no preferred languages and neutral
+// ranking.
+func (ss *SearchService) searchPreferences(ctx context.Context, userID uuid.UUID) (SearchPreferences, error) {
+	key := searchPrefsCacheKey(userID)
+	if ss.redis != nil {
+		if cached, err := ss.redis.Get(ctx, key).Result(); err == nil {
+			var prefs SearchPreferences
+			if json.Unmarshal([]byte(cached), &prefs) == nil {
+				return prefs, nil
+			}
+		}
+	}
+
+	var languages pq.StringArray
+	prefs := SearchPreferences{}
+	err := ss.db.QueryRowContext(ctx, `
+		SELECT preferred_languages, personalized_ranking FROM search_preferences WHERE user_id = $1`,
+		userID).Scan(&languages, &prefs.PersonalizedRanking)
+	if err != nil && err != sql.ErrNoRows {
+		return prefs, err
+	}
+	prefs.PreferredLanguages = []string(languages)
+	if prefs.PreferredLanguages == nil {
+		prefs.PreferredLanguages = []string{}
+	}
+
+	if ss.redis != nil {
+		if data, err := json.Marshal(prefs); err == nil {
+			ss.redis.Set(ctx, key, data, searchPrefsCacheTTL)
+		}
+	}
+	return prefs, nil
+}
+
+// GetSearchPreferences returns the reader's search preferences.
+// GET /api/v1/search/preferences
+func (ss *SearchService) GetSearchPreferences(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	prefs, err := ss.searchPreferences(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdateSearchPreferences changes the preferences given in the body and
+// keeps the rest. An empty preferred_languages turns the language boost
+// off; personalized_ranking false returns to neutral ranking.
+// PUT /api/v1/search/preferences
+func (ss *SearchService) UpdateSearchPreferences(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req struct {
+		PreferredLanguages  *[]string `json:"preferred_languages"`
+		PersonalizedRanking *bool     `json:"personalized_ranking"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var languages interface{}
+	if req.PreferredLanguages != nil {
+		normalized, err := normalizePreferredLanguages(*req.PreferredLanguages)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		languages = pq.Array(normalized)
+	}
+
+	ctx := c.Request.Context()
+	_, err := ss.db.ExecContext(ctx, `
+		INSERT INTO search_preferences (user_id, preferred_languages, personalized_ranking, updated_at)
+		VALUES ($1, COALESCE($2::varchar[], '{}'), COALESCE($3, false), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			preferred_languages = COALESCE($2::varchar[], search_preferences.preferred_languages),
+			personalized_ranking = COALESCE($3, search_preferences.personalized_ranking),
+			updated_at = NOW()`,
+		userID, languages, req.PersonalizedRanking)
+	if err != nil {
+		log.Printf("Failed to save search preferences for %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save search preferences"})
+		return
+	}
+	if ss.redis != nil {
+		ss.redis.Del(ctx, searchPrefsCacheKey(userID))
+	}
+
+	prefs, err := ss.searchPreferences(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
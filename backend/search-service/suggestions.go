@@ -0,0 +1,568 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Search-as-you-type suggestions come from one completion field in the
+// "suggestions" index. Every entry carries a type (work, tag or author)
+// that the field uses as a category context, so one request can ask for
+// each kind separately. Work titles follow work events; canonical tags and
+// the pseuds of visible works are synced from Postgres on an interval, and
+// entries that weren't seen in a full sync are swept away.
+
+const (
+	suggestionIndex = "suggestions"
+	// suggestionWordStarts is how many words into a title a prefix may begin
+	suggestionWordStarts = 4
+	suggestionBatchSize  = 1000
+	suggestionSyncLock   = "search:suggestion_sync:lock"
+	suggestionCacheTTL   = 10 * time.Minute
+	// A prefix is hot once requested suggestionHotThreshold times within
+	// suggestionHeatWindow; only hot prefixes are cached
+	suggestionHotThreshold = 3
+	suggestionHeatWindow   = 5 * time.Minute
+	// suggestionAlwaysHotLen covers the short prefixes every search starts with
+	suggestionAlwaysHotLen = 3
+)
+
+// Suggestion types, used as the completion field's context
+const (
+	suggestionWork   = "work"
+	suggestionTag    = "tag"
+	suggestionAuthor = "author"
+)
+
+// suggestionTypeKeys maps the type query parameter, and the response keys,
+// to suggestion types
+var suggestionTypeKeys = map[string]string{
+	"works":   suggestionWork,
+	"tags":    suggestionTag,
+	"authors": suggestionAuthor,
+}
+
+// suggestionCompletion is the completion field of a suggestion document.
+// Its context comes from the document's type field.
+type suggestionCompletion struct {
+	Input  []string `json:"input"`
+	Weight int      `json:"weight"`
+}
+
+// suggestionDocument is one entry of the suggestions index
+type suggestionDocument struct {
+	Suggest  suggestionCompletion `json:"suggest"`
+	Type     string               `json:"type"`
+	Text     string               `json:"text"`
+	RefID    string               `json:"ref_id"`
+	TagType  string               `json:"tag_type,omitempty"`
+	SyncedAt time.Time            `json:"synced_at"`
+}
+
+// suggestionID is the document id of a suggestion
+func suggestionID(suggestionType, refID string) string {
+	return suggestionType + ":" + refID
+}
+
+// newSuggestion builds the suggestion for text
+func newSuggestion(suggestionType, refID, text string, weight int) suggestionDocument {
+	return suggestionDocument{
+		Suggest:  suggestionCompletion{Input: suggestionInputs(text), Weight: clampSuggestionWeight(weight)},
+		Type:     suggestionType,
+		Text:     text,
+		RefID:    refID,
+		SyncedAt: time.Now(),
+	}
+}
+
+// suggestionInputs is text plus the rest of it from each of its next few
+// words, so "The Long Way Home" is suggested for "long" and "way" too
+func suggestionInputs(text string) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	inputs := []string{strings.Join(words, " ")}
+	for i := 1; i < len(words) && i <= suggestionWordStarts; i++ {
+		inputs = append(inputs, strings.Join(words[i:], " "))
+	}
+	return inputs
+}
+
+// clampSuggestionWeight keeps a weight within what Elasticsearch accepts
+func clampSuggestionWeight(weight int) int {
+	if weight < 0 {
+		return 0
+	}
+	if weight > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return weight
+}
+
+// workSuggestion is the suggestion for a work's title, weighted by how
+// much readers engage with it. Restricted works aren't suggested.
+func workSuggestion(doc WorkIndexDocument) (suggestionDocument, bool) {
+	if doc.IsRestricted || strings.TrimSpace(doc.Title) == "" {
+		return suggestionDocument{}, false
+	}
+	weight := doc.Kudos + 2*doc.Bookmarks + doc.Hits/100
+	return newSuggestion(suggestionWork, doc.WorkID, doc.Title, weight), true
+}
+
+// parseSuggestionTypes turns the type query parameter, "all" or a
+// comma-separated list of works, tags and authors, into response keys
+func parseSuggestionTypes(param string) ([]string, error) {
+	if param == "" || param == "all" {
+		return []string{"works", "tags", "authors"}, nil
+	}
+	var keys []string
+	seen := map[string]bool{}
+	for _, key := range strings.Split(param, ",") {
+		key = strings.TrimSpace(key)
+		if _, ok := suggestionTypeKeys[key]; !ok {
+			return nil, fmt.Errorf("unknown suggestion type %q", key)
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// normalizeSuggestionPrefix lowercases a prefix and collapses its spaces,
+// so equivalent prefixes share a cache entry
+func normalizeSuggestionPrefix(prefix string) string {
+	return strings.ToLower(strings.Join(strings.Fields(prefix), " "))
+}
+
+// suggestionQuery is a search body with one completion suggester per
+// requested type. Fuzzy matching kicks in from three characters, and the
+// first character must match so results stay anchored to what was typed.
+func suggestionQuery(prefix string, keys []string, size int) map[string]interface{} {
+	suggesters := map[string]interface{}{}
+	for _, key := range keys {
+		suggesters[key] = map[string]interface{}{
+			"prefix": prefix,
+			"completion": map[string]interface{}{
+				"field":           "suggest",
+				"size":            size,
+				"skip_duplicates": true,
+				"fuzzy": map[string]interface{}{
+					"fuzziness":     "AUTO",
+					"min_length":    3,
+					"prefix_length": 1,
+				},
+				"contexts": map[string]interface{}{
+					"type": []string{suggestionTypeKeys[key]},
+				},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"_source": []string{"text", "ref_id"},
+		"suggest": suggesters,
+	}
+}
+
+// parseSuggestions reads each suggester's options from a search response.
+// The stored text is returned rather than the matched input, which may be
+// the tail of a title.
+func parseSuggestions(body []byte, keys []string) (map[string][]string, error) {
+	var response struct {
+		Suggest map[string][]struct {
+			Options []struct {
+				Text   string `json:"text"`
+				Source struct {
+					Text string `json:"text"`
+				} `json:"_source"`
+			} `json:"options"`
+		} `json:"suggest"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse suggest response: %w", err)
+	}
+
+	suggestions := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		suggestions[key] = []string{}
+		seen := map[string]bool{}
+		for _, entry := range response.Suggest[key] {
+			for _, option := range entry.Options {
+				text := option.Source.Text
+				if text == "" {
+					text = option.Text
+				}
+				if !seen[text] {
+					seen[text] = true
+					suggestions[key] = append(suggestions[key], text)
+				}
+			}
+		}
+	}
+	return suggestions, nil
+}
+
+// GetSuggestions suggests works, tags and authors for what has been typed
+// so far. GET /api/v1/search/suggestions?q=har&type=tags,authors&limit=10
+func (ss *SearchService) GetSuggestions(c *gin.Context) {
+	prefix := normalizeSuggestionPrefix(c.Query("q"))
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
+		return
+	}
+	keys, err := parseSuggestionTypes(c.DefaultQuery("type", "all"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 {
+		limit = 10
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	ctx := c.Request.Context()
+	cacheKey := fmt.Sprintf("suggestions:%s:%d:%s", strings.Join(keys, ","), limit, prefix)
+	if cached, err := ss.redis.Get(ctx, cacheKey).Bytes(); err == nil {
+		var suggestions map[string][]string
+		if json.Unmarshal(cached, &suggestions) == nil {
+			c.JSON(http.StatusOK, suggestions)
+			return
+		}
+	}
+
+	suggestions, err := ss.suggest(ctx, prefix, keys, limit)
+	if err != nil {
+		log.Printf("Suggestions for %q failed: %v", prefix, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Suggestion request failed"})
+		return
+	}
+
+	if ss.hotSuggestionPrefix(ctx, prefix) {
+		if data, err := json.Marshal(suggestions); err == nil {
+			ss.redis.Set(ctx, cacheKey, data, suggestionCacheTTL)
+		}
+	}
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// suggest runs the completion suggesters for prefix
+func (ss *SearchService) suggest(ctx context.Context, prefix string, keys []string, size int) (map[string][]string, error) {
+	body, err := json.Marshal(suggestionQuery(prefix, keys, size))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	res, err := ss.es.Search(
+		ss.es.Search.WithContext(ctx),
+		ss.es.Search.WithIndex(suggestionIndex),
+		ss.es.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("suggest request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("suggest request returned error: %s", res.String())
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(res.Body); err != nil {
+		return nil, err
+	}
+	return parseSuggestions(buf.Bytes(), keys)
+}
+
+// hotSuggestionPrefix counts a request for prefix and reports whether it's
+// requested often enough to be worth caching. Short prefixes always are.
+func (ss *SearchService) hotSuggestionPrefix(ctx context.Context, prefix string) bool {
+	if len([]rune(prefix)) <= suggestionAlwaysHotLen {
+		return true
+	}
+	key := "suggestions:heat:" + prefix
+	count, err := ss.redis.Incr(ctx, key).Result()
+	if err != nil {
+		return false
+	}
+	if count == 1 {
+		ss.redis.Expire(ctx, key, suggestionHeatWindow)
+	}
+	return count >= suggestionHotThreshold
+}
+
+// indexWorkSuggestion updates a work's title suggestion at the version of
+// its work event, removing it when the work can't be suggested
+func (ss *SearchService) indexWorkSuggestion(doc WorkIndexDocument, version int64) error {
+	suggestion, ok := workSuggestion(doc)
+	if !ok {
+		return ss.deleteWorkSuggestion(doc.WorkID, version)
+	}
+	data, err := json.Marshal(suggestion)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := ss.es.Index(
+		suggestionIndex,
+		bytes.NewReader(data),
+		ss.es.Index.WithContext(ctx),
+		ss.es.Index.WithDocumentID(suggestionID(suggestionWork, doc.WorkID)),
+		ss.es.Index.WithVersion(int(version)),
+		ss.es.Index.WithVersionType("external"),
+	)
+	if err != nil {
+		return fmt.Errorf("suggestion index request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != http.StatusConflict {
+		return fmt.Errorf("suggestion index request returned error: %s", res.String())
+	}
+	return nil
+}
+
+// deleteWorkSuggestion removes a work's title suggestion
+func (ss *SearchService) deleteWorkSuggestion(workID string, version int64) error {
+	return ss.deleteWorkAtVersion(suggestionIndex, suggestionID(suggestionWork, workID), version)
+}
+
+// suggestionBulkBody is a bulk request indexing suggestions, at an
+// external version unless version is 0
+func suggestionBulkBody(version int64, docs []suggestionDocument) ([]byte, error) {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		meta := map[string]interface{}{
+			"_index": suggestionIndex,
+			"_id":    suggestionID(doc.Type, doc.RefID),
+		}
+		if version > 0 {
+			meta["version"] = version
+			meta["version_type"] = "external"
+		}
+		action, err := json.Marshal(map[string]interface{}{"index": meta})
+		if err != nil {
+			return nil, err
+		}
+		source, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(source)
+		body.WriteByte('\n')
+	}
+	return body.Bytes(), nil
+}
+
+// bulkIndexSuggestions writes suggestions, returning how many failed
+func (ss *SearchService) bulkIndexSuggestions(ctx context.Context, version int64, docs []suggestionDocument) (int64, error) {
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	body, err := suggestionBulkBody(version, docs)
+	if err != nil {
+		return 0, err
+	}
+	res, err := ss.es.Bulk(bytes.NewReader(body), ss.es.Bulk.WithContext(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("suggestion bulk request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, fmt.Errorf("suggestion bulk request returned error: %s", res.String())
+	}
+	_, failed, err := countBulkResults(res.Body)
+	return failed, err
+}
+
+// startSuggestionSync refreshes tag and author suggestions every
+// SUGGESTION_SYNC_INTERVAL until ctx is cancelled. Only one instance syncs
+// at a time.
+func (ss *SearchService) startSuggestionSync(ctx context.Context) {
+	if ss.db == nil || ss.redis == nil {
+		log.Println("Suggestion sync disabled: no database or Redis connection")
+		return
+	}
+	interval := 15 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("SUGGESTION_SYNC_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+
+	for {
+		acquired, err := ss.redis.SetNX(ctx, suggestionSyncLock, workEventConsumerName(), interval).Result()
+		if err == nil && acquired {
+			if err := ss.syncSuggestions(ctx); err != nil {
+				log.Printf("Suggestion sync failed: %v", err)
+			}
+		}
+		if !sleepContext(ctx, interval) {
+			return
+		}
+	}
+}
+
+// syncSuggestions reloads every tag and author suggestion, then removes
+// those that weren't reloaded. A failed pass leaves old entries in place.
+func (ss *SearchService) syncSuggestions(ctx context.Context) error {
+	started := time.Now()
+	loaders := []func(context.Context, string) ([]suggestionDocument, string, error){
+		ss.tagSuggestionsAfter,
+		ss.authorSuggestionsAfter,
+	}
+
+	var synced int
+	for _, load := range loaders {
+		after := "00000000-0000-0000-0000-000000000000"
+		for {
+			docs, last, err := load(ctx, after)
+			if err != nil {
+				return err
+			}
+			failed, err := ss.bulkIndexSuggestions(ctx, 0, docs)
+			if err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d suggestions failed to index", failed)
+			}
+			synced += len(docs)
+			if len(docs) < suggestionBatchSize {
+				break
+			}
+			after = last
+		}
+	}
+
+	if err := ss.sweepSuggestions(ctx, started); err != nil {
+		return err
+	}
+	log.Printf("Synced %d tag and author suggestions in %s", synced, time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// tagSuggestionsAfter is the next page of canonical tag suggestions after
+// the tag id after, weighted by how many works use the tag
+func (ss *SearchService) tagSuggestionsAfter(ctx context.Context, after string) ([]suggestionDocument, string, error) {
+	rows, err := ss.readDB().QueryContext(ctx, `
+		SELECT id, name, type, COALESCE(use_count, 0)
+		FROM tags
+		WHERE is_canonical = true AND id > $1::uuid
+		ORDER BY id
+		LIMIT $2`, after, suggestionBatchSize)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var docs []suggestionDocument
+	var last string
+	for rows.Next() {
+		var id, name, tagType string
+		var uses int
+		if err := rows.Scan(&id, &name, &tagType, &uses); err != nil {
+			return nil, "", err
+		}
+		doc := newSuggestion(suggestionTag, id, name, uses)
+		doc.TagType = tagType
+		docs = append(docs, doc)
+		last = id
+	}
+	return docs, last, rows.Err()
+}
+
+// authorSuggestionsAfter is the next page of pseud suggestions after the
+// pseud id after. Only pseuds credited on a visible, non-anonymous work
+// are suggested, weighted by how many such works they have.
+func (ss *SearchService) authorSuggestionsAfter(ctx context.Context, after string) ([]suggestionDocument, string, error) {
+	rows, err := ss.readDB().QueryContext(ctx, `
+		SELECT p.id, p.name, COUNT(DISTINCT w.id)
+		FROM pseuds p
+		JOIN creatorships c ON c.pseud_id = p.id AND c.creation_type = 'Work' AND c.approved = true
+		JOIN works w ON w.id = c.creation_id
+		WHERE p.id > $1::uuid AND `+searchableWorksSQL+`
+			AND NOT COALESCE(w.is_anonymous, false) AND NOT COALESCE(w.in_anon_collection, false)
+			AND NOT COALESCE(w.restricted_to_users, false)
+		GROUP BY p.id, p.name
+		ORDER BY p.id
+		LIMIT $2`, after, suggestionBatchSize)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var docs []suggestionDocument
+	var last string
+	for rows.Next() {
+		var id, name string
+		var works int
+		if err := rows.Scan(&id, &name, &works); err != nil {
+			return nil, "", err
+		}
+		docs = append(docs, newSuggestion(suggestionAuthor, id, name, works))
+		last = id
+	}
+	return docs, last, rows.Err()
+}
+
+// sweepSuggestions deletes tag and author suggestions not synced since
+// started, such as tags that stopped being canonical
+func (ss *SearchService) sweepSuggestions(ctx context.Context, started time.Time) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"terms": map[string]interface{}{"type": []string{suggestionTag, suggestionAuthor}}},
+					map[string]interface{}{"range": map[string]interface{}{"synced_at": map[string]interface{}{"lt": started}}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	res, err := ss.es.DeleteByQuery([]string{suggestionIndex}, bytes.NewReader(body), ss.es.DeleteByQuery.WithContext(ctx))
+	return esResult(res, err, "sweep stale suggestions")
+}
+
+// workSuggestionsForRebuild is the title suggestions of works loaded by an
+// index rebuild
+func workSuggestionsForRebuild(docs []WorkIndexDocument) []suggestionDocument {
+	suggestions := make([]suggestionDocument, 0, len(docs))
+	for _, doc := range docs {
+		if suggestion, ok := workSuggestion(doc); ok {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions
+}
+
+// refreshWorkSuggestions indexes the title suggestions of a rebuild batch.
+// Suggestions are a convenience, so failures are logged rather than
+// failing the rebuild.
+func (ss *SearchService) refreshWorkSuggestions(ctx context.Context, version int64, docs []WorkIndexDocument) {
+	failed, err := ss.bulkIndexSuggestions(ctx, version, workSuggestionsForRebuild(docs))
+	if err != nil {
+		log.Printf("Failed to index work suggestions: %v", err)
+	} else if failed > 0 {
+		log.Printf("%d work suggestions failed to index", failed)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSuggestionInputs(t *testing.T) {
+	got := suggestionInputs("  The Long   Way Home ")
+	want := []string{"The Long Way Home", "Long Way Home", "Way Home", "Home"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("suggestionInputs = %q, want %q", got, want)
+	}
+
+	long := suggestionInputs("one two three four five six seven")
+	if len(long) != suggestionWordStarts+1 {
+		t.Errorf("expected %d inputs for a long title, got %d", suggestionWordStarts+1, len(long))
+	}
+	if suggestionInputs("   ") != nil {
+		t.Error("expected no inputs for blank text")
+	}
+}
+
+func TestParseSuggestionTypes(t *testing.T) {
+	all, err := parseSuggestionTypes("all")
+	if err != nil || !reflect.DeepEqual(all, []string{"works", "tags", "authors"}) {
+		t.Errorf("all = %v, %v", all, err)
+	}
+	some, err := parseSuggestionTypes("tags, authors,tags")
+	if err != nil || !reflect.DeepEqual(some, []string{"tags", "authors"}) {
+		t.Errorf("tags,authors = %v, %v", some, err)
+	}
+	if _, err := parseSuggestionTypes("series"); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+}
+
+func TestSuggestionQuery(t *testing.T) {
+	query := suggestionQuery("harr", []string{"tags"}, 5)
+	suggesters := query["suggest"].(map[string]interface{})
+	if len(suggesters) != 1 {
+		t.Fatalf("expected one suggester, got %d", len(suggesters))
+	}
+	completion := suggesters["tags"].(map[string]interface{})["completion"].(map[string]interface{})
+	contexts := completion["contexts"].(map[string]interface{})
+	if !reflect.DeepEqual(contexts["type"], []string{suggestionTag}) {
+		t.Errorf("tags suggester context = %v", contexts["type"])
+	}
+	if completion["size"] != 5 || completion["fuzzy"] == nil {
+		t.Errorf("unexpected completion options %v", completion)
+	}
+}
+
+func TestParseSuggestions(t *testing.T) {
+	body := []byte(`{"suggest": {
+		"works": [{"text": "way", "options": [
+			{"text": "Way Home", "_source": {"text": "The Long Way Home"}},
+			{"text": "The Long Way Home", "_source": {"text": "The Long Way Home"}}
+		]}],
+		"tags": [{"text": "har", "options": [{"text": "Harry Potter", "_source": {"text": "Harry Potter"}}]}]
+	}}`)
+
+	got, err := parseSuggestions(body, []string{"works", "tags", "authors"})
+	if err != nil {
+		t.Fatalf("parseSuggestions: %v", err)
+	}
+	want := map[string][]string{
+		"works":   {"The Long Way Home"},
+		"tags":    {"Harry Potter"},
+		"authors": {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSuggestions = %v, want %v", got, want)
+	}
+}
+
+func TestWorkSuggestion(t *testing.T) {
+	doc := WorkIndexDocument{WorkID: "w1", Title: "Home", Kudos: 10, Bookmarks: 2, Hits: 500}
+	suggestion, ok := workSuggestion(doc)
+	if !ok {
+		t.Fatal("expected a suggestion for a public work")
+	}
+	if suggestion.Type != suggestionWork || suggestion.Suggest.Weight != 19 {
+		t.Errorf("unexpected suggestion %+v", suggestion)
+	}
+
+	doc.IsRestricted = true
+	if _, ok := workSuggestion(doc); ok {
+		t.Error("restricted works must not be suggested")
+	}
+}
+
+func TestSuggestionBulkBody(t *testing.T) {
+	docs := []suggestionDocument{newSuggestion(suggestionTag, "t1", "Angst", 3)}
+
+	body, err := suggestionBulkBody(0, docs)
+	if err != nil {
+		t.Fatalf("suggestionBulkBody: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected an action and a source line, got %d lines", len(lines))
+	}
+	var action map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &action); err != nil {
+		t.Fatal(err)
+	}
+	if action["index"]["_id"] != "tag:t1" || action["index"]["version"] != nil {
+		t.Errorf("unexpected action %v", action)
+	}
+
+	versioned, _ := suggestionBulkBody(42, docs)
+	if !bytes.Contains(versioned, []byte(`"version_type":"external"`)) {
+		t.Error("expected an external version when one is given")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Canonical tag expansion: a search for a tag also matches works tagged with
+// its synonyms and sub-tags. Expansions come from tag-service, which knows
+// the wrangled hierarchy, and are cached in Redis.
+
+const (
+	tagExpansionCachePrefix = "tag_expansion:"
+	tagExpansionTTL         = 10 * time.Minute
+	tagExpansionTimeout     = 2 * time.Second
+)
+
+var tagExpansionClient = &http.Client{Timeout: tagExpansionTimeout}
+
+// applyTagExpansion widens the request's tag filters and exclusions to the
+// full synonym/descendant set of each tag. When tag-service can't be
+// reached the tags are searched as given.
+func (ss *SearchService) applyTagExpansion(ctx context.Context, req *WorkSearchRequest) {
+	if req.ExactTags {
+		return
+	}
+	lists := []*[]string{
+		&req.Fandoms, &req.Characters, &req.Relationships, &req.Tags,
+		&req.ExcludeFandoms, &req.ExcludeCharacters, &req.ExcludeRelationships, &req.ExcludeTags,
+	}
+
+	var queryTags []*queryNode
+	if req.parsedQuery != nil {
+		queryTags = req.parsedQuery.tagFieldNodes()
+	}
+
+	names := []string{}
+	for _, list := range lists {
+		names = append(names, *list...)
+	}
+	for _, node := range queryTags {
+		names = append(names, node.value)
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	expansions := ss.expandTagNames(ctx, names)
+	for _, list := range lists {
+		*list = expandTagList(*list, expansions)
+	}
+	// fandom:, tag: and the like in q widen the same way
+	for _, node := range queryTags {
+		node.values = expandTagList([]string{node.value}, expansions)
+	}
+}
+
+// expandTagList replaces each name with its expansion, keeping order and
+// dropping duplicates
+func expandTagList(names []string, expansions map[string][]string) []string {
+	if len(names) == 0 {
+		return names
+	}
+	seen := map[string]bool{}
+	expanded := []string{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			expanded = append(expanded, name)
+		}
+	}
+	for _, name := range names {
+		add(name)
+		for _, e := range expansions[strings.ToLower(strings.TrimSpace(name))] {
+			add(e)
+		}
+	}
+	return expanded
+}
+
+// expandTagNames returns the expansion of each name, keyed by lowercased
+// name, from Redis where cached and tag-service otherwise
+func (ss *SearchService) expandTagNames(ctx context.Context, names []string) map[string][]string {
+	expansions := map[string][]string{}
+	missing := []string{}
+	seen := map[string]bool{}
+
+	for _, name := range names {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if ss.redis != nil {
+			if cached, err := ss.redis.Get(ctx, tagExpansionCachePrefix+key).Result(); err == nil {
+				var expansion []string
+				if json.Unmarshal([]byte(cached), &expansion) == nil {
+					expansions[key] = expansion
+					continue
+				}
+			}
+		}
+		missing = append(missing, strings.TrimSpace(name))
+	}
+	if len(missing) == 0 {
+		return expansions
+	}
+
+	fetched, err := fetchTagExpansions(ctx, missing)
+	if err != nil {
+		log.Printf("Tag expansion unavailable, searching tags as given: %v", err)
+		return expansions
+	}
+	for name, expansion := range fetched {
+		key := strings.ToLower(name)
+		expansions[key] = expansion
+		if ss.redis != nil {
+			if data, err := json.Marshal(expansion); err == nil {
+				ss.redis.Set(ctx, tagExpansionCachePrefix+key, data, tagExpansionTTL)
+			}
+		}
+	}
+	return expansions
+}
+
+// fetchTagExpansions asks tag-service to expand names
+func fetchTagExpansions(ctx context.Context, names []string) (map[string][]string, error) {
+	params := url.Values{}
+	for _, name := range names {
+		params.Add("name", name)
+	}
+	endpoint := strings.TrimSuffix(getEnv("TAG_SERVICE_URL", "http://localhost:8083"), "/") + "/api/v1/tags/expand?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := tagExpansionClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tag-service returned status %d", resp.StatusCode)
+	}
+	var body struct {
+		Expansions map[string][]string `json:"expansions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Expansions, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestApplyTagExpansion(t *testing.T) {
+	var asked []string
+	tagService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/tags/expand" {
+			http.NotFound(w, r)
+			return
+		}
+		asked = r.URL.Query()["name"]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"expansions": map[string][]string{
+				"Harry Potter": {"Harry Potter", "Harry Potter - J. K. Rowling", "HP"},
+				"angst":        {"Angst", "Angst with a Happy Ending"},
+			},
+		})
+	}))
+	defer tagService.Close()
+	t.Setenv("TAG_SERVICE_URL", tagService.URL)
+
+	ss := &SearchService{}
+	req := WorkSearchRequest{
+		Fandoms:     []string{"Harry Potter"},
+		ExcludeTags: []string{"angst"},
+	}
+	ss.applyTagExpansion(context.Background(), &req)
+
+	if !reflect.DeepEqual(asked, []string{"Harry Potter", "angst"}) {
+		t.Errorf("asked tag-service for %v", asked)
+	}
+	if want := []string{"Harry Potter", "Harry Potter - J. K. Rowling", "HP"}; !reflect.DeepEqual(req.Fandoms, want) {
+		t.Errorf("fandoms = %v, want %v", req.Fandoms, want)
+	}
+	if want := []string{"angst", "Angst", "Angst with a Happy Ending"}; !reflect.DeepEqual(req.ExcludeTags, want) {
+		t.Errorf("excluded tags = %v, want %v", req.ExcludeTags, want)
+	}
+
+	exact := WorkSearchRequest{Fandoms: []string{"Harry Potter"}, ExactTags: true}
+	ss.applyTagExpansion(context.Background(), &exact)
+	if len(exact.Fandoms) != 1 {
+		t.Errorf("exact_tags should leave tags as given, got %v", exact.Fandoms)
+	}
+}
+
+func TestApplyTagExpansionWithoutTagService(t *testing.T) {
+	t.Setenv("TAG_SERVICE_URL", "http://127.0.0.1:1")
+
+	ss := &SearchService{}
+	req := WorkSearchRequest{Characters: []string{"Hermione Granger"}}
+	ss.applyTagExpansion(context.Background(), &req)
+
+	if !reflect.DeepEqual(req.Characters, []string{"Hermione Granger"}) {
+		t.Errorf("tags should be searched as given when expansion fails, got %v", req.Characters)
+	}
+}
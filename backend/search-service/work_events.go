@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/searchstream"
+)
+
+// Work event consumer: work-service publishes every work change from its
+// outbox to a Redis stream, and this consumer group reindexes them. Events
+// that fail stay pending and are retried once idle; after
+// workEventMaxDeliveries they move to the dead-letter stream, from which an
+// admin can replay them. Documents are indexed with the outbox id as an
+// external version, so a late or replayed event never overwrites a newer one.
+
+const (
+	workEventBatchSize       = 50
+	workEventBlock           = 5 * time.Second
+	workEventRetryIdle       = time.Minute
+	workEventMaxDeliveries   = 5
+	workEventReclaimInterval = 30 * time.Second
+	workDeadLetterMaxLen     = 10000
+)
+
+// startWorkEventConsumer reads the work event stream until ctx is cancelled
+func (ss *SearchService) startWorkEventConsumer(ctx context.Context) {
+	if ss.redis == nil {
+		log.Println("Work event consumer disabled: no Redis connection")
+		return
+	}
+	consumer := workEventConsumerName()
+
+	for ss.ensureWorkEventGroup(ctx) != nil {
+		if !sleepContext(ctx, 5*time.Second) {
+			return
+		}
+	}
+	log.Printf("Work event consumer %s started", consumer)
+
+	lastReclaim := time.Now()
+	for ctx.Err() == nil {
+		streams, err := ss.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    searchstream.ConsumerGroup,
+			Consumer: consumer,
+			Streams:  []string{searchstream.Stream, ">"},
+			Count:    workEventBatchSize,
+			Block:    workEventBlock,
+		}).Result()
+		switch {
+		case err == redis.Nil:
+		case err != nil:
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Reading work events failed: %v", err)
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				ss.ensureWorkEventGroup(ctx)
+			}
+			sleepContext(ctx, time.Second)
+		default:
+			for _, stream := range streams {
+				for _, msg := range stream.Messages {
+					ss.processWorkEventMessage(ctx, msg)
+				}
+			}
+		}
+
+		if time.Since(lastReclaim) >= workEventReclaimInterval {
+			ss.reclaimWorkEvents(ctx, consumer)
+			lastReclaim = time.Now()
+		}
+	}
+	log.Println("Work event consumer stopped")
+}
+
+// ensureWorkEventGroup creates the consumer group, reading the stream from
+// the start so events published before the first deploy aren't skipped
+func (ss *SearchService) ensureWorkEventGroup(ctx context.Context) error {
+	err := ss.redis.XGroupCreateMkStream(ctx, searchstream.Stream, searchstream.ConsumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		log.Printf("Failed to create work event consumer group: %v", err)
+		return err
+	}
+	return nil
+}
+
+func workEventConsumerName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "search-service"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// sleepContext waits d, returning false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// processWorkEventMessage applies one event and acknowledges it. A failed
+// event stays pending for reclaimWorkEvents; one that can't be decoded is
+// dead-lettered straight away.
+func (ss *SearchService) processWorkEventMessage(ctx context.Context, msg redis.XMessage) {
+	event, err := searchstream.ParseWorkEvent(msg.Values)
+	if err != nil {
+		log.Printf("Dead-lettering malformed work event %s: %v", msg.ID, err)
+		ss.deadLetterWorkEvent(ctx, msg, err.Error())
+		return
+	}
+
+	if err := ss.applyWorkEvent(event); err != nil {
+		log.Printf("Work event %s for work %s failed, will retry: %v", msg.ID, event.WorkID, err)
+		ss.workEventErrors.Store(msg.ID, err.Error())
+		return
+	}
+	ss.workEventErrors.Delete(msg.ID)
+	if err := ss.redis.XAck(ctx, searchstream.Stream, searchstream.ConsumerGroup, msg.ID).Err(); err != nil {
+		log.Printf("Failed to acknowledge work event %s: %v", msg.ID, err)
+	}
+}
+
+// applyWorkEvent reindexes or removes the event's work, in the index being
+// rebuilt as well while a rebuild runs, and its title suggestion
+func (ss *SearchService) applyWorkEvent(event searchstream.WorkEvent) error {
+	indices := []string{worksAlias}
+	if target := ss.rebuildTargetIndex(context.Background()); target != "" {
+		indices = append(indices, target)
+	}
+
+	if event.Type == searchstream.EventDelete {
+		for _, index := range indices {
+			if err := ss.deleteWorkAtVersion(index, event.WorkID, event.OutboxID); err != nil {
+				return err
+			}
+		}
+		if err := ss.deleteWorkSuggestion(event.WorkID, event.OutboxID); err != nil {
+			return err
+		}
+		return ss.deleteWorkChapters(event.WorkID)
+	}
+
+	var doc WorkIndexDocument
+	if err := json.Unmarshal(event.Document, &doc); err != nil {
+		return fmt.Errorf("invalid work document: %w", err)
+	}
+	doc.WorkID = event.WorkID
+	ss.enhanceWorkDocument(&doc)
+	for _, index := range indices {
+		if err := ss.indexWorkAtVersion(index, doc, event.OutboxID); err != nil {
+			return err
+		}
+	}
+	return ss.indexWorkSuggestion(doc, event.OutboxID)
+}
+
+// indexWorkAtVersion indexes a work unless a newer event already has
+func (ss *SearchService) indexWorkAtVersion(index string, doc WorkIndexDocument, version int64) error {
+	doc.IndexedAt = time.Now()
+	docJSON, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := ss.es.Index(
+		index,
+		bytes.NewReader(docJSON),
+		ss.es.Index.WithContext(ctx),
+		ss.es.Index.WithDocumentID(doc.WorkID),
+		ss.es.Index.WithVersion(int(version)),
+		ss.es.Index.WithVersionType("external"),
+	)
+	if err != nil {
+		return fmt.Errorf("index request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	// A conflict means a newer version is already indexed
+	if res.IsError() && res.StatusCode != http.StatusConflict {
+		return fmt.Errorf("index request returned error: %s", res.String())
+	}
+	return nil
+}
+
+// deleteWorkAtVersion removes a work unless a newer event re-added it
+func (ss *SearchService) deleteWorkAtVersion(index, workID string, version int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := ss.es.Delete(
+		index,
+		workID,
+		ss.es.Delete.WithContext(ctx),
+		ss.es.Delete.WithVersion(int(version)),
+		ss.es.Delete.WithVersionType("external"),
+	)
+	if err != nil {
+		return fmt.Errorf("delete request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound && res.StatusCode != http.StatusConflict {
+		return fmt.Errorf("delete request returned error: %s", res.String())
+	}
+	return nil
+}
+
+// reclaimWorkEvents retries events left pending past workEventRetryIdle,
+// including those of consumers that died, and dead-letters events that
+// have been delivered too often
+func (ss *SearchService) reclaimWorkEvents(ctx context.Context, consumer string) {
+	pending, err := ss.redis.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: searchstream.Stream,
+		Group:  searchstream.ConsumerGroup,
+		Idle:   workEventRetryIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  workEventBatchSize,
+	}).Result()
+	if err != nil {
+		log.Printf("Failed to list pending work events: %v", err)
+		return
+	}
+
+	for _, p := range pending {
+		msgs, err := ss.redis.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   searchstream.Stream,
+			Group:    searchstream.ConsumerGroup,
+			Consumer: consumer,
+			MinIdle:  workEventRetryIdle,
+			Messages: []string{p.ID},
+		}).Result()
+		if err != nil || len(msgs) == 0 {
+			// Claimed by another consumer, or trimmed from the stream
+			if err == nil {
+				ss.redis.XAck(ctx, searchstream.Stream, searchstream.ConsumerGroup, p.ID)
+			}
+			continue
+		}
+
+		if p.RetryCount >= workEventMaxDeliveries {
+			reason := fmt.Sprintf("gave up after %d deliveries", p.RetryCount)
+			if lastErr, ok := ss.workEventErrors.Load(p.ID); ok {
+				reason += ": " + lastErr.(string)
+			}
+			log.Printf("Dead-lettering work event %s: %s", p.ID, reason)
+			ss.deadLetterWorkEvent(ctx, msgs[0], reason)
+			continue
+		}
+		ss.processWorkEventMessage(ctx, msgs[0])
+	}
+}
+
+// deadLetterWorkEvent moves an event to the dead-letter stream
+func (ss *SearchService) deadLetterWorkEvent(ctx context.Context, msg redis.XMessage, reason string) {
+	values := map[string]interface{}{}
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["original_id"] = msg.ID
+	values["error"] = reason
+	values["dead_lettered_at"] = time.Now().UTC().Format(time.RFC3339)
+
+	err := ss.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: searchstream.DeadLetterStream,
+		MaxLen: workDeadLetterMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		log.Printf("Failed to dead-letter work event %s: %v", msg.ID, err)
+		return
+	}
+	ss.workEventErrors.Delete(msg.ID)
+	ss.redis.XAck(ctx, searchstream.Stream, searchstream.ConsumerGroup, msg.ID)
+}
+
+// GetWorkEventDeadLetters lists dead-lettered work events, newest first:
+// GET /api/v1/index/events/dead-letters
+func (ss *SearchService) GetWorkEventDeadLetters(c *gin.Context) {
+	if ss.redis == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Redis unavailable"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	msgs, err := ss.redis.XRevRangeN(ctx, searchstream.DeadLetterStream, "+", "-", 100).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dead letters"})
+		return
+	}
+	deadLetters := make([]gin.H, 0, len(msgs))
+	for _, msg := range msgs {
+		entry := gin.H{"id": msg.ID}
+		for _, field := range []string{"work_id", "type", "outbox_id", "original_id", "error", "dead_lettered_at"} {
+			entry[field] = msg.Values[field]
+		}
+		deadLetters = append(deadLetters, entry)
+	}
+
+	response := gin.H{"dead_letters": deadLetters}
+	if total, err := ss.redis.XLen(ctx, searchstream.DeadLetterStream).Result(); err == nil {
+		response["total"] = total
+	}
+	if pending, err := ss.redis.XPending(ctx, searchstream.Stream, searchstream.ConsumerGroup).Result(); err == nil {
+		response["pending"] = pending.Count
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ReplayWorkEventDeadLetters puts dead-lettered events back on the work
+// event stream: POST /api/v1/index/events/dead-letters/replay
+// {"ids": [...]} replays those entries; an empty body replays them all.
+func (ss *SearchService) ReplayWorkEventDeadLetters(c *gin.Context) {
+	if ss.redis == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Redis unavailable"})
+		return
+	}
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+	}
+	ctx := c.Request.Context()
+
+	var msgs []redis.XMessage
+	var err error
+	if len(req.IDs) == 0 {
+		msgs, err = ss.redis.XRange(ctx, searchstream.DeadLetterStream, "-", "+").Result()
+	} else {
+		for _, id := range req.IDs {
+			var found []redis.XMessage
+			found, err = ss.redis.XRangeN(ctx, searchstream.DeadLetterStream, id, id, 1).Result()
+			if err != nil {
+				break
+			}
+			msgs = append(msgs, found...)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dead letters"})
+		return
+	}
+
+	replayed := 0
+	for _, msg := range msgs {
+		values := map[string]interface{}{}
+		for k, v := range msg.Values {
+			switch k {
+			case "original_id", "error", "dead_lettered_at":
+			default:
+				values[k] = v
+			}
+		}
+		if err := ss.redis.XAdd(ctx, &redis.XAddArgs{Stream: searchstream.Stream, Values: values}).Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay dead letters", "replayed": replayed})
+			return
+		}
+		ss.redis.XDel(ctx, searchstream.DeadLetterStream, msg.ID)
+		replayed++
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dead letters replayed", "replayed": replayed})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// workSortFields maps plain sort modes onto the fields work documents are
+// indexed with (see WorkIndexDocument)
+var workSortFields = map[string]struct {
+	field        string
+	unmappedType string
+}{
+	"updated_at":   {"updated_date", "date"},
+	"published_at": {"published_date", "date"},
+	"word_count":   {"word_count", "long"},
+	"hits":         {"hits", "long"},
+	"kudos":        {"kudos", "long"},
+	"comments":     {"comments", "long"},
+	"bookmarks":    {"bookmarks", "long"},
+}
+
+// workDateFields are the indexed date fields behind the published/updated
+// range filters
+var workDateFields = map[string]string{
+	"published": "published_date",
+	"updated":   "updated_date",
+}
+
+// searchDateLayouts are the date formats accepted by the range filters
+var searchDateLayouts = []string{time.RFC3339, "2006-01-02"}
+
+// workFieldSort sorts on a single indexed field, newest/largest first by
+// default, with relevance breaking ties. Works missing the field sort last
+// and an index without it yet doesn't fail the search.
+func workFieldSort(sortBy, sortOrder string) []map[string]interface{} {
+	sortField := workSortFields[sortBy]
+	return []map[string]interface{}{
+		{sortField.field: map[string]interface{}{
+			"order":         sortOrder,
+			"missing":       "_last",
+			"unmapped_type": sortField.unmappedType,
+		}},
+		{"_score": map[string]interface{}{"order": "desc"}},
+	}
+}
+
+// kudosPerHitSort ranks works by kudos per hit, so a small work most readers
+// liked can outrank a widely read one. Works without hits score zero, and
+// raw kudos breaks ties.
+func kudosPerHitSort(sortOrder string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"_script": map[string]interface{}{
+				"type": "number",
+				"script": map[string]interface{}{
+					"source": `
+						def hits = doc.containsKey('hits') && doc['hits'].size() > 0 ? doc['hits'].value : 0;
+						def kudos = doc.containsKey('kudos') && doc['kudos'].size() > 0 ? doc['kudos'].value : 0;
+						return hits > 0 ? (double) kudos / hits : 0;
+					`,
+				},
+				"order": sortOrder,
+			},
+		},
+		{"kudos": map[string]interface{}{"order": "desc", "unmapped_type": "long"}},
+	}
+}
+
+// parseSearchDate accepts a YYYY-MM-DD date or an RFC 3339 timestamp
+func parseSearchDate(value string) (time.Time, error) {
+	for _, layout := range searchDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+}
+
+// validateWorkDateRanges rejects unparseable dates and ranges that end
+// before they start
+func validateWorkDateRanges(req WorkSearchRequest) error {
+	ranges := []struct {
+		name          string
+		after, before string
+	}{
+		{"published", req.PublishedAfter, req.PublishedBefore},
+		{"updated", req.UpdatedAfter, req.UpdatedBefore},
+	}
+	for _, r := range ranges {
+		var after, before time.Time
+		var err error
+		if r.after != "" {
+			if after, err = parseSearchDate(r.after); err != nil {
+				return fmt.Errorf("%s_after: %w", r.name, err)
+			}
+		}
+		if r.before != "" {
+			if before, err = parseSearchDate(r.before); err != nil {
+				return fmt.Errorf("%s_before: %w", r.name, err)
+			}
+		}
+		if r.after != "" && r.before != "" && before.Before(after) {
+			return fmt.Errorf("%s_before must not be earlier than %s_after", r.name, r.name)
+		}
+	}
+	return nil
+}
+
+// workDateRangeClauses filters works published and/or updated between the
+// requested dates. A bare date as the upper bound covers that whole day.
+func workDateRangeClauses(req WorkSearchRequest) []map[string]interface{} {
+	ranges := []struct {
+		field         string
+		after, before string
+	}{
+		{workDateFields["published"], req.PublishedAfter, req.PublishedBefore},
+		{workDateFields["updated"], req.UpdatedAfter, req.UpdatedBefore},
+	}
+
+	clauses := []map[string]interface{}{}
+	for _, r := range ranges {
+		if r.after == "" && r.before == "" {
+			continue
+		}
+		rangeQuery := map[string]interface{}{}
+		if r.after != "" {
+			rangeQuery["gte"] = r.after
+		}
+		if r.before != "" {
+			if _, err := time.Parse("2006-01-02", r.before); err == nil {
+				rangeQuery["lte"] = r.before + "||/d"
+			} else {
+				rangeQuery["lte"] = r.before
+			}
+		}
+		clauses = append(clauses, map[string]interface{}{
+			"range": map[string]interface{}{
+				r.field: rangeQuery,
+			},
+		})
+	}
+	return clauses
+}
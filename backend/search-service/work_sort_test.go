@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildSortClauseUsesIndexedFields(t *testing.T) {
+	ss := &SearchService{}
+
+	sort := ss.buildSortClause("kudos", "asc")
+	want := map[string]interface{}{"order": "asc", "missing": "_last", "unmapped_type": "long"}
+	if got := sort[0]["kudos"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("kudos sort = %v, want %v", got, want)
+	}
+	if _, ok := ss.buildSortClause("published_at", "desc")[0]["published_date"]; !ok {
+		t.Error("published_at sort should use the indexed published_date field")
+	}
+
+	ratio := ss.buildSortClause("kudos_per_hit", "sideways")
+	script, ok := ratio[0]["_script"].(map[string]interface{})
+	if !ok || script["order"] != "desc" {
+		t.Errorf("kudos_per_hit should be a script sort with a sanitised order, got %v", ratio[0])
+	}
+}
+
+func TestValidateWorkDateRanges(t *testing.T) {
+	cases := []struct {
+		req   WorkSearchRequest
+		valid bool
+	}{
+		{WorkSearchRequest{PublishedAfter: "2023-01-01", PublishedBefore: "2023-12-31"}, true},
+		{WorkSearchRequest{UpdatedAfter: "2024-03-01T12:00:00Z"}, true},
+		{WorkSearchRequest{PublishedAfter: "2023-12-31", PublishedBefore: "2023-01-01"}, false},
+		{WorkSearchRequest{UpdatedBefore: "last tuesday"}, false},
+	}
+	for _, tc := range cases {
+		if err := validateWorkDateRanges(tc.req); (err == nil) != tc.valid {
+			t.Errorf("validateWorkDateRanges(%+v) = %v, want valid=%v", tc.req, err, tc.valid)
+		}
+	}
+}
+
+func TestWorkDateRangeClauses(t *testing.T) {
+	clauses := workDateRangeClauses(WorkSearchRequest{
+		PublishedAfter:  "2023-01-01",
+		PublishedBefore: "2023-06-30",
+		UpdatedAfter:    "2024-01-01T00:00:00Z",
+	})
+
+	want := []map[string]interface{}{
+		{"range": map[string]interface{}{"published_date": map[string]interface{}{"gte": "2023-01-01", "lte": "2023-06-30||/d"}}},
+		{"range": map[string]interface{}{"updated_date": map[string]interface{}{"gte": "2024-01-01T00:00:00Z"}}},
+	}
+	if !reflect.DeepEqual(clauses, want) {
+		t.Errorf("clauses = %v, want %v", clauses, want)
+	}
+}
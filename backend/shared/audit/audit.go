@@ -0,0 +1,218 @@
+// Package audit records who did what to which object for admin and
+// wrangler actions across the services, in the shared audit_log table.
+//
+// Handlers for the actions that matter most record an entry themselves,
+// inside the transaction making the change, with a before and after
+// snapshot. Middleware mounted on the admin and wrangling route groups
+// records every other successful change, so no staff endpoint goes
+// unaudited.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// recordedKey marks a request whose handler recorded its own entry
+const recordedKey = "audit_recorded"
+
+// Execer is a database or transaction an entry can be recorded on
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Change is the before and after value of one field
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Entry is one audited action
+type Entry struct {
+	ID         uuid.UUID         `json:"id"`
+	ActorID    *uuid.UUID        `json:"actor_id,omitempty"`
+	Service    string            `json:"service"`
+	Action     string            `json:"action"`
+	TargetType string            `json:"target_type,omitempty"`
+	TargetID   string            `json:"target_id,omitempty"`
+	Before     json.RawMessage   `json:"before,omitempty"`
+	After      json.RawMessage   `json:"after,omitempty"`
+	Diff       map[string]Change `json:"diff,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+	IPAddress  string            `json:"ip_address,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// Record writes an entry. Pass a transaction to record it with the change
+// it describes. Before and After are snapshots, either may be nil.
+func Record(ctx context.Context, q Execer, e Entry, before, after interface{}) error {
+	var err error
+	if e.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if e.After, err = snapshot(after); err != nil {
+		return err
+	}
+	if e.Diff, err = Diff(before, after); err != nil {
+		return err
+	}
+	var diff json.RawMessage
+	if e.Diff != nil {
+		if diff, err = json.Marshal(e.Diff); err != nil {
+			return err
+		}
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO audit_log (actor_id, service, action, target_type, target_id, before, after, diff, reason, ip_address)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::inet)`,
+		e.ActorID, e.Service, e.Action, e.TargetType, e.TargetID,
+		nullJSON(e.Before), nullJSON(e.After), nullJSON(diff), e.Reason, e.IPAddress)
+	return err
+}
+
+// RecordRequest records an entry for the current request, filling in the
+// actor and IP, and stops the middleware recording it a second time
+func RecordRequest(c *gin.Context, q Execer, service string, e Entry, before, after interface{}) error {
+	e.Service = service
+	e.ActorID = Actor(c)
+	e.IPAddress = c.ClientIP()
+	if err := Record(c.Request.Context(), q, e, before, after); err != nil {
+		return err
+	}
+	c.Set(recordedKey, true)
+	return nil
+}
+
+// Middleware records every successful change made through the routes it
+// is mounted on whose handler didn't record one. The action is the method
+// and route, the target the first :*_id path parameter.
+func Middleware(db Execer, service string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		if c.Writer.Status() >= http.StatusBadRequest || c.GetBool(recordedKey) {
+			return
+		}
+
+		e := Entry{
+			Service:   service,
+			Action:    c.Request.Method + " " + c.FullPath(),
+			ActorID:   Actor(c),
+			IPAddress: c.ClientIP(),
+		}
+		e.TargetType, e.TargetID = routeTarget(c.Params)
+
+		// The request may have been cancelled by the time the handler returns
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := Record(ctx, db, e, nil, nil); err != nil {
+			log.Printf("Failed to record audit entry for %s: %v", e.Action, err)
+		}
+	}
+}
+
+// Actor is the signed-in user of the request. Services store user_id as a
+// string or a UUID, both are understood.
+func Actor(c *gin.Context) *uuid.UUID {
+	v, _ := c.Get("user_id")
+	switch v := v.(type) {
+	case uuid.UUID:
+		return &v
+	case string:
+		if id, err := uuid.Parse(v); err == nil {
+			return &id
+		}
+	}
+	return nil
+}
+
+// routeTarget picks the object a route acts on from its path parameters
+func routeTarget(params gin.Params) (string, string) {
+	for _, p := range params {
+		if strings.HasSuffix(p.Key, "_id") {
+			return strings.TrimSuffix(p.Key, "_id"), p.Value
+		}
+	}
+	if len(params) > 0 {
+		return params[0].Key, params[0].Value
+	}
+	return "", ""
+}
+
+// Diff lists the top-level fields whose value differs between two
+// snapshots. Fields only present on one side are compared against null.
+func Diff(before, after interface{}) (map[string]Change, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := map[string]Change{}
+	for key, bv := range b {
+		if av, ok := a[key]; !ok || !reflect.DeepEqual(bv, av) {
+			diff[key] = Change{Before: bv, After: a[key]}
+		}
+	}
+	for key, av := range a {
+		if _, ok := b[key]; !ok {
+			diff[key] = Change{After: av}
+		}
+	}
+	if len(diff) == 0 {
+		return nil, nil
+	}
+	return diff, nil
+}
+
+// fields decodes a snapshot into its top-level fields
+func fields(v interface{}) (map[string]interface{}, error) {
+	raw, err := snapshot(v)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		// Not an object, compare it as a whole
+		var whole interface{}
+		if err := json.Unmarshal(raw, &whole); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"value": whole}, nil
+	}
+	return m, nil
+}
+
+func snapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if raw, ok := v.(json.RawMessage); ok {
+		return raw, nil
+	}
+	return json.Marshal(v)
+}
+
+func nullJSON(raw json.RawMessage) interface{} {
+	if raw == nil {
+		return nil
+	}
+	return []byte(raw)
+}
@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	diff, err := Diff(
+		map[string]interface{}{"status": "published", "title": "Same", "banned": false},
+		map[string]interface{}{"status": "hiatus", "title": "Same", "reason": "spam"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Change{
+		"status": {Before: "published", After: "hiatus"},
+		"banned": {Before: false, After: nil},
+		"reason": {Before: nil, After: "spam"},
+	}, diff)
+
+	diff, err = Diff(map[string]string{"status": "open"}, map[string]string{"status": "open"})
+	assert.NoError(t, err)
+	assert.Nil(t, diff, "nothing changed")
+
+	diff, err = Diff(nil, struct {
+		Role string `json:"role"`
+	}{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Change{"role": {After: "admin"}}, diff, "a creation diffs against nothing")
+
+	diff, err = Diff([]string{"user"}, []string{"user", "admin"})
+	assert.NoError(t, err)
+	assert.Contains(t, diff, "value", "non-objects are compared whole")
+}
+
+func TestActor(t *testing.T) {
+	id := uuid.New()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	assert.Nil(t, Actor(c))
+	c.Set("user_id", id.String())
+	assert.Equal(t, &id, Actor(c))
+	c.Set("user_id", id)
+	assert.Equal(t, &id, Actor(c), "auth-service stores a UUID")
+	c.Set("user_id", "not-a-uuid")
+	assert.Nil(t, Actor(c))
+}
+
+func TestRouteTarget(t *testing.T) {
+	kind, id := routeTarget(gin.Params{{Key: "tag_id", Value: "12"}, {Key: "parent_id", Value: "34"}})
+	assert.Equal(t, "tag", kind)
+	assert.Equal(t, "12", id)
+
+	kind, id = routeTarget(gin.Params{{Key: "role", Value: "admin"}})
+	assert.Equal(t, "role", kind)
+	assert.Equal(t, "admin", id)
+
+	kind, id = routeTarget(nil)
+	assert.Empty(t, kind)
+	assert.Empty(t, id)
+}
+
+func TestMiddlewareSkipsReadsFailuresAndRecordedRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// A nil database makes Record panic, so any attempt to record fails the test
+	r := gin.New()
+	r.Use(Middleware(nil, "test"))
+	r.GET("/things/:thing_id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/things", func(c *gin.Context) { c.Status(http.StatusBadRequest) })
+	r.PUT("/things/:thing_id", func(c *gin.Context) {
+		c.Set(recordedKey, true)
+		c.Status(http.StatusOK)
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/things/1", nil),
+		httptest.NewRequest(http.MethodPost, "/things", nil),
+		httptest.NewRequest(http.MethodPut, "/things/1", nil),
+	} {
+		w := httptest.NewRecorder()
+		assert.NotPanics(t, func() { r.ServeHTTP(w, req) }, req.Method)
+	}
+}
+
+func TestFilterWhere(t *testing.T) {
+	actor := uuid.New()
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	where, args := Filter{ActorID: &actor, Action: "tag.*", TargetType: "tag", Since: &since}.where()
+	assert.Equal(t, "TRUE AND actor_id = $1 AND action LIKE $2 AND target_type = $3 AND created_at >= $4", where)
+	assert.Equal(t, []interface{}{actor, "tag.%", "tag", since}, args)
+
+	where, args = Filter{Action: "role_grant"}.where()
+	assert.Equal(t, "TRUE AND action = $1", where)
+	assert.Equal(t, []interface{}{"role_grant"}, args)
+
+	_, args = Filter{Action: "POST /api/v1/admin_*"}.where()
+	assert.Equal(t, []interface{}{`POST /api/v1/admin\_%`}, args, "LIKE wildcards in the prefix are literal")
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	cw, err := NewCSVWriter(&buf)
+	assert.NoError(t, err)
+
+	actor := uuid.New()
+	e := Entry{
+		ID:         uuid.New(),
+		ActorID:    &actor,
+		Service:    "tag-service",
+		Action:     "tag.ban",
+		TargetType: "tag",
+		TargetID:   "42",
+		Reason:     "spam, again",
+		Diff:       map[string]Change{"is_banned": {Before: false, After: true}},
+		CreatedAt:  time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+	}
+	assert.NoError(t, cw.Write(e))
+	assert.NoError(t, cw.Write(Entry{ID: uuid.New(), Service: "auth-service", Action: "role.grant"}))
+	assert.NoError(t, cw.Flush())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, strings.Join(CSVHeader, ","), lines[0])
+	assert.Contains(t, lines[1], `2026-03-04T05:06:07Z,tag-service,`+actor.String())
+	assert.Contains(t, lines[1], `"spam, again","{""is_banned"":{""before"":false,""after"":true}}"`)
+	assert.Contains(t, lines[2], ",auth-service,,,role.grant,", "no actor for service calls")
+}
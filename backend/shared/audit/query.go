@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Filter narrows the audit log. Zero fields match everything.
+type Filter struct {
+	ActorID    *uuid.UUID
+	Service    string
+	Action     string // exact action, or a prefix ending in "*"
+	TargetType string
+	TargetID   string
+	Since      *time.Time
+	Until      *time.Time
+}
+
+// where builds the filter's SQL condition and arguments
+func (f Filter) where() (string, []interface{}) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.ActorID != nil {
+		add("actor_id = $%d", *f.ActorID)
+	}
+	if f.Service != "" {
+		add("service = $%d", f.Service)
+	}
+	if prefix, ok := strings.CutSuffix(f.Action, "*"); ok {
+		add("action LIKE $%d", strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)+"%")
+	} else if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if f.Since != nil {
+		add("created_at >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		add("created_at < $%d", *f.Until)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+const entryColumns = `id, actor_id, service, action, COALESCE(target_type, ''), COALESCE(target_id, ''),
+	before, after, diff, COALESCE(reason, ''), COALESCE(host(ip_address), ''), created_at`
+
+// List returns a page of entries matching the filter, newest first, and
+// how many match in all
+func List(ctx context.Context, db *sql.DB, f Filter, limit, offset int) ([]Entry, int, error) {
+	where, args := f.where()
+
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, limit, offset)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM audit_log WHERE %s ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d",
+		entryColumns, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// Export calls fn with every entry matching the filter, oldest first, up
+// to max entries
+func Export(ctx context.Context, db *sql.DB, f Filter, max int, fn func(Entry) error) error {
+	where, args := f.where()
+	args = append(args, max)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM audit_log WHERE %s ORDER BY created_at, id LIMIT $%d",
+		entryColumns, where, len(args)), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanEntry(rows *sql.Rows) (Entry, error) {
+	var e Entry
+	var actorID uuid.NullUUID
+	var before, after, diff []byte
+	if err := rows.Scan(&e.ID, &actorID, &e.Service, &e.Action, &e.TargetType, &e.TargetID,
+		&before, &after, &diff, &e.Reason, &e.IPAddress, &e.CreatedAt); err != nil {
+		return e, err
+	}
+	if actorID.Valid {
+		e.ActorID = &actorID.UUID
+	}
+	if before != nil {
+		e.Before = json.RawMessage(before)
+	}
+	if after != nil {
+		e.After = json.RawMessage(after)
+	}
+	if diff != nil {
+		if err := json.Unmarshal(diff, &e.Diff); err != nil {
+			return e, err
+		}
+	}
+	return e, nil
+}
+
+// CSVHeader is the header row written by CSVWriter
+var CSVHeader = []string{"id", "created_at", "service", "actor_id", "ip_address", "action", "target_type", "target_id", "reason", "diff"}
+
+// CSVWriter writes entries as CSV rows, the diff as JSON
+type CSVWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter writes the header row and returns a writer for the entries
+func NewCSVWriter(w io.Writer) (*CSVWriter, error) {
+	cw := &CSVWriter{w: csv.NewWriter(w)}
+	if err := cw.w.Write(CSVHeader); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Write writes one entry
+func (cw *CSVWriter) Write(e Entry) error {
+	actor := ""
+	if e.ActorID != nil {
+		actor = e.ActorID.String()
+	}
+	diff := ""
+	if e.Diff != nil {
+		raw, err := json.Marshal(e.Diff)
+		if err != nil {
+			return err
+		}
+		diff = string(raw)
+	}
+	return cw.w.Write([]string{
+		e.ID.String(), e.CreatedAt.UTC().Format(time.RFC3339), e.Service, actor, e.IPAddress,
+		e.Action, e.TargetType, e.TargetID, e.Reason, diff,
+	})
+}
+
+// Flush flushes buffered rows and reports any write error
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
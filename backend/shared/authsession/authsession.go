@@ -0,0 +1,380 @@
+// Package authsession keeps signed-in sessions in Redis. auth-service
+// creates a session at login and hands out a refresh token for it; each
+// refresh rotates the token, and presenting one that has already been
+// rotated away revokes the session, since it means the token was copied.
+// Access tokens carry the session ID in their sid claim, so revoking a
+// session signs its access tokens out as soon as they are next checked.
+package authsession
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	sessionKeyPrefix      = "auth:session:"
+	userSessionsKeyPrefix = "auth:user_sessions:"
+	// touchInterval is how stale last_used_at may get before a check
+	// updates it, so a busy session isn't written on every request
+	touchInterval = time.Minute
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for a malformed or unknown token,
+	// or one whose session has ended
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when a rotated-away token is
+	// presented again; the session has been revoked
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// Session is one signed-in device
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session making the request, when listing
+	Current bool `json:"current,omitempty"`
+}
+
+// Client is where a session is being used from
+type Client struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Store keeps sessions in Redis
+type Store struct {
+	redis *redis.Client
+}
+
+func NewStore(client *redis.Client) *Store {
+	return &Store{redis: client}
+}
+
+func sessionKey(id uuid.UUID) string {
+	return sessionKeyPrefix + id.String()
+}
+
+func usedKey(id uuid.UUID) string {
+	return sessionKeyPrefix + id.String() + ":used"
+}
+
+func userSessionsKey(userID uuid.UUID) string {
+	return userSessionsKeyPrefix + userID.String()
+}
+
+// hashSecret is what is stored of a refresh token's secret
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// refreshToken joins a session ID and secret into the token clients hold
+func refreshToken(id uuid.UUID, secret string) string {
+	return id.String() + "." + secret
+}
+
+// ParseRefreshToken splits a refresh token into its session ID and secret
+func ParseRefreshToken(token string) (uuid.UUID, string, error) {
+	sid, secret, ok := strings.Cut(token, ".")
+	if !ok || secret == "" {
+		return uuid.Nil, "", ErrInvalidRefreshToken
+	}
+	id, err := uuid.Parse(sid)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidRefreshToken
+	}
+	return id, secret, nil
+}
+
+// Create starts a session lasting lifetime from its last refresh,
+// returning it with its first refresh token
+func (s *Store) Create(ctx context.Context, userID uuid.UUID, client Client, lifetime time.Duration) (*Session, string, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	session := &Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Device:     DeviceName(client.UserAgent),
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(lifetime),
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, sessionKey(session.ID), map[string]interface{}{
+		"user_id":      userID.String(),
+		"device":       session.Device,
+		"user_agent":   session.UserAgent,
+		"ip_address":   session.IPAddress,
+		"created_at":   now.Unix(),
+		"last_used_at": now.Unix(),
+		"expires_at":   session.ExpiresAt.Unix(),
+		"lifetime":     int64(lifetime.Seconds()),
+		"refresh_hash": hashSecret(secret),
+	})
+	pipe.ExpireAt(ctx, sessionKey(session.ID), session.ExpiresAt)
+	pipe.SAdd(ctx, userSessionsKey(userID), session.ID.String())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, "", err
+	}
+	return session, refreshToken(session.ID, secret), nil
+}
+
+// rotateScript swaps a session's refresh token hash for a new one if the
+// presented hash is current, keeping the old hash to recognise reuse.
+// It returns 1 when rotated, -1 when the hash was already used and 0 when
+// the session or hash is unknown.
+var rotateScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'refresh_hash')
+if not current then
+	return 0
+end
+if current == ARGV[1] then
+	local lifetime = tonumber(redis.call('HGET', KEYS[1], 'lifetime'))
+	local expires = tonumber(ARGV[3]) + lifetime
+	redis.call('HSET', KEYS[1], 'refresh_hash', ARGV[2], 'last_used_at', ARGV[3], 'expires_at', expires, 'ip_address', ARGV[4])
+	redis.call('SADD', KEYS[2], ARGV[1])
+	redis.call('EXPIREAT', KEYS[1], expires)
+	redis.call('EXPIREAT', KEYS[2], expires)
+	return 1
+end
+if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then
+	return -1
+end
+return 0
+`)
+
+// Refresh rotates a refresh token, returning the session and its new
+// token. A token that was already rotated away revokes the session and
+// returns ErrRefreshTokenReused.
+func (s *Store) Refresh(ctx context.Context, token string, client Client) (*Session, string, error) {
+	id, secret, err := ParseRefreshToken(token)
+	if err != nil {
+		return nil, "", err
+	}
+	next, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	result, err := rotateScript.Run(ctx, s.redis, []string{sessionKey(id), usedKey(id)},
+		hashSecret(secret), hashSecret(next), time.Now().Unix(), client.IPAddress).Int()
+	if err != nil {
+		return nil, "", err
+	}
+	switch result {
+	case 1:
+	case -1:
+		if session, err := s.Get(ctx, id); err == nil && session != nil {
+			s.revoke(ctx, session.UserID, id)
+		}
+		return nil, "", ErrRefreshTokenReused
+	default:
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if session == nil {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	return session, refreshToken(id, next), nil
+}
+
+// Get returns a session, or nil if it has ended
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (*Session, error) {
+	fields, err := s.redis.HGetAll(ctx, sessionKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return sessionFromHash(id, fields)
+}
+
+func sessionFromHash(id uuid.UUID, fields map[string]string) (*Session, error) {
+	userID, err := uuid.Parse(fields["user_id"])
+	if err != nil {
+		return nil, err
+	}
+	unix := func(field string) time.Time {
+		n, _ := strconv.ParseInt(fields[field], 10, 64)
+		return time.Unix(n, 0)
+	}
+	return &Session{
+		ID:         id,
+		UserID:     userID,
+		Device:     fields["device"],
+		UserAgent:  fields["user_agent"],
+		IPAddress:  fields["ip_address"],
+		CreatedAt:  unix("created_at"),
+		LastUsedAt: unix("last_used_at"),
+		ExpiresAt:  unix("expires_at"),
+	}, nil
+}
+
+// SessionActive reports whether a session is still signed in, noting that
+// it was used. It's what access token checks call.
+func (s *Store) SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return false, nil
+	}
+	lastUsed, err := s.redis.HGet(ctx, sessionKey(id), "last_used_at").Int64()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if now := time.Now(); now.Sub(time.Unix(lastUsed, 0)) >= touchInterval {
+		s.redis.HSet(ctx, sessionKey(id), "last_used_at", now.Unix())
+	}
+	return true, nil
+}
+
+// List returns a user's sessions, most recently used first, forgetting
+// any that have expired
+func (s *Store) List(ctx context.Context, userID uuid.UUID) ([]*Session, error) {
+	ids, err := s.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	sessions := []*Session{}
+	for _, raw := range ids {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			s.redis.SRem(ctx, userSessionsKey(userID), raw)
+			continue
+		}
+		session, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil {
+			s.redis.SRem(ctx, userSessionsKey(userID), raw)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+// Revoke ends one of a user's sessions, reporting whether it existed
+func (s *Store) Revoke(ctx context.Context, userID, sessionID uuid.UUID) (bool, error) {
+	session, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	if session == nil || session.UserID != userID {
+		return false, nil
+	}
+	return true, s.revoke(ctx, userID, sessionID)
+}
+
+// RevokeAll ends all of a user's sessions except keep, which may be
+// uuid.Nil, returning how many it ended
+func (s *Store) RevokeAll(ctx context.Context, userID, keep uuid.UUID) (int, error) {
+	sessions, err := s.List(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == keep {
+			continue
+		}
+		if err := s.revoke(ctx, userID, session.ID); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+func (s *Store) revoke(ctx context.Context, userID, sessionID uuid.UUID) error {
+	pipe := s.redis.TxPipeline()
+	pipe.Del(ctx, sessionKey(sessionID), usedKey(sessionID))
+	pipe.SRem(ctx, userSessionsKey(userID), sessionID.String())
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeviceName describes a user agent as "Browser on OS" for the sessions
+// list
+func DeviceName(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	os := ""
+	for _, o := range []struct{ token, name string }{
+		{"iphone", "iOS"},
+		{"ipad", "iPadOS"},
+		{"android", "Android"},
+		{"windows", "Windows"},
+		{"mac os x", "macOS"},
+		{"cros", "ChromeOS"},
+		{"linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			os = o.name
+			break
+		}
+	}
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
@@ -0,0 +1,47 @@
+package authsession
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseRefreshToken(t *testing.T) {
+	id := uuid.New()
+	gotID, secret, err := ParseRefreshToken(refreshToken(id, "s3cret"))
+	if err != nil || gotID != id || secret != "s3cret" {
+		t.Fatalf("round trip = %v, %q, %v", gotID, secret, err)
+	}
+
+	for _, bad := range []string{"", "no-dot", id.String() + ".", "not-a-uuid.secret"} {
+		if _, _, err := ParseRefreshToken(bad); err != ErrInvalidRefreshToken {
+			t.Errorf("ParseRefreshToken(%q) err = %v", bad, err)
+		}
+	}
+}
+
+func TestHashSecretDiffers(t *testing.T) {
+	a, err := newSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := newSecret()
+	if a == b || hashSecret(a) == hashSecret(b) || hashSecret(a) == a {
+		t.Error("secrets and their hashes should be distinct")
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	cases := map[string]string{
+		"": "Unknown device",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36":  "Chrome on Windows",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Version/17.0 Mobile Safari/604.1": "Safari on iOS",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0":                                       "Firefox on Linux",
+		"curl/8.4.0": "curl",
+	}
+	for ua, want := range cases {
+		if got := DeviceName(ua); got != want {
+			t.Errorf("DeviceName(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
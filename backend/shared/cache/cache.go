@@ -2,57 +2,107 @@ package cache
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// Cache provides a Redis-based caching layer
+// Cache provides a Redis-based caching layer. GetOrSet protects the
+// database when hot keys expire: concurrent misses on a key share one load,
+// keys close to expiring are refreshed early by a random few requests, and
+// "not found" results are cached briefly so a burst of requests for a
+// missing row doesn't reach the database each time. EnableLocal adds an
+// in-process tier in front of Redis for the hottest keys.
 type Cache struct {
 	client *redis.Client
 	prefix string
+
+	// NegativeTTL is how long a "not found" result is cached; zero turns
+	// negative caching off
+	NegativeTTL time.Duration
+
+	loads group
+
+	// In-process tier, set up by EnableLocal
+	local    *lru
+	localTTL time.Duration
+	jitter   float64
+	instance string
 }
 
 // NewCache creates a new cache instance
 func NewCache(client *redis.Client, prefix string) *Cache {
 	return &Cache{
-		client: client,
-		prefix: prefix,
+		client:      client,
+		prefix:      prefix,
+		NegativeTTL: DefaultNegativeTTL,
 	}
 }
 
 // Get retrieves a value from cache and unmarshals it
 func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
-	val, err := c.client.Get(ctx, c.key(key)).Result()
-	if err == redis.Nil {
-		return ErrCacheMiss
-	}
+	val, _, err := c.lookup(ctx, key)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal([]byte(val), dest)
+	return json.Unmarshal(val, dest)
 }
 
-// Set stores a value in cache with expiration
-func (c *Cache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+// Set stores a value in cache with expiration. InvalidateTag with any of
+// tags deletes it.
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error {
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	return c.client.Set(ctx, c.key(key), data, expiration).Err()
+	if err := c.store(ctx, key, string(data), expiration, tags); err != nil {
+		return err
+	}
+	c.broadcast(ctx, key, false)
+	return nil
+}
+
+// store writes a value to Redis, and the process if it's held there, and
+// adds it to its tags
+func (c *Cache) store(ctx context.Context, key, value string, expiration time.Duration, tags []string) error {
+	if len(tags) == 0 {
+		if err := c.client.Set(ctx, c.key(key), value, expiration).Err(); err != nil {
+			return err
+		}
+	} else {
+		pipe := c.client.TxPipeline()
+		pipe.Set(ctx, c.key(key), value, expiration)
+		c.addToTags(ctx, pipe, key, expiration, tags)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	c.keepLocal(key, value, expiration)
+	return nil
 }
 
 // Delete removes a key from cache
 func (c *Cache) Delete(ctx context.Context, key string) error {
+	if c.local != nil {
+		c.local.delete(key)
+		defer c.broadcast(ctx, key, false)
+	}
 	return c.client.Del(ctx, c.key(key)).Err()
 }
 
 // DeletePattern removes all keys matching a pattern
 func (c *Cache) DeletePattern(ctx context.Context, pattern string) error {
+	if c.local != nil {
+		c.local.deletePrefix(patternPrefix(pattern))
+		defer c.broadcast(ctx, pattern, true)
+	}
 	keys, err := c.client.Keys(ctx, c.key(pattern)).Result()
 	if err != nil {
 		return err
@@ -70,34 +120,108 @@ func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
 	return count > 0, err
 }
 
-// GetOrSet gets a value from cache, or sets it if not found
-func (c *Cache) GetOrSet(ctx context.Context, key string, dest interface{}, expiration time.Duration, setter func() (interface{}, error)) error {
-	err := c.Get(ctx, key, dest)
-	if err == nil {
-		return nil // Cache hit
-	}
-	if err != ErrCacheMiss {
-		return err // Redis error
+// GetOrSet gets a value from cache, or sets it from setter if not found.
+// A setter error that IsNotFound is cached for NegativeTTL, and returned
+// as ErrNotFound until then. InvalidateTag with any of tags deletes the
+// cached value.
+func (c *Cache) GetOrSet(ctx context.Context, key string, dest interface{}, expiration time.Duration, setter func() (interface{}, error), tags ...string) error {
+	cached, ttl, err := c.lookup(ctx, key)
+	switch {
+	case err == nil && !refreshEarly(ttl, expiration):
+		return json.Unmarshal(cached, dest) // Cache hit
+	case err == nil:
+		// Picked to refresh this key before it expires
+	case err != ErrCacheMiss:
+		return err // Redis error, or a cached "not found"
 	}
 
-	// Cache miss - get value from setter
-	value, err := setter()
+	data, err := c.loads.do(key, func() ([]byte, error) {
+		return c.load(key, expiration, setter, tags)
+	})
 	if err != nil {
+		if cached != nil {
+			// The early refresh failed, the cached value is still good
+			return json.Unmarshal(cached, dest)
+		}
 		return err
 	}
+	return json.Unmarshal(data, dest)
+}
 
-	// Store in cache for next time
-	if err := c.Set(ctx, key, value, expiration); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Failed to set cache: %v\n", err)
+// lookup reads a key with its remaining time to live. A key held in process
+// is returned with no time to live, as Redis decides when it's refreshed.
+func (c *Cache) lookup(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	if c.local != nil {
+		if val, ok := c.local.get(key); ok {
+			if val == notFoundMarker {
+				return nil, 0, ErrNotFound
+			}
+			return []byte(val), 0, nil
+		}
+	}
+
+	pipe := c.client.Pipeline()
+	get := pipe.Get(ctx, c.key(key))
+	pttl := pipe.PTTL(ctx, c.key(key))
+	_, err := pipe.Exec(ctx)
+	if err == redis.Nil {
+		return nil, 0, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	c.keepLocal(key, get.Val(), pttl.Val())
+	if get.Val() == notFoundMarker {
+		return nil, 0, ErrNotFound
+	}
+	return []byte(get.Val()), pttl.Val(), nil
+}
+
+// load runs setter and caches its result. The result is shared by every
+// request waiting on the load, so it's cached whichever of them goes away.
+func (c *Cache) load(key string, expiration time.Duration, setter func() (interface{}, error), tags []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := setter()
+	if err != nil {
+		if IsNotFound(err) && c.NegativeTTL > 0 {
+			if setErr := c.store(ctx, key, notFoundMarker, c.NegativeTTL, tags); setErr != nil {
+				fmt.Printf("Failed to set cache: %v\n", setErr)
+			}
+		}
+		return nil, err
 	}
 
-	// Marshal the value into dest
 	data, err := json.Marshal(value)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return json.Unmarshal(data, dest)
+	// Store in cache for next time, without failing the request. Jitter
+	// keeps keys loaded together from expiring together.
+	if err := c.store(ctx, key, string(data), c.jittered(expiration), tags); err != nil {
+		fmt.Printf("Failed to set cache: %v\n", err)
+	}
+	return data, nil
+}
+
+// earlyRefreshWindow is the final share of a key's lifetime in which
+// requests may refresh it early
+const earlyRefreshWindow = 10
+
+// randFloat is swapped out by tests
+var randFloat = rand.Float64
+
+// refreshEarly decides whether a request refreshes a key with ttl left of
+// the expiration it was set with. In the last tenth of its lifetime the
+// chance rises from zero to certain, so one of the many requests for a hot
+// key reloads it before it expires and the rest never see a miss.
+func refreshEarly(ttl, expiration time.Duration) bool {
+	window := expiration / earlyRefreshWindow
+	if ttl <= 0 || window <= 0 || ttl >= window {
+		return false
+	}
+	return randFloat() >= float64(ttl)/float64(window)
 }
 
 // Increment atomically increments a counter
@@ -120,12 +244,27 @@ func (c *Cache) key(key string) string {
 // Common cache errors
 var (
 	ErrCacheMiss = fmt.Errorf("cache miss")
+	// ErrNotFound is returned for a key whose value was recently found not
+	// to exist
+	ErrNotFound = errors.New("not found")
 )
 
+// notFoundMarker is cached in place of a value that doesn't exist
+const notFoundMarker = "\x00not_found"
+
+// IsNotFound reports whether err means the value doesn't exist: a cached
+// ErrNotFound, or a setter's sql.ErrNoRows
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, sql.ErrNoRows)
+}
+
 // Common cache durations
 const (
 	ShortTTL  = 5 * time.Minute  // For frequently changing data
 	MediumTTL = 30 * time.Minute // For moderately stable data
 	LongTTL   = 2 * time.Hour    // For stable data
 	DayTTL    = 24 * time.Hour   // For daily aggregates
+
+	// DefaultNegativeTTL is how long "not found" results are cached
+	DefaultNegativeTTL = 30 * time.Second
 )
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupCoalescesLoads(t *testing.T) {
+	var g group
+	var loads int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := g.do("work:1", func() ([]byte, error) {
+				atomic.AddInt32(&loads, 1)
+				close(started)
+				<-release
+				return []byte("loaded"), nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = string(data)
+		}(i)
+		if i == 0 {
+			<-started
+		}
+	}
+	// Give the followers time to join the running load
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("%d loads ran, want 1", loads)
+	}
+	for i, got := range results {
+		if got != "loaded" {
+			t.Errorf("caller %d got %q", i, got)
+		}
+	}
+
+	// Once finished the next call loads again
+	data, _ := g.do("work:1", func() ([]byte, error) { return []byte("reloaded"), nil })
+	if string(data) != "reloaded" {
+		t.Errorf("second load = %q", data)
+	}
+}
+
+func TestGroupSharesErrors(t *testing.T) {
+	var g group
+	_, err := g.do("work:2", func() ([]byte, error) { return nil, sql.ErrNoRows })
+	if err != sql.ErrNoRows {
+		t.Errorf("err = %v", err)
+	}
+
+	func() {
+		defer func() { recover() }()
+		g.do("work:3", func() ([]byte, error) { panic("boom") })
+	}()
+	if _, err := g.do("work:3", func() ([]byte, error) { return []byte("ok"), nil }); err != nil {
+		t.Errorf("a panicked load should not block the key: %v", err)
+	}
+}
+
+func TestRefreshEarly(t *testing.T) {
+	defer func(orig func() float64) { randFloat = orig }(randFloat)
+
+	randFloat = func() float64 { return 0.99 }
+	if refreshEarly(20*time.Minute, 30*time.Minute) {
+		t.Error("keys well within their lifetime are never refreshed early")
+	}
+	if refreshEarly(-1, 30*time.Minute) {
+		t.Error("keys without an expiry are never refreshed early")
+	}
+	if !refreshEarly(30*time.Second, 30*time.Minute) {
+		t.Error("a high roll near expiry should refresh")
+	}
+
+	randFloat = func() float64 { return 0.5 }
+	// The window is the last 3 minutes: 2m left is a third of the way in
+	if refreshEarly(2*time.Minute, 30*time.Minute) {
+		t.Error("a roll below the remaining share should not refresh")
+	}
+	if !refreshEarly(time.Minute, 30*time.Minute) {
+		t.Error("a roll above the remaining share should refresh")
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if !IsNotFound(ErrNotFound) || !IsNotFound(sql.ErrNoRows) || !IsNotFound(fmt.Errorf("work: %w", sql.ErrNoRows)) {
+		t.Error("not found errors not recognised")
+	}
+	if IsNotFound(ErrCacheMiss) || IsNotFound(sql.ErrConnDone) {
+		t.Error("other errors are not not-found")
+	}
+}
@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LocalOptions configures the in-process tier a Cache can keep in front of
+// Redis, for hot reads such as work metadata and canonical tags
+type LocalOptions struct {
+	// Size is how many entries are kept, least recently used going first
+	Size int
+	// TTL is the longest an entry is kept in process. Writes and deletes are
+	// broadcast to every process, so this only bounds staleness if a
+	// broadcast is missed.
+	TTL time.Duration
+	// Jitter varies each TTL, here and of values GetOrSet loads into Redis,
+	// by up to this fraction either way, so keys cached together don't
+	// expire together
+	Jitter float64
+}
+
+// Default in-process tier settings
+const (
+	DefaultLocalSize   = 10000
+	DefaultLocalTTL    = 30 * time.Second
+	DefaultLocalJitter = 0.1
+)
+
+// LocalOptionsFromEnv reads CACHE_LOCAL_SIZE and CACHE_LOCAL_TTL. A size of
+// 0 turns the in-process tier off.
+func LocalOptionsFromEnv() LocalOptions {
+	opts := LocalOptions{Size: DefaultLocalSize, TTL: DefaultLocalTTL, Jitter: DefaultLocalJitter}
+	if size, err := strconv.Atoi(os.Getenv("CACHE_LOCAL_SIZE")); err == nil && size >= 0 {
+		opts.Size = size
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("CACHE_LOCAL_TTL")); err == nil && ttl > 0 {
+		opts.TTL = ttl
+	}
+	return opts
+}
+
+// EnableLocal puts an in-process LRU in front of Redis. Set, Delete and
+// DeletePattern broadcast the change on a Redis channel so every process
+// sharing the cache prefix drops its copy; the subscription runs until ctx
+// is done.
+func (c *Cache) EnableLocal(ctx context.Context, opts LocalOptions) {
+	if opts.Size <= 0 || opts.TTL <= 0 {
+		return
+	}
+	c.local = newLRU(opts.Size)
+	c.localTTL = opts.TTL
+	c.jitter = opts.Jitter
+	c.instance = uuid.NewString()
+
+	sub := c.client.Subscribe(ctx, c.invalidationChannel())
+	go func() {
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				c.applyInvalidation(msg.Payload)
+			}
+		}
+	}()
+}
+
+func (c *Cache) invalidationChannel() string {
+	return "cache:invalidate:" + c.prefix
+}
+
+// broadcast tells the other processes to drop a key, or with isPattern set
+// every key matching a pattern
+func (c *Cache) broadcast(ctx context.Context, key string, isPattern bool) {
+	if c.local == nil {
+		return
+	}
+	kind := "key"
+	if isPattern {
+		kind = "pattern"
+	}
+	payload := fmt.Sprintf("%s %s %s", c.instance, kind, key)
+	if err := c.client.Publish(ctx, c.invalidationChannel(), payload).Err(); err != nil {
+		log.Printf("Failed to broadcast cache invalidation of %s: %v", key, err)
+	}
+}
+
+// applyInvalidation drops what another process's broadcast names
+func (c *Cache) applyInvalidation(payload string) {
+	parts := strings.SplitN(payload, " ", 3)
+	if len(parts) != 3 || parts[0] == c.instance {
+		return
+	}
+	if parts[1] == "pattern" {
+		c.local.deletePrefix(patternPrefix(parts[2]))
+	} else {
+		c.local.delete(parts[2])
+	}
+}
+
+// patternPrefix is the literal start of a Redis glob pattern. Local entries
+// under it are dropped, which may be more than the pattern matches but never
+// fewer.
+func patternPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// jittered varies d by up to the cache's jitter either way
+func (c *Cache) jittered(d time.Duration) time.Duration {
+	if c.jitter <= 0 || d <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + c.jitter*(2*randFloat()-1)))
+}
+
+// keepLocal holds a value read from or written to Redis, for no longer
+// than it has left there
+func (c *Cache) keepLocal(key, value string, redisTTL time.Duration) {
+	if c.local == nil {
+		return
+	}
+	ttl := c.jittered(c.localTTL)
+	if redisTTL > 0 && redisTTL < ttl {
+		ttl = redisTTL
+	}
+	c.local.put(key, value, ttl)
+}
+
+// lru is a size-bounded map whose least recently used entries are evicted
+// first, with a time to live on each entry
+type lru struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, order: list.New(), entries: map[string]*list.Element{}, now: time.Now}
+}
+
+func (l *lru) get(key string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.entries[key]
+	if !ok {
+		return "", false
+	}
+	entry := el.Value.(*lruEntry)
+	if !l.now().Before(entry.expires) {
+		l.order.Remove(el)
+		delete(l.entries, key)
+		return "", false
+	}
+	l.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (l *lru) put(key, value string, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	expires := l.now().Add(ttl)
+	if el, ok := l.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		l.order.MoveToFront(el)
+		return
+	}
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *lru) delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.entries[key]; ok {
+		l.order.Remove(el)
+		delete(l.entries, key)
+	}
+}
+
+func (l *lru) deletePrefix(prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, el := range l.entries {
+		if strings.HasPrefix(key, prefix) {
+			l.order.Remove(el)
+			delete(l.entries, key)
+		}
+	}
+}
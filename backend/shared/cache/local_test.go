@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	l := newLRU(2)
+	l.put("a", "1", time.Minute)
+	l.put("b", "2", time.Minute)
+	l.get("a")
+	l.put("c", "3", time.Minute)
+
+	if _, ok := l.get("b"); ok {
+		t.Error("b was least recently used and should have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := l.get(key); !ok {
+			t.Errorf("%s should still be cached", key)
+		}
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	now := time.Now()
+	l := newLRU(10)
+	l.now = func() time.Time { return now }
+	l.put("work:1", "{}", time.Second)
+
+	if _, ok := l.get("work:1"); !ok {
+		t.Fatal("entry should be cached before it expires")
+	}
+	now = now.Add(time.Second)
+	if _, ok := l.get("work:1"); ok {
+		t.Error("entry should expire after its TTL")
+	}
+	if len(l.entries) != 0 || l.order.Len() != 0 {
+		t.Error("an expired entry should be dropped")
+	}
+}
+
+func TestLRUDeletePrefix(t *testing.T) {
+	l := newLRU(10)
+	for _, key := range []string{"work:1", "work:1:chapters", "work:10", "tag:1"} {
+		l.put(key, "x", time.Minute)
+	}
+	l.deletePrefix("work:1")
+	if len(l.entries) != 1 {
+		t.Errorf("only tag:1 should be left, have %d entries", len(l.entries))
+	}
+}
+
+func TestPatternPrefix(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"work:*":         "work:",
+		"works:list:?:x": "works:list:",
+		"tag:[ab]*":      "tag:",
+		"work:1":         "work:1",
+	} {
+		if got := patternPrefix(pattern); got != want {
+			t.Errorf("patternPrefix(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestJitteredStaysInBounds(t *testing.T) {
+	c := &Cache{jitter: 0.1}
+	defer func(orig func() float64) { randFloat = orig }(randFloat)
+
+	for _, roll := range []float64{0, 0.5, 0.999} {
+		randFloat = func() float64 { return roll }
+		got := c.jittered(time.Minute)
+		if got < 54*time.Second || got > 66*time.Second {
+			t.Errorf("roll %v: jittered TTL %v outside 10%% of 1m", roll, got)
+		}
+	}
+	if got := (&Cache{}).jittered(time.Minute); got != time.Minute {
+		t.Errorf("no jitter should leave the TTL alone, got %v", got)
+	}
+}
+
+func TestLocalTierServesAndInvalidates(t *testing.T) {
+	c := &Cache{local: newLRU(10), localTTL: time.Minute, instance: "self"}
+	c.keepLocal("work:1", `{"title":"Local"}`, time.Hour)
+	c.keepLocal("work:2", notFoundMarker, time.Hour)
+
+	var work struct{ Title string }
+	if err := c.Get(context.Background(), "work:1", &work); err != nil || work.Title != "Local" {
+		t.Fatalf("local hit: %+v, %v", work, err)
+	}
+	if err := c.Get(context.Background(), "work:2", &work); err != ErrNotFound {
+		t.Errorf("a cached not-found should be ErrNotFound, got %v", err)
+	}
+
+	c.applyInvalidation("self key work:1")
+	if _, ok := c.local.get("work:1"); !ok {
+		t.Error("a process should ignore its own broadcasts")
+	}
+	c.applyInvalidation("other key work:1")
+	if _, ok := c.local.get("work:1"); ok {
+		t.Error("another process's broadcast should drop the key")
+	}
+	c.applyInvalidation("other pattern work:*")
+	if _, ok := c.local.get("work:2"); ok {
+		t.Error("a pattern broadcast should drop matching keys")
+	}
+}
+
+func TestKeepLocalOutlivesRedis(t *testing.T) {
+	now := time.Now()
+	c := &Cache{local: newLRU(10), localTTL: time.Minute}
+	c.local.now = func() time.Time { return now }
+	c.keepLocal("work:1", "{}", 5*time.Second)
+
+	now = now.Add(5 * time.Second)
+	if _, ok := c.local.get("work:1"); ok {
+		t.Error("a local copy shouldn't outlive the Redis key")
+	}
+}
+
+func TestLocalOptionsFromEnv(t *testing.T) {
+	t.Setenv("CACHE_LOCAL_SIZE", "500")
+	t.Setenv("CACHE_LOCAL_TTL", "nope")
+	opts := LocalOptionsFromEnv()
+	if opts.Size != 500 || opts.TTL != DefaultLocalTTL {
+		t.Errorf("options = %+v", opts)
+	}
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// errLoadPanicked is what waiting callers get if the load panicked
+var errLoadPanicked = errors.New("cache load panicked")
+
+// group coalesces concurrent loads of the same key: the first caller runs
+// the load and the rest wait for its result
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// do runs fn for key unless a load of key is already running, in which
+// case it waits for that load and returns its result
+func (g *group) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call{}
+	}
+	if running, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-running.done
+		return running.data, running.err
+	}
+	leader := &call{done: make(chan struct{})}
+	g.calls[key] = leader
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(leader.done)
+	}()
+	leader.err = errLoadPanicked
+	leader.data, leader.err = fn()
+	return leader.data, leader.err
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache tags group the keys derived from one thing, such as a work's page,
+// stats and chapter list, so a single InvalidateTag call clears them all
+// when it changes. Each tag is a Redis set of the keys cached with it.
+
+// tagKey is the set listing the keys cached with a tag
+func (c *Cache) tagKey(tag string) string {
+	return c.key("cache_tag:" + tag)
+}
+
+// addToTags queues adding key to each tag's set. A set lives as long as the
+// longest-lived key in it, so a key can't outlast the record of its tags.
+func (c *Cache) addToTags(ctx context.Context, pipe redis.Pipeliner, key string, expiration time.Duration, tags []string) {
+	// Expiry is set in whole seconds, so round up
+	setTTL := (expiration + time.Second - 1).Truncate(time.Second)
+	for _, tag := range tags {
+		tagKey := c.tagKey(tag)
+		pipe.SAdd(ctx, tagKey, key)
+		if expiration > 0 {
+			pipe.ExpireNX(ctx, tagKey, setTTL)
+			pipe.ExpireGT(ctx, tagKey, setTTL)
+		} else {
+			pipe.Persist(ctx, tagKey)
+		}
+	}
+}
+
+// InvalidateTag deletes every key cached with any of tags
+func (c *Cache) InvalidateTag(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		tagKey := c.tagKey(tag)
+		keys, err := c.client.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		prefixed := make([]string, len(keys))
+		members := make([]interface{}, len(keys))
+		for i, key := range keys {
+			prefixed[i] = c.key(key)
+			members[i] = key
+		}
+		// Only the keys read are removed from the set, so one cached while
+		// this runs stays tagged
+		pipe := c.client.TxPipeline()
+		pipe.Del(ctx, prefixed...)
+		pipe.SRem(ctx, tagKey, members...)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if c.local != nil {
+				c.local.delete(key)
+			}
+			c.broadcast(ctx, key, false)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// recordingHook answers commands without a Redis server, recording each
+// one and answering SMEMBERS from members
+type recordingHook struct {
+	commands []string
+	members  map[string][]string
+}
+
+func (h *recordingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("no redis in tests")
+	}
+}
+
+func (h *recordingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.record(cmd)
+		return nil
+	}
+}
+
+func (h *recordingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.record(cmd)
+		}
+		return nil
+	}
+}
+
+func (h *recordingHook) record(cmd redis.Cmder) {
+	args := make([]string, len(cmd.Args()))
+	for i, arg := range cmd.Args() {
+		args[i] = fmt.Sprint(arg)
+	}
+	if strings.EqualFold(args[0], "smembers") {
+		cmd.(*redis.StringSliceCmd).SetVal(h.members[args[1]])
+	}
+	// MULTI and EXEC wrap transactions; only what's inside matters here
+	if name := strings.ToLower(args[0]); name != "multi" && name != "exec" {
+		h.commands = append(h.commands, strings.Join(args, " "))
+	}
+}
+
+func recordingCache() (*Cache, *recordingHook) {
+	hook := &recordingHook{members: map[string][]string{}}
+	client := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	client.AddHook(hook)
+	return NewCache(client, "test"), hook
+}
+
+func TestSetAddsKeyToTags(t *testing.T) {
+	c, hook := recordingCache()
+	if err := c.Set(context.Background(), "work_stats:1", 1, 90*time.Second, "work:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"set test:work_stats:1 1 ex 90",
+		"sadd test:cache_tag:work:1 work_stats:1",
+		"expire test:cache_tag:work:1 90 NX",
+		"expire test:cache_tag:work:1 90 GT",
+	}
+	if strings.Join(hook.commands, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands:\n%s\nwant:\n%s", strings.Join(hook.commands, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestInvalidateTagDeletesTaggedKeys(t *testing.T) {
+	c, hook := recordingCache()
+	c.local = newLRU(10)
+	c.local.put("work:1", "{}", time.Minute)
+	c.local.put("work:2", "{}", time.Minute)
+	hook.members["test:cache_tag:work:1"] = []string{"work:1", "work_chapters:1"}
+
+	if err := c.InvalidateTag(context.Background(), "work:1", "work:3"); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := strings.Join(hook.commands, "\n")
+	for _, want := range []string{
+		"del test:work:1 test:work_chapters:1",
+		"srem test:cache_tag:work:1 work:1 work_chapters:1",
+		"smembers test:cache_tag:work:3",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("missing %q in:\n%s", want, commands)
+		}
+	}
+	if _, ok := c.local.get("work:1"); ok {
+		t.Error("the in-process copy of a tagged key should be dropped")
+	}
+	if _, ok := c.local.get("work:2"); !ok {
+		t.Error("keys without the tag should stay cached")
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"time"
+)
+
+// PoolConfig sizes a service's Postgres connection pool
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DefaultPoolConfig is sized for budget hosting, where every service shares
+// one small Postgres connection limit
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxOpenConns:    10,
+		MaxIdleConns:    3,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+	}
+}
+
+// PoolConfigFromEnv starts from defaults and applies overrides:
+//
+//	DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS     explicit pool sizes
+//	DB_CONN_MAX_LIFETIME, DB_CONN_MAX_IDLE_TIME  durations such as "30m"
+//	DB_CONN_BUDGET, DB_POOL_SERVICES         size the pool as an even share
+//	                                         of the database's connection
+//	                                         limit when no explicit size is set
+func PoolConfigFromEnv(defaults PoolConfig) PoolConfig {
+	config := defaults
+
+	if maxOpen, ok := envInt("DB_MAX_OPEN_CONNS"); ok {
+		config.MaxOpenConns = maxOpen
+	} else if budget, ok := envInt("DB_CONN_BUDGET"); ok {
+		services, ok := envInt("DB_POOL_SERVICES")
+		if !ok {
+			services = 1
+		}
+		config.MaxOpenConns = budgetShare(budget, services)
+	}
+
+	if maxIdle, ok := envInt("DB_MAX_IDLE_CONNS"); ok {
+		config.MaxIdleConns = maxIdle
+	} else if config.MaxOpenConns != defaults.MaxOpenConns {
+		// Keep roughly the default idle-to-open ratio for resized pools
+		config.MaxIdleConns = config.MaxOpenConns / 3
+		if config.MaxIdleConns < 1 {
+			config.MaxIdleConns = 1
+		}
+	}
+	if config.MaxIdleConns > config.MaxOpenConns {
+		config.MaxIdleConns = config.MaxOpenConns
+	}
+
+	if lifetime, ok := envDuration("DB_CONN_MAX_LIFETIME"); ok {
+		config.ConnMaxLifetime = lifetime
+	}
+	if idleTime, ok := envDuration("DB_CONN_MAX_IDLE_TIME"); ok {
+		config.ConnMaxIdleTime = idleTime
+	}
+	return config
+}
+
+// Apply sets the pool limits on db
+func (p PoolConfig) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(p.MaxOpenConns)
+	db.SetMaxIdleConns(p.MaxIdleConns)
+	db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+}
+
+// budgetShare splits a connection budget evenly, holding a few connections
+// back for migrations and psql sessions and never going below two
+func budgetShare(budget, services int) int {
+	if services < 1 {
+		services = 1
+	}
+	reserved := budget / 10
+	if reserved < 2 {
+		reserved = 2
+	}
+	share := (budget - reserved) / services
+	if share < 2 {
+		share = 2
+	}
+	return share
+}
+
+func envInt(key string) (int, bool) {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+	return value, true
+}
+
+func envDuration(key string) (time.Duration, bool) {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolConfigFromEnvDefaults(t *testing.T) {
+	defaults := PoolConfig{MaxOpenConns: 25, MaxIdleConns: 5, ConnMaxLifetime: time.Hour}
+	if got := PoolConfigFromEnv(defaults); got != defaults {
+		t.Errorf("Expected defaults %+v without overrides, got %+v", defaults, got)
+	}
+}
+
+func TestPoolConfigFromEnvOverrides(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "8")
+	t.Setenv("DB_MAX_IDLE_CONNS", "20")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "10m")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "not-a-duration")
+
+	got := PoolConfigFromEnv(DefaultPoolConfig())
+	if got.MaxOpenConns != 8 {
+		t.Errorf("Expected 8 open connections, got %d", got.MaxOpenConns)
+	}
+	if got.MaxIdleConns != 8 {
+		t.Errorf("Expected idle connections capped at 8, got %d", got.MaxIdleConns)
+	}
+	if got.ConnMaxLifetime != 10*time.Minute {
+		t.Errorf("Expected 10m lifetime, got %s", got.ConnMaxLifetime)
+	}
+	if got.ConnMaxIdleTime != DefaultPoolConfig().ConnMaxIdleTime {
+		t.Errorf("Expected invalid idle time to be ignored, got %s", got.ConnMaxIdleTime)
+	}
+}
+
+func TestPoolConfigFromBudget(t *testing.T) {
+	t.Setenv("DB_CONN_BUDGET", "100")
+	t.Setenv("DB_POOL_SERVICES", "6")
+
+	got := PoolConfigFromEnv(DefaultPoolConfig())
+	// 10 of 100 are held back, the rest split six ways
+	if got.MaxOpenConns != 15 {
+		t.Errorf("Expected 15 open connections, got %d", got.MaxOpenConns)
+	}
+	if got.MaxIdleConns != 5 {
+		t.Errorf("Expected 5 idle connections, got %d", got.MaxIdleConns)
+	}
+}
+
+func TestBudgetShareFloor(t *testing.T) {
+	if got := budgetShare(5, 10); got != 2 {
+		t.Errorf("Expected a floor of 2 connections, got %d", got)
+	}
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"nuclear-ao3/shared/metrics"
+)
+
+// Read replica routing. A service with DATABASE_REPLICA_URL set sends its
+// heavy reads (browsing, listings, stats) to the replica and everything
+// else to the primary. The replica is checked every
+// DB_REPLICA_CHECK_INTERVAL (default 5s); while it is unreachable, not
+// streaming from the primary, or more than DB_REPLICA_MAX_LAG (default
+// 10s) behind, reads go to the primary.
+
+const (
+	// DefaultReplicaMaxLag is how far behind the replica may fall before
+	// reads move back to the primary
+	DefaultReplicaMaxLag = 10 * time.Second
+	// DefaultReplicaCheckInterval is how often the replica is checked
+	DefaultReplicaCheckInterval = 5 * time.Second
+)
+
+// replicaLagQuery is whether the replica is receiving WAL and how far its
+// replay is behind. Having replayed everything received only means the
+// replica is current while its WAL receiver is streaming: with the
+// receiver down, it has simply stopped hearing from the primary. Roles
+// without pg_read_all_stats (or pg_monitor) see only the receiver's pid, so
+// for them a running receiver counts as streaming. A server that isn't a
+// replica reports no lag.
+const replicaLagQuery = `
+	SELECT
+		NOT pg_is_in_recovery()
+			OR EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE COALESCE(status, 'streaming') = 'streaming'),
+		CASE
+			WHEN NOT pg_is_in_recovery() THEN 0
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END`
+
+// errReplicaNotStreaming is a replica whose WAL receiver isn't streaming,
+// so its lag can't be known
+var errReplicaNotStreaming = errors.New("replica is not streaming WAL from the primary")
+
+// Replica routes reads between a primary and an optional read replica
+type Replica struct {
+	primary *sql.DB
+	replica *sql.DB
+	maxLag  time.Duration
+	usable  atomic.Bool
+
+	// lag measures the replica's lag; replaced in tests
+	lag func(ctx context.Context) (time.Duration, error)
+}
+
+// NewReplica routes reads to replica while it keeps within maxLag. The
+// replica isn't used until its first successful check. A nil replica sends
+// every read to the primary.
+func NewReplica(primary, replica *sql.DB, maxLag time.Duration) *Replica {
+	r := &Replica{primary: primary, replica: replica, maxLag: maxLag}
+	r.lag = r.queryLag
+	return r
+}
+
+// OpenReplica connects to DATABASE_REPLICA_URL, if set, with the given pool
+// and starts checking it. Without a replica, or if it can't be opened,
+// reads stay on the primary.
+func OpenReplica(ctx context.Context, service string, primary *sql.DB, pool PoolConfig) *Replica {
+	url := GetEnv("DATABASE_REPLICA_URL", "")
+	if url == "" {
+		return NewReplica(primary, nil, 0)
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		log.Printf("Failed to open read replica, reading from the primary: %v", err)
+		return NewReplica(primary, nil, 0)
+	}
+	PoolConfigFromEnv(pool).Apply(db)
+	metrics.InstrumentDBPool(service+"-replica", db)
+
+	maxLag, ok := envDuration("DB_REPLICA_MAX_LAG")
+	if !ok {
+		maxLag = DefaultReplicaMaxLag
+	}
+	interval, ok := envDuration("DB_REPLICA_CHECK_INTERVAL")
+	if !ok {
+		interval = DefaultReplicaCheckInterval
+	}
+
+	r := NewReplica(primary, db, maxLag)
+	r.Check(ctx)
+	go r.Run(ctx, interval)
+	return r
+}
+
+// Reader is the database to read from: the replica while it is usable,
+// and the primary otherwise
+func (r *Replica) Reader() *sql.DB {
+	if r.replica != nil && r.usable.Load() {
+		return r.replica
+	}
+	return r.primary
+}
+
+// Check measures the replica's lag and decides whether reads may use it
+func (r *Replica) Check(ctx context.Context) {
+	if r.replica == nil {
+		return
+	}
+	lag, err := r.lag(ctx)
+	usable := err == nil && lag <= r.maxLag
+
+	if was := r.usable.Swap(usable); was != usable {
+		switch {
+		case usable:
+			log.Printf("Read replica is usable (lag %s), reading from it", lag)
+		case err != nil:
+			log.Printf("Read replica is unusable, reading from the primary: %v", err)
+		default:
+			log.Printf("Read replica is %s behind (limit %s), reading from the primary", lag, r.maxLag)
+		}
+	}
+}
+
+// Run checks the replica every interval until ctx is done
+func (r *Replica) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Check(ctx)
+		}
+	}
+}
+
+// Close closes the replica's connections; the primary belongs to the caller
+func (r *Replica) Close() {
+	if r.replica != nil {
+		r.replica.Close()
+	}
+}
+
+func (r *Replica) queryLag(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	var streaming bool
+	var seconds float64
+	if err := r.replica.QueryRowContext(ctx, replicaLagQuery).Scan(&streaming, &seconds); err != nil {
+		return 0, err
+	}
+	return replicaLag(streaming, seconds)
+}
+
+// replicaLag reads the result of replicaLagQuery
+func replicaLag(streaming bool, seconds float64) (time.Duration, error) {
+	if !streaming {
+		return 0, errReplicaNotStreaming
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
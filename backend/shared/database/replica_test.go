@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReplicaReaderFollowsChecks(t *testing.T) {
+	primary, replica := &sql.DB{}, &sql.DB{}
+	r := NewReplica(primary, replica, 10*time.Second)
+
+	if r.Reader() != primary {
+		t.Error("The replica shouldn't be read from before it has been checked")
+	}
+
+	lag, lagErr := time.Second, error(nil)
+	r.lag = func(context.Context) (time.Duration, error) { return lag, lagErr }
+
+	r.Check(context.Background())
+	if r.Reader() != replica {
+		t.Error("A current replica should serve reads")
+	}
+
+	lag = time.Minute
+	r.Check(context.Background())
+	if r.Reader() != primary {
+		t.Error("A lagging replica should hand reads back to the primary")
+	}
+
+	lag = 0
+	r.Check(context.Background())
+	if r.Reader() != replica {
+		t.Error("A replica that caught up should serve reads again")
+	}
+
+	lagErr = errors.New("connection refused")
+	r.Check(context.Background())
+	if r.Reader() != primary {
+		t.Error("An unreachable replica should hand reads back to the primary")
+	}
+}
+
+func TestReplicaLag(t *testing.T) {
+	if lag, err := replicaLag(true, 2.5); err != nil || lag != 2500*time.Millisecond {
+		t.Errorf("Expected a streaming replica 2.5s behind, got %v, %v", lag, err)
+	}
+	if _, err := replicaLag(false, 0); !errors.Is(err, errReplicaNotStreaming) {
+		t.Errorf("A replica whose WAL receiver is down reports no lag but isn't current, got %v", err)
+	}
+
+	primary, replica := &sql.DB{}, &sql.DB{}
+	r := NewReplica(primary, replica, 10*time.Second)
+	r.lag = func(context.Context) (time.Duration, error) { return replicaLag(false, 0) }
+	r.Check(context.Background())
+	if r.Reader() != primary {
+		t.Error("A replica that isn't streaming should hand reads back to the primary")
+	}
+}
+
+func TestReplicaWithoutReplicaURL(t *testing.T) {
+	t.Setenv("DATABASE_REPLICA_URL", "")
+	primary := &sql.DB{}
+	r := OpenReplica(context.Background(), "test-service", primary, DefaultPoolConfig())
+	r.Check(context.Background())
+	if r.Reader() != primary {
+		t.Error("Without a replica every read should go to the primary")
+	}
+	r.Close()
+}
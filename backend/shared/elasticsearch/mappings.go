@@ -0,0 +1,32 @@
+// Package elasticsearch holds the index definitions used both by
+// scripts/setup-elasticsearch.sh and by the search service's index rebuilds
+package elasticsearch
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+//go:embed mappings.json
+var mappingsJSON []byte
+
+// IndexDefinition is an index's settings and mappings, the body of a
+// create index request
+type IndexDefinition struct {
+	Settings map[string]interface{} `json:"settings"`
+	Mappings map[string]interface{} `json:"mappings"`
+}
+
+// Definition returns the definition of the named index from mappings.json
+func Definition(name string) (IndexDefinition, error) {
+	var definitions map[string]IndexDefinition
+	if err := json.Unmarshal(mappingsJSON, &definitions); err != nil {
+		return IndexDefinition{}, fmt.Errorf("invalid mappings.json: %w", err)
+	}
+	definition, ok := definitions[name]
+	if !ok {
+		return IndexDefinition{}, fmt.Errorf("no index definition for %q", name)
+	}
+	return definition, nil
+}
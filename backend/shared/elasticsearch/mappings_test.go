@@ -0,0 +1,20 @@
+package elasticsearch
+
+import "testing"
+
+func TestDefinition(t *testing.T) {
+	works, err := Definition("works")
+	if err != nil {
+		t.Fatalf("Definition(works): %v", err)
+	}
+	if works.Settings["number_of_shards"] == nil {
+		t.Error("works settings are missing number_of_shards")
+	}
+	if _, ok := works.Mappings["properties"].(map[string]interface{}); !ok {
+		t.Error("works mappings are missing properties")
+	}
+
+	if _, err := Definition("nope"); err == nil {
+		t.Error("expected an error for an unknown index")
+	}
+}
@@ -0,0 +1,334 @@
+// Package deliverability follows email after it leaves us. Bounces and spam
+// complaints arrive synchronously from SMTP or later through provider
+// webhooks (Amazon SES, SendGrid); hard bounces and complaints put the
+// address on a suppression list and switch email off for its account, and
+// every delivery's status is kept so it can be looked up per message.
+package deliverability
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	smtperrors "nuclear-ao3/shared/messaging/errors"
+	"nuclear-ao3/shared/models"
+)
+
+// EventType is what a provider reported about a delivery
+type EventType string
+
+const (
+	EventDelivered EventType = "delivered"
+	EventDeferred  EventType = "deferred"
+	EventBounce    EventType = "bounce"
+	EventComplaint EventType = "complaint"
+)
+
+// BounceType separates addresses that can't receive mail (hard) from
+// mailboxes that are full, throttling or blocking us for now (soft)
+type BounceType string
+
+const (
+	BounceHard BounceType = "hard"
+	BounceSoft BounceType = "soft"
+)
+
+// SuppressionReason is why an address is no longer sent to
+type SuppressionReason string
+
+const (
+	ReasonHardBounce  SuppressionReason = "hard_bounce"
+	ReasonSoftBounces SuppressionReason = "soft_bounces" // too many in the window
+	ReasonComplaint   SuppressionReason = "complaint"
+	ReasonManual      SuppressionReason = "manual"
+)
+
+// Event is one report about one recipient of an email. DeliveryID is our
+// delivery attempt, recovered from the Message-ID header when the provider
+// echoes it; BounceType is set when the provider has already classified
+// the bounce.
+type Event struct {
+	Provider          string
+	Type              EventType
+	Address           string
+	DeliveryID        uuid.UUID
+	ProviderMessageID string
+	BounceType        BounceType
+	SMTPCode          int
+	EnhancedCode      string
+	Diagnostic        string
+	OccurredAt        time.Time
+}
+
+// Suppression is an address email is no longer sent to
+type Suppression struct {
+	Address   string            `json:"address"`
+	Reason    SuppressionReason `json:"reason"`
+	Detail    string            `json:"detail,omitempty"`
+	Provider  string            `json:"provider,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Bounce is a recorded bounce, kept to count soft bounces
+type Bounce struct {
+	Address    string
+	Type       BounceType
+	ErrorType  string
+	Diagnostic string
+	Provider   string
+	DeliveryID uuid.UUID
+	OccurredAt time.Time
+}
+
+// Delivery is one email sent to one address, and what became of it. Its
+// ID is the delivery attempt's, which is also the email's Message-ID.
+type Delivery struct {
+	ID          uuid.UUID             `json:"id"`
+	MessageID   uuid.UUID             `json:"message_id"`
+	UserID      uuid.UUID             `json:"user_id"`
+	Address     string                `json:"-"`
+	Status      models.DeliveryStatus `json:"status"`
+	Error       *models.DeliveryError `json:"error,omitempty"`
+	AttemptedAt time.Time             `json:"attempted_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+	DeliveredAt *time.Time            `json:"delivered_at,omitempty"`
+}
+
+// Store keeps the suppression list, bounces and delivery statuses.
+// Addresses are passed in normalized (trimmed and lower-cased).
+type Store interface {
+	// GetSuppression returns the address's suppression, or nil if it
+	// isn't suppressed
+	GetSuppression(ctx context.Context, address string) (*Suppression, error)
+	// Suppress adds an address to the list, replacing any earlier entry
+	Suppress(ctx context.Context, suppression *Suppression) error
+	// Unsuppress takes an address off the list
+	Unsuppress(ctx context.Context, address string) error
+
+	RecordBounce(ctx context.Context, bounce *Bounce) error
+	// CountBounces counts an address's bounces of a type since a time
+	CountBounces(ctx context.Context, address string, bounceType BounceType, since time.Time) (int, error)
+
+	// SaveDelivery records a delivery attempt
+	SaveDelivery(ctx context.Context, delivery *Delivery) error
+	// UpdateDeliveryStatus records a later report about a delivery; it is
+	// not an error if the delivery is unknown
+	UpdateDeliveryStatus(ctx context.Context, id uuid.UUID, status models.DeliveryStatus, deliveryErr *models.DeliveryError, at time.Time) error
+	// ListDeliveries returns every delivery of a message
+	ListDeliveries(ctx context.Context, messageID uuid.UUID) ([]*Delivery, error)
+}
+
+// EmailDisabler switches email off for the accounts using an address
+type EmailDisabler interface {
+	DisableEmail(ctx context.Context, address string, reason SuppressionReason) error
+}
+
+// Config sets when repeated soft bounces suppress an address
+type Config struct {
+	// SoftBounceLimit soft bounces within SoftBounceWindow suppress the
+	// address (default 5 in 7 days)
+	SoftBounceLimit  int
+	SoftBounceWindow time.Duration
+}
+
+// Service applies bounces and complaints to the suppression list and
+// tracks delivery status. It implements messaging.DeliverabilityTracker.
+type Service struct {
+	store      Store
+	disabler   EmailDisabler
+	classifier *smtperrors.SMTPErrorClassifier
+	config     Config
+}
+
+// NewService creates a deliverability service; disabler may be nil
+func NewService(store Store, disabler EmailDisabler, classifier *smtperrors.SMTPErrorClassifier, config Config) *Service {
+	if config.SoftBounceLimit <= 0 {
+		config.SoftBounceLimit = 5
+	}
+	if config.SoftBounceWindow <= 0 {
+		config.SoftBounceWindow = 7 * 24 * time.Hour
+	}
+	if classifier == nil {
+		classifier = smtperrors.NewSMTPErrorClassifier()
+	}
+	return &Service{store: store, disabler: disabler, classifier: classifier, config: config}
+}
+
+// NormalizeAddress is the form addresses are stored and compared in
+func NormalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// IsSuppressed reports whether email must not be sent to address
+func (s *Service) IsSuppressed(ctx context.Context, address string) (bool, error) {
+	suppression, err := s.store.GetSuppression(ctx, NormalizeAddress(address))
+	return suppression != nil, err
+}
+
+// GetSuppression returns the address's suppression, or nil
+func (s *Service) GetSuppression(ctx context.Context, address string) (*Suppression, error) {
+	return s.store.GetSuppression(ctx, NormalizeAddress(address))
+}
+
+// Unsuppress lets email go to an address again, once its owner has fixed
+// the mailbox or taken back a complaint
+func (s *Service) Unsuppress(ctx context.Context, address string) error {
+	return s.store.Unsuppress(ctx, NormalizeAddress(address))
+}
+
+// DeliveriesForMessage returns the status of every delivery of a message
+func (s *Service) DeliveriesForMessage(ctx context.Context, messageID uuid.UUID) ([]*Delivery, error) {
+	return s.store.ListDeliveries(ctx, messageID)
+}
+
+// RecordAttempt records an email delivery attempt. A send the receiving
+// server refused outright is handled as a bounce straight away.
+func (s *Service) RecordAttempt(ctx context.Context, attempt *models.DeliveryAttempt, address string) error {
+	if attempt.Channel != models.ChannelEmail {
+		return nil
+	}
+	address = NormalizeAddress(address)
+	delivery := &Delivery{
+		ID:          attempt.ID,
+		MessageID:   attempt.MessageID,
+		UserID:      attempt.UserID,
+		Address:     address,
+		Status:      attempt.Status,
+		Error:       attempt.Error,
+		AttemptedAt: attempt.AttemptedAt,
+		UpdatedAt:   time.Now(),
+		DeliveredAt: attempt.DeliveredAt,
+	}
+	if err := s.store.SaveDelivery(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to save delivery: %w", err)
+	}
+
+	smtpCode := 0
+	if attempt.Error != nil {
+		smtpCode, _ = attempt.Error.Details["smtp_code"].(int)
+	}
+	if attempt.Status != models.DeliveryStatusFailed || smtpCode < 500 || smtpCode > 599 {
+		return nil
+	}
+	return s.HandleEvent(ctx, Event{
+		Provider:   "smtp",
+		Type:       EventBounce,
+		Address:    address,
+		DeliveryID: attempt.ID,
+		SMTPCode:   smtpCode,
+		Diagnostic: attempt.Error.Message,
+		OccurredAt: attempt.AttemptedAt,
+	})
+}
+
+// HandleEvent applies a provider's report about a delivery
+func (s *Service) HandleEvent(ctx context.Context, event Event) error {
+	event.Address = NormalizeAddress(event.Address)
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	switch event.Type {
+	case EventDelivered:
+		return s.updateDelivery(ctx, event, models.DeliveryStatusDelivered, nil)
+	case EventDeferred:
+		return s.updateDelivery(ctx, event, models.DeliveryStatusRetrying, nil)
+	case EventComplaint:
+		if err := s.updateDelivery(ctx, event, models.DeliveryStatusComplained, nil); err != nil {
+			return err
+		}
+		return s.suppress(ctx, event, ReasonComplaint, "marked as spam")
+	case EventBounce:
+		return s.handleBounce(ctx, event)
+	}
+	return fmt.Errorf("unknown event type %q", event.Type)
+}
+
+func (s *Service) handleBounce(ctx context.Context, event Event) error {
+	deliveryErr := s.classifier.ClassifyBounce(event.SMTPCode, event.EnhancedCode, event.Diagnostic)
+	bounceType := event.BounceType
+	if bounceType == "" {
+		bounceType = BounceSoft
+		if s.classifier.IsHardBounce(deliveryErr.Type) {
+			bounceType = BounceHard
+		}
+	}
+
+	if err := s.updateDelivery(ctx, event, models.DeliveryStatusBounced, deliveryErr); err != nil {
+		return err
+	}
+	if event.Address == "" {
+		return nil
+	}
+	if err := s.store.RecordBounce(ctx, &Bounce{
+		Address:    event.Address,
+		Type:       bounceType,
+		ErrorType:  deliveryErr.Type,
+		Diagnostic: event.Diagnostic,
+		Provider:   event.Provider,
+		DeliveryID: event.DeliveryID,
+		OccurredAt: event.OccurredAt,
+	}); err != nil {
+		return fmt.Errorf("failed to record bounce: %w", err)
+	}
+
+	if bounceType == BounceHard {
+		return s.suppress(ctx, event, ReasonHardBounce, event.Diagnostic)
+	}
+	count, err := s.store.CountBounces(ctx, event.Address, BounceSoft, event.OccurredAt.Add(-s.config.SoftBounceWindow))
+	if err != nil {
+		return fmt.Errorf("failed to count soft bounces: %w", err)
+	}
+	if count >= s.config.SoftBounceLimit {
+		return s.suppress(ctx, event, ReasonSoftBounces,
+			fmt.Sprintf("%d soft bounces in %s; last: %s", count, s.config.SoftBounceWindow, event.Diagnostic))
+	}
+	return nil
+}
+
+func (s *Service) updateDelivery(ctx context.Context, event Event, status models.DeliveryStatus, deliveryErr *models.DeliveryError) error {
+	if event.DeliveryID == uuid.Nil {
+		return nil
+	}
+	if err := s.store.UpdateDeliveryStatus(ctx, event.DeliveryID, status, deliveryErr, event.OccurredAt); err != nil {
+		return fmt.Errorf("failed to update delivery %s: %w", event.DeliveryID, err)
+	}
+	return nil
+}
+
+// suppress puts the event's address on the list and switches email off
+// for the accounts using it
+func (s *Service) suppress(ctx context.Context, event Event, reason SuppressionReason, detail string) error {
+	if event.Address == "" {
+		return nil
+	}
+	if err := s.store.Suppress(ctx, &Suppression{
+		Address:   event.Address,
+		Reason:    reason,
+		Detail:    detail,
+		Provider:  event.Provider,
+		CreatedAt: event.OccurredAt,
+	}); err != nil {
+		return fmt.Errorf("failed to suppress address: %w", err)
+	}
+	log.Printf("Suppressed email to %s: %s", redactAddress(event.Address), reason)
+
+	if s.disabler != nil {
+		if err := s.disabler.DisableEmail(ctx, event.Address, reason); err != nil {
+			return fmt.Errorf("failed to disable email: %w", err)
+		}
+	}
+	return nil
+}
+
+// redactAddress keeps addresses out of logs: "j***@example.com"
+func redactAddress(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 1 {
+		return "***"
+	}
+	return address[:1] + "***" + address[at:]
+}
@@ -0,0 +1,251 @@
+package deliverability
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/models"
+)
+
+type recordingDisabler struct {
+	disabled map[string]SuppressionReason
+}
+
+func (d *recordingDisabler) DisableEmail(ctx context.Context, address string, reason SuppressionReason) error {
+	d.disabled[address] = reason
+	return nil
+}
+
+func newTestService() (*Service, *MemoryStore, *recordingDisabler) {
+	store := NewMemoryStore()
+	disabler := &recordingDisabler{disabled: make(map[string]SuppressionReason)}
+	return NewService(store, disabler, nil, Config{SoftBounceLimit: 3, SoftBounceWindow: 24 * time.Hour}), store, disabler
+}
+
+func emailAttempt(status models.DeliveryStatus) *models.DeliveryAttempt {
+	return &models.DeliveryAttempt{
+		ID:          uuid.New(),
+		MessageID:   uuid.New(),
+		UserID:      uuid.New(),
+		Channel:     models.ChannelEmail,
+		Status:      status,
+		AttemptedAt: time.Now(),
+	}
+}
+
+func TestHardBounceSuppressesAndDisablesEmail(t *testing.T) {
+	service, _, disabler := newTestService()
+	ctx := context.Background()
+
+	attempt := emailAttempt(models.DeliveryStatusSent)
+	if err := service.RecordAttempt(ctx, attempt, " Reader@Example.com "); err != nil {
+		t.Fatal(err)
+	}
+	err := service.HandleEvent(ctx, Event{
+		Provider:     "sendgrid",
+		Type:         EventBounce,
+		Address:      "reader@example.com",
+		DeliveryID:   attempt.ID,
+		EnhancedCode: "5.1.1",
+		Diagnostic:   "550 5.1.1 user unknown",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if suppressed, _ := service.IsSuppressed(ctx, "READER@example.com"); !suppressed {
+		t.Error("A hard bounce should suppress the address")
+	}
+	if disabler.disabled["reader@example.com"] != ReasonHardBounce {
+		t.Errorf("Email should be disabled for a hard bounce, got %v", disabler.disabled)
+	}
+	deliveries, _ := service.DeliveriesForMessage(ctx, attempt.MessageID)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryStatusBounced {
+		t.Fatalf("Expected one bounced delivery, got %+v", deliveries)
+	}
+	if deliveries[0].Error == nil || deliveries[0].Error.Type != "invalid_recipient" {
+		t.Errorf("Bounce should be classified as invalid_recipient, got %+v", deliveries[0].Error)
+	}
+}
+
+func TestSoftBouncesSuppressAtLimit(t *testing.T) {
+	service, _, disabler := newTestService()
+	ctx := context.Background()
+	start := time.Now()
+
+	bounce := func(at time.Time) {
+		err := service.HandleEvent(ctx, Event{
+			Provider:   "ses",
+			Type:       EventBounce,
+			Address:    "full@example.com",
+			SMTPCode:   452,
+			Diagnostic: "452 4.2.2 mailbox full",
+			OccurredAt: at,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An old bounce falls outside the window and doesn't count
+	bounce(start.Add(-48 * time.Hour))
+	bounce(start)
+	bounce(start.Add(time.Hour))
+	if suppressed, _ := service.IsSuppressed(ctx, "full@example.com"); suppressed {
+		t.Fatal("Two soft bounces in the window should not suppress")
+	}
+	bounce(start.Add(2 * time.Hour))
+	if suppressed, _ := service.IsSuppressed(ctx, "full@example.com"); !suppressed {
+		t.Fatal("The third soft bounce in the window should suppress")
+	}
+	if disabler.disabled["full@example.com"] != ReasonSoftBounces {
+		t.Errorf("Expected email disabled for soft bounces, got %v", disabler.disabled)
+	}
+}
+
+func TestComplaintSuppresses(t *testing.T) {
+	service, store, _ := newTestService()
+	ctx := context.Background()
+
+	if err := service.HandleEvent(ctx, Event{Provider: "ses", Type: EventComplaint, Address: "angry@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	suppression, _ := store.GetSuppression(ctx, "angry@example.com")
+	if suppression == nil || suppression.Reason != ReasonComplaint {
+		t.Fatalf("Expected a complaint suppression, got %+v", suppression)
+	}
+
+	if err := service.Unsuppress(ctx, "Angry@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if suppressed, _ := service.IsSuppressed(ctx, "angry@example.com"); suppressed {
+		t.Error("Unsuppress should lift the suppression")
+	}
+}
+
+func TestRecordAttemptTreatsSMTPRejectionAsBounce(t *testing.T) {
+	service, _, _ := newTestService()
+	ctx := context.Background()
+
+	attempt := emailAttempt(models.DeliveryStatusFailed)
+	attempt.Error = &models.DeliveryError{
+		Type:    "invalid_recipient",
+		Message: "550 No such user here",
+		Details: map[string]interface{}{"smtp_code": 550},
+	}
+	if err := service.RecordAttempt(ctx, attempt, "gone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if suppressed, _ := service.IsSuppressed(ctx, "gone@example.com"); !suppressed {
+		t.Error("A 550 at send time should suppress the address")
+	}
+
+	// A connection failure says nothing about the address
+	attempt = emailAttempt(models.DeliveryStatusFailed)
+	attempt.Error = &models.DeliveryError{Type: "network_error", Message: "connection refused"}
+	if err := service.RecordAttempt(ctx, attempt, "fine@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if suppressed, _ := service.IsSuppressed(ctx, "fine@example.com"); suppressed {
+		t.Error("A network error should not suppress the address")
+	}
+}
+
+func TestParseSESNotification(t *testing.T) {
+	deliveryID := uuid.New()
+	message, _ := json.Marshal(map[string]interface{}{
+		"notificationType": "Bounce",
+		"mail": map[string]interface{}{
+			"messageId":     "0100018d-ses",
+			"commonHeaders": map[string]interface{}{"messageId": "<" + deliveryID.String() + "@smtp.example.com>"},
+		},
+		"bounce": map[string]interface{}{
+			"bounceType": "Permanent",
+			"timestamp":  "2026-03-05T08:00:00.000Z",
+			"bouncedRecipients": []map[string]interface{}{
+				{"emailAddress": "a@example.com", "status": "5.1.1", "diagnosticCode": "smtp; 550 5.1.1 user unknown"},
+			},
+		},
+	})
+	body, _ := json.Marshal(map[string]string{"Type": "Notification", "Message": string(message)})
+
+	events, subscribeURL, err := ParseSESNotification(body)
+	if err != nil || subscribeURL != "" {
+		t.Fatalf("Unexpected result: %v %q", err, subscribeURL)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected one event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Type != EventBounce || event.BounceType != BounceHard || event.Address != "a@example.com" ||
+		event.DeliveryID != deliveryID || event.EnhancedCode != "5.1.1" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	confirm, _ := json.Marshal(map[string]string{"Type": "SubscriptionConfirmation", "SubscribeURL": "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription"})
+	if _, subscribeURL, err := ParseSESNotification(confirm); err != nil || subscribeURL == "" {
+		t.Errorf("Expected a subscription URL, got %q %v", subscribeURL, err)
+	}
+	forged, _ := json.Marshal(map[string]string{"Type": "SubscriptionConfirmation", "SubscribeURL": "http://169.254.169.254/latest"})
+	if _, _, err := ParseSESNotification(forged); err == nil {
+		t.Error("A non-AWS subscription URL should be refused")
+	}
+}
+
+func TestParseSendGridEvents(t *testing.T) {
+	deliveryID := uuid.New()
+	body := []byte(`[
+		{"email":"a@example.com","event":"bounce","type":"bounce","status":"5.1.1","reason":"user unknown","timestamp":1772697600,"smtp-id":"<` + deliveryID.String() + `@smtp.example.com>"},
+		{"email":"b@example.com","event":"bounce","type":"blocked","status":"5.7.1","reason":"blocked by policy","timestamp":1772697600},
+		{"email":"c@example.com","event":"spamreport","timestamp":1772697600},
+		{"email":"d@example.com","event":"open","timestamp":1772697600}
+	]`)
+
+	events, err := ParseSendGridEvents(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	if events[0].BounceType != BounceHard || events[0].DeliveryID != deliveryID {
+		t.Errorf("Unexpected bounce %+v", events[0])
+	}
+	if events[1].BounceType != BounceSoft {
+		t.Error("A blocked message should be a soft bounce")
+	}
+	if events[2].Type != EventComplaint {
+		t.Error("A spam report should be a complaint")
+	}
+}
+
+func TestVerifySendGridSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	publicKey := base64.StdEncoding.EncodeToString(der)
+
+	body := []byte(`[{"event":"delivered"}]`)
+	timestamp := "1772697600"
+	digest := sha256.Sum256(append([]byte(timestamp), body...))
+	sig, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	signature := base64.StdEncoding.EncodeToString(sig)
+
+	if err := VerifySendGridSignature(publicKey, signature, timestamp, body); err != nil {
+		t.Errorf("Valid signature rejected: %v", err)
+	}
+	if err := VerifySendGridSignature(publicKey, signature, timestamp, []byte(`[]`)); err == nil {
+		t.Error("A tampered body should be rejected")
+	}
+}
@@ -0,0 +1,106 @@
+package deliverability
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/models"
+)
+
+// MemoryStore is an in-process Store for tests and single-instance setups
+type MemoryStore struct {
+	mu           sync.Mutex
+	suppressions map[string]*Suppression
+	bounces      []*Bounce
+	deliveries   map[uuid.UUID]*Delivery
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		suppressions: make(map[string]*Suppression),
+		deliveries:   make(map[uuid.UUID]*Delivery),
+	}
+}
+
+func (m *MemoryStore) GetSuppression(ctx context.Context, address string) (*Suppression, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.suppressions[address], nil
+}
+
+func (m *MemoryStore) Suppress(ctx context.Context, suppression *Suppression) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.suppressions[suppression.Address] = suppression
+	return nil
+}
+
+func (m *MemoryStore) Unsuppress(ctx context.Context, address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.suppressions, address)
+	return nil
+}
+
+func (m *MemoryStore) RecordBounce(ctx context.Context, bounce *Bounce) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bounces = append(m.bounces, bounce)
+	return nil
+}
+
+func (m *MemoryStore) CountBounces(ctx context.Context, address string, bounceType BounceType, since time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, b := range m.bounces {
+		if b.Address == address && b.Type == bounceType && !b.OccurredAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) SaveDelivery(ctx context.Context, delivery *Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *delivery
+	m.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (m *MemoryStore) UpdateDeliveryStatus(ctx context.Context, id uuid.UUID, status models.DeliveryStatus, deliveryErr *models.DeliveryError, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery, ok := m.deliveries[id]
+	if !ok {
+		return nil
+	}
+	delivery.Status = status
+	delivery.UpdatedAt = at
+	if deliveryErr != nil {
+		delivery.Error = deliveryErr
+	}
+	if status == models.DeliveryStatusDelivered {
+		delivery.DeliveredAt = &at
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListDeliveries(ctx context.Context, messageID uuid.UUID) ([]*Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deliveries []*Delivery
+	for _, d := range m.deliveries {
+		if d.MessageID == messageID {
+			copied := *d
+			deliveries = append(deliveries, &copied)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].AttemptedAt.Before(deliveries[j].AttemptedAt) })
+	return deliveries, nil
+}
@@ -0,0 +1,242 @@
+package deliverability
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeliveryIDFromMessageID recovers our delivery ID from a Message-ID
+// header, "<id@host>", or returns uuid.Nil for mail we didn't label
+func DeliveryIDFromMessageID(messageID string) uuid.UUID {
+	messageID = strings.Trim(strings.TrimSpace(messageID), "<>")
+	if at := strings.Index(messageID, "@"); at >= 0 {
+		messageID = messageID[:at]
+	}
+	id, err := uuid.Parse(messageID)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// =============================================================================
+// AMAZON SES
+// SES reports bounces, complaints and deliveries through SNS. The SNS
+// envelope's Message holds the SES notification as a JSON string, and a new
+// subscription is confirmed by fetching its SubscribeURL.
+// =============================================================================
+
+type snsEnvelope struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"` // configuration-set event publishing
+	Mail             struct {
+		MessageID     string `json:"messageId"`
+		CommonHeaders struct {
+			MessageID string `json:"messageId"`
+		} `json:"commonHeaders"`
+	} `json:"mail"`
+	Bounce *struct {
+		BounceType        string    `json:"bounceType"`
+		Timestamp         time.Time `json:"timestamp"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			Status         string `json:"status"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint *struct {
+		Timestamp            time.Time `json:"timestamp"`
+		ComplainedRecipients []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+	} `json:"complaint"`
+	Delivery *struct {
+		Timestamp  time.Time `json:"timestamp"`
+		Recipients []string  `json:"recipients"`
+	} `json:"delivery"`
+}
+
+// ParseSESNotification reads an SNS delivery from SES. For a subscription
+// confirmation it returns the URL to fetch instead of events; it only
+// returns AWS URLs, so a forged confirmation can't make us fetch anything
+// else.
+func ParseSESNotification(body []byte) (events []Event, subscribeURL string, err error) {
+	var envelope snsEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, "", fmt.Errorf("invalid SNS message: %w", err)
+	}
+
+	switch envelope.Type {
+	case "SubscriptionConfirmation":
+		u, err := url.Parse(envelope.SubscribeURL)
+		if err != nil || u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".amazonaws.com") {
+			return nil, "", errors.New("subscription confirmation URL is not an AWS URL")
+		}
+		return nil, envelope.SubscribeURL, nil
+	case "Notification":
+	default:
+		return nil, "", nil
+	}
+
+	var n sesNotification
+	if err := json.Unmarshal([]byte(envelope.Message), &n); err != nil {
+		return nil, "", fmt.Errorf("invalid SES notification: %w", err)
+	}
+	kind := n.NotificationType
+	if kind == "" {
+		kind = n.EventType
+	}
+	base := Event{
+		Provider:          "ses",
+		DeliveryID:        DeliveryIDFromMessageID(n.Mail.CommonHeaders.MessageID),
+		ProviderMessageID: n.Mail.MessageID,
+	}
+
+	switch {
+	case kind == "Bounce" && n.Bounce != nil:
+		// Transient and Undetermined bounces are left to the classifier
+		bounceType := BounceType("")
+		if n.Bounce.BounceType == "Permanent" {
+			bounceType = BounceHard
+		}
+		for _, r := range n.Bounce.BouncedRecipients {
+			event := base
+			event.Type = EventBounce
+			event.Address = r.EmailAddress
+			event.BounceType = bounceType
+			event.EnhancedCode = r.Status
+			event.Diagnostic = r.DiagnosticCode
+			event.OccurredAt = n.Bounce.Timestamp
+			events = append(events, event)
+		}
+	case kind == "Complaint" && n.Complaint != nil:
+		for _, r := range n.Complaint.ComplainedRecipients {
+			event := base
+			event.Type = EventComplaint
+			event.Address = r.EmailAddress
+			event.OccurredAt = n.Complaint.Timestamp
+			events = append(events, event)
+		}
+	case kind == "Delivery" && n.Delivery != nil:
+		for _, address := range n.Delivery.Recipients {
+			event := base
+			event.Type = EventDelivered
+			event.Address = address
+			event.OccurredAt = n.Delivery.Timestamp
+			events = append(events, event)
+		}
+	}
+	return events, "", nil
+}
+
+// =============================================================================
+// SENDGRID
+// The event webhook posts a JSON array of events. A "bounce" event with
+// type "blocked" is the receiving server refusing us, not a bad address.
+// =============================================================================
+
+type sendGridEvent struct {
+	Email       string `json:"email"`
+	Event       string `json:"event"`
+	Type        string `json:"type"`
+	Status      string `json:"status"`
+	Reason      string `json:"reason"`
+	Response    string `json:"response"`
+	Timestamp   int64  `json:"timestamp"`
+	SMTPID      string `json:"smtp-id"`
+	SGMessageID string `json:"sg_message_id"`
+}
+
+// ParseSendGridEvents reads a SendGrid event webhook post, skipping the
+// events (opens, clicks, processed) that don't bear on deliverability
+func ParseSendGridEvents(body []byte) ([]Event, error) {
+	var raw []sendGridEvent
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("invalid SendGrid events: %w", err)
+	}
+
+	var events []Event
+	for _, r := range raw {
+		event := Event{
+			Provider:          "sendgrid",
+			Address:           r.Email,
+			DeliveryID:        DeliveryIDFromMessageID(r.SMTPID),
+			ProviderMessageID: r.SGMessageID,
+			EnhancedCode:      r.Status,
+			OccurredAt:        time.Unix(r.Timestamp, 0),
+		}
+		switch r.Event {
+		case "delivered":
+			event.Type = EventDelivered
+		case "deferred":
+			event.Type = EventDeferred
+			event.Diagnostic = r.Response
+		case "bounce":
+			event.Type = EventBounce
+			event.Diagnostic = r.Reason
+			if r.Type == "blocked" {
+				event.BounceType = BounceSoft
+			} else {
+				event.BounceType = BounceHard
+			}
+		case "spamreport":
+			event.Type = EventComplaint
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// VerifySendGridSignature checks a signed event webhook post. The public
+// key is the base64 DER key from SendGrid's settings; signature and
+// timestamp are the X-Twilio-Email-Event-Webhook-Signature and -Timestamp
+// headers.
+func VerifySendGridSignature(publicKey, signature, timestamp string, body []byte) error {
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("public key is not an ECDSA key")
+	}
+
+	sigBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.New("invalid signature encoding")
+	}
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sigBytes, &sig); err != nil {
+		return errors.New("invalid signature")
+	}
+
+	digest := sha256.Sum256(append([]byte(timestamp), body...))
+	if !ecdsa.Verify(key, digest[:], sig.R, sig.S) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
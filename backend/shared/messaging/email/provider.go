@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"html"
 	"net"
 	"net/smtp"
 	"regexp"
@@ -140,6 +141,10 @@ func (e *EmailChannelProvider) DeliverMessage(ctx context.Context, msg *models.M
 		return attempt, fmt.Errorf("failed to render email template: %w", err)
 	}
 
+	if unsubscribeURL, ok := recipient.Context[models.RecipientContextUnsubscribeURL].(string); ok && unsubscribeURL != "" {
+		addUnsubscribeLink(renderedEmail, unsubscribeURL)
+	}
+
 	// Send email with full telemetry
 	smtpResponse, err := e.sendEmailWithTelemetry(ctx, emailAddress, renderedEmail, attempt)
 
@@ -190,7 +195,7 @@ func (e *EmailChannelProvider) sendEmailWithTelemetry(ctx context.Context, to st
 	})
 
 	// Build email message
-	message, err := e.buildEmailMessage(to, email)
+	message, err := e.buildEmailMessage(to, email, attempt.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build email message: %w", err)
 	}
@@ -304,8 +309,30 @@ func (e *EmailChannelProvider) connectSMTP(ctx context.Context) (net.Conn, error
 	return dialer.DialContext(ctx, "tcp", address)
 }
 
+// addUnsubscribeLink adds the List-Unsubscribe headers, which mail clients
+// show as an unsubscribe button and POST to for one-click unsubscribe
+// (RFC 8058), and puts the link at the foot of the email
+func addUnsubscribeLink(email *templates.RenderedEmail, unsubscribeURL string) {
+	if email.Headers == nil {
+		email.Headers = make(map[string]string)
+	}
+	email.Headers["List-Unsubscribe"] = "<" + unsubscribeURL + ">"
+	email.Headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+
+	email.PlainText += "\n\nUnsubscribe: " + unsubscribeURL + "\n"
+	if email.HTML != "" {
+		footer := fmt.Sprintf(`<p style="font-size: 12px; color: #666;"><a href="%s">Unsubscribe</a></p>`,
+			html.EscapeString(unsubscribeURL))
+		if end := strings.LastIndex(email.HTML, "</body>"); end >= 0 {
+			email.HTML = email.HTML[:end] + footer + email.HTML[end:]
+		} else {
+			email.HTML += footer
+		}
+	}
+}
+
 // buildEmailMessage constructs the full email message
-func (e *EmailChannelProvider) buildEmailMessage(to string, email *templates.RenderedEmail) (string, error) {
+func (e *EmailChannelProvider) buildEmailMessage(to string, email *templates.RenderedEmail, attemptID uuid.UUID) (string, error) {
 	var message strings.Builder
 
 	// Headers
@@ -323,7 +350,8 @@ func (e *EmailChannelProvider) buildEmailMessage(to string, email *templates.Ren
 
 	message.WriteString("MIME-Version: 1.0\r\n")
 	message.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
-	message.WriteString(fmt.Sprintf("Message-ID: <%s@%s>\r\n", uuid.New().String(), e.config.Host))
+	// The attempt ID comes back in bounce and complaint reports
+	message.WriteString(fmt.Sprintf("Message-ID: <%s@%s>\r\n", attemptID, e.config.Host))
 
 	// Custom headers
 	for key, value := range email.Headers {
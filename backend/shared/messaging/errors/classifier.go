@@ -1,6 +1,8 @@
 package errors
 
 import (
+	"regexp"
+	"strconv"
 	"strings"
 
 	"nuclear-ao3/shared/models"
@@ -25,7 +27,7 @@ func (c *SMTPErrorClassifier) ClassifySMTPError(code int, message string) *model
 
 	return &models.DeliveryError{
 		Type:      errorType,
-		Code:      string(rune(code)),
+		Code:      strconv.Itoa(code),
 		Message:   message,
 		Retryable: retryable,
 		Details: map[string]interface{}{
@@ -36,6 +38,96 @@ func (c *SMTPErrorClassifier) ClassifySMTPError(code int, message string) *model
 	}
 }
 
+var (
+	// smtpCodePattern finds the reply code in a bounce diagnostic such as
+	// "smtp; 550 5.1.1 user unknown"
+	smtpCodePattern = regexp.MustCompile(`\b([245]\d\d)\b`)
+	// enhancedCodePattern finds an RFC 3463 enhanced status code
+	enhancedCodePattern = regexp.MustCompile(`\b([245]\.\d{1,3}\.\d{1,3})\b`)
+)
+
+// ClassifyBounce classifies a bounce reported after the message left us,
+// from a delivery status notification or a provider webhook. The enhanced
+// status code says most about the recipient, so it decides where it can;
+// otherwise permanent failures are refined by the message as well, since a
+// 5xx can mean the mailbox doesn't exist or that the message was blocked.
+// Missing codes are read from the diagnostic text.
+func (c *SMTPErrorClassifier) ClassifyBounce(code int, enhancedCode, message string) *models.DeliveryError {
+	if code == 0 {
+		if match := smtpCodePattern.FindStringSubmatch(message); match != nil {
+			code, _ = strconv.Atoi(match[1])
+		}
+	}
+	if enhancedCode == "" {
+		if match := enhancedCodePattern.FindStringSubmatch(message); match != nil {
+			enhancedCode = match[1]
+		}
+	}
+	if code == 0 {
+		switch {
+		case strings.HasPrefix(enhancedCode, "5."):
+			code = 550
+		case strings.HasPrefix(enhancedCode, "4."):
+			code = 451
+		}
+	}
+
+	errorType := c.classifyEnhancedCode(enhancedCode)
+	if errorType == "" {
+		errorType, _ = c.classifyByCode(code)
+		if code >= 400 && code < 600 {
+			if refined, _ := c.refineByMessage(message, false); refined != "temporary_failure" {
+				errorType = refined
+			}
+		}
+	}
+
+	return &models.DeliveryError{
+		Type:      errorType,
+		Code:      strconv.Itoa(code),
+		Message:   message,
+		Retryable: c.IsRetryable(errorType),
+		Details: map[string]interface{}{
+			"smtp_code":     code,
+			"enhanced_code": enhancedCode,
+			"smtp_message":  message,
+			"category":      c.getErrorCategory(errorType),
+			"hard_bounce":   c.IsHardBounce(errorType),
+		},
+	}
+}
+
+// classifyEnhancedCode maps the enhanced status codes that pin down the
+// recipient (RFC 3463), or returns "" for the rest
+func (c *SMTPErrorClassifier) classifyEnhancedCode(code string) string {
+	switch {
+	case strings.HasPrefix(code, "5.1."):
+		// Bad mailbox, bad domain or a moved recipient
+		return "invalid_recipient"
+	case code == "5.2.1":
+		return "mailbox_unavailable"
+	case code == "5.2.2" || code == "4.2.2":
+		return "mailbox_full"
+	case strings.HasPrefix(code, "5.7."):
+		// Delivery refused by policy: a block, not a bad address
+		return "reputation_issue"
+	case strings.HasPrefix(code, "4."):
+		return "temporary_failure"
+	}
+	return ""
+}
+
+// IsHardBounce reports whether an error type means the address itself
+// can't receive mail, so sending to it again would only hurt our sending
+// reputation
+func (c *SMTPErrorClassifier) IsHardBounce(errorType string) bool {
+	switch errorType {
+	case "invalid_recipient", "mailbox_unavailable", "user_not_local", "mailbox_name_invalid":
+		return true
+	}
+	return false
+}
+
 // classifyByCode provides initial classification based on SMTP response codes
 func (c *SMTPErrorClassifier) classifyByCode(code int) (string, bool) {
 	switch {
@@ -159,7 +251,7 @@ func (c *SMTPErrorClassifier) getErrorCategory(errorType string) string {
 		return "connectivity"
 	case "content_filtered":
 		return "content"
-	case "mailbox_full", "mailbox_unavailable", "invalid_recipient":
+	case "mailbox_full", "mailbox_unavailable", "invalid_recipient", "user_not_local", "mailbox_name_invalid":
 		return "recipient"
 	case "server_error", "server_maintenance", "service_unavailable":
 		return "server"
@@ -229,6 +229,18 @@ type RateLimiter interface {
 	GetUsage(ctx context.Context, channel models.DeliveryChannel) (int, error)
 }
 
+// DeliverabilityTracker defines the interface for following email after it
+// is sent (see the deliverability package)
+type DeliverabilityTracker interface {
+	// IsSuppressed reports whether an address has bounced or complained and
+	// must not be sent to
+	IsSuppressed(ctx context.Context, address string) (bool, error)
+
+	// RecordAttempt records a delivery attempt so later bounces and
+	// complaints can be matched to it
+	RecordAttempt(ctx context.Context, attempt *models.DeliveryAttempt, address string) error
+}
+
 // DeliveryQueue defines the interface for queueing message deliveries
 type DeliveryQueue interface {
 	// Enqueue adds a delivery attempt to the queue
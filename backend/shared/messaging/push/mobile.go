@@ -0,0 +1,443 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"nuclear-ao3/shared/models"
+)
+
+const (
+	fcmScope       = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmSendURL     = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+	apnsProduction = "https://api.push.apple.com"
+	apnsSandbox    = "https://api.sandbox.push.apple.com"
+
+	// APNs rejects provider tokens older than an hour and throttles ones
+	// refreshed more often than every 20 minutes
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// FCMConfig configures Firebase Cloud Messaging through the HTTP v1 API
+type FCMConfig struct {
+	// ServiceAccountFile is the path to a Firebase service account's JSON key
+	ServiceAccountFile string
+}
+
+// APNsConfig configures the Apple Push Notification service with
+// token-based (.p8 key) authentication
+type APNsConfig struct {
+	KeyFile  string // the .p8 signing key downloaded from Apple
+	KeyID    string
+	TeamID   string
+	BundleID string // sent as apns-topic
+	Sandbox  bool   // use the development environment
+}
+
+// MobilePushProvider delivers to mobile apps, through FCM for Android
+// devices and APNs for iOS devices. Either service can be left
+// unconfigured, in which case its devices are skipped.
+type MobilePushProvider struct {
+	devices DeviceStore
+	client  *http.Client
+	senders map[models.PushPlatform]deviceSender
+	stats   counters
+
+	fcm  *fcmSender
+	apns *apnsSender
+}
+
+// NewMobilePushProvider creates a mobile push provider for whichever of
+// FCM and APNs are configured (nil to leave one out)
+func NewMobilePushProvider(fcmConfig *FCMConfig, apnsConfig *APNsConfig, devices DeviceStore) (*MobilePushProvider, error) {
+	m := &MobilePushProvider{
+		devices: devices,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		senders: map[models.PushPlatform]deviceSender{},
+	}
+	if fcmConfig != nil {
+		sender, err := newFCMSender(*fcmConfig, m.client)
+		if err != nil {
+			return nil, fmt.Errorf("FCM: %w", err)
+		}
+		m.fcm = sender
+		m.senders[models.PushPlatformFCM] = sender.send
+	}
+	if apnsConfig != nil {
+		sender, err := newAPNsSender(*apnsConfig, m.client)
+		if err != nil {
+			return nil, fmt.Errorf("APNs: %w", err)
+		}
+		m.apns = sender
+		m.senders[models.PushPlatformAPNs] = sender.send
+	}
+	if len(m.senders) == 0 {
+		return nil, errors.New("neither FCM nor APNs is configured")
+	}
+	return m, nil
+}
+
+// Platforms lists the platforms this provider can reach
+func (m *MobilePushProvider) Platforms() []models.PushPlatform {
+	platforms := make([]models.PushPlatform, 0, len(m.senders))
+	for platform := range m.senders {
+		platforms = append(platforms, platform)
+	}
+	return platforms
+}
+
+// GetChannelType returns the channel type
+func (m *MobilePushProvider) GetChannelType() models.DeliveryChannel {
+	return models.ChannelPush
+}
+
+// DeliverMessage pushes a message to each of the recipient's app installs
+func (m *MobilePushProvider) DeliverMessage(ctx context.Context, msg *models.Message, recipient *models.Recipient) (*models.DeliveryAttempt, error) {
+	return deliver(ctx, m.devices, models.ChannelPush, m.senders, &m.stats, msg, recipient)
+}
+
+// ValidateAddress checks a device token looks like one: FCM tokens are
+// opaque strings and APNs tokens hex, neither short nor containing spaces
+func (m *MobilePushProvider) ValidateAddress(address string) error {
+	if len(address) < 32 || len(address) > 4096 || strings.ContainsAny(address, " \t\r\n") {
+		return errors.New("invalid device token")
+	}
+	return nil
+}
+
+// SendVerification isn't needed for push: an app proves it can receive
+// pushes by registering its token
+func (m *MobilePushProvider) SendVerification(ctx context.Context, address string, token string) error {
+	return errors.New("push devices don't need verification")
+}
+
+// GetDeliveryStatus isn't available: FCM and APNs don't report delivery
+func (m *MobilePushProvider) GetDeliveryStatus(ctx context.Context, messageID string) (*models.DeliveryAttempt, error) {
+	return nil, errors.New("mobile push services don't report delivery status")
+}
+
+// GetMetrics returns the provider's delivery counts since it started
+func (m *MobilePushProvider) GetMetrics(ctx context.Context, start, end time.Time) (*models.ChannelMetrics, error) {
+	return m.stats.metrics(), nil
+}
+
+// IsAvailable reports whether FCM can issue access tokens, when it's
+// configured; APNs credentials are checked when the provider is created
+func (m *MobilePushProvider) IsAvailable(ctx context.Context) bool {
+	if m.fcm == nil {
+		return true
+	}
+	_, err := m.fcm.accessToken(ctx)
+	return err == nil
+}
+
+// =============================================================================
+// FCM
+// =============================================================================
+
+// fcmServiceAccount is the part of a service account key FCM needs
+type fcmServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+type fcmSender struct {
+	account fcmServiceAccount
+	key     *rsa.PrivateKey
+	client  *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newFCMSender(config FCMConfig, client *http.Client) (*fcmSender, error) {
+	data, err := os.ReadFile(config.ServiceAccountFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account: %w", err)
+	}
+	var account fcmServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("invalid service account: %w", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" {
+		return nil, errors.New("service account is missing project_id or client_email")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	parsed, err := parsePKCS8PEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account key is not an RSA key")
+	}
+	return &fcmSender{account: account, key: key, client: client}, nil
+}
+
+// accessToken returns an OAuth2 access token for FCM, exchanging a signed
+// service account assertion for a new one shortly before the last expires
+func (f *fcmSender) accessToken(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.token != "" && time.Now().Before(f.expires) {
+		return f.token, nil
+	}
+
+	now := time.Now()
+	signingInput, err := jwtSigningInput(map[string]interface{}{"typ": "JWT", "alg": "RS256"}, map[string]interface{}{
+		"iss":   f.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   f.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token exchange failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token exchange returned %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", errors.New("token exchange returned no access token")
+	}
+
+	f.token = token.AccessToken
+	f.expires = now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return f.token, nil
+}
+
+func (f *fcmSender) send(ctx context.Context, device *models.PushDevice, n Notification) (sendResult, error) {
+	token, err := f.accessToken(ctx)
+	if err != nil {
+		return sendRetry, err
+	}
+
+	priority := "NORMAL"
+	if n.Urgent {
+		priority = "HIGH"
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token":        device.Token,
+			"notification": map[string]string{"title": n.Title, "body": n.Body},
+			"data":         map[string]string{"url": n.URL},
+			"android":      map[string]string{"priority": priority},
+		},
+	})
+	if err != nil {
+		return sendFailed, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(fcmSendURL, f.account.ProjectID), bytes.NewReader(body))
+	if err != nil {
+		return sendFailed, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return sendRetry, fmt.Errorf("FCM unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return sendOK, nil
+	}
+
+	var failure struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&failure)
+	err = fmt.Errorf("FCM returned %s: %s", resp.Status, failure.Error.Message)
+	for _, detail := range failure.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" || detail.ErrorCode == "INVALID_ARGUMENT" {
+			return sendGone, err
+		}
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		// The cached access token was revoked; fetch a new one next time
+		f.mu.Lock()
+		f.token = ""
+		f.mu.Unlock()
+		return sendRetry, err
+	}
+	return resultForStatus(resp.StatusCode, http.StatusNotFound), err
+}
+
+// =============================================================================
+// APNs
+// =============================================================================
+
+type apnsSender struct {
+	config APNsConfig
+	key    *ecdsa.PrivateKey
+	host   string
+	client *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func newAPNsSender(config APNsConfig, client *http.Client) (*apnsSender, error) {
+	if config.KeyID == "" || config.TeamID == "" || config.BundleID == "" {
+		return nil, errors.New("key ID, team ID and bundle ID are required")
+	}
+	data, err := os.ReadFile(config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	parsed, err := parsePKCS8PEM(data)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key is not an EC key")
+	}
+	host := apnsProduction
+	if config.Sandbox {
+		host = apnsSandbox
+	}
+	return &apnsSender{config: config, key: key, host: host, client: client}, nil
+}
+
+// providerToken returns the signed token APNs authenticates requests with,
+// reusing it until it nears the end of its lifetime
+func (a *apnsSender) providerToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Since(a.issuedAt) < apnsTokenLifetime {
+		return a.token, nil
+	}
+	now := time.Now()
+	token, err := signES256(a.key, map[string]interface{}{"alg": "ES256", "kid": a.config.KeyID},
+		map[string]interface{}{"iss": a.config.TeamID, "iat": now.Unix()})
+	if err != nil {
+		return "", err
+	}
+	a.token, a.issuedAt = token, now
+	return token, nil
+}
+
+func (a *apnsSender) send(ctx context.Context, device *models.PushDevice, n Notification) (sendResult, error) {
+	token, err := a.providerToken()
+	if err != nil {
+		return sendFailed, err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{"title": n.Title, "body": n.Body},
+			"sound": "default",
+		},
+		"url": n.URL,
+	})
+	if err != nil {
+		return sendFailed, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.host+"/3/device/"+url.PathEscape(device.Token), bytes.NewReader(body))
+	if err != nil {
+		return sendFailed, err
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("apns-topic", a.config.BundleID)
+	req.Header.Set("apns-push-type", "alert")
+	if n.Urgent {
+		req.Header.Set("apns-priority", "10")
+	} else {
+		req.Header.Set("apns-priority", "5")
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return sendRetry, fmt.Errorf("APNs unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return sendOK, nil
+	}
+
+	var failure struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(resp.Body).Decode(&failure)
+	err = fmt.Errorf("APNs returned %s: %s", resp.Status, failure.Reason)
+	switch failure.Reason {
+	case "BadDeviceToken", "Unregistered", "DeviceTokenNotForTopic":
+		return sendGone, err
+	case "ExpiredProviderToken":
+		a.mu.Lock()
+		a.token = ""
+		a.mu.Unlock()
+		return sendRetry, err
+	}
+	return resultForStatus(resp.StatusCode, http.StatusGone), err
+}
+
+// parsePKCS8PEM parses a PEM-encoded PKCS #8 private key, the format of
+// both Firebase service account keys and APNs .p8 keys
+func parsePKCS8PEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,198 @@
+// Package push delivers notifications to browsers through Web Push and to
+// mobile apps through FCM and APNs. A user can have several devices; a
+// message goes to every device registered on the channel's platforms, and
+// devices the push service reports as gone are dropped from the store.
+package push
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/models"
+)
+
+// DeviceStore is where push devices are registered
+type DeviceStore interface {
+	// DevicesForUser returns a user's devices on the given platforms
+	DevicesForUser(ctx context.Context, userID uuid.UUID, platforms ...models.PushPlatform) ([]*models.PushDevice, error)
+
+	// RemoveDevice drops a device the push service no longer knows
+	RemoveDevice(ctx context.Context, deviceID uuid.UUID) error
+
+	// MarkDeviceUsed records a successful delivery to a device
+	MarkDeviceUsed(ctx context.Context, deviceID uuid.UUID, at time.Time) error
+}
+
+// Notification is what a push shows: a title, a line of text and the page
+// opened when it's tapped
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url,omitempty"`
+
+	// Urgent notifications (account security, password resets) ask the
+	// push service to wake the device
+	Urgent bool `json:"-"`
+}
+
+// maxBodyLength keeps payloads inside the push services' size limits
+// (4KB for FCM, APNs and most Web Push services) with room for the rest
+const maxBodyLength = 1000
+
+// notificationFor builds the push shown for a message
+func notificationFor(msg *models.Message) Notification {
+	body := msg.Content.PlainText
+	if runes := []rune(body); len(runes) > maxBodyLength {
+		body = string(runes[:maxBodyLength-1]) + "…"
+	}
+	return Notification{
+		Title:  msg.Content.Subject,
+		Body:   body,
+		URL:    msg.Content.ActionURL,
+		Urgent: msg.Type == models.MessageAccountSecurity || msg.Type == models.MessagePasswordReset,
+	}
+}
+
+// sendResult is how a push service answered for one device
+type sendResult int
+
+const (
+	sendOK     sendResult = iota
+	sendGone              // the device is unregistered or its token invalid
+	sendRetry             // throttled or a server error; worth trying again
+	sendFailed            // rejected for a reason retrying won't fix
+)
+
+// deviceSender sends a notification to one device
+type deviceSender func(ctx context.Context, device *models.PushDevice, n Notification) (sendResult, error)
+
+// counters track a provider's deliveries for GetMetrics
+type counters struct {
+	sent, failed, latencyMs atomic.Int64
+}
+
+func (c *counters) metrics() *models.ChannelMetrics {
+	sent, failed := c.sent.Load(), c.failed.Load()
+	m := &models.ChannelMetrics{Sent: sent, Delivered: sent, Failed: failed}
+	if total := sent + failed; total > 0 {
+		m.DeliveryRate = float64(sent) / float64(total)
+		m.AvgLatency = c.latencyMs.Load() / total
+	}
+	return m
+}
+
+// deliver sends a message to each of the recipient's devices on the given
+// platforms, using the sender for each device's platform. The attempt
+// succeeds if any device received it and is retryable if a failure was.
+func deliver(ctx context.Context, store DeviceStore, channel models.DeliveryChannel, senders map[models.PushPlatform]deviceSender,
+	stats *counters, msg *models.Message, recipient *models.Recipient) (*models.DeliveryAttempt, error) {
+	start := time.Now()
+	attempt := &models.DeliveryAttempt{
+		ID:          uuid.New(),
+		MessageID:   msg.ID,
+		UserID:      recipient.UserID,
+		Channel:     channel,
+		Status:      models.DeliveryStatusPending,
+		AttemptedAt: start,
+		Metadata:    make(map[string]interface{}),
+	}
+	fail := func(errType, message string, retryable bool) (*models.DeliveryAttempt, error) {
+		attempt.Status = models.DeliveryStatusFailed
+		attempt.Error = &models.DeliveryError{Type: errType, Message: message, Retryable: retryable}
+		stats.failed.Add(1)
+		return attempt, fmt.Errorf("%s", message)
+	}
+
+	platforms := make([]models.PushPlatform, 0, len(senders))
+	for platform := range senders {
+		platforms = append(platforms, platform)
+	}
+	devices, err := store.DevicesForUser(ctx, recipient.UserID, platforms...)
+	if err != nil {
+		return fail("device_lookup_error", fmt.Sprintf("failed to load push devices: %v", err), true)
+	}
+	if len(devices) == 0 {
+		return fail("no_devices", "no push devices registered", false)
+	}
+
+	n := notificationFor(msg)
+	var (
+		mu                       sync.Mutex
+		wg                       sync.WaitGroup
+		delivered, gone, retries int
+		lastErr                  error
+	)
+	for _, device := range devices {
+		wg.Add(1)
+		go func(device *models.PushDevice) {
+			defer wg.Done()
+			result, err := senders[device.Platform](ctx, device, n)
+			switch result {
+			case sendOK:
+				store.MarkDeviceUsed(ctx, device.ID, time.Now())
+			case sendGone:
+				store.RemoveDevice(ctx, device.ID)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch result {
+			case sendOK:
+				delivered++
+			case sendGone:
+				gone++
+			case sendRetry:
+				retries++
+			}
+			if err != nil {
+				lastErr = err
+			}
+		}(device)
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	stats.latencyMs.Add(elapsed.Milliseconds())
+	attempt.Metadata["devices"] = len(devices)
+	attempt.Metadata["delivered"] = delivered
+	attempt.Metadata["removed"] = gone
+	attempt.Metadata["duration_ms"] = elapsed.Milliseconds()
+
+	if delivered > 0 {
+		now := time.Now()
+		attempt.Status = models.DeliveryStatusSent
+		attempt.DeliveredAt = &now
+		stats.sent.Add(1)
+		return attempt, nil
+	}
+	if gone == len(devices) {
+		return fail("devices_unregistered", "every push device was unregistered", false)
+	}
+	message := "push delivery failed"
+	if lastErr != nil {
+		message = lastErr.Error()
+	}
+	return fail("push_error", message, retries > 0)
+}
+
+// resultForStatus classifies a push service's HTTP status. goneStatuses
+// are the ones that mean the device is no longer registered.
+func resultForStatus(status int, goneStatuses ...int) sendResult {
+	for _, gone := range goneStatuses {
+		if status == gone {
+			return sendGone
+		}
+	}
+	switch {
+	case status >= 200 && status < 300:
+		return sendOK
+	case status == 429 || status >= 500:
+		return sendRetry
+	default:
+		return sendFailed
+	}
+}
@@ -0,0 +1,226 @@
+package push
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/models"
+)
+
+// decryptWebPush decrypts a payload the way a browser does
+func decryptWebPush(t *testing.T, body []byte, uaPrivate *ecdh.PrivateKey, auth []byte) []byte {
+	t.Helper()
+	if len(body) < 21 {
+		t.Fatalf("body too short: %d bytes", len(body))
+	}
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != webPushRecordSize {
+		t.Fatalf("record size = %d, want %d", rs, webPushRecordSize)
+	}
+	idLen := int(body[20])
+	asPublicBytes := body[21 : 21+idLen]
+	ciphertext := body[21+idLen:]
+
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		t.Fatalf("bad sender key: %v", err)
+	}
+	shared, err := uaPrivate.ECDH(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cek, nonce := webPushKeys(shared, auth, uaPrivate.PublicKey().Bytes(), asPublicBytes, salt)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	record, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	if record[len(record)-1] != 0x02 {
+		t.Fatalf("missing last-record delimiter")
+	}
+	return record[:len(record)-1]
+}
+
+func TestEncryptWebPushRoundTrip(t *testing.T) {
+	uaPrivate, _ := ecdh.P256().GenerateKey(rand.Reader)
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	p256dh := base64.RawURLEncoding.EncodeToString(uaPrivate.PublicKey().Bytes())
+
+	plaintext := []byte(`{"title":"New chapter","body":"Chapter 3 is up"}`)
+	// Padded base64url should be accepted too
+	body, err := encryptWebPush(plaintext, p256dh, base64.URLEncoding.EncodeToString(auth))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if got := decryptWebPush(t, body, uaPrivate, auth); string(got) != string(plaintext) {
+		t.Errorf("decrypted %q, want %q", got, plaintext)
+	}
+
+	if _, err := encryptWebPush(plaintext, p256dh, "c2hvcnQ"); err == nil {
+		t.Error("expected an error for a short auth secret")
+	}
+	if _, err := encryptWebPush(make([]byte, webPushRecordSize), p256dh, base64.RawURLEncoding.EncodeToString(auth)); err == nil {
+		t.Error("expected an error for an oversized payload")
+	}
+}
+
+func TestVAPIDAuthorization(t *testing.T) {
+	key, err := GenerateVAPIDKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewWebPushProvider(WebPushConfig{VAPIDPrivateKey: key, Subject: "mailto:admin@example.org"}, nil)
+	if err != nil {
+		t.Fatalf("provider: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	header, err := provider.vapidAuthorization("https://push.example.com/send/abc123", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(header, "vapid t="), ", k=", 2)
+	if len(parts) != 2 || parts[1] != provider.PublicKey() {
+		t.Fatalf("unexpected header %q", header)
+	}
+
+	segments := strings.Split(parts[0], ".")
+	if len(segments) != 3 {
+		t.Fatalf("token has %d segments", len(segments))
+	}
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(segments[1])
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Aud != "https://push.example.com" || claims.Sub != "mailto:admin@example.org" || claims.Exp != now.Add(12*time.Hour).Unix() {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	sig, _ := base64.RawURLEncoding.DecodeString(segments[2])
+	if len(sig) != 64 {
+		t.Fatalf("signature is %d bytes, want 64", len(sig))
+	}
+	digest := sha256.Sum256([]byte(segments[0] + "." + segments[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(&provider.key.PublicKey, digest[:], r, s) {
+		t.Error("signature does not verify against the VAPID public key")
+	}
+
+	if _, err := NewWebPushProvider(WebPushConfig{VAPIDPrivateKey: key}, nil); err == nil {
+		t.Error("expected an error without a subject")
+	}
+}
+
+func TestResultForStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   sendResult
+	}{
+		{201, sendOK},
+		{404, sendGone},
+		{410, sendGone},
+		{429, sendRetry},
+		{503, sendRetry},
+		{400, sendFailed},
+		{403, sendFailed},
+	}
+	for _, tt := range tests {
+		if got := resultForStatus(tt.status, 404, 410); got != tt.want {
+			t.Errorf("resultForStatus(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	devices []*models.PushDevice
+	removed []uuid.UUID
+	used    []uuid.UUID
+}
+
+func (m *memoryStore) DevicesForUser(ctx context.Context, userID uuid.UUID, platforms ...models.PushPlatform) ([]*models.PushDevice, error) {
+	var out []*models.PushDevice
+	for _, d := range m.devices {
+		for _, p := range platforms {
+			if d.UserID == userID && d.Platform == p {
+				out = append(out, d)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryStore) RemoveDevice(ctx context.Context, deviceID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removed = append(m.removed, deviceID)
+	return nil
+}
+
+func (m *memoryStore) MarkDeviceUsed(ctx context.Context, deviceID uuid.UUID, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.used = append(m.used, deviceID)
+	return nil
+}
+
+func TestDeliverRemovesGoneDevices(t *testing.T) {
+	userID := uuid.New()
+	live := &models.PushDevice{ID: uuid.New(), UserID: userID, Platform: models.PushPlatformFCM, Token: "live"}
+	stale := &models.PushDevice{ID: uuid.New(), UserID: userID, Platform: models.PushPlatformFCM, Token: "stale"}
+	other := &models.PushDevice{ID: uuid.New(), UserID: userID, Platform: models.PushPlatformWeb, Token: "https://push.example.com/x"}
+	store := &memoryStore{devices: []*models.PushDevice{live, stale, other}}
+
+	senders := map[models.PushPlatform]deviceSender{
+		models.PushPlatformFCM: func(ctx context.Context, device *models.PushDevice, n Notification) (sendResult, error) {
+			if device.Token == "stale" {
+				return sendGone, errors.New("UNREGISTERED")
+			}
+			return sendOK, nil
+		},
+	}
+	msg := &models.Message{ID: uuid.New(), Content: models.MessageContent{Subject: "Kudos", PlainText: "Someone left kudos"}}
+	var stats counters
+
+	attempt, err := deliver(context.Background(), store, models.ChannelPush, senders, &stats, msg, &models.Recipient{UserID: userID})
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if attempt.Status != models.DeliveryStatusSent {
+		t.Errorf("status = %s, want sent", attempt.Status)
+	}
+	if len(store.removed) != 1 || store.removed[0] != stale.ID {
+		t.Errorf("removed %v, want only the stale device", store.removed)
+	}
+	if len(store.used) != 1 || store.used[0] != live.ID {
+		t.Errorf("used %v, want only the live device", store.used)
+	}
+
+	store.devices = []*models.PushDevice{stale}
+	attempt, err = deliver(context.Background(), store, models.ChannelPush, senders, &stats, msg, &models.Recipient{UserID: userID})
+	if err == nil || attempt.Error == nil || attempt.Error.Retryable {
+		t.Errorf("expected a non-retryable failure when every device is gone, got %+v", attempt.Error)
+	}
+}
@@ -0,0 +1,334 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"nuclear-ao3/shared/models"
+)
+
+// WebPushConfig configures Web Push delivery
+type WebPushConfig struct {
+	// VAPIDPrivateKey is the application server's P-256 private key as
+	// unpadded base64url, the format web-push tooling generates
+	VAPIDPrivateKey string
+	// Subject is a mailto: or https: contact for push services to reach
+	Subject string
+	// TTL is how long a push service holds a message for an offline browser
+	TTL     time.Duration
+	Timeout time.Duration
+}
+
+// WebPushProvider delivers to browsers through the Web Push protocol,
+// encrypting payloads per RFC 8291 and identifying itself with VAPID
+// (RFC 8292)
+type WebPushProvider struct {
+	config    WebPushConfig
+	key       *ecdsa.PrivateKey
+	publicKey []byte // uncompressed point, handed to browsers to subscribe
+	devices   DeviceStore
+	client    *http.Client
+	stats     counters
+}
+
+// NewWebPushProvider creates a Web Push provider from a VAPID key
+func NewWebPushProvider(config WebPushConfig, devices DeviceStore) (*WebPushProvider, error) {
+	raw, err := decodeBase64URL(config.VAPIDPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	private, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	public := private.PublicKey().Bytes()
+	if config.Subject == "" {
+		return nil, errors.New("a VAPID subject (mailto: or https: contact) is required")
+	}
+	if config.TTL == 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &WebPushProvider{
+		config: config,
+		key: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: new(big.Int).SetBytes(raw),
+		},
+		publicKey: public,
+		devices:   devices,
+		client:    &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// PublicKey is the VAPID public key browsers pass to pushManager.subscribe
+// as applicationServerKey, as unpadded base64url
+func (w *WebPushProvider) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(w.publicKey)
+}
+
+// GetChannelType returns the channel type
+func (w *WebPushProvider) GetChannelType() models.DeliveryChannel {
+	return models.ChannelWebPush
+}
+
+// DeliverMessage pushes a message to each of the recipient's browsers
+func (w *WebPushProvider) DeliverMessage(ctx context.Context, msg *models.Message, recipient *models.Recipient) (*models.DeliveryAttempt, error) {
+	return deliver(ctx, w.devices, models.ChannelWebPush,
+		map[models.PushPlatform]deviceSender{models.PushPlatformWeb: w.send}, &w.stats, msg, recipient)
+}
+
+// send encrypts a notification for one browser subscription and posts it
+// to the subscription's push service
+func (w *WebPushProvider) send(ctx context.Context, device *models.PushDevice, n Notification) (sendResult, error) {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return sendFailed, err
+	}
+	body, err := encryptWebPush(payload, device.P256dh, device.Auth)
+	if err != nil {
+		// A subscription whose keys can't be used will never work
+		return sendGone, fmt.Errorf("unusable push subscription: %w", err)
+	}
+	auth, err := w.vapidAuthorization(device.Token, time.Now())
+	if err != nil {
+		return sendFailed, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, device.Token, bytes.NewReader(body))
+	if err != nil {
+		return sendGone, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(w.config.TTL.Seconds())))
+	if n.Urgent {
+		req.Header.Set("Urgency", "high")
+	} else {
+		req.Header.Set("Urgency", "normal")
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return sendRetry, fmt.Errorf("push service unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	result := resultForStatus(resp.StatusCode, http.StatusNotFound, http.StatusGone)
+	if result != sendOK {
+		return result, fmt.Errorf("push service returned %s", resp.Status)
+	}
+	return sendOK, nil
+}
+
+// vapidAuthorization signs the VAPID JWT for an endpoint's push service
+func (w *WebPushProvider) vapidAuthorization(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid push endpoint %q", endpoint)
+	}
+	token, err := signES256(w.key, map[string]interface{}{"typ": "JWT", "alg": "ES256"}, map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(12 * time.Hour).Unix(),
+		"sub": w.config.Subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", token, w.PublicKey()), nil
+}
+
+// ValidateAddress checks a subscription endpoint is an https URL
+func (w *WebPushProvider) ValidateAddress(address string) error {
+	u, err := url.Parse(address)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("push endpoint must be an https URL")
+	}
+	return nil
+}
+
+// SendVerification isn't needed for push: a browser proves it can receive
+// pushes by subscribing
+func (w *WebPushProvider) SendVerification(ctx context.Context, address string, token string) error {
+	return errors.New("web push subscriptions don't need verification")
+}
+
+// GetDeliveryStatus isn't available: push services don't report delivery
+func (w *WebPushProvider) GetDeliveryStatus(ctx context.Context, messageID string) (*models.DeliveryAttempt, error) {
+	return nil, errors.New("web push services don't report delivery status")
+}
+
+// GetMetrics returns the provider's delivery counts since it started
+func (w *WebPushProvider) GetMetrics(ctx context.Context, start, end time.Time) (*models.ChannelMetrics, error) {
+	return w.stats.metrics(), nil
+}
+
+// IsAvailable reports whether Web Push can be used; each subscription
+// names its own push service, so there is nothing to check ahead of time
+func (w *WebPushProvider) IsAvailable(ctx context.Context) bool {
+	return true
+}
+
+// webPushRecordSize is the record size written in the aes128gcm header.
+// Payloads are always sent as a single record.
+const webPushRecordSize = 4096
+
+// encryptWebPush encrypts a payload for a browser subscription with the
+// aes128gcm content coding (RFC 8188) and the key derivation of RFC 8291,
+// given the subscription's p256dh and auth keys as base64url
+func encryptWebPush(plaintext []byte, p256dh, authSecret string) ([]byte, error) {
+	uaPublicBytes, err := decodeBase64URL(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	auth, err := decodeBase64URL(authSecret)
+	if err != nil || len(auth) != 16 {
+		return nil, errors.New("invalid auth secret")
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+
+	// A fresh key pair and salt for every message
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	shared, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, nonce := webPushKeys(shared, auth, uaPublicBytes, asPublic, salt)
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext)+1+gcm.Overhead() > webPushRecordSize {
+		return nil, errors.New("payload too large for web push")
+	}
+
+	// Header: salt, record size, key ID length and the key ID, which is
+	// the sender's public key
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	// 0x02 marks the last (and only) record
+	record := append(append([]byte{}, plaintext...), 0x02)
+	return gcm.Seal(header, nonce, record, nil), nil
+}
+
+// webPushKeys derives the content encryption key and nonce for a message
+// from the ECDH shared secret, the subscription's auth secret, both public
+// keys and the salt
+func webPushKeys(shared, auth, uaPublic, asPublic, salt []byte) (cek, nonce []byte) {
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm := hkdf(auth, shared, keyInfo, 32)
+
+	cek = hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce = hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+	return cek, nonce
+}
+
+// hkdf is HKDF-SHA256 (RFC 5869) for outputs of at most one hash length,
+// all Web Push needs
+func hkdf(salt, secret, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:length]
+}
+
+// decodeBase64URL decodes base64url with or without padding, as browsers
+// and client libraries differ
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// signES256 builds a JWT signed with an ECDSA P-256 key, with the
+// signature in the fixed-width r||s form JWS requires
+func signES256(key *ecdsa.PrivateKey, header, claims map[string]interface{}) (string, error) {
+	signingInput, err := jwtSigningInput(header, claims)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// jwtSigningInput encodes a JWT's header and claims
+func jwtSigningInput(header, claims map[string]interface{}) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c), nil
+}
+
+// GenerateVAPIDKey creates a new VAPID private key as unpadded base64url,
+// for WebPushConfig.VAPIDPrivateKey
+func GenerateVAPIDKey() (string, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
@@ -21,6 +21,8 @@ type UniversalMessageService struct {
 	messageRepo       MessageRepository
 	attemptRepo       DeliveryAttemptRepository
 	preferenceService PreferenceService
+	deliverability    DeliverabilityTracker
+	unsubscribe       *UnsubscribeTokens
 }
 
 // NewUniversalMessageService creates a new universal message service
@@ -55,6 +57,21 @@ func (s *UniversalMessageService) RegisterChannelProvider(provider ChannelProvid
 	return nil
 }
 
+// SetDeliverabilityTracker makes email skip suppressed addresses and
+// records each email attempt so bounces can be matched to it
+func (s *UniversalMessageService) SetDeliverabilityTracker(tracker DeliverabilityTracker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliverability = tracker
+}
+
+// SetUnsubscribeTokens gives every email a signed unsubscribe link
+func (s *UniversalMessageService) SetUnsubscribeTokens(tokens *UnsubscribeTokens) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unsubscribe = tokens
+}
+
 // SendMessage sends a message to recipients based on their preferences
 func (s *UniversalMessageService) SendMessage(ctx context.Context, msg *models.Message) error {
 	// Validate message
@@ -76,8 +93,10 @@ func (s *UniversalMessageService) SendMessage(ctx context.Context, msg *models.M
 	msg.UpdatedAt = now
 
 	// Store message
-	if err := s.messageRepo.CreateMessage(ctx, msg); err != nil {
-		return fmt.Errorf("failed to store message: %w", err)
+	if s.messageRepo != nil {
+		if err := s.messageRepo.CreateMessage(ctx, msg); err != nil {
+			return fmt.Errorf("failed to store message: %w", err)
+		}
 	}
 
 	// Process each recipient
@@ -103,7 +122,9 @@ func (s *UniversalMessageService) SendMessage(ctx context.Context, msg *models.M
 	}
 
 	msg.UpdatedAt = time.Now()
-	s.messageRepo.UpdateMessage(ctx, msg)
+	if s.messageRepo != nil {
+		s.messageRepo.UpdateMessage(ctx, msg)
+	}
 
 	// Return error if no recipients were processed successfully
 	if successCount == 0 {
@@ -215,8 +236,42 @@ func (s *UniversalMessageService) sendThroughChannel(ctx context.Context, msg *m
 		return fmt.Errorf("no provider for channel %s", channel)
 	}
 
+	address := recipient.Preferences.Channels[channel].Address
+	s.mu.RLock()
+	tracker := s.deliverability
+	unsubscribe := s.unsubscribe
+	s.mu.RUnlock()
+
+	if channel == models.ChannelEmail && unsubscribe != nil {
+		if recipient.Context == nil {
+			recipient.Context = make(map[string]interface{})
+		}
+		recipient.Context[models.RecipientContextUnsubscribeURL] = unsubscribe.URL(unsubscribeClaimsFor(msg, recipient.UserID))
+	}
+	if channel != models.ChannelEmail || address == "" {
+		tracker = nil
+	}
+
+	// Addresses that hard-bounced or complained aren't sent to again
+	if tracker != nil {
+		suppressed, err := tracker.IsSuppressed(ctx, address)
+		if err != nil {
+			log.Printf("Failed to check email suppression: %v", err)
+		} else if suppressed {
+			s.recordAttempt(ctx, &models.DeliveryAttempt{
+				ID:          uuid.New(),
+				MessageID:   msg.ID,
+				UserID:      recipient.UserID,
+				Channel:     channel,
+				Status:      models.DeliveryStatusSuppressed,
+				AttemptedAt: time.Now(),
+			})
+			return fmt.Errorf("email address for user %s is suppressed", recipient.UserID)
+		}
+	}
+
 	// Check rate limiting
-	if !s.rateLimiter.Allow(ctx, channel, recipient.Preferences.Channels[channel].Address) {
+	if !s.rateLimiter.Allow(ctx, channel, address) {
 		return fmt.Errorf("rate limited for channel %s", channel)
 	}
 
@@ -227,19 +282,33 @@ func (s *UniversalMessageService) sendThroughChannel(ctx context.Context, msg *m
 
 	// Deliver message
 	attempt, err := provider.DeliverMessage(ctx, msg, recipient)
-	if err != nil {
+	if err != nil && s.telemetry != nil {
 		s.telemetry.RecordError(channel, "delivery_error", err)
 	}
 
-	// Store delivery attempt
 	if attempt != nil {
-		s.attemptRepo.CreateDeliveryAttempt(ctx, attempt)
-		s.telemetry.RecordDeliveryAttempt(attempt)
+		s.recordAttempt(ctx, attempt)
+		if tracker != nil {
+			if trackErr := tracker.RecordAttempt(ctx, attempt, address); trackErr != nil {
+				log.Printf("Failed to track email delivery %s: %v", attempt.ID, trackErr)
+			}
+		}
 	}
 
 	return err
 }
 
+// recordAttempt stores a delivery attempt. The repository and telemetry are
+// optional, so a service can deliver without persisting attempts.
+func (s *UniversalMessageService) recordAttempt(ctx context.Context, attempt *models.DeliveryAttempt) {
+	if s.attemptRepo != nil {
+		s.attemptRepo.CreateDeliveryAttempt(ctx, attempt)
+	}
+	if s.telemetry != nil {
+		s.telemetry.RecordDeliveryAttempt(attempt)
+	}
+}
+
 // ScheduleMessage schedules a message for future delivery
 func (s *UniversalMessageService) ScheduleMessage(ctx context.Context, msg *models.Message, deliverAt time.Time) error {
 	// Validate message
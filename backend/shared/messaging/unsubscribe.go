@@ -0,0 +1,159 @@
+package messaging
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/models"
+)
+
+// UnsubscribeScope is what an unsubscribe link turns off
+type UnsubscribeScope string
+
+const (
+	// UnsubscribeEmail turns off all email to the user
+	UnsubscribeEmail UnsubscribeScope = "email"
+	// UnsubscribeEvent turns off email for one notification event
+	UnsubscribeEvent UnsubscribeScope = "event"
+)
+
+// ErrInvalidUnsubscribeToken is returned for a token that is malformed or
+// wasn't signed with our secret
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+
+// UnsubscribeClaims is what an unsubscribe token carries
+type UnsubscribeClaims struct {
+	UserID   uuid.UUID                `json:"user_id"`
+	Scope    UnsubscribeScope         `json:"scope"`
+	Event    models.NotificationEvent `json:"event,omitempty"`
+	IssuedAt time.Time                `json:"issued_at"`
+}
+
+// UnsubscribeTokens signs the unsubscribe tokens put in every email, so a
+// recipient can turn email off without logging in. Tokens don't expire:
+// the link in an old email should still work.
+type UnsubscribeTokens struct {
+	secret  []byte
+	baseURL string
+}
+
+// NewUnsubscribeTokens creates a token signer; baseURL is the public
+// unsubscribe endpoint the token is appended to
+func NewUnsubscribeTokens(secret, baseURL string) *UnsubscribeTokens {
+	return &UnsubscribeTokens{secret: []byte(secret), baseURL: baseURL}
+}
+
+// Generate signs claims into a token: the base64url payload
+// "v1:<user>:<scope>:<event>:<issued>", a dot, and its base64url HMAC-SHA256
+func (t *UnsubscribeTokens) Generate(claims UnsubscribeClaims) string {
+	if claims.IssuedAt.IsZero() {
+		claims.IssuedAt = time.Now()
+	}
+	payload := strings.Join([]string{"v1", claims.UserID.String(), string(claims.Scope), string(claims.Event),
+		strconv.FormatInt(claims.IssuedAt.Unix(), 10)}, ":")
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(t.sign(encoded))
+}
+
+// URL is the unsubscribe link for claims
+func (t *UnsubscribeTokens) URL(claims UnsubscribeClaims) string {
+	separator := "?"
+	if strings.Contains(t.baseURL, "?") {
+		separator = "&"
+	}
+	return t.baseURL + separator + "token=" + url.QueryEscape(t.Generate(claims))
+}
+
+// Verify checks a token's signature and returns its claims
+func (t *UnsubscribeTokens) Verify(token string) (*UnsubscribeClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, t.sign(encoded)) {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	parts := strings.Split(string(payload), ":")
+	if len(parts) != 5 || parts[0] != "v1" {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	userID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	issued, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	claims := &UnsubscribeClaims{
+		UserID:   userID,
+		Scope:    UnsubscribeScope(parts[2]),
+		Event:    models.NotificationEvent(parts[3]),
+		IssuedAt: time.Unix(issued, 0),
+	}
+	switch {
+	case claims.Scope == UnsubscribeEmail && claims.Event == "":
+	case claims.Scope == UnsubscribeEvent && claims.Event != "":
+	default:
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	return claims, nil
+}
+
+// Apply turns off what the claims cover in prefs, reporting whether
+// anything changed
+func (c UnsubscribeClaims) Apply(prefs *models.NotificationPreferences) bool {
+	if c.Scope == UnsubscribeEmail {
+		changed := prefs.EmailEnabled
+		prefs.EmailEnabled = false
+		return changed
+	}
+
+	pref, ok := prefs.EventPreferences[c.Event]
+	if !ok {
+		return false
+	}
+	channels := make([]models.DeliveryChannel, 0, len(pref.Channels))
+	for _, channel := range pref.Channels {
+		if channel != models.ChannelEmail {
+			channels = append(channels, channel)
+		}
+	}
+	if len(channels) == len(pref.Channels) {
+		return false
+	}
+	pref.Channels = channels
+	prefs.EventPreferences[c.Event] = pref
+	return true
+}
+
+func (t *UnsubscribeTokens) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte("unsubscribe:" + encoded))
+	return mac.Sum(nil)
+}
+
+// unsubscribeClaimsFor picks what a message's unsubscribe link turns off:
+// email for its notification event when it has one (see
+// models.MessageMetaNotificationEvent), otherwise all email
+func unsubscribeClaimsFor(msg *models.Message, userID uuid.UUID) UnsubscribeClaims {
+	claims := UnsubscribeClaims{UserID: userID, Scope: UnsubscribeEmail}
+	if event, ok := msg.Metadata[models.MessageMetaNotificationEvent].(string); ok && event != "" {
+		claims.Scope = UnsubscribeEvent
+		claims.Event = models.NotificationEvent(event)
+	}
+	return claims
+}
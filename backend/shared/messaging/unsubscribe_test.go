@@ -0,0 +1,75 @@
+package messaging
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/models"
+)
+
+func TestUnsubscribeTokenRoundTrip(t *testing.T) {
+	tokens := NewUnsubscribeTokens("secret", "https://example.org/unsubscribe")
+	userID := uuid.New()
+
+	token := tokens.Generate(UnsubscribeClaims{UserID: userID, Scope: UnsubscribeEvent, Event: models.EventKudosReceived})
+	claims, err := tokens.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != userID || claims.Scope != UnsubscribeEvent || claims.Event != models.EventKudosReceived {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	if _, err := NewUnsubscribeTokens("other", "").Verify(token); err != ErrInvalidUnsubscribeToken {
+		t.Error("A token signed with another secret should be rejected")
+	}
+	encoded, signature, _ := strings.Cut(token, ".")
+	if _, err := tokens.Verify(encoded[:len(encoded)-2] + "xx." + signature); err != ErrInvalidUnsubscribeToken {
+		t.Error("A tampered token should be rejected")
+	}
+	if _, err := tokens.Verify(tokens.Generate(UnsubscribeClaims{UserID: userID, Scope: UnsubscribeEvent})); err != ErrInvalidUnsubscribeToken {
+		t.Error("An event token needs an event")
+	}
+
+	if url := tokens.URL(UnsubscribeClaims{UserID: userID, Scope: UnsubscribeEmail}); !strings.HasPrefix(url, "https://example.org/unsubscribe?token=") {
+		t.Errorf("Unexpected URL %s", url)
+	}
+}
+
+func TestUnsubscribeClaimsApply(t *testing.T) {
+	userID := uuid.New()
+	prefs := models.DefaultNotificationPreferences(userID)
+
+	event := UnsubscribeClaims{UserID: userID, Scope: UnsubscribeEvent, Event: models.EventWorkUpdated}
+	if !event.Apply(&prefs) {
+		t.Fatal("Expected the event's email to be turned off")
+	}
+	for _, channel := range prefs.EventPreferences[models.EventWorkUpdated].Channels {
+		if channel == models.ChannelEmail {
+			t.Error("Email should be removed from the event's channels")
+		}
+	}
+	if !prefs.EmailEnabled {
+		t.Error("An event unsubscribe should leave other email on")
+	}
+	if event.Apply(&prefs) {
+		t.Error("Unsubscribing twice should change nothing")
+	}
+
+	all := UnsubscribeClaims{UserID: userID, Scope: UnsubscribeEmail}
+	if !all.Apply(&prefs) || prefs.EmailEnabled {
+		t.Error("Expected all email to be turned off")
+	}
+}
+
+func TestUnsubscribeClaimsForMessage(t *testing.T) {
+	userID := uuid.New()
+	msg := &models.Message{Metadata: map[string]interface{}{models.MessageMetaNotificationEvent: "comment_received"}}
+	if claims := unsubscribeClaimsFor(msg, userID); claims.Scope != UnsubscribeEvent || claims.Event != models.EventCommentReceived {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+	if claims := unsubscribeClaimsFor(&models.Message{}, userID); claims.Scope != UnsubscribeEmail {
+		t.Errorf("A message without an event should unsubscribe from all email, got %+v", claims)
+	}
+}
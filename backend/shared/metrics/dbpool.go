@@ -0,0 +1,159 @@
+// Package metrics holds Prometheus instrumentation shared by the services
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolAlertConfig sets when a connection pool is reported as saturated
+type PoolAlertConfig struct {
+	// Interval is how often the pool is sampled
+	Interval time.Duration
+	// MaxAvgWait is the longest acceptable average wait for a connection
+	// over one interval
+	MaxAvgWait time.Duration
+	// MaxUtilization is the in-use share of MaxOpenConnections (0-1) above
+	// which the pool counts as nearly exhausted
+	MaxUtilization float64
+}
+
+// DefaultPoolAlertConfig samples every 15s and alerts on waits averaging
+// over 100ms or a pool more than 90% in use
+func DefaultPoolAlertConfig() PoolAlertConfig {
+	return PoolAlertConfig{
+		Interval:       15 * time.Second,
+		MaxAvgWait:     100 * time.Millisecond,
+		MaxUtilization: 0.9,
+	}
+}
+
+var poolAlerts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "nuclear_db_pool_alerts_total",
+	Help: "Times a service's database pool crossed a saturation threshold",
+}, []string{"service", "reason"})
+
+// RegisterDBPool exposes db's pool statistics as gauges and counters
+// labelled with the service name, and registers the alert counter
+func RegisterDBPool(service string, db *sql.DB) error {
+	labels := prometheus.Labels{"service": service}
+	gauge := func(name, help string, value func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: name, Help: help, ConstLabels: labels,
+		}, func() float64 { return value(db.Stats()) })
+	}
+	counter := func(name, help string, value func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: name, Help: help, ConstLabels: labels,
+		}, func() float64 { return value(db.Stats()) })
+	}
+
+	collectors := []prometheus.Collector{
+		gauge("nuclear_db_pool_max_open_connections", "Configured maximum open connections",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+		gauge("nuclear_db_pool_open_connections", "Open connections, in use and idle",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		gauge("nuclear_db_pool_in_use_connections", "Connections currently in use",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		gauge("nuclear_db_pool_idle_connections", "Idle connections",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		counter("nuclear_db_pool_wait_count_total", "Connections that had to be waited for",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		counter("nuclear_db_pool_wait_duration_seconds_total", "Total time spent waiting for connections",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+		poolAlerts,
+	}
+
+	for _, c := range collectors {
+		if err := prometheus.Register(c); err != nil {
+			var already prometheus.AlreadyRegisteredError
+			if errors.As(err, &already) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// poolAlertsFor compares two samples taken one interval apart and returns
+// the thresholds crossed in between, keyed by reason
+func poolAlertsFor(prev, cur sql.DBStats, config PoolAlertConfig) map[string]string {
+	alerts := make(map[string]string)
+
+	waits := cur.WaitCount - prev.WaitCount
+	if waits > 0 && config.MaxAvgWait > 0 {
+		avg := (cur.WaitDuration - prev.WaitDuration) / time.Duration(waits)
+		if avg > config.MaxAvgWait {
+			alerts["wait_time"] = fmt.Sprintf("%d waits for a connection averaging %s (threshold %s)",
+				waits, avg.Round(time.Millisecond), config.MaxAvgWait)
+		}
+	}
+
+	if cur.MaxOpenConnections > 0 && config.MaxUtilization > 0 {
+		utilization := float64(cur.InUse) / float64(cur.MaxOpenConnections)
+		if utilization >= config.MaxUtilization {
+			alerts["utilization"] = fmt.Sprintf("%d of %d connections in use",
+				cur.InUse, cur.MaxOpenConnections)
+		}
+	}
+	return alerts
+}
+
+// MonitorDBPool samples db every interval until ctx is cancelled, logging
+// and counting each threshold crossed
+func MonitorDBPool(ctx context.Context, service string, db *sql.DB, config PoolAlertConfig) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultPoolAlertConfig().Interval
+	}
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	prev := db.Stats()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cur := db.Stats()
+			for reason, message := range poolAlertsFor(prev, cur, config) {
+				poolAlerts.WithLabelValues(service, reason).Inc()
+				log.Printf("⚠️ DB pool saturation (%s): %s", service, message)
+			}
+			prev = cur
+		}
+	}
+}
+
+// PoolAlertConfigFromEnv applies DB_POOL_ALERT_INTERVAL, DB_POOL_MAX_AVG_WAIT
+// (durations) and DB_POOL_MAX_UTILIZATION (0-1) over the defaults
+func PoolAlertConfigFromEnv() PoolAlertConfig {
+	config := DefaultPoolAlertConfig()
+	if d, err := time.ParseDuration(os.Getenv("DB_POOL_ALERT_INTERVAL")); err == nil && d > 0 {
+		config.Interval = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("DB_POOL_MAX_AVG_WAIT")); err == nil && d > 0 {
+		config.MaxAvgWait = d
+	}
+	if u, err := strconv.ParseFloat(os.Getenv("DB_POOL_MAX_UTILIZATION"), 64); err == nil && u > 0 && u <= 1 {
+		config.MaxUtilization = u
+	}
+	return config
+}
+
+// InstrumentDBPool registers db's pool metrics and alerts on saturation for
+// the life of the process
+func InstrumentDBPool(service string, db *sql.DB) {
+	if err := RegisterDBPool(service, db); err != nil {
+		log.Printf("Failed to register DB pool metrics for %s: %v", service, err)
+	}
+	go MonitorDBPool(context.Background(), service, db, PoolAlertConfigFromEnv())
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestPoolAlertsForWaitTime(t *testing.T) {
+	config := PoolAlertConfig{MaxAvgWait: 100 * time.Millisecond}
+	prev := sql.DBStats{WaitCount: 10, WaitDuration: time.Second}
+
+	fast := sql.DBStats{WaitCount: 20, WaitDuration: 2 * time.Second}
+	if alerts := poolAlertsFor(prev, fast, config); len(alerts) != 0 {
+		t.Errorf("Expected no alert for 100ms average waits, got %v", alerts)
+	}
+
+	slow := sql.DBStats{WaitCount: 12, WaitDuration: 2 * time.Second}
+	if _, ok := poolAlertsFor(prev, slow, config)["wait_time"]; !ok {
+		t.Error("Expected a wait_time alert for 500ms average waits")
+	}
+}
+
+func TestPoolAlertsForUtilization(t *testing.T) {
+	config := PoolAlertConfig{MaxUtilization: 0.9}
+
+	if alerts := poolAlertsFor(sql.DBStats{}, sql.DBStats{MaxOpenConnections: 10, InUse: 8}, config); len(alerts) != 0 {
+		t.Errorf("Expected no alert at 80%% use, got %v", alerts)
+	}
+	if _, ok := poolAlertsFor(sql.DBStats{}, sql.DBStats{MaxOpenConnections: 10, InUse: 9}, config)["utilization"]; !ok {
+		t.Error("Expected a utilization alert at 90% use")
+	}
+	// An unlimited pool can't be saturated
+	if alerts := poolAlertsFor(sql.DBStats{}, sql.DBStats{InUse: 50}, config); len(alerts) != 0 {
+		t.Errorf("Expected no alert for an unlimited pool, got %v", alerts)
+	}
+}
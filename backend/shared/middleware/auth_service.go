@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Identity is who a request is made by, as auth-service reports it
+type Identity struct {
+	UserID string   `json:"user_id"`
+	Roles  []string `json:"roles"`
+	Scopes []string `json:"scopes"`
+}
+
+var authServiceClient = &http.Client{Timeout: 5 * time.Second}
+
+// errTokenRejected is returned when auth-service turns a token away
+var errTokenRejected = errors.New("token rejected by auth service")
+
+// FetchIdentity asks auth-service who a bearer token belongs to. It's how
+// services other than auth-service validate tokens, so revoked sessions
+// and role changes take effect everywhere at once.
+func FetchIdentity(ctx context.Context, authServiceURL, token string) (*Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, authServiceURL+"/api/v1/auth/me", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := authServiceClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach auth service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, errTokenRejected
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth service returned status %d", resp.StatusCode)
+	}
+
+	var identity Identity
+	if err := json.NewDecoder(resp.Body).Decode(&identity); err != nil {
+		return nil, fmt.Errorf("failed to parse auth service response: %w", err)
+	}
+	if identity.UserID == "" {
+		return nil, errTokenRejected
+	}
+	return &identity, nil
+}
+
+// SetIdentity records who is making the request for handlers and the role
+// and scope middleware
+func SetIdentity(c *gin.Context, identity *Identity) {
+	if identity.UserID != "" {
+		c.Set("user_id", identity.UserID)
+	}
+	c.Set("roles", identity.Roles)
+	c.Set("scopes", identity.Scopes)
+}
+
+// AuthServiceMiddleware requires a bearer token and validates it with
+// auth-service, setting user_id, roles and scopes. When service is given,
+// internal callers presenting INTERNAL_SERVICE_TOKEN are let in as that
+// identity without a user token.
+func AuthServiceMiddleware(authServiceURL string, service *Identity) gin.HandlerFunc {
+	serviceToken := os.Getenv(ServiceTokenEnv)
+	return func(c *gin.Context) {
+		if service != nil && serviceToken != "" {
+			if presented := c.GetHeader(ServiceTokenHeader); presented != "" {
+				if subtle.ConstantTimeCompare([]byte(presented), []byte(serviceToken)) != 1 {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid service token"})
+					c.Abort()
+					return
+				}
+				SetIdentity(c, service)
+				c.Next()
+				return
+			}
+		}
+
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			c.Abort()
+			return
+		}
+
+		identity, err := FetchIdentity(c.Request.Context(), authServiceURL, strings.TrimPrefix(authHeader, "Bearer "))
+		if errors.Is(err, errTokenRejected) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Authentication unavailable"})
+			c.Abort()
+			return
+		}
+
+		SetIdentity(c, identity)
+		c.Next()
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestETag(t *testing.T) {
+	id := uuid.New()
+	updated := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	etag := ETag(id, updated)
+	if etag != ETag(id, updated) {
+		t.Error("the same content should have the same ETag")
+	}
+	if etag == ETag(id, updated.Add(time.Second)) {
+		t.Error("an update should change the ETag")
+	}
+	if etag[0] != '"' || etag[len(etag)-1] != '"' {
+		t.Errorf("ETag %s should be quoted", etag)
+	}
+	if ETag(func() {}) != "" {
+		t.Error("a part that can't be encoded should give no ETag")
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	etag := ETag("work")
+	for header, want := range map[string]bool{
+		etag:                    true,
+		`"other", W/` + etag:    true,
+		"*":                     true,
+		"":                      false,
+		`"other"`:               false,
+		`"other", "another"`:    false,
+		etag[:len(etag)-1]:      false,
+		" " + etag + " , \"x\"": true,
+	} {
+		if got := ETagMatches(header, etag); got != want {
+			t.Errorf("ETagMatches(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	etag := ETag("chapter", 1)
+	r := gin.New()
+	r.GET("/chapter", func(c *gin.Context) {
+		if NotModified(c, etag) {
+			return
+		}
+		c.String(http.StatusOK, "long chapter text")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/chapter", nil))
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != etag {
+		t.Fatalf("first read: status %d, ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/chapter", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("revalidation: status %d, body %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") != etag {
+		t.Error("a 304 should repeat the ETag")
+	}
+}
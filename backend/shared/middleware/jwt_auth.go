@@ -207,8 +207,10 @@ func JWTAuthMiddleware(authService interface{}) gin.HandlerFunc {
 	}
 }
 
-// RequireRoleMiddleware checks if user has required role
-func RequireRoleMiddleware(requiredRole string) gin.HandlerFunc {
+// RequireRoleMiddleware lets through users holding any of the given roles,
+// or admin, which can do everything. Roles come from the "roles" context
+// value set by the authentication middleware.
+func RequireRoleMiddleware(requiredRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		roles, exists := c.Get("roles")
 		if !exists {
@@ -224,17 +226,8 @@ func RequireRoleMiddleware(requiredRole string) gin.HandlerFunc {
 			return
 		}
 
-		// Check if user has required role
-		hasRole := false
-		for _, role := range userRoles {
-			if role == requiredRole || role == "admin" { // Admins have access to everything
-				hasRole = true
-				break
-			}
-		}
-
-		if !hasRole {
-			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Role '%s' required", requiredRole)})
+		if !HasAnyRole(userRoles, requiredRoles...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Role '%s' required", strings.Join(requiredRoles, "' or '"))})
 			c.Abort()
 			return
 		}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// ReadOnlyEnv forces read-only mode from configuration, for deploys where
+// the switch must be on before any service starts
+const ReadOnlyEnv = "READ_ONLY_MODE"
+
+// ReadOnlyKey is the Redis key, in DB 0, that switches read-only mode on at
+// runtime. Its value is the message shown to users.
+const ReadOnlyKey = "site:read_only"
+
+// ReadOnlyHeader is set on every response while the archive is read-only, so
+// clients can show a banner on pages that only read
+const ReadOnlyHeader = "X-Read-Only"
+
+// DefaultReadOnlyMessage is shown when read-only mode was switched on
+// without a message of its own
+const DefaultReadOnlyMessage = "The archive is in read-only maintenance. Reading works as usual; posting and editing will be back shortly."
+
+const (
+	// readOnlyRefresh is how often the Redis flag is re-read
+	readOnlyRefresh = 5 * time.Second
+	// readOnlyRetryAfter is the Retry-After, in seconds, on rejected writes
+	readOnlyRetryAfter = "120"
+	// internalPathPrefix is never blocked, so operators can still switch
+	// read-only mode off and run maintenance tasks
+	internalPathPrefix = "/api/v1/internal/"
+)
+
+// ReadOnlyMode reports whether the archive is in read-only maintenance. The
+// Redis flag is cached for a few seconds; if Redis can't be reached the last
+// known state holds, so an outage doesn't flip the archive either way.
+type ReadOnlyMode struct {
+	redis  *redis.Client
+	forced bool
+
+	mu        sync.Mutex
+	enabled   bool
+	message   string
+	checkedAt time.Time
+}
+
+// NewReadOnlyMode reads the flag from rdb, which must be connected to DB 0
+func NewReadOnlyMode(rdb *redis.Client) *ReadOnlyMode {
+	forced, _ := strconv.ParseBool(os.Getenv(ReadOnlyEnv))
+	return &ReadOnlyMode{redis: rdb, forced: forced}
+}
+
+// NewReadOnlyModeFromEnv connects to DB 0 of REDIS_URL. Services keep their
+// own data in other databases, but the flag is sitewide so every service
+// reads it from the same place.
+func NewReadOnlyModeFromEnv() *ReadOnlyMode {
+	addr := os.Getenv("REDIS_URL")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	return NewReadOnlyMode(redis.NewClient(&redis.Options{
+		Addr:         addr,
+		Password:     os.Getenv("REDIS_PASSWORD"),
+		DB:           0,
+		DialTimeout:  time.Second,
+		ReadTimeout:  500 * time.Millisecond,
+		WriteTimeout: 500 * time.Millisecond,
+	}))
+}
+
+// State reports whether the archive is read-only and the message to show
+func (m *ReadOnlyMode) State(ctx context.Context) (bool, string) {
+	if m.forced {
+		return true, DefaultReadOnlyMessage
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.redis == nil || time.Since(m.checkedAt) < readOnlyRefresh {
+		return m.enabled, m.message
+	}
+	m.checkedAt = time.Now()
+
+	message, err := m.redis.Get(ctx, ReadOnlyKey).Result()
+	switch {
+	case err == redis.Nil:
+		m.enabled, m.message = false, ""
+	case err != nil:
+		log.Printf("Failed to read read-only flag, keeping last state: %v", err)
+	default:
+		if message == "" {
+			message = DefaultReadOnlyMessage
+		}
+		m.enabled, m.message = true, message
+	}
+	return m.enabled, m.message
+}
+
+// Set switches read-only mode on with message, or off, and applies the
+// change to this process at once rather than at the next refresh
+func (m *ReadOnlyMode) Set(ctx context.Context, enabled bool, message string) error {
+	var err error
+	if enabled {
+		err = m.redis.Set(ctx, ReadOnlyKey, message, 0).Err()
+	} else {
+		err = m.redis.Del(ctx, ReadOnlyKey).Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.checkedAt = time.Time{}
+	m.mu.Unlock()
+	return nil
+}
+
+// isMutatingMethod reports whether a request method changes state
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// Middleware rejects requests that change state with 503 while the archive
+// is read-only. Reads carry on as usual. Internal routes are always let
+// through, as are paths under the exempt prefixes, for POST endpoints that
+// only read such as advanced search.
+func (m *ReadOnlyMode) Middleware(exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enabled, message := m.State(c.Request.Context())
+		if !enabled {
+			c.Next()
+			return
+		}
+		c.Header(ReadOnlyHeader, "true")
+
+		path := c.Request.URL.Path
+		if !isMutatingMethod(c.Request.Method) || strings.HasPrefix(path, internalPathPrefix) {
+			c.Next()
+			return
+		}
+		for _, prefix := range exempt {
+			if strings.HasPrefix(path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		c.Header("Retry-After", readOnlyRetryAfter)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error":     "read-only maintenance",
+			"message":   message,
+			"read_only": true,
+		})
+	}
+}
+
+// GetReadOnlyStatus reports the current state:
+// GET /api/v1/internal/read-only
+func (m *ReadOnlyMode) GetReadOnlyStatus(c *gin.Context) {
+	enabled, message := m.State(c.Request.Context())
+	c.JSON(http.StatusOK, gin.H{"read_only": enabled, "message": message, "forced": m.forced})
+}
+
+// SetReadOnlyStatus switches read-only mode on or off. It can't switch off
+// a mode forced by READ_ONLY_MODE, which needs a redeploy.
+// POST /api/v1/internal/read-only
+func (m *ReadOnlyMode) SetReadOnlyStatus(c *gin.Context) {
+	var req struct {
+		Enabled *bool  `json:"enabled" binding:"required"`
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "enabled is required"})
+		return
+	}
+	if !*req.Enabled && m.forced {
+		c.JSON(http.StatusConflict, gin.H{"error": "Read-only mode is forced by " + ReadOnlyEnv + "; redeploy without it to switch it off"})
+		return
+	}
+
+	message := strings.TrimSpace(req.Message)
+	if *req.Enabled && message == "" {
+		message = DefaultReadOnlyMessage
+	}
+	if IsDryRun(c) {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "read_only": *req.Enabled, "message": message})
+		return
+	}
+
+	if err := m.Set(c.Request.Context(), *req.Enabled, message); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update read-only mode"})
+		return
+	}
+	if !*req.Enabled {
+		message = ""
+	}
+	c.JSON(http.StatusOK, gin.H{"read_only": *req.Enabled, "message": message})
+}
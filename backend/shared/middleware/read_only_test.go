@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func readOnlyRouter(mode *ReadOnlyMode, exempt ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(mode.Middleware(exempt...))
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) }
+	r.GET("/api/v1/works", ok)
+	r.POST("/api/v1/works", ok)
+	r.DELETE("/api/v1/works/w1", ok)
+	r.POST("/api/v1/search/works/advanced", ok)
+	r.POST("/api/v1/internal/read-only", ok)
+	return r
+}
+
+// readOnlyState is a mode with no Redis whose cached state stays fresh
+func readOnlyState(enabled bool, message string) *ReadOnlyMode {
+	return &ReadOnlyMode{enabled: enabled, message: message, checkedAt: time.Now()}
+}
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	cases := []struct {
+		name    string
+		enabled bool
+		method  string
+		path    string
+		want    int
+	}{
+		{"writes pass when off", false, http.MethodPost, "/api/v1/works", http.StatusOK},
+		{"reads pass when on", true, http.MethodGet, "/api/v1/works", http.StatusOK},
+		{"posts rejected when on", true, http.MethodPost, "/api/v1/works", http.StatusServiceUnavailable},
+		{"deletes rejected when on", true, http.MethodDelete, "/api/v1/works/w1", http.StatusServiceUnavailable},
+		{"exempt prefix passes", true, http.MethodPost, "/api/v1/search/works/advanced", http.StatusOK},
+		{"internal routes pass", true, http.MethodPost, "/api/v1/internal/read-only", http.StatusOK},
+	}
+	for _, tc := range cases {
+		router := readOnlyRouter(readOnlyState(tc.enabled, "Back soon"), "/api/v1/search/")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.want)
+		}
+		if got := w.Header().Get(ReadOnlyHeader) == "true"; got != tc.enabled {
+			t.Errorf("%s: %s header present = %v, want %v", tc.name, ReadOnlyHeader, got, tc.enabled)
+		}
+	}
+}
+
+func TestReadOnlyRejection(t *testing.T) {
+	router := readOnlyRouter(readOnlyState(true, "Back soon"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/works", nil))
+
+	want := `{"error":"read-only maintenance","message":"Back soon","read_only":true}`
+	if w.Body.String() != want {
+		t.Errorf("body %s, want %s", w.Body.String(), want)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+}
+
+func TestReadOnlyForcedByEnv(t *testing.T) {
+	t.Setenv(ReadOnlyEnv, "true")
+	mode := NewReadOnlyMode(nil)
+
+	enabled, message := mode.State(context.Background())
+	if !enabled || message != DefaultReadOnlyMessage {
+		t.Errorf("got %v %q, want forced on with the default message", enabled, message)
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRole can do everything any other role can
+const AdminRole = "admin"
+
+// Scopes guarding staff routes. First-party sessions hold the scopes of the
+// user's roles; tokens issued to OAuth clients hold only what the user
+// consented to, so a client can't act as staff without being granted it.
+const (
+	ScopeAdmin       = "admin"
+	ScopeTagsWrangle = "tags:wrangle"
+	ScopeSearchIndex = "search:index"
+)
+
+// RoleScopes are the scopes each role brings to a first-party session
+var RoleScopes = map[string][]string{
+	"tag_wrangler": {ScopeTagsWrangle},
+	"admin":        {ScopeAdmin},
+}
+
+// ScopesForRoles is the scope list of a first-party session for a user
+// holding roles
+func ScopesForRoles(roles []string) []string {
+	scopes := []string{"user"}
+	for _, role := range roles {
+		scopes = append(scopes, RoleScopes[role]...)
+	}
+	return scopes
+}
+
+// HasAnyRole reports whether userRoles include admin or any of roles
+func HasAnyRole(userRoles []string, roles ...string) bool {
+	for _, held := range userRoles {
+		if held == AdminRole || contains(roles, held) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyScope reports whether tokenScopes include admin or any of scopes
+func HasAnyScope(tokenScopes []string, scopes ...string) bool {
+	for _, held := range tokenScopes {
+		if held == ScopeAdmin || contains(scopes, held) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScopeMiddleware lets through tokens carrying any of the given
+// scopes, or admin. Scopes come from the "scopes" context value set by the
+// authentication middleware.
+func RequireScopeMiddleware(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasAnyScope(c.GetStringSlice("scopes"), scopes...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Scope '%s' required", strings.Join(scopes, "' or '"))})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHasAnyRole(t *testing.T) {
+	cases := []struct {
+		held []string
+		want []string
+		ok   bool
+	}{
+		{[]string{"user", "tag_wrangler"}, []string{"tag_wrangler"}, true},
+		{[]string{"user"}, []string{"tag_wrangler"}, false},
+		{[]string{"user", "admin"}, []string{"tag_wrangler"}, true},
+		{nil, []string{"admin"}, false},
+	}
+	for _, tc := range cases {
+		if got := HasAnyRole(tc.held, tc.want...); got != tc.ok {
+			t.Errorf("HasAnyRole(%v, %v) = %v, want %v", tc.held, tc.want, got, tc.ok)
+		}
+	}
+}
+
+func TestScopesForRoles(t *testing.T) {
+	scopes := ScopesForRoles([]string{"user", "tag_wrangler"})
+	if !HasAnyScope(scopes, ScopeTagsWrangle) {
+		t.Errorf("wrangler session scopes %v lack %s", scopes, ScopeTagsWrangle)
+	}
+	if HasAnyScope(scopes, ScopeSearchIndex) {
+		t.Errorf("wrangler session scopes %v shouldn't include %s", scopes, ScopeSearchIndex)
+	}
+	if !HasAnyScope(ScopesForRoles([]string{"admin"}), ScopeSearchIndex) {
+		t.Error("admin scope should satisfy any scope")
+	}
+}
+
+// fakeAuthService answers /me for the "wrangler" and "oauth-app" tokens
+func fakeAuthService() *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/v1/auth/me", func(c *gin.Context) {
+		switch c.GetHeader("Authorization") {
+		case "Bearer wrangler":
+			c.JSON(http.StatusOK, gin.H{"user_id": "u1", "roles": []string{"user", "tag_wrangler"}, "scopes": []string{"user", ScopeTagsWrangle}})
+		case "Bearer oauth-app":
+			c.JSON(http.StatusOK, gin.H{"user_id": "u1", "roles": []string{"user", "tag_wrangler"}, "scopes": []string{"read"}})
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		}
+	})
+	return httptest.NewServer(r)
+}
+
+func TestAuthServiceMiddlewareEnforcesRolesAndScopes(t *testing.T) {
+	t.Setenv(ServiceTokenEnv, "s3cret")
+	auth := fakeAuthService()
+	defer auth.Close()
+
+	r := gin.New()
+	wrangling := r.Group("/wrangling")
+	wrangling.Use(AuthServiceMiddleware(auth.URL, nil), RequireRoleMiddleware("tag_wrangler"), RequireScopeMiddleware(ScopeTagsWrangle))
+	wrangling.GET("/queue", func(c *gin.Context) { c.Status(http.StatusOK) })
+	admin := r.Group("/admin")
+	admin.Use(AuthServiceMiddleware(auth.URL, nil), RequireRoleMiddleware("admin"))
+	admin.GET("/tags", func(c *gin.Context) { c.Status(http.StatusOK) })
+	index := r.Group("/index")
+	index.Use(AuthServiceMiddleware(auth.URL, &Identity{Roles: []string{"indexer"}, Scopes: []string{ScopeSearchIndex}}),
+		RequireRoleMiddleware("indexer"), RequireScopeMiddleware(ScopeSearchIndex))
+	index.POST("/works", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	cases := []struct {
+		name, method, path, bearer, serviceToken string
+		want                                     int
+	}{
+		{"wrangler session", "GET", "/wrangling/queue", "wrangler", "", http.StatusOK},
+		{"oauth client without scope", "GET", "/wrangling/queue", "oauth-app", "", http.StatusForbidden},
+		{"missing role", "GET", "/admin/tags", "wrangler", "", http.StatusForbidden},
+		{"revoked token", "GET", "/wrangling/queue", "expired", "", http.StatusUnauthorized},
+		{"no token", "GET", "/wrangling/queue", "", "", http.StatusUnauthorized},
+		{"service token", "POST", "/index/works", "", "s3cret", http.StatusOK},
+		{"wrong service token", "POST", "/index/works", "", "guess", http.StatusUnauthorized},
+		{"service token elsewhere", "GET", "/admin/tags", "", "s3cret", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.bearer)
+		}
+		if tc.serviceToken != "" {
+			req.Header.Set(ServiceTokenHeader, tc.serviceToken)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Route rate limits give a route group its own request budget on top of the
+// service-wide limit: searching, posting and commenting each have one. A
+// signed-in user has a bucket of their own, with a bigger budget than
+// anonymous visitors, who share one per IP. Responses carry the standard
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+// headers, and Retry-After when the budget is spent.
+
+// Route rate limit policy names
+const (
+	RateLimitSearch  = "search"
+	RateLimitPost    = "post"
+	RateLimitComment = "comment"
+)
+
+// RateLimitPolicy is the request budget of one route group
+type RateLimitPolicy struct {
+	Name          string        `json:"name"`
+	Anonymous     int           `json:"anonymous"`     // requests per window for each IP
+	Authenticated int           `json:"authenticated"` // requests per window for each user
+	Window        time.Duration `json:"window"`
+}
+
+// DefaultRateLimitPolicies are the budgets used unless configured otherwise
+func DefaultRateLimitPolicies() map[string]RateLimitPolicy {
+	return map[string]RateLimitPolicy{
+		RateLimitSearch:  {Name: RateLimitSearch, Anonymous: 30, Authenticated: 120, Window: time.Minute},
+		RateLimitPost:    {Name: RateLimitPost, Anonymous: 5, Authenticated: 60, Window: time.Hour},
+		RateLimitComment: {Name: RateLimitComment, Anonymous: 5, Authenticated: 30, Window: 10 * time.Minute},
+	}
+}
+
+// ParseRateLimitPolicy reads a policy written as
+// "anonymous/authenticated/window", for example "30/120/1m"
+func ParseRateLimitPolicy(name, spec string) (RateLimitPolicy, error) {
+	policy := RateLimitPolicy{Name: name}
+	parts := strings.Split(spec, "/")
+	if len(parts) != 3 {
+		return policy, fmt.Errorf("rate limit %q must be anonymous/authenticated/window", spec)
+	}
+	var err error
+	if policy.Anonymous, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil || policy.Anonymous < 1 {
+		return policy, fmt.Errorf("rate limit %q: anonymous budget must be a positive number", spec)
+	}
+	if policy.Authenticated, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil || policy.Authenticated < 1 {
+		return policy, fmt.Errorf("rate limit %q: authenticated budget must be a positive number", spec)
+	}
+	if policy.Window, err = time.ParseDuration(strings.TrimSpace(parts[2])); err != nil || policy.Window < time.Second {
+		return policy, fmt.Errorf("rate limit %q: window must be a duration of at least 1s", spec)
+	}
+	return policy, nil
+}
+
+// RateLimitPoliciesFromEnv returns the default policies, each overridden by
+// RATE_LIMIT_<NAME> when set, for example RATE_LIMIT_SEARCH=30/120/1m. An
+// invalid setting is logged and the default kept.
+func RateLimitPoliciesFromEnv() map[string]RateLimitPolicy {
+	policies := DefaultRateLimitPolicies()
+	for name := range policies {
+		env := "RATE_LIMIT_" + strings.ToUpper(name)
+		spec := os.Getenv(env)
+		if spec == "" {
+			continue
+		}
+		policy, err := ParseRateLimitPolicy(name, spec)
+		if err != nil {
+			log.Printf("Ignoring %s: %v", env, err)
+			continue
+		}
+		policies[name] = policy
+	}
+	return policies
+}
+
+// RouteRateLimiter enforces route rate limit policies, counting requests in
+// fixed windows in Redis. If Redis can't be reached requests are let
+// through, as with the service-wide limit.
+type RouteRateLimiter struct {
+	service  string
+	policies map[string]RateLimitPolicy
+	// Identify returns the signed-in user of a request, if any. It defaults
+	// to the user_id set by the service's auth middleware.
+	Identify func(c *gin.Context) (string, bool)
+	// count adds a request to a bucket and returns its count and how long
+	// until its window ends
+	count func(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+}
+
+// NewRouteRateLimiter counts requests for service in rdb. Without a Redis
+// client nothing is limited.
+func NewRouteRateLimiter(rdb *redis.Client, service string, policies map[string]RateLimitPolicy) *RouteRateLimiter {
+	l := &RouteRateLimiter{
+		service:  service,
+		policies: policies,
+		Identify: contextUserID,
+	}
+	if rdb != nil {
+		l.count = func(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+			pipe := rdb.Pipeline()
+			incr := pipe.Incr(ctx, key)
+			ttl := pipe.PTTL(ctx, key)
+			if _, err := pipe.Exec(ctx); err != nil {
+				return 0, 0, err
+			}
+			// A new bucket, or one whose expiry was lost, starts its window now
+			remaining := ttl.Val()
+			if remaining < 0 {
+				if err := rdb.PExpire(ctx, key, window).Err(); err != nil {
+					return 0, 0, err
+				}
+				remaining = window
+			}
+			return incr.Val(), remaining, nil
+		}
+	}
+	return l
+}
+
+// contextUserID reads the user_id auth middleware set, as a string or UUID
+func contextUserID(c *gin.Context) (string, bool) {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	switch id := value.(type) {
+	case string:
+		return id, id != ""
+	case uuid.UUID:
+		return id.String(), id != uuid.Nil
+	}
+	return "", false
+}
+
+// Limit returns middleware enforcing the named policy. Mount it after the
+// route group's auth middleware so signed-in users get their own budget.
+func (l *RouteRateLimiter) Limit(name string) gin.HandlerFunc {
+	policy, ok := l.policies[name]
+	if !ok {
+		panic("unknown rate limit policy " + name)
+	}
+
+	return func(c *gin.Context) {
+		if l.count == nil {
+			c.Next()
+			return
+		}
+		budget := policy.Anonymous
+		bucket := "ip:" + GetClientIP(c.Request)
+		if userID, ok := l.Identify(c); ok {
+			budget = policy.Authenticated
+			bucket = "user:" + userID
+		}
+
+		key := fmt.Sprintf("rate_limit:%s:%s:%s", l.service, policy.Name, bucket)
+		count, reset, err := l.count(c.Request.Context(), key, policy.Window)
+		if err != nil {
+			log.Printf("Redis error in %s rate limiting: %v", policy.Name, err)
+			c.Next()
+			return
+		}
+
+		remaining := int64(budget) - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		resetSeconds := int64((reset + time.Second - 1) / time.Second)
+		c.Header("RateLimit-Limit", strconv.Itoa(budget))
+		c.Header("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("RateLimit-Reset", strconv.FormatInt(resetSeconds, 10))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d;name=%q", budget, int64(policy.Window/time.Second), policy.Name))
+
+		if count > int64(budget) {
+			c.Header("Retry-After", strconv.FormatInt(resetSeconds, 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":             "rate_limit_exceeded",
+				"error_description": "Too many requests. Please try again later.",
+				"policy":            policy.Name,
+				"limit":             budget,
+				"retry_after":       resetSeconds,
+			})
+			return
+		}
+		c.Next()
+	}
+}
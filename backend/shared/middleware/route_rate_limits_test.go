@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestParseRateLimitPolicy(t *testing.T) {
+	policy, err := ParseRateLimitPolicy("search", "30/120/1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RateLimitPolicy{Name: "search", Anonymous: 30, Authenticated: 120, Window: time.Minute}
+	if policy != want {
+		t.Errorf("policy = %+v, want %+v", policy, want)
+	}
+
+	for _, spec := range []string{"30/120", "0/120/1m", "30/x/1m", "30/120/forever", "30/120/10ms"} {
+		if _, err := ParseRateLimitPolicy("search", spec); err == nil {
+			t.Errorf("%q parsed without error", spec)
+		}
+	}
+}
+
+func TestRateLimitPoliciesFromEnv(t *testing.T) {
+	t.Setenv("RATE_LIMIT_COMMENT", "2/10/1h")
+	t.Setenv("RATE_LIMIT_SEARCH", "lots")
+
+	policies := RateLimitPoliciesFromEnv()
+	if got := policies[RateLimitComment]; got.Anonymous != 2 || got.Authenticated != 10 || got.Window != time.Hour {
+		t.Errorf("comment policy = %+v", got)
+	}
+	if policies[RateLimitSearch] != DefaultRateLimitPolicies()[RateLimitSearch] {
+		t.Errorf("an invalid setting should keep the default, got %+v", policies[RateLimitSearch])
+	}
+}
+
+// countingLimiter counts in memory, with every window ending in 30s
+func countingLimiter(policy RateLimitPolicy) (*RouteRateLimiter, map[string]int64) {
+	counts := map[string]int64{}
+	l := &RouteRateLimiter{
+		service:  "test",
+		policies: map[string]RateLimitPolicy{policy.Name: policy},
+		Identify: contextUserID,
+		count: func(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+			counts[key]++
+			return counts[key], 30 * time.Second, nil
+		},
+	}
+	return l, counts
+}
+
+func limitedRouter(l *RouteRateLimiter, name string, userID interface{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if userID != nil {
+			c.Set("user_id", userID)
+		}
+	})
+	r.GET("/search", l.Limit(name), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func TestRouteRateLimitAnonymousBudget(t *testing.T) {
+	l, _ := countingLimiter(RateLimitPolicy{Name: "search", Anonymous: 2, Authenticated: 5, Window: time.Minute})
+	router := limitedRouter(l, "search", nil)
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search", nil))
+		if i < 2 && w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, w.Code)
+		}
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status %d, want 429", w.Code)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "30",
+		"RateLimit-Policy":    `2;w=60;name="search"`,
+		"Retry-After":         "30",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestRouteRateLimitUserBuckets(t *testing.T) {
+	l, counts := countingLimiter(RateLimitPolicy{Name: "comment", Anonymous: 1, Authenticated: 3, Window: time.Minute})
+	userID := uuid.New()
+
+	w := httptest.NewRecorder()
+	limitedRouter(l, "comment", userID).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search", nil))
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "3" || w.Header().Get("RateLimit-Remaining") != "2" {
+		t.Errorf("signed-in request: status %d, headers %v", w.Code, w.Header())
+	}
+	if counts["rate_limit:test:comment:user:"+userID.String()] != 1 {
+		t.Errorf("user bucket not counted: %v", counts)
+	}
+
+	w = httptest.NewRecorder()
+	limitedRouter(l, "comment", nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search", nil))
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("anonymous request should use its own bucket: status %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestRouteRateLimitWithoutRedis(t *testing.T) {
+	l := NewRouteRateLimiter(nil, "test", DefaultRateLimitPolicies())
+	w := httptest.NewRecorder()
+	limitedRouter(l, RateLimitPost, nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search", nil))
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("status %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestRouteRateLimitUnknownPolicy(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("an unknown policy should panic when routes are set up")
+		}
+	}()
+	NewRouteRateLimiter(nil, "test", DefaultRateLimitPolicies()).Limit("kudos")
+}
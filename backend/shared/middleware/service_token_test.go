@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serviceTokenRouter(token string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequireServiceToken(token))
+	r.POST("/internal", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"dry_run": IsDryRun(c)})
+	})
+	return r
+}
+
+func TestRequireServiceToken(t *testing.T) {
+	cases := []struct {
+		name       string
+		configured string
+		presented  string
+		want       int
+	}{
+		{"matching token", "s3cret", "s3cret", http.StatusOK},
+		{"wrong token", "s3cret", "guess", http.StatusUnauthorized},
+		{"missing token", "s3cret", "", http.StatusUnauthorized},
+		{"not configured", "", "", http.StatusServiceUnavailable},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/internal", nil)
+		if tc.presented != "" {
+			req.Header.Set(ServiceTokenHeader, tc.presented)
+		}
+		w := httptest.NewRecorder()
+		serviceTokenRouter(tc.configured).ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
+
+func TestIsDryRun(t *testing.T) {
+	for query, want := range map[string]string{
+		"":              `{"dry_run":false}`,
+		"?dry_run=true": `{"dry_run":true}`,
+		"?dry_run=1":    `{"dry_run":true}`,
+		"?dry_run=nope": `{"dry_run":false}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/internal"+query, nil)
+		req.Header.Set(ServiceTokenHeader, "s3cret")
+		w := httptest.NewRecorder()
+		serviceTokenRouter("s3cret").ServeHTTP(w, req)
+		if w.Body.String() != want {
+			t.Errorf("%q: got %s, want %s", query, w.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+// Package migrate applies a service's versioned schema migrations.
+//
+// Each service embeds its own migrations as NNN_name.sql files and records
+// the ones applied in the shared schema_migrations table, keyed by service
+// and version along with a checksum of the SQL. Migrations apply in version
+// order, each in its own transaction, under an advisory lock so replicas
+// starting together don't race.
+//
+// Services gate startup on their migrations: pending migrations are applied
+// when MIGRATE_ON_START is true, and otherwise the service refuses to start
+// until `<service> migrate up` has been run. `<service> migrate status`
+// lists what is applied and pending, and `<service> migrate baseline N`
+// marks migrations through N as applied on a database whose schema
+// predates tracking.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	SQL     string
+}
+
+// Checksum identifies the migration's SQL, to notice edits to migrations
+// that were already applied
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.SQL))
+	return hex.EncodeToString(sum[:])
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.sql$`)
+
+// Load reads the NNN_name.sql migrations in dir of fsys, in version order
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []Migration{}
+	seen := map[int64]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s isn't named NNN_name.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s has an invalid version", entry.Name())
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: match[2], SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Applied is a migration recorded in schema_migrations
+type Applied struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Status compares a service's migrations with those applied
+type Status struct {
+	Applied []Applied
+	Pending []Migration
+	// Modified are applied migrations whose SQL has since changed
+	Modified []Migration
+	// Unknown are applied versions this build has no migration for, as
+	// after rolling back to an older build
+	Unknown []Applied
+}
+
+// UpToDate reports whether every migration is applied unchanged
+func (s Status) UpToDate() bool {
+	return len(s.Pending) == 0 && len(s.Modified) == 0
+}
+
+// compare works out a Status from the migrations and the applied rows
+func compare(migrations []Migration, applied []Applied) Status {
+	status := Status{Applied: applied}
+	byVersion := make(map[int64]Applied, len(applied))
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+	known := make(map[int64]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+		a, ok := byVersion[m.Version]
+		switch {
+		case !ok:
+			status.Pending = append(status.Pending, m)
+		case a.Checksum != m.Checksum():
+			status.Modified = append(status.Modified, m)
+		}
+	}
+	for _, a := range applied {
+		if !known[a.Version] {
+			status.Unknown = append(status.Unknown, a)
+		}
+	}
+	return status
+}
+
+const createTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		service VARCHAR(100) NOT NULL,
+		version BIGINT NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (service, version)
+	)`
+
+// Runner applies one service's migrations
+type Runner struct {
+	DB         *sql.DB
+	Service    string
+	Migrations []Migration
+}
+
+// NewRunner loads the service's migrations from dir of fsys
+func NewRunner(db *sql.DB, service string, fsys fs.FS, dir string) (*Runner, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{DB: db, Service: service, Migrations: migrations}, nil
+}
+
+// lockID is the advisory lock serializing a service's migrations
+func (r *Runner) lockID() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("schema_migrations:" + r.Service))
+	return int64(h.Sum64())
+}
+
+// Status reports which migrations are applied and pending
+func (r *Runner) Status(ctx context.Context) (Status, error) {
+	if _, err := r.DB.ExecContext(ctx, createTable); err != nil {
+		return Status{}, err
+	}
+	applied, err := r.applied(ctx, r.DB)
+	if err != nil {
+		return Status{}, err
+	}
+	return compare(r.Migrations, applied), nil
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (r *Runner) applied(ctx context.Context, q querier) ([]Applied, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT version, name, checksum, applied_at FROM schema_migrations
+		WHERE service = $1 ORDER BY version`, r.Service)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := []Applied{}
+	for rows.Next() {
+		var a Applied
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	return applied, rows.Err()
+}
+
+// Up applies every pending migration and returns those it applied. It
+// refuses to run while an applied migration has been modified.
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	if _, err := r.DB.ExecContext(ctx, createTable); err != nil {
+		return nil, err
+	}
+
+	conn, err := r.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", r.lockID()); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", r.lockID())
+
+	// Read under the lock, so migrations another instance just applied
+	// aren't applied again
+	applied, err := r.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	status := compare(r.Migrations, applied)
+	if len(status.Modified) > 0 {
+		return nil, fmt.Errorf("migration %d_%s was changed after it was applied",
+			status.Modified[0].Version, status.Modified[0].Name)
+	}
+
+	done := []Migration{}
+	for _, m := range status.Pending {
+		if err := r.apply(ctx, conn, m); err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+func (r *Runner) apply(ctx context.Context, conn *sql.Conn, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO schema_migrations (service, version, name, checksum)
+		VALUES ($1, $2, $3, $4)`, r.Service, m.Version, m.Name, m.Checksum()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Baseline records every migration up to and including through as applied
+// without running it, for a database whose schema was created before the
+// service's migrations were tracked. Migrations already recorded are left
+// as they are; it returns those it recorded.
+func (r *Runner) Baseline(ctx context.Context, through int64) ([]Migration, error) {
+	if _, err := r.DB.ExecContext(ctx, createTable); err != nil {
+		return nil, err
+	}
+
+	conn, err := r.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", r.lockID()); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", r.lockID())
+
+	applied, err := r.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, m := range compare(r.Migrations, applied).Pending {
+		if m.Version > through {
+			break
+		}
+		if _, err := conn.ExecContext(ctx, `
+			INSERT INTO schema_migrations (service, version, name, checksum)
+			VALUES ($1, $2, $3, $4)`, r.Service, m.Version, m.Name, m.Checksum()); err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Gate readies the schema at startup. With onStart pending migrations are
+// applied; otherwise any pending or modified migration is an error, so a
+// service never runs against a schema it doesn't expect.
+func (r *Runner) Gate(ctx context.Context, onStart bool, log func(format string, args ...interface{})) error {
+	if onStart {
+		done, err := r.Up(ctx)
+		for _, m := range done {
+			log("Applied %s migration %d_%s", r.Service, m.Version, m.Name)
+		}
+		return err
+	}
+
+	status, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if !status.UpToDate() {
+		return fmt.Errorf("%d pending and %d modified migrations; run `%s migrate up` or set MIGRATE_ON_START=true",
+			len(status.Pending), len(status.Modified), r.Service)
+	}
+	return nil
+}
+
+// Command runs `migrate up`, `migrate status` or `migrate baseline
+// <version>` for the service, writing a report to out
+func (r *Runner) Command(ctx context.Context, args []string, out io.Writer) error {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		done, err := r.Up(ctx)
+		for _, m := range done {
+			fmt.Fprintf(out, "applied %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+		return nil
+	case "status":
+		status, err := r.Status(ctx)
+		if err != nil {
+			return err
+		}
+		writeStatus(out, status)
+		return nil
+	case "baseline":
+		if len(args) < 2 {
+			return fmt.Errorf("migrate baseline needs the last version the schema already has")
+		}
+		through, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || through < 1 {
+			return fmt.Errorf("invalid baseline version %q", args[1])
+		}
+		done, err := r.Baseline(ctx, through)
+		for _, m := range done {
+			fmt.Fprintf(out, "baselined %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	}
+	return fmt.Errorf("unknown migrate command %q (use up, status or baseline)", action)
+}
+
+func writeStatus(out io.Writer, status Status) {
+	for _, a := range status.Applied {
+		fmt.Fprintf(out, "applied  %d_%s  %s\n", a.Version, a.Name, a.AppliedAt.Format(time.RFC3339))
+	}
+	for _, m := range status.Modified {
+		fmt.Fprintf(out, "MODIFIED %d_%s\n", m.Version, m.Name)
+	}
+	for _, m := range status.Pending {
+		fmt.Fprintf(out, "pending  %d_%s\n", m.Version, m.Name)
+	}
+	for _, a := range status.Unknown {
+		fmt.Fprintf(out, "unknown  %d_%s (not in this build)\n", a.Version, a.Name)
+	}
+}
+
+// OnStart reads MIGRATE_ON_START, falling back to the service's default
+func OnStart(defaultOn bool) bool {
+	onStart, err := strconv.ParseBool(os.Getenv("MIGRATE_ON_START"))
+	if err != nil {
+		return defaultOn
+	}
+	return onStart
+}
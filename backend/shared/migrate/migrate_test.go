@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadOrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/010_add_callbacks.sql": {Data: []byte("ALTER TABLE exports ADD COLUMN callback_url TEXT;")},
+		"migrations/002_add_index.sql":     {Data: []byte("CREATE INDEX ON exports(id);")},
+		"migrations/001_create.sql":        {Data: []byte("CREATE TABLE exports (id TEXT);")},
+		"migrations/README.md":             {Data: []byte("not a migration")},
+	}
+
+	migrations, err := Load(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var names []string
+	for _, m := range migrations {
+		names = append(names, m.Name)
+	}
+	if got := strings.Join(names, ","); got != "create,add_index,add_callbacks" {
+		t.Errorf("Expected migrations in version order, got %s", got)
+	}
+}
+
+func TestLoadRejectsBadNames(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"shared version": {
+			"m/001_a.sql": {Data: []byte("SELECT 1;")},
+			"m/1_b.sql":   {Data: []byte("SELECT 2;")},
+		},
+		"no version": {"m/create_table.sql": {Data: []byte("SELECT 1;")}},
+		"zero":       {"m/000_start.sql": {Data: []byte("SELECT 1;")}},
+	} {
+		if _, err := Load(fsys, "m"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	first := Migration{Version: 1, Name: "create", SQL: "CREATE TABLE a (id INT);"}
+	second := Migration{Version: 2, Name: "index", SQL: "CREATE INDEX ON a(id);"}
+	now := time.Now()
+
+	status := compare([]Migration{first, second}, []Applied{{Version: 1, Name: "create", Checksum: first.Checksum(), AppliedAt: now}})
+	if len(status.Pending) != 1 || status.Pending[0].Version != 2 || status.UpToDate() {
+		t.Errorf("Expected migration 2 pending, got %+v", status)
+	}
+
+	edited := first
+	edited.SQL = "CREATE TABLE a (id BIGINT);"
+	status = compare([]Migration{edited, second}, []Applied{
+		{Version: 1, Name: "create", Checksum: first.Checksum(), AppliedAt: now},
+		{Version: 2, Name: "index", Checksum: second.Checksum(), AppliedAt: now},
+		{Version: 3, Name: "from_a_newer_build", Checksum: "x", AppliedAt: now},
+	})
+	if len(status.Modified) != 1 || status.UpToDate() {
+		t.Errorf("An applied migration that changed should be reported, got %+v", status)
+	}
+	if len(status.Unknown) != 1 || status.Unknown[0].Version != 3 {
+		t.Errorf("Versions this build doesn't know should be reported, got %+v", status.Unknown)
+	}
+
+	var out bytes.Buffer
+	writeStatus(&out, status)
+	if !strings.Contains(out.String(), "MODIFIED 1_create") || !strings.Contains(out.String(), "unknown  3_from_a_newer_build") {
+		t.Errorf("Unexpected status report:\n%s", out.String())
+	}
+}
+
+func TestCommandRejectsUnknownActions(t *testing.T) {
+	r := &Runner{Service: "export-service"}
+	if err := r.Command(context.Background(), []string{"down"}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for an unsupported action")
+	}
+}
+
+func TestCommandRejectsBadBaselines(t *testing.T) {
+	r := &Runner{Service: "schema"}
+	for _, args := range [][]string{{"baseline"}, {"baseline", "latest"}, {"baseline", "0"}} {
+		if err := r.Command(context.Background(), args, &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
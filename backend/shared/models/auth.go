@@ -13,6 +13,8 @@ type AuthClaims struct {
 	Username string    `json:"username"`
 	Email    string    `json:"email"`
 	Roles    []string  `json:"roles"`
+	// SessionID is the signed-in session the token belongs to
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -31,6 +33,8 @@ type RegisterRequest struct {
 	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=Password"`
 	DisplayName     string `json:"display_name" validate:"max=100"`
 	AcceptTOS       bool   `json:"accept_tos" validate:"required"`
+	// InviteCode is required while registration is invite-only
+	InviteCode string `json:"invite_code,omitempty"`
 }
 
 // AuthResponse represents successful authentication response
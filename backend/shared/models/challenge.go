@@ -0,0 +1,172 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Challenge types
+const (
+	ChallengeTypeGiftExchange = "gift_exchange"
+	ChallengeTypePromptMeme   = "prompt_meme"
+)
+
+// Assignment statuses
+const (
+	AssignmentStatusDraft     = "draft"
+	AssignmentStatusAssigned  = "assigned"
+	AssignmentStatusFulfilled = "fulfilled"
+	AssignmentStatusDefaulted = "defaulted"
+)
+
+// Prompt claim statuses
+const (
+	ClaimStatusOpen     = "open"
+	ClaimStatusFilled   = "filled"
+	ClaimStatusReleased = "released"
+)
+
+// TagLimit bounds how many tags of one type a prompt may carry
+type TagLimit struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// ChallengeSettings configures a collection as a gift exchange or prompt meme
+type ChallengeSettings struct {
+	CollectionID      uuid.UUID           `json:"collection_id" db:"collection_id"`
+	ChallengeType     string              `json:"challenge_type" db:"challenge_type"`
+	SignupsOpen       bool                `json:"signups_open" db:"signups_open"`
+	SignupsOpenAt     *time.Time          `json:"signups_open_at" db:"signups_open_at"`
+	SignupsCloseAt    *time.Time          `json:"signups_close_at" db:"signups_close_at"`
+	AssignmentsDueAt  *time.Time          `json:"assignments_due_at" db:"assignments_due_at"`
+	WorksRevealAt     *time.Time          `json:"works_reveal_at" db:"works_reveal_at"`
+	AuthorsRevealAt   *time.Time          `json:"authors_reveal_at" db:"authors_reveal_at"`
+	RequestsMin       int                 `json:"requests_min" db:"requests_min"`
+	RequestsMax       int                 `json:"requests_max" db:"requests_max"`
+	OffersMin         int                 `json:"offers_min" db:"offers_min"`
+	OffersMax         int                 `json:"offers_max" db:"offers_max"`
+	PromptTagLimits   map[string]TagLimit `json:"prompt_tag_limits" db:"prompt_tag_limits"`
+	MatchTagTypes     []string            `json:"match_tag_types" db:"match_tag_types"`
+	MatchMinTags      int                 `json:"match_min_tags" db:"match_min_tags"`
+	ClaimLimit        int                 `json:"claim_limit" db:"claim_limit"` // Prompt memes: open claims per user, 0 = unlimited
+	AllowMultiClaims  bool                `json:"allow_multiple_claims" db:"allow_multiple_claims"`
+	AssignmentsSentAt *time.Time          `json:"assignments_sent_at" db:"assignments_sent_at"`
+	TagSetIDs         []uuid.UUID         `json:"tag_set_ids"` // Loaded from challenge_tag_sets
+	CreatedAt         time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time           `json:"updated_at" db:"updated_at"`
+}
+
+// ChallengeSignup is one participant's entry in a challenge
+type ChallengeSignup struct {
+	ID           uuid.UUID         `json:"id" db:"id"`
+	CollectionID uuid.UUID         `json:"collection_id" db:"collection_id"`
+	UserID       uuid.UUID         `json:"user_id" db:"user_id"`
+	PseudID      *uuid.UUID        `json:"pseud_id" db:"pseud_id"`
+	Username     string            `json:"username,omitempty"` // Loaded from join
+	Requests     []ChallengePrompt `json:"requests"`
+	Offers       []ChallengePrompt `json:"offers"`
+	CreatedAt    time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// ChallengePrompt is a request or offer within a sign-up
+type ChallengePrompt struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	SignupID    uuid.UUID `json:"signup_id" db:"signup_id"`
+	Kind        string    `json:"kind" db:"kind"` // request, offer
+	Position    int       `json:"position" db:"position"`
+	Title       string    `json:"title" db:"title"`
+	Description string    `json:"description" db:"description"`
+	Tags        []string  `json:"tags" db:"tags"`
+	IsAnonymous bool      `json:"is_anonymous" db:"is_anonymous"`
+}
+
+// ChallengeAssignment pairs a giver with a recipient's request
+type ChallengeAssignment struct {
+	ID                uuid.UUID  `json:"id" db:"id"`
+	CollectionID      uuid.UUID  `json:"collection_id" db:"collection_id"`
+	GiverUserID       uuid.UUID  `json:"giver_user_id" db:"giver_user_id"`
+	GiverSignupID     *uuid.UUID `json:"giver_signup_id" db:"giver_signup_id"`
+	RecipientSignupID uuid.UUID  `json:"recipient_signup_id" db:"recipient_signup_id"`
+	RequestPromptID   *uuid.UUID `json:"request_prompt_id" db:"request_prompt_id"`
+	OfferPromptID     *uuid.UUID `json:"offer_prompt_id" db:"offer_prompt_id"`
+	Status            string     `json:"status" db:"status"`
+	MatchScore        int        `json:"match_score" db:"match_score"`
+	DueAt             *time.Time `json:"due_at" db:"due_at"`
+	SentAt            *time.Time `json:"sent_at" db:"sent_at"`
+	FulfilledAt       *time.Time `json:"fulfilled_at" db:"fulfilled_at"`
+	DefaultedAt       *time.Time `json:"defaulted_at" db:"defaulted_at"`
+	WorkID            *uuid.UUID `json:"work_id" db:"work_id"`
+	PinchHitFor       *uuid.UUID `json:"pinch_hit_for" db:"pinch_hit_for"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// PromptClaim records a user's intent to fill a prompt meme prompt
+type PromptClaim struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	PromptID     uuid.UUID  `json:"prompt_id" db:"prompt_id"`
+	CollectionID uuid.UUID  `json:"collection_id" db:"collection_id"`
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	Status       string     `json:"status" db:"status"`
+	WorkID       *uuid.UUID `json:"work_id" db:"work_id"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	FilledAt     *time.Time `json:"filled_at" db:"filled_at"`
+	ReleasedAt   *time.Time `json:"released_at" db:"released_at"`
+}
+
+// PromptFill links a work to the prompt it was written for
+type PromptFill struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	PromptID     uuid.UUID  `json:"prompt_id" db:"prompt_id"`
+	CollectionID uuid.UUID  `json:"collection_id" db:"collection_id"`
+	WorkID       uuid.UUID  `json:"work_id" db:"work_id"`
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	ClaimID      *uuid.UUID `json:"claim_id" db:"claim_id"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Tag types a tag set can hold
+var TagSetTagTypes = []string{"fandom", "character", "relationship"}
+
+// Tag set nomination statuses
+const (
+	NominationStatusPending  = "pending"
+	NominationStatusApproved = "approved"
+	NominationStatusRejected = "rejected"
+)
+
+// TagSet is a curated list of tags a challenge can restrict sign-ups to
+type TagSet struct {
+	ID               uuid.UUID           `json:"id" db:"id"`
+	Title            string              `json:"title" db:"title"`
+	Description      string              `json:"description" db:"description"`
+	OwnerID          uuid.UUID           `json:"owner_id" db:"owner_id"`
+	NominationsOpen  bool                `json:"nominations_open" db:"nominations_open"`
+	NominationLimits map[string]TagLimit `json:"nomination_limits" db:"nomination_limits"`
+	TagCount         int                 `json:"tag_count"` // Loaded from join
+	CreatedAt        time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at" db:"updated_at"`
+}
+
+// TagSetTag is one tag in a tag set
+type TagSetTag struct {
+	Type   string  `json:"type" db:"tag_type"`
+	Name   string  `json:"name" db:"name"`
+	Fandom *string `json:"fandom,omitempty" db:"fandom"`
+}
+
+// TagSetNomination is a tag a user has nominated for a tag set
+type TagSetNomination struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	TagSetID    uuid.UUID  `json:"tag_set_id" db:"tag_set_id"`
+	NominatorID uuid.UUID  `json:"nominator_id" db:"nominator_id"`
+	Type        string     `json:"type" db:"tag_type"`
+	Name        string     `json:"name" db:"name"`
+	Fandom      *string    `json:"fandom,omitempty" db:"fandom"`
+	Status      string     `json:"status" db:"status"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
@@ -40,6 +40,7 @@ type CommentWithDetails struct {
 	WorkAuthorID      *uuid.UUID           `json:"work_author_id" db:"work_author_id"`
 	ParentContent     *string              `json:"parent_content" db:"parent_content"`
 	ParentAuthorName  *string              `json:"parent_author_name" db:"parent_author_name"`
+	Mentions          []CommentMention     `json:"mentions,omitempty"`
 	Replies           []CommentWithDetails `json:"replies,omitempty"` // For nested display
 }
 
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MaxMentionsPerComment caps how many users one comment can notify
+const MaxMentionsPerComment = 10
+
+// mentionPattern matches @username where the @ doesn't follow a word
+// character, so email addresses aren't mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_-]{3,50})`)
+
+// CommentMention is a user mentioned in a comment
+type CommentMention struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+}
+
+// ParseMentions returns the usernames mentioned in content, in order of
+// first appearance, without duplicates (compared case-insensitively) and
+// at most MaxMentionsPerComment of them
+func ParseMentions(content string) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := match[1]
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		names = append(names, name)
+		if len(names) == MaxMentionsPerComment {
+			break
+		}
+	}
+	return names
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	cases := map[string][]string{
+		"@alice loved this":                      {"alice"},
+		"thanks @bob_writes and @Carol-2!":       {"bob_writes", "Carol-2"},
+		"(@dave) @Dave again, @dave":             {"dave"},
+		"mail me at erin@example.com":            {},
+		"@@frank and @go are not mentions":       {},
+		"first line\n@grace on the second line.": {"grace"},
+	}
+	for content, want := range cases {
+		if got := ParseMentions(content); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseMentions(%q) = %v, want %v", content, got, want)
+		}
+	}
+
+	many := []string{}
+	for i := 0; i < MaxMentionsPerComment+5; i++ {
+		many = append(many, fmt.Sprintf("@reader%d", i))
+	}
+	if got := ParseMentions(strings.Join(many, " ")); len(got) != MaxMentionsPerComment {
+		t.Errorf("expected mentions capped at %d, got %d", MaxMentionsPerComment, len(got))
+	}
+}
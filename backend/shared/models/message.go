@@ -38,12 +38,45 @@ type DeliveryChannel string
 
 const (
 	ChannelEmail   DeliveryChannel = "email"
-	ChannelPush    DeliveryChannel = "push"
+	ChannelPush    DeliveryChannel = "push" // mobile apps, through FCM or APNs
+	ChannelWebPush DeliveryChannel = "web_push"
 	ChannelSMS     DeliveryChannel = "sms"
 	ChannelWebhook DeliveryChannel = "webhook"
 	ChannelInApp   DeliveryChannel = "in_app"
 )
 
+// PushPlatform is the push service a device is reached through
+type PushPlatform string
+
+const (
+	PushPlatformWeb  PushPlatform = "web"  // browsers, through Web Push with VAPID
+	PushPlatformFCM  PushPlatform = "fcm"  // Android, through Firebase Cloud Messaging
+	PushPlatformAPNs PushPlatform = "apns" // iOS, through the Apple Push Notification service
+)
+
+// PushDevice is a browser or app install registered for push notifications.
+// Token is the FCM or APNs device token, or a Web Push subscription's
+// endpoint URL, whose P256dh and Auth keys encrypt the payload.
+type PushDevice struct {
+	ID         uuid.UUID    `json:"id" db:"id"`
+	UserID     uuid.UUID    `json:"user_id" db:"user_id"`
+	Platform   PushPlatform `json:"platform" db:"platform"`
+	Token      string       `json:"-" db:"token"`
+	P256dh     string       `json:"-" db:"p256dh"`
+	Auth       string       `json:"-" db:"auth"`
+	Name       string       `json:"name" db:"name"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// PushChannel is the delivery channel a platform's devices are reached on
+func (p PushPlatform) PushChannel() DeliveryChannel {
+	if p == PushPlatformWeb {
+		return ChannelWebPush
+	}
+	return ChannelPush
+}
+
 // DeliveryStatus represents the status of a delivery attempt
 type DeliveryStatus string
 
@@ -54,6 +87,10 @@ const (
 	DeliveryStatusFailed    DeliveryStatus = "failed"
 	DeliveryStatusBounced   DeliveryStatus = "bounced"
 	DeliveryStatusRetrying  DeliveryStatus = "retrying"
+	// The recipient reported the message as spam
+	DeliveryStatusComplained DeliveryStatus = "complained"
+	// Not sent because the address is on the suppression list
+	DeliveryStatusSuppressed DeliveryStatus = "suppressed"
 )
 
 // NotificationFrequency represents how often notifications should be sent
@@ -65,6 +102,9 @@ const (
 	FrequencyDaily     NotificationFrequency = "daily"
 	FrequencyWeekly    NotificationFrequency = "weekly"
 	FrequencyNever     NotificationFrequency = "never"
+	// FrequencyMuted keeps a subscription (e.g. a followed tag) without
+	// creating any notifications for it
+	FrequencyMuted NotificationFrequency = "muted"
 )
 
 // Message represents a notification message that can be delivered through multiple channels
@@ -79,6 +119,10 @@ type Message struct {
 	Recipients []Recipient            `json:"recipients,omitempty" db:"-"`
 }
 
+// MessageMetaNotificationEvent is the Metadata key naming the notification
+// event a message was sent for, which its unsubscribe link turns off
+const MessageMetaNotificationEvent = "notification_event"
+
 // MessageContent represents the content of a message across different channels
 type MessageContent struct {
 	Subject   string                 `json:"subject"`
@@ -97,6 +141,10 @@ type Recipient struct {
 	Context     map[string]interface{}   `json:"context,omitempty"`
 }
 
+// RecipientContextUnsubscribeURL is the Context key holding the
+// recipient's signed unsubscribe link, set by the messaging service
+const RecipientContextUnsubscribeURL = "unsubscribe_url"
+
 // DeliveryAttempt represents an attempt to deliver a message through a specific channel
 type DeliveryAttempt struct {
 	ID          uuid.UUID              `json:"id" db:"id"`
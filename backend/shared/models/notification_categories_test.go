@@ -0,0 +1,56 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotificationEventCategory(t *testing.T) {
+	cases := map[NotificationEvent]NotificationCategory{
+		EventCommentReplied:   CategoryComments,
+		EventKudosReceived:    CategoryKudos,
+		EventWorkUpdated:      CategorySubscriptions,
+		EventSavedSearchMatch: CategorySubscriptions,
+		EventGiftReceived:     CategoryOther,
+		"something_new":       CategoryOther,
+	}
+	for event, want := range cases {
+		if got := event.Category(); got != want {
+			t.Errorf("%s.Category() = %s, want %s", event, got, want)
+		}
+	}
+
+	counts := UnreadCountsByCategory(map[NotificationEvent]int{
+		EventCommentReceived: 2, EventCommentMention: 1, EventBookmarkAdded: 4,
+	})
+	if counts[CategoryComments] != 3 || counts[CategoryOther] != 4 || counts[CategoryKudos] != 0 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+	if len(counts) != len(NotificationCategories) {
+		t.Errorf("expected every category in %v", counts)
+	}
+}
+
+func TestNotificationReadFilterCategoryAndBefore(t *testing.T) {
+	cutoff := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	filter := NotificationReadFilter{Category: CategoryOther, Before: &cutoff}
+
+	cases := []struct {
+		item NotificationItem
+		want bool
+	}{
+		{NotificationItem{Event: EventGiftReceived, CreatedAt: cutoff}, true},
+		{NotificationItem{Event: EventGiftReceived, CreatedAt: cutoff.Add(time.Second)}, false},
+		{NotificationItem{Event: EventKudosReceived, CreatedAt: cutoff.Add(-time.Hour)}, false},
+	}
+	for _, tc := range cases {
+		if got := filter.Matches(&tc.item); got != tc.want {
+			t.Errorf("Matches(%s at %s) = %v, want %v", tc.item.Event, tc.item.CreatedAt, got, tc.want)
+		}
+	}
+
+	excluded, include := CategoryEvents(CategoryOther)
+	if include || len(excluded) == 0 {
+		t.Errorf("CategoryEvents(other) = %v, %v; want the categorised events to exclude", excluded, include)
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestGroupNotifications(t *testing.T) {
+	work, other := uuid.New(), uuid.New()
+	now := time.Now()
+	kudos := func(source uuid.UUID, read bool, ago time.Duration) *NotificationItem {
+		return &NotificationItem{
+			ID: uuid.New(), Event: EventKudosReceived, SourceID: source, Title: "New kudos",
+			ExtraData: map[string]interface{}{"work_title": "Work X"}, IsRead: read, CreatedAt: now.Add(-ago),
+		}
+	}
+	comment := &NotificationItem{ID: uuid.New(), Event: EventCommentReceived, SourceID: work, Title: "New comment", CreatedAt: now.Add(-2 * time.Minute)}
+	comment2 := &NotificationItem{ID: uuid.New(), Event: EventCommentReceived, SourceID: work, Title: "Another comment", CreatedAt: now.Add(-3 * time.Minute)}
+
+	// Newest first, as the inbox lists them
+	groups := GroupNotifications([]*NotificationItem{
+		kudos(work, false, time.Minute),
+		comment,
+		comment2,
+		kudos(other, false, 4*time.Minute),
+		kudos(work, true, 5*time.Minute),
+		kudos(work, false, 6*time.Minute),
+	})
+
+	if len(groups) != 4 {
+		t.Fatalf("expected 4 groups, got %d", len(groups))
+	}
+	if groups[0].Count != 3 || groups[0].UnreadCount != 2 || groups[0].Summary != "3 kudos on Work X" {
+		t.Errorf("unexpected kudos group %+v", groups[0])
+	}
+	if groups[1].Count != 1 || groups[1].Summary != "New comment" || groups[2].Latest != comment2 {
+		t.Error("comments should not be grouped")
+	}
+	if groups[3].SourceID != other || groups[3].Summary != "New kudos" {
+		t.Errorf("kudos on another work should be their own group, got %+v", groups[3])
+	}
+}
+
+func TestNotificationInboxFilter(t *testing.T) {
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 0, 7)
+	archivedAt := since.AddDate(0, 0, 2)
+	filter := NotificationInboxFilter{Category: CategoryComments, UnreadOnly: true, Since: &since, Until: &until}
+
+	cases := []struct {
+		item NotificationItem
+		want bool
+	}{
+		{NotificationItem{Event: EventCommentReplied, CreatedAt: since.AddDate(0, 0, 1)}, true},
+		{NotificationItem{Event: EventCommentReplied, CreatedAt: since.AddDate(0, 0, 1), IsRead: true}, false},
+		{NotificationItem{Event: EventCommentReplied, CreatedAt: since.AddDate(0, 0, 1), ArchivedAt: &archivedAt}, false},
+		{NotificationItem{Event: EventKudosReceived, CreatedAt: since.AddDate(0, 0, 1)}, false},
+		{NotificationItem{Event: EventCommentReplied, CreatedAt: since.AddDate(0, 0, -1)}, false},
+		{NotificationItem{Event: EventCommentReplied, CreatedAt: until.AddDate(0, 0, 1)}, false},
+	}
+	for i, c := range cases {
+		if got := filter.Matches(&c.item); got != c.want {
+			t.Errorf("case %d: Matches = %v, want %v", i, got, c.want)
+		}
+	}
+
+	archive := NotificationInboxFilter{Archived: true}
+	if !archive.Matches(&cases[2].item) || archive.Matches(&cases[0].item) {
+		t.Error("the archive should list only archived notifications")
+	}
+}
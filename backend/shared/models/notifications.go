@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -32,20 +33,28 @@ const (
 type NotificationEvent string
 
 const (
-	EventWorkUpdated      NotificationEvent = "work_updated"
-	EventWorkCompleted    NotificationEvent = "work_completed"
-	EventSeriesUpdated    NotificationEvent = "series_updated"
-	EventNewWork          NotificationEvent = "new_work"
-	EventCommentReceived  NotificationEvent = "comment_received"
-	EventCommentReplied   NotificationEvent = "comment_replied"
-	EventKudosReceived    NotificationEvent = "kudos_received"
-	EventBookmarkAdded    NotificationEvent = "bookmark_added"
-	EventGiftReceived     NotificationEvent = "gift_received"
-	EventCollectionInvite NotificationEvent = "collection_invite"
-	EventModeratorAction  NotificationEvent = "moderator_action"
-	EventSystemAlert      NotificationEvent = "system_alert"
-	EventAccountSecurity  NotificationEvent = "account_security"
-	EventPasswordReset    NotificationEvent = "password_reset"
+	EventWorkUpdated            NotificationEvent = "work_updated"
+	EventWorkCompleted          NotificationEvent = "work_completed"
+	EventSeriesUpdated          NotificationEvent = "series_updated"
+	EventNewWork                NotificationEvent = "new_work"
+	EventCommentReceived        NotificationEvent = "comment_received"
+	EventCommentReplied         NotificationEvent = "comment_replied"
+	EventCommentMention         NotificationEvent = "comment_mention"
+	EventKudosReceived          NotificationEvent = "kudos_received"
+	EventBookmarkAdded          NotificationEvent = "bookmark_added"
+	EventGiftReceived           NotificationEvent = "gift_received"
+	EventCollectionInvite       NotificationEvent = "collection_invite"
+	EventModeratorAction        NotificationEvent = "moderator_action"
+	EventSystemAlert            NotificationEvent = "system_alert"
+	EventAccountSecurity        NotificationEvent = "account_security"
+	EventPasswordReset          NotificationEvent = "password_reset"
+	EventWorkUnpublished        NotificationEvent = "work_unpublished"
+	EventWorkRevealed           NotificationEvent = "work_revealed"
+	EventCollectionItemReviewed NotificationEvent = "collection_item_reviewed"
+	EventSavedSearchMatch       NotificationEvent = "saved_search_match"
+	EventTagReportReviewed      NotificationEvent = "tag_report_reviewed"
+	EventWorkMilestone          NotificationEvent = "work_milestone"
+	EventAbuseReportUpdated     NotificationEvent = "abuse_report_updated"
 )
 
 // Subscription represents a user's subscription to content
@@ -76,9 +85,10 @@ type NotificationPreferences struct {
 	UserID uuid.UUID `json:"user_id" db:"user_id"`
 
 	// Global settings
-	EmailEnabled bool `json:"email_enabled" db:"email_enabled"`
-	WebEnabled   bool `json:"web_enabled" db:"web_enabled"`
-	PushEnabled  bool `json:"push_enabled" db:"push_enabled"`
+	EmailEnabled   bool `json:"email_enabled" db:"email_enabled"`
+	WebEnabled     bool `json:"web_enabled" db:"web_enabled"`
+	PushEnabled    bool `json:"push_enabled" db:"push_enabled"` // mobile app push
+	WebPushEnabled bool `json:"web_push_enabled" db:"web_push_enabled"`
 
 	// Event-specific settings
 	EventPreferences map[NotificationEvent]EventPreference `json:"event_preferences" db:"event_preferences"`
@@ -90,14 +100,90 @@ type NotificationPreferences struct {
 	QuietHoursEnd   *time.Time            `json:"quiet_hours_end,omitempty" db:"quiet_hours_end"`
 	Timezone        string                `json:"timezone" db:"timezone"`
 
+	// Digest layout: sections in this order come first, and each section
+	// shows at most DigestSectionLimit items (0 means the default)
+	DigestSectionOrder []NotificationEvent `json:"digest_section_order,omitempty" db:"digest_section_order"`
+	DigestSectionLimit int                 `json:"digest_section_limit" db:"digest_section_limit"`
+
 	// Anti-spam settings
 	MaxNotificationsPerHour int           `json:"max_notifications_per_hour" db:"max_notifications_per_hour"`
 	MinTimeBetweenSimilar   time.Duration `json:"min_time_between_similar" db:"min_time_between_similar"`
 
+	// Pause: until this time only critical notifications are delivered; the
+	// rest are held and sent as one digest when the pause ends. Set through
+	// the pause endpoint, not by saving preferences.
+	PausedUntil *time.Time `json:"paused_until,omitempty" db:"paused_until"`
+
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// Location is the user's time zone, UTC when it's unset or unknown
+func (p *NotificationPreferences) Location() *time.Location {
+	if p.Timezone != "" {
+		if loc, err := time.LoadLocation(p.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// InQuietHours reports whether now falls in the user's quiet hours. Start
+// and end are wall-clock times in the user's time zone (only their hour and
+// minute count); a start after the end spans midnight.
+func (p *NotificationPreferences) InQuietHours(now time.Time) bool {
+	if p.QuietHoursStart == nil || p.QuietHoursEnd == nil {
+		return false
+	}
+	local := now.In(p.Location())
+	minute := local.Hour()*60 + local.Minute()
+	start := p.QuietHoursStart.Hour()*60 + p.QuietHoursStart.Minute()
+	end := p.QuietHoursEnd.Hour()*60 + p.QuietHoursEnd.Minute()
+
+	switch {
+	case start == end:
+		return false
+	case start < end:
+		return minute >= start && minute < end
+	default:
+		return minute >= start || minute < end
+	}
+}
+
+// IsPaused reports whether notifications are paused at now
+func (p *NotificationPreferences) IsPaused(now time.Time) bool {
+	return p.PausedUntil != nil && now.Before(*p.PausedUntil)
+}
+
+// ChannelEnabled reports whether the user has a delivery channel switched
+// on. An event's preferred channels are only used when this allows them.
+func (p *NotificationPreferences) ChannelEnabled(channel DeliveryChannel) bool {
+	switch channel {
+	case ChannelEmail:
+		return p.EmailEnabled
+	case ChannelInApp:
+		return p.WebEnabled
+	case ChannelPush:
+		return p.PushEnabled
+	case ChannelWebPush:
+		return p.WebPushEnabled
+	default:
+		return true
+	}
+}
+
+// criticalEvents are delivered even while notifications are paused
+var criticalEvents = map[NotificationEvent]bool{
+	EventAccountSecurity: true,
+	EventPasswordReset:   true,
+	EventModeratorAction: true,
+}
+
+// IsCriticalEvent reports whether an event is delivered during a pause
+func IsCriticalEvent(event NotificationEvent) bool {
+	return criticalEvents[event]
+}
+
 // EventPreference defines preferences for a specific event type
 type EventPreference struct {
 	Enabled   bool                  `json:"enabled"`
@@ -116,6 +202,10 @@ type NotificationDigest struct {
 	SentAt        *time.Time         `json:"sent_at,omitempty" db:"sent_at"`
 	CreatedAt     time.Time          `json:"created_at" db:"created_at"`
 	Status        DigestStatus       `json:"status" db:"status"`
+	// ScheduledFor is the slot a scheduled daily or weekly digest was sent
+	// for; at most one digest is sent per user, type and slot
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty" db:"scheduled_for"`
+	Error        string     `json:"error,omitempty" db:"error"`
 }
 
 type DigestStatus string
@@ -151,11 +241,282 @@ type NotificationItem struct {
 	IsDelivered bool       `json:"is_delivered" db:"is_delivered"`
 	DeliveredAt *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
 	DigestID    *uuid.UUID `json:"digest_id,omitempty" db:"digest_id"`
+	// DigestFrequency is daily or weekly while the notification waits for
+	// a scheduled digest
+	DigestFrequency NotificationFrequency `json:"digest_frequency,omitempty" db:"digest_frequency"`
+	// ArchivedAt is set while the notification is archived, out of the inbox
+	ArchivedAt *time.Time `json:"archived_at,omitempty" db:"archived_at"`
 
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
 }
 
+// NotificationCategory groups events for unread counts and inbox tabs
+type NotificationCategory string
+
+const (
+	CategoryComments      NotificationCategory = "comments"
+	CategoryKudos         NotificationCategory = "kudos"
+	CategorySubscriptions NotificationCategory = "subscriptions"
+	CategoryOther         NotificationCategory = "other"
+)
+
+// NotificationCategories lists every category, "other" last
+var NotificationCategories = []NotificationCategory{CategoryComments, CategoryKudos, CategorySubscriptions, CategoryOther}
+
+// categoryEvents are the events in each category but "other", which holds
+// everything else
+var categoryEvents = map[NotificationCategory][]NotificationEvent{
+	CategoryComments:      {EventCommentReceived, EventCommentReplied, EventCommentMention},
+	CategoryKudos:         {EventKudosReceived, EventWorkMilestone},
+	CategorySubscriptions: {EventWorkUpdated, EventWorkCompleted, EventSeriesUpdated, EventNewWork, EventWorkRevealed, EventSavedSearchMatch},
+}
+
+// Category returns the category an event is counted under
+func (e NotificationEvent) Category() NotificationCategory {
+	for category, events := range categoryEvents {
+		for _, event := range events {
+			if event == e {
+				return category
+			}
+		}
+	}
+	return CategoryOther
+}
+
+// IsNotificationCategory reports whether category is a known category
+func IsNotificationCategory(category NotificationCategory) bool {
+	for _, c := range NotificationCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// CategoryEvents returns the events in a category. For "other", which has
+// no fixed list, it returns the events in every other category, and the
+// boolean is false to say they're the ones to exclude.
+func CategoryEvents(category NotificationCategory) ([]NotificationEvent, bool) {
+	if category != CategoryOther {
+		return categoryEvents[category], true
+	}
+	var excluded []NotificationEvent
+	for _, events := range categoryEvents {
+		excluded = append(excluded, events...)
+	}
+	return excluded, false
+}
+
+// UnreadCountsByCategory totals per-event unread counts into categories,
+// with every category present
+func UnreadCountsByCategory(byEvent map[NotificationEvent]int) map[NotificationCategory]int {
+	counts := make(map[NotificationCategory]int, len(NotificationCategories))
+	for _, category := range NotificationCategories {
+		counts[category] = 0
+	}
+	for event, count := range byEvent {
+		counts[event.Category()] += count
+	}
+	return counts
+}
+
+// NotificationReadFilter selects a subset of a user's notifications to mark
+// read or archive in one go. Empty fields match everything.
+type NotificationReadFilter struct {
+	IDs        []uuid.UUID
+	SourceID   *uuid.UUID
+	SourceType string
+	Events     []NotificationEvent
+	Category   NotificationCategory
+	// Before limits the filter to notifications created at or before it,
+	// so a client only marks what it has shown
+	Before *time.Time
+}
+
+// IsEmpty reports whether the filter would match every notification.
+func (f NotificationReadFilter) IsEmpty() bool {
+	return len(f.IDs) == 0 && f.SourceID == nil && f.SourceType == "" && len(f.Events) == 0 && f.Category == "" && f.Before == nil
+}
+
+// Matches reports whether a notification falls within the filter.
+func (f NotificationReadFilter) Matches(n *NotificationItem) bool {
+	if len(f.IDs) > 0 {
+		found := false
+		for _, id := range f.IDs {
+			if n.ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.SourceID != nil && n.SourceID != *f.SourceID {
+		return false
+	}
+	if f.SourceType != "" && n.SourceType != f.SourceType {
+		return false
+	}
+	if f.Category != "" && n.Event.Category() != f.Category {
+		return false
+	}
+	if f.Before != nil && n.CreatedAt.After(*f.Before) {
+		return false
+	}
+	if len(f.Events) == 0 {
+		return true
+	}
+	for _, event := range f.Events {
+		if n.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationInboxFilter selects the notifications listed in a user's
+// inbox, or with Archived set their archive. Empty fields match everything.
+type NotificationInboxFilter struct {
+	Events     []NotificationEvent
+	Category   NotificationCategory
+	UnreadOnly bool
+	// Since and Until bound the creation time, both inclusive
+	Since    *time.Time
+	Until    *time.Time
+	Archived bool
+}
+
+// Matches reports whether a notification falls within the filter.
+func (f NotificationInboxFilter) Matches(n *NotificationItem) bool {
+	if (n.ArchivedAt != nil) != f.Archived {
+		return false
+	}
+	if f.UnreadOnly && n.IsRead {
+		return false
+	}
+	if f.Category != "" && n.Event.Category() != f.Category {
+		return false
+	}
+	if f.Since != nil && n.CreatedAt.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && n.CreatedAt.After(*f.Until) {
+		return false
+	}
+	if len(f.Events) == 0 {
+		return true
+	}
+	for _, event := range f.Events {
+		if n.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupedNotificationEvents are collapsed in the inbox to one entry per
+// source, so a popular work's kudos read as "12 kudos on Work X"
+var GroupedNotificationEvents = []NotificationEvent{EventKudosReceived, EventBookmarkAdded}
+
+// IsGroupedEvent reports whether notifications for event are grouped
+func IsGroupedEvent(event NotificationEvent) bool {
+	for _, grouped := range GroupedNotificationEvents {
+		if grouped == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationGroupKey identifies the inbox group a notification belongs
+// to: its event and source for grouped events, otherwise just itself
+func NotificationGroupKey(n *NotificationItem) string {
+	if IsGroupedEvent(n.Event) && n.SourceID != uuid.Nil {
+		return string(n.Event) + ":" + n.SourceID.String()
+	}
+	return n.ID.String()
+}
+
+// NotificationGroup is one inbox entry when notifications are grouped.
+// Latest is the newest notification in the group; the group can be marked
+// read or archived with its event and source as the filter.
+type NotificationGroup struct {
+	Key         string            `json:"key"`
+	Event       NotificationEvent `json:"event"`
+	SourceID    uuid.UUID         `json:"source_id"`
+	SourceType  string            `json:"source_type"`
+	Summary     string            `json:"summary"`
+	Count       int               `json:"count"`
+	UnreadCount int               `json:"unread_count"`
+	Latest      *NotificationItem `json:"latest"`
+}
+
+// NewNotificationGroup builds a group around its newest notification
+func NewNotificationGroup(latest *NotificationItem, count, unread int) *NotificationGroup {
+	return &NotificationGroup{
+		Key:         NotificationGroupKey(latest),
+		Event:       latest.Event,
+		SourceID:    latest.SourceID,
+		SourceType:  latest.SourceType,
+		Summary:     notificationGroupSummary(latest, count),
+		Count:       count,
+		UnreadCount: unread,
+		Latest:      latest,
+	}
+}
+
+// notificationGroupSummary describes a group in one line, falling back to
+// the notification's own title for a group of one
+func notificationGroupSummary(latest *NotificationItem, count int) string {
+	if count <= 1 {
+		return latest.Title
+	}
+	workTitle, _ := latest.ExtraData["work_title"].(string)
+	if workTitle == "" {
+		workTitle = "your work"
+	}
+	switch latest.Event {
+	case EventKudosReceived:
+		return fmt.Sprintf("%d kudos on %s", count, workTitle)
+	case EventBookmarkAdded:
+		return fmt.Sprintf("%d bookmarks of %s", count, workTitle)
+	}
+	return fmt.Sprintf("%s and %d more", latest.Title, count-1)
+}
+
+// GroupNotifications groups notifications listed newest first, keeping
+// the groups in the order of their newest notification
+func GroupNotifications(notifications []*NotificationItem) []*NotificationGroup {
+	type tally struct {
+		latest        *NotificationItem
+		count, unread int
+	}
+	var order []string
+	tallies := make(map[string]*tally)
+	for _, n := range notifications {
+		key := NotificationGroupKey(n)
+		t, ok := tallies[key]
+		if !ok {
+			t = &tally{latest: n}
+			tallies[key] = t
+			order = append(order, key)
+		}
+		t.count++
+		if !n.IsRead {
+			t.unread++
+		}
+	}
+
+	groups := make([]*NotificationGroup, len(order))
+	for i, key := range order {
+		t := tallies[key]
+		groups[i] = NewNotificationGroup(t.latest, t.count, t.unread)
+	}
+	return groups
+}
+
 // NotificationRule defines smart filtering rules for notifications
 type NotificationRule struct {
 	ID          uuid.UUID `json:"id" db:"id"`
@@ -199,6 +560,10 @@ type SmartNotificationFilter struct {
 	ActiveRules         []NotificationRule      `json:"active_rules"`
 }
 
+// DefaultDigestSectionLimit is how many items a digest section shows before
+// collapsing the rest into an "and N more" link
+const DefaultDigestSectionLimit = 5
+
 // DefaultNotificationPreferences returns default notification preferences for a new user
 func DefaultNotificationPreferences(userID uuid.UUID) NotificationPreferences {
 	return NotificationPreferences{
@@ -213,12 +578,26 @@ func DefaultNotificationPreferences(userID uuid.UUID) NotificationPreferences {
 				Frequency: FrequencyImmediate,
 				Priority:  PriorityMedium,
 			},
+			// Only sent through author and tag subscriptions, whose own
+			// frequency decides when they're delivered
+			EventNewWork: {
+				Enabled:   true,
+				Channels:  []DeliveryChannel{ChannelEmail, ChannelInApp},
+				Frequency: FrequencyImmediate,
+				Priority:  PriorityLow,
+			},
 			EventCommentReceived: {
 				Enabled:   true,
 				Channels:  []DeliveryChannel{ChannelEmail, ChannelInApp},
 				Frequency: FrequencyImmediate,
 				Priority:  PriorityHigh,
 			},
+			EventCommentMention: {
+				Enabled:   true,
+				Channels:  []DeliveryChannel{ChannelEmail, ChannelInApp},
+				Frequency: FrequencyImmediate,
+				Priority:  PriorityMedium,
+			},
 			EventKudosReceived: {
 				Enabled:   true,
 				Channels:  []DeliveryChannel{ChannelInApp},
@@ -231,12 +610,63 @@ func DefaultNotificationPreferences(userID uuid.UUID) NotificationPreferences {
 				Frequency: FrequencyImmediate,
 				Priority:  PriorityHigh,
 			},
+			EventWorkUnpublished: {
+				Enabled:   true,
+				Channels:  []DeliveryChannel{ChannelEmail, ChannelInApp},
+				Frequency: FrequencyImmediate,
+				Priority:  PriorityMedium,
+			},
+			EventWorkRevealed: {
+				Enabled:   true,
+				Channels:  []DeliveryChannel{ChannelEmail, ChannelInApp},
+				Frequency: FrequencyImmediate,
+				Priority:  PriorityMedium,
+			},
+			EventCollectionItemReviewed: {
+				Enabled:   true,
+				Channels:  []DeliveryChannel{ChannelInApp},
+				Frequency: FrequencyImmediate,
+				Priority:  PriorityMedium,
+			},
+			EventTagReportReviewed: {
+				Enabled:   true,
+				Channels:  []DeliveryChannel{ChannelInApp},
+				Frequency: FrequencyImmediate,
+				Priority:  PriorityLow,
+			},
+			EventAbuseReportUpdated: {
+				Enabled:   true,
+				Channels:  []DeliveryChannel{ChannelEmail, ChannelInApp},
+				Frequency: FrequencyImmediate,
+				Priority:  PriorityMedium,
+			},
+			// Sent as a daily digest by default, so the author of a popular
+			// work hears about its milestones once a day at most
+			EventWorkMilestone: {
+				Enabled:   true,
+				Channels:  []DeliveryChannel{ChannelEmail, ChannelInApp},
+				Frequency: FrequencyDaily,
+				Priority:  PriorityLow,
+			},
+			EventSavedSearchMatch: {
+				Enabled:   true,
+				Channels:  []DeliveryChannel{ChannelEmail, ChannelInApp},
+				Frequency: FrequencyImmediate,
+				Priority:  PriorityLow,
+			},
+			EventCollectionInvite: {
+				Enabled:   true,
+				Channels:  []DeliveryChannel{ChannelEmail, ChannelInApp},
+				Frequency: FrequencyImmediate,
+				Priority:  PriorityMedium,
+			},
 		},
 		EnableBatching:          true,
 		BatchFrequency:          FrequencyDaily,
 		MaxNotificationsPerHour: 10,
 		MinTimeBetweenSimilar:   time.Hour,
 		Timezone:                "UTC",
+		DigestSectionLimit:      DefaultDigestSectionLimit,
 		CreatedAt:               time.Now(),
 		UpdatedAt:               time.Now(),
 	}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEstimateReadingMinutes(t *testing.T) {
+	cases := []struct {
+		words    int
+		language string
+		want     int
+	}{
+		{0, "en", 0},
+		{1, "en", 1},
+		{2300, "en", 10},
+		{2301, "en", 11},
+		{2300, "de", 13},    // 2300 / (230 * 0.79)
+		{2300, "pt-BR", 13}, // region suffix ignored
+		{2300, "tlh", 10},   // unlisted languages read at the base speed
+	}
+	for _, tc := range cases {
+		if got := EstimateReadingMinutes(tc.words, tc.language); got != tc.want {
+			t.Errorf("EstimateReadingMinutes(%d, %q) = %d, want %d", tc.words, tc.language, got, tc.want)
+		}
+	}
+}
+
+func TestReadingWordsPerMinuteOverride(t *testing.T) {
+	t.Setenv("READING_WORDS_PER_MINUTE", "100")
+	if got := EstimateReadingMinutes(1000, "en"); got != 10 {
+		t.Errorf("with 100 wpm, 1000 words = %d minutes, want 10", got)
+	}
+
+	t.Setenv("READING_WORDS_PER_MINUTE", "fast")
+	if got := ReadingWordsPerMinute(); got != DefaultWordsPerMinute {
+		t.Errorf("invalid override should fall back to %d, got %v", DefaultWordsPerMinute, got)
+	}
+}
+
+func TestChapterJSONIncludesReadingTime(t *testing.T) {
+	data, err := json.Marshal(Chapter{WordCount: 4600, Language: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+
+	if decoded["estimated_reading_minutes"] != float64(20) {
+		t.Errorf("estimated_reading_minutes = %v, want 20", decoded["estimated_reading_minutes"])
+	}
+	if _, ok := decoded["Language"]; ok {
+		t.Error("the chapter's language is internal and shouldn't be serialized")
+	}
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Required tag types, in the order every client renders them
+const (
+	RequiredTagRating     = "rating"
+	RequiredTagWarning    = "warning"
+	RequiredTagCategory   = "category"
+	RequiredTagCompletion = "completion"
+)
+
+// RequiredTag is one entry of a work's required tags: the rating, archive
+// warnings, categories and completion status AO3 shows in the corner square
+// of every blurb. Key is a stable identifier for styling; Label is the
+// canonical display text.
+type RequiredTag struct {
+	Type  string `json:"type"`
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+type requiredTagValue struct {
+	key, label string
+	aliases    []string
+}
+
+// The canonical values of each required tag type, in display order
+var (
+	requiredRatings = []requiredTagValue{
+		{"not_rated", "Not Rated", []string{"not rated", "notrated", "unrated"}},
+		{"general", "General Audiences", []string{"general audiences"}},
+		{"teen", "Teen And Up Audiences", []string{"teen and up audiences", "teen and up"}},
+		{"mature", "Mature", nil},
+		{"explicit", "Explicit", nil},
+	}
+	requiredWarnings = []requiredTagValue{
+		{"choose_not_to_warn", "Creator Chose Not To Use Archive Warnings", []string{"creator chose not to use archive warnings", "choose not to use archive warnings", "choose not to warn"}},
+		{"none", "No Archive Warnings Apply", []string{"no archive warnings apply", "no warnings", "no_warnings"}},
+		{"violence", "Graphic Depictions Of Violence", []string{"graphic depictions of violence"}},
+		{"major_character_death", "Major Character Death", []string{"major character death", "mcd"}},
+		{"rape_noncon", "Rape/Non-Con", []string{"rape/non-con", "noncon", "non-con"}},
+		{"underage", "Underage Sex", []string{"underage sex"}},
+	}
+	requiredCategories = []requiredTagValue{
+		{"f_f", "F/F", []string{"f/f", "ff"}},
+		{"f_m", "F/M", []string{"f/m", "fm", "m/f"}},
+		{"gen", "Gen", nil},
+		{"m_m", "M/M", []string{"m/m", "mm"}},
+		{"multi", "Multi", nil},
+		{"other", "Other", nil},
+	}
+)
+
+// The category and completion entries that aren't looked up by value
+var (
+	requiredTagNoCategory = RequiredTag{Type: RequiredTagCategory, Key: "none", Label: "No Category"}
+	requiredTagComplete   = RequiredTag{Type: RequiredTagCompletion, Key: "complete", Label: "Complete Work"}
+	requiredTagInProgress = RequiredTag{Type: RequiredTagCompletion, Key: "in_progress", Label: "Work in Progress"}
+)
+
+// matchRequiredTag finds value among values by key, label or alias,
+// ignoring case and surrounding space
+func matchRequiredTag(values []requiredTagValue, value string) (int, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for i, v := range values {
+		if value == v.key || value == strings.ToLower(v.label) {
+			return i, true
+		}
+		for _, alias := range v.aliases {
+			if value == alias {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// RatingKey is the canonical key (general, teen, mature, explicit,
+// not_rated) of a rating however it is spelled; unrecognised ratings read
+// as not_rated
+func RatingKey(rating string) string {
+	key, _ := LookupRatingKey(rating)
+	return key
+}
+
+// LookupRatingKey is RatingKey that also reports whether the rating was
+// recognised
+func LookupRatingKey(rating string) (string, bool) {
+	i, ok := matchRequiredTag(requiredRatings, rating)
+	return requiredRatings[i].key, ok
+}
+
+// RatingSpellings lists, lowercased, every spelling a stored rating with
+// the given key may have, for matching works.rating in SQL
+func RatingSpellings(key string) []string {
+	for _, r := range requiredRatings {
+		if r.key == key {
+			spellings := []string{}
+			seen := map[string]bool{}
+			for _, s := range append([]string{r.key, strings.ToLower(r.label)}, r.aliases...) {
+				if !seen[s] {
+					seen[s] = true
+					spellings = append(spellings, s)
+				}
+			}
+			return spellings
+		}
+	}
+	return nil
+}
+
+// BuildRequiredTags returns the required tags for a work in their fixed
+// order: rating, then warnings, then categories, then completion. Values the
+// archive doesn't recognise are dropped, an unrecognised or missing rating
+// reads as Not Rated, and a work without warnings reads as the creator
+// choosing not to warn, since that is the safe assumption for readers.
+func BuildRequiredTags(rating string, warnings, categories []string, complete bool) []RequiredTag {
+	tags := make([]RequiredTag, 0, 2+len(warnings)+len(categories))
+
+	ratingIndex, _ := matchRequiredTag(requiredRatings, rating)
+	r := requiredRatings[ratingIndex]
+	tags = append(tags, RequiredTag{Type: RequiredTagRating, Key: r.key, Label: r.label})
+
+	warningTags := orderedRequiredTags(RequiredTagWarning, requiredWarnings, warnings)
+	if len(warningTags) == 0 {
+		w := requiredWarnings[0]
+		warningTags = []RequiredTag{{Type: RequiredTagWarning, Key: w.key, Label: w.label}}
+	}
+	tags = append(tags, warningTags...)
+
+	categoryTags := orderedRequiredTags(RequiredTagCategory, requiredCategories, categories)
+	if len(categoryTags) == 0 {
+		categoryTags = []RequiredTag{requiredTagNoCategory}
+	}
+	tags = append(tags, categoryTags...)
+
+	if complete {
+		tags = append(tags, requiredTagComplete)
+	} else {
+		tags = append(tags, requiredTagInProgress)
+	}
+	return tags
+}
+
+// orderedRequiredTags maps values onto the canonical list, deduplicated and
+// in canonical order whatever order they were given in
+func orderedRequiredTags(tagType string, canonical []requiredTagValue, values []string) []RequiredTag {
+	seen := make(map[int]bool)
+	indexes := []int{}
+	for _, value := range values {
+		if i, ok := matchRequiredTag(canonical, value); ok && !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+
+	tags := make([]RequiredTag, len(indexes))
+	for n, i := range indexes {
+		tags[n] = RequiredTag{Type: tagType, Key: canonical[i].key, Label: canonical[i].label}
+	}
+	return tags
+}
+
+// HiddenRequiredTags stands in for a work in an unrevealed collection: one
+// entry of each type, so clients can draw the square without giving away
+// anything about the work
+func HiddenRequiredTags() []RequiredTag {
+	types := []string{RequiredTagRating, RequiredTagWarning, RequiredTagCategory, RequiredTagCompletion}
+	tags := make([]RequiredTag, len(types))
+	for i, t := range types {
+		tags[i] = RequiredTag{Type: t, Key: "hidden", Label: "Mystery Work"}
+	}
+	return tags
+}
+
+// RequiredTags returns the work's required tags. Works in an unrevealed
+// collection get the hidden placeholders; anonymous works show theirs as
+// usual, since anonymity only hides the creator.
+func (w Work) RequiredTags() []RequiredTag {
+	if w.InUnrevealedCollection {
+		return HiddenRequiredTags()
+	}
+	return BuildRequiredTags(w.Rating, w.Warnings, w.Category, w.IsComplete)
+}
+
+// MarshalJSON adds the computed required_tags and reading time to every
+// serialized work, so no handler can return a work without them
+func (w Work) MarshalJSON() ([]byte, error) {
+	type work Work
+	return json.Marshal(struct {
+		work
+		RequiredTags            []RequiredTag `json:"required_tags"`
+		EstimatedReadingMinutes int           `json:"estimated_reading_minutes"`
+	}{work(w), w.RequiredTags(), w.EstimatedReadingMinutes()})
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func requiredTagKeys(tags []RequiredTag) []string {
+	keys := make([]string, len(tags))
+	for i, t := range tags {
+		keys[i] = t.Type + ":" + t.Key
+	}
+	return keys
+}
+
+func TestBuildRequiredTagsOrder(t *testing.T) {
+	tags := BuildRequiredTags("Teen And Up Audiences",
+		[]string{"Major Character Death", "graphic depictions of violence", "Major Character Death"},
+		[]string{"M/M", "F/F"}, true)
+
+	want := []string{
+		"rating:teen",
+		"warning:violence", "warning:major_character_death",
+		"category:f_f", "category:m_m",
+		"completion:complete",
+	}
+	if got := requiredTagKeys(tags); !reflect.DeepEqual(got, want) {
+		t.Errorf("required tags = %v, want %v", got, want)
+	}
+	if tags[0].Label != "Teen And Up Audiences" {
+		t.Errorf("rating label = %q", tags[0].Label)
+	}
+}
+
+func TestBuildRequiredTagsDefaults(t *testing.T) {
+	tags := BuildRequiredTags("", nil, []string{"Crack"}, false)
+
+	want := []string{"rating:not_rated", "warning:choose_not_to_warn", "category:none", "completion:in_progress"}
+	if got := requiredTagKeys(tags); !reflect.DeepEqual(got, want) {
+		t.Errorf("required tags = %v, want %v", got, want)
+	}
+}
+
+func TestWorkRequiredTagsHiddenWhenUnrevealed(t *testing.T) {
+	work := Work{Rating: "explicit", Warnings: []string{"Underage Sex"}, InUnrevealedCollection: true}
+
+	for _, tag := range work.RequiredTags() {
+		if tag.Key != "hidden" {
+			t.Errorf("unrevealed work leaked %s:%s", tag.Type, tag.Key)
+		}
+	}
+
+	work.InUnrevealedCollection = false
+	work.IsAnonymous = true
+	if got := work.RequiredTags()[0].Key; got != "explicit" {
+		t.Errorf("anonymous work rating = %q, want explicit", got)
+	}
+}
+
+func TestWorkJSONIncludesRequiredTags(t *testing.T) {
+	data, err := json.Marshal(&Work{Title: "A Work", Rating: "general", IsComplete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Title        string        `json:"title"`
+		RequiredTags []RequiredTag `json:"required_tags"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Title != "A Work" {
+		t.Errorf("title = %q, want the work's fields alongside required_tags", decoded.Title)
+	}
+	if len(decoded.RequiredTags) != 4 || decoded.RequiredTags[0].Key != "general" {
+		t.Errorf("required_tags = %+v", decoded.RequiredTags)
+	}
+
+	// Works still decode from JSON that carries the computed field
+	var work Work
+	if err := json.Unmarshal(data, &work); err != nil {
+		t.Fatal(err)
+	}
+	if work.Title != "A Work" || !work.IsComplete {
+		t.Errorf("round trip lost fields: %+v", work)
+	}
+}
+
+func TestRatingKey(t *testing.T) {
+	if got := RatingKey("Teen And Up Audiences"); got != "teen" {
+		t.Errorf("RatingKey(label) = %q, want teen", got)
+	}
+	if _, ok := LookupRatingKey("Spicy"); ok {
+		t.Error("an unknown rating should not be recognised")
+	}
+	if got := RatingKey("Spicy"); got != "not_rated" {
+		t.Errorf("unknown ratings should read as not_rated, got %q", got)
+	}
+
+	want := []string{"teen", "teen and up audiences", "teen and up"}
+	if got := RatingSpellings("teen"); !reflect.DeepEqual(got, want) {
+		t.Errorf("RatingSpellings(teen) = %v, want %v", got, want)
+	}
+}
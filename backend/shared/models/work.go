@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,7 +16,6 @@ type Work struct {
 	Notes                  string     `json:"notes" db:"notes"`
 	UserID                 uuid.UUID  `json:"user_id" db:"user_id"`
 	Username               string     `json:"username"` // Loaded from join
-	SeriesID               *uuid.UUID `json:"series_id" db:"series_id"`
 	Language               string     `json:"language" db:"language" validate:"required,len=2"`
 	Rating                 string     `json:"rating" db:"rating" validate:"required,oneof=general teen mature explicit"`
 	Category               []string   `json:"category" db:"category"`           // JSON array
@@ -28,7 +28,7 @@ type Work struct {
 	ChapterCount           int        `json:"chapter_count" db:"chapter_count"`
 	MaxChapters            *int       `json:"max_chapters" db:"max_chapters"` // nil if unknown
 	IsComplete             bool       `json:"is_complete" db:"is_complete"`
-	Status                 string     `json:"status" db:"status" validate:"oneof=draft posted hidden"`
+	Status                 string     `json:"status" db:"status" validate:"oneof=draft posted hidden unlisted"`
 	RestrictedToUsers      bool       `json:"restricted_to_users" db:"restricted_to_users"`
 	RestrictedToAdults     bool       `json:"restricted_to_adults" db:"restricted_to_adults"`
 	CommentPolicy          string     `json:"comment_policy" db:"comment_policy" validate:"oneof=open users_only disabled"`
@@ -38,8 +38,10 @@ type Work struct {
 	InUnrevealedCollection bool       `json:"in_unrevealed_collection" db:"in_unrevealed_collection"`
 	IsAnonymous            bool       `json:"is_anonymous" db:"is_anonymous"`
 	PublishedAt            *time.Time `json:"published_at" db:"published_at"`
+	UnpublishAt            *time.Time `json:"unpublish_at,omitempty" db:"unpublish_at"` // Scheduled hiding, nil if none
 	UpdatedAt              time.Time  `json:"updated_at" db:"updated_at"`
 	CreatedAt              time.Time  `json:"created_at" db:"created_at"`
+	Version                int        `json:"version" db:"version"` // Bumped by every author edit
 	// Statistics (loaded separately)
 	Hits        int `json:"hits"`
 	Kudos       int `json:"kudos"`
@@ -59,10 +61,12 @@ type Chapter struct {
 	EndNotes    string     `json:"end_notes" db:"end_notes"`
 	Content     string     `json:"content" db:"content" validate:"required"`
 	WordCount   int        `json:"word_count" db:"word_count"`
+	Language    string     `json:"-" db:"-"` // The work's language, for reading time
 	Status      string     `json:"status" db:"status" validate:"oneof=draft posted"`
 	PublishedAt *time.Time `json:"published_at" db:"published_at"`
 	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	Version     int        `json:"version" db:"version"` // Bumped by every author edit
 }
 
 // Series represents a collection of related works
@@ -80,6 +84,60 @@ type Series struct {
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
 
+// SeriesNavigation places a work within one of its series, with the
+// neighbouring posted works for prev/next links
+type SeriesNavigation struct {
+	SeriesID   uuid.UUID  `json:"series_id"`
+	Title      string     `json:"title"`
+	Position   int        `json:"position"`
+	WorkCount  int        `json:"work_count"`
+	IsComplete bool       `json:"is_complete"`
+	PrevWorkID *uuid.UUID `json:"prev_work_id"`
+	NextWorkID *uuid.UUID `json:"next_work_id"`
+}
+
+// Related work relations
+const (
+	RelationTranslation = "translation"
+	RelationRemix       = "remix"
+	RelationInspiredBy  = "inspired_by"
+)
+
+// RelatedWork links a derived work to the work it translates, remixes or
+// was inspired by. The parent is either a local work or an external URL.
+type RelatedWork struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	WorkID         uuid.UUID  `json:"work_id" db:"work_id"`
+	WorkTitle      string     `json:"work_title,omitempty"`  // Loaded from join
+	WorkAuthor     string     `json:"work_author,omitempty"` // Loaded from join
+	ParentWorkID   *uuid.UUID `json:"parent_work_id,omitempty" db:"parent_work_id"`
+	ParentURL      string     `json:"parent_url,omitempty" db:"parent_url"`
+	ParentTitle    string     `json:"parent_title" db:"parent_title"`
+	ParentAuthor   string     `json:"parent_author,omitempty" db:"parent_author"`
+	ParentLanguage string     `json:"parent_language,omitempty" db:"parent_language"`
+	Relation       string     `json:"relation" db:"relation"`
+	Status         string     `json:"status" db:"status"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	ReviewedAt     *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+}
+
+// RelatedWorks groups a work's approved relations: the works it derives
+// from and the works derived from it
+type RelatedWorks struct {
+	Parents  []RelatedWork `json:"parents"`
+	Children []RelatedWork `json:"children"`
+}
+
+// RelatedWorkInput declares a parent work when posting or editing a work
+type RelatedWorkInput struct {
+	ParentWorkID *uuid.UUID `json:"parent_work_id"`
+	URL          string     `json:"url"`
+	Title        string     `json:"title"`
+	Author       string     `json:"author"`
+	Language     string     `json:"language"`
+	Relation     string     `json:"relation" binding:"required"`
+}
+
 // WorkStatistics tracks engagement metrics for a work
 type WorkStatistics struct {
 	WorkID      uuid.UUID `json:"work_id" db:"work_id"`
@@ -102,22 +160,25 @@ type WorkKudos struct {
 
 // WorkComment represents a comment on a work or chapter
 type WorkComment struct {
-	ID               uuid.UUID  `json:"id" db:"id"`
-	WorkID           uuid.UUID  `json:"work_id" db:"work_id"`
-	ChapterID        *uuid.UUID `json:"chapter_id" db:"chapter_id"`       // Nil for work-level comments
-	UserID           *uuid.UUID `json:"user_id" db:"user_id"`             // Nil for anonymous comments
-	Username         string     `json:"username"`                         // Display name for comment
-	ParentID         *uuid.UUID `json:"parent_id" db:"parent_comment_id"` // For threaded comments
-	Content          string     `json:"content" db:"content" validate:"required,max=10000"`
-	Status           string     `json:"status" db:"status" validate:"oneof=published pending deleted spam hidden"`
-	ModerationReason string     `json:"moderation_reason" db:"moderation_reason"`
-	ModeratedBy      *uuid.UUID `json:"moderated_by" db:"moderated_by"`
-	ModeratedAt      *time.Time `json:"moderated_at" db:"moderated_at"`
-	IsAnonymous      bool       `json:"is_anonymous" db:"is_anonymous"`
-	IPAddress        string     `json:"ip_address" db:"ip_address"`
-	IsDeleted        bool       `json:"is_deleted" db:"is_deleted"`
-	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+	ID               uuid.UUID        `json:"id" db:"id"`
+	WorkID           uuid.UUID        `json:"work_id" db:"work_id"`
+	ChapterID        *uuid.UUID       `json:"chapter_id" db:"chapter_id"`       // Nil for work-level comments
+	UserID           *uuid.UUID       `json:"user_id" db:"user_id"`             // Nil for anonymous comments
+	Username         string           `json:"username"`                         // Display name for comment
+	ParentID         *uuid.UUID       `json:"parent_id" db:"parent_comment_id"` // For threaded comments
+	Content          string           `json:"content" db:"content" validate:"required,max=10000"`
+	Status           string           `json:"status" db:"status" validate:"oneof=published pending deleted spam hidden"`
+	ModerationReason string           `json:"moderation_reason" db:"moderation_reason"`
+	ModeratedBy      *uuid.UUID       `json:"moderated_by" db:"moderated_by"`
+	ModeratedAt      *time.Time       `json:"moderated_at" db:"moderated_at"`
+	IsAnonymous      bool             `json:"is_anonymous" db:"is_anonymous"`
+	IPAddress        string           `json:"ip_address" db:"ip_address"`
+	IsDeleted        bool             `json:"is_deleted" db:"is_deleted"`
+	IsFrozen         bool             `json:"is_frozen" db:"is_frozen"` // Frozen threads take no new replies
+	Anchor           string           `json:"anchor,omitempty"`         // Stable permalink fragment, e.g. comment_<id>
+	Mentions         []CommentMention `json:"mentions,omitempty"`
+	CreatedAt        time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at" db:"updated_at"`
 }
 
 // UserMute represents a user muting another user (matching AO3's implementation)
@@ -178,25 +239,85 @@ type Bookmark struct {
 	UserID    uuid.UUID `json:"user_id" db:"user_id"`
 	WorkID    uuid.UUID `json:"work_id" db:"work_id"`
 	IsPrivate bool      `json:"is_private" db:"is_private"`
+	IsRec     bool      `json:"is_rec" db:"is_rec"` // Recommended, not just saved
 	Notes     string    `json:"notes" db:"notes"`
 	Tags      []string  `json:"tags" db:"tags"` // User's own tags for the bookmark
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// PublicBookmark is a public bookmark as listed on its work, with who made it
+type PublicBookmark struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Username  string    `json:"username" db:"username"`
+	Notes     string    `json:"notes" db:"notes"`
+	Tags      []string  `json:"tags" db:"tags"`
+	IsRec     bool      `json:"is_rec" db:"is_rec"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BookmarkList is a named public list a user groups their bookmarks into
+type BookmarkList struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	UserID        uuid.UUID `json:"user_id" db:"user_id"`
+	Name          string    `json:"name" db:"name"`
+	Description   string    `json:"description" db:"description"`
+	BookmarkCount int       `json:"bookmark_count"` // Public bookmarks, loaded from join
+	RecCount      int       `json:"rec_count"`      // Public recs, loaded from join
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// MarkedForLater is a work a reader has saved to read later
+type MarkedForLater struct {
+	WorkID    uuid.UUID `json:"work_id" db:"work_id"`
+	Title     string    `json:"title" db:"title"`
+	Authors   string    `json:"authors,omitempty"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ReadingHistoryEntry is a work in a reader's history
+type ReadingHistoryEntry struct {
+	WorkID      uuid.UUID `json:"work_id" db:"work_id"`
+	Title       string    `json:"title" db:"title"`
+	Authors     string    `json:"authors,omitempty"`
+	FirstReadAt time.Time `json:"first_read_at" db:"first_read_at"`
+	LastReadAt  time.Time `json:"last_read_at" db:"last_read_at"`
+	Visits      int       `json:"visits" db:"visits"`
+}
+
+// ExternalBookmark is a bookmark of a work hosted on another site
+type ExternalBookmark struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	URL       string    `json:"url" db:"url"`
+	Title     string    `json:"title" db:"title"`
+	Notes     string    `json:"notes" db:"notes"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
 // Collection represents a themed collection of works
 type Collection struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name" validate:"required,min=1,max=100"`
-	Title       string    `json:"title" db:"title" validate:"required,min=1,max=200"`
-	Description string    `json:"description" db:"description"`
-	UserID      uuid.UUID `json:"user_id" db:"user_id"` // Collection maintainer
-	IsOpen      bool      `json:"is_open" db:"is_open"` // Can anyone add works?
-	IsModerated bool      `json:"is_moderated" db:"is_moderated"`
-	IsAnonymous bool      `json:"is_anonymous" db:"is_anonymous"`
-	WorkCount   int       `json:"work_count" db:"work_count"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID           uuid.UUID `json:"id" db:"id"`
+	Name         string    `json:"name" db:"name" validate:"required,min=1,max=100"`
+	Title        string    `json:"title" db:"title" validate:"required,min=1,max=200"`
+	Description  string    `json:"description" db:"description"`
+	UserID       uuid.UUID `json:"user_id" db:"user_id"` // Collection maintainer
+	IsOpen       bool      `json:"is_open" db:"is_open"` // Can anyone add works?
+	IsModerated  bool      `json:"is_moderated" db:"is_moderated"`
+	IsAnonymous  bool      `json:"is_anonymous" db:"is_anonymous"`   // Creators hidden until authors are revealed
+	IsUnrevealed bool      `json:"is_unrevealed" db:"is_unrevealed"` // Works hidden until works are revealed
+	WorkCount    int       `json:"work_count" db:"work_count"`
+	// Reveal schedule; the *RevealedAt times are set once a reveal has happened
+	RevealWorksAt     *time.Time `json:"reveal_works_at,omitempty" db:"reveal_works_at"`
+	RevealAuthorsAt   *time.Time `json:"reveal_authors_at,omitempty" db:"reveal_authors_at"`
+	WorksRevealedAt   *time.Time `json:"works_revealed_at,omitempty" db:"works_revealed_at"`
+	AuthorsRevealedAt *time.Time `json:"authors_revealed_at,omitempty" db:"authors_revealed_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 // CollectionItem represents a work in a collection
@@ -208,23 +329,67 @@ type CollectionItem struct {
 	IsApproved   bool       `json:"is_approved" db:"is_approved"`
 	AddedAt      time.Time  `json:"added_at" db:"added_at"`
 	ApprovedAt   *time.Time `json:"approved_at" db:"approved_at"`
+	// Set when a maintainer turns the item down
+	RejectedAt       *time.Time `json:"rejected_at,omitempty" db:"rejected_at"`
+	RejectionMessage *string    `json:"rejection_message,omitempty" db:"rejection_message"`
+}
+
+// Collection maintainer roles
+const (
+	CollectionRoleOwner     = "owner"
+	CollectionRoleModerator = "moderator"
+)
+
+// CollectionMaintainer is a user who helps run a collection
+type CollectionMaintainer struct {
+	CollectionID uuid.UUID  `json:"collection_id" db:"collection_id"`
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	Username     string     `json:"username" db:"username"`
+	Role         string     `json:"role" db:"role"`
+	AddedBy      *uuid.UUID `json:"added_by,omitempty" db:"added_by"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Collection invitation statuses
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusDeclined = "declined"
+)
+
+// CollectionInvitation is a maintainer's request for a work to join a collection
+type CollectionInvitation struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	CollectionID uuid.UUID  `json:"collection_id" db:"collection_id"`
+	WorkID       uuid.UUID  `json:"work_id" db:"work_id"`
+	InvitedBy    *uuid.UUID `json:"invited_by,omitempty" db:"invited_by"`
+	Message      *string    `json:"message,omitempty" db:"message"`
+	Status       string     `json:"status" db:"status"`
+	RespondedAt  *time.Time `json:"responded_at,omitempty" db:"responded_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	// Loaded from joins
+	CollectionTitle string `json:"collection_title,omitempty"`
+	WorkTitle       string `json:"work_title,omitempty"`
 }
 
 // CreateWorkRequest represents the request to create a new work
 type CreateWorkRequest struct {
-	Title         string     `json:"title" validate:"required,min=1,max=200"`
-	Summary       string     `json:"summary"`
-	Notes         string     `json:"notes"`
-	SeriesID      *uuid.UUID `json:"series_id"`
-	Language      string     `json:"language" validate:"required,len=2"`
-	Rating        string     `json:"rating" validate:"required,oneof=general teen mature explicit"`
-	Category      []string   `json:"category"`
-	Warnings      []string   `json:"warnings"`
-	Fandoms       []string   `json:"fandoms" validate:"required,min=1"`
-	Characters    []string   `json:"characters"`
-	Relationships []string   `json:"relationships"`
-	FreeformTags  []string   `json:"freeform_tags"`
-	MaxChapters   *int       `json:"max_chapters"`
+	Title         string             `json:"title" validate:"required,min=1,max=200"`
+	Summary       string             `json:"summary"`
+	Notes         string             `json:"notes"`
+	SeriesID      *uuid.UUID         `json:"series_id"`
+	Language      string             `json:"language" validate:"required,len=2"`
+	Rating        string             `json:"rating" validate:"required,oneof=general teen mature explicit"`
+	Category      []string           `json:"category"`
+	Warnings      []string           `json:"warnings"`
+	Fandoms       []string           `json:"fandoms" validate:"required,min=1"`
+	Characters    []string           `json:"characters"`
+	Relationships []string           `json:"relationships"`
+	FreeformTags  []string           `json:"freeform_tags"`
+	MaxChapters   *int               `json:"max_chapters"`
+	PromptIDs     []uuid.UUID        `json:"prompt_ids"`    // Prompt meme prompts this work fills
+	RelatedWorks  []RelatedWorkInput `json:"related_works"` // Works this one translates, remixes or was inspired by
+	UnpublishAt   *time.Time         `json:"unpublish_at"`  // Hide the work again at this time
 	// First chapter data
 	ChapterTitle    string `json:"chapter_title"`
 	ChapterSummary  string `json:"chapter_summary"`
@@ -248,7 +413,7 @@ type UpdateWorkRequest struct {
 	FreeformTags           []string   `json:"freeform_tags,omitempty"`
 	MaxChapters            *int       `json:"max_chapters,omitempty"`
 	IsComplete             *bool      `json:"is_complete,omitempty"`
-	Status                 *string    `json:"status,omitempty" validate:"omitempty,oneof=draft posted hidden"`
+	Status                 *string    `json:"status,omitempty" validate:"omitempty,oneof=draft posted hidden unlisted"`
 	RestrictedToUsers      *bool      `json:"restricted_to_users,omitempty"`
 	RestrictedToAdults     *bool      `json:"restricted_to_adults,omitempty"`
 	CommentPolicy          *string    `json:"comment_policy,omitempty" validate:"omitempty,oneof=open users_only disabled"`
@@ -257,6 +422,23 @@ type UpdateWorkRequest struct {
 	IsAnonymous            *bool      `json:"is_anonymous,omitempty"`
 	InAnonCollection       *bool      `json:"in_anon_collection,omitempty"`
 	InUnrevealedCollection *bool      `json:"in_unrevealed_collection,omitempty"`
+	UnpublishAt            *time.Time `json:"unpublish_at,omitempty"`
+	ClearUnpublishAt       bool       `json:"clear_unpublish_at,omitempty"` // Cancel a scheduled unpublish
+	Version                *int       `json:"version,omitempty"`            // Version being edited, if not sent as If-Match
+}
+
+// WorkMetadataChange is one field changed by an edit of a work, with its
+// value before and after. Fields changed by the same edit share ChangeSetID.
+type WorkMetadataChange struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	WorkID      uuid.UUID       `json:"work_id" db:"work_id"`
+	ChangeSetID uuid.UUID       `json:"change_set_id" db:"change_set_id"`
+	ActorID     *uuid.UUID      `json:"actor_id,omitempty" db:"actor_id"`
+	ActorName   string          `json:"actor_name,omitempty" db:"actor_name"`
+	Field       string          `json:"field" db:"field"`
+	OldValue    json.RawMessage `json:"old_value" db:"old_value"`
+	NewValue    json.RawMessage `json:"new_value" db:"new_value"`
+	ChangedAt   time.Time       `json:"changed_at" db:"changed_at"`
 }
 
 // WorkReport represents a report on inappropriate work content
@@ -311,4 +493,5 @@ type UpdateChapterRequest struct {
 	EndNotes *string `json:"end_notes,omitempty"`
 	Content  *string `json:"content,omitempty"`
 	Status   *string `json:"status,omitempty" validate:"omitempty,oneof=draft posted"`
+	Version  *int    `json:"version,omitempty"` // Version being edited, if not sent as If-Match
 }
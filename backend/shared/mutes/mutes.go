@@ -0,0 +1,106 @@
+// Package mutes looks up who a user has muted, for the listings that hide
+// muted users' works and comments. Each user's list is cached in Redis;
+// the service that changes mutes invalidates its own cache, and other
+// services see the change once their cached copy expires.
+package mutes
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "mutes:"
+
+// Filter caches each user's muted user IDs
+type Filter struct {
+	db    *sql.DB
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+// NewFilter caches mute lists in client for ttl. A nil client reads the
+// database every time.
+func NewFilter(db *sql.DB, client *redis.Client, ttl time.Duration) *Filter {
+	return &Filter{db: db, redis: client, ttl: ttl}
+}
+
+func key(userID uuid.UUID) string {
+	return keyPrefix + userID.String()
+}
+
+// encode stores a list as comma separated IDs; an empty list is cached as
+// an empty string so users who mute nobody don't go back to the database
+func encode(ids []uuid.UUID) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = id.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+func decode(value string) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, part := range strings.Split(value, ",") {
+		if id, err := uuid.Parse(part); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// MutedUserIDs returns the users userID has muted. A nil Filter mutes
+// nobody.
+func (f *Filter) MutedUserIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	if f == nil {
+		return nil, nil
+	}
+	if f.redis != nil {
+		if value, err := f.redis.Get(ctx, key(userID)).Result(); err == nil {
+			return decode(value), nil
+		}
+	}
+
+	rows, err := f.db.QueryContext(ctx, "SELECT muted_id FROM user_mutes WHERE muter_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if f.redis != nil {
+		f.redis.Set(ctx, key(userID), encode(ids), f.ttl)
+	}
+	return ids, nil
+}
+
+// Invalidate drops userID's cached list after their mutes change
+func (f *Filter) Invalidate(ctx context.Context, userID uuid.UUID) {
+	if f == nil || f.redis == nil {
+		return
+	}
+	f.redis.Del(ctx, key(userID))
+}
+
+// Strings returns ids as strings, for search queries
+func Strings(ids []uuid.UUID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
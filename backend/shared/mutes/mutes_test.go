@@ -0,0 +1,36 @@
+package mutes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	got := decode(encode(ids))
+	if len(got) != 2 || got[0] != ids[0] || got[1] != ids[1] {
+		t.Fatalf("round trip = %v, want %v", got, ids)
+	}
+
+	if got := decode(encode(nil)); len(got) != 0 {
+		t.Errorf("empty list decoded to %v", got)
+	}
+}
+
+func TestNilFilterMutesNobody(t *testing.T) {
+	var f *Filter
+	ids, err := f.MutedUserIDs(context.Background(), uuid.New())
+	if err != nil || len(ids) != 0 {
+		t.Errorf("nil filter = %v, %v", ids, err)
+	}
+	f.Invalidate(context.Background(), uuid.New())
+}
+
+func TestStrings(t *testing.T) {
+	id := uuid.New()
+	if got := Strings([]uuid.UUID{id}); len(got) != 1 || got[0] != id.String() {
+		t.Errorf("Strings = %v", got)
+	}
+}
@@ -2,9 +2,10 @@ package notifications
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"sort"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,7 +19,46 @@ type BatchProcessor struct {
 	maxBatchSize    int
 	ticker          *time.Ticker
 	stopChan        chan bool
-	pendingBatches  map[string][]*models.NotificationItem // userID -> notifications
+
+	mu             sync.Mutex
+	pendingBatches map[batchKey]*pendingBatch
+}
+
+// batchKey separates a user's notifications by how often they go out, so a
+// followed tag set to weekly doesn't hold up the rest of their digest
+type batchKey struct {
+	userID    string
+	frequency models.NotificationFrequency
+}
+
+// pendingBatch is a batch waiting to go out, and when it started. A batch
+// held for a pause goes out when the pause ends, at until.
+type pendingBatch struct {
+	notifications []*models.NotificationItem
+	since         time.Time
+	until         time.Time
+}
+
+// frequencyPaused keys the batch of notifications held while a user has
+// paused them, sent as one digest on resume
+const frequencyPaused models.NotificationFrequency = "paused"
+
+// batchHoldPeriods is how long daily and weekly batches collect
+// notifications; other batches go out on the next tick
+var batchHoldPeriods = map[models.NotificationFrequency]time.Duration{
+	models.FrequencyDaily:  24 * time.Hour,
+	models.FrequencyWeekly: 7 * 24 * time.Hour,
+}
+
+// due reports whether a batch should be sent at now
+func (b *pendingBatch) due(frequency models.NotificationFrequency, now time.Time) bool {
+	if len(b.notifications) == 0 {
+		return false
+	}
+	if frequency == frequencyPaused {
+		return !now.Before(b.until)
+	}
+	return !now.Before(b.since.Add(batchHoldPeriods[frequency]))
 }
 
 // NewBatchProcessor creates a new batch processor
@@ -28,7 +68,7 @@ func NewBatchProcessor(service *NotificationService, intervalMinutes, maxBatchSi
 		intervalMinutes: intervalMinutes,
 		maxBatchSize:    maxBatchSize,
 		stopChan:        make(chan bool),
-		pendingBatches:  make(map[string][]*models.NotificationItem),
+		pendingBatches:  make(map[batchKey]*pendingBatch),
 	}
 
 	// Start the batch processing ticker
@@ -59,43 +99,97 @@ func (bp *BatchProcessor) Stop() {
 	close(bp.stopChan)
 }
 
-// AddToBatch adds a notification to the pending batch for a user
-func (bp *BatchProcessor) AddToBatch(ctx context.Context, notification *models.NotificationItem) error {
-	userID := notification.UserID.String()
+// AddToBatch adds a notification to the user's pending batch for frequency
+func (bp *BatchProcessor) AddToBatch(ctx context.Context, notification *models.NotificationItem, frequency models.NotificationFrequency) error {
+	key := batchKey{userID: notification.UserID.String(), frequency: frequency}
 
 	// Add to pending batch
-	bp.pendingBatches[userID] = append(bp.pendingBatches[userID], notification)
-
-	// Check if batch is full and should be sent immediately
-	if len(bp.pendingBatches[userID]) >= bp.maxBatchSize {
-		return bp.processBatchForUser(ctx, userID)
+	bp.mu.Lock()
+	batch, ok := bp.pendingBatches[key]
+	if !ok || len(batch.notifications) == 0 {
+		batch = &pendingBatch{since: time.Now()}
+		bp.pendingBatches[key] = batch
+	}
+	batch.notifications = append(batch.notifications, notification)
+	// A full batch is sent right away, unless it is a daily or weekly
+	// digest, which waits out its period however busy it gets
+	full := len(batch.notifications) >= bp.maxBatchSize && batchHoldPeriods[frequency] == 0
+	bp.mu.Unlock()
+
+	if full {
+		return bp.processBatch(ctx, key)
 	}
 
 	return nil
 }
 
-// processPendingBatches processes all pending batches
+// HoldUntil keeps a notification for a paused user until the pause ends at
+// until, when everything held goes out together
+func (bp *BatchProcessor) HoldUntil(ctx context.Context, notification *models.NotificationItem, until time.Time) error {
+	key := batchKey{userID: notification.UserID.String(), frequency: frequencyPaused}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	batch, ok := bp.pendingBatches[key]
+	if !ok {
+		batch = &pendingBatch{since: time.Now()}
+		bp.pendingBatches[key] = batch
+	}
+	batch.notifications = append(batch.notifications, notification)
+	batch.until = until
+	return nil
+}
+
+// rescheduleHeld moves the release of a user's held notifications to until
+// when they change the length of their pause
+func (bp *BatchProcessor) rescheduleHeld(userID uuid.UUID, until time.Time) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if batch, ok := bp.pendingBatches[batchKey{userID: userID.String(), frequency: frequencyPaused}]; ok {
+		batch.until = until
+	}
+}
+
+// releaseHeld sends a user's held notifications now
+func (bp *BatchProcessor) releaseHeld(ctx context.Context, userID uuid.UUID) error {
+	return bp.processBatch(ctx, batchKey{userID: userID.String(), frequency: frequencyPaused})
+}
+
+// processPendingBatches processes all pending batches that are due
 func (bp *BatchProcessor) processPendingBatches() {
 	ctx := context.Background()
+	now := time.Now()
 
-	for userID := range bp.pendingBatches {
-		if len(bp.pendingBatches[userID]) > 0 {
-			if err := bp.processBatchForUser(ctx, userID); err != nil {
-				log.Printf("Failed to process batch for user %s: %v", userID, err)
-			}
+	bp.mu.Lock()
+	var due []batchKey
+	for key, batch := range bp.pendingBatches {
+		if batch.due(key.frequency, now) {
+			due = append(due, key)
+		}
+	}
+	bp.mu.Unlock()
+
+	for _, key := range due {
+		if err := bp.processBatch(ctx, key); err != nil {
+			log.Printf("Failed to process batch for user %s: %v", key.userID, err)
 		}
 	}
 }
 
-// processBatchForUser processes the pending batch for a specific user
-func (bp *BatchProcessor) processBatchForUser(ctx context.Context, userID string) error {
-	notifications := bp.pendingBatches[userID]
+// processBatch sends one pending batch as a digest
+func (bp *BatchProcessor) processBatch(ctx context.Context, key batchKey) error {
+	// Take the pending batch
+	bp.mu.Lock()
+	var notifications []*models.NotificationItem
+	if batch, ok := bp.pendingBatches[key]; ok {
+		notifications = batch.notifications
+		delete(bp.pendingBatches, key)
+	}
+	bp.mu.Unlock()
 	if len(notifications) == 0 {
 		return nil
 	}
-
-	// Clear the pending batch
-	bp.pendingBatches[userID] = nil
+	userID := key.userID
 
 	// Get user preferences to determine digest type
 	uid, err := uuid.Parse(userID)
@@ -110,11 +204,22 @@ func (bp *BatchProcessor) processBatchForUser(ctx context.Context, userID string
 		prefs = &defaultPrefs
 	}
 
-	// Create digest based on user's batch frequency
+	// Daily, weekly and paused batches are digests of that type; anything
+	// else goes out as the user's usual batch
 	digestType := string(prefs.BatchFrequency)
+	if batchHoldPeriods[key.frequency] > 0 || key.frequency == frequencyPaused {
+		digestType = string(key.frequency)
+	}
+
+	return bp.service.sendDigest(ctx, uid, digestType, nil, notifications, prefs)
+}
 
-	// Group notifications by type and importance
-	groupedNotifications := bp.groupNotifications(notifications)
+// sendDigest saves notifications as a digest and sends it. scheduledFor is
+// the slot of a scheduled digest, and nil for batches.
+func (ns *NotificationService) sendDigest(ctx context.Context, userID uuid.UUID, digestType string, scheduledFor *time.Time,
+	notifications []*models.NotificationItem, prefs *models.NotificationPreferences) error {
+	// Rank notifications into capped sections in the user's preferred order
+	sections := buildDigestSections(notifications, prefs)
 
 	// Convert notification pointers to values for digest
 	notificationValues := make([]models.NotificationItem, len(notifications))
@@ -125,56 +230,36 @@ func (bp *BatchProcessor) processBatchForUser(ctx context.Context, userID string
 	// Create digest
 	digest := &models.NotificationDigest{
 		ID:            uuid.New(),
-		UserID:        uid,
+		UserID:        userID,
 		DigestType:    digestType,
 		Notifications: notificationValues,
 		CreatedAt:     time.Now(),
 		Status:        models.DigestPending,
+		ScheduledFor:  scheduledFor,
 	}
 
 	// Save digest
-	if err := bp.service.digestRepo.CreateDigest(ctx, digest); err != nil {
+	if err := ns.digestRepo.CreateDigest(ctx, digest); err != nil {
+		if errors.Is(err, ErrDigestExists) {
+			return err
+		}
 		return fmt.Errorf("failed to create digest: %w", err)
 	}
 
 	// Send digest email
-	return bp.sendDigestEmail(ctx, digest, groupedNotifications, prefs)
-}
-
-// groupNotifications groups notifications by type and priority for better digest formatting
-func (bp *BatchProcessor) groupNotifications(notifications []*models.NotificationItem) map[string][]*models.NotificationItem {
-	groups := make(map[string][]*models.NotificationItem)
-
-	// Sort notifications by priority and time
-	sort.Slice(notifications, func(i, j int) bool {
-		// Sort by priority first (high > medium > low)
-		if notifications[i].Priority != notifications[j].Priority {
-			priorityOrder := map[models.NotificationPriority]int{
-				models.PriorityHigh:   3,
-				models.PriorityMedium: 2,
-				models.PriorityLow:    1,
-			}
-			return priorityOrder[notifications[i].Priority] > priorityOrder[notifications[j].Priority]
-		}
-		// Then by time (newest first)
-		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
-	})
-
-	// Group by event type
-	for _, notification := range notifications {
-		eventType := string(notification.Event)
-		groups[eventType] = append(groups[eventType], notification)
-	}
-
-	return groups
+	return ns.sendDigestEmail(ctx, digest, sections, prefs)
 }
 
-// sendDigestEmail sends a digest email to the user
-func (bp *BatchProcessor) sendDigestEmail(ctx context.Context, digest *models.NotificationDigest, groupedNotifications map[string][]*models.NotificationItem, prefs *models.NotificationPreferences) error {
+// sendDigestEmail sends a digest email to the user and records the outcome
+// on the digest
+func (ns *NotificationService) sendDigestEmail(ctx context.Context, digest *models.NotificationDigest, sections []DigestSection, prefs *models.NotificationPreferences) error {
 	// Generate digest content
-	subject := bp.generateDigestSubject(digest, groupedNotifications)
-	plainText := bp.generateDigestPlainText(digest, groupedNotifications)
-	html := bp.generateDigestHTML(digest, groupedNotifications)
+	subject := generateDigestSubject(digest, sections)
+	plainText := generateDigestPlainText(digest, sections)
+	html, err := generateDigestHTML(digest, sections, prefs)
+	if err != nil {
+		return ns.digestFailed(ctx, digest, fmt.Errorf("failed to render digest: %w", err))
+	}
 
 	// Create message content
 	content := &models.MessageContent{
@@ -189,8 +274,12 @@ func (bp *BatchProcessor) sendDigestEmail(ctx context.Context, digest *models.No
 		},
 	}
 
-	// Determine which channels to use for digest
-	digestChannels := []models.DeliveryChannel{models.ChannelEmail}
+	// Determine which channels to use for digest; a user who unsubscribed
+	// from email gets it in the app only
+	var digestChannels []models.DeliveryChannel
+	if prefs.EmailEnabled {
+		digestChannels = append(digestChannels, models.ChannelEmail)
+	}
 	if prefs.WebEnabled {
 		digestChannels = append(digestChannels, models.ChannelInApp)
 	}
@@ -222,8 +311,8 @@ func (bp *BatchProcessor) sendDigestEmail(ctx context.Context, digest *models.No
 	}
 
 	// Send message
-	if err := bp.service.messageService.SendMessage(ctx, message); err != nil {
-		return fmt.Errorf("failed to send digest message: %w", err)
+	if err := ns.messageService.SendMessage(ctx, message); err != nil {
+		return ns.digestFailed(ctx, digest, fmt.Errorf("failed to send digest message: %w", err))
 	}
 
 	// Update digest as sent
@@ -231,7 +320,7 @@ func (bp *BatchProcessor) sendDigestEmail(ctx context.Context, digest *models.No
 	now := time.Now()
 	digest.SentAt = &now
 
-	if err := bp.service.digestRepo.UpdateDigest(ctx, digest); err != nil {
+	if err := ns.digestRepo.UpdateDigest(ctx, digest); err != nil {
 		log.Printf("Failed to update digest status: %v", err)
 	}
 
@@ -241,7 +330,7 @@ func (bp *BatchProcessor) sendDigestEmail(ctx context.Context, digest *models.No
 		digest.Notifications[i].DeliveredAt = &now
 		digest.Notifications[i].DigestID = &digest.ID
 
-		if err := bp.service.notificationRepo.UpdateNotification(ctx, &digest.Notifications[i]); err != nil {
+		if err := ns.notificationRepo.UpdateNotification(ctx, &digest.Notifications[i]); err != nil {
 			log.Printf("Failed to update notification %s: %v", digest.Notifications[i].ID, err)
 		}
 	}
@@ -249,10 +338,25 @@ func (bp *BatchProcessor) sendDigestEmail(ctx context.Context, digest *models.No
 	return nil
 }
 
+// digestFailed records why a digest wasn't sent. Its notifications stay
+// undelivered and go out with the next digest.
+func (ns *NotificationService) digestFailed(ctx context.Context, digest *models.NotificationDigest, err error) error {
+	digest.Status = models.DigestFailed
+	digest.Error = err.Error()
+	if updateErr := ns.digestRepo.UpdateDigest(ctx, digest); updateErr != nil {
+		log.Printf("Failed to update digest status: %v", updateErr)
+	}
+	return err
+}
+
 // generateDigestSubject creates a subject line for the digest
-func (bp *BatchProcessor) generateDigestSubject(digest *models.NotificationDigest, groups map[string][]*models.NotificationItem) string {
+func generateDigestSubject(digest *models.NotificationDigest, sections []DigestSection) string {
 	count := len(digest.Notifications)
 
+	if digest.DigestType == string(frequencyPaused) {
+		return fmt.Sprintf("[Nuclear AO3] While you were away: %d notifications", count)
+	}
+
 	if count == 1 {
 		return fmt.Sprintf("[Nuclear AO3] 1 new notification")
 	}
@@ -261,21 +365,24 @@ func (bp *BatchProcessor) generateDigestSubject(digest *models.NotificationDiges
 }
 
 // generateDigestPlainText creates plain text content for the digest
-func (bp *BatchProcessor) generateDigestPlainText(digest *models.NotificationDigest, groups map[string][]*models.NotificationItem) string {
+func generateDigestPlainText(digest *models.NotificationDigest, sections []DigestSection) string {
 	var content string
 
 	content += fmt.Sprintf("You have %d new notifications:\n\n", len(digest.Notifications))
 
-	// Add content for each group
-	for eventType, notifications := range groups {
-		content += fmt.Sprintf("%s (%d):\n", bp.getEventDisplayName(eventType), len(notifications))
+	// Add content for each section, in ranked order
+	for _, section := range sections {
+		content += fmt.Sprintf("%s (%d):\n", eventDisplayName(string(section.Event)), section.Total)
 
-		for _, notification := range notifications {
-			content += fmt.Sprintf("  • %s\n", notification.Title)
-			if notification.ActionURL != "" {
-				content += fmt.Sprintf("    %s\n", notification.ActionURL)
+		for _, entry := range section.Entries {
+			content += fmt.Sprintf("  • %s\n", digestEntryTitle(entry))
+			if entry.Notification.ActionURL != "" {
+				content += fmt.Sprintf("    %s\n", entry.Notification.ActionURL)
 			}
 		}
+		if more := section.MoreCount(); more > 0 {
+			content += fmt.Sprintf("  …and %d more: %s\n", more, section.MoreURL())
+		}
 		content += "\n"
 	}
 
@@ -286,81 +393,8 @@ func (bp *BatchProcessor) generateDigestPlainText(digest *models.NotificationDig
 	return content
 }
 
-// generateDigestHTML creates HTML content for the digest
-func (bp *BatchProcessor) generateDigestHTML(digest *models.NotificationDigest, groups map[string][]*models.NotificationItem) string {
-	html := `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <title>Nuclear AO3 Notifications</title>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background: #990000; color: white; padding: 20px; border-radius: 5px 5px 0 0; }
-        .content { background: #f9f9f9; padding: 20px; border-radius: 0 0 5px 5px; }
-        .notification-group { margin-bottom: 25px; }
-        .group-title { font-size: 18px; font-weight: bold; color: #990000; margin-bottom: 10px; border-bottom: 2px solid #990000; padding-bottom: 5px; }
-        .notification-item { background: white; padding: 15px; margin-bottom: 10px; border-radius: 3px; border-left: 4px solid #990000; }
-        .notification-title { font-weight: bold; margin-bottom: 5px; }
-        .notification-desc { color: #666; margin-bottom: 8px; }
-        .notification-action { margin-top: 10px; }
-        .action-button { background: #990000; color: white; padding: 8px 15px; text-decoration: none; border-radius: 3px; display: inline-block; }
-        .footer { text-align: center; margin-top: 30px; padding-top: 20px; border-top: 1px solid #ddd; font-size: 12px; color: #666; }
-    </style>
-</head>
-<body>`
-
-	html += fmt.Sprintf(`
-    <div class="header">
-        <h1>Nuclear AO3 Notifications</h1>
-        <p>You have %d new notifications</p>
-    </div>
-    <div class="content">`, len(digest.Notifications))
-
-	// Add content for each group
-	for eventType, notifications := range groups {
-		html += fmt.Sprintf(`
-        <div class="notification-group">
-            <div class="group-title">%s (%d)</div>`, bp.getEventDisplayName(eventType), len(notifications))
-
-		for _, notification := range notifications {
-			html += fmt.Sprintf(`
-            <div class="notification-item">
-                <div class="notification-title">%s</div>`, notification.Title)
-
-			if notification.Description != "" {
-				html += fmt.Sprintf(`
-                <div class="notification-desc">%s</div>`, notification.Description)
-			}
-
-			if notification.ActionURL != "" {
-				html += fmt.Sprintf(`
-                <div class="notification-action">
-                    <a href="%s" class="action-button">View</a>
-                </div>`, notification.ActionURL)
-			}
-
-			html += `
-            </div>`
-		}
-
-		html += `
-        </div>`
-	}
-
-	html += `
-    </div>
-    <div class="footer">
-        <p>To manage your notification preferences, visit your account settings.<br>
-        To unsubscribe from digest emails, change your batch frequency to 'never'.</p>
-    </div>
-</body>
-</html>`
-
-	return html
-}
-
-// getEventDisplayName returns a user-friendly name for an event type
-func (bp *BatchProcessor) getEventDisplayName(eventType string) string {
+// eventDisplayName returns a user-friendly name for an event type
+func eventDisplayName(eventType string) string {
 	switch eventType {
 	case string(models.EventWorkUpdated):
 		return "📖 Work Updates"
@@ -368,8 +402,12 @@ func (bp *BatchProcessor) getEventDisplayName(eventType string) string {
 		return "💬 New Comments"
 	case string(models.EventKudosReceived):
 		return "❤️ Kudos"
+	case string(models.EventWorkMilestone):
+		return "🏆 Milestones"
 	case string(models.EventNewWork):
 		return "✨ New Works"
+	case string(models.EventSavedSearchMatch):
+		return "🔍 Saved Search Alerts"
 	case string(models.EventSeriesUpdated):
 		return "📚 Series Updates"
 	case string(models.EventCollectionInvite):
@@ -380,3 +418,11 @@ func (bp *BatchProcessor) getEventDisplayName(eventType string) string {
 		return "📢 Notifications"
 	}
 }
+
+// digestEntryTitle shows how many notifications an aggregated entry stands for
+func digestEntryTitle(entry DigestEntry) string {
+	if entry.Count > 1 {
+		return fmt.Sprintf("%s (×%d)", entry.Notification.Title, entry.Count)
+	}
+	return entry.Notification.Title
+}
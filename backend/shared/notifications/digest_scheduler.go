@@ -0,0 +1,146 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/models"
+)
+
+// ErrDigestExists is returned by DigestRepository.CreateDigest when a
+// digest was already created for the same user, type and slot
+var ErrDigestExists = errors.New("digest already created for this slot")
+
+// DigestSchedulerConfig sets when scheduled digests go out
+type DigestSchedulerConfig struct {
+	// Hour is the hour of the day, in each user's time zone, digests are
+	// sent (default 8)
+	Hour int
+	// Weekday is the day weekly digests are sent; the zero value is Sunday
+	Weekday time.Weekday
+	// CheckInterval is how often the scheduler looks for due digests
+	// (default 15 minutes)
+	CheckInterval time.Duration
+}
+
+// DigestScheduler sends daily and weekly digests. Notifications due in a
+// digest wait undelivered in the database, marked with its frequency; once
+// a user's local digest time has passed the waiting notifications go out as
+// one digest, unless it's their quiet hours, when they wait for the next
+// check after the quiet hours end.
+type DigestScheduler struct {
+	service  *NotificationService
+	config   DigestSchedulerConfig
+	stopChan chan struct{}
+}
+
+// scheduledDigestFrequencies are the frequencies the scheduler sends
+var scheduledDigestFrequencies = []models.NotificationFrequency{models.FrequencyDaily, models.FrequencyWeekly}
+
+// NewDigestScheduler creates a digest scheduler and starts it
+func NewDigestScheduler(service *NotificationService, config DigestSchedulerConfig) *DigestScheduler {
+	if config.Hour < 0 || config.Hour > 23 {
+		config.Hour = 8
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 15 * time.Minute
+	}
+
+	ds := &DigestScheduler{
+		service:  service,
+		config:   config,
+		stopChan: make(chan struct{}),
+	}
+	go ds.run()
+	return ds
+}
+
+func (ds *DigestScheduler) run() {
+	ticker := time.NewTicker(ds.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ds.SendDueDigests(context.Background(), time.Now())
+		case <-ds.stopChan:
+			return
+		}
+	}
+}
+
+// Stop stops the digest scheduler
+func (ds *DigestScheduler) Stop() {
+	close(ds.stopChan)
+}
+
+// SendDueDigests sends every digest due at now and returns how many went out
+func (ds *DigestScheduler) SendDueDigests(ctx context.Context, now time.Time) int {
+	sent := 0
+	for _, frequency := range scheduledDigestFrequencies {
+		userIDs, err := ds.service.notificationRepo.GetUsersAwaitingDigest(ctx, frequency)
+		if err != nil {
+			log.Printf("Failed to find users awaiting a %s digest: %v", frequency, err)
+			continue
+		}
+		for _, userID := range userIDs {
+			ok, err := ds.sendIfDue(ctx, userID, frequency, now)
+			if err != nil {
+				log.Printf("Failed to send %s digest to user %s: %v", frequency, userID, err)
+			}
+			if ok {
+				sent++
+			}
+		}
+	}
+	return sent
+}
+
+// sendIfDue sends a user's digest of one frequency if its slot has passed
+// since the oldest waiting notification arrived
+func (ds *DigestScheduler) sendIfDue(ctx context.Context, userID uuid.UUID, frequency models.NotificationFrequency, now time.Time) (bool, error) {
+	prefs, err := ds.service.preferenceRepo.GetPreferences(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to get preferences for user %s, using defaults: %v", userID, err)
+		defaultPrefs := models.DefaultNotificationPreferences(userID)
+		prefs = &defaultPrefs
+	}
+	// A pause holds digests too; quiet hours only delay them
+	if prefs.IsPaused(now) || prefs.InQuietHours(now) {
+		return false, nil
+	}
+
+	slot := ds.lastSlot(now, prefs.Location(), frequency)
+	notifications, err := ds.service.notificationRepo.GetNotificationsForBatch(ctx, userID, frequency)
+	if err != nil {
+		return false, err
+	}
+	if len(notifications) == 0 || !notifications[0].CreatedAt.Before(slot) {
+		return false, nil
+	}
+
+	err = ds.service.sendDigest(ctx, userID, string(frequency), &slot, notifications, prefs)
+	if errors.Is(err, ErrDigestExists) {
+		// Another replica got there first
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// lastSlot is the most recent digest time at or before now in loc: today's
+// (or the last weekday's) digest hour, or the one before it
+func (ds *DigestScheduler) lastSlot(now time.Time, loc *time.Location, frequency models.NotificationFrequency) time.Time {
+	local := now.In(loc)
+	slot := time.Date(local.Year(), local.Month(), local.Day(), ds.config.Hour, 0, 0, 0, loc)
+	if slot.After(local) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if frequency == models.FrequencyWeekly {
+		back := (int(slot.Weekday()) - int(ds.config.Weekday) + 7) % 7
+		slot = slot.AddDate(0, 0, -back)
+	}
+	return slot
+}
@@ -0,0 +1,189 @@
+package notifications
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/models"
+)
+
+// waitingNotificationRepo holds notifications waiting for digests
+type waitingNotificationRepo struct {
+	mockNotificationRepo
+	waiting []*models.NotificationItem
+}
+
+func (m *waitingNotificationRepo) GetUsersAwaitingDigest(ctx context.Context, frequency models.NotificationFrequency) ([]uuid.UUID, error) {
+	seen := make(map[uuid.UUID]bool)
+	var userIDs []uuid.UUID
+	for _, n := range m.waiting {
+		if !n.IsDelivered && n.DigestFrequency == frequency && !seen[n.UserID] {
+			seen[n.UserID] = true
+			userIDs = append(userIDs, n.UserID)
+		}
+	}
+	return userIDs, nil
+}
+
+func (m *waitingNotificationRepo) GetNotificationsForBatch(ctx context.Context, userID uuid.UUID, frequency models.NotificationFrequency) ([]*models.NotificationItem, error) {
+	var waiting []*models.NotificationItem
+	for _, n := range m.waiting {
+		if n.UserID == userID && !n.IsDelivered && n.DigestFrequency == frequency {
+			copied := *n
+			waiting = append(waiting, &copied)
+		}
+	}
+	return waiting, nil
+}
+
+func (m *waitingNotificationRepo) UpdateNotification(ctx context.Context, notification *models.NotificationItem) error {
+	for _, n := range m.waiting {
+		if n.ID == notification.ID {
+			n.IsDelivered = notification.IsDelivered
+		}
+	}
+	return nil
+}
+
+// slotDigestRepo keeps created digests and refuses a second for a slot
+type slotDigestRepo struct {
+	mockDigestRepo
+	digests []*models.NotificationDigest
+}
+
+func (m *slotDigestRepo) CreateDigest(ctx context.Context, digest *models.NotificationDigest) error {
+	for _, d := range m.digests {
+		if d.UserID == digest.UserID && d.DigestType == digest.DigestType && d.ScheduledFor != nil &&
+			digest.ScheduledFor != nil && d.ScheduledFor.Equal(*digest.ScheduledFor) {
+			return ErrDigestExists
+		}
+	}
+	m.digests = append(m.digests, digest)
+	return nil
+}
+
+// zonedPreferenceRepo gives every user the same time zone and quiet hours
+type zonedPreferenceRepo struct {
+	mockPreferenceRepo
+	timezone          string
+	quietStart, quiet *time.Time
+}
+
+func (m *zonedPreferenceRepo) GetPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error) {
+	prefs := models.DefaultNotificationPreferences(userID)
+	prefs.Timezone = m.timezone
+	prefs.QuietHoursStart, prefs.QuietHoursEnd = m.quietStart, m.quiet
+	return &prefs, nil
+}
+
+func clock(s string) *time.Time {
+	t, _ := time.Parse("15:04", s)
+	return &t
+}
+
+func TestDigestSchedulerSlots(t *testing.T) {
+	ds := &DigestScheduler{config: DigestSchedulerConfig{Hour: 8, Weekday: time.Monday}}
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data unavailable")
+	}
+
+	// Wednesday 2026-03-04 07:30 in New York is before that day's slot
+	now := time.Date(2026, 3, 4, 7, 30, 0, 0, loc)
+	if got, want := ds.lastSlot(now, loc, models.FrequencyDaily), time.Date(2026, 3, 3, 8, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("daily slot = %s, want %s", got, want)
+	}
+	if got, want := ds.lastSlot(now.Add(time.Hour), loc, models.FrequencyDaily), time.Date(2026, 3, 4, 8, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("daily slot = %s, want %s", got, want)
+	}
+	if got, want := ds.lastSlot(now, loc, models.FrequencyWeekly), time.Date(2026, 3, 2, 8, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("weekly slot = %s, want %s", got, want)
+	}
+	// On Monday before 08:00 the slot is the Monday before
+	monday := time.Date(2026, 3, 9, 7, 0, 0, 0, loc)
+	if got, want := ds.lastSlot(monday, loc, models.FrequencyWeekly), time.Date(2026, 3, 2, 8, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("weekly slot = %s, want %s", got, want)
+	}
+}
+
+func TestSendDueDigestsRespectsTimezoneAndQuietHours(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("time zone data unavailable")
+	}
+	userID := uuid.New()
+	created := time.Date(2026, 3, 4, 20, 0, 0, 0, loc)
+	notificationRepo := &waitingNotificationRepo{waiting: []*models.NotificationItem{
+		{ID: uuid.New(), UserID: userID, Event: models.EventWorkUpdated, Title: "<b>Chapter 2</b>", CreatedAt: created, DigestFrequency: models.FrequencyDaily},
+		{ID: uuid.New(), UserID: userID, Event: models.EventNewWork, Title: "New work", CreatedAt: created, DigestFrequency: models.FrequencyWeekly},
+	}}
+	digestRepo := &slotDigestRepo{}
+	prefs := &zonedPreferenceRepo{timezone: "Asia/Tokyo", quietStart: clock("07:00"), quiet: clock("09:00")}
+	service := NewNotificationService(&mockMessageService{}, &mockSubscriptionRepo{}, notificationRepo,
+		digestRepo, prefs, NotificationServiceConfig{})
+	ds := &DigestScheduler{service: service, config: DigestSchedulerConfig{Hour: 8, Weekday: time.Monday}}
+	ctx := context.Background()
+
+	// 07:30 in Tokyo: the day's slot hasn't come
+	if sent := ds.SendDueDigests(ctx, time.Date(2026, 3, 5, 7, 30, 0, 0, loc)); sent != 0 {
+		t.Fatalf("Nothing should be due before the slot, sent %d", sent)
+	}
+	// 08:30: past the slot but inside quiet hours
+	if sent := ds.SendDueDigests(ctx, time.Date(2026, 3, 5, 8, 30, 0, 0, loc)); sent != 0 {
+		t.Fatalf("Quiet hours should delay the digest, sent %d", sent)
+	}
+	// 09:15: quiet hours are over; the daily digest goes, the weekly waits
+	// for Monday
+	if sent := ds.SendDueDigests(ctx, time.Date(2026, 3, 5, 9, 15, 0, 0, loc)); sent != 1 {
+		t.Fatalf("Expected the daily digest, sent %d", sent)
+	}
+	if len(digestRepo.digests) != 1 {
+		t.Fatalf("Expected one recorded digest, got %d", len(digestRepo.digests))
+	}
+	digest := digestRepo.digests[0]
+	if digest.Status != models.DigestSent || digest.DigestType != "daily" || digest.SentAt == nil {
+		t.Errorf("Digest should be recorded as a sent daily digest, got %+v", digest)
+	}
+	if want := time.Date(2026, 3, 5, 8, 0, 0, 0, loc); digest.ScheduledFor == nil || !digest.ScheduledFor.Equal(want) {
+		t.Errorf("Digest slot = %v, want %s", digest.ScheduledFor, want)
+	}
+	if !notificationRepo.waiting[0].IsDelivered || notificationRepo.waiting[1].IsDelivered {
+		t.Error("Only the daily notification should be delivered")
+	}
+
+	// Another check in the same slot finds nothing left to send
+	if sent := ds.SendDueDigests(ctx, time.Date(2026, 3, 5, 9, 30, 0, 0, loc)); sent != 0 {
+		t.Errorf("The digest should only go out once, sent %d more", sent)
+	}
+	// A second replica racing on the same slot is refused
+	notificationRepo.waiting[0].IsDelivered = false
+	if sent := ds.SendDueDigests(ctx, time.Date(2026, 3, 5, 9, 30, 0, 0, loc)); sent != 0 || len(digestRepo.digests) != 1 {
+		t.Errorf("A slot already sent should not be sent again, sent %d", sent)
+	}
+
+	// Monday 09:15 Tokyo: the weekly digest is due
+	if sent := ds.SendDueDigests(ctx, time.Date(2026, 3, 9, 9, 15, 0, 0, loc)); sent != 2 {
+		t.Errorf("Expected the weekly and the next daily digest on Monday, sent %d", sent)
+	}
+}
+
+func TestDigestHTMLEscapesContent(t *testing.T) {
+	slot := time.Date(2026, 3, 5, 8, 0, 0, 0, time.UTC)
+	item := models.NotificationItem{ID: uuid.New(), Event: models.EventCommentReceived, Title: `<script>alert(1)</script>`, ActionURL: "/works/1"}
+	digest := &models.NotificationDigest{DigestType: "daily", ScheduledFor: &slot, Notifications: []models.NotificationItem{item}}
+	prefs := &models.NotificationPreferences{Timezone: "UTC"}
+
+	html, err := generateDigestHTML(digest, buildDigestSections([]*models.NotificationItem{&item}, prefs), prefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(html, "<script>") {
+		t.Error("Notification titles should be escaped")
+	}
+	if !strings.Contains(html, "Your daily digest for Thursday 5 March: 1 new notification") {
+		t.Error("Expected a dated heading")
+	}
+}
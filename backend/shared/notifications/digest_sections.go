@@ -0,0 +1,189 @@
+package notifications
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"nuclear-ao3/shared/models"
+)
+
+// MaxDigestSectionLimit is the most items a user can ask a section to show
+const MaxDigestSectionLimit = 50
+
+// defaultDigestSectionOrder ranks digest sections when the user hasn't set
+// an order: updates to works the reader follows lead, and activity on the
+// reader's own works that gets aggregated (kudos, bookmarks) comes last.
+var defaultDigestSectionOrder = []models.NotificationEvent{
+	models.EventWorkUpdated,
+	models.EventWorkCompleted,
+	models.EventSeriesUpdated,
+	models.EventNewWork,
+	models.EventSavedSearchMatch,
+	models.EventCommentReplied,
+	models.EventCommentMention,
+	models.EventCommentReceived,
+	models.EventGiftReceived,
+	models.EventCollectionInvite,
+	models.EventCollectionItemReviewed,
+	models.EventTagReportReviewed,
+	models.EventAbuseReportUpdated,
+	models.EventWorkRevealed,
+	models.EventWorkUnpublished,
+	models.EventModeratorAction,
+	models.EventSystemAlert,
+	models.EventAccountSecurity,
+	models.EventPasswordReset,
+	models.EventWorkMilestone,
+	models.EventBookmarkAdded,
+	models.EventKudosReceived,
+}
+
+// DigestEntry is one line of a digest section. Count is above one when
+// several notifications about the same source were aggregated.
+type DigestEntry struct {
+	Notification *models.NotificationItem
+	Count        int
+}
+
+// DigestSection is the capped, ranked list of entries for one event type
+type DigestSection struct {
+	Event   models.NotificationEvent
+	Entries []DigestEntry
+	// Total is the number of entries before the cap was applied
+	Total int
+}
+
+// MoreCount is how many entries were cut from the section
+func (s DigestSection) MoreCount() int {
+	return s.Total - len(s.Entries)
+}
+
+// MoreURL links to the inbox filtered to this section's event type
+func (s DigestSection) MoreURL() string {
+	return "/notifications?event=" + url.QueryEscape(string(s.Event))
+}
+
+// ValidateDigestLayout checks the digest settings in a preferences update
+func ValidateDigestLayout(prefs *models.NotificationPreferences) error {
+	if prefs.DigestSectionLimit < 0 || prefs.DigestSectionLimit > MaxDigestSectionLimit {
+		return fmt.Errorf("digest_section_limit must be between 1 and %d", MaxDigestSectionLimit)
+	}
+
+	known := make(map[models.NotificationEvent]bool, len(defaultDigestSectionOrder))
+	for _, event := range defaultDigestSectionOrder {
+		known[event] = true
+	}
+	seen := make(map[models.NotificationEvent]bool)
+	for _, event := range prefs.DigestSectionOrder {
+		if !known[event] {
+			return fmt.Errorf("unknown digest section %q", event)
+		}
+		if seen[event] {
+			return fmt.Errorf("digest section %q is listed more than once", event)
+		}
+		seen[event] = true
+	}
+	return nil
+}
+
+// digestSectionOrder returns the user's chosen sections followed by the
+// remaining sections in default order
+func digestSectionOrder(prefs *models.NotificationPreferences) []models.NotificationEvent {
+	order := make([]models.NotificationEvent, 0, len(defaultDigestSectionOrder))
+	seen := make(map[models.NotificationEvent]bool)
+	if prefs != nil {
+		for _, event := range prefs.DigestSectionOrder {
+			if !seen[event] {
+				seen[event] = true
+				order = append(order, event)
+			}
+		}
+	}
+	for _, event := range defaultDigestSectionOrder {
+		if !seen[event] {
+			seen[event] = true
+			order = append(order, event)
+		}
+	}
+	return order
+}
+
+// buildDigestSections groups notifications into ranked sections. Within a
+// section, higher priority and then newer notifications come first, and
+// each section is capped at the user's section limit.
+func buildDigestSections(notifications []*models.NotificationItem, prefs *models.NotificationPreferences) []DigestSection {
+	limit := models.DefaultDigestSectionLimit
+	if prefs != nil && prefs.DigestSectionLimit > 0 {
+		limit = prefs.DigestSectionLimit
+	}
+
+	sorted := make([]*models.NotificationItem, len(notifications))
+	copy(sorted, notifications)
+	sortNotificationsForDigest(sorted)
+
+	grouped := make(map[models.NotificationEvent][]DigestEntry)
+	for _, notification := range sorted {
+		entries := grouped[notification.Event]
+		// Grouped events collapse to one entry per work, as in the inbox,
+		// so fifty kudos on one work read as one line
+		if models.IsGroupedEvent(notification.Event) {
+			merged := false
+			for i := range entries {
+				if entries[i].Notification.SourceID == notification.SourceID {
+					entries[i].Count++
+					merged = true
+					break
+				}
+			}
+			if merged {
+				continue
+			}
+		}
+		grouped[notification.Event] = append(entries, DigestEntry{Notification: notification, Count: 1})
+	}
+
+	order := digestSectionOrder(prefs)
+	// Events missing from the default order still get a section, after the rest
+	extra := []models.NotificationEvent{}
+	listed := make(map[models.NotificationEvent]bool, len(order))
+	for _, event := range order {
+		listed[event] = true
+	}
+	for event := range grouped {
+		if !listed[event] {
+			extra = append(extra, event)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i] < extra[j] })
+	order = append(order, extra...)
+
+	sections := []DigestSection{}
+	for _, event := range order {
+		entries := grouped[event]
+		if len(entries) == 0 {
+			continue
+		}
+		section := DigestSection{Event: event, Entries: entries, Total: len(entries)}
+		if len(entries) > limit {
+			section.Entries = entries[:limit]
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// sortNotificationsForDigest orders by priority (high first), then newest
+func sortNotificationsForDigest(notifications []*models.NotificationItem) {
+	priorityOrder := map[models.NotificationPriority]int{
+		models.PriorityHigh:   3,
+		models.PriorityMedium: 2,
+		models.PriorityLow:    1,
+	}
+	sort.SliceStable(notifications, func(i, j int) bool {
+		if notifications[i].Priority != notifications[j].Priority {
+			return priorityOrder[notifications[i].Priority] > priorityOrder[notifications[j].Priority]
+		}
+		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+	})
+}
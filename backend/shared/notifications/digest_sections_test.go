@@ -0,0 +1,96 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/models"
+)
+
+func digestItem(event models.NotificationEvent, sourceID uuid.UUID, age time.Duration) *models.NotificationItem {
+	return &models.NotificationItem{
+		ID:        uuid.New(),
+		Event:     event,
+		Priority:  models.PriorityMedium,
+		SourceID:  sourceID,
+		Title:     string(event),
+		CreatedAt: time.Now().Add(-age),
+	}
+}
+
+func TestBuildDigestSectionsRanksAndCaps(t *testing.T) {
+	work := uuid.New()
+	items := []*models.NotificationItem{}
+	for i := 0; i < 30; i++ {
+		items = append(items, digestItem(models.EventKudosReceived, work, time.Duration(i)*time.Minute))
+	}
+	for i := 0; i < 8; i++ {
+		items = append(items, digestItem(models.EventWorkUpdated, uuid.New(), time.Duration(i)*time.Minute))
+	}
+
+	sections := buildDigestSections(items, &models.NotificationPreferences{DigestSectionLimit: 3})
+	if len(sections) != 2 {
+		t.Fatalf("Expected 2 sections, got %d", len(sections))
+	}
+
+	updates := sections[0]
+	if updates.Event != models.EventWorkUpdated {
+		t.Errorf("Expected work updates first, got %s", updates.Event)
+	}
+	if len(updates.Entries) != 3 || updates.MoreCount() != 5 {
+		t.Errorf("Expected 3 entries and 5 more, got %d and %d", len(updates.Entries), updates.MoreCount())
+	}
+	if !updates.Entries[0].Notification.CreatedAt.After(updates.Entries[1].Notification.CreatedAt) {
+		t.Error("Expected newest updates first")
+	}
+
+	kudos := sections[1]
+	if len(kudos.Entries) != 1 || kudos.Entries[0].Count != 30 {
+		t.Errorf("Expected kudos aggregated into one entry of 30, got %+v", kudos.Entries)
+	}
+}
+
+func TestBuildDigestSectionsUserOrder(t *testing.T) {
+	items := []*models.NotificationItem{
+		digestItem(models.EventWorkUpdated, uuid.New(), 0),
+		digestItem(models.EventCommentReceived, uuid.New(), 0),
+		digestItem(models.EventKudosReceived, uuid.New(), 0),
+	}
+	prefs := &models.NotificationPreferences{
+		DigestSectionOrder: []models.NotificationEvent{models.EventKudosReceived, models.EventCommentReceived},
+	}
+
+	sections := buildDigestSections(items, prefs)
+	want := []models.NotificationEvent{models.EventKudosReceived, models.EventCommentReceived, models.EventWorkUpdated}
+	if len(sections) != len(want) {
+		t.Fatalf("Expected %d sections, got %d", len(want), len(sections))
+	}
+	for i, event := range want {
+		if sections[i].Event != event {
+			t.Errorf("Section %d: expected %s, got %s", i, event, sections[i].Event)
+		}
+	}
+}
+
+func TestValidateDigestLayout(t *testing.T) {
+	valid := &models.NotificationPreferences{
+		DigestSectionOrder: []models.NotificationEvent{models.EventCommentReceived},
+		DigestSectionLimit: 10,
+	}
+	if err := ValidateDigestLayout(valid); err != nil {
+		t.Errorf("Expected valid layout, got %v", err)
+	}
+
+	invalid := []*models.NotificationPreferences{
+		{DigestSectionLimit: MaxDigestSectionLimit + 1},
+		{DigestSectionLimit: -1},
+		{DigestSectionOrder: []models.NotificationEvent{"not_an_event"}},
+		{DigestSectionOrder: []models.NotificationEvent{models.EventNewWork, models.EventNewWork}},
+	}
+	for i, prefs := range invalid {
+		if err := ValidateDigestLayout(prefs); err == nil {
+			t.Errorf("Case %d: expected an error", i)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+
+	"nuclear-ao3/shared/models"
+)
+
+// digestHTMLTemplate is the HTML body of a digest email. Titles and
+// descriptions come from other users, so they go through html/template's
+// escaping.
+var digestHTMLTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Nuclear AO3 Notifications</title>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #990000; color: white; padding: 20px; border-radius: 5px 5px 0 0; }
+        .content { background: #f9f9f9; padding: 20px; border-radius: 0 0 5px 5px; }
+        .notification-group { margin-bottom: 25px; }
+        .group-title { font-size: 18px; font-weight: bold; color: #990000; margin-bottom: 10px; border-bottom: 2px solid #990000; padding-bottom: 5px; }
+        .notification-item { background: white; padding: 15px; margin-bottom: 10px; border-radius: 3px; border-left: 4px solid #990000; }
+        .notification-title { font-weight: bold; margin-bottom: 5px; }
+        .notification-desc { color: #666; margin-bottom: 8px; }
+        .notification-action { margin-top: 10px; }
+        .action-button { background: #990000; color: white; padding: 8px 15px; text-decoration: none; border-radius: 3px; display: inline-block; }
+        .more-link { color: #990000; font-style: italic; }
+        .footer { text-align: center; margin-top: 30px; padding-top: 20px; border-top: 1px solid #ddd; font-size: 12px; color: #666; }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>Nuclear AO3 Notifications</h1>
+        <p>{{.Heading}}</p>
+    </div>
+    <div class="content">
+{{- range .Sections}}
+        <div class="notification-group">
+            <div class="group-title">{{.Title}} ({{.Total}})</div>
+{{- range .Entries}}
+            <div class="notification-item">
+                <div class="notification-title">{{.Title}}</div>
+{{- if .Description}}
+                <div class="notification-desc">{{.Description}}</div>
+{{- end}}
+{{- if .URL}}
+                <div class="notification-action">
+                    <a href="{{.URL}}" class="action-button">View</a>
+                </div>
+{{- end}}
+            </div>
+{{- end}}
+{{- if .MoreCount}}
+            <a href="{{.MoreURL}}" class="more-link">…and {{.MoreCount}} more</a>
+{{- end}}
+        </div>
+{{- end}}
+    </div>
+    <div class="footer">
+        <p>To manage your notification preferences, visit your account settings.<br>
+        To unsubscribe from digest emails, change your batch frequency to 'never'.</p>
+    </div>
+</body>
+</html>`))
+
+type digestHTMLData struct {
+	Heading  string
+	Sections []digestHTMLSection
+}
+
+type digestHTMLSection struct {
+	Title     string
+	Total     int
+	Entries   []digestHTMLEntry
+	MoreCount int
+	MoreURL   string
+}
+
+type digestHTMLEntry struct {
+	Title       string
+	Description string
+	URL         string
+}
+
+// digestHeading introduces a digest, dated in the user's time zone when it
+// was scheduled
+func digestHeading(digest *models.NotificationDigest, prefs *models.NotificationPreferences) string {
+	count := len(digest.Notifications)
+	noun := "notifications"
+	if count == 1 {
+		noun = "notification"
+	}
+
+	date := ""
+	if digest.ScheduledFor != nil {
+		date = digest.ScheduledFor.In(prefs.Location()).Format("Monday 2 January")
+	}
+	switch {
+	case digest.DigestType == string(models.FrequencyDaily) && date != "":
+		return fmt.Sprintf("Your daily digest for %s: %d new %s", date, count, noun)
+	case digest.DigestType == string(models.FrequencyWeekly) && date != "":
+		return fmt.Sprintf("Your weekly digest to %s: %d new %s", date, count, noun)
+	case digest.DigestType == string(frequencyPaused):
+		return fmt.Sprintf("While you were away: %d %s", count, noun)
+	default:
+		return fmt.Sprintf("You have %d new %s", count, noun)
+	}
+}
+
+// generateDigestHTML renders the HTML body of a digest
+func generateDigestHTML(digest *models.NotificationDigest, sections []DigestSection, prefs *models.NotificationPreferences) (string, error) {
+	data := digestHTMLData{Heading: digestHeading(digest, prefs)}
+	for _, section := range sections {
+		htmlSection := digestHTMLSection{
+			Title:     eventDisplayName(string(section.Event)),
+			Total:     section.Total,
+			MoreCount: section.MoreCount(),
+			MoreURL:   section.MoreURL(),
+		}
+		for _, entry := range section.Entries {
+			htmlSection.Entries = append(htmlSection.Entries, digestHTMLEntry{
+				Title:       digestEntryTitle(entry),
+				Description: entry.Notification.Description,
+				URL:         entry.Notification.ActionURL,
+			})
+		}
+		data.Sections = append(data.Sections, htmlSection)
+	}
+
+	var buf bytes.Buffer
+	if err := digestHTMLTemplate.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
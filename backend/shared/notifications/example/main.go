@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"time"
 
@@ -415,10 +416,100 @@ func (r *InMemoryNotificationRepo) GetUnreadCount(ctx context.Context, userID uu
 	return count, nil
 }
 
+func (r *InMemoryNotificationRepo) GetUnreadCountsByEvent(ctx context.Context, userID uuid.UUID) (map[models.NotificationEvent]int, error) {
+	counts := make(map[models.NotificationEvent]int)
+	for _, notif := range r.notifications {
+		if notif.UserID == userID && !notif.IsRead {
+			counts[notif.Event]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *InMemoryNotificationRepo) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter) (int, int, error) {
+	marked, unread := 0, 0
+	now := time.Now()
+	for _, notif := range r.notifications {
+		if notif.UserID != userID || notif.IsRead {
+			continue
+		}
+		if filter.Matches(notif) {
+			notif.IsRead = true
+			notif.ReadAt = &now
+			marked++
+			continue
+		}
+		unread++
+	}
+	return marked, unread, nil
+}
+
+func (r *InMemoryNotificationRepo) ListNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationItem, error) {
+	var result []*models.NotificationItem
+	for _, notif := range r.notifications {
+		if notif.UserID == userID && filter.Matches(notif) {
+			result = append(result, notif)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	start, end := pageBounds(len(result), limit, offset)
+	return result[start:end], nil
+}
+
+func (r *InMemoryNotificationRepo) ListNotificationGroups(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationGroup, error) {
+	all, _ := r.ListNotifications(ctx, userID, filter, len(r.notifications), 0)
+	groups := models.GroupNotifications(all)
+	start, end := pageBounds(len(groups), limit, offset)
+	return groups[start:end], nil
+}
+
+func (r *InMemoryNotificationRepo) SetNotificationsArchived(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter, archived bool) (int, error) {
+	changed := 0
+	now := time.Now()
+	for _, notif := range r.notifications {
+		if notif.UserID != userID || (notif.ArchivedAt != nil) == archived || !filter.Matches(notif) {
+			continue
+		}
+		if archived {
+			notif.ArchivedAt = &now
+			notif.IsRead = true
+		} else {
+			notif.ArchivedAt = nil
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// pageBounds returns the slice bounds of a page of n items
+func pageBounds(n, limit, offset int) (int, int) {
+	start := offset
+	if start > n {
+		start = n
+	}
+	end := start + limit
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
+func (r *InMemoryNotificationRepo) GetUsersAwaitingDigest(ctx context.Context, frequency models.NotificationFrequency) ([]uuid.UUID, error) {
+	seen := make(map[uuid.UUID]bool)
+	var result []uuid.UUID
+	for _, notif := range r.notifications {
+		if !notif.IsDelivered && notif.DigestFrequency == frequency && !seen[notif.UserID] {
+			seen[notif.UserID] = true
+			result = append(result, notif.UserID)
+		}
+	}
+	return result, nil
+}
+
 func (r *InMemoryNotificationRepo) GetNotificationsForBatch(ctx context.Context, userID uuid.UUID, frequency models.NotificationFrequency) ([]*models.NotificationItem, error) {
 	var result []*models.NotificationItem
 	for _, notif := range r.notifications {
-		if notif.UserID == userID && !notif.IsDelivered {
+		if notif.UserID == userID && !notif.IsDelivered && notif.DigestFrequency == frequency {
 			result = append(result, notif)
 		}
 	}
@@ -480,3 +571,10 @@ func (r *InMemoryPreferenceRepo) CreatePreferences(ctx context.Context, preferen
 	r.preferences[preferences.UserID] = preferences
 	return nil
 }
+
+func (r *InMemoryPreferenceRepo) SetPausedUntil(ctx context.Context, userID uuid.UUID, until *time.Time) error {
+	prefs, _ := r.GetPreferences(ctx, userID)
+	prefs.PausedUntil = until
+	r.preferences[userID] = prefs
+	return nil
+}
@@ -80,6 +80,30 @@ func (m *mockNotificationRepo) GetUnreadCount(ctx context.Context, userID uuid.U
 	return 0, nil
 }
 
+func (m *mockNotificationRepo) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter) (int, int, error) {
+	return 0, 0, nil
+}
+
+func (m *mockNotificationRepo) GetUnreadCountsByEvent(ctx context.Context, userID uuid.UUID) (map[models.NotificationEvent]int, error) {
+	return map[models.NotificationEvent]int{}, nil
+}
+
+func (m *mockNotificationRepo) ListNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationItem, error) {
+	return []*models.NotificationItem{}, nil
+}
+
+func (m *mockNotificationRepo) ListNotificationGroups(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationGroup, error) {
+	return []*models.NotificationGroup{}, nil
+}
+
+func (m *mockNotificationRepo) SetNotificationsArchived(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter, archived bool) (int, error) {
+	return 0, nil
+}
+
+func (m *mockNotificationRepo) GetUsersAwaitingDigest(ctx context.Context, frequency models.NotificationFrequency) ([]uuid.UUID, error) {
+	return nil, nil
+}
+
 func (m *mockNotificationRepo) GetNotificationsForBatch(ctx context.Context, userID uuid.UUID, frequency models.NotificationFrequency) ([]*models.NotificationItem, error) {
 	return []*models.NotificationItem{}, nil
 }
@@ -117,6 +141,10 @@ func (m *mockPreferenceRepo) CreatePreferences(ctx context.Context, preferences
 	return nil
 }
 
+func (m *mockPreferenceRepo) SetPausedUntil(ctx context.Context, userID uuid.UUID, until *time.Time) error {
+	return nil
+}
+
 // Mock message service
 type mockMessageService struct{}
 
@@ -249,6 +277,207 @@ func TestEventProcessing(t *testing.T) {
 	t.Log("Event processing completed successfully")
 }
 
+// recordingNotificationRepo remembers who notifications were created for
+type recordingNotificationRepo struct {
+	mockNotificationRepo
+	userIDs []uuid.UUID
+}
+
+func (m *recordingNotificationRepo) CreateNotification(ctx context.Context, notification *models.NotificationItem) error {
+	m.userIDs = append(m.userIDs, notification.UserID)
+	return nil
+}
+
+func TestEventProcessingNotifiesRecipients(t *testing.T) {
+	notificationRepo := &recordingNotificationRepo{}
+	service := NewNotificationService(
+		&mockMessageService{},
+		&mockSubscriptionRepo{},
+		notificationRepo,
+		&mockDigestRepo{},
+		&mockPreferenceRepo{},
+		NotificationServiceConfig{},
+	)
+
+	authorID := uuid.New()
+	event := &EventData{
+		Type:         models.EventWorkUnpublished,
+		SourceID:     uuid.New(),
+		SourceType:   "work",
+		Title:        "Your work was unpublished",
+		RecipientIDs: []uuid.UUID{authorID, authorID},
+	}
+
+	if err := service.ProcessEvent(context.Background(), event); err != nil {
+		t.Fatalf("Failed to process event: %v", err)
+	}
+
+	if len(notificationRepo.userIDs) != 1 || notificationRepo.userIDs[0] != authorID {
+		t.Errorf("Expected one notification for %s, got %v", authorID, notificationRepo.userIDs)
+	}
+}
+
+// blockList reports the users in it as blocking everyone
+type blockList map[uuid.UUID]bool
+
+func (b blockList) BlockersOf(ctx context.Context, actorID uuid.UUID, userIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	blockers := map[uuid.UUID]bool{}
+	for _, id := range userIDs {
+		if b[id] {
+			blockers[id] = true
+		}
+	}
+	return blockers, nil
+}
+
+func TestEventProcessingSkipsUsersBlockingActor(t *testing.T) {
+	blocker, other := uuid.New(), uuid.New()
+	notificationRepo := &recordingNotificationRepo{}
+	service := NewNotificationService(
+		&mockMessageService{},
+		&mockSubscriptionRepo{},
+		notificationRepo,
+		&mockDigestRepo{},
+		&mockPreferenceRepo{},
+		NotificationServiceConfig{Blocks: blockList{blocker: true}},
+	)
+
+	actorID := uuid.New()
+	event := &EventData{
+		Type:         models.EventWorkUnpublished,
+		SourceID:     uuid.New(),
+		SourceType:   "work",
+		Title:        "Your work was unpublished",
+		ActorID:      &actorID,
+		RecipientIDs: []uuid.UUID{blocker, other},
+	}
+
+	if err := service.ProcessEvent(context.Background(), event); err != nil {
+		t.Fatalf("Failed to process event: %v", err)
+	}
+
+	if len(notificationRepo.userIDs) != 1 || notificationRepo.userIDs[0] != other {
+		t.Errorf("Expected one notification for %s, got %v", other, notificationRepo.userIDs)
+	}
+}
+
+// targetSubscriptionRepo returns the subscriptions registered for each target
+type targetSubscriptionRepo struct {
+	mockSubscriptionRepo
+	byTarget map[uuid.UUID][]*models.Subscription
+}
+
+func (m *targetSubscriptionRepo) FindByTarget(ctx context.Context, targetType models.SubscriptionType, targetID uuid.UUID) ([]*models.Subscription, error) {
+	return m.byTarget[targetID], nil
+}
+
+func TestChapterPostedFansOutToSubscribers(t *testing.T) {
+	workID, authorID, seriesID := uuid.New(), uuid.New(), uuid.New()
+	workReader, authorReader, seriesReader := uuid.New(), uuid.New(), uuid.New()
+
+	subscribe := func(userID uuid.UUID, subType models.SubscriptionType, targetID uuid.UUID) *models.Subscription {
+		return &models.Subscription{
+			ID: uuid.New(), UserID: userID, Type: subType, TargetID: targetID, IsActive: true,
+			Events: []models.NotificationEvent{models.EventWorkUpdated},
+		}
+	}
+	repo := &targetSubscriptionRepo{byTarget: map[uuid.UUID][]*models.Subscription{
+		workID: {subscribe(workReader, models.SubscriptionWork, workID)},
+		authorID: {
+			subscribe(authorReader, models.SubscriptionAuthor, authorID),
+			// Also follows the work, so must only hear about it once
+			subscribe(workReader, models.SubscriptionAuthor, authorID),
+		},
+		seriesID: {
+			subscribe(seriesReader, models.SubscriptionSeries, seriesID),
+			// The author following their own series is the actor here
+			subscribe(authorID, models.SubscriptionSeries, seriesID),
+		},
+	}}
+
+	notificationRepo := &recordingNotificationRepo{}
+	service := NewNotificationService(
+		&mockMessageService{},
+		repo,
+		notificationRepo,
+		&mockDigestRepo{},
+		&mockPreferenceRepo{},
+		NotificationServiceConfig{},
+	)
+
+	event := &EventData{
+		Type:       models.EventWorkUpdated,
+		SourceID:   workID,
+		SourceType: "work",
+		Title:      "New chapter",
+		ActorID:    &authorID,
+		AuthorIDs:  []uuid.UUID{authorID},
+		SeriesIDs:  []uuid.UUID{seriesID},
+	}
+	if err := service.ProcessEvent(context.Background(), event); err != nil {
+		t.Fatalf("Failed to process event: %v", err)
+	}
+
+	got := make(map[uuid.UUID]int)
+	for _, id := range notificationRepo.userIDs {
+		got[id]++
+	}
+	for _, reader := range []uuid.UUID{workReader, authorReader, seriesReader} {
+		if got[reader] != 1 {
+			t.Errorf("Expected one notification for %s, got %d", reader, got[reader])
+		}
+	}
+	if got[authorID] != 0 {
+		t.Errorf("Actor should not be notified, got %d", got[authorID])
+	}
+}
+
+func TestNewWorkNotifiesTagFollowers(t *testing.T) {
+	authorID, tagID, otherTagID := uuid.New(), uuid.New(), uuid.New()
+	tagFollower, authorAndTagFollower, otherFollower := uuid.New(), uuid.New(), uuid.New()
+
+	subscribe := func(userID uuid.UUID, subType models.SubscriptionType, targetID uuid.UUID) *models.Subscription {
+		return &models.Subscription{
+			ID: uuid.New(), UserID: userID, Type: subType, TargetID: targetID, IsActive: true,
+			Frequency: models.FrequencyDaily, Events: []models.NotificationEvent{models.EventNewWork},
+		}
+	}
+	repo := &targetSubscriptionRepo{byTarget: map[uuid.UUID][]*models.Subscription{
+		authorID: {subscribe(authorAndTagFollower, models.SubscriptionAuthor, authorID)},
+		tagID: {
+			subscribe(tagFollower, models.SubscriptionTag, tagID),
+			subscribe(authorAndTagFollower, models.SubscriptionTag, tagID),
+		},
+		otherTagID: {subscribe(otherFollower, models.SubscriptionTag, otherTagID)},
+	}}
+
+	notificationRepo := &recordingNotificationRepo{}
+	service := NewNotificationService(&mockMessageService{}, repo, notificationRepo,
+		&mockDigestRepo{}, &mockPreferenceRepo{}, NotificationServiceConfig{})
+
+	event := &EventData{
+		Type:      models.EventNewWork,
+		SourceID:  uuid.New(),
+		ActorID:   &authorID,
+		AuthorIDs: []uuid.UUID{authorID},
+		TagIDs:    []uuid.UUID{tagID},
+	}
+	if err := service.ProcessEvent(context.Background(), event); err != nil {
+		t.Fatalf("Failed to process event: %v", err)
+	}
+
+	got := make(map[uuid.UUID]int)
+	for _, id := range notificationRepo.userIDs {
+		got[id]++
+	}
+	if got[tagFollower] != 1 || got[authorAndTagFollower] != 1 {
+		t.Errorf("Expected one notification each for the tag's followers, got %v", got)
+	}
+	if got[otherFollower] != 0 {
+		t.Errorf("Followers of other tags should not be notified, got %d", got[otherFollower])
+	}
+}
+
 func TestSmartFilterCreation(t *testing.T) {
 	filter := NewSmartFilter()
 	if filter == nil {
@@ -308,3 +537,162 @@ func TestRuleEngineCreation(t *testing.T) {
 		t.Errorf("Expected ActionAllow, got %v", action.Action)
 	}
 }
+
+func TestDeliveryFrequency(t *testing.T) {
+	cases := []struct {
+		event, subscription, want models.NotificationFrequency
+	}{
+		{models.FrequencyImmediate, models.FrequencyWeekly, models.FrequencyWeekly},
+		{models.FrequencyDaily, models.FrequencyImmediate, models.FrequencyDaily},
+		{models.FrequencyWeekly, models.FrequencyDaily, models.FrequencyWeekly},
+		{models.FrequencyNever, models.FrequencyDaily, models.FrequencyNever},
+		{models.FrequencyBatched, "", models.FrequencyBatched},
+	}
+	for _, tc := range cases {
+		if got := deliveryFrequency(tc.event, tc.subscription); got != tc.want {
+			t.Errorf("deliveryFrequency(%q, %q) = %q, want %q", tc.event, tc.subscription, got, tc.want)
+		}
+	}
+}
+
+func TestMutedSubscriptionDoesNotNotify(t *testing.T) {
+	workID, authorID := uuid.New(), uuid.New()
+	mutedOnly, mutedButFollowsWork := uuid.New(), uuid.New()
+
+	subscribe := func(userID uuid.UUID, subType models.SubscriptionType, targetID uuid.UUID, frequency models.NotificationFrequency) *models.Subscription {
+		return &models.Subscription{
+			ID: uuid.New(), UserID: userID, Type: subType, TargetID: targetID, IsActive: true,
+			Frequency: frequency, Events: []models.NotificationEvent{models.EventWorkUpdated},
+		}
+	}
+	repo := &targetSubscriptionRepo{byTarget: map[uuid.UUID][]*models.Subscription{
+		workID: {subscribe(mutedButFollowsWork, models.SubscriptionWork, workID, models.FrequencyImmediate)},
+		authorID: {
+			subscribe(mutedOnly, models.SubscriptionAuthor, authorID, models.FrequencyMuted),
+			subscribe(mutedButFollowsWork, models.SubscriptionAuthor, authorID, models.FrequencyMuted),
+		},
+	}}
+
+	notificationRepo := &recordingNotificationRepo{}
+	service := NewNotificationService(&mockMessageService{}, repo, notificationRepo,
+		&mockDigestRepo{}, &mockPreferenceRepo{}, NotificationServiceConfig{})
+
+	event := &EventData{Type: models.EventWorkUpdated, SourceID: workID, AuthorIDs: []uuid.UUID{authorID}}
+	if err := service.ProcessEvent(context.Background(), event); err != nil {
+		t.Fatalf("Failed to process event: %v", err)
+	}
+
+	got := make(map[uuid.UUID]int)
+	for _, id := range notificationRepo.userIDs {
+		got[id]++
+	}
+	if got[mutedOnly] != 0 {
+		t.Errorf("Muted subscriber should not be notified, got %d", got[mutedOnly])
+	}
+	if got[mutedButFollowsWork] != 1 {
+		t.Errorf("Expected one notification through the unmuted subscription, got %d", got[mutedButFollowsWork])
+	}
+}
+
+func TestBatchesAreHeldByFrequency(t *testing.T) {
+	bp := &BatchProcessor{maxBatchSize: 2, pendingBatches: make(map[batchKey]*pendingBatch)}
+	userID := uuid.New()
+	notification := func() *models.NotificationItem {
+		return &models.NotificationItem{ID: uuid.New(), UserID: userID, Event: models.EventNewWork}
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := bp.AddToBatch(context.Background(), notification(), models.FrequencyWeekly); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bp.AddToBatch(context.Background(), notification(), models.FrequencyDaily)
+
+	weekly := bp.pendingBatches[batchKey{userID.String(), models.FrequencyWeekly}]
+	if weekly == nil || len(weekly.notifications) != 3 {
+		t.Fatalf("Weekly batch should keep collecting past the size cap, got %+v", weekly)
+	}
+	daily := bp.pendingBatches[batchKey{userID.String(), models.FrequencyDaily}]
+	if daily == nil || len(daily.notifications) != 1 {
+		t.Fatalf("Daily notifications should batch separately, got %+v", daily)
+	}
+
+	now := weekly.since
+	if weekly.due(models.FrequencyWeekly, now.Add(6*24*time.Hour)) {
+		t.Error("Weekly batch should not be due after six days")
+	}
+	if !weekly.due(models.FrequencyWeekly, now.Add(7*24*time.Hour)) {
+		t.Error("Weekly batch should be due after a week")
+	}
+	if !(&pendingBatch{notifications: weekly.notifications, since: now}).due(models.FrequencyBatched, now) {
+		t.Error("Plain batches should go out on the next tick")
+	}
+}
+
+// pausedPreferenceRepo returns preferences paused until a fixed time, with
+// account security alerts turned on
+type pausedPreferenceRepo struct {
+	mockPreferenceRepo
+	until time.Time
+}
+
+func (m *pausedPreferenceRepo) GetPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error) {
+	prefs := models.DefaultNotificationPreferences(userID)
+	prefs.PausedUntil = &m.until
+	prefs.EventPreferences[models.EventAccountSecurity] = models.EventPreference{
+		Enabled:   true,
+		Channels:  []models.DeliveryChannel{models.ChannelEmail},
+		Frequency: models.FrequencyImmediate,
+		Priority:  models.PriorityHigh,
+	}
+	return &prefs, nil
+}
+
+func TestPausedNotificationsAreHeldUntilResume(t *testing.T) {
+	until := time.Now().Add(3 * 24 * time.Hour)
+	service := NewNotificationService(&mockMessageService{}, &mockSubscriptionRepo{}, &mockNotificationRepo{},
+		&mockDigestRepo{}, &pausedPreferenceRepo{until: until}, NotificationServiceConfig{})
+	bp := &BatchProcessor{service: service, maxBatchSize: 1, pendingBatches: make(map[batchKey]*pendingBatch)}
+	service.batchProcessor = bp
+
+	userID := uuid.New()
+	for _, event := range []models.NotificationEvent{models.EventCommentReceived, models.EventAccountSecurity} {
+		if err := service.createNotificationForUser(context.Background(), &EventData{Type: event, Title: "t"}, userID, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := batchKey{userID.String(), frequencyPaused}
+	held := bp.pendingBatches[key]
+	if held == nil || len(held.notifications) != 1 || held.notifications[0].Event != models.EventCommentReceived {
+		t.Fatalf("Only the non-critical notification should be held, got %+v", held)
+	}
+	if held.due(frequencyPaused, until.Add(-time.Minute)) || !held.due(frequencyPaused, until) {
+		t.Error("Held notifications should go out when the pause ends")
+	}
+
+	if err := service.ResumeNotifications(context.Background(), userID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bp.pendingBatches[key]; ok {
+		t.Error("Resuming should send the held notifications")
+	}
+}
+
+func TestDisableNotificationsValidatesDuration(t *testing.T) {
+	service := NewNotificationService(&mockMessageService{}, &mockSubscriptionRepo{}, &mockNotificationRepo{},
+		&mockDigestRepo{}, &mockPreferenceRepo{}, NotificationServiceConfig{})
+	userID := uuid.New().String()
+
+	if err := service.DisableNotifications(context.Background(), userID, 7*24*time.Hour); err != nil {
+		t.Errorf("A week's pause should be allowed: %v", err)
+	}
+	for _, d := range []time.Duration{0, (MaxPauseDays + 1) * 24 * time.Hour} {
+		if service.DisableNotifications(context.Background(), userID, d) == nil {
+			t.Errorf("A pause of %v should be rejected", d)
+		}
+	}
+	if service.DisableNotifications(context.Background(), "not-a-uuid", time.Hour) == nil {
+		t.Error("An invalid user ID should be rejected")
+	}
+}
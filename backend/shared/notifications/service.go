@@ -21,7 +21,9 @@ type NotificationService struct {
 	preferenceRepo   PreferenceRepository
 	ruleEngine       *RuleEngine
 	batchProcessor   *BatchProcessor
+	digestScheduler  *DigestScheduler
 	smartFilter      *SmartFilter
+	blocks           BlockChecker
 }
 
 // NotificationServiceConfig configures the notification service
@@ -31,6 +33,16 @@ type NotificationServiceConfig struct {
 	MaxBatchSize         int
 	EnableSmartFiltering bool
 	DefaultQuietHours    []string // ["22:00", "08:00"] format
+
+	// EnableDigestScheduler leaves daily and weekly notifications in the
+	// database for the digest scheduler, which sends them at each user's
+	// local digest time, instead of holding them in memory
+	EnableDigestScheduler bool
+	DigestScheduler       DigestSchedulerConfig
+
+	// Blocks, when set, keeps users from being notified about what people
+	// they block do
+	Blocks BlockChecker
 }
 
 // NewNotificationService creates a new notification service
@@ -50,11 +62,15 @@ func NewNotificationService(
 		preferenceRepo:   preferenceRepo,
 		ruleEngine:       NewRuleEngine(),
 		smartFilter:      NewSmartFilter(),
+		blocks:           config.Blocks,
 	}
 
 	if config.EnableBatching {
 		ns.batchProcessor = NewBatchProcessor(ns, config.BatchIntervalMinutes, config.MaxBatchSize)
 	}
+	if config.EnableDigestScheduler {
+		ns.digestScheduler = NewDigestScheduler(ns, config.DigestScheduler)
+	}
 
 	return ns
 }
@@ -71,17 +87,56 @@ func (ns *NotificationService) ProcessEvent(ctx context.Context, event *EventDat
 
 	log.Printf("Found %d matching subscriptions", len(subscriptions))
 
+	// Users blocking the actor hear nothing about what they do
+	blockers, err := ns.findBlockers(ctx, event, subscriptions)
+	if err != nil {
+		return fmt.Errorf("failed to check blocks: %w", err)
+	}
+
 	// Create notifications for each subscription
+	notified := make(map[uuid.UUID]bool)
 	for _, subscription := range subscriptions {
+		if blockers[subscription.UserID] {
+			continue
+		}
+		notified[subscription.UserID] = true
 		if err := ns.createNotificationForSubscription(ctx, event, subscription); err != nil {
 			log.Printf("Failed to create notification for subscription %s: %v", subscription.ID, err)
 			continue
 		}
 	}
 
+	// Notify explicit recipients who were not already reached by a subscription
+	for _, recipientID := range event.RecipientIDs {
+		if notified[recipientID] || blockers[recipientID] {
+			continue
+		}
+		notified[recipientID] = true
+		if err := ns.createNotificationForUser(ctx, event, recipientID, ""); err != nil {
+			log.Printf("Failed to create notification for user %s: %v", recipientID, err)
+		}
+	}
+
 	return nil
 }
 
+// findBlockers returns which of the event's subscribers and recipients
+// block its actor
+func (ns *NotificationService) findBlockers(ctx context.Context, event *EventData, subscriptions []*models.Subscription) (map[uuid.UUID]bool, error) {
+	if ns.blocks == nil || event.ActorID == nil {
+		return nil, nil
+	}
+	userIDs := make([]uuid.UUID, 0, len(subscriptions)+len(event.RecipientIDs))
+	for _, subscription := range subscriptions {
+		userIDs = append(userIDs, subscription.UserID)
+	}
+	userIDs = append(userIDs, event.RecipientIDs...)
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	return ns.blocks.BlockersOf(ctx, *event.ActorID, userIDs)
+}
+
 // findMatchingSubscriptions finds all subscriptions that should be notified for an event
 func (ns *NotificationService) findMatchingSubscriptions(ctx context.Context, event *EventData) ([]*models.Subscription, error) {
 	var allSubscriptions []*models.Subscription
@@ -137,12 +192,28 @@ func (ns *NotificationService) findMatchingSubscriptions(ctx context.Context, ev
 				allSubscriptions = append(allSubscriptions, authorSubs...)
 			}
 		}
+
+		// Followers of any of the work's canonical tags
+		for _, tagID := range event.TagIDs {
+			tagSubs, err := ns.subscriptionRepo.FindByTarget(ctx, models.SubscriptionTag, tagID)
+			if err != nil {
+				continue
+			}
+			allSubscriptions = append(allSubscriptions, tagSubs...)
+		}
 	}
 
-	// Filter subscriptions that have this event enabled
+	// Filter subscriptions that have this event enabled. A reader following
+	// the work, its author and its series hears about it once, through the
+	// most specific subscription, and the actor never notifies themselves.
 	var matchingSubscriptions []*models.Subscription
+	seen := make(map[uuid.UUID]bool)
 	for _, sub := range allSubscriptions {
+		if seen[sub.UserID] || (event.ActorID != nil && sub.UserID == *event.ActorID) {
+			continue
+		}
 		if ns.subscriptionMatchesEvent(sub, event) {
+			seen[sub.UserID] = true
 			matchingSubscriptions = append(matchingSubscriptions, sub)
 		}
 	}
@@ -152,7 +223,7 @@ func (ns *NotificationService) findMatchingSubscriptions(ctx context.Context, ev
 
 // subscriptionMatchesEvent checks if a subscription should be notified for an event
 func (ns *NotificationService) subscriptionMatchesEvent(sub *models.Subscription, event *EventData) bool {
-	if !sub.IsActive {
+	if !sub.IsActive || sub.Frequency == models.FrequencyMuted {
 		return false
 	}
 
@@ -215,13 +286,21 @@ func (ns *NotificationService) subscriptionMatchesEvent(sub *models.Subscription
 	return true
 }
 
-// createNotificationForSubscription creates a notification for a specific subscription
+// createNotificationForSubscription creates a notification for a specific
+// subscription, delivered no more often than the subscription allows
 func (ns *NotificationService) createNotificationForSubscription(ctx context.Context, event *EventData, subscription *models.Subscription) error {
+	return ns.createNotificationForUser(ctx, event, subscription.UserID, subscription.Frequency)
+}
+
+// createNotificationForUser creates and delivers a notification for one user.
+// subscriptionFrequency is the frequency of the subscription that matched,
+// or empty for a direct recipient.
+func (ns *NotificationService) createNotificationForUser(ctx context.Context, event *EventData, userID uuid.UUID, subscriptionFrequency models.NotificationFrequency) error {
 	// Get user preferences
-	prefs, err := ns.preferenceRepo.GetPreferences(ctx, subscription.UserID)
+	prefs, err := ns.preferenceRepo.GetPreferences(ctx, userID)
 	if err != nil {
-		log.Printf("Failed to get preferences for user %s, using defaults: %v", subscription.UserID, err)
-		defaultPrefs := models.DefaultNotificationPreferences(subscription.UserID)
+		log.Printf("Failed to get preferences for user %s, using defaults: %v", userID, err)
+		defaultPrefs := models.DefaultNotificationPreferences(userID)
 		prefs = &defaultPrefs
 	}
 
@@ -234,7 +313,7 @@ func (ns *NotificationService) createNotificationForSubscription(ctx context.Con
 	// Create notification item
 	notification := &models.NotificationItem{
 		ID:          uuid.New(),
-		UserID:      subscription.UserID,
+		UserID:      userID,
 		Event:       event.Type,
 		Priority:    eventPref.Priority,
 		SourceID:    event.SourceID,
@@ -252,7 +331,7 @@ func (ns *NotificationService) createNotificationForSubscription(ctx context.Con
 	if ns.smartFilter != nil {
 		shouldNotify, modifiedNotification := ns.smartFilter.ShouldNotify(ctx, prefs, notification)
 		if !shouldNotify {
-			log.Printf("Smart filter blocked notification for user %s", subscription.UserID)
+			log.Printf("Smart filter blocked notification for user %s", userID)
 			return nil
 		}
 		if modifiedNotification != nil {
@@ -265,7 +344,7 @@ func (ns *NotificationService) createNotificationForSubscription(ctx context.Con
 		action := ns.ruleEngine.EvaluateNotification(ctx, prefs, notification)
 		switch action.Action {
 		case models.ActionBlock:
-			log.Printf("User rule blocked notification for user %s", subscription.UserID)
+			log.Printf("User rule blocked notification for user %s", userID)
 			return nil
 		case models.ActionModify:
 			if action.ModifiedNotification != nil {
@@ -274,25 +353,120 @@ func (ns *NotificationService) createNotificationForSubscription(ctx context.Con
 		}
 	}
 
+	// Handle delivery based on frequency preference
+	frequency := deliveryFrequency(eventPref.Frequency, subscriptionFrequency)
+	held := prefs.IsPaused(notification.CreatedAt) && !models.IsCriticalEvent(notification.Event)
+	scheduled := ns.digestScheduler != nil && !held &&
+		(frequency == models.FrequencyDaily || frequency == models.FrequencyWeekly)
+	if scheduled {
+		notification.DigestFrequency = frequency
+	}
+
 	// Save notification
 	if err := ns.notificationRepo.CreateNotification(ctx, notification); err != nil {
 		return fmt.Errorf("failed to save notification: %w", err)
 	}
+	if scheduled {
+		return nil // The digest scheduler sends it
+	}
 
-	// Handle delivery based on frequency preference
-	switch eventPref.Frequency {
+	// While the user has paused notifications only critical ones go out;
+	// the rest wait for the digest sent when the pause ends. Without a batch
+	// processor they stay in the inbox undelivered.
+	if held {
+		if frequency == models.FrequencyNever || ns.batchProcessor == nil {
+			return nil
+		}
+		return ns.batchProcessor.HoldUntil(ctx, notification, *prefs.PausedUntil)
+	}
+
+	channels := enabledChannels(prefs, eventPref.Channels)
+	switch frequency {
 	case models.FrequencyImmediate:
-		return ns.deliverNotificationImmediate(ctx, notification, eventPref.Channels)
+		return ns.deliverNotificationImmediate(ctx, notification, channels)
 	case models.FrequencyBatched, models.FrequencyDaily, models.FrequencyWeekly:
 		if ns.batchProcessor != nil {
-			return ns.batchProcessor.AddToBatch(ctx, notification)
+			return ns.batchProcessor.AddToBatch(ctx, notification, frequency)
 		}
-		return ns.deliverNotificationImmediate(ctx, notification, eventPref.Channels)
+		return ns.deliverNotificationImmediate(ctx, notification, channels)
 	case models.FrequencyNever:
 		return nil // Just save, don't deliver
 	default:
-		return ns.deliverNotificationImmediate(ctx, notification, eventPref.Channels)
+		return ns.deliverNotificationImmediate(ctx, notification, channels)
+	}
+}
+
+// MaxPauseDays is the longest a user can pause notifications at once
+const MaxPauseDays = 90
+
+// DisableNotifications pauses all but critical notifications for a user for
+// duration. Pausing again replaces the end of the current pause.
+func (ns *NotificationService) DisableNotifications(ctx context.Context, userID string, duration time.Duration) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID: %w", err)
+	}
+	if duration <= 0 || duration > MaxPauseDays*24*time.Hour {
+		return fmt.Errorf("pause must be between 1 and %d days", MaxPauseDays)
+	}
+
+	until := time.Now().Add(duration)
+	if err := ns.preferenceRepo.SetPausedUntil(ctx, uid, &until); err != nil {
+		return fmt.Errorf("failed to pause notifications: %w", err)
+	}
+	if ns.batchProcessor != nil {
+		ns.batchProcessor.rescheduleHeld(uid, until)
+	}
+	return nil
+}
+
+// ResumeNotifications ends a user's pause early and sends the notifications
+// held during it as a digest
+func (ns *NotificationService) ResumeNotifications(ctx context.Context, userID uuid.UUID) error {
+	if err := ns.preferenceRepo.SetPausedUntil(ctx, userID, nil); err != nil {
+		return fmt.Errorf("failed to resume notifications: %w", err)
+	}
+	if ns.batchProcessor != nil {
+		return ns.batchProcessor.releaseHeld(ctx, userID)
 	}
+	return nil
+}
+
+// frequencyRank orders delivery frequencies from most to least often
+var frequencyRank = map[models.NotificationFrequency]int{
+	models.FrequencyImmediate: 0,
+	models.FrequencyBatched:   1,
+	models.FrequencyDaily:     2,
+	models.FrequencyWeekly:    3,
+	models.FrequencyNever:     4,
+}
+
+// deliveryFrequency combines the user's preference for an event type with
+// the frequency of the subscription that matched: whichever is less often
+// wins, so a busy followed tag can be quieted to a daily or weekly digest
+// without making anything else louder. Unknown values leave the event
+// preference in charge.
+func deliveryFrequency(eventFrequency, subscriptionFrequency models.NotificationFrequency) models.NotificationFrequency {
+	subRank, ok := frequencyRank[subscriptionFrequency]
+	if !ok {
+		return eventFrequency
+	}
+	if eventRank, ok := frequencyRank[eventFrequency]; ok && eventRank >= subRank {
+		return eventFrequency
+	}
+	return subscriptionFrequency
+}
+
+// enabledChannels narrows an event's preferred channels to those the user
+// has switched on
+func enabledChannels(prefs *models.NotificationPreferences, channels []models.DeliveryChannel) []models.DeliveryChannel {
+	enabled := make([]models.DeliveryChannel, 0, len(channels))
+	for _, channel := range channels {
+		if prefs.ChannelEnabled(channel) {
+			enabled = append(enabled, channel)
+		}
+	}
+	return enabled
 }
 
 // deliverNotificationImmediate delivers a notification immediately
@@ -329,8 +503,9 @@ func (ns *NotificationService) deliverNotificationImmediate(ctx context.Context,
 	}
 
 	message := &models.Message{
-		Type:    messageType,
-		Content: *content,
+		Type:     messageType,
+		Content:  *content,
+		Metadata: map[string]interface{}{models.MessageMetaNotificationEvent: string(notification.Event)},
 		Recipients: []models.Recipient{
 			{
 				UserID:   notification.UserID,
@@ -339,7 +514,10 @@ func (ns *NotificationService) deliverNotificationImmediate(ctx context.Context,
 					UserID:        notification.UserID,
 					GlobalEnabled: true,
 					Channels:      channelConfigs,
-					UpdatedAt:     time.Now(),
+					MessageTypes: map[models.MessageType]models.MessageTypeConfig{
+						messageType: {Enabled: true, Channels: channels, Frequency: models.FrequencyImmediate},
+					},
+					UpdatedAt: time.Now(),
 				},
 			},
 		},
@@ -363,7 +541,7 @@ func (ns *NotificationService) mapEventToMessageType(event models.NotificationEv
 	switch event {
 	case models.EventWorkUpdated:
 		return models.MessageSubscriptionUpdate
-	case models.EventCommentReceived, models.EventCommentReplied:
+	case models.EventCommentReceived, models.EventCommentReplied, models.EventCommentMention:
 		return models.MessageCommentNotify
 	case models.EventKudosReceived:
 		return models.MessageKudosNotify
@@ -417,9 +595,14 @@ type EventData struct {
 	ActorName   string                   `json:"actor_name"`
 	ExtraData   map[string]interface{}   `json:"extra_data,omitempty"`
 
+	// RecipientIDs are notified directly, without a subscription, for
+	// events about their own content (e.g. a scheduled unpublish)
+	RecipientIDs []uuid.UUID `json:"recipient_ids,omitempty"`
+
 	// Content metadata for filtering
 	AuthorIDs   []uuid.UUID `json:"author_ids,omitempty"`
 	SeriesIDs   []uuid.UUID `json:"series_ids,omitempty"`
+	TagIDs      []uuid.UUID `json:"tag_ids,omitempty"` // canonical tags, for tag follows
 	Tags        []string    `json:"tags,omitempty"`
 	Rating      string      `json:"rating,omitempty"`
 	WordCount   int         `json:"word_count,omitempty"`
@@ -444,18 +627,49 @@ type NotificationRepository interface {
 	DeleteNotification(ctx context.Context, id uuid.UUID) error
 	GetUserNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.NotificationItem, error)
 	GetUnreadCount(ctx context.Context, userID uuid.UUID) (int, error)
+	// MarkNotificationsRead marks the user's unread notifications matching
+	// filter as read, returning how many changed and the unread count left.
+	MarkNotificationsRead(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter) (marked, unread int, err error)
+	// GetUnreadCountsByEvent counts the user's unread notifications for
+	// each event that has any
+	GetUnreadCountsByEvent(ctx context.Context, userID uuid.UUID) (map[models.NotificationEvent]int, error)
+	// ListNotifications lists the user's notifications matching filter,
+	// newest first
+	ListNotifications(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationItem, error)
+	// ListNotificationGroups lists the user's notifications matching filter
+	// grouped as models.GroupNotifications groups them, newest group first
+	ListNotificationGroups(ctx context.Context, userID uuid.UUID, filter models.NotificationInboxFilter, limit, offset int) ([]*models.NotificationGroup, error)
+	// SetNotificationsArchived archives, or with archived false restores,
+	// the user's notifications matching filter and returns how many
+	// changed. Archiving also marks them read.
+	SetNotificationsArchived(ctx context.Context, userID uuid.UUID, filter models.NotificationReadFilter, archived bool) (int, error)
+	// GetNotificationsForBatch returns the user's undelivered notifications
+	// waiting for the digest of frequency, oldest first
 	GetNotificationsForBatch(ctx context.Context, userID uuid.UUID, frequency models.NotificationFrequency) ([]*models.NotificationItem, error)
+	// GetUsersAwaitingDigest lists users with notifications waiting for the
+	// digest of frequency
+	GetUsersAwaitingDigest(ctx context.Context, frequency models.NotificationFrequency) ([]uuid.UUID, error)
 }
 
 type DigestRepository interface {
+	// CreateDigest saves a new digest, returning ErrDigestExists if one was
+	// already created for its user, type and ScheduledFor slot
 	CreateDigest(ctx context.Context, digest *models.NotificationDigest) error
 	GetDigest(ctx context.Context, id uuid.UUID) (*models.NotificationDigest, error)
 	UpdateDigest(ctx context.Context, digest *models.NotificationDigest) error
 	GetPendingDigests(ctx context.Context, digestType string) ([]*models.NotificationDigest, error)
 }
 
+// BlockChecker looks up user blocks
+type BlockChecker interface {
+	// BlockersOf returns which of userIDs block actorID
+	BlockersOf(ctx context.Context, actorID uuid.UUID, userIDs []uuid.UUID) (map[uuid.UUID]bool, error)
+}
+
 type PreferenceRepository interface {
 	GetPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error)
 	UpdatePreferences(ctx context.Context, preferences *models.NotificationPreferences) error
 	CreatePreferences(ctx context.Context, preferences *models.NotificationPreferences) error
+	// SetPausedUntil starts, extends or (with nil) ends a user's pause
+	SetPausedUntil(ctx context.Context, userID uuid.UUID, until *time.Time) error
 }
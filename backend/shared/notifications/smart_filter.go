@@ -50,27 +50,7 @@ func (sf *SmartFilter) ShouldNotify(ctx context.Context, prefs *models.Notificat
 
 // isInQuietHours checks if the current time is within user's quiet hours
 func (sf *SmartFilter) isInQuietHours(prefs *models.NotificationPreferences, now time.Time) bool {
-	if prefs.QuietHoursStart == nil || prefs.QuietHoursEnd == nil {
-		return false
-	}
-
-	// Convert to user's timezone
-	location, err := time.LoadLocation(prefs.Timezone)
-	if err != nil {
-		location = time.UTC
-	}
-
-	userTime := now.In(location)
-	startTime := prefs.QuietHoursStart.In(location)
-	endTime := prefs.QuietHoursEnd.In(location)
-
-	// Handle overnight quiet hours (e.g., 22:00 to 08:00)
-	if startTime.After(endTime) {
-		return userTime.After(startTime) || userTime.Before(endTime)
-	}
-
-	// Normal quiet hours (e.g., 01:00 to 06:00)
-	return userTime.After(startTime) && userTime.Before(endTime)
+	return prefs.InQuietHours(now)
 }
 
 // isRateLimited checks if the user has exceeded their notification rate limits
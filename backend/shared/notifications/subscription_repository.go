@@ -0,0 +1,162 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"nuclear-ao3/shared/models"
+)
+
+// PostgresSubscriptionRepository stores subscriptions in the subscriptions
+// table. Work-service writes through it and notification-service reads
+// through it, so both sides agree on who follows what.
+type PostgresSubscriptionRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresSubscriptionRepository creates a SubscriptionRepository backed by db
+func NewPostgresSubscriptionRepository(db *sql.DB) SubscriptionRepository {
+	return &PostgresSubscriptionRepository{db: db}
+}
+
+const subscriptionColumns = `
+	id, user_id, type, target_id, COALESCE(target_name, ''), events,
+	COALESCE(frequency, 'immediate'), last_notified, is_active, created_at, updated_at,
+	filter_completed, filter_rating, filter_warnings, filter_tags, min_word_count, max_word_count`
+
+type subscriptionScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubscription(row subscriptionScanner) (*models.Subscription, error) {
+	var subscription models.Subscription
+	var events []string
+	var lastNotified sql.NullTime
+	var filterCompleted sql.NullBool
+	var minWordCount, maxWordCount sql.NullInt64
+
+	err := row.Scan(
+		&subscription.ID, &subscription.UserID, &subscription.Type, &subscription.TargetID,
+		&subscription.TargetName, pq.Array(&events), &subscription.Frequency, &lastNotified,
+		&subscription.IsActive, &subscription.CreatedAt, &subscription.UpdatedAt,
+		&filterCompleted, pq.Array(&subscription.FilterRating), pq.Array(&subscription.FilterWarnings),
+		pq.Array(&subscription.FilterTags), &minWordCount, &maxWordCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.Events = make([]models.NotificationEvent, len(events))
+	for i, event := range events {
+		subscription.Events[i] = models.NotificationEvent(event)
+	}
+	if lastNotified.Valid {
+		subscription.LastNotified = &lastNotified.Time
+	}
+	if filterCompleted.Valid {
+		subscription.FilterCompleted = &filterCompleted.Bool
+	}
+	if minWordCount.Valid {
+		n := int(minWordCount.Int64)
+		subscription.MinWordCount = &n
+	}
+	if maxWordCount.Valid {
+		n := int(maxWordCount.Int64)
+		subscription.MaxWordCount = &n
+	}
+	return &subscription, nil
+}
+
+func (r *PostgresSubscriptionRepository) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]*models.Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []*models.Subscription{}
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+func subscriptionEventNames(events []models.NotificationEvent) []string {
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = string(event)
+	}
+	return names
+}
+
+func (r *PostgresSubscriptionRepository) CreateSubscription(ctx context.Context, subscription *models.Subscription) error {
+	if subscription.Frequency == "" {
+		subscription.Frequency = models.FrequencyImmediate
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO subscriptions
+		(id, user_id, type, target_id, target_name, events, frequency, is_active, created_at, updated_at,
+		 filter_completed, filter_rating, filter_warnings, filter_tags, min_word_count, max_word_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+		subscription.ID, subscription.UserID, subscription.Type, subscription.TargetID,
+		subscription.TargetName, pq.Array(subscriptionEventNames(subscription.Events)),
+		subscription.Frequency, subscription.IsActive, subscription.CreatedAt, subscription.UpdatedAt,
+		subscription.FilterCompleted, pq.Array(subscription.FilterRating),
+		pq.Array(subscription.FilterWarnings), pq.Array(subscription.FilterTags),
+		subscription.MinWordCount, subscription.MaxWordCount,
+	)
+	return err
+}
+
+func (r *PostgresSubscriptionRepository) GetSubscription(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions WHERE id = $1`, id)
+	return scanSubscription(row)
+}
+
+func (r *PostgresSubscriptionRepository) UpdateSubscription(ctx context.Context, subscription *models.Subscription) error {
+	subscription.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET target_name = $1, events = $2, frequency = $3, is_active = $4, updated_at = $5,
+		    filter_completed = $6, filter_rating = $7, filter_warnings = $8, filter_tags = $9,
+		    min_word_count = $10, max_word_count = $11
+		WHERE id = $12`,
+		subscription.TargetName, pq.Array(subscriptionEventNames(subscription.Events)),
+		subscription.Frequency, subscription.IsActive, subscription.UpdatedAt,
+		subscription.FilterCompleted, pq.Array(subscription.FilterRating),
+		pq.Array(subscription.FilterWarnings), pq.Array(subscription.FilterTags),
+		subscription.MinWordCount, subscription.MaxWordCount, subscription.ID,
+	)
+	return err
+}
+
+func (r *PostgresSubscriptionRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM subscriptions WHERE id = $1`, id)
+	return err
+}
+
+func (r *PostgresSubscriptionRepository) FindByUser(ctx context.Context, userID uuid.UUID) ([]*models.Subscription, error) {
+	return r.querySubscriptions(ctx, `
+		SELECT `+subscriptionColumns+` FROM subscriptions
+		WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+}
+
+func (r *PostgresSubscriptionRepository) FindByTarget(ctx context.Context, targetType models.SubscriptionType, targetID uuid.UUID) ([]*models.Subscription, error) {
+	return r.querySubscriptions(ctx, `
+		SELECT `+subscriptionColumns+` FROM subscriptions
+		WHERE type = $1 AND target_id = $2 AND is_active = true`, targetType, targetID)
+}
+
+func (r *PostgresSubscriptionRepository) FindByUserAndTarget(ctx context.Context, userID, targetID uuid.UUID, targetType models.SubscriptionType) (*models.Subscription, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+subscriptionColumns+` FROM subscriptions
+		WHERE user_id = $1 AND target_id = $2 AND type = $3`, userID, targetID, targetType)
+	return scanSubscription(row)
+}
@@ -0,0 +1,88 @@
+// Package notificationstream is the contract between work-service, which
+// publishes notification events from its outbox, and notification-service,
+// which turns them into notifications
+package notificationstream
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/notifications"
+)
+
+const (
+	// Stream carries notification events to the notification service. It
+	// lives in the work service's Redis database.
+	Stream = "notifications:events"
+	// DeadLetterStream holds events the notification service couldn't decode
+	DeadLetterStream = "notifications:events:dead"
+	// ConsumerGroup is the notification-service consumer group on Stream
+	ConsumerGroup = "notification-service"
+)
+
+// Execer is a database or transaction an event can be queued on
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Enqueue records an event in the notification outbox under a new
+// idempotency key, for work-service's publisher to put on Stream. Pass a
+// transaction to queue the event with the change it announces.
+func Enqueue(ctx context.Context, q Execer, data notifications.EventData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx,
+		"INSERT INTO notification_outbox (idempotency_key, event_type, payload) VALUES ($1, $2, $3)",
+		uuid.New(), string(data.Type), payload)
+	return err
+}
+
+// Event is one notification event. Key is its idempotency key: an event
+// delivered more than once carries the same key every time, so the
+// consumer processes it once.
+type Event struct {
+	Key  uuid.UUID
+	Data notifications.EventData
+}
+
+// Values encodes the event as stream message fields
+func (e Event) Values() (map[string]interface{}, error) {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"key":  e.Key.String(),
+		"type": string(e.Data.Type),
+		"data": string(data),
+	}, nil
+}
+
+// ParseEvent decodes stream message fields into an event
+func ParseEvent(values map[string]interface{}) (Event, error) {
+	field := func(name string) string {
+		s, _ := values[name].(string)
+		return s
+	}
+
+	var event Event
+	key, err := uuid.Parse(field("key"))
+	if err != nil {
+		return event, fmt.Errorf("event has an invalid key %q", field("key"))
+	}
+	event.Key = key
+
+	if err := json.Unmarshal([]byte(field("data")), &event.Data); err != nil {
+		return event, fmt.Errorf("event %s has invalid data: %w", key, err)
+	}
+	if event.Data.Type == "" {
+		return event, fmt.Errorf("event %s has no type", key)
+	}
+	return event, nil
+}
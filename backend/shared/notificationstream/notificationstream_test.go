@@ -0,0 +1,57 @@
+package notificationstream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+)
+
+func TestEventRoundTrip(t *testing.T) {
+	actor := uuid.New()
+	event := Event{
+		Key: uuid.New(),
+		Data: notifications.EventData{
+			Type:         models.EventCommentMention,
+			SourceID:     uuid.New(),
+			SourceType:   "work",
+			Title:        "You were mentioned in a comment",
+			ActorID:      &actor,
+			RecipientIDs: []uuid.UUID{uuid.New()},
+			ExtraData:    map[string]interface{}{"work_title": "A Study in Scarlet"},
+		},
+	}
+
+	values, err := event.Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseEvent(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Key != event.Key || parsed.Data.Type != event.Data.Type || parsed.Data.SourceID != event.Data.SourceID ||
+		parsed.Data.ActorID == nil || *parsed.Data.ActorID != actor ||
+		len(parsed.Data.RecipientIDs) != 1 || parsed.Data.RecipientIDs[0] != event.Data.RecipientIDs[0] ||
+		parsed.Data.ExtraData["work_title"] != "A Study in Scarlet" {
+		t.Errorf("round trip changed the event: %+v", parsed)
+	}
+}
+
+func TestParseEventRejectsBadMessages(t *testing.T) {
+	key := uuid.New().String()
+	cases := map[string]map[string]interface{}{
+		"no key":       {"data": `{"type":"new_work"}`},
+		"bad key":      {"key": "x", "data": `{"type":"new_work"}`},
+		"no data":      {"key": key},
+		"invalid data": {"key": key, "data": "{"},
+		"no type":      {"key": key, "data": `{"title":"untyped"}`},
+	}
+	for name, values := range cases {
+		if _, err := ParseEvent(values); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
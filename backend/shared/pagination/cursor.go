@@ -0,0 +1,112 @@
+// Package pagination implements cursor pagination for list endpoints.
+//
+// A cursor continues a list after the last item a client saw instead of
+// skipping an offset, so deep pages cost the same as the first and items
+// added in the meantime don't push earlier ones onto the next page as
+// duplicates. Cursors are opaque to clients: base64 JSON naming the order
+// they were issued for and the position reached.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned for a cursor that is malformed or was issued
+// for a different list order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type envelope struct {
+	Sort     string          `json:"s"`
+	Position json.RawMessage `json:"p"`
+}
+
+// Encode packs a list position into a cursor. sort names the order the
+// list is in, including its direction, so the cursor can't be used to
+// continue the list in another order.
+func Encode(sort string, position interface{}) string {
+	data, err := json.Marshal(position)
+	if err != nil {
+		return ""
+	}
+	data, err = json.Marshal(envelope{Sort: sort, Position: data})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode unpacks a cursor issued for sort into position
+func Decode(cursor, sort string, position interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Sort != sort {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(env.Position, position); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// Keyset is a position in a list ordered by a timestamp, then ID to break
+// ties
+type Keyset struct {
+	Time time.Time `json:"t"`
+	ID   uuid.UUID `json:"id"`
+}
+
+// Condition is the SQL condition selecting the rows after k in a list
+// ordered by timeColumn then idColumn, both in the same direction. Its
+// placeholders start at $argIndex.
+func (k Keyset) Condition(timeColumn, idColumn string, desc bool, argIndex int) (string, []interface{}) {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	return fmt.Sprintf("(%s, %s) %s ($%d, $%d)", timeColumn, idColumn, op, argIndex, argIndex+1),
+		[]interface{}{k.Time, k.ID}
+}
+
+// FromQuery reads the cursor query parameter of a list in the given sort.
+// A list is paged by cursor whenever the parameter is present; an empty
+// cursor asks for the first page, and after is nil then.
+func FromQuery(c *gin.Context, sort string) (after *Keyset, cursorMode bool, err error) {
+	cursor, ok := c.GetQuery("cursor")
+	if !ok {
+		return nil, false, nil
+	}
+	if cursor == "" {
+		return nil, true, nil
+	}
+	var k Keyset
+	if err := Decode(cursor, sort, &k); err != nil {
+		return nil, true, err
+	}
+	return &k, true, nil
+}
+
+// Next is the cursor for the page after one ending with the item at time t
+// with the given ID
+func Next(sort string, t time.Time, id uuid.UUID) string {
+	return Encode(sort, Keyset{Time: t, ID: id})
+}
+
+// Response is the pagination block of a page fetched by cursor. next is
+// empty on the last page.
+func Response(limit int, next string) gin.H {
+	page := gin.H{"limit": limit, "has_more": next != ""}
+	if next != "" {
+		page["next_cursor"] = next
+	}
+	return page
+}
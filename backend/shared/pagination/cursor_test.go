@@ -0,0 +1,69 @@
+package pagination
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	position := Keyset{Time: time.Date(2026, 4, 2, 10, 30, 0, 123456789, time.UTC), ID: uuid.New()}
+	cursor := Encode("works:updated_at:desc", position)
+
+	var got Keyset
+	assert.NoError(t, Decode(cursor, "works:updated_at:desc", &got))
+	assert.True(t, position.Time.Equal(got.Time), "nanoseconds survive the round trip")
+	assert.Equal(t, position.ID, got.ID)
+
+	assert.ErrorIs(t, Decode(cursor, "works:updated_at:asc", &got), ErrInvalidCursor, "a cursor only continues its own order")
+	assert.ErrorIs(t, Decode("not a cursor!", "works:updated_at:desc", &got), ErrInvalidCursor)
+	assert.ErrorIs(t, Decode(Encode("works:updated_at:desc", "text"), "works:updated_at:desc", &got), ErrInvalidCursor)
+}
+
+func TestKeysetCondition(t *testing.T) {
+	k := Keyset{Time: time.Now(), ID: uuid.New()}
+
+	cond, args := k.Condition("w.updated_at", "w.id", true, 3)
+	assert.Equal(t, "(w.updated_at, w.id) < ($3, $4)", cond)
+	assert.Equal(t, []interface{}{k.Time, k.ID}, args)
+
+	cond, _ = k.Condition("c.created_at", "c.id", false, 1)
+	assert.Equal(t, "(c.created_at, c.id) > ($1, $2)", cond)
+}
+
+func queryContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/works?"+query, nil)
+	return c
+}
+
+func TestFromQuery(t *testing.T) {
+	after, cursorMode, err := FromQuery(queryContext("page=3"), "bookmarks")
+	assert.NoError(t, err)
+	assert.False(t, cursorMode, "without a cursor the list keeps offset paging")
+	assert.Nil(t, after)
+
+	after, cursorMode, err = FromQuery(queryContext("cursor="), "bookmarks")
+	assert.NoError(t, err)
+	assert.True(t, cursorMode)
+	assert.Nil(t, after, "an empty cursor starts at the first page")
+
+	id := uuid.New()
+	next := Next("bookmarks", time.Now(), id)
+	after, cursorMode, err = FromQuery(queryContext("cursor="+next), "bookmarks")
+	assert.NoError(t, err)
+	assert.True(t, cursorMode)
+	assert.Equal(t, id, after.ID)
+
+	_, _, err = FromQuery(queryContext("cursor="+next), "comments:oldest")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestResponse(t *testing.T) {
+	assert.Equal(t, gin.H{"limit": 20, "has_more": true, "next_cursor": "abc"}, Response(20, "abc"))
+	assert.Equal(t, gin.H{"limit": 20, "has_more": false}, Response(20, ""))
+}
@@ -0,0 +1,130 @@
+-- Nuclear AO3: Full orphaning semantics
+-- Adds pseud keep/scrub options to orphaning, moves ownership off the
+-- orphaning user's dashboard, and records an audit trail of every orphan.
+
+-- =====================================================
+-- ORPHAN AUDIT LOG
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS orphan_audit_log (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    -- NULL once the work is purged; work_title keeps the record readable
+    work_id UUID REFERENCES works(id) ON DELETE SET NULL,
+    work_title VARCHAR(500) NOT NULL,
+    original_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    original_pseud_names TEXT[] NOT NULL DEFAULT '{}',
+    pseud_option VARCHAR(10) NOT NULL,
+    orphan_pseud_id UUID REFERENCES pseuds(id) ON DELETE SET NULL,
+    remaining_coauthors INTEGER NOT NULL DEFAULT 0,
+    ip_address INET,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT orphan_pseud_option_values CHECK (pseud_option IN ('keep', 'scrub'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_orphan_audit_work ON orphan_audit_log(work_id);
+CREATE INDEX IF NOT EXISTS idx_orphan_audit_user ON orphan_audit_log(original_user_id);
+CREATE INDEX IF NOT EXISTS idx_orphan_audit_created ON orphan_audit_log(created_at DESC);
+
+-- =====================================================
+-- ORPHAN FUNCTION WITH PSEUD OPTIONS
+-- =====================================================
+
+-- Orphans a work for one author. With keep_pseud the orphan account gets a
+-- pseud carrying the author's pseud name (AO3's "leave a copy of my pseud");
+-- otherwise the generic orphan_account pseud is used. Returns the orphan
+-- pseud that now holds the creatorship, or NULL if the user is not an author.
+CREATE OR REPLACE FUNCTION orphan_work_with_options(work_uuid UUID, orphaning_user_uuid UUID, keep_pseud BOOLEAN)
+RETURNS UUID AS $$
+DECLARE
+    orphan_user_id CONSTANT UUID := '00000000-0000-0000-0000-000000000000';
+    target_pseud_id UUID;
+    source_pseud_name VARCHAR;
+    remaining_owner UUID;
+BEGIN
+    SELECT p.name INTO source_pseud_name
+    FROM creatorships c
+    JOIN pseuds p ON c.pseud_id = p.id
+    WHERE c.creation_id = work_uuid
+    AND c.creation_type = 'Work'
+    AND p.user_id = orphaning_user_uuid
+    ORDER BY c.created_at
+    LIMIT 1;
+
+    IF NOT FOUND THEN
+        RETURN NULL; -- User is not an author
+    END IF;
+
+    IF keep_pseud THEN
+        SELECT id INTO target_pseud_id
+        FROM pseuds
+        WHERE user_id = orphan_user_id AND name = source_pseud_name;
+
+        IF target_pseud_id IS NULL THEN
+            INSERT INTO pseuds (user_id, name, is_default)
+            VALUES (orphan_user_id, source_pseud_name, false)
+            RETURNING id INTO target_pseud_id;
+        END IF;
+    ELSE
+        SELECT id INTO target_pseud_id
+        FROM pseuds
+        WHERE user_id = orphan_user_id AND is_default = true;
+    END IF;
+
+    IF target_pseud_id IS NULL THEN
+        RETURN NULL;
+    END IF;
+
+    -- Move work and chapter creatorships to the orphan pseud
+    INSERT INTO creatorships (creation_id, creation_type, pseud_id, approved)
+    SELECT DISTINCT c.creation_id, c.creation_type, target_pseud_id, true
+    FROM creatorships c
+    JOIN pseuds p ON c.pseud_id = p.id
+    WHERE p.user_id = orphaning_user_uuid
+    AND (
+        (c.creation_type = 'Work' AND c.creation_id = work_uuid) OR
+        (c.creation_type = 'Chapter' AND c.creation_id IN (SELECT id FROM chapters WHERE work_id = work_uuid))
+    )
+    ON CONFLICT (creation_id, creation_type, pseud_id) DO NOTHING;
+
+    DELETE FROM creatorships c
+    USING pseuds p
+    WHERE c.pseud_id = p.id
+    AND p.user_id = orphaning_user_uuid
+    AND (
+        (c.creation_type = 'Work' AND c.creation_id = work_uuid) OR
+        (c.creation_type = 'Chapter' AND c.creation_id IN (SELECT id FROM chapters WHERE work_id = work_uuid))
+    );
+
+    -- Hand ownership to a remaining co-author, or the orphan account
+    SELECT p.user_id INTO remaining_owner
+    FROM creatorships c
+    JOIN pseuds p ON c.pseud_id = p.id
+    WHERE c.creation_id = work_uuid
+    AND c.creation_type = 'Work'
+    AND c.approved = true
+    AND p.user_id != orphan_user_id
+    ORDER BY c.created_at
+    LIMIT 1;
+
+    UPDATE works
+    SET user_id = COALESCE(remaining_owner, orphan_user_id), updated_at = NOW()
+    WHERE id = work_uuid AND user_id = orphaning_user_uuid;
+
+    RETURN target_pseud_id;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Keep the original two-argument function for existing callers; it scrubs
+-- the pseud, matching its previous behaviour.
+CREATE OR REPLACE FUNCTION orphan_work(work_uuid UUID, orphaning_user_uuid UUID)
+RETURNS BOOLEAN AS $$
+BEGIN
+    RETURN orphan_work_with_options(work_uuid, orphaning_user_uuid, false) IS NOT NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON TABLE orphan_audit_log IS 'Irreversible work orphaning actions, visible to admins only';
+COMMENT ON COLUMN orphan_audit_log.work_id IS 'The orphaned work; NULL after the work is purged from the trash';
+COMMENT ON COLUMN orphan_audit_log.work_title IS 'Title of the work when it was orphaned, kept after the work is gone';
+COMMENT ON FUNCTION orphan_work_with_options(UUID, UUID, BOOLEAN) IS 'Orphans a work, optionally keeping the author pseud name on the orphan account';
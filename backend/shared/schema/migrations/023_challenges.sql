@@ -0,0 +1,115 @@
+-- Nuclear AO3: Challenges (gift exchanges and prompt memes)
+-- A challenge is a collection with challenge settings attached. Participants
+-- sign up with requests and offers, moderators run matching, and the
+-- resulting assignments are tracked through to posted works or pinch hits.
+
+-- =====================================================
+-- CHALLENGE SETTINGS
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS challenge_settings (
+    collection_id UUID PRIMARY KEY REFERENCES collections(id) ON DELETE CASCADE,
+    challenge_type VARCHAR(20) NOT NULL,
+    signups_open BOOLEAN DEFAULT false,
+    signups_open_at TIMESTAMP WITH TIME ZONE,
+    signups_close_at TIMESTAMP WITH TIME ZONE,
+    assignments_due_at TIMESTAMP WITH TIME ZONE,
+    works_reveal_at TIMESTAMP WITH TIME ZONE,
+    authors_reveal_at TIMESTAMP WITH TIME ZONE,
+    requests_min INTEGER DEFAULT 1,
+    requests_max INTEGER DEFAULT 1,
+    offers_min INTEGER DEFAULT 1,
+    offers_max INTEGER DEFAULT 1,
+    -- Per tag type limits, e.g. {"fandom": {"min": 1, "max": 1}, "character": {"min": 0, "max": 4}}
+    prompt_tag_limits JSONB DEFAULT '{}',
+    -- Tag types an offer and request must share for a match, and how many
+    match_tag_types TEXT[] DEFAULT ARRAY['fandom'],
+    match_min_tags INTEGER DEFAULT 1,
+    assignments_sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT challenge_types CHECK (challenge_type IN ('gift_exchange', 'prompt_meme')),
+    CONSTRAINT challenge_request_limits CHECK (requests_min >= 0 AND requests_max >= requests_min),
+    CONSTRAINT challenge_offer_limits CHECK (offers_min >= 0 AND offers_max >= offers_min),
+    CONSTRAINT challenge_match_min CHECK (match_min_tags >= 0)
+);
+
+-- =====================================================
+-- SIGN-UPS AND PROMPTS
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS challenge_signups (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    pseud_id UUID REFERENCES pseuds(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    UNIQUE(collection_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_signups_collection ON challenge_signups(collection_id);
+CREATE INDEX IF NOT EXISTS idx_challenge_signups_user ON challenge_signups(user_id);
+
+CREATE TABLE IF NOT EXISTS challenge_prompts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    signup_id UUID NOT NULL REFERENCES challenge_signups(id) ON DELETE CASCADE,
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 1,
+    title VARCHAR(255),
+    description TEXT,
+    tags TEXT[] DEFAULT '{}',
+    is_anonymous BOOLEAN DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT challenge_prompt_kinds CHECK (kind IN ('request', 'offer'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_prompts_signup ON challenge_prompts(signup_id, kind, position);
+CREATE INDEX IF NOT EXISTS idx_challenge_prompts_collection ON challenge_prompts(collection_id, kind);
+CREATE INDEX IF NOT EXISTS idx_challenge_prompts_tags ON challenge_prompts USING GIN(tags);
+
+-- =====================================================
+-- ASSIGNMENTS
+-- =====================================================
+
+-- Status flow: draft (matched, not yet sent) -> assigned -> fulfilled, or
+-- assigned -> defaulted. A pinch hit is a new assignment row for the same
+-- recipient that points back at the defaulted one.
+CREATE TABLE IF NOT EXISTS challenge_assignments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    giver_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    giver_signup_id UUID REFERENCES challenge_signups(id) ON DELETE SET NULL,
+    recipient_signup_id UUID NOT NULL REFERENCES challenge_signups(id) ON DELETE CASCADE,
+    request_prompt_id UUID REFERENCES challenge_prompts(id) ON DELETE SET NULL,
+    offer_prompt_id UUID REFERENCES challenge_prompts(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    match_score INTEGER DEFAULT 0,
+    due_at TIMESTAMP WITH TIME ZONE,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    fulfilled_at TIMESTAMP WITH TIME ZONE,
+    defaulted_at TIMESTAMP WITH TIME ZONE,
+    work_id UUID REFERENCES works(id) ON DELETE SET NULL,
+    pinch_hit_for UUID REFERENCES challenge_assignments(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT challenge_assignment_statuses CHECK (status IN ('draft', 'assigned', 'fulfilled', 'defaulted'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_assignments_collection ON challenge_assignments(collection_id, status);
+CREATE INDEX IF NOT EXISTS idx_challenge_assignments_giver ON challenge_assignments(giver_user_id, status);
+CREATE INDEX IF NOT EXISTS idx_challenge_assignments_recipient ON challenge_assignments(recipient_signup_id);
+CREATE INDEX IF NOT EXISTS idx_challenge_assignments_due ON challenge_assignments(due_at) WHERE status = 'assigned';
+-- A defaulted assignment is pinch hit at most once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_challenge_assignments_pinch_hit_for ON challenge_assignments(pinch_hit_for) WHERE pinch_hit_for IS NOT NULL;
+
+COMMENT ON TABLE challenge_settings IS 'Gift exchange / prompt meme configuration for a collection';
+COMMENT ON TABLE challenge_signups IS 'One sign-up per user per challenge';
+COMMENT ON TABLE challenge_prompts IS 'Requests and offers belonging to a sign-up';
+COMMENT ON TABLE challenge_assignments IS 'Gift exchange assignments produced by matching, including pinch hits';
+COMMENT ON COLUMN challenge_settings.prompt_tag_limits IS 'Per tag type min/max tag counts enforced on each prompt';
@@ -0,0 +1,56 @@
+-- Nuclear AO3: Prompt meme claims and fills
+-- Prompts in a prompt meme are the request prompts from 023_challenges.
+-- Users claim prompts they intend to write, and works are linked back to
+-- the prompts they fill.
+
+ALTER TABLE challenge_settings ADD COLUMN IF NOT EXISTS claim_limit INTEGER DEFAULT 0;
+ALTER TABLE challenge_settings ADD COLUMN IF NOT EXISTS allow_multiple_claims BOOLEAN DEFAULT true;
+
+COMMENT ON COLUMN challenge_settings.claim_limit IS 'Maximum open claims per user in a prompt meme; 0 means unlimited';
+COMMENT ON COLUMN challenge_settings.allow_multiple_claims IS 'Whether several users may hold open claims on the same prompt';
+
+-- =====================================================
+-- CLAIMS
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS prompt_claims (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    prompt_id UUID NOT NULL REFERENCES challenge_prompts(id) ON DELETE CASCADE,
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    work_id UUID REFERENCES works(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    filled_at TIMESTAMP WITH TIME ZONE,
+    released_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT prompt_claim_statuses CHECK (status IN ('open', 'filled', 'released'))
+);
+
+-- One open claim per user per prompt
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prompt_claims_open_unique ON prompt_claims(prompt_id, user_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_prompt_claims_prompt ON prompt_claims(prompt_id, status);
+CREATE INDEX IF NOT EXISTS idx_prompt_claims_user ON prompt_claims(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_prompt_claims_collection ON prompt_claims(collection_id, status);
+
+-- =====================================================
+-- FILLS
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS prompt_fills (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    prompt_id UUID NOT NULL REFERENCES challenge_prompts(id) ON DELETE CASCADE,
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    claim_id UUID REFERENCES prompt_claims(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    UNIQUE(prompt_id, work_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_prompt_fills_prompt ON prompt_fills(prompt_id);
+CREATE INDEX IF NOT EXISTS idx_prompt_fills_work ON prompt_fills(work_id);
+
+COMMENT ON TABLE prompt_claims IS 'Prompt meme claims; released claims are kept for history';
+COMMENT ON TABLE prompt_fills IS 'Works posted in response to prompt meme prompts';
@@ -0,0 +1,67 @@
+-- Nuclear AO3: Blocklist import/export and shared mute lists
+-- Users can publish snapshots of their mute or block lists and import
+-- lists published by others. Imported entries remember which import they
+-- came from so a whole import can be undone in one step.
+
+-- =====================================================
+-- SHARED LISTS
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS shared_block_lists (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    kind VARCHAR(10) NOT NULL DEFAULT 'mute',
+    is_public BOOLEAN DEFAULT false,
+    entry_count INTEGER DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT shared_block_list_kinds CHECK (kind IN ('mute', 'block'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_shared_block_lists_owner ON shared_block_lists(owner_id);
+CREATE INDEX IF NOT EXISTS idx_shared_block_lists_public ON shared_block_lists(is_public, updated_at DESC);
+
+CREATE TABLE IF NOT EXISTS shared_block_list_entries (
+    list_id UUID NOT NULL REFERENCES shared_block_lists(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    block_type VARCHAR(20),
+    reason TEXT,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (list_id, user_id)
+);
+
+-- =====================================================
+-- IMPORT PROVENANCE
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS blocklist_imports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source_list_id UUID REFERENCES shared_block_lists(id) ON DELETE SET NULL,
+    source_label VARCHAR(200) NOT NULL,
+    mutes_added INTEGER DEFAULT 0,
+    blocks_added INTEGER DEFAULT 0,
+    skipped INTEGER DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_blocklist_imports_user ON blocklist_imports(user_id, created_at DESC);
+
+ALTER TABLE user_mutes ADD COLUMN IF NOT EXISTS import_id UUID REFERENCES blocklist_imports(id) ON DELETE SET NULL;
+ALTER TABLE user_blocks ADD COLUMN IF NOT EXISTS import_id UUID REFERENCES blocklist_imports(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_mutes_import ON user_mutes(import_id) WHERE import_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_user_blocks_import ON user_blocks(import_id) WHERE import_id IS NOT NULL;
+
+-- =====================================================
+-- COMMENTS AND DOCUMENTATION
+-- =====================================================
+
+COMMENT ON TABLE shared_block_lists IS 'Published snapshots of a user''s mute or block list';
+COMMENT ON TABLE blocklist_imports IS 'One row per import so imported entries can be traced and removed together';
+COMMENT ON COLUMN user_mutes.import_id IS 'Import that added this mute; NULL for mutes added by hand';
+COMMENT ON COLUMN user_blocks.import_id IS 'Import that added this block; NULL for blocks added by hand';
@@ -0,0 +1,33 @@
+-- Nuclear AO3: Related works
+-- A work can declare itself a translation, remix or "inspired by" another
+-- work, either on this archive or at an external URL. Links to local works
+-- wait for the original author's approval before they are shown.
+
+CREATE TABLE IF NOT EXISTS related_works (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    parent_work_id UUID REFERENCES works(id) ON DELETE CASCADE,
+    parent_url TEXT,
+    parent_title VARCHAR(255),
+    parent_author VARCHAR(255),
+    parent_language VARCHAR(10),
+    relation VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT related_work_relations CHECK (relation IN ('translation', 'remix', 'inspired_by')),
+    CONSTRAINT related_work_statuses CHECK (status IN ('pending', 'approved', 'rejected')),
+    CONSTRAINT related_work_has_parent CHECK ((parent_work_id IS NULL) != (parent_url IS NULL)),
+    CONSTRAINT related_work_not_self CHECK (parent_work_id IS NULL OR parent_work_id != work_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_related_works_local_unique ON related_works(work_id, parent_work_id) WHERE parent_work_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_related_works_external_unique ON related_works(work_id, parent_url) WHERE parent_url IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_related_works_work ON related_works(work_id, status);
+CREATE INDEX IF NOT EXISTS idx_related_works_parent ON related_works(parent_work_id, status);
+
+COMMENT ON TABLE related_works IS 'Translations, remixes and inspirations; work_id is the derived work';
+COMMENT ON COLUMN related_works.status IS 'External links and links between works by the same author are approved immediately';
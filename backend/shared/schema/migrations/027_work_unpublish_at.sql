@@ -0,0 +1,13 @@
+-- Nuclear AO3: Scheduled unpublishing
+-- Works can be given an unpublish_at time, after which the work scheduler
+-- returns them to draft (visible only to their authors) and tells the
+-- authors.
+
+ALTER TABLE works ADD COLUMN IF NOT EXISTS unpublish_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE works ADD COLUMN IF NOT EXISTS unpublished_at TIMESTAMP WITH TIME ZONE;
+
+-- The scheduler only ever looks at works with a pending unpublish time
+CREATE INDEX IF NOT EXISTS idx_works_unpublish_at ON works(unpublish_at) WHERE unpublish_at IS NOT NULL;
+
+COMMENT ON COLUMN works.unpublish_at IS 'When the work scheduler should hide the work; cleared once it runs';
+COMMENT ON COLUMN works.unpublished_at IS 'When the work was last hidden by the scheduler';
@@ -0,0 +1,19 @@
+-- Nuclear AO3: Anonymous and unrevealed collection lifecycle
+-- Unrevealed collections hide their works and anonymous collections hide
+-- their creators until a reveal. Reveals can be scheduled per collection
+-- (falling back to a challenge's works_reveal_at / authors_reveal_at) and
+-- are carried out by the work-service scheduler, or triggered by hand.
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS is_unrevealed BOOLEAN DEFAULT false;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS reveal_works_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS reveal_authors_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS works_revealed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS authors_revealed_at TIMESTAMP WITH TIME ZONE;
+
+-- The scheduler only looks at collections still holding something back
+CREATE INDEX IF NOT EXISTS idx_collections_pending_reveal ON collections(id)
+    WHERE (is_unrevealed AND works_revealed_at IS NULL) OR (is_anonymous AND authors_revealed_at IS NULL);
+
+COMMENT ON COLUMN collections.is_unrevealed IS 'Works in the collection are hidden until works_revealed_at is set';
+COMMENT ON COLUMN collections.reveal_works_at IS 'Scheduled works reveal; overrides challenge_settings.works_reveal_at';
+COMMENT ON COLUMN collections.reveal_authors_at IS 'Scheduled creator reveal; overrides challenge_settings.authors_reveal_at';
@@ -0,0 +1,60 @@
+-- Nuclear AO3: Collection moderation
+-- Items submitted to a moderated collection wait for a maintainer to approve
+-- or reject them, and maintainers can invite specific works in. The
+-- collection handlers already read and write collection_items, so it is
+-- created here with the columns they expect.
+
+-- =====================================================
+-- COLLECTION ITEMS
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS collection_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    added_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    is_approved BOOLEAN DEFAULT false,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    approved_at TIMESTAMP WITH TIME ZONE,
+
+    UNIQUE(collection_id, work_id)
+);
+
+ALTER TABLE collection_items ADD COLUMN IF NOT EXISTS rejected_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE collection_items ADD COLUMN IF NOT EXISTS rejection_message TEXT;
+ALTER TABLE collection_items ADD COLUMN IF NOT EXISTS reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_collection_items_collection ON collection_items(collection_id, is_approved, added_at DESC);
+CREATE INDEX IF NOT EXISTS idx_collection_items_work ON collection_items(work_id);
+CREATE INDEX IF NOT EXISTS idx_collection_items_pending ON collection_items(collection_id, added_at)
+    WHERE NOT is_approved AND rejected_at IS NULL;
+
+-- =====================================================
+-- INVITATIONS
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS collection_invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    message TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    responded_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    responded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT collection_invitation_statuses CHECK (status IN ('pending', 'accepted', 'declined')),
+    UNIQUE(collection_id, work_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_invitations_collection ON collection_invitations(collection_id, status);
+CREATE INDEX IF NOT EXISTS idx_collection_invitations_work ON collection_invitations(work_id, status);
+
+-- =====================================================
+-- COMMENTS AND DOCUMENTATION
+-- =====================================================
+
+COMMENT ON TABLE collection_items IS 'Works added to collections; pending items are unapproved and not rejected';
+COMMENT ON COLUMN collection_items.rejection_message IS 'Optional note from the maintainer shown to whoever submitted the work';
+COMMENT ON TABLE collection_invitations IS 'Maintainer requests for a specific work to join a collection; accepting adds an approved item';
@@ -0,0 +1,29 @@
+-- Nuclear AO3: Collection maintainers
+-- A collection can be run by several people. Owners manage settings and the
+-- maintainer list; moderators review items and run challenges. The user who
+-- created the collection (collections.user_id) is always an owner.
+
+-- =====================================================
+-- COLLECTION MAINTAINERS
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS collection_maintainers (
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'moderator',
+    added_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (collection_id, user_id),
+    CONSTRAINT collection_maintainer_roles CHECK (role IN ('owner', 'moderator'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_maintainers_user ON collection_maintainers(user_id);
+
+-- Existing collections start with their creator as sole owner
+INSERT INTO collection_maintainers (collection_id, user_id, role, created_at)
+SELECT id, user_id, 'owner', created_at FROM collections
+ON CONFLICT (collection_id, user_id) DO NOTHING;
+
+COMMENT ON TABLE collection_maintainers IS 'Users who run a collection, with owner or moderator role';
+COMMENT ON COLUMN collection_maintainers.role IS 'owner: settings and maintainers; moderator: items and challenges';
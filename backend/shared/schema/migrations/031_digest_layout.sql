@@ -0,0 +1,19 @@
+-- Nuclear AO3: Digest layout preferences
+-- Digests are split into ranked sections. Users can put sections in their
+-- own order and cap how many items each section shows before collapsing the
+-- rest into an "and N more" link.
+
+-- =====================================================
+-- NOTIFICATION PREFERENCES
+-- =====================================================
+
+ALTER TABLE IF EXISTS notification_preferences
+    ADD COLUMN IF NOT EXISTS digest_section_order JSONB DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS digest_section_limit INTEGER DEFAULT 5;
+
+ALTER TABLE IF EXISTS user_notification_preferences
+    ADD COLUMN IF NOT EXISTS digest_section_order JSONB DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS digest_section_limit INTEGER DEFAULT 5;
+
+COMMENT ON COLUMN notification_preferences.digest_section_order IS 'Event types to list first in digests, in order';
+COMMENT ON COLUMN notification_preferences.digest_section_limit IS 'Items shown per digest section before "and N more"';
@@ -0,0 +1,18 @@
+-- Nuclear AO3: Daily hit aggregates
+-- Hits are counted in Redis, deduplicated per viewer (user or IP) and work
+-- per day, and flushed in batches by the work-service scheduler. Postgres
+-- only ever sees one upsert per work and day per flush, never one per view.
+
+CREATE TABLE IF NOT EXISTS work_daily_hits (
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    hit_date DATE NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (work_id, hit_date)
+);
+
+-- Pruning and site-wide rollups scan by day
+CREATE INDEX IF NOT EXISTS idx_work_daily_hits_date ON work_daily_hits(hit_date);
+
+COMMENT ON TABLE work_daily_hits IS 'Unique viewers per work per day, flushed from Redis by the hit pipeline';
+COMMENT ON COLUMN work_daily_hits.hits IS 'Distinct viewers (user or IP) that day; not raw page views';
@@ -0,0 +1,27 @@
+-- Nuclear AO3: Language mismatch flags
+-- When a posted chapter reads confidently as a different language from the
+-- one its work declares, the author gets a warning and the work is queued
+-- here for a wrangler to confirm or dismiss. Posting is never blocked.
+
+CREATE TABLE IF NOT EXISTS work_language_flags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    chapter_id UUID REFERENCES chapters(id) ON DELETE SET NULL,
+    declared_language VARCHAR(10) NOT NULL,
+    detected_language VARCHAR(10) NOT NULL,
+    confidence REAL NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT work_language_flag_status_values CHECK (status IN ('pending', 'relabeled', 'dismissed'))
+);
+
+-- One open flag per work; later chapters don't pile on
+CREATE UNIQUE INDEX IF NOT EXISTS idx_work_language_flags_pending
+    ON work_language_flags(work_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_work_language_flags_status ON work_language_flags(status, created_at);
+
+COMMENT ON TABLE work_language_flags IS 'Works whose chapter text disagrees with the declared language, awaiting wrangler review';
+COMMENT ON COLUMN work_language_flags.confidence IS 'Detector confidence (0-1) when the flag was raised';
@@ -0,0 +1,28 @@
+-- Nuclear AO3: Per-chapter hit and kudos attribution
+-- Breaks a work's unique viewers down by chapter and by where the reader
+-- came from, so authors can see where readers drop off and how they find
+-- the work. Flushed from Redis alongside work_daily_hits.
+
+CREATE TABLE IF NOT EXISTS work_chapter_daily_hits (
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    -- The nil UUID stands for the work's landing page rather than a chapter;
+    -- rows for deleted chapters are simply left out of the breakdown
+    chapter_id UUID NOT NULL,
+    referrer_class VARCHAR(20) NOT NULL
+        CHECK (referrer_class IN ('search', 'tag', 'internal', 'external', 'direct')),
+    hit_date DATE NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (work_id, chapter_id, referrer_class, hit_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_work_chapter_daily_hits_date ON work_chapter_daily_hits(hit_date);
+
+-- Kudos given from a chapter page remember which chapter it was
+ALTER TABLE kudos ADD COLUMN IF NOT EXISTS chapter_id UUID REFERENCES chapters(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_kudos_chapter ON kudos(chapter_id) WHERE chapter_id IS NOT NULL;
+
+COMMENT ON TABLE work_chapter_daily_hits IS 'Unique viewers per chapter, referrer class and day; owner-only analytics';
+COMMENT ON COLUMN work_chapter_daily_hits.referrer_class IS 'search, tag (tag/fandom pages), internal (other archive pages), external or direct';
+COMMENT ON COLUMN kudos.chapter_id IS 'Chapter the kudos was left from, when known';
@@ -0,0 +1,62 @@
+-- Nuclear AO3: User dashboard statistics rollups
+-- The "my stats" dashboard reads precomputed per-user rollups instead of
+-- aggregating over every work, kudos and comment on each page load. A
+-- rollup is built the first time a user opens the dashboard and kept fresh
+-- by the work-service scheduler for as long as they keep visiting.
+
+CREATE TABLE IF NOT EXISTS user_stats_rollups (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+
+    total_works INTEGER NOT NULL DEFAULT 0,
+    published_works INTEGER NOT NULL DEFAULT 0,
+    draft_works INTEGER NOT NULL DEFAULT 0,
+    complete_works INTEGER NOT NULL DEFAULT 0,
+    total_word_count BIGINT NOT NULL DEFAULT 0,
+    published_word_count BIGINT NOT NULL DEFAULT 0,
+    total_chapters INTEGER NOT NULL DEFAULT 0,
+
+    total_hits BIGINT NOT NULL DEFAULT 0,
+    total_kudos INTEGER NOT NULL DEFAULT 0,
+    total_comments INTEGER NOT NULL DEFAULT 0,
+    total_bookmarks INTEGER NOT NULL DEFAULT 0,
+
+    work_subscribers INTEGER NOT NULL DEFAULT 0,
+    author_subscribers INTEGER NOT NULL DEFAULT 0,
+    series_subscribers INTEGER NOT NULL DEFAULT 0,
+
+    total_series INTEGER NOT NULL DEFAULT 0,
+    total_collections INTEGER NOT NULL DEFAULT 0,
+
+    first_published TIMESTAMP WITH TIME ZONE,
+    last_published TIMESTAMP WITH TIME ZONE,
+    last_updated TIMESTAMP WITH TIME ZONE,
+
+    refreshed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_viewed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- The refresh job picks the stalest rollups of recently active users
+CREATE INDEX IF NOT EXISTS idx_user_stats_rollups_refresh ON user_stats_rollups(refreshed_at, last_viewed_at);
+
+CREATE TABLE IF NOT EXISTS user_fandom_rollups (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    fandom VARCHAR(500) NOT NULL,
+    works INTEGER NOT NULL DEFAULT 0,
+    word_count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, fandom)
+);
+
+CREATE TABLE IF NOT EXISTS user_daily_rollups (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stat_date DATE NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    kudos INTEGER NOT NULL DEFAULT 0,
+    comments INTEGER NOT NULL DEFAULT 0,
+    bookmarks INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, stat_date)
+);
+
+COMMENT ON TABLE user_stats_rollups IS 'Per-user dashboard totals, refreshed in the background by the work-service scheduler';
+COMMENT ON COLUMN user_stats_rollups.last_viewed_at IS 'Last dashboard visit; rollups of users who stop visiting are no longer refreshed';
+COMMENT ON TABLE user_fandom_rollups IS 'Works and words per fandom for each user, rebuilt with user_stats_rollups';
+COMMENT ON TABLE user_daily_rollups IS 'Rolling 30-day engagement trend across each user''s works';
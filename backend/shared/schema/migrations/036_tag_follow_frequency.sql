@@ -0,0 +1,15 @@
+-- Nuclear AO3: Per-tag follow frequency
+-- Followed tags are stored as 'tag' subscriptions. Following a busy fandom
+-- tag can flood a reader, so each follow carries its own frequency:
+-- immediate, daily or weekly delivery, or muted to keep the follow without
+-- any notifications.
+
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscription_frequency_check;
+ALTER TABLE subscriptions ADD CONSTRAINT subscription_frequency_check CHECK (frequency IN (
+    'immediate', 'batched', 'daily', 'weekly', 'never', 'muted'
+));
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_followed_tags
+    ON subscriptions(user_id, created_at DESC) WHERE type = 'tag';
+
+COMMENT ON COLUMN subscriptions.frequency IS 'How often notifications for this subscription are delivered; muted keeps the subscription without notifying';
@@ -0,0 +1,20 @@
+-- Nuclear AO3: Deployment content rating policy
+-- Each deployment decides which ratings may be posted at all and which
+-- need a logged-in reader. One row per canonical rating key; every rating
+-- starts allowed and open to everyone, matching the archive's defaults.
+
+CREATE TABLE IF NOT EXISTS content_rating_policy (
+    rating VARCHAR(20) PRIMARY KEY CHECK (rating IN ('general', 'teen', 'mature', 'explicit', 'not_rated')),
+    allowed BOOLEAN NOT NULL DEFAULT true,
+    login_required BOOLEAN NOT NULL DEFAULT false,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+INSERT INTO content_rating_policy (rating) VALUES
+    ('general'), ('teen'), ('mature'), ('explicit'), ('not_rated')
+ON CONFLICT (rating) DO NOTHING;
+
+COMMENT ON TABLE content_rating_policy IS 'Per-deployment rules for each work rating, set by admins';
+COMMENT ON COLUMN content_rating_policy.allowed IS 'Whether works with this rating may be posted';
+COMMENT ON COLUMN content_rating_policy.login_required IS 'Whether works with this rating are hidden from logged-out readers';
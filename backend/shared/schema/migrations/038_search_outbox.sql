@@ -0,0 +1,68 @@
+-- Nuclear AO3: Search reindexing outbox
+-- Every insert, update and delete of a work (or its statistics) records an
+-- event in the same transaction, so the search index can't miss a change.
+-- work-service publishes pending events to a Redis stream that
+-- search-service consumes; events that keep failing are dead-lettered for
+-- an admin to replay.
+
+CREATE TABLE IF NOT EXISTS search_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    work_id UUID NOT NULL,
+    event_type VARCHAR(10) NOT NULL CHECK (event_type IN ('upsert', 'delete')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMP WITH TIME ZONE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    dead_lettered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_outbox_pending
+    ON search_outbox(id) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_search_outbox_dead
+    ON search_outbox(dead_lettered_at) WHERE dead_lettered_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_search_outbox_published
+    ON search_outbox(published_at) WHERE published_at IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION enqueue_work_search_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'works' AND TG_OP = 'DELETE' THEN
+        INSERT INTO search_outbox (work_id, event_type) VALUES (OLD.id, 'delete');
+        RETURN OLD;
+    ELSIF TG_TABLE_NAME = 'works' THEN
+        INSERT INTO search_outbox (work_id, event_type) VALUES (NEW.id, 'upsert');
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        INSERT INTO search_outbox (work_id, event_type) VALUES (OLD.work_id, 'upsert');
+        RETURN OLD;
+    END IF;
+    INSERT INTO search_outbox (work_id, event_type) VALUES (NEW.work_id, 'upsert');
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trigger_works_search_outbox ON works;
+CREATE TRIGGER trigger_works_search_outbox
+    AFTER INSERT OR DELETE ON works
+    FOR EACH ROW
+    EXECUTE FUNCTION enqueue_work_search_event();
+
+DROP TRIGGER IF EXISTS trigger_works_search_outbox_update ON works;
+CREATE TRIGGER trigger_works_search_outbox_update
+    AFTER UPDATE ON works
+    FOR EACH ROW
+    WHEN (OLD.* IS DISTINCT FROM NEW.*)
+    EXECUTE FUNCTION enqueue_work_search_event();
+
+DROP TRIGGER IF EXISTS trigger_work_statistics_search_outbox ON work_statistics;
+CREATE TRIGGER trigger_work_statistics_search_outbox
+    AFTER INSERT OR UPDATE OR DELETE ON work_statistics
+    FOR EACH ROW
+    EXECUTE FUNCTION enqueue_work_search_event();
+
+COMMENT ON TABLE search_outbox IS 'Work changes waiting to be published to the search reindexing stream';
+COMMENT ON COLUMN search_outbox.next_attempt_at IS 'When a failed publish is retried';
+COMMENT ON COLUMN search_outbox.dead_lettered_at IS 'Set once publishing gave up; cleared by a replay';
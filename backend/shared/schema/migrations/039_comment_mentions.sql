@@ -0,0 +1,15 @@
+-- Nuclear AO3: @mentions in comments
+-- A comment's mentions are resolved when it is posted or edited: only
+-- existing users who haven't blocked the commenter from commenting are
+-- recorded, and each is notified once per comment.
+
+CREATE TABLE IF NOT EXISTS comment_mentions (
+    comment_id UUID NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    mentioned_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (comment_id, mentioned_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_mentions_user ON comment_mentions(mentioned_user_id, created_at DESC);
+
+COMMENT ON TABLE comment_mentions IS 'Users mentioned with @username in a comment';
@@ -0,0 +1,30 @@
+-- Nuclear AO3: share target and bookmarklet ingestion
+-- A URL shared from a browser lands in one of two places: links to works on
+-- this archive go on the reader's marked-for-later list, anything else is
+-- kept as an external bookmark.
+
+CREATE TABLE IF NOT EXISTS marked_for_later (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, work_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_marked_for_later_user ON marked_for_later(user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS external_bookmarks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    title VARCHAR(500) NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    UNIQUE (user_id, url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_external_bookmarks_user ON external_bookmarks(user_id, created_at DESC);
+
+COMMENT ON TABLE marked_for_later IS 'Works a reader has saved to read later';
+COMMENT ON TABLE external_bookmarks IS 'Bookmarks of works hosted elsewhere, saved by URL';
@@ -0,0 +1,36 @@
+-- Nuclear AO3: saved work searches and their alerts
+-- A saved search keeps the search request as JSON. When it has an alert the
+-- search service reruns it on the alert's schedule and notifies the owner of
+-- works that newly match; every work it has told them about is recorded so
+-- a work is only announced once per saved search.
+
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    query JSONB NOT NULL,
+    alert_frequency VARCHAR(20) NOT NULL DEFAULT 'off',
+    alert_last_run_at TIMESTAMP WITH TIME ZONE,
+    alert_next_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT saved_search_alert_frequency CHECK (alert_frequency IN ('off', 'hourly', 'daily', 'weekly'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_searches_user ON saved_searches(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_saved_searches_alert_due ON saved_searches(alert_next_run_at)
+    WHERE alert_frequency <> 'off';
+
+CREATE TABLE IF NOT EXISTS saved_search_alert_hits (
+    saved_search_id UUID NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    notified_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (saved_search_id, work_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_search_alert_hits_notified ON saved_search_alert_hits(notified_at);
+
+COMMENT ON TABLE saved_searches IS 'Work searches saved by readers, optionally rerun as alerts';
+COMMENT ON COLUMN saved_searches.alert_next_run_at IS 'When the alert is next due; pushed forward while a run holds it';
+COMMENT ON TABLE saved_search_alert_hits IS 'Works a saved search alert has already notified its owner about';
@@ -0,0 +1,14 @@
+-- Nuclear AO3: per-reader search preferences
+-- Preferred languages don't filter searches; works in them are ranked
+-- higher for the reader. A search can still name its own with
+-- preferred_language.
+
+CREATE TABLE IF NOT EXISTS search_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    preferred_languages VARCHAR(10)[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+COMMENT ON TABLE search_preferences IS 'How a reader wants their work searches ranked';
+COMMENT ON COLUMN search_preferences.preferred_languages IS 'Language codes boosted in the reader''s search results';
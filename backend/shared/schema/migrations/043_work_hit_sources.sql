@@ -0,0 +1,34 @@
+-- Nuclear AO3: Where a work's readers come from
+-- Each unique viewer of a work is counted once a day by the coarse class of
+-- referrer they arrived from and by their country. The country is looked up
+-- by the API gateway and passed on as a two-letter code; addresses are never
+-- stored. Only a work's creators see these numbers, and countries with too
+-- few readers to be anonymous are folded together when reported.
+
+CREATE TABLE IF NOT EXISTS work_source_daily_hits (
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    referrer_class VARCHAR(20) NOT NULL
+        CHECK (referrer_class IN ('search', 'tag', 'internal', 'external', 'direct')),
+    -- ISO 3166-1 alpha-2, or ZZ when the country is unknown
+    country CHAR(2) NOT NULL DEFAULT 'ZZ',
+    hit_date DATE NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (work_id, referrer_class, country, hit_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_work_source_daily_hits_date ON work_source_daily_hits(hit_date);
+
+-- The dashboard's breakdown of hits across all of a user's works over the
+-- trend window, rebuilt with user_stats_rollups
+CREATE TABLE IF NOT EXISTS user_hit_source_rollups (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dimension VARCHAR(10) NOT NULL CHECK (dimension IN ('referrer', 'country')),
+    source VARCHAR(20) NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, dimension, source)
+);
+
+COMMENT ON TABLE work_source_daily_hits IS 'Unique viewers per work, referrer class, country and day; owner-only analytics';
+COMMENT ON COLUMN work_source_daily_hits.country IS 'Viewer country from the gateway''s GeoIP lookup; ZZ when unknown';
+COMMENT ON TABLE user_hit_source_rollups IS 'Hits across each user''s works by referrer class and by country over the dashboard trend window';
@@ -0,0 +1,23 @@
+-- Nuclear AO3: reading history and personalized search ranking
+-- Signed-in readers' visits to works are kept so they can find works again,
+-- and, if they opt in, so search can rank works from the fandoms and tags
+-- they read most higher. Readers can clear their history at any time.
+
+CREATE TABLE IF NOT EXISTS reading_history (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    first_read_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_read_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    visits INTEGER NOT NULL DEFAULT 1,
+
+    PRIMARY KEY (user_id, work_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reading_history_user_recent ON reading_history(user_id, last_read_at DESC);
+
+ALTER TABLE search_preferences
+    ADD COLUMN IF NOT EXISTS personalized_ranking BOOLEAN NOT NULL DEFAULT false;
+
+COMMENT ON TABLE reading_history IS 'Works each signed-in reader has visited';
+COMMENT ON COLUMN reading_history.visits IS 'Visits, counting at most one per hour';
+COMMENT ON COLUMN search_preferences.personalized_ranking IS 'Opt-in: rank works from the reader''s most read fandoms and tags higher';
@@ -0,0 +1,11 @@
+-- Nuclear AO3: Pausing notifications
+-- Users can pause all but critical notifications for a number of days. What
+-- arrives during the pause is sent as one digest when it ends.
+
+ALTER TABLE IF EXISTS notification_preferences
+    ADD COLUMN IF NOT EXISTS paused_until TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE IF EXISTS user_notification_preferences
+    ADD COLUMN IF NOT EXISTS paused_until TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN notification_preferences.paused_until IS 'Only critical notifications are delivered before this time';
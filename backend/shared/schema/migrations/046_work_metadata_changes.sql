@@ -0,0 +1,20 @@
+-- Nuclear AO3: work metadata change log
+-- Every field a work edit changes (title, summary, notes, rating and tags)
+-- is recorded with who changed it and the old and new values, so co-authors
+-- can see who changed what. Changes saved by one edit share a change set.
+
+CREATE TABLE IF NOT EXISTS work_metadata_changes (
+    id UUID PRIMARY KEY,
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    change_set_id UUID NOT NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    field VARCHAR(50) NOT NULL,
+    old_value JSONB NOT NULL,
+    new_value JSONB NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_work_metadata_changes_work ON work_metadata_changes(work_id, changed_at DESC);
+
+COMMENT ON TABLE work_metadata_changes IS 'Field-level history of work metadata edits, shown to the work''s creators';
+COMMENT ON COLUMN work_metadata_changes.change_set_id IS 'Shared by the fields changed in one edit';
@@ -0,0 +1,27 @@
+-- Nuclear AO3: Stats service rollups
+-- The stats service now owns hit counting. Alongside the daily hit tables it
+-- rolls kudos up by day, which trending works are scored from, and records
+-- when a work's hit and kudos totals first cross a milestone.
+
+CREATE TABLE IF NOT EXISTS work_daily_kudos (
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    kudos_date DATE NOT NULL,
+    kudos INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (work_id, kudos_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_work_daily_kudos_date ON work_daily_kudos(kudos_date);
+
+CREATE TABLE IF NOT EXISTS work_stat_milestones (
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    metric VARCHAR(10) NOT NULL CHECK (metric IN ('hits', 'kudos')),
+    threshold INTEGER NOT NULL,
+    reached_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (work_id, metric, threshold)
+);
+
+CREATE INDEX IF NOT EXISTS idx_work_stat_milestones_reached ON work_stat_milestones(reached_at);
+
+COMMENT ON TABLE work_daily_kudos IS 'Kudos given per work per day, net of kudos removed, flushed by the stats service';
+COMMENT ON TABLE work_stat_milestones IS 'When a work''s hits or kudos first reached each milestone';
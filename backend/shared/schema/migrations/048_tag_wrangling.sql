@@ -0,0 +1,76 @@
+-- Nuclear AO3: Tag wrangling workflow
+-- Wranglers make tags canonical, attach synonyms (moving the synonym's works
+-- onto its canonical), arrange canonicals into parent/child metatags and
+-- approve merge requests. Every action is written to an append-only log
+-- with who did it and when.
+
+-- A tag may now be unwrangled: neither canonical nor a synonym. Only a
+-- canonical tag with a canonical_name of its own is invalid.
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS canonical_logic;
+ALTER TABLE tags ADD CONSTRAINT canonical_logic CHECK (NOT (is_canonical AND canonical_name IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS idx_tags_unwrangled ON tags(type, use_count DESC)
+    WHERE is_canonical = false AND canonical_name IS NULL;
+
+CREATE TABLE IF NOT EXISTS tag_merge_requests (
+    id UUID PRIMARY KEY,
+    source_tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    target_tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    review_note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT tag_merge_distinct CHECK (source_tag_id != target_tag_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tag_merge_requests_pending
+    ON tag_merge_requests(source_tag_id, target_tag_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_tag_merge_requests_status ON tag_merge_requests(status, created_at);
+
+-- Log rows name their tags as well as pointing at them and have no foreign
+-- keys, so they outlive the tags and users they mention
+CREATE TABLE IF NOT EXISTS tag_wrangling_log (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(30) NOT NULL CHECK (action IN (
+        'make_canonical', 'remove_canonical', 'add_synonym', 'add_parent', 'remove_parent',
+        'merge_requested', 'merge_approved', 'merge_rejected'
+    )),
+    tag_id UUID NOT NULL,
+    tag_name TEXT NOT NULL,
+    related_tag_id UUID,
+    related_tag_name TEXT,
+    actor_id UUID,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_wrangling_log_tag ON tag_wrangling_log(tag_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_tag_wrangling_log_related ON tag_wrangling_log(related_tag_id, id DESC) WHERE related_tag_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tag_wrangling_log_actor ON tag_wrangling_log(actor_id, id DESC);
+
+CREATE OR REPLACE FUNCTION reject_tag_wrangling_log_change()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RAISE EXCEPTION 'tag_wrangling_log is append-only';
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trigger_tag_wrangling_log_immutable ON tag_wrangling_log;
+CREATE TRIGGER trigger_tag_wrangling_log_immutable
+    BEFORE UPDATE OR DELETE ON tag_wrangling_log
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_tag_wrangling_log_change();
+
+DROP TRIGGER IF EXISTS trigger_tag_wrangling_log_no_truncate ON tag_wrangling_log;
+CREATE TRIGGER trigger_tag_wrangling_log_no_truncate
+    BEFORE TRUNCATE ON tag_wrangling_log
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION reject_tag_wrangling_log_change();
+
+COMMENT ON TABLE tag_merge_requests IS 'Requests to merge one tag into another, approved or rejected by a second wrangler';
+COMMENT ON TABLE tag_wrangling_log IS 'Append-only record of every wrangling action, with actor and time';
@@ -0,0 +1,8 @@
+-- Nuclear AO3: Canonical tag suggestions
+-- When a work is posted, tags the archive doesn't know are matched against
+-- canonical tags spelled with the same letters and digits, so "hurt comfort"
+-- can suggest "Hurt/Comfort". This index keeps that lookup off a full scan.
+
+CREATE INDEX IF NOT EXISTS idx_tags_canonical_match_key
+    ON tags(type, regexp_replace(lower(name::text), '[^[:alnum:]]+', '', 'g'))
+    WHERE is_canonical = true;
@@ -0,0 +1,58 @@
+-- Nuclear AO3: Tag sets
+-- A tag set is a named, curated list of fandoms, characters and
+-- relationships. Its owner adds tags directly or opens nominations and
+-- approves what participants nominate. A gift exchange can restrict its
+-- sign-ups to the tags in one or more tag sets.
+
+CREATE TABLE IF NOT EXISTS tag_sets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    nominations_open BOOLEAN NOT NULL DEFAULT false,
+    -- How many tags of each type one user may nominate, e.g. {"fandom": {"min": 0, "max": 3}}
+    nomination_limits JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_sets_owner ON tag_sets(owner_id);
+
+CREATE TABLE IF NOT EXISTS tag_set_tags (
+    tag_set_id UUID NOT NULL REFERENCES tag_sets(id) ON DELETE CASCADE,
+    tag_type VARCHAR(20) NOT NULL CHECK (tag_type IN ('fandom', 'character', 'relationship')),
+    name CITEXT NOT NULL,
+    -- The fandom a character or relationship was added under, if any
+    fandom CITEXT,
+    added_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tag_set_id, tag_type, name)
+);
+
+CREATE TABLE IF NOT EXISTS tag_set_nominations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tag_set_id UUID NOT NULL REFERENCES tag_sets(id) ON DELETE CASCADE,
+    nominator_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tag_type VARCHAR(20) NOT NULL CHECK (tag_type IN ('fandom', 'character', 'relationship')),
+    name CITEXT NOT NULL,
+    fandom CITEXT,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (tag_set_id, nominator_id, tag_type, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_set_nominations_review ON tag_set_nominations(tag_set_id, status, tag_type, name);
+
+-- Challenges whose sign-ups are restricted to the union of these tag sets
+CREATE TABLE IF NOT EXISTS challenge_tag_sets (
+    collection_id UUID NOT NULL REFERENCES challenge_settings(collection_id) ON DELETE CASCADE,
+    tag_set_id UUID NOT NULL REFERENCES tag_sets(id) ON DELETE RESTRICT,
+    PRIMARY KEY (collection_id, tag_set_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_tag_sets_tag_set ON challenge_tag_sets(tag_set_id);
+
+COMMENT ON TABLE tag_sets IS 'Curated lists of fandoms, characters and relationships that challenges can restrict sign-ups to';
+COMMENT ON TABLE tag_set_nominations IS 'Tags users nominate for a tag set, pending the owner''s approval';
@@ -0,0 +1,9 @@
+-- Nuclear AO3: Frozen comment threads
+-- A work's creator (or an admin) can freeze a comment and every reply
+-- beneath it. Frozen comments stay visible but take no new replies.
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS is_frozen BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS frozen_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS frozen_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN comments.is_frozen IS 'Replies to this comment are rejected; set on a whole thread at once';
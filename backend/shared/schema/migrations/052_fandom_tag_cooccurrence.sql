@@ -0,0 +1,18 @@
+-- Nuclear AO3: Common tags for fandom pages
+-- How many posted works pair each fandom with each canonical character,
+-- relationship and freeform tag. Rebuilt on a schedule by tag-service, which
+-- keeps the most common tags of each type per fandom.
+
+CREATE TABLE IF NOT EXISTS fandom_tag_cooccurrence (
+    fandom_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    tag_type VARCHAR(50) NOT NULL,
+    work_count INTEGER NOT NULL,
+    computed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (fandom_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fandom_tag_cooccurrence_rank
+    ON fandom_tag_cooccurrence(fandom_id, tag_type, work_count DESC);
+
+COMMENT ON TABLE fandom_tag_cooccurrence IS 'Works pairing a fandom with a tag, for ranking the fandom''s common tags';
@@ -0,0 +1,33 @@
+-- Nuclear AO3: Tag use_count maintenance
+-- use_count is owned by the work_tags trigger alone. The trigger now also
+-- follows rows moved between tags (as wrangling synonyms does) and never
+-- lets a count go negative; tag-service stops adjusting counts itself, which
+-- double counted every tag added through its API. Counts that already
+-- drifted are repaired by tag-service's reconciliation job.
+
+CREATE OR REPLACE FUNCTION update_tag_use_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.tag_id = NEW.tag_id THEN
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE tags SET use_count = use_count + 1 WHERE id = NEW.tag_id;
+    END IF;
+    IF TG_OP IN ('DELETE', 'UPDATE') THEN
+        UPDATE tags SET use_count = GREATEST(use_count - 1, 0) WHERE id = OLD.tag_id;
+    END IF;
+
+    RETURN COALESCE(NEW, OLD);
+END;
+$$ language 'plpgsql';
+
+UPDATE tags SET use_count = 0 WHERE use_count IS NULL;
+ALTER TABLE tags ALTER COLUMN use_count SET DEFAULT 0;
+ALTER TABLE tags ALTER COLUMN use_count SET NOT NULL;
+
+DROP TRIGGER IF EXISTS update_tag_use_count_trigger ON work_tags;
+CREATE TRIGGER update_tag_use_count_trigger
+    AFTER INSERT OR DELETE OR UPDATE OF tag_id ON work_tags
+    FOR EACH ROW EXECUTE FUNCTION update_tag_use_count();
@@ -0,0 +1,20 @@
+-- Nuclear AO3: Wrangling queue claims
+-- A wrangler claims a batch of unwrangled tags so two wranglers don't work
+-- the same tags. Claims lapse at expires_at; tag-service ignores lapsed
+-- claims and sweeps them away on a schedule.
+
+CREATE TABLE IF NOT EXISTS tag_wrangling_claims (
+    tag_id UUID PRIMARY KEY REFERENCES tags(id) ON DELETE CASCADE,
+    wrangler_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    claimed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_wrangling_claims_wrangler ON tag_wrangling_claims(wrangler_id);
+CREATE INDEX IF NOT EXISTS idx_tag_wrangling_claims_expires_at ON tag_wrangling_claims(expires_at);
+
+-- The queue sorts unwrangled tags by age as well as by use
+CREATE INDEX IF NOT EXISTS idx_tags_unwrangled_created_at ON tags(created_at)
+    WHERE is_canonical = false AND canonical_name IS NULL;
+
+COMMENT ON TABLE tag_wrangling_claims IS 'Unwrangled tags a wrangler is working through; a lapsed claim frees the tag';
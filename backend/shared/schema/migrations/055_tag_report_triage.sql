@@ -0,0 +1,35 @@
+-- Nuclear AO3: Tag report triage
+-- Users report tags that are miscategorised, should be wrangled together or
+-- break the rules. A report moves open -> in_review -> resolved/rejected
+-- with the reviewer's notes. A report of the same tag for the same reason
+-- as an open one is filed as its duplicate and follows it, so wranglers
+-- see one report and every reporter hears the outcome.
+
+CREATE TABLE IF NOT EXISTS tag_reports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason VARCHAR(30) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    duplicate_of UUID REFERENCES tag_reports(id) ON DELETE SET NULL,
+    reviewer_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewer_notes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    review_started_at TIMESTAMP WITH TIME ZONE,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT tag_report_reason_values CHECK (reason IN ('wrong_type', 'should_be_synonym', 'should_be_canonical', 'offensive', 'spam', 'other')),
+    CONSTRAINT tag_report_status_values CHECK (status IN ('open', 'in_review', 'resolved', 'rejected'))
+);
+
+-- At most one live primary report per tag and reason; later ones are its
+-- duplicates
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tag_reports_live_primary ON tag_reports(tag_id, reason)
+    WHERE duplicate_of IS NULL AND status IN ('open', 'in_review');
+CREATE INDEX IF NOT EXISTS idx_tag_reports_status ON tag_reports(status, created_at) WHERE duplicate_of IS NULL;
+CREATE INDEX IF NOT EXISTS idx_tag_reports_duplicate_of ON tag_reports(duplicate_of) WHERE duplicate_of IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tag_reports_reporter ON tag_reports(reporter_id);
+
+COMMENT ON COLUMN tag_reports.duplicate_of IS 'The live report of the same tag and reason this one was filed under; it follows that report''s status';
@@ -0,0 +1,32 @@
+-- Nuclear AO3: Push devices
+-- Browsers (Web Push) and app installs (FCM, APNs) register here to receive
+-- push notifications. A device the push service reports as unregistered is
+-- deleted on the next delivery. Web push and mobile push are switched on
+-- separately in a user's notification preferences.
+
+CREATE TABLE IF NOT EXISTS push_devices (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    platform VARCHAR(10) NOT NULL,
+    token TEXT NOT NULL,
+    p256dh TEXT NOT NULL DEFAULT '',
+    auth TEXT NOT NULL DEFAULT '',
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT push_device_platform_values CHECK (platform IN ('web', 'fcm', 'apns')),
+    CONSTRAINT push_device_web_keys CHECK (platform <> 'web' OR (p256dh <> '' AND auth <> ''))
+);
+
+-- A token belongs to one install; registering it again moves it to the
+-- user now signed in there
+CREATE UNIQUE INDEX IF NOT EXISTS idx_push_devices_token ON push_devices(platform, token);
+CREATE INDEX IF NOT EXISTS idx_push_devices_user ON push_devices(user_id, platform);
+
+COMMENT ON COLUMN push_devices.token IS 'FCM or APNs device token, or the Web Push subscription endpoint';
+COMMENT ON COLUMN push_devices.p256dh IS 'Web Push subscription public key (base64url), used to encrypt payloads';
+COMMENT ON COLUMN push_devices.auth IS 'Web Push subscription auth secret (base64url)';
+
+ALTER TABLE IF EXISTS user_notification_preferences
+    ADD COLUMN IF NOT EXISTS web_push_enabled BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,34 @@
+-- Nuclear AO3: Digest scheduling
+-- Notifications due in a daily or weekly digest wait undelivered with the
+-- digest they belong to, and the digest scheduler sends them at the user's
+-- local digest time. Each scheduled digest records the slot it was sent
+-- for, so two notification-service replicas can't send the same one.
+
+-- notification_items is created by the notification service's schema, so
+-- only touch it where it exists
+DO $$
+BEGIN
+    IF to_regclass('notification_items') IS NOT NULL THEN
+        ALTER TABLE notification_items ADD COLUMN IF NOT EXISTS digest_frequency VARCHAR(20);
+        CREATE INDEX IF NOT EXISTS idx_notification_items_awaiting_digest
+            ON notification_items(digest_frequency, user_id)
+            WHERE is_delivered = false AND digest_frequency IS NOT NULL;
+        COMMENT ON COLUMN notification_items.digest_frequency IS 'daily or weekly when the notification waits for a scheduled digest';
+    END IF;
+END $$;
+
+ALTER TABLE notification_digests
+    ADD COLUMN IF NOT EXISTS scheduled_for TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS error TEXT;
+
+-- Batched digests and the digest sent when a pause ends were already
+-- written with these types
+ALTER TABLE notification_digests DROP CONSTRAINT IF EXISTS digest_type_check;
+ALTER TABLE notification_digests ADD CONSTRAINT digest_type_check
+    CHECK (digest_type IN ('daily', 'weekly', 'batched', 'paused'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_digests_slot
+    ON notification_digests(user_id, digest_type, scheduled_for)
+    WHERE scheduled_for IS NOT NULL;
+
+COMMENT ON COLUMN notification_digests.scheduled_for IS 'The digest slot (user''s local digest time, in UTC) a scheduled digest was sent for';
@@ -0,0 +1,18 @@
+-- Nuclear AO3: Notification archive
+-- Archiving moves a notification out of the inbox without deleting it. The
+-- inbox lists notifications with no archived_at, newest first, and the
+-- archive those with one.
+
+DO $$
+BEGIN
+    IF to_regclass('notification_items') IS NOT NULL THEN
+        ALTER TABLE notification_items ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE;
+        CREATE INDEX IF NOT EXISTS idx_notification_items_inbox
+            ON notification_items(user_id, created_at DESC)
+            WHERE archived_at IS NULL;
+        CREATE INDEX IF NOT EXISTS idx_notification_items_archive
+            ON notification_items(user_id, created_at DESC)
+            WHERE archived_at IS NOT NULL;
+        COMMENT ON COLUMN notification_items.archived_at IS 'When the user archived the notification; NULL while it is in the inbox';
+    END IF;
+END $$;
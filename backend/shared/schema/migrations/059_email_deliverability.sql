@@ -0,0 +1,50 @@
+-- Nuclear AO3: Email deliverability
+-- Bounces and spam complaints come back from SMTP and from the SES and
+-- SendGrid webhooks. A hard bounce, a complaint or too many soft bounces in
+-- a week puts the address on the suppression list and switches email off
+-- for its account; nothing is sent to a suppressed address. Each email
+-- delivery keeps its latest status, keyed by the ID in its Message-ID.
+
+CREATE TABLE IF NOT EXISTS email_suppressions (
+    address TEXT PRIMARY KEY, -- lower-cased
+    reason VARCHAR(20) NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    provider VARCHAR(20) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT email_suppression_reason_values CHECK (reason IN ('hard_bounce', 'soft_bounces', 'complaint', 'manual'))
+);
+
+CREATE TABLE IF NOT EXISTS email_bounces (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    address TEXT NOT NULL,
+    bounce_type VARCHAR(10) NOT NULL,
+    error_type VARCHAR(50) NOT NULL DEFAULT '',
+    diagnostic TEXT NOT NULL DEFAULT '',
+    provider VARCHAR(20) NOT NULL DEFAULT '',
+    delivery_id UUID,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT email_bounce_type_values CHECK (bounce_type IN ('hard', 'soft'))
+);
+
+-- Soft bounces are counted per address over a recent window
+CREATE INDEX IF NOT EXISTS idx_email_bounces_address ON email_bounces(address, bounce_type, occurred_at DESC);
+
+CREATE TABLE IF NOT EXISTS email_deliveries (
+    id UUID PRIMARY KEY,
+    message_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    address TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error JSONB,
+    attempted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_deliveries_message ON email_deliveries(message_id, attempted_at);
+CREATE INDEX IF NOT EXISTS idx_email_deliveries_user ON email_deliveries(user_id, attempted_at DESC);
+
+COMMENT ON COLUMN email_deliveries.id IS 'Delivery attempt ID, sent as the local part of the Message-ID header';
+COMMENT ON COLUMN email_deliveries.error IS 'Classified delivery error (models.DeliveryError) from the last failure or bounce';
@@ -0,0 +1,36 @@
+-- Nuclear AO3: Notification event outbox
+-- work-service records each notification event here instead of posting it
+-- to notification-service, so an event survives a crash or an outage of
+-- either service. A publisher moves pending events onto a Redis stream that
+-- notification-service consumes at least once; each event's idempotency key
+-- is recorded once it has been processed, so a redelivered event is skipped.
+
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    idempotency_key UUID NOT NULL UNIQUE,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMP WITH TIME ZONE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    dead_lettered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_pending
+    ON notification_outbox(id) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_dead
+    ON notification_outbox(dead_lettered_at) WHERE dead_lettered_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_published
+    ON notification_outbox(published_at) WHERE published_at IS NOT NULL;
+
+-- Events notification-service has processed, by idempotency key
+CREATE TABLE IF NOT EXISTS notification_event_receipts (
+    idempotency_key UUID PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_event_receipts_processed
+    ON notification_event_receipts(processed_at);
@@ -0,0 +1,24 @@
+-- Nuclear AO3: Milestone notifications
+-- Authors choose the hit and kudos totals they want to hear about; NULL
+-- thresholds mean the defaults. stats-service checks them after each flush
+-- and records every milestone it notifies a creator of, so a total that
+-- dips below a threshold and climbs back isn't announced again.
+
+CREATE TABLE IF NOT EXISTS user_milestone_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    hit_thresholds INTEGER[],
+    kudos_thresholds INTEGER[],
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS milestone_notifications (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    work_id UUID NOT NULL REFERENCES works(id) ON DELETE CASCADE,
+    metric VARCHAR(10) NOT NULL CHECK (metric IN ('hits', 'kudos')),
+    threshold INTEGER NOT NULL,
+    notified_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, work_id, metric, threshold)
+);
+
+CREATE INDEX IF NOT EXISTS idx_milestone_notifications_work ON milestone_notifications(work_id);
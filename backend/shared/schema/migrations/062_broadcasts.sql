@@ -0,0 +1,39 @@
+-- Nuclear AO3: Admin broadcast announcements
+-- An admin composes an announcement for everyone or for a segment of users
+-- (by role, locale or recent activity), to go out now or at a set time.
+-- notification-service fans it out in throttled batches, walking users in id
+-- order; last_user_id is how far it has got, so a restart resumes where it
+-- stopped. The counts are the broadcast's delivery metrics.
+
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    title VARCHAR(200) NOT NULL,
+    body TEXT NOT NULL,
+    action_url TEXT,
+    send_email BOOLEAN NOT NULL DEFAULT FALSE,
+    segment JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled'
+        CHECK (status IN ('scheduled', 'sending', 'sent', 'cancelled')),
+    scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    last_user_id UUID,
+    recipient_count INTEGER NOT NULL DEFAULT 0,
+    email_count INTEGER NOT NULL DEFAULT 0,
+    email_failed_count INTEGER NOT NULL DEFAULT 0,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcasts_due
+    ON broadcasts(scheduled_at) WHERE status IN ('scheduled', 'sending');
+CREATE INDEX IF NOT EXISTS idx_broadcasts_created ON broadcasts(created_at DESC);
+
+-- Read metrics count a broadcast's notifications by source
+DO $$
+BEGIN
+    IF to_regclass('notification_items') IS NOT NULL THEN
+        CREATE INDEX IF NOT EXISTS idx_notification_items_source
+            ON notification_items(source_type, source_id);
+    END IF;
+END $$;
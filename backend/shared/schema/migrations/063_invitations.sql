@@ -0,0 +1,54 @@
+-- Nuclear AO3: Invitations
+-- While invite_required is on, registering takes an invitation code. Codes
+-- come from the request queue (auth-service issues queue_batch_size of them
+-- every queue_interval_hours, oldest request first), from users whose
+-- accounts are old enough to invite friends, or from admins. Each code
+-- registers one account before it expires.
+
+CREATE TABLE IF NOT EXISTS invitation_settings (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    invite_required BOOLEAN NOT NULL DEFAULT FALSE,
+    queue_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    queue_batch_size INTEGER NOT NULL DEFAULT 100 CHECK (queue_batch_size >= 0),
+    queue_interval_hours INTEGER NOT NULL DEFAULT 24 CHECK (queue_interval_hours > 0),
+    invite_expiry_days INTEGER NOT NULL DEFAULT 14 CHECK (invite_expiry_days > 0),
+    user_invites_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    user_min_account_days INTEGER NOT NULL DEFAULT 30 CHECK (user_min_account_days >= 0),
+    user_invites_per_month INTEGER NOT NULL DEFAULT 2 CHECK (user_invites_per_month >= 0),
+    last_queue_issue_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT INTO invitation_settings (id) VALUES (TRUE) ON CONFLICT (id) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(32) NOT NULL UNIQUE,
+    source VARCHAR(10) NOT NULL CHECK (source IN ('queue', 'user', 'admin')),
+    creator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    invitee_email VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- delivered_at is set once the code has been emailed to invitee_email
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    redeemed_at TIMESTAMP WITH TIME ZONE,
+    redeemed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_creator ON invitations(creator_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_invitations_undelivered
+    ON invitations(created_at) WHERE delivered_at IS NULL AND invitee_email IS NOT NULL AND revoked_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS invite_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    email VARCHAR(255) NOT NULL,
+    requested_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    invitation_id UUID REFERENCES invitations(id) ON DELETE SET NULL,
+    invited_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invite_requests_email ON invite_requests(LOWER(email));
+CREATE INDEX IF NOT EXISTS idx_invite_requests_waiting
+    ON invite_requests(requested_at) WHERE invited_at IS NULL;
@@ -0,0 +1,51 @@
+-- Nuclear AO3: Abuse tickets
+-- Reporting a work, chapter or comment opens a ticket for the abuse team.
+-- Tickets carry a category and priority, are assigned to admins and move
+-- open -> in_progress -> resolved or dismissed. Evidence is a snapshot of
+-- the reported content taken when the report was filed, so later edits or
+-- deletions don't erase it. Every change is recorded as an update; internal
+-- updates are for admins only, the rest are shown to the reporter.
+
+CREATE TABLE IF NOT EXISTS abuse_tickets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    target_type VARCHAR(20) NOT NULL CHECK (target_type IN ('work', 'comment')),
+    work_id UUID REFERENCES works(id) ON DELETE SET NULL,
+    chapter_id UUID REFERENCES chapters(id) ON DELETE SET NULL,
+    comment_id UUID REFERENCES comments(id) ON DELETE SET NULL,
+    reporter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    reporter_ip INET,
+    category VARCHAR(50) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    priority VARCHAR(10) NOT NULL DEFAULT 'normal' CHECK (priority IN ('low', 'normal', 'high', 'urgent')),
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'in_progress', 'resolved', 'dismissed')),
+    assignee_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolution TEXT,
+    evidence JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_abuse_tickets_queue ON abuse_tickets(status, priority, created_at);
+CREATE INDEX IF NOT EXISTS idx_abuse_tickets_assignee ON abuse_tickets(assignee_id, status);
+CREATE INDEX IF NOT EXISTS idx_abuse_tickets_reporter ON abuse_tickets(reporter_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_abuse_tickets_work ON abuse_tickets(work_id);
+CREATE INDEX IF NOT EXISTS idx_abuse_tickets_comment ON abuse_tickets(comment_id);
+
+CREATE TABLE IF NOT EXISTS abuse_ticket_updates (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ticket_id UUID NOT NULL REFERENCES abuse_tickets(id) ON DELETE CASCADE,
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('note', 'status', 'assignment', 'priority')),
+    body TEXT NOT NULL DEFAULT '',
+    from_value VARCHAR(50),
+    to_value VARCHAR(50),
+    internal BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_abuse_ticket_updates_ticket ON abuse_ticket_updates(ticket_id, created_at);
+
+COMMENT ON TABLE abuse_tickets IS 'Reports of works, chapters and comments, worked by the abuse team';
+COMMENT ON COLUMN abuse_tickets.evidence IS 'Snapshot of the reported work, chapter and comment when the report was filed';
+COMMENT ON TABLE abuse_ticket_updates IS 'Notes, status, assignment and priority changes on abuse tickets; internal ones are hidden from the reporter';
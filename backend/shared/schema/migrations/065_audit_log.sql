@@ -0,0 +1,29 @@
+-- Nuclear AO3: Audit log
+-- One row per admin or wrangler action, from every service: who acted, from
+-- which IP, what they did, to which object, and the object's state before
+-- and after with the fields that changed. Entries are only ever inserted;
+-- actions made with a service token have no actor. moderation_logs is kept
+-- for the author-facing moderation history.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    service VARCHAR(50) NOT NULL,
+    action VARCHAR(200) NOT NULL,
+    target_type VARCHAR(50),
+    target_id VARCHAR(100),
+    before JSONB,
+    after JSONB,
+    diff JSONB,
+    reason TEXT,
+    ip_address INET,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action, created_at DESC);
+
+COMMENT ON TABLE audit_log IS 'Append-only record of admin and wrangler actions across the services';
+COMMENT ON COLUMN audit_log.diff IS 'Top-level fields that differ between before and after';
@@ -0,0 +1,107 @@
+-- Nuclear AO3: Work trash
+-- Deleting a work moves it to the author's trash instead of removing it:
+-- the work is hidden from every listing, search and work page, and the
+-- author can restore it until the trash window (30 days by default) runs
+-- out. work-service's scheduler then purges it with the full cascading
+-- delete. A restored work gets back the status it had when deleted.
+
+ALTER TABLE works ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE works ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE works ADD COLUMN IF NOT EXISTS status_before_delete VARCHAR(20);
+
+ALTER TABLE works DROP CONSTRAINT IF EXISTS work_status_values;
+ALTER TABLE works ADD CONSTRAINT work_status_values
+    CHECK (status IN ('draft', 'published', 'complete', 'abandoned', 'hiatus', 'deleted'));
+
+CREATE INDEX IF NOT EXISTS idx_works_deleted_at
+    ON works(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Trashed works are visible to nobody, their authors included; the trash
+-- endpoints read them directly.
+CREATE OR REPLACE FUNCTION can_user_view_work(work_uuid UUID, viewer_uuid UUID DEFAULT NULL)
+RETURNS BOOLEAN AS $$
+DECLARE
+    work_record RECORD;
+    is_blocked BOOLEAN := false;
+    is_muted BOOLEAN := false;
+    is_author BOOLEAN := false;
+BEGIN
+    -- Get work privacy settings
+    SELECT restricted_to_users, restricted_to_adults, status, user_id, is_anonymous, in_anon_collection, deleted_at
+    INTO work_record
+    FROM works
+    WHERE id = work_uuid;
+
+    -- Work doesn't exist
+    IF NOT FOUND THEN
+        RETURN false;
+    END IF;
+
+    -- Work is in its author's trash
+    IF work_record.deleted_at IS NOT NULL THEN
+        RETURN false;
+    END IF;
+
+    -- Check if viewer is one of the authors (for anonymous works)
+    IF viewer_uuid IS NOT NULL THEN
+        SELECT EXISTS(
+            SELECT 1 FROM creatorships c
+            JOIN pseuds p ON c.pseud_id = p.id
+            WHERE c.creation_id = work_uuid
+            AND c.creation_type = 'Work'
+            AND c.approved = true
+            AND p.user_id = viewer_uuid
+        ) INTO is_author;
+    END IF;
+
+    -- Draft works are only visible to their authors
+    IF work_record.status = 'draft' THEN
+        RETURN is_author;
+    END IF;
+
+    -- Check if work is restricted to users only
+    IF work_record.restricted_to_users = true AND viewer_uuid IS NULL THEN
+        RETURN false;
+    END IF;
+
+    -- For anonymous works, we still check blocks/mutes against actual authors
+    IF viewer_uuid IS NOT NULL AND NOT is_author THEN
+        -- Check if viewer is blocked by any author
+        SELECT EXISTS(
+            SELECT 1 FROM user_blocks ub
+            JOIN pseuds p ON ub.blocker_id = p.user_id
+            JOIN creatorships c ON p.id = c.pseud_id
+            WHERE c.creation_id = work_uuid
+            AND c.creation_type = 'Work'
+            AND c.approved = true
+            AND ub.blocked_id = viewer_uuid
+            AND ub.block_type IN ('full', 'works')
+        ) INTO is_blocked;
+
+        IF is_blocked THEN
+            RETURN false;
+        END IF;
+
+        -- Check if viewer has muted any author
+        SELECT EXISTS(
+            SELECT 1 FROM user_mutes um
+            JOIN pseuds p ON um.muted_id = p.user_id
+            JOIN creatorships c ON p.id = c.pseud_id
+            WHERE c.creation_id = work_uuid
+            AND c.creation_type = 'Work'
+            AND c.approved = true
+            AND um.muter_id = viewer_uuid
+        ) INTO is_muted;
+
+        IF is_muted THEN
+            RETURN false;
+        END IF;
+    END IF;
+
+    RETURN true;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON COLUMN works.deleted_at IS 'When the work was moved to the trash; NULL for live works';
+COMMENT ON COLUMN works.deleted_by IS 'Who moved the work to the trash';
+COMMENT ON COLUMN works.status_before_delete IS 'Status a restored work returns to';
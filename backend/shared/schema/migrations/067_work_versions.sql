@@ -0,0 +1,12 @@
+-- Nuclear AO3: Work and chapter versions
+-- Every author edit of a work's metadata or a chapter bumps its version.
+-- Edits name the version they started from (If-Match or "version"), and
+-- one made against a stale version is refused with the latest instead of
+-- silently overwriting a co-author's changes. Counters, stats and
+-- scheduled changes don't bump the version, so they never cause conflicts.
+
+ALTER TABLE works ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE chapters ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+COMMENT ON COLUMN works.version IS 'Bumped by every author edit; updates must name the current version';
+COMMENT ON COLUMN chapters.version IS 'Bumped by every author edit; updates must name the current version';
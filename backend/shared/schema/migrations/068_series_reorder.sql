@@ -0,0 +1,13 @@
+-- Nuclear AO3: Series reordering
+-- series_works is the only record of which series a work is in, so a work
+-- can be in any number of series, with its own position in each. Position
+-- uniqueness is checked at the end of each statement (or of the
+-- transaction, once deferred) so a reorder or an insert that shifts the
+-- works after it can move positions past each other.
+
+ALTER TABLE series_works DROP CONSTRAINT IF EXISTS series_works_series_id_position_key;
+ALTER TABLE series_works DROP CONSTRAINT IF EXISTS series_works_position_unique;
+ALTER TABLE series_works ADD CONSTRAINT series_works_position_unique
+    UNIQUE (series_id, position) DEFERRABLE INITIALLY IMMEDIATE;
+
+COMMENT ON COLUMN series_works.position IS 'Position of the work in this series, from 1; a work has one per series it is in';
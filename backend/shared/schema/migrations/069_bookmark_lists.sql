@@ -0,0 +1,32 @@
+-- Nuclear AO3: Bookmark lists
+-- A reader can group their bookmarks into named lists with a description,
+-- listed on their profile with a works feed each. Lists are public, but a
+-- private bookmark added to one only ever shows to its owner. Bookmarks
+-- flagged as recs (bookmarks.is_rec) can be filtered on in lists, on the
+-- profile and in bookmark search.
+
+CREATE TABLE IF NOT EXISTS bookmark_lists (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS bookmark_list_items (
+    list_id UUID NOT NULL REFERENCES bookmark_lists(id) ON DELETE CASCADE,
+    bookmark_id UUID NOT NULL REFERENCES bookmarks(id) ON DELETE CASCADE,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (list_id, bookmark_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmark_lists_user ON bookmark_lists(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_bookmark_list_items_bookmark ON bookmark_list_items(bookmark_id);
+CREATE INDEX IF NOT EXISTS idx_bookmark_list_items_list ON bookmark_list_items(list_id, added_at DESC);
+
+COMMENT ON TABLE bookmark_lists IS 'Named public lists a user groups their bookmarks into';
+COMMENT ON TABLE bookmark_list_items IS 'Bookmarks in each bookmark list; private bookmarks are only shown to their owner';
@@ -0,0 +1,109 @@
+-- Nuclear AO3: Unlisted works
+-- An unlisted work is published but never listed: it is left out of
+-- browse, search, tag and series listings, and only its authors and people
+-- holding its share link can read it. A share link carries a token signed
+-- by work-service over the work and share_token_nonce, so issuing a new
+-- link (a new nonce) retires the old one and clearing the nonce revokes it.
+
+ALTER TABLE works ADD COLUMN IF NOT EXISTS share_token_nonce VARCHAR(64);
+ALTER TABLE works ADD COLUMN IF NOT EXISTS share_token_created_at TIMESTAMP WITH TIME ZONE;
+
+-- Every status work-service sets, including the posted and hidden ones
+-- the earlier constraint left out
+ALTER TABLE works DROP CONSTRAINT IF EXISTS work_status_values;
+ALTER TABLE works ADD CONSTRAINT work_status_values
+    CHECK (status IN ('draft', 'posted', 'published', 'complete', 'abandoned', 'hiatus',
+                      'hidden', 'deleted', 'unlisted'));
+
+CREATE OR REPLACE FUNCTION can_user_view_work(work_uuid UUID, viewer_uuid UUID DEFAULT NULL)
+RETURNS BOOLEAN AS $$
+DECLARE
+    work_record RECORD;
+    is_blocked BOOLEAN := false;
+    is_muted BOOLEAN := false;
+    is_author BOOLEAN := false;
+BEGIN
+    -- Get work privacy settings
+    SELECT restricted_to_users, restricted_to_adults, status, user_id, is_anonymous, in_anon_collection, deleted_at
+    INTO work_record
+    FROM works
+    WHERE id = work_uuid;
+
+    -- Work doesn't exist
+    IF NOT FOUND THEN
+        RETURN false;
+    END IF;
+
+    -- Work is in its author's trash
+    IF work_record.deleted_at IS NOT NULL THEN
+        RETURN false;
+    END IF;
+
+    -- Check if viewer is one of the authors (for anonymous works)
+    IF viewer_uuid IS NOT NULL THEN
+        SELECT EXISTS(
+            SELECT 1 FROM creatorships c
+            JOIN pseuds p ON c.pseud_id = p.id
+            WHERE c.creation_id = work_uuid
+            AND c.creation_type = 'Work'
+            AND c.approved = true
+            AND p.user_id = viewer_uuid
+        ) INTO is_author;
+    END IF;
+
+    -- Draft works are only visible to their authors
+    IF work_record.status = 'draft' THEN
+        RETURN is_author;
+    END IF;
+
+    -- Unlisted works are only listed for their authors; everyone else reads
+    -- them through a share link, which work-service checks itself
+    IF work_record.status = 'unlisted' THEN
+        RETURN is_author;
+    END IF;
+
+    -- Check if work is restricted to users only
+    IF work_record.restricted_to_users = true AND viewer_uuid IS NULL THEN
+        RETURN false;
+    END IF;
+
+    -- For anonymous works, we still check blocks/mutes against actual authors
+    IF viewer_uuid IS NOT NULL AND NOT is_author THEN
+        -- Check if viewer is blocked by any author
+        SELECT EXISTS(
+            SELECT 1 FROM user_blocks ub
+            JOIN pseuds p ON ub.blocker_id = p.user_id
+            JOIN creatorships c ON p.id = c.pseud_id
+            WHERE c.creation_id = work_uuid
+            AND c.creation_type = 'Work'
+            AND c.approved = true
+            AND ub.blocked_id = viewer_uuid
+            AND ub.block_type IN ('full', 'works')
+        ) INTO is_blocked;
+
+        IF is_blocked THEN
+            RETURN false;
+        END IF;
+
+        -- Check if viewer has muted any author
+        SELECT EXISTS(
+            SELECT 1 FROM user_mutes um
+            JOIN pseuds p ON um.muted_id = p.user_id
+            JOIN creatorships c ON p.id = c.pseud_id
+            WHERE c.creation_id = work_uuid
+            AND c.creation_type = 'Work'
+            AND c.approved = true
+            AND um.muter_id = viewer_uuid
+        ) INTO is_muted;
+
+        IF is_muted THEN
+            RETURN false;
+        END IF;
+    END IF;
+
+    RETURN true;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON COLUMN works.share_token_nonce IS 'Nonce signed into the current share link of an unlisted work; NULL when no link is valid';
+COMMENT ON COLUMN works.share_token_created_at IS 'When the current share link was issued';
@@ -0,0 +1,48 @@
+// Package schema holds the platform's shared database schema: the tables
+// every service reads and writes, as versioned migrations applied by
+// shared/migrate.
+//
+// Each service gates its startup on these migrations the way export-service
+// gates on its own, recording them in schema_migrations under the "schema"
+// service so they apply once however many services start together.
+// Databases created before the migrations were tracked (from the
+// Postgres init scripts) are brought under tracking with
+// `<binary> migrate baseline <version>`.
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"log"
+	"os"
+
+	"nuclear-ao3/shared/migrate"
+)
+
+// Service is the name the shared migrations are recorded under
+const Service = "schema"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// NewRunner loads the shared migrations
+func NewRunner(db *sql.DB) (*migrate.Runner, error) {
+	return migrate.NewRunner(db, Service, migrationFiles, "migrations")
+}
+
+// Ready readies the shared schema for a starting service. When args are
+// a `migrate [up|status|baseline N]` command it runs that, writing to
+// stdout, and reports done so the service exits instead of serving.
+// Otherwise pending migrations are applied (MIGRATE_ON_START=false
+// refuses to start with any pending instead).
+func Ready(ctx context.Context, db *sql.DB, args []string) (done bool, err error) {
+	migrations, err := NewRunner(db)
+	if err != nil {
+		return false, err
+	}
+	if len(args) > 0 && args[0] == "migrate" {
+		return true, migrations.Command(ctx, args[1:], os.Stdout)
+	}
+	return false, migrations.Gate(ctx, migrate.OnStart(true), log.Printf)
+}
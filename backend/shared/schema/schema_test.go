@@ -0,0 +1,18 @@
+package schema
+
+import "testing"
+
+func TestMigrationsLoad(t *testing.T) {
+	migrations, err := NewRunner(nil)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+	for i, m := range migrations.Migrations {
+		if m.Version != int64(i+1) {
+			t.Fatalf("Expected migration %d next, got %d_%s", i+1, m.Version, m.Name)
+		}
+	}
+	if len(migrations.Migrations) < 70 {
+		t.Errorf("Expected every shared migration embedded, got %d", len(migrations.Migrations))
+	}
+}
@@ -0,0 +1,77 @@
+// Package searchstream is the contract between work-service, which publishes
+// work changes from its outbox, and search-service, which reindexes them
+package searchstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// Stream carries work change events to the search indexer
+	Stream = "search:work_events"
+	// DeadLetterStream holds events the indexer gave up on
+	DeadLetterStream = "search:work_events:dead"
+	// ConsumerGroup is the search-service consumer group on Stream
+	ConsumerGroup = "search-indexer"
+
+	// EventUpsert (re)indexes the work from Document
+	EventUpsert = "upsert"
+	// EventDelete removes the work from the index
+	EventDelete = "delete"
+)
+
+// WorkEvent is one work change. OutboxID increases with every change, so
+// the indexer can use it to drop events older than what it has indexed.
+type WorkEvent struct {
+	OutboxID int64
+	WorkID   string
+	Type     string
+	// Document is the search document for upserts
+	Document json.RawMessage
+}
+
+// Values encodes the event as stream message fields
+func (e WorkEvent) Values() map[string]interface{} {
+	values := map[string]interface{}{
+		"outbox_id": strconv.FormatInt(e.OutboxID, 10),
+		"work_id":   e.WorkID,
+		"type":      e.Type,
+	}
+	if len(e.Document) > 0 {
+		values["document"] = string(e.Document)
+	}
+	return values
+}
+
+// ParseWorkEvent decodes stream message fields into an event
+func ParseWorkEvent(values map[string]interface{}) (WorkEvent, error) {
+	field := func(name string) string {
+		s, _ := values[name].(string)
+		return s
+	}
+
+	event := WorkEvent{WorkID: field("work_id"), Type: field("type")}
+	if event.WorkID == "" {
+		return event, fmt.Errorf("event has no work_id")
+	}
+	id, err := strconv.ParseInt(field("outbox_id"), 10, 64)
+	if err != nil {
+		return event, fmt.Errorf("event has an invalid outbox_id %q", field("outbox_id"))
+	}
+	event.OutboxID = id
+
+	switch event.Type {
+	case EventDelete:
+	case EventUpsert:
+		document := field("document")
+		if document == "" || !json.Valid([]byte(document)) {
+			return event, fmt.Errorf("upsert for work %s has no valid document", event.WorkID)
+		}
+		event.Document = json.RawMessage(document)
+	default:
+		return event, fmt.Errorf("unknown event type %q", event.Type)
+	}
+	return event, nil
+}
@@ -0,0 +1,40 @@
+package searchstream
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWorkEventRoundTrip(t *testing.T) {
+	event := WorkEvent{
+		OutboxID: 42,
+		WorkID:   "0b7c2c3e-7d59-4c57-a3a4-3c8f1d1e2f10",
+		Type:     EventUpsert,
+		Document: json.RawMessage(`{"title":"A Study in Scarlet"}`),
+	}
+
+	parsed, err := ParseWorkEvent(event.Values())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.OutboxID != 42 || parsed.WorkID != event.WorkID || parsed.Type != EventUpsert ||
+		string(parsed.Document) != string(event.Document) {
+		t.Errorf("round trip changed the event: %+v", parsed)
+	}
+}
+
+func TestParseWorkEventRejectsBadMessages(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"no work":        {"outbox_id": "1", "type": EventDelete},
+		"bad outbox id":  {"outbox_id": "x", "work_id": "w", "type": EventDelete},
+		"unknown type":   {"outbox_id": "1", "work_id": "w", "type": "rename"},
+		"upsert, no doc": {"outbox_id": "1", "work_id": "w", "type": EventUpsert},
+		"upsert, bad doc": {"outbox_id": "1", "work_id": "w", "type": EventUpsert,
+			"document": "{"},
+	}
+	for name, values := range cases {
+		if _, err := ParseWorkEvent(values); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Package statsstream is the contract between work-service, which publishes
+// a reader's hits and kudos as they happen, and stats-service, which
+// deduplicates, counts and rolls them up. It also holds the reporting rules
+// every stats view follows.
+package statsstream
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Stream carries hit and kudos events to the stats service. It lives in
+	// the work service's Redis database.
+	Stream = "stats:events"
+	// DeadLetterStream holds events the stats service couldn't decode
+	DeadLetterStream = "stats:events:dead"
+	// ConsumerGroup is the stats-service consumer group on Stream
+	ConsumerGroup = "stats-service"
+
+	// EventHit is a view of a work or one of its chapters
+	EventHit = "hit"
+	// EventKudos is kudos given to a work, or taken back when Delta is -1
+	EventKudos = "kudos"
+
+	// DateLayout is the layout of event dates; days are UTC
+	DateLayout = "2006-01-02"
+)
+
+// Referrer classes a hit is attributed to
+const (
+	ReferrerSearch   = "search"
+	ReferrerTag      = "tag"
+	ReferrerInternal = "internal"
+	ReferrerExternal = "external"
+	ReferrerDirect   = "direct"
+)
+
+// ReferrerClasses lists every referrer class
+var ReferrerClasses = []string{ReferrerSearch, ReferrerTag, ReferrerInternal, ReferrerExternal, ReferrerDirect}
+
+// IsReferrerClass reports whether class is a known referrer class
+func IsReferrerClass(class string) bool {
+	for _, c := range ReferrerClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// NewReferrerCounts starts a count at zero for every referrer class, so
+// classes without hits are still reported
+func NewReferrerCounts() map[string]int {
+	counts := make(map[string]int, len(ReferrerClasses))
+	for _, class := range ReferrerClasses {
+		counts[class] = 0
+	}
+	return counts
+}
+
+const (
+	// UnknownCountry stands in when the gateway couldn't place a viewer
+	UnknownCountry = "ZZ"
+	// OtherCountries collects countries reported with too few hits
+	OtherCountries = "other"
+	// MinCountryHits is the fewest hits a country needs to be reported on
+	// its own, so a handful of readers can't be singled out by location
+	MinCountryHits = 5
+)
+
+// NormalizeCountry accepts a two-letter country code, anything else is
+// unknown
+func NormalizeCountry(country string) string {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+		return UnknownCountry
+	}
+	return country
+}
+
+// CountryHits is one country's share of hits
+type CountryHits struct {
+	Country string `json:"country"`
+	Hits    int    `json:"hits"`
+}
+
+// ReportableCountries orders countries by hits and folds those with fewer
+// than MinCountryHits, and unknown ones, into a single "other" entry
+func ReportableCountries(counts map[string]int) []CountryHits {
+	result := []CountryHits{}
+	other := 0
+	for country, hits := range counts {
+		if hits < MinCountryHits || country == UnknownCountry {
+			other += hits
+			continue
+		}
+		result = append(result, CountryHits{Country: country, Hits: hits})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Hits != result[j].Hits {
+			return result[i].Hits > result[j].Hits
+		}
+		return result[i].Country < result[j].Country
+	})
+	if other > 0 {
+		result = append(result, CountryHits{Country: OtherCountries, Hits: other})
+	}
+	return result
+}
+
+// Event is one hit or kudos. Viewer is an opaque hash identifying the
+// reader for deduplication; it is never a raw user ID or address.
+type Event struct {
+	Type   string
+	WorkID uuid.UUID
+	Date   string
+	// ChapterID, Viewer, Referrer and Country describe hits. ChapterID is
+	// uuid.Nil for the work page.
+	ChapterID uuid.UUID
+	Viewer    string
+	Referrer  string
+	Country   string
+	// Delta is +1 for kudos given and -1 for kudos removed
+	Delta int
+}
+
+// NewHit describes a view happening now
+func NewHit(workID, chapterID uuid.UUID, viewer, referrer, country string) Event {
+	return Event{
+		Type:      EventHit,
+		WorkID:    workID,
+		Date:      time.Now().UTC().Format(DateLayout),
+		ChapterID: chapterID,
+		Viewer:    viewer,
+		Referrer:  referrer,
+		Country:   NormalizeCountry(country),
+	}
+}
+
+// NewKudos describes kudos given (delta 1) or removed (delta -1) now
+func NewKudos(workID uuid.UUID, delta int) Event {
+	return Event{
+		Type:   EventKudos,
+		WorkID: workID,
+		Date:   time.Now().UTC().Format(DateLayout),
+		Delta:  delta,
+	}
+}
+
+// Values encodes the event as stream message fields
+func (e Event) Values() map[string]interface{} {
+	values := map[string]interface{}{
+		"type":    e.Type,
+		"work_id": e.WorkID.String(),
+		"date":    e.Date,
+	}
+	switch e.Type {
+	case EventHit:
+		values["chapter_id"] = e.ChapterID.String()
+		values["viewer"] = e.Viewer
+		values["referrer"] = e.Referrer
+		values["country"] = e.Country
+	case EventKudos:
+		values["delta"] = strconv.Itoa(e.Delta)
+	}
+	return values
+}
+
+// ParseEvent decodes stream message fields into an event
+func ParseEvent(values map[string]interface{}) (Event, error) {
+	field := func(name string) string {
+		s, _ := values[name].(string)
+		return s
+	}
+
+	event := Event{Type: field("type"), Date: field("date")}
+	workID, err := uuid.Parse(field("work_id"))
+	if err != nil {
+		return event, fmt.Errorf("event has an invalid work_id %q", field("work_id"))
+	}
+	event.WorkID = workID
+	if _, err := time.Parse(DateLayout, event.Date); err != nil {
+		return event, fmt.Errorf("event has an invalid date %q", event.Date)
+	}
+
+	switch event.Type {
+	case EventHit:
+		chapterID, err := uuid.Parse(field("chapter_id"))
+		if err != nil {
+			return event, fmt.Errorf("hit has an invalid chapter_id %q", field("chapter_id"))
+		}
+		event.ChapterID = chapterID
+		event.Viewer = field("viewer")
+		if event.Viewer == "" {
+			return event, fmt.Errorf("hit on work %s has no viewer", workID)
+		}
+		event.Referrer = field("referrer")
+		if !IsReferrerClass(event.Referrer) {
+			return event, fmt.Errorf("hit has an unknown referrer class %q", event.Referrer)
+		}
+		event.Country = NormalizeCountry(field("country"))
+	case EventKudos:
+		delta, err := strconv.Atoi(field("delta"))
+		if err != nil || (delta != 1 && delta != -1) {
+			return event, fmt.Errorf("kudos has an invalid delta %q", field("delta"))
+		}
+		event.Delta = delta
+	default:
+		return event, fmt.Errorf("unknown event type %q", event.Type)
+	}
+	return event, nil
+}
@@ -0,0 +1,83 @@
+package statsstream
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestEventRoundTrip(t *testing.T) {
+	hit := NewHit(uuid.New(), uuid.New(), "viewer-hash", ReferrerTag, "gb")
+	parsed, err := ParseEvent(hit.Values())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != hit || parsed.Country != "GB" {
+		t.Errorf("round trip changed the hit: %+v", parsed)
+	}
+
+	kudos := NewKudos(uuid.New(), -1)
+	parsed, err = ParseEvent(kudos.Values())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != kudos {
+		t.Errorf("round trip changed the kudos: %+v", parsed)
+	}
+}
+
+func TestParseEventRejectsBadMessages(t *testing.T) {
+	workID := uuid.New().String()
+	chapterID := uuid.Nil.String()
+	cases := map[string]map[string]interface{}{
+		"no work":      {"type": EventKudos, "date": "2026-10-16", "delta": "1"},
+		"bad date":     {"type": EventKudos, "work_id": workID, "date": "yesterday", "delta": "1"},
+		"unknown type": {"type": "share", "work_id": workID, "date": "2026-10-16"},
+		"kudos, bad delta": {"type": EventKudos, "work_id": workID, "date": "2026-10-16",
+			"delta": "5"},
+		"hit, no viewer": {"type": EventHit, "work_id": workID, "date": "2026-10-16",
+			"chapter_id": chapterID, "referrer": ReferrerDirect},
+		"hit, bad referrer": {"type": EventHit, "work_id": workID, "date": "2026-10-16",
+			"chapter_id": chapterID, "viewer": "v", "referrer": "email"},
+		"hit, no chapter": {"type": EventHit, "work_id": workID, "date": "2026-10-16",
+			"viewer": "v", "referrer": ReferrerDirect},
+	}
+	for name, values := range cases {
+		if _, err := ParseEvent(values); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestNormalizeCountry(t *testing.T) {
+	for in, want := range map[string]string{"us": "US", " de ": "DE", "": UnknownCountry, "USA": UnknownCountry, "1A": UnknownCountry} {
+		if got := NormalizeCountry(in); got != want {
+			t.Errorf("NormalizeCountry(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReportableCountries(t *testing.T) {
+	countries := ReportableCountries(map[string]int{
+		"US":           40,
+		"DE":           12,
+		"FR":           12,
+		"IS":           2,
+		"NZ":           1,
+		UnknownCountry: 9,
+	})
+
+	want := []CountryHits{
+		{Country: "US", Hits: 40},
+		{Country: "DE", Hits: 12},
+		{Country: "FR", Hits: 12},
+		{Country: OtherCountries, Hits: 12},
+	}
+	if !reflect.DeepEqual(countries, want) {
+		t.Errorf("ReportableCountries = %v, want %v", countries, want)
+	}
+	if got := ReportableCountries(map[string]int{}); len(got) != 0 {
+		t.Errorf("expected no countries, got %v", got)
+	}
+}
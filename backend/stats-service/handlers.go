@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/cache"
+	"nuclear-ao3/shared/statsstream"
+)
+
+// =============================================================================
+// WORK STATS
+// Public totals for every work, and owner-only breakdowns by chapter, by
+// where readers came from and by milestone
+// =============================================================================
+
+// GetWorkStats serves a work's stats. Without a granularity it returns the
+// public totals, cached briefly; the breakdowns are owner-only, so they
+// never go through the shared cache.
+// GET /api/v1/works/:work_id/stats
+func (ss *StatsService) GetWorkStats(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+
+	switch c.Query("granularity") {
+	case "chapter":
+		ss.GetChapterStats(c, workID)
+		return
+	case "source":
+		ss.GetHitSourceStats(c, workID)
+		return
+	case "milestones":
+		ss.GetMilestoneStats(c, workID)
+		return
+	}
+
+	ctx := c.Request.Context()
+	cacheKey := fmt.Sprintf("work_stats:%s", workID.String())
+	var stats map[string]interface{}
+	err = ss.cache.GetOrSet(ctx, cacheKey, &stats, cache.ShortTTL, func() (interface{}, error) {
+		return ss.fetchWorkStatsFromDB(ctx, workID)
+	}, workCacheTag(workID))
+	if cache.IsNotFound(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve work stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// workCacheTag is the tag work-service invalidates when a work changes,
+// so its stats are refreshed along with the rest of its cached keys
+func workCacheTag(workID uuid.UUID) string {
+	return fmt.Sprintf("work:%s", workID.String())
+}
+
+func (ss *StatsService) fetchWorkStatsFromDB(ctx context.Context, workID uuid.UUID) (interface{}, error) {
+	var hits, kudos, comments, bookmarks int
+	err := ss.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(hit_count, 0) as hits,
+			COALESCE(kudos_count, 0) as kudos,
+			COALESCE(comment_count, 0) as comments,
+			COALESCE(bookmark_count, 0) as bookmarks
+		FROM works
+		WHERE id = $1
+	`, workID).Scan(&hits, &kudos, &comments, &bookmarks)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"hits":      hits,
+		"kudos":     kudos,
+		"comments":  comments,
+		"bookmarks": bookmarks,
+	}, nil
+}
+
+// isWorkCreator reports whether the user is an approved creator of the work
+func (ss *StatsService) isWorkCreator(ctx context.Context, workID, userID uuid.UUID) (bool, error) {
+	var isCreator bool
+	err := ss.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM creatorships cr
+			JOIN pseuds p ON cr.pseud_id = p.id
+			WHERE cr.creation_id = $1 AND cr.creation_type = 'Work'
+			AND cr.approved = true AND p.user_id = $2
+		) OR EXISTS(SELECT 1 FROM works WHERE id = $1 AND user_id = $2)`, workID, userID).Scan(&isCreator)
+	return isCreator, err
+}
+
+// requireWorkCreator lets the work's creators through and answers everyone
+// else, naming what they asked for in the error
+func (ss *StatsService) requireWorkCreator(c *gin.Context, workID uuid.UUID, what string) bool {
+	userID, ok := requestUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required for " + what})
+		return false
+	}
+
+	isCreator, err := ss.isWorkCreator(c.Request.Context(), workID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check work ownership"})
+		return false
+	}
+	if !isCreator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the work's creators can view " + what})
+		return false
+	}
+	return true
+}
+
+// ChapterStats is one row of the per-chapter breakdown
+type ChapterStats struct {
+	ChapterID     uuid.UUID      `json:"chapter_id"`
+	ChapterNumber int            `json:"chapter_number"`
+	Title         string         `json:"title"`
+	Hits          int            `json:"hits"`
+	Kudos         int            `json:"kudos"`
+	Comments      int            `json:"comments"`
+	Referrers     map[string]int `json:"referrers"`
+}
+
+// GetChapterStats serves GET /works/:work_id/stats?granularity=chapter to
+// the work's creators. An optional days parameter limits the window;
+// without it the breakdown covers all time.
+func (ss *StatsService) GetChapterStats(c *gin.Context, workID uuid.UUID) {
+	if !ss.requireWorkCreator(c, workID, "chapter statistics") {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var since sql.NullTime
+	if days, err := strconv.Atoi(c.Query("days")); err == nil && days > 0 {
+		since = sql.NullTime{Time: time.Now().UTC().AddDate(0, 0, -days), Valid: true}
+	}
+
+	chapters := []*ChapterStats{}
+	byID := make(map[uuid.UUID]*ChapterStats)
+	rows, err := ss.db.QueryContext(ctx, `
+		SELECT id, chapter_number, COALESCE(title, '')
+		FROM chapters WHERE work_id = $1
+		ORDER BY chapter_number`, workID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chapters"})
+		return
+	}
+	for rows.Next() {
+		ch := &ChapterStats{Referrers: statsstream.NewReferrerCounts()}
+		if err := rows.Scan(&ch.ChapterID, &ch.ChapterNumber, &ch.Title); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read chapters"})
+			return
+		}
+		chapters = append(chapters, ch)
+		byID[ch.ChapterID] = ch
+	}
+	rows.Close()
+
+	// The landing page collects work-page hits and kudos or comments not
+	// tied to a chapter
+	workPage := &ChapterStats{Title: "Work page", Referrers: statsstream.NewReferrerCounts()}
+	byID[uuid.Nil] = workPage
+	referrerTotals := statsstream.NewReferrerCounts()
+
+	rows, err = ss.db.QueryContext(ctx, `
+		SELECT chapter_id, referrer_class, SUM(hits)
+		FROM work_chapter_daily_hits
+		WHERE work_id = $1 AND ($2::timestamptz IS NULL OR hit_date >= $2::date)
+		GROUP BY chapter_id, referrer_class`, workID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chapter hits"})
+		return
+	}
+	for rows.Next() {
+		var chapterID uuid.UUID
+		var referrer string
+		var hits int
+		if err := rows.Scan(&chapterID, &referrer, &hits); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read chapter hits"})
+			return
+		}
+		referrerTotals[referrer] += hits
+		if ch, ok := byID[chapterID]; ok {
+			ch.Hits += hits
+			ch.Referrers[referrer] += hits
+		}
+	}
+	rows.Close()
+
+	counts := []struct {
+		query string
+		add   func(*ChapterStats, int)
+	}{
+		{`
+			SELECT COALESCE(chapter_id, '00000000-0000-0000-0000-000000000000'::uuid), COUNT(*)
+			FROM kudos
+			WHERE work_id = $1 AND ($2::timestamptz IS NULL OR created_at >= $2)
+			GROUP BY 1`, func(ch *ChapterStats, n int) { ch.Kudos += n }},
+		{`
+			SELECT COALESCE(chapter_id, '00000000-0000-0000-0000-000000000000'::uuid), COUNT(*)
+			FROM comments
+			WHERE (work_id = $1 OR chapter_id IN (SELECT id FROM chapters WHERE work_id = $1))
+			AND is_deleted = false AND ($2::timestamptz IS NULL OR created_at >= $2)
+			GROUP BY 1`, func(ch *ChapterStats, n int) { ch.Comments += n }},
+	}
+	for _, count := range counts {
+		rows, err := ss.db.QueryContext(ctx, count.query, workID, since)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chapter statistics"})
+			return
+		}
+		for rows.Next() {
+			var chapterID uuid.UUID
+			var n int
+			if err := rows.Scan(&chapterID, &n); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read chapter statistics"})
+				return
+			}
+			if ch, ok := byID[chapterID]; ok {
+				count.add(ch, n)
+			}
+		}
+		rows.Close()
+	}
+
+	response := gin.H{
+		"work_id":     workID,
+		"granularity": "chapter",
+		"chapters":    chapters,
+		"work_page":   workPage,
+		"referrers":   referrerTotals,
+	}
+	if since.Valid {
+		response["since"] = since.Time.Format(statsstream.DateLayout)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetHitSourceStats serves GET /works/:work_id/stats?granularity=source to
+// the work's creators: hits by referrer class and by country. An optional
+// days parameter limits the window; without it the breakdown covers all
+// time.
+func (ss *StatsService) GetHitSourceStats(c *gin.Context, workID uuid.UUID) {
+	if !ss.requireWorkCreator(c, workID, "hit sources") {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var since sql.NullTime
+	if days, err := strconv.Atoi(c.Query("days")); err == nil && days > 0 {
+		since = sql.NullTime{Time: time.Now().UTC().AddDate(0, 0, -days), Valid: true}
+	}
+
+	rows, err := ss.db.QueryContext(ctx, `
+		SELECT referrer_class, country, SUM(hits)
+		FROM work_source_daily_hits
+		WHERE work_id = $1 AND ($2::timestamptz IS NULL OR hit_date >= $2::date)
+		GROUP BY referrer_class, country`, workID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch hit sources"})
+		return
+	}
+	defer rows.Close()
+
+	referrers := statsstream.NewReferrerCounts()
+	countries := map[string]int{}
+	total := 0
+	for rows.Next() {
+		var referrer, country string
+		var hits int
+		if err := rows.Scan(&referrer, &country, &hits); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read hit sources"})
+			return
+		}
+		referrers[referrer] += hits
+		countries[country] += hits
+		total += hits
+	}
+
+	response := gin.H{
+		"work_id":     workID,
+		"granularity": "source",
+		"hits":        total,
+		"referrers":   referrers,
+		"countries":   statsstream.ReportableCountries(countries),
+	}
+	if since.Valid {
+		response["since"] = since.Time.Format(statsstream.DateLayout)
+	}
+	c.JSON(http.StatusOK, response)
+}
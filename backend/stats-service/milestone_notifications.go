@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+	"nuclear-ao3/shared/notificationstream"
+)
+
+// =============================================================================
+// MILESTONE NOTIFICATIONS
+// After each flush, creators are told when it carried one of their works
+// past a hit or kudos total they chose (the statMilestones thresholds unless
+// they've set their own). Only a threshold the flush crossed counts, so
+// lowering a threshold doesn't announce totals passed long ago, and when a
+// flush crosses several only the highest is announced. A creator gets at
+// most one notification per work per flush, delivered at the frequency set
+// for milestone notifications: a daily digest unless they change it.
+// =============================================================================
+
+// maxMilestoneThresholds bounds the thresholds a user can set per metric
+const maxMilestoneThresholds = 20
+
+// MilestoneSettings are the totals a user wants to hear about
+type MilestoneSettings struct {
+	Enabled         bool  `json:"enabled"`
+	HitThresholds   []int `json:"hit_thresholds"`
+	KudosThresholds []int `json:"kudos_thresholds"`
+}
+
+// defaultMilestoneSettings notifies every statMilestones threshold
+func defaultMilestoneSettings() MilestoneSettings {
+	settings := MilestoneSettings{Enabled: true, HitThresholds: []int{}, KudosThresholds: []int{}}
+	for _, m := range statMilestones {
+		if m.Metric == "hits" {
+			settings.HitThresholds = append(settings.HitThresholds, m.Threshold)
+		} else {
+			settings.KudosThresholds = append(settings.KudosThresholds, m.Threshold)
+		}
+	}
+	return settings
+}
+
+func (s MilestoneSettings) thresholds(metric string) []int {
+	if metric == "hits" {
+		return s.HitThresholds
+	}
+	return s.KudosThresholds
+}
+
+// normalizeThresholds sorts thresholds and drops duplicates, rejecting
+// totals below one and lists too long to be useful
+func normalizeThresholds(thresholds []int) ([]int, error) {
+	if len(thresholds) > maxMilestoneThresholds {
+		return nil, fmt.Errorf("at most %d thresholds per metric", maxMilestoneThresholds)
+	}
+	sorted := append([]int(nil), thresholds...)
+	sort.Ints(sorted)
+	normalized := []int{}
+	for i, t := range sorted {
+		if t < 1 {
+			return nil, fmt.Errorf("thresholds must be at least 1")
+		}
+		if i == 0 || t != sorted[i-1] {
+			normalized = append(normalized, t)
+		}
+	}
+	return normalized, nil
+}
+
+// crossedMilestone returns the highest threshold a total passed on its way
+// from before to after, or 0 if it passed none
+func crossedMilestone(before, after int, thresholds []int) int {
+	crossed := 0
+	for _, t := range thresholds {
+		if before < t && t <= after && t > crossed {
+			crossed = t
+		}
+	}
+	return crossed
+}
+
+// batchTotals sums each work's counts across the days in a batch
+func batchTotals(batch []hitCount) map[uuid.UUID]int {
+	totals := map[uuid.UUID]int{}
+	for _, h := range batch {
+		totals[h.WorkID] += h.Hits
+	}
+	return totals
+}
+
+// milestoneCrossing is a milestone one creator is to be told about
+type milestoneCrossing struct {
+	UserID    uuid.UUID
+	WorkID    uuid.UUID
+	Metric    string
+	Threshold int
+}
+
+// notifyMilestones queues milestone notifications for the thresholds the
+// flushed hits and kudos carried works past, and reports how many
+// creators' works it notified about
+func (ss *StatsService) notifyMilestones(ctx context.Context, hits, kudos []hitCount) (int, error) {
+	workIDs := touchedWorks(hits, kudos)
+	if len(workIDs) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, len(workIDs))
+	for i, id := range workIDs {
+		ids[i] = id.String()
+	}
+	deltas := map[string]map[uuid.UUID]int{"hits": batchTotals(hits), "kudos": batchTotals(kudos)}
+
+	titles := map[uuid.UUID]string{}
+	totals := map[string]map[uuid.UUID]int{"hits": {}, "kudos": {}}
+	rows, err := ss.db.QueryContext(ctx, `
+		SELECT id, title, COALESCE(hit_count, 0), COALESCE(kudos_count, 0)
+		FROM works WHERE id = ANY($1::uuid[])`, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		var title string
+		var hitTotal, kudosTotal int
+		if err := rows.Scan(&id, &title, &hitTotal, &kudosTotal); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		titles[id] = title
+		totals["hits"][id] = hitTotal
+		totals["kudos"][id] = kudosTotal
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	creators, err := ss.workCreators(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	settings, err := ss.milestoneSettingsFor(ctx, creators)
+	if err != nil {
+		return 0, err
+	}
+
+	var crossings []milestoneCrossing
+	for workID, userIDs := range creators {
+		for _, userID := range userIDs {
+			s := settings[userID]
+			if !s.Enabled {
+				continue
+			}
+			for _, metric := range []string{"hits", "kudos"} {
+				delta, ok := deltas[metric][workID]
+				if !ok || delta <= 0 {
+					continue
+				}
+				after := totals[metric][workID]
+				if t := crossedMilestone(after-delta, after, s.thresholds(metric)); t > 0 {
+					crossings = append(crossings, milestoneCrossing{UserID: userID, WorkID: workID, Metric: metric, Threshold: t})
+				}
+			}
+		}
+	}
+	if len(crossings) == 0 {
+		return 0, nil
+	}
+	return ss.queueMilestoneNotifications(ctx, crossings, titles)
+}
+
+// workCreators returns the approved creators of each work
+func (ss *StatsService) workCreators(ctx context.Context, workIDs []string) (map[uuid.UUID][]uuid.UUID, error) {
+	rows, err := ss.db.QueryContext(ctx, `
+		SELECT id, user_id FROM works WHERE id = ANY($1::uuid[]) AND user_id IS NOT NULL
+		UNION
+		SELECT cr.creation_id, p.user_id FROM creatorships cr
+		JOIN pseuds p ON cr.pseud_id = p.id
+		WHERE cr.creation_id = ANY($1::uuid[]) AND cr.creation_type = 'Work' AND cr.approved = true`,
+		pq.Array(workIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	creators := map[uuid.UUID][]uuid.UUID{}
+	for rows.Next() {
+		var workID, userID uuid.UUID
+		if err := rows.Scan(&workID, &userID); err != nil {
+			return nil, err
+		}
+		creators[workID] = append(creators[workID], userID)
+	}
+	return creators, rows.Err()
+}
+
+// milestoneSettingsFor loads the settings of every creator, defaulting
+// those who haven't saved any
+func (ss *StatsService) milestoneSettingsFor(ctx context.Context, creators map[uuid.UUID][]uuid.UUID) (map[uuid.UUID]MilestoneSettings, error) {
+	settings := map[uuid.UUID]MilestoneSettings{}
+	userIDs := []string{}
+	for _, ids := range creators {
+		for _, id := range ids {
+			if _, ok := settings[id]; !ok {
+				settings[id] = defaultMilestoneSettings()
+				userIDs = append(userIDs, id.String())
+			}
+		}
+	}
+
+	rows, err := ss.db.QueryContext(ctx, `
+		SELECT user_id, enabled, hit_thresholds, kudos_thresholds
+		FROM user_milestone_settings WHERE user_id = ANY($1::uuid[])`, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID uuid.UUID
+		s, err := scanMilestoneSettings(rows.Scan, &userID)
+		if err != nil {
+			return nil, err
+		}
+		settings[userID] = s
+	}
+	return settings, rows.Err()
+}
+
+// scanMilestoneSettings reads enabled, hit_thresholds and kudos_thresholds
+// after any leading columns; NULL thresholds are the defaults
+func scanMilestoneSettings(scan func(...interface{}) error, leading ...interface{}) (MilestoneSettings, error) {
+	settings := defaultMilestoneSettings()
+	var hitThresholds, kudosThresholds pq.Int64Array
+	if err := scan(append(leading, &settings.Enabled, &hitThresholds, &kudosThresholds)...); err != nil {
+		return settings, err
+	}
+	if hitThresholds != nil {
+		settings.HitThresholds = intsFrom(hitThresholds)
+	}
+	if kudosThresholds != nil {
+		settings.KudosThresholds = intsFrom(kudosThresholds)
+	}
+	return settings, nil
+}
+
+func intsFrom(values pq.Int64Array) []int {
+	ints := make([]int, len(values))
+	for i, v := range values {
+		ints[i] = int(v)
+	}
+	return ints
+}
+
+// queueMilestoneNotifications records the crossings not announced before and
+// queues one notification per creator and work, in one transaction
+func (ss *StatsService) queueMilestoneNotifications(ctx context.Context, crossings []milestoneCrossing, titles map[uuid.UUID]string) (int, error) {
+	userIDs := make([]string, len(crossings))
+	workIDs := make([]string, len(crossings))
+	metrics := make([]string, len(crossings))
+	thresholds := make([]int64, len(crossings))
+	for i, c := range crossings {
+		userIDs[i], workIDs[i], metrics[i], thresholds[i] = c.UserID.String(), c.WorkID.String(), c.Metric, int64(c.Threshold)
+	}
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO milestone_notifications (user_id, work_id, metric, threshold)
+		SELECT * FROM unnest($1::uuid[], $2::uuid[], $3::text[], $4::int[])
+		ON CONFLICT (user_id, work_id, metric, threshold) DO NOTHING
+		RETURNING user_id, work_id, metric, threshold`,
+		pq.Array(userIDs), pq.Array(workIDs), pq.Array(metrics), pq.Array(thresholds))
+	if err != nil {
+		return 0, err
+	}
+	type creatorWork struct{ UserID, WorkID uuid.UUID }
+	grouped := map[creatorWork][]milestoneCrossing{}
+	var order []creatorWork
+	for rows.Next() {
+		var c milestoneCrossing
+		if err := rows.Scan(&c.UserID, &c.WorkID, &c.Metric, &c.Threshold); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		key := creatorWork{c.UserID, c.WorkID}
+		if _, ok := grouped[key]; !ok {
+			order = append(order, key)
+		}
+		grouped[key] = append(grouped[key], c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, key := range order {
+		event := milestoneEvent(key.UserID, key.WorkID, titles[key.WorkID], grouped[key])
+		if err := notificationstream.Enqueue(ctx, tx, event); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(order), nil
+}
+
+// milestoneEvent is the notification telling a creator about the
+// milestones one of their works reached, hits before kudos
+func milestoneEvent(userID, workID uuid.UUID, title string, crossings []milestoneCrossing) notifications.EventData {
+	sort.Slice(crossings, func(i, j int) bool { return crossings[i].Metric < crossings[j].Metric })
+	reached := make([]string, len(crossings))
+	milestones := make([]map[string]interface{}, len(crossings))
+	for i, c := range crossings {
+		reached[i] = formatCount(c.Threshold) + " " + c.Metric
+		milestones[i] = map[string]interface{}{"metric": c.Metric, "threshold": c.Threshold}
+	}
+	summary := strings.Join(reached, " and ")
+
+	return notifications.EventData{
+		Type:         models.EventWorkMilestone,
+		SourceID:     workID,
+		SourceType:   "work",
+		Title:        fmt.Sprintf("%s reached %s", title, summary),
+		Description:  fmt.Sprintf("Your work %s has reached %s.", title, summary),
+		ActionURL:    fmt.Sprintf("/works/%s/stats", workID),
+		RecipientIDs: []uuid.UUID{userID},
+		ExtraData: map[string]interface{}{
+			"work_id":    workID,
+			"work_title": title,
+			"milestones": milestones,
+		},
+	}
+}
+
+// formatCount writes a count with thousands separators: 10000 is "10,000"
+func formatCount(n int) string {
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// GetMilestoneSettings returns the signed-in user's milestone settings:
+// GET /api/v1/works/milestone-settings
+func (ss *StatsService) GetMilestoneSettings(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	row := ss.db.QueryRowContext(c.Request.Context(), `
+		SELECT enabled, hit_thresholds, kudos_thresholds
+		FROM user_milestone_settings WHERE user_id = $1`, userID)
+	settings, err := scanMilestoneSettings(row.Scan)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load milestone settings"})
+		return
+	}
+	if err == sql.ErrNoRows {
+		settings = defaultMilestoneSettings()
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateMilestoneSettings saves the signed-in user's milestone settings:
+// PUT /api/v1/works/milestone-settings
+// {"enabled": true, "hit_thresholds": [1000, 5000], "kudos_thresholds": [50]}
+// A threshold list left out keeps the defaults.
+func (ss *StatsService) UpdateMilestoneSettings(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req struct {
+		Enabled         *bool `json:"enabled"`
+		HitThresholds   []int `json:"hit_thresholds"`
+		KudosThresholds []int `json:"kudos_thresholds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	enabled := req.Enabled == nil || *req.Enabled
+
+	var hitThresholds, kudosThresholds interface{}
+	for _, list := range []struct {
+		values []int
+		target *interface{}
+	}{{req.HitThresholds, &hitThresholds}, {req.KudosThresholds, &kudosThresholds}} {
+		if list.values == nil {
+			continue
+		}
+		normalized, err := normalizeThresholds(list.values)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		*list.target = pq.Array(normalized)
+	}
+
+	row := ss.db.QueryRowContext(c.Request.Context(), `
+		INSERT INTO user_milestone_settings (user_id, enabled, hit_thresholds, kudos_thresholds, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			hit_thresholds = EXCLUDED.hit_thresholds,
+			kudos_thresholds = EXCLUDED.kudos_thresholds,
+			updated_at = NOW()
+		RETURNING enabled, hit_thresholds, kudos_thresholds`,
+		userID, enabled, hitThresholds, kudosThresholds)
+	settings, err := scanMilestoneSettings(row.Scan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save milestone settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
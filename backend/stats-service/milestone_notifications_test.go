@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestCrossedMilestone(t *testing.T) {
+	thresholds := []int{100, 1000, 10000}
+
+	assert.Equal(t, 100, crossedMilestone(99, 100, thresholds))
+	assert.Equal(t, 0, crossedMilestone(100, 150, thresholds), "a threshold already reached isn't crossed again")
+	assert.Equal(t, 1000, crossedMilestone(50, 2000, thresholds), "only the highest crossed threshold is announced")
+	assert.Equal(t, 0, crossedMilestone(20000, 20100, thresholds))
+	assert.Equal(t, 0, crossedMilestone(99, 100, nil))
+}
+
+func TestNormalizeThresholds(t *testing.T) {
+	normalized, err := normalizeThresholds([]int{500, 50, 500, 5000})
+	require.NoError(t, err)
+	assert.Equal(t, []int{50, 500, 5000}, normalized)
+
+	normalized, err = normalizeThresholds([]int{})
+	require.NoError(t, err)
+	assert.Empty(t, normalized, "an empty list turns a metric off")
+
+	_, err = normalizeThresholds([]int{0, 10})
+	assert.Error(t, err)
+	_, err = normalizeThresholds(make([]int, maxMilestoneThresholds+1))
+	assert.Error(t, err)
+}
+
+func TestDefaultMilestoneSettings(t *testing.T) {
+	settings := defaultMilestoneSettings()
+	assert.True(t, settings.Enabled)
+	assert.Equal(t, []int{100, 1000, 10000, 100000, 1000000}, settings.thresholds("hits"))
+	assert.Equal(t, []int{10, 100, 1000, 10000}, settings.thresholds("kudos"))
+}
+
+func TestMilestoneEvent(t *testing.T) {
+	userID, workID := uuid.New(), uuid.New()
+	event := milestoneEvent(userID, workID, "Sherlock's Sock Index", []milestoneCrossing{
+		{UserID: userID, WorkID: workID, Metric: "kudos", Threshold: 100},
+		{UserID: userID, WorkID: workID, Metric: "hits", Threshold: 10000},
+	})
+
+	assert.Equal(t, models.EventWorkMilestone, event.Type)
+	assert.Equal(t, workID, event.SourceID)
+	assert.Equal(t, []uuid.UUID{userID}, event.RecipientIDs)
+	assert.Equal(t, "Sherlock's Sock Index reached 10,000 hits and 100 kudos", event.Title)
+}
+
+func TestFormatCount(t *testing.T) {
+	assert.Equal(t, "7", formatCount(7))
+	assert.Equal(t, "100", formatCount(100))
+	assert.Equal(t, "1,000", formatCount(1000))
+	assert.Equal(t, "1,000,000", formatCount(1000000))
+}
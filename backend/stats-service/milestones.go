@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// =============================================================================
+// MILESTONES
+// After each flush, the works whose counts changed are checked against fixed
+// hit and kudos milestones. The first time a total reaches one it is
+// recorded with the time it was noticed; later flushes never record it again.
+// =============================================================================
+
+// milestone is one threshold of one metric
+type milestone struct {
+	Metric    string
+	Threshold int
+}
+
+// statMilestones are the thresholds recorded for every work
+var statMilestones = []milestone{
+	{"hits", 100}, {"hits", 1000}, {"hits", 10000}, {"hits", 100000}, {"hits", 1000000},
+	{"kudos", 10}, {"kudos", 100}, {"kudos", 1000}, {"kudos", 10000},
+}
+
+// WorkMilestone is a milestone a work has reached
+type WorkMilestone struct {
+	Metric    string    `json:"metric"`
+	Threshold int       `json:"threshold"`
+	ReachedAt time.Time `json:"reached_at"`
+}
+
+// recordMilestones records the milestones the works' current totals have
+// reached and that weren't recorded before, and reports how many it added
+func (ss *StatsService) recordMilestones(ctx context.Context, workIDs []uuid.UUID) (int, error) {
+	if len(workIDs) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, len(workIDs))
+	for i, id := range workIDs {
+		ids[i] = id.String()
+	}
+	metrics := make([]string, len(statMilestones))
+	thresholds := make([]int64, len(statMilestones))
+	for i, m := range statMilestones {
+		metrics[i] = m.Metric
+		thresholds[i] = int64(m.Threshold)
+	}
+
+	result, err := ss.db.ExecContext(ctx, `
+		INSERT INTO work_stat_milestones (work_id, metric, threshold, reached_at)
+		SELECT w.id, m.metric, m.threshold, NOW()
+		FROM works w
+		CROSS JOIN unnest($2::text[], $3::int[]) AS m(metric, threshold)
+		WHERE w.id = ANY($1::uuid[])
+		AND CASE m.metric
+			WHEN 'hits' THEN COALESCE(w.hit_count, 0)
+			ELSE COALESCE(w.kudos_count, 0)
+		END >= m.threshold
+		ON CONFLICT (work_id, metric, threshold) DO NOTHING`,
+		pq.Array(ids), pq.Array(metrics), pq.Array(thresholds))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// GetMilestoneStats serves GET /works/:work_id/stats?granularity=milestones
+// to the work's creators: the milestones the work has reached, newest first
+func (ss *StatsService) GetMilestoneStats(c *gin.Context, workID uuid.UUID) {
+	if !ss.requireWorkCreator(c, workID, "milestones") {
+		return
+	}
+
+	rows, err := ss.db.QueryContext(c.Request.Context(), `
+		SELECT metric, threshold, reached_at
+		FROM work_stat_milestones
+		WHERE work_id = $1
+		ORDER BY reached_at DESC, metric, threshold DESC`, workID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch milestones"})
+		return
+	}
+	defer rows.Close()
+
+	milestones := []WorkMilestone{}
+	for rows.Next() {
+		var m WorkMilestone
+		if err := rows.Scan(&m.Metric, &m.Threshold, &m.ReachedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read milestones"})
+			return
+		}
+		milestones = append(milestones, m)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"work_id":     workID,
+		"granularity": "milestones",
+		"milestones":  milestones,
+	})
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/statsstream"
+)
+
+// =============================================================================
+// DAILY ROLLUPS
+// Hits and kudos are counted in Redis as events arrive and flushed to
+// Postgres in batches: one upsert per work and day per flush, never one per
+// view. Key names are the ones work-service used when it counted hits itself.
+// =============================================================================
+
+const (
+	// hitsPendingKey is a hash of "<work id>|<date>" to hits not yet flushed
+	hitsPendingKey = "hits:pending"
+	// hitsFlushingKey holds the batch being written; a batch left here by a
+	// failed flush is retried before new hits are picked up
+	hitsFlushingKey = "hits:flushing"
+	// chapterHitsPendingKey is a hash of "<work>|<chapter>|<referrer>|<date>"
+	// to chapter hits not yet flushed
+	chapterHitsPendingKey  = "hits:chapters:pending"
+	chapterHitsFlushingKey = "hits:chapters:flushing"
+	// sourceHitsPendingKey is a hash of "<work>|<referrer>|<country>|<date>"
+	// to hits not yet flushed
+	sourceHitsPendingKey  = "hits:sources:pending"
+	sourceHitsFlushingKey = "hits:sources:flushing"
+	// kudosPendingKey is a hash of "<work id>|<date>" to net kudos not yet
+	// flushed
+	kudosPendingKey  = "kudos:pending"
+	kudosFlushingKey = "kudos:flushing"
+)
+
+// hitCount is one work's unflushed hits for one day. The kudos rollup uses
+// it too, with Hits holding net kudos.
+type hitCount struct {
+	WorkID uuid.UUID
+	Date   string
+	Hits   int
+}
+
+func hitField(workID uuid.UUID, date string) string {
+	return workID.String() + "|" + date
+}
+
+// chapterHitCount is one chapter's unflushed hits from one referrer class
+// for one day. ChapterID is uuid.Nil for the work's landing page.
+type chapterHitCount struct {
+	WorkID    uuid.UUID
+	ChapterID uuid.UUID
+	Referrer  string
+	Date      string
+	Hits      int
+}
+
+func (h chapterHitCount) field() string {
+	return strings.Join([]string{h.WorkID.String(), h.ChapterID.String(), h.Referrer, h.Date}, "|")
+}
+
+// sourceHitCount is one work's unflushed hits from one referrer class and
+// country for one day
+type sourceHitCount struct {
+	WorkID   uuid.UUID
+	Referrer string
+	Country  string
+	Date     string
+	Hits     int
+}
+
+func (h sourceHitCount) field() string {
+	return strings.Join([]string{h.WorkID.String(), h.Referrer, h.Country, h.Date}, "|")
+}
+
+// parseHitBatch turns a pending hash into counts, dropping malformed fields.
+// Kudos can be taken back, so with allowNegative a count below zero is kept.
+func parseHitBatch(fields map[string]string, allowNegative bool) []hitCount {
+	batch := make([]hitCount, 0, len(fields))
+	for field, value := range fields {
+		idPart, date, ok := strings.Cut(field, "|")
+		if !ok {
+			continue
+		}
+		workID, err := uuid.Parse(idPart)
+		if err != nil {
+			continue
+		}
+		if _, err := time.Parse(statsstream.DateLayout, date); err != nil {
+			continue
+		}
+		hits, err := strconv.Atoi(value)
+		if err != nil || hits == 0 || (hits < 0 && !allowNegative) {
+			continue
+		}
+		batch = append(batch, hitCount{WorkID: workID, Date: date, Hits: hits})
+	}
+	return batch
+}
+
+// parseChapterHitBatch turns a pending hash into counts, dropping malformed
+// fields
+func parseChapterHitBatch(fields map[string]string) []chapterHitCount {
+	batch := make([]chapterHitCount, 0, len(fields))
+	for field, value := range fields {
+		parts := strings.Split(field, "|")
+		if len(parts) != 4 {
+			continue
+		}
+		workID, err := uuid.Parse(parts[0])
+		if err != nil {
+			continue
+		}
+		chapterID, err := uuid.Parse(parts[1])
+		if err != nil {
+			continue
+		}
+		if !statsstream.IsReferrerClass(parts[2]) {
+			continue
+		}
+		if _, err := time.Parse(statsstream.DateLayout, parts[3]); err != nil {
+			continue
+		}
+		hits, err := strconv.Atoi(value)
+		if err != nil || hits <= 0 {
+			continue
+		}
+		batch = append(batch, chapterHitCount{
+			WorkID: workID, ChapterID: chapterID, Referrer: parts[2], Date: parts[3], Hits: hits,
+		})
+	}
+	return batch
+}
+
+// parseSourceHitBatch turns a pending hash into counts, dropping malformed
+// fields
+func parseSourceHitBatch(fields map[string]string) []sourceHitCount {
+	batch := make([]sourceHitCount, 0, len(fields))
+	for field, value := range fields {
+		parts := strings.Split(field, "|")
+		if len(parts) != 4 {
+			continue
+		}
+		workID, err := uuid.Parse(parts[0])
+		if err != nil {
+			continue
+		}
+		if !statsstream.IsReferrerClass(parts[1]) || statsstream.NormalizeCountry(parts[2]) != parts[2] {
+			continue
+		}
+		if _, err := time.Parse(statsstream.DateLayout, parts[3]); err != nil {
+			continue
+		}
+		hits, err := strconv.Atoi(value)
+		if err != nil || hits <= 0 {
+			continue
+		}
+		batch = append(batch, sourceHitCount{
+			WorkID: workID, Referrer: parts[1], Country: parts[2], Date: parts[3], Hits: hits,
+		})
+	}
+	return batch
+}
+
+// startRollupScheduler flushes counters every flushInterval and rescores
+// trending works every trendingInterval until ctx is cancelled
+func (ss *StatsService) startRollupScheduler(ctx context.Context, flushInterval, trendingInterval time.Duration) {
+	flush := time.NewTicker(flushInterval)
+	defer flush.Stop()
+	trending := time.NewTicker(trendingInterval)
+	defer trending.Stop()
+
+	log.Printf("Rollup scheduler started, flushing every %s and scoring trending works every %s", flushInterval, trendingInterval)
+	ss.runTrendingJob(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Rollup scheduler stopped")
+			return
+		case <-flush.C:
+			n, err := ss.flushRollups(ctx)
+			if err != nil {
+				log.Printf("Rollup flush failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Rollup flush wrote %d work-days", n)
+			}
+		case <-trending.C:
+			ss.runTrendingJob(ctx)
+		}
+	}
+}
+
+// flushRollups moves pending counts from Redis into the daily tables and
+// the works' running hit totals, then records any milestones the touched
+// works reached and tells their creators. It reports how many work-days of
+// hits and kudos were written.
+func (ss *StatsService) flushRollups(ctx context.Context) (int, error) {
+	if ss.redis == nil {
+		return 0, nil
+	}
+
+	fields, err := ss.takeBatch(ctx, hitsPendingKey, hitsFlushingKey)
+	if err != nil {
+		return 0, err
+	}
+	hits := parseHitBatch(fields, false)
+	if err := ss.writeHitBatch(ctx, hits); err != nil {
+		return 0, err
+	}
+	if err := ss.redis.Del(ctx, hitsFlushingKey).Err(); err != nil {
+		return 0, err
+	}
+
+	fields, err = ss.takeBatch(ctx, chapterHitsPendingKey, chapterHitsFlushingKey)
+	if err != nil {
+		return len(hits), err
+	}
+	if err := ss.writeChapterHitBatch(ctx, parseChapterHitBatch(fields)); err != nil {
+		return len(hits), err
+	}
+	if err := ss.redis.Del(ctx, chapterHitsFlushingKey).Err(); err != nil {
+		return len(hits), err
+	}
+
+	fields, err = ss.takeBatch(ctx, sourceHitsPendingKey, sourceHitsFlushingKey)
+	if err != nil {
+		return len(hits), err
+	}
+	if err := ss.writeSourceHitBatch(ctx, parseSourceHitBatch(fields)); err != nil {
+		return len(hits), err
+	}
+	if err := ss.redis.Del(ctx, sourceHitsFlushingKey).Err(); err != nil {
+		return len(hits), err
+	}
+
+	fields, err = ss.takeBatch(ctx, kudosPendingKey, kudosFlushingKey)
+	if err != nil {
+		return len(hits), err
+	}
+	kudos := parseHitBatch(fields, true)
+	if err := ss.writeKudosBatch(ctx, kudos); err != nil {
+		return len(hits), err
+	}
+	if err := ss.redis.Del(ctx, kudosFlushingKey).Err(); err != nil {
+		return len(hits), err
+	}
+
+	if _, err := ss.recordMilestones(ctx, touchedWorks(hits, kudos)); err != nil {
+		log.Printf("Failed to record milestones: %v", err)
+	}
+	if _, err := ss.notifyMilestones(ctx, hits, kudos); err != nil {
+		log.Printf("Failed to notify milestones: %v", err)
+	}
+	return len(hits) + len(kudos), nil
+}
+
+// touchedWorks lists each work in the batches once
+func touchedWorks(batches ...[]hitCount) []uuid.UUID {
+	seen := map[uuid.UUID]bool{}
+	var works []uuid.UUID
+	for _, batch := range batches {
+		for _, h := range batch {
+			if !seen[h.WorkID] {
+				seen[h.WorkID] = true
+				works = append(works, h.WorkID)
+			}
+		}
+	}
+	return works
+}
+
+// takeBatch moves a pending hash aside for flushing and returns its
+// contents. A batch left behind by a failed flush is returned first.
+func (ss *StatsService) takeBatch(ctx context.Context, pendingKey, flushingKey string) (map[string]string, error) {
+	leftover, err := ss.redis.Exists(ctx, flushingKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if leftover == 0 {
+		// Renaming is atomic, so counts recorded from here on land in a
+		// fresh pending hash and aren't lost or counted twice
+		if err := ss.redis.Rename(ctx, pendingKey, flushingKey).Err(); err != nil {
+			if strings.Contains(err.Error(), "no such key") {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+	return ss.redis.HGetAll(ctx, flushingKey).Result()
+}
+
+// countArrays splits a batch into the arrays the upserts unnest
+func countArrays(batch []hitCount) (workIDs, dates []string, counts []int64) {
+	workIDs = make([]string, len(batch))
+	dates = make([]string, len(batch))
+	counts = make([]int64, len(batch))
+	for i, h := range batch {
+		workIDs[i] = h.WorkID.String()
+		dates[i] = h.Date
+		counts[i] = int64(h.Hits)
+	}
+	return workIDs, dates, counts
+}
+
+// writeHitBatch adds a batch of hits to the daily aggregates and to the
+// works' hit counts in one transaction. Hits for deleted works are dropped.
+func (ss *StatsService) writeHitBatch(ctx context.Context, batch []hitCount) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	workIDs, dates, hits := countArrays(batch)
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO work_daily_hits (work_id, hit_date, hits, updated_at)
+		SELECT h.work_id, h.hit_date, SUM(h.hits), NOW()
+		FROM unnest($1::uuid[], $2::date[], $3::int[]) AS h(work_id, hit_date, hits)
+		WHERE EXISTS (SELECT 1 FROM works WHERE id = h.work_id)
+		GROUP BY h.work_id, h.hit_date
+		ON CONFLICT (work_id, hit_date)
+		DO UPDATE SET hits = work_daily_hits.hits + EXCLUDED.hits, updated_at = NOW()`,
+		pq.Array(workIDs), pq.Array(dates), pq.Array(hits))
+	if err != nil {
+		return fmt.Errorf("write daily hits: %w", err)
+	}
+
+	totals := `
+		SELECT h.work_id, SUM(h.hits) AS hits
+		FROM unnest($1::uuid[], $2::int[]) AS h(work_id, hits)
+		GROUP BY h.work_id`
+	_, err = tx.ExecContext(ctx, `
+		UPDATE works w SET hit_count = COALESCE(w.hit_count, 0) + t.hits
+		FROM (`+totals+`) t
+		WHERE w.id = t.work_id`, pq.Array(workIDs), pq.Array(hits))
+	if err != nil {
+		return fmt.Errorf("update work hit counts: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE work_statistics s SET hits = COALESCE(s.hits, 0) + t.hits, updated_at = NOW()
+		FROM (`+totals+`) t
+		WHERE s.work_id = t.work_id`, pq.Array(workIDs), pq.Array(hits))
+	if err != nil {
+		return fmt.Errorf("update work statistics: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// writeKudosBatch adds a batch of net kudos to the daily kudos rollup. The
+// works' kudos counts are kept by work-service, which owns kudos.
+func (ss *StatsService) writeKudosBatch(ctx context.Context, batch []hitCount) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	workIDs, dates, kudos := countArrays(batch)
+
+	_, err := ss.db.ExecContext(ctx, `
+		INSERT INTO work_daily_kudos (work_id, kudos_date, kudos, updated_at)
+		SELECT k.work_id, k.kudos_date, GREATEST(SUM(k.kudos), 0), NOW()
+		FROM unnest($1::uuid[], $2::date[], $3::int[]) AS k(work_id, kudos_date, kudos)
+		WHERE EXISTS (SELECT 1 FROM works WHERE id = k.work_id)
+		GROUP BY k.work_id, k.kudos_date
+		ON CONFLICT (work_id, kudos_date)
+		DO UPDATE SET kudos = GREATEST(work_daily_kudos.kudos + EXCLUDED.kudos, 0), updated_at = NOW()`,
+		pq.Array(workIDs), pq.Array(dates), pq.Array(kudos))
+	if err != nil {
+		return fmt.Errorf("write daily kudos: %w", err)
+	}
+	return nil
+}
+
+// writeChapterHitBatch adds a batch of chapter hits to the breakdown in one
+// statement. Hits for deleted works are dropped.
+func (ss *StatsService) writeChapterHitBatch(ctx context.Context, batch []chapterHitCount) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	workIDs := make([]string, len(batch))
+	chapterIDs := make([]string, len(batch))
+	referrers := make([]string, len(batch))
+	dates := make([]string, len(batch))
+	hits := make([]int64, len(batch))
+	for i, h := range batch {
+		workIDs[i] = h.WorkID.String()
+		chapterIDs[i] = h.ChapterID.String()
+		referrers[i] = h.Referrer
+		dates[i] = h.Date
+		hits[i] = int64(h.Hits)
+	}
+
+	_, err := ss.db.ExecContext(ctx, `
+		INSERT INTO work_chapter_daily_hits (work_id, chapter_id, referrer_class, hit_date, hits, updated_at)
+		SELECT h.work_id, h.chapter_id, h.referrer_class, h.hit_date, SUM(h.hits), NOW()
+		FROM unnest($1::uuid[], $2::uuid[], $3::text[], $4::date[], $5::int[])
+			AS h(work_id, chapter_id, referrer_class, hit_date, hits)
+		WHERE EXISTS (SELECT 1 FROM works WHERE id = h.work_id)
+		GROUP BY h.work_id, h.chapter_id, h.referrer_class, h.hit_date
+		ON CONFLICT (work_id, chapter_id, referrer_class, hit_date)
+		DO UPDATE SET hits = work_chapter_daily_hits.hits + EXCLUDED.hits, updated_at = NOW()`,
+		pq.Array(workIDs), pq.Array(chapterIDs), pq.Array(referrers), pq.Array(dates), pq.Array(hits))
+	if err != nil {
+		return fmt.Errorf("write chapter hits: %w", err)
+	}
+	return nil
+}
+
+// writeSourceHitBatch adds a batch of hits to the referrer and country
+// breakdown in one statement. Hits for deleted works are dropped.
+func (ss *StatsService) writeSourceHitBatch(ctx context.Context, batch []sourceHitCount) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	workIDs := make([]string, len(batch))
+	referrers := make([]string, len(batch))
+	countries := make([]string, len(batch))
+	dates := make([]string, len(batch))
+	hits := make([]int64, len(batch))
+	for i, h := range batch {
+		workIDs[i] = h.WorkID.String()
+		referrers[i] = h.Referrer
+		countries[i] = h.Country
+		dates[i] = h.Date
+		hits[i] = int64(h.Hits)
+	}
+
+	_, err := ss.db.ExecContext(ctx, `
+		INSERT INTO work_source_daily_hits (work_id, referrer_class, country, hit_date, hits, updated_at)
+		SELECT h.work_id, h.referrer_class, h.country, h.hit_date, SUM(h.hits), NOW()
+		FROM unnest($1::uuid[], $2::text[], $3::text[], $4::date[], $5::int[])
+			AS h(work_id, referrer_class, country, hit_date, hits)
+		WHERE EXISTS (SELECT 1 FROM works WHERE id = h.work_id)
+		GROUP BY h.work_id, h.referrer_class, h.country, h.hit_date
+		ON CONFLICT (work_id, referrer_class, country, hit_date)
+		DO UPDATE SET hits = work_source_daily_hits.hits + EXCLUDED.hits, updated_at = NOW()`,
+		pq.Array(workIDs), pq.Array(referrers), pq.Array(countries), pq.Array(dates), pq.Array(hits))
+	if err != nil {
+		return fmt.Errorf("write source hits: %w", err)
+	}
+	return nil
+}
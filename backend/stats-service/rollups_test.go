@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"nuclear-ao3/shared/statsstream"
+)
+
+func TestParseHitBatch(t *testing.T) {
+	workID := uuid.New()
+	fields := map[string]string{
+		hitField(workID, "2026-10-15"):  "3",
+		workID.String() + "|not-a-date": "1",
+		"not-a-uuid|2026-10-15":         "1",
+		"missing-separator":             "1",
+		hitField(workID, "2026-10-16"):  "0",
+		hitField(workID, "2026-10-14"):  "-2",
+	}
+
+	batch := parseHitBatch(fields, false)
+	require.Len(t, batch, 1)
+	assert.Equal(t, hitCount{WorkID: workID, Date: "2026-10-15", Hits: 3}, batch[0])
+
+	// Kudos taken back on a day can outnumber those given
+	batch = parseHitBatch(fields, true)
+	require.Len(t, batch, 2)
+	got := map[string]int{}
+	for _, h := range batch {
+		got[h.Date] = h.Hits
+	}
+	assert.Equal(t, map[string]int{"2026-10-15": 3, "2026-10-14": -2}, got)
+}
+
+func TestParseChapterHitBatch(t *testing.T) {
+	workID, chapterID := uuid.New(), uuid.New()
+	valid := chapterHitCount{WorkID: workID, ChapterID: chapterID, Referrer: statsstream.ReferrerSearch, Date: "2026-10-15"}
+	landing := chapterHitCount{WorkID: workID, ChapterID: uuid.Nil, Referrer: statsstream.ReferrerDirect, Date: "2026-10-15"}
+	badClass := chapterHitCount{WorkID: workID, ChapterID: chapterID, Referrer: "carrier-pigeon", Date: "2026-10-15"}
+
+	batch := parseChapterHitBatch(map[string]string{
+		valid.field():    "4",
+		landing.field():  "2",
+		badClass.field(): "1",
+		workID.String() + "|" + chapterID.String() + "|tag":           "1",
+		"not-a-uuid|" + chapterID.String() + "|tag|2026-10-15":        "1",
+		workID.String() + "|" + chapterID.String() + "|tag|yesterday": "1",
+	})
+
+	require.Len(t, batch, 2)
+	got := map[uuid.UUID]chapterHitCount{}
+	for _, h := range batch {
+		got[h.ChapterID] = h
+	}
+	valid.Hits, landing.Hits = 4, 2
+	assert.Equal(t, valid, got[chapterID])
+	assert.Equal(t, landing, got[uuid.Nil])
+}
+
+func TestParseSourceHitBatch(t *testing.T) {
+	workID := uuid.New()
+	valid := sourceHitCount{WorkID: workID, Referrer: statsstream.ReferrerSearch, Country: "BR", Date: "2026-10-15"}
+	unknown := sourceHitCount{WorkID: workID, Referrer: statsstream.ReferrerDirect, Country: statsstream.UnknownCountry, Date: "2026-10-15"}
+	badCountry := sourceHitCount{WorkID: workID, Referrer: statsstream.ReferrerTag, Country: "br", Date: "2026-10-15"}
+	badClass := sourceHitCount{WorkID: workID, Referrer: "carrier-pigeon", Country: "BR", Date: "2026-10-15"}
+
+	batch := parseSourceHitBatch(map[string]string{
+		valid.field():                             "3",
+		unknown.field():                           "1",
+		badCountry.field():                        "1",
+		badClass.field():                          "1",
+		workID.String() + "|tag|BR":               "1",
+		workID.String() + "|tag|BR|yesterday":     "1",
+		"not-a-uuid|tag|BR|2026-10-15":            "1",
+		workID.String() + "|search|FR|2026-10-15": "zero",
+	})
+
+	require.Len(t, batch, 2)
+	got := map[string]sourceHitCount{}
+	for _, h := range batch {
+		got[h.Country] = h
+	}
+	valid.Hits, unknown.Hits = 3, 1
+	assert.Equal(t, valid, got["BR"])
+	assert.Equal(t, unknown, got[statsstream.UnknownCountry])
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/statsstream"
+)
+
+// Stats event consumer: work-service publishes every hit and kudos to a
+// Redis stream and this consumer group counts them. Each event is applied
+// by a script that marks it applied in the same step, so an event delivered
+// again after a crash is never counted twice. Events that fail stay pending
+// and are retried once idle; events that can't be decoded are dead-lettered.
+
+const (
+	statsEventBatchSize       = 200
+	statsEventBlock           = 5 * time.Second
+	statsEventRetryIdle       = time.Minute
+	statsEventReclaimInterval = 30 * time.Second
+	statsDeadLetterMaxLen     = 10000
+
+	// hitSeenTTL keeps a day's viewer sets past midnight in every timezone
+	hitSeenTTL = 48 * time.Hour
+	// statsEventAppliedTTL is how long an applied event is remembered; far
+	// longer than an event stays pending
+	statsEventAppliedTTL = 24 * time.Hour
+)
+
+// applyHitScript counts a hit once per viewer per day for the work, and
+// once per viewer per day for the chapter. Sources are counted with the
+// work hit, by where the viewer first came from that day.
+//
+// KEYS: applied marker, work seen set, chapter seen set, hits pending,
+// source hits pending, chapter hits pending
+// ARGV: viewer, seen TTL, hit field, source field, chapter field, applied TTL
+var applyHitScript = redis.NewScript(`
+if not redis.call('SET', KEYS[1], 1, 'NX', 'EX', ARGV[6]) then
+	return 0
+end
+if redis.call('SADD', KEYS[2], ARGV[1]) == 1 then
+	redis.call('HINCRBY', KEYS[4], ARGV[3], 1)
+	redis.call('HINCRBY', KEYS[5], ARGV[4], 1)
+end
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+if redis.call('SADD', KEYS[3], ARGV[1]) == 1 then
+	redis.call('HINCRBY', KEYS[6], ARGV[5], 1)
+end
+redis.call('EXPIRE', KEYS[3], ARGV[2])
+return 1
+`)
+
+// applyKudosScript adds kudos given or removed to the day's count.
+//
+// KEYS: applied marker, kudos pending
+// ARGV: kudos field, delta, applied TTL
+var applyKudosScript = redis.NewScript(`
+if not redis.call('SET', KEYS[1], 1, 'NX', 'EX', ARGV[3]) then
+	return 0
+end
+redis.call('HINCRBY', KEYS[2], ARGV[1], ARGV[2])
+return 1
+`)
+
+func hitSeenKey(date string, workID uuid.UUID) string {
+	return fmt.Sprintf("hits:seen:%s:%s", date, workID)
+}
+
+func chapterHitSeenKey(date string, workID, chapterID uuid.UUID) string {
+	return fmt.Sprintf("hits:seen:%s:%s:%s", date, workID, chapterID)
+}
+
+func statsEventAppliedKey(messageID string) string {
+	return "stats:applied:" + messageID
+}
+
+// startStatsEventConsumer reads the stats stream until ctx is cancelled
+func (ss *StatsService) startStatsEventConsumer(ctx context.Context) {
+	if ss.redis == nil {
+		log.Println("Stats event consumer disabled: no Redis connection")
+		return
+	}
+	consumer := statsEventConsumerName()
+
+	for ss.ensureStatsEventGroup(ctx) != nil {
+		if !sleepContext(ctx, 5*time.Second) {
+			return
+		}
+	}
+	log.Printf("Stats event consumer %s started", consumer)
+
+	lastReclaim := time.Now()
+	for ctx.Err() == nil {
+		streams, err := ss.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    statsstream.ConsumerGroup,
+			Consumer: consumer,
+			Streams:  []string{statsstream.Stream, ">"},
+			Count:    statsEventBatchSize,
+			Block:    statsEventBlock,
+		}).Result()
+		switch {
+		case err == redis.Nil:
+		case err != nil:
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Reading stats events failed: %v", err)
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				ss.ensureStatsEventGroup(ctx)
+			}
+			sleepContext(ctx, time.Second)
+		default:
+			for _, stream := range streams {
+				for _, msg := range stream.Messages {
+					ss.processStatsEventMessage(ctx, msg)
+				}
+			}
+		}
+
+		if time.Since(lastReclaim) >= statsEventReclaimInterval {
+			ss.reclaimStatsEvents(ctx, consumer)
+			lastReclaim = time.Now()
+		}
+	}
+	log.Println("Stats event consumer stopped")
+}
+
+// ensureStatsEventGroup creates the consumer group, reading the stream from
+// the start so hits published before the first deploy aren't skipped
+func (ss *StatsService) ensureStatsEventGroup(ctx context.Context) error {
+	err := ss.redis.XGroupCreateMkStream(ctx, statsstream.Stream, statsstream.ConsumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		log.Printf("Failed to create stats event consumer group: %v", err)
+		return err
+	}
+	return nil
+}
+
+func statsEventConsumerName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "stats-service"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// sleepContext waits d, returning false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// processStatsEventMessage counts one event and acknowledges it. A failed
+// event stays pending for reclaimStatsEvents; one that can't be decoded is
+// dead-lettered straight away.
+func (ss *StatsService) processStatsEventMessage(ctx context.Context, msg redis.XMessage) {
+	event, err := statsstream.ParseEvent(msg.Values)
+	if err != nil {
+		log.Printf("Dead-lettering malformed stats event %s: %v", msg.ID, err)
+		ss.deadLetterStatsEvent(ctx, msg, err.Error())
+		return
+	}
+
+	if err := ss.applyStatsEvent(ctx, msg.ID, event); err != nil {
+		log.Printf("Stats event %s for work %s failed, will retry: %v", msg.ID, event.WorkID, err)
+		return
+	}
+	if err := ss.redis.XAck(ctx, statsstream.Stream, statsstream.ConsumerGroup, msg.ID).Err(); err != nil {
+		log.Printf("Failed to acknowledge stats event %s: %v", msg.ID, err)
+	}
+}
+
+// applyStatsEvent adds an event to the pending counters
+func (ss *StatsService) applyStatsEvent(ctx context.Context, messageID string, event statsstream.Event) error {
+	applied := statsEventAppliedKey(messageID)
+	appliedTTL := int(statsEventAppliedTTL / time.Second)
+
+	switch event.Type {
+	case statsstream.EventHit:
+		chapterHit := chapterHitCount{WorkID: event.WorkID, ChapterID: event.ChapterID, Referrer: event.Referrer, Date: event.Date}
+		sourceHit := sourceHitCount{WorkID: event.WorkID, Referrer: event.Referrer, Country: event.Country, Date: event.Date}
+		return applyHitScript.Run(ctx, ss.redis,
+			[]string{
+				applied,
+				hitSeenKey(event.Date, event.WorkID),
+				chapterHitSeenKey(event.Date, event.WorkID, event.ChapterID),
+				hitsPendingKey, sourceHitsPendingKey, chapterHitsPendingKey,
+			},
+			event.Viewer, int(hitSeenTTL/time.Second),
+			hitField(event.WorkID, event.Date), sourceHit.field(), chapterHit.field(), appliedTTL,
+		).Err()
+	case statsstream.EventKudos:
+		return applyKudosScript.Run(ctx, ss.redis,
+			[]string{applied, kudosPendingKey},
+			hitField(event.WorkID, event.Date), event.Delta, appliedTTL,
+		).Err()
+	}
+	return fmt.Errorf("unknown event type %q", event.Type)
+}
+
+// reclaimStatsEvents retries events left pending past statsEventRetryIdle,
+// including those of consumers that died
+func (ss *StatsService) reclaimStatsEvents(ctx context.Context, consumer string) {
+	msgs, _, err := ss.redis.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   statsstream.Stream,
+		Group:    statsstream.ConsumerGroup,
+		Consumer: consumer,
+		MinIdle:  statsEventRetryIdle,
+		Start:    "0",
+		Count:    statsEventBatchSize,
+	}).Result()
+	if err != nil {
+		log.Printf("Failed to reclaim pending stats events: %v", err)
+		return
+	}
+	for _, msg := range msgs {
+		ss.processStatsEventMessage(ctx, msg)
+	}
+}
+
+// deadLetterStatsEvent moves an event to the dead-letter stream
+func (ss *StatsService) deadLetterStatsEvent(ctx context.Context, msg redis.XMessage, reason string) {
+	values := map[string]interface{}{}
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["original_id"] = msg.ID
+	values["error"] = reason
+	values["dead_lettered_at"] = time.Now().UTC().Format(time.RFC3339)
+
+	err := ss.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: statsstream.DeadLetterStream,
+		MaxLen: statsDeadLetterMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		log.Printf("Failed to dead-letter stats event %s: %v", msg.ID, err)
+		return
+	}
+	ss.redis.XAck(ctx, statsstream.Stream, statsstream.ConsumerGroup, msg.ID)
+}
+
+// GetStatsEventDeadLetters lists dead-lettered stats events, newest first,
+// with the consumer's backlog:
+// GET /api/v1/internal/stats/dead-letters
+func (ss *StatsService) GetStatsEventDeadLetters(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	msgs, err := ss.redis.XRevRangeN(ctx, statsstream.DeadLetterStream, "+", "-", 100).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dead letters"})
+		return
+	}
+	deadLetters := make([]gin.H, 0, len(msgs))
+	for _, msg := range msgs {
+		entry := gin.H{"id": msg.ID}
+		for _, field := range []string{"work_id", "type", "original_id", "error", "dead_lettered_at"} {
+			entry[field] = msg.Values[field]
+		}
+		deadLetters = append(deadLetters, entry)
+	}
+
+	response := gin.H{"dead_letters": deadLetters}
+	if total, err := ss.redis.XLen(ctx, statsstream.DeadLetterStream).Result(); err == nil {
+		response["total"] = total
+	}
+	if pending, err := ss.redis.XPending(ctx, statsstream.Stream, statsstream.ConsumerGroup).Result(); err == nil {
+		response["pending"] = pending.Count
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/statsstream"
+)
+
+// =============================================================================
+// TRENDING WORKS
+// Works are scored from the daily rollups: each day's hits plus weighted
+// kudos over the last week, halving in weight every two days so works
+// picking up readers now outrank works that were busy last week. Scores are
+// recomputed on a schedule into a sorted set the endpoint reads from.
+// =============================================================================
+
+const (
+	trendingKey         = "stats:trending"
+	trendingScoringKey  = "stats:trending:scoring"
+	trendingScoredAtKey = "stats:trending:scored_at"
+
+	trendingWindowDays   = 7
+	trendingHalfLifeDays = 2.0
+	// trendingKudosWeight counts kudos as this many hits; leaving kudos
+	// says more than opening a work
+	trendingKudosWeight = 5
+	// maxTrendingWorks is how many works are kept scored, enough to fill
+	// the largest page after logged-out filtering
+	maxTrendingWorks = 200
+
+	defaultTrendingLimit = 20
+	maxTrendingLimit     = 100
+)
+
+// TrendingWork is one work in the trending list. Creators aren't listed, so
+// works posted anonymously stay anonymous.
+type TrendingWork struct {
+	WorkID    uuid.UUID `json:"work_id"`
+	Title     string    `json:"title"`
+	Rating    string    `json:"rating"`
+	Fandoms   []string  `json:"fandoms"`
+	WordCount int       `json:"word_count"`
+	Hits      int       `json:"hits"`
+	Kudos     int       `json:"kudos"`
+	Score     float64   `json:"score"`
+}
+
+// runTrendingJob rescores trending works, logging failures
+func (ss *StatsService) runTrendingJob(ctx context.Context) {
+	n, err := ss.scoreTrendingWorks(ctx)
+	if err != nil {
+		log.Printf("Trending scoring failed: %v", err)
+		return
+	}
+	log.Printf("Scored %d trending works", n)
+}
+
+// scoreTrendingWorks scores public works from the last week's rollups and
+// swaps the result in whole, so readers never see a half-built list
+func (ss *StatsService) scoreTrendingWorks(ctx context.Context) (int, error) {
+	today := time.Now().UTC().Format(statsstream.DateLayout)
+	rows, err := ss.db.QueryContext(ctx, `
+		SELECT d.work_id, SUM(d.activity * power(0.5, ($1::date - d.day) / $2::float)) AS score
+		FROM (
+			SELECT work_id, hit_date AS day, hits AS activity
+			FROM work_daily_hits WHERE hit_date > $1::date - $3::int
+			UNION ALL
+			SELECT work_id, kudos_date, kudos * $4
+			FROM work_daily_kudos WHERE kudos_date > $1::date - $3::int
+		) d
+		JOIN works w ON w.id = d.work_id
+		WHERE w.status = 'posted' AND w.restricted = false
+		GROUP BY d.work_id
+		ORDER BY score DESC, d.work_id
+		LIMIT $5`,
+		today, trendingHalfLifeDays, trendingWindowDays, trendingKudosWeight, maxTrendingWorks)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	members := []redis.Z{}
+	for rows.Next() {
+		var workID string
+		var score float64
+		if err := rows.Scan(&workID, &score); err != nil {
+			return 0, err
+		}
+		members = append(members, redis.Z{Member: workID, Score: score})
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	pipe := ss.redis.TxPipeline()
+	pipe.Del(ctx, trendingScoringKey)
+	if len(members) > 0 {
+		pipe.ZAdd(ctx, trendingScoringKey, members...)
+		pipe.Rename(ctx, trendingScoringKey, trendingKey)
+	} else {
+		pipe.Del(ctx, trendingKey)
+	}
+	pipe.Set(ctx, trendingScoredAtKey, time.Now().UTC().Format(time.RFC3339), 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return len(members), nil
+}
+
+// trendingLimit reads the limit parameter, defaulting and capping it
+func trendingLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultTrendingLimit
+	}
+	if limit > maxTrendingLimit {
+		return maxTrendingLimit
+	}
+	return limit
+}
+
+// loginGatedRatings lists the rating keys the deployment hides from
+// logged-out readers
+func (ss *StatsService) loginGatedRatings(ctx context.Context) (map[string]bool, error) {
+	rows, err := ss.db.QueryContext(ctx, "SELECT rating FROM content_rating_policy WHERE login_required")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gated := map[string]bool{}
+	for rows.Next() {
+		var rating string
+		if err := rows.Scan(&rating); err != nil {
+			return nil, err
+		}
+		gated[models.RatingKey(rating)] = true
+	}
+	return gated, rows.Err()
+}
+
+// visibleTrendingWorks keeps the works a reader may see, in score order, up
+// to limit
+func visibleTrendingWorks(works []TrendingWork, gated map[string]bool, limit int) []TrendingWork {
+	visible := []TrendingWork{}
+	for _, w := range works {
+		if gated[models.RatingKey(w.Rating)] {
+			continue
+		}
+		visible = append(visible, w)
+		if len(visible) == limit {
+			break
+		}
+	}
+	return visible
+}
+
+// GetTrendingWorks lists the works with the most recent activity. Works
+// whose rating needs a login are left out for logged-out readers.
+// GET /api/v1/works/trending
+func (ss *StatsService) GetTrendingWorks(c *gin.Context) {
+	ctx := c.Request.Context()
+	limit := trendingLimit(c)
+
+	scored, err := ss.redis.ZRevRangeWithScores(ctx, trendingKey, 0, maxTrendingWorks-1).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load trending works"})
+		return
+	}
+	ids := make([]string, len(scored))
+	scores := make(map[uuid.UUID]float64, len(scored))
+	for i, z := range scored {
+		id, _ := z.Member.(string)
+		ids[i] = id
+		if workID, err := uuid.Parse(id); err == nil {
+			scores[workID] = z.Score
+		}
+	}
+
+	// Works are looked up again so one made private or deleted since the
+	// last scoring drops out at once
+	byID := make(map[uuid.UUID]TrendingWork, len(ids))
+	if len(ids) > 0 {
+		rows, err := ss.db.QueryContext(ctx, `
+			SELECT id, title, COALESCE(rating, ''), COALESCE(fandoms, '{}'), COALESCE(word_count, 0),
+				COALESCE(hit_count, 0), COALESCE(kudos_count, 0)
+			FROM works
+			WHERE id = ANY($1::uuid[]) AND status = 'posted' AND restricted = false`, pq.Array(ids))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load trending works"})
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var w TrendingWork
+			if err := rows.Scan(&w.WorkID, &w.Title, &w.Rating, pq.Array(&w.Fandoms), &w.WordCount, &w.Hits, &w.Kudos); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read trending works"})
+				return
+			}
+			w.Score = scores[w.WorkID]
+			byID[w.WorkID] = w
+		}
+	}
+
+	ordered := make([]TrendingWork, 0, len(byID))
+	for _, z := range scored {
+		id, _ := z.Member.(string)
+		if workID, err := uuid.Parse(id); err == nil {
+			if w, ok := byID[workID]; ok {
+				ordered = append(ordered, w)
+			}
+		}
+	}
+
+	gated := map[string]bool{}
+	if _, signedIn := requestUserID(c); !signedIn {
+		if gated, err = ss.loginGatedRatings(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rating policy"})
+			return
+		}
+	}
+
+	response := gin.H{
+		"works":       visibleTrendingWorks(ordered, gated, limit),
+		"window_days": trendingWindowDays,
+	}
+	if scoredAt, err := ss.redis.Get(ctx, trendingScoredAtKey).Result(); err == nil {
+		response["scored_at"] = scoredAt
+	}
+	c.JSON(http.StatusOK, response)
+}
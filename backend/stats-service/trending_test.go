@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisibleTrendingWorks(t *testing.T) {
+	works := []TrendingWork{
+		{WorkID: uuid.New(), Rating: "Explicit"},
+		{WorkID: uuid.New(), Rating: "General Audiences"},
+		{WorkID: uuid.New(), Rating: "Mature"},
+		{WorkID: uuid.New(), Rating: "Teen And Up Audiences"},
+	}
+
+	all := visibleTrendingWorks(works, map[string]bool{}, 10)
+	assert.Equal(t, works, all)
+
+	gated := map[string]bool{"explicit": true}
+	visible := visibleTrendingWorks(works, gated, 2)
+	assert.Equal(t, []TrendingWork{works[1], works[2]}, visible)
+}
+
+func TestTrendingLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := map[string]int{
+		"":           defaultTrendingLimit,
+		"?limit=5":   5,
+		"?limit=0":   defaultTrendingLimit,
+		"?limit=abc": defaultTrendingLimit,
+		"?limit=500": maxTrendingLimit,
+	}
+	for query, want := range cases {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/api/v1/works/trending"+query, nil)
+		assert.Equal(t, want, trendingLimit(c), query)
+	}
+}
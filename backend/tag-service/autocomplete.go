@@ -0,0 +1,616 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/middleware"
+	"nuclear-ao3/shared/models"
+)
+
+// =============================================================================
+// TAG AUTOCOMPLETE INDEX
+// Canonical tags are indexed in Redis so autocomplete never scans Postgres.
+// Every word in a tag's name starts a suffix ("Harry Potter/Draco Malfoy"
+// gives "harry potter/draco malfoy", "potter/draco malfoy", "draco malfoy"
+// and "malfoy"), and each suffix is indexed in every scope the tag belongs
+// to: all tags, its type, and for characters and relationships each fandom
+// it sits under.
+//
+// Short prefixes match too many tags to list, so for each one the most used
+// tags are kept in a sorted set scored by use_count. Longer prefixes are
+// answered from a lexicographic sorted set of the suffixes and ranked by
+// use_count afterwards.
+//
+// The index is rebuilt on a schedule into a new generation of keys, which
+// replaces the old one in a single step.
+// =============================================================================
+
+const (
+	autocompleteKeyPrefix  = "tag_autocomplete:"
+	autocompleteCurrentKey = autocompleteKeyPrefix + "current"
+	autocompleteBuiltAtKey = autocompleteKeyPrefix + "built_at"
+	autocompleteLockKey    = autocompleteKeyPrefix + "lock"
+	autocompleteLockTTL    = 5 * time.Minute
+
+	// minAutocompleteQuery is the shortest query answered
+	minAutocompleteQuery = 2
+	// autocompleteShortPrefix is the longest prefix served from the
+	// popularity sets; longer ones use the lexicographic index
+	autocompleteShortPrefix = 3
+	// autocompletePerPrefix is how many tags each popularity set keeps
+	autocompletePerPrefix = 50
+	// autocompleteLexScan caps how many suffixes a long prefix reads
+	autocompleteLexScan = 500
+	// autocompleteWriteBatch is how many commands go in one pipeline
+	autocompleteWriteBatch = 1000
+
+	defaultAutocompleteLimit = 10
+	maxAutocompleteLimit     = 50
+)
+
+// fandomScopedTypes are the tag types that can be completed within a fandom
+var fandomScopedTypes = []string{"character", "relationship"}
+
+// autocompleteTag is a canonical tag as indexed, with the fandoms it sits
+// under
+type autocompleteTag struct {
+	models.TagSuggestion
+	Fandoms []uuid.UUID
+}
+
+// autocompletePrefix names one popularity set
+type autocompletePrefix struct {
+	scope  string
+	prefix string
+}
+
+// autocompleteIndex is a built index ready to write: the popularity sets,
+// the suffixes by scope, and each tag's suggestion by ID
+type autocompleteIndex struct {
+	prefixes map[autocompletePrefix][]redis.Z
+	suffixes map[string][]string
+	tags     map[string]string
+}
+
+// errAutocompleteRebuildRunning is returned when another rebuild holds the
+// lock
+var errAutocompleteRebuildRunning = errors.New("an autocomplete rebuild is already running")
+
+func autocompleteGenKey(gen string, parts ...string) string {
+	return autocompleteKeyPrefix + gen + ":" + strings.Join(parts, ":")
+}
+
+func autocompletePrefixKey(gen, scope, prefix string) string {
+	return autocompleteGenKey(gen, "p", scope, prefix)
+}
+
+func autocompleteLexKey(gen, scope string) string {
+	return autocompleteGenKey(gen, "lex", scope)
+}
+
+func autocompleteTagsKey(gen string) string {
+	return autocompleteGenKey(gen, "tags")
+}
+
+// normalizeTagName lowercases a name and collapses its whitespace, so names
+// and queries compare the same way
+func normalizeTagName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// tagNameSuffixes returns the normalized name from each word onwards, where
+// a word is a letter or digit following anything else
+func tagNameSuffixes(name string) []string {
+	normalized := normalizeTagName(name)
+	suffixes := []string{}
+	seen := map[string]bool{}
+	prevWord := false
+	for i, r := range normalized {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && !prevWord {
+			if suffix := normalized[i:]; !seen[suffix] {
+				seen[suffix] = true
+				suffixes = append(suffixes, suffix)
+			}
+		}
+		prevWord = isWord
+	}
+	return suffixes
+}
+
+// runePrefix is s cut to its first n runes
+func runePrefix(s string, n int) string {
+	i := 0
+	for count := 0; i < len(s) && count < n; count++ {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return s[:i]
+}
+
+// autocompleteScopes lists the scopes a tag is indexed in
+func autocompleteScopes(tag autocompleteTag) []string {
+	scopes := []string{"all", "type:" + tag.Type}
+	for _, scoped := range fandomScopedTypes {
+		if tag.Type != scoped {
+			continue
+		}
+		for _, fandomID := range tag.Fandoms {
+			scopes = append(scopes, "fandom:"+fandomID.String()+":"+tag.Type)
+		}
+	}
+	return scopes
+}
+
+// buildAutocompleteIndex indexes the tags, keeping only the most used tags
+// for each short prefix
+func buildAutocompleteIndex(tags []autocompleteTag) autocompleteIndex {
+	type scored struct {
+		id       string
+		name     string
+		useCount int
+	}
+	prefixMembers := map[autocompletePrefix]map[string]scored{}
+	index := autocompleteIndex{
+		prefixes: map[autocompletePrefix][]redis.Z{},
+		suffixes: map[string][]string{},
+		tags:     make(map[string]string, len(tags)),
+	}
+
+	for _, tag := range tags {
+		id := tag.ID.String()
+		if data, err := json.Marshal(tag.TagSuggestion); err == nil {
+			index.tags[id] = string(data)
+		}
+		suffixes := tagNameSuffixes(tag.Name)
+		for _, scope := range autocompleteScopes(tag) {
+			for _, suffix := range suffixes {
+				index.suffixes[scope] = append(index.suffixes[scope], suffix+"\x00"+id)
+				for n := minAutocompleteQuery; n <= autocompleteShortPrefix; n++ {
+					prefix := runePrefix(suffix, n)
+					if utf8.RuneCountInString(prefix) < n {
+						break
+					}
+					key := autocompletePrefix{scope, prefix}
+					if prefixMembers[key] == nil {
+						prefixMembers[key] = map[string]scored{}
+					}
+					prefixMembers[key][id] = scored{id, tag.Name, tag.UseCount}
+				}
+			}
+		}
+	}
+
+	for key, members := range prefixMembers {
+		ranked := make([]scored, 0, len(members))
+		for _, m := range members {
+			ranked = append(ranked, m)
+		}
+		sort.Slice(ranked, func(i, j int) bool {
+			if ranked[i].useCount != ranked[j].useCount {
+				return ranked[i].useCount > ranked[j].useCount
+			}
+			return ranked[i].name < ranked[j].name
+		})
+		if len(ranked) > autocompletePerPrefix {
+			ranked = ranked[:autocompletePerPrefix]
+		}
+		zs := make([]redis.Z, len(ranked))
+		for i, m := range ranked {
+			zs[i] = redis.Z{Member: m.id, Score: float64(m.useCount)}
+		}
+		index.prefixes[key] = zs
+	}
+	return index
+}
+
+// rankAutocompleteSuggestions orders candidates for a query: an exact name
+// first, then names that start with the query ahead of those where a later
+// word does, then by use, then by name
+func rankAutocompleteSuggestions(query string, candidates []models.TagSuggestion, limit int) []models.TagSuggestion {
+	query = normalizeTagName(query)
+	rank := func(s models.TagSuggestion) int {
+		name := normalizeTagName(s.Name)
+		switch {
+		case name == query:
+			return 0
+		case strings.HasPrefix(name, query):
+			return 1
+		}
+		return 2
+	}
+
+	ranked := append([]models.TagSuggestion(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		ri, rj := rank(ranked[i]), rank(ranked[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if ranked[i].UseCount != ranked[j].UseCount {
+			return ranked[i].UseCount > ranked[j].UseCount
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// autocompleteQueryScopes lists the scopes a request searches
+func autocompleteQueryScopes(tagType string, fandoms []uuid.UUID) []string {
+	if len(fandoms) == 0 {
+		if tagType == "" {
+			return []string{"all"}
+		}
+		return []string{"type:" + tagType}
+	}
+
+	types := fandomScopedTypes
+	if tagType != "" {
+		types = []string{tagType}
+	}
+	scopes := []string{}
+	for _, fandomID := range fandoms {
+		for _, t := range types {
+			scopes = append(scopes, "fandom:"+fandomID.String()+":"+t)
+		}
+	}
+	return scopes
+}
+
+// loadAutocompleteTags reads the canonical, filterable tags with the fandoms
+// each character and relationship sits under
+func (ts *TagService) loadAutocompleteTags(ctx context.Context) ([]autocompleteTag, error) {
+	rows, err := ts.db.QueryContext(ctx, `
+		SELECT t.id, t.name, t.type, COALESCE(t.use_count, 0),
+			ARRAY(
+				SELECT tr.parent_tag_id
+				FROM tag_relationships tr
+				JOIN tags f ON f.id = tr.parent_tag_id
+				WHERE tr.child_tag_id = t.id
+				AND tr.relationship_type = 'parent_child'
+				AND f.type = 'fandom'
+			)
+		FROM tags t
+		WHERE t.is_canonical = true AND t.is_filterable = true`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []autocompleteTag{}
+	for rows.Next() {
+		var tag autocompleteTag
+		var fandoms []string
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Type, &tag.UseCount, pq.Array(&fandoms)); err != nil {
+			return nil, err
+		}
+		tag.Canonical = true
+		for _, f := range fandoms {
+			if fandomID, err := uuid.Parse(f); err == nil {
+				tag.Fandoms = append(tag.Fandoms, fandomID)
+			}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// startAutocompleteRefresher rebuilds the index every interval until ctx is
+// cancelled. With several replicas running, whichever gets there first
+// rebuilds and the rest find the index fresh.
+func (ts *TagService) startAutocompleteRefresher(ctx context.Context, interval time.Duration) {
+	refresh := func() {
+		builtAt, err := ts.redis.Get(ctx, autocompleteBuiltAtKey).Int64()
+		if err == nil && time.Since(time.Unix(builtAt, 0)) < interval {
+			return
+		}
+		if _, err := ts.rebuildAutocompleteIndex(ctx); err != nil {
+			log.Printf("Tag autocomplete rebuild failed: %v", err)
+		}
+	}
+
+	refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
+
+// rebuildAutocompleteIndex builds a new generation of the index, switches
+// readers to it and removes the old one. It reports how many tags were
+// indexed; a rebuild already under way elsewhere is left to finish.
+func (ts *TagService) rebuildAutocompleteIndex(ctx context.Context) (int, error) {
+	locked, err := ts.redis.SetNX(ctx, autocompleteLockKey, 1, autocompleteLockTTL).Result()
+	if err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, errAutocompleteRebuildRunning
+	}
+	defer ts.redis.Del(context.Background(), autocompleteLockKey)
+
+	tags, err := ts.loadAutocompleteTags(ctx)
+	if err != nil {
+		return 0, err
+	}
+	index := buildAutocompleteIndex(tags)
+	gen := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	pipe := ts.redis.Pipeline()
+	flush := func(force bool) error {
+		if pipe.Len() == 0 || (!force && pipe.Len() < autocompleteWriteBatch) {
+			return nil
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	}
+	for key, members := range index.prefixes {
+		pipe.ZAdd(ctx, autocompletePrefixKey(gen, key.scope, key.prefix), members...)
+		if err := flush(false); err != nil {
+			return 0, err
+		}
+	}
+	for scope, suffixes := range index.suffixes {
+		for start := 0; start < len(suffixes); start += autocompleteWriteBatch {
+			end := start + autocompleteWriteBatch
+			if end > len(suffixes) {
+				end = len(suffixes)
+			}
+			members := make([]redis.Z, 0, end-start)
+			for _, suffix := range suffixes[start:end] {
+				members = append(members, redis.Z{Member: suffix})
+			}
+			pipe.ZAdd(ctx, autocompleteLexKey(gen, scope), members...)
+			if err := flush(false); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if len(index.tags) > 0 {
+		pipe.HSet(ctx, autocompleteTagsKey(gen), index.tags)
+	}
+	if err := flush(true); err != nil {
+		return 0, err
+	}
+
+	previous, _ := ts.redis.Get(ctx, autocompleteCurrentKey).Result()
+	pipe.Set(ctx, autocompleteCurrentKey, gen, 0)
+	pipe.Set(ctx, autocompleteBuiltAtKey, time.Now().Unix(), 0)
+	if err := flush(true); err != nil {
+		return 0, err
+	}
+	if previous != "" && previous != gen {
+		ts.dropAutocompleteGeneration(ctx, previous)
+	}
+
+	log.Printf("Indexed %d tags for autocomplete", len(tags))
+	return len(tags), nil
+}
+
+// dropAutocompleteGeneration removes a replaced generation's keys
+func (ts *TagService) dropAutocompleteGeneration(ctx context.Context, gen string) {
+	iter := ts.redis.Scan(ctx, 0, autocompleteGenKey(gen, "*"), autocompleteWriteBatch).Iterator()
+	batch := []string{}
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == autocompleteWriteBatch {
+			ts.redis.Unlink(ctx, batch...)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		ts.redis.Unlink(ctx, batch...)
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("Failed to remove old autocomplete index %s: %v", gen, err)
+	}
+}
+
+// autocompleteFromIndex answers a query from the index. It reports false
+// when no index has been built yet.
+func (ts *TagService) autocompleteFromIndex(ctx context.Context, query, tagType string, fandoms []uuid.UUID, limit int) ([]models.TagSuggestion, bool, error) {
+	gen, err := ts.redis.Get(ctx, autocompleteCurrentKey).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	query = normalizeTagName(query)
+	short := utf8.RuneCountInString(query) <= autocompleteShortPrefix
+	pipe := ts.redis.Pipeline()
+	var prefixCmds []*redis.StringSliceCmd
+	for _, scope := range autocompleteQueryScopes(tagType, fandoms) {
+		if short {
+			prefixCmds = append(prefixCmds, pipe.ZRevRange(ctx, autocompletePrefixKey(gen, scope, query), 0, -1))
+		} else {
+			prefixCmds = append(prefixCmds, pipe.ZRangeByLex(ctx, autocompleteLexKey(gen, scope), &redis.ZRangeBy{
+				Min: "[" + query, Max: "[" + query + "\xff", Count: autocompleteLexScan,
+			}))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, true, err
+	}
+
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, cmd := range prefixCmds {
+		for _, member := range cmd.Val() {
+			if !short {
+				_, member, _ = strings.Cut(member, "\x00")
+			}
+			if member != "" && !seen[member] {
+				seen[member] = true
+				ids = append(ids, member)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return []models.TagSuggestion{}, true, nil
+	}
+
+	values, err := ts.redis.HMGet(ctx, autocompleteTagsKey(gen), ids...).Result()
+	if err != nil {
+		return nil, true, err
+	}
+	candidates := make([]models.TagSuggestion, 0, len(values))
+	for _, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var suggestion models.TagSuggestion
+		if json.Unmarshal([]byte(data), &suggestion) == nil {
+			candidates = append(candidates, suggestion)
+		}
+	}
+	return rankAutocompleteSuggestions(query, candidates, limit), true, nil
+}
+
+// autocompleteFromDB answers a query from Postgres, used only until the
+// first index is built
+func (ts *TagService) autocompleteFromDB(ctx context.Context, query, tagType string, fandoms []uuid.UUID, limit int) ([]models.TagSuggestion, error) {
+	fandomIDs := make([]string, len(fandoms))
+	for i, f := range fandoms {
+		fandomIDs[i] = f.String()
+	}
+
+	rows, err := ts.db.QueryContext(ctx, `
+		SELECT id, name, type, COALESCE(use_count, 0), is_canonical
+		FROM tags t
+		WHERE name ILIKE $1 AND is_filterable = true
+		AND ($2 = '' OR type = $2)
+		AND (cardinality($3::uuid[]) = 0 OR (type IN ('character', 'relationship') AND EXISTS (
+			SELECT 1 FROM tag_relationships tr
+			WHERE tr.child_tag_id = t.id AND tr.relationship_type = 'parent_child'
+			AND tr.parent_tag_id = ANY($3::uuid[])
+		)))
+		ORDER BY
+			CASE WHEN name ILIKE $4 THEN 1 ELSE 2 END,
+			use_count DESC,
+			name ASC
+		LIMIT $5`,
+		query+"%", tagType, pq.Array(fandomIDs), query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []models.TagSuggestion{}
+	for rows.Next() {
+		var suggestion models.TagSuggestion
+		if err := rows.Scan(&suggestion.ID, &suggestion.Name, &suggestion.Type, &suggestion.UseCount, &suggestion.Canonical); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, rows.Err()
+}
+
+// AutocompleteTags completes tag names from the prefix index, most used
+// first. type limits the tag type; fandom, a fandom tag ID that may be
+// repeated, limits characters and relationships to those fandoms.
+// GET /api/v1/tags/autocomplete?q=harry&type=character&fandom=123
+func (ts *TagService) AutocompleteTags(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
+		return
+	}
+	if utf8.RuneCountInString(query) < minAutocompleteQuery {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query must be at least 2 characters"})
+		return
+	}
+
+	tagType := c.Query("type")
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultAutocompleteLimit
+	}
+	if limit > maxAutocompleteLimit {
+		limit = maxAutocompleteLimit
+	}
+
+	fandoms := []uuid.UUID{}
+	for _, f := range c.QueryArray("fandom") {
+		fandomID, err := uuid.Parse(f)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fandom ID"})
+			return
+		}
+		fandoms = append(fandoms, fandomID)
+	}
+	if len(fandoms) > 0 && tagType != "" && tagType != "character" && tagType != "relationship" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only characters and relationships can be completed within a fandom"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	suggestions, indexed, err := ts.autocompleteFromIndex(ctx, query, tagType, fandoms, limit)
+	if err != nil {
+		log.Printf("Tag autocomplete index lookup failed: %v", err)
+	}
+	if err != nil || !indexed {
+		suggestions, err = ts.autocompleteFromDB(ctx, query, tagType, fandoms, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
+// InternalRebuildAutocomplete rebuilds the autocomplete index now rather
+// than at the next scheduled refresh:
+// POST /api/v1/internal/tags/autocomplete/rebuild
+func (ts *TagService) InternalRebuildAutocomplete(c *gin.Context) {
+	ctx := c.Request.Context()
+	if middleware.IsDryRun(c) {
+		var count int
+		err := ts.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tags WHERE is_canonical = true AND is_filterable = true").Scan(&count)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count tags"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "tags": count})
+		return
+	}
+
+	count, err := ts.rebuildAutocompleteIndex(ctx)
+	if errors.Is(err, errAutocompleteRebuildRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An autocomplete rebuild is already running"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild autocomplete index"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": count, "message": "Autocomplete index rebuilt"})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestTagNameSuffixes(t *testing.T) {
+	assert.Equal(t,
+		[]string{"harry potter/draco malfoy", "potter/draco malfoy", "draco malfoy", "malfoy"},
+		tagNameSuffixes("Harry  Potter/Draco Malfoy"))
+	assert.Equal(t, []string{"the 100 (tv)", "100 (tv)", "tv)"}, tagNameSuffixes("The 100 (TV)"))
+	assert.Equal(t, []string{"ōkami"}, tagNameSuffixes("Ōkami"))
+}
+
+func TestBuildAutocompleteIndex(t *testing.T) {
+	fandom := uuid.New()
+	harry := autocompleteTag{TagSuggestion: models.TagSuggestion{ID: uuid.New(), Name: "Harry Potter", Type: "character", UseCount: 900}, Fandoms: []uuid.UUID{fandom}}
+	hermione := autocompleteTag{TagSuggestion: models.TagSuggestion{ID: uuid.New(), Name: "Hermione Granger", Type: "character", UseCount: 700}, Fandoms: []uuid.UUID{fandom}}
+	hurt := autocompleteTag{TagSuggestion: models.TagSuggestion{ID: uuid.New(), Name: "Hurt/Comfort", Type: "freeform", UseCount: 5000}}
+
+	index := buildAutocompleteIndex([]autocompleteTag{harry, hermione, hurt})
+
+	all := index.prefixes[autocompletePrefix{"all", "h"}]
+	assert.Empty(t, all, "single characters aren't indexed")
+
+	all = index.prefixes[autocompletePrefix{"all", "he"}]
+	require.Len(t, all, 1)
+	assert.Equal(t, hermione.ID.String(), all[0].Member)
+
+	hu := index.prefixes[autocompletePrefix{"all", "hu"}]
+	require.Len(t, hu, 1)
+	assert.Equal(t, float64(5000), hu[0].Score)
+
+	// Later words are indexed too
+	po := index.prefixes[autocompletePrefix{"type:character", "pot"}]
+	require.Len(t, po, 1)
+	assert.Equal(t, harry.ID.String(), po[0].Member)
+
+	scoped := index.prefixes[autocompletePrefix{"fandom:" + fandom.String() + ":character", "ha"}]
+	require.Len(t, scoped, 1)
+	assert.Nil(t, index.prefixes[autocompletePrefix{"fandom:" + fandom.String() + ":freeform", "hu"}])
+
+	assert.Contains(t, index.suffixes["all"], "granger\x00"+hermione.ID.String())
+	assert.Len(t, index.tags, 3)
+}
+
+func TestBuildAutocompleteIndexKeepsMostUsed(t *testing.T) {
+	tags := []autocompleteTag{}
+	for i := 0; i < autocompletePerPrefix+10; i++ {
+		tags = append(tags, autocompleteTag{TagSuggestion: models.TagSuggestion{
+			ID: uuid.New(), Name: fmt.Sprintf("Alpha %d", i), Type: "freeform", UseCount: i,
+		}})
+	}
+
+	set := buildAutocompleteIndex(tags).prefixes[autocompletePrefix{"all", "alp"}]
+	require.Len(t, set, autocompletePerPrefix)
+	assert.Equal(t, float64(autocompletePerPrefix+9), set[0].Score)
+	assert.Equal(t, float64(10), set[len(set)-1].Score)
+}
+
+func TestRankAutocompleteSuggestions(t *testing.T) {
+	popular := models.TagSuggestion{Name: "Alternate Universe - Harry Potter Setting", UseCount: 9000}
+	prefix := models.TagSuggestion{Name: "Harry Potter/Draco Malfoy", UseCount: 4000}
+	exact := models.TagSuggestion{Name: "Harry Potter", UseCount: 100}
+	prefixLessUsed := models.TagSuggestion{Name: "Harry Potter & Ron Weasley", UseCount: 50}
+
+	ranked := rankAutocompleteSuggestions("harry  potter", []models.TagSuggestion{popular, prefixLessUsed, prefix, exact}, 3)
+	assert.Equal(t, []models.TagSuggestion{exact, prefix, prefixLessUsed}, ranked)
+}
+
+func TestAutocompleteQueryScopes(t *testing.T) {
+	fandom := uuid.New()
+	assert.Equal(t, []string{"all"}, autocompleteQueryScopes("", nil))
+	assert.Equal(t, []string{"type:fandom"}, autocompleteQueryScopes("fandom", nil))
+	assert.Equal(t,
+		[]string{"fandom:" + fandom.String() + ":character", "fandom:" + fandom.String() + ":relationship"},
+		autocompleteQueryScopes("", []uuid.UUID{fandom}))
+	assert.Equal(t,
+		[]string{"fandom:" + fandom.String() + ":relationship"},
+		autocompleteQueryScopes("relationship", []uuid.UUID{fandom}))
+}
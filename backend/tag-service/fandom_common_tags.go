@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// =============================================================================
+// FANDOM COMMON TAGS
+// A fandom page lists the characters, relationships and freeforms most often
+// used alongside it, ranked by how many posted works actually pair them with
+// the fandom rather than by what wranglers have filed under it. Counting
+// co-occurrence across work_tags is too slow to do per request, so a
+// periodic job aggregates it into fandom_tag_cooccurrence, keeping the top
+// tags of each type per fandom, and the endpoints read from there through a
+// short Redis cache.
+// =============================================================================
+
+const (
+	cooccurrenceKeyPrefix  = "fandom_cooccurrence:"
+	cooccurrenceBuiltAtKey = cooccurrenceKeyPrefix + "built_at"
+	cooccurrenceLockKey    = cooccurrenceKeyPrefix + "lock"
+	cooccurrenceLockTTL    = 30 * time.Minute
+	commonTagsCachePrefix  = "fandom_common_tags:"
+	commonTagsCacheTTL     = 10 * time.Minute
+
+	// cooccurrenceKeepPerType is how many tags of each type are kept per
+	// fandom; it bounds the largest page the endpoints can serve
+	cooccurrenceKeepPerType = 100
+
+	defaultCommonTagsLimit = 20
+)
+
+// commonTagTypes are the tag types ranked on fandom pages
+var commonTagTypes = []string{"character", "relationship", "freeform"}
+
+var errCooccurrenceRebuildRunning = errors.New("co-occurrence rebuild already running")
+
+// CommonTag is a tag ranked by the works it shares with a fandom
+type CommonTag struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	WorkCount int       `json:"work_count"`
+}
+
+// commonTagsPage is one cached answer from a common tags endpoint
+type commonTagsPage struct {
+	Tags       []CommonTag `json:"tags"`
+	ComputedAt *time.Time  `json:"computed_at"`
+}
+
+// commonTagsLimit reads the limit query parameter, defaulting when missing
+// or invalid and capping at what the aggregation keeps
+func commonTagsLimit(param string) int {
+	limit, err := strconv.Atoi(param)
+	if err != nil || limit <= 0 {
+		return defaultCommonTagsLimit
+	}
+	if limit > cooccurrenceKeepPerType {
+		return cooccurrenceKeepPerType
+	}
+	return limit
+}
+
+// commonTagsCacheKey names the cached page for a fandom, tag type ("" for
+// all types) and limit
+func commonTagsCacheKey(fandomID uuid.UUID, tagType string, limit int) string {
+	if tagType == "" {
+		tagType = "all"
+	}
+	return fmt.Sprintf("%s%s:%s:%d", commonTagsCachePrefix, fandomID, tagType, limit)
+}
+
+// startCooccurrenceRefresher rebuilds the aggregation every interval until
+// ctx is cancelled. As with the autocomplete index, whichever replica gets
+// there first rebuilds and the rest find it fresh.
+func (ts *TagService) startCooccurrenceRefresher(ctx context.Context, interval time.Duration) {
+	refresh := func() {
+		builtAt, err := ts.redis.Get(ctx, cooccurrenceBuiltAtKey).Int64()
+		if err == nil && time.Since(time.Unix(builtAt, 0)) < interval {
+			return
+		}
+		if _, err := ts.rebuildFandomCooccurrence(ctx); err != nil && err != errCooccurrenceRebuildRunning {
+			log.Printf("Fandom co-occurrence rebuild failed: %v", err)
+		}
+	}
+
+	refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
+
+// rebuildFandomCooccurrence recounts which tags posted works pair with each
+// fandom and replaces the aggregation in one transaction, so readers see
+// either the old counts or the new. It reports how many pairs were kept.
+func (ts *TagService) rebuildFandomCooccurrence(ctx context.Context) (int64, error) {
+	locked, err := ts.redis.SetNX(ctx, cooccurrenceLockKey, 1, cooccurrenceLockTTL).Result()
+	if err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, errCooccurrenceRebuildRunning
+	}
+	defer ts.redis.Del(context.Background(), cooccurrenceLockKey)
+
+	tx, err := ts.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fandom_tag_cooccurrence"); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO fandom_tag_cooccurrence (fandom_id, tag_id, tag_type, work_count, computed_at)
+		SELECT fandom_id, tag_id, tag_type, work_count, NOW()
+		FROM (
+			SELECT ft.tag_id AS fandom_id, ot.tag_id, t.type AS tag_type, COUNT(DISTINCT w.id) AS work_count,
+				ROW_NUMBER() OVER (
+					PARTITION BY ft.tag_id, t.type
+					ORDER BY COUNT(DISTINCT w.id) DESC, ot.tag_id
+				) AS rank
+			FROM work_tags ft
+			JOIN tags f ON f.id = ft.tag_id AND f.type = 'fandom'
+			JOIN works w ON w.id = ft.work_id AND w.is_draft = false AND w.published_at IS NOT NULL
+				AND w.deleted_at IS NULL AND w.status != 'unlisted'
+			JOIN work_tags ot ON ot.work_id = ft.work_id AND ot.tag_id <> ft.tag_id
+			JOIN tags t ON t.id = ot.tag_id AND t.is_canonical = true AND t.type = ANY($1)
+			GROUP BY ft.tag_id, ot.tag_id, t.type
+		) ranked
+		WHERE rank <= $2`, pq.Array(commonTagTypes), cooccurrenceKeepPerType)
+	if err != nil {
+		return 0, err
+	}
+	kept, _ := res.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	ts.redis.Set(ctx, cooccurrenceBuiltAtKey, time.Now().Unix(), 0)
+	ts.clearCommonTagsCache(ctx)
+	log.Printf("Fandom co-occurrence rebuilt: %d fandom/tag pairs", kept)
+	return kept, nil
+}
+
+// clearCommonTagsCache drops every cached common tags page after a rebuild
+func (ts *TagService) clearCommonTagsCache(ctx context.Context) {
+	iter := ts.redis.Scan(ctx, 0, commonTagsCachePrefix+"*", 500).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 500 {
+			ts.redis.Del(ctx, keys...)
+			keys = keys[:0]
+		}
+	}
+	if len(keys) > 0 {
+		ts.redis.Del(ctx, keys...)
+	}
+}
+
+// fandomCommonTags returns a fandom's most common tags of one type, or of
+// all ranked types when tagType is empty, from the cache when it can
+func (ts *TagService) fandomCommonTags(ctx context.Context, fandomID uuid.UUID, tagType string, limit int) (*commonTagsPage, error) {
+	cacheKey := commonTagsCacheKey(fandomID, tagType, limit)
+	if data, err := ts.redis.Get(ctx, cacheKey).Bytes(); err == nil {
+		var page commonTagsPage
+		if json.Unmarshal(data, &page) == nil {
+			return &page, nil
+		}
+	}
+
+	types := commonTagTypes
+	if tagType != "" {
+		types = []string{tagType}
+	}
+	rows, err := ts.db.QueryContext(ctx, `
+		SELECT t.id, t.name, co.tag_type, co.work_count, co.computed_at
+		FROM fandom_tag_cooccurrence co
+		JOIN tags t ON t.id = co.tag_id
+		WHERE co.fandom_id = $1 AND co.tag_type = ANY($2)
+		ORDER BY co.work_count DESC, t.name
+		LIMIT $3`, fandomID, pq.Array(types), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := commonTagsPage{Tags: []CommonTag{}}
+	for rows.Next() {
+		var tag CommonTag
+		var computedAt time.Time
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Type, &tag.WorkCount, &computedAt); err != nil {
+			return nil, err
+		}
+		page.ComputedAt = &computedAt
+		page.Tags = append(page.Tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(page); err == nil {
+		ts.redis.Set(ctx, cacheKey, data, commonTagsCacheTTL)
+	}
+	return &page, nil
+}
+
+// serveFandomCommonTags answers a common tags endpoint, listing the tags
+// under key in the response
+func (ts *TagService) serveFandomCommonTags(c *gin.Context, tagType, key string) {
+	fandomID, err := uuid.Parse(c.Param("fandom_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fandom ID"})
+		return
+	}
+
+	var isFandom bool
+	err = ts.db.QueryRowContext(c.Request.Context(),
+		"SELECT type = 'fandom' FROM tags WHERE id = $1", fandomID).Scan(&isFandom)
+	if err == sql.ErrNoRows || (err == nil && !isFandom) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fandom not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	limit := commonTagsLimit(c.Query("limit"))
+	page, err := ts.fandomCommonTags(c.Request.Context(), fandomID, tagType, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch common tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"fandom_id":   fandomID,
+		key:           page.Tags,
+		"limit":       limit,
+		"computed_at": page.ComputedAt,
+	})
+}
+
+// GetFandomTags lists the characters, relationships and freeforms most
+// often used with a fandom
+// GET /api/v1/fandoms/:fandom_id/tags?limit=20
+func (ts *TagService) GetFandomTags(c *gin.Context) {
+	ts.serveFandomCommonTags(c, "", "tags")
+}
+
+// GetFandomCharacters lists the characters most often used with a fandom
+// GET /api/v1/fandoms/:fandom_id/characters?limit=20
+func (ts *TagService) GetFandomCharacters(c *gin.Context) {
+	ts.serveFandomCommonTags(c, "character", "characters")
+}
+
+// GetFandomRelationships lists the relationships most often used with a
+// fandom
+// GET /api/v1/fandoms/:fandom_id/relationships?limit=20
+func (ts *TagService) GetFandomRelationships(c *gin.Context) {
+	ts.serveFandomCommonTags(c, "relationship", "relationships")
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommonTagsLimit(t *testing.T) {
+	assert.Equal(t, defaultCommonTagsLimit, commonTagsLimit(""))
+	assert.Equal(t, defaultCommonTagsLimit, commonTagsLimit("-5"))
+	assert.Equal(t, defaultCommonTagsLimit, commonTagsLimit("ten"))
+	assert.Equal(t, 5, commonTagsLimit("5"))
+	assert.Equal(t, cooccurrenceKeepPerType, commonTagsLimit("1000"))
+}
+
+func TestCommonTagsCacheKey(t *testing.T) {
+	fandomID := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	assert.Equal(t, "fandom_common_tags:6ba7b810-9dad-11d1-80b4-00c04fd430c8:character:20",
+		commonTagsCacheKey(fandomID, "character", 20))
+	assert.Equal(t, "fandom_common_tags:6ba7b810-9dad-11d1-80b4-00c04fd430c8:all:20",
+		commonTagsCacheKey(fandomID, "", 20))
+}
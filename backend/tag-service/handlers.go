@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -12,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"nuclear-ao3/shared/middleware"
 	"nuclear-ao3/shared/models"
 )
 
@@ -110,7 +110,7 @@ func (ts *TagService) GetTag(c *gin.Context) {
 
 	// Try cache first
 	if tag := ts.getCachedTag(tagID); tag != nil {
-		c.JSON(http.StatusOK, gin.H{"tag": tag})
+		respondWithTag(c, tag)
 		return
 	}
 
@@ -135,6 +135,17 @@ func (ts *TagService) GetTag(c *gin.Context) {
 	// Cache the result
 	ts.cacheTag(&tag)
 
+	respondWithTag(c, &tag)
+}
+
+// respondWithTag sends a tag with an ETag of its content, answering 304
+// when the client's copy is current. Use counts change without updated_at
+// moving, so the whole tag is hashed.
+func respondWithTag(c *gin.Context, tag *models.Tag) {
+	c.Header("Cache-Control", "no-cache")
+	if middleware.NotModified(c, middleware.ETag(tag)) {
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"tag": tag})
 }
 
@@ -193,7 +204,7 @@ func (ts *TagService) SearchTags(c *gin.Context) {
 	args = append(args, limit, offset)
 
 	// Execute query
-	rows, err := ts.db.Query(baseQuery, args...)
+	rows, err := ts.readDB().Query(baseQuery, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
 		return
@@ -220,7 +231,7 @@ func (ts *TagService) SearchTags(c *gin.Context) {
 	if len(conditions) > 0 {
 		countQuery += " WHERE " + strings.Join(conditions, " AND ")
 	}
-	ts.db.QueryRow(countQuery, args[:len(args)-2]...).Scan(&total)
+	ts.readDB().QueryRow(countQuery, args[:len(args)-2]...).Scan(&total)
 
 	c.JSON(http.StatusOK, gin.H{
 		"tags":   tags,
@@ -230,82 +241,6 @@ func (ts *TagService) SearchTags(c *gin.Context) {
 	})
 }
 
-// AutocompleteTags provides ultra-fast tag autocomplete
-func (ts *TagService) AutocompleteTags(c *gin.Context) {
-	query := c.Query("q")
-	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
-		return
-	}
-
-	tagType := c.Query("type")
-	limitStr := c.DefaultQuery("limit", "10")
-	limit, _ := strconv.Atoi(limitStr)
-
-	if limit > 50 {
-		limit = 50
-	}
-
-	// Check cache first for popular queries
-	cacheKey := fmt.Sprintf("autocomplete:%s:%s:%d", query, tagType, limit)
-	if cached := ts.getCachedAutocomplete(cacheKey); cached != nil {
-		c.JSON(http.StatusOK, gin.H{"suggestions": cached})
-		return
-	}
-
-	// Build optimized autocomplete query
-	var querySQL string
-	var args []interface{}
-
-	if tagType != "" {
-		querySQL = `
-			SELECT id, name, type, use_count, is_canonical
-			FROM tags 
-			WHERE name ILIKE $1 AND type = $2 AND is_filterable = true
-			ORDER BY 
-				CASE WHEN name ILIKE $3 THEN 1 ELSE 2 END,
-				use_count DESC,
-				name ASC
-			LIMIT $4
-		`
-		args = []interface{}{query + "%", tagType, query, limit}
-	} else {
-		querySQL = `
-			SELECT id, name, type, use_count, is_canonical
-			FROM tags 
-			WHERE name ILIKE $1 AND is_filterable = true
-			ORDER BY 
-				CASE WHEN name ILIKE $2 THEN 1 ELSE 2 END,
-				use_count DESC,
-				name ASC
-			LIMIT $3
-		`
-		args = []interface{}{query + "%", query, limit}
-	}
-
-	rows, err := ts.db.Query(querySQL, args...)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
-	}
-	defer rows.Close()
-
-	var suggestions []models.TagSuggestion
-	for rows.Next() {
-		var suggestion models.TagSuggestion
-		err := rows.Scan(&suggestion.ID, &suggestion.Name, &suggestion.Type, &suggestion.UseCount, &suggestion.Canonical)
-		if err != nil {
-			continue
-		}
-		suggestions = append(suggestions, suggestion)
-	}
-
-	// Cache the results for fast subsequent requests
-	ts.cacheAutocomplete(cacheKey, suggestions)
-
-	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
-}
-
 // GetTagsByWork retrieves all tags for a specific work
 func (ts *TagService) GetTagsByWork(c *gin.Context) {
 	workIDStr := c.Param("work_id")
@@ -371,7 +306,7 @@ func (ts *TagService) AddTagsToWork(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	// Add work-tag relationships
+	// Add work-tag relationships; the work_tags trigger keeps use_count
 	for _, tagID := range req.TagIDs {
 		_, err = tx.Exec(`
 			INSERT INTO work_tags (work_id, tag_id, created_at)
@@ -383,16 +318,6 @@ func (ts *TagService) AddTagsToWork(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add tag relationship"})
 			return
 		}
-
-		// Increment tag use count
-		_, err = tx.Exec(`
-			UPDATE tags SET use_count = use_count + 1, updated_at = $1 WHERE id = $2
-		`, time.Now(), tagID)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag use count"})
-			return
-		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -446,16 +371,6 @@ func (ts *TagService) RemoveTagFromWork(c *gin.Context) {
 		return
 	}
 
-	// Decrement tag use count
-	_, err = tx.Exec(`
-		UPDATE tags SET use_count = GREATEST(use_count - 1, 0), updated_at = $1 WHERE id = $2
-	`, time.Now(), tagID)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag use count"})
-		return
-	}
-
 	if err = tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
@@ -513,6 +428,7 @@ func (ts *TagService) CreateTagRelationship(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag relationship"})
 		return
 	}
+	ts.invalidateTagHierarchy(c.Request.Context())
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Tag relationship created successfully"})
 }
@@ -603,7 +519,7 @@ func (ts *TagService) GetPopularTags(c *gin.Context) {
 		args = []interface{}{limit}
 	}
 
-	rows, err := ts.db.Query(query, args...)
+	rows, err := ts.readDB().Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -630,62 +546,31 @@ func (ts *TagService) GetPopularTags(c *gin.Context) {
 // CACHE MANAGEMENT METHODS
 // =============================================================================
 
-// cacheTag stores a tag in Redis cache
+// cacheTag stores a tag in the tag cache
 func (ts *TagService) cacheTag(tag *models.Tag) {
-	if tag == nil {
+	if tag == nil || ts.tags == nil {
 		return
 	}
 
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("tag:%s", tag.ID)
-
-	if data, err := json.Marshal(tag); err == nil {
-		ts.redis.Set(ctx, cacheKey, data, time.Hour)
-	}
+	ts.tags.Set(context.Background(), tagCacheKey(tag.ID.String()), tag, time.Hour)
 }
 
-// getCachedTag retrieves a tag from Redis cache
+// getCachedTag retrieves a tag from the tag cache
 func (ts *TagService) getCachedTag(tagID uuid.UUID) *models.Tag {
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("tag:%s", tagID)
-
-	data, err := ts.redis.Get(ctx, cacheKey).Result()
-	if err != nil {
+	if ts.tags == nil {
 		return nil
 	}
 
 	var tag models.Tag
-	if err := json.Unmarshal([]byte(data), &tag); err != nil {
+	if err := ts.tags.Get(context.Background(), tagCacheKey(tagID.String()), &tag); err != nil {
 		return nil
 	}
 
 	return &tag
 }
 
-// cacheAutocomplete stores autocomplete results in Redis cache
-func (ts *TagService) cacheAutocomplete(cacheKey string, suggestions []models.TagSuggestion) {
-	ctx := context.Background()
-
-	if data, err := json.Marshal(suggestions); err == nil {
-		ts.redis.Set(ctx, cacheKey, data, 15*time.Minute)
-	}
-}
-
-// getCachedAutocomplete retrieves autocomplete results from Redis cache
-func (ts *TagService) getCachedAutocomplete(cacheKey string) []models.TagSuggestion {
-	ctx := context.Background()
-
-	data, err := ts.redis.Get(ctx, cacheKey).Result()
-	if err != nil {
-		return nil
-	}
-
-	var suggestions []models.TagSuggestion
-	if err := json.Unmarshal([]byte(data), &suggestions); err != nil {
-		return nil
-	}
-
-	return suggestions
+func tagCacheKey(tagID string) string {
+	return "tag:" + tagID
 }
 
 // clearWorkTagsCache clears cache entries related to work tags
@@ -703,8 +588,9 @@ func (ts *TagService) clearWorkTagsCache(workID uuid.UUID) {
 // clearTagCache clears cache entries related to a specific tag
 func (ts *TagService) clearTagCache(tagID string) {
 	ctx := context.Background()
-	cacheKey := fmt.Sprintf("tag:%s", tagID)
-	ts.redis.Del(ctx, cacheKey)
+	if ts.tags != nil {
+		ts.tags.Delete(ctx, tagCacheKey(tagID))
+	}
 
 	// Also clear autocomplete caches that might contain this tag
 	pattern := "autocomplete:*"
@@ -798,12 +684,12 @@ func (ts *TagService) GetTagWorks(c *gin.Context) {
 			w.created_at, w.updated_at, wt.prominence, wt.prominence_score
 		FROM works w
 		JOIN work_tags wt ON w.id = wt.work_id
-		WHERE wt.tag_id = $1
+		WHERE wt.tag_id = $1 AND w.deleted_at IS NULL AND w.status != 'unlisted'
 		ORDER BY %s DESC
 		LIMIT $2 OFFSET $3
 	`, sortBy)
 
-	rows, err := ts.db.Query(query, tagID, limit, offset)
+	rows, err := ts.readDB().Query(query, tagID, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -840,7 +726,10 @@ func (ts *TagService) GetTagWorks(c *gin.Context) {
 
 	// Get total count
 	var total int
-	err = ts.db.QueryRow(`SELECT COUNT(*) FROM work_tags WHERE tag_id = $1`, tagID).Scan(&total)
+	err = ts.readDB().QueryRow(`
+		SELECT COUNT(*) FROM work_tags wt
+		JOIN works w ON w.id = wt.work_id
+		WHERE wt.tag_id = $1 AND w.deleted_at IS NULL AND w.status != 'unlisted'`, tagID).Scan(&total)
 	if err != nil {
 		total = 0
 	}
@@ -895,7 +784,7 @@ func (ts *TagService) SearchFandoms(c *gin.Context) {
 	args = append(args, limit, offset)
 
 	// Execute query
-	rows, err := ts.db.Query(baseQuery, args...)
+	rows, err := ts.readDB().Query(baseQuery, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
 		return
@@ -920,7 +809,7 @@ func (ts *TagService) SearchFandoms(c *gin.Context) {
 	var total int
 	countQuery := "SELECT COUNT(*) FROM tags"
 	countQuery += " WHERE " + strings.Join(conditions, " AND ")
-	ts.db.QueryRow(countQuery, args[:len(args)-2]...).Scan(&total)
+	ts.readDB().QueryRow(countQuery, args[:len(args)-2]...).Scan(&total)
 
 	c.JSON(http.StatusOK, gin.H{
 		"fandoms":    tags,
@@ -993,18 +882,6 @@ func (ts *TagService) GetFandom(c *gin.Context) {
 	})
 }
 
-func (ts *TagService) GetFandomTags(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"tags": []string{}})
-}
-
-func (ts *TagService) GetFandomCharacters(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"characters": []string{}})
-}
-
-func (ts *TagService) GetFandomRelationships(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"relationships": []string{}})
-}
-
 func (ts *TagService) SearchCharacters(c *gin.Context) {
 	query := c.Query("q")
 	fandomQuery := c.Query("fandom")
@@ -1448,7 +1325,7 @@ func (ts *TagService) GetTrendingTags(c *gin.Context) {
 	query += fmt.Sprintf(" LIMIT $%d", argIndex)
 	args = append(args, limit)
 
-	rows, err := ts.db.Query(query, args...)
+	rows, err := ts.readDB().Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -1497,11 +1374,11 @@ func (ts *TagService) GetTagUsageStats(c *gin.Context) {
 
 	// Total works using this tag
 	var totalWorks int
-	ts.db.QueryRow(`SELECT COUNT(*) FROM work_tags WHERE tag_id = $1`, tagID).Scan(&totalWorks)
+	ts.readDB().QueryRow(`SELECT COUNT(*) FROM work_tags WHERE tag_id = $1`, tagID).Scan(&totalWorks)
 
 	// Usage by prominence
 	var primaryCount, secondaryCount, microCount, unassignedCount int
-	ts.db.QueryRow(`
+	ts.readDB().QueryRow(`
 		SELECT 
 			COUNT(CASE WHEN prominence = 'primary' THEN 1 END),
 			COUNT(CASE WHEN prominence = 'secondary' THEN 1 END),
@@ -1512,15 +1389,15 @@ func (ts *TagService) GetTagUsageStats(c *gin.Context) {
 
 	// Recent usage (last 30 days)
 	var recentWorks int
-	ts.db.QueryRow(`
+	ts.readDB().QueryRow(`
 		SELECT COUNT(DISTINCT wt.work_id)
 		FROM work_tags wt
 		JOIN works w ON wt.work_id = w.id
-		WHERE wt.tag_id = $1 AND w.created_at >= $2
+		WHERE wt.tag_id = $1 AND w.created_at >= $2 AND w.deleted_at IS NULL AND w.status != 'unlisted'
 	`, tagID, time.Now().AddDate(0, 0, -30)).Scan(&recentWorks)
 
 	// Co-occurrence with other tags (top 10)
-	cotagRows, err := ts.db.Query(`
+	cotagRows, err := ts.readDB().Query(`
 		SELECT t.name, t.type, COUNT(*) as cooccurrence_count
 		FROM work_tags wt1
 		JOIN work_tags wt2 ON wt1.work_id = wt2.work_id
@@ -1709,70 +1586,11 @@ func (ts *TagService) CreateSynonym(c *gin.Context) {
 
 	// Clear relevant caches
 	ts.clearTagCache(canonicalID.String())
+	ts.invalidateTagHierarchy(c.Request.Context())
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Synonym created successfully"})
 }
 
-func (ts *TagService) RequestTagMerge(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Merge requested"})
-}
-
-func (ts *TagService) FollowTag(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Tag followed"})
-}
-
-func (ts *TagService) UnfollowTag(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Tag unfollowed"})
-}
-
-func (ts *TagService) GetFollowedTags(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"followed": []string{}})
-}
-
-func (ts *TagService) ReportTag(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Tag reported"})
-}
-
-func (ts *TagService) GetWranglingQueue(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"queue": []string{}})
-}
-
-func (ts *TagService) GetTagForWrangling(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"tag": nil})
-}
-
-func (ts *TagService) WrangleTag(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Tag wrangled"})
-}
-
-func (ts *TagService) MakeCanonical(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Tag made canonical"})
-}
-
-func (ts *TagService) CreateCanonicalSynonym(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Canonical synonym created"})
-}
-
-func (ts *TagService) AddParentTag(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Parent tag added"})
-}
-
-func (ts *TagService) RemoveParentTag(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Parent tag removed"})
-}
-
-func (ts *TagService) ProcessTagMerge(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Tag merge processed"})
-}
-
-func (ts *TagService) GetTagReports(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"reports": []string{}})
-}
-
-func (ts *TagService) ProcessTagReport(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Report processed"})
-}
-
 func (ts *TagService) AdminListTags(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"tags": []string{}})
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/middleware"
+)
+
+// =============================================================================
+// INTERNAL API
+// Operational endpoints behind the service token, driven by nuclearctl.
+// =============================================================================
+
+// InternalPurgeTagCache drops a tag's cached record and the autocomplete
+// caches that may list it:
+// POST /api/v1/internal/tags/:tag_id/cache/purge
+func (ts *TagService) InternalPurgeTagCache(c *gin.Context) {
+	tagID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	keys := []string{tagCacheKey(tagID.String()), "autocomplete:*"}
+	if middleware.IsDryRun(c) {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "tag_id": tagID, "keys": keys})
+		return
+	}
+
+	ts.clearTagCache(tagID.String())
+	c.JSON(http.StatusOK, gin.H{"tag_id": tagID, "keys": keys, "message": "Tag cache purged"})
+}
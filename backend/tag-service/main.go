@@ -15,6 +15,13 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/audit"
+	"nuclear-ao3/shared/cache"
+	"nuclear-ao3/shared/database"
+	"nuclear-ao3/shared/metrics"
+	"nuclear-ao3/shared/middleware"
+	"nuclear-ao3/shared/schema"
 )
 
 func main() {
@@ -27,6 +34,23 @@ func main() {
 	tagService := NewTagService()
 	defer tagService.Close()
 
+	// `main migrate [up|status|baseline N]` manages the shared schema and
+	// exits; otherwise pending migrations are applied before serving
+	if done, err := schema.Ready(context.Background(), tagService.db, os.Args[1:]); err != nil {
+		log.Fatal("Schema is not ready: ", err)
+	} else if done {
+		return
+	}
+
+	// Keep the autocomplete index, fandom common tags and use counts fresh,
+	// and return lapsed wrangling claims to the queue
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	go tagService.startAutocompleteRefresher(refreshCtx, getEnvDuration("TAG_AUTOCOMPLETE_REFRESH", 10*time.Minute))
+	go tagService.startCooccurrenceRefresher(refreshCtx, getEnvDuration("TAG_COOCCURRENCE_REFRESH", time.Hour))
+	go tagService.startUseCountReconciler(refreshCtx, getEnvDuration("TAG_USE_COUNT_RECONCILE", 6*time.Hour))
+	go tagService.startWranglingClaimSweeper(refreshCtx, getEnvDuration("WRANGLING_CLAIM_SWEEP", 5*time.Minute))
+
 	// Setup router
 	router := setupRouter(tagService)
 
@@ -54,6 +78,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopRefresh()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -80,6 +105,7 @@ func setupRouter(tagService *TagService) *gin.Engine {
 	r.Use(LoggingMiddleware())
 	r.Use(RateLimitMiddleware(tagService.redis))
 	r.Use(SecurityHeadersMiddleware())
+	r.Use(middleware.NewReadOnlyModeFromEnv().Middleware())
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
@@ -113,10 +139,14 @@ func setupRouter(tagService *TagService) *gin.Engine {
 			tags.GET("/debug", func(c *gin.Context) {
 				c.JSON(http.StatusOK, gin.H{"debug": "Tags debug working", "timestamp": time.Now().Unix()})
 			})
-			tags.GET("/:tag_id", tagService.GetTag)                 // GET /api/v1/tags/123
-			tags.GET("/:tag_id/related", tagService.GetRelatedTags) // GET /api/v1/tags/123/related
-			tags.GET("/:tag_id/works", tagService.GetTagWorks)      // GET /api/v1/tags/123/works
-			tags.GET("/autocomplete", tagService.AutocompleteTags)  // GET /api/v1/tags/autocomplete?q=harry
+			tags.GET("/:tag_id", tagService.GetTag)                        // GET /api/v1/tags/123
+			tags.GET("/:tag_id/related", tagService.GetRelatedTags)        // GET /api/v1/tags/123/related
+			tags.GET("/:tag_id/works", tagService.GetTagWorks)             // GET /api/v1/tags/123/works
+			tags.GET("/:tag_id/ancestors", tagService.GetTagAncestors)     // GET /api/v1/tags/123/ancestors?depth=10
+			tags.GET("/:tag_id/descendants", tagService.GetTagDescendants) // GET /api/v1/tags/123/descendants?depth=10
+			tags.GET("/:tag_id/feed", tagService.GetTagFeed)               // GET /api/v1/tags/123/feed?format=rss
+			tags.GET("/autocomplete", tagService.AutocompleteTags)         // GET /api/v1/tags/autocomplete?q=harry&type=character&fandom=123
+			tags.GET("/expand", tagService.ExpandTags)                     // GET /api/v1/tags/expand?name=Harry%20Potter
 		}
 
 		// Fandoms
@@ -171,9 +201,10 @@ func setupRouter(tagService *TagService) *gin.Engine {
 			protected.POST("/tags/merge", tagService.RequestTagMerge)         // POST /api/v1/tags/merge
 
 			// User tag relationships
-			protected.POST("/user/tags/follow", tagService.FollowTag)             // POST /api/v1/user/tags/follow
-			protected.DELETE("/user/tags/follow/:tag_id", tagService.UnfollowTag) // DELETE /api/v1/user/tags/follow/123
-			protected.GET("/user/tags/followed", tagService.GetFollowedTags)      // GET /api/v1/user/tags/followed
+			protected.POST("/user/tags/follow", tagService.FollowTag)              // POST /api/v1/user/tags/follow
+			protected.PUT("/user/tags/follow/:tag_id", tagService.UpdateTagFollow) // PUT /api/v1/user/tags/follow/123
+			protected.DELETE("/user/tags/follow/:tag_id", tagService.UnfollowTag)  // DELETE /api/v1/user/tags/follow/123
+			protected.GET("/user/tags/followed", tagService.GetFollowedTags)       // GET /api/v1/user/tags/followed
 
 			// Tag reports
 			protected.POST("/tags/:tag_id/report", tagService.ReportTag) // POST /api/v1/tags/123/report
@@ -182,16 +213,23 @@ func setupRouter(tagService *TagService) *gin.Engine {
 		// Wrangler endpoints (tag wranglers have special permissions)
 		wrangler := api.Group("/wrangling")
 		wrangler.Use(JWTAuthMiddleware())
-		wrangler.Use(RequireRoleMiddleware("tag_wrangler", "admin"))
+		wrangler.Use(middleware.RequireRoleMiddleware("tag_wrangler"))
+		wrangler.Use(middleware.RequireScopeMiddleware(middleware.ScopeTagsWrangle))
+		wrangler.Use(audit.Middleware(tagService.db, auditService))
 		{
 			wrangler.GET("/queue", tagService.GetWranglingQueue)                           // GET /api/v1/wrangling/queue
+			wrangler.POST("/queue/claim", tagService.ClaimWranglingTags)                   // POST /api/v1/wrangling/queue/claim
+			wrangler.POST("/queue/release", tagService.ReleaseWranglingTags)               // POST /api/v1/wrangling/queue/release
 			wrangler.GET("/tags/:tag_id", tagService.GetTagForWrangling)                   // GET /api/v1/wrangling/tags/123
 			wrangler.POST("/tags/:tag_id/wrangle", tagService.WrangleTag)                  // POST /api/v1/wrangling/tags/123/wrangle
 			wrangler.POST("/tags/:tag_id/canonical", tagService.MakeCanonical)             // POST /api/v1/wrangling/tags/123/canonical
 			wrangler.POST("/tags/:tag_id/synonym", tagService.CreateCanonicalSynonym)      // POST /api/v1/wrangling/tags/123/synonym
 			wrangler.POST("/tags/:tag_id/parent", tagService.AddParentTag)                 // POST /api/v1/wrangling/tags/123/parent
 			wrangler.DELETE("/tags/:tag_id/parent/:parent_id", tagService.RemoveParentTag) // DELETE /api/v1/wrangling/tags/123/parent/456
+			wrangler.GET("/merge", tagService.ListTagMergeRequests)                        // GET /api/v1/wrangling/merge?status=pending
 			wrangler.PUT("/merge/:merge_id", tagService.ProcessTagMerge)                   // PUT /api/v1/wrangling/merge/123
+			wrangler.GET("/log", tagService.GetWranglingLog)                               // GET /api/v1/wrangling/log?tag_id=123
+			wrangler.GET("/merge/:tag_id/preview", tagService.PreviewTagMerge)             // GET /api/v1/wrangling/merge/123/preview?into=456
 			wrangler.GET("/reports", tagService.GetTagReports)                             // GET /api/v1/wrangling/reports
 			wrangler.PUT("/reports/:report_id", tagService.ProcessTagReport)               // PUT /api/v1/wrangling/reports/123
 		}
@@ -199,7 +237,9 @@ func setupRouter(tagService *TagService) *gin.Engine {
 		// Admin endpoints
 		admin := api.Group("/admin")
 		admin.Use(JWTAuthMiddleware())
-		admin.Use(RequireRoleMiddleware("admin"))
+		admin.Use(middleware.RequireRoleMiddleware("admin"))
+		admin.Use(middleware.RequireScopeMiddleware(middleware.ScopeAdmin))
+		admin.Use(audit.Middleware(tagService.db, auditService))
 		{
 			admin.GET("/tags", tagService.AdminListTags)                        // GET /api/v1/admin/tags
 			admin.DELETE("/tags/:tag_id", tagService.AdminDeleteTag)            // DELETE /api/v1/admin/tags/123
@@ -210,15 +250,30 @@ func setupRouter(tagService *TagService) *gin.Engine {
 			admin.DELETE("/wranglers/:user_id", tagService.AdminRemoveWrangler) // DELETE /api/v1/admin/wranglers/123
 			admin.GET("/statistics", tagService.AdminGetTagStatistics)          // GET /api/v1/admin/statistics
 		}
+
+		// Internal operational endpoints (service token, used by nuclearctl)
+		internal := api.Group("/internal")
+		internal.Use(middleware.ServiceTokenMiddleware())
+		{
+			internal.POST("/tags/:tag_id/cache/purge", tagService.InternalPurgeTagCache)          // POST /api/v1/internal/tags/123/cache/purge
+			internal.POST("/tags/autocomplete/rebuild", tagService.InternalRebuildAutocomplete)   // POST /api/v1/internal/tags/autocomplete/rebuild
+			internal.POST("/tags/suggestions", tagService.InternalSuggestCanonicalTags)           // POST /api/v1/internal/tags/suggestions
+			internal.POST("/tags/use-counts/reconcile", tagService.InternalReconcileTagUseCounts) // POST /api/v1/internal/tags/use-counts/reconcile
+		}
 	}
 
 	return r
 }
 
+// auditService names this service in the audit log
+const auditService = "tag-service"
+
 // TagService holds all dependencies for tag management
 type TagService struct {
 	db    *sql.DB
 	redis *redis.Client
+	tags  *cache.Cache      // tag records by ID, also held in process
+	reads *database.Replica // routes browsing and stats reads to DATABASE_REPLICA_URL
 }
 
 func NewTagService() *TagService {
@@ -234,10 +289,14 @@ func NewTagService() *TagService {
 		log.Fatal("Failed to ping database:", err)
 	}
 
-	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
+	// Set connection pool settings (DB_MAX_OPEN_CONNS etc. override)
+	pool := database.PoolConfig{
+		MaxOpenConns:    25,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: time.Hour,
+	}
+	database.PoolConfigFromEnv(pool).Apply(db)
+	metrics.InstrumentDBPool("tag-service", db)
 
 	// Redis connection
 	redisURL := getEnv("REDIS_URL", "localhost:6379")
@@ -258,15 +317,32 @@ func NewTagService() *TagService {
 		log.Fatal("Failed to connect to Redis:", err)
 	}
 
+	tags := cache.NewCache(rdb, "tag-service")
+	tags.EnableLocal(context.Background(), cache.LocalOptionsFromEnv())
+
 	log.Println("Tag service initialized successfully")
 
 	return &TagService{
 		db:    db,
 		redis: rdb,
+		tags:  tags,
+		reads: database.OpenReplica(context.Background(), "tag-service", db, pool),
 	}
 }
 
+// readDB is the database for browsing and stats reads: the read replica
+// while it is usable, and the primary otherwise
+func (ts *TagService) readDB() *sql.DB {
+	if ts.reads == nil {
+		return ts.db
+	}
+	return ts.reads.Reader()
+}
+
 func (ts *TagService) Close() {
+	if ts.reads != nil {
+		ts.reads.Close()
+	}
 	if ts.db != nil {
 		ts.db.Close()
 	}
@@ -282,6 +358,13 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
 // Middleware functions (simplified versions)
 
 func CORSMiddleware() gin.HandlerFunc {
@@ -332,16 +415,8 @@ func LoggingMiddleware() gin.HandlerFunc {
 	return gin.Logger()
 }
 
+// JWTAuthMiddleware validates the bearer token with auth-service, which
+// also reports the user's roles and scopes for the wrangler and admin routes
 func JWTAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// JWT validation - would integrate with auth service
-		c.Next()
-	}
-}
-
-func RequireRoleMiddleware(roles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Role validation - would integrate with auth service
-		c.Next()
-	}
+	return middleware.AuthServiceMiddleware(getEnv("AUTH_SERVICE_URL", "http://localhost:8081"), nil)
 }
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// TAG MERGE PREVIEW
+// Impact analysis for wranglers before a merge; never writes anything
+// =============================================================================
+
+// mergeOverlapSampleSize caps how many overlapping works a preview lists
+const mergeOverlapSampleSize = 20
+
+// MergePreviewTag is the slice of a tag a merge preview reports on
+type MergePreviewTag struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	Type          string    `json:"type"`
+	IsCanonical   bool      `json:"is_canonical"`
+	CanonicalName *string   `json:"canonical_name,omitempty"`
+	UseCount      int       `json:"use_count"`
+}
+
+// MergePreviewWork is a work tagged with both sides of a merge
+type MergePreviewWork struct {
+	ID    uuid.UUID `json:"id"`
+	Title string    `json:"title"`
+}
+
+// SynonymChain is a synonym-of-a-synonym path the merge would leave behind
+type SynonymChain struct {
+	Tags   []string `json:"tags"`
+	Reason string   `json:"reason"`
+}
+
+// HierarchyConflict is a reason the merged tag would sit badly in the tree
+type HierarchyConflict struct {
+	Type        string     `json:"type"`
+	Description string     `json:"description"`
+	TagID       *uuid.UUID `json:"tag_id,omitempty"`
+}
+
+// mergeHierarchy is what the preview knows about where the two tags sit
+// relative to each other
+type mergeHierarchy struct {
+	TargetIsAncestor   bool
+	TargetIsDescendant bool
+}
+
+// PreviewTagMerge reports what merging a tag into another would change:
+// GET /api/v1/wrangling/merge/:tag_id/preview?into=<target tag id>
+func (ts *TagService) PreviewTagMerge(c *gin.Context) {
+	sourceID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+	targetID, err := uuid.Parse(c.Query("into"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "into must be a valid tag ID"})
+		return
+	}
+	if sourceID == targetID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a tag into itself"})
+		return
+	}
+
+	// One read-only snapshot keeps the counts consistent with each other
+	tx, err := ts.db.BeginTx(c.Request.Context(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	source, err := loadMergePreviewTag(tx, sourceID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	target, err := loadMergePreviewTag(tx, targetID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target tag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var affected, targetWorks, overlap int
+	err = tx.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE tag_id = $1),
+			COUNT(*) FILTER (WHERE tag_id = $2),
+			COUNT(*) FILTER (WHERE tag_id = $1 AND work_id IN (SELECT work_id FROM work_tags WHERE tag_id = $2))
+		FROM work_tags
+		WHERE tag_id IN ($1, $2)
+	`, sourceID, targetID).Scan(&affected, &targetWorks, &overlap)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count affected works"})
+		return
+	}
+
+	overlapping, err := overlappingMergeWorks(tx, sourceID, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load overlapping works"})
+		return
+	}
+
+	sourceSynonyms, err := tagSynonymNames(tx, source)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load synonyms"})
+		return
+	}
+
+	hierarchy, err := tagHierarchyBetween(tx, sourceID, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check tag hierarchy"})
+		return
+	}
+
+	inheritedParents, err := inheritedMergeParents(tx, sourceID, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load parent tags"})
+		return
+	}
+
+	conflicts := mergeHierarchyConflicts(source, target, hierarchy)
+
+	c.JSON(http.StatusOK, gin.H{
+		"source":         source,
+		"target":         target,
+		"affected_works": affected,
+		"overlapping_works": gin.H{
+			"count": overlap,
+			"works": overlapping,
+		},
+		"resulting_work_count": targetWorks + affected - overlap,
+		"synonym_chains":       mergeSynonymChains(source, target, sourceSynonyms),
+		"hierarchy_conflicts":  conflicts,
+		"inherited_parents":    inheritedParents,
+		"has_conflicts":        len(conflicts) > 0,
+	})
+}
+
+// mergeSynonymChains lists the synonym-of-a-synonym paths merging source
+// into target would create: source's own synonyms end up two hops from a
+// canonical, and a non-canonical target adds another hop on top.
+func mergeSynonymChains(source, target MergePreviewTag, sourceSynonyms []string) []SynonymChain {
+	chains := []SynonymChain{}
+	tail := []string{source.Name, target.Name}
+	if target.CanonicalName != nil {
+		tail = append(tail, *target.CanonicalName)
+		chains = append(chains, SynonymChain{
+			Tags:   tail,
+			Reason: fmt.Sprintf("%s is itself a synonym of %s", target.Name, *target.CanonicalName),
+		})
+	}
+	for _, name := range sourceSynonyms {
+		chains = append(chains, SynonymChain{
+			Tags:   append([]string{name}, tail...),
+			Reason: fmt.Sprintf("%s is a synonym of %s and would not be repointed", name, source.Name),
+		})
+	}
+	return chains
+}
+
+// mergeHierarchyConflicts finds the problems that would leave the merged
+// tag in an invalid place in the tree
+func mergeHierarchyConflicts(source, target MergePreviewTag, h mergeHierarchy) []HierarchyConflict {
+	conflicts := []HierarchyConflict{}
+	if source.Type != target.Type {
+		conflicts = append(conflicts, HierarchyConflict{
+			Type:        "type_mismatch",
+			Description: fmt.Sprintf("%s is a %s tag but %s is a %s tag", source.Name, source.Type, target.Name, target.Type),
+		})
+	}
+	if h.TargetIsAncestor {
+		conflicts = append(conflicts, HierarchyConflict{
+			Type:        "cycle",
+			Description: fmt.Sprintf("%s is a parent of %s, so the merged tag would be its own parent", target.Name, source.Name),
+			TagID:       &target.ID,
+		})
+	}
+	if h.TargetIsDescendant {
+		conflicts = append(conflicts, HierarchyConflict{
+			Type:        "cycle",
+			Description: fmt.Sprintf("%s is a child of %s, so the merged tag would be its own child", target.Name, source.Name),
+			TagID:       &target.ID,
+		})
+	}
+	if source.IsCanonical && !target.IsCanonical {
+		conflicts = append(conflicts, HierarchyConflict{
+			Type:        "canonical_into_synonym",
+			Description: fmt.Sprintf("%s is canonical but %s is not", source.Name, target.Name),
+			TagID:       &target.ID,
+		})
+	}
+	return conflicts
+}
+
+// tagHierarchyBetween reports whether target is an ancestor or descendant
+// of source through parent_child relationships
+func tagHierarchyBetween(tx *sql.Tx, sourceID, targetID uuid.UUID) (mergeHierarchy, error) {
+	var h mergeHierarchy
+	err := tx.QueryRow(`
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_tag_id AS id FROM tag_relationships
+			WHERE child_tag_id = $1 AND relationship_type = 'parent_child'
+			UNION
+			SELECT tr.parent_tag_id FROM tag_relationships tr
+			JOIN ancestors a ON tr.child_tag_id = a.id
+			WHERE tr.relationship_type = 'parent_child'
+		), descendants AS (
+			SELECT child_tag_id AS id FROM tag_relationships
+			WHERE parent_tag_id = $1 AND relationship_type = 'parent_child'
+			UNION
+			SELECT tr.child_tag_id FROM tag_relationships tr
+			JOIN descendants d ON tr.parent_tag_id = d.id
+			WHERE tr.relationship_type = 'parent_child'
+		)
+		SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $2),
+			EXISTS(SELECT 1 FROM descendants WHERE id = $2)
+	`, sourceID, targetID).Scan(&h.TargetIsAncestor, &h.TargetIsDescendant)
+	return h, err
+}
+
+func loadMergePreviewTag(tx *sql.Tx, tagID uuid.UUID) (MergePreviewTag, error) {
+	var tag MergePreviewTag
+	err := tx.QueryRow(`
+		SELECT id, name, type, COALESCE(is_canonical, false), canonical_name, COALESCE(use_count, 0)
+		FROM tags WHERE id = $1
+	`, tagID).Scan(&tag.ID, &tag.Name, &tag.Type, &tag.IsCanonical, &tag.CanonicalName, &tag.UseCount)
+	return tag, err
+}
+
+func overlappingMergeWorks(tx *sql.Tx, sourceID, targetID uuid.UUID) ([]MergePreviewWork, error) {
+	rows, err := tx.Query(`
+		SELECT w.id, w.title
+		FROM works w
+		JOIN work_tags a ON a.work_id = w.id AND a.tag_id = $1
+		JOIN work_tags b ON b.work_id = w.id AND b.tag_id = $2
+		ORDER BY w.updated_at DESC
+		LIMIT $3
+	`, sourceID, targetID, mergeOverlapSampleSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	works := []MergePreviewWork{}
+	for rows.Next() {
+		var work MergePreviewWork
+		if err := rows.Scan(&work.ID, &work.Title); err != nil {
+			return nil, err
+		}
+		works = append(works, work)
+	}
+	return works, rows.Err()
+}
+
+// tagSynonymNames returns the tags that currently point at tag as their
+// canonical, through either canonical_name or a synonym relationship
+func tagSynonymNames(tx *sql.Tx, tag MergePreviewTag) ([]string, error) {
+	rows, err := tx.Query(`
+		SELECT name FROM tags WHERE canonical_name = $2
+		UNION
+		SELECT t.name FROM tag_relationships tr
+		JOIN tags t ON t.id = tr.child_tag_id
+		WHERE tr.parent_tag_id = $1 AND tr.relationship_type = 'synonym'
+		ORDER BY name
+	`, tag.ID, tag.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// inheritedMergeParents returns source's parents that target would gain
+func inheritedMergeParents(tx *sql.Tx, sourceID, targetID uuid.UUID) ([]MergePreviewTag, error) {
+	rows, err := tx.Query(`
+		SELECT t.id, t.name, t.type, COALESCE(t.is_canonical, false), t.canonical_name, COALESCE(t.use_count, 0)
+		FROM tag_relationships tr
+		JOIN tags t ON t.id = tr.parent_tag_id
+		WHERE tr.child_tag_id = $1 AND tr.relationship_type = 'parent_child' AND tr.parent_tag_id != $2
+			AND NOT EXISTS (
+				SELECT 1 FROM tag_relationships existing
+				WHERE existing.child_tag_id = $2 AND existing.parent_tag_id = tr.parent_tag_id
+			)
+		ORDER BY t.name
+	`, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parents := []MergePreviewTag{}
+	for rows.Next() {
+		var tag MergePreviewTag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Type, &tag.IsCanonical, &tag.CanonicalName, &tag.UseCount); err != nil {
+			return nil, err
+		}
+		parents = append(parents, tag)
+	}
+	return parents, rows.Err()
+}
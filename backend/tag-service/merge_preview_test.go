@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeSynonymChains(t *testing.T) {
+	source := MergePreviewTag{ID: uuid.New(), Name: "Harry/Draco", Type: "relationship"}
+	canonical := "Draco Malfoy/Harry Potter"
+
+	t.Run("canonical target with no synonyms", func(t *testing.T) {
+		target := MergePreviewTag{ID: uuid.New(), Name: canonical, Type: "relationship", IsCanonical: true}
+		assert.Empty(t, mergeSynonymChains(source, target, nil))
+	})
+
+	t.Run("source synonyms become chained", func(t *testing.T) {
+		target := MergePreviewTag{ID: uuid.New(), Name: canonical, Type: "relationship", IsCanonical: true}
+		chains := mergeSynonymChains(source, target, []string{"Drarry"})
+		assert.Len(t, chains, 1)
+		assert.Equal(t, []string{"Drarry", "Harry/Draco", canonical}, chains[0].Tags)
+	})
+
+	t.Run("non-canonical target adds a hop", func(t *testing.T) {
+		target := MergePreviewTag{ID: uuid.New(), Name: "Draco/Harry", Type: "relationship", CanonicalName: &canonical}
+		chains := mergeSynonymChains(source, target, []string{"Drarry"})
+		assert.Len(t, chains, 2)
+		assert.Equal(t, []string{"Harry/Draco", "Draco/Harry", canonical}, chains[0].Tags)
+		assert.Equal(t, []string{"Drarry", "Harry/Draco", "Draco/Harry", canonical}, chains[1].Tags)
+	})
+}
+
+func TestMergeHierarchyConflicts(t *testing.T) {
+	source := MergePreviewTag{ID: uuid.New(), Name: "Hermione", Type: "character", IsCanonical: true}
+	target := MergePreviewTag{ID: uuid.New(), Name: "Hermione Granger", Type: "character", IsCanonical: true}
+
+	assert.Empty(t, mergeHierarchyConflicts(source, target, mergeHierarchy{}))
+
+	conflicts := mergeHierarchyConflicts(source, target, mergeHierarchy{TargetIsAncestor: true})
+	assert.Len(t, conflicts, 1)
+	assert.Equal(t, "cycle", conflicts[0].Type)
+
+	fandom := MergePreviewTag{ID: uuid.New(), Name: "Harry Potter", Type: "fandom"}
+	conflicts = mergeHierarchyConflicts(source, fandom, mergeHierarchy{})
+	types := []string{}
+	for _, conflict := range conflicts {
+		types = append(types, conflict.Type)
+	}
+	assert.Equal(t, []string{"type_mismatch", "canonical_into_synonym"}, types)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// =============================================================================
+// TAG EXPANSION
+// Resolves tag names to every name a search for them should match: the
+// canonical tag, its descendants through parent_child relationships, and
+// the synonyms of all of those
+// =============================================================================
+
+const (
+	// maxExpandNames caps how many names one request may expand
+	maxExpandNames = 50
+	// maxExpansionDepth bounds the walk down the tag hierarchy
+	maxExpansionDepth = 10
+)
+
+// ExpandTags expands tag names for search:
+// GET /api/v1/tags/expand?name=Harry%20Potter&name=Hermione%20Granger
+// Each requested name maps to its expansion; unknown names map to themselves.
+func (ts *TagService) ExpandTags(c *gin.Context) {
+	requested := map[string]string{} // lowercased name -> name as asked for
+	lowered := []string{}
+	for _, name := range c.QueryArray("name") {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" || requested[key] != "" {
+			continue
+		}
+		requested[key] = name
+		lowered = append(lowered, key)
+	}
+	if len(lowered) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one name is required"})
+		return
+	}
+	if len(lowered) > maxExpandNames {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many names", "max": maxExpandNames})
+		return
+	}
+
+	rows, err := ts.db.QueryContext(c.Request.Context(), `
+		WITH RECURSIVE requested AS (
+			SELECT LOWER(t.name) AS requested, COALESCE(canon.id, t.id) AS root_id
+			FROM tags t
+			LEFT JOIN tags canon ON t.canonical_name IS NOT NULL AND canon.name = t.canonical_name
+			WHERE LOWER(t.name) = ANY($1)
+		),
+		tree AS (
+			SELECT requested, root_id AS tag_id, ARRAY[root_id] AS path FROM requested
+			UNION ALL
+			SELECT tree.requested, tr.child_tag_id, tree.path || tr.child_tag_id
+			FROM tree
+			JOIN tag_relationships tr ON tr.parent_tag_id = tree.tag_id AND tr.relationship_type = 'parent_child'
+			WHERE NOT tr.child_tag_id = ANY(tree.path) AND array_length(tree.path, 1) < $2
+		)
+		SELECT DISTINCT tree.requested, expanded.name
+		FROM tree
+		JOIN tags base ON base.id = tree.tag_id
+		CROSS JOIN LATERAL (
+			SELECT base.name::text AS name
+			UNION
+			SELECT s.name::text FROM tags s WHERE s.canonical_name = base.name
+			UNION
+			SELECT st.name::text FROM tag_relationships sr
+			JOIN tags st ON st.id = sr.child_tag_id
+			WHERE sr.parent_tag_id = base.id AND sr.relationship_type = 'synonym'
+		) expanded
+		ORDER BY tree.requested, expanded.name`,
+		pq.Array(lowered), maxExpansionDepth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to expand tags"})
+		return
+	}
+	defer rows.Close()
+
+	expansions := map[string][]string{}
+	for rows.Next() {
+		var key, name string
+		if err := rows.Scan(&key, &name); err != nil {
+			continue
+		}
+		original := requested[key]
+		expansions[original] = append(expansions[original], name)
+	}
+
+	// Names the archive doesn't know still match themselves
+	for _, key := range lowered {
+		original := requested[key]
+		if len(expansions[original]) == 0 {
+			expansions[original] = []string{original}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"expansions": expansions})
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+)
+
+// =============================================================================
+// TAG FEEDS
+// A canonical tag's most recently updated works, as JSON for its landing
+// page or as RSS or Atom for feed readers. The feed covers the tag's
+// synonyms and its subtags (and theirs) the same way a search for the tag
+// does, and lists only works anyone may read. Responses carry an ETag so
+// readers polling an unchanged feed get 304 Not Modified.
+// =============================================================================
+
+const (
+	defaultTagFeedLimit = 20
+	maxTagFeedLimit     = 50
+	tagFeedMaxAge       = 5 * time.Minute
+)
+
+// Feed formats
+const (
+	feedFormatJSON = "json"
+	feedFormatRSS  = "rss"
+	feedFormatAtom = "atom"
+)
+
+// TagFeedWork is a work in a tag feed
+type TagFeedWork struct {
+	ID          uuid.UUID `json:"id"`
+	Title       string    `json:"title"`
+	Summary     string    `json:"summary"`
+	Author      string    `json:"author"`
+	Rating      string    `json:"rating"`
+	WordCount   int       `json:"word_count"`
+	Chapters    int       `json:"chapter_count"`
+	IsComplete  bool      `json:"is_complete"`
+	PublishedAt time.Time `json:"published_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	URL         string    `json:"url"`
+}
+
+// tagFeedFormat picks the feed format from the format query parameter,
+// falling back to the Accept header and then JSON
+func tagFeedFormat(param, accept string) string {
+	switch strings.ToLower(param) {
+	case feedFormatRSS, feedFormatAtom, feedFormatJSON:
+		return strings.ToLower(param)
+	}
+	switch {
+	case strings.Contains(accept, "application/atom+xml"):
+		return feedFormatAtom
+	case strings.Contains(accept, "application/rss+xml"):
+		return feedFormatRSS
+	}
+	return feedFormatJSON
+}
+
+// tagFeedETag identifies a feed's content, changing whenever a work in it
+// is added, removed or updated
+func tagFeedETag(tagID uuid.UUID, format string, works []TagFeedWork) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s:%s", tagID, format)
+	for _, w := range works {
+		fmt.Fprintf(h, ":%s@%d", w.ID, w.UpdatedAt.UnixNano())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header names the ETag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// feedSelfURL is the address the feed was requested at, as seen through the
+// gateway
+func feedSelfURL(c *gin.Context) string {
+	scheme := c.GetHeader("X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = "http"
+	}
+	host := c.GetHeader("X-Forwarded-Host")
+	if host == "" {
+		host = c.Request.Host
+	}
+	return scheme + "://" + host + c.Request.URL.RequestURI()
+}
+
+// loginGatedRatings lists every spelling of the ratings that need a login
+// to read, which stay out of public feeds
+func (ts *TagService) loginGatedRatings(ctx context.Context) ([]string, error) {
+	rows, err := ts.db.QueryContext(ctx, "SELECT rating FROM content_rating_policy WHERE login_required")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spellings := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		spellings = append(spellings, models.RatingSpellings(key)...)
+	}
+	return spellings, rows.Err()
+}
+
+// tagFeedWorks loads the most recently updated public works tagged with
+// the tag, its synonyms or any of its subtags
+func (ts *TagService) tagFeedWorks(ctx context.Context, tagID uuid.UUID, limit int) ([]TagFeedWork, error) {
+	ids := []string{tagID.String()}
+	tree, err := ts.walkTagHierarchy(ctx, tagID, hierarchyDescendants, defaultHierarchyDepth)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tree.Tags {
+		ids = append(ids, tag.ID.String())
+	}
+
+	gated, err := ts.loginGatedRatings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := ts.db.QueryContext(ctx, `
+		WITH feed_tags AS (
+			SELECT id FROM tags WHERE id = ANY($1::uuid[])
+			UNION
+			SELECT s.id FROM tags base
+			JOIN tags s ON s.canonical_name = base.name
+			WHERE base.id = ANY($1::uuid[])
+			UNION
+			SELECT tr.child_tag_id FROM tag_relationships tr
+			WHERE tr.parent_tag_id = ANY($1::uuid[]) AND tr.relationship_type = 'synonym'
+		)
+		SELECT w.id, w.title, COALESCE(w.summary, ''),
+			CASE WHEN COALESCE(w.is_anonymous, false) OR COALESCE(w.in_anon_collection, false)
+				THEN 'Anonymous' ELSE COALESCE(u.username, '') END,
+			w.rating, w.word_count, w.chapter_count, COALESCE(w.is_complete, false),
+			w.published_at, w.updated_at
+		FROM works w
+		LEFT JOIN users u ON u.id = w.user_id
+		WHERE EXISTS (SELECT 1 FROM work_tags wt WHERE wt.work_id = w.id AND wt.tag_id IN (SELECT id FROM feed_tags))
+			AND w.is_draft = false AND w.published_at IS NOT NULL AND w.restricted = false
+			AND w.deleted_at IS NULL AND w.status != 'unlisted'
+			AND COALESCE(w.in_unrevealed_collection, false) = false
+			AND LOWER(COALESCE(w.rating, '')) <> ALL($2::text[])
+		ORDER BY w.updated_at DESC, w.id
+		LIMIT $3`, pq.Array(ids), pq.Array(gated), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	base := strings.TrimSuffix(getEnv("FRONTEND_URL", "http://localhost:3000"), "/")
+	works := []TagFeedWork{}
+	for rows.Next() {
+		var w TagFeedWork
+		if err := rows.Scan(&w.ID, &w.Title, &w.Summary, &w.Author, &w.Rating, &w.WordCount,
+			&w.Chapters, &w.IsComplete, &w.PublishedAt, &w.UpdatedAt); err != nil {
+			return nil, err
+		}
+		w.URL = base + "/works/" + w.ID.String()
+		works = append(works, w)
+	}
+	return works, rows.Err()
+}
+
+// GetTagFeed lists a canonical tag's recently updated works
+// GET /api/v1/tags/:tag_id/feed?format=json|rss|atom&limit=20
+// A synonym's feed is its canonical tag's.
+func (ts *TagService) GetTagFeed(c *gin.Context) {
+	tagID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	var tag struct {
+		ID          uuid.UUID
+		Name        string
+		Type        string
+		IsCanonical bool
+	}
+	err = ts.db.QueryRowContext(ctx, `
+		SELECT COALESCE(canon.id, t.id), COALESCE(canon.name, t.name), COALESCE(canon.type, t.type),
+			COALESCE(canon.is_canonical, t.is_canonical, false)
+		FROM tags t
+		LEFT JOIN tags canon ON t.canonical_name IS NOT NULL AND canon.name = t.canonical_name
+		WHERE t.id = $1`, tagID).Scan(&tag.ID, &tag.Name, &tag.Type, &tag.IsCanonical)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !tag.IsCanonical {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feeds are only available for canonical tags"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultTagFeedLimit)))
+	if err != nil || limit <= 0 {
+		limit = defaultTagFeedLimit
+	}
+	if limit > maxTagFeedLimit {
+		limit = maxTagFeedLimit
+	}
+
+	works, err := ts.tagFeedWorks(ctx, tag.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tag feed"})
+		return
+	}
+
+	format := tagFeedFormat(c.Query("format"), c.GetHeader("Accept"))
+	etag := tagFeedETag(tag.ID, format, works)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(tagFeedMaxAge.Seconds())))
+	c.Header("Vary", "Accept")
+	if len(works) > 0 {
+		c.Header("Last-Modified", works[0].UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	base := strings.TrimSuffix(getEnv("FRONTEND_URL", "http://localhost:3000"), "/")
+	page := base + "/works?tag=" + url.QueryEscape(tag.Name)
+	self := feedSelfURL(c)
+	title := tag.Name + " - recent works"
+
+	switch format {
+	case feedFormatRSS:
+		c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", renderRSS(title, page, works))
+	case feedFormatAtom:
+		c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", renderAtom(tag.ID, title, page, self, works))
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"tag":   gin.H{"id": tag.ID, "name": tag.Name, "type": tag.Type},
+			"works": works,
+			"limit": limit,
+		})
+	}
+}
+
+// =============================================================================
+// FEED DOCUMENTS
+// =============================================================================
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Author      string  `xml:"dc:creator,omitempty"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Link      atomLink   `xml:"link"`
+	Author    atomAuthor `xml:"author"`
+	Summary   string     `xml:"summary"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// feedSummary describes a work in a feed entry
+func feedSummary(w TagFeedWork) string {
+	status := "In progress"
+	if w.IsComplete {
+		status = "Complete"
+	}
+	details := fmt.Sprintf("%s · %d words · %d chapters · %s", w.Rating, w.WordCount, w.Chapters, status)
+	if w.Summary == "" {
+		return details
+	}
+	return w.Summary + "\n\n" + details
+}
+
+// renderRSS writes works as an RSS 2.0 document
+func renderRSS(title, link string, works []TagFeedWork) []byte {
+	channel := rssChannel{Title: title, Link: link, Description: title, Items: []rssItem{}}
+	if len(works) > 0 {
+		channel.LastBuildDate = works[0].UpdatedAt.UTC().Format(time.RFC1123Z)
+	}
+	for _, w := range works {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       w.Title,
+			Link:        w.URL,
+			GUID:        rssGUID{IsPermaLink: true, Value: w.URL},
+			Author:      w.Author,
+			Description: feedSummary(w),
+			PubDate:     w.UpdatedAt.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	out, _ := xml.MarshalIndent(rssDocument{Version: "2.0", Channel: channel}, "", "  ")
+	// dc:creator needs its namespace declared on the root element
+	out = []byte(strings.Replace(string(out), `<rss version="2.0">`,
+		`<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">`, 1))
+	return append([]byte(xml.Header), out...)
+}
+
+// renderAtom writes works as an Atom 1.0 document
+func renderAtom(tagID uuid.UUID, title, link, self string, works []TagFeedWork) []byte {
+	feed := atomFeed{
+		ID:      "urn:uuid:" + tagID.String(),
+		Title:   title,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Rel: "alternate", Href: link}, {Rel: "self", Href: self}},
+		Entries: []atomEntry{},
+	}
+	if len(works) > 0 {
+		feed.Updated = works[0].UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	for _, w := range works {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        "urn:uuid:" + w.ID.String(),
+			Title:     w.Title,
+			Link:      atomLink{Rel: "alternate", Href: w.URL},
+			Author:    atomAuthor{Name: w.Author},
+			Summary:   feedSummary(w),
+			Published: w.PublishedAt.UTC().Format(time.RFC3339),
+			Updated:   w.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	out, _ := xml.MarshalIndent(feed, "", "  ")
+	return append([]byte(xml.Header), out...)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagFeedFormat(t *testing.T) {
+	assert.Equal(t, feedFormatJSON, tagFeedFormat("", ""))
+	assert.Equal(t, feedFormatRSS, tagFeedFormat("RSS", "application/json"))
+	assert.Equal(t, feedFormatAtom, tagFeedFormat("", "application/atom+xml, */*"))
+	assert.Equal(t, feedFormatRSS, tagFeedFormat("", "application/rss+xml"))
+	assert.Equal(t, feedFormatJSON, tagFeedFormat("yaml", ""))
+}
+
+func TestTagFeedETag(t *testing.T) {
+	tagID := uuid.New()
+	work := TagFeedWork{ID: uuid.New(), UpdatedAt: time.Now()}
+	etag := tagFeedETag(tagID, feedFormatRSS, []TagFeedWork{work})
+
+	assert.Equal(t, etag, tagFeedETag(tagID, feedFormatRSS, []TagFeedWork{work}))
+	assert.NotEqual(t, etag, tagFeedETag(tagID, feedFormatAtom, []TagFeedWork{work}), "formats differ in content")
+
+	work.UpdatedAt = work.UpdatedAt.Add(time.Minute)
+	assert.NotEqual(t, etag, tagFeedETag(tagID, feedFormatRSS, []TagFeedWork{work}), "an updated work changes the feed")
+
+	assert.True(t, etagMatches(etag, etag))
+	assert.True(t, etagMatches(`"other", W/`+etag, etag))
+	assert.True(t, etagMatches("*", etag))
+	assert.False(t, etagMatches("", etag))
+	assert.False(t, etagMatches(`"other"`, etag))
+}
+
+func TestRenderTagFeeds(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	works := []TagFeedWork{{
+		ID:          uuid.New(),
+		Title:       "Coffee & <Consequences>",
+		Summary:     "A meet-cute.",
+		Author:      "Anonymous",
+		Rating:      "General Audiences",
+		WordCount:   1200,
+		Chapters:    1,
+		IsComplete:  true,
+		PublishedAt: updated.Add(-time.Hour),
+		UpdatedAt:   updated,
+		URL:         "http://localhost:3000/works/1",
+	}}
+
+	rss := renderRSS("AU - recent works", "http://localhost:3000/works?tag=AU", works)
+	assert.True(t, strings.HasPrefix(string(rss), xml.Header))
+	assert.Contains(t, string(rss), `xmlns:dc="http://purl.org/dc/elements/1.1/"`)
+	var doc rssDocument
+	require.NoError(t, xml.Unmarshal(rss, &doc))
+	require.Len(t, doc.Channel.Items, 1)
+	assert.Equal(t, "Coffee & <Consequences>", doc.Channel.Items[0].Title)
+	assert.Equal(t, updated.Format(time.RFC1123Z), doc.Channel.Items[0].PubDate)
+	assert.Contains(t, doc.Channel.Items[0].Description, "1200 words")
+
+	atom := renderAtom(uuid.New(), "AU - recent works", "http://localhost:3000/works?tag=AU", "http://localhost:8080/api/v1/tags/1/feed?format=atom", works)
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(atom, &feed))
+	require.Len(t, feed.Entries, 1)
+	assert.Equal(t, "2024-03-01T12:00:00Z", feed.Updated)
+	assert.Equal(t, "Anonymous", feed.Entries[0].Author.Name)
+	assert.Equal(t, "2024-03-01T11:00:00Z", feed.Entries[0].Published)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"nuclear-ao3/shared/models"
+)
+
+// =============================================================================
+// FOLLOWED TAGS
+// A followed tag is a 'tag' subscription, so the notification pipeline sees
+// it like any other subscription, frequency included
+// =============================================================================
+
+// tagFollowFrequencies are the frequencies a followed tag can be set to
+var tagFollowFrequencies = map[models.NotificationFrequency]bool{
+	models.FrequencyImmediate: true,
+	models.FrequencyDaily:     true,
+	models.FrequencyWeekly:    true,
+	models.FrequencyMuted:     true,
+}
+
+// tagFollowEvents are the events a followed tag notifies about
+var tagFollowEvents = []string{string(models.EventNewWork)}
+
+// FollowedTag is one of a user's followed tags
+type FollowedTag struct {
+	TagID      uuid.UUID                    `json:"tag_id"`
+	Name       string                       `json:"name"`
+	Type       string                       `json:"type"`
+	Frequency  models.NotificationFrequency `json:"frequency"`
+	FollowedAt time.Time                    `json:"followed_at"`
+}
+
+// requestUserID reads the authenticated user's id, answering 401 without one
+func requestUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, _ := c.Get("user_id")
+	uid, ok := userID.(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return uuid.Nil, false
+	}
+	parsed, err := uuid.Parse(uid)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		return uuid.Nil, false
+	}
+	return parsed, true
+}
+
+// FollowTag follows a tag, or changes the frequency of a tag already
+// followed: POST /api/v1/user/tags/follow {"tag_id": ..., "frequency": "daily"}.
+// Frequency defaults to immediate for a new follow and is left alone when
+// re-following without one.
+func (ts *TagService) FollowTag(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		TagID     uuid.UUID                    `json:"tag_id" binding:"required"`
+		Frequency models.NotificationFrequency `json:"frequency"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	if req.Frequency != "" && !tagFollowFrequencies[req.Frequency] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Frequency must be immediate, daily, weekly or muted"})
+		return
+	}
+
+	var tag FollowedTag
+	err := ts.db.QueryRow("SELECT id, name, type FROM tags WHERE id = $1", req.TagID).Scan(&tag.TagID, &tag.Name, &tag.Type)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tag"})
+		return
+	}
+
+	frequency := req.Frequency
+	if frequency == "" {
+		frequency = models.FrequencyImmediate
+	}
+	err = ts.db.QueryRow(`
+		INSERT INTO subscriptions (user_id, type, target_id, target_name, events, frequency, is_active)
+		VALUES ($1, 'tag', $2, $3, $4, $5, true)
+		ON CONFLICT (user_id, type, target_id) DO UPDATE SET
+			target_name = EXCLUDED.target_name,
+			frequency = CASE WHEN $6 THEN EXCLUDED.frequency ELSE subscriptions.frequency END,
+			is_active = true,
+			updated_at = NOW()
+		RETURNING frequency, created_at`,
+		userID, tag.TagID, tag.Name, pq.Array(tagFollowEvents), frequency, req.Frequency != "",
+	).Scan(&tag.Frequency, &tag.FollowedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag followed", "followed": tag})
+}
+
+// UpdateTagFollow changes how often a followed tag notifies:
+// PUT /api/v1/user/tags/follow/:tag_id {"frequency": "weekly"}
+func (ts *TagService) UpdateTagFollow(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	tagID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	var req struct {
+		Frequency models.NotificationFrequency `json:"frequency" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !tagFollowFrequencies[req.Frequency] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Frequency must be immediate, daily, weekly or muted"})
+		return
+	}
+
+	result, err := ts.db.Exec(`
+		UPDATE subscriptions SET frequency = $1, updated_at = NOW()
+		WHERE user_id = $2 AND type = 'tag' AND target_id = $3 AND is_active = true`,
+		req.Frequency, userID, tagID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update followed tag"})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag is not followed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Followed tag updated", "tag_id": tagID, "frequency": req.Frequency})
+}
+
+// UnfollowTag stops following a tag: DELETE /api/v1/user/tags/follow/:tag_id
+func (ts *TagService) UnfollowTag(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	tagID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	_, err = ts.db.Exec("DELETE FROM subscriptions WHERE user_id = $1 AND type = 'tag' AND target_id = $2", userID, tagID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag unfollowed"})
+}
+
+// GetFollowedTags lists the user's followed tags with their frequencies:
+// GET /api/v1/user/tags/followed
+func (ts *TagService) GetFollowedTags(c *gin.Context) {
+	userID, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+
+	rows, err := ts.db.Query(`
+		SELECT t.id, t.name, t.type, s.frequency, s.created_at
+		FROM subscriptions s
+		JOIN tags t ON t.id = s.target_id
+		WHERE s.user_id = $1 AND s.type = 'tag' AND s.is_active = true
+		ORDER BY s.created_at DESC`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load followed tags"})
+		return
+	}
+	defer rows.Close()
+
+	followed := []FollowedTag{}
+	for rows.Next() {
+		var tag FollowedTag
+		if err := rows.Scan(&tag.TagID, &tag.Name, &tag.Type, &tag.Frequency, &tag.FollowedAt); err != nil {
+			continue
+		}
+		followed = append(followed, tag)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"followed": followed})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// TAG HIERARCHY TRAVERSAL
+// Lists every metatag above a tag or every subtag below it through
+// parent_child relationships, so a filter on "Alternate Universe" can take
+// in all its AU subtags. The walk stops at a depth limit and at any tag
+// already on its path, reporting the loops it found rather than following
+// them. Closures are cached in Redis under a hierarchy version that every
+// wrangling change bumps, which drops them all at once.
+// =============================================================================
+
+const (
+	hierarchyCachePrefix = "tag_hierarchy:"
+	hierarchyVersionKey  = hierarchyCachePrefix + "version"
+	hierarchyCacheTTL    = time.Hour
+
+	defaultHierarchyDepth = maxExpansionDepth
+	maxHierarchyDepth     = 25
+)
+
+// Directions of a hierarchy walk
+const (
+	hierarchyAncestors   = "ancestors"
+	hierarchyDescendants = "descendants"
+)
+
+// HierarchyTag is a tag reached by a hierarchy walk, at the shortest
+// distance it was found
+type HierarchyTag struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	IsCanonical bool      `json:"is_canonical"`
+	Depth       int       `json:"depth"`
+}
+
+// HierarchyCycle is a parent_child relationship that leads back to a tag
+// already on the walk's path
+type HierarchyCycle struct {
+	ParentID uuid.UUID `json:"parent_id"`
+	ChildID  uuid.UUID `json:"child_id"`
+}
+
+// TagHierarchy is a tag's ancestors or descendants
+type TagHierarchy struct {
+	TagID     uuid.UUID        `json:"tag_id"`
+	Direction string           `json:"direction"`
+	Depth     int              `json:"depth"`
+	Tags      []HierarchyTag   `json:"tags"`
+	Truncated bool             `json:"truncated"` // the depth limit cut the walk short
+	Cycles    []HierarchyCycle `json:"cycles"`
+}
+
+// hierarchyStep is one edge followed by the walk
+type hierarchyStep struct {
+	from, to HierarchyTag
+	cycle    bool
+}
+
+// hierarchyDepth reads the depth query parameter, defaulting when missing
+// or invalid and capping at maxHierarchyDepth
+func hierarchyDepth(param string) int {
+	depth, err := strconv.Atoi(param)
+	if err != nil || depth <= 0 {
+		return defaultHierarchyDepth
+	}
+	if depth > maxHierarchyDepth {
+		return maxHierarchyDepth
+	}
+	return depth
+}
+
+// collectHierarchy turns the walk's steps into its tags, each at its
+// shortest depth, and the loops it met. Steps one past the depth limit only
+// show the walk was cut short.
+func collectHierarchy(h *TagHierarchy, steps []hierarchyStep) {
+	found := map[uuid.UUID]HierarchyTag{}
+	seenCycles := map[HierarchyCycle]bool{}
+	h.Tags = []HierarchyTag{}
+	h.Cycles = []HierarchyCycle{}
+
+	for _, step := range steps {
+		if step.cycle {
+			edge := HierarchyCycle{ParentID: step.from.ID, ChildID: step.to.ID}
+			if h.Direction == hierarchyAncestors {
+				edge = HierarchyCycle{ParentID: step.to.ID, ChildID: step.from.ID}
+			}
+			if !seenCycles[edge] {
+				seenCycles[edge] = true
+				h.Cycles = append(h.Cycles, edge)
+			}
+			continue
+		}
+		if step.to.Depth > h.Depth {
+			h.Truncated = true
+			continue
+		}
+		if existing, ok := found[step.to.ID]; !ok || step.to.Depth < existing.Depth {
+			found[step.to.ID] = step.to
+		}
+	}
+
+	for _, tag := range found {
+		h.Tags = append(h.Tags, tag)
+	}
+	sort.Slice(h.Tags, func(i, j int) bool {
+		if h.Tags[i].Depth != h.Tags[j].Depth {
+			return h.Tags[i].Depth < h.Tags[j].Depth
+		}
+		return h.Tags[i].Name < h.Tags[j].Name
+	})
+}
+
+// hierarchyCacheKey names a cached closure under the current hierarchy
+// version
+func (ts *TagService) hierarchyCacheKey(ctx context.Context, direction string, tagID uuid.UUID, depth int) string {
+	version, _ := ts.redis.Get(ctx, hierarchyVersionKey).Int64()
+	return fmt.Sprintf("%s%d:%s:%s:%d", hierarchyCachePrefix, version, direction, tagID, depth)
+}
+
+// invalidateTagHierarchy drops every cached closure after a wrangling
+// change; old entries are never read again and expire on their own
+func (ts *TagService) invalidateTagHierarchy(ctx context.Context) {
+	if err := ts.redis.Incr(ctx, hierarchyVersionKey).Err(); err != nil {
+		log.Printf("Failed to invalidate tag hierarchy cache: %v", err)
+	}
+}
+
+// walkTagHierarchy follows parent_child relationships from a tag up to its
+// metatags or down to its subtags
+func (ts *TagService) walkTagHierarchy(ctx context.Context, tagID uuid.UUID, direction string, depth int) (*TagHierarchy, error) {
+	cacheKey := ts.hierarchyCacheKey(ctx, direction, tagID, depth)
+	if data, err := ts.redis.Get(ctx, cacheKey).Bytes(); err == nil {
+		var h TagHierarchy
+		if json.Unmarshal(data, &h) == nil {
+			return &h, nil
+		}
+	}
+
+	// Walking up follows child -> parent, walking down parent -> child
+	from, to := "child_tag_id", "parent_tag_id"
+	if direction == hierarchyDescendants {
+		from, to = to, from
+	}
+
+	// The walk goes one level past the limit to learn whether it was cut
+	// short, and stops at a tag already on its path
+	rows, err := ts.db.QueryContext(ctx, fmt.Sprintf(`
+		WITH RECURSIVE walk AS (
+			SELECT tr.%[1]s AS from_id, tr.%[2]s AS tag_id, 1 AS depth,
+				ARRAY[$1::uuid, tr.%[2]s] AS path, tr.%[2]s = $1 AS cycle
+			FROM tag_relationships tr
+			WHERE tr.%[1]s = $1 AND tr.relationship_type = 'parent_child'
+			UNION ALL
+			SELECT tr.%[1]s, tr.%[2]s, walk.depth + 1,
+				walk.path || tr.%[2]s, tr.%[2]s = ANY(walk.path)
+			FROM walk
+			JOIN tag_relationships tr ON tr.%[1]s = walk.tag_id AND tr.relationship_type = 'parent_child'
+			WHERE NOT walk.cycle AND walk.depth <= $2
+		)
+		SELECT walk.from_id, walk.tag_id, t.name, t.type, COALESCE(t.is_canonical, false), walk.depth, walk.cycle
+		FROM walk
+		JOIN tags t ON t.id = walk.tag_id`, from, to), tagID, depth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []hierarchyStep
+	for rows.Next() {
+		var step hierarchyStep
+		if err := rows.Scan(&step.from.ID, &step.to.ID, &step.to.Name, &step.to.Type,
+			&step.to.IsCanonical, &step.to.Depth, &step.cycle); err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	h := &TagHierarchy{TagID: tagID, Direction: direction, Depth: depth}
+	collectHierarchy(h, steps)
+	if len(h.Cycles) > 0 {
+		log.Printf("Tag hierarchy cycles found walking %s of %s: %v", direction, tagID, h.Cycles)
+	}
+
+	if data, err := json.Marshal(h); err == nil {
+		ts.redis.Set(ctx, cacheKey, data, hierarchyCacheTTL)
+	}
+	return h, nil
+}
+
+// serveTagHierarchy answers a hierarchy endpoint for one direction
+func (ts *TagService) serveTagHierarchy(c *gin.Context, direction string) {
+	tagID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var exists bool
+	err = ts.db.QueryRowContext(ctx, "SELECT true FROM tags WHERE id = $1", tagID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	h, err := ts.walkTagHierarchy(ctx, tagID, direction, hierarchyDepth(c.Query("depth")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to walk tag hierarchy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tag_id":    h.TagID,
+		direction:   h.Tags,
+		"depth":     h.Depth,
+		"truncated": h.Truncated,
+		"cycles":    h.Cycles,
+	})
+}
+
+// GetTagAncestors lists the metatags above a tag, nearest first
+// GET /api/v1/tags/:tag_id/ancestors?depth=10
+func (ts *TagService) GetTagAncestors(c *gin.Context) {
+	ts.serveTagHierarchy(c, hierarchyAncestors)
+}
+
+// GetTagDescendants lists the subtags below a tag, nearest first
+// GET /api/v1/tags/:tag_id/descendants?depth=10
+func (ts *TagService) GetTagDescendants(c *gin.Context) {
+	ts.serveTagHierarchy(c, hierarchyDescendants)
+}
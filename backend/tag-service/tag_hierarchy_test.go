@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHierarchyDepth(t *testing.T) {
+	assert.Equal(t, defaultHierarchyDepth, hierarchyDepth(""))
+	assert.Equal(t, defaultHierarchyDepth, hierarchyDepth("0"))
+	assert.Equal(t, defaultHierarchyDepth, hierarchyDepth("deep"))
+	assert.Equal(t, 3, hierarchyDepth("3"))
+	assert.Equal(t, maxHierarchyDepth, hierarchyDepth("500"))
+}
+
+func TestCollectHierarchy(t *testing.T) {
+	au := HierarchyTag{ID: uuid.New(), Name: "Alternate Universe"}
+	modern := HierarchyTag{ID: uuid.New(), Name: "Alternate Universe - Modern Setting"}
+	coffee := HierarchyTag{ID: uuid.New(), Name: "Alternate Universe - Coffee Shops & Cafés"}
+	college := HierarchyTag{ID: uuid.New(), Name: "Alternate Universe - College/University"}
+	at := func(tag HierarchyTag, depth int) HierarchyTag {
+		tag.Depth = depth
+		return tag
+	}
+
+	h := &TagHierarchy{TagID: au.ID, Direction: hierarchyDescendants, Depth: 2}
+	collectHierarchy(h, []hierarchyStep{
+		{from: au, to: at(modern, 1)},
+		{from: modern, to: at(coffee, 2)},
+		// Coffee shops is also filed directly under AU
+		{from: au, to: at(coffee, 1)},
+		// A wrangling mistake put AU under coffee shops, met on both paths
+		{from: coffee, to: at(au, 3), cycle: true},
+		{from: coffee, to: at(au, 2), cycle: true},
+		// One level past the limit
+		{from: coffee, to: at(college, 3)},
+	})
+
+	assert.Equal(t, []HierarchyTag{at(coffee, 1), at(modern, 1)}, h.Tags, "each tag once, at its shortest depth")
+	assert.Equal(t, []HierarchyCycle{{ParentID: coffee.ID, ChildID: au.ID}}, h.Cycles)
+	assert.True(t, h.Truncated)
+
+	up := &TagHierarchy{TagID: modern.ID, Direction: hierarchyAncestors, Depth: 5}
+	collectHierarchy(up, []hierarchyStep{
+		{from: modern, to: at(au, 1)},
+		{from: au, to: at(modern, 2), cycle: true},
+	})
+	assert.Equal(t, []HierarchyTag{at(au, 1)}, up.Tags)
+	assert.Equal(t, []HierarchyCycle{{ParentID: modern.ID, ChildID: au.ID}}, up.Cycles,
+		"walking up, the edge's parent is the tag reached")
+	assert.False(t, up.Truncated)
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+)
+
+// =============================================================================
+// TAG REPORTS
+// Anyone signed in can report a tag. Wranglers work the reports through
+// open -> in_review -> resolved or rejected, leaving notes that the
+// reporter is sent when the report closes. Reporting a tag for the same
+// reason as a live report files a duplicate under it: wranglers see one
+// report with a duplicate count, duplicates follow its status, and every
+// reporter is told the outcome.
+// =============================================================================
+
+const maxReportDetails = 2000
+
+// Report statuses
+const (
+	reportOpen     = "open"
+	reportInReview = "in_review"
+	reportResolved = "resolved"
+	reportRejected = "rejected"
+)
+
+// errDuplicateReportCode answers a user reporting the same tag for the same
+// reason twice while the first report is live
+const errDuplicateReportCode = "DUPLICATE_REPORT"
+
+// tagReportReasons are the reasons a tag can be reported for
+var tagReportReasons = []string{"wrong_type", "should_be_synonym", "should_be_canonical", "offensive", "spam", "other"}
+
+// reportTransitions lists the statuses each status may move to. A report
+// under review can be handed back to the queue.
+var reportTransitions = map[string][]string{
+	reportOpen:     {reportInReview},
+	reportInReview: {reportOpen, reportResolved, reportRejected},
+}
+
+var (
+	tagReportsFiled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nuclear_tag_reports_total",
+		Help: "Tag reports filed, by reason and whether they duplicated a live report",
+	}, []string{"reason", "duplicate"})
+	tagReportResolution = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "nuclear_tag_report_resolution_seconds",
+		Help:    "Time from a tag report being filed to it being resolved or rejected",
+		Buckets: []float64{3600, 6 * 3600, 24 * 3600, 3 * 24 * 3600, 7 * 24 * 3600, 30 * 24 * 3600},
+	}, []string{"outcome"})
+)
+
+// TagReport is a report of a tag
+type TagReport struct {
+	ID              uuid.UUID  `json:"id"`
+	TagID           uuid.UUID  `json:"tag_id"`
+	TagName         string     `json:"tag_name"`
+	ReporterID      uuid.UUID  `json:"reporter_id"`
+	Reason          string     `json:"reason"`
+	Details         string     `json:"details"`
+	Status          string     `json:"status"`
+	DuplicateOf     *uuid.UUID `json:"duplicate_of,omitempty"`
+	DuplicateCount  int        `json:"duplicate_count"`
+	ReviewerID      *uuid.UUID `json:"reviewer_id,omitempty"`
+	ReviewerNotes   *string    `json:"reviewer_notes,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	ReviewStartedAt *time.Time `json:"review_started_at,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+}
+
+// validReportTransition reports whether a report may move between statuses
+func validReportTransition(from, to string) bool {
+	return contains(reportTransitions[from], to)
+}
+
+const tagReportColumns = `
+	r.id, r.tag_id, t.name, r.reporter_id, r.reason, r.details, r.status, r.duplicate_of,
+	(SELECT COUNT(*) FROM tag_reports d WHERE d.duplicate_of = r.id),
+	r.reviewer_id, r.reviewer_notes, r.created_at, r.updated_at, r.review_started_at, r.resolved_at`
+
+func scanTagReport(row interface{ Scan(...interface{}) error }) (*TagReport, error) {
+	var r TagReport
+	err := row.Scan(&r.ID, &r.TagID, &r.TagName, &r.ReporterID, &r.Reason, &r.Details, &r.Status, &r.DuplicateOf,
+		&r.DuplicateCount, &r.ReviewerID, &r.ReviewerNotes, &r.CreatedAt, &r.UpdatedAt, &r.ReviewStartedAt, &r.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func getTagReport(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, reportID uuid.UUID) (*TagReport, error) {
+	return scanTagReport(q.QueryRowContext(ctx, `
+		SELECT `+tagReportColumns+`
+		FROM tag_reports r JOIN tags t ON t.id = r.tag_id
+		WHERE r.id = $1`, reportID))
+}
+
+// ReportTag files a report of a tag:
+// POST /api/v1/tags/:tag_id/report {"reason": "should_be_synonym", "details": "..."}
+func (ts *TagService) ReportTag(c *gin.Context) {
+	tagID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+	reporter, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Reason  string `json:"reason" binding:"required"`
+		Details string `json:"details"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if !contains(tagReportReasons, req.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report reason", "valid_reasons": tagReportReasons})
+		return
+	}
+	req.Details = strings.TrimSpace(req.Details)
+	if len(req.Details) > maxReportDetails {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Report details are too long", "max": maxReportDetails})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := ts.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file report"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the tag so two reports filed together can't both become the
+	// live report for a reason
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT true FROM tags WHERE id = $1 FOR UPDATE", tagID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file report"})
+		return
+	}
+
+	var liveID uuid.UUID
+	var liveStatus string
+	var reportedAlready bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT r.id, r.status, (r.reporter_id = $3 OR EXISTS (
+			SELECT 1 FROM tag_reports d WHERE d.duplicate_of = r.id AND d.reporter_id = $3))
+		FROM tag_reports r
+		WHERE r.tag_id = $1 AND r.reason = $2 AND r.duplicate_of IS NULL AND r.status IN ('open', 'in_review')`,
+		tagID, req.Reason, reporter).Scan(&liveID, &liveStatus, &reportedAlready)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file report"})
+		return
+	}
+	isDuplicate := err == nil
+	if reportedAlready {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "You've already reported this tag for this reason",
+			"code":      errDuplicateReportCode,
+			"report_id": liveID,
+		})
+		return
+	}
+
+	var duplicateOf *uuid.UUID
+	status := reportOpen
+	if isDuplicate {
+		duplicateOf, status = &liveID, liveStatus
+	}
+	reportID := uuid.New()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO tag_reports (id, tag_id, reporter_id, reason, details, status, duplicate_of)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, reportID, tagID, reporter, req.Reason, req.Details, status, duplicateOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file report"})
+		return
+	}
+	report, err := getTagReport(ctx, tx, reportID)
+	if err != nil || tx.Commit() != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file report"})
+		return
+	}
+
+	tagReportsFiled.WithLabelValues(req.Reason, fmt.Sprint(isDuplicate)).Inc()
+	c.JSON(http.StatusCreated, gin.H{"report": report})
+}
+
+// GetTagReports lists reports for wranglers, oldest first, leaving out
+// duplicates, which are counted on the report they follow:
+// GET /api/v1/wrangling/reports?status=open&reason=spam&tag_id=123&limit=50&offset=0
+func (ts *TagService) GetTagReports(c *gin.Context) {
+	status := c.DefaultQuery("status", reportOpen)
+	if status != "all" && !contains([]string{reportOpen, reportInReview, reportResolved, reportRejected}, status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if status == "all" {
+		status = ""
+	}
+	var tagID interface{}
+	if id := c.Query("tag_id"); id != "" {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+			return
+		}
+		tagID = parsed
+	}
+	limit, offset := wranglingPage(c)
+	ctx := c.Request.Context()
+
+	const filter = `
+		FROM tag_reports r JOIN tags t ON t.id = r.tag_id
+		WHERE r.duplicate_of IS NULL
+		AND ($1 = '' OR r.status = $1)
+		AND ($2 = '' OR r.reason = $2)
+		AND ($3::uuid IS NULL OR r.tag_id = $3::uuid)`
+	reason := c.Query("reason")
+
+	var total int
+	if err := ts.db.QueryRowContext(ctx, "SELECT COUNT(*) "+filter, status, reason, tagID).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count tag reports"})
+		return
+	}
+
+	rows, err := ts.db.QueryContext(ctx, `
+		SELECT `+tagReportColumns+filter+`
+		ORDER BY r.created_at, r.id
+		LIMIT $4 OFFSET $5`, status, reason, tagID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tag reports"})
+		return
+	}
+	defer rows.Close()
+
+	reports := []*TagReport{}
+	for rows.Next() {
+		report, err := scanTagReport(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read tag reports"})
+			return
+		}
+		reports = append(reports, report)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports, "total": total, "limit": limit, "offset": offset})
+}
+
+// ProcessTagReport moves a report, and its duplicates, to a new status:
+// PUT /api/v1/wrangling/reports/:report_id {"status": "in_review"|"open"|"resolved"|"rejected", "notes": "..."}
+// Rejecting needs notes saying why. Closing a report tells every reporter.
+func (ts *TagService) ProcessTagReport(c *gin.Context) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+	reviewer, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Status string `json:"status" binding:"required"`
+		Notes  string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	req.Notes = strings.TrimSpace(req.Notes)
+	if req.Status == reportRejected && req.Notes == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rejecting a report needs notes for the reporter"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := ts.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+	defer tx.Rollback()
+
+	var current string
+	var duplicateOf *uuid.UUID
+	err = tx.QueryRowContext(ctx, "SELECT status, duplicate_of FROM tag_reports WHERE id = $1 FOR UPDATE",
+		reportID).Scan(&current, &duplicateOf)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+	if duplicateOf != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This report is a duplicate; process the report it follows", "report_id": duplicateOf})
+		return
+	}
+	if !validReportTransition(current, req.Status) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   fmt.Sprintf("A report can't move from %s to %s", current, req.Status),
+			"allowed": reportTransitions[current],
+		})
+		return
+	}
+
+	closing := req.Status == reportResolved || req.Status == reportRejected
+	var notes *string
+	if req.Notes != "" {
+		notes = &req.Notes
+	}
+	// The report and its duplicates move together. Handing a report back
+	// to the queue clears its reviewer.
+	_, err = tx.ExecContext(ctx, `
+		UPDATE tag_reports SET
+			status = $2,
+			reviewer_id = CASE WHEN $2 = 'open' THEN NULL ELSE $3::uuid END,
+			reviewer_notes = COALESCE($4, reviewer_notes),
+			review_started_at = CASE WHEN $2 = 'in_review' THEN NOW() WHEN $2 = 'open' THEN NULL ELSE review_started_at END,
+			resolved_at = CASE WHEN $5 THEN NOW() END,
+			updated_at = NOW()
+		WHERE id = $1 OR duplicate_of = $1`, reportID, req.Status, reviewer, notes, closing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+
+	report, err := getTagReport(ctx, tx, reportID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+	var reporters []uuid.UUID
+	if closing {
+		reporters, err = reportReporters(ctx, tx, reportID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+
+	if closing {
+		tagReportResolution.WithLabelValues(req.Status).Observe(report.ResolvedAt.Sub(report.CreatedAt).Seconds())
+		go notifyReporters(report, reporters)
+	}
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// reportReporters lists everyone who filed a report or one of its
+// duplicates
+func reportReporters(ctx context.Context, tx *sql.Tx, reportID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT DISTINCT reporter_id FROM tag_reports WHERE id = $1 OR duplicate_of = $1`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reporters := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		reporters = append(reporters, id)
+	}
+	return reporters, rows.Err()
+}
+
+// notifyReporters tells a closed report's reporters how it ended
+func notifyReporters(report *TagReport, reporters []uuid.UUID) {
+	description := fmt.Sprintf("Your report of %q was resolved", report.TagName)
+	if report.Status == reportRejected {
+		description = fmt.Sprintf("Your report of %q was declined", report.TagName)
+	}
+	notes := ""
+	if report.ReviewerNotes != nil {
+		notes = *report.ReviewerNotes
+		description += ": " + notes
+	}
+
+	event := notifications.EventData{
+		Type:         models.EventTagReportReviewed,
+		SourceID:     report.ID,
+		SourceType:   "tag_report",
+		Title:        "Tag report reviewed",
+		Description:  description,
+		ActionURL:    fmt.Sprintf("/tags/%s", report.TagID),
+		RecipientIDs: reporters,
+		ExtraData: map[string]interface{}{
+			"tag_id":         report.TagID,
+			"tag_name":       report.TagName,
+			"reason":         report.Reason,
+			"status":         report.Status,
+			"reviewer_notes": notes,
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sendNotificationEvent(ctx, event); err != nil {
+		log.Printf("Failed to notify reporters of tag report %s: %v", report.ID, err)
+	}
+}
+
+// sendNotificationEvent hands an event to the notification service
+func sendNotificationEvent(ctx context.Context, event notifications.EventData) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	url := getEnv("NOTIFICATION_SERVICE_URL", "http://localhost:8004") + "/api/v1/process-event"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notification service unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("notification service returned " + resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidReportTransition(t *testing.T) {
+	assert.True(t, validReportTransition(reportOpen, reportInReview))
+	assert.True(t, validReportTransition(reportInReview, reportResolved))
+	assert.True(t, validReportTransition(reportInReview, reportRejected))
+	assert.True(t, validReportTransition(reportInReview, reportOpen), "a reviewer can hand a report back")
+
+	assert.False(t, validReportTransition(reportOpen, reportResolved), "reports are reviewed before they close")
+	assert.False(t, validReportTransition(reportResolved, reportOpen), "closed reports stay closed")
+	assert.False(t, validReportTransition(reportRejected, reportInReview))
+	assert.False(t, validReportTransition(reportOpen, "deleted"))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// =============================================================================
+// CANONICAL TAG SUGGESTIONS
+// Work-service asks, when a work is posted or edited, whether any of its tags
+// have a canonical the author could have used instead. A synonym suggests its
+// canonical; an unwrangled or brand-new tag suggests a canonical of the same
+// type spelled with the same letters and digits ("hurt comfort" for
+// "Hurt/Comfort"). Suggestions never change the work's tags.
+// =============================================================================
+
+// maxSuggestionQueries caps how many tags one request may ask about
+const maxSuggestionQueries = 200
+
+// Suggestion reasons
+const (
+	suggestionSynonym = "synonym"
+	suggestionSimilar = "similar"
+)
+
+// TagSuggestionQuery is a tag as the author typed it
+type TagSuggestionQuery struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TagSuggestion offers a canonical in place of a tag the author typed
+type TagSuggestion struct {
+	Name          string    `json:"name"`
+	Type          string    `json:"type"`
+	CanonicalID   uuid.UUID `json:"canonical_id"`
+	CanonicalName string    `json:"canonical_name"`
+	Reason        string    `json:"reason"`
+}
+
+// tagMatchKey reduces a tag name to its lower-case letters and digits, the
+// same as the SQL expression behind idx_tags_canonical_match_key
+func tagMatchKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// knownTag is what the suggester knows about a typed name that exists
+type knownTag struct {
+	Type          string
+	IsCanonical   bool
+	CanonicalID   *uuid.UUID
+	CanonicalName *string
+}
+
+// canonicalMatch is a canonical tag reachable by match key
+type canonicalMatch struct {
+	ID   uuid.UUID
+	Name string
+}
+
+// suggestCanonicalTags picks a suggestion for each query that isn't already
+// canonical. known is keyed by lower-case name, similar by type and match key.
+func suggestCanonicalTags(queries []TagSuggestionQuery, known map[string]knownTag, similar map[[2]string]canonicalMatch) []TagSuggestion {
+	suggestions := []TagSuggestion{}
+	seen := map[string]bool{}
+	for _, q := range queries {
+		lower := strings.ToLower(q.Name)
+		if seen[q.Type+"\x00"+lower] {
+			continue
+		}
+		seen[q.Type+"\x00"+lower] = true
+
+		tag, exists := known[lower]
+		switch {
+		case exists && tag.IsCanonical:
+			continue
+		case exists && tag.CanonicalID != nil:
+			suggestions = append(suggestions, TagSuggestion{
+				Name: q.Name, Type: q.Type,
+				CanonicalID: *tag.CanonicalID, CanonicalName: *tag.CanonicalName,
+				Reason: suggestionSynonym,
+			})
+			continue
+		}
+
+		if match, ok := similar[[2]string{q.Type, tagMatchKey(q.Name)}]; ok && !strings.EqualFold(match.Name, q.Name) {
+			suggestions = append(suggestions, TagSuggestion{
+				Name: q.Name, Type: q.Type,
+				CanonicalID: match.ID, CanonicalName: match.Name,
+				Reason: suggestionSimilar,
+			})
+		}
+	}
+	return suggestions
+}
+
+// InternalSuggestCanonicalTags suggests canonicals for the tags of a work
+// being posted or edited:
+// POST /api/v1/internal/tags/suggestions {"tags": [{"name": "hurt comfort", "type": "freeform"}]}
+func (ts *TagService) InternalSuggestCanonicalTags(c *gin.Context) {
+	var req struct {
+		Tags []TagSuggestionQuery `json:"tags" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tags is required"})
+		return
+	}
+	if len(req.Tags) > maxSuggestionQueries {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many tags"})
+		return
+	}
+
+	queries := []TagSuggestionQuery{}
+	names, keys, types := []string{}, []string{}, []string{}
+	for _, q := range req.Tags {
+		q.Name = strings.TrimSpace(q.Name)
+		if q.Name == "" {
+			continue
+		}
+		queries = append(queries, q)
+		names = append(names, q.Name)
+		keys = append(keys, tagMatchKey(q.Name))
+		types = append(types, q.Type)
+	}
+	if len(queries) == 0 {
+		c.JSON(http.StatusOK, gin.H{"suggestions": []TagSuggestion{}})
+		return
+	}
+	ctx := c.Request.Context()
+
+	// Synonyms point at their canonical through canonical_name or a
+	// synonym relationship
+	rows, err := ts.db.QueryContext(ctx, `
+		SELECT t.name, t.type, COALESCE(t.is_canonical, false), c.id, c.name
+		FROM tags t
+		LEFT JOIN LATERAL (
+			SELECT ct.id, ct.name FROM tags ct
+			WHERE ct.is_canonical AND (
+				ct.name = t.canonical_name
+				OR ct.id IN (SELECT parent_tag_id FROM tag_relationships
+					WHERE child_tag_id = t.id AND relationship_type = 'synonym')
+			)
+			LIMIT 1
+		) c ON true
+		WHERE t.name = ANY($1::citext[])`, pq.Array(names))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up tags"})
+		return
+	}
+	defer rows.Close()
+
+	known := map[string]knownTag{}
+	for rows.Next() {
+		var name string
+		var tag knownTag
+		if err := rows.Scan(&name, &tag.Type, &tag.IsCanonical, &tag.CanonicalID, &tag.CanonicalName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read tags"})
+			return
+		}
+		known[strings.ToLower(name)] = tag
+	}
+	rows.Close()
+
+	// The most used canonical wins when several share a match key
+	rows, err = ts.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (type, match_key) type, match_key, id, name
+		FROM (
+			SELECT id, name, type, use_count,
+				regexp_replace(lower(name::text), '[^[:alnum:]]+', '', 'g') AS match_key
+			FROM tags
+			WHERE is_canonical AND type = ANY($2::text[])
+			AND regexp_replace(lower(name::text), '[^[:alnum:]]+', '', 'g') = ANY($1::text[])
+		) m
+		ORDER BY type, match_key, use_count DESC, id`, pq.Array(keys), pq.Array(types))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up similar tags"})
+		return
+	}
+	defer rows.Close()
+
+	similar := map[[2]string]canonicalMatch{}
+	for rows.Next() {
+		var tagType, key string
+		var match canonicalMatch
+		if err := rows.Scan(&tagType, &key, &match.ID, &match.Name); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read similar tags"})
+			return
+		}
+		similar[[2]string{tagType, key}] = match
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestCanonicalTags(queries, known, similar)})
+}
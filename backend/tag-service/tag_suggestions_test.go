@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagMatchKey(t *testing.T) {
+	assert.Equal(t, "hurtcomfort", tagMatchKey("Hurt/Comfort"))
+	assert.Equal(t, "hurtcomfort", tagMatchKey("  hurt - comfort "))
+	assert.Equal(t, "alternateuniversecoffeeshops", tagMatchKey("Alternate Universe - Coffee Shops"))
+	assert.Equal(t, "pokémon", tagMatchKey("Pokémon!"))
+}
+
+func TestSuggestCanonicalTags(t *testing.T) {
+	hcID, fluffID := uuid.New(), uuid.New()
+	fluff := "Fluff"
+	known := map[string]knownTag{
+		"hurt/comfort":     {Type: "freeform", IsCanonical: true},
+		"fluffy":           {Type: "freeform", CanonicalID: &fluffID, CanonicalName: &fluff},
+		"hurt and comfort": {Type: "freeform"},
+	}
+	similar := map[[2]string]canonicalMatch{
+		{"freeform", "hurtcomfort"}: {ID: hcID, Name: "Hurt/Comfort"},
+	}
+
+	suggestions := suggestCanonicalTags([]TagSuggestionQuery{
+		{Name: "Hurt/Comfort", Type: "freeform"},
+		{Name: "Fluffy", Type: "freeform"},
+		{Name: "hurt comfort", Type: "freeform"},
+		{Name: "Hurt Comfort", Type: "freeform"}, // same tag in another case, suggested once
+		{Name: "hurt comfort", Type: "character"},
+		{Name: "Something New", Type: "freeform"},
+	}, known, similar)
+
+	assert.Len(t, suggestions, 2)
+	assert.Equal(t, "Fluffy", suggestions[0].Name)
+	assert.Equal(t, fluffID, suggestions[0].CanonicalID)
+	assert.Equal(t, suggestionSynonym, suggestions[0].Reason)
+
+	assert.Equal(t, "hurt comfort", suggestions[1].Name)
+	assert.Equal(t, "Hurt/Comfort", suggestions[1].CanonicalName)
+	assert.Equal(t, suggestionSimilar, suggestions[1].Reason)
+
+	// Unwrangled tags with no similar canonical get no suggestion
+	assert.Empty(t, suggestCanonicalTags([]TagSuggestionQuery{{Name: "Hurt and Comfort", Type: "freeform"}}, known, map[[2]string]canonicalMatch{}))
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"nuclear-ao3/shared/middleware"
+)
+
+// =============================================================================
+// TAG USE COUNTS
+// tags.use_count is kept by a trigger on work_tags, so every insert, delete
+// or move of a work's tag adjusts it in the same transaction. Counts can
+// still drift (rows changed with triggers disabled, restores, old double
+// counting), so a reconciliation job compares each count with its work_tags
+// rows on a schedule, repairs any that disagree and reports what it found.
+// nuclearctl runs the same check on demand.
+// =============================================================================
+
+const (
+	useCountLockKey = "tag_use_count:reconcile_lock"
+	useCountLockTTL = 30 * time.Minute
+
+	// maxReportedUseCountDrift caps the tags listed in a reconciliation
+	// report; the totals always cover every drifted tag
+	maxReportedUseCountDrift = 100
+)
+
+var errUseCountReconcileRunning = errors.New("use count reconciliation already running")
+
+var (
+	tagUseCountDrift = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "nuclear_tag_use_count_drift_total",
+		Help: "Tags found with a use_count that disagreed with their works, by whether it was repaired",
+	}, []string{"repaired"})
+	tagUseCountDrifted = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "nuclear_tag_use_count_drifted",
+		Help: "Tags whose use_count had drifted at the last reconciliation",
+	})
+	tagUseCountLastReconciled = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "nuclear_tag_use_count_last_reconciled_timestamp_seconds",
+		Help: "When tag use counts were last reconciled",
+	})
+)
+
+// UseCountDrift is a tag whose use_count disagrees with its work_tags rows
+type UseCountDrift struct {
+	TagID    uuid.UUID `json:"tag_id"`
+	Name     string    `json:"name"`
+	UseCount int       `json:"use_count"`
+	Works    int       `json:"works"`
+}
+
+// useCountDriftCTE finds the selected tags whose use_count is wrong. $1 is
+// an array of tag IDs, or NULL for every tag.
+const useCountDriftCTE = `
+	WITH actual AS (
+		SELECT t.id, t.name, t.use_count,
+			(SELECT COUNT(*) FROM work_tags wt WHERE wt.tag_id = t.id) AS works
+		FROM tags t
+		WHERE $1::uuid[] IS NULL OR t.id = ANY($1::uuid[])
+	), drift AS (
+		SELECT * FROM actual WHERE use_count <> works
+	)`
+
+// reconcileTagUseCounts finds tags whose use_count drifted and, unless
+// dryRun, corrects them in the same statement. The returned drift holds the
+// counts as they were before the fix.
+func (ts *TagService) reconcileTagUseCounts(ctx context.Context, tagIDs []uuid.UUID, dryRun bool) ([]UseCountDrift, error) {
+	var ids interface{}
+	if tagIDs != nil {
+		ids = pq.Array(uuidStrings(tagIDs))
+	}
+
+	query := useCountDriftCTE + `
+		SELECT id, name, use_count, works FROM drift ORDER BY id`
+	if !dryRun {
+		query = useCountDriftCTE + `
+		UPDATE tags t
+		SET use_count = d.works, updated_at = NOW()
+		FROM drift d
+		WHERE t.id = d.id
+		RETURNING d.id, d.name, d.use_count, d.works`
+	}
+
+	rows, err := ts.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drifted := []UseCountDrift{}
+	for rows.Next() {
+		var d UseCountDrift
+		if err := rows.Scan(&d.TagID, &d.Name, &d.UseCount, &d.Works); err != nil {
+			return nil, err
+		}
+		drifted = append(drifted, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	repaired := "false"
+	if !dryRun {
+		repaired = "true"
+	}
+	tagUseCountDrift.WithLabelValues(repaired).Add(float64(len(drifted)))
+	if tagIDs == nil {
+		tagUseCountDrifted.Set(float64(len(drifted)))
+		tagUseCountLastReconciled.SetToCurrentTime()
+	}
+	return drifted, nil
+}
+
+// reconcileAllTagUseCounts repairs every drifted tag unless another replica
+// is already doing so
+func (ts *TagService) reconcileAllTagUseCounts(ctx context.Context) ([]UseCountDrift, error) {
+	locked, err := ts.redis.SetNX(ctx, useCountLockKey, 1, useCountLockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, errUseCountReconcileRunning
+	}
+	defer ts.redis.Del(context.Background(), useCountLockKey)
+
+	return ts.reconcileTagUseCounts(ctx, nil, false)
+}
+
+// startUseCountReconciler repairs drifted use counts every interval until
+// ctx is cancelled
+func (ts *TagService) startUseCountReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			drifted, err := ts.reconcileAllTagUseCounts(ctx)
+			if err == errUseCountReconcileRunning {
+				continue
+			}
+			if err != nil {
+				log.Printf("Tag use count reconciliation failed: %v", err)
+				continue
+			}
+			if len(drifted) > 0 {
+				log.Printf("Tag use count reconciliation repaired %d tags", len(drifted))
+			}
+		}
+	}
+}
+
+// InternalReconcileTagUseCounts recomputes tags' use counts from their
+// works and fixes any that drifted:
+// POST /api/v1/internal/tags/use-counts/reconcile {"tag_ids": [...]} or {"all": true}
+func (ts *TagService) InternalReconcileTagUseCounts(c *gin.Context) {
+	var req struct {
+		TagIDs []uuid.UUID `json:"tag_ids"`
+		All    bool        `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	if len(req.TagIDs) == 0 && !req.All {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Choose tag_ids or all"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	dryRun := middleware.IsDryRun(c)
+	var drifted []UseCountDrift
+	var err error
+	switch {
+	case !req.All:
+		drifted, err = ts.reconcileTagUseCounts(ctx, req.TagIDs, dryRun)
+	case dryRun:
+		drifted, err = ts.reconcileTagUseCounts(ctx, nil, true)
+	default:
+		drifted, err = ts.reconcileAllTagUseCounts(ctx)
+	}
+	if err == errUseCountReconcileRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "A reconciliation is already running"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile use counts"})
+		return
+	}
+
+	if !dryRun && len(drifted) > 0 {
+		for _, d := range drifted {
+			ts.tags.Delete(ctx, tagCacheKey(d.TagID.String()))
+		}
+	}
+
+	report := drifted
+	if len(report) > maxReportedUseCountDrift {
+		report = report[:maxReportedUseCountDrift]
+	}
+	response := gin.H{"drifted": len(drifted), "tags": report}
+	if dryRun {
+		response["dry_run"] = true
+	} else {
+		response["fixed"] = len(drifted)
+	}
+	c.JSON(http.StatusOK, response)
+}
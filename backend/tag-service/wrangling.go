@@ -0,0 +1,1113 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// =============================================================================
+// TAG WRANGLING
+// Wranglers decide which tags are canonical, which are synonyms of a
+// canonical and how canonicals nest under each other. Attaching a synonym
+// moves its works, parents and children onto the canonical, so filtering by
+// the canonical finds everything. Anyone can request a merge; a second
+// wrangler approves or rejects it. Every change is written to
+// tag_wrangling_log in the same transaction as the change itself.
+// =============================================================================
+
+const (
+	defaultWranglingPage = 50
+	maxWranglingPage     = 200
+	// wranglingTagLogSize is how many log entries a tag's wrangling page shows
+	wranglingTagLogSize = 20
+)
+
+// Wrangling actions as recorded in tag_wrangling_log
+const (
+	actionMakeCanonical   = "make_canonical"
+	actionRemoveCanonical = "remove_canonical"
+	actionAddSynonym      = "add_synonym"
+	actionAddParent       = "add_parent"
+	actionRemoveParent    = "remove_parent"
+	actionMergeRequested  = "merge_requested"
+	actionMergeApproved   = "merge_approved"
+	actionMergeRejected   = "merge_rejected"
+)
+
+// validParentTypes lists, for each tag type, the types its parents may be.
+// A parent of the tag's own type is a metatag.
+var validParentTypes = map[string][]string{
+	"fandom":       {"fandom"},
+	"character":    {"fandom", "character"},
+	"relationship": {"fandom", "character", "relationship"},
+	"freeform":     {"fandom", "freeform"},
+}
+
+// WranglingLogEntry is one logged wrangling action
+type WranglingLogEntry struct {
+	ID             int64           `json:"id"`
+	Action         string          `json:"action"`
+	TagID          uuid.UUID       `json:"tag_id"`
+	TagName        string          `json:"tag_name"`
+	RelatedTagID   *uuid.UUID      `json:"related_tag_id,omitempty"`
+	RelatedTagName *string         `json:"related_tag_name,omitempty"`
+	ActorID        *uuid.UUID      `json:"actor_id,omitempty"`
+	Details        json.RawMessage `json:"details"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// TagMergeRequest is a request to merge one tag into another
+type TagMergeRequest struct {
+	ID          uuid.UUID       `json:"id"`
+	Source      MergePreviewTag `json:"source"`
+	Target      MergePreviewTag `json:"target"`
+	RequestedBy *uuid.UUID      `json:"requested_by,omitempty"`
+	Reason      string          `json:"reason"`
+	Status      string          `json:"status"`
+	ReviewedBy  *uuid.UUID      `json:"reviewed_by,omitempty"`
+	ReviewNote  *string         `json:"review_note,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ReviewedAt  *time.Time      `json:"reviewed_at,omitempty"`
+}
+
+// SynonymResult reports what attaching a synonym moved onto the canonical
+type SynonymResult struct {
+	WorksRepointed         int `json:"works_repointed"`
+	SynonymsRepointed      int `json:"synonyms_repointed"`
+	RelationshipsRepointed int `json:"relationships_repointed"`
+}
+
+// wranglingQuerier is a *sql.DB or *sql.Tx
+type wranglingQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// wranglingError is a refusal to wrangle, answered with its status
+type wranglingError struct {
+	status    int
+	message   string
+	conflicts []HierarchyConflict
+}
+
+func (e *wranglingError) Error() string { return e.message }
+
+func wranglingConflict(format string, args ...interface{}) error {
+	return &wranglingError{status: http.StatusConflict, message: fmt.Sprintf(format, args...)}
+}
+
+// respondWranglingError answers a refusal with its own status and anything
+// else with a 500 carrying fallback
+func respondWranglingError(c *gin.Context, err error, fallback string) {
+	var werr *wranglingError
+	if errors.As(err, &werr) {
+		body := gin.H{"error": werr.message}
+		if len(werr.conflicts) > 0 {
+			body["conflicts"] = werr.conflicts
+		}
+		c.JSON(werr.status, body)
+		return
+	}
+	log.Printf("%s: %v", fallback, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
+
+// wranglingPage reads limit and offset, defaulting and capping them
+func wranglingPage(c *gin.Context) (int, int) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultWranglingPage
+	}
+	if limit > maxWranglingPage {
+		limit = maxWranglingPage
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// synonymConflicts lists why synonym can't be attached to canonical. It is
+// the merge preview's hierarchy check, plus the target being canonical.
+func synonymConflicts(synonym, canonical MergePreviewTag, h mergeHierarchy) []HierarchyConflict {
+	conflicts := mergeHierarchyConflicts(synonym, canonical, h)
+	if !canonical.IsCanonical && !synonym.IsCanonical {
+		conflicts = append(conflicts, HierarchyConflict{
+			Type:        "target_not_canonical",
+			Description: fmt.Sprintf("%s is not canonical", canonical.Name),
+			TagID:       &canonical.ID,
+		})
+	}
+	return conflicts
+}
+
+// parentProblem says why parent can't be added above child, or "" if it can
+func parentProblem(child, parent MergePreviewTag) string {
+	switch {
+	case child.ID == parent.ID:
+		return "A tag can't be its own parent"
+	case !child.IsCanonical:
+		return fmt.Sprintf("%s is not canonical; only canonical tags can have parents", child.Name)
+	case !parent.IsCanonical:
+		return fmt.Sprintf("%s is not canonical", parent.Name)
+	}
+	for _, allowed := range validParentTypes[child.Type] {
+		if parent.Type == allowed {
+			return ""
+		}
+	}
+	return fmt.Sprintf("A %s tag can't sit under a %s tag", child.Type, parent.Type)
+}
+
+// lockWranglingTags loads tags for update, in ID order so concurrent
+// wrangles of the same tags can't deadlock. Missing tags are answered 404.
+func lockWranglingTags(ctx context.Context, tx *sql.Tx, ids ...uuid.UUID) (map[uuid.UUID]MergePreviewTag, error) {
+	idStrings := make([]string, len(ids))
+	for i, id := range ids {
+		idStrings[i] = id.String()
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, name, type, COALESCE(is_canonical, false), canonical_name, COALESCE(use_count, 0)
+		FROM tags WHERE id = ANY($1::uuid[])
+		ORDER BY id
+		FOR UPDATE`, pq.Array(idStrings))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := map[uuid.UUID]MergePreviewTag{}
+	for rows.Next() {
+		var tag MergePreviewTag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Type, &tag.IsCanonical, &tag.CanonicalName, &tag.UseCount); err != nil {
+			return nil, err
+		}
+		tags[tag.ID] = tag
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, ok := tags[id]; !ok {
+			return nil, &wranglingError{status: http.StatusNotFound, message: fmt.Sprintf("Tag %s not found", id)}
+		}
+	}
+	return tags, nil
+}
+
+// logWranglingAction appends an action to the wrangling log
+func logWranglingAction(ctx context.Context, tx *sql.Tx, action string, tag MergePreviewTag, related *MergePreviewTag, actor uuid.UUID, details interface{}) error {
+	data := []byte("{}")
+	if details != nil {
+		var err error
+		if data, err = json.Marshal(details); err != nil {
+			return err
+		}
+	}
+	var relatedID *uuid.UUID
+	var relatedName *string
+	if related != nil {
+		relatedID, relatedName = &related.ID, &related.Name
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO tag_wrangling_log (action, tag_id, tag_name, related_tag_id, related_tag_name, actor_id, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		action, tag.ID, tag.Name, relatedID, relatedName, actor, data)
+	return err
+}
+
+// makeCanonical makes a tag canonical, detaching it from the canonical it
+// was a synonym of
+func makeCanonical(ctx context.Context, tx *sql.Tx, tag MergePreviewTag, actor uuid.UUID) error {
+	if tag.IsCanonical {
+		return wranglingConflict("%s is already canonical", tag.Name)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE tags SET is_canonical = true, canonical_name = NULL, updated_at = NOW()
+		WHERE id = $1`, tag.ID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM tag_relationships WHERE child_tag_id = $1 AND relationship_type = 'synonym'`, tag.ID); err != nil {
+		return err
+	}
+
+	details := gin.H{}
+	if tag.CanonicalName != nil {
+		details["previous_canonical"] = *tag.CanonicalName
+	}
+	return logWranglingAction(ctx, tx, actionMakeCanonical, tag, nil, actor, details)
+}
+
+// removeCanonical returns a canonical tag to unwrangled. Its synonyms and
+// sub-tags must be moved first, or they'd be left pointing at nothing.
+func removeCanonical(ctx context.Context, tx *sql.Tx, tag MergePreviewTag, actor uuid.UUID) error {
+	if !tag.IsCanonical {
+		return wranglingConflict("%s is not canonical", tag.Name)
+	}
+	var dependents int
+	err := tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM tags WHERE canonical_name = $2)
+			+ (SELECT COUNT(*) FROM tag_relationships
+			   WHERE parent_tag_id = $1 AND relationship_type IN ('synonym', 'parent_child'))`,
+		tag.ID, tag.Name).Scan(&dependents)
+	if err != nil {
+		return err
+	}
+	if dependents > 0 {
+		return wranglingConflict("%s still has synonyms or sub-tags; move them before removing its canonical status", tag.Name)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE tags SET is_canonical = false, canonical_name = NULL, updated_at = NOW()
+		WHERE id = $1`, tag.ID); err != nil {
+		return err
+	}
+	return logWranglingAction(ctx, tx, actionRemoveCanonical, tag, nil, actor, nil)
+}
+
+// attachSynonym makes synonym a synonym of canonical after checking the
+// hierarchy allows it. The synonym's works, its own synonyms and its place
+// in the hierarchy all move to the canonical, and the moved works are
+// queued for reindexing.
+func attachSynonym(ctx context.Context, tx *sql.Tx, synonym, canonical MergePreviewTag, actor uuid.UUID) (SynonymResult, error) {
+	var result SynonymResult
+	if synonym.ID == canonical.ID {
+		return result, &wranglingError{status: http.StatusBadRequest, message: "A tag can't be a synonym of itself"}
+	}
+	if synonym.CanonicalName != nil && strings.EqualFold(*synonym.CanonicalName, canonical.Name) {
+		return result, wranglingConflict("%s is already a synonym of %s", synonym.Name, canonical.Name)
+	}
+	h, err := tagHierarchyBetween(tx, synonym.ID, canonical.ID)
+	if err != nil {
+		return result, err
+	}
+	if conflicts := synonymConflicts(synonym, canonical, h); len(conflicts) > 0 {
+		return result, &wranglingError{
+			status:    http.StatusConflict,
+			message:   fmt.Sprintf("%s can't become a synonym of %s", synonym.Name, canonical.Name),
+			conflicts: conflicts,
+		}
+	}
+
+	// Works tagged with the synonym move to the canonical
+	res, err := tx.ExecContext(ctx, `
+		WITH moved AS (
+			DELETE FROM work_tags WHERE tag_id = $1
+			RETURNING work_id, created_at, prominence, prominence_score, auto_assigned
+		), repointed AS (
+			INSERT INTO work_tags (work_id, tag_id, created_at, prominence, prominence_score, auto_assigned)
+			SELECT work_id, $2, created_at, prominence, prominence_score, auto_assigned FROM moved
+			ON CONFLICT (work_id, tag_id) DO NOTHING
+		)
+		INSERT INTO search_outbox (work_id, event_type)
+		SELECT work_id, 'upsert' FROM moved`, synonym.ID, canonical.ID)
+	if err != nil {
+		return result, err
+	}
+	works, _ := res.RowsAffected()
+	result.WorksRepointed = int(works)
+
+	// Tags that were synonyms of the synonym now point at the canonical
+	res, err = tx.ExecContext(ctx, `
+		UPDATE tags SET canonical_name = $2, updated_at = NOW()
+		WHERE canonical_name = $1 AND id != $3`, synonym.Name, canonical.Name, canonical.ID)
+	if err != nil {
+		return result, err
+	}
+	synonyms, _ := res.RowsAffected()
+	result.SynonymsRepointed = int(synonyms)
+
+	// Its parents, children and synonym relationships move across too
+	res, err = tx.ExecContext(ctx, `
+		INSERT INTO tag_relationships (parent_tag_id, child_tag_id, relationship_type, created_by)
+		SELECT CASE WHEN parent_tag_id = $1 THEN $2 ELSE parent_tag_id END,
+			CASE WHEN child_tag_id = $1 THEN $2 ELSE child_tag_id END,
+			relationship_type, $3
+		FROM tag_relationships
+		WHERE (parent_tag_id = $1 OR child_tag_id = $1)
+		AND relationship_type IN ('parent_child', 'synonym')
+		AND parent_tag_id != $2 AND child_tag_id != $2
+		AND NOT (child_tag_id = $1 AND relationship_type = 'synonym')
+		ON CONFLICT (parent_tag_id, child_tag_id) DO NOTHING`, synonym.ID, canonical.ID, actor)
+	if err != nil {
+		return result, err
+	}
+	relationships, _ := res.RowsAffected()
+	result.RelationshipsRepointed = int(relationships)
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM tag_relationships
+		WHERE (parent_tag_id = $1 OR child_tag_id = $1)
+		AND relationship_type IN ('parent_child', 'synonym')`, synonym.ID); err != nil {
+		return result, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO tag_relationships (parent_tag_id, child_tag_id, relationship_type, created_by)
+		VALUES ($1, $2, 'synonym', $3)
+		ON CONFLICT (parent_tag_id, child_tag_id) DO UPDATE SET relationship_type = 'synonym'`,
+		canonical.ID, synonym.ID, actor); err != nil {
+		return result, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE tags SET is_canonical = false, canonical_name = $2, updated_at = NOW()
+		WHERE id = $1`, synonym.ID, canonical.Name); err != nil {
+		return result, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE tags SET use_count = (SELECT COUNT(*) FROM work_tags wt WHERE wt.tag_id = tags.id)
+		WHERE id IN ($1, $2)`, synonym.ID, canonical.ID); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// addParent places child under parent
+func addParent(ctx context.Context, tx *sql.Tx, child, parent MergePreviewTag, actor uuid.UUID) error {
+	if problem := parentProblem(child, parent); problem != "" {
+		return &wranglingError{status: http.StatusBadRequest, message: problem}
+	}
+	h, err := tagHierarchyBetween(tx, child.ID, parent.ID)
+	if err != nil {
+		return err
+	}
+	if h.TargetIsDescendant {
+		return wranglingConflict("%s is already under %s, so it can't also be its parent", parent.Name, child.Name)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO tag_relationships (parent_tag_id, child_tag_id, relationship_type, created_by)
+		VALUES ($1, $2, 'parent_child', $3)
+		ON CONFLICT (parent_tag_id, child_tag_id) DO NOTHING`, parent.ID, child.ID, actor)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return wranglingConflict("%s is already related to %s", child.Name, parent.Name)
+	}
+	return logWranglingAction(ctx, tx, actionAddParent, child, &parent, actor, nil)
+}
+
+// clearWrangledTagCaches drops the cached records of tags a wrangle changed,
+// along with the cached hierarchy closures that may pass through them
+func (ts *TagService) clearWrangledTagCaches(ids ...uuid.UUID) {
+	for _, id := range ids {
+		ts.clearTagCache(id.String())
+	}
+	ts.invalidateTagHierarchy(context.Background())
+}
+
+// wrangle runs fn in a transaction and commits it
+func (ts *TagService) wrangle(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := ts.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// bindOptionalJSON binds a request body that may be empty
+func bindOptionalJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return false
+	}
+	return true
+}
+
+// MakeCanonical makes a tag canonical, or with {"canonical": false} returns
+// a canonical tag with no synonyms or sub-tags to unwrangled:
+// POST /api/v1/wrangling/tags/:tag_id/canonical
+func (ts *TagService) MakeCanonical(c *gin.Context) {
+	tagID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+	actor, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Canonical *bool `json:"canonical"`
+	}
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+	canonical := req.Canonical == nil || *req.Canonical
+
+	ctx := c.Request.Context()
+	err = ts.wrangle(ctx, func(tx *sql.Tx) error {
+		tags, err := lockWranglingTags(ctx, tx, tagID)
+		if err != nil {
+			return err
+		}
+		if canonical {
+			return makeCanonical(ctx, tx, tags[tagID], actor)
+		}
+		return removeCanonical(ctx, tx, tags[tagID], actor)
+	})
+	if err != nil {
+		respondWranglingError(c, err, "Failed to update canonical status")
+		return
+	}
+
+	ts.clearWrangledTagCaches(tagID)
+	c.JSON(http.StatusOK, gin.H{"tag_id": tagID, "canonical": canonical})
+}
+
+// CreateCanonicalSynonym attaches a tag, by ID or name, as a synonym of the
+// canonical tag. A name the archive doesn't know yet becomes a new tag of
+// the canonical's type.
+// POST /api/v1/wrangling/tags/:tag_id/synonym {"synonym_id": ...} or {"synonym_name": ...}
+func (ts *TagService) CreateCanonicalSynonym(c *gin.Context) {
+	canonicalID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+	actor, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		SynonymID   string `json:"synonym_id"`
+		SynonymName string `json:"synonym_name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	req.SynonymName = strings.TrimSpace(req.SynonymName)
+	if (req.SynonymID == "") == (req.SynonymName == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give one of synonym_id or synonym_name"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var synonymID uuid.UUID
+	var result SynonymResult
+	err = ts.wrangle(ctx, func(tx *sql.Tx) error {
+		if req.SynonymID != "" {
+			parsed, err := uuid.Parse(req.SynonymID)
+			if err != nil {
+				return &wranglingError{status: http.StatusBadRequest, message: "Invalid synonym_id"}
+			}
+			synonymID = parsed
+		} else {
+			err := tx.QueryRowContext(ctx, `
+				WITH created AS (
+					INSERT INTO tags (id, name, type, is_canonical, is_filterable, use_count, created_by)
+					SELECT $1, $2, type, false, true, 0, $4 FROM tags WHERE id = $3
+					ON CONFLICT (name) DO NOTHING
+					RETURNING id
+				)
+				SELECT id FROM created
+				UNION ALL
+				SELECT id FROM tags WHERE name = $2
+				LIMIT 1`, uuid.New(), req.SynonymName, canonicalID, actor).Scan(&synonymID)
+			if err == sql.ErrNoRows {
+				return &wranglingError{status: http.StatusNotFound, message: "Canonical tag not found"}
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		tags, err := lockWranglingTags(ctx, tx, canonicalID, synonymID)
+		if err != nil {
+			return err
+		}
+		synonym, canonical := tags[synonymID], tags[canonicalID]
+		if result, err = attachSynonym(ctx, tx, synonym, canonical, actor); err != nil {
+			return err
+		}
+		return logWranglingAction(ctx, tx, actionAddSynonym, synonym, &canonical, actor, result)
+	})
+	if err != nil {
+		respondWranglingError(c, err, "Failed to attach synonym")
+		return
+	}
+
+	ts.clearWrangledTagCaches(canonicalID, synonymID)
+	c.JSON(http.StatusOK, gin.H{"canonical_id": canonicalID, "synonym_id": synonymID, "result": result})
+}
+
+// AddParentTag places a canonical tag under another, such as a character
+// under its fandom or a fandom under its metatag:
+// POST /api/v1/wrangling/tags/:tag_id/parent {"parent_id": ...}
+func (ts *TagService) AddParentTag(c *gin.Context) {
+	childID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+	actor, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		ParentID string `json:"parent_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parent_id is required"})
+		return
+	}
+	parentID, err := uuid.Parse(req.ParentID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent_id"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ts.wrangle(ctx, func(tx *sql.Tx) error {
+		tags, err := lockWranglingTags(ctx, tx, childID, parentID)
+		if err != nil {
+			return err
+		}
+		return addParent(ctx, tx, tags[childID], tags[parentID], actor)
+	})
+	if err != nil {
+		respondWranglingError(c, err, "Failed to add parent tag")
+		return
+	}
+
+	ts.clearWrangledTagCaches(childID, parentID)
+	c.JSON(http.StatusCreated, gin.H{"tag_id": childID, "parent_id": parentID})
+}
+
+// RemoveParentTag takes a tag out from under a parent:
+// DELETE /api/v1/wrangling/tags/:tag_id/parent/:parent_id
+func (ts *TagService) RemoveParentTag(c *gin.Context) {
+	childID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+	parentID, err := uuid.Parse(c.Param("parent_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent ID"})
+		return
+	}
+	actor, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = ts.wrangle(ctx, func(tx *sql.Tx) error {
+		tags, err := lockWranglingTags(ctx, tx, childID, parentID)
+		if err != nil {
+			return err
+		}
+		child, parent := tags[childID], tags[parentID]
+		res, err := tx.ExecContext(ctx, `
+			DELETE FROM tag_relationships
+			WHERE parent_tag_id = $1 AND child_tag_id = $2 AND relationship_type = 'parent_child'`,
+			parentID, childID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return &wranglingError{status: http.StatusNotFound, message: fmt.Sprintf("%s is not a parent of %s", parent.Name, child.Name)}
+		}
+		return logWranglingAction(ctx, tx, actionRemoveParent, child, &parent, actor, nil)
+	})
+	if err != nil {
+		respondWranglingError(c, err, "Failed to remove parent tag")
+		return
+	}
+
+	ts.clearWrangledTagCaches(childID, parentID)
+	c.JSON(http.StatusOK, gin.H{"tag_id": childID, "parent_id": parentID})
+}
+
+// WrangleTag applies several wrangling steps to a tag at once: making it
+// canonical or a synonym, then adding parents. All of them apply or none.
+// POST /api/v1/wrangling/tags/:tag_id/wrangle {"canonical": true, "parent_ids": [...]}
+// POST /api/v1/wrangling/tags/:tag_id/wrangle {"synonym_of": ...}
+func (ts *TagService) WrangleTag(c *gin.Context) {
+	tagID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+	actor, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Canonical bool     `json:"canonical"`
+		SynonymOf string   `json:"synonym_of"`
+		ParentIDs []string `json:"parent_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if req.Canonical && req.SynonymOf != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A tag can't be both canonical and a synonym"})
+		return
+	}
+	if !req.Canonical && req.SynonymOf == "" && len(req.ParentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to wrangle: give canonical, synonym_of or parent_ids"})
+		return
+	}
+	if req.SynonymOf != "" && len(req.ParentIDs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Synonyms take their canonical's parents; add parents to the canonical instead"})
+		return
+	}
+
+	ids := []uuid.UUID{tagID}
+	var canonicalID uuid.UUID
+	if req.SynonymOf != "" {
+		if canonicalID, err = uuid.Parse(req.SynonymOf); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid synonym_of"})
+			return
+		}
+		ids = append(ids, canonicalID)
+	}
+	parentIDs := []uuid.UUID{}
+	for _, p := range req.ParentIDs {
+		parentID, err := uuid.Parse(p)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent ID"})
+			return
+		}
+		parentIDs = append(parentIDs, parentID)
+		ids = append(ids, parentID)
+	}
+
+	ctx := c.Request.Context()
+	response := gin.H{"tag_id": tagID}
+	err = ts.wrangle(ctx, func(tx *sql.Tx) error {
+		tags, err := lockWranglingTags(ctx, tx, ids...)
+		if err != nil {
+			return err
+		}
+		tag := tags[tagID]
+
+		switch {
+		case req.Canonical && !tag.IsCanonical:
+			if err := makeCanonical(ctx, tx, tag, actor); err != nil {
+				return err
+			}
+			tag.IsCanonical, tag.CanonicalName = true, nil
+			response["canonical"] = true
+		case req.SynonymOf != "":
+			canonical := tags[canonicalID]
+			result, err := attachSynonym(ctx, tx, tag, canonical, actor)
+			if err != nil {
+				return err
+			}
+			if err := logWranglingAction(ctx, tx, actionAddSynonym, tag, &canonical, actor, result); err != nil {
+				return err
+			}
+			response["synonym_of"] = canonicalID
+			response["result"] = result
+		}
+
+		for _, parentID := range parentIDs {
+			if err := addParent(ctx, tx, tag, tags[parentID], actor); err != nil {
+				return err
+			}
+		}
+		if len(parentIDs) > 0 {
+			response["parent_ids"] = parentIDs
+		}
+		return nil
+	})
+	if err != nil {
+		respondWranglingError(c, err, "Failed to wrangle tag")
+		return
+	}
+
+	ts.clearWrangledTagCaches(ids...)
+	c.JSON(http.StatusOK, response)
+}
+
+// tagRelatives lists the canonical tags directly above or below a tag
+func tagRelatives(ctx context.Context, tx *sql.Tx, tagID uuid.UUID, parents bool) ([]MergePreviewTag, error) {
+	join, match := "tr.parent_tag_id", "tr.child_tag_id"
+	if !parents {
+		join, match = match, join
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT t.id, t.name, t.type, COALESCE(t.is_canonical, false), t.canonical_name, COALESCE(t.use_count, 0)
+		FROM tag_relationships tr
+		JOIN tags t ON t.id = `+join+`
+		WHERE `+match+` = $1 AND tr.relationship_type = 'parent_child'
+		ORDER BY t.type, t.name`, tagID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []MergePreviewTag{}
+	for rows.Next() {
+		var tag MergePreviewTag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Type, &tag.IsCanonical, &tag.CanonicalName, &tag.UseCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// queryWranglingLog reads log entries, newest first
+func queryWranglingLog(ctx context.Context, q wranglingQuerier, where string, args ...interface{}) ([]WranglingLogEntry, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, action, tag_id, tag_name, related_tag_id, related_tag_name, actor_id, details, created_at
+		FROM tag_wrangling_log
+		WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []WranglingLogEntry{}
+	for rows.Next() {
+		var e WranglingLogEntry
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.Action, &e.TagID, &e.TagName, &e.RelatedTagID, &e.RelatedTagName, &e.ActorID, &details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Details = json.RawMessage(details)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetTagForWrangling shows a tag with everything a wrangler needs: its
+// synonyms, parents, children, open merge requests and recent history:
+// GET /api/v1/wrangling/tags/:tag_id
+func (ts *TagService) GetTagForWrangling(c *gin.Context) {
+	tagID, err := uuid.Parse(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	tx, err := ts.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	tag, err := loadMergePreviewTag(tx, tagID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	synonyms, err := tagSynonymNames(tx, tag)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load synonyms"})
+		return
+	}
+	parents, err := tagRelatives(ctx, tx, tagID, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load parent tags"})
+		return
+	}
+	children, err := tagRelatives(ctx, tx, tagID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load child tags"})
+		return
+	}
+	merges, err := loadTagMergeRequests(ctx, tx, "m.status = 'pending' AND (m.source_tag_id = $1 OR m.target_tag_id = $1) ORDER BY m.created_at, m.id", tagID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load merge requests"})
+		return
+	}
+	history, err := queryWranglingLog(ctx, tx, "tag_id = $1 OR related_tag_id = $1 ORDER BY id DESC LIMIT $2", tagID, wranglingTagLogSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load wrangling history"})
+		return
+	}
+
+	status := "unwrangled"
+	switch {
+	case tag.IsCanonical:
+		status = "canonical"
+	case tag.CanonicalName != nil:
+		status = "synonym"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tag":            tag,
+		"status":         status,
+		"synonyms":       synonyms,
+		"parents":        parents,
+		"children":       children,
+		"merge_requests": merges,
+		"history":        history,
+	})
+}
+
+// GetWranglingLog lists wrangling actions, newest first, optionally for one
+// tag or one wrangler; before_id pages back through older entries:
+// GET /api/v1/wrangling/log?tag_id=...&actor_id=...&before_id=...&limit=50
+func (ts *TagService) GetWranglingLog(c *gin.Context) {
+	limit, _ := wranglingPage(c)
+	conditions := []string{"true"}
+	args := []interface{}{}
+	for _, filter := range []struct{ param, column string }{
+		{"tag_id", "(tag_id = $%d OR related_tag_id = $%[1]d)"},
+		{"actor_id", "actor_id = $%d"},
+	} {
+		value := c.Query(filter.param)
+		if value == "" {
+			continue
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + filter.param})
+			return
+		}
+		args = append(args, id)
+		conditions = append(conditions, fmt.Sprintf(filter.column, len(args)))
+	}
+	if before := c.Query("before_id"); before != "" {
+		beforeID, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before_id"})
+			return
+		}
+		args = append(args, beforeID)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+	args = append(args, limit)
+
+	entries, err := queryWranglingLog(c.Request.Context(), ts.db,
+		fmt.Sprintf("%s ORDER BY id DESC LIMIT $%d", strings.Join(conditions, " AND "), len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load wrangling log"})
+		return
+	}
+
+	response := gin.H{"entries": entries, "limit": limit}
+	if len(entries) == limit {
+		response["next_before_id"] = entries[len(entries)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// loadTagMergeRequests reads merge requests with both tags
+func loadTagMergeRequests(ctx context.Context, q wranglingQuerier, where string, args ...interface{}) ([]TagMergeRequest, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT m.id, m.requested_by, m.reason, m.status, m.reviewed_by, m.review_note, m.created_at, m.reviewed_at,
+			s.id, s.name, s.type, COALESCE(s.is_canonical, false), s.canonical_name, COALESCE(s.use_count, 0),
+			t.id, t.name, t.type, COALESCE(t.is_canonical, false), t.canonical_name, COALESCE(t.use_count, 0)
+		FROM tag_merge_requests m
+		JOIN tags s ON s.id = m.source_tag_id
+		JOIN tags t ON t.id = m.target_tag_id
+		WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []TagMergeRequest{}
+	for rows.Next() {
+		var m TagMergeRequest
+		if err := rows.Scan(&m.ID, &m.RequestedBy, &m.Reason, &m.Status, &m.ReviewedBy, &m.ReviewNote, &m.CreatedAt, &m.ReviewedAt,
+			&m.Source.ID, &m.Source.Name, &m.Source.Type, &m.Source.IsCanonical, &m.Source.CanonicalName, &m.Source.UseCount,
+			&m.Target.ID, &m.Target.Name, &m.Target.Type, &m.Target.IsCanonical, &m.Target.CanonicalName, &m.Target.UseCount); err != nil {
+			return nil, err
+		}
+		requests = append(requests, m)
+	}
+	return requests, rows.Err()
+}
+
+// RequestTagMerge asks wranglers to merge one tag into another. A merge
+// already pending for the same pair is refused.
+// POST /api/v1/tags/merge {"source_id": ..., "target_id": ..., "reason": "..."}
+func (ts *TagService) RequestTagMerge(c *gin.Context) {
+	actor, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		SourceID string `json:"source_id" binding:"required"`
+		TargetID string `json:"target_id" binding:"required"`
+		Reason   string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source_id and target_id are required"})
+		return
+	}
+	sourceID, err := uuid.Parse(req.SourceID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source_id"})
+		return
+	}
+	targetID, err := uuid.Parse(req.TargetID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target_id"})
+		return
+	}
+	if sourceID == targetID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a tag into itself"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID := uuid.New()
+	reason := strings.TrimSpace(req.Reason)
+	err = ts.wrangle(ctx, func(tx *sql.Tx) error {
+		tags, err := lockWranglingTags(ctx, tx, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO tag_merge_requests (id, source_tag_id, target_tag_id, requested_by, reason)
+			VALUES ($1, $2, $3, $4, $5)`, requestID, sourceID, targetID, actor, reason)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return wranglingConflict("A merge of %s into %s is already pending", tags[sourceID].Name, tags[targetID].Name)
+		}
+		if err != nil {
+			return err
+		}
+		target := tags[targetID]
+		return logWranglingAction(ctx, tx, actionMergeRequested, tags[sourceID], &target, actor,
+			gin.H{"merge_request_id": requestID, "reason": reason})
+	})
+	if err != nil {
+		respondWranglingError(c, err, "Failed to request merge")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": requestID, "source_id": sourceID, "target_id": targetID, "status": "pending"})
+}
+
+// ListTagMergeRequests lists merge requests by status, pending by default:
+// GET /api/v1/wrangling/merge?status=pending
+func (ts *TagService) ListTagMergeRequests(c *gin.Context) {
+	status := c.DefaultQuery("status", "pending")
+	if status != "pending" && status != "approved" && status != "rejected" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, approved or rejected"})
+		return
+	}
+	limit, offset := wranglingPage(c)
+
+	requests, err := loadTagMergeRequests(c.Request.Context(), ts.db,
+		"m.status = $1 ORDER BY m.created_at, m.id LIMIT $2 OFFSET $3", status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load merge requests"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"merge_requests": requests, "status": status, "limit": limit, "offset": offset})
+}
+
+// ProcessTagMerge approves or rejects a pending merge. Approving makes the
+// source a synonym of the target, moving its works across; a merge the
+// hierarchy doesn't allow is refused with the conflicts. Wranglers can't
+// review their own requests.
+// PUT /api/v1/wrangling/merge/:merge_id {"action": "approve"|"reject", "note": "..."}
+func (ts *TagService) ProcessTagMerge(c *gin.Context) {
+	mergeID, err := uuid.Parse(c.Param("merge_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge request ID"})
+		return
+	}
+	actor, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Action string `json:"action" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Action != "approve" && req.Action != "reject") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be approve or reject"})
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+
+	ctx := c.Request.Context()
+	var sourceID, targetID uuid.UUID
+	var newStatus string
+	var result *SynonymResult
+	err = ts.wrangle(ctx, func(tx *sql.Tx) error {
+		var status string
+		var requestedBy *uuid.UUID
+		err := tx.QueryRowContext(ctx, `
+			SELECT source_tag_id, target_tag_id, status, requested_by
+			FROM tag_merge_requests WHERE id = $1
+			FOR UPDATE`, mergeID).Scan(&sourceID, &targetID, &status, &requestedBy)
+		if err == sql.ErrNoRows {
+			return &wranglingError{status: http.StatusNotFound, message: "Merge request not found"}
+		}
+		if err != nil {
+			return err
+		}
+		if status != "pending" {
+			return wranglingConflict("This merge request was already %s", status)
+		}
+		if requestedBy != nil && *requestedBy == actor {
+			return &wranglingError{status: http.StatusForbidden, message: "Merge requests must be reviewed by another wrangler"}
+		}
+
+		tags, err := lockWranglingTags(ctx, tx, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		source, target := tags[sourceID], tags[targetID]
+
+		action := actionMergeRejected
+		newStatus = "rejected"
+		details := gin.H{"merge_request_id": mergeID, "note": note}
+		if req.Action == "approve" {
+			action, newStatus = actionMergeApproved, "approved"
+			merged, err := attachSynonym(ctx, tx, source, target, actor)
+			if err != nil {
+				return err
+			}
+			result = &merged
+			details["result"] = merged
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE tag_merge_requests
+			SET status = $2, reviewed_by = $3, review_note = NULLIF($4, ''), reviewed_at = NOW()
+			WHERE id = $1`, mergeID, newStatus, actor, note); err != nil {
+			return err
+		}
+		return logWranglingAction(ctx, tx, action, source, &target, actor, details)
+	})
+	if err != nil {
+		respondWranglingError(c, err, "Failed to process merge request")
+		return
+	}
+
+	ts.clearWrangledTagCaches(sourceID, targetID)
+	response := gin.H{"id": mergeID, "status": newStatus}
+	if result != nil {
+		response["result"] = result
+	}
+	c.JSON(http.StatusOK, response)
+}
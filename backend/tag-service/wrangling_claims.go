@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// =============================================================================
+// WRANGLING QUEUE CLAIMS
+// The wrangling queue can be narrowed to one fandom and ordered by use or
+// age. A wrangler claims a batch of the tags it shows so nobody else is
+// handed them; by default the queue hides tags claimed by others. Claims
+// lapse after WRANGLING_CLAIM_TTL, and a sweeper clears lapsed ones so an
+// abandoned batch returns to the queue on its own.
+// =============================================================================
+
+const (
+	defaultClaimBatch = 20
+	maxClaimBatch     = 100
+)
+
+// Queue orders
+const (
+	queueSortUses   = "uses"
+	queueSortOldest = "oldest"
+	queueSortNewest = "newest"
+)
+
+// Which claims the queue shows
+const (
+	queueClaimsAvailable = "available" // unclaimed, or claimed by the caller
+	queueClaimsMine      = "mine"
+	queueClaimsUnclaimed = "unclaimed"
+	queueClaimsAll       = "all"
+)
+
+// queueOrders maps queue sorts to their ORDER BY clauses
+var queueOrders = map[string]string{
+	queueSortUses:   "t.use_count DESC, t.created_at, t.id",
+	queueSortOldest: "t.created_at, t.id",
+	queueSortNewest: "t.created_at DESC, t.id",
+}
+
+// QueueTag is an unwrangled tag in the queue, with its claim if it has one
+type QueueTag struct {
+	MergePreviewTag
+	ClaimedBy      *uuid.UUID `json:"claimed_by,omitempty"`
+	ClaimExpiresAt *time.Time `json:"claim_expires_at,omitempty"`
+}
+
+// wranglingQueueFilter selects the queue's tags
+type wranglingQueueFilter struct {
+	Type     string
+	FandomID *uuid.UUID
+	Claims   string
+	Wrangler uuid.UUID
+}
+
+// wranglingClaimTTL is how long a claim holds before the tag returns to
+// the queue
+func wranglingClaimTTL() time.Duration {
+	return getEnvDuration("WRANGLING_CLAIM_TTL", 2*time.Hour)
+}
+
+// queueSortOrder returns the ORDER BY for a sort, defaulting to most used
+func queueSortOrder(sort string) string {
+	if order, ok := queueOrders[sort]; ok {
+		return order
+	}
+	return queueOrders[queueSortUses]
+}
+
+// where builds the condition selecting unwrangled tags that pass the
+// filter. Tags are t and their live claim, if any, is claim.
+func (f wranglingQueueFilter) where() (string, []interface{}) {
+	where := `t.is_canonical = false AND t.canonical_name IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM tag_relationships tr
+			WHERE tr.child_tag_id = t.id AND tr.relationship_type = 'synonym'
+		)`
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.Type != "" {
+		where += " AND t.type = " + arg(f.Type)
+	}
+	if f.FandomID != nil {
+		// Unwrangled tags have no parents yet, so a tag belongs to a fandom
+		// through the works that use them together
+		where += ` AND EXISTS (
+			SELECT 1 FROM work_tags wt
+			JOIN work_tags ft ON ft.work_id = wt.work_id AND ft.tag_id = ` + arg(*f.FandomID) + `
+			WHERE wt.tag_id = t.id
+		)`
+	}
+	switch f.Claims {
+	case queueClaimsAll:
+	case queueClaimsUnclaimed:
+		where += " AND claim.tag_id IS NULL"
+	case queueClaimsMine:
+		where += " AND claim.wrangler_id = " + arg(f.Wrangler)
+	default:
+		where += " AND (claim.tag_id IS NULL OR claim.wrangler_id = " + arg(f.Wrangler) + ")"
+	}
+	return where, args
+}
+
+// queueFromClause joins each tag to its live claim
+const queueFromClause = `
+	FROM tags t
+	LEFT JOIN tag_wrangling_claims claim ON claim.tag_id = t.id AND claim.expires_at > NOW()`
+
+// bindQueueFilter reads the queue's filter from query parameters, writing
+// an error response and returning false if the fandom is invalid
+func bindQueueFilter(c *gin.Context, wrangler uuid.UUID) (wranglingQueueFilter, bool) {
+	filter := wranglingQueueFilter{Type: c.Query("type"), Claims: c.DefaultQuery("claimed", queueClaimsAvailable), Wrangler: wrangler}
+	if fandom := c.Query("fandom"); fandom != "" {
+		fandomID, err := uuid.Parse(fandom)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fandom ID"})
+			return filter, false
+		}
+		filter.FandomID = &fandomID
+	}
+	return filter, true
+}
+
+// GetWranglingQueue lists unwrangled tags, neither canonical nor a synonym:
+// GET /api/v1/wrangling/queue?type=character&fandom=123&sort=uses|oldest|newest&claimed=available|mine|unclaimed|all&limit=50&offset=0
+// By default it lists the most used tags that nobody else has claimed.
+func (ts *TagService) GetWranglingQueue(c *gin.Context) {
+	wrangler, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	filter, ok := bindQueueFilter(c, wrangler)
+	if !ok {
+		return
+	}
+	sort := c.DefaultQuery("sort", queueSortUses)
+	limit, offset := wranglingPage(c)
+	ctx := c.Request.Context()
+
+	where, args := filter.where()
+	var total, pendingMerges int
+	err := ts.db.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) `+queueFromClause+` WHERE `+where+`),
+			(SELECT COUNT(*) FROM tag_merge_requests WHERE status = 'pending')`, args...).Scan(&total, &pendingMerges)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count the wrangling queue"})
+		return
+	}
+
+	rows, err := ts.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT t.id, t.name, t.type, COALESCE(t.is_canonical, false), t.canonical_name, COALESCE(t.use_count, 0),
+			claim.wrangler_id, claim.expires_at
+		%s
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d`, queueFromClause, where, queueSortOrder(sort), len(args)+1, len(args)+2),
+		append(args, limit, offset)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the wrangling queue"})
+		return
+	}
+	defer rows.Close()
+
+	tags := []QueueTag{}
+	for rows.Next() {
+		var tag QueueTag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Type, &tag.IsCanonical, &tag.CanonicalName, &tag.UseCount,
+			&tag.ClaimedBy, &tag.ClaimExpiresAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the wrangling queue"})
+			return
+		}
+		tags = append(tags, tag)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tags":           tags,
+		"total":          total,
+		"limit":          limit,
+		"offset":         offset,
+		"pending_merges": pendingMerges,
+	})
+}
+
+// ClaimWranglingTags claims the next unclaimed tags in the queue, or the
+// given tags, for the caller:
+// POST /api/v1/wrangling/queue/claim {"count": 20, "type": "character", "fandom": "...", "sort": "oldest"} or {"tag_ids": [...]}
+// Claiming tags the caller already holds extends them. Named tags claimed
+// by someone else are left out and listed as taken.
+func (ts *TagService) ClaimWranglingTags(c *gin.Context) {
+	wrangler, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Count    int         `json:"count"`
+		Type     string      `json:"type"`
+		FandomID *uuid.UUID  `json:"fandom"`
+		Sort     string      `json:"sort"`
+		TagIDs   []uuid.UUID `json:"tag_ids"`
+	}
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+	if req.Count <= 0 {
+		req.Count = defaultClaimBatch
+	}
+	if req.Count > maxClaimBatch || len(req.TagIDs) > maxClaimBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many tags to claim at once", "max": maxClaimBatch})
+		return
+	}
+
+	// A batch is new tags; naming tags may also renew the caller's own
+	filter := wranglingQueueFilter{Type: req.Type, FandomID: req.FandomID, Claims: queueClaimsUnclaimed, Wrangler: wrangler}
+	limit := req.Count
+	if len(req.TagIDs) > 0 {
+		filter.Claims = queueClaimsAvailable
+		limit = len(req.TagIDs)
+	}
+	where, args := filter.where()
+	if len(req.TagIDs) > 0 {
+		args = append(args, pq.Array(uuidStrings(req.TagIDs)))
+		where += fmt.Sprintf(" AND t.id = ANY($%d::uuid[])", len(args))
+	}
+
+	// SKIP LOCKED lets wranglers claiming at the same moment take
+	// different tags instead of waiting on, then colliding over, the same ones
+	expiresAt := time.Now().Add(wranglingClaimTTL())
+	args = append(args, wrangler, expiresAt)
+	query := fmt.Sprintf(`
+		WITH picked AS (
+			SELECT t.id %s
+			WHERE %s
+			ORDER BY %s
+			LIMIT %d
+			FOR UPDATE OF t SKIP LOCKED
+		)
+		INSERT INTO tag_wrangling_claims (tag_id, wrangler_id, claimed_at, expires_at)
+		SELECT id, $%d, NOW(), $%d FROM picked
+		ON CONFLICT (tag_id) DO UPDATE SET
+			wrangler_id = EXCLUDED.wrangler_id,
+			claimed_at = CASE WHEN tag_wrangling_claims.wrangler_id = EXCLUDED.wrangler_id
+				THEN tag_wrangling_claims.claimed_at ELSE EXCLUDED.claimed_at END,
+			expires_at = EXCLUDED.expires_at
+		WHERE tag_wrangling_claims.wrangler_id = EXCLUDED.wrangler_id
+			OR tag_wrangling_claims.expires_at <= NOW()
+		RETURNING tag_id`, queueFromClause, where, queueSortOrder(req.Sort), limit, len(args)-1, len(args))
+
+	rows, err := ts.db.QueryContext(c.Request.Context(), query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim tags"})
+		return
+	}
+	defer rows.Close()
+
+	claimed := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim tags"})
+			return
+		}
+		claimed = append(claimed, id)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim tags"})
+		return
+	}
+
+	response := gin.H{"claimed": claimed, "expires_at": expiresAt}
+	if len(req.TagIDs) > 0 {
+		got := map[uuid.UUID]bool{}
+		for _, id := range claimed {
+			got[id] = true
+		}
+		taken := []uuid.UUID{}
+		for _, id := range req.TagIDs {
+			if !got[id] {
+				taken = append(taken, id)
+			}
+		}
+		response["taken"] = taken
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ReleaseWranglingTags gives the caller's claimed tags back to the queue:
+// POST /api/v1/wrangling/queue/release {"tag_ids": [...]}, or {} for all
+func (ts *TagService) ReleaseWranglingTags(c *gin.Context) {
+	wrangler, ok := requestUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		TagIDs []uuid.UUID `json:"tag_ids"`
+	}
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	var ids interface{}
+	if len(req.TagIDs) > 0 {
+		ids = pq.Array(uuidStrings(req.TagIDs))
+	}
+	res, err := ts.db.ExecContext(c.Request.Context(), `
+		DELETE FROM tag_wrangling_claims
+		WHERE wrangler_id = $1 AND ($2::uuid[] IS NULL OR tag_id = ANY($2::uuid[]))`, wrangler, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release tags"})
+		return
+	}
+	released, _ := res.RowsAffected()
+	c.JSON(http.StatusOK, gin.H{"released": released})
+}
+
+// startWranglingClaimSweeper deletes lapsed claims every interval until ctx
+// is cancelled. Lapsed claims are already ignored; sweeping keeps the table
+// to the claims that matter.
+func (ts *TagService) startWranglingClaimSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := ts.db.ExecContext(ctx, "DELETE FROM tag_wrangling_claims WHERE expires_at <= NOW()")
+			if err != nil {
+				log.Printf("Wrangling claim sweep failed: %v", err)
+				continue
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				log.Printf("Released %d lapsed wrangling claims", n)
+			}
+		}
+	}
+}
+
+// uuidStrings formats IDs for a uuid[] query parameter
+func uuidStrings(ids []uuid.UUID) []string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strs
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueSortOrder(t *testing.T) {
+	assert.Equal(t, "t.use_count DESC, t.created_at, t.id", queueSortOrder(""))
+	assert.Equal(t, "t.created_at, t.id", queueSortOrder(queueSortOldest))
+	assert.Equal(t, "t.created_at DESC, t.id", queueSortOrder(queueSortNewest))
+	assert.Equal(t, queueSortOrder(queueSortUses), queueSortOrder("name; DROP TABLE tags"), "unknown sorts never reach the query")
+}
+
+func TestWranglingQueueFilter(t *testing.T) {
+	wrangler := uuid.New()
+	fandom := uuid.New()
+
+	where, args := wranglingQueueFilter{Wrangler: wrangler}.where()
+	assert.Contains(t, where, "claim.tag_id IS NULL OR claim.wrangler_id = $1", "the default hides other wranglers' claims")
+	assert.Equal(t, []interface{}{wrangler}, args)
+
+	where, args = wranglingQueueFilter{Type: "character", FandomID: &fandom, Claims: queueClaimsMine, Wrangler: wrangler}.where()
+	assert.Contains(t, where, "t.type = $1")
+	assert.Contains(t, where, "ft.tag_id = $2")
+	assert.Contains(t, where, "claim.wrangler_id = $3")
+	assert.Equal(t, []interface{}{"character", fandom, wrangler}, args)
+
+	where, args = wranglingQueueFilter{Claims: queueClaimsUnclaimed, Wrangler: wrangler}.where()
+	assert.Contains(t, where, "claim.tag_id IS NULL")
+	assert.Empty(t, args)
+
+	where, args = wranglingQueueFilter{Claims: queueClaimsAll, Wrangler: wrangler}.where()
+	assert.NotContains(t, where, "claim.")
+	assert.Empty(t, args)
+}
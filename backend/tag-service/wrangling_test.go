@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynonymConflicts(t *testing.T) {
+	canonical := MergePreviewTag{ID: uuid.New(), Name: "Hermione Granger", Type: "character", IsCanonical: true}
+	unwrangled := MergePreviewTag{ID: uuid.New(), Name: "Hermione", Type: "character"}
+
+	assert.Empty(t, synonymConflicts(unwrangled, canonical, mergeHierarchy{}))
+
+	other := MergePreviewTag{ID: uuid.New(), Name: "Mione", Type: "character"}
+	conflicts := synonymConflicts(unwrangled, other, mergeHierarchy{})
+	assert.Len(t, conflicts, 1)
+	assert.Equal(t, "target_not_canonical", conflicts[0].Type)
+
+	// A canonical source is already reported by the merge preview's check
+	source := MergePreviewTag{ID: uuid.New(), Name: "Hermione G.", Type: "character", IsCanonical: true}
+	conflicts = synonymConflicts(source, other, mergeHierarchy{})
+	assert.Len(t, conflicts, 1)
+	assert.Equal(t, "canonical_into_synonym", conflicts[0].Type)
+
+	conflicts = synonymConflicts(unwrangled, canonical, mergeHierarchy{TargetIsDescendant: true})
+	assert.Len(t, conflicts, 1)
+	assert.Equal(t, "cycle", conflicts[0].Type)
+}
+
+func TestParentProblem(t *testing.T) {
+	fandom := MergePreviewTag{ID: uuid.New(), Name: "Harry Potter - J. K. Rowling", Type: "fandom", IsCanonical: true}
+	metatag := MergePreviewTag{ID: uuid.New(), Name: "Wizarding World", Type: "fandom", IsCanonical: true}
+	character := MergePreviewTag{ID: uuid.New(), Name: "Hermione Granger", Type: "character", IsCanonical: true}
+	relationship := MergePreviewTag{ID: uuid.New(), Name: "Hermione Granger/Ron Weasley", Type: "relationship", IsCanonical: true}
+	freeform := MergePreviewTag{ID: uuid.New(), Name: "Fluff", Type: "freeform", IsCanonical: true}
+
+	assert.Empty(t, parentProblem(character, fandom))
+	assert.Empty(t, parentProblem(fandom, metatag))
+	assert.Empty(t, parentProblem(relationship, character))
+	assert.Empty(t, parentProblem(freeform, fandom))
+
+	assert.Contains(t, parentProblem(fandom, fandom), "own parent")
+	assert.Contains(t, parentProblem(fandom, character), "can't sit under")
+	assert.Contains(t, parentProblem(character, freeform), "can't sit under")
+
+	synonym := MergePreviewTag{ID: uuid.New(), Name: "Hermione", Type: "character"}
+	assert.Contains(t, parentProblem(synonym, fandom), "Hermione is not canonical")
+	assert.Contains(t, parentProblem(character, MergePreviewTag{ID: uuid.New(), Name: "HP", Type: "fandom"}), "HP is not canonical")
+}
+
+func TestValidParentTypesAllowMetatags(t *testing.T) {
+	for tagType, parents := range validParentTypes {
+		assert.Contains(t, parents, tagType, "%s tags should be able to have metatags", tagType)
+		assert.Contains(t, parents, "fandom", "%s tags should be able to sit under a fandom", tagType)
+	}
+}
@@ -0,0 +1,830 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/audit"
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+	"nuclear-ao3/shared/notificationstream"
+)
+
+// =============================================================================
+// ABUSE TICKETS
+// Reporting a work, one of its chapters or a comment opens a ticket in
+// abuse_tickets (migration 064), with a snapshot of the reported content as
+// evidence. Admins triage tickets by priority, assign them, and move them
+// open -> in_progress -> resolved or dismissed, keeping internal notes on
+// the way. Status changes and notes marked public are shown to the
+// reporter, who is notified of each.
+// =============================================================================
+
+// Ticket statuses
+const (
+	ticketOpen       = "open"
+	ticketInProgress = "in_progress"
+	ticketResolved   = "resolved"
+	ticketDismissed  = "dismissed"
+)
+
+// Kinds of ticket update
+const (
+	ticketUpdateNote       = "note"
+	ticketUpdateStatus     = "status"
+	ticketUpdateAssignment = "assignment"
+	ticketUpdatePriority   = "priority"
+)
+
+// maxTicketText caps report descriptions and notes
+const maxTicketText = 5000
+
+// ticketTransitions lists the statuses each status may move to. Obvious
+// junk can be dismissed without being picked up, and closed tickets can be
+// reopened.
+var ticketTransitions = map[string][]string{
+	ticketOpen:       {ticketInProgress, ticketDismissed},
+	ticketInProgress: {ticketOpen, ticketResolved, ticketDismissed},
+	ticketResolved:   {ticketOpen},
+	ticketDismissed:  {ticketOpen},
+}
+
+// ticketCategories are the reasons each kind of content can be reported for
+var ticketCategories = map[string][]string{
+	"work":    {"copyright", "plagiarism", "harassment", "inappropriate_content", "wrong_rating", "missing_warnings", "spam", "other"},
+	"comment": {"spam", "harassment", "off_topic", "inappropriate", "hate_speech", "doxxing", "other"},
+}
+
+// ticketPriorities from least to most pressing
+var ticketPriorities = []string{"low", "normal", "high", "urgent"}
+
+// categoryPriorities are the priorities new tickets start at; anything not
+// listed is normal
+var categoryPriorities = map[string]string{
+	"doxxing":     "urgent",
+	"harassment":  "high",
+	"hate_speech": "high",
+	"spam":        "low",
+}
+
+// ticketPriorityOrder sorts the queue most pressing first
+const ticketPriorityOrder = `CASE t.priority WHEN 'urgent' THEN 0 WHEN 'high' THEN 1 WHEN 'normal' THEN 2 ELSE 3 END`
+
+// AbuseTicket is a report being worked by the abuse team
+type AbuseTicket struct {
+	ID          uuid.UUID       `json:"id"`
+	TargetType  string          `json:"target_type"`
+	WorkID      *uuid.UUID      `json:"work_id,omitempty"`
+	ChapterID   *uuid.UUID      `json:"chapter_id,omitempty"`
+	CommentID   *uuid.UUID      `json:"comment_id,omitempty"`
+	ReporterID  *uuid.UUID      `json:"reporter_id,omitempty"`
+	Category    string          `json:"category"`
+	Description string          `json:"description"`
+	Priority    string          `json:"priority,omitempty"`
+	Status      string          `json:"status"`
+	AssigneeID  *uuid.UUID      `json:"assignee_id,omitempty"`
+	Resolution  *string         `json:"resolution,omitempty"`
+	Evidence    json.RawMessage `json:"evidence,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	ResolvedAt  *time.Time      `json:"resolved_at,omitempty"`
+	Updates     []TicketUpdate  `json:"updates,omitempty"`
+}
+
+// TicketUpdate is a note or change on a ticket
+type TicketUpdate struct {
+	ID        uuid.UUID  `json:"id"`
+	AuthorID  *uuid.UUID `json:"author_id,omitempty"`
+	Kind      string     `json:"kind"`
+	Body      string     `json:"body,omitempty"`
+	FromValue *string    `json:"from_value,omitempty"`
+	ToValue   *string    `json:"to_value,omitempty"`
+	Internal  bool       `json:"internal"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ticketTarget is what a report is about
+type ticketTarget struct {
+	Type      string
+	WorkID    *uuid.UUID
+	ChapterID *uuid.UUID
+	CommentID *uuid.UUID
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validTicketTransition reports whether a ticket may move between statuses
+func validTicketTransition(from, to string) bool {
+	return containsValue(ticketTransitions[from], to)
+}
+
+// defaultTicketPriority is the priority a new ticket of a category starts at
+func defaultTicketPriority(category string) string {
+	if priority, ok := categoryPriorities[category]; ok {
+		return priority
+	}
+	return "normal"
+}
+
+// forReporter is the ticket as its reporter sees it: no evidence, triage
+// details or internal updates, and updates don't name the admin
+func (t *AbuseTicket) forReporter() *AbuseTicket {
+	view := *t
+	view.ReporterID, view.AssigneeID, view.Priority, view.Evidence = nil, nil, "", nil
+	view.Updates = []TicketUpdate{}
+	for _, update := range t.Updates {
+		if update.Internal {
+			continue
+		}
+		update.AuthorID = nil
+		view.Updates = append(view.Updates, update)
+	}
+	return &view
+}
+
+const abuseTicketColumns = `
+	t.id, t.target_type, t.work_id, t.chapter_id, t.comment_id, t.reporter_id, t.category, t.description,
+	t.priority, t.status, t.assignee_id, t.resolution, t.evidence, t.created_at, t.updated_at, t.resolved_at`
+
+func scanAbuseTicket(row interface{ Scan(...interface{}) error }) (*AbuseTicket, error) {
+	var t AbuseTicket
+	var evidence []byte
+	err := row.Scan(&t.ID, &t.TargetType, &t.WorkID, &t.ChapterID, &t.CommentID, &t.ReporterID, &t.Category,
+		&t.Description, &t.Priority, &t.Status, &t.AssigneeID, &t.Resolution, &evidence, &t.CreatedAt,
+		&t.UpdatedAt, &t.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.Evidence = evidence
+	return &t, nil
+}
+
+type ticketQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadAbuseTicket loads a ticket with its updates, oldest first
+func loadAbuseTicket(ctx context.Context, q ticketQuerier, ticketID uuid.UUID) (*AbuseTicket, error) {
+	ticket, err := scanAbuseTicket(q.QueryRowContext(ctx,
+		"SELECT "+abuseTicketColumns+" FROM abuse_tickets t WHERE t.id = $1", ticketID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, author_id, kind, body, from_value, to_value, internal, created_at
+		FROM abuse_ticket_updates WHERE ticket_id = $1
+		ORDER BY created_at, id`, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ticket.Updates = []TicketUpdate{}
+	for rows.Next() {
+		var u TicketUpdate
+		if err := rows.Scan(&u.ID, &u.AuthorID, &u.Kind, &u.Body, &u.FromValue, &u.ToValue, &u.Internal, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		ticket.Updates = append(ticket.Updates, u)
+	}
+	return ticket, rows.Err()
+}
+
+// captureEvidence snapshots the reported content. For a comment it also
+// fills in the work and chapter the comment is on.
+func captureEvidence(ctx context.Context, tx *sql.Tx, target *ticketTarget) ([]byte, error) {
+	evidence := map[string]interface{}{"captured_at": time.Now().UTC()}
+
+	if target.CommentID != nil {
+		var comment []byte
+		err := tx.QueryRowContext(ctx, `
+			SELECT c.work_id, c.chapter_id, json_build_object(
+				'id', c.id, 'content', c.content, 'commenter_id', c.user_id, 'commenter', u.username,
+				'is_anonymous', c.is_anonymous, 'parent_comment_id', c.parent_comment_id,
+				'created_at', c.created_at, 'updated_at', c.updated_at)
+			FROM comments c LEFT JOIN users u ON u.id = c.user_id
+			WHERE c.id = $1`, *target.CommentID).Scan(&target.WorkID, &target.ChapterID, &comment)
+		if err != nil {
+			return nil, err
+		}
+		evidence["comment"] = json.RawMessage(comment)
+	}
+
+	if target.WorkID != nil {
+		var work []byte
+		err := tx.QueryRowContext(ctx, `
+			SELECT json_build_object(
+				'id', w.id, 'title', w.title, 'summary', w.summary, 'notes', w.notes, 'rating', w.rating,
+				'warnings', w.archive_warning, 'language', w.language, 'creator_id', w.user_id,
+				'creator', u.username, 'is_anonymous', COALESCE(w.is_anonymous, false),
+				'tags', COALESCE((
+					SELECT json_agg(json_build_object('name', tg.name, 'type', tg.type) ORDER BY tg.type, tg.name)
+					FROM work_tags wt JOIN tags tg ON tg.id = wt.tag_id WHERE wt.work_id = w.id), '[]'::json),
+				'updated_at', w.updated_at)
+			FROM works w LEFT JOIN users u ON u.id = w.user_id
+			WHERE w.id = $1`, *target.WorkID).Scan(&work)
+		if err != nil {
+			return nil, err
+		}
+		evidence["work"] = json.RawMessage(work)
+	}
+
+	// A report of a chapter keeps its text; comments keep just their own
+	if target.Type == "work" && target.ChapterID != nil {
+		var chapter []byte
+		err := tx.QueryRowContext(ctx, `
+			SELECT json_build_object(
+				'id', id, 'number', chapter_number, 'title', title, 'summary', summary, 'notes', notes,
+				'end_notes', end_notes, 'content', content, 'updated_at', updated_at)
+			FROM chapters WHERE id = $1 AND work_id = $2`, *target.ChapterID, *target.WorkID).Scan(&chapter)
+		if err != nil {
+			return nil, err
+		}
+		evidence["chapter"] = json.RawMessage(chapter)
+	}
+
+	return json.Marshal(evidence)
+}
+
+// fileAbuseTicket opens a ticket for a report of target
+func (ws *WorkService) fileAbuseTicket(c *gin.Context, target ticketTarget) {
+	reporterID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Reason      string     `json:"reason" binding:"required"`
+		Description string     `json:"description"`
+		ChapterID   *uuid.UUID `json:"chapter_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	categories := ticketCategories[target.Type]
+	if !containsValue(categories, req.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report reason", "valid_reasons": categories})
+		return
+	}
+	req.Description = strings.TrimSpace(req.Description)
+	if len(req.Description) > maxTicketText {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Report description is too long", "max": maxTicketText})
+		return
+	}
+	if target.Type == "work" {
+		target.ChapterID = req.ChapterID
+	}
+
+	ctx := c.Request.Context()
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+	defer tx.Rollback()
+
+	evidence, err := captureEvidence(ctx, tx, &target)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reported content not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+
+	// One live report per reporter for the same content
+	var liveID uuid.UUID
+	err = tx.QueryRowContext(ctx, `
+		SELECT id FROM abuse_tickets
+		WHERE reporter_id = $1 AND status IN ('open', 'in_progress') AND target_type = $2
+			AND work_id IS NOT DISTINCT FROM $3 AND chapter_id IS NOT DISTINCT FROM $4
+			AND comment_id IS NOT DISTINCT FROM $5
+		LIMIT 1`, reporterID, target.Type, target.WorkID, target.ChapterID, target.CommentID).Scan(&liveID)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "You've already reported this and the report is still open",
+			"code":      "DUPLICATE_REPORT",
+			"ticket_id": liveID,
+		})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+
+	ticketID := uuid.New()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO abuse_tickets (id, target_type, work_id, chapter_id, comment_id, reporter_id, reporter_ip,
+			category, description, priority, evidence)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		ticketID, target.Type, target.WorkID, target.ChapterID, target.CommentID, reporterID, c.ClientIP(),
+		req.Reason, req.Description, defaultTicketPriority(req.Reason), evidence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+	ticket, err := loadAbuseTicket(ctx, tx, ticketID)
+	if err != nil || tx.Commit() != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Report submitted successfully", "ticket": ticket.forReporter()})
+}
+
+// ReportWork reports a work, or one of its chapters:
+// POST /api/v1/works/:work_id/report {"reason": "wrong_rating", "description": "...", "chapter_id": "..."}
+func (ws *WorkService) ReportWork(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+	ws.fileAbuseTicket(c, ticketTarget{Type: "work", WorkID: &workID})
+}
+
+// ReportComment reports a comment:
+// POST /api/v1/comments/:comment_id/report {"reason": "harassment", "description": "..."}
+func (ws *WorkService) ReportComment(c *gin.Context) {
+	commentID, err := uuid.Parse(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+	ws.fileAbuseTicket(c, ticketTarget{Type: "comment", CommentID: &commentID})
+}
+
+// GetMyReports lists the caller's reports, newest first:
+// GET /api/v1/my/reports?page=1&limit=20
+func (ws *WorkService) GetMyReports(c *gin.Context) {
+	reporterID := c.GetString("user_id")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	ctx := c.Request.Context()
+	var total int
+	if err := ws.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM abuse_tickets WHERE reporter_id = $1", reporterID).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count reports"})
+		return
+	}
+
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT `+abuseTicketColumns+` FROM abuse_tickets t
+		WHERE t.reporter_id = $1
+		ORDER BY t.created_at DESC, t.id
+		LIMIT $2 OFFSET $3`, reporterID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+	defer rows.Close()
+
+	tickets := []*AbuseTicket{}
+	for rows.Next() {
+		ticket, err := scanAbuseTicket(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read reports"})
+			return
+		}
+		view := ticket.forReporter()
+		view.Updates = nil
+		tickets = append(tickets, view)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports": tickets,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+// GetMyReport shows one of the caller's reports with its status updates:
+// GET /api/v1/my/reports/:ticket_id
+func (ws *WorkService) GetMyReport(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("ticket_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+	ticket, err := loadAbuseTicket(c.Request.Context(), ws.db, ticketID)
+	if err == sql.ErrNoRows || (err == nil && (ticket.ReporterID == nil || ticket.ReporterID.String() != c.GetString("user_id"))) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch report"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"report": ticket.forReporter()})
+}
+
+// AdminListTickets lists tickets, most pressing then oldest first:
+// GET /api/v1/admin/tickets?status=live&priority=high&category=spam&target_type=work&assignee=me|unassigned|<id>&page=1&limit=20
+// status is a ticket status, live (open or in progress, the default) or all.
+func (ws *WorkService) AdminListTickets(c *gin.Context) {
+	filters := []string{}
+	args := []interface{}{}
+	addFilter := func(clause string, value interface{}) {
+		args = append(args, value)
+		filters = append(filters, fmt.Sprintf(clause, len(args)))
+	}
+
+	switch status := c.DefaultQuery("status", "live"); status {
+	case "all":
+	case "live":
+		filters = append(filters, "t.status IN ('open', 'in_progress')")
+	default:
+		if _, ok := ticketTransitions[status]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+			return
+		}
+		addFilter("t.status = $%d", status)
+	}
+	if priority := c.Query("priority"); priority != "" {
+		if !containsValue(ticketPriorities, priority) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid priority", "valid_priorities": ticketPriorities})
+			return
+		}
+		addFilter("t.priority = $%d", priority)
+	}
+	if category := c.Query("category"); category != "" {
+		addFilter("t.category = $%d", category)
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		if _, ok := ticketCategories[targetType]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target type"})
+			return
+		}
+		addFilter("t.target_type = $%d", targetType)
+	}
+	switch assignee := c.Query("assignee"); assignee {
+	case "":
+	case "unassigned":
+		filters = append(filters, "t.assignee_id IS NULL")
+	case "me":
+		addFilter("t.assignee_id = $%d", c.GetString("user_id"))
+	default:
+		assigneeID, err := uuid.Parse(assignee)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignee"})
+			return
+		}
+		addFilter("t.assignee_id = $%d", assigneeID)
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	where := ""
+	if len(filters) > 0 {
+		where = " WHERE " + strings.Join(filters, " AND ")
+	}
+	ctx := c.Request.Context()
+	var total int
+	if err := ws.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM abuse_tickets t"+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count tickets"})
+		return
+	}
+
+	rows, err := ws.db.QueryContext(ctx, "SELECT "+abuseTicketColumns+" FROM abuse_tickets t"+where+
+		" ORDER BY "+ticketPriorityOrder+", t.created_at, t.id"+
+		fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2),
+		append(args, limit, (page-1)*limit)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tickets"})
+		return
+	}
+	defer rows.Close()
+
+	tickets := []*AbuseTicket{}
+	for rows.Next() {
+		ticket, err := scanAbuseTicket(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read tickets"})
+			return
+		}
+		// Evidence can be a whole chapter; it's shown on the ticket itself
+		ticket.Evidence = nil
+		tickets = append(tickets, ticket)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tickets": tickets,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+// AdminGetTicket shows a ticket with its evidence and every update:
+// GET /api/v1/admin/tickets/:ticket_id
+func (ws *WorkService) AdminGetTicket(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("ticket_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+	ticket, err := loadAbuseTicket(c.Request.Context(), ws.db, ticketID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ticket"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ticket": ticket})
+}
+
+// updateTicket locks a ticket and lets change apply an update to it, then
+// records the update and notifies the reporter of public ones. change
+// writes its own error response and returns nil to abandon the update.
+func (ws *WorkService) updateTicket(c *gin.Context, change func(ctx context.Context, tx *sql.Tx, ticket *AbuseTicket, actorID uuid.UUID) *TicketUpdate) {
+	ticketID, err := uuid.Parse(c.Param("ticket_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+	actorID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket"})
+		return
+	}
+	defer tx.Rollback()
+
+	ticket, err := scanAbuseTicket(tx.QueryRowContext(ctx,
+		"SELECT "+abuseTicketColumns+" FROM abuse_tickets t WHERE t.id = $1 FOR UPDATE", ticketID))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket"})
+		return
+	}
+
+	update := change(ctx, tx, ticket, actorID)
+	if update == nil {
+		return
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO abuse_ticket_updates (ticket_id, author_id, kind, body, from_value, to_value, internal)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		ticket.ID, actorID, update.Kind, update.Body, update.FromValue, update.ToValue, update.Internal)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "UPDATE abuse_tickets SET updated_at = NOW() WHERE id = $1", ticket.ID)
+	}
+	if err == nil && !update.Internal && ticket.ReporterID != nil {
+		err = notificationstream.Enqueue(ctx, tx, reportUpdatedEvent(ticket, update))
+	}
+	if err == nil {
+		err = audit.RecordRequest(c, tx, auditService, audit.Entry{
+			Action: "ticket." + update.Kind, TargetType: "abuse_ticket", TargetID: ticket.ID.String(), Reason: update.Body,
+		}, ticketUpdateSnapshot(update.Kind, update.FromValue), ticketUpdateSnapshot(update.Kind, update.ToValue))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket"})
+		return
+	}
+
+	updated, err := loadAbuseTicket(ctx, tx, ticket.ID)
+	if err != nil || tx.Commit() != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ticket": updated})
+}
+
+// ticketUpdateSnapshot is one side of a ticket update for the audit log.
+// Notes change nothing, so they have no snapshot.
+func ticketUpdateSnapshot(kind string, value *string) interface{} {
+	if kind == ticketUpdateNote {
+		return nil
+	}
+	return gin.H{kind: value}
+}
+
+// reportUpdatedEvent tells a reporter their report changed
+func reportUpdatedEvent(ticket *AbuseTicket, update *TicketUpdate) notifications.EventData {
+	description := "The abuse team added a note to your report"
+	if update.Kind == ticketUpdateStatus && update.ToValue != nil {
+		description = fmt.Sprintf("Your report is now %s", strings.ReplaceAll(*update.ToValue, "_", " "))
+	}
+	if update.Body != "" {
+		description += ": " + update.Body
+	}
+	return notifications.EventData{
+		Type:         models.EventAbuseReportUpdated,
+		SourceID:     ticket.ID,
+		SourceType:   "abuse_ticket",
+		Title:        "Update on your report",
+		Description:  description,
+		ActionURL:    fmt.Sprintf("/my/reports/%s", ticket.ID),
+		RecipientIDs: []uuid.UUID{*ticket.ReporterID},
+		ExtraData: map[string]interface{}{
+			"ticket_id": ticket.ID,
+			"category":  ticket.Category,
+			"status":    ticket.Status,
+		},
+	}
+}
+
+// AdminUpdateTicketStatus moves a ticket to a new status:
+// PUT /api/v1/admin/tickets/:ticket_id/status {"status": "in_progress"|"open"|"resolved"|"dismissed", "message": "..."}
+// The message is shown to the reporter; dismissing needs one. Picking up an
+// unassigned ticket assigns it to the caller.
+func (ws *WorkService) AdminUpdateTicketStatus(c *gin.Context) {
+	var req struct {
+		Status  string `json:"status" binding:"required"`
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Status == ticketDismissed && req.Message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dismissing a report needs a message for the reporter"})
+		return
+	}
+	if len(req.Message) > maxTicketText {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message is too long", "max": maxTicketText})
+		return
+	}
+
+	ws.updateTicket(c, func(ctx context.Context, tx *sql.Tx, ticket *AbuseTicket, actorID uuid.UUID) *TicketUpdate {
+		if !validTicketTransition(ticket.Status, req.Status) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   fmt.Sprintf("A ticket can't move from %s to %s", ticket.Status, req.Status),
+				"allowed": ticketTransitions[ticket.Status],
+			})
+			return nil
+		}
+
+		closing := req.Status == ticketResolved || req.Status == ticketDismissed
+		var resolution *string
+		if closing && req.Message != "" {
+			resolution = &req.Message
+		}
+		_, err := tx.ExecContext(ctx, `
+			UPDATE abuse_tickets SET
+				status = $2,
+				assignee_id = CASE WHEN $2 = 'in_progress' THEN COALESCE(assignee_id, $3) ELSE assignee_id END,
+				resolution = $4,
+				resolved_at = CASE WHEN $5 THEN NOW() END
+			WHERE id = $1`, ticket.ID, req.Status, actorID, resolution, closing)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket"})
+			return nil
+		}
+
+		from := ticket.Status
+		ticket.Status = req.Status
+		return &TicketUpdate{Kind: ticketUpdateStatus, Body: req.Message, FromValue: &from, ToValue: &req.Status}
+	})
+}
+
+// AdminAssignTicket assigns a ticket to an admin, or unassigns it:
+// PUT /api/v1/admin/tickets/:ticket_id/assignee {"assignee_id": "..."|null}
+func (ws *WorkService) AdminAssignTicket(c *gin.Context) {
+	var req struct {
+		AssigneeID *uuid.UUID `json:"assignee_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	ws.updateTicket(c, func(ctx context.Context, tx *sql.Tx, ticket *AbuseTicket, actorID uuid.UUID) *TicketUpdate {
+		if ticket.Status == ticketResolved || ticket.Status == ticketDismissed {
+			c.JSON(http.StatusConflict, gin.H{"error": "Reopen a closed ticket before reassigning it"})
+			return nil
+		}
+		if req.AssigneeID != nil {
+			var isAdmin bool
+			err := tx.QueryRowContext(ctx, `
+				SELECT EXISTS(SELECT 1 FROM user_roles WHERE user_id = $1 AND role = 'admin' AND revoked_at IS NULL)`,
+				*req.AssigneeID).Scan(&isAdmin)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket"})
+				return nil
+			}
+			if !isAdmin {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Tickets can only be assigned to admins"})
+				return nil
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE abuse_tickets SET assignee_id = $2 WHERE id = $1", ticket.ID, req.AssigneeID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket"})
+			return nil
+		}
+
+		update := &TicketUpdate{Kind: ticketUpdateAssignment, Internal: true}
+		if ticket.AssigneeID != nil {
+			from := ticket.AssigneeID.String()
+			update.FromValue = &from
+		}
+		if req.AssigneeID != nil {
+			to := req.AssigneeID.String()
+			update.ToValue = &to
+		}
+		return update
+	})
+}
+
+// AdminUpdateTicketPriority reprioritizes a ticket:
+// PUT /api/v1/admin/tickets/:ticket_id/priority {"priority": "low"|"normal"|"high"|"urgent"}
+func (ws *WorkService) AdminUpdateTicketPriority(c *gin.Context) {
+	var req struct {
+		Priority string `json:"priority" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if !containsValue(ticketPriorities, req.Priority) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid priority", "valid_priorities": ticketPriorities})
+		return
+	}
+
+	ws.updateTicket(c, func(ctx context.Context, tx *sql.Tx, ticket *AbuseTicket, actorID uuid.UUID) *TicketUpdate {
+		if _, err := tx.ExecContext(ctx, "UPDATE abuse_tickets SET priority = $2 WHERE id = $1", ticket.ID, req.Priority); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket"})
+			return nil
+		}
+		from := ticket.Priority
+		return &TicketUpdate{Kind: ticketUpdatePriority, FromValue: &from, ToValue: &req.Priority, Internal: true}
+	})
+}
+
+// AdminAddTicketNote adds a note to a ticket. Notes are internal unless
+// internal is false, in which case the reporter sees them:
+// POST /api/v1/admin/tickets/:ticket_id/notes {"body": "...", "internal": true}
+func (ws *WorkService) AdminAddTicketNote(c *gin.Context) {
+	var req struct {
+		Body     string `json:"body" binding:"required"`
+		Internal *bool  `json:"internal"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" || len(req.Body) > maxTicketText {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Notes must be 1 to %d characters", maxTicketText)})
+		return
+	}
+	internal := req.Internal == nil || *req.Internal
+
+	ws.updateTicket(c, func(ctx context.Context, tx *sql.Tx, ticket *AbuseTicket, actorID uuid.UUID) *TicketUpdate {
+		return &TicketUpdate{Kind: ticketUpdateNote, Body: req.Body, Internal: internal}
+	})
+}
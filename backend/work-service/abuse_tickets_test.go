@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidTicketTransition(t *testing.T) {
+	assert.True(t, validTicketTransition(ticketOpen, ticketInProgress))
+	assert.True(t, validTicketTransition(ticketOpen, ticketDismissed), "junk can be dismissed straight away")
+	assert.True(t, validTicketTransition(ticketInProgress, ticketResolved))
+	assert.True(t, validTicketTransition(ticketInProgress, ticketOpen), "an admin can hand a ticket back")
+	assert.True(t, validTicketTransition(ticketResolved, ticketOpen), "closed tickets can be reopened")
+
+	assert.False(t, validTicketTransition(ticketOpen, ticketResolved), "tickets are worked before they're resolved")
+	assert.False(t, validTicketTransition(ticketDismissed, ticketResolved))
+	assert.False(t, validTicketTransition(ticketOpen, "deleted"))
+}
+
+func TestDefaultTicketPriority(t *testing.T) {
+	assert.Equal(t, "urgent", defaultTicketPriority("doxxing"))
+	assert.Equal(t, "high", defaultTicketPriority("harassment"))
+	assert.Equal(t, "low", defaultTicketPriority("spam"))
+	assert.Equal(t, "normal", defaultTicketPriority("wrong_rating"))
+}
+
+func TestTicketForReporterHidesTriage(t *testing.T) {
+	admin, reporter := uuid.New(), uuid.New()
+	resolved := ticketResolved
+	ticket := &AbuseTicket{
+		ID:         uuid.New(),
+		ReporterID: &reporter,
+		AssigneeID: &admin,
+		Priority:   "high",
+		Status:     ticketResolved,
+		Evidence:   json.RawMessage(`{"work":{}}`),
+		Updates: []TicketUpdate{
+			{Kind: ticketUpdateNote, Body: "Creator has a history of this", AuthorID: &admin, Internal: true},
+			{Kind: ticketUpdateStatus, Body: "The work was hidden", ToValue: &resolved, AuthorID: &admin},
+		},
+	}
+
+	view := ticket.forReporter()
+	assert.Nil(t, view.AssigneeID)
+	assert.Nil(t, view.Evidence)
+	assert.Empty(t, view.Priority)
+	if assert.Len(t, view.Updates, 1, "internal notes stay with the abuse team") {
+		assert.Equal(t, "The work was hidden", view.Updates[0].Body)
+		assert.Nil(t, view.Updates[0].AuthorID, "reporters aren't told which admin acted")
+	}
+	assert.Equal(t, &admin, ticket.Updates[1].AuthorID, "the admin view is left alone")
+}
+
+func TestReportUpdatedEvent(t *testing.T) {
+	reporter := uuid.New()
+	dismissed := ticketDismissed
+	ticket := &AbuseTicket{ID: uuid.New(), ReporterID: &reporter, Category: "spam", Status: ticketDismissed}
+
+	event := reportUpdatedEvent(ticket, &TicketUpdate{Kind: ticketUpdateStatus, Body: "Not spam", ToValue: &dismissed})
+	assert.Equal(t, []uuid.UUID{reporter}, event.RecipientIDs)
+	assert.Equal(t, "Your report is now dismissed: Not spam", event.Description)
+
+	inProgress := ticketInProgress
+	event = reportUpdatedEvent(ticket, &TicketUpdate{Kind: ticketUpdateStatus, ToValue: &inProgress})
+	assert.Equal(t, "Your report is now in progress", event.Description)
+}
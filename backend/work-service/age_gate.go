@@ -0,0 +1,153 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Age gate for works flagged restricted_to_adults. A reader who has told us
+// their birth date is let through or turned away by it; anyone else has to
+// acknowledge the adult content warning first, by sending
+// X-Adult-Content-Acknowledged: true or the adult_content_acknowledged=true
+// cookie. Readers stopped at the gate get a 451 whose age_gate object is
+// the interstitial to show: AGE_CONFIRMATION_REQUIRED can be acknowledged,
+// AGE_RESTRICTED can't. A work's authors always get through.
+
+const (
+	adultContentHeader = "X-Adult-Content-Acknowledged"
+	adultContentCookie = "adult_content_acknowledged"
+
+	ageConfirmationRequiredCode = "AGE_CONFIRMATION_REQUIRED"
+	ageRestrictedCode           = "AGE_RESTRICTED"
+
+	defaultAdultContentMinAge = 18
+)
+
+// AgeGate is the interstitial sent in place of an adult work
+type AgeGate struct {
+	WorkID         uuid.UUID `json:"work_id"`
+	Title          string    `json:"title"`
+	Rating         string    `json:"rating"`
+	MinimumAge     int       `json:"minimum_age"`
+	CanAcknowledge bool      `json:"can_acknowledge"`
+	// AcknowledgeHeader and AcknowledgeCookie say how to get past the gate
+	// when it can be acknowledged
+	AcknowledgeHeader string `json:"acknowledge_header,omitempty"`
+	AcknowledgeCookie string `json:"acknowledge_cookie,omitempty"`
+}
+
+// adultContentMinAge is the age readers must be to read adult works,
+// ADULT_CONTENT_MIN_AGE or 18
+func adultContentMinAge() int {
+	age, err := strconv.Atoi(getEnv("ADULT_CONTENT_MIN_AGE", ""))
+	if err != nil || age <= 0 {
+		return defaultAdultContentMinAge
+	}
+	return age
+}
+
+// ageOn is how old someone born on birthDate is on day now
+func ageOn(birthDate, now time.Time) int {
+	age := now.Year() - birthDate.Year()
+	if now.Month() < birthDate.Month() || (now.Month() == birthDate.Month() && now.Day() < birthDate.Day()) {
+		age--
+	}
+	return age
+}
+
+// ageGateCode is the code a reader is stopped at the gate with, or empty
+// if they may read the work. A known birth date decides on its own; without
+// one the reader must have acknowledged the warning.
+func ageGateCode(birthDate *time.Time, acknowledged bool, minAge int, now time.Time) string {
+	if birthDate != nil {
+		if ageOn(*birthDate, now) < minAge {
+			return ageRestrictedCode
+		}
+		return ""
+	}
+	if !acknowledged {
+		return ageConfirmationRequiredCode
+	}
+	return ""
+}
+
+// adultContentAcknowledged reports whether the request carries the adult
+// content acknowledgment, as a header or a cookie
+func adultContentAcknowledged(c *gin.Context) bool {
+	if ok, err := strconv.ParseBool(c.GetHeader(adultContentHeader)); err == nil && ok {
+		return true
+	}
+	cookie, err := c.Cookie(adultContentCookie)
+	if err != nil {
+		return false
+	}
+	ok, err := strconv.ParseBool(cookie)
+	return err == nil && ok
+}
+
+// readerBirthDate is the logged-in reader's birth date, nil when they
+// haven't given one
+func (ws *WorkService) readerBirthDate(c *gin.Context, userID uuid.UUID) *time.Time {
+	var birthDate *time.Time
+	err := ws.db.QueryRowContext(c.Request.Context(), "SELECT birth_date FROM users WHERE id = $1", userID).Scan(&birthDate)
+	if err != nil {
+		log.Printf("Failed to load birth date for user %s: %v", userID, err)
+		return nil
+	}
+	return birthDate
+}
+
+// rejectAgeGatedReader answers a request for an adult work the reader
+// hasn't cleared the age gate for, returning true if it did
+func (ws *WorkService) rejectAgeGatedReader(c *gin.Context, workID uuid.UUID, title, rating string) bool {
+	var birthDate *time.Time
+	if userID := ws.getUserIDFromContext(c); userID != nil {
+		if isCreator, err := ws.isWorkCreator(c.Request.Context(), workID, *userID); err == nil && isCreator {
+			return false
+		}
+		birthDate = ws.readerBirthDate(c, *userID)
+	}
+
+	minAge := adultContentMinAge()
+	code := ageGateCode(birthDate, adultContentAcknowledged(c), minAge, time.Now())
+	if code == "" {
+		return false
+	}
+
+	gate := AgeGate{WorkID: workID, Title: title, Rating: rating, MinimumAge: minAge}
+	message := "This work is restricted to readers aged " + strconv.Itoa(minAge) + " or over"
+	if code == ageConfirmationRequiredCode {
+		gate.CanAcknowledge = true
+		gate.AcknowledgeHeader = adultContentHeader
+		gate.AcknowledgeCookie = adultContentCookie
+		message = "This work contains adult content; confirm you are " + strconv.Itoa(minAge) + " or over to read it"
+	}
+
+	// The answer depends on who is asking, so nothing may keep it
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusUnavailableForLegalReasons, gin.H{
+		"error":    message,
+		"code":     code,
+		"age_gate": gate,
+	})
+	return true
+}
+
+// rejectAgeGatedWorkReader is rejectAgeGatedReader for handlers that
+// haven't loaded the work, such as chapter reads
+func (ws *WorkService) rejectAgeGatedWorkReader(c *gin.Context, workID uuid.UUID) bool {
+	var restricted bool
+	var title, rating string
+	err := ws.db.QueryRowContext(c.Request.Context(), `
+		SELECT COALESCE(restricted_to_adults, false), title, COALESCE(rating, '')
+		FROM works WHERE id = $1`, workID).Scan(&restricted, &title, &rating)
+	if err != nil || !restricted {
+		return false
+	}
+	return ws.rejectAgeGatedReader(c, workID, title, rating)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgeOn(t *testing.T) {
+	birthDate := time.Date(2008, time.March, 15, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 17, ageOn(birthDate, time.Date(2026, time.March, 14, 23, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 18, ageOn(birthDate, time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)), "birthday itself")
+	assert.Equal(t, 17, ageOn(birthDate, time.Date(2026, time.February, 28, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 18, ageOn(birthDate, time.Date(2026, time.December, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestAgeGateCode(t *testing.T) {
+	now := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+	adult := time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC)
+	minor := time.Date(2010, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, ageConfirmationRequiredCode, ageGateCode(nil, false, 18, now))
+	assert.Equal(t, "", ageGateCode(nil, true, 18, now))
+	assert.Equal(t, "", ageGateCode(&adult, false, 18, now), "a known adult needn't acknowledge")
+	assert.Equal(t, ageRestrictedCode, ageGateCode(&minor, true, 18, now), "acknowledging can't override a birth date")
+	assert.Equal(t, "", ageGateCode(&minor, false, 16, now))
+}
+
+func TestAdultContentMinAge(t *testing.T) {
+	t.Setenv("ADULT_CONTENT_MIN_AGE", "")
+	assert.Equal(t, 18, adultContentMinAge())
+	t.Setenv("ADULT_CONTENT_MIN_AGE", "21")
+	assert.Equal(t, 21, adultContentMinAge())
+	t.Setenv("ADULT_CONTENT_MIN_AGE", "adult")
+	assert.Equal(t, 18, adultContentMinAge())
+}
+
+func TestAdultContentAcknowledged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		header, cookie string
+		want           bool
+	}{
+		{"", "", false},
+		{"true", "", true},
+		{"1", "", true},
+		{"no", "", false},
+		{"", "true", true},
+		{"", "false", false},
+	}
+	for _, tc := range cases {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/api/v1/works/x", nil)
+		if tc.header != "" {
+			c.Request.Header.Set(adultContentHeader, tc.header)
+		}
+		if tc.cookie != "" {
+			c.Request.AddCookie(&http.Cookie{Name: adultContentCookie, Value: tc.cookie})
+		}
+		assert.Equal(t, tc.want, adultContentAcknowledged(c), "header %q cookie %q", tc.header, tc.cookie)
+	}
+}
+
+func TestRejectAgeGatedReaderLoggedOut(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADULT_CONTENT_MIN_AGE", "")
+	ws := &WorkService{}
+	workID := uuid.New()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/works/"+workID.String(), nil)
+	require.True(t, ws.rejectAgeGatedReader(c, workID, "A Work", "Explicit"))
+
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	var body struct {
+		Code    string  `json:"code"`
+		AgeGate AgeGate `json:"age_gate"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, ageConfirmationRequiredCode, body.Code)
+	assert.Equal(t, workID, body.AgeGate.WorkID)
+	assert.Equal(t, "A Work", body.AgeGate.Title)
+	assert.Equal(t, 18, body.AgeGate.MinimumAge)
+	assert.True(t, body.AgeGate.CanAcknowledge)
+	assert.Equal(t, adultContentHeader, body.AgeGate.AcknowledgeHeader)
+
+	// Acknowledged, the same logged-out request goes through
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/v1/works/"+workID.String(), nil)
+	c.Request.Header.Set(adultContentHeader, "true")
+	assert.False(t, ws.rejectAgeGatedReader(c, workID, "A Work", "Explicit"))
+}
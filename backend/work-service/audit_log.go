@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/audit"
+)
+
+// =============================================================================
+// AUDIT LOG
+// Every service records admin and wrangler actions in audit_log; the admin
+// API here is where they're read back and exported.
+// =============================================================================
+
+const auditService = "work-service"
+
+// maxAuditExport caps how many entries one export returns
+const maxAuditExport = 50000
+
+// auditFilterFromQuery reads the audit log filters:
+// actor_id, service, action (a trailing * matches a prefix), target_type,
+// target_id, since and until (YYYY-MM-DD or RFC 3339; a bare until date
+// covers that whole day)
+func auditFilterFromQuery(c *gin.Context) (audit.Filter, error) {
+	f := audit.Filter{
+		Service:    c.Query("service"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+	}
+	if value := c.Query("actor_id"); value != "" {
+		actorID, err := uuid.Parse(value)
+		if err != nil {
+			return f, fmt.Errorf("actor_id must be a user ID")
+		}
+		f.ActorID = &actorID
+	}
+	for _, param := range []string{"since", "until"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			day, dayErr := time.Parse("2006-01-02", value)
+			if dayErr != nil {
+				return f, fmt.Errorf("%s must be YYYY-MM-DD or RFC 3339", param)
+			}
+			t = day
+			if param == "until" {
+				t = day.Add(24 * time.Hour)
+			}
+		}
+		if param == "since" {
+			f.Since = &t
+		} else {
+			f.Until = &t
+		}
+	}
+	return f, nil
+}
+
+// AdminListAuditLog lists audit entries, newest first:
+// GET /api/v1/admin/audit-log?actor_id=...&action=tag.*&target_type=work&since=2026-01-01&page=1&limit=20
+func (ws *WorkService) AdminListAuditLog(c *gin.Context) {
+	filter, err := auditFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	entries, total, err := audit.List(c.Request.Context(), ws.db, filter, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+// AdminExportAuditLog downloads the matching audit entries, oldest first,
+// as CSV or as JSON lines with the full before and after snapshots:
+// GET /api/v1/admin/audit-log/export?format=csv|jsonl&since=2026-01-01
+func (ws *WorkService) AdminExportAuditLog(c *gin.Context) {
+	filter, err := auditFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or jsonl"})
+		return
+	}
+
+	filename := fmt.Sprintf("audit-log-%s.%s", time.Now().UTC().Format("2006-01-02"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	// Rows are streamed, so a failure part way through can only be logged
+	ctx := c.Request.Context()
+	if format == "jsonl" {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		enc := json.NewEncoder(c.Writer)
+		err = audit.Export(ctx, ws.db, filter, maxAuditExport, func(e audit.Entry) error { return enc.Encode(e) })
+	} else {
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		var cw *audit.CSVWriter
+		if cw, err = audit.NewCSVWriter(c.Writer); err == nil {
+			err = audit.Export(ctx, ws.db, filter, maxAuditExport, cw.Write)
+			if flushErr := cw.Flush(); err == nil {
+				err = flushErr
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("Audit log export failed: %v", err)
+	}
+}
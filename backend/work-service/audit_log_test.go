@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func auditQueryContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/v1/admin/audit-log?"+query, nil)
+	return c
+}
+
+func TestAuditFilterFromQuery(t *testing.T) {
+	actor := uuid.New()
+	f, err := auditFilterFromQuery(auditQueryContext("actor_id=" + actor.String() + "&action=tag.*&target_type=work&since=2026-01-01&until=2026-01-31"))
+	assert.NoError(t, err)
+	assert.Equal(t, &actor, f.ActorID)
+	assert.Equal(t, "tag.*", f.Action)
+	assert.Equal(t, "work", f.TargetType)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), *f.Since)
+	assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), *f.Until, "a bare until date covers the whole day")
+
+	f, err = auditFilterFromQuery(auditQueryContext("until=2026-01-31T12:00:00Z"))
+	assert.NoError(t, err)
+	assert.Nil(t, f.Since)
+	assert.Equal(t, time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC), *f.Until)
+
+	_, err = auditFilterFromQuery(auditQueryContext("actor_id=someone"))
+	assert.Error(t, err)
+	_, err = auditFilterFromQuery(auditQueryContext("since=last+week"))
+	assert.EqualError(t, err, "since must be YYYY-MM-DD or RFC 3339")
+}
+
+func TestTicketUpdateSnapshot(t *testing.T) {
+	high := "high"
+	assert.Equal(t, gin.H{"priority": &high}, ticketUpdateSnapshot(ticketUpdatePriority, &high))
+	assert.Nil(t, ticketUpdateSnapshot(ticketUpdateNote, nil))
+}
@@ -0,0 +1,787 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Blocklist import/export and shared mute/block lists
+
+const (
+	blocklistFormat   = "nuclear-ao3-blocklist"
+	blocklistVersion  = 1
+	maxMutedUsers     = 100 // Similar to AO3's limits
+	maxBlockedUsers   = 1000
+	maxImportEntries  = 500
+	maxImportsPerDay  = 10
+	maxSharedLists    = 10
+	maxSharedListName = 100
+)
+
+// BlocklistEntry is one muted or blocked user in an export, import or
+// shared list. Imports may identify the user by ID or username.
+type BlocklistEntry struct {
+	UserID    *uuid.UUID `json:"user_id,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	BlockType string     `json:"block_type,omitempty"` // Blocks only: full, comments, works
+	Reason    string     `json:"reason,omitempty"`
+	ImportID  *uuid.UUID `json:"import_id,omitempty"` // Set when the entry came from an import
+}
+
+// BlocklistDocument is the portable export format
+type BlocklistDocument struct {
+	Format     string           `json:"format"`
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Mutes      []BlocklistEntry `json:"mutes"`
+	Blocks     []BlocklistEntry `json:"blocks"`
+}
+
+// BlocklistImportRequest imports either a shared list or an uploaded document
+type BlocklistImportRequest struct {
+	ListID   *uuid.UUID         `json:"list_id"`
+	Document *BlocklistDocument `json:"document"`
+	Label    string             `json:"label"`
+}
+
+// SharedBlockList is a published snapshot of a user's mutes or blocks
+type SharedBlockList struct {
+	ID            uuid.UUID        `json:"id"`
+	OwnerID       uuid.UUID        `json:"owner_id"`
+	OwnerUsername string           `json:"owner_username,omitempty"`
+	Name          string           `json:"name"`
+	Description   string           `json:"description"`
+	Kind          string           `json:"kind"`
+	IsPublic      bool             `json:"is_public"`
+	EntryCount    int              `json:"entry_count"`
+	Entries       []BlocklistEntry `json:"entries,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+// BlocklistImport records where a batch of mutes and blocks came from
+type BlocklistImport struct {
+	ID           uuid.UUID  `json:"id"`
+	SourceListID *uuid.UUID `json:"source_list_id"`
+	SourceLabel  string     `json:"source_label"`
+	MutesAdded   int        `json:"mutes_added"`
+	BlocksAdded  int        `json:"blocks_added"`
+	Skipped      int        `json:"skipped"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+var validBlockTypes = map[string]bool{"full": true, "comments": true, "works": true}
+
+// normalizeBlocklistEntries drops entries that name no user or carry an
+// unknown block type, and removes duplicates. Block entries without a type
+// default to a full block.
+func normalizeBlocklistEntries(entries []BlocklistEntry, isBlock bool) ([]BlocklistEntry, int) {
+	seen := make(map[string]bool, len(entries))
+	valid := make([]BlocklistEntry, 0, len(entries))
+	invalid := 0
+
+	for _, entry := range entries {
+		entry.Username = strings.TrimSpace(entry.Username)
+		entry.ImportID = nil
+
+		var key string
+		switch {
+		case entry.UserID != nil:
+			key = "id:" + entry.UserID.String()
+		case entry.Username != "":
+			key = "name:" + strings.ToLower(entry.Username)
+		default:
+			invalid++
+			continue
+		}
+
+		if isBlock {
+			if entry.BlockType == "" {
+				entry.BlockType = "full"
+			}
+			if !validBlockTypes[entry.BlockType] {
+				invalid++
+				continue
+			}
+		} else {
+			entry.BlockType = ""
+		}
+
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		valid = append(valid, entry)
+	}
+
+	return valid, invalid
+}
+
+// resolveBlocklistUsers fills in user IDs for entries given by username and
+// drops entries naming users that do not exist. Returns the resolved entries
+// and how many were dropped.
+func (ws *WorkService) resolveBlocklistUsers(entries []BlocklistEntry) ([]BlocklistEntry, int, error) {
+	var ids, names []string
+	for _, entry := range entries {
+		if entry.UserID != nil {
+			ids = append(ids, entry.UserID.String())
+		} else {
+			names = append(names, strings.ToLower(entry.Username))
+		}
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT id, username FROM users
+		WHERE id = ANY($1::uuid[]) OR LOWER(username) = ANY($2)`,
+		pq.Array(ids), pq.Array(names))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	byID := make(map[uuid.UUID]string)
+	byName := make(map[string]uuid.UUID)
+	for rows.Next() {
+		var id uuid.UUID
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			return nil, 0, err
+		}
+		byID[id] = username
+		byName[strings.ToLower(username)] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	seen := make(map[uuid.UUID]bool, len(entries))
+	resolved := make([]BlocklistEntry, 0, len(entries))
+	dropped := 0
+	for _, entry := range entries {
+		if entry.UserID != nil {
+			username, ok := byID[*entry.UserID]
+			if !ok {
+				dropped++
+				continue
+			}
+			entry.Username = username
+		} else {
+			id, ok := byName[strings.ToLower(entry.Username)]
+			if !ok {
+				dropped++
+				continue
+			}
+			entry.UserID = &id
+		}
+		if seen[*entry.UserID] {
+			continue
+		}
+		seen[*entry.UserID] = true
+		resolved = append(resolved, entry)
+	}
+
+	return resolved, dropped, nil
+}
+
+// ExportBlocklist returns the caller's mutes and blocks as a portable document
+func (ws *WorkService) ExportBlocklist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	kind := c.DefaultQuery("kind", "all")
+	if kind != "all" && kind != "mutes" && kind != "blocks" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be all, mutes or blocks"})
+		return
+	}
+
+	doc := BlocklistDocument{
+		Format:     blocklistFormat,
+		Version:    blocklistVersion,
+		ExportedAt: time.Now(),
+		Mutes:      []BlocklistEntry{},
+		Blocks:     []BlocklistEntry{},
+	}
+
+	var err error
+	if kind != "blocks" {
+		doc.Mutes, err = ws.loadBlocklistEntries(`
+			SELECT m.muted_id, u.username, '', COALESCE(m.reason, ''), m.import_id
+			FROM user_mutes m JOIN users u ON m.muted_id = u.id
+			WHERE m.muter_id = $1 ORDER BY m.created_at`, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export mutes"})
+			return
+		}
+	}
+	if kind != "mutes" {
+		doc.Blocks, err = ws.loadBlocklistEntries(`
+			SELECT b.blocked_id, u.username, b.block_type, COALESCE(b.reason, ''), b.import_id
+			FROM user_blocks b JOIN users u ON b.blocked_id = u.id
+			WHERE b.blocker_id = $1 ORDER BY b.created_at`, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export blocks"})
+			return
+		}
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"blocklist-%s.json\"", doc.ExportedAt.Format("2006-01-02")))
+	c.JSON(http.StatusOK, doc)
+}
+
+// loadBlocklistEntries scans (user_id, username, block_type, reason, import_id) rows
+func (ws *WorkService) loadBlocklistEntries(query string, args ...interface{}) ([]BlocklistEntry, error) {
+	rows, err := ws.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []BlocklistEntry{}
+	for rows.Next() {
+		var entry BlocklistEntry
+		var id uuid.UUID
+		var importID uuid.NullUUID
+		if err := rows.Scan(&id, &entry.Username, &entry.BlockType, &entry.Reason, &importID); err != nil {
+			return nil, err
+		}
+		entry.UserID = &id
+		if importID.Valid {
+			entry.ImportID = &importID.UUID
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// ImportBlocklist adds the entries of a shared list or an uploaded document
+// to the caller's mutes and blocks. Existing entries are left alone, and the
+// mute and block caps are applied to the combined total.
+func (ws *WorkService) ImportBlocklist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req BlocklistImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if (req.ListID == nil) == (req.Document == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either list_id or document"})
+		return
+	}
+
+	var recentImports int
+	err = ws.db.QueryRow(`
+		SELECT COUNT(*) FROM blocklist_imports
+		WHERE user_id = $1 AND created_at > NOW() - INTERVAL '1 day'`, userUUID).Scan(&recentImports)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check import limit"})
+		return
+	}
+	if recentImports >= maxImportsPerDay {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("You can import at most %d lists per day", maxImportsPerDay)})
+		return
+	}
+
+	var mutes, blocks []BlocklistEntry
+	label := strings.TrimSpace(req.Label)
+	if req.ListID != nil {
+		list, err := ws.getSharedBlockList(*req.ListID, true)
+		if err == sql.ErrNoRows || (err == nil && !list.IsPublic && list.OwnerID != userUUID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shared list not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shared list"})
+			return
+		}
+		if list.Kind == "block" {
+			blocks = list.Entries
+		} else {
+			mutes = list.Entries
+		}
+		if label == "" {
+			label = list.Name
+		}
+	} else {
+		if req.Document.Format != blocklistFormat || req.Document.Version > blocklistVersion {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported blocklist format"})
+			return
+		}
+		mutes, blocks = req.Document.Mutes, req.Document.Blocks
+		if label == "" {
+			label = "Uploaded blocklist"
+		}
+	}
+	if len(label) > 200 {
+		label = label[:200]
+	}
+
+	if len(mutes)+len(blocks) > maxImportEntries {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Imports are limited to %d entries", maxImportEntries)})
+		return
+	}
+
+	mutes, badMutes := normalizeBlocklistEntries(mutes, false)
+	blocks, badBlocks := normalizeBlocklistEntries(blocks, true)
+	skipped := badMutes + badBlocks
+
+	mutes, unknown, err := ws.resolveBlocklistUsers(mutes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve users"})
+		return
+	}
+	skipped += unknown
+	blocks, unknown, err = ws.resolveBlocklistUsers(blocks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve users"})
+		return
+	}
+	skipped += unknown
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	imp := BlocklistImport{SourceListID: req.ListID, SourceLabel: label}
+	err = tx.QueryRow(`
+		INSERT INTO blocklist_imports (user_id, source_list_id, source_label)
+		VALUES ($1, $2, $3) RETURNING id, created_at`,
+		userUUID, req.ListID, label).Scan(&imp.ID, &imp.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record import"})
+		return
+	}
+
+	var added, capped int
+	added, capped, err = importBlocklistEntries(tx, userUUID, imp.ID, mutes, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import mutes"})
+		return
+	}
+	imp.MutesAdded = added
+	skipped += capped
+
+	added, capped, err = importBlocklistEntries(tx, userUUID, imp.ID, blocks, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import blocks"})
+		return
+	}
+	imp.BlocksAdded = added
+	skipped += capped
+	imp.Skipped = skipped
+
+	_, err = tx.Exec(`
+		UPDATE blocklist_imports SET mutes_added = $2, blocks_added = $3, skipped = $4
+		WHERE id = $1`, imp.ID, imp.MutesAdded, imp.BlocksAdded, imp.Skipped)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record import"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import blocklist"})
+		return
+	}
+	ws.invalidateMutes(c)
+
+	c.JSON(http.StatusCreated, gin.H{"import": imp})
+}
+
+// importBlocklistEntries inserts mutes or blocks tagged with the import,
+// skipping the caller themselves, users already on their list and anything
+// past the cap. Returns how many were added and how many were skipped.
+func importBlocklistEntries(tx *sql.Tx, userID, importID uuid.UUID, entries []BlocklistEntry, isBlock bool) (int, int, error) {
+	if len(entries) == 0 {
+		return 0, 0, nil
+	}
+
+	countQuery := "SELECT COUNT(*) FROM user_mutes WHERE muter_id = $1"
+	insertQuery := `
+		INSERT INTO user_mutes (muter_id, muted_id, reason, import_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (muter_id, muted_id) DO NOTHING`
+	limit := maxMutedUsers
+	if isBlock {
+		countQuery = "SELECT COUNT(*) FROM user_blocks WHERE blocker_id = $1"
+		insertQuery = `
+			INSERT INTO user_blocks (blocker_id, blocked_id, reason, import_id, block_type)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (blocker_id, blocked_id) DO NOTHING`
+		limit = maxBlockedUsers
+	}
+
+	var current int
+	if err := tx.QueryRow(countQuery, userID).Scan(&current); err != nil {
+		return 0, 0, err
+	}
+
+	added, skipped := 0, 0
+	for _, entry := range entries {
+		if *entry.UserID == userID || current+added >= limit {
+			skipped++
+			continue
+		}
+
+		args := []interface{}{userID, *entry.UserID, entry.Reason, importID}
+		if isBlock {
+			args = append(args, entry.BlockType)
+		}
+		result, err := tx.Exec(insertQuery, args...)
+		if err != nil {
+			return 0, 0, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added++
+		} else {
+			skipped++
+		}
+	}
+
+	return added, skipped, nil
+}
+
+// GetBlocklistImports lists the caller's imports, newest first
+func (ws *WorkService) GetBlocklistImports(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT id, source_list_id, source_label, mutes_added, blocks_added, skipped, created_at
+		FROM blocklist_imports WHERE user_id = $1
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch imports"})
+		return
+	}
+	defer rows.Close()
+
+	imports := []BlocklistImport{}
+	for rows.Next() {
+		var imp BlocklistImport
+		var sourceListID uuid.NullUUID
+		if err := rows.Scan(&imp.ID, &sourceListID, &imp.SourceLabel, &imp.MutesAdded,
+			&imp.BlocksAdded, &imp.Skipped, &imp.CreatedAt); err != nil {
+			continue
+		}
+		if sourceListID.Valid {
+			imp.SourceListID = &sourceListID.UUID
+		}
+		imports = append(imports, imp)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"imports": imports})
+}
+
+// BulkRemoveBlocklist removes mutes and/or blocks in one go, either every
+// entry from one import or an explicit set of users
+func (ws *WorkService) BulkRemoveBlocklist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Kind     string      `json:"kind"` // all (default), mutes, blocks
+		ImportID *uuid.UUID  `json:"import_id"`
+		UserIDs  []uuid.UUID `json:"user_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = "all"
+	}
+	if req.Kind != "all" && req.Kind != "mutes" && req.Kind != "blocks" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be all, mutes or blocks"})
+		return
+	}
+	if (req.ImportID == nil) == (len(req.UserIDs) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either import_id or user_ids"})
+		return
+	}
+
+	filter := "import_id = $2"
+	var arg interface{}
+	if req.ImportID != nil {
+		arg = *req.ImportID
+	} else {
+		ids := make([]string, len(req.UserIDs))
+		for i, id := range req.UserIDs {
+			ids[i] = id.String()
+		}
+		arg = pq.Array(ids)
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var mutesRemoved, blocksRemoved int64
+	if req.Kind != "blocks" {
+		if req.ImportID == nil {
+			filter = "muted_id = ANY($2::uuid[])"
+		}
+		result, err := tx.Exec("DELETE FROM user_mutes WHERE muter_id = $1 AND "+filter, userID, arg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove mutes"})
+			return
+		}
+		mutesRemoved, _ = result.RowsAffected()
+	}
+	if req.Kind != "mutes" {
+		if req.ImportID == nil {
+			filter = "blocked_id = ANY($2::uuid[])"
+		}
+		result, err := tx.Exec("DELETE FROM user_blocks WHERE blocker_id = $1 AND "+filter, userID, arg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove blocks"})
+			return
+		}
+		blocksRemoved, _ = result.RowsAffected()
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove entries"})
+		return
+	}
+	if mutesRemoved > 0 {
+		ws.invalidateMutes(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mutes_removed":  mutesRemoved,
+		"blocks_removed": blocksRemoved,
+	})
+}
+
+// getSharedBlockList loads a shared list, optionally with its entries
+func (ws *WorkService) getSharedBlockList(listID uuid.UUID, withEntries bool) (*SharedBlockList, error) {
+	var list SharedBlockList
+	err := ws.db.QueryRow(`
+		SELECT l.id, l.owner_id, u.username, l.name, COALESCE(l.description, ''), l.kind,
+			l.is_public, l.entry_count, l.created_at, l.updated_at
+		FROM shared_block_lists l JOIN users u ON l.owner_id = u.id
+		WHERE l.id = $1`, listID).Scan(&list.ID, &list.OwnerID, &list.OwnerUsername, &list.Name,
+		&list.Description, &list.Kind, &list.IsPublic, &list.EntryCount, &list.CreatedAt, &list.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if withEntries {
+		list.Entries, err = ws.loadBlocklistEntries(`
+			SELECT e.user_id, u.username, COALESCE(e.block_type, ''), COALESCE(e.reason, ''), NULL::uuid
+			FROM shared_block_list_entries e JOIN users u ON e.user_id = u.id
+			WHERE e.list_id = $1 ORDER BY e.added_at`, listID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &list, nil
+}
+
+// CreateSharedBlockList publishes a snapshot of the caller's current mutes
+// or blocks. Entries the caller imported from elsewhere are left out unless
+// include_imported is set, so lists are not laundered through each other.
+func (ws *WorkService) CreateSharedBlockList(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Name            string `json:"name" binding:"required"`
+		Description     string `json:"description"`
+		Kind            string `json:"kind"` // mute (default), block
+		IsPublic        bool   `json:"is_public"`
+		IncludeImported bool   `json:"include_imported"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxSharedListName {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Name must be 1-%d characters", maxSharedListName)})
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = "mute"
+	}
+	if req.Kind != "mute" && req.Kind != "block" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be mute or block"})
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var listCount int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM shared_block_lists WHERE owner_id = $1", userID).Scan(&listCount); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check list limit"})
+		return
+	}
+	if listCount >= maxSharedLists {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("You can publish at most %d shared lists", maxSharedLists)})
+		return
+	}
+
+	var list SharedBlockList
+	err = tx.QueryRow(`
+		INSERT INTO shared_block_lists (owner_id, name, description, kind, is_public)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, owner_id, name, COALESCE(description, ''), kind, is_public, created_at, updated_at`,
+		userID, req.Name, req.Description, req.Kind, req.IsPublic).Scan(&list.ID, &list.OwnerID, &list.Name,
+		&list.Description, &list.Kind, &list.IsPublic, &list.CreatedAt, &list.UpdatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shared list"})
+		return
+	}
+
+	snapshot := `
+		INSERT INTO shared_block_list_entries (list_id, user_id, block_type, reason)
+		SELECT $1, muted_id, NULL, reason FROM user_mutes
+		WHERE muter_id = $2 AND ($3 OR import_id IS NULL)`
+	if req.Kind == "block" {
+		snapshot = `
+			INSERT INTO shared_block_list_entries (list_id, user_id, block_type, reason)
+			SELECT $1, blocked_id, block_type, reason FROM user_blocks
+			WHERE blocker_id = $2 AND ($3 OR import_id IS NULL)`
+	}
+	result, err := tx.Exec(snapshot, list.ID, userID, req.IncludeImported)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy entries"})
+		return
+	}
+	entries, _ := result.RowsAffected()
+	list.EntryCount = int(entries)
+
+	if _, err := tx.Exec("UPDATE shared_block_lists SET entry_count = $2 WHERE id = $1", list.ID, list.EntryCount); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shared list"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shared list"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"list": list})
+}
+
+// GetMySharedBlockLists lists the shared lists the caller has published
+func (ws *WorkService) GetMySharedBlockLists(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT id, owner_id, name, COALESCE(description, ''), kind, is_public, entry_count, created_at, updated_at
+		FROM shared_block_lists WHERE owner_id = $1
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shared lists"})
+		return
+	}
+	defer rows.Close()
+
+	lists := []SharedBlockList{}
+	for rows.Next() {
+		var list SharedBlockList
+		if err := rows.Scan(&list.ID, &list.OwnerID, &list.Name, &list.Description, &list.Kind,
+			&list.IsPublic, &list.EntryCount, &list.CreatedAt, &list.UpdatedAt); err != nil {
+			continue
+		}
+		lists = append(lists, list)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lists": lists})
+}
+
+// GetSharedBlockList shows a shared list and its entries. Private lists are
+// visible only to their owner.
+func (ws *WorkService) GetSharedBlockList(c *gin.Context) {
+	listID, err := uuid.Parse(c.Param("list_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid list ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	list, err := ws.getSharedBlockList(listID, true)
+	if err == sql.ErrNoRows || (err == nil && !list.IsPublic && list.OwnerID.String() != userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared list not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shared list"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"list": list})
+}
+
+// DeleteSharedBlockList unpublishes a shared list. Mutes and blocks others
+// imported from it are kept; their imports just lose the link to the list.
+func (ws *WorkService) DeleteSharedBlockList(c *gin.Context) {
+	listID, err := uuid.Parse(c.Param("list_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid list ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := ws.db.Exec("DELETE FROM shared_block_lists WHERE id = $1 AND owner_id = $2", listID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete shared list"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared list not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shared list deleted"})
+}
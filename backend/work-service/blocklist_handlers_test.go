@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeBlocklistEntries(t *testing.T) {
+	id := uuid.New()
+	importID := uuid.New()
+
+	t.Run("mutes drop block types and dedupe", func(t *testing.T) {
+		entries := []BlocklistEntry{
+			{UserID: &id, BlockType: "full", ImportID: &importID},
+			{UserID: &id, Reason: "duplicate"},
+			{Username: " Spammer "},
+			{Username: "spammer"},
+			{Reason: "names nobody"},
+		}
+
+		valid, invalid := normalizeBlocklistEntries(entries, false)
+
+		assert.Equal(t, 1, invalid)
+		if assert.Len(t, valid, 2) {
+			assert.Equal(t, id, *valid[0].UserID)
+			assert.Empty(t, valid[0].BlockType)
+			assert.Nil(t, valid[0].ImportID, "provenance from the source must not carry over")
+			assert.Equal(t, "Spammer", valid[1].Username)
+		}
+	})
+
+	t.Run("blocks default to full and reject unknown types", func(t *testing.T) {
+		entries := []BlocklistEntry{
+			{Username: "a"},
+			{Username: "b", BlockType: "comments"},
+			{Username: "c", BlockType: "everything"},
+		}
+
+		valid, invalid := normalizeBlocklistEntries(entries, true)
+
+		assert.Equal(t, 1, invalid)
+		if assert.Len(t, valid, 2) {
+			assert.Equal(t, "full", valid[0].BlockType)
+			assert.Equal(t, "comments", valid[1].BlockType)
+		}
+	})
+}
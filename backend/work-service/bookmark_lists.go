@@ -0,0 +1,489 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+)
+
+// =============================================================================
+// BOOKMARK LISTS
+// A reader groups their bookmarks into named lists with a description, shown
+// on their profile, each with a works feed. Lists are public, but a private
+// bookmark in one is only ever shown to its owner. List membership and the
+// rec flag go to the search index with each bookmark, so bookmark search can
+// filter to a list or to recs.
+// =============================================================================
+
+// maxBookmarkListName is the longest a bookmark list's name can be
+const maxBookmarkListName = 255
+
+var errBookmarkListName = errors.New("list name must be 1-255 characters")
+
+// BookmarkListRequest creates or updates a bookmark list
+type BookmarkListRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// BookmarkListEntry is one bookmark in a list's works feed
+type BookmarkListEntry struct {
+	BookmarkID uuid.UUID           `json:"bookmark_id"`
+	Notes      string              `json:"notes"`
+	Tags       []string            `json:"tags"`
+	IsRec      bool                `json:"is_rec"`
+	IsPrivate  bool                `json:"is_private"`
+	AddedAt    time.Time           `json:"added_at"`
+	Work       BookmarkListWork    `json:"work"`
+	Authors    []models.WorkAuthor `json:"authors"`
+}
+
+// BookmarkListWork is the work a list entry bookmarks
+type BookmarkListWork struct {
+	ID           uuid.UUID  `json:"id"`
+	Title        string     `json:"title"`
+	Summary      string     `json:"summary"`
+	Rating       string     `json:"rating"`
+	Fandoms      []string   `json:"fandoms"`
+	WordCount    int        `json:"word_count"`
+	ChapterCount int        `json:"chapter_count"`
+	IsComplete   bool       `json:"is_complete"`
+	PublishedAt  *time.Time `json:"published_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// bookmarkListName trims a list name and checks its length
+func bookmarkListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxBookmarkListName {
+		return "", errBookmarkListName
+	}
+	return name, nil
+}
+
+// bookmarkListColumns counts only public bookmarks, as everyone but the
+// owner sees the list
+const bookmarkListColumns = `bl.id, bl.user_id, bl.name, bl.description,
+	(SELECT COUNT(*) FROM bookmark_list_items li JOIN bookmarks b ON b.id = li.bookmark_id
+		WHERE li.list_id = bl.id AND NOT COALESCE(b.is_private, false)),
+	(SELECT COUNT(*) FROM bookmark_list_items li JOIN bookmarks b ON b.id = li.bookmark_id
+		WHERE li.list_id = bl.id AND NOT COALESCE(b.is_private, false) AND COALESCE(b.is_rec, false)),
+	bl.created_at, bl.updated_at`
+
+func scanBookmarkList(scanner interface{ Scan(...interface{}) error }) (*models.BookmarkList, error) {
+	var l models.BookmarkList
+	err := scanner.Scan(&l.ID, &l.UserID, &l.Name, &l.Description, &l.BookmarkCount, &l.RecCount,
+		&l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (ws *WorkService) getBookmarkList(listID uuid.UUID) (*models.BookmarkList, error) {
+	return scanBookmarkList(ws.db.QueryRow("SELECT "+bookmarkListColumns+" FROM bookmark_lists bl WHERE bl.id = $1", listID))
+}
+
+func parseBookmarkListID(c *gin.Context) (uuid.UUID, bool) {
+	listID, err := uuid.Parse(c.Param("list_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid list ID"})
+		return uuid.Nil, false
+	}
+	return listID, true
+}
+
+// requireBookmarkListOwner writes an error response and returns false unless
+// the current user owns the list
+func (ws *WorkService) requireBookmarkListOwner(c *gin.Context, listID uuid.UUID) (*models.BookmarkList, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	list, err := ws.getBookmarkList(listID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark list not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark list"})
+		return nil, false
+	}
+	if list.UserID.String() != userID.(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the list's owner can do this"})
+		return nil, false
+	}
+	return list, true
+}
+
+// isDuplicateListName reports whether err is the owner already having a list
+// with that name
+func isDuplicateListName(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// reindexBookmarks resends bookmarks to search after their lists change
+func (ws *WorkService) reindexBookmarks(bookmarkIDs []uuid.UUID) {
+	for _, bookmarkID := range bookmarkIDs {
+		var workID uuid.UUID
+		var isPrivate bool
+		var createdAt time.Time
+		err := ws.db.QueryRow(`
+			SELECT work_id, COALESCE(is_private, false), created_at
+			FROM bookmarks WHERE id = $1`, bookmarkID).Scan(&workID, &isPrivate, &createdAt)
+		if err != nil {
+			continue
+		}
+		ws.sendBookmarkSearchEvent("bookmark_updated", bookmarkID, workID, isPrivate, createdAt)
+	}
+}
+
+// GetUserBookmarkLists lists a user's bookmark lists, newest first
+func (ws *WorkService) GetUserBookmarkLists(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	rows, err := ws.db.Query("SELECT "+bookmarkListColumns+`
+		FROM bookmark_lists bl
+		WHERE bl.user_id = $1
+		ORDER BY bl.created_at DESC, bl.id`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark lists"})
+		return
+	}
+	defer rows.Close()
+
+	lists := []*models.BookmarkList{}
+	for rows.Next() {
+		l, err := scanBookmarkList(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read bookmark lists"})
+			return
+		}
+		lists = append(lists, l)
+	}
+	c.JSON(http.StatusOK, gin.H{"bookmark_lists": lists})
+}
+
+// GetBookmarkListWorks is a list's works feed, most recently added first.
+// Only works the viewer can see are listed, and private bookmarks only to
+// the list's owner. ?rec=true narrows it to recs.
+func (ws *WorkService) GetBookmarkListWorks(c *gin.Context) {
+	listID, ok := parseBookmarkListID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	list, err := ws.getBookmarkList(listID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark list not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark list"})
+		return
+	}
+
+	where := " WHERE li.list_id = $1"
+	args := []interface{}{listID}
+	userID, signedIn := c.Get("user_id")
+	if !signedIn || list.UserID.String() != fmt.Sprint(userID) {
+		where += " AND NOT COALESCE(b.is_private, false)"
+	}
+	if c.Query("rec") == "true" {
+		where += " AND COALESCE(b.is_rec, false)"
+	}
+	if signedIn {
+		args = append(args, userID)
+		where += fmt.Sprintf(" AND can_user_view_work(w.id, $%d)", len(args))
+	} else {
+		where += " AND w.restricted = false AND w.status = 'posted'" + ws.loginGatedRatingsSQL(ctx)
+	}
+	from := `
+		FROM bookmark_list_items li
+		JOIN bookmarks b ON b.id = li.bookmark_id
+		JOIN works w ON w.id = b.work_id` + where
+
+	page, limit := sharePageParams(c)
+
+	var total int
+	if err := ws.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark list works"})
+		return
+	}
+
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT b.id, COALESCE(b.notes, ''), COALESCE(b.tags, '{}'), COALESCE(b.is_rec, false),
+			COALESCE(b.is_private, false), li.added_at,
+			w.id, w.title, COALESCE(w.summary, ''), w.rating, COALESCE(w.fandoms, '{}'),
+			COALESCE(w.word_count, 0), COALESCE(w.chapter_count, 0), COALESCE(w.is_complete, false),
+			w.published_at, w.updated_at`+from+fmt.Sprintf(`
+		ORDER BY li.added_at DESC, b.id
+		LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2), append(args, limit, (page-1)*limit)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark list works"})
+		return
+	}
+
+	entries := []BookmarkListEntry{}
+	for rows.Next() {
+		var e BookmarkListEntry
+		if err := rows.Scan(&e.BookmarkID, &e.Notes, pq.Array(&e.Tags), &e.IsRec, &e.IsPrivate, &e.AddedAt,
+			&e.Work.ID, &e.Work.Title, &e.Work.Summary, &e.Work.Rating, pq.Array(&e.Work.Fandoms),
+			&e.Work.WordCount, &e.Work.ChapterCount, &e.Work.IsComplete,
+			&e.Work.PublishedAt, &e.Work.UpdatedAt); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read bookmark list works"})
+			return
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	// Authors are loaded once the feed's rows are closed
+	var viewerID *uuid.UUID
+	if signedIn {
+		if id, err := uuid.Parse(fmt.Sprint(userID)); err == nil {
+			viewerID = &id
+		}
+	}
+	for i := range entries {
+		entries[i].Authors = ws.bookmarkListAuthors(entries[i].Work.ID, viewerID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bookmark_list": list,
+		"works":         entries,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+// bookmarkListAuthors loads a listed work's authors as the viewer may see
+// them
+func (ws *WorkService) bookmarkListAuthors(workID uuid.UUID, viewerID *uuid.UUID) []models.WorkAuthor {
+	authors := []models.WorkAuthor{}
+	rows, err := ws.db.Query("SELECT * FROM get_work_authors($1, $2)", workID, viewerID)
+	if err != nil {
+		return authors
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var author models.WorkAuthor
+		if err := rows.Scan(&author.PseudID, &author.PseudName, &author.UserID, &author.Username, &author.IsAnonymous); err == nil {
+			authors = append(authors, author)
+		}
+	}
+	return authors
+}
+
+// CreateBookmarkList creates a bookmark list owned by the caller
+func (ws *WorkService) CreateBookmarkList(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req BookmarkListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	name, err := bookmarkListName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var listID uuid.UUID
+	err = ws.db.QueryRow(`
+		INSERT INTO bookmark_lists (user_id, name, description)
+		VALUES ($1, $2, $3)
+		RETURNING id`, userID, name, strings.TrimSpace(req.Description)).Scan(&listID)
+	if isDuplicateListName(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a list with that name"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bookmark list"})
+		return
+	}
+
+	list, err := ws.getBookmarkList(listID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark list"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"bookmark_list": list})
+}
+
+// UpdateBookmarkList renames a bookmark list or changes its description
+func (ws *WorkService) UpdateBookmarkList(c *gin.Context) {
+	listID, ok := parseBookmarkListID(c)
+	if !ok {
+		return
+	}
+
+	var req BookmarkListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	name, err := bookmarkListName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := ws.requireBookmarkListOwner(c, listID); !ok {
+		return
+	}
+
+	_, err = ws.db.Exec(`
+		UPDATE bookmark_lists SET name = $2, description = $3, updated_at = NOW()
+		WHERE id = $1`, listID, name, strings.TrimSpace(req.Description))
+	if isDuplicateListName(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a list with that name"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark list"})
+		return
+	}
+
+	list, err := ws.getBookmarkList(listID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark list"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bookmark_list": list})
+}
+
+// DeleteBookmarkList deletes a list; the bookmarks in it are kept
+func (ws *WorkService) DeleteBookmarkList(c *gin.Context) {
+	listID, ok := parseBookmarkListID(c)
+	if !ok {
+		return
+	}
+	if _, ok := ws.requireBookmarkListOwner(c, listID); !ok {
+		return
+	}
+
+	rows, err := ws.db.Query("DELETE FROM bookmark_list_items WHERE list_id = $1 RETURNING bookmark_id", listID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bookmark list"})
+		return
+	}
+	bookmarkIDs := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			bookmarkIDs = append(bookmarkIDs, id)
+		}
+	}
+	rows.Close()
+
+	if _, err := ws.db.Exec("DELETE FROM bookmark_lists WHERE id = $1", listID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bookmark list"})
+		return
+	}
+
+	go ws.reindexBookmarks(bookmarkIDs)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark list deleted"})
+}
+
+// AddBookmarkToList adds one of the caller's bookmarks to their list
+func (ws *WorkService) AddBookmarkToList(c *gin.Context) {
+	listID, ok := parseBookmarkListID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		BookmarkID uuid.UUID `json:"bookmark_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	list, ok := ws.requireBookmarkListOwner(c, listID)
+	if !ok {
+		return
+	}
+
+	var owned bool
+	if err := ws.db.QueryRow("SELECT EXISTS(SELECT 1 FROM bookmarks WHERE id = $1 AND user_id = $2)",
+		req.BookmarkID, list.UserID).Scan(&owned); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark"})
+		return
+	}
+	if !owned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		return
+	}
+
+	if _, err := ws.db.Exec(`
+		INSERT INTO bookmark_list_items (list_id, bookmark_id) VALUES ($1, $2)
+		ON CONFLICT (list_id, bookmark_id) DO NOTHING`, listID, req.BookmarkID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add bookmark to list"})
+		return
+	}
+	ws.db.Exec("UPDATE bookmark_lists SET updated_at = NOW() WHERE id = $1", listID)
+
+	go ws.reindexBookmarks([]uuid.UUID{req.BookmarkID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark added to list"})
+}
+
+// RemoveBookmarkFromList takes a bookmark out of a list, keeping the bookmark
+func (ws *WorkService) RemoveBookmarkFromList(c *gin.Context) {
+	listID, ok := parseBookmarkListID(c)
+	if !ok {
+		return
+	}
+	bookmarkID, err := uuid.Parse(c.Param("bookmark_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+	if _, ok := ws.requireBookmarkListOwner(c, listID); !ok {
+		return
+	}
+
+	result, err := ws.db.Exec("DELETE FROM bookmark_list_items WHERE list_id = $1 AND bookmark_id = $2", listID, bookmarkID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove bookmark from list"})
+		return
+	}
+	if removed, _ := result.RowsAffected(); removed == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark is not in this list"})
+		return
+	}
+	ws.db.Exec("UPDATE bookmark_lists SET updated_at = NOW() WHERE id = $1", listID)
+
+	go ws.reindexBookmarks([]uuid.UUID{bookmarkID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark removed from list"})
+}
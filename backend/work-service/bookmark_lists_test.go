@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookmarkListName(t *testing.T) {
+	name, err := bookmarkListName("  Comfort reads ")
+	assert.NoError(t, err)
+	assert.Equal(t, "Comfort reads", name)
+
+	_, err = bookmarkListName("   ")
+	assert.ErrorIs(t, err, errBookmarkListName)
+
+	// Names are limited in characters, not bytes
+	name, err = bookmarkListName(strings.Repeat("é", maxBookmarkListName))
+	assert.NoError(t, err)
+	assert.Len(t, []rune(name), maxBookmarkListName)
+	_, err = bookmarkListName(strings.Repeat("a", maxBookmarkListName+1))
+	assert.ErrorIs(t, err, errBookmarkListName)
+}
+
+func TestIsDuplicateListName(t *testing.T) {
+	assert.True(t, isDuplicateListName(&pq.Error{Code: "23505"}))
+	assert.False(t, isDuplicateListName(&pq.Error{Code: "23503"}))
+	assert.False(t, isDuplicateListName(errors.New("connection refused")))
+	assert.False(t, isDuplicateListName(nil))
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// =============================================================================
+// BOOKMARK PRIVACY
+// Users choose whether new bookmarks start private, and can flip the privacy
+// of all their bookmarks, or those matching a filter, in one request. A bulk
+// change runs in the background in batches so a large collection doesn't
+// hold a request or a long transaction open; its progress is kept in Redis
+// for the user to poll. Each changed bookmark is sent to search so public
+// listings and counts follow.
+// =============================================================================
+
+const (
+	bookmarkPrivacyJobPrefix  = "bookmark_privacy_job:"
+	bookmarkPrivacyJobTTL     = 24 * time.Hour
+	bookmarkPrivacyUserLock   = bookmarkPrivacyJobPrefix + "user:"
+	bookmarkPrivacyLockTTL    = time.Hour
+	bookmarkPrivacyBatchSize  = 500
+	bookmarkPrivacyPreference = "bookmarks_private_by_default"
+)
+
+// Bulk privacy job statuses
+const (
+	bookmarkPrivacyRunning   = "running"
+	bookmarkPrivacyCompleted = "completed"
+	bookmarkPrivacyFailed    = "failed"
+)
+
+// BookmarkPrivacyFilter selects which of the caller's bookmarks to change.
+// An empty filter selects them all.
+type BookmarkPrivacyFilter struct {
+	Tag     string      `json:"tag"`    // One of the bookmark's own tags
+	Fandom  string      `json:"fandom"` // A fandom of the bookmarked work
+	Query   string      `json:"q"`      // Matches the work's title or the bookmark's notes
+	WorkIDs []uuid.UUID `json:"work_ids"`
+}
+
+// BookmarkPrivacyJob reports the progress of a bulk privacy change
+type BookmarkPrivacyJob struct {
+	ID         uuid.UUID             `json:"id"`
+	UserID     uuid.UUID             `json:"user_id"`
+	IsPrivate  bool                  `json:"is_private"`
+	Filter     BookmarkPrivacyFilter `json:"filter"`
+	Status     string                `json:"status"`
+	Total      int                   `json:"total"`     // Bookmarks selected that needed changing
+	Processed  int                   `json:"processed"` // Bookmarks changed so far
+	Error      string                `json:"error,omitempty"`
+	StartedAt  time.Time             `json:"started_at"`
+	FinishedAt *time.Time            `json:"finished_at,omitempty"`
+}
+
+// bookmarkPrivacyFilterSQL builds the condition selecting a user's
+// bookmarks that match the filter and aren't already at the target privacy.
+// Placeholders start at $1, which is the user and $2 the target privacy.
+func bookmarkPrivacyFilterSQL(userID uuid.UUID, isPrivate bool, filter BookmarkPrivacyFilter) (string, []interface{}) {
+	where := "b.user_id = $1 AND COALESCE(b.is_private, false) <> $2"
+	args := []interface{}{userID, isPrivate}
+
+	if tag := strings.TrimSpace(filter.Tag); tag != "" {
+		args = append(args, tag)
+		where += fmt.Sprintf(" AND $%d = ANY(b.tags)", len(args))
+	}
+	if fandom := strings.TrimSpace(filter.Fandom); fandom != "" {
+		args = append(args, fandom)
+		where += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM works w WHERE w.id = b.work_id AND EXISTS (SELECT 1 FROM unnest(w.fandoms) f WHERE LOWER(f) = LOWER($%d)))", len(args))
+	}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		args = append(args, "%"+q+"%")
+		where += fmt.Sprintf(" AND (b.notes ILIKE $%d OR EXISTS (SELECT 1 FROM works w WHERE w.id = b.work_id AND w.title ILIKE $%d))", len(args), len(args))
+	}
+	if len(filter.WorkIDs) > 0 {
+		args = append(args, pq.Array(uuidStrings(filter.WorkIDs)))
+		where += fmt.Sprintf(" AND b.work_id = ANY($%d::uuid[])", len(args))
+	}
+	return where, args
+}
+
+// defaultBookmarkPrivate reports whether the user's new bookmarks start
+// private
+func (ws *WorkService) defaultBookmarkPrivate(ctx context.Context, userID uuid.UUID) bool {
+	var private bool
+	err := ws.db.QueryRowContext(ctx, `
+		SELECT COALESCE((preferences->>'`+bookmarkPrivacyPreference+`')::boolean, false)
+		FROM users WHERE id = $1`, userID).Scan(&private)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to read bookmark privacy preference for %s: %v", userID, err)
+	}
+	return private
+}
+
+// GetBookmarkPreferences returns the caller's bookmark defaults
+func (ws *WorkService) GetBookmarkPreferences(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"private_by_default": ws.defaultBookmarkPrivate(c.Request.Context(), userUUID)})
+}
+
+// UpdateBookmarkPreferences sets whether the caller's new bookmarks start
+// private. Existing bookmarks are left as they are.
+func (ws *WorkService) UpdateBookmarkPreferences(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		PrivateByDefault *bool `json:"private_by_default" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	_, err := ws.db.ExecContext(c.Request.Context(), `
+		UPDATE users
+		SET preferences = jsonb_set(COALESCE(preferences, '{}'::jsonb), '{`+bookmarkPrivacyPreference+`}', to_jsonb($2::boolean)),
+			updated_at = NOW()
+		WHERE id = $1`, userID, *req.PrivateByDefault)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"private_by_default": *req.PrivateByDefault})
+}
+
+func (ws *WorkService) saveBookmarkPrivacyJob(ctx context.Context, job *BookmarkPrivacyJob) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return
+	}
+	if err := ws.redis.Set(ctx, bookmarkPrivacyJobPrefix+job.ID.String(), data, bookmarkPrivacyJobTTL).Err(); err != nil {
+		log.Printf("Failed to save bookmark privacy job %s: %v", job.ID, err)
+	}
+}
+
+// BulkUpdateBookmarkPrivacy starts making the caller's bookmarks, or those
+// matching a filter, public or private. It answers with the job to poll.
+func (ws *WorkService) BulkUpdateBookmarkPrivacy(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		IsPrivate *bool                 `json:"is_private" binding:"required"`
+		Filter    BookmarkPrivacyFilter `json:"filter"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	where, args := bookmarkPrivacyFilterSQL(userUUID, *req.IsPrivate, req.Filter)
+	var total int
+	if err := ws.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM bookmarks b WHERE "+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count bookmarks"})
+		return
+	}
+
+	job := &BookmarkPrivacyJob{
+		ID:        uuid.New(),
+		UserID:    userUUID,
+		IsPrivate: *req.IsPrivate,
+		Filter:    req.Filter,
+		Status:    bookmarkPrivacyRunning,
+		Total:     total,
+		StartedAt: time.Now(),
+	}
+
+	// One bulk change per user at a time, so two can't race over the same
+	// bookmarks in opposite directions
+	lockKey := bookmarkPrivacyUserLock + userUUID.String()
+	locked, err := ws.redis.SetNX(ctx, lockKey, job.ID.String(), bookmarkPrivacyLockTTL).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start bulk privacy change"})
+		return
+	}
+	if !locked {
+		running, _ := ws.redis.Get(ctx, lockKey).Result()
+		c.JSON(http.StatusConflict, gin.H{"error": "A bulk privacy change is already running", "job_id": running})
+		return
+	}
+
+	ws.saveBookmarkPrivacyJob(ctx, job)
+	go ws.runBookmarkPrivacyJob(job, lockKey)
+
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+// runBookmarkPrivacyJob changes the job's bookmarks a batch at a time,
+// recording progress after each batch
+func (ws *WorkService) runBookmarkPrivacyJob(job *BookmarkPrivacyJob, lockKey string) {
+	ctx := context.Background()
+	defer ws.redis.Del(ctx, lockKey)
+
+	finish := func(status string, err error) {
+		now := time.Now()
+		job.Status = status
+		job.FinishedAt = &now
+		if err != nil {
+			job.Error = "The change stopped partway; bookmarks already processed keep their new privacy"
+			log.Printf("Bookmark privacy job %s failed: %v", job.ID, err)
+		}
+		ws.saveBookmarkPrivacyJob(ctx, job)
+	}
+
+	where, args := bookmarkPrivacyFilterSQL(job.UserID, job.IsPrivate, job.Filter)
+	query := fmt.Sprintf(`
+		UPDATE bookmarks SET is_private = $2, updated_at = NOW()
+		WHERE id IN (
+			SELECT b.id FROM bookmarks b WHERE %s
+			ORDER BY b.id LIMIT %d
+		)
+		RETURNING id, work_id, created_at`, where, bookmarkPrivacyBatchSize)
+
+	for {
+		rows, err := ws.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			finish(bookmarkPrivacyFailed, err)
+			return
+		}
+		type changedBookmark struct {
+			id, workID uuid.UUID
+			createdAt  time.Time
+		}
+		var changed []changedBookmark
+		for rows.Next() {
+			var b changedBookmark
+			if err := rows.Scan(&b.id, &b.workID, &b.createdAt); err != nil {
+				rows.Close()
+				finish(bookmarkPrivacyFailed, err)
+				return
+			}
+			changed = append(changed, b)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			finish(bookmarkPrivacyFailed, err)
+			return
+		}
+		if len(changed) == 0 {
+			break
+		}
+
+		for _, b := range changed {
+			ws.sendBookmarkSearchEvent("bookmark_updated", b.id, b.workID, job.IsPrivate, b.createdAt)
+		}
+		job.Processed += len(changed)
+		// Bookmarks made since the count are picked up too
+		if job.Processed > job.Total {
+			job.Total = job.Processed
+		}
+		ws.saveBookmarkPrivacyJob(ctx, job)
+	}
+
+	finish(bookmarkPrivacyCompleted, nil)
+}
+
+// GetBulkBookmarkPrivacyJob reports the progress of one of the caller's
+// bulk privacy changes
+func (ws *WorkService) GetBulkBookmarkPrivacyJob(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	data, err := ws.redis.Get(c.Request.Context(), bookmarkPrivacyJobPrefix+jobID.String()).Bytes()
+	var job BookmarkPrivacyJob
+	if err != nil || json.Unmarshal(data, &job) != nil || job.UserID.String() != userID.(string) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
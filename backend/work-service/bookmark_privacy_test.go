@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookmarkPrivacyFilterSQL(t *testing.T) {
+	userID := uuid.New()
+
+	where, args := bookmarkPrivacyFilterSQL(userID, true, BookmarkPrivacyFilter{})
+	assert.Equal(t, "b.user_id = $1 AND COALESCE(b.is_private, false) <> $2", where)
+	assert.Equal(t, []interface{}{userID, true}, args, "an empty filter selects every bookmark needing the change")
+
+	where, args = bookmarkPrivacyFilterSQL(userID, false, BookmarkPrivacyFilter{
+		Tag:     " to reread ",
+		Query:   "slow burn",
+		WorkIDs: []uuid.UUID{uuid.New()},
+	})
+	assert.Contains(t, where, "$3 = ANY(b.tags)")
+	assert.Contains(t, where, "b.notes ILIKE $4")
+	assert.Contains(t, where, "w.title ILIKE $4")
+	assert.Contains(t, where, "b.work_id = ANY($5::uuid[])")
+	assert.NotContains(t, where, "fandoms", "an unset fandom adds no condition")
+	assert.Len(t, args, 5)
+	assert.Equal(t, "to reread", args[2])
+	assert.Equal(t, "%slow burn%", args[3])
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Bulk editing of an author's works
+
+// WorkBulkEditFilter selects which of the caller's works to edit
+type WorkBulkEditFilter struct {
+	Scope    string     `json:"scope" binding:"required,oneof=all fandom series"`
+	Fandom   string     `json:"fandom"`
+	SeriesID *uuid.UUID `json:"series_id"`
+}
+
+// WorkBulkEditPatch lists the changes to apply to every selected work
+type WorkBulkEditPatch struct {
+	AddTags          []string `json:"add_tags"`
+	RemoveTags       []string `json:"remove_tags"`
+	Rating           *string  `json:"rating" binding:"omitempty,oneof=not_rated general teen mature explicit"`
+	CommentPolicy    *string  `json:"comment_policy" binding:"omitempty,oneof=open users_only disabled"`
+	ModerateComments *bool    `json:"moderate_comments"`
+	DisableComments  *bool    `json:"disable_comments"`
+}
+
+// WorkBulkEditRequest is the body of POST /my/works/bulk-edit
+type WorkBulkEditRequest struct {
+	Filter WorkBulkEditFilter `json:"filter"`
+	Patch  WorkBulkEditPatch  `json:"patch"`
+	DryRun bool               `json:"dry_run"`
+}
+
+// WorkBulkEditChange describes what a bulk edit does to one work
+type WorkBulkEditChange struct {
+	WorkID      uuid.UUID              `json:"work_id"`
+	Title       string                 `json:"title"`
+	AddedTags   []string               `json:"added_tags,omitempty"`
+	RemovedTags []string               `json:"removed_tags,omitempty"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+	Conflict    string                 `json:"conflict,omitempty"`
+}
+
+func (ch WorkBulkEditChange) changed() bool {
+	return len(ch.AddedTags) > 0 || len(ch.RemovedTags) > 0 || len(ch.Fields) > 0
+}
+
+// bulkEditTagColumns maps tag types to the works array column holding them
+var bulkEditTagColumns = map[string]string{
+	"fandom":       "fandoms",
+	"character":    "characters",
+	"relationship": "relationships",
+	"freeform":     "freeform_tags",
+	"additional":   "freeform_tags",
+	"warning":      "warnings",
+	"category":     "category",
+}
+
+// bulkEditColumnOrder fixes the order tag columns are read, planned and written in
+var bulkEditColumnOrder = []string{"fandoms", "characters", "relationships", "freeform_tags", "warnings", "category"}
+
+type bulkEditTag struct {
+	ID     uuid.UUID
+	Name   string
+	Column string
+}
+
+// bulkEditWork is the editable state of one work
+type bulkEditWork struct {
+	ID               uuid.UUID
+	Title            string
+	Rating           string
+	CommentPolicy    string
+	ModerateComments bool
+	DisableComments  bool
+	Tags             map[string][]string // column -> tag names
+}
+
+// planWorkBulkEdit applies the patch to the work in memory and reports what
+// changed. Tag matching ignores case. A work left without a fandom is
+// reported as a conflict.
+func planWorkBulkEdit(work *bulkEditWork, patch WorkBulkEditPatch, add []bulkEditTag) WorkBulkEditChange {
+	change := WorkBulkEditChange{WorkID: work.ID, Title: work.Title, Fields: map[string]interface{}{}}
+
+	removing := make(map[string]bool, len(patch.RemoveTags))
+	for _, name := range patch.RemoveTags {
+		removing[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for _, column := range bulkEditColumnOrder {
+		kept := work.Tags[column][:0:0]
+		for _, name := range work.Tags[column] {
+			if removing[strings.ToLower(name)] {
+				change.RemovedTags = append(change.RemovedTags, name)
+				continue
+			}
+			kept = append(kept, name)
+		}
+		work.Tags[column] = kept
+	}
+
+	for _, tag := range add {
+		present := false
+		for _, name := range work.Tags[tag.Column] {
+			if strings.EqualFold(name, tag.Name) {
+				present = true
+				break
+			}
+		}
+		if !present {
+			work.Tags[tag.Column] = append(work.Tags[tag.Column], tag.Name)
+			change.AddedTags = append(change.AddedTags, tag.Name)
+		}
+	}
+
+	if patch.Rating != nil && *patch.Rating != work.Rating {
+		work.Rating = *patch.Rating
+		change.Fields["rating"] = work.Rating
+	}
+	if patch.CommentPolicy != nil && *patch.CommentPolicy != work.CommentPolicy {
+		work.CommentPolicy = *patch.CommentPolicy
+		change.Fields["comment_policy"] = work.CommentPolicy
+	}
+	if patch.ModerateComments != nil && *patch.ModerateComments != work.ModerateComments {
+		work.ModerateComments = *patch.ModerateComments
+		change.Fields["moderate_comments"] = work.ModerateComments
+	}
+	if patch.DisableComments != nil && *patch.DisableComments != work.DisableComments {
+		work.DisableComments = *patch.DisableComments
+		change.Fields["disable_comments"] = work.DisableComments
+	}
+
+	if len(work.Tags["fandoms"]) == 0 {
+		change.Conflict = "work would be left without a fandom"
+	}
+	if len(change.Fields) == 0 {
+		change.Fields = nil
+	}
+	return change
+}
+
+// BulkEditWorks applies one patch to many of the caller's works in a single
+// transaction. With dry_run the planned changes are returned and nothing is
+// written.
+func (ws *WorkService) BulkEditWorks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req WorkBulkEditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	patch := req.Patch
+	if len(patch.AddTags) == 0 && len(patch.RemoveTags) == 0 && patch.Rating == nil &&
+		patch.CommentPolicy == nil && patch.ModerateComments == nil && patch.DisableComments == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes provided"})
+		return
+	}
+	if patch.Rating != nil && ws.rejectDisallowedRating(c, *patch.Rating) {
+		return
+	}
+
+	query := `
+		SELECT w.id, w.title, w.rating, COALESCE(w.comment_policy, 'open'),
+			COALESCE(w.moderate_comments, false), COALESCE(w.disable_comments, false),
+			w.fandoms, w.characters, w.relationships, w.freeform_tags, w.warnings, w.category
+		FROM works w
+		WHERE w.id IN (
+			SELECT c.creation_id FROM creatorships c
+			JOIN pseuds p ON c.pseud_id = p.id
+			WHERE c.creation_type = 'Work' AND c.approved = true AND p.user_id = $1
+		)`
+	args := []interface{}{userUUID}
+	switch req.Filter.Scope {
+	case "fandom":
+		if strings.TrimSpace(req.Filter.Fandom) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "filter.fandom is required for the fandom scope"})
+			return
+		}
+		query += " AND EXISTS (SELECT 1 FROM unnest(w.fandoms) f WHERE LOWER(f) = LOWER($2))"
+		args = append(args, strings.TrimSpace(req.Filter.Fandom))
+	case "series":
+		if req.Filter.SeriesID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "filter.series_id is required for the series scope"})
+			return
+		}
+		query += " AND w.id IN (SELECT work_id FROM series_works WHERE series_id = $2)"
+		args = append(args, *req.Filter.SeriesID)
+	}
+	query += " ORDER BY w.created_at FOR UPDATE OF w"
+
+	// Tags being added must already exist so we know which column they go in
+	var addTags, removeTags []bulkEditTag
+	if len(patch.AddTags) > 0 {
+		addTags, err = ws.lookupBulkEditTags(patch.AddTags)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up tags"})
+			return
+		}
+		if missing := missingBulkEditTags(patch.AddTags, addTags); len(missing) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or unsupported tags", "tags": missing})
+			return
+		}
+	}
+	if len(patch.RemoveTags) > 0 {
+		removeTags, err = ws.lookupBulkEditTags(patch.RemoveTags)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up tags"})
+			return
+		}
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch works"})
+		return
+	}
+	works := []*bulkEditWork{}
+	for rows.Next() {
+		w := &bulkEditWork{Tags: map[string][]string{}}
+		cols := make([]pq.StringArray, len(bulkEditColumnOrder))
+		dest := []interface{}{&w.ID, &w.Title, &w.Rating, &w.CommentPolicy, &w.ModerateComments, &w.DisableComments}
+		for i := range cols {
+			dest = append(dest, &cols[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read works"})
+			return
+		}
+		for i, column := range bulkEditColumnOrder {
+			w.Tags[column] = []string(cols[i])
+		}
+		works = append(works, w)
+	}
+	rows.Close()
+
+	changes := []WorkBulkEditChange{}
+	conflicts := []WorkBulkEditChange{}
+	changedWorks := []*bulkEditWork{}
+	for _, w := range works {
+		change := planWorkBulkEdit(w, patch, addTags)
+		if change.Conflict != "" {
+			conflicts = append(conflicts, change)
+			continue
+		}
+		if change.changed() {
+			changes = append(changes, change)
+			changedWorks = append(changedWorks, w)
+		}
+	}
+
+	response := gin.H{
+		"dry_run":   req.DryRun,
+		"matched":   len(works),
+		"changed":   len(changes),
+		"changes":   changes,
+		"conflicts": conflicts,
+	}
+	if len(conflicts) > 0 && !req.DryRun {
+		response["error"] = "Some works cannot be edited this way; nothing was changed"
+		c.JSON(http.StatusUnprocessableEntity, response)
+		return
+	}
+	if req.DryRun || len(changedWorks) == 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	for i, w := range changedWorks {
+		_, err = tx.Exec(`
+			UPDATE works SET fandoms = $2, characters = $3, relationships = $4, freeform_tags = $5,
+				warnings = $6, category = $7, rating = $8, comment_policy = $9,
+				moderate_comments = $10, disable_comments = $11, updated_at = NOW(),
+				version = version + 1
+			WHERE id = $1`,
+			w.ID, pq.Array(w.Tags["fandoms"]), pq.Array(w.Tags["characters"]), pq.Array(w.Tags["relationships"]),
+			pq.Array(w.Tags["freeform_tags"]), pq.Array(w.Tags["warnings"]), pq.Array(w.Tags["category"]),
+			w.Rating, w.CommentPolicy, w.ModerateComments, w.DisableComments)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update works", "work_id": w.ID})
+			return
+		}
+
+		if err := syncBulkEditWorkTags(tx, w.ID, changes[i], addTags, removeTags); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work tags", "work_id": w.ID})
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit bulk edit"})
+		return
+	}
+
+	for _, w := range changedWorks {
+		if ws.redis != nil {
+			ws.redis.Del(c.Request.Context(), fmt.Sprintf("work:%s", w.ID))
+		}
+		if ws.cache != nil {
+			ws.InvalidateWorkCache(w.ID)
+		}
+	}
+	if ws.cache != nil {
+		ws.InvalidateUserCache(userUUID)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// lookupBulkEditTags finds existing tags by name, ignoring tag types that
+// do not live in a works array column (ratings are set via the rating field)
+func (ws *WorkService) lookupBulkEditTags(names []string) ([]bulkEditTag, error) {
+	lowered := make([]string, 0, len(names))
+	for _, name := range names {
+		lowered = append(lowered, strings.ToLower(strings.TrimSpace(name)))
+	}
+
+	rows, err := ws.db.Query("SELECT id, name, type FROM tags WHERE LOWER(name) = ANY($1)", pq.Array(lowered))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []bulkEditTag{}
+	seen := map[string]bool{}
+	for rows.Next() {
+		var tag bulkEditTag
+		var tagType string
+		if err := rows.Scan(&tag.ID, &tag.Name, &tagType); err != nil {
+			return nil, err
+		}
+		column, ok := bulkEditTagColumns[tagType]
+		if !ok || seen[strings.ToLower(tag.Name)] {
+			continue
+		}
+		seen[strings.ToLower(tag.Name)] = true
+		tag.Column = column
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func missingBulkEditTags(names []string, found []bulkEditTag) []string {
+	have := make(map[string]bool, len(found))
+	for _, tag := range found {
+		have[strings.ToLower(tag.Name)] = true
+	}
+	missing := []string{}
+	for _, name := range names {
+		if !have[strings.ToLower(strings.TrimSpace(name))] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// syncBulkEditWorkTags mirrors a work's tag changes into work_tags
+func syncBulkEditWorkTags(tx *sql.Tx, workID uuid.UUID, change WorkBulkEditChange, addTags, removeTags []bulkEditTag) error {
+	for _, name := range change.AddedTags {
+		for _, tag := range addTags {
+			if !strings.EqualFold(tag.Name, name) {
+				continue
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO work_tags (work_id, tag_id) VALUES ($1, $2)
+				ON CONFLICT (work_id, tag_id) DO NOTHING`, workID, tag.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range change.RemovedTags {
+		for _, tag := range removeTags {
+			if !strings.EqualFold(tag.Name, name) {
+				continue
+			}
+			if _, err := tx.Exec("DELETE FROM work_tags WHERE work_id = $1 AND tag_id = $2", workID, tag.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBulkEditWork() *bulkEditWork {
+	return &bulkEditWork{
+		ID:            uuid.New(),
+		Title:         "Test Work",
+		Rating:        "teen",
+		CommentPolicy: "open",
+		Tags: map[string][]string{
+			"fandoms":       {"Good Omens"},
+			"characters":    {"Crowley"},
+			"freeform_tags": {"Fluff", "Angst"},
+		},
+	}
+}
+
+func TestPlanWorkBulkEdit(t *testing.T) {
+	t.Run("adds and removes tags ignoring case", func(t *testing.T) {
+		work := newBulkEditWork()
+		patch := WorkBulkEditPatch{RemoveTags: []string{"angst"}}
+		add := []bulkEditTag{
+			{Name: "Hurt/Comfort", Column: "freeform_tags"},
+			{Name: "crowley", Column: "characters"},
+		}
+
+		change := planWorkBulkEdit(work, patch, add)
+
+		assert.True(t, change.changed())
+		assert.Equal(t, []string{"Hurt/Comfort"}, change.AddedTags)
+		assert.Equal(t, []string{"Angst"}, change.RemovedTags)
+		assert.Equal(t, []string{"Fluff", "Hurt/Comfort"}, work.Tags["freeform_tags"])
+		assert.Equal(t, []string{"Crowley"}, work.Tags["characters"])
+		assert.Empty(t, change.Conflict)
+	})
+
+	t.Run("only reports fields that differ", func(t *testing.T) {
+		work := newBulkEditWork()
+		teen, disabled := "teen", "disabled"
+		moderate := true
+		patch := WorkBulkEditPatch{Rating: &teen, CommentPolicy: &disabled, ModerateComments: &moderate}
+
+		change := planWorkBulkEdit(work, patch, nil)
+
+		assert.Equal(t, map[string]interface{}{"comment_policy": "disabled", "moderate_comments": true}, change.Fields)
+		assert.Equal(t, "disabled", work.CommentPolicy)
+	})
+
+	t.Run("unchanged work", func(t *testing.T) {
+		work := newBulkEditWork()
+		change := planWorkBulkEdit(work, WorkBulkEditPatch{RemoveTags: []string{"Not There"}}, nil)
+		assert.False(t, change.changed())
+	})
+
+	t.Run("removing the last fandom is a conflict", func(t *testing.T) {
+		work := newBulkEditWork()
+		change := planWorkBulkEdit(work, WorkBulkEditPatch{RemoveTags: []string{"Good Omens"}}, nil)
+		assert.NotEmpty(t, change.Conflict)
+	})
+}
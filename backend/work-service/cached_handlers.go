@@ -13,6 +13,7 @@ import (
 	"github.com/lib/pq"
 
 	"nuclear-ao3/shared/cache"
+	"nuclear-ao3/shared/middleware"
 	"nuclear-ao3/shared/models"
 )
 
@@ -42,10 +43,10 @@ func (ws *WorkService) CachedGetWork(c *gin.Context) {
 	err := ws.cache.GetOrSet(ctx, cacheKey, &cachedWork, cache.MediumTTL, func() (interface{}, error) {
 		// Cache miss - fetch from database
 		return ws.fetchWorkFromDB(ctx, workID)
-	})
+	}, workCacheTag(workID))
 
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if cache.IsNotFound(err) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve work"})
@@ -55,10 +56,17 @@ func (ws *WorkService) CachedGetWork(c *gin.Context) {
 
 	// Apply privacy filters (this needs to be done per-request)
 	userID := ws.getUserIDFromContext(c)
-	if !ws.canViewWork(&cachedWork, userID) {
+	if !ws.canViewWork(ctx, &cachedWork, userID) &&
+		!(cachedWork.Status == "unlisted" && ws.shareLinkGrantsAccess(c, workID)) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
 		return
 	}
+	if ws.rejectLoggedOutReader(c, cachedWork.Rating) {
+		return
+	}
+	if cachedWork.RestrictedToAdults && ws.rejectAgeGatedReader(c, workID, cachedWork.Title, cachedWork.Rating) {
+		return
+	}
 
 	// Fetch authors (not cached as it depends on viewer's permissions)
 	authors, err := ws.fetchWorkAuthors(ctx, workID, userID)
@@ -68,38 +76,19 @@ func (ws *WorkService) CachedGetWork(c *gin.Context) {
 		authors = []models.WorkAuthor{}
 	}
 
-	// Return work with authors in expected format
+	// Return work with authors, series navigation and related works in expected format
 	response := gin.H{
-		"work":    cachedWork,
-		"authors": authors,
-	}
-	c.JSON(http.StatusOK, response)
-}
-
-// CachedGetWorkStats provides cached work statistics
-func (ws *WorkService) CachedGetWorkStats(c *gin.Context) {
-	workIDParam := c.Param("work_id")
-	ctx := c.Request.Context()
-
-	workID, err := uuid.Parse(workIDParam)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
-		return
+		"work":          cachedWork,
+		"authors":       authors,
+		"series":        ws.getSeriesNavigation(ctx, workID),
+		"related_works": ws.getRelatedWorks(ctx, workID),
 	}
-
-	cacheKey := fmt.Sprintf("work_stats:%s", workID.String())
-	var stats map[string]interface{}
-
-	err = ws.cache.GetOrSet(ctx, cacheKey, &stats, cache.ShortTTL, func() (interface{}, error) {
-		return ws.fetchWorkStatsFromDB(ctx, workID)
-	})
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve work stats"})
+	// The response is small, so its ETag covers all of it: the work's
+	// updated_at alone misses authors and series changes
+	if notModified(c, versionETag(cachedWork.Version, cachedWork.ID, cachedWork.UpdatedAt, response)) {
 		return
 	}
-
-	c.JSON(http.StatusOK, stats)
+	c.JSON(http.StatusOK, response)
 }
 
 // CachedSearchWorks provides cached search results for common queries
@@ -125,6 +114,26 @@ func (ws *WorkService) CachedSearchWorks(c *gin.Context) {
 
 // Helper functions
 
+// notModified marks a work response as revalidated on every use, by the
+// gateway as well as the browser, and answers 304 when the client's copy is
+// current. Who may read a work depends on the viewer, so caches keep a copy
+// per Authorization header.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Vary", "Authorization")
+	return middleware.NotModified(c, etag)
+}
+
+// chaptersETag identifies a chapter list by each chapter's position and
+// last update, so clients revalidate without the chapter text being hashed
+func chaptersETag(workID uuid.UUID, chapters []models.Chapter) string {
+	parts := []interface{}{workID}
+	for _, ch := range chapters {
+		parts = append(parts, ch.ID, ch.Number, ch.Status, ch.Language, ch.UpdatedAt)
+	}
+	return middleware.ETag(parts...)
+}
+
 func (ws *WorkService) parseWorkID(workIDParam string) (uuid.UUID, bool, string) {
 	// Try UUID first
 	if workID, err := uuid.Parse(workIDParam); err == nil {
@@ -158,33 +167,7 @@ func (ws *WorkService) fetchWorkFromDB(ctx context.Context, workID uuid.UUID) (i
 	// Handle array fields that might be NULL
 	var fandoms, characters, relationships, freeformTags pq.StringArray
 
-	err := ws.db.QueryRowContext(ctx, `
-		SELECT 
-			w.id, w.legacy_id, w.title, 
-			COALESCE(w.summary, '') as summary, 
-			COALESCE(w.notes, '') as notes, 
-			w.user_id, u.username, w.language, w.rating, 
-			COALESCE(w.word_count, 0) as word_count, 
-			COALESCE(w.chapter_count, 1) as chapter_count, 
-			w.max_chapters,
-			COALESCE(w.status, 'draft') as status, 
-			COALESCE(w.restricted_to_users, false) as restricted_to_users,
-			COALESCE(w.restricted_to_adults, false) as restricted_to_adults,
-			COALESCE(w.comment_policy, 'open') as comment_policy,
-			COALESCE(w.moderate_comments, false) as moderate_comments,
-			COALESCE(w.disable_comments, false) as disable_comments,
-			COALESCE(w.in_anon_collection, false) as in_anon_collection,
-			COALESCE(w.in_unrevealed_collection, false) as in_unrevealed_collection,
-			COALESCE(w.is_anonymous, false) as is_anonymous,
-			COALESCE(w.fandoms, '{}') as fandoms,
-			COALESCE(w.characters, '{}') as characters,
-			COALESCE(w.relationships, '{}') as relationships,
-			COALESCE(w.freeform_tags, '{}') as freeform_tags,
-			w.published_at, w.updated_at, w.created_at
-		FROM works w
-		JOIN users u ON w.user_id = u.id
-		WHERE w.id = $1
-	`, workID).Scan(
+	err := ws.queryRow(ctx, workPageQuery, workID).Scan(
 		&work.ID, &legacyID, &work.Title, &work.Summary, &work.Notes,
 		&work.UserID, &work.Username, &work.Language, &work.Rating,
 		&work.WordCount, &work.ChapterCount, &maxChapters, &work.Status,
@@ -192,7 +175,7 @@ func (ws *WorkService) fetchWorkFromDB(ctx context.Context, workID uuid.UUID) (i
 		&work.ModerateComments, &work.DisableComments, &work.InAnonCollection,
 		&work.InUnrevealedCollection, &work.IsAnonymous,
 		&fandoms, &characters, &relationships, &freeformTags,
-		&publishedAt, &work.UpdatedAt, &work.CreatedAt,
+		&publishedAt, &work.UnpublishAt, &work.UpdatedAt, &work.CreatedAt, &work.Version,
 	)
 
 	if err != nil {
@@ -294,33 +277,6 @@ func (ws *WorkService) fetchWorkAuthors(ctx context.Context, workID uuid.UUID, u
 	return authors, nil
 }
 
-func (ws *WorkService) fetchWorkStatsFromDB(ctx context.Context, workID uuid.UUID) (interface{}, error) {
-	stats := make(map[string]interface{})
-
-	// Fetch various stats
-	var hits, kudos, comments, bookmarks int
-	err := ws.db.QueryRowContext(ctx, `
-		SELECT 
-			COALESCE(hit_count, 0) as hits,
-			COALESCE(kudos_count, 0) as kudos,
-			COALESCE(comment_count, 0) as comments,
-			COALESCE(bookmark_count, 0) as bookmarks
-		FROM works 
-		WHERE id = $1
-	`, workID).Scan(&hits, &kudos, &comments, &bookmarks)
-
-	if err != nil {
-		return nil, err
-	}
-
-	stats["hits"] = hits
-	stats["kudos"] = kudos
-	stats["comments"] = comments
-	stats["bookmarks"] = bookmarks
-
-	return stats, nil
-}
-
 func (ws *WorkService) performSearchFromDB(ctx context.Context, params map[string][]string) (interface{}, error) {
 	// Placeholder for search implementation
 	results := map[string]interface{}{
@@ -360,7 +316,25 @@ func (ws *WorkService) getUserIDFromContext(c *gin.Context) *uuid.UUID {
 	return nil
 }
 
-func (ws *WorkService) canViewWork(work *models.Work, userID *uuid.UUID) bool {
+// isWorkCreator reports whether the user is an approved creator of the work
+func (ws *WorkService) isWorkCreator(ctx context.Context, workID, userID uuid.UUID) (bool, error) {
+	var isCreator bool
+	err := ws.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM creatorships cr
+			JOIN pseuds p ON cr.pseud_id = p.id
+			WHERE cr.creation_id = $1 AND cr.creation_type = 'Work'
+			AND cr.approved = true AND p.user_id = $2
+		) OR EXISTS(SELECT 1 FROM works WHERE id = $1 AND user_id = $2)`, workID, userID).Scan(&isCreator)
+	return isCreator, err
+}
+
+func (ws *WorkService) canViewWork(ctx context.Context, work *models.Work, userID *uuid.UUID) bool {
+	// Works in the trash are only reachable through /my/trash
+	if work.Status == "deleted" {
+		return false
+	}
+
 	// Check if work is in draft status
 	if work.Status == "draft" {
 		// Only the author can view their own drafts
@@ -369,6 +343,19 @@ func (ws *WorkService) canViewWork(work *models.Work, userID *uuid.UUID) bool {
 		}
 	}
 
+	// Unlisted works are read by their authors, co-authors included, and
+	// by everyone else through their share link
+	if work.Status == "unlisted" {
+		if userID == nil {
+			return false
+		}
+		if *userID != work.UserID {
+			if isCreator, err := ws.isWorkCreator(ctx, work.ID, *userID); err != nil || !isCreator {
+				return false
+			}
+		}
+	}
+
 	// Check other privacy settings
 	if work.RestrictedToUsers && userID == nil {
 		return false
@@ -381,18 +368,26 @@ func (ws *WorkService) canViewWork(work *models.Work, userID *uuid.UUID) bool {
 
 // Cache invalidation helpers
 
-func (ws *WorkService) InvalidateWorkCache(workID uuid.UUID) error {
-	ctx := context.Background()
+// workCacheTag tags every key cached from a work: its page, stats, chapter
+// list, series navigation and related works. stats-service tags the stats
+// it caches the same way.
+func workCacheTag(workID uuid.UUID) string {
+	return fmt.Sprintf("work:%s", workID.String())
+}
 
-	// Invalidate work cache
-	cacheKey := fmt.Sprintf("work:%s", workID.String())
-	if err := ws.cache.Delete(ctx, cacheKey); err != nil {
-		return err
+func chapterListCacheKey(workID uuid.UUID) string {
+	return fmt.Sprintf("work_chapters:%s", workID.String())
+}
+
+// InvalidateWorkCache clears everything cached from a work, and the search
+// results it may appear in
+func (ws *WorkService) InvalidateWorkCache(workID uuid.UUID) error {
+	if ws.cache == nil {
+		return nil
 	}
+	ctx := context.Background()
 
-	// Invalidate stats cache
-	statsKey := fmt.Sprintf("work_stats:%s", workID.String())
-	if err := ws.cache.Delete(ctx, statsKey); err != nil {
+	if err := ws.cache.InvalidateTag(ctx, workCacheTag(workID)); err != nil {
 		return err
 	}
 
@@ -400,6 +395,18 @@ func (ws *WorkService) InvalidateWorkCache(workID uuid.UUID) error {
 	return ws.cache.DeletePattern(ctx, "search:*")
 }
 
+// invalidateWork clears everything cached from a work but leaves search
+// results alone, for changes such as kudos and comments that only move its
+// stats
+func (ws *WorkService) invalidateWork(ctx context.Context, workID uuid.UUID) {
+	if ws.cache == nil {
+		return
+	}
+	if err := ws.cache.InvalidateTag(ctx, workCacheTag(workID)); err != nil {
+		log.Printf("Failed to invalidate cache for work %s: %v", workID, err)
+	}
+}
+
 func (ws *WorkService) InvalidateUserCache(userID uuid.UUID) error {
 	ctx := context.Background()
 
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestChaptersETag(t *testing.T) {
+	workID := uuid.New()
+	updated := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	chapters := []models.Chapter{
+		{ID: uuid.New(), Number: 1, Status: "posted", Language: "en", UpdatedAt: updated, Content: "First"},
+		{ID: uuid.New(), Number: 2, Status: "draft", Language: "en", UpdatedAt: updated, Content: "Second"},
+	}
+	etag := chaptersETag(workID, chapters)
+
+	chapters[0].Content = "Edited without touching updated_at"
+	assert.Equal(t, etag, chaptersETag(workID, chapters), "chapter text isn't hashed")
+
+	chapters[1].UpdatedAt = updated.Add(time.Minute)
+	assert.NotEqual(t, etag, chaptersETag(workID, chapters), "an edited chapter changes the list")
+
+	assert.NotEqual(t, chaptersETag(workID, chapters), chaptersETag(workID, chapters[:1]), "a removed chapter changes the list")
+}
+
+func TestNotModifiedRevalidatesPerViewer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/works/:work_id", func(c *gin.Context) {
+		if notModified(c, `"v1"`) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"work": "Title"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/works/1", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Authorization", w.Header().Get("Vary"))
+}
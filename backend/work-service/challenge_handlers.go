@@ -0,0 +1,1209 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"nuclear-ao3/shared/models"
+)
+
+// Challenge handlers for the work service: gift exchange and prompt meme
+// configuration, sign-ups, matching and assignment tracking
+
+// ChallengeSettingsRequest configures a collection as a challenge
+type ChallengeSettingsRequest struct {
+	ChallengeType    string                     `json:"challenge_type" binding:"required,oneof=gift_exchange prompt_meme"`
+	SignupsOpen      bool                       `json:"signups_open"`
+	SignupsOpenAt    *time.Time                 `json:"signups_open_at"`
+	SignupsCloseAt   *time.Time                 `json:"signups_close_at"`
+	AssignmentsDueAt *time.Time                 `json:"assignments_due_at"`
+	WorksRevealAt    *time.Time                 `json:"works_reveal_at"`
+	AuthorsRevealAt  *time.Time                 `json:"authors_reveal_at"`
+	RequestsMin      *int                       `json:"requests_min"`
+	RequestsMax      *int                       `json:"requests_max"`
+	OffersMin        *int                       `json:"offers_min"`
+	OffersMax        *int                       `json:"offers_max"`
+	PromptTagLimits  map[string]models.TagLimit `json:"prompt_tag_limits"`
+	MatchTagTypes    []string                   `json:"match_tag_types"`
+	MatchMinTags     *int                       `json:"match_min_tags"`
+	ClaimLimit       *int                       `json:"claim_limit"`
+	AllowMultiClaims *bool                      `json:"allow_multiple_claims"`
+	TagSetIDs        *[]uuid.UUID               `json:"tag_set_ids"` // Restricts sign-up tags; omit to keep the current sets
+}
+
+// ChallengePromptInput is a request or offer submitted with a sign-up
+type ChallengePromptInput struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	IsAnonymous bool     `json:"is_anonymous"`
+}
+
+// ChallengeSignupRequest creates or replaces the caller's sign-up
+type ChallengeSignupRequest struct {
+	PseudID  *uuid.UUID             `json:"pseud_id"`
+	Requests []ChallengePromptInput `json:"requests"`
+	Offers   []ChallengePromptInput `json:"offers"`
+}
+
+const challengeSettingsColumns = `collection_id, challenge_type, signups_open, signups_open_at, signups_close_at,
+	assignments_due_at, works_reveal_at, authors_reveal_at, requests_min, requests_max, offers_min, offers_max,
+	prompt_tag_limits, match_tag_types, match_min_tags, claim_limit, allow_multiple_claims,
+	assignments_sent_at, created_at, updated_at`
+
+func scanChallengeSettings(row *sql.Row) (*models.ChallengeSettings, error) {
+	var s models.ChallengeSettings
+	var limits []byte
+	err := row.Scan(&s.CollectionID, &s.ChallengeType, &s.SignupsOpen, &s.SignupsOpenAt, &s.SignupsCloseAt,
+		&s.AssignmentsDueAt, &s.WorksRevealAt, &s.AuthorsRevealAt, &s.RequestsMin, &s.RequestsMax,
+		&s.OffersMin, &s.OffersMax, &limits, pq.Array(&s.MatchTagTypes), &s.MatchMinTags,
+		&s.ClaimLimit, &s.AllowMultiClaims, &s.AssignmentsSentAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.PromptTagLimits = make(map[string]models.TagLimit)
+	if len(limits) > 0 {
+		if err := json.Unmarshal(limits, &s.PromptTagLimits); err != nil {
+			return nil, err
+		}
+	}
+	return &s, nil
+}
+
+func (ws *WorkService) getChallengeSettings(collectionID uuid.UUID) (*models.ChallengeSettings, error) {
+	settings, err := scanChallengeSettings(ws.db.QueryRow(
+		"SELECT "+challengeSettingsColumns+" FROM challenge_settings WHERE collection_id = $1", collectionID))
+	if err != nil {
+		return nil, err
+	}
+	if settings.TagSetIDs, err = ws.challengeTagSetIDs(collectionID); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// signupsAcceptingAt reports whether sign-ups are open at the given time
+func signupsAcceptingAt(s *models.ChallengeSettings, now time.Time) bool {
+	if !s.SignupsOpen {
+		return false
+	}
+	if s.SignupsOpenAt != nil && now.Before(*s.SignupsOpenAt) {
+		return false
+	}
+	if s.SignupsCloseAt != nil && !now.Before(*s.SignupsCloseAt) {
+		return false
+	}
+	return true
+}
+
+// lookupTagTypes maps lowercased tag names to their tag type
+func (ws *WorkService) lookupTagTypes(names []string) (map[string]string, error) {
+	lowered := make([]string, 0, len(names))
+	for _, name := range names {
+		lowered = append(lowered, strings.ToLower(strings.TrimSpace(name)))
+	}
+
+	types := make(map[string]string)
+	if len(lowered) == 0 {
+		return types, nil
+	}
+
+	rows, err := ws.db.Query("SELECT LOWER(name), type FROM tags WHERE LOWER(name) = ANY($1)", pq.Array(lowered))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, tagType string
+		if err := rows.Scan(&name, &tagType); err != nil {
+			return nil, err
+		}
+		types[name] = tagType
+	}
+	return types, rows.Err()
+}
+
+// GetChallenge returns a collection's challenge settings and sign-up totals
+func (ws *WorkService) GetChallenge(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	settings, err := ws.getChallengeSettings(collectionID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection is not a challenge"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenge"})
+		return
+	}
+
+	var signupCount int
+	ws.db.QueryRow("SELECT COUNT(*) FROM challenge_signups WHERE collection_id = $1", collectionID).Scan(&signupCount)
+
+	c.JSON(http.StatusOK, gin.H{
+		"challenge":         settings,
+		"signups_accepting": signupsAcceptingAt(settings, time.Now()),
+		"signup_count":      signupCount,
+	})
+}
+
+// ConfigureChallenge creates or updates the challenge settings of a collection
+func (ws *WorkService) ConfigureChallenge(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var req ChallengeSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	if !ws.requireCollectionOwner(c, collectionID) {
+		return
+	}
+
+	intOr := func(v *int, def int) int {
+		if v != nil {
+			return *v
+		}
+		return def
+	}
+
+	// Prompt memes only take requests; offers are what a claim is for
+	offersDefault := 1
+	if req.ChallengeType == models.ChallengeTypePromptMeme {
+		offersDefault = 0
+	}
+
+	requestsMin, requestsMax := intOr(req.RequestsMin, 1), intOr(req.RequestsMax, 1)
+	offersMin, offersMax := intOr(req.OffersMin, offersDefault), intOr(req.OffersMax, offersDefault)
+	if requestsMin < 0 || requestsMax < requestsMin || offersMin < 0 || offersMax < offersMin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request/offer limits"})
+		return
+	}
+	if req.SignupsOpenAt != nil && req.SignupsCloseAt != nil && !req.SignupsCloseAt.After(*req.SignupsOpenAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sign-ups must close after they open"})
+		return
+	}
+
+	allowMultiClaims := true
+	if req.AllowMultiClaims != nil {
+		allowMultiClaims = *req.AllowMultiClaims
+	}
+
+	matchTagTypes := req.MatchTagTypes
+	if len(matchTagTypes) == 0 {
+		matchTagTypes = []string{"fandom"}
+	}
+	limits := req.PromptTagLimits
+	if limits == nil {
+		limits = map[string]models.TagLimit{}
+	}
+	limitsJSON, err := json.Marshal(limits)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt tag limits"})
+		return
+	}
+
+	collectionType := "exchange"
+	if req.ChallengeType == models.ChallengeTypePromptMeme {
+		collectionType = "prompt_meme"
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO challenge_settings (collection_id, challenge_type, signups_open, signups_open_at, signups_close_at,
+			assignments_due_at, works_reveal_at, authors_reveal_at, requests_min, requests_max, offers_min, offers_max,
+			prompt_tag_limits, match_tag_types, match_min_tags, claim_limit, allow_multiple_claims)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		ON CONFLICT (collection_id) DO UPDATE SET
+			challenge_type = EXCLUDED.challenge_type,
+			signups_open = EXCLUDED.signups_open,
+			signups_open_at = EXCLUDED.signups_open_at,
+			signups_close_at = EXCLUDED.signups_close_at,
+			assignments_due_at = EXCLUDED.assignments_due_at,
+			works_reveal_at = EXCLUDED.works_reveal_at,
+			authors_reveal_at = EXCLUDED.authors_reveal_at,
+			requests_min = EXCLUDED.requests_min,
+			requests_max = EXCLUDED.requests_max,
+			offers_min = EXCLUDED.offers_min,
+			offers_max = EXCLUDED.offers_max,
+			prompt_tag_limits = EXCLUDED.prompt_tag_limits,
+			match_tag_types = EXCLUDED.match_tag_types,
+			match_min_tags = EXCLUDED.match_min_tags,
+			claim_limit = EXCLUDED.claim_limit,
+			allow_multiple_claims = EXCLUDED.allow_multiple_claims,
+			updated_at = NOW()`,
+		collectionID, req.ChallengeType, req.SignupsOpen, req.SignupsOpenAt, req.SignupsCloseAt,
+		req.AssignmentsDueAt, req.WorksRevealAt, req.AuthorsRevealAt, requestsMin, requestsMax, offersMin, offersMax,
+		limitsJSON, pq.Array(matchTagTypes), intOr(req.MatchMinTags, 1), intOr(req.ClaimLimit, 0), allowMultiClaims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save challenge settings"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE collections SET type = $1, updated_at = NOW() WHERE id = $2", collectionType, collectionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection type"})
+		return
+	}
+
+	if req.TagSetIDs != nil {
+		err = setChallengeTagSets(tx, collectionID, *req.TagSetIDs)
+		if err == errTagSetNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tag set not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save challenge tag sets"})
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save challenge settings"})
+		return
+	}
+
+	settings, err := ws.getChallengeSettings(collectionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"challenge": settings})
+}
+
+// SubmitChallengeSignup creates the caller's sign-up, or replaces its
+// prompts if one already exists, while sign-ups are open
+func (ws *WorkService) SubmitChallengeSignup(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req ChallengeSignupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	settings, err := ws.getChallengeSettings(collectionID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection is not a challenge"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenge"})
+		return
+	}
+
+	if !signupsAcceptingAt(settings, time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sign-ups are closed"})
+		return
+	}
+
+	if len(req.Requests) < settings.RequestsMin || len(req.Requests) > settings.RequestsMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Between %d and %d requests are required", settings.RequestsMin, settings.RequestsMax)})
+		return
+	}
+	if len(req.Offers) < settings.OffersMin || len(req.Offers) > settings.OffersMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Between %d and %d offers are required", settings.OffersMin, settings.OffersMax)})
+		return
+	}
+
+	var allTags []string
+	for _, p := range append(append([]ChallengePromptInput{}, req.Requests...), req.Offers...) {
+		allTags = append(allTags, p.Tags...)
+	}
+	tagTypes, err := ws.lookupTagTypes(allTags)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up tags"})
+		return
+	}
+	allowed, err := ws.challengeTagSetTags(collectionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up tag sets"})
+		return
+	}
+	// Tags from the challenge's tag sets count as their set's type even
+	// before anyone has used them on a work
+	for name, tagType := range allowed {
+		if _, known := tagTypes[name]; !known {
+			tagTypes[name] = tagType
+		}
+	}
+	restricted := len(settings.TagSetIDs) > 0
+	checkPrompt := func(p ChallengePromptInput) error {
+		if err := validatePromptTags(p.Tags, tagTypes, settings.PromptTagLimits); err != nil {
+			return err
+		}
+		if !restricted {
+			return nil
+		}
+		if outside := tagsOutsideTagSets(p.Tags, tagTypes, allowed); len(outside) > 0 {
+			return fmt.Errorf("%s not in the challenge's tag sets", strings.Join(outside, ", "))
+		}
+		return nil
+	}
+	for i, p := range req.Requests {
+		if err := checkPrompt(p); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Request %d: %s", i+1, err.Error())})
+			return
+		}
+	}
+	for i, p := range req.Offers {
+		if err := checkPrompt(p); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Offer %d: %s", i+1, err.Error())})
+			return
+		}
+	}
+
+	// Sign up under the requested pseud, or the user's default one
+	var pseudID uuid.UUID
+	if req.PseudID != nil {
+		err = ws.db.QueryRow("SELECT id FROM pseuds WHERE id = $1 AND user_id = $2", *req.PseudID, userID).Scan(&pseudID)
+	} else {
+		err = ws.db.QueryRow("SELECT id FROM pseuds WHERE user_id = $1 AND is_default = true", userID).Scan(&pseudID)
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pseud not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify pseud"})
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var signupID uuid.UUID
+	var created bool
+	err = tx.QueryRow(`
+		INSERT INTO challenge_signups (collection_id, user_id, pseud_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (collection_id, user_id) DO UPDATE SET pseud_id = EXCLUDED.pseud_id, updated_at = NOW()
+		RETURNING id, (xmax = 0)`, collectionID, userID, pseudID).Scan(&signupID, &created)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save sign-up"})
+		return
+	}
+
+	if _, err = tx.Exec("DELETE FROM challenge_prompts WHERE signup_id = $1", signupID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save sign-up"})
+		return
+	}
+
+	insertPrompts := func(kind string, prompts []ChallengePromptInput) error {
+		for i, p := range prompts {
+			_, err := tx.Exec(`
+				INSERT INTO challenge_prompts (signup_id, collection_id, kind, position, title, description, tags, is_anonymous)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				signupID, collectionID, kind, i+1, p.Title, p.Description, pq.Array(p.Tags), p.IsAnonymous)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err = insertPrompts("request", req.Requests); err == nil {
+		err = insertPrompts("offer", req.Offers)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save prompts"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save sign-up"})
+		return
+	}
+
+	signup, err := ws.loadChallengeSignup(signupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sign-up"})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{"signup": signup})
+}
+
+func (ws *WorkService) loadChallengeSignup(signupID uuid.UUID) (*models.ChallengeSignup, error) {
+	var s models.ChallengeSignup
+	err := ws.db.QueryRow(`
+		SELECT cs.id, cs.collection_id, cs.user_id, cs.pseud_id, u.username, cs.created_at, cs.updated_at
+		FROM challenge_signups cs
+		JOIN users u ON cs.user_id = u.id
+		WHERE cs.id = $1`, signupID).Scan(
+		&s.ID, &s.CollectionID, &s.UserID, &s.PseudID, &s.Username, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	prompts, err := ws.loadChallengePrompts("signup_id = $1", signupID)
+	if err != nil {
+		return nil, err
+	}
+	s.Requests = []models.ChallengePrompt{}
+	s.Offers = []models.ChallengePrompt{}
+	for _, p := range prompts {
+		if p.Kind == "request" {
+			s.Requests = append(s.Requests, p)
+		} else {
+			s.Offers = append(s.Offers, p)
+		}
+	}
+	return &s, nil
+}
+
+func (ws *WorkService) loadChallengePrompts(where string, args ...interface{}) ([]models.ChallengePrompt, error) {
+	rows, err := ws.db.Query(`
+		SELECT id, signup_id, kind, position, COALESCE(title, ''), COALESCE(description, ''), tags, is_anonymous
+		FROM challenge_prompts
+		WHERE `+where+`
+		ORDER BY signup_id, kind, position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prompts []models.ChallengePrompt
+	for rows.Next() {
+		var p models.ChallengePrompt
+		if err := rows.Scan(&p.ID, &p.SignupID, &p.Kind, &p.Position, &p.Title, &p.Description,
+			pq.Array(&p.Tags), &p.IsAnonymous); err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, p)
+	}
+	return prompts, rows.Err()
+}
+
+// GetMyChallengeSignup returns the caller's sign-up for a challenge
+func (ws *WorkService) GetMyChallengeSignup(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var signupID uuid.UUID
+	err = ws.db.QueryRow("SELECT id FROM challenge_signups WHERE collection_id = $1 AND user_id = $2",
+		collectionID, userID).Scan(&signupID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sign-up not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sign-up"})
+		return
+	}
+
+	signup, err := ws.loadChallengeSignup(signupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sign-up"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"signup": signup})
+}
+
+// DeleteMyChallengeSignup withdraws the caller's sign-up while sign-ups are open
+func (ws *WorkService) DeleteMyChallengeSignup(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	settings, err := ws.getChallengeSettings(collectionID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection is not a challenge"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenge"})
+		return
+	}
+	if settings.AssignmentsSentAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Assignments have been sent; default on your assignment instead"})
+		return
+	}
+
+	result, err := ws.db.Exec("DELETE FROM challenge_signups WHERE collection_id = $1 AND user_id = $2", collectionID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete sign-up"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sign-up not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sign-up withdrawn"})
+}
+
+// ListChallengeSignups returns every sign-up with its prompts (maintainer only)
+func (ws *WorkService) ListChallengeSignups(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	if !ws.requireCollectionMaintainer(c, collectionID) {
+		return
+	}
+
+	signups, err := ws.loadAllChallengeSignups(collectionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sign-ups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"signups": signups, "total": len(signups)})
+}
+
+func (ws *WorkService) loadAllChallengeSignups(collectionID uuid.UUID) ([]*models.ChallengeSignup, error) {
+	rows, err := ws.db.Query(`
+		SELECT cs.id, cs.collection_id, cs.user_id, cs.pseud_id, u.username, cs.created_at, cs.updated_at
+		FROM challenge_signups cs
+		JOIN users u ON cs.user_id = u.id
+		WHERE cs.collection_id = $1
+		ORDER BY cs.created_at`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	signups := []*models.ChallengeSignup{}
+	byID := make(map[uuid.UUID]*models.ChallengeSignup)
+	for rows.Next() {
+		s := &models.ChallengeSignup{Requests: []models.ChallengePrompt{}, Offers: []models.ChallengePrompt{}}
+		if err := rows.Scan(&s.ID, &s.CollectionID, &s.UserID, &s.PseudID, &s.Username, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		signups = append(signups, s)
+		byID[s.ID] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	prompts, err := ws.loadChallengePrompts("collection_id = $1", collectionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range prompts {
+		s, ok := byID[p.SignupID]
+		if !ok {
+			continue
+		}
+		if p.Kind == "request" {
+			s.Requests = append(s.Requests, p)
+		} else {
+			s.Offers = append(s.Offers, p)
+		}
+	}
+	return signups, nil
+}
+
+// RunChallengeMatching closes sign-ups and generates draft assignments for a
+// gift exchange. Re-running replaces any drafts that have not been sent.
+func (ws *WorkService) RunChallengeMatching(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	if !ws.requireCollectionMaintainer(c, collectionID) {
+		return
+	}
+
+	settings, err := ws.getChallengeSettings(collectionID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection is not a challenge"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenge"})
+		return
+	}
+	if settings.ChallengeType != models.ChallengeTypeGiftExchange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Matching is only available for gift exchanges"})
+		return
+	}
+	if settings.AssignmentsSentAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Assignments have already been sent"})
+		return
+	}
+
+	signups, err := ws.loadAllChallengeSignups(collectionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sign-ups"})
+		return
+	}
+
+	var allTags []string
+	for _, s := range signups {
+		for _, p := range append(append([]models.ChallengePrompt{}, s.Requests...), s.Offers...) {
+			allTags = append(allTags, p.Tags...)
+		}
+	}
+	tagTypes, err := ws.lookupTagTypes(allTags)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up tags"})
+		return
+	}
+
+	participants := make([]matchParticipant, 0, len(signups))
+	for _, s := range signups {
+		p := matchParticipant{SignupID: s.ID, UserID: s.UserID}
+		for _, r := range s.Requests {
+			p.Requests = append(p.Requests, newMatchPrompt(r.ID, r.Tags, tagTypes))
+		}
+		for _, o := range s.Offers {
+			p.Offers = append(p.Offers, newMatchPrompt(o.ID, o.Tags, tagTypes))
+		}
+		participants = append(participants, p)
+	}
+
+	result := matchGiftExchange(participants, matchRules{
+		TagTypes:  settings.MatchTagTypes,
+		MinShared: settings.MatchMinTags,
+	})
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("UPDATE challenge_settings SET signups_open = false, updated_at = NOW() WHERE collection_id = $1", collectionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close sign-ups"})
+		return
+	}
+	if _, err = tx.Exec("DELETE FROM challenge_assignments WHERE collection_id = $1 AND status = 'draft'", collectionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear previous matches"})
+		return
+	}
+
+	for _, a := range result.Assignments {
+		_, err = tx.Exec(`
+			INSERT INTO challenge_assignments (collection_id, giver_user_id, giver_signup_id, recipient_signup_id,
+				request_prompt_id, offer_prompt_id, status, match_score, due_at)
+			VALUES ($1, $2, $3, $4, $5, $6, 'draft', $7, $8)`,
+			collectionID, a.GiverUserID, a.GiverSignupID, a.RecipientSignupID,
+			a.RequestPromptID, a.OfferPromptID, a.Score, settings.AssignmentsDueAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save assignments"})
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save assignments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"matched":              len(result.Assignments),
+		"signups":              len(signups),
+		"unmatched_givers":     nonNilUUIDs(result.UnmatchedGivers),
+		"unmatched_recipients": nonNilUUIDs(result.UnmatchedRecipients),
+	})
+}
+
+func nonNilUUIDs(ids []uuid.UUID) []uuid.UUID {
+	if ids == nil {
+		return []uuid.UUID{}
+	}
+	return ids
+}
+
+// SendChallengeAssignments publishes draft assignments to their givers
+func (ws *WorkService) SendChallengeAssignments(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	if !ws.requireCollectionMaintainer(c, collectionID) {
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE challenge_assignments
+		SET status = 'assigned', sent_at = NOW(), updated_at = NOW()
+		WHERE collection_id = $1 AND status = 'draft'`, collectionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send assignments"})
+		return
+	}
+	sent, _ := result.RowsAffected()
+	if sent == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "No draft assignments to send; run matching first"})
+		return
+	}
+
+	if _, err = tx.Exec("UPDATE challenge_settings SET assignments_sent_at = NOW(), updated_at = NOW() WHERE collection_id = $1", collectionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send assignments"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send assignments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Assignments sent", "sent": sent})
+}
+
+const challengeAssignmentColumns = `a.id, a.collection_id, a.giver_user_id, a.giver_signup_id, a.recipient_signup_id,
+	a.request_prompt_id, a.offer_prompt_id, a.status, a.match_score, a.due_at, a.sent_at, a.fulfilled_at,
+	a.defaulted_at, a.work_id, a.pinch_hit_for, a.created_at, a.updated_at`
+
+func scanChallengeAssignment(scanner interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.ChallengeAssignment, error) {
+	var a models.ChallengeAssignment
+	dest := []interface{}{&a.ID, &a.CollectionID, &a.GiverUserID, &a.GiverSignupID, &a.RecipientSignupID,
+		&a.RequestPromptID, &a.OfferPromptID, &a.Status, &a.MatchScore, &a.DueAt, &a.SentAt, &a.FulfilledAt,
+		&a.DefaultedAt, &a.WorkID, &a.PinchHitFor, &a.CreatedAt, &a.UpdatedAt}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListChallengeAssignments returns all assignments for a challenge (maintainer only)
+func (ws *WorkService) ListChallengeAssignments(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	if !ws.requireCollectionMaintainer(c, collectionID) {
+		return
+	}
+
+	query := "SELECT " + challengeAssignmentColumns + ", gu.username, ru.username" + `
+		FROM challenge_assignments a
+		JOIN users gu ON a.giver_user_id = gu.id
+		JOIN challenge_signups rs ON a.recipient_signup_id = rs.id
+		JOIN users ru ON rs.user_id = ru.id
+		WHERE a.collection_id = $1`
+	args := []interface{}{collectionID}
+	if status := c.Query("status"); status != "" {
+		query += " AND a.status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY a.created_at"
+
+	rows, err := ws.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
+		return
+	}
+	defer rows.Close()
+
+	assignments := []gin.H{}
+	for rows.Next() {
+		var giver, recipient string
+		a, err := scanChallengeAssignment(rows, &giver, &recipient)
+		if err != nil {
+			continue
+		}
+		assignments = append(assignments, gin.H{
+			"assignment": a,
+			"giver":      giver,
+			"recipient":  recipient,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignments": assignments, "total": len(assignments)})
+}
+
+// GetMyAssignments returns the caller's sent assignments with the prompt
+// they are writing for
+func (ws *WorkService) GetMyAssignments(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	rows, err := ws.db.Query("SELECT "+challengeAssignmentColumns+`, col.title, ru.username,
+			COALESCE(p.title, ''), COALESCE(p.description, ''), COALESCE(p.tags, '{}')
+		FROM challenge_assignments a
+		JOIN collections col ON a.collection_id = col.id
+		JOIN challenge_signups rs ON a.recipient_signup_id = rs.id
+		JOIN users ru ON rs.user_id = ru.id
+		LEFT JOIN challenge_prompts p ON a.request_prompt_id = p.id
+		WHERE a.giver_user_id = $1 AND a.status != 'draft'
+		ORDER BY a.due_at NULLS LAST, a.created_at`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
+		return
+	}
+	defer rows.Close()
+
+	assignments := []gin.H{}
+	for rows.Next() {
+		var collectionTitle, recipient, promptTitle, promptDescription string
+		var promptTags []string
+		a, err := scanChallengeAssignment(rows, &collectionTitle, &recipient,
+			&promptTitle, &promptDescription, pq.Array(&promptTags))
+		if err != nil {
+			continue
+		}
+		assignments = append(assignments, gin.H{
+			"assignment":       a,
+			"collection_title": collectionTitle,
+			"recipient":        recipient,
+			"request": gin.H{
+				"title":       promptTitle,
+				"description": promptDescription,
+				"tags":        promptTags,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignments": assignments})
+}
+
+func (ws *WorkService) getChallengeAssignment(assignmentID uuid.UUID) (*models.ChallengeAssignment, error) {
+	return scanChallengeAssignment(ws.db.QueryRow(
+		"SELECT "+challengeAssignmentColumns+" FROM challenge_assignments a WHERE a.id = $1", assignmentID))
+}
+
+// FulfillAssignment links a posted work to an assignment, adds it to the
+// challenge collection and gifts it to the recipient
+func (ws *WorkService) FulfillAssignment(c *gin.Context) {
+	assignmentID, err := uuid.Parse(c.Param("assignment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		WorkID uuid.UUID `json:"work_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	assignment, err := ws.getChallengeAssignment(assignmentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignment"})
+		return
+	}
+	if assignment.GiverUserID.String() != userID.(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This is not your assignment"})
+		return
+	}
+	if assignment.Status != models.AssignmentStatusAssigned {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Assignment is %s", assignment.Status)})
+		return
+	}
+
+	var isAuthor bool
+	err = ws.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM creatorships c
+			JOIN pseuds p ON c.pseud_id = p.id
+			WHERE c.creation_id = $1 AND c.creation_type = 'Work' AND p.user_id = $2
+		)`, req.WorkID, userID).Scan(&isAuthor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify work ownership"})
+		return
+	}
+	if !isAuthor {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only fulfill an assignment with your own work"})
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE challenge_assignments
+		SET status = 'fulfilled', work_id = $1, fulfilled_at = NOW(), updated_at = NOW()
+		WHERE id = $2`, req.WorkID, assignmentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update assignment"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO collection_works (collection_id, work_id, approved)
+		VALUES ($1, $2, true)
+		ON CONFLICT (collection_id, work_id) DO UPDATE SET approved = true`,
+		assignment.CollectionID, req.WorkID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add work to collection"})
+		return
+	}
+
+	if _, err := markWorkForCollection(tx, assignment.CollectionID, req.WorkID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work visibility"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO gifts (work_id, pseud_id)
+		SELECT $1, rs.pseud_id FROM challenge_signups rs
+		WHERE rs.id = $2 AND rs.pseud_id IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM gifts g WHERE g.work_id = $1 AND g.pseud_id = rs.pseud_id)`,
+		req.WorkID, assignment.RecipientSignupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to gift work"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fulfill assignment"})
+		return
+	}
+
+	if ws.redis != nil {
+		ws.redis.Del(c.Request.Context(), fmt.Sprintf("work:%s", req.WorkID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Assignment fulfilled", "assignment_id": assignmentID, "work_id": req.WorkID})
+}
+
+// DefaultAssignment marks an assignment as defaulted so it can be pinch hit.
+// Either the giver or the collection maintainer may default it.
+func (ws *WorkService) DefaultAssignment(c *gin.Context) {
+	assignmentID, err := uuid.Parse(c.Param("assignment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	assignment, err := ws.getChallengeAssignment(assignmentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignment"})
+		return
+	}
+
+	if assignment.GiverUserID.String() != userID.(string) && !ws.requireCollectionMaintainer(c, assignment.CollectionID) {
+		return
+	}
+	if assignment.Status != models.AssignmentStatusAssigned {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Assignment is %s", assignment.Status)})
+		return
+	}
+
+	_, err = ws.db.Exec(`
+		UPDATE challenge_assignments
+		SET status = 'defaulted', defaulted_at = NOW(), updated_at = NOW()
+		WHERE id = $1`, assignmentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to default assignment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Assignment defaulted", "assignment_id": assignmentID})
+}
+
+// PinchHitAssignment reassigns a defaulted assignment's recipient to a new
+// giver (maintainer only). The new assignment is sent immediately, and each
+// defaulted assignment can be pinch hit once.
+func (ws *WorkService) PinchHitAssignment(c *gin.Context) {
+	assignmentID, err := uuid.Parse(c.Param("assignment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return
+	}
+
+	var req struct {
+		PinchHitterID uuid.UUID  `json:"pinch_hitter_id" binding:"required"`
+		DueAt         *time.Time `json:"due_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	assignment, err := ws.getChallengeAssignment(assignmentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignment"})
+		return
+	}
+
+	if !ws.requireCollectionMaintainer(c, assignment.CollectionID) {
+		return
+	}
+
+	var recipientUserID uuid.UUID
+	err = ws.db.QueryRow("SELECT user_id FROM challenge_signups WHERE id = $1", assignment.RecipientSignupID).Scan(&recipientUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recipient"})
+		return
+	}
+	if recipientUserID == req.PinchHitterID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A recipient cannot pinch hit their own request"})
+		return
+	}
+
+	var hitterExists bool
+	ws.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", req.PinchHitterID).Scan(&hitterExists)
+	if !hitterExists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pinch hitter not found"})
+		return
+	}
+
+	// Pinch hitters need not be participants, but keep the link if they are
+	var hitterSignupID *uuid.UUID
+	var signupID uuid.UUID
+	if err := ws.db.QueryRow("SELECT id FROM challenge_signups WHERE collection_id = $1 AND user_id = $2",
+		assignment.CollectionID, req.PinchHitterID).Scan(&signupID); err == nil {
+		hitterSignupID = &signupID
+	}
+
+	dueAt := req.DueAt
+	if dueAt == nil {
+		dueAt = assignment.DueAt
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the defaulted assignment so only one pinch hit can replace it
+	var status string
+	var alreadyPinchHit bool
+	err = tx.QueryRow(`
+		SELECT status, EXISTS(SELECT 1 FROM challenge_assignments WHERE pinch_hit_for = $1)
+		FROM challenge_assignments WHERE id = $1 FOR UPDATE`, assignment.ID).Scan(&status, &alreadyPinchHit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignment"})
+		return
+	}
+	if status != models.AssignmentStatusDefaulted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only defaulted assignments can be pinch hit"})
+		return
+	}
+	if alreadyPinchHit {
+		c.JSON(http.StatusConflict, gin.H{"error": "This assignment already has a pinch hitter"})
+		return
+	}
+
+	var newID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO challenge_assignments (collection_id, giver_user_id, giver_signup_id, recipient_signup_id,
+			request_prompt_id, status, due_at, sent_at, pinch_hit_for)
+		VALUES ($1, $2, $3, $4, $5, 'assigned', $6, NOW(), $7)
+		RETURNING id`,
+		assignment.CollectionID, req.PinchHitterID, hitterSignupID, assignment.RecipientSignupID,
+		assignment.RequestPromptID, dueAt, assignment.ID).Scan(&newID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pinch hit assignment"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	pinchHit, err := ws.getChallengeAssignment(newID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pinch hit assignment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"assignment": pinchHit})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/models"
+)
+
+// Gift exchange matching
+//
+// Every participant both gives and receives. A giver is compatible with a
+// recipient when one of the giver's offers shares at least MinShared tags
+// (restricted to TagTypes) with one of the recipient's requests. Matching is
+// a maximum bipartite matching over givers and recipients using augmenting
+// paths, so a tightly constrained participant is not starved by a greedy
+// early pick. Within each giver's candidates the best-scoring pair is tried
+// first; participants left over are reported for moderators to pinch hit.
+
+type matchPrompt struct {
+	ID   uuid.UUID
+	Tags map[string]map[string]bool // tag type -> lowercased tag names
+}
+
+type matchParticipant struct {
+	SignupID uuid.UUID
+	UserID   uuid.UUID
+	Requests []matchPrompt
+	Offers   []matchPrompt
+}
+
+type matchRules struct {
+	TagTypes  []string
+	MinShared int
+}
+
+type matchAssignment struct {
+	GiverSignupID     uuid.UUID
+	GiverUserID       uuid.UUID
+	RecipientSignupID uuid.UUID
+	RequestPromptID   uuid.UUID
+	OfferPromptID     uuid.UUID
+	Score             int
+}
+
+type matchResult struct {
+	Assignments         []matchAssignment
+	UnmatchedGivers     []uuid.UUID // sign-ups with nobody to write for
+	UnmatchedRecipients []uuid.UUID // sign-ups nobody is writing for
+}
+
+type matchCandidate struct {
+	recipient int
+	score     int
+	requestID uuid.UUID
+	offerID   uuid.UUID
+}
+
+// newMatchPrompt groups a prompt's tags by type. Tags missing from tagTypes
+// are treated as freeforms.
+func newMatchPrompt(id uuid.UUID, tags []string, tagTypes map[string]string) matchPrompt {
+	p := matchPrompt{ID: id, Tags: make(map[string]map[string]bool)}
+	for _, tag := range tags {
+		name := strings.ToLower(strings.TrimSpace(tag))
+		if name == "" {
+			continue
+		}
+		tagType, ok := tagTypes[name]
+		if !ok {
+			tagType = "freeform"
+		}
+		if p.Tags[tagType] == nil {
+			p.Tags[tagType] = make(map[string]bool)
+		}
+		p.Tags[tagType][name] = true
+	}
+	return p
+}
+
+func sharedTagCount(offer, request matchPrompt, tagTypes []string) int {
+	shared := 0
+	for _, t := range tagTypes {
+		for name := range request.Tags[t] {
+			if offer.Tags[t][name] {
+				shared++
+			}
+		}
+	}
+	return shared
+}
+
+// bestPromptPair returns the highest scoring offer/request pair between a
+// giver and a recipient, or ok=false if no pair meets the rules
+func bestPromptPair(giver, recipient matchParticipant, rules matchRules) (matchCandidate, bool) {
+	best := matchCandidate{score: -1}
+	for _, offer := range giver.Offers {
+		for _, request := range recipient.Requests {
+			score := sharedTagCount(offer, request, rules.TagTypes)
+			if score < rules.MinShared || score <= best.score {
+				continue
+			}
+			best = matchCandidate{score: score, requestID: request.ID, offerID: offer.ID}
+		}
+	}
+	return best, best.score >= 0
+}
+
+func matchGiftExchange(participants []matchParticipant, rules matchRules) matchResult {
+	n := len(participants)
+
+	candidates := make([][]matchCandidate, n)
+	for g := range participants {
+		for r := range participants {
+			if g == r || participants[g].UserID == participants[r].UserID {
+				continue
+			}
+			cand, ok := bestPromptPair(participants[g], participants[r], rules)
+			if !ok {
+				continue
+			}
+			cand.recipient = r
+			candidates[g] = append(candidates[g], cand)
+		}
+		sort.SliceStable(candidates[g], func(i, j int) bool {
+			return candidates[g][i].score > candidates[g][j].score
+		})
+	}
+
+	// Hardest to place first keeps the augmenting searches short
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(candidates[order[i]]) < len(candidates[order[j]])
+	})
+
+	giverOf := make([]int, n) // recipient -> giver
+	pairOf := make([]matchCandidate, n)
+	for i := range giverOf {
+		giverOf[i] = -1
+	}
+
+	var augment func(g int, visited []bool) bool
+	augment = func(g int, visited []bool) bool {
+		for _, cand := range candidates[g] {
+			if visited[cand.recipient] {
+				continue
+			}
+			visited[cand.recipient] = true
+			if giverOf[cand.recipient] == -1 || augment(giverOf[cand.recipient], visited) {
+				giverOf[cand.recipient] = g
+				pairOf[cand.recipient] = cand
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, g := range order {
+		augment(g, make([]bool, n))
+	}
+
+	result := matchResult{}
+	gives := make([]bool, n)
+	for r, g := range giverOf {
+		if g == -1 {
+			result.UnmatchedRecipients = append(result.UnmatchedRecipients, participants[r].SignupID)
+			continue
+		}
+		gives[g] = true
+		result.Assignments = append(result.Assignments, matchAssignment{
+			GiverSignupID:     participants[g].SignupID,
+			GiverUserID:       participants[g].UserID,
+			RecipientSignupID: participants[r].SignupID,
+			RequestPromptID:   pairOf[r].requestID,
+			OfferPromptID:     pairOf[r].offerID,
+			Score:             pairOf[r].score,
+		})
+	}
+	for g, ok := range gives {
+		if !ok {
+			result.UnmatchedGivers = append(result.UnmatchedGivers, participants[g].SignupID)
+		}
+	}
+
+	return result
+}
+
+// validatePromptTags enforces a challenge's per tag type limits on a prompt
+func validatePromptTags(tags []string, tagTypes map[string]string, limits map[string]models.TagLimit) error {
+	counts := make(map[string]int)
+	for tagType, names := range newMatchPrompt(uuid.Nil, tags, tagTypes).Tags {
+		counts[tagType] = len(names)
+	}
+
+	for tagType, limit := range limits {
+		if counts[tagType] < limit.Min {
+			return fmt.Errorf("at least %d %s tag(s) required", limit.Min, tagType)
+		}
+		if limit.Max > 0 && counts[tagType] > limit.Max {
+			return fmt.Errorf("at most %d %s tag(s) allowed", limit.Max, tagType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"nuclear-ao3/shared/models"
+)
+
+var testTagTypes = map[string]string{
+	"star wars":          "fandom",
+	"good omens":         "fandom",
+	"the witcher":        "fandom",
+	"aziraphale":         "character",
+	"crowley":            "character",
+	"geralt of rivia":    "character",
+	"luke skywalker":     "character",
+	"alternate universe": "freeform",
+}
+
+func newTestParticipant(requests, offers [][]string) matchParticipant {
+	p := matchParticipant{SignupID: uuid.New(), UserID: uuid.New()}
+	for _, tags := range requests {
+		p.Requests = append(p.Requests, newMatchPrompt(uuid.New(), tags, testTagTypes))
+	}
+	for _, tags := range offers {
+		p.Offers = append(p.Offers, newMatchPrompt(uuid.New(), tags, testTagTypes))
+	}
+	return p
+}
+
+func assignmentsByGiver(result matchResult) map[uuid.UUID]matchAssignment {
+	byGiver := make(map[uuid.UUID]matchAssignment)
+	for _, a := range result.Assignments {
+		byGiver[a.GiverSignupID] = a
+	}
+	return byGiver
+}
+
+func TestMatchGiftExchange_FullCycle(t *testing.T) {
+	rules := matchRules{TagTypes: []string{"fandom"}, MinShared: 1}
+	a := newTestParticipant([][]string{{"Star Wars"}}, [][]string{{"Good Omens"}})
+	b := newTestParticipant([][]string{{"Good Omens"}}, [][]string{{"The Witcher"}})
+	c := newTestParticipant([][]string{{"The Witcher"}}, [][]string{{"Star Wars"}})
+
+	result := matchGiftExchange([]matchParticipant{a, b, c}, rules)
+
+	assert.Len(t, result.Assignments, 3)
+	assert.Empty(t, result.UnmatchedGivers)
+	assert.Empty(t, result.UnmatchedRecipients)
+
+	byGiver := assignmentsByGiver(result)
+	assert.Equal(t, b.SignupID, byGiver[a.SignupID].RecipientSignupID)
+	assert.Equal(t, c.SignupID, byGiver[b.SignupID].RecipientSignupID)
+	assert.Equal(t, a.SignupID, byGiver[c.SignupID].RecipientSignupID)
+	assert.Equal(t, b.Requests[0].ID, byGiver[a.SignupID].RequestPromptID)
+	assert.Equal(t, a.Offers[0].ID, byGiver[a.SignupID].OfferPromptID)
+}
+
+func TestMatchGiftExchange_NeverSelfAssigns(t *testing.T) {
+	rules := matchRules{TagTypes: []string{"fandom"}, MinShared: 1}
+	solo := newTestParticipant([][]string{{"Star Wars"}}, [][]string{{"Star Wars"}})
+
+	result := matchGiftExchange([]matchParticipant{solo}, rules)
+
+	assert.Empty(t, result.Assignments)
+	assert.Equal(t, []uuid.UUID{solo.SignupID}, result.UnmatchedGivers)
+	assert.Equal(t, []uuid.UUID{solo.SignupID}, result.UnmatchedRecipients)
+}
+
+func TestMatchGiftExchange_AugmentsPastGreedyChoice(t *testing.T) {
+	rules := matchRules{TagTypes: []string{"fandom"}, MinShared: 1}
+	// a can write for b or c, d can only write for b. A greedy pass that
+	// gives b to a would leave d without an assignment.
+	a := newTestParticipant([][]string{{"The Witcher"}}, [][]string{{"Star Wars", "Good Omens"}})
+	b := newTestParticipant([][]string{{"Star Wars"}}, [][]string{{"The Witcher"}})
+	c := newTestParticipant([][]string{{"Good Omens"}}, [][]string{{"The Witcher"}})
+	d := newTestParticipant([][]string{{"The Witcher"}}, [][]string{{"Star Wars"}})
+
+	result := matchGiftExchange([]matchParticipant{a, b, c, d}, rules)
+
+	byGiver := assignmentsByGiver(result)
+	assert.Equal(t, c.SignupID, byGiver[a.SignupID].RecipientSignupID)
+	assert.Equal(t, b.SignupID, byGiver[d.SignupID].RecipientSignupID)
+}
+
+func TestMatchGiftExchange_RespectsMinimumSharedTags(t *testing.T) {
+	rules := matchRules{TagTypes: []string{"fandom", "character"}, MinShared: 2}
+	a := newTestParticipant([][]string{{"Good Omens", "Crowley"}}, [][]string{{"Good Omens", "Aziraphale"}})
+	b := newTestParticipant([][]string{{"Good Omens", "Aziraphale"}}, [][]string{{"Good Omens"}})
+
+	result := matchGiftExchange([]matchParticipant{a, b}, rules)
+
+	byGiver := assignmentsByGiver(result)
+	assert.Equal(t, b.SignupID, byGiver[a.SignupID].RecipientSignupID)
+	assert.Equal(t, 2, byGiver[a.SignupID].Score)
+	assert.NotContains(t, byGiver, b.SignupID, "one shared tag is below the minimum")
+	assert.Equal(t, []uuid.UUID{b.SignupID}, result.UnmatchedGivers)
+	assert.Equal(t, []uuid.UUID{a.SignupID}, result.UnmatchedRecipients)
+}
+
+func TestMatchGiftExchange_IgnoresTagsOutsideMatchTypes(t *testing.T) {
+	rules := matchRules{TagTypes: []string{"fandom"}, MinShared: 1}
+	a := newTestParticipant([][]string{{"Alternate Universe"}}, [][]string{{"Alternate Universe"}})
+	b := newTestParticipant([][]string{{"Alternate Universe"}}, [][]string{{"Alternate Universe"}})
+
+	result := matchGiftExchange([]matchParticipant{a, b}, rules)
+
+	assert.Empty(t, result.Assignments)
+}
+
+func TestValidatePromptTags(t *testing.T) {
+	limits := map[string]models.TagLimit{
+		"fandom":    {Min: 1, Max: 1},
+		"character": {Min: 0, Max: 2},
+	}
+
+	assert.NoError(t, validatePromptTags([]string{"Good Omens", "Crowley"}, testTagTypes, limits))
+	assert.Error(t, validatePromptTags([]string{"Crowley"}, testTagTypes, limits), "missing fandom")
+	assert.Error(t, validatePromptTags([]string{"Good Omens", "Star Wars"}, testTagTypes, limits), "too many fandoms")
+	assert.Error(t, validatePromptTags(
+		[]string{"Good Omens", "Crowley", "Aziraphale", "Luke Skywalker"}, testTagTypes, limits), "too many characters")
+	assert.NoError(t, validatePromptTags([]string{"Good Omens", "Some Unknown Tag"}, testTagTypes, limits),
+		"unknown tags count as freeforms")
+}
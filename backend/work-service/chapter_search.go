@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// indexChapterInSearch sends a chapter's text to the search service's content
+// index, or drops it from the index when it isn't readable: a draft chapter
+// or a chapter of a draft work.
+func (ws *WorkService) indexChapterInSearch(workID, chapterID uuid.UUID) {
+	var content, language string
+	var chapterDraft, workDraft bool
+	err := ws.db.QueryRow(`
+		SELECT COALESCE(c.content, ''), COALESCE(c.is_draft, false),
+			COALESCE(w.language, ''), COALESCE(w.status, 'draft') = 'draft'
+		FROM chapters c JOIN works w ON w.id = c.work_id
+		WHERE c.id = $1 AND c.work_id = $2`, chapterID, workID).Scan(&content, &chapterDraft, &language, &workDraft)
+	if err != nil {
+		log.Printf("ERROR: Failed to load chapter %s for indexing: %v", chapterID, err)
+		return
+	}
+	if chapterDraft || workDraft {
+		ws.removeChapterFromSearch(chapterID)
+		return
+	}
+
+	searchClient := NewSearchServiceClient(getEnv("SEARCH_SERVICE_URL", "http://localhost:8084"))
+	body, _ := json.Marshal(map[string]interface{}{
+		"work_id":  workID.String(),
+		"language": language,
+		"content":  content,
+	})
+	req, _ := searchClient.indexRequest("PUT", fmt.Sprintf("/api/v1/index/chapters/%s", chapterID), bytes.NewBuffer(body))
+	resp, err := searchClient.client.Do(req)
+	if err != nil {
+		log.Printf("ERROR: Failed to index chapter %s: %v", chapterID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("ERROR: Indexing chapter %s returned status %d", chapterID, resp.StatusCode)
+	}
+}
+
+// indexWorkChaptersInSearch re-indexes every chapter of a work, e.g. when
+// the work itself is posted or returned to draft
+func (ws *WorkService) indexWorkChaptersInSearch(workID uuid.UUID) {
+	rows, err := ws.db.Query("SELECT id FROM chapters WHERE work_id = $1", workID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load chapters of work %s for indexing: %v", workID, err)
+		return
+	}
+	var chapterIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			chapterIDs = append(chapterIDs, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range chapterIDs {
+		ws.indexChapterInSearch(workID, id)
+	}
+}
+
+// removeChapterFromSearch drops a chapter's text from the content index
+func (ws *WorkService) removeChapterFromSearch(chapterID uuid.UUID) {
+	searchClient := NewSearchServiceClient(getEnv("SEARCH_SERVICE_URL", "http://localhost:8084"))
+	req, _ := searchClient.indexRequest("DELETE", fmt.Sprintf("/api/v1/index/chapters/%s", chapterID), nil)
+	resp, err := searchClient.client.Do(req)
+	if err != nil {
+		log.Printf("ERROR: Failed to remove chapter %s from search: %v", chapterID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		log.Printf("ERROR: Removing chapter %s from search returned status %d", chapterID, resp.StatusCode)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/models"
+)
+
+// validCollectionRole reports whether role can be given to a maintainer
+func validCollectionRole(role string) bool {
+	return role == models.CollectionRoleOwner || role == models.CollectionRoleModerator
+}
+
+// collectionRole returns the user's role in a collection, or "" if they
+// don't maintain it. The collection's creator is always an owner. It
+// returns sql.ErrNoRows if the collection doesn't exist.
+func collectionRole(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, collectionID, userID uuid.UUID) (string, error) {
+	var role string
+	err := q.QueryRow(`
+		SELECT CASE WHEN c.user_id = $2 THEN 'owner' ELSE COALESCE(cm.role, '') END
+		FROM collections c
+		LEFT JOIN collection_maintainers cm ON cm.collection_id = c.id AND cm.user_id = $2
+		WHERE c.id = $1`, collectionID, userID).Scan(&role)
+	return role, err
+}
+
+// requireCollectionRole writes an error response and returns false unless
+// the current user maintains the collection, as an owner if ownerOnly
+func (ws *WorkService) requireCollectionRole(c *gin.Context, collectionID uuid.UUID, ownerOnly bool) bool {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return false
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return false
+	}
+
+	role, err := collectionRole(ws.db, collectionID, userUUID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify collection ownership"})
+		return false
+	}
+
+	if ownerOnly && role != models.CollectionRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only a collection owner can do this"})
+		return false
+	}
+	if role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the collection maintainers can manage this collection"})
+		return false
+	}
+	return true
+}
+
+// requireCollectionOwner allows only the collection's owners through
+func (ws *WorkService) requireCollectionOwner(c *gin.Context, collectionID uuid.UUID) bool {
+	return ws.requireCollectionRole(c, collectionID, true)
+}
+
+// requireCollectionMaintainer allows owners and moderators through
+func (ws *WorkService) requireCollectionMaintainer(c *gin.Context, collectionID uuid.UUID) bool {
+	return ws.requireCollectionRole(c, collectionID, false)
+}
+
+// GetCollectionMaintainers lists who runs a collection, owners first
+func (ws *WorkService) GetCollectionMaintainers(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var creatorID uuid.UUID
+	var createdAt time.Time
+	err = ws.db.QueryRow("SELECT user_id, created_at FROM collections WHERE id = $1", collectionID).Scan(&creatorID, &createdAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection"})
+		return
+	}
+
+	// The creator is listed even if the backfill never gave them a row
+	rows, err := ws.db.Query(`
+		SELECT m.user_id, u.username, m.role, m.added_by, m.created_at
+		FROM (
+			SELECT user_id, role, added_by, created_at FROM collection_maintainers
+			WHERE collection_id = $1 AND user_id != $2
+			UNION ALL
+			SELECT $2, 'owner', NULL, $3
+		) m
+		JOIN users u ON u.id = m.user_id
+		ORDER BY m.role = 'owner' DESC, m.created_at ASC`, collectionID, creatorID, createdAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch maintainers"})
+		return
+	}
+	defer rows.Close()
+
+	maintainers := []models.CollectionMaintainer{}
+	for rows.Next() {
+		m := models.CollectionMaintainer{CollectionID: collectionID}
+		var addedBy uuid.NullUUID
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &addedBy, &m.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read maintainers"})
+			return
+		}
+		if addedBy.Valid {
+			m.AddedBy = &addedBy.UUID
+		}
+		maintainers = append(maintainers, m)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"maintainers": maintainers})
+}
+
+// AddCollectionMaintainer adds a maintainer, or changes an existing
+// maintainer's role (owners only)
+func (ws *WorkService) AddCollectionMaintainer(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var req struct {
+		UserID   *uuid.UUID `json:"user_id"`
+		Username string     `json:"username"`
+		Role     string     `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.CollectionRoleModerator
+	}
+	if !validCollectionRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be owner or moderator"})
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.UserID == nil && req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id or username is required"})
+		return
+	}
+
+	if !ws.requireCollectionOwner(c, collectionID) {
+		return
+	}
+	adderID, _ := uuid.Parse(c.GetString("user_id"))
+
+	maintainer := models.CollectionMaintainer{CollectionID: collectionID, Role: req.Role, AddedBy: &adderID}
+	if req.UserID != nil {
+		err = ws.db.QueryRow("SELECT id, username FROM users WHERE id = $1", *req.UserID).Scan(&maintainer.UserID, &maintainer.Username)
+	} else {
+		err = ws.db.QueryRow("SELECT id, username FROM users WHERE LOWER(username) = LOWER($1)", req.Username).Scan(&maintainer.UserID, &maintainer.Username)
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	var creatorID uuid.UUID
+	if err := ws.db.QueryRow("SELECT user_id FROM collections WHERE id = $1", collectionID).Scan(&creatorID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection"})
+		return
+	}
+	if maintainer.UserID == creatorID && req.Role != models.CollectionRoleOwner {
+		c.JSON(http.StatusConflict, gin.H{"error": "The collection's creator is always an owner"})
+		return
+	}
+
+	var inserted bool
+	err = ws.db.QueryRow(`
+		INSERT INTO collection_maintainers (collection_id, user_id, role, added_by, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (collection_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING created_at, (xmax = 0)`,
+		collectionID, maintainer.UserID, req.Role, adderID).Scan(&maintainer.CreatedAt, &inserted)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add maintainer"})
+		return
+	}
+
+	status := http.StatusOK
+	if inserted {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{"maintainer": maintainer})
+}
+
+// RemoveCollectionMaintainer removes a maintainer. Owners can remove anyone
+// but the creator; moderators can only step down themselves.
+func (ws *WorkService) RemoveCollectionMaintainer(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if targetID != userUUID && !ws.requireCollectionOwner(c, collectionID) {
+		return
+	}
+
+	var creatorID uuid.UUID
+	err = ws.db.QueryRow("SELECT user_id FROM collections WHERE id = $1", collectionID).Scan(&creatorID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection"})
+		return
+	}
+	if targetID == creatorID {
+		c.JSON(http.StatusConflict, gin.H{"error": "The collection's creator cannot be removed"})
+		return
+	}
+
+	result, err := ws.db.Exec(`
+		DELETE FROM collection_maintainers WHERE collection_id = $1 AND user_id = $2`, collectionID, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove maintainer"})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a maintainer of this collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Maintainer removed"})
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestValidCollectionRole(t *testing.T) {
+	assert.True(t, validCollectionRole(models.CollectionRoleOwner))
+	assert.True(t, validCollectionRole(models.CollectionRoleModerator))
+	assert.False(t, validCollectionRole(""))
+	assert.False(t, validCollectionRole("admin"))
+	assert.False(t, validCollectionRole("Owner"))
+}
@@ -0,0 +1,587 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+)
+
+// maxRejectionMessageLength caps the note sent back with a rejected item
+const maxRejectionMessageLength = 1000
+
+// PendingCollectionItem is a submission waiting for a maintainer
+type PendingCollectionItem struct {
+	models.CollectionItem
+	WorkTitle   string   `json:"work_title"`
+	WorkAuthor  string   `json:"work_author"`
+	WorkRating  string   `json:"work_rating"`
+	Fandoms     []string `json:"fandoms"`
+	AddedByName *string  `json:"added_by_name,omitempty"`
+}
+
+// CollectionInvitationRequest invites a work into a collection
+type CollectionInvitationRequest struct {
+	WorkID  uuid.UUID `json:"work_id" binding:"required"`
+	Message string    `json:"message"`
+}
+
+// refreshCollectionWorkCount recounts a collection's approved items
+func refreshCollectionWorkCount(q interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, collectionID uuid.UUID) error {
+	_, err := q.Exec(`
+		UPDATE collections SET
+			work_count = (SELECT COUNT(*) FROM collection_items WHERE collection_id = $1 AND is_approved = true),
+			updated_at = NOW()
+		WHERE id = $1`, collectionID)
+	return err
+}
+
+// GetPendingCollectionItems lists submissions awaiting approval, oldest first
+func (ws *WorkService) GetPendingCollectionItems(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+	if !ws.requireCollectionMaintainer(c, collectionID) {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var total int
+	err = ws.db.QueryRow(`
+		SELECT COUNT(*) FROM collection_items
+		WHERE collection_id = $1 AND NOT is_approved AND rejected_at IS NULL`, collectionID).Scan(&total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count pending items"})
+		return
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT ci.id, ci.collection_id, ci.work_id, ci.added_by, ci.is_approved, ci.added_at,
+			w.title, COALESCE(u.username, ''), w.rating, COALESCE(w.fandoms, '{}'), adder.username
+		FROM collection_items ci
+		JOIN works w ON w.id = ci.work_id
+		LEFT JOIN users u ON u.id = w.user_id
+		LEFT JOIN users adder ON adder.id = ci.added_by
+		WHERE ci.collection_id = $1 AND NOT ci.is_approved AND ci.rejected_at IS NULL
+		ORDER BY ci.added_at ASC
+		LIMIT $2 OFFSET $3`, collectionID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pending items"})
+		return
+	}
+	defer rows.Close()
+
+	items := []PendingCollectionItem{}
+	for rows.Next() {
+		var item PendingCollectionItem
+		var addedBy uuid.NullUUID
+		var fandoms pq.StringArray
+		if err := rows.Scan(&item.ID, &item.CollectionID, &item.WorkID, &addedBy, &item.IsApproved, &item.AddedAt,
+			&item.WorkTitle, &item.WorkAuthor, &item.WorkRating, &fandoms, &item.AddedByName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read pending items"})
+			return
+		}
+		if addedBy.Valid {
+			item.AddedBy = addedBy.UUID
+		}
+		item.Fandoms = []string(fandoms)
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+// ApproveCollectionItem accepts a pending submission into the collection
+func (ws *WorkService) ApproveCollectionItem(c *gin.Context) {
+	ws.reviewCollectionItem(c, true)
+}
+
+// RejectCollectionItem turns down a pending submission, optionally with a
+// message for whoever submitted it
+func (ws *WorkService) RejectCollectionItem(c *gin.Context) {
+	ws.reviewCollectionItem(c, false)
+}
+
+func (ws *WorkService) reviewCollectionItem(c *gin.Context, approve bool) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+	itemID, err := uuid.Parse(c.Param("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var req struct {
+		Message string `json:"message"`
+	}
+	if !approve && c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if len(req.Message) > maxRejectionMessageLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Rejection message must be %d characters or fewer", maxRejectionMessageLength)})
+		return
+	}
+
+	if !ws.requireCollectionMaintainer(c, collectionID) {
+		return
+	}
+	reviewerID, _ := uuid.Parse(c.GetString("user_id"))
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var item models.CollectionItem
+	var addedBy uuid.NullUUID
+	var collectionTitle, workTitle string
+	err = tx.QueryRow(`
+		SELECT ci.id, ci.collection_id, ci.work_id, ci.added_by, ci.is_approved, ci.added_at, ci.rejected_at,
+			c.title, w.title
+		FROM collection_items ci
+		JOIN collections c ON c.id = ci.collection_id
+		JOIN works w ON w.id = ci.work_id
+		WHERE ci.id = $1 AND ci.collection_id = $2
+		FOR UPDATE OF ci`, itemID, collectionID).Scan(
+		&item.ID, &item.CollectionID, &item.WorkID, &addedBy, &item.IsApproved, &item.AddedAt, &item.RejectedAt,
+		&collectionTitle, &workTitle)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found in collection"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection item"})
+		return
+	}
+	if item.IsApproved || item.RejectedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Item has already been reviewed"})
+		return
+	}
+
+	now := time.Now()
+	if approve {
+		item.IsApproved = true
+		item.ApprovedAt = &now
+		_, err = tx.Exec(`
+			UPDATE collection_items SET is_approved = true, approved_at = $1, reviewed_by = $2
+			WHERE id = $3`, now, reviewerID, itemID)
+		if err == nil {
+			err = refreshCollectionWorkCount(tx, collectionID)
+		}
+	} else {
+		item.RejectedAt = &now
+		if req.Message != "" {
+			item.RejectionMessage = &req.Message
+		}
+		_, err = tx.Exec(`
+			UPDATE collection_items SET rejected_at = $1, rejection_message = $2, reviewed_by = $3
+			WHERE id = $4`, now, item.RejectionMessage, reviewerID, itemID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review collection item"})
+		return
+	}
+
+	if addedBy.Valid {
+		item.AddedBy = addedBy.UUID
+		status := "approved"
+		description := fmt.Sprintf("%s was approved for %s", workTitle, collectionTitle)
+		if !approve {
+			status = "rejected"
+			description = fmt.Sprintf("%s was not accepted into %s", workTitle, collectionTitle)
+		}
+		err = sendNotificationEvent(c.Request.Context(), tx, notifications.EventData{
+			Type:         models.EventCollectionItemReviewed,
+			SourceID:     collectionID,
+			SourceType:   "collection",
+			Title:        "Collection submission reviewed",
+			Description:  description,
+			ActionURL:    fmt.Sprintf("/collections/%s", collectionID),
+			RecipientIDs: []uuid.UUID{addedBy.UUID},
+			ExtraData: map[string]interface{}{
+				"work_id":           item.WorkID,
+				"work_title":        workTitle,
+				"status":            status,
+				"rejection_message": req.Message,
+			},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review collection item"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// InviteWorkToCollection asks a work's creators to add it to the collection
+func (ws *WorkService) InviteWorkToCollection(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var req CollectionInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+
+	if !ws.requireCollectionMaintainer(c, collectionID) {
+		return
+	}
+	inviterID, _ := uuid.Parse(c.GetString("user_id"))
+
+	var workTitle, collectionTitle string
+	err = ws.db.QueryRow(`
+		SELECT w.title, c.title FROM works w, collections c
+		WHERE w.id = $1 AND c.id = $2`, req.WorkID, collectionID).Scan(&workTitle, &collectionTitle)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work"})
+		return
+	}
+
+	var inCollection bool
+	err = ws.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM collection_items WHERE collection_id = $1 AND work_id = $2 AND rejected_at IS NULL)`,
+		collectionID, req.WorkID).Scan(&inCollection)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check collection membership"})
+		return
+	}
+	if inCollection {
+		c.JSON(http.StatusConflict, gin.H{"error": "Work is already in this collection"})
+		return
+	}
+
+	var message *string
+	if req.Message != "" {
+		message = &req.Message
+	}
+
+	// Re-inviting a work that declined earlier starts a fresh invitation
+	invitation := models.CollectionInvitation{
+		CollectionID:    collectionID,
+		WorkID:          req.WorkID,
+		InvitedBy:       &inviterID,
+		Message:         message,
+		Status:          models.InvitationStatusPending,
+		CollectionTitle: collectionTitle,
+		WorkTitle:       workTitle,
+	}
+	// The invitation and its notification are recorded together
+	ctx := c.Request.Context()
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO collection_invitations (collection_id, work_id, invited_by, message)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (collection_id, work_id) DO UPDATE SET
+			invited_by = EXCLUDED.invited_by, message = EXCLUDED.message, status = 'pending',
+			responded_by = NULL, responded_at = NULL, created_at = NOW()
+		WHERE collection_invitations.status != 'pending'
+		RETURNING id, created_at`,
+		collectionID, req.WorkID, inviterID, message).Scan(&invitation.ID, &invitation.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "Work has already been invited"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	creators, err := workCreatorIDs(ctx, tx, []string{req.WorkID.String()})
+	if err == nil && len(creators[req.WorkID]) > 0 {
+		err = sendNotificationEvent(ctx, tx, notifications.EventData{
+			Type:         models.EventCollectionInvite,
+			SourceID:     collectionID,
+			SourceType:   "collection",
+			Title:        "Collection invitation",
+			Description:  fmt.Sprintf("%s has been invited to %s", workTitle, collectionTitle),
+			ActionURL:    "/my/collection-invitations",
+			ActorID:      &inviterID,
+			RecipientIDs: creators[req.WorkID],
+			ExtraData: map[string]interface{}{
+				"invitation_id": invitation.ID,
+				"work_id":       req.WorkID,
+				"message":       req.Message,
+			},
+		})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"invitation": invitation})
+}
+
+// GetCollectionInvitations lists a collection's invitations for its maintainer
+func (ws *WorkService) GetCollectionInvitations(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+	if !ws.requireCollectionMaintainer(c, collectionID) {
+		return
+	}
+
+	query := `
+		SELECT ci.id, ci.collection_id, ci.work_id, ci.invited_by, ci.message, ci.status, ci.responded_at,
+			ci.created_at, c.title, w.title
+		FROM collection_invitations ci
+		JOIN collections c ON c.id = ci.collection_id
+		JOIN works w ON w.id = ci.work_id
+		WHERE ci.collection_id = $1`
+	args := []interface{}{collectionID}
+	if status := c.Query("status"); status != "" {
+		query += " AND ci.status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY ci.created_at DESC"
+
+	invitations, err := ws.queryCollectionInvitations(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// GetMyCollectionInvitations lists pending invitations for the caller's works
+func (ws *WorkService) GetMyCollectionInvitations(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	invitations, err := ws.queryCollectionInvitations(`
+		SELECT ci.id, ci.collection_id, ci.work_id, ci.invited_by, ci.message, ci.status, ci.responded_at,
+			ci.created_at, c.title, w.title
+		FROM collection_invitations ci
+		JOIN collections c ON c.id = ci.collection_id
+		JOIN works w ON w.id = ci.work_id
+		WHERE ci.status = 'pending' AND EXISTS (
+			SELECT 1 FROM creatorships cr
+			JOIN pseuds p ON cr.pseud_id = p.id
+			WHERE cr.creation_id = ci.work_id AND cr.creation_type = 'Work'
+			AND cr.approved = true AND p.user_id = $1
+		)
+		ORDER BY ci.created_at DESC`, userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+func (ws *WorkService) queryCollectionInvitations(query string, args ...interface{}) ([]models.CollectionInvitation, error) {
+	rows, err := ws.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []models.CollectionInvitation{}
+	for rows.Next() {
+		var inv models.CollectionInvitation
+		var invitedBy uuid.NullUUID
+		if err := rows.Scan(&inv.ID, &inv.CollectionID, &inv.WorkID, &invitedBy, &inv.Message, &inv.Status,
+			&inv.RespondedAt, &inv.CreatedAt, &inv.CollectionTitle, &inv.WorkTitle); err != nil {
+			return nil, err
+		}
+		if invitedBy.Valid {
+			inv.InvitedBy = &invitedBy.UUID
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// AcceptCollectionInvitation adds the invited work to the collection. The
+// maintainer asked for it, so the item skips moderation.
+func (ws *WorkService) AcceptCollectionInvitation(c *gin.Context) {
+	ws.respondToCollectionInvitation(c, true)
+}
+
+// DeclineCollectionInvitation turns an invitation down
+func (ws *WorkService) DeclineCollectionInvitation(c *gin.Context) {
+	ws.respondToCollectionInvitation(c, false)
+}
+
+func (ws *WorkService) respondToCollectionInvitation(c *gin.Context, accept bool) {
+	invitationID, err := uuid.Parse(c.Param("invitation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var inv models.CollectionInvitation
+	var invitedBy uuid.NullUUID
+	err = tx.QueryRow(`
+		SELECT id, collection_id, work_id, invited_by, status, created_at
+		FROM collection_invitations WHERE id = $1
+		FOR UPDATE`, invitationID).Scan(
+		&inv.ID, &inv.CollectionID, &inv.WorkID, &invitedBy, &inv.Status, &inv.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitation"})
+		return
+	}
+
+	isCreator, err := isWorkCreator(tx, inv.WorkID, userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify work ownership"})
+		return
+	}
+	if !isCreator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the work's creators can respond to this invitation"})
+		return
+	}
+	if inv.Status != models.InvitationStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Invitation has already been answered"})
+		return
+	}
+
+	now := time.Now()
+	inv.Status = models.InvitationStatusDeclined
+	if accept {
+		inv.Status = models.InvitationStatusAccepted
+	}
+	inv.RespondedAt = &now
+	if invitedBy.Valid {
+		inv.InvitedBy = &invitedBy.UUID
+	}
+
+	_, err = tx.Exec(`
+		UPDATE collection_invitations SET status = $1, responded_by = $2, responded_at = $3
+		WHERE id = $4`, inv.Status, userUUID, now, invitationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invitation"})
+		return
+	}
+
+	if accept {
+		_, err = tx.Exec(`
+			INSERT INTO collection_items (id, collection_id, work_id, added_by, is_approved, added_at, approved_at)
+			VALUES ($1, $2, $3, $4, true, $5, $5)
+			ON CONFLICT (collection_id, work_id) DO UPDATE SET
+				is_approved = true, approved_at = EXCLUDED.approved_at,
+				rejected_at = NULL, rejection_message = NULL`,
+			uuid.New(), inv.CollectionID, inv.WorkID, userUUID, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add work to collection"})
+			return
+		}
+
+		_, err = markWorkForCollection(tx, inv.CollectionID, inv.WorkID)
+		if err == nil {
+			err = refreshCollectionWorkCount(tx, inv.CollectionID)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	if accept && ws.redis != nil {
+		ws.redis.Del(c.Request.Context(), fmt.Sprintf("work:%s", inv.WorkID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitation": inv})
+}
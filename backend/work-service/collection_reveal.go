@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+)
+
+// collectionRevealState is the part of a collection the reveal job needs.
+// The scheduled times already have any challenge fallback applied.
+type collectionRevealState struct {
+	ID                uuid.UUID
+	Title             string
+	IsUnrevealed      bool
+	IsAnonymous       bool
+	RevealWorksAt     *time.Time
+	RevealAuthorsAt   *time.Time
+	WorksRevealedAt   *time.Time
+	AuthorsRevealedAt *time.Time
+}
+
+// worksHeld reports whether the collection is still hiding its works.
+func (s collectionRevealState) worksHeld() bool {
+	return s.IsUnrevealed && s.WorksRevealedAt == nil
+}
+
+// authorsHeld reports whether the collection is still hiding its creators.
+func (s collectionRevealState) authorsHeld() bool {
+	return s.IsAnonymous && s.AuthorsRevealedAt == nil
+}
+
+// dueReveals decides which reveals are due at now. Creators are never
+// revealed while the works themselves are still hidden.
+func dueReveals(s collectionRevealState, now time.Time) (works, authors bool) {
+	works = s.worksHeld() && s.RevealWorksAt != nil && !now.Before(*s.RevealWorksAt)
+	authors = s.authorsHeld() && s.RevealAuthorsAt != nil && !now.Before(*s.RevealAuthorsAt) &&
+		(works || !s.worksHeld())
+	return works, authors
+}
+
+// markWorkForCollection sets a work's anon/unrevealed flags when it joins a
+// collection that is still holding works or creators back.
+func markWorkForCollection(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, collectionID, workID uuid.UUID) (hidden bool, err error) {
+	err = q.QueryRow(`
+		UPDATE works w SET
+			in_unrevealed_collection = COALESCE(w.in_unrevealed_collection, false)
+				OR (COALESCE(c.is_unrevealed, false) AND c.works_revealed_at IS NULL),
+			in_anon_collection = COALESCE(w.in_anon_collection, false)
+				OR (COALESCE(c.is_anonymous, false) AND c.authors_revealed_at IS NULL)
+		FROM collections c
+		WHERE c.id = $1 AND w.id = $2
+		RETURNING w.in_unrevealed_collection`, collectionID, workID).Scan(&hidden)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return hidden, err
+}
+
+// revealCollection records a reveal on the collection and recomputes the
+// flags of its works, which stay set if another collection still holds
+// them. It returns the works that changed.
+func revealCollection(ctx context.Context, tx *sql.Tx, collectionID uuid.UUID, works, authors bool) ([]uuid.UUID, error) {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE collections SET
+			works_revealed_at = CASE WHEN $2 THEN COALESCE(works_revealed_at, NOW()) ELSE works_revealed_at END,
+			authors_revealed_at = CASE WHEN $3 THEN COALESCE(authors_revealed_at, NOW()) ELSE authors_revealed_at END,
+			updated_at = NOW()
+		WHERE id = $1`, collectionID, works, authors)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH flags AS (
+			SELECT w.id,
+				EXISTS (
+					SELECT 1 FROM collection_works cw JOIN collections c ON c.id = cw.collection_id
+					WHERE cw.work_id = w.id AND COALESCE(c.is_unrevealed, false) AND c.works_revealed_at IS NULL
+				) AS unrevealed,
+				EXISTS (
+					SELECT 1 FROM collection_works cw JOIN collections c ON c.id = cw.collection_id
+					WHERE cw.work_id = w.id AND COALESCE(c.is_anonymous, false) AND c.authors_revealed_at IS NULL
+				) AS anon
+			FROM works w
+			JOIN collection_works cw ON cw.work_id = w.id AND cw.collection_id = $1
+		)
+		UPDATE works w SET
+			in_unrevealed_collection = f.unrevealed,
+			in_anon_collection = f.anon,
+			updated_at = NOW()
+		FROM flags f
+		WHERE w.id = f.id
+			AND (COALESCE(w.in_unrevealed_collection, false) != f.unrevealed
+				OR COALESCE(w.in_anon_collection, false) != f.anon)
+		RETURNING w.id`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changed := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		changed = append(changed, id)
+	}
+	return changed, rows.Err()
+}
+
+// revealDueCollections carries out every scheduled reveal that has come due.
+func (ws *WorkService) revealDueCollections(ctx context.Context) (int, error) {
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT c.id, c.title, COALESCE(c.is_unrevealed, false), COALESCE(c.is_anonymous, false),
+			COALESCE(c.reveal_works_at, cs.works_reveal_at),
+			COALESCE(c.reveal_authors_at, cs.authors_reveal_at),
+			c.works_revealed_at, c.authors_revealed_at
+		FROM collections c
+		LEFT JOIN challenge_settings cs ON cs.collection_id = c.id
+		WHERE (c.is_unrevealed AND c.works_revealed_at IS NULL
+				AND COALESCE(c.reveal_works_at, cs.works_reveal_at) <= NOW())
+			OR (c.is_anonymous AND c.authors_revealed_at IS NULL
+				AND COALESCE(c.reveal_authors_at, cs.authors_reveal_at) <= NOW())
+		LIMIT $1
+		FOR UPDATE OF c SKIP LOCKED`, scheduledWorkBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	due := []collectionRevealState{}
+	for rows.Next() {
+		var s collectionRevealState
+		if err := rows.Scan(&s.ID, &s.Title, &s.IsUnrevealed, &s.IsAnonymous, &s.RevealWorksAt,
+			&s.RevealAuthorsAt, &s.WorksRevealedAt, &s.AuthorsRevealedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	type revealed struct {
+		workIDs  []uuid.UUID
+		creators map[uuid.UUID][]uuid.UUID
+	}
+	done := []revealed{}
+	now := time.Now()
+	for _, s := range due {
+		works, authors := dueReveals(s, now)
+		if !works && !authors {
+			continue
+		}
+		workIDs, err := revealCollection(ctx, tx, s.ID, works, authors)
+		if err != nil {
+			return 0, fmt.Errorf("reveal collection %s: %w", s.ID, err)
+		}
+		creators, err := queueRevealNotifications(ctx, tx, s.Title, workIDs, works, authors)
+		if err != nil {
+			return 0, fmt.Errorf("reveal collection %s: %w", s.ID, err)
+		}
+		done = append(done, revealed{workIDs: workIDs, creators: creators})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range done {
+		ws.finishCollectionReveal(ctx, r.workIDs, r.creators)
+	}
+	return len(done), nil
+}
+
+// queueRevealNotifications notifies the creators of revealed works and
+// their subscribers, in the reveal's transaction, and returns each work's
+// creators
+func queueRevealNotifications(ctx context.Context, tx *sql.Tx, collectionTitle string, workIDs []uuid.UUID, works, authors bool) (map[uuid.UUID][]uuid.UUID, error) {
+	if len(workIDs) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(workIDs))
+	for i, id := range workIDs {
+		ids[i] = id.String()
+	}
+	creators, err := workCreatorIDs(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Works in %s have been revealed", collectionTitle)
+	if !works {
+		description = fmt.Sprintf("Creators in %s have been revealed", collectionTitle)
+	} else if authors {
+		description = fmt.Sprintf("Works and creators in %s have been revealed", collectionTitle)
+	}
+
+	for _, workID := range workIDs {
+		err := sendNotificationEvent(ctx, tx, notifications.EventData{
+			Type:         models.EventWorkRevealed,
+			SourceID:     workID,
+			SourceType:   "work",
+			Title:        "Work revealed",
+			Description:  description,
+			ActionURL:    fmt.Sprintf("/works/%s", workID),
+			RecipientIDs: creators[workID],
+			ExtraData: map[string]interface{}{
+				"collection_title": collectionTitle,
+				"works_revealed":   works,
+				"authors_revealed": authors,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return creators, nil
+}
+
+// finishCollectionReveal refreshes caches for revealed works and their
+// creators. Call it after the reveal commits.
+func (ws *WorkService) finishCollectionReveal(ctx context.Context, workIDs []uuid.UUID, creators map[uuid.UUID][]uuid.UUID) {
+	for _, workID := range workIDs {
+		if ws.redis != nil {
+			ws.redis.Del(ctx, fmt.Sprintf("work:%s", workID))
+		}
+		ws.InvalidateWorkCache(workID)
+		for _, userID := range creators[workID] {
+			ws.InvalidateUserCache(userID)
+		}
+	}
+}
+
+// RevealCollection lets a maintainer reveal works and/or creators now
+// instead of waiting for the scheduled time
+func (ws *WorkService) RevealCollection(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var req struct {
+		Works   *bool `json:"works"`
+		Authors *bool `json:"authors"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+	}
+
+	if !ws.requireCollectionMaintainer(c, collectionID) {
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var s collectionRevealState
+	err = tx.QueryRow(`
+		SELECT id, title, COALESCE(is_unrevealed, false), COALESCE(is_anonymous, false),
+			works_revealed_at, authors_revealed_at
+		FROM collections WHERE id = $1
+		FOR UPDATE`, collectionID).Scan(
+		&s.ID, &s.Title, &s.IsUnrevealed, &s.IsAnonymous, &s.WorksRevealedAt, &s.AuthorsRevealedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection"})
+		return
+	}
+
+	// Without a body, reveal whatever is still being held back
+	works := s.worksHeld() && (req.Works == nil || *req.Works)
+	authors := s.authorsHeld() && (req.Authors == nil || *req.Authors)
+	if !works && !authors {
+		c.JSON(http.StatusConflict, gin.H{"error": "Nothing left to reveal"})
+		return
+	}
+	if authors && !works && s.worksHeld() {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Works must be revealed before their creators"})
+		return
+	}
+
+	workIDs, err := revealCollection(c.Request.Context(), tx, collectionID, works, authors)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reveal collection"})
+		return
+	}
+	creators, err := queueRevealNotifications(c.Request.Context(), tx, s.Title, workIDs, works, authors)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reveal collection"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	ws.finishCollectionReveal(c.Request.Context(), workIDs, creators)
+
+	c.JSON(http.StatusOK, gin.H{
+		"works_revealed":   works,
+		"authors_revealed": authors,
+		"works_updated":    len(workIDs),
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDueReveals(t *testing.T) {
+	now := time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	t.Run("works reveal at scheduled time", func(t *testing.T) {
+		works, authors := dueReveals(collectionRevealState{IsUnrevealed: true, RevealWorksAt: &now}, now)
+		assert.True(t, works)
+		assert.False(t, authors)
+	})
+
+	t.Run("nothing due before scheduled time", func(t *testing.T) {
+		works, authors := dueReveals(collectionRevealState{
+			IsUnrevealed: true, IsAnonymous: true, RevealWorksAt: &future, RevealAuthorsAt: &future,
+		}, now)
+		assert.False(t, works)
+		assert.False(t, authors)
+	})
+
+	t.Run("no schedule means no automatic reveal", func(t *testing.T) {
+		works, authors := dueReveals(collectionRevealState{IsUnrevealed: true, IsAnonymous: true}, now)
+		assert.False(t, works)
+		assert.False(t, authors)
+	})
+
+	t.Run("already revealed is not revealed again", func(t *testing.T) {
+		works, authors := dueReveals(collectionRevealState{
+			IsUnrevealed: true, RevealWorksAt: &past, WorksRevealedAt: &past,
+			IsAnonymous: true, RevealAuthorsAt: &past, AuthorsRevealedAt: &past,
+		}, now)
+		assert.False(t, works)
+		assert.False(t, authors)
+	})
+
+	t.Run("authors wait for works", func(t *testing.T) {
+		works, authors := dueReveals(collectionRevealState{
+			IsUnrevealed: true, RevealWorksAt: &future,
+			IsAnonymous: true, RevealAuthorsAt: &past,
+		}, now)
+		assert.False(t, works)
+		assert.False(t, authors)
+	})
+
+	t.Run("authors and works revealed together", func(t *testing.T) {
+		works, authors := dueReveals(collectionRevealState{
+			IsUnrevealed: true, RevealWorksAt: &past,
+			IsAnonymous: true, RevealAuthorsAt: &past,
+		}, now)
+		assert.True(t, works)
+		assert.True(t, authors)
+	})
+
+	t.Run("anonymous-only collection reveals authors", func(t *testing.T) {
+		works, authors := dueReveals(collectionRevealState{IsAnonymous: true, RevealAuthorsAt: &past}, now)
+		assert.False(t, works)
+		assert.True(t, authors)
+	})
+}
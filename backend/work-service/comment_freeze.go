@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"nuclear-ao3/shared/middleware"
+)
+
+// =============================================================================
+// FROZEN COMMENT THREADS
+// A work's creator, or an admin, can freeze a comment to stop a heated
+// thread: the comment and every reply beneath it stay readable but take no
+// new replies. Unfreezing reopens the same subtree.
+// =============================================================================
+
+// errThreadFrozenCode is the error code answering a reply to a frozen comment
+const errThreadFrozenCode = "THREAD_FROZEN"
+
+// rejectReplyToFrozen answers a reply whose parent comment is frozen,
+// returning true if it did
+func (ws *WorkService) rejectReplyToFrozen(c *gin.Context, parentID *uuid.UUID) bool {
+	if parentID == nil {
+		return false
+	}
+	var frozen bool
+	err := ws.db.QueryRow("SELECT is_frozen FROM comments WHERE id = $1", *parentID).Scan(&frozen)
+	if err != nil || !frozen {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "This comment thread has been frozen and can't be replied to",
+		"code":  errThreadFrozenCode,
+	})
+	return true
+}
+
+// requireThreadModerator writes an error response and returns false unless
+// the current user created the comment's work or is an admin
+func (ws *WorkService) requireThreadModerator(c *gin.Context, commentID uuid.UUID) (uuid.UUID, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return uuid.Nil, false
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return uuid.Nil, false
+	}
+
+	var authorID uuid.UUID
+	err = ws.db.QueryRow(`
+		SELECT w.user_id
+		FROM comments c
+		JOIN works w ON w.id = c.work_id
+		WHERE c.id = $1`, commentID).Scan(&authorID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return uuid.Nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comment"})
+		return uuid.Nil, false
+	}
+	if authorID == userUUID {
+		return userUUID, true
+	}
+	isAdmin, err := ws.hasStaffRole(c.Request.Context(), userUUID, middleware.AdminRole)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return uuid.Nil, false
+	}
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the work's creator or an admin can freeze comments"})
+		return uuid.Nil, false
+	}
+	return userUUID, true
+}
+
+// setThreadFrozen freezes or unfreezes a comment and all its replies,
+// returning how many comments changed
+func (ws *WorkService) setThreadFrozen(commentID, userID uuid.UUID, frozen bool) (int64, error) {
+	res, err := ws.db.Exec(`
+		WITH RECURSIVE thread AS (
+			SELECT id FROM comments WHERE id = $1
+			UNION ALL
+			SELECT r.id FROM comments r JOIN thread t ON r.parent_comment_id = t.id
+		)
+		UPDATE comments
+		SET is_frozen = $2,
+			frozen_by = CASE WHEN $2 THEN $3::uuid END,
+			frozen_at = CASE WHEN $2 THEN NOW() END
+		WHERE id IN (SELECT id FROM thread) AND is_frozen <> $2`, commentID, frozen, userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// FreezeCommentThread stops new replies to a comment and its replies
+func (ws *WorkService) FreezeCommentThread(c *gin.Context) {
+	ws.updateThreadFrozen(c, true)
+}
+
+// UnfreezeCommentThread lets a frozen thread take replies again
+func (ws *WorkService) UnfreezeCommentThread(c *gin.Context) {
+	ws.updateThreadFrozen(c, false)
+}
+
+func (ws *WorkService) updateThreadFrozen(c *gin.Context, frozen bool) {
+	commentID, err := uuid.Parse(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	userID, ok := ws.requireThreadModerator(c, commentID)
+	if !ok {
+		return
+	}
+
+	changed, err := ws.setThreadFrozen(commentID, userID, frozen)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment thread"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comment_id":       commentID,
+		"is_frozen":        frozen,
+		"comments_updated": changed,
+	})
+}
@@ -1,17 +1,20 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+	"nuclear-ao3/shared/pagination"
 )
 
 // Comment handlers for the work service
@@ -128,6 +131,17 @@ func (ws *WorkService) GetWorkComments(c *gin.Context) {
 		comments = append(comments, comment)
 	}
 
+	// Mentions let the frontend link @usernames
+	commentIDs := make([]uuid.UUID, len(comments))
+	for i, comment := range comments {
+		commentIDs[i] = comment.ID
+	}
+	if mentions, err := ws.loadCommentMentions(c.Request.Context(), commentIDs); err == nil {
+		for i := range comments {
+			comments[i].Mentions = mentions[comments[i].ID]
+		}
+	}
+
 	// Get total count for pagination
 	var totalCount int
 	countQuery := `SELECT COUNT(*) FROM comments WHERE work_id = $1 AND is_deleted = false`
@@ -207,6 +221,9 @@ func (ws *WorkService) CreateGuestComment(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
 		return
 	}
+	if ws.rejectReplyToFrozen(c, req.ParentCommentID) {
+		return
+	}
 
 	// Create the comment using same logic as CreateComment
 	commentID := uuid.New()
@@ -229,7 +246,16 @@ func (ws *WorkService) CreateGuestComment(c *gin.Context) {
 		)
 	`
 
-	_, err = ws.db.Exec(query,
+	// The comment and its notification are recorded together
+	ctx := c.Request.Context()
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query,
 		commentID, req.WorkID, req.ChapterID, nil, nil, req.ParentCommentID,
 		req.Content, req.GuestName, req.GuestEmail, ipParam,
 	)
@@ -240,14 +266,21 @@ func (ws *WorkService) CreateGuestComment(c *gin.Context) {
 	}
 
 	// Retrieve the created comment with details
-	comment, err := ws.getCommentByID(commentID)
+	comment, err := loadCommentByID(tx, commentID)
+	if err == nil {
+		err = queueCommentNotification(ctx, tx, comment)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Comment created but failed to retrieve details"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
 		return
 	}
 
-	// Trigger notification for comment creation
-	go ws.triggerCommentNotification(comment, "comment_created")
+	if comment.WorkID != nil {
+		ws.invalidateWork(ctx, *comment.WorkID)
+	}
 
 	c.JSON(http.StatusCreated, comment)
 }
@@ -326,6 +359,12 @@ func (ws *WorkService) CreateComment(c *gin.Context) {
 			return
 		}
 	}
+	if ws.rejectReplyToFrozen(c, req.ParentCommentID) {
+		return
+	}
+	if ws.rejectBlockedCommenter(c, userID, req.WorkID, req.ChapterID, req.ParentCommentID) {
+		return
+	}
 
 	// Create the comment
 	commentID := uuid.New()
@@ -348,7 +387,16 @@ func (ws *WorkService) CreateComment(c *gin.Context) {
 		)
 	`
 
-	_, err := ws.db.Exec(query,
+	// The comment and its notification are recorded together
+	ctx := c.Request.Context()
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query,
 		commentID, req.WorkID, req.ChapterID, userID, pseudonymID, req.ParentCommentID,
 		req.Content, req.GuestName, req.GuestEmail, ipParam,
 	)
@@ -359,24 +407,29 @@ func (ws *WorkService) CreateComment(c *gin.Context) {
 	}
 
 	// Retrieve the created comment with details
-	comment, err := ws.getCommentByID(commentID)
+	comment, err := loadCommentByID(tx, commentID)
+	if err == nil {
+		err = queueCommentNotification(ctx, tx, comment)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
-		// Log error
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Comment created but failed to retrieve details"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
 		return
 	}
+	comment.Mentions = ws.applyCommentMentions(ctx, comment)
 
-	// Trigger notification for comment creation
-	go ws.triggerCommentNotification(comment, "comment_created")
+	if comment.WorkID != nil {
+		ws.invalidateWork(ctx, *comment.WorkID)
+	}
 
 	c.JSON(http.StatusCreated, comment)
 }
 
-// triggerCommentNotification sends a notification event to the notification service
-func (ws *WorkService) triggerCommentNotification(comment *models.CommentWithDetails, eventType string) {
-	// Get notification service URL from environment
-	notificationServiceURL := getEnv("NOTIFICATION_SERVICE_URL", "http://localhost:8004")
-
+// queueCommentNotification tells the work's authors, or the parent
+// comment's author, about a new comment
+func queueCommentNotification(ctx context.Context, tx *sql.Tx, comment *models.CommentWithDetails) error {
 	// Determine the notification event type
 	var notificationEventType string
 	if comment.ParentCommentID != nil && *comment.ParentCommentID != uuid.Nil {
@@ -385,46 +438,29 @@ func (ws *WorkService) triggerCommentNotification(comment *models.CommentWithDet
 		notificationEventType = "comment_received"
 	}
 
-	// Create event data
-	eventData := map[string]interface{}{
-		"type":        notificationEventType,
-		"source_id":   comment.WorkID,
-		"source_type": "work",
-		"title":       fmt.Sprintf("New comment on work"),
-		"description": fmt.Sprintf("%s left a comment on your work", comment.AuthorName),
-		"action_url":  fmt.Sprintf("/works/%s/comments/%s", comment.WorkID, comment.ID),
-		"actor_id":    comment.AuthorUserID,
-		"actor_name":  comment.AuthorName,
-		"extra_data": map[string]interface{}{
+	sourceID, actionURL := uuid.Nil, ""
+	if comment.WorkID != nil {
+		sourceID = *comment.WorkID
+		actionURL = commentActionURL(*comment.WorkID, comment.ID)
+	}
+
+	return sendNotificationEvent(ctx, tx, notifications.EventData{
+		Type:        models.NotificationEvent(notificationEventType),
+		SourceID:    sourceID,
+		SourceType:  "work",
+		Title:       "New comment on work",
+		Description: fmt.Sprintf("%s left a comment on your work", comment.AuthorName),
+		ActionURL:   actionURL,
+		ActorID:     comment.AuthorUserID,
+		ActorName:   comment.AuthorName,
+		ExtraData: map[string]interface{}{
 			"comment_id":        comment.ID,
 			"work_id":           comment.WorkID,
 			"work_title":        comment.WorkTitle,
 			"comment_content":   comment.Content,
 			"parent_comment_id": comment.ParentCommentID,
 		},
-	}
-
-	// Send to notification service
-	jsonData, err := json.Marshal(eventData)
-	if err != nil {
-		fmt.Printf("Failed to marshal notification event: %v\n", err)
-		return
-	}
-
-	resp, err := http.Post(
-		notificationServiceURL+"/api/v1/process-event",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
-	if err != nil {
-		fmt.Printf("Failed to send notification event: %v\n", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Notification service returned status: %d\n", resp.StatusCode)
-	}
+	})
 }
 
 // UpdateComment updates an existing comment
@@ -499,6 +535,8 @@ func (ws *WorkService) UpdateComment(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Comment updated but failed to retrieve details"})
 		return
 	}
+	// Only users newly mentioned by the edit are notified
+	updatedComment.Mentions = ws.applyCommentMentions(c.Request.Context(), updatedComment)
 
 	c.JSON(http.StatusOK, updatedComment)
 }
@@ -536,9 +574,9 @@ func (ws *WorkService) DeleteComment(c *gin.Context) {
 
 	// Verify the comment exists
 	var existingComment models.Comment
-	query := `SELECT id, user_id FROM comments WHERE id = $1 AND is_deleted = false`
+	query := `SELECT id, user_id, work_id FROM comments WHERE id = $1 AND is_deleted = false`
 
-	err = ws.db.QueryRow(query, commentID).Scan(&existingComment.ID, &existingComment.UserID)
+	err = ws.db.QueryRow(query, commentID).Scan(&existingComment.ID, &existingComment.UserID, &existingComment.WorkID)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
@@ -566,6 +604,9 @@ func (ws *WorkService) DeleteComment(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
 		return
 	}
+	if existingComment.WorkID != nil {
+		ws.invalidateWork(c.Request.Context(), *existingComment.WorkID)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
 }
@@ -675,6 +716,13 @@ func (ws *WorkService) GiveCommentKudos(c *gin.Context) {
 
 // Helper function to get a comment by ID with all details
 func (ws *WorkService) getCommentByID(commentID uuid.UUID) (*models.CommentWithDetails, error) {
+	return loadCommentByID(ws.db, commentID)
+}
+
+// loadCommentByID is getCommentByID on a database or transaction
+func loadCommentByID(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, commentID uuid.UUID) (*models.CommentWithDetails, error) {
 	query := `
 		SELECT 
 			c.id, c.work_id, c.chapter_id, c.user_id, c.pseudonym_id, c.parent_comment_id,
@@ -702,7 +750,7 @@ func (ws *WorkService) getCommentByID(commentID uuid.UUID) (*models.CommentWithD
 	var chapterID sql.NullString
 	var editedAt sql.NullTime
 
-	err := ws.db.QueryRow(query, commentID).Scan(
+	err := q.QueryRow(query, commentID).Scan(
 		&comment.ID, &comment.WorkID, &chapterID, &userID, &pseudonymID, &parentCommentID,
 		&comment.Content, &comment.GuestName, &comment.GuestEmail, &comment.IsDeleted,
 		&comment.IsModerated, &comment.IsSpam, &comment.ThreadLevel, &comment.KudosCount,
@@ -738,3 +786,397 @@ func (ws *WorkService) getCommentByID(commentID uuid.UUID) (*models.CommentWithD
 
 	return &comment, nil
 }
+
+// Comment pagination and permalinks
+//
+// Comments are paginated by thread: a page holds perPage top-level comments
+// and every visible reply beneath them, so a thread is never split across
+// pages. Each comment carries a stable anchor ("comment_<id>") that
+// notification action URLs point at; GET /comments/:id/context resolves an
+// anchor to the page its thread currently lives on.
+
+const (
+	defaultCommentsPerPage = 20
+	maxCommentsPerPage     = 100
+)
+
+const workCommentColumns = `c.id, c.work_id, c.chapter_id, c.user_id, c.parent_comment_id, c.content,
+	c.status, c.is_anonymous, c.is_frozen, c.created_at, c.updated_at,
+	COALESCE(u.username, 'Anonymous') as username`
+
+// commentAnchor is the fragment identifying a comment on a work page
+func commentAnchor(commentID uuid.UUID) string {
+	return "comment_" + commentID.String()
+}
+
+// commentActionURL is the link used in notifications about a comment
+func commentActionURL(workID, commentID uuid.UUID) string {
+	return fmt.Sprintf("/works/%s#%s", workID, commentAnchor(commentID))
+}
+
+// commentPaging reads order, page and per_page query parameters. Order is
+// "oldest" (the default) or "newest" and applies to top-level comments;
+// replies always read oldest first within their thread.
+func commentPaging(c *gin.Context) (order string, page, perPage int) {
+	order = c.DefaultQuery("order", "oldest")
+	if order != "newest" {
+		order = "oldest"
+	}
+
+	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	perPage, _ = strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultCommentsPerPage)))
+	if perPage < 1 {
+		perPage = defaultCommentsPerPage
+	}
+	if perPage > maxCommentsPerPage {
+		perPage = maxCommentsPerPage
+	}
+	return order, page, perPage
+}
+
+func scanWorkComments(rows *sql.Rows) ([]models.WorkComment, error) {
+	comments := []models.WorkComment{}
+	for rows.Next() {
+		var comment models.WorkComment
+		err := rows.Scan(
+			&comment.ID, &comment.WorkID, &comment.ChapterID, &comment.UserID, &comment.ParentID,
+			&comment.Content, &comment.Status, &comment.IsAnonymous, &comment.IsFrozen, &comment.CreatedAt, &comment.UpdatedAt,
+			&comment.Username)
+		if err != nil {
+			return nil, err
+		}
+		comment.Anchor = commentAnchor(comment.ID)
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// orderCommentThreads flattens roots and their replies depth first, replies
+// oldest first. Replies whose parent is not in the set (hidden or on another
+// page) are dropped along with their subtree.
+func orderCommentThreads(roots, replies []models.WorkComment) []models.WorkComment {
+	children := make(map[uuid.UUID][]models.WorkComment)
+	for _, reply := range replies {
+		if reply.ParentID != nil {
+			children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+		}
+	}
+	for parent := range children {
+		kids := children[parent]
+		sort.SliceStable(kids, func(i, j int) bool { return kids[i].CreatedAt.Before(kids[j].CreatedAt) })
+	}
+
+	ordered := make([]models.WorkComment, 0, len(roots)+len(replies))
+	var walk func(comment models.WorkComment)
+	walk = func(comment models.WorkComment) {
+		ordered = append(ordered, comment)
+		for _, child := range children[comment.ID] {
+			walk(child)
+		}
+	}
+	for _, root := range roots {
+		walk(root)
+	}
+	return ordered
+}
+
+// commentViewer resolves the viewer and whether they may see unpublished
+// comments on the work, writing an error response if the work is off limits
+func (ws *WorkService) commentViewer(c *gin.Context, workID uuid.UUID) (isAuthor bool, ok bool) {
+	var userUUID *uuid.UUID
+	if userID, hasUser := c.Get("user_id"); hasUser {
+		if userVal, err := uuid.Parse(userID.(string)); err == nil {
+			userUUID = &userVal
+		}
+	}
+
+	var canView bool
+	err := ws.db.QueryRow("SELECT can_user_view_work($1, $2)", workID, userUUID).Scan(&canView)
+	if err != nil || !canView {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot view this work"})
+		return false, false
+	}
+
+	var authorID uuid.UUID
+	if err := ws.db.QueryRow("SELECT user_id FROM works WHERE id = $1", workID).Scan(&authorID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get work info"})
+		return false, false
+	}
+
+	return userUUID != nil && *userUUID == authorID, true
+}
+
+// loadThreadReplies returns every reply beneath the given root comments
+func (ws *WorkService) loadThreadReplies(rootIDs []uuid.UUID, publishedOnly bool) ([]models.WorkComment, error) {
+	if len(rootIDs) == 0 {
+		return []models.WorkComment{}, nil
+	}
+
+	ids := make([]string, len(rootIDs))
+	for i, id := range rootIDs {
+		ids[i] = id.String()
+	}
+
+	query := `
+		WITH RECURSIVE thread AS (
+			SELECT id FROM comments WHERE id = ANY($1::uuid[])
+			UNION ALL
+			SELECT r.id FROM comments r JOIN thread t ON r.parent_comment_id = t.id
+		)
+		SELECT ` + workCommentColumns + `
+		FROM comments c
+		LEFT JOIN users u ON c.user_id = u.id AND c.is_anonymous = false
+		WHERE c.id IN (SELECT id FROM thread) AND c.parent_comment_id IS NOT NULL`
+	if publishedOnly {
+		query += " AND c.status = 'published'"
+	}
+	query += " ORDER BY c.created_at ASC"
+
+	rows, err := ws.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanWorkComments(rows)
+}
+
+// GetComments returns one page of comment threads on a work
+func (ws *WorkService) GetComments(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+
+	isAuthor, ok := ws.commentViewer(c, workID)
+	if !ok {
+		return
+	}
+
+	order, page, perPage := commentPaging(c)
+	cursorSort := "comments:" + order
+	after, cursorMode, err := pagination.FromQuery(c, cursorSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+
+	// Authors can see all comments, others only see published ones
+	filter := " WHERE c.work_id = $1"
+	args := []interface{}{workID}
+	if !isAuthor {
+		filter += " AND c.status = 'published'"
+	}
+	if chapterParam := c.Query("chapter_id"); chapterParam != "" {
+		chapterID, err := uuid.Parse(chapterParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chapter ID"})
+			return
+		}
+		filter += fmt.Sprintf(" AND c.chapter_id = $%d", len(args)+1)
+		args = append(args, chapterID)
+	}
+	// Comments by users the viewer muted are hidden, replies and all
+	muted := ws.viewerMutedUserIDs(c)
+	if len(muted) > 0 {
+		filter += " AND " + mutedCommentsCondition(len(args)+1)
+		args = append(args, mutedIDsArg(muted))
+	}
+
+	var totalThreads, totalCount int
+	err = ws.db.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE c.parent_comment_id IS NULL), COUNT(*)
+		FROM comments c`+filter, args...).Scan(&totalThreads, &totalCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count comments"})
+		return
+	}
+
+	direction := "ASC"
+	if order == "newest" {
+		direction = "DESC"
+	}
+
+	// By cursor, threads continue after the last one of the previous page,
+	// and one extra tells whether there's a next page
+	rootFilter := filter + " AND c.parent_comment_id IS NULL"
+	rootArgs := append([]interface{}{}, args...)
+	limit, offset := perPage, (page-1)*perPage
+	if cursorMode {
+		if after != nil {
+			cond, condArgs := after.Condition("c.created_at", "c.id", direction == "DESC", len(rootArgs)+1)
+			rootFilter += " AND " + cond
+			rootArgs = append(rootArgs, condArgs...)
+		}
+		limit, offset = perPage+1, 0
+	}
+
+	rootQuery := `SELECT ` + workCommentColumns + `
+		FROM comments c
+		LEFT JOIN users u ON c.user_id = u.id AND c.is_anonymous = false` + rootFilter + `
+		ORDER BY c.created_at ` + direction + `, c.id ` + direction +
+		fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(rootArgs)+1, len(rootArgs)+2)
+
+	rows, err := ws.db.Query(rootQuery, append(rootArgs, limit, offset)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+	roots, err := scanWorkComments(rows)
+	rows.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan comment"})
+		return
+	}
+	next := ""
+	if cursorMode && len(roots) > perPage {
+		roots = roots[:perPage]
+		last := roots[perPage-1]
+		next = pagination.Next(cursorSort, last.CreatedAt, last.ID)
+	}
+
+	rootIDs := make([]uuid.UUID, len(roots))
+	for i, root := range roots {
+		rootIDs[i] = root.ID
+	}
+	replies, err := ws.loadThreadReplies(rootIDs, !isAuthor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch replies"})
+		return
+	}
+
+	thread := orderCommentThreads(roots, withoutMutedComments(replies, muted))
+	ws.attachCommentMentions(c.Request.Context(), thread)
+
+	if cursorMode {
+		c.JSON(http.StatusOK, gin.H{
+			"comments":      thread,
+			"order":         order,
+			"per_page":      perPage,
+			"total_threads": totalThreads,
+			"total_count":   totalCount,
+			"pagination":    pagination.Response(perPage, next),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"comments":      thread,
+		"order":         order,
+		"page":          page,
+		"per_page":      perPage,
+		"total_threads": totalThreads,
+		"total_count":   totalCount,
+		"total_pages":   (totalThreads + perPage - 1) / perPage,
+	})
+}
+
+// GetCommentContext resolves a comment permalink: the whole thread it
+// belongs to and the page that thread appears on for the requested order
+// and page size
+func (ws *WorkService) GetCommentContext(c *gin.Context) {
+	commentID, err := uuid.Parse(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	// Walk up to the top-level comment of the thread
+	var workID, rootID uuid.UUID
+	var rootCreatedAt time.Time
+	var status string
+	err = ws.db.QueryRow(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_comment_id, 0 AS depth FROM comments WHERE id = $1
+			UNION ALL
+			SELECT p.id, p.parent_comment_id, a.depth + 1
+			FROM comments p JOIN ancestors a ON p.id = a.parent_comment_id
+		)
+		SELECT root.id, root.work_id, root.created_at, target.status
+		FROM ancestors a
+		JOIN comments root ON root.id = a.id
+		JOIN comments target ON target.id = $1
+		WHERE a.parent_comment_id IS NULL
+		ORDER BY a.depth DESC
+		LIMIT 1`, commentID).Scan(&rootID, &workID, &rootCreatedAt, &status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comment"})
+		return
+	}
+
+	isAuthor, ok := ws.commentViewer(c, workID)
+	if !ok {
+		return
+	}
+	if !isAuthor && status != "published" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+
+	order, _, perPage := commentPaging(c)
+
+	// Count the visible threads that sort ahead of this one
+	comparison := "<"
+	if order == "newest" {
+		comparison = ">"
+	}
+	positionQuery := `
+		SELECT COUNT(*) FROM comments c
+		WHERE c.work_id = $1 AND c.parent_comment_id IS NULL
+		AND (c.created_at, c.id) ` + comparison + ` ($2, $3)`
+	if !isAuthor {
+		positionQuery += " AND c.status = 'published'"
+	}
+	var ahead int
+	if err := ws.db.QueryRow(positionQuery, workID, rootCreatedAt, rootID).Scan(&ahead); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to locate comment"})
+		return
+	}
+
+	rows, err := ws.db.Query(`SELECT `+workCommentColumns+`
+		FROM comments c
+		LEFT JOIN users u ON c.user_id = u.id AND c.is_anonymous = false
+		WHERE c.id = $1`, rootID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
+		return
+	}
+	roots, err := scanWorkComments(rows)
+	rows.Close()
+	if err != nil || len(roots) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
+		return
+	}
+	if !isAuthor && roots[0].Status != "published" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+
+	replies, err := ws.loadThreadReplies([]uuid.UUID{rootID}, !isAuthor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch replies"})
+		return
+	}
+
+	thread := orderCommentThreads(roots, replies)
+	ws.attachCommentMentions(c.Request.Context(), thread)
+
+	page := ahead/perPage + 1
+	c.JSON(http.StatusOK, gin.H{
+		"comment_id": commentID,
+		"work_id":    workID,
+		"thread":     thread,
+		"anchor":     commentAnchor(commentID),
+		"page":       page,
+		"per_page":   perPage,
+		"order":      order,
+		"url":        fmt.Sprintf("/works/%s?page=%d&order=%s#%s", workID, page, order, commentAnchor(commentID)),
+	})
+}
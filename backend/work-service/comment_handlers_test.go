@@ -61,6 +61,8 @@ func (suite *CommentHandlersTestSuite) SetupSuite() {
 		api.PUT("/comments/:commentId", suite.workService.UpdateComment)
 		api.DELETE("/comments/:commentId", suite.workService.DeleteComment)
 		api.POST("/comments/:commentId/kudos", suite.workService.GiveCommentKudos)
+		api.POST("/comments/:comment_id/freeze", suite.workService.FreezeCommentThread)
+		api.POST("/comments/:comment_id/unfreeze", suite.workService.UnfreezeCommentThread)
 	}
 }
 
@@ -195,6 +197,42 @@ func (suite *CommentHandlersTestSuite) TestCreateComment_ThreadedReply() {
 	assert.Equal(suite.T(), parentComment.ID.String(), parentID.String)
 }
 
+func (suite *CommentHandlersTestSuite) TestFreezeCommentThread_RejectsReplies() {
+	root := suite.createTestComment("Heated comment", nil)
+	reply := suite.createTestComment("Heated reply", &root.ID)
+
+	post := func(path string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	replyTo := func(parentID uuid.UUID) *httptest.ResponseRecorder {
+		return post(fmt.Sprintf("/api/v1/works/%s/comments", suite.testWorkID), models.CommentCreateRequest{
+			WorkID:          &suite.testWorkID,
+			Content:         "One more thing",
+			PseudonymID:     &suite.testPseudID,
+			ParentCommentID: &parentID,
+		})
+	}
+
+	w := post(fmt.Sprintf("/api/v1/comments/%s/freeze", root.ID), nil)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	// The freeze covers replies already in the thread
+	w = replyTo(reply.ID)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(suite.T(), errThreadFrozenCode, response["code"])
+
+	w = post(fmt.Sprintf("/api/v1/comments/%s/unfreeze", root.ID), nil)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Equal(suite.T(), http.StatusCreated, replyTo(reply.ID).Code)
+}
+
 func (suite *CommentHandlersTestSuite) TestGetWorkComments_Success() {
 	// Create test comments
 	comment1 := suite.createTestComment("First comment", nil)
@@ -480,3 +518,52 @@ func commentStringPtr(s string) *string {
 func TestCommentHandlersTestSuite(t *testing.T) {
 	suite.Run(t, new(CommentHandlersTestSuite))
 }
+
+func TestOrderCommentThreads(t *testing.T) {
+	base := time.Now()
+	newComment := func(parent *uuid.UUID, offset time.Duration) models.WorkComment {
+		return models.WorkComment{ID: uuid.New(), ParentID: parent, CreatedAt: base.Add(offset)}
+	}
+
+	rootA := newComment(nil, 0)
+	rootB := newComment(nil, time.Minute)
+	replyA2 := newComment(&rootA.ID, 3*time.Minute)
+	replyA1 := newComment(&rootA.ID, 2*time.Minute)
+	nested := newComment(&replyA1.ID, 4*time.Minute)
+	orphaned := newComment(&[]uuid.UUID{uuid.New()}[0], 5*time.Minute)
+
+	ordered := orderCommentThreads(
+		[]models.WorkComment{rootB, rootA},
+		[]models.WorkComment{replyA2, nested, replyA1, orphaned})
+
+	ids := make([]uuid.UUID, len(ordered))
+	for i, comment := range ordered {
+		ids[i] = comment.ID
+	}
+	assert.Equal(t, []uuid.UUID{rootB.ID, rootA.ID, replyA1.ID, nested.ID, replyA2.ID}, ids,
+		"roots keep page order, replies follow depth first and oldest first")
+}
+
+func TestCommentActionURLUsesAnchor(t *testing.T) {
+	workID, commentID := uuid.New(), uuid.New()
+	assert.Equal(t, "comment_"+commentID.String(), commentAnchor(commentID))
+	assert.Equal(t, fmt.Sprintf("/works/%s#comment_%s", workID, commentID), commentActionURL(workID, commentID))
+}
+
+func TestWithoutMutedCommentsDropsRepliesToMutedUsers(t *testing.T) {
+	muted, other := uuid.New(), uuid.New()
+	root := models.WorkComment{ID: uuid.New(), UserID: &other}
+	mutedReply := models.WorkComment{ID: uuid.New(), ParentID: &root.ID, UserID: &muted, CreatedAt: time.Now()}
+	replyToMuted := models.WorkComment{ID: uuid.New(), ParentID: &mutedReply.ID, UserID: &other, CreatedAt: time.Now()}
+	anonymous := models.WorkComment{ID: uuid.New(), ParentID: &root.ID, UserID: &muted, IsAnonymous: true, CreatedAt: time.Now()}
+
+	replies := withoutMutedComments([]models.WorkComment{mutedReply, replyToMuted, anonymous}, []uuid.UUID{muted})
+	ordered := orderCommentThreads([]models.WorkComment{root}, replies)
+
+	ids := make([]uuid.UUID, len(ordered))
+	for i, comment := range ordered {
+		ids[i] = comment.ID
+	}
+	assert.Equal(t, []uuid.UUID{root.ID, anonymous.ID}, ids,
+		"muted users' replies go with their subtree, anonymous comments stay")
+}
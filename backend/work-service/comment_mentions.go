@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+)
+
+// Comment @mentions: resolved against users when a comment is posted or
+// edited, stored in comment_mentions, and notified once per comment. Users
+// who have blocked the commenter from commenting can't be mentioned, and
+// guests can't mention anyone.
+
+// resolveCommentMentions looks up the users content mentions, leaving out
+// unknown usernames, the commenter themselves, and users who block them
+func (ws *WorkService) resolveCommentMentions(ctx context.Context, commenterID uuid.UUID, content string) ([]models.CommentMention, error) {
+	names := models.ParseMentions(content)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	lowered := make([]string, len(names))
+	for i, name := range names {
+		lowered[i] = strings.ToLower(name)
+	}
+
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT u.id, u.username FROM users u
+		WHERE LOWER(u.username) = ANY($1) AND u.id != $2
+			AND NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE b.blocker_id = u.id AND b.blocked_id = $2
+					AND b.block_type IN ('full', 'comments')
+			)`, pq.Array(lowered), commenterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[string]models.CommentMention{}
+	for rows.Next() {
+		var m models.CommentMention
+		if err := rows.Scan(&m.UserID, &m.Username); err != nil {
+			return nil, err
+		}
+		found[strings.ToLower(m.Username)] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Keep the order the comment mentions them in
+	mentions := []models.CommentMention{}
+	for _, name := range lowered {
+		if m, ok := found[name]; ok {
+			mentions = append(mentions, m)
+		}
+	}
+	return mentions, nil
+}
+
+// saveCommentMentions makes the comment's stored mentions match mentions and
+// notifies the users newly mentioned, together
+func (ws *WorkService) saveCommentMentions(ctx context.Context, comment *models.CommentWithDetails, mentions []models.CommentMention) error {
+	userIDs := make([]string, len(mentions))
+	for i, m := range mentions {
+		userIDs[i] = m.UserID.String()
+	}
+
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM comment_mentions
+		WHERE comment_id = $1 AND NOT mentioned_user_id = ANY($2::uuid[])`,
+		comment.ID, pq.Array(userIDs)); err != nil {
+		return err
+	}
+
+	added := []models.CommentMention{}
+	for _, m := range mentions {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO comment_mentions (comment_id, mentioned_user_id) VALUES ($1, $2)
+			ON CONFLICT (comment_id, mentioned_user_id) DO NOTHING`, comment.ID, m.UserID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added = append(added, m)
+		}
+	}
+	if err := notifyCommentMentions(ctx, tx, comment, added); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// applyCommentMentions resolves, stores and notifies the mentions in a
+// comment the commenter just posted or edited, returning all its mentions.
+// Failures are logged: the comment itself has already been saved.
+func (ws *WorkService) applyCommentMentions(ctx context.Context, comment *models.CommentWithDetails) []models.CommentMention {
+	if comment.UserID == nil {
+		return nil
+	}
+	mentions, err := ws.resolveCommentMentions(ctx, *comment.UserID, comment.Content)
+	if err == nil {
+		err = ws.saveCommentMentions(ctx, comment, mentions)
+		if err == nil {
+			return mentions
+		}
+	}
+	log.Printf("Failed to record mentions in comment %s: %v", comment.ID, err)
+	return nil
+}
+
+// notifyCommentMentions tells each newly mentioned user about the comment
+func notifyCommentMentions(ctx context.Context, tx *sql.Tx, comment *models.CommentWithDetails, mentions []models.CommentMention) error {
+	if len(mentions) == 0 || comment.WorkID == nil {
+		return nil
+	}
+	recipients := make([]uuid.UUID, len(mentions))
+	for i, m := range mentions {
+		recipients[i] = m.UserID
+	}
+
+	workTitle := ""
+	if comment.WorkTitle != nil {
+		workTitle = *comment.WorkTitle
+	}
+	return sendNotificationEvent(ctx, tx, notifications.EventData{
+		Type:         models.EventCommentMention,
+		SourceID:     *comment.WorkID,
+		SourceType:   "work",
+		Title:        "You were mentioned in a comment",
+		Description:  fmt.Sprintf("%s mentioned you in a comment on %s", comment.AuthorName, workTitle),
+		ActionURL:    commentActionURL(*comment.WorkID, comment.ID),
+		ActorID:      comment.UserID,
+		ActorName:    comment.AuthorName,
+		RecipientIDs: recipients,
+		ExtraData: map[string]interface{}{
+			"comment_id":      comment.ID,
+			"work_id":         comment.WorkID,
+			"work_title":      workTitle,
+			"comment_content": comment.Content,
+		},
+	})
+}
+
+// loadCommentMentions returns the stored mentions of each comment
+func (ws *WorkService) loadCommentMentions(ctx context.Context, commentIDs []uuid.UUID) (map[uuid.UUID][]models.CommentMention, error) {
+	mentions := map[uuid.UUID][]models.CommentMention{}
+	if len(commentIDs) == 0 {
+		return mentions, nil
+	}
+	ids := make([]string, len(commentIDs))
+	for i, id := range commentIDs {
+		ids[i] = id.String()
+	}
+
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT cm.comment_id, u.id, u.username
+		FROM comment_mentions cm JOIN users u ON u.id = cm.mentioned_user_id
+		WHERE cm.comment_id = ANY($1::uuid[])
+		ORDER BY cm.created_at, u.username`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID uuid.UUID
+		var m models.CommentMention
+		if err := rows.Scan(&commentID, &m.UserID, &m.Username); err != nil {
+			return nil, err
+		}
+		mentions[commentID] = append(mentions[commentID], m)
+	}
+	return mentions, rows.Err()
+}
+
+// attachCommentMentions fills in Mentions on listed comments
+func (ws *WorkService) attachCommentMentions(ctx context.Context, comments []models.WorkComment) {
+	ids := make([]uuid.UUID, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	mentions, err := ws.loadCommentMentions(ctx, ids)
+	if err != nil {
+		log.Printf("Failed to load comment mentions: %v", err)
+		return
+	}
+	for i := range comments {
+		comments[i].Mentions = mentions[comments[i].ID]
+	}
+}
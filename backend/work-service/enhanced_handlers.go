@@ -5,11 +5,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -18,6 +20,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"nuclear-ao3/shared/middleware"
 	"nuclear-ao3/shared/models"
 )
 
@@ -52,6 +55,20 @@ func NewSearchServiceClient(baseURL string) *SearchServiceClient {
 	}
 }
 
+// indexRequest builds a request to search-service's index API, which
+// accepts the internal service token in place of a user token
+func (sc *SearchServiceClient) indexRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, sc.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(middleware.ServiceTokenHeader, os.Getenv(middleware.ServiceTokenEnv))
+	return req, nil
+}
+
 // Enhanced CreateWork with tag integration
 func (ws *WorkService) CreateWorkEnhanced(c *gin.Context) {
 	log.Printf("DEBUG ENHANCED: ====== STARTING CreateWorkEnhanced ======")
@@ -67,6 +84,12 @@ func (ws *WorkService) CreateWorkEnhanced(c *gin.Context) {
 	}
 	log.Printf("DEBUG ENHANCED: Step 1 SUCCESS - JSON parsed. Title: %s", req.Title)
 
+	if req.UnpublishAt != nil {
+		if err := validateUnpublishAt(*req.UnpublishAt, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 	// Step 2: Get user ID from context
 	log.Printf("DEBUG ENHANCED: Step 2 - Extracting user_id from context")
 	userID, exists := c.Get("user_id")
@@ -100,6 +123,10 @@ func (ws *WorkService) CreateWorkEnhanced(c *gin.Context) {
 	}
 	log.Printf("DEBUG ENHANCED: Step 3 SUCCESS - Parsed user UUID: %s", userUUID)
 
+	if ws.rejectDisallowedRating(c, req.Rating) {
+		return
+	}
+
 	// Step 4: Start database transaction
 	log.Printf("DEBUG ENHANCED: Step 4 - Starting database transaction")
 	tx, err := ws.db.Begin()
@@ -124,7 +151,6 @@ func (ws *WorkService) CreateWorkEnhanced(c *gin.Context) {
 		Title:                  req.Title,
 		Summary:                req.Summary,
 		Notes:                  req.Notes,
-		SeriesID:               req.SeriesID,
 		Language:               req.Language,
 		Rating:                 req.Rating,
 		Category:               req.Category,
@@ -144,6 +170,7 @@ func (ws *WorkService) CreateWorkEnhanced(c *gin.Context) {
 		IsAnonymous:            false,
 		InAnonCollection:       false,
 		InUnrevealedCollection: false,
+		UnpublishAt:            req.UnpublishAt,
 		CreatedAt:              now,
 		UpdatedAt:              now,
 	}
@@ -157,8 +184,8 @@ func (ws *WorkService) CreateWorkEnhanced(c *gin.Context) {
 			max_chapters, chapter_count, is_complete, status, 
 			restricted, comment_policy, moderate_comments, disable_comments,
 			is_anonymous, in_anon_collection, in_unrevealed_collection,
-			created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)`
+			unpublish_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)`
 
 	log.Printf("DEBUG ENHANCED: About to execute SQL with these key values:")
 	log.Printf("DEBUG ENHANCED: $1 (id): %s", work.ID)
@@ -172,7 +199,8 @@ func (ws *WorkService) CreateWorkEnhanced(c *gin.Context) {
 		pq.Array(work.FreeformTags), work.MaxChapters, work.ChapterCount,
 		work.IsComplete, work.Status, work.RestrictedToUsers, work.CommentPolicy,
 		work.ModerateComments, work.DisableComments, work.IsAnonymous,
-		work.InAnonCollection, work.InUnrevealedCollection, work.CreatedAt, work.UpdatedAt)
+		work.InAnonCollection, work.InUnrevealedCollection, work.UnpublishAt,
+		work.CreatedAt, work.UpdatedAt)
 
 	if err != nil {
 		log.Printf("DEBUG ENHANCED: ERROR - SQL execution failed: %v", err)
@@ -214,6 +242,32 @@ func (ws *WorkService) CreateWorkEnhanced(c *gin.Context) {
 		return
 	}
 
+	// Link prompt meme fills
+	if len(req.PromptIDs) > 0 {
+		if _, err = ws.linkPromptFills(tx, workID, userUUID, req.PromptIDs); err != nil {
+			if errors.Is(err, errPromptNotFillable) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link prompt fills"})
+			return
+		}
+	}
+
+	// Link related works (translations, remixes, inspirations)
+	var relatedWorks []models.RelatedWork
+	if len(req.RelatedWorks) > 0 {
+		relatedWorks, err = ws.linkRelatedWorks(tx, workID, userUUID, req.RelatedWorks)
+		if err != nil {
+			if errors.Is(err, errInvalidRelatedWork) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link related works"})
+			return
+		}
+	}
+
 	// Step 7: Commit transaction
 	log.Printf("DEBUG ENHANCED: Step 7 - Committing transaction")
 	if err = tx.Commit(); err != nil {
@@ -226,10 +280,16 @@ func (ws *WorkService) CreateWorkEnhanced(c *gin.Context) {
 	// Step 8: Async processing
 	log.Printf("DEBUG ENHANCED: Step 8 - Starting async processing")
 	go ws.processWorkTags(workID, req)
-	go ws.indexWorkInSearch(workID, work)
+	for _, r := range relatedWorks {
+		if r.ParentWorkID != nil && r.Status == "approved" {
+			ws.invalidateRelatedWorks(c.Request.Context(), *r.ParentWorkID)
+		}
+	}
+
+	suggestions := ws.tagSuggestions(c.Request.Context(), workTagFields(req.Fandoms, req.Characters, req.Relationships, req.FreeformTags))
 
 	log.Printf("DEBUG ENHANCED: ====== SUCCESS - Work created with ID: %s ======", workID)
-	c.JSON(http.StatusCreated, gin.H{"work": work})
+	c.JSON(http.StatusCreated, gin.H{"work": work, "tag_suggestions": suggestions})
 }
 
 // processWorkTags processes and creates tag relationships for a work
@@ -268,7 +328,7 @@ func (ws *WorkService) processWorkTags(workID uuid.UUID, req models.CreateWorkRe
 // getOrCreateTag gets an existing tag or creates a new one
 func (ws *WorkService) getOrCreateTag(ctx context.Context, tagName string) (uuid.UUID, error) {
 	// First try to find existing tag
-	tagClient := NewTagServiceClient("http://tag-service:8083")
+	tagClient := NewTagServiceClient(getEnv("TAG_SERVICE_URL", "http://tag-service:8083"))
 
 	// Search for existing tag
 	searchURL := fmt.Sprintf("%s/api/v1/tags?q=%s&limit=1", tagClient.baseURL, tagName)
@@ -365,7 +425,7 @@ func (ws *WorkService) inferTagType(tagName string) string {
 
 // addTagsToWork adds tags to a work via tag service
 func (ws *WorkService) addTagsToWork(ctx context.Context, workID uuid.UUID, tagIDs []uuid.UUID) {
-	tagClient := NewTagServiceClient("http://tag-service:8083")
+	tagClient := NewTagServiceClient(getEnv("TAG_SERVICE_URL", "http://tag-service:8083"))
 
 	reqBody := map[string][]uuid.UUID{
 		"tag_ids": tagIDs,
@@ -380,13 +440,17 @@ func (ws *WorkService) addTagsToWork(ctx context.Context, workID uuid.UUID, tagI
 	}
 }
 
-// indexWorkInSearch indexes a work in the search service
-func (ws *WorkService) indexWorkInSearch(workID uuid.UUID, work *models.Work) {
-	log.Printf("DEBUG: Starting indexing for work %s", workID)
-	searchClient := NewSearchServiceClient("http://localhost:8084")
+// workSearchDocument is the search service's document for a work, as
+// published to the search outbox stream
+func workSearchDocument(workID uuid.UUID, work *models.Work) map[string]interface{} {
+	// Anonymous works must not be findable by their creators
+	isAnonymous := work.IsAnonymous || work.InAnonCollection
+	authorIDs := []string{work.UserID.String()}
+	if isAnonymous {
+		authorIDs = []string{}
+	}
 
-	// Prepare work data for search indexing in the format expected by search service
-	searchDoc := map[string]interface{}{
+	return map[string]interface{}{
 		"work_id":           workID.String(),
 		"title":             work.Title,
 		"summary":           work.Summary,
@@ -397,13 +461,13 @@ func (ws *WorkService) indexWorkInSearch(workID uuid.UUID, work *models.Work) {
 		"relationships":     work.Relationships,
 		"additional_tags":   work.FreeformTags,
 		"warnings":          work.Warnings,
-		"categories":        []string{}, // TODO: extract from work.Category if needed
+		"categories":        work.Category,
 		"word_count":        work.WordCount,
 		"chapter_count":     work.ChapterCount,
 		"completion_status": work.Status,
 		"published_date":    work.CreatedAt,
 		"updated_date":      work.UpdatedAt,
-		"author_ids":        []string{work.UserID.String()},
+		"author_ids":        authorIDs,
 		"author_names":      []string{}, // TODO: fetch author name if needed
 		"hits":              work.Hits,
 		"kudos":             work.Kudos,
@@ -412,28 +476,65 @@ func (ws *WorkService) indexWorkInSearch(workID uuid.UUID, work *models.Work) {
 		"collections":       []string{},
 		"series":            []string{},
 		"is_restricted":     work.RestrictedToUsers,
-		"is_anonymous":      work.IsAnonymous,
+		"is_anonymous":      isAnonymous,
 	}
+}
 
-	body, _ := json.Marshal(searchDoc)
-	url := fmt.Sprintf("%s/api/v1/index/works/%s", searchClient.baseURL, workID.String())
-
-	log.Printf("DEBUG: Indexing work at URL: %s", url)
+// sendBookmarkSearchEvent tells the search service about a bookmark change
+// along with the work's current public bookmark count. The bookmark's notes,
+// tags, bookmarker, rec flag and bookmark lists go along so public bookmarks
+// can be searched; they are empty once the bookmark is deleted.
+func (ws *WorkService) sendBookmarkSearchEvent(eventType string, bookmarkID, workID uuid.UUID, isPrivate bool, createdAt time.Time) {
+	var fandoms, tags, listIDs pq.StringArray
+	var publicBookmarks int
+	var userID, username, notes sql.NullString
+	var isRec bool
+	err := ws.db.QueryRow(`
+		SELECT COALESCE(w.fandoms, '{}'),
+			(SELECT COUNT(*) FROM bookmarks b WHERE b.work_id = w.id AND NOT COALESCE(b.is_private, false)),
+			bm.user_id::text, u.username, bm.notes, COALESCE(bm.tags, '{}'), COALESCE(bm.is_rec, false),
+			ARRAY(SELECT li.list_id::text FROM bookmark_list_items li WHERE li.bookmark_id = $2 ORDER BY li.list_id)
+		FROM works w
+		LEFT JOIN bookmarks bm ON bm.id = $2
+		LEFT JOIN users u ON u.id = bm.user_id
+		WHERE w.id = $1`, workID, bookmarkID).Scan(&fandoms, &publicBookmarks, &userID, &username, &notes, &tags,
+		&isRec, &listIDs)
+	if err != nil {
+		log.Printf("ERROR: Failed to load bookmark data for work %s: %v", workID, err)
+		return
+	}
 
-	req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
+	searchClient := NewSearchServiceClient(getEnv("SEARCH_SERVICE_URL", "http://localhost:8084"))
+	body, _ := json.Marshal(map[string]interface{}{
+		"type":           eventType,
+		"bookmark_id":    bookmarkID.String(),
+		"work_id":        workID.String(),
+		"fandoms":        []string(fandoms),
+		"is_private":     isPrivate,
+		"created_at":     createdAt,
+		"work_bookmarks": publicBookmarks,
+		"user_id":        userID.String,
+		"bookmarker":     username.String,
+		"notes":          notes.String,
+		"tags":           []string(tags),
+		"is_rec":         isRec,
+		"list_ids":       []string(listIDs),
+	})
+
+	req, err := searchClient.indexRequest("POST", "/api/v1/index/events/bookmarks", bytes.NewBuffer(body))
+	if err != nil {
+		log.Printf("ERROR: Failed to build bookmark event for work %s: %v", workID, err)
+		return
+	}
 	resp, err := searchClient.client.Do(req)
 	if err != nil {
-		log.Printf("ERROR: Failed to index work %s: %v", workID, err)
+		log.Printf("ERROR: Failed to send bookmark event for work %s: %v", workID, err)
 		return
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		log.Printf("ERROR: Indexing failed for work %s, status: %d, response: %s", workID, resp.StatusCode, string(bodyBytes))
-	} else {
-		log.Printf("DEBUG: Successfully indexed work %s", workID)
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("ERROR: Bookmark event for work %s returned status %d", workID, resp.StatusCode)
 	}
 }
 
@@ -473,13 +574,14 @@ func (ws *WorkService) GetWorkWithTags(c *gin.Context) {
 		})
 	}
 
-	// Increment hit count asynchronously
-	go ws.incrementHits(workID)
+	// Count the view towards the work's hits
+	ws.recordHit(workID, uuid.Nil, hitViewer(c), hitReferrer(c), hitCountry(c))
+	ws.recordReading(c, workID)
 }
 
 // getWorkTags retrieves tags for a work from tag service
 func (ws *WorkService) getWorkTags(workID uuid.UUID) ([]models.Tag, error) {
-	tagClient := NewTagServiceClient("http://tag-service:8083")
+	tagClient := NewTagServiceClient(getEnv("TAG_SERVICE_URL", "http://tag-service:8083"))
 	url := fmt.Sprintf("%s/api/v1/works/%s/tags", tagClient.baseURL, workID)
 
 	resp, err := tagClient.client.Get(url)
@@ -722,7 +824,7 @@ func (ws *WorkService) SearchWorksAdvanced(c *gin.Context) {
 
 // searchWorksViaService searches works via search service
 func (ws *WorkService) searchWorksViaService(params map[string]interface{}) (gin.H, error) {
-	searchClient := NewSearchServiceClient("http://localhost:8084")
+	searchClient := NewSearchServiceClient(getEnv("SEARCH_SERVICE_URL", "http://localhost:8084"))
 
 	body, _ := json.Marshal(params)
 	url := fmt.Sprintf("%s/api/v1/search/works", searchClient.baseURL)
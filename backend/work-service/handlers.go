@@ -13,8 +13,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"nuclear-ao3/shared/audit"
+	"nuclear-ao3/shared/cache"
+	"nuclear-ao3/shared/middleware"
 	"nuclear-ao3/shared/models"
-	"nuclear-ao3/shared/notifications"
+	"nuclear-ao3/shared/pagination"
 )
 
 // Work CRUD operations
@@ -64,6 +67,9 @@ func (ws *WorkService) CreateWork(c *gin.Context) {
 	if rating == "" {
 		rating = "Not Rated"
 	}
+	if ws.rejectDisallowedRating(c, rating) {
+		return
+	}
 
 	work := &models.Work{
 		ID:                     workID,
@@ -71,7 +77,6 @@ func (ws *WorkService) CreateWork(c *gin.Context) {
 		Summary:                req.Summary,
 		Notes:                  req.Notes,
 		UserID:                 userUUID,
-		SeriesID:               req.SeriesID,
 		Language:               language,
 		Rating:                 rating,
 		Category:               req.Category,
@@ -162,6 +167,7 @@ func (ws *WorkService) CreateWork(c *gin.Context) {
 		EndNotes:  req.ChapterEndNotes,
 		Content:   req.ChapterContent,
 		WordCount: wordCount,
+		Language:  work.Language,
 		Status:    "published", // Make chapter published so it gets counted by trigger
 		CreatedAt: now,
 		UpdatedAt: now,
@@ -198,19 +204,12 @@ func (ws *WorkService) CreateWork(c *gin.Context) {
 
 	work.WordCount = wordCount
 
-	// Index work in search service asynchronously
-	go ws.indexWorkInSearch(workID, work)
+	// The search outbox picks up the work itself; chapters are indexed directly
+	go ws.indexChapterInSearch(workID, chapterID)
 
-	// Trigger notification for new work
-	go func() {
-		ctx := context.Background()
-		// For new works, we might want to notify author subscribers
-		// The triggerWorkNotification function handles work-specific subscriptions,
-		// but we might also want author-level notifications here
-		ws.triggerWorkNotification(ctx, workID, models.EventNewWork, work.Title, "New work has been published")
-	}()
+	suggestions := ws.tagSuggestions(c.Request.Context(), workTagFields(req.Fandoms, req.Characters, req.Relationships, req.FreeformTags))
 
-	c.JSON(http.StatusCreated, gin.H{"work": work, "first_chapter": chapter})
+	c.JSON(http.StatusCreated, gin.H{"work": work, "first_chapter": chapter, "tag_suggestions": suggestions})
 }
 
 func (ws *WorkService) GetWork(c *gin.Context) {
@@ -314,6 +313,12 @@ func (ws *WorkService) GetWork(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work", "details": err.Error()})
 		return
 	}
+	if ws.rejectLoggedOutReader(c, work.Rating) {
+		return
+	}
+	if work.RestrictedToAdults && ws.rejectAgeGatedReader(c, workID, work.Title, work.Rating) {
+		return
+	}
 
 	// Handle nullable fields exactly like SearchWorks
 	if summary.Valid {
@@ -363,10 +368,12 @@ func (ws *WorkService) GetWork(c *gin.Context) {
 			authors = append(authors, author)
 		}
 
-		// Return work with authors
+		// Return work with authors, series navigation and related works
 		c.JSON(http.StatusOK, gin.H{
-			"work":    work,
-			"authors": authors,
+			"work":          work,
+			"authors":       authors,
+			"series":        ws.getSeriesNavigation(c.Request.Context(), workID),
+			"related_works": ws.getRelatedWorks(c.Request.Context(), workID),
 		})
 		return
 	}
@@ -394,6 +401,15 @@ func (ws *WorkService) UpdateWork(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
 		return
 	}
+	if req.Status != nil {
+		switch *req.Status {
+		case "draft", "posted", "hidden", "unlisted":
+		default:
+			// Trashing goes through DeleteWork
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of draft, posted, hidden, unlisted"})
+			return
+		}
+	}
 
 	// Verify ownership using creatorship system
 	var isAuthor bool
@@ -415,6 +431,11 @@ func (ws *WorkService) UpdateWork(c *gin.Context) {
 		return
 	}
 
+	expected, ok := requireVersion(c, req.Version)
+	if !ok {
+		return
+	}
+
 	// Build dynamic update query
 	updates := []string{}
 	args := []interface{}{}
@@ -436,6 +457,9 @@ func (ws *WorkService) UpdateWork(c *gin.Context) {
 		argIndex++
 	}
 	if req.Rating != nil {
+		if ws.rejectDisallowedRating(c, *req.Rating) {
+			return
+		}
 		updates = append(updates, fmt.Sprintf("rating = $%d", argIndex))
 		args = append(args, *req.Rating)
 		argIndex++
@@ -499,8 +523,9 @@ func (ws *WorkService) UpdateWork(c *gin.Context) {
 		updates = append(updates, fmt.Sprintf("status = $%d", argIndex))
 		args = append(args, *req.Status)
 
-		// If publishing for first time, set published_at
-		if *req.Status == "posted" {
+		// If publishing for first time, set published_at. Unlisted works
+		// are published too, just not listed.
+		if *req.Status == "posted" || *req.Status == "unlisted" {
 			argIndex++
 			updates = append(updates, fmt.Sprintf("published_at = $%d", argIndex))
 			args = append(args, time.Now())
@@ -547,167 +572,127 @@ func (ws *WorkService) UpdateWork(c *gin.Context) {
 		args = append(args, *req.InUnrevealedCollection)
 		argIndex++
 	}
+	if req.ClearUnpublishAt {
+		updates = append(updates, "unpublish_at = NULL")
+	} else if req.UnpublishAt != nil {
+		if err := validateUnpublishAt(*req.UnpublishAt, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates = append(updates, fmt.Sprintf("unpublish_at = $%d", argIndex))
+		args = append(args, *req.UnpublishAt)
+		argIndex++
+	}
 
 	if len(updates) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No updates provided"})
 		return
 	}
 
-	// Add updated_at
-	updates = append(updates, fmt.Sprintf("updated_at = $%d", argIndex))
+	// Add updated_at and the next version
+	updates = append(updates, fmt.Sprintf("updated_at = $%d", argIndex), "version = version + 1")
 	args = append(args, time.Now())
 	argIndex++
 
 	// Add work ID for WHERE clause
 	args = append(args, workID)
 
-	query := fmt.Sprintf("UPDATE works SET %s WHERE id = $%d", strings.Join(updates, ", "), argIndex)
+	query := fmt.Sprintf("UPDATE works SET %s WHERE id = $%d RETURNING version", strings.Join(updates, ", "), argIndex)
 
-	_, err = ws.db.Exec(query, args...)
+	// Update and record changed metadata for the changelog together
+	ctx := c.Request.Context()
+	tx, err := ws.db.BeginTx(ctx, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
 		return
 	}
+	defer tx.Rollback()
 
-	// Clear cache
-	cacheKey := fmt.Sprintf("work:%s", workID)
-	ws.redis.Del(c.Request.Context(), cacheKey)
-
-	// Fetch updated work
-	work, err := ws.getWorkByID(workID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch updated work"})
+	before, err := loadWorkMetadataForUpdate(ctx, tx, workID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
 		return
 	}
-
-	// Trigger notification for work update
-	go func() {
-		ctx := context.Background()
-		ws.triggerWorkNotification(ctx, workID, models.EventWorkUpdated, work.Title, "Work has been updated")
-	}()
-
-	c.JSON(http.StatusOK, gin.H{"work": work})
-}
-
-func (ws *WorkService) DeleteWork(c *gin.Context) {
-	workID, err := uuid.Parse(c.Param("work_id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work", "details": err.Error()})
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	// The row is locked now, so the version can't move before the update
+	var current int
+	if err := tx.QueryRowContext(ctx, "SELECT version FROM works WHERE id = $1", workID).Scan(&current); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work", "details": err.Error()})
+		return
+	}
+	if current != expected {
+		tx.Rollback()
+		var latest interface{}
+		if work, err := ws.getWorkByID(workID); err == nil {
+			latest = work
+		}
+		respondVersionConflict(c, expected, current, "work", latest)
 		return
 	}
 
-	// Verify ownership using creatorship system
-	var isAuthor bool
-	err = ws.db.QueryRow(`
-		SELECT EXISTS(
-			SELECT 1 FROM creatorships c
-			JOIN pseuds p ON c.pseud_id = p.id
-			WHERE c.creation_id = $1 AND c.creation_type = 'Work' 
-			AND c.approved = true AND p.user_id = $2
-		)`, workID, userID).Scan(&isAuthor)
+	// Followers hear about a work the first time it's posted, not on reposts
+	firstPublish := false
+	if req.Status != nil && *req.Status == "posted" {
+		err = tx.QueryRowContext(ctx, "SELECT published_at IS NULL FROM works WHERE id = $1", workID).Scan(&firstPublish)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work", "details": err.Error()})
+			return
+		}
+	}
 
+	var version int
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&version)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ownership"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work", "details": err.Error()})
 		return
 	}
 
-	if !isAuthor {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to delete this work"})
+	var actorID *uuid.UUID
+	if id, err := uuid.Parse(userID.(string)); err == nil {
+		actorID = &id
+	}
+	if err := recordWorkMetadataChanges(ctx, tx, workID, actorID, diffWorkMetadata(before, req), time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record work changes", "details": err.Error()})
 		return
 	}
 
-	tx, err := ws.db.Begin()
+	// Notifications are queued with the update so they can't be lost
+	err = notifyWorkUpdated(ctx, tx, workID, actorID)
+	if err == nil && firstPublish {
+		err = notifyWorkPublished(ctx, tx, workID, actorID)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work", "details": err.Error()})
 		return
 	}
-	defer tx.Rollback()
-
-	// Delete related data (cascading)
-	tables := []string{"gifts", "creatorships", "work_comments", "work_kudos", "bookmarks", "work_statistics", "chapters", "works"}
-	for _, table := range tables {
-		var query string
-		if table == "works" {
-			query = fmt.Sprintf("DELETE FROM %s WHERE id = $1", table)
-		} else if table == "creatorships" || table == "gifts" {
-			if table == "creatorships" {
-				query = fmt.Sprintf("DELETE FROM %s WHERE creation_id = $1 AND creation_type = 'Work'", table)
-			} else {
-				query = fmt.Sprintf("DELETE FROM %s WHERE work_id = $1", table)
-			}
-		} else {
-			query = fmt.Sprintf("DELETE FROM %s WHERE work_id = $1", table)
-		}
-
-		_, err = tx.Exec(query, workID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete work data"})
-			return
-		}
-	}
 
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work", "details": err.Error()})
 		return
 	}
 
-	// Clear cache
-	cacheKey := fmt.Sprintf("work:%s", workID)
-	ws.redis.Del(c.Request.Context(), cacheKey)
-
-	c.JSON(http.StatusOK, gin.H{"message": "Work deleted successfully"})
-}
-
-// loadWorkTags loads tags for a work from the work_tags relationship table
-func (ws *WorkService) loadWorkTags(workID string) (fandoms, characters, relationships, freeformTags []string) {
-	log.Printf("=== LOADING TAGS FOR WORK %s ===", workID)
-	query := `
-		SELECT t.name, t.type 
-		FROM tags t 
-		JOIN work_tags wt ON t.id = wt.tag_id 
-		WHERE wt.work_id = $1 
-		ORDER BY t.type, t.name`
+	// Clear everything cached from the work
+	ws.InvalidateWorkCache(workID)
 
-	rows, err := ws.db.Query(query, workID)
+	// Fetch updated work
+	work, err := ws.getWorkByID(workID)
 	if err != nil {
-		log.Printf("Error loading tags for work %s: %v", workID, err)
-		return nil, nil, nil, nil
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch updated work"})
+		return
 	}
-	defer rows.Close()
-
-	tagCount := 0
-	for rows.Next() {
-		tagCount++
-		var name, tagType string
-		if err := rows.Scan(&name, &tagType); err != nil {
-			log.Printf("Error scanning tag: %v", err)
-			continue
-		}
 
-		log.Printf("DEBUG: Found tag %s (type: %s) for work %s", name, tagType, workID)
-		switch tagType {
-		case "fandom":
-			fandoms = append(fandoms, name)
-		case "character":
-			characters = append(characters, name)
-		case "relationship":
-			relationships = append(relationships, name)
-		case "freeform", "additional":
-			freeformTags = append(freeformTags, name)
-		case "warning":
-			// Warnings are handled separately in the main work query
-		}
+	// Posting or un-posting the work changes whether its text is searchable
+	if req.Status != nil {
+		go ws.indexWorkChaptersInSearch(workID)
 	}
 
-	log.Printf("DEBUG: Loaded %d tags for work %s. Fandoms: %v, Characters: %v, Relationships: %v, Freeform: %v",
-		tagCount, workID, fandoms, characters, relationships, freeformTags)
-	return fandoms, characters, relationships, freeformTags
+	suggestions := ws.tagSuggestions(c.Request.Context(), workTagFields(req.Fandoms, req.Characters, req.Relationships, req.FreeformTags))
+
+	c.JSON(http.StatusOK, gin.H{"work": work, "tag_suggestions": suggestions})
 }
 
 // SearchTags provides enhanced tag search with partial matching
@@ -830,8 +815,17 @@ func (ws *WorkService) SearchTags(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// searchWorksColumns are the work columns SearchWorks lists
+const searchWorksColumns = `
+		SELECT w.id, w.title, w.summary, w.user_id, u.username, w.language, w.rating,
+			w.category, w.archive_warning,
+			w.word_count, w.chapter_count, w.expected_chapters, w.is_complete, 
+			CASE WHEN w.is_draft THEN 'draft' WHEN w.is_complete THEN 'complete' ELSE 'in_progress' END as status,
+			w.published_at, w.updated_at, w.created_at,
+			COALESCE(w.hit_count, 0) as hits, COALESCE(w.kudos_count, 0) as kudos,
+			COALESCE(w.comment_count, 0) as comments, COALESCE(w.bookmark_count, 0) as bookmarks`
+
 func (ws *WorkService) SearchWorks(c *gin.Context) {
-	log.Printf("=== SEARCHWORKS HANDLER CALLED! ===")
 	// Parse query parameters
 	query := c.DefaultQuery("q", "")
 	fandoms := c.QueryArray("fandom")
@@ -841,6 +835,8 @@ func (ws *WorkService) SearchWorks(c *gin.Context) {
 	rating := c.QueryArray("rating")
 	category := c.QueryArray("category")
 	warnings := c.QueryArray("warning")
+	excludeRatings := c.QueryArray("exclude_rating")
+	excludeWarnings := c.QueryArray("exclude_warning")
 
 	sortBy := c.DefaultQuery("sort", "updated_at")
 	sortOrder := c.DefaultQuery("order", "desc")
@@ -850,31 +846,28 @@ func (ws *WorkService) SearchWorks(c *gin.Context) {
 	if limit > 100 {
 		limit = 100
 	}
+	if limit < 1 {
+		limit = 20
+	}
 	offset := (page - 1) * limit
 
 	// Get user ID for privacy filtering
 	_, hasUser := c.Get("user_id")
 
-	// Build SQL query - only show published works, not drafts
-	// Note: Remove the empty array columns, we'll load tags separately from work_tags table
+	// Build SQL query - only show published works, not drafts. Tag names
+	// are aggregated from work_tags for the page in the same query.
 	baseQuery := `
-		SELECT w.id, w.title, w.summary, w.user_id, u.username, w.language, w.rating,
-			w.category, w.archive_warning,
-			w.word_count, w.chapter_count, w.expected_chapters, w.is_complete, 
-			CASE WHEN w.is_draft THEN 'draft' WHEN w.is_complete THEN 'complete' ELSE 'in_progress' END as status,
-			w.published_at, w.updated_at, w.created_at,
-			COALESCE(w.hit_count, 0) as hits, COALESCE(w.kudos_count, 0) as kudos,
-			COALESCE(w.comment_count, 0) as comments, COALESCE(w.bookmark_count, 0) as bookmarks
 		FROM works w
 		JOIN users u ON w.user_id = u.id
-		WHERE w.is_draft = false AND w.published_at IS NOT NULL`
+		WHERE w.is_draft = false AND w.published_at IS NOT NULL AND w.deleted_at IS NULL
+			AND w.status != 'unlisted'`
 
 	args := []interface{}{}
 	argIndex := 1
 
-	// If no user is logged in, exclude user-restricted works
+	// If no user is logged in, exclude user-restricted and login-gated works
 	if !hasUser {
-		baseQuery += " AND w.restricted = false"
+		baseQuery += " AND w.restricted = false" + ws.loginGatedRatingsSQL(c.Request.Context())
 	}
 
 	conditions := []string{}
@@ -972,62 +965,163 @@ func (ws *WorkService) SearchWorks(c *gin.Context) {
 		conditions = append(conditions, fmt.Sprintf("w.warnings IN (%s)", strings.Join(placeholders, ",")))
 	}
 
+	// Exclusion filters: leave out works carrying any excluded tag
+	tagExclusions := []struct {
+		param    string
+		tagTypes string
+	}{
+		{"exclude_fandom", "'fandom'"},
+		{"exclude_character", "'character'"},
+		{"exclude_relationship", "'relationship'"},
+		{"exclude_tag", "'freeform', 'additional'"},
+	}
+	for _, exclusion := range tagExclusions {
+		names := c.QueryArray(exclusion.param)
+		if len(names) == 0 {
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf(`w.id NOT IN (
+			SELECT wt.work_id FROM work_tags wt
+			JOIN tags t ON wt.tag_id = t.id
+			WHERE t.type IN (%s) AND t.name = ANY($%d)
+		)`, exclusion.tagTypes, argIndex))
+		args = append(args, pq.Array(names))
+		argIndex++
+	}
+
+	if len(excludeRatings) > 0 {
+		conditions = append(conditions, fmt.Sprintf("NOT (w.rating = ANY($%d))", argIndex))
+		args = append(args, pq.Array(excludeRatings))
+		argIndex++
+	}
+
+	if len(excludeWarnings) > 0 {
+		conditions = append(conditions, fmt.Sprintf("(w.warnings IS NULL OR NOT (w.warnings = ANY($%d)))", argIndex))
+		args = append(args, pq.Array(excludeWarnings))
+		argIndex++
+	}
+
+	// Signed-in readers don't see works by users they've muted
+	if muted := ws.viewerMutedUserIDs(c); len(muted) > 0 {
+		conditions = append(conditions, mutedWorksCondition(argIndex))
+		args = append(args, mutedIDsArg(muted))
+		argIndex++
+	}
+
 	if len(conditions) > 0 {
 		baseQuery += " AND " + strings.Join(conditions, " AND ")
 	}
 
-	// Add ordering
-	allowedSort := map[string]bool{
-		"title": true, "updated_at": true, "created_at": true, "published_at": true,
-		"word_count": true, "hits": true, "kudos": true, "comments": true, "bookmarks": true,
+	// Published/updated between; a bare date as the upper bound covers that whole day
+	dateRanges := []struct {
+		column, param string
+		op            string
+	}{
+		{"w.published_at", "published_after", ">="},
+		{"w.published_at", "published_before", "<="},
+		{"w.updated_at", "updated_after", ">="},
+		{"w.updated_at", "updated_before", "<="},
+	}
+	for _, r := range dateRanges {
+		value := c.Query(r.param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			day, dayErr := time.Parse("2006-01-02", value)
+			if dayErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be YYYY-MM-DD or RFC 3339", r.param)})
+				return
+			}
+			t = day
+			if r.op == "<=" {
+				t = day.Add(24*time.Hour - time.Nanosecond)
+			}
+		}
+		baseQuery += fmt.Sprintf(" AND %s %s $%d", r.column, r.op, argIndex)
+		args = append(args, t)
+		argIndex++
 	}
-	if !allowedSort[sortBy] {
+
+	// Add ordering
+	sortExprs := map[string]string{
+		"title": "w.title", "updated_at": "w.updated_at", "created_at": "w.created_at",
+		"published_at": "w.published_at", "word_count": "w.word_count",
+		"hits": "COALESCE(w.hit_count, 0)", "kudos": "COALESCE(w.kudos_count, 0)",
+		"comments": "COALESCE(w.comment_count, 0)", "bookmarks": "COALESCE(w.bookmark_count, 0)",
+		"kudos_per_hit": "COALESCE(w.kudos_count, 0)::float / NULLIF(COALESCE(w.hit_count, 0), 0)",
+	}
+	orderExpr, ok := sortExprs[sortBy]
+	if !ok {
 		sortBy = "updated_at"
+		orderExpr = sortExprs[sortBy]
 	}
 	if sortOrder != "asc" && sortOrder != "desc" {
 		sortOrder = "desc"
 	}
 
-	baseQuery += fmt.Sprintf(" ORDER BY %s %s LIMIT $%d OFFSET $%d", sortBy, sortOrder, argIndex, argIndex+1)
-	args = append(args, limit, offset)
-
-	fmt.Printf("FINAL QUERY: %s\n", baseQuery)
-	fmt.Printf("ARGS: %v\n", args)
+	// The filters without ordering or paging, for counting matches
+	filters, filterArgs := baseQuery, args
 
-	// Test simple query first
-	var testCount int
-	testErr := ws.db.QueryRow("SELECT COUNT(*) FROM works").Scan(&testCount)
-	fmt.Printf("TEST QUERY: SELECT COUNT(*) FROM works = %d, err=%v\n", testCount, testErr)
+	// Cursor paging continues after the last work of the previous page,
+	// keyed on one of the timestamp orders
+	cursorSort := "works:" + sortBy + ":" + sortOrder
+	after, cursorMode, err := pagination.FromQuery(c, cursorSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	orderBy := fmt.Sprintf("%s %s NULLS LAST, w.id", orderExpr, sortOrder)
+	pageSize := limit
+	if cursorMode {
+		cursorExpr, ok := workCursorExprs[sortBy]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor paging needs sort=updated_at, created_at or published_at"})
+			return
+		}
+		if after != nil {
+			cond, condArgs := after.Condition(cursorExpr, "w.id", sortOrder == "desc", argIndex)
+			baseQuery += " AND " + cond
+			args = append(args, condArgs...)
+			argIndex += len(condArgs)
+		}
+		// One extra row tells whether there's a next page
+		offset = 0
+		orderBy = fmt.Sprintf("%s %s, w.id %s", cursorExpr, sortOrder, sortOrder)
+		pageSize = limit + 1
+	}
+	baseQuery += fmt.Sprintf(" ORDER BY %s LIMIT $%d OFFSET $%d", orderBy, argIndex, argIndex+1)
+	args = append(args, pageSize, offset)
 
-	fmt.Printf("About to execute main query...\n")
-	rows, err := ws.db.Query(baseQuery, args...)
-	fmt.Printf("Query result: rows=%v, err=%v\n", rows != nil, err)
+	// Browsing reads from the replica when there is one
+	rows, err := ws.readDB().Query(withWorkTagNames(searchWorksColumns+", row_number() OVER (ORDER BY "+orderBy+") AS position"+baseQuery), args...)
 	if err != nil {
-		fmt.Printf("Query failed: %v\n", err)
+		log.Printf("Failed to search works: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search works", "details": err.Error()})
 		return
 	}
 	defer rows.Close()
-	fmt.Printf("Query executed successfully\n")
 
 	works := []models.Work{}
-	fmt.Printf("Starting to scan rows\n")
 	for rows.Next() {
-		fmt.Printf("Processing row\n")
 		var work models.Work
 		var categoryStr sql.NullString
 		var warningsStr sql.NullString
 		var summaryStr sql.NullString
+		var position int64
+		var fandoms, characters, relationships, freeformTags pq.StringArray
 
 		err := rows.Scan(
 			&work.ID, &work.Title, &summaryStr, &work.UserID, &work.Username,
 			&work.Language, &work.Rating, &categoryStr, &warningsStr,
 			&work.WordCount, &work.ChapterCount, &work.MaxChapters,
 			&work.IsComplete, &work.Status, &work.PublishedAt, &work.UpdatedAt, &work.CreatedAt,
-			&work.Hits, &work.Kudos, &work.Comments, &work.Bookmarks)
+			&work.Hits, &work.Kudos, &work.Comments, &work.Bookmarks,
+			&position, &fandoms, &characters, &relationships, &freeformTags)
 
 		if err != nil {
-			fmt.Printf("Error scanning row: %v\n", err)
+			log.Printf("Error scanning searched work: %v", err)
 			continue
 		}
 
@@ -1042,27 +1136,31 @@ func (ws *WorkService) SearchWorks(c *gin.Context) {
 			work.Warnings = []string{warningsStr.String}
 		}
 
-		// Load tags from work_tags relationship table
-		log.Printf("DEBUG: Work ID is: %s, type: %T", work.ID, work.ID)
-		log.Printf("=== ABOUT TO LOAD TAGS FOR WORK %s ===", work.ID.String())
-		work.Fandoms, work.Characters, work.Relationships, work.FreeformTags = ws.loadWorkTags(work.ID.String())
-		log.Printf("=== FINISHED LOADING TAGS FOR WORK %s ===", work.ID.String())
-		if err != nil {
-			fmt.Printf("SCAN ERROR: %v\n", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan work data", "details": err.Error()})
-			return
-		}
+		work.Fandoms = []string(fandoms)
+		work.Characters = []string(characters)
+		work.Relationships = []string(relationships)
+		work.FreeformTags = []string(freeformTags)
 		works = append(works, work)
-		fmt.Printf("Successfully scanned work: %s\n", work.Title)
 	}
-	fmt.Printf("Finished scanning. Found %d works\n", len(works))
 
-	// Get total count
-	countQuery := strings.Replace(baseQuery, "SELECT w.id, w.title, w.summary, w.user_id, u.username, w.language, w.rating, w.category, w.warnings, w.fandoms, w.characters, w.relationships, w.freeform_tags, w.word_count, w.chapter_count, w.max_chapters, w.is_complete, w.status, w.published_at, w.updated_at, w.created_at, COALESCE(ws.hits, 0) as hits, COALESCE(ws.kudos, 0) as kudos, COALESCE(ws.comments, 0) as comments, COALESCE(ws.bookmarks, 0) as bookmarks", "SELECT COUNT(*)", 1)
-	countQuery = strings.Split(countQuery, "ORDER BY")[0] // Remove ORDER BY and LIMIT
+	if cursorMode {
+		next := ""
+		if len(works) > limit {
+			works = works[:limit]
+			last := works[limit-1]
+			lastTime, _ := workCursorTime(last, sortBy)
+			next = pagination.Next(cursorSort, lastTime, last.ID)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"works":      works,
+			"pagination": pagination.Response(limit, next),
+		})
+		return
+	}
 
+	// Get total count
 	var total int
-	err = ws.db.QueryRow(countQuery, args[:len(args)-2]...).Scan(&total) // Remove LIMIT and OFFSET args
+	err = ws.readDB().QueryRow("SELECT COUNT(*)"+filters, filterArgs...).Scan(&total)
 	if err != nil {
 		total = len(works) // Fallback
 	}
@@ -1080,33 +1178,63 @@ func (ws *WorkService) SearchWorks(c *gin.Context) {
 
 // Helper functions
 
-func (ws *WorkService) getWorkByID(workID uuid.UUID) (*models.Work, error) {
-	query := `
-		SELECT w.id, w.title, w.summary, w.notes, w.user_id, u.username,
-			w.language, w.rating, w.category, w.warnings, w.fandoms, w.characters, 
-			w.relationships, w.freeform_tags, w.word_count, w.chapter_count, w.max_chapters,
-			w.is_complete, w.status, w.published_at, w.updated_at, w.created_at,
-			COALESCE(ws.hits, 0) as hits, COALESCE(ws.kudos, 0) as kudos,
-			COALESCE(ws.comments, 0) as comments, COALESCE(ws.bookmarks, 0) as bookmarks
-		FROM works w
-		JOIN users u ON w.user_id = u.id
-		LEFT JOIN work_statistics ws ON w.id = ws.work_id
-		WHERE w.id = $1`
+// workCursorExprs are the orders a work list can be cursor paged by, each
+// keyed on a timestamp that is never NULL: a work without a published_at
+// sorts by when it was created, in the ORDER BY, the keyset condition and
+// the cursor alike
+var workCursorExprs = map[string]string{
+	"updated_at":   "w.updated_at",
+	"created_at":   "w.created_at",
+	"published_at": "COALESCE(w.published_at, w.created_at)",
+}
+
+// workCursorTime is the timestamp a work list sorted by sortBy is keyed on
+// for cursor paging, matching workCursorExprs; ok is false for sorts that
+// can't be paged by cursor
+func workCursorTime(w models.Work, sortBy string) (t time.Time, ok bool) {
+	switch sortBy {
+	case "updated_at":
+		return w.UpdatedAt, true
+	case "created_at":
+		return w.CreatedAt, true
+	case "published_at":
+		if w.PublishedAt != nil {
+			return *w.PublishedAt, true
+		}
+		return w.CreatedAt, true
+	}
+	return t, false
+}
 
+// workColumns selects what scanWork reads, from works w joined to users u
+// and, optionally, work_statistics ws
+const workColumns = `
+	w.id, w.title, w.summary, w.notes, w.user_id, u.username,
+	w.language, w.rating, w.category, w.warnings, w.fandoms, w.characters, 
+	w.relationships, w.freeform_tags, w.word_count, w.chapter_count, w.max_chapters,
+	w.is_complete, w.status, w.published_at, w.unpublish_at, w.updated_at, w.created_at,
+	COALESCE(w.is_anonymous, false), COALESCE(w.in_anon_collection, false),
+	COALESCE(w.in_unrevealed_collection, false), COALESCE(w.restricted_to_users, false),
+	COALESCE(ws.hits, 0) as hits, COALESCE(ws.kudos, 0) as kudos,
+	COALESCE(ws.comments, 0) as comments, COALESCE(ws.bookmarks, 0) as bookmarks,
+	w.version`
+
+// scanWork scans a row of workColumns followed by any extra columns
+func scanWork(scanner interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.Work, error) {
 	var work models.Work
 	var categoryArray, warningsArray, fandomsArray, charactersArray, relationshipsArray, freeformArray pq.StringArray
 
-	err := ws.db.QueryRow(query, workID).Scan(
+	dest := []interface{}{
 		&work.ID, &work.Title, &work.Summary, &work.Notes, &work.UserID, &work.Username,
 		&work.Language, &work.Rating, &categoryArray,
 		&warningsArray, &fandomsArray, &charactersArray,
 		&relationshipsArray, &freeformArray, &work.WordCount,
 		&work.ChapterCount, &work.MaxChapters, &work.IsComplete, &work.Status,
-		&work.PublishedAt, &work.UpdatedAt, &work.CreatedAt,
-		&work.Hits, &work.Kudos, &work.Comments, &work.Bookmarks)
-
-	if err != nil {
-		return nil, fmt.Errorf("DEBUG: Query error in getWorkByID: %v", err)
+		&work.PublishedAt, &work.UnpublishAt, &work.UpdatedAt, &work.CreatedAt,
+		&work.IsAnonymous, &work.InAnonCollection, &work.InUnrevealedCollection, &work.RestrictedToUsers,
+		&work.Hits, &work.Kudos, &work.Comments, &work.Bookmarks, &work.Version}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
 	}
 
 	// Convert arrays to slices
@@ -1117,26 +1245,23 @@ func (ws *WorkService) getWorkByID(workID uuid.UUID) (*models.Work, error) {
 	work.Relationships = []string(relationshipsArray)
 	work.FreeformTags = []string(freeformArray)
 
-	// Set SeriesID to nil since it's not stored in works table
-	work.SeriesID = nil
-
 	return &work, nil
 }
 
-func (ws *WorkService) incrementHits(workID uuid.UUID) {
-	// Increment hit counter asynchronously
-	go func() {
-		_, err := ws.db.Exec(`
-			INSERT INTO work_statistics (work_id, hits, kudos, comments, bookmarks, collections, updated_at)
-			VALUES ($1, 1, 0, 0, 0, 0, NOW())
-			ON CONFLICT (work_id)
-			DO UPDATE SET hits = work_statistics.hits + 1, updated_at = NOW()`,
-			workID)
-		if err != nil {
-			// Log error but don't fail the request
-			fmt.Printf("Failed to increment hits for work %s: %v\n", workID, err)
-		}
-	}()
+func (ws *WorkService) getWorkByID(workID uuid.UUID) (*models.Work, error) {
+	work, err := scanWork(ws.queryRow(context.Background(), workByIDQuery, workID))
+	if err != nil {
+		return nil, fmt.Errorf("DEBUG: Query error in getWorkByID: %v", err)
+	}
+	return work, nil
+}
+
+// workLanguage is a work's language code, for estimating its chapters'
+// reading time; unknown works read at the base speed
+func (ws *WorkService) workLanguage(workID uuid.UUID) string {
+	var language string
+	ws.queryRow(context.Background(), workLanguageQuery, workID).Scan(&language)
+	return language
 }
 
 func countWords(text string) int {
@@ -1178,27 +1303,40 @@ func (ws *WorkService) GetChapters(c *gin.Context) {
 	// 	return
 	// }
 
-	log.Printf("About to query chapters for work %s", workID)
-	rows, err := ws.db.Query(`
-		SELECT id, work_id, chapter_number, 
-			COALESCE(title, '') as title, 
-			COALESCE(summary, '') as summary, 
-			COALESCE(notes, '') as notes, 
-			COALESCE(end_notes, '') as end_notes, 
-			COALESCE(content, '') as content, 
-			COALESCE(word_count, 0) as word_count, 
-			CASE WHEN is_draft THEN 'draft' ELSE 'posted' END as status, 
-			published_at, created_at, updated_at
-		FROM chapters 
-		WHERE work_id = $1 
-		ORDER BY chapter_number`, workID)
+	if ws.rejectUnsharedWorkReader(c, workID) || ws.rejectLoggedOutWorkReader(c, workID) ||
+		ws.rejectAgeGatedWorkReader(c, workID) {
+		return
+	}
+
+	chapters := []models.Chapter{}
+	if ws.cache != nil {
+		err = ws.cache.GetOrSet(c.Request.Context(), chapterListCacheKey(workID), &chapters, cache.MediumTTL, func() (interface{}, error) {
+			return ws.fetchChaptersFromDB(workID)
+		}, workCacheTag(workID))
+	} else {
+		chapters, err = ws.fetchChaptersFromDB(workID)
+	}
 	if err != nil {
 		log.Printf("Failed to fetch chapters for work %s: %v", workID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chapters", "details": err.Error()})
 		return
 	}
+
+	if notModified(c, chaptersETag(workID, chapters)) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"chapters": chapters})
+}
+
+// fetchChaptersFromDB loads a work's chapters, drafts included, in order
+func (ws *WorkService) fetchChaptersFromDB(workID uuid.UUID) ([]models.Chapter, error) {
+	rows, err := ws.query(context.Background(), workChaptersQuery, workID)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
+	language := ws.workLanguage(workID)
 	chapters := []models.Chapter{}
 	for rows.Next() {
 		var chapter models.Chapter
@@ -1206,19 +1344,17 @@ func (ws *WorkService) GetChapters(c *gin.Context) {
 		err := rows.Scan(
 			&chapter.ID, &chapter.WorkID, &chapter.Number, &chapter.Title, &chapter.Summary,
 			&chapter.Notes, &chapter.EndNotes, &chapter.Content, &chapter.WordCount,
-			&chapter.Status, &publishedAt, &chapter.CreatedAt, &chapter.UpdatedAt)
+			&chapter.Status, &publishedAt, &chapter.CreatedAt, &chapter.UpdatedAt, &chapter.Version)
 		if err != nil {
-			log.Printf("Failed to scan chapter for work %s: %v", workID, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan chapter", "details": err.Error()})
-			return
+			return nil, err
 		}
 		if publishedAt.Valid {
 			chapter.PublishedAt = &publishedAt.Time
 		}
+		chapter.Language = language
 		chapters = append(chapters, chapter)
 	}
-
-	c.JSON(http.StatusOK, gin.H{"chapters": chapters})
+	return chapters, rows.Err()
 }
 
 func (ws *WorkService) GetChapter(c *gin.Context) {
@@ -1228,7 +1364,7 @@ func (ws *WorkService) GetChapter(c *gin.Context) {
 		return
 	}
 
-	chapterNumber, err := strconv.Atoi(c.Param("chapter_number"))
+	chapterNumber, err := strconv.Atoi(c.Param("chapter_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chapter number"})
 		return
@@ -1245,24 +1381,22 @@ func (ws *WorkService) GetChapter(c *gin.Context) {
 	}
 
 	var canView bool
-	err = ws.db.QueryRow("SELECT can_user_view_work($1, $2)", workID, userUUID).Scan(&canView)
-	if err != nil || !canView {
+	err = ws.queryRow(c.Request.Context(), canViewWorkQuery, workID, userUUID).Scan(&canView)
+	if (err != nil || !canView) && !ws.shareLinkGrantsAccess(c, workID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot view this work"})
 		return
 	}
+	if ws.rejectLoggedOutWorkReader(c, workID) || ws.rejectAgeGatedWorkReader(c, workID) {
+		return
+	}
 
 	var chapter models.Chapter
 	var publishedAt sql.NullTime
 
-	err = ws.db.QueryRow(`
-		SELECT id, work_id, chapter_number, title, summary, notes, end_notes, 
-			content, word_count, CASE WHEN is_draft THEN 'draft' ELSE 'posted' END as status, 
-			published_at, created_at, updated_at
-		FROM chapters 
-		WHERE work_id = $1 AND chapter_number = $2`, workID, chapterNumber).Scan(
+	err = ws.queryRow(c.Request.Context(), chapterByNumberQuery, workID, chapterNumber).Scan(
 		&chapter.ID, &chapter.WorkID, &chapter.Number, &chapter.Title, &chapter.Summary,
 		&chapter.Notes, &chapter.EndNotes, &chapter.Content, &chapter.WordCount,
-		&chapter.Status, &publishedAt, &chapter.CreatedAt, &chapter.UpdatedAt)
+		&chapter.Status, &publishedAt, &chapter.CreatedAt, &chapter.UpdatedAt, &chapter.Version)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Chapter not found"})
@@ -1276,11 +1410,21 @@ func (ws *WorkService) GetChapter(c *gin.Context) {
 	if publishedAt.Valid {
 		chapter.PublishedAt = &publishedAt.Time
 	}
+	chapter.Language = ws.workLanguage(workID)
 
-	// Increment work hit count when chapter is viewed
-	ws.incrementHits(workID)
+	// Count the view towards the work's and the chapter's hits
+	ws.recordHit(workID, chapter.ID, hitViewer(c), hitReferrer(c), hitCountry(c))
+	ws.recordReading(c, workID)
 
-	c.JSON(http.StatusOK, gin.H{"chapter": chapter})
+	// Re-reads still count as hits, but skip resending the chapter text
+	series := ws.getSeriesNavigation(c.Request.Context(), workID)
+	if notModified(c, versionETag(chapter.Version, chapter.ID, chapter.Number, chapter.Status, chapter.UpdatedAt, chapter.Language, series)) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"chapter": chapter,
+		"series":  series,
+	})
 }
 
 func (ws *WorkService) CreateChapter(c *gin.Context) {
@@ -1369,6 +1513,14 @@ func (ws *WorkService) CreateChapter(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
+	if err := lockLiveWork(c.Request.Context(), tx, workID); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create chapter"})
+		return
+	}
+
 	_, err = tx.Exec(`
 		INSERT INTO chapters (id, work_id, chapter_number, title, summary, notes, end_notes, 
 			content, word_count, is_draft, published_at, created_at, updated_at)
@@ -1395,12 +1547,32 @@ func (ws *WorkService) CreateChapter(c *gin.Context) {
 		return
 	}
 
+	if chapter.Status == "posted" {
+		if actorID, err := uuid.Parse(userID.(string)); err == nil {
+			if err := notifyChapterPosted(c.Request.Context(), tx, workID, chapter.Number, actorID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create chapter"})
+				return
+			}
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"chapter": chapter})
+	ws.InvalidateWorkCache(workID)
+	go ws.indexChapterInSearch(workID, chapterID)
+
+	chapter.Language = ws.workLanguage(workID)
+	response := gin.H{"chapter": chapter}
+	if chapter.Status == "posted" {
+		if warning := ws.checkPostedChapterLanguage(workID, chapterID, chapter.Content); warning != nil {
+			response["language_warning"] = warning
+		}
+	}
+
+	c.JSON(http.StatusCreated, response)
 }
 
 func (ws *WorkService) UpdateChapter(c *gin.Context) {
@@ -1448,18 +1620,13 @@ func (ws *WorkService) UpdateChapter(c *gin.Context) {
 		return
 	}
 
-	// Verify chapter belongs to this work
-	var existingChapter models.Chapter
-	err = ws.db.QueryRow(`
-		SELECT id, work_id, chapter_number, title, summary, notes, end_notes, 
-			content, word_count, CASE WHEN is_draft THEN 'draft' ELSE 'posted' END as status
-		FROM chapters 
-		WHERE id = $1 AND work_id = $2`, chapterID, workID).Scan(
-		&existingChapter.ID, &existingChapter.WorkID, &existingChapter.Number,
-		&existingChapter.Title, &existingChapter.Summary, &existingChapter.Notes,
-		&existingChapter.EndNotes, &existingChapter.Content, &existingChapter.WordCount,
-		&existingChapter.Status)
+	expected, ok := requireVersion(c, req.Version)
+	if !ok {
+		return
+	}
 
+	// Verify chapter belongs to this work
+	existingChapter, err := ws.fetchChapterForEdit(workID, chapterID)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Chapter not found"})
 		return
@@ -1523,19 +1690,20 @@ func (ws *WorkService) UpdateChapter(c *gin.Context) {
 		return
 	}
 
-	// Always update the updated_at timestamp
-	updates = append(updates, fmt.Sprintf("updated_at = $%d", argIndex))
+	// Always update the updated_at timestamp and the version
+	updates = append(updates, fmt.Sprintf("updated_at = $%d", argIndex), "version = version + 1")
 	args = append(args, time.Now())
 	argIndex++
 
-	// Add WHERE clause parameters
-	args = append(args, chapterID, workID)
+	// Add WHERE clause parameters; a stale version matches no row
+	args = append(args, chapterID, workID, expected)
 
 	query := fmt.Sprintf(`
 		UPDATE chapters 
 		SET %s 
-		WHERE id = $%d AND work_id = $%d`,
-		strings.Join(updates, ", "), argIndex, argIndex+1)
+		WHERE id = $%d AND work_id = $%d AND version = $%d
+		RETURNING version`,
+		strings.Join(updates, ", "), argIndex, argIndex+1, argIndex+2)
 
 	tx, err := ws.db.Begin()
 	if err != nil {
@@ -1544,7 +1712,26 @@ func (ws *WorkService) UpdateChapter(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(query, args...)
+	if err := lockLiveWork(c.Request.Context(), tx, workID); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chapter"})
+		return
+	}
+
+	var version int
+	err = tx.QueryRow(query, args...).Scan(&version)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		latest, err := ws.fetchChapterForEdit(workID, chapterID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chapter"})
+			return
+		}
+		respondVersionConflict(c, expected, latest.Version, "chapter", latest)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chapter"})
 		return
@@ -1586,31 +1773,67 @@ func (ws *WorkService) UpdateChapter(c *gin.Context) {
 		}
 	}
 
+	// A draft chapter going live is a new chapter as far as readers are concerned
+	postedNow := req.Status != nil && *req.Status == "posted" && existingChapter.Status == "draft"
+	if postedNow {
+		if actorID, err := uuid.Parse(userID.(string)); err == nil {
+			if err := notifyChapterPosted(c.Request.Context(), tx, workID, existingChapter.Number, actorID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chapter"})
+				return
+			}
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
 
-	// Clear cache
-	cacheKey := fmt.Sprintf("work:%s", workID)
-	ws.redis.Del(c.Request.Context(), cacheKey)
+	// Clear everything cached from the work
+	ws.InvalidateWorkCache(workID)
 	chapterCacheKey := fmt.Sprintf("chapter:%s", chapterID)
 	ws.redis.Del(c.Request.Context(), chapterCacheKey)
 
-	// Trigger notification for chapter update
-	go func() {
-		ctx := context.Background()
-		// Get work title for notification
-		var workTitle string
-		err := ws.db.QueryRow("SELECT title FROM works WHERE id = $1", workID).Scan(&workTitle)
-		if err != nil {
-			log.Printf("Failed to get work title for notification: %v", err)
-			workTitle = "Unknown Work"
+	if req.Content != nil || req.Status != nil {
+		go ws.indexChapterInSearch(workID, chapterID)
+	}
+
+	response := gin.H{"message": "Chapter updated successfully", "version": version}
+	// Re-check the language when posted text appears or changes
+	isPosted := existingChapter.Status == "posted"
+	if req.Status != nil {
+		isPosted = *req.Status == "posted"
+	}
+	if isPosted && (postedNow || req.Content != nil) {
+		content := existingChapter.Content
+		if req.Content != nil {
+			content = *req.Content
 		}
-		ws.triggerWorkNotification(ctx, workID, models.EventWorkUpdated, workTitle, "New chapter has been posted")
-	}()
+		if warning := ws.checkPostedChapterLanguage(workID, chapterID, content); warning != nil {
+			response["language_warning"] = warning
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Chapter updated successfully"})
+	c.JSON(http.StatusOK, response)
+}
+
+// fetchChapterForEdit loads a chapter of a work, as its authors edit it
+func (ws *WorkService) fetchChapterForEdit(workID, chapterID uuid.UUID) (*models.Chapter, error) {
+	var chapter models.Chapter
+	err := ws.db.QueryRow(`
+		SELECT id, work_id, chapter_number, title, summary, notes, end_notes, 
+			content, word_count, CASE WHEN is_draft THEN 'draft' ELSE 'posted' END as status,
+			version
+		FROM chapters 
+		WHERE id = $1 AND work_id = $2`, chapterID, workID).Scan(
+		&chapter.ID, &chapter.WorkID, &chapter.Number,
+		&chapter.Title, &chapter.Summary, &chapter.Notes,
+		&chapter.EndNotes, &chapter.Content, &chapter.WordCount,
+		&chapter.Status, &chapter.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &chapter, nil
 }
 
 func (ws *WorkService) DeleteChapter(c *gin.Context) {
@@ -1689,6 +1912,14 @@ func (ws *WorkService) DeleteChapter(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
+	if err := lockLiveWork(c.Request.Context(), tx, workID); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chapter"})
+		return
+	}
+
 	// Delete the chapter
 	_, err = tx.Exec("DELETE FROM chapters WHERE id = $1 AND work_id = $2", chapterID, workID)
 	if err != nil {
@@ -1741,12 +1972,13 @@ func (ws *WorkService) DeleteChapter(c *gin.Context) {
 		return
 	}
 
-	// Clear cache
-	cacheKey := fmt.Sprintf("work:%s", workID)
-	ws.redis.Del(c.Request.Context(), cacheKey)
+	// Clear everything cached from the work
+	ws.InvalidateWorkCache(workID)
 	chapterCacheKey := fmt.Sprintf("chapter:%s", chapterID)
 	ws.redis.Del(c.Request.Context(), chapterCacheKey)
 
+	go ws.removeChapterFromSearch(chapterID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":                "Chapter deleted successfully",
 		"deleted_chapter_number": chapter.Number,
@@ -1755,81 +1987,6 @@ func (ws *WorkService) DeleteChapter(c *gin.Context) {
 	})
 }
 
-func (ws *WorkService) GetComments(c *gin.Context) {
-	workID, err := uuid.Parse(c.Param("work_id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
-		return
-	}
-
-	// Get user ID for moderation checks
-	userID, hasUser := c.Get("user_id")
-	var userUUID *uuid.UUID
-	if hasUser {
-		userIDStr := userID.(string)
-		if userVal, err := uuid.Parse(userIDStr); err == nil {
-			userUUID = &userVal
-		}
-	}
-
-	// Check if user can view this work
-	var canView bool
-	err = ws.db.QueryRow("SELECT can_user_view_work($1, $2)", workID, userUUID).Scan(&canView)
-	if err != nil || !canView {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot view this work"})
-		return
-	}
-
-	// Get work owner for moderation check
-	var authorID uuid.UUID
-	err = ws.db.QueryRow("SELECT user_id FROM works WHERE id = $1", workID).Scan(&authorID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get work info"})
-		return
-	}
-
-	isAuthor := userUUID != nil && *userUUID == authorID
-
-	// Build query - show different comments based on user role
-	baseQuery := `
-		SELECT c.id, c.work_id, c.chapter_id, c.user_id, c.parent_comment_id, c.content,
-			c.status, c.is_anonymous, c.created_at, c.updated_at,
-			COALESCE(u.username, 'Anonymous') as username
-		FROM comments c
-		LEFT JOIN users u ON c.user_id = u.id AND c.is_anonymous = false
-		WHERE c.work_id = $1`
-
-	// Authors can see all comments, others only see published ones
-	if !isAuthor {
-		baseQuery += " AND c.status = 'published'"
-	}
-
-	baseQuery += " ORDER BY c.created_at ASC"
-
-	rows, err := ws.db.Query(baseQuery, workID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
-		return
-	}
-	defer rows.Close()
-
-	comments := []models.WorkComment{}
-	for rows.Next() {
-		var comment models.WorkComment
-		err := rows.Scan(
-			&comment.ID, &comment.WorkID, &comment.ChapterID, &comment.UserID, &comment.ParentID,
-			&comment.Content, &comment.Status, &comment.IsAnonymous, &comment.CreatedAt, &comment.UpdatedAt,
-			&comment.Username)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan comment"})
-			return
-		}
-		comments = append(comments, comment)
-	}
-
-	c.JSON(http.StatusOK, gin.H{"comments": comments})
-}
-
 func (ws *WorkService) GetKudos(c *gin.Context) {
 	workID, err := uuid.Parse(c.Param("work_id"))
 	if err != nil {
@@ -2001,15 +2158,27 @@ func (ws *WorkService) GiveKudos(c *gin.Context) {
 		}
 	}
 
+	// Kudos left from a chapter page may name the chapter for the author's
+	// per-chapter stats; a chapter from another work is ignored
+	var req struct {
+		ChapterID *uuid.UUID `json:"chapter_id"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+	}
+
 	// Give kudos
 	kudosID := uuid.New()
 	now := time.Now()
 	clientIP := c.ClientIP()
 
 	_, err = ws.db.Exec(`
-		INSERT INTO kudos (id, work_id, user_id, ip_address, created_at)
-		VALUES ($1, $2, $3, $4, $5)`,
-		kudosID, workID, userUUID, clientIP, now)
+		INSERT INTO kudos (id, work_id, user_id, ip_address, created_at, chapter_id)
+		VALUES ($1, $2, $3, $4, $5, (SELECT id FROM chapters WHERE id = $6 AND work_id = $2))`,
+		kudosID, workID, userUUID, clientIP, now, req.ChapterID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to give kudos"})
@@ -2027,6 +2196,8 @@ func (ws *WorkService) GiveKudos(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update kudos count"})
 		return
 	}
+	ws.recordKudos(workID, 1)
+	ws.invalidateWork(c.Request.Context(), workID)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Kudos given successfully"})
 }
@@ -2183,11 +2354,10 @@ func (ws *WorkService) GetStats(c *gin.Context) {
 	if isOwner {
 		// Get daily hits for the last 30 days
 		rows, err := ws.db.Query(`
-			SELECT DATE(hit_date) as date, COUNT(*) as count
-			FROM work_hits 
+			SELECT TO_CHAR(hit_date, 'YYYY-MM-DD') as date, hits as count
+			FROM work_daily_hits
 			WHERE work_id = $1 AND hit_date >= CURRENT_DATE - INTERVAL '30 days'
-			GROUP BY DATE(hit_date)
-			ORDER BY date DESC`, workID)
+			ORDER BY hit_date DESC`, workID)
 
 		if err == nil {
 			defer rows.Close()
@@ -2205,8 +2375,8 @@ func (ws *WorkService) GetStats(c *gin.Context) {
 
 		// Get monthly hits for the last 12 months
 		monthlyRows, err := ws.db.Query(`
-			SELECT TO_CHAR(hit_date, 'YYYY-MM') as month, COUNT(*) as count
-			FROM work_hits 
+			SELECT TO_CHAR(hit_date, 'YYYY-MM') as month, SUM(hits) as count
+			FROM work_daily_hits
 			WHERE work_id = $1 AND hit_date >= CURRENT_DATE - INTERVAL '12 months'
 			GROUP BY TO_CHAR(hit_date, 'YYYY-MM')
 			ORDER BY month DESC`, workID)
@@ -2288,7 +2458,7 @@ func (ws *WorkService) SearchSeries(c *gin.Context) {
 			SELECT COALESCE(SUM(w.word_count), 0) 
 			FROM works w 
 			JOIN series_works sw ON w.id = sw.work_id
-			WHERE sw.series_id = $1 AND w.status != 'draft'`, s.ID).Scan(&s.WordCount)
+			WHERE sw.series_id = $1 AND w.status NOT IN ('draft', 'unlisted') AND w.deleted_at IS NULL`, s.ID).Scan(&s.WordCount)
 
 		series = append(series, s)
 	}
@@ -2347,7 +2517,7 @@ func (ws *WorkService) GetSeries(c *gin.Context) {
 		SELECT COALESCE(SUM(w.word_count), 0)
 		FROM works w
 		JOIN series_works sw ON w.id = sw.work_id
-		WHERE sw.series_id = $1 AND w.status != 'draft'`, seriesID).Scan(&series.WordCount)
+		WHERE sw.series_id = $1 AND w.status NOT IN ('draft', 'unlisted') AND w.deleted_at IS NULL`, seriesID).Scan(&series.WordCount)
 
 	if err != nil {
 		series.WordCount = 0 // Fallback
@@ -2383,11 +2553,11 @@ func (ws *WorkService) GetSeriesWorks(c *gin.Context) {
 			sw.position
 		FROM works w
 		JOIN series_works sw ON w.id = sw.work_id
-		WHERE sw.series_id = $1`
+		WHERE sw.series_id = $1 AND w.deleted_at IS NULL`
 
 	// If no user, only show non-draft, non-restricted works
 	if !hasUser {
-		baseQuery += " AND w.status != 'draft' AND w.restricted = false"
+		baseQuery += " AND w.status NOT IN ('draft', 'unlisted') AND w.restricted = false" + ws.loginGatedRatingsSQL(c.Request.Context())
 	}
 
 	baseQuery += " ORDER BY sw.position"
@@ -2569,13 +2739,6 @@ func (ws *WorkService) CreateSeries(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add work to series"})
 			return
 		}
-
-		// Update work to reference series
-		_, err = tx.Exec("UPDATE works SET series_id = $1, updated_at = $2 WHERE id = $3", seriesID, now, workID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work series reference"})
-			return
-		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -2623,7 +2786,7 @@ func (ws *WorkService) UpdateSeries(c *gin.Context) {
 		return
 	}
 
-	if ownerID != userID {
+	if ownerID.String() != userID.(string) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own series"})
 		return
 	}
@@ -2659,6 +2822,10 @@ func (ws *WorkService) UpdateSeries(c *gin.Context) {
 	}
 
 	series.Username = username
+
+	// Title and completion status are part of each member's navigation
+	ws.invalidateSeriesNavigation(c.Request.Context(), ws.seriesWorkIDs(c.Request.Context(), seriesID)...)
+
 	c.JSON(http.StatusOK, gin.H{"series": series})
 }
 
@@ -2687,7 +2854,7 @@ func (ws *WorkService) DeleteSeries(c *gin.Context) {
 		return
 	}
 
-	if ownerID != userID {
+	if ownerID.String() != userID.(string) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own series"})
 		return
 	}
@@ -2699,12 +2866,8 @@ func (ws *WorkService) DeleteSeries(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	// Remove series reference from works
-	_, err = tx.Exec("UPDATE works SET series_id = NULL WHERE series_id = $1", seriesID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update works"})
-		return
-	}
+	// Capture members before the cascade so their navigation can be dropped
+	memberIDs := ws.seriesWorkIDs(c.Request.Context(), seriesID)
 
 	// Delete series (series_works will be deleted by CASCADE)
 	result, err := tx.Exec("DELETE FROM series WHERE id = $1", seriesID)
@@ -2724,6 +2887,8 @@ func (ws *WorkService) DeleteSeries(c *gin.Context) {
 		return
 	}
 
+	ws.invalidateSeriesNavigation(c.Request.Context(), memberIDs...)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Series deleted successfully"})
 }
 
@@ -2782,7 +2947,7 @@ func (ws *WorkService) AddWorkToSeries(c *gin.Context) {
 		return
 	}
 
-	if seriesOwnerID != userID || workOwnerID != userID {
+	if seriesOwnerID.String() != userID.(string) || workOwnerID.String() != userID.(string) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only add your own works to your own series"})
 		return
 	}
@@ -2827,13 +2992,6 @@ func (ws *WorkService) AddWorkToSeries(c *gin.Context) {
 		return
 	}
 
-	// Update work's series reference
-	_, err = tx.Exec("UPDATE works SET series_id = $1, updated_at = $2 WHERE id = $3", seriesID, now, workID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work series reference"})
-		return
-	}
-
 	// Update series work count
 	_, err = tx.Exec("UPDATE series SET work_count = (SELECT COUNT(*) FROM series_works WHERE series_id = $1), updated_at = $2 WHERE id = $1", seriesID, now)
 	if err != nil {
@@ -2846,6 +3004,8 @@ func (ws *WorkService) AddWorkToSeries(c *gin.Context) {
 		return
 	}
 
+	ws.invalidateSeriesNavigation(c.Request.Context(), ws.seriesWorkIDs(c.Request.Context(), seriesID)...)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Work added to series successfully", "position": position})
 }
 
@@ -2893,7 +3053,7 @@ func (ws *WorkService) RemoveWorkFromSeries(c *gin.Context) {
 		return
 	}
 
-	if seriesOwnerID != userID || workOwnerID != userID {
+	if seriesOwnerID.String() != userID.(string) || workOwnerID.String() != userID.(string) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only remove your own works from your own series"})
 		return
 	}
@@ -2905,14 +3065,6 @@ func (ws *WorkService) RemoveWorkFromSeries(c *gin.Context) {
 		return
 	}
 
-	// Update work's series reference
-	now := time.Now()
-	_, err = tx.Exec("UPDATE works SET series_id = NULL, updated_at = $1 WHERE id = $2", now, workID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work series reference"})
-		return
-	}
-
 	// Reorder remaining works to close the gap
 	_, err = tx.Exec("UPDATE series_works SET position = position - 1 WHERE series_id = $1 AND position > $2", seriesID, position)
 	if err != nil {
@@ -2921,7 +3073,7 @@ func (ws *WorkService) RemoveWorkFromSeries(c *gin.Context) {
 	}
 
 	// Update series work count
-	_, err = tx.Exec("UPDATE series SET work_count = (SELECT COUNT(*) FROM series_works WHERE series_id = $1), updated_at = $2 WHERE id = $1", seriesID, now)
+	_, err = tx.Exec("UPDATE series SET work_count = (SELECT COUNT(*) FROM series_works WHERE series_id = $1), updated_at = $2 WHERE id = $1", seriesID, time.Now())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update series work count"})
 		return
@@ -2932,6 +3084,9 @@ func (ws *WorkService) RemoveWorkFromSeries(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+	ws.invalidateSeriesNavigation(ctx, append(ws.seriesWorkIDs(ctx, seriesID), workID)...)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Work removed from series successfully"})
 }
 
@@ -2982,12 +3137,12 @@ func (ws *WorkService) SearchCollections(c *gin.Context) {
 	var collections []gin.H
 	for rows.Next() {
 		var collection models.Collection
-		var username string
+		var username, role string
 		err := rows.Scan(
 			&collection.ID, &collection.Name, &collection.Title, &collection.Description,
 			&collection.UserID, &collection.IsOpen, &collection.IsModerated,
 			&collection.IsAnonymous, &collection.WorkCount, &collection.CreatedAt,
-			&collection.UpdatedAt, &username)
+			&collection.UpdatedAt, &username, &role)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan collection"})
 			return
@@ -3032,14 +3187,16 @@ func (ws *WorkService) GetCollection(c *gin.Context) {
 	var username string
 	err = ws.db.QueryRow(`
 		SELECT c.id, c.name, c.title, c.description, c.user_id, c.is_open, 
-			c.is_moderated, c.is_anonymous, c.work_count, c.created_at, c.updated_at,
-			u.username
+			c.is_moderated, c.is_anonymous, COALESCE(c.is_unrevealed, false), c.work_count,
+			c.reveal_works_at, c.reveal_authors_at, c.works_revealed_at, c.authors_revealed_at,
+			c.created_at, c.updated_at, u.username
 		FROM collections c
 		JOIN users u ON c.user_id = u.id
 		WHERE c.id = $1`, collectionID).Scan(
 		&collection.ID, &collection.Name, &collection.Title, &collection.Description,
 		&collection.UserID, &collection.IsOpen, &collection.IsModerated, &collection.IsAnonymous,
-		&collection.WorkCount, &collection.CreatedAt, &collection.UpdatedAt, &username)
+		&collection.IsUnrevealed, &collection.WorkCount, &collection.RevealWorksAt, &collection.RevealAuthorsAt,
+		&collection.WorksRevealedAt, &collection.AuthorsRevealedAt, &collection.CreatedAt, &collection.UpdatedAt, &username)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
@@ -3116,10 +3273,8 @@ func (ws *WorkService) GetCollectionWorks(c *gin.Context) {
 	// Only show approved items unless user is collection maintainer
 	var isOwner bool
 	if userUUID != nil {
-		err = ws.db.QueryRow("SELECT user_id = $1 FROM collections WHERE id = $2", *userUUID, collectionID).Scan(&isOwner)
-		if err != nil {
-			isOwner = false
-		}
+		role, err := collectionRole(ws.db, collectionID, *userUUID)
+		isOwner = err == nil && role != ""
 	}
 
 	if !isOwner {
@@ -3128,7 +3283,7 @@ func (ws *WorkService) GetCollectionWorks(c *gin.Context) {
 
 	// If no user, only show non-draft, non-restricted works
 	if !hasUser {
-		baseQuery += " AND w.status != 'draft' AND w.restricted = false"
+		baseQuery += " AND w.status NOT IN ('draft', 'unlisted') AND w.restricted = false" + ws.loginGatedRatingsSQL(c.Request.Context())
 	}
 
 	baseQuery += " ORDER BY ci.added_at DESC LIMIT $2 OFFSET $3"
@@ -3223,7 +3378,7 @@ func (ws *WorkService) GetCollectionWorks(c *gin.Context) {
 		countQuery += " AND ci.is_approved = true"
 	}
 	if !hasUser {
-		countQuery += " AND w.status != 'draft' AND w.restricted = false"
+		countQuery += " AND w.status NOT IN ('draft', 'unlisted') AND w.restricted = false" + ws.loginGatedRatingsSQL(c.Request.Context())
 	}
 
 	var total int
@@ -3257,6 +3412,10 @@ func (ws *WorkService) CreateCollection(c *gin.Context) {
 		IsOpen      bool   `json:"is_open"`
 		IsModerated bool   `json:"is_moderated"`
 		IsAnonymous bool   `json:"is_anonymous"`
+		// Reveal settings for anonymous/unrevealed collections
+		IsUnrevealed    bool       `json:"is_unrevealed"`
+		RevealWorksAt   *time.Time `json:"reveal_works_at"`
+		RevealAuthorsAt *time.Time `json:"reveal_authors_at"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -3295,13 +3454,26 @@ func (ws *WorkService) CreateCollection(c *gin.Context) {
 		WorkCount:   0,
 		CreatedAt:   now,
 		UpdatedAt:   now,
+
+		IsUnrevealed:    req.IsUnrevealed,
+		RevealWorksAt:   req.RevealWorksAt,
+		RevealAuthorsAt: req.RevealAuthorsAt,
 	}
 
-	_, err = ws.db.Exec(`
-		INSERT INTO collections (id, name, title, description, user_id, is_open, is_moderated, is_anonymous, work_count, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO collections (id, name, title, description, user_id, is_open, is_moderated, is_anonymous,
+			is_unrevealed, reveal_works_at, reveal_authors_at, work_count, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
 		collection.ID, collection.Name, collection.Title, collection.Description, collection.UserID,
-		collection.IsOpen, collection.IsModerated, collection.IsAnonymous, collection.WorkCount,
+		collection.IsOpen, collection.IsModerated, collection.IsAnonymous,
+		collection.IsUnrevealed, collection.RevealWorksAt, collection.RevealAuthorsAt, collection.WorkCount,
 		collection.CreatedAt, collection.UpdatedAt)
 
 	if err != nil {
@@ -3309,6 +3481,20 @@ func (ws *WorkService) CreateCollection(c *gin.Context) {
 		return
 	}
 
+	// The creator is the collection's first owner
+	_, err = tx.Exec(`
+		INSERT INTO collection_maintainers (collection_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4)`, collection.ID, userUUID, models.CollectionRoleOwner, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create collection"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{"collection": collection})
 }
 
@@ -3319,12 +3505,6 @@ func (ws *WorkService) UpdateCollection(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	var req struct {
 		Name        *string `json:"name"`
 		Title       *string `json:"title"`
@@ -3332,6 +3512,10 @@ func (ws *WorkService) UpdateCollection(c *gin.Context) {
 		IsOpen      *bool   `json:"is_open"`
 		IsModerated *bool   `json:"is_moderated"`
 		IsAnonymous *bool   `json:"is_anonymous"`
+		// Reveal settings for anonymous/unrevealed collections
+		IsUnrevealed    *bool      `json:"is_unrevealed"`
+		RevealWorksAt   *time.Time `json:"reveal_works_at"`
+		RevealAuthorsAt *time.Time `json:"reveal_authors_at"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -3339,21 +3523,8 @@ func (ws *WorkService) UpdateCollection(c *gin.Context) {
 		return
 	}
 
-	// Verify user owns the collection
-	var ownerID uuid.UUID
-	err = ws.db.QueryRow("SELECT user_id FROM collections WHERE id = $1", collectionID).Scan(&ownerID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
-		return
-	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify collection ownership"})
-		return
-	}
-
-	userUUID, parseErr := uuid.Parse(userID.(string))
-	if parseErr != nil || ownerID != userUUID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own collections"})
+	// Settings belong to the collection's owners
+	if !ws.requireCollectionOwner(c, collectionID) {
 		return
 	}
 
@@ -3402,6 +3573,21 @@ func (ws *WorkService) UpdateCollection(c *gin.Context) {
 		args = append(args, *req.IsAnonymous)
 		argIndex++
 	}
+	if req.IsUnrevealed != nil {
+		updates = append(updates, fmt.Sprintf("is_unrevealed = $%d", argIndex))
+		args = append(args, *req.IsUnrevealed)
+		argIndex++
+	}
+	if req.RevealWorksAt != nil {
+		updates = append(updates, fmt.Sprintf("reveal_works_at = $%d", argIndex))
+		args = append(args, *req.RevealWorksAt)
+		argIndex++
+	}
+	if req.RevealAuthorsAt != nil {
+		updates = append(updates, fmt.Sprintf("reveal_authors_at = $%d", argIndex))
+		args = append(args, *req.RevealAuthorsAt)
+		argIndex++
+	}
 
 	if len(updates) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
@@ -3429,14 +3615,16 @@ func (ws *WorkService) UpdateCollection(c *gin.Context) {
 	var username string
 	err = ws.db.QueryRow(`
 		SELECT c.id, c.name, c.title, c.description, c.user_id, c.is_open, 
-			c.is_moderated, c.is_anonymous, c.work_count, c.created_at, c.updated_at,
-			u.username
+			c.is_moderated, c.is_anonymous, COALESCE(c.is_unrevealed, false), c.work_count,
+			c.reveal_works_at, c.reveal_authors_at, c.works_revealed_at, c.authors_revealed_at,
+			c.created_at, c.updated_at, u.username
 		FROM collections c
 		JOIN users u ON c.user_id = u.id
 		WHERE c.id = $1`, collectionID).Scan(
 		&collection.ID, &collection.Name, &collection.Title, &collection.Description,
 		&collection.UserID, &collection.IsOpen, &collection.IsModerated, &collection.IsAnonymous,
-		&collection.WorkCount, &collection.CreatedAt, &collection.UpdatedAt, &username)
+		&collection.IsUnrevealed, &collection.WorkCount, &collection.RevealWorksAt, &collection.RevealAuthorsAt,
+		&collection.WorksRevealedAt, &collection.AuthorsRevealedAt, &collection.CreatedAt, &collection.UpdatedAt, &username)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch updated collection"})
@@ -3456,28 +3644,7 @@ func (ws *WorkService) DeleteCollection(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Verify user owns the collection
-	var ownerID uuid.UUID
-	var workCount int
-	err = ws.db.QueryRow("SELECT user_id, work_count FROM collections WHERE id = $1", collectionID).Scan(&ownerID, &workCount)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
-		return
-	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify collection ownership"})
-		return
-	}
-
-	userUUID, parseErr := uuid.Parse(userID.(string))
-	if parseErr != nil || ownerID != userUUID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own collections"})
+	if !ws.requireCollectionOwner(c, collectionID) {
 		return
 	}
 
@@ -3488,6 +3655,12 @@ func (ws *WorkService) DeleteCollection(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
+	var workCount int
+	if err := tx.QueryRow("SELECT work_count FROM collections WHERE id = $1", collectionID).Scan(&workCount); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection"})
+		return
+	}
+
 	// Delete collection items first (foreign key constraint)
 	_, err = tx.Exec("DELETE FROM collection_items WHERE collection_id = $1", collectionID)
 	if err != nil {
@@ -3561,7 +3734,12 @@ func (ws *WorkService) AddWorkToCollection(c *gin.Context) {
 	var isWorkAuthor bool
 
 	// Check if user is collection maintainer
-	isMaintainer := collection.UserID == userUUID
+	role, err := collectionRole(ws.db, collectionID, userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check collection maintainers"})
+		return
+	}
+	isMaintainer := role != ""
 
 	// Check if user is work author
 	err = ws.db.QueryRow(`
@@ -3612,6 +3790,13 @@ func (ws *WorkService) AddWorkToCollection(c *gin.Context) {
 		return
 	}
 
+	// Hide the work or its creators if the collection hasn't revealed yet;
+	// the search outbox drops hidden works from the index
+	if _, err := markWorkForCollection(ws.db, collectionID, workID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work visibility"})
+		return
+	}
+
 	// Update collection work count if approved
 	if isApproved {
 		_, err = ws.db.Exec(`
@@ -3661,13 +3846,11 @@ func (ws *WorkService) RemoveWorkFromCollection(c *gin.Context) {
 
 	// Check if work is in collection and get details
 	var item models.CollectionItem
-	var collectionOwnerID uuid.UUID
 	err = ws.db.QueryRow(`
-		SELECT ci.id, ci.collection_id, ci.work_id, ci.added_by, ci.is_approved, ci.added_at, c.user_id
+		SELECT ci.id, ci.collection_id, ci.work_id, ci.added_by, ci.is_approved, ci.added_at
 		FROM collection_items ci
-		JOIN collections c ON ci.collection_id = c.id
 		WHERE ci.collection_id = $1 AND ci.work_id = $2`, collectionID, workID).Scan(
-		&item.ID, &item.CollectionID, &item.WorkID, &item.AddedBy, &item.IsApproved, &item.AddedAt, &collectionOwnerID)
+		&item.ID, &item.CollectionID, &item.WorkID, &item.AddedBy, &item.IsApproved, &item.AddedAt)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found in collection"})
@@ -3682,7 +3865,12 @@ func (ws *WorkService) RemoveWorkFromCollection(c *gin.Context) {
 	var canRemove bool
 
 	// Check if user is collection maintainer
-	isMaintainer := collectionOwnerID == userUUID
+	role, err := collectionRole(ws.db, collectionID, userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check collection maintainers"})
+		return
+	}
+	isMaintainer := role != ""
 
 	// Check if user is work author
 	var isWorkAuthor bool
@@ -3784,6 +3972,15 @@ func (ws *WorkService) GetUserWorks(c *gin.Context) {
 
 	offset := (page - 1) * limit
 
+	// Newest first; by cursor the list continues after the last work of the
+	// previous page
+	const cursorSort = "user_works:updated_at:desc"
+	after, cursorMode, err := pagination.FromQuery(c, cursorSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+
 	// Get works created by the user
 	baseQuery := `
 		SELECT w.id, w.title, w.summary, w.language, w.rating,
@@ -3795,16 +3992,30 @@ func (ws *WorkService) GetUserWorks(c *gin.Context) {
 		FROM works w
 		JOIN creatorships cr ON w.id = cr.creation_id AND cr.creation_type = 'Work'
 		JOIN pseuds p ON cr.pseud_id = p.id
-		WHERE p.user_id = $1 AND cr.approved = true`
+		WHERE p.user_id = $1 AND cr.approved = true AND w.deleted_at IS NULL`
 
 	// If not viewing own profile, only show published, non-restricted works
 	if !isOwnProfile {
 		baseQuery += " AND w.status = 'posted' AND w.restricted = false"
 	}
+	if viewerID == nil {
+		baseQuery += ws.loginGatedRatingsSQL(c.Request.Context())
+	}
 
-	baseQuery += " ORDER BY w.updated_at DESC LIMIT $2 OFFSET $3"
+	queryArgs := []interface{}{targetUserID}
+	if after != nil {
+		cond, condArgs := after.Condition("w.updated_at", "w.id", true, len(queryArgs)+1)
+		baseQuery += " AND " + cond
+		queryArgs = append(queryArgs, condArgs...)
+	}
+	fetch := limit
+	if cursorMode {
+		// One extra row tells whether there's a next page
+		fetch, offset = limit+1, 0
+	}
+	baseQuery += fmt.Sprintf(" ORDER BY w.updated_at DESC, w.id DESC LIMIT $%d OFFSET $%d", len(queryArgs)+1, len(queryArgs)+2)
 
-	rows, err := ws.db.Query(baseQuery, targetUserID, limit, offset)
+	rows, err := ws.db.Query(baseQuery, append(queryArgs, fetch, offset)...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user works"})
 		return
@@ -3864,18 +4075,37 @@ func (ws *WorkService) GetUserWorks(c *gin.Context) {
 		works = append(works, work)
 	}
 
+	if cursorMode {
+		next := ""
+		if len(works) > limit {
+			works = works[:limit]
+			last := works[limit-1]
+			next = pagination.Next(cursorSort, last.UpdatedAt, last.ID)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"works":      works,
+			"username":   ws.usernameOrUnknown(targetUserID),
+			"user_id":    targetUserID,
+			"pagination": pagination.Response(limit, next),
+		})
+		return
+	}
+
 	// Get total count for pagination
 	countQuery := `
 		SELECT COUNT(*) 
 		FROM works w
 		JOIN creatorships cr ON w.id = cr.creation_id AND cr.creation_type = 'Work'
 		JOIN pseuds p ON cr.pseud_id = p.id
-		WHERE p.user_id = $1 AND cr.approved = true`
+		WHERE p.user_id = $1 AND cr.approved = true AND w.deleted_at IS NULL`
 
 	args := []interface{}{targetUserID}
 	if !isOwnProfile {
 		countQuery += " AND w.status = 'posted' AND w.restricted = false"
 	}
+	if viewerID == nil {
+		countQuery += ws.loginGatedRatingsSQL(c.Request.Context())
+	}
 
 	var total int
 	err = ws.db.QueryRow(countQuery, args...).Scan(&total)
@@ -3883,16 +4113,9 @@ func (ws *WorkService) GetUserWorks(c *gin.Context) {
 		total = len(works) // Fallback
 	}
 
-	// Get user info
-	var username string
-	err = ws.db.QueryRow("SELECT username FROM users WHERE id = $1", targetUserID).Scan(&username)
-	if err != nil {
-		username = "Unknown User"
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"works":    works,
-		"username": username,
+		"username": ws.usernameOrUnknown(targetUserID),
 		"user_id":  targetUserID,
 		"pagination": gin.H{
 			"page":        page,
@@ -3903,6 +4126,15 @@ func (ws *WorkService) GetUserWorks(c *gin.Context) {
 	})
 }
 
+// usernameOrUnknown is a user's name for listing headers
+func (ws *WorkService) usernameOrUnknown(userID uuid.UUID) string {
+	var username string
+	if err := ws.db.QueryRow("SELECT username FROM users WHERE id = $1", userID).Scan(&username); err != nil {
+		return "Unknown User"
+	}
+	return username
+}
+
 func (ws *WorkService) GetUserSeries(c *gin.Context) {
 	userIDParam := c.Param("user_id")
 	targetUserID, err := uuid.Parse(userIDParam)
@@ -3960,7 +4192,7 @@ func (ws *WorkService) GetUserSeries(c *gin.Context) {
 			SELECT COALESCE(SUM(w.word_count), 0) 
 			FROM works w 
 			JOIN series_works sw ON w.id = sw.work_id
-			WHERE sw.series_id = $1 AND w.status != 'draft'`, s.ID).Scan(&s.WordCount)
+			WHERE sw.series_id = $1 AND w.status NOT IN ('draft', 'unlisted') AND w.deleted_at IS NULL`, s.ID).Scan(&s.WordCount)
 
 		series = append(series, s)
 	}
@@ -4003,10 +4235,11 @@ func (ws *WorkService) GetUserBookmarks(c *gin.Context) {
 
 	// Build query to get user's bookmarks
 	query := `
-		SELECT b.id, b.work_id, b.notes, b.tags, b.is_private, b.created_at, b.updated_at,
+		SELECT b.id, b.work_id, b.notes, b.tags, b.is_private, COALESCE(b.is_rec, false), b.created_at, b.updated_at,
 			   w.title, w.summary, w.rating, w.fandoms, w.characters, w.relationships, 
 			   w.freeform_tags, w.word_count, w.chapter_count, w.is_complete, w.status,
-			   w.published_at, w.updated_at as work_updated_at,
+			   w.published_at, w.updated_at as work_updated_at, w.category, w.warnings,
+			   COALESCE(w.language, '') as language,
 			   COALESCE(ws.hits, 0) as hits, COALESCE(ws.kudos, 0) as kudos,
 			   COALESCE(ws.comments, 0) as comments, COALESCE(ws.bookmarks, 0) as bookmarks
 		FROM bookmarks b
@@ -4020,13 +4253,16 @@ func (ws *WorkService) GetUserBookmarks(c *gin.Context) {
 	if viewerID == nil || *viewerID != targetUserID {
 		query += " AND b.is_private = false"
 	}
+	if c.Query("rec") == "true" {
+		query += " AND b.is_rec = true"
+	}
 
 	// Only show works the viewer can access
 	if viewerID != nil {
 		query += " AND can_user_view_work(w.id, $2)"
 		args = append(args, *viewerID)
 	} else {
-		query += " AND w.restricted = false AND w.status = 'posted'"
+		query += " AND w.restricted = false AND w.status = 'posted'" + ws.loginGatedRatingsSQL(c.Request.Context())
 	}
 
 	query += " ORDER BY b.created_at DESC"
@@ -4045,10 +4281,11 @@ func (ws *WorkService) GetUserBookmarks(c *gin.Context) {
 		var hits, kudos, comments, bookmarkCount int
 
 		err := rows.Scan(
-			&b.ID, &b.WorkID, &b.Notes, pq.Array(&b.Tags), &b.IsPrivate, &b.CreatedAt, &b.UpdatedAt,
+			&b.ID, &b.WorkID, &b.Notes, pq.Array(&b.Tags), &b.IsPrivate, &b.IsRec, &b.CreatedAt, &b.UpdatedAt,
 			&w.Title, &w.Summary, &w.Rating, pq.Array(&w.Fandoms), pq.Array(&w.Characters),
 			pq.Array(&w.Relationships), pq.Array(&w.FreeformTags), &w.WordCount, &w.ChapterCount,
 			&w.IsComplete, &w.Status, &w.PublishedAt, &w.UpdatedAt,
+			pq.Array(&w.Category), pq.Array(&w.Warnings), &w.Language,
 			&hits, &kudos, &comments, &bookmarkCount)
 
 		if err != nil {
@@ -4080,28 +4317,31 @@ func (ws *WorkService) GetUserBookmarks(c *gin.Context) {
 			"notes":      b.Notes,
 			"tags":       b.Tags,
 			"is_private": b.IsPrivate,
+			"is_rec":     b.IsRec,
 			"created_at": b.CreatedAt,
 			"updated_at": b.UpdatedAt,
 			"work": gin.H{
-				"id":            w.ID,
-				"title":         w.Title,
-				"summary":       w.Summary,
-				"rating":        w.Rating,
-				"fandoms":       w.Fandoms,
-				"characters":    w.Characters,
-				"relationships": w.Relationships,
-				"freeform_tags": w.FreeformTags,
-				"word_count":    w.WordCount,
-				"chapter_count": w.ChapterCount,
-				"is_complete":   w.IsComplete,
-				"status":        w.Status,
-				"published_at":  w.PublishedAt,
-				"updated_at":    w.UpdatedAt,
-				"hits":          w.Hits,
-				"kudos":         w.Kudos,
-				"comments":      w.Comments,
-				"bookmarks":     w.Bookmarks,
-				"authors":       authors,
+				"id":                        w.ID,
+				"title":                     w.Title,
+				"summary":                   w.Summary,
+				"rating":                    w.Rating,
+				"fandoms":                   w.Fandoms,
+				"characters":                w.Characters,
+				"relationships":             w.Relationships,
+				"freeform_tags":             w.FreeformTags,
+				"word_count":                w.WordCount,
+				"chapter_count":             w.ChapterCount,
+				"is_complete":               w.IsComplete,
+				"required_tags":             w.RequiredTags(),
+				"estimated_reading_minutes": w.EstimatedReadingMinutes(),
+				"status":                    w.Status,
+				"published_at":              w.PublishedAt,
+				"updated_at":                w.UpdatedAt,
+				"hits":                      w.Hits,
+				"kudos":                     w.Kudos,
+				"comments":                  w.Comments,
+				"bookmarks":                 w.Bookmarks,
+				"authors":                   authors,
 			},
 		})
 	}
@@ -4125,7 +4365,8 @@ func (ws *WorkService) CreateBookmark(c *gin.Context) {
 	var req struct {
 		Notes     string   `json:"notes"`
 		Tags      []string `json:"tags"`
-		IsPrivate bool     `json:"is_private"`
+		IsPrivate *bool    `json:"is_private"` // Defaults to the user's preference
+		IsRec     bool     `json:"is_rec"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -4140,6 +4381,13 @@ func (ws *WorkService) CreateBookmark(c *gin.Context) {
 		return
 	}
 
+	var isPrivate bool
+	if req.IsPrivate != nil {
+		isPrivate = *req.IsPrivate
+	} else {
+		isPrivate = ws.defaultBookmarkPrivate(c.Request.Context(), userUUID)
+	}
+
 	// Check if user can view this work
 	var canView bool
 	err = ws.db.QueryRow("SELECT can_user_view_work($1, $2)", workID, userUUID).Scan(&canView)
@@ -4166,16 +4414,17 @@ func (ws *WorkService) CreateBookmark(c *gin.Context) {
 		UserID:    userUUID,
 		Notes:     req.Notes,
 		Tags:      req.Tags,
-		IsPrivate: req.IsPrivate,
+		IsPrivate: isPrivate,
+		IsRec:     req.IsRec,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 
 	_, err = ws.db.Exec(`
-		INSERT INTO bookmarks (id, work_id, user_id, notes, tags, is_private, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		INSERT INTO bookmarks (id, work_id, user_id, notes, tags, is_private, is_rec, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
 		bookmark.ID, bookmark.WorkID, bookmark.UserID, bookmark.Notes,
-		pq.Array(bookmark.Tags), bookmark.IsPrivate, bookmark.CreatedAt, bookmark.UpdatedAt)
+		pq.Array(bookmark.Tags), bookmark.IsPrivate, bookmark.IsRec, bookmark.CreatedAt, bookmark.UpdatedAt)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bookmark"})
@@ -4194,6 +4443,8 @@ func (ws *WorkService) CreateBookmark(c *gin.Context) {
 		return
 	}
 
+	go ws.sendBookmarkSearchEvent("bookmark_created", bookmark.ID, workID, bookmark.IsPrivate, bookmark.CreatedAt)
+
 	c.JSON(http.StatusCreated, gin.H{"bookmark": bookmark})
 }
 
@@ -4242,6 +4493,7 @@ func (ws *WorkService) UpdateBookmark(c *gin.Context) {
 		Notes     *string  `json:"notes"`
 		Tags      []string `json:"tags"`
 		IsPrivate *bool    `json:"is_private"`
+		IsRec     *bool    `json:"is_rec"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -4259,12 +4511,13 @@ func (ws *WorkService) UpdateBookmark(c *gin.Context) {
 	// Check if bookmark exists and belongs to user
 	var existingBookmark models.Bookmark
 	err = ws.db.QueryRow(`
-		SELECT id, work_id, user_id, notes, tags, is_private, created_at, updated_at
+		SELECT id, work_id, user_id, COALESCE(notes, ''), tags, is_private, COALESCE(is_rec, false),
+			created_at, updated_at
 		FROM bookmarks WHERE id = $1 AND user_id = $2`,
 		bookmarkID, userUUID).Scan(
 		&existingBookmark.ID, &existingBookmark.WorkID, &existingBookmark.UserID,
 		&existingBookmark.Notes, pq.Array(&existingBookmark.Tags), &existingBookmark.IsPrivate,
-		&existingBookmark.CreatedAt, &existingBookmark.UpdatedAt)
+		&existingBookmark.IsRec, &existingBookmark.CreatedAt, &existingBookmark.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -4285,20 +4538,27 @@ func (ws *WorkService) UpdateBookmark(c *gin.Context) {
 	if req.IsPrivate != nil {
 		existingBookmark.IsPrivate = *req.IsPrivate
 	}
+	if req.IsRec != nil {
+		existingBookmark.IsRec = *req.IsRec
+	}
 	existingBookmark.UpdatedAt = time.Now()
 
 	// Update bookmark in database
 	_, err = ws.db.Exec(`
-		UPDATE bookmarks SET notes = $1, tags = $2, is_private = $3, updated_at = $4
-		WHERE id = $5`,
+		UPDATE bookmarks SET notes = $1, tags = $2, is_private = $3, is_rec = $4, updated_at = $5
+		WHERE id = $6`,
 		existingBookmark.Notes, pq.Array(existingBookmark.Tags),
-		existingBookmark.IsPrivate, existingBookmark.UpdatedAt, bookmarkID)
+		existingBookmark.IsPrivate, existingBookmark.IsRec, existingBookmark.UpdatedAt, bookmarkID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark"})
 		return
 	}
 
+	// Search indexes public bookmarks' notes and tags as well as counting them
+	go ws.sendBookmarkSearchEvent("bookmark_updated", bookmarkID, existingBookmark.WorkID,
+		existingBookmark.IsPrivate, existingBookmark.CreatedAt)
+
 	c.JSON(http.StatusOK, gin.H{"bookmark": existingBookmark})
 }
 
@@ -4356,6 +4616,8 @@ func (ws *WorkService) DeleteBookmark(c *gin.Context) {
 		log.Printf("Failed to update bookmark count for work %s: %v", workID, err)
 	}
 
+	go ws.sendBookmarkSearchEvent("bookmark_deleted", bookmarkID, workID, false, time.Time{})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Bookmark deleted successfully"})
 }
 
@@ -4387,12 +4649,22 @@ func (ws *WorkService) GetMyBookmarks(c *gin.Context) {
 	}
 	offset := (page - 1) * limit
 
+	// Newest first; by cursor the list continues after the last bookmark of
+	// the previous page
+	const cursorSort = "bookmarks:created_at:desc"
+	after, cursorMode, err := pagination.FromQuery(c, cursorSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+
 	// Build query with optional filters
 	baseQuery := `
-		SELECT b.id, b.work_id, b.notes, b.tags, b.is_private, b.created_at, b.updated_at,
+		SELECT b.id, b.work_id, b.notes, b.tags, b.is_private, COALESCE(b.is_rec, false), b.created_at, b.updated_at,
 			   w.title, w.summary, w.rating, w.fandoms, w.characters, w.relationships, 
 			   w.freeform_tags, w.word_count, w.chapter_count, w.is_complete, w.status,
-			   w.published_at, w.updated_at as work_updated_at,
+			   w.published_at, w.updated_at as work_updated_at, w.category, w.warnings,
+			   COALESCE(w.language, '') as language,
 			   COALESCE(ws.hits, 0) as hits, COALESCE(ws.kudos, 0) as kudos,
 			   COALESCE(ws.comments, 0) as comments, COALESCE(ws.bookmarks, 0) as bookmarks
 		FROM bookmarks b
@@ -4403,6 +4675,11 @@ func (ws *WorkService) GetMyBookmarks(c *gin.Context) {
 	args := []interface{}{userUUID}
 	argCount := 1
 
+	// Only recs
+	if c.Query("rec") == "true" {
+		baseQuery += " AND b.is_rec = true"
+	}
+
 	// Add tag filter
 	if tag != "" {
 		argCount++
@@ -4418,22 +4695,34 @@ func (ws *WorkService) GetMyBookmarks(c *gin.Context) {
 	}
 
 	// Count total bookmarks for pagination
-	countQuery := strings.Replace(baseQuery,
-		"SELECT b.id, b.work_id, b.notes, b.tags, b.is_private, b.created_at, b.updated_at, w.title, w.summary, w.rating, w.fandoms, w.characters, w.relationships, w.freeform_tags, w.word_count, w.chapter_count, w.is_complete, w.status, w.published_at, w.updated_at as work_updated_at, COALESCE(ws.hits, 0) as hits, COALESCE(ws.kudos, 0) as kudos, COALESCE(ws.comments, 0) as comments, COALESCE(ws.bookmarks, 0) as bookmarks",
-		"SELECT COUNT(*)", 1)
+	countQuery := "SELECT COUNT(*)" + baseQuery[strings.Index(baseQuery, "FROM bookmarks b")-1:]
 
+	// Cursor pages skip the count, which costs as much as a deep offset
 	var total int
-	err := ws.db.QueryRow(countQuery, args...).Scan(&total)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count bookmarks"})
-		return
+	if !cursorMode {
+		if err := ws.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count bookmarks"})
+			return
+		}
+	}
+
+	if after != nil {
+		cond, condArgs := after.Condition("b.created_at", "b.id", true, argCount+1)
+		baseQuery += " AND " + cond
+		args = append(args, condArgs...)
+		argCount += len(condArgs)
+	}
+	fetch := limit
+	if cursorMode {
+		// One extra row tells whether there's a next page
+		fetch, offset = limit+1, 0
 	}
 
 	// Add ordering and pagination
-	baseQuery += " ORDER BY b.created_at DESC"
+	baseQuery += " ORDER BY b.created_at DESC, b.id DESC"
 	argCount++
 	baseQuery += fmt.Sprintf(" LIMIT $%d", argCount)
-	args = append(args, limit)
+	args = append(args, fetch)
 	argCount++
 	baseQuery += fmt.Sprintf(" OFFSET $%d", argCount)
 	args = append(args, offset)
@@ -4446,16 +4735,18 @@ func (ws *WorkService) GetMyBookmarks(c *gin.Context) {
 	defer rows.Close()
 
 	var bookmarks []gin.H
+	var positions []pagination.Keyset
 	for rows.Next() {
 		var b models.Bookmark
 		var w models.Work
 		var hits, kudos, comments, bookmarkCount int
 
 		err := rows.Scan(
-			&b.ID, &b.WorkID, &b.Notes, pq.Array(&b.Tags), &b.IsPrivate, &b.CreatedAt, &b.UpdatedAt,
+			&b.ID, &b.WorkID, &b.Notes, pq.Array(&b.Tags), &b.IsPrivate, &b.IsRec, &b.CreatedAt, &b.UpdatedAt,
 			&w.Title, &w.Summary, &w.Rating, pq.Array(&w.Fandoms), pq.Array(&w.Characters),
 			pq.Array(&w.Relationships), pq.Array(&w.FreeformTags), &w.WordCount, &w.ChapterCount,
 			&w.IsComplete, &w.Status, &w.PublishedAt, &w.UpdatedAt,
+			pq.Array(&w.Category), pq.Array(&w.Warnings), &w.Language,
 			&hits, &kudos, &comments, &bookmarkCount)
 
 		if err != nil {
@@ -4481,38 +4772,55 @@ func (ws *WorkService) GetMyBookmarks(c *gin.Context) {
 			}
 		}
 
+		positions = append(positions, pagination.Keyset{Time: b.CreatedAt, ID: b.ID})
 		bookmarks = append(bookmarks, gin.H{
 			"id":         b.ID,
 			"work_id":    b.WorkID,
 			"notes":      b.Notes,
 			"tags":       b.Tags,
 			"is_private": b.IsPrivate,
+			"is_rec":     b.IsRec,
 			"created_at": b.CreatedAt,
 			"updated_at": b.UpdatedAt,
 			"work": gin.H{
-				"id":            w.ID,
-				"title":         w.Title,
-				"summary":       w.Summary,
-				"rating":        w.Rating,
-				"fandoms":       w.Fandoms,
-				"characters":    w.Characters,
-				"relationships": w.Relationships,
-				"freeform_tags": w.FreeformTags,
-				"word_count":    w.WordCount,
-				"chapter_count": w.ChapterCount,
-				"is_complete":   w.IsComplete,
-				"status":        w.Status,
-				"published_at":  w.PublishedAt,
-				"updated_at":    w.UpdatedAt,
-				"hits":          w.Hits,
-				"kudos":         w.Kudos,
-				"comments":      w.Comments,
-				"bookmarks":     w.Bookmarks,
-				"authors":       authors,
+				"id":                        w.ID,
+				"title":                     w.Title,
+				"summary":                   w.Summary,
+				"rating":                    w.Rating,
+				"fandoms":                   w.Fandoms,
+				"characters":                w.Characters,
+				"relationships":             w.Relationships,
+				"freeform_tags":             w.FreeformTags,
+				"word_count":                w.WordCount,
+				"chapter_count":             w.ChapterCount,
+				"is_complete":               w.IsComplete,
+				"required_tags":             w.RequiredTags(),
+				"estimated_reading_minutes": w.EstimatedReadingMinutes(),
+				"status":                    w.Status,
+				"published_at":              w.PublishedAt,
+				"updated_at":                w.UpdatedAt,
+				"hits":                      w.Hits,
+				"kudos":                     w.Kudos,
+				"comments":                  w.Comments,
+				"bookmarks":                 w.Bookmarks,
+				"authors":                   authors,
 			},
 		})
 	}
 
+	if cursorMode {
+		next := ""
+		if len(bookmarks) > limit {
+			bookmarks = bookmarks[:limit]
+			next = pagination.Encode(cursorSort, positions[limit-1])
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"bookmarks":  bookmarks,
+			"pagination": pagination.Response(limit, next),
+		})
+		return
+	}
+
 	totalPages := (total + limit - 1) / limit
 
 	c.JSON(http.StatusOK, gin.H{
@@ -4562,6 +4870,7 @@ func (ws *WorkService) GetMyWorks(c *gin.Context) {
 		WHERE c.creation_type = 'Work' 
 		AND c.approved = true
 		AND p.user_id = $1
+		AND w.deleted_at IS NULL
 		ORDER BY w.updated_at DESC
 		LIMIT $2 OFFSET $3`
 
@@ -4737,14 +5046,15 @@ func (ws *WorkService) GetMyCollections(c *gin.Context) {
 
 	offset := (page - 1) * limit
 
-	// Get user's collections (ones they maintain)
+	// Get user's collections (ones they own or moderate)
 	query := `
 		SELECT c.id, c.name, c.title, c.description, c.user_id, c.is_open,
 			c.is_moderated, c.is_anonymous, c.work_count, c.created_at, c.updated_at,
-			u.username
+			u.username, CASE WHEN c.user_id = $1 THEN 'owner' ELSE cm.role END
 		FROM collections c
 		JOIN users u ON c.user_id = u.id
-		WHERE c.user_id = $1
+		LEFT JOIN collection_maintainers cm ON cm.collection_id = c.id AND cm.user_id = $1
+		WHERE c.user_id = $1 OR cm.user_id IS NOT NULL
 		ORDER BY c.updated_at DESC
 		LIMIT $2 OFFSET $3`
 
@@ -4758,12 +5068,12 @@ func (ws *WorkService) GetMyCollections(c *gin.Context) {
 	var collections []gin.H
 	for rows.Next() {
 		var collection models.Collection
-		var username string
+		var username, role string
 		err := rows.Scan(
 			&collection.ID, &collection.Name, &collection.Title, &collection.Description,
 			&collection.UserID, &collection.IsOpen, &collection.IsModerated,
 			&collection.IsAnonymous, &collection.WorkCount, &collection.CreatedAt,
-			&collection.UpdatedAt, &username)
+			&collection.UpdatedAt, &username, &role)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan collection"})
 			return
@@ -4783,13 +5093,18 @@ func (ws *WorkService) GetMyCollections(c *gin.Context) {
 		collections = append(collections, gin.H{
 			"collection":    collection,
 			"maintainer":    username,
+			"role":          role,
 			"pending_count": pendingCount,
 		})
 	}
 
 	// Get total count for pagination
 	var total int
-	err = ws.db.QueryRow("SELECT COUNT(*) FROM collections WHERE user_id = $1", userUUID).Scan(&total)
+	err = ws.db.QueryRow(`
+		SELECT COUNT(*) FROM collections c
+		WHERE c.user_id = $1
+			OR EXISTS (SELECT 1 FROM collection_maintainers cm WHERE cm.collection_id = c.id AND cm.user_id = $1)`,
+		userUUID).Scan(&total)
 	if err != nil {
 		total = len(collections) // Fallback
 	}
@@ -4970,196 +5285,6 @@ func (ws *WorkService) GetMyComments(c *gin.Context) {
 	})
 }
 
-func (ws *WorkService) GetMyStats(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	userUUID, err := uuid.Parse(userID.(string))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
-	}
-
-	// Get comprehensive user statistics
-	var stats struct {
-		UserID uuid.UUID `json:"user_id"`
-
-		// Work counts
-		TotalWorks     int `json:"total_works"`
-		PublishedWorks int `json:"published_works"`
-		DraftWorks     int `json:"draft_works"`
-		CompleteWorks  int `json:"complete_works"`
-
-		// Content statistics
-		TotalWordCount   int `json:"total_word_count"`
-		TotalChapters    int `json:"total_chapters"`
-		AverageWordCount int `json:"average_word_count"`
-
-		// Engagement statistics
-		TotalHits          int `json:"total_hits"`
-		TotalKudos         int `json:"total_kudos"`
-		TotalComments      int `json:"total_comments"`
-		TotalBookmarks     int `json:"total_bookmarks"`
-		TotalSubscriptions int `json:"total_subscriptions"`
-
-		// Series and collection statistics
-		TotalSeries      int `json:"total_series"`
-		TotalCollections int `json:"total_collections"`
-
-		// Activity metrics
-		FirstPublished *time.Time `json:"first_published"`
-		LastPublished  *time.Time `json:"last_published"`
-		LastUpdated    *time.Time `json:"last_updated"`
-
-		// Top performing works
-		TopWorks []struct {
-			ID        uuid.UUID `json:"id"`
-			Title     string    `json:"title"`
-			Hits      int       `json:"hits"`
-			Kudos     int       `json:"kudos"`
-			Comments  int       `json:"comments"`
-			Bookmarks int       `json:"bookmarks"`
-		} `json:"top_works"`
-	}
-
-	stats.UserID = userUUID
-
-	// Get work counts and content statistics
-	err = ws.db.QueryRow(`
-		SELECT 
-			COUNT(*) as total_works,
-			COUNT(CASE WHEN w.status = 'posted' THEN 1 END) as published_works,
-			COUNT(CASE WHEN w.status = 'draft' THEN 1 END) as draft_works,
-			COUNT(CASE WHEN w.is_complete = true THEN 1 END) as complete_works,
-			COALESCE(SUM(w.word_count), 0) as total_word_count,
-			COALESCE(SUM(w.chapter_count), 0) as total_chapters,
-			COALESCE(AVG(w.word_count)::int, 0) as average_word_count
-		FROM works w
-		JOIN creatorships cr ON w.id = cr.creation_id AND cr.creation_type = 'Work'
-		JOIN pseuds p ON cr.pseud_id = p.id
-		WHERE p.user_id = $1 AND cr.approved = true`, userUUID).Scan(
-		&stats.TotalWorks, &stats.PublishedWorks, &stats.DraftWorks,
-		&stats.CompleteWorks, &stats.TotalWordCount, &stats.TotalChapters,
-		&stats.AverageWordCount)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work statistics"})
-		return
-	}
-
-	// Get engagement statistics
-	err = ws.db.QueryRow(`
-		SELECT 
-			COALESCE(SUM(w.hit_count), 0) as total_hits,
-			COALESCE(SUM(w.kudos_count), 0) as total_kudos,
-			COALESCE(SUM(w.comment_count), 0) as total_comments,
-			COALESCE(SUM(w.bookmark_count), 0) as total_bookmarks
-		FROM works w
-		JOIN creatorships cr ON w.id = cr.creation_id AND cr.creation_type = 'Work'
-		JOIN pseuds p ON cr.pseud_id = p.id
-		WHERE p.user_id = $1 AND cr.approved = true`, userUUID).Scan(
-		&stats.TotalHits, &stats.TotalKudos, &stats.TotalComments, &stats.TotalBookmarks)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch engagement statistics"})
-		return
-	}
-
-	// Get subscription count
-	err = ws.db.QueryRow(`
-		SELECT COUNT(*)
-		FROM subscriptions s
-		JOIN works w ON s.subscribable_id = w.id AND s.subscribable_type = 'Work'
-		JOIN creatorships cr ON w.id = cr.creation_id AND cr.creation_type = 'Work'
-		JOIN pseuds p ON cr.pseud_id = p.id
-		WHERE p.user_id = $1 AND cr.approved = true`, userUUID).Scan(&stats.TotalSubscriptions)
-
-	if err != nil {
-		stats.TotalSubscriptions = 0
-	}
-
-	// Get series count
-	err = ws.db.QueryRow(`
-		SELECT COUNT(*) FROM series WHERE user_id = $1`, userUUID).Scan(&stats.TotalSeries)
-
-	if err != nil {
-		stats.TotalSeries = 0
-	}
-
-	// Get collection count (collections created by user)
-	err = ws.db.QueryRow(`
-		SELECT COUNT(*) FROM collections WHERE user_id = $1`, userUUID).Scan(&stats.TotalCollections)
-
-	if err != nil {
-		stats.TotalCollections = 0
-	}
-
-	// Get activity metrics
-	var firstPublished, lastPublished, lastUpdated sql.NullTime
-	err = ws.db.QueryRow(`
-		SELECT 
-			MIN(w.published_at) as first_published,
-			MAX(w.published_at) as last_published,
-			MAX(w.updated_at) as last_updated
-		FROM works w
-		JOIN creatorships cr ON w.id = cr.creation_id AND cr.creation_type = 'Work'
-		JOIN pseuds p ON cr.pseud_id = p.id
-		WHERE p.user_id = $1 AND cr.approved = true AND w.status = 'posted'`, userUUID).Scan(
-		&firstPublished, &lastPublished, &lastUpdated)
-
-	if err == nil {
-		if firstPublished.Valid {
-			stats.FirstPublished = &firstPublished.Time
-		}
-		if lastPublished.Valid {
-			stats.LastPublished = &lastPublished.Time
-		}
-		if lastUpdated.Valid {
-			stats.LastUpdated = &lastUpdated.Time
-		}
-	}
-
-	// Get top 5 performing works by total engagement (hits + kudos + comments + bookmarks)
-	topWorksQuery := `
-		SELECT w.id, w.title, 
-			COALESCE(w.hit_count, 0) as hits,
-			COALESCE(w.kudos_count, 0) as kudos,
-			COALESCE(w.comment_count, 0) as comments,
-			COALESCE(w.bookmark_count, 0) as bookmarks
-		FROM works w
-		JOIN creatorships cr ON w.id = cr.creation_id AND cr.creation_type = 'Work'
-		JOIN pseuds p ON cr.pseud_id = p.id
-		WHERE p.user_id = $1 AND cr.approved = true AND w.status = 'posted'
-		ORDER BY (COALESCE(w.hit_count, 0) + COALESCE(w.kudos_count, 0) + 
-		         COALESCE(w.comment_count, 0) + COALESCE(w.bookmark_count, 0)) DESC
-		LIMIT 5`
-
-	rows, err := ws.db.Query(topWorksQuery, userUUID)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var topWork struct {
-				ID        uuid.UUID `json:"id"`
-				Title     string    `json:"title"`
-				Hits      int       `json:"hits"`
-				Kudos     int       `json:"kudos"`
-				Comments  int       `json:"comments"`
-				Bookmarks int       `json:"bookmarks"`
-			}
-			err := rows.Scan(&topWork.ID, &topWork.Title, &topWork.Hits,
-				&topWork.Kudos, &topWork.Comments, &topWork.Bookmarks)
-			if err == nil {
-				stats.TopWorks = append(stats.TopWorks, topWork)
-			}
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{"stats": stats})
-}
-
 // Privacy and moderation handlers
 
 func (ws *WorkService) ModerateComment(c *gin.Context) {
@@ -5232,14 +5357,14 @@ func (ws *WorkService) BlockUser(c *gin.Context) {
 		return
 	}
 
-	blockerID, exists := c.Get("user_id")
-	if !exists {
+	blockerID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
 	var req struct {
-		BlockType string `json:"block_type" validate:"required,oneof=full comments works"`
+		BlockType string `json:"block_type"`
 		Reason    string `json:"reason"`
 	}
 
@@ -5247,9 +5372,16 @@ func (ws *WorkService) BlockUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
 		return
 	}
+	if req.BlockType == "" {
+		req.BlockType = "full"
+	}
+	if !validBlockTypes[req.BlockType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "block_type must be full, comments or works"})
+		return
+	}
 
 	// Can't block yourself
-	if blockerID.(uuid.UUID) == blockedUserID {
+	if blockerID == blockedUserID {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot block yourself"})
 		return
 	}
@@ -5299,94 +5431,6 @@ func (ws *WorkService) UnblockUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User unblocked successfully"})
 }
 
-func (ws *WorkService) ReportComment(c *gin.Context) {
-	commentID, err := uuid.Parse(c.Param("comment_id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
-		return
-	}
-
-	var req struct {
-		Reason      string `json:"reason" validate:"required,oneof=spam harassment off_topic inappropriate hate_speech doxxing other"`
-		Description string `json:"description"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
-		return
-	}
-
-	// Get reporter info
-	reporterID, hasUser := c.Get("user_id")
-	var reporterUUID *uuid.UUID
-	if hasUser {
-		reporterVal := reporterID.(uuid.UUID)
-		reporterUUID = &reporterVal
-	}
-
-	clientIP := c.ClientIP()
-
-	// Create report
-	reportID := uuid.New()
-	now := time.Now()
-
-	_, err = ws.db.Exec(`
-		INSERT INTO comment_reports (id, comment_id, reporter_id, reporter_ip, reason, description, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		reportID, commentID, reporterUUID, clientIP, req.Reason, req.Description, now)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Report submitted successfully"})
-}
-
-func (ws *WorkService) ReportWork(c *gin.Context) {
-	workID, err := uuid.Parse(c.Param("work_id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
-		return
-	}
-
-	var req struct {
-		Reason      string `json:"reason" validate:"required,oneof=copyright plagiarism harassment inappropriate_content wrong_rating missing_warnings spam other"`
-		Description string `json:"description"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
-		return
-	}
-
-	// Get reporter info
-	reporterID, hasUser := c.Get("user_id")
-	var reporterUUID *uuid.UUID
-	if hasUser {
-		reporterVal := reporterID.(uuid.UUID)
-		reporterUUID = &reporterVal
-	}
-
-	clientIP := c.ClientIP()
-
-	// Create report
-	reportID := uuid.New()
-	now := time.Now()
-
-	_, err = ws.db.Exec(`
-		INSERT INTO work_reports (id, work_id, reporter_id, reporter_ip, reason, description, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		reportID, workID, reporterUUID, clientIP, req.Reason, req.Description, now)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Report submitted successfully"})
-}
-
 // User muting handlers (matching AO3's implementation)
 
 func (ws *WorkService) MuteUser(c *gin.Context) {
@@ -5412,7 +5456,7 @@ func (ws *WorkService) MuteUser(c *gin.Context) {
 	}
 
 	// Can't mute yourself
-	if muterID.(uuid.UUID) == mutedUserID {
+	if c.GetString("user_id") == mutedUserID.String() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot mute yourself"})
 		return
 	}
@@ -5425,8 +5469,7 @@ func (ws *WorkService) MuteUser(c *gin.Context) {
 		return
 	}
 
-	maxMutes := 100 // Similar to AO3's limits
-	if muteCount >= maxMutes {
+	if muteCount >= maxMutedUsers {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Maximum muted users limit reached"})
 		return
 	}
@@ -5446,6 +5489,7 @@ func (ws *WorkService) MuteUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mute user"})
 		return
 	}
+	ws.invalidateMutes(c)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User muted successfully"})
 }
@@ -5472,6 +5516,7 @@ func (ws *WorkService) UnmuteUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unmute user"})
 		return
 	}
+	ws.invalidateMutes(c)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User unmuted successfully"})
 }
@@ -5671,6 +5716,9 @@ func (ws *WorkService) GiftWork(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only gift works you authored"})
 		return
 	}
+	if gifterID, err := uuid.Parse(c.GetString("user_id")); err == nil && ws.rejectBlockedGift(c, gifterID, req.PseudID) {
+		return
+	}
 
 	// Create gift
 	giftID := uuid.New()
@@ -5755,36 +5803,6 @@ func (ws *WorkService) GetWorkGifts(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"gifts": gifts})
 }
 
-// Work orphaning
-func (ws *WorkService) OrphanWork(c *gin.Context) {
-	workID, err := uuid.Parse(c.Param("work_id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
-		return
-	}
-
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Use database function to orphan the work
-	var success bool
-	err = ws.db.QueryRow("SELECT orphan_work($1, $2)", workID, userID).Scan(&success)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to orphan work"})
-		return
-	}
-
-	if !success {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not an author of this work"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Work orphaned successfully"})
-}
-
 // Get work authors (respecting anonymity)
 func (ws *WorkService) GetWorkAuthors(c *gin.Context) {
 	workID, err := uuid.Parse(c.Param("work_id"))
@@ -5878,6 +5896,29 @@ func (ws *WorkService) AdminListWorks(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"works": []gin.H{}})
 }
 
+// hasStaffRole reports whether the user currently holds any of roles, or
+// admin, in user_roles, where role grants are recorded
+func (ws *WorkService) hasStaffRole(ctx context.Context, userID interface{}, roles ...string) (bool, error) {
+	rows, err := ws.db.QueryContext(ctx, "SELECT role FROM user_roles WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var held []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return false, err
+		}
+		held = append(held, role)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return middleware.HasAnyRole(held, roles...), nil
+}
+
 func (ws *WorkService) AdminUpdateWorkStatus(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -5886,11 +5927,8 @@ func (ws *WorkService) AdminUpdateWorkStatus(c *gin.Context) {
 	}
 
 	// Check if user has moderator or admin privileges
-	var role string
-	err := ws.db.QueryRow(`
-		SELECT COALESCE(role, 'user') FROM users WHERE id = $1`, userID).Scan(&role)
-
-	if err != nil || (role != "moderator" && role != "admin") {
+	isStaff, err := ws.hasStaffRole(c.Request.Context(), userID, "moderator")
+	if err != nil || !isStaff {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Moderator or admin access required"})
 		return
 	}
@@ -5969,7 +6007,7 @@ func (ws *WorkService) AdminUpdateWorkStatus(c *gin.Context) {
 	now := time.Now()
 	_, err = tx.Exec(`
 		UPDATE works 
-		SET status = $1, updated_at = $2
+		SET status = $1, updated_at = $2, version = version + 1
 		WHERE id = $3`, req.Status, now, workID)
 
 	if err != nil {
@@ -5991,6 +6029,14 @@ func (ws *WorkService) AdminUpdateWorkStatus(c *gin.Context) {
 		return
 	}
 
+	err = audit.RecordRequest(c, tx, auditService, audit.Entry{
+		Action: "work.status_change", TargetType: "work", TargetID: workID.String(), Reason: req.Reason,
+	}, gin.H{"status": currentStatus}, gin.H{"status": req.Status})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit entry"})
+		return
+	}
+
 	// Send notification to work author (if not deleted)
 	if req.Status != "deleted" {
 		notificationID := uuid.New()
@@ -6033,11 +6079,8 @@ func (ws *WorkService) AdminDeleteWork(c *gin.Context) {
 	}
 
 	// Check if user has admin privileges (only admins can permanently delete)
-	var role string
-	err := ws.db.QueryRow(`
-		SELECT COALESCE(role, 'user') FROM users WHERE id = $1`, userID).Scan(&role)
-
-	if err != nil || role != "admin" {
+	isAdmin, err := ws.hasStaffRole(c.Request.Context(), userID, middleware.AdminRole)
+	if err != nil || !isAdmin {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required for permanent deletion"})
 		return
 	}
@@ -6103,6 +6146,14 @@ func (ws *WorkService) AdminDeleteWork(c *gin.Context) {
 		return
 	}
 
+	err = audit.RecordRequest(c, tx, auditService, audit.Entry{
+		Action: "work.delete", TargetType: "work", TargetID: workID.String(), Reason: req.Reason,
+	}, gin.H{"title": workTitle, "user_id": authorID, "status": workStatus}, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit entry"})
+		return
+	}
+
 	// Delete related data in correct order (respecting foreign key constraints)
 	// Only include tables that actually exist in the current schema
 	deletionOrder := []string{
@@ -6162,11 +6213,8 @@ func (ws *WorkService) AdminDeleteWork(c *gin.Context) {
 		return
 	}
 
-	// Clear any cached data (if Redis is available)
-	if ws.redis != nil {
-		cacheKey := fmt.Sprintf("work:%s", workID)
-		ws.redis.Del(c.Request.Context(), cacheKey)
-	}
+	// Clear everything cached from the work
+	ws.InvalidateWorkCache(workID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":         "Work permanently deleted",
@@ -6186,11 +6234,8 @@ func (ws *WorkService) AdminListComments(c *gin.Context) {
 	}
 
 	// Check if user has moderator or admin privileges
-	var role string
-	err := ws.db.QueryRow(`
-		SELECT COALESCE(role, 'user') FROM users WHERE id = $1`, userID).Scan(&role)
-
-	if err != nil || (role != "moderator" && role != "admin") {
+	isStaff, err := ws.hasStaffRole(c.Request.Context(), userID, "moderator")
+	if err != nil || !isStaff {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Moderator or admin access required"})
 		return
 	}
@@ -6433,11 +6478,8 @@ func (ws *WorkService) AdminUpdateCommentStatus(c *gin.Context) {
 	}
 
 	// Check if user has moderator or admin privileges
-	var role string
-	err := ws.db.QueryRow(`
-		SELECT COALESCE(role, 'user') FROM users WHERE id = $1`, userID).Scan(&role)
-
-	if err != nil || (role != "moderator" && role != "admin") {
+	isStaff, err := ws.hasStaffRole(c.Request.Context(), userID, "moderator")
+	if err != nil || !isStaff {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Moderator or admin access required"})
 		return
 	}
@@ -6514,6 +6556,14 @@ func (ws *WorkService) AdminUpdateCommentStatus(c *gin.Context) {
 		return
 	}
 
+	err = audit.RecordRequest(c, tx, auditService, audit.Entry{
+		Action: "comment.status_change", TargetType: "comment", TargetID: commentID.String(), Reason: req.Reason,
+	}, gin.H{"status": currentStatus}, gin.H{"status": req.Status})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit entry"})
+		return
+	}
+
 	// Resolve pending reports if requested
 	var resolvedReports int
 	if req.ResolveReports {
@@ -6592,11 +6642,8 @@ func (ws *WorkService) AdminDeleteComment(c *gin.Context) {
 	}
 
 	// Check if user has moderator or admin privileges
-	var role string
-	err := ws.db.QueryRow(`
-		SELECT COALESCE(role, 'user') FROM users WHERE id = $1`, userID).Scan(&role)
-
-	if err != nil || (role != "moderator" && role != "admin") {
+	isStaff, err := ws.hasStaffRole(c.Request.Context(), userID, "moderator")
+	if err != nil || !isStaff {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Moderator or admin access required"})
 		return
 	}
@@ -6712,6 +6759,14 @@ func (ws *WorkService) AdminDeleteComment(c *gin.Context) {
 		}
 	}
 
+	err = audit.RecordRequest(c, tx, auditService, audit.Entry{
+		Action: "comment.delete", TargetType: "comment", TargetID: commentID.String(), Reason: req.Reason,
+	}, gin.H{"user_id": authorID, "work_id": workID, "content": content, "replies_deleted": len(deletedCommentIDs) - 1}, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit entry"})
+		return
+	}
+
 	// Delete all comments (children first, then parent)
 	for i := len(deletedCommentIDs) - 1; i >= 0; i-- {
 		delCommentID := deletedCommentIDs[i]
@@ -6798,11 +6853,8 @@ func (ws *WorkService) AdminGetReports(c *gin.Context) {
 	}
 
 	// Check if user has moderator or admin privileges
-	var role string
-	err := ws.db.QueryRow(`
-		SELECT COALESCE(role, 'user') FROM users WHERE id = $1`, userID).Scan(&role)
-
-	if err != nil || (role != "moderator" && role != "admin") {
+	isStaff, err := ws.hasStaffRole(c.Request.Context(), userID, "moderator")
+	if err != nil || !isStaff {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Moderator or admin access required"})
 		return
 	}
@@ -7571,29 +7623,3 @@ func (ws *WorkService) DeleteSubscription(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Subscription deleted successfully"})
 }
-
-// triggerWorkNotification sends a notification when a work is updated
-func (ws *WorkService) triggerWorkNotification(ctx context.Context, workID uuid.UUID, eventType models.NotificationEvent, title, description string) {
-	if ws.notificationService == nil {
-		log.Printf("Notification service not initialized, skipping notification for work %s", workID)
-		return
-	}
-
-	event := &notifications.EventData{
-		Type:        eventType,
-		SourceID:    workID,
-		SourceType:  "work",
-		Title:       title,
-		Description: description,
-		ActionURL:   fmt.Sprintf("/works/%s", workID),
-		ActorID:     nil, // TODO: Get user ID from context
-		ActorName:   "",  // TODO: Get username from context
-		ExtraData:   make(map[string]interface{}),
-	}
-
-	if err := ws.notificationService.ProcessEvent(ctx, event); err != nil {
-		log.Printf("Failed to process notification event for work %s: %v", workID, err)
-	} else {
-		log.Printf("Successfully triggered notification for work %s", workID)
-	}
-}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"testing"
 	"time"
 
 	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/pagination"
 	"nuclear-ao3/shared/server"
 	testutils "nuclear-ao3/shared/testing"
 
@@ -1067,12 +1070,30 @@ func (suite *WorkServiceTestSuite) TestProtectedEndpoints_NoAuth() {
 		{"POST", "/api/v1/works"},
 		{"POST", "/api/v1/works/" + uuid.New().String() + "/gift"},
 		{"POST", "/api/v1/works/" + uuid.New().String() + "/orphan"},
+		{"POST", "/api/v1/works/" + uuid.New().String() + "/orphan/confirm"},
 		{"GET", "/api/v1/works/" + uuid.New().String() + "/authors"},
 		{"POST", "/api/v1/works/" + uuid.New().String() + "/co-authors"},
 		{"POST", "/api/v1/users/" + uuid.New().String() + "/mute"},
 		{"DELETE", "/api/v1/users/" + uuid.New().String() + "/mute"},
 		{"GET", "/api/v1/users/" + uuid.New().String() + "/mute-status"},
 		{"GET", "/api/v1/my/muted-users"},
+		{"POST", "/api/v1/prompts/" + uuid.New().String() + "/claims"},
+		{"POST", "/api/v1/prompts/" + uuid.New().String() + "/fills"},
+		{"GET", "/api/v1/my/claims"},
+		{"GET", "/api/v1/my/blocklist/export"},
+		{"POST", "/api/v1/my/blocklist/import"},
+		{"POST", "/api/v1/collections/" + uuid.New().String() + "/reveal"},
+		{"GET", "/api/v1/collections/" + uuid.New().String() + "/pending"},
+		{"POST", "/api/v1/collections/" + uuid.New().String() + "/items/" + uuid.New().String() + "/approve"},
+		{"POST", "/api/v1/collections/" + uuid.New().String() + "/items/" + uuid.New().String() + "/reject"},
+		{"POST", "/api/v1/collections/" + uuid.New().String() + "/invitations"},
+		{"GET", "/api/v1/my/collection-invitations"},
+		{"POST", "/api/v1/collection-invitations/" + uuid.New().String() + "/accept"},
+		{"POST", "/api/v1/collections/" + uuid.New().String() + "/maintainers"},
+		{"DELETE", "/api/v1/collections/" + uuid.New().String() + "/maintainers/" + uuid.New().String()},
+		{"POST", "/api/v1/works/" + uuid.New().String() + "/subscribe"},
+		{"DELETE", "/api/v1/users/" + uuid.New().String() + "/subscribe"},
+		{"POST", "/api/v1/series/" + uuid.New().String() + "/subscribe"},
 	}
 
 	for _, endpoint := range protectedEndpoints {
@@ -1265,6 +1286,205 @@ func (suite *WorkServiceTestSuite) TestWork_PrivacyFields() {
 	}
 }
 
+// setRatingPolicy applies a rating rule for a test and puts it back after
+func (suite *WorkServiceTestSuite) setRatingPolicy(rating string, allowed, loginRequired bool) {
+	ctx := context.Background()
+	var oldAllowed, oldLoginRequired bool
+	err := suite.service.db.QueryRow("SELECT allowed, login_required FROM content_rating_policy WHERE rating = $1", rating).
+		Scan(&oldAllowed, &oldLoginRequired)
+	suite.Require().NoError(err)
+
+	_, err = suite.service.db.Exec("UPDATE content_rating_policy SET allowed = $2, login_required = $3 WHERE rating = $1",
+		rating, allowed, loginRequired)
+	suite.Require().NoError(err)
+	suite.service.cache.Delete(ctx, ratingPolicyCacheKey)
+
+	suite.T().Cleanup(func() {
+		suite.service.db.Exec("UPDATE content_rating_policy SET allowed = $2, login_required = $3 WHERE rating = $1",
+			rating, oldAllowed, oldLoginRequired)
+		suite.service.cache.Delete(ctx, ratingPolicyCacheKey)
+	})
+}
+
+// The rating policy is enforced on the routes readers and authors use
+func (suite *WorkServiceTestSuite) TestRatingPolicy_RoutedCreateRejectsDisallowedRating() {
+	suite.setRatingPolicy("explicit", false, false)
+	router := setupRouter(suite.service)
+
+	w := testutils.PerformRequest(router, testutils.TestRequest{
+		Method: "POST",
+		URL:    "/api/v1/works",
+		Body:   `{"title": "Policy Test", "summary": "Test", "language": "en", "rating": "explicit", "fandoms": ["Test"], "chapter_content": "Content"}`,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+			"X-User-ID":    suite.testUsers["testuser"].String(),
+		},
+		ExpectedCode: 422,
+	})
+
+	response := testutils.AssertJSONResponse(suite.T(), w, 422)
+	assert.Equal(suite.T(), "RATING_NOT_ALLOWED", response["code"])
+}
+
+func (suite *WorkServiceTestSuite) TestRatingPolicy_BulkEditRejectsDisallowedRating() {
+	suite.setRatingPolicy("explicit", false, false)
+	router := setupRouter(suite.service)
+
+	for _, dryRun := range []string{"true", "false"} {
+		w := testutils.PerformRequest(router, testutils.TestRequest{
+			Method: "POST",
+			URL:    "/api/v1/my/works/bulk-edit",
+			Body:   `{"filter": {"scope": "all"}, "patch": {"rating": "explicit"}, "dry_run": ` + dryRun + `}`,
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+				"X-User-ID":    suite.testUsers["testuser"].String(),
+			},
+			ExpectedCode: 422,
+		})
+
+		response := testutils.AssertJSONResponse(suite.T(), w, 422)
+		assert.Equal(suite.T(), "RATING_NOT_ALLOWED", response["code"], "dry_run %s", dryRun)
+	}
+}
+
+func (suite *WorkServiceTestSuite) TestRatingPolicy_RoutedGetHidesGatedWorkWhenLoggedOut() {
+	suite.setRatingPolicy("mature", true, true)
+	router := setupRouter(suite.service)
+
+	workID := uuid.New()
+	_, err := suite.service.db.Exec(`
+		INSERT INTO works (id, title, summary, user_id, language, rating, word_count, status, created_at, updated_at)
+		VALUES ($1, 'Gated Mature Work', 'Summary', $2, 'en', 'Mature', 100, 'posted', NOW(), NOW())`,
+		workID, suite.testUsers["author2"])
+	suite.Require().NoError(err)
+	suite.T().Cleanup(func() { suite.service.db.Exec("DELETE FROM works WHERE id = $1", workID) })
+
+	w := testutils.PerformRequest(router, testutils.TestRequest{
+		Method:       "GET",
+		URL:          "/api/v1/works/" + workID.String(),
+		ExpectedCode: 401,
+	})
+
+	response := testutils.AssertJSONResponse(suite.T(), w, 401)
+	assert.Equal(suite.T(), "LOGIN_REQUIRED", response["code"])
+}
+
+func (suite *WorkServiceTestSuite) TestUpdateWork_TrashedWorkNotFound() {
+	router := setupRouter(suite.service)
+	author := suite.testUsers["testuser"]
+
+	workID := uuid.New()
+	pseudID := uuid.New()
+	_, err := suite.service.db.Exec(`
+		INSERT INTO works (id, title, summary, user_id, language, rating, word_count, status, deleted_at, created_at, updated_at)
+		VALUES ($1, 'Trashed Work', 'Summary', $2, 'en', 'General Audiences', 100, 'deleted', NOW(), NOW(), NOW())`,
+		workID, author)
+	suite.Require().NoError(err)
+	_, err = suite.service.db.Exec("INSERT INTO pseuds (id, user_id, name) VALUES ($1, $2, $3)", pseudID, author, "trash-"+pseudID.String()[:8])
+	suite.Require().NoError(err)
+	_, err = suite.service.db.Exec("INSERT INTO creatorships (creation_id, creation_type, pseud_id, approved) VALUES ($1, 'Work', $2, true)", workID, pseudID)
+	suite.Require().NoError(err)
+	suite.T().Cleanup(func() {
+		suite.service.db.Exec("DELETE FROM creatorships WHERE creation_id = $1", workID)
+		suite.service.db.Exec("DELETE FROM pseuds WHERE id = $1", pseudID)
+		suite.service.db.Exec("DELETE FROM works WHERE id = $1", workID)
+	})
+
+	w := testutils.PerformRequest(router, testutils.TestRequest{
+		Method: "PUT",
+		URL:    "/api/v1/works/" + workID.String(),
+		Body:   `{"title": "Edited in the trash", "version": 1}`,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+			"X-User-ID":    author.String(),
+		},
+		ExpectedCode: 404,
+	})
+
+	testutils.AssertJSONResponse(suite.T(), w, 404)
+}
+
+func (suite *WorkServiceTestSuite) TestCanViewWork_UnlistedWorkVisibleToCoAuthors() {
+	owner := suite.testUsers["testuser"]
+	coAuthor := suite.testUsers["author2"]
+	ctx := context.Background()
+
+	workID := uuid.New()
+	pseudID := uuid.New()
+	_, err := suite.service.db.Exec(`
+		INSERT INTO works (id, title, summary, user_id, language, rating, word_count, status, created_at, updated_at)
+		VALUES ($1, 'Unlisted Work', 'Summary', $2, 'en', 'General Audiences', 100, 'unlisted', NOW(), NOW())`,
+		workID, owner)
+	suite.Require().NoError(err)
+	_, err = suite.service.db.Exec("INSERT INTO pseuds (id, user_id, name) VALUES ($1, $2, $3)", pseudID, coAuthor, "coauthor-"+pseudID.String()[:8])
+	suite.Require().NoError(err)
+	_, err = suite.service.db.Exec("INSERT INTO creatorships (creation_id, creation_type, pseud_id, approved) VALUES ($1, 'Work', $2, true)", workID, pseudID)
+	suite.Require().NoError(err)
+	suite.T().Cleanup(func() {
+		suite.service.db.Exec("DELETE FROM creatorships WHERE creation_id = $1", workID)
+		suite.service.db.Exec("DELETE FROM pseuds WHERE id = $1", pseudID)
+		suite.service.db.Exec("DELETE FROM works WHERE id = $1", workID)
+	})
+
+	work := &models.Work{ID: workID, UserID: owner, Status: "unlisted"}
+	reader := uuid.New()
+	assert.True(suite.T(), suite.service.canViewWork(ctx, work, &coAuthor))
+	assert.False(suite.T(), suite.service.canViewWork(ctx, work, &reader), "readers need the share link")
+}
+
+func (suite *WorkServiceTestSuite) TestWorkCursor_PublishedAtPagesPastNullTimestamps() {
+	author := suite.testUsers["author2"]
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	// Two works never published, created between and after two that were
+	first, third := base, base.Add(2*time.Minute)
+	published := []*time.Time{&first, nil, &third, nil}
+	var ids []uuid.UUID
+	for i, at := range published {
+		workID := uuid.New()
+		_, err := suite.service.db.Exec(`
+			INSERT INTO works (id, title, summary, user_id, language, rating, word_count, status, published_at, created_at, updated_at)
+			VALUES ($1, $2, 'Summary', $3, 'en', 'General Audiences', 100, 'draft', $4, $5, $5)`,
+			workID, fmt.Sprintf("Cursor Work %d", i), author, at, base.Add(time.Duration(i)*time.Minute))
+		suite.Require().NoError(err)
+		ids = append(ids, workID)
+	}
+	suite.T().Cleanup(func() {
+		for _, id := range ids {
+			suite.service.db.Exec("DELETE FROM works WHERE id = $1", id)
+		}
+	})
+
+	// Page through one work at a time, the way SearchWorks keys its cursor
+	expr := workCursorExprs["published_at"]
+	var seen []uuid.UUID
+	var after *pagination.Keyset
+	for len(seen) <= len(ids) {
+		query := "SELECT id, published_at, created_at, updated_at FROM works w WHERE w.user_id = $1 AND w.title LIKE 'Cursor Work %'"
+		args := []interface{}{author}
+		if after != nil {
+			cond, condArgs := after.Condition(expr, "w.id", true, 2)
+			query += " AND " + cond
+			args = append(args, condArgs...)
+		}
+		query += fmt.Sprintf(" ORDER BY %s DESC, w.id DESC LIMIT 1", expr)
+
+		var work models.Work
+		err := suite.service.db.QueryRow(query, args...).Scan(&work.ID, &work.PublishedAt, &work.CreatedAt, &work.UpdatedAt)
+		if err == sql.ErrNoRows {
+			break
+		}
+		suite.Require().NoError(err)
+		seen = append(seen, work.ID)
+
+		t, ok := workCursorTime(work, "published_at")
+		suite.Require().True(ok)
+		after = &pagination.Keyset{Time: t, ID: work.ID}
+	}
+
+	assert.Equal(suite.T(), []uuid.UUID{ids[3], ids[2], ids[1], ids[0]}, seen)
+}
+
 // Test endpoint structure validation
 func (suite *WorkServiceTestSuite) TestEndpoint_ResponseStructures() {
 	router := setupRouter(suite.service)
@@ -1315,3 +1535,19 @@ func (suite *WorkServiceTestSuite) TestEndpoint_ResponseStructures() {
 func TestWorkServiceTestSuite(t *testing.T) {
 	suite.Run(t, new(WorkServiceTestSuite))
 }
+
+func TestWorkCursorTime(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	published := created.Add(time.Hour)
+
+	at, ok := workCursorTime(models.Work{CreatedAt: created, PublishedAt: &published}, "published_at")
+	assert.True(t, ok)
+	assert.Equal(t, published, at)
+
+	at, ok = workCursorTime(models.Work{CreatedAt: created}, "published_at")
+	assert.True(t, ok)
+	assert.Equal(t, created, at, "a work never published is keyed on created_at, as COALESCE orders it")
+
+	_, ok = workCursorTime(models.Work{}, "kudos")
+	assert.False(t, ok)
+}
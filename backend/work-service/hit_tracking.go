@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/statsstream"
+)
+
+// =============================================================================
+// HIT TRACKING
+// Views and kudos are published to the stats stream as they happen. The
+// stats service deduplicates and counts them, and serves the stats APIs.
+// =============================================================================
+
+const (
+	// statsStreamMaxLen caps the stats stream; the stats service keeps up
+	// with it in normal operation, so this only bounds an outage
+	statsStreamMaxLen = 500000
+	// clientCountryHeader carries the viewer's country from the gateway
+	clientCountryHeader = "X-Client-Country"
+)
+
+// Referrer classes, as stored in work_chapter_daily_hits.referrer_class
+const (
+	referrerSearch   = statsstream.ReferrerSearch
+	referrerTag      = statsstream.ReferrerTag
+	referrerInternal = statsstream.ReferrerInternal
+	referrerExternal = statsstream.ReferrerExternal
+	referrerDirect   = statsstream.ReferrerDirect
+)
+
+// hitViewer identifies who is viewing for dedup: the user when logged in,
+// otherwise their IP. It is hashed so Redis never holds raw addresses.
+func hitViewer(c *gin.Context) string {
+	viewer := "ip:" + c.ClientIP()
+	if userID, exists := c.Get("user_id"); exists {
+		if id, ok := userID.(string); ok && id != "" {
+			viewer = "user:" + id
+		}
+	}
+	sum := sha256.Sum256([]byte(viewer))
+	return hex.EncodeToString(sum[:16])
+}
+
+// recordHit publishes a view of a work, attributed to the chapter viewed
+// (uuid.Nil for the work page), the referrer class and the viewer's
+// country. It returns immediately; the stats service counts it at most once
+// per viewer per day.
+func (ws *WorkService) recordHit(workID, chapterID uuid.UUID, viewer, referrer, country string) {
+	ws.publishStatsEvent(statsstream.NewHit(workID, chapterID, viewer, referrer, country))
+}
+
+// recordKudos publishes kudos given (delta 1) or removed (delta -1) for the
+// stats service's daily rollups
+func (ws *WorkService) recordKudos(workID uuid.UUID, delta int) {
+	ws.publishStatsEvent(statsstream.NewKudos(workID, delta))
+}
+
+// publishStatsEvent adds an event to the stats stream in the background.
+// Stats are best effort: an event that can't be published is logged and
+// dropped rather than failing the reader's request.
+func (ws *WorkService) publishStatsEvent(event statsstream.Event) {
+	if ws.redis == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := ws.redis.XAdd(ctx, &redis.XAddArgs{
+			Stream: statsstream.Stream,
+			MaxLen: statsStreamMaxLen,
+			Approx: true,
+			Values: event.Values(),
+		}).Err()
+		if err != nil {
+			log.Printf("Failed to publish %s event for work %s: %v", event.Type, event.WorkID, err)
+		}
+	}()
+}
+
+// classifyReferrer sorts a view by where the reader came from. The frontend
+// knows this better than the Referer header of an API call does, so an
+// explicit ref wins; otherwise the header is matched against the archive's
+// own hosts and pages.
+func classifyReferrer(ref, referer string, siteHosts []string) string {
+	if ref = strings.ToLower(strings.TrimSpace(ref)); statsstream.IsReferrerClass(ref) {
+		return ref
+	}
+	if referer == "" {
+		return referrerDirect
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Hostname() == "" {
+		return referrerDirect
+	}
+
+	internal := false
+	for _, host := range siteHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			internal = true
+			break
+		}
+	}
+	if !internal {
+		return referrerExternal
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	query := u.Query()
+	switch {
+	case strings.HasPrefix(path, "/search"), path == "/works" && query.Get("q") != "":
+		return referrerSearch
+	case strings.HasPrefix(path, "/tags/"), strings.HasPrefix(path, "/fandoms"),
+		path == "/works" && (query.Get("tag") != "" || query.Get("tags") != "" || query.Get("fandom") != ""):
+		return referrerTag
+	}
+	return referrerInternal
+}
+
+// archiveHosts is the API's own host and the frontend's host: the hosts
+// whose links point at this archive
+func archiveHosts(c *gin.Context) []string {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	hosts := []string{host}
+	if frontend, err := url.Parse(getEnv("FRONTEND_URL", "http://localhost:3000")); err == nil && frontend.Hostname() != "" {
+		hosts = append(hosts, frontend.Hostname())
+	}
+	return hosts
+}
+
+// hitReferrer classifies the current request's referrer, treating the
+// archive's own hosts as internal
+func hitReferrer(c *gin.Context) string {
+	return classifyReferrer(c.Query("ref"), c.GetHeader("Referer"), archiveHosts(c))
+}
+
+// hitCountry is the viewer's country as looked up by the gateway
+func hitCountry(c *gin.Context) string {
+	return statsstream.NormalizeCountry(c.GetHeader(clientCountryHeader))
+}
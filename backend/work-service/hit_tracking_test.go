@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHitViewer(t *testing.T) {
+	newContext := func(ip string, userID string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.RemoteAddr = ip + ":1234"
+		if userID != "" {
+			c.Set("user_id", userID)
+		}
+		return c
+	}
+
+	userID := uuid.New().String()
+	anon := hitViewer(newContext("10.0.0.1", ""))
+
+	assert.Equal(t, anon, hitViewer(newContext("10.0.0.1", "")), "same IP is the same viewer")
+	assert.NotEqual(t, anon, hitViewer(newContext("10.0.0.2", "")))
+	assert.Equal(t, hitViewer(newContext("10.0.0.1", userID)), hitViewer(newContext("10.0.0.2", userID)),
+		"a logged-in user is one viewer from any address")
+	assert.NotContains(t, anon, "10.0.0.1")
+}
+
+func TestClassifyReferrer(t *testing.T) {
+	hosts := []string{"api.nuclear-ao3.org", "nuclear-ao3.org"}
+
+	cases := []struct {
+		name, ref, referer, want string
+	}{
+		{"explicit ref wins", "tag", "https://www.google.com/", referrerTag},
+		{"explicit ref is case-insensitive", " Search ", "", referrerSearch},
+		{"unknown ref falls back to header", "newsletter", "", referrerDirect},
+		{"no referer", "", "", referrerDirect},
+		{"unparseable referer", "", "::not a url", referrerDirect},
+		{"other site", "", "https://www.tumblr.com/some/post", referrerExternal},
+		{"search page", "", "https://nuclear-ao3.org/search?q=coffee+shop", referrerSearch},
+		{"works search", "", "https://nuclear-ao3.org/works?q=slow+burn", referrerSearch},
+		{"tag page", "", "https://nuclear-ao3.org/tags/Fluff/works", referrerTag},
+		{"fandom listing", "", "https://nuclear-ao3.org/fandoms/anime", referrerTag},
+		{"works filtered by tag", "", "https://nuclear-ao3.org/works?tag=Angst", referrerTag},
+		{"host match ignores case and port", "", "http://Nuclear-AO3.org:8080/search", referrerSearch},
+		{"other archive page", "", "https://nuclear-ao3.org/users/someone", referrerInternal},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, classifyReferrer(tc.ref, tc.referer, hosts))
+		})
+	}
+}
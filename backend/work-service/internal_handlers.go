@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/middleware"
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/searchstream"
+)
+
+// =============================================================================
+// INTERNAL API
+// Operational endpoints behind the service token, driven by nuclearctl, and
+// bulk reads for other services. Every endpoint that changes something
+// accepts ?dry_run=true and then only reports what it would do.
+// =============================================================================
+
+// maxReportedDrift caps the works listed in a reconciliation report; the
+// totals always cover every drifted work
+const maxReportedDrift = 100
+
+// maxBatchWorks caps the works one batch request may fetch
+const maxBatchWorks = 100
+
+// WorkDocument is a work with its chapters, as served to other services
+type WorkDocument struct {
+	Work     *models.Work     `json:"work"`
+	Chapters []models.Chapter `json:"chapters"`
+}
+
+// CountDrift is a work whose cached counts disagree with its rows
+type CountDrift struct {
+	WorkID        uuid.UUID `json:"work_id"`
+	KudosCount    int       `json:"kudos_count"`
+	Kudos         int       `json:"kudos"`
+	CommentCount  int       `json:"comment_count"`
+	Comments      int       `json:"comments"`
+	BookmarkCount int       `json:"bookmark_count"`
+	Bookmarks     int       `json:"bookmarks"`
+}
+
+// countDriftCTE computes the true counts of the selected works, matching how
+// the kudos, comment and bookmark handlers maintain them. $1 is an array of
+// work IDs, or NULL for every work.
+const countDriftCTE = `
+	WITH actual AS (
+		SELECT w.id,
+			COALESCE(w.kudos_count, 0) AS kudos_count,
+			(SELECT COUNT(*) FROM kudos k WHERE k.work_id = w.id) AS kudos,
+			COALESCE(w.comment_count, 0) AS comment_count,
+			(SELECT COUNT(*) FROM comments cm WHERE cm.work_id = w.id AND cm.status = 'published') AS comments,
+			COALESCE(w.bookmark_count, 0) AS bookmark_count,
+			(SELECT COUNT(*) FROM bookmarks b WHERE b.work_id = w.id) AS bookmarks
+		FROM works w
+		WHERE $1::uuid[] IS NULL OR w.id = ANY($1::uuid[])
+	), drift AS (
+		SELECT * FROM actual
+		WHERE kudos_count <> kudos OR comment_count <> comments OR bookmark_count <> bookmarks
+	)`
+
+// InternalReindexWork queues a search reindex of one work:
+// POST /api/v1/internal/works/:work_id/reindex
+func (ws *WorkService) InternalReindexWork(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var exists bool
+	if err := ws.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM works WHERE id = $1)", workID).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up work"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	}
+
+	if middleware.IsDryRun(c) {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "work_id": workID, "message": "Work would be queued for reindexing"})
+		return
+	}
+
+	if _, err := ws.db.ExecContext(ctx,
+		"INSERT INTO search_outbox (work_id, event_type) VALUES ($1, $2)",
+		workID, searchstream.EventUpsert); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue reindex"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"work_id": workID, "message": "Work queued for reindexing"})
+}
+
+// workCacheKeys lists the cache entries held for a work
+func workCacheKeys(workID uuid.UUID) []string {
+	return []string{
+		"work:" + workID.String(),
+		"work_stats:" + workID.String(),
+		relatedWorksCacheKey(workID),
+		seriesNavigationCacheKey(workID),
+		"search:*",
+	}
+}
+
+// InternalPurgeWorkCache drops every cache entry held for a work:
+// POST /api/v1/internal/works/:work_id/cache/purge
+func (ws *WorkService) InternalPurgeWorkCache(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+
+	keys := workCacheKeys(workID)
+	if middleware.IsDryRun(c) {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "work_id": workID, "keys": keys})
+		return
+	}
+	if ws.cache == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cache is not available"})
+		return
+	}
+
+	if err := ws.InvalidateWorkCache(workID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge work cache", "details": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	ws.invalidateRelatedWorks(ctx, workID)
+	ws.invalidateSeriesNavigation(ctx, workID)
+
+	c.JSON(http.StatusOK, gin.H{"work_id": workID, "keys": keys, "message": "Work cache purged"})
+}
+
+// InternalReconcileCounts recomputes the kudos, comment and bookmark counts
+// cached on works and fixes any that drifted:
+// POST /api/v1/internal/counts/reconcile {"work_ids": [...]} or {"all": true}
+func (ws *WorkService) InternalReconcileCounts(c *gin.Context) {
+	var req struct {
+		WorkIDs []uuid.UUID `json:"work_ids"`
+		All     bool        `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	if len(req.WorkIDs) == 0 && !req.All {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Choose work_ids or all"})
+		return
+	}
+
+	var workIDs interface{}
+	if !req.All {
+		workIDs = pq.Array(uuidStrings(req.WorkIDs))
+	}
+
+	dryRun := middleware.IsDryRun(c)
+	drifted, err := ws.reconcileCounts(c.Request.Context(), workIDs, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile counts"})
+		return
+	}
+
+	if !dryRun && ws.cache != nil {
+		for _, d := range drifted {
+			ws.InvalidateWorkCache(d.WorkID)
+		}
+	}
+
+	report := drifted
+	if len(report) > maxReportedDrift {
+		report = report[:maxReportedDrift]
+	}
+	response := gin.H{"drifted": len(drifted), "works": report}
+	if dryRun {
+		response["dry_run"] = true
+	} else {
+		response["fixed"] = len(drifted)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// reconcileCounts finds works whose counts drifted and, unless dryRun,
+// corrects them in the same statement. The returned drift holds the counts
+// as they were before the fix.
+func (ws *WorkService) reconcileCounts(ctx context.Context, workIDs interface{}, dryRun bool) ([]CountDrift, error) {
+	query := countDriftCTE + `
+		SELECT id, kudos_count, kudos, comment_count, comments, bookmark_count, bookmarks
+		FROM drift ORDER BY id`
+	if !dryRun {
+		query = countDriftCTE + `
+		UPDATE works w
+		SET kudos_count = d.kudos, comment_count = d.comments, bookmark_count = d.bookmarks
+		FROM drift d
+		WHERE w.id = d.id
+		RETURNING d.id, d.kudos_count, d.kudos, d.comment_count, d.comments, d.bookmark_count, d.bookmarks`
+	}
+
+	rows, err := ws.db.QueryContext(ctx, query, workIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drifted := []CountDrift{}
+	for rows.Next() {
+		var d CountDrift
+		if err := rows.Scan(&d.WorkID, &d.KudosCount, &d.Kudos, &d.CommentCount, &d.Comments,
+			&d.BookmarkCount, &d.Bookmarks); err != nil {
+			return nil, err
+		}
+		drifted = append(drifted, d)
+	}
+	return drifted, rows.Err()
+}
+
+// batchWorksQuery loads works with their chapters, drafts included, in one
+// round trip. $1 is an array of work IDs.
+const batchWorksQuery = `
+	SELECT` + workColumns + `,
+		COALESCE(ch.chapters, '[]')
+	FROM works w
+	JOIN users u ON w.user_id = u.id
+	LEFT JOIN work_statistics ws ON w.id = ws.work_id
+	LEFT JOIN LATERAL (
+		SELECT json_agg(json_build_object(
+			'id', c.id, 'work_id', c.work_id, 'number', c.chapter_number,
+			'title', COALESCE(c.title, ''), 'summary', COALESCE(c.summary, ''),
+			'notes', COALESCE(c.notes, ''), 'end_notes', COALESCE(c.end_notes, ''),
+			'content', COALESCE(c.content, ''), 'word_count', COALESCE(c.word_count, 0),
+			'status', CASE WHEN c.is_draft THEN 'draft' ELSE 'posted' END,
+			'published_at', c.published_at, 'created_at', c.created_at, 'updated_at', c.updated_at,
+			'version', c.version
+		) ORDER BY c.chapter_number) AS chapters
+		FROM chapters c
+		WHERE c.work_id = w.id
+	) ch ON true
+	WHERE w.id = ANY($1::uuid[])`
+
+// batchWorkIDs drops repeated IDs, keeping the first of each, and checks
+// the batch size
+func batchWorkIDs(ids []uuid.UUID) ([]uuid.UUID, error) {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, fmt.Errorf("work_ids is required")
+	}
+	if len(unique) > maxBatchWorks {
+		return nil, fmt.Errorf("at most %d works can be fetched at once", maxBatchWorks)
+	}
+	return unique, nil
+}
+
+// InternalBatchWorks returns full work documents, chapters included, for
+// services that would otherwise fetch works one at a time:
+// POST /api/v1/internal/works/batch {"work_ids": [...]}
+// Works come back in the order asked for; IDs with no work are listed as
+// missing.
+func (ws *WorkService) InternalBatchWorks(c *gin.Context) {
+	var req struct {
+		WorkIDs []uuid.UUID `json:"work_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	workIDs, err := batchWorkIDs(req.WorkIDs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	found, err := ws.loadWorkDocuments(c.Request.Context(), workIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load works"})
+		return
+	}
+
+	works := make([]WorkDocument, 0, len(found))
+	missing := []uuid.UUID{}
+	for _, id := range workIDs {
+		if doc, ok := found[id]; ok {
+			works = append(works, doc)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"works": works, "missing": missing})
+}
+
+// loadWorkDocuments loads the given works with their chapters, by ID
+func (ws *WorkService) loadWorkDocuments(ctx context.Context, workIDs []uuid.UUID) (map[uuid.UUID]WorkDocument, error) {
+	rows, err := ws.db.QueryContext(ctx, batchWorksQuery, pq.Array(uuidStrings(workIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := make(map[uuid.UUID]WorkDocument, len(workIDs))
+	for rows.Next() {
+		var chapters []byte
+		work, err := scanWork(rows, &chapters)
+		if err != nil {
+			return nil, err
+		}
+		doc := WorkDocument{Work: work}
+		if err := json.Unmarshal(chapters, &doc.Chapters); err != nil {
+			return nil, fmt.Errorf("decoding chapters of work %s: %w", work.ID, err)
+		}
+		for i := range doc.Chapters {
+			doc.Chapters[i].Language = work.Language
+		}
+		docs[work.ID] = doc
+	}
+	return docs, rows.Err()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestBatchWorkIDs(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	ids, err := batchWorkIDs([]uuid.UUID{a, b, a})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{a, b}, ids, "repeats are dropped and the order is kept")
+
+	_, err = batchWorkIDs(nil)
+	assert.Error(t, err)
+
+	tooMany := make([]uuid.UUID, maxBatchWorks+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.New()
+	}
+	_, err = batchWorkIDs(tooMany)
+	assert.Error(t, err)
+}
+
+func TestInternalBatchWorksRejectsBadBatches(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ws := &WorkService{}
+	r := gin.New()
+	r.POST("/works/batch", ws.InternalBatchWorks)
+
+	for _, body := range []string{`{"work_ids": []}`, `{"work_ids": ["not-a-uuid"]}`, `not json`} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/works/batch", bytes.NewBufferString(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestWorkDocumentKeepsChapters(t *testing.T) {
+	work := &WorkDocument{Work: &models.Work{ID: uuid.New(), Title: "Good Omens, Again"}}
+	data, err := json.Marshal(work)
+	assert.NoError(t, err)
+
+	var doc map[string]map[string]interface{}
+	json.Unmarshal(data, &doc)
+	assert.Equal(t, work.Work.Title, doc["work"]["title"])
+	assert.Contains(t, string(data), `"chapters":`, "the work's own JSON encoding mustn't swallow its chapters")
+}
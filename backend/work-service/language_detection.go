@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// =============================================================================
+// LANGUAGE DETECTION
+// A light check on posted chapter text against the work's declared language.
+// It only ever warns; authors are free to post what they like.
+// =============================================================================
+
+const (
+	// languageDetectionMinWords is the least text worth guessing from
+	languageDetectionMinWords = 50
+	// languageMismatchConfidence is how sure detection must be before a
+	// disagreement with the declared language is reported
+	languageMismatchConfidence = 0.6
+	// latinStopwordMinCoverage is the share of words that must be stopwords
+	// of some language before a Latin-script guess is trusted at all
+	latinStopwordMinCoverage = 0.15
+)
+
+// LanguageWarning is returned alongside a posted chapter when its text
+// looks like a different language from the one the work declares
+type LanguageWarning struct {
+	Declared   string  `json:"declared"`
+	Detected   string  `json:"detected"`
+	Confidence float64 `json:"confidence"`
+	Message    string  `json:"message"`
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// languageStopwords are frequent, fairly distinctive words for the Latin
+// script languages the archive sees most
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "was", "he", "she", "that", "it", "with", "his", "her", "you", "is", "for", "had", "but", "not", "they", "said"},
+	"es": {"el", "los", "las", "que", "y", "en", "por", "con", "una", "para", "pero", "su", "se", "del", "como", "estaba", "dijo", "muy", "más", "ella"},
+	"fr": {"le", "les", "et", "est", "dans", "que", "une", "pour", "pas", "qui", "sur", "il", "elle", "avec", "mais", "je", "vous", "était", "au", "ce"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ich", "sie", "er", "ein", "eine", "zu", "mit", "den", "sich", "auf", "es", "war", "auch", "dem"},
+	"it": {"il", "che", "di", "e", "non", "per", "una", "sono", "della", "gli", "lei", "lui", "era", "come", "anche", "ma", "questo", "mi", "ha", "nel"},
+	"pt": {"o", "os", "que", "e", "não", "uma", "com", "para", "ele", "ela", "mas", "da", "do", "em", "seu", "sua", "você", "está", "foi", "isso"},
+	"nl": {"de", "het", "een", "en", "van", "ik", "niet", "is", "dat", "zijn", "op", "te", "hij", "ze", "met", "voor", "maar", "was", "wat", "er"},
+	"pl": {"i", "się", "nie", "w", "na", "że", "to", "z", "jest", "jak", "do", "ale", "jego", "jej", "był", "była", "co", "tak", "mnie", "go"},
+	"sv": {"och", "att", "det", "som", "en", "är", "på", "inte", "jag", "han", "hon", "med", "för", "var", "till", "den", "har", "de", "om", "sig"},
+	"id": {"yang", "dan", "di", "itu", "dengan", "tidak", "ini", "dia", "ke", "untuk", "saya", "aku", "ada", "dari", "akan", "kamu", "tapi", "sudah", "bisa", "juga"},
+}
+
+var stopwordLanguages = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range languageStopwords {
+		for _, word := range words {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+// scriptLanguages maps writing systems that mostly belong to one language
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// detectLanguage guesses the language of text, returning "" when there is
+// too little text to say. Confidence runs from 0 to 1.
+func detectLanguage(text string) (string, float64) {
+	text = htmlTagPattern.ReplaceAllString(text, " ")
+
+	var letters, latin, han, kana int
+	scripts := make(map[string]int)
+	ukrainian := false
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		default:
+			for _, s := range scriptLanguages {
+				if unicode.Is(s.table, r) {
+					scripts[s.lang]++
+					break
+				}
+			}
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				ukrainian = true
+			}
+		}
+	}
+	if letters == 0 {
+		return "", 0
+	}
+
+	// Chinese and Japanese text has no spaces, so judge it by characters
+	if cjk := han + kana; cjk*2 > letters {
+		if kana*10 > cjk {
+			return "ja", float64(cjk) / float64(letters)
+		}
+		return "zh", float64(cjk) / float64(letters)
+	}
+	for lang, count := range scripts {
+		if count*2 > letters {
+			if lang == "ru" && ukrainian {
+				lang = "uk"
+			}
+			return lang, float64(count) / float64(letters)
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) < languageDetectionMinWords || latin*2 <= letters {
+		return "", 0
+	}
+	return detectLatinLanguage(words)
+}
+
+// detectLatinLanguage scores words against each language's stopwords.
+// Confidence is how far the winner leads the runner-up.
+func detectLatinLanguage(words []string) (string, float64) {
+	scores := make(map[string]int)
+	matched := 0
+	for _, word := range words {
+		langs := stopwordLanguages[word]
+		if len(langs) > 0 {
+			matched++
+		}
+		for _, lang := range langs {
+			scores[lang]++
+		}
+	}
+	if float64(matched) < latinStopwordMinCoverage*float64(len(words)) {
+		return "", 0
+	}
+
+	best, bestScore, secondScore := "", 0, 0
+	for lang, score := range scores {
+		if score > bestScore || (score == bestScore && lang < best) {
+			best, bestScore, secondScore = lang, score, bestScore
+		} else if score > secondScore {
+			secondScore = score
+		}
+	}
+	if bestScore == 0 {
+		return "", 0
+	}
+	return best, float64(bestScore-secondScore) / float64(bestScore)
+}
+
+// normalizeLanguageCode reduces "en-US" or "EN" to "en"
+func normalizeLanguageCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// checkChapterLanguage returns a warning when text confidently reads as a
+// language other than declared, or nil when they agree or it can't tell
+func checkChapterLanguage(declared, text string) *LanguageWarning {
+	detected, confidence := detectLanguage(text)
+	if detected == "" || confidence < languageMismatchConfidence {
+		return nil
+	}
+	declared = normalizeLanguageCode(declared)
+	if declared == "" || declared == detected {
+		return nil
+	}
+	return &LanguageWarning{
+		Declared:   declared,
+		Detected:   detected,
+		Confidence: confidence,
+		Message: fmt.Sprintf("This chapter looks like it is written in %q, but the work's language is %q. "+
+			"Please check the work's language setting.", detected, declared),
+	}
+}
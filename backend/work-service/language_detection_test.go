@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	englishSample = "She had been waiting at the station for an hour, and the train was late again. " +
+		"He said that it was not his fault, but she knew better than to believe him. " +
+		"They walked home together in the rain, and neither of them said a word about what had happened. " +
+		"It was the kind of silence that you only share with someone you have known for years."
+	spanishSample = "Ella estaba en la estación desde hacía una hora y el tren llegaba tarde otra vez. " +
+		"Él dijo que no era su culpa, pero ella sabía que no debía creerle. " +
+		"Caminaron juntos a casa bajo la lluvia y ninguno de los dos dijo nada sobre lo que había pasado. " +
+		"Era el tipo de silencio que solo se comparte con alguien a quien se conoce desde hace muchos años, como una vieja canción."
+)
+
+func TestDetectLanguageLatin(t *testing.T) {
+	lang, confidence := detectLanguage(englishSample)
+	assert.Equal(t, "en", lang)
+	assert.GreaterOrEqual(t, confidence, languageMismatchConfidence)
+
+	lang, confidence = detectLanguage(spanishSample)
+	assert.Equal(t, "es", lang)
+	assert.GreaterOrEqual(t, confidence, languageMismatchConfidence)
+}
+
+func TestDetectLanguageIgnoresMarkup(t *testing.T) {
+	lang, _ := detectLanguage("<p>" + strings.ReplaceAll(spanishSample, ". ", ".</p><p class=\"the and of\">") + "</p>")
+	assert.Equal(t, "es", lang)
+}
+
+func TestDetectLanguageScripts(t *testing.T) {
+	lang, _ := detectLanguage("彼女は駅で一時間も待っていたが、電車はまた遅れていた。")
+	assert.Equal(t, "ja", lang)
+
+	lang, _ = detectLanguage("她在车站等了一个小时，火车又晚点了。")
+	assert.Equal(t, "zh", lang)
+
+	lang, _ = detectLanguage("Она ждала на вокзале целый час, а поезд снова опаздывал.")
+	assert.Equal(t, "ru", lang)
+
+	lang, _ = detectLanguage("Вона чекала на вокзалі цілу годину, а потяг знову запізнювався.")
+	assert.Equal(t, "uk", lang)
+
+	lang, _ = detectLanguage("그녀는 역에서 한 시간 동안 기다렸지만 기차는 또 늦었다.")
+	assert.Equal(t, "ko", lang)
+}
+
+func TestDetectLanguageNeedsEnoughText(t *testing.T) {
+	lang, confidence := detectLanguage("The end.")
+	assert.Equal(t, "", lang)
+	assert.Zero(t, confidence)
+}
+
+func TestCheckChapterLanguage(t *testing.T) {
+	assert.Nil(t, checkChapterLanguage("en", englishSample))
+	assert.Nil(t, checkChapterLanguage("EN-gb", englishSample), "regional and upper-case codes match")
+	assert.Nil(t, checkChapterLanguage("es", "Hola."), "too little text to judge")
+
+	warning := checkChapterLanguage("en", spanishSample)
+	require.NotNil(t, warning)
+	assert.Equal(t, "en", warning.Declared)
+	assert.Equal(t, "es", warning.Detected)
+	assert.NotEmpty(t, warning.Message)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// languageDetectionEnabled reports whether posted chapters are checked
+// against their work's language (LANGUAGE_DETECTION=false turns it off)
+func languageDetectionEnabled() bool {
+	enabled, err := strconv.ParseBool(getEnv("LANGUAGE_DETECTION", "true"))
+	return err != nil || enabled
+}
+
+// WorkLanguageFlag is a work queued for wrangler review because its text
+// didn't match its declared language
+type WorkLanguageFlag struct {
+	ID               uuid.UUID  `json:"id"`
+	WorkID           uuid.UUID  `json:"work_id"`
+	WorkTitle        string     `json:"work_title"`
+	ChapterID        *uuid.UUID `json:"chapter_id,omitempty"`
+	DeclaredLanguage string     `json:"declared_language"`
+	DetectedLanguage string     `json:"detected_language"`
+	Confidence       float64    `json:"confidence"`
+	Status           string     `json:"status"`
+	ReviewedBy       *uuid.UUID `json:"reviewed_by,omitempty"`
+	ReviewedAt       *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// checkPostedChapterLanguage compares a posted chapter with its work's
+// language, queueing a wrangler flag on a confident mismatch. It returns
+// the warning to show the author, or nil.
+func (ws *WorkService) checkPostedChapterLanguage(workID, chapterID uuid.UUID, content string) *LanguageWarning {
+	if !languageDetectionEnabled() {
+		return nil
+	}
+
+	var declared string
+	if err := ws.db.QueryRow("SELECT COALESCE(language, '') FROM works WHERE id = $1", workID).Scan(&declared); err != nil {
+		log.Printf("Failed to load language of work %s: %v", workID, err)
+		return nil
+	}
+
+	warning := checkChapterLanguage(declared, content)
+	if warning == nil {
+		return nil
+	}
+
+	_, err := ws.db.Exec(`
+		INSERT INTO work_language_flags (work_id, chapter_id, declared_language, detected_language, confidence)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (work_id) WHERE status = 'pending' DO NOTHING`,
+		workID, chapterID, warning.Declared, warning.Detected, warning.Confidence)
+	if err != nil {
+		log.Printf("Failed to flag language mismatch on work %s: %v", workID, err)
+	}
+	return warning
+}
+
+// requireWrangler writes an error and returns false unless the current
+// user is a tag wrangler or admin
+func (ws *WorkService) requireWrangler(c *gin.Context) (uuid.UUID, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return uuid.Nil, false
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return uuid.Nil, false
+	}
+
+	isWrangler, err := ws.hasStaffRole(c.Request.Context(), userUUID, "tag_wrangler")
+	if err != nil || !isWrangler {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Wrangler or admin access required"})
+		return uuid.Nil, false
+	}
+	return userUUID, true
+}
+
+// GetLanguageFlags lists works flagged for a language mismatch, oldest first
+func (ws *WorkService) GetLanguageFlags(c *gin.Context) {
+	if _, ok := ws.requireWrangler(c); !ok {
+		return
+	}
+
+	status := c.DefaultQuery("status", "pending")
+	limit := 25
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(c.Query("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT f.id, f.work_id, w.title, f.chapter_id, f.declared_language, f.detected_language,
+			f.confidence, f.status, f.reviewed_by, f.reviewed_at, f.created_at
+		FROM work_language_flags f
+		JOIN works w ON w.id = f.work_id
+		WHERE f.status = $1
+		ORDER BY f.created_at ASC
+		LIMIT $2 OFFSET $3`, status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch language flags"})
+		return
+	}
+	defer rows.Close()
+
+	flags := []WorkLanguageFlag{}
+	for rows.Next() {
+		var f WorkLanguageFlag
+		var chapterID, reviewedBy uuid.NullUUID
+		var reviewedAt sql.NullTime
+		if err := rows.Scan(&f.ID, &f.WorkID, &f.WorkTitle, &chapterID, &f.DeclaredLanguage,
+			&f.DetectedLanguage, &f.Confidence, &f.Status, &reviewedBy, &reviewedAt, &f.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read language flags"})
+			return
+		}
+		if chapterID.Valid {
+			f.ChapterID = &chapterID.UUID
+		}
+		if reviewedBy.Valid {
+			f.ReviewedBy = &reviewedBy.UUID
+		}
+		if reviewedAt.Valid {
+			f.ReviewedAt = &reviewedAt.Time
+		}
+		flags = append(flags, f)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"flags": flags})
+}
+
+// ResolveLanguageFlag closes a flag, either dismissing it or relabeling
+// the work with a corrected language
+func (ws *WorkService) ResolveLanguageFlag(c *gin.Context) {
+	flagID, err := uuid.Parse(c.Param("flag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flag ID"})
+		return
+	}
+
+	var req struct {
+		Action   string `json:"action" binding:"required"`
+		Language string `json:"language"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if req.Action != "dismiss" && req.Action != "relabel" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be dismiss or relabel"})
+		return
+	}
+
+	reviewerID, ok := ws.requireWrangler(c)
+	if !ok {
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var workID uuid.UUID
+	var detected, status string
+	err = tx.QueryRow(`
+		SELECT work_id, detected_language, status FROM work_language_flags
+		WHERE id = $1 FOR UPDATE`, flagID).Scan(&workID, &detected, &status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Language flag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch language flag"})
+		return
+	}
+	if status != "pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Language flag has already been resolved"})
+		return
+	}
+
+	newStatus := "dismissed"
+	if req.Action == "relabel" {
+		newStatus = "relabeled"
+		language := normalizeLanguageCode(req.Language)
+		if language == "" {
+			language = detected
+		}
+		if len(language) != 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "language must be a two-letter code"})
+			return
+		}
+		if _, err := tx.Exec("UPDATE works SET language = $1, updated_at = NOW(), version = version + 1 WHERE id = $2", language, workID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work language"})
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE work_language_flags SET status = $1, reviewed_by = $2, reviewed_at = NOW()
+		WHERE id = $3`, newStatus, reviewerID, flagID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve language flag"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	if req.Action == "relabel" {
+		ws.InvalidateWorkCache(workID)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Language flag resolved", "status": newStatus})
+}
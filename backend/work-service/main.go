@@ -19,8 +19,14 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 
+	"nuclear-ao3/shared/audit"
 	"nuclear-ao3/shared/cache"
+	"nuclear-ao3/shared/database"
+	"nuclear-ao3/shared/metrics"
+	"nuclear-ao3/shared/middleware"
+	"nuclear-ao3/shared/mutes"
 	"nuclear-ao3/shared/notifications"
+	"nuclear-ao3/shared/schema"
 )
 
 func main() {
@@ -33,6 +39,39 @@ func main() {
 	workService := NewWorkService()
 	defer workService.Close()
 
+	// `main migrate [up|status|baseline N]` manages the shared schema and
+	// exits; otherwise pending migrations are applied before serving
+	if done, err := schema.Ready(context.Background(), workService.db, os.Args[1:]); err != nil {
+		log.Fatal("Schema is not ready: ", err)
+	} else if done {
+		return
+	}
+
+	// Start the scheduler for time-based work changes
+	schedulerInterval, err := time.ParseDuration(getEnv("WORK_SCHEDULER_INTERVAL", "1m"))
+	if err != nil || schedulerInterval <= 0 {
+		log.Printf("Invalid WORK_SCHEDULER_INTERVAL, using 1m")
+		schedulerInterval = time.Minute
+	}
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	go workService.startWorkScheduler(schedulerCtx, schedulerInterval)
+
+	// Publish work changes to the search reindexing stream
+	outboxInterval, err := time.ParseDuration(getEnv("SEARCH_OUTBOX_INTERVAL", "2s"))
+	if err != nil || outboxInterval <= 0 {
+		log.Printf("Invalid SEARCH_OUTBOX_INTERVAL, using 2s")
+		outboxInterval = 2 * time.Second
+	}
+	go workService.startSearchOutboxPublisher(schedulerCtx, outboxInterval)
+
+	// Publish queued notification events to the notification stream
+	notificationOutboxInterval, err := time.ParseDuration(getEnv("NOTIFICATION_OUTBOX_INTERVAL", "1s"))
+	if err != nil || notificationOutboxInterval <= 0 {
+		log.Printf("Invalid NOTIFICATION_OUTBOX_INTERVAL, using 1s")
+		notificationOutboxInterval = time.Second
+	}
+	go workService.startNotificationOutboxPublisher(schedulerCtx, notificationOutboxInterval)
+
 	// Setup router
 	router := setupRouter(workService)
 
@@ -60,6 +99,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopScheduler()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -86,6 +126,7 @@ func setupRouter(workService *WorkService) *gin.Engine {
 	r.Use(LoggingMiddleware())
 	r.Use(RateLimitMiddleware(workService.redis))
 	r.Use(SecurityHeadersMiddleware())
+	r.Use(middleware.NewReadOnlyModeFromEnv().Middleware())
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
@@ -101,6 +142,10 @@ func setupRouter(workService *WorkService) *gin.Engine {
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// API endpoints
+	// Searching, posting and commenting each have their own budget on top of
+	// the service-wide limit
+	limits := middleware.NewRouteRateLimiter(workService.redis, "work-service", middleware.RateLimitPoliciesFromEnv())
+
 	api := r.Group("/api/v1")
 	{
 		// Public endpoints with optional auth
@@ -108,57 +153,86 @@ func setupRouter(workService *WorkService) *gin.Engine {
 		legacy := api.Group("/works")
 		legacy.Use(OptionalAuthMiddleware())
 		{
-			legacy.GET("", workService.SearchWorks)                              // GET /api/v1/works?q=search&fandom=HP (browse/search)
-			legacy.GET("/:work_id", workService.CachedGetWork)                   // GET /api/v1/works/123 or /works/uuid (redirects legacy IDs)
-			legacy.GET("/:work_id/chapters", workService.GetChapters)            // GET /api/v1/works/123/chapters
-			legacy.GET("/:work_id/chapters/:chapter_id", workService.GetChapter) // GET /api/v1/works/123/chapters/1
-			legacy.GET("/:work_id/comments", workService.GetComments)            // GET /api/v1/works/123/comments
-			legacy.GET("/:work_id/kudos", workService.GetKudos)                  // GET /api/v1/works/123/kudos
-			legacy.GET("/:work_id/stats", workService.CachedGetWorkStats)        // GET /api/v1/works/123/stats
-			legacy.POST("/:work_id/comments", workService.CreateComment)         // POST /api/v1/works/123/comments (guest + auth comments)
+			legacy.GET("", limits.Limit(middleware.RateLimitSearch), workService.SearchWorks)                       // GET /api/v1/works?q=search&fandom=HP (browse/search)
+			legacy.GET("/:work_id", workService.CachedGetWork)                                                      // GET /api/v1/works/123 or /works/uuid (redirects legacy IDs)
+			legacy.GET("/:work_id/chapters", workService.GetChapters)                                               // GET /api/v1/works/123/chapters
+			legacy.GET("/:work_id/chapters/:chapter_id", workService.GetChapter)                                    // GET /api/v1/works/123/chapters/1
+			legacy.GET("/:work_id/comments", workService.GetComments)                                               // GET /api/v1/works/123/comments
+			legacy.GET("/:work_id/kudos", workService.GetKudos)                                                     // GET /api/v1/works/123/kudos
+			legacy.GET("/:work_id/bookmarks", workService.GetWorkBookmarks)                                         // GET /api/v1/works/123/bookmarks
+			legacy.GET("/:work_id/related", workService.GetRelatedWorks)                                            // GET /api/v1/works/123/related
+			legacy.POST("/:work_id/comments", limits.Limit(middleware.RateLimitComment), workService.CreateComment) // POST /api/v1/works/123/comments (guest + auth comments)
 		}
 
 		// Modern routes (singular - UUID-based permanent URLs)
 		modern := api.Group("/work")
 		modern.Use(OptionalAuthMiddleware())
 		{
-			modern.GET("/:work_id", workService.CachedGetWork)                   // GET /api/v1/work/{uuid} (permanent)
-			modern.GET("/:work_id/chapters", workService.GetChapters)            // GET /api/v1/work/{uuid}/chapters
-			modern.GET("/:work_id/chapters/:chapter_id", workService.GetChapter) // GET /api/v1/work/{uuid}/chapters/{uuid}
-			modern.GET("/:work_id/comments", workService.GetComments)            // GET /api/v1/work/{uuid}/comments
-			modern.GET("/:work_id/kudos", workService.GetKudos)                  // GET /api/v1/work/{uuid}/kudos
-			modern.GET("/:work_id/stats", workService.CachedGetWorkStats)        // GET /api/v1/work/{uuid}/stats
-			modern.POST("/:work_id/comments", workService.CreateComment)         // POST /api/v1/work/{uuid}/comments (guest + auth comments)
+			modern.GET("/:work_id", workService.CachedGetWork)                                                      // GET /api/v1/work/{uuid} (permanent)
+			modern.GET("/:work_id/chapters", workService.GetChapters)                                               // GET /api/v1/work/{uuid}/chapters
+			modern.GET("/:work_id/chapters/:chapter_id", workService.GetChapter)                                    // GET /api/v1/work/{uuid}/chapters/{uuid}
+			modern.GET("/:work_id/comments", workService.GetComments)                                               // GET /api/v1/work/{uuid}/comments
+			modern.GET("/:work_id/kudos", workService.GetKudos)                                                     // GET /api/v1/work/{uuid}/kudos
+			modern.GET("/:work_id/bookmarks", workService.GetWorkBookmarks)                                         // GET /api/v1/work/{uuid}/bookmarks
+			modern.GET("/:work_id/related", workService.GetRelatedWorks)                                            // GET /api/v1/work/{uuid}/related
+			modern.POST("/:work_id/comments", limits.Limit(middleware.RateLimitComment), workService.CreateComment) // POST /api/v1/work/{uuid}/comments (guest + auth comments)
 		}
 
 		// Series endpoints
 		series := api.Group("/series")
 		{
-			series.GET("", workService.SearchSeries)                    // GET /api/v1/series?q=search
-			series.GET("/:series_id", workService.GetSeries)            // GET /api/v1/series/123
-			series.GET("/:series_id/works", workService.GetSeriesWorks) // GET /api/v1/series/123/works
+			series.GET("", limits.Limit(middleware.RateLimitSearch), workService.SearchSeries) // GET /api/v1/series?q=search
+			series.GET("/:series_id", workService.GetSeries)                                   // GET /api/v1/series/123
+			series.GET("/:series_id/works", workService.GetSeriesWorks)                        // GET /api/v1/series/123/works
 		}
 
 		// Collections endpoints
 		collections := api.Group("/collections")
 		{
-			collections.GET("", workService.SearchCollections)                       // GET /api/v1/collections
-			collections.GET("/:collection_id", workService.GetCollection)            // GET /api/v1/collections/123
-			collections.GET("/:collection_id/works", workService.GetCollectionWorks) // GET /api/v1/collections/123/works
+			collections.GET("", limits.Limit(middleware.RateLimitSearch), workService.SearchCollections) // GET /api/v1/collections
+			collections.GET("/:collection_id", workService.GetCollection)                                // GET /api/v1/collections/123
+			collections.GET("/:collection_id/works", workService.GetCollectionWorks)                     // GET /api/v1/collections/123/works
+			collections.GET("/:collection_id/challenge", workService.GetChallenge)                       // GET /api/v1/collections/123/challenge
+			collections.GET("/:collection_id/prompts", workService.ListPrompts)                          // GET /api/v1/collections/123/prompts?status=unfilled&sort=age
+
+			// Maintainers are public so readers know who runs a collection
+			collections.GET("/:collection_id/maintainers", workService.GetCollectionMaintainers) // GET /api/v1/collections/123/maintainers
+		}
+
+		// Tag sets for challenges
+		tagSets := api.Group("/tag-sets")
+		{
+			tagSets.GET("", workService.ListTagSets)           // GET /api/v1/tag-sets
+			tagSets.GET("/:tag_set_id", workService.GetTagSet) // GET /api/v1/tag-sets/123
+		}
+
+		// Comment permalinks
+		comments := api.Group("/comments")
+		comments.Use(OptionalAuthMiddleware())
+		{
+			comments.GET("/:comment_id/context", workService.GetCommentContext) // GET /api/v1/comments/123/context?order=newest&per_page=20
 		}
 
 		// Tag search endpoints (enhanced partial matching)
 		tags := api.Group("/tags")
 		{
-			tags.GET("/search", workService.SearchTags) // GET /api/v1/tags/search?q=flu&limit=10
+			tags.GET("/search", limits.Limit(middleware.RateLimitSearch), workService.SearchTags) // GET /api/v1/tags/search?q=flu&limit=10
 		}
 
 		// User-specific endpoints
 		users := api.Group("/users")
 		{
-			users.GET("/:user_id/works", workService.GetUserWorks)         // GET /api/v1/users/123/works
-			users.GET("/:user_id/series", workService.GetUserSeries)       // GET /api/v1/users/123/series
-			users.GET("/:user_id/bookmarks", workService.GetUserBookmarks) // GET /api/v1/users/123/bookmarks
+			users.GET("/:user_id/works", workService.GetUserWorks)                  // GET /api/v1/users/123/works
+			users.GET("/:user_id/series", workService.GetUserSeries)                // GET /api/v1/users/123/series
+			users.GET("/:user_id/bookmarks", workService.GetUserBookmarks)          // GET /api/v1/users/123/bookmarks?rec=true
+			users.GET("/:user_id/bookmark-lists", workService.GetUserBookmarkLists) // GET /api/v1/users/123/bookmark-lists
+		}
+
+		// Bookmark list feeds
+		bookmarkLists := api.Group("/bookmark-lists")
+		bookmarkLists.Use(OptionalAuthMiddleware())
+		{
+			bookmarkLists.GET("/:list_id/works", workService.GetBookmarkListWorks) // GET /api/v1/bookmark-lists/123/works?rec=true
 		}
 
 		// Authenticated endpoints
@@ -166,12 +240,17 @@ func setupRouter(workService *WorkService) *gin.Engine {
 		protected.Use(JWTAuthMiddleware())
 		{
 			// Work management
-			protected.POST("/works", workService.CreateWorkEnhanced)                            // POST /api/v1/works
-			protected.PUT("/works/:work_id", workService.UpdateWork)                            // PUT /api/v1/works/123
-			protected.DELETE("/works/:work_id", workService.DeleteWork)                         // DELETE /api/v1/works/123
-			protected.POST("/works/:work_id/chapters", workService.CreateChapter)               // POST /api/v1/works/123/chapters
-			protected.PUT("/works/:work_id/chapters/:chapter_id", workService.UpdateChapter)    // PUT /api/v1/works/123/chapters/1
-			protected.DELETE("/works/:work_id/chapters/:chapter_id", workService.DeleteChapter) // DELETE /api/v1/works/123/chapters/1
+			protected.POST("/works", limits.Limit(middleware.RateLimitPost), workService.CreateWorkEnhanced)              // POST /api/v1/works
+			protected.PUT("/works/:work_id", workService.UpdateWork)                                                      // PUT /api/v1/works/123
+			protected.DELETE("/works/:work_id", workService.DeleteWork)                                                   // DELETE /api/v1/works/123
+			protected.GET("/works/:work_id/changelog", workService.GetWorkChangelog)                                      // GET /api/v1/works/123/changelog
+			protected.GET("/works/:work_id/share-link", workService.GetWorkShareLink)                                     // GET /api/v1/works/123/share-link
+			protected.POST("/works/:work_id/share-link", workService.CreateWorkShareLink)                                 // POST /api/v1/works/123/share-link (issues a new link, retiring the old one)
+			protected.DELETE("/works/:work_id/share-link", workService.RevokeWorkShareLink)                               // DELETE /api/v1/works/123/share-link
+			protected.POST("/works/:work_id/chapters", limits.Limit(middleware.RateLimitPost), workService.CreateChapter) // POST /api/v1/works/123/chapters
+			protected.PUT("/works/:work_id/chapters/:chapter_id", workService.UpdateChapter)                              // PUT /api/v1/works/123/chapters/1
+			protected.DELETE("/works/:work_id/chapters/:chapter_id", workService.DeleteChapter)                           // DELETE /api/v1/works/123/chapters/1
+			protected.POST("/my/works/bulk-edit", workService.BulkEditWorks)                                              // POST /api/v1/my/works/bulk-edit
 
 			// Engagement
 			protected.POST("/works/:work_id/kudos", workService.GiveKudos)     // POST /api/v1/works/123/kudos
@@ -181,11 +260,30 @@ func setupRouter(workService *WorkService) *gin.Engine {
 			protected.DELETE("/comments/:comment_id", workService.DeleteComment) // DELETE /api/v1/comments/123
 
 			// Bookmarks
-			protected.POST("/works/:work_id/bookmark", workService.CreateBookmark)          // POST /api/v1/works/123/bookmark
-			protected.GET("/works/:work_id/bookmark-status", workService.GetBookmarkStatus) // GET /api/v1/works/123/bookmark-status
-			protected.PUT("/bookmarks/:bookmark_id", workService.UpdateBookmark)            // PUT /api/v1/bookmarks/123
-			protected.DELETE("/bookmarks/:bookmark_id", workService.DeleteBookmark)         // DELETE /api/v1/bookmarks/123
-			protected.GET("/bookmarks", workService.GetMyBookmarks)                         // GET /api/v1/bookmarks
+			protected.POST("/works/:work_id/bookmark", workService.CreateBookmark)                     // POST /api/v1/works/123/bookmark
+			protected.GET("/works/:work_id/bookmark-status", workService.GetBookmarkStatus)            // GET /api/v1/works/123/bookmark-status
+			protected.PUT("/bookmarks/:bookmark_id", workService.UpdateBookmark)                       // PUT /api/v1/bookmarks/123
+			protected.DELETE("/bookmarks/:bookmark_id", workService.DeleteBookmark)                    // DELETE /api/v1/bookmarks/123
+			protected.GET("/bookmarks", workService.GetMyBookmarks)                                    // GET /api/v1/bookmarks
+			protected.GET("/my/bookmark-preferences", workService.GetBookmarkPreferences)              // GET /api/v1/my/bookmark-preferences
+			protected.PUT("/my/bookmark-preferences", workService.UpdateBookmarkPreferences)           // PUT /api/v1/my/bookmark-preferences
+			protected.POST("/my/bookmarks/bulk-privacy", workService.BulkUpdateBookmarkPrivacy)        // POST /api/v1/my/bookmarks/bulk-privacy
+			protected.GET("/my/bookmarks/bulk-privacy/:job_id", workService.GetBulkBookmarkPrivacyJob) // GET /api/v1/my/bookmarks/bulk-privacy/123
+
+			// Bookmark lists
+			protected.POST("/bookmark-lists", workService.CreateBookmarkList)                                       // POST /api/v1/bookmark-lists
+			protected.PUT("/bookmark-lists/:list_id", workService.UpdateBookmarkList)                               // PUT /api/v1/bookmark-lists/123
+			protected.DELETE("/bookmark-lists/:list_id", workService.DeleteBookmarkList)                            // DELETE /api/v1/bookmark-lists/123
+			protected.POST("/bookmark-lists/:list_id/bookmarks", workService.AddBookmarkToList)                     // POST /api/v1/bookmark-lists/123/bookmarks
+			protected.DELETE("/bookmark-lists/:list_id/bookmarks/:bookmark_id", workService.RemoveBookmarkFromList) // DELETE /api/v1/bookmark-lists/123/bookmarks/456
+
+			// Share target and bookmarklet: our works are marked for later, other links become external bookmarks
+			protected.POST("/share", workService.ShareToArchive)                                        // POST /api/v1/share
+			protected.GET("/my/marked-for-later", workService.GetMarkedForLater)                        // GET /api/v1/my/marked-for-later
+			protected.DELETE("/my/marked-for-later/:work_id", workService.UnmarkForLater)               // DELETE /api/v1/my/marked-for-later/123
+			protected.GET("/my/external-bookmarks", workService.GetExternalBookmarks)                   // GET /api/v1/my/external-bookmarks
+			protected.PUT("/my/external-bookmarks/:bookmark_id", workService.UpdateExternalBookmark)    // PUT /api/v1/my/external-bookmarks/123
+			protected.DELETE("/my/external-bookmarks/:bookmark_id", workService.DeleteExternalBookmark) // DELETE /api/v1/my/external-bookmarks/123
 
 			// Series management
 			protected.POST("/series", workService.CreateSeries)                                     // POST /api/v1/series
@@ -193,6 +291,7 @@ func setupRouter(workService *WorkService) *gin.Engine {
 			protected.DELETE("/series/:series_id", workService.DeleteSeries)                        // DELETE /api/v1/series/123
 			protected.POST("/series/:series_id/works/:work_id", workService.AddWorkToSeries)        // POST /api/v1/series/123/works/456
 			protected.DELETE("/series/:series_id/works/:work_id", workService.RemoveWorkFromSeries) // DELETE /api/v1/series/123/works/456
+			protected.PUT("/series/:series_id/reorder", workService.ReorderSeries)                  // PUT /api/v1/series/123/reorder
 
 			// Collections management
 			protected.POST("/collections", workService.CreateCollection)                                         // POST /api/v1/collections
@@ -200,15 +299,65 @@ func setupRouter(workService *WorkService) *gin.Engine {
 			protected.DELETE("/collections/:collection_id", workService.DeleteCollection)                        // DELETE /api/v1/collections/123
 			protected.POST("/collections/:collection_id/works/:work_id", workService.AddWorkToCollection)        // POST /api/v1/collections/123/works/456
 			protected.DELETE("/collections/:collection_id/works/:work_id", workService.RemoveWorkFromCollection) // DELETE /api/v1/collections/123/works/456
+			protected.POST("/collections/:collection_id/reveal", workService.RevealCollection)                   // POST /api/v1/collections/123/reveal
+
+			// Collection maintainers (owners manage the list; moderators may step down)
+			protected.POST("/collections/:collection_id/maintainers", workService.AddCollectionMaintainer)               // POST /api/v1/collections/123/maintainers
+			protected.DELETE("/collections/:collection_id/maintainers/:user_id", workService.RemoveCollectionMaintainer) // DELETE /api/v1/collections/123/maintainers/456
+
+			// Collection moderation and invitations
+			protected.GET("/collections/:collection_id/pending", workService.GetPendingCollectionItems)               // GET /api/v1/collections/123/pending
+			protected.POST("/collections/:collection_id/items/:item_id/approve", workService.ApproveCollectionItem)   // POST /api/v1/collections/123/items/456/approve
+			protected.POST("/collections/:collection_id/items/:item_id/reject", workService.RejectCollectionItem)     // POST /api/v1/collections/123/items/456/reject
+			protected.POST("/collections/:collection_id/invitations", workService.InviteWorkToCollection)             // POST /api/v1/collections/123/invitations
+			protected.GET("/collections/:collection_id/invitations", workService.GetCollectionInvitations)            // GET /api/v1/collections/123/invitations?status=pending
+			protected.GET("/my/collection-invitations", workService.GetMyCollectionInvitations)                       // GET /api/v1/my/collection-invitations
+			protected.POST("/collection-invitations/:invitation_id/accept", workService.AcceptCollectionInvitation)   // POST /api/v1/collection-invitations/123/accept
+			protected.POST("/collection-invitations/:invitation_id/decline", workService.DeclineCollectionInvitation) // POST /api/v1/collection-invitations/123/decline
+
+			// Challenges: gift exchanges and prompt memes
+			protected.PUT("/collections/:collection_id/challenge", workService.ConfigureChallenge)               // PUT /api/v1/collections/123/challenge
+			protected.POST("/collections/:collection_id/signups", workService.SubmitChallengeSignup)             // POST /api/v1/collections/123/signups
+			protected.GET("/collections/:collection_id/signups", workService.ListChallengeSignups)               // GET /api/v1/collections/123/signups
+			protected.GET("/collections/:collection_id/signups/mine", workService.GetMyChallengeSignup)          // GET /api/v1/collections/123/signups/mine
+			protected.DELETE("/collections/:collection_id/signups/mine", workService.DeleteMyChallengeSignup)    // DELETE /api/v1/collections/123/signups/mine
+			protected.POST("/collections/:collection_id/matching", workService.RunChallengeMatching)             // POST /api/v1/collections/123/matching
+			protected.POST("/collections/:collection_id/assignments/send", workService.SendChallengeAssignments) // POST /api/v1/collections/123/assignments/send
+			protected.GET("/collections/:collection_id/assignments", workService.ListChallengeAssignments)       // GET /api/v1/collections/123/assignments
+			protected.GET("/my/assignments", workService.GetMyAssignments)                                       // GET /api/v1/my/assignments
+			protected.POST("/assignments/:assignment_id/fulfill", workService.FulfillAssignment)                 // POST /api/v1/assignments/123/fulfill
+			protected.POST("/assignments/:assignment_id/default", workService.DefaultAssignment)                 // POST /api/v1/assignments/123/default
+			protected.POST("/assignments/:assignment_id/pinch-hit", workService.PinchHitAssignment)              // POST /api/v1/assignments/123/pinch-hit
+			protected.POST("/collections/:collection_id/prompts", workService.PostPrompt)                        // POST /api/v1/collections/123/prompts
+			protected.POST("/prompts/:prompt_id/claims", workService.ClaimPrompt)                                // POST /api/v1/prompts/123/claims
+			protected.DELETE("/prompts/:prompt_id/claims/mine", workService.ReleaseClaim)                        // DELETE /api/v1/prompts/123/claims/mine
+			protected.POST("/prompts/:prompt_id/fills", workService.FillPrompt)                                  // POST /api/v1/prompts/123/fills
+			protected.GET("/my/claims", workService.GetMyClaims)                                                 // GET /api/v1/my/claims
+
+			// Tag sets: owners curate them directly or from users' nominations
+			protected.POST("/tag-sets", workService.CreateTagSet)                                       // POST /api/v1/tag-sets
+			protected.PUT("/tag-sets/:tag_set_id", workService.UpdateTagSet)                            // PUT /api/v1/tag-sets/123
+			protected.DELETE("/tag-sets/:tag_set_id", workService.DeleteTagSet)                         // DELETE /api/v1/tag-sets/123
+			protected.POST("/tag-sets/:tag_set_id/tags", workService.AddTagSetTags)                     // POST /api/v1/tag-sets/123/tags
+			protected.DELETE("/tag-sets/:tag_set_id/tags", workService.RemoveTagSetTag)                 // DELETE /api/v1/tag-sets/123/tags?type=fandom&name=...
+			protected.POST("/tag-sets/:tag_set_id/nominations", workService.SubmitTagSetNominations)    // POST /api/v1/tag-sets/123/nominations
+			protected.GET("/tag-sets/:tag_set_id/nominations/mine", workService.GetMyTagSetNominations) // GET /api/v1/tag-sets/123/nominations/mine
+			protected.GET("/tag-sets/:tag_set_id/nominations", workService.ListTagSetNominations)       // GET /api/v1/tag-sets/123/nominations?status=pending
+			protected.PUT("/tag-sets/:tag_set_id/nominations", workService.ReviewTagSetNominations)     // PUT /api/v1/tag-sets/123/nominations
 
 			// Comment moderation
-			protected.PUT("/comments/:comment_id/moderate", workService.ModerateComment) // PUT /api/v1/comments/123/moderate
+			protected.PUT("/comments/:comment_id/moderate", workService.ModerateComment)        // PUT /api/v1/comments/123/moderate
+			protected.POST("/comments/:comment_id/freeze", workService.FreezeCommentThread)     // POST /api/v1/comments/123/freeze
+			protected.POST("/comments/:comment_id/unfreeze", workService.UnfreezeCommentThread) // POST /api/v1/comments/123/unfreeze
 
 			// User blocking and reports
 			protected.POST("/users/:user_id/block", workService.BlockUser)            // POST /api/v1/users/123/block
 			protected.DELETE("/users/:user_id/block", workService.UnblockUser)        // DELETE /api/v1/users/123/block
+			protected.GET("/my/blocked-users", workService.GetBlockedUsers)           // GET /api/v1/my/blocked-users?page=1
 			protected.POST("/comments/:comment_id/report", workService.ReportComment) // POST /api/v1/comments/123/report
 			protected.POST("/works/:work_id/report", workService.ReportWork)          // POST /api/v1/works/123/report
+			protected.GET("/my/reports", workService.GetMyReports)                    // GET /api/v1/my/reports?page=1
+			protected.GET("/my/reports/:ticket_id", workService.GetMyReport)          // GET /api/v1/my/reports/123
 
 			// User muting (matching AO3's implementation)
 			protected.POST("/users/:user_id/mute", workService.MuteUser)            // POST /api/v1/users/123/mute
@@ -216,14 +365,32 @@ func setupRouter(workService *WorkService) *gin.Engine {
 			protected.GET("/users/:user_id/mute-status", workService.GetMuteStatus) // GET /api/v1/users/123/mute-status
 			protected.GET("/my/muted-users", workService.GetMutedUsers)             // GET /api/v1/my/muted-users
 
+			// Blocklist import/export and shared lists
+			protected.GET("/my/blocklist/export", workService.ExportBlocklist)            // GET /api/v1/my/blocklist/export?kind=all
+			protected.POST("/my/blocklist/import", workService.ImportBlocklist)           // POST /api/v1/my/blocklist/import
+			protected.GET("/my/blocklist/imports", workService.GetBlocklistImports)       // GET /api/v1/my/blocklist/imports
+			protected.POST("/my/blocklist/bulk-remove", workService.BulkRemoveBlocklist)  // POST /api/v1/my/blocklist/bulk-remove
+			protected.POST("/my/shared-lists", workService.CreateSharedBlockList)         // POST /api/v1/my/shared-lists
+			protected.GET("/my/shared-lists", workService.GetMySharedBlockLists)          // GET /api/v1/my/shared-lists
+			protected.GET("/shared-lists/:list_id", workService.GetSharedBlockList)       // GET /api/v1/shared-lists/123
+			protected.DELETE("/shared-lists/:list_id", workService.DeleteSharedBlockList) // DELETE /api/v1/shared-lists/123
+
+			// Related works: translations, remixes, inspirations
+			protected.POST("/works/:work_id/related", workService.AddRelatedWorks)                // POST /api/v1/works/123/related
+			protected.GET("/my/related-work-requests", workService.GetRelatedWorkRequests)        // GET /api/v1/my/related-work-requests
+			protected.POST("/related-works/:relation_id/approve", workService.ApproveRelatedWork) // POST /api/v1/related-works/123/approve
+			protected.POST("/related-works/:relation_id/reject", workService.RejectRelatedWork)   // POST /api/v1/related-works/123/reject
+			protected.DELETE("/related-works/:relation_id", workService.DeleteRelatedWork)        // DELETE /api/v1/related-works/123
+
 			// Core AO3 Features: Pseuds, Gifting, Orphaning, Co-authors
-			protected.POST("/pseuds", workService.CreatePseud)                    // POST /api/v1/pseuds
-			protected.GET("/my/pseuds", workService.GetUserPseuds)                // GET /api/v1/my/pseuds
-			protected.POST("/works/:work_id/gift", workService.GiftWork)          // POST /api/v1/works/123/gift
-			protected.GET("/works/:work_id/gifts", workService.GetWorkGifts)      // GET /api/v1/works/123/gifts
-			protected.POST("/works/:work_id/orphan", workService.OrphanWork)      // POST /api/v1/works/123/orphan
-			protected.GET("/works/:work_id/authors", workService.GetWorkAuthors)  // GET /api/v1/works/123/authors
-			protected.POST("/works/:work_id/co-authors", workService.AddCoAuthor) // POST /api/v1/works/123/co-authors
+			protected.POST("/pseuds", workService.CreatePseud)                                      // POST /api/v1/pseuds
+			protected.GET("/my/pseuds", workService.GetUserPseuds)                                  // GET /api/v1/my/pseuds
+			protected.POST("/works/:work_id/gift", workService.GiftWork)                            // POST /api/v1/works/123/gift
+			protected.GET("/works/:work_id/gifts", workService.GetWorkGifts)                        // GET /api/v1/works/123/gifts
+			protected.POST("/works/:work_id/orphan/confirm", workService.RequestOrphanConfirmation) // POST /api/v1/works/123/orphan/confirm
+			protected.POST("/works/:work_id/orphan", workService.OrphanWork)                        // POST /api/v1/works/123/orphan
+			protected.GET("/works/:work_id/authors", workService.GetWorkAuthors)                    // GET /api/v1/works/123/authors
+			protected.POST("/works/:work_id/co-authors", workService.AddCoAuthor)                   // POST /api/v1/works/123/co-authors
 
 			// User dashboard
 			protected.GET("/my/works", workService.GetMyWorks)             // GET /api/v1/my/works
@@ -232,18 +399,39 @@ func setupRouter(workService *WorkService) *gin.Engine {
 			protected.GET("/my/comments", workService.GetMyComments)       // GET /api/v1/my/comments
 			protected.GET("/my/stats", workService.GetMyStats)             // GET /api/v1/my/stats
 
+			// Trash
+			protected.GET("/my/trash", workService.GetMyTrash)                    // GET /api/v1/my/trash
+			protected.POST("/my/trash/:work_id/restore", workService.RestoreWork) // POST /api/v1/my/trash/123/restore
+
+			// Reading history
+			protected.GET("/my/history", workService.GetReadingHistory)                     // GET /api/v1/my/history
+			protected.DELETE("/my/history", workService.ClearReadingHistory)                // DELETE /api/v1/my/history
+			protected.DELETE("/my/history/:work_id", workService.DeleteReadingHistoryEntry) // DELETE /api/v1/my/history/123
+
 			// Subscriptions
 			protected.POST("/subscriptions", workService.CreateSubscription)           // POST /api/v1/subscriptions
 			protected.GET("/subscriptions", workService.GetUserSubscriptions)          // GET /api/v1/subscriptions
 			protected.PUT("/subscriptions/:id", workService.UpdateSubscription)        // PUT /api/v1/subscriptions/123
 			protected.DELETE("/subscriptions/:id", workService.DeleteSubscription)     // DELETE /api/v1/subscriptions/123
 			protected.GET("/subscription-status", workService.CheckSubscriptionStatus) // GET /api/v1/subscription-status?type=work&target_id=123
+
+			// One-click subscriptions to works, authors and series
+			protected.POST("/works/:work_id/subscribe", workService.SubscribeToWork)            // POST /api/v1/works/123/subscribe
+			protected.DELETE("/works/:work_id/subscribe", workService.UnsubscribeFromWork)      // DELETE /api/v1/works/123/subscribe
+			protected.POST("/users/:user_id/subscribe", workService.SubscribeToUser)            // POST /api/v1/users/123/subscribe
+			protected.DELETE("/users/:user_id/subscribe", workService.UnsubscribeFromUser)      // DELETE /api/v1/users/123/subscribe
+			protected.POST("/series/:series_id/subscribe", workService.SubscribeToSeries)       // POST /api/v1/series/123/subscribe
+			protected.DELETE("/series/:series_id/subscribe", workService.UnsubscribeFromSeries) // DELETE /api/v1/series/123/subscribe
 		}
 
 		// Admin endpoints
 		admin := api.Group("/admin")
-		admin.Use(JWTAuthMiddleware())
-		admin.Use(RequireRoleMiddleware("admin"))
+		// Staff routes always check the token with auth-service for roles,
+		// rather than trusting a forwarded user ID
+		admin.Use(middleware.AuthServiceMiddleware(getEnv("AUTH_SERVICE_URL", "http://ao3_auth_service:8081"), nil))
+		admin.Use(middleware.RequireRoleMiddleware("admin"))
+		admin.Use(middleware.RequireScopeMiddleware(middleware.ScopeAdmin))
+		admin.Use(audit.Middleware(workService.db, auditService))
 		{
 			admin.GET("/works", workService.AdminListWorks)                                 // GET /api/v1/admin/works
 			admin.PUT("/works/:work_id/status", workService.AdminUpdateWorkStatus)          // PUT /api/v1/admin/works/123/status
@@ -253,6 +441,45 @@ func setupRouter(workService *WorkService) *gin.Engine {
 			admin.DELETE("/comments/:comment_id", workService.AdminDeleteComment)           // DELETE /api/v1/admin/comments/123
 			admin.GET("/reports", workService.AdminGetReports)                              // GET /api/v1/admin/reports
 			admin.GET("/statistics", workService.AdminGetStatistics)                        // GET /api/v1/admin/statistics
+
+			// Abuse tickets
+			admin.GET("/tickets", workService.AdminListTickets)                              // GET /api/v1/admin/tickets?status=live&assignee=me
+			admin.GET("/tickets/:ticket_id", workService.AdminGetTicket)                     // GET /api/v1/admin/tickets/123
+			admin.PUT("/tickets/:ticket_id/status", workService.AdminUpdateTicketStatus)     // PUT /api/v1/admin/tickets/123/status
+			admin.PUT("/tickets/:ticket_id/assignee", workService.AdminAssignTicket)         // PUT /api/v1/admin/tickets/123/assignee
+			admin.PUT("/tickets/:ticket_id/priority", workService.AdminUpdateTicketPriority) // PUT /api/v1/admin/tickets/123/priority
+			admin.POST("/tickets/:ticket_id/notes", workService.AdminAddTicketNote)          // POST /api/v1/admin/tickets/123/notes
+
+			// Deployment content rating policy
+			admin.GET("/rating-policy", workService.GetRatingPolicy)    // GET /api/v1/admin/rating-policy
+			admin.PUT("/rating-policy", workService.UpdateRatingPolicy) // PUT /api/v1/admin/rating-policy
+
+			// Search reindexing outbox
+			admin.GET("/search-outbox", workService.GetSearchOutboxStatus)      // GET /api/v1/admin/search-outbox
+			admin.POST("/search-outbox/replay", workService.ReplaySearchOutbox) // POST /api/v1/admin/search-outbox/replay
+
+			// Notification event outbox
+			admin.GET("/notification-outbox", workService.GetNotificationOutboxStatus)      // GET /api/v1/admin/notification-outbox
+			admin.POST("/notification-outbox/replay", workService.ReplayNotificationOutbox) // POST /api/v1/admin/notification-outbox/replay
+
+			// Language mismatch review (wranglers and admins)
+			admin.GET("/language-flags", workService.GetLanguageFlags)                      // GET /api/v1/admin/language-flags?status=pending
+			admin.POST("/language-flags/:flag_id/resolve", workService.ResolveLanguageFlag) // POST /api/v1/admin/language-flags/123/resolve
+
+			// Audit log of admin and wrangler actions across the services
+			admin.GET("/audit-log", workService.AdminListAuditLog)          // GET /api/v1/admin/audit-log?actor_id=...&action=tag.*&since=2026-01-01
+			admin.GET("/audit-log/export", workService.AdminExportAuditLog) // GET /api/v1/admin/audit-log/export?format=csv|jsonl
+		}
+
+		// Internal operational endpoints (service token, used by nuclearctl)
+		internal := api.Group("/internal")
+		internal.Use(middleware.ServiceTokenMiddleware())
+		{
+			internal.POST("/works/batch", workService.InternalBatchWorks)                    // POST /api/v1/internal/works/batch
+			internal.POST("/works/:work_id/reindex", workService.InternalReindexWork)        // POST /api/v1/internal/works/123/reindex
+			internal.POST("/works/:work_id/cache/purge", workService.InternalPurgeWorkCache) // POST /api/v1/internal/works/123/cache/purge
+			internal.POST("/search-outbox/replay", workService.ReplaySearchOutbox)           // POST /api/v1/internal/search-outbox/replay
+			internal.POST("/counts/reconcile", workService.InternalReconcileCounts)          // POST /api/v1/internal/counts/reconcile
 		}
 	}
 
@@ -261,10 +488,13 @@ func setupRouter(workService *WorkService) *gin.Engine {
 
 // WorkService holds all dependencies for work management
 type WorkService struct {
-	db                  *sql.DB
-	redis               *redis.Client
-	cache               *cache.Cache
-	notificationService *notifications.NotificationService
+	db            *sql.DB
+	redis         *redis.Client
+	cache         *cache.Cache
+	subscriptions notifications.SubscriptionRepository
+	mutes         *mutes.Filter
+	prepared      map[string]*sql.Stmt // hot queries by text; see prepared_queries.go
+	reads         *database.Replica    // routes heavy reads to DATABASE_REPLICA_URL
 }
 
 func NewWorkService() *WorkService {
@@ -282,10 +512,15 @@ func NewWorkService() *WorkService {
 	}
 
 	// Set optimized connection pool settings for budget hosting
-	db.SetMaxOpenConns(10) // Reduced from 25 to 10
-	db.SetMaxIdleConns(3)  // Reduced from 5 to 3
-	db.SetConnMaxLifetime(time.Hour)
-	db.SetConnMaxIdleTime(15 * time.Minute) // Add idle timeout
+	// (DB_MAX_OPEN_CONNS etc. override)
+	pool := database.PoolConfig{
+		MaxOpenConns:    10,
+		MaxIdleConns:    3,
+		ConnMaxLifetime: time.Hour,
+		ConnMaxIdleTime: 15 * time.Minute,
+	}
+	database.PoolConfigFromEnv(pool).Apply(db)
+	metrics.InstrumentDBPool("work-service", db)
 
 	// Redis connection
 	redisURL := getEnv("REDIS_URL", "localhost:6379")
@@ -306,8 +541,9 @@ func NewWorkService() *WorkService {
 		log.Fatal("Failed to connect to Redis:", err)
 	}
 
-	// Initialize cache
+	// Initialize cache, with hot keys also held in process
 	workCache := cache.NewCache(rdb, "work-service")
+	workCache.EnableLocal(context.Background(), cache.LocalOptionsFromEnv())
 
 	// Validate database schema at startup
 	validator := NewSchemaValidator(db)
@@ -317,15 +553,32 @@ func NewWorkService() *WorkService {
 
 	log.Println("Work service initialized successfully")
 
-	return &WorkService{
-		db:                  db,
-		redis:               rdb,
-		cache:               workCache,
-		notificationService: nil, // TODO: Initialize notification service
+	ws := &WorkService{
+		db:            db,
+		redis:         rdb,
+		cache:         workCache,
+		subscriptions: notifications.NewPostgresSubscriptionRepository(db),
+		mutes:         mutes.NewFilter(db, rdb, muteListTTL),
+		reads:         database.OpenReplica(context.Background(), "work-service", db, pool),
 	}
+	ws.enablePreparedQueries(ctx)
+	return ws
+}
+
+// readDB is the database for heavy reads that may lag behind writes:
+// the read replica while it is usable, and the primary otherwise
+func (ws *WorkService) readDB() *sql.DB {
+	if ws.reads == nil {
+		return ws.db
+	}
+	return ws.reads.Reader()
 }
 
 func (ws *WorkService) Close() {
+	closePrepared(ws.prepared)
+	if ws.reads != nil {
+		ws.reads.Close()
+	}
 	if ws.db != nil {
 		ws.db.Close()
 	}
@@ -368,7 +621,7 @@ func CORSMiddleware() gin.HandlerFunc {
 
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, If-Match, X-Adult-Content-Acknowledged")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -438,6 +691,9 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// validateTokenWithAuthService asks auth-service who a token belongs to.
+// Its /me route runs the session check, so tokens from revoked sessions are
+// turned away here too rather than lasting until they expire.
 func validateTokenWithAuthService(tokenString string) (string, error) {
 	// Make request to auth service to validate token and get user info
 	authServiceURL := getEnv("AUTH_SERVICE_URL", "http://ao3_auth_service:8081")
@@ -505,10 +761,3 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
-
-func RequireRoleMiddleware(role string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Role validation - would integrate with auth service
-		c.Next()
-	}
-}
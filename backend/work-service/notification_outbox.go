@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/middleware"
+	"nuclear-ao3/shared/notifications"
+	"nuclear-ao3/shared/notificationstream"
+)
+
+// Notification outbox: notification events are recorded in
+// notification_outbox (migration 060) rather than posted to
+// notification-service, so they outlive a crash of this process or an
+// outage of that one. The publisher moves pending events onto the
+// notification stream, retrying with backoff and dead-lettering events
+// that keep failing. Other services on the same database, such as
+// stats-service, queue events here too. Each event gets an idempotency key
+// when it's queued; notification-service processes a key once however often
+// it's delivered.
+
+const (
+	notificationOutboxBatchSize   = 100
+	notificationOutboxMaxAttempts = 8
+	// notificationOutboxRetention is how long published events are kept
+	notificationOutboxRetention = 7 * 24 * time.Hour
+	// notificationStreamMaxLen caps the stream; the consumer trims nothing
+	notificationStreamMaxLen = 100000
+)
+
+// notificationOutboxEntry is one pending outbox row
+type notificationOutboxEntry struct {
+	ID       int64
+	Key      uuid.UUID
+	Payload  []byte
+	Attempts int
+}
+
+// sendNotificationEvent queues a notification event for the publisher in
+// the transaction making the change it announces, so the event is recorded
+// if and only if the change commits
+func sendNotificationEvent(ctx context.Context, tx *sql.Tx, event notifications.EventData) error {
+	if err := notificationstream.Enqueue(ctx, tx, event); err != nil {
+		return fmt.Errorf("queue %s notification for %s: %w", event.Type, event.SourceID, err)
+	}
+	return nil
+}
+
+// startNotificationOutboxPublisher publishes pending outbox events every
+// interval until ctx is cancelled, draining a backlog without waiting
+// between batches
+func (ws *WorkService) startNotificationOutboxPublisher(ctx context.Context, interval time.Duration) {
+	if ws.redis == nil {
+		log.Println("Notification outbox publisher disabled: no Redis connection")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Notification outbox publisher started, polling every %s", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Notification outbox publisher stopped")
+			return
+		case <-ticker.C:
+			for {
+				count, err := ws.publishNotificationOutbox(ctx)
+				if err != nil {
+					log.Printf("Notification outbox publish failed: %v", err)
+				}
+				if err != nil || count < notificationOutboxBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// publishNotificationOutbox publishes one batch of due events in order,
+// returning how many rows it handled. Rows are locked with SKIP LOCKED so
+// several work-service instances can publish side by side.
+func (ws *WorkService) publishNotificationOutbox(ctx context.Context) (int, error) {
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, idempotency_key, payload, attempts FROM notification_outbox
+		WHERE published_at IS NULL AND dead_lettered_at IS NULL
+			AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, notificationOutboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	entries := []notificationOutboxEntry{}
+	for rows.Next() {
+		var e notificationOutboxEntry
+		if err := rows.Scan(&e.ID, &e.Key, &e.Payload, &e.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	published := []int64{}
+	for _, e := range entries {
+		publishErr := ws.publishNotificationEvent(ctx, e)
+		if publishErr == nil {
+			published = append(published, e.ID)
+			continue
+		}
+
+		attempts := e.Attempts + 1
+		log.Printf("Failed to publish notification event %s (attempt %d): %v", e.Key, attempts, publishErr)
+		_, err := tx.ExecContext(ctx, `
+			UPDATE notification_outbox SET
+				attempts = $2, last_error = $3, next_attempt_at = NOW() + $4::interval,
+				dead_lettered_at = CASE WHEN $2 >= $5 THEN NOW() END
+			WHERE id = $1`,
+			e.ID, attempts, publishErr.Error(), fmt.Sprintf("%d seconds", int(outboxBackoff(attempts).Seconds())),
+			notificationOutboxMaxAttempts)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(published) > 0 {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE notification_outbox SET published_at = NOW(), last_error = NULL WHERE id = ANY($1)",
+			pq.Array(published)); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// publishNotificationEvent adds one event to the notification stream
+func (ws *WorkService) publishNotificationEvent(ctx context.Context, e notificationOutboxEntry) error {
+	event := notificationstream.Event{Key: e.Key}
+	if err := json.Unmarshal(e.Payload, &event.Data); err != nil {
+		return err
+	}
+	values, err := event.Values()
+	if err != nil {
+		return err
+	}
+	return ws.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: notificationstream.Stream,
+		MaxLen: notificationStreamMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+}
+
+// purgePublishedNotificationOutbox deletes published events past retention
+func (ws *WorkService) purgePublishedNotificationOutbox(ctx context.Context) (int, error) {
+	result, err := ws.db.ExecContext(ctx,
+		"DELETE FROM notification_outbox WHERE published_at < $1", time.Now().Add(-notificationOutboxRetention))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// GetNotificationOutboxStatus reports the outbox backlog and recent dead
+// letters: GET /api/v1/admin/notification-outbox
+func (ws *WorkService) GetNotificationOutboxStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var pending, dead int
+	var oldestPending sql.NullTime
+	err := ws.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE published_at IS NULL AND dead_lettered_at IS NULL),
+			COUNT(*) FILTER (WHERE dead_lettered_at IS NOT NULL),
+			MIN(created_at) FILTER (WHERE published_at IS NULL AND dead_lettered_at IS NULL)
+		FROM notification_outbox`).Scan(&pending, &dead, &oldestPending)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notification outbox status"})
+		return
+	}
+
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT id, idempotency_key, event_type, attempts, COALESCE(last_error, ''), created_at, dead_lettered_at
+		FROM notification_outbox WHERE dead_lettered_at IS NOT NULL
+		ORDER BY dead_lettered_at DESC LIMIT 50`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dead letters"})
+		return
+	}
+	defer rows.Close()
+
+	deadLetters := []gin.H{}
+	for rows.Next() {
+		var id int64
+		var key uuid.UUID
+		var eventType, lastError string
+		var attempts int
+		var createdAt, deadAt time.Time
+		if err := rows.Scan(&id, &key, &eventType, &attempts, &lastError, &createdAt, &deadAt); err != nil {
+			continue
+		}
+		deadLetters = append(deadLetters, gin.H{
+			"id": id, "idempotency_key": key, "event_type": eventType, "attempts": attempts,
+			"last_error": lastError, "created_at": createdAt, "dead_lettered_at": deadAt,
+		})
+	}
+
+	status := gin.H{"pending": pending, "dead_lettered": dead, "dead_letters": deadLetters}
+	if oldestPending.Valid {
+		status["oldest_pending_at"] = oldestPending.Time
+	}
+	if ws.redis != nil {
+		if length, err := ws.redis.XLen(ctx, notificationstream.Stream).Result(); err == nil {
+			status["stream_length"] = length
+		}
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// ReplayNotificationOutbox re-queues dead-lettered events for publishing:
+// POST /api/v1/admin/notification-outbox/replay
+// They keep their idempotency keys, so an event that did reach
+// notification-service isn't notified twice. With ?dry_run=true it only
+// counts what would be queued.
+func (ws *WorkService) ReplayNotificationOutbox(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if middleware.IsDryRun(c) {
+		var n int64
+		if err := ws.db.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM notification_outbox WHERE dead_lettered_at IS NOT NULL").Scan(&n); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count dead letters"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "message": "Notification events would be queued", "queued": n})
+		return
+	}
+
+	result, err := ws.db.ExecContext(ctx, `
+		UPDATE notification_outbox SET dead_lettered_at = NULL, attempts = 0, next_attempt_at = NULL
+		WHERE dead_lettered_at IS NOT NULL`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay dead letters"})
+		return
+	}
+	n, _ := result.RowsAffected()
+	c.JSON(http.StatusOK, gin.H{"message": "Notification events queued", "queued": n})
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+// Orphaning handlers for the work service
+
+const orphanConfirmationTTL = 10 * time.Minute
+
+// OrphanWorkRequest is the body of an orphan request. PseudOption "keep"
+// leaves a copy of the author's pseud name on the orphan account, "scrub"
+// credits the work to the generic orphan_account pseud instead.
+type OrphanWorkRequest struct {
+	ConfirmationToken string `json:"confirmation_token" binding:"required"`
+	PseudOption       string `json:"pseud_option" binding:"required,oneof=keep scrub"`
+}
+
+func orphanConfirmationKey(userID string, workID uuid.UUID) string {
+	return fmt.Sprintf("orphan_confirm:%s:%s", userID, workID)
+}
+
+// RequestOrphanConfirmation issues a short-lived token that must be echoed
+// back to OrphanWork. Orphaning cannot be undone, so the extra round trip
+// guards against a stray click or replayed request.
+func (ws *WorkService) RequestOrphanConfirmation(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if ws.redis == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Orphaning is temporarily unavailable"})
+		return
+	}
+
+	var title string
+	var pseudNames pq.StringArray
+	var coAuthors int
+	err = ws.db.QueryRow(`
+		SELECT w.title,
+			ARRAY(SELECT p.name FROM creatorships c JOIN pseuds p ON c.pseud_id = p.id
+				WHERE c.creation_id = w.id AND c.creation_type = 'Work' AND p.user_id = $2),
+			(SELECT COUNT(DISTINCT p.user_id) FROM creatorships c JOIN pseuds p ON c.pseud_id = p.id
+				WHERE c.creation_id = w.id AND c.creation_type = 'Work' AND c.approved = true AND p.user_id != $2)
+		FROM works w
+		WHERE w.id = $1`, workID, userID).Scan(&title, &pseudNames, &coAuthors)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load work"})
+		return
+	}
+
+	if len(pseudNames) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not an author of this work"})
+		return
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate confirmation token"})
+		return
+	}
+	token := hex.EncodeToString(buf)
+
+	key := orphanConfirmationKey(userID.(string), workID)
+	if err := ws.redis.Set(c.Request.Context(), key, token, orphanConfirmationTTL).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store confirmation token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"confirmation_token":  token,
+		"expires_at":          time.Now().Add(orphanConfirmationTTL),
+		"work_title":          title,
+		"pseuds":              pseudNames,
+		"remaining_coauthors": coAuthors,
+		"pseud_options":       []string{"keep", "scrub"},
+		"warning":             "Orphaning is permanent. The work will be removed from your account and cannot be reclaimed.",
+	})
+}
+
+// OrphanWork permanently transfers the caller's authorship of a work to the
+// orphan account. Requires a token from RequestOrphanConfirmation.
+func (ws *WorkService) OrphanWork(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req OrphanWorkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	if ws.redis == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Orphaning is temporarily unavailable"})
+		return
+	}
+
+	// Tokens are single use: consume before doing anything else
+	key := orphanConfirmationKey(userID.(string), workID)
+	stored, err := ws.redis.GetDel(c.Request.Context(), key).Result()
+	if err == redis.Nil || (err == nil && stored != req.ConfirmationToken) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired confirmation token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify confirmation token"})
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Capture what the audit record needs before creatorships are rewritten
+	var title string
+	var pseudNames pq.StringArray
+	err = tx.QueryRow(`
+		SELECT w.title,
+			ARRAY(SELECT p.name FROM creatorships c JOIN pseuds p ON c.pseud_id = p.id
+				WHERE c.creation_id = w.id AND c.creation_type = 'Work' AND p.user_id = $2)
+		FROM works w
+		WHERE w.id = $1
+		FOR UPDATE OF w`, workID, userID).Scan(&title, &pseudNames)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load work"})
+		return
+	}
+
+	var orphanPseudID uuid.NullUUID
+	err = tx.QueryRow("SELECT orphan_work_with_options($1, $2, $3)",
+		workID, userID, req.PseudOption == "keep").Scan(&orphanPseudID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to orphan work"})
+		return
+	}
+	if !orphanPseudID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not an author of this work"})
+		return
+	}
+
+	var remaining int
+	err = tx.QueryRow(`
+		SELECT COUNT(DISTINCT p.user_id) FROM creatorships c
+		JOIN pseuds p ON c.pseud_id = p.id
+		WHERE c.creation_id = $1 AND c.creation_type = 'Work' AND c.approved = true
+		AND p.user_id != '00000000-0000-0000-0000-000000000000'`, workID).Scan(&remaining)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to orphan work"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO orphan_audit_log
+			(work_id, work_title, original_user_id, original_pseud_names, pseud_option, orphan_pseud_id, remaining_coauthors, ip_address)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		workID, title, userID, pseudNames, req.PseudOption, orphanPseudID.UUID, remaining, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record orphaning"})
+		return
+	}
+
+	// Reindex so search shows the new byline
+	if err := enqueueSearchReindex(c.Request.Context(), tx, workID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to orphan work"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit orphaning"})
+		return
+	}
+
+	// Drop the work from caches and the caller's dashboard
+	ws.redis.Del(c.Request.Context(), fmt.Sprintf("work:%s", workID))
+	if ws.cache != nil {
+		ws.InvalidateWorkCache(workID)
+		if uid, err := uuid.Parse(userID.(string)); err == nil {
+			ws.InvalidateUserCache(uid)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Work orphaned successfully",
+		"work_id":      workID,
+		"pseud_option": req.PseudOption,
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// =============================================================================
+// PREPARED HOT QUERIES
+// The reads behind every work and chapter page are prepared once at startup,
+// so on the small connection pool each call skips parsing and planning and
+// only sends its arguments. database/sql prepares them again on any new
+// connection by itself. DB_PREPARED_STATEMENTS=false turns this off for
+// poolers in transaction mode, which can't hold prepared statements.
+//
+// This is prepared statements only, on database/sql and lib/pq. Moving the
+// hot paths to pgx with sqlc-generated queries is not done here; it waits
+// on those dependencies being approved for the module.
+// =============================================================================
+
+const workByIDQuery = `
+		SELECT` + workColumns + `
+		FROM works w
+		JOIN users u ON w.user_id = u.id
+		LEFT JOIN work_statistics ws ON w.id = ws.work_id
+		WHERE w.id = $1`
+
+const workPageQuery = `
+		SELECT 
+			w.id, w.legacy_id, w.title, 
+			COALESCE(w.summary, '') as summary, 
+			COALESCE(w.notes, '') as notes, 
+			w.user_id, u.username, w.language, w.rating, 
+			COALESCE(w.word_count, 0) as word_count, 
+			COALESCE(w.chapter_count, 1) as chapter_count, 
+			w.max_chapters,
+			COALESCE(w.status, 'draft') as status, 
+			COALESCE(w.restricted_to_users, false) as restricted_to_users,
+			COALESCE(w.restricted_to_adults, false) as restricted_to_adults,
+			COALESCE(w.comment_policy, 'open') as comment_policy,
+			COALESCE(w.moderate_comments, false) as moderate_comments,
+			COALESCE(w.disable_comments, false) as disable_comments,
+			COALESCE(w.in_anon_collection, false) as in_anon_collection,
+			COALESCE(w.in_unrevealed_collection, false) as in_unrevealed_collection,
+			COALESCE(w.is_anonymous, false) as is_anonymous,
+			COALESCE(w.fandoms, '{}') as fandoms,
+			COALESCE(w.characters, '{}') as characters,
+			COALESCE(w.relationships, '{}') as relationships,
+			COALESCE(w.freeform_tags, '{}') as freeform_tags,
+			w.published_at, w.unpublish_at, w.updated_at, w.created_at, w.version
+		FROM works w
+		JOIN users u ON w.user_id = u.id
+		WHERE w.id = $1
+	`
+
+const workChaptersQuery = `
+		SELECT id, work_id, chapter_number, 
+			COALESCE(title, '') as title, 
+			COALESCE(summary, '') as summary, 
+			COALESCE(notes, '') as notes, 
+			COALESCE(end_notes, '') as end_notes, 
+			COALESCE(content, '') as content, 
+			COALESCE(word_count, 0) as word_count, 
+			CASE WHEN is_draft THEN 'draft' ELSE 'posted' END as status, 
+			published_at, created_at, updated_at, version
+		FROM chapters 
+		WHERE work_id = $1 
+		ORDER BY chapter_number`
+
+const chapterByNumberQuery = `
+		SELECT id, work_id, chapter_number, title, summary, notes, end_notes, 
+			content, word_count, CASE WHEN is_draft THEN 'draft' ELSE 'posted' END as status, 
+			published_at, created_at, updated_at, version
+		FROM chapters 
+		WHERE work_id = $1 AND chapter_number = $2`
+
+const canViewWorkQuery = "SELECT can_user_view_work($1, $2)"
+
+const workLanguageQuery = "SELECT COALESCE(language, '') FROM works WHERE id = $1"
+
+// hotQueries are the queries prepared at startup
+var hotQueries = []string{
+	workByIDQuery,
+	workPageQuery,
+	workChaptersQuery,
+	chapterByNumberQuery,
+	canViewWorkQuery,
+	workLanguageQuery,
+}
+
+// preparedStatementsEnabled reads DB_PREPARED_STATEMENTS, on by default
+func preparedStatementsEnabled() bool {
+	enabled, err := strconv.ParseBool(getEnv("DB_PREPARED_STATEMENTS", "true"))
+	return err != nil || enabled
+}
+
+// prepareHotQueries prepares hotQueries, keyed by their text
+func prepareHotQueries(ctx context.Context, db *sql.DB) (map[string]*sql.Stmt, error) {
+	prepared := make(map[string]*sql.Stmt, len(hotQueries))
+	for _, query := range hotQueries {
+		stmt, err := db.PrepareContext(ctx, query)
+		if err != nil {
+			closePrepared(prepared)
+			return nil, fmt.Errorf("preparing %q: %w", query, err)
+		}
+		prepared[query] = stmt
+	}
+	return prepared, nil
+}
+
+func closePrepared(prepared map[string]*sql.Stmt) {
+	for _, stmt := range prepared {
+		stmt.Close()
+	}
+}
+
+// queryRow runs a query through its prepared statement when it has one,
+// and as plain text otherwise
+func (ws *WorkService) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if stmt, ok := ws.prepared[query]; ok {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return ws.db.QueryRowContext(ctx, query, args...)
+}
+
+// query runs a query through its prepared statement when it has one, and as
+// plain text otherwise
+func (ws *WorkService) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if stmt, ok := ws.prepared[query]; ok {
+		return stmt.QueryContext(ctx, args...)
+	}
+	return ws.db.QueryContext(ctx, query, args...)
+}
+
+// enablePreparedQueries prepares the hot queries unless turned off. The
+// service keeps working from plain text if preparing fails.
+func (ws *WorkService) enablePreparedQueries(ctx context.Context) {
+	if !preparedStatementsEnabled() {
+		log.Println("Prepared statements are off (DB_PREPARED_STATEMENTS=false)")
+		return
+	}
+	prepared, err := prepareHotQueries(ctx, ws.db)
+	if err != nil {
+		log.Printf("Failed to prepare hot queries, running them unprepared: %v", err)
+		return
+	}
+	ws.prepared = prepared
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreparedQueriesArePreparedOnce(t *testing.T) {
+	ws, d := newListingService(t, 0)
+	ws.db.SetMaxOpenConns(1)
+
+	prepared, err := prepareHotQueries(context.Background(), ws.db)
+	assert.NoError(t, err)
+	assert.Len(t, prepared, len(hotQueries))
+	ws.prepared = prepared
+	defer closePrepared(prepared)
+
+	before := d.prepares
+	for i := 0; i < 5; i++ {
+		ws.workLanguage(uuid.New())
+	}
+	assert.Equal(t, before, d.prepares, "a prepared query isn't parsed again")
+	assert.Equal(t, 5, d.queryCount())
+
+	ws.prepared = nil
+	ws.workLanguage(uuid.New())
+	assert.Equal(t, before+1, d.prepares, "without prepared statements the text is sent each time")
+}
+
+func TestPreparedStatementsEnabled(t *testing.T) {
+	t.Setenv("DB_PREPARED_STATEMENTS", "")
+	assert.True(t, preparedStatementsEnabled())
+	t.Setenv("DB_PREPARED_STATEMENTS", "false")
+	assert.False(t, preparedStatementsEnabled())
+}
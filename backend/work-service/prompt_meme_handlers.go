@@ -0,0 +1,545 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"nuclear-ao3/shared/models"
+)
+
+// Prompt meme handlers: posting prompts, claims and fills. Prompts are the
+// request prompts of challenge sign-ups (see challenge_handlers.go).
+
+var errPromptNotFillable = errors.New("prompt not found or not part of a prompt meme")
+
+// PromptListing is a prompt as shown in a prompt meme's prompt list
+type PromptListing struct {
+	models.ChallengePrompt
+	CollectionID uuid.UUID `json:"collection_id"`
+	Username     string    `json:"username,omitempty"` // Hidden for anonymous prompts
+	ClaimCount   int       `json:"claim_count"`
+	FillCount    int       `json:"fill_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// promptMemeFor loads a prompt's collection settings, failing unless the
+// prompt is a request in a prompt meme
+func (ws *WorkService) promptMemeFor(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, promptID uuid.UUID) (*models.ChallengeSettings, error) {
+	settings, err := scanChallengeSettings(q.QueryRow(
+		"SELECT "+challengeSettingsColumns+` FROM challenge_settings
+		WHERE collection_id = (SELECT collection_id FROM challenge_prompts WHERE id = $1 AND kind = 'request')`, promptID))
+	if err == sql.ErrNoRows {
+		return nil, errPromptNotFillable
+	}
+	if err != nil {
+		return nil, err
+	}
+	if settings.ChallengeType != models.ChallengeTypePromptMeme {
+		return nil, errPromptNotFillable
+	}
+	return settings, nil
+}
+
+// PostPrompt adds a prompt to a prompt meme, creating the caller's sign-up
+// on first use
+func (ws *WorkService) PostPrompt(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req ChallengePromptInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if req.Title == "" && req.Description == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A prompt needs a title or description"})
+		return
+	}
+
+	settings, err := ws.getChallengeSettings(collectionID)
+	if err == sql.ErrNoRows || (err == nil && settings.ChallengeType != models.ChallengeTypePromptMeme) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection is not a prompt meme"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenge"})
+		return
+	}
+	if !signupsAcceptingAt(settings, time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This prompt meme is not accepting prompts"})
+		return
+	}
+
+	tagTypes, err := ws.lookupTagTypes(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up tags"})
+		return
+	}
+	if err := validatePromptTags(req.Tags, tagTypes, settings.PromptTagLimits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var signupID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO challenge_signups (collection_id, user_id, pseud_id)
+		VALUES ($1, $2, (SELECT id FROM pseuds WHERE user_id = $2 AND is_default = true))
+		ON CONFLICT (collection_id, user_id) DO UPDATE SET updated_at = NOW()
+		RETURNING id`, collectionID, userID).Scan(&signupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save sign-up"})
+		return
+	}
+
+	var existing int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM challenge_prompts WHERE signup_id = $1 AND kind = 'request'",
+		signupID).Scan(&existing); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count prompts"})
+		return
+	}
+	if settings.RequestsMax > 0 && existing >= settings.RequestsMax {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("You can post at most %d prompts here", settings.RequestsMax)})
+		return
+	}
+
+	prompt := models.ChallengePrompt{
+		SignupID:    signupID,
+		Kind:        "request",
+		Position:    existing + 1,
+		Title:       req.Title,
+		Description: req.Description,
+		Tags:        req.Tags,
+		IsAnonymous: req.IsAnonymous,
+	}
+	err = tx.QueryRow(`
+		INSERT INTO challenge_prompts (signup_id, collection_id, kind, position, title, description, tags, is_anonymous)
+		VALUES ($1, $2, 'request', $3, $4, $5, $6, $7)
+		RETURNING id`,
+		signupID, collectionID, prompt.Position, prompt.Title, prompt.Description,
+		pq.Array(prompt.Tags), prompt.IsAnonymous).Scan(&prompt.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save prompt"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save prompt"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"prompt": prompt})
+}
+
+// ListPrompts lists a prompt meme's prompts. status is unfilled (default),
+// filled or all; sort is age (oldest first), newest or popularity (most
+// claimed first).
+func (ws *WorkService) ListPrompts(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	status := c.DefaultQuery("status", "unfilled")
+	var filter string
+	switch status {
+	case "unfilled":
+		filter = "WHERE fill_count = 0"
+	case "filled":
+		filter = "WHERE fill_count > 0"
+	case "all":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be unfilled, filled or all"})
+		return
+	}
+
+	sortBy := c.DefaultQuery("sort", "age")
+	var orderBy string
+	switch sortBy {
+	case "age":
+		orderBy = "created_at ASC"
+	case "newest":
+		orderBy = "created_at DESC"
+	case "popularity":
+		orderBy = "claim_count DESC, created_at ASC"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be age, newest or popularity"})
+		return
+	}
+
+	query := `
+		SELECT *, COUNT(*) OVER() FROM (
+			SELECT p.id, p.signup_id, p.kind, p.position, COALESCE(p.title, '') AS title,
+				COALESCE(p.description, '') AS description, p.tags, p.is_anonymous, p.collection_id,
+				CASE WHEN p.is_anonymous THEN '' ELSE u.username END AS username,
+				(SELECT COUNT(*) FROM prompt_claims pc WHERE pc.prompt_id = p.id AND pc.status != 'released') AS claim_count,
+				(SELECT COUNT(*) FROM prompt_fills pf WHERE pf.prompt_id = p.id) AS fill_count,
+				p.created_at
+			FROM challenge_prompts p
+			JOIN challenge_signups s ON p.signup_id = s.id
+			JOIN users u ON s.user_id = u.id
+			WHERE p.collection_id = $1 AND p.kind = 'request'
+		) prompts ` + filter + `
+		ORDER BY ` + orderBy + `
+		LIMIT $2 OFFSET $3`
+
+	rows, err := ws.db.Query(query, collectionID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prompts"})
+		return
+	}
+	defer rows.Close()
+
+	prompts := []PromptListing{}
+	total := 0
+	for rows.Next() {
+		var p PromptListing
+		if err := rows.Scan(&p.ID, &p.SignupID, &p.Kind, &p.Position, &p.Title, &p.Description,
+			pq.Array(&p.Tags), &p.IsAnonymous, &p.CollectionID, &p.Username,
+			&p.ClaimCount, &p.FillCount, &p.CreatedAt, &total); err != nil {
+			continue
+		}
+		prompts = append(prompts, p)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"prompts": prompts,
+		"status":  status,
+		"sort":    sortBy,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+// ClaimPrompt claims a prompt for the caller, enforcing the meme's claim
+// limit and whether prompts may be claimed by several people at once
+func (ws *WorkService) ClaimPrompt(c *gin.Context) {
+	promptID, err := uuid.Parse(c.Param("prompt_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	settings, err := ws.promptMemeFor(tx, promptID)
+	if errors.Is(err, errPromptNotFillable) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prompt"})
+		return
+	}
+
+	// Serialise claims on this prompt so the checks below hold
+	if _, err := tx.Exec("SELECT id FROM challenge_prompts WHERE id = $1 FOR UPDATE", promptID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock prompt"})
+		return
+	}
+
+	var mine, others int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE user_id = $2), COUNT(*) FILTER (WHERE user_id != $2)
+		FROM prompt_claims WHERE prompt_id = $1 AND status = 'open'`, promptID, userID).Scan(&mine, &others)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check claims"})
+		return
+	}
+	if mine > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already claimed this prompt"})
+		return
+	}
+	if others > 0 && !settings.AllowMultiClaims {
+		c.JSON(http.StatusConflict, gin.H{"error": "This prompt has already been claimed"})
+		return
+	}
+
+	if settings.ClaimLimit > 0 {
+		var open int
+		err = tx.QueryRow("SELECT COUNT(*) FROM prompt_claims WHERE collection_id = $1 AND user_id = $2 AND status = 'open'",
+			settings.CollectionID, userID).Scan(&open)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check claim limit"})
+			return
+		}
+		if open >= settings.ClaimLimit {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("You can hold at most %d open claims in this prompt meme", settings.ClaimLimit)})
+			return
+		}
+	}
+
+	var claim models.PromptClaim
+	err = tx.QueryRow(`
+		INSERT INTO prompt_claims (prompt_id, collection_id, user_id)
+		VALUES ($1, $2, $3)
+		RETURNING id, prompt_id, collection_id, user_id, status, created_at`,
+		promptID, settings.CollectionID, userID).Scan(
+		&claim.ID, &claim.PromptID, &claim.CollectionID, &claim.UserID, &claim.Status, &claim.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim prompt"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim prompt"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"claim": claim})
+}
+
+// ReleaseClaim gives up the caller's open claim on a prompt
+func (ws *WorkService) ReleaseClaim(c *gin.Context) {
+	promptID, err := uuid.Parse(c.Param("prompt_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := ws.db.Exec(`
+		UPDATE prompt_claims SET status = 'released', released_at = NOW()
+		WHERE prompt_id = $1 AND user_id = $2 AND status = 'open'`, promptID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release claim"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No open claim on this prompt"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Claim released"})
+}
+
+// GetMyClaims lists the caller's claims, open ones by default
+func (ws *WorkService) GetMyClaims(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	status := c.DefaultQuery("status", models.ClaimStatusOpen)
+	query := `
+		SELECT pc.id, pc.prompt_id, pc.collection_id, pc.user_id, pc.status, pc.work_id,
+			pc.created_at, pc.filled_at, pc.released_at,
+			col.title, COALESCE(p.title, ''), COALESCE(p.description, ''), p.tags
+		FROM prompt_claims pc
+		JOIN challenge_prompts p ON pc.prompt_id = p.id
+		JOIN collections col ON pc.collection_id = col.id
+		WHERE pc.user_id = $1`
+	args := []interface{}{userID}
+	if status != "all" {
+		query += " AND pc.status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY pc.created_at DESC"
+
+	rows, err := ws.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch claims"})
+		return
+	}
+	defer rows.Close()
+
+	claims := []gin.H{}
+	for rows.Next() {
+		var claim models.PromptClaim
+		var collectionTitle, promptTitle, promptDescription string
+		var promptTags []string
+		if err := rows.Scan(&claim.ID, &claim.PromptID, &claim.CollectionID, &claim.UserID, &claim.Status,
+			&claim.WorkID, &claim.CreatedAt, &claim.FilledAt, &claim.ReleasedAt,
+			&collectionTitle, &promptTitle, &promptDescription, pq.Array(&promptTags)); err != nil {
+			continue
+		}
+		claims = append(claims, gin.H{
+			"claim":            claim,
+			"collection_title": collectionTitle,
+			"prompt": gin.H{
+				"title":       promptTitle,
+				"description": promptDescription,
+				"tags":        promptTags,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"claims": claims})
+}
+
+// FillPrompt links one of the caller's existing works to a prompt
+func (ws *WorkService) FillPrompt(c *gin.Context) {
+	promptID, err := uuid.Parse(c.Param("prompt_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		WorkID uuid.UUID `json:"work_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	isAuthor, err := isWorkCreator(ws.db, req.WorkID, userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify work ownership"})
+		return
+	}
+	if !isAuthor {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only fill a prompt with your own work"})
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	fills, err := ws.linkPromptFills(tx, req.WorkID, userUUID, []uuid.UUID{promptID})
+	if errors.Is(err, errPromptNotFillable) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link fill"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link fill"})
+		return
+	}
+
+	if ws.redis != nil {
+		ws.redis.Del(c.Request.Context(), fmt.Sprintf("work:%s", req.WorkID))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"fills": fills})
+}
+
+// linkPromptFills records a work as filling each prompt, closes the author's
+// open claim on it and adds the work to the prompt meme collection. Callers
+// verify the user is an author of the work.
+func (ws *WorkService) linkPromptFills(tx *sql.Tx, workID, userID uuid.UUID, promptIDs []uuid.UUID) ([]models.PromptFill, error) {
+	fills := []models.PromptFill{}
+	for _, promptID := range promptIDs {
+		settings, err := ws.promptMemeFor(tx, promptID)
+		if err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", promptID, err)
+		}
+
+		var claimID *uuid.UUID
+		var id uuid.UUID
+		err = tx.QueryRow(`
+			UPDATE prompt_claims SET status = 'filled', work_id = $3, filled_at = NOW()
+			WHERE prompt_id = $1 AND user_id = $2 AND status = 'open'
+			RETURNING id`, promptID, userID, workID).Scan(&id)
+		if err == nil {
+			claimID = &id
+		} else if err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		fill := models.PromptFill{
+			PromptID:     promptID,
+			CollectionID: settings.CollectionID,
+			WorkID:       workID,
+			UserID:       userID,
+			ClaimID:      claimID,
+		}
+		err = tx.QueryRow(`
+			INSERT INTO prompt_fills (prompt_id, collection_id, work_id, user_id, claim_id)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (prompt_id, work_id) DO UPDATE SET claim_id = COALESCE(EXCLUDED.claim_id, prompt_fills.claim_id)
+			RETURNING id, created_at`,
+			promptID, settings.CollectionID, workID, userID, claimID).Scan(&fill.ID, &fill.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO collection_works (collection_id, work_id, approved)
+			SELECT id, $2, NOT is_moderated FROM collections WHERE id = $1
+			ON CONFLICT (collection_id, work_id) DO NOTHING`, settings.CollectionID, workID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := markWorkForCollection(tx, settings.CollectionID, workID); err != nil {
+			return nil, err
+		}
+
+		fills = append(fills, fill)
+	}
+	return fills, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/cache"
+	"nuclear-ao3/shared/models"
+)
+
+// Deployment content rating policy: admins choose which ratings may be
+// posted and which need a logged-in reader. Posting a disallowed rating
+// fails with RATING_NOT_ALLOWED; reading a gated work logged out fails
+// with LOGIN_REQUIRED, and gated works drop out of logged-out listings.
+
+const ratingPolicyCacheKey = "content_rating_policy"
+
+// RatingRule is the policy for one canonical rating key
+type RatingRule struct {
+	Rating        string `json:"rating"`
+	Allowed       bool   `json:"allowed"`
+	LoginRequired bool   `json:"login_required"`
+}
+
+// RatingPolicy maps rating keys to their rules; ratings without a rule are
+// allowed and open to everyone
+type RatingPolicy map[string]RatingRule
+
+// Allows reports whether works with the rating may be posted
+func (p RatingPolicy) Allows(rating string) bool {
+	rule, ok := p[models.RatingKey(rating)]
+	return !ok || rule.Allowed
+}
+
+// RequiresLogin reports whether works with the rating are hidden from
+// logged-out readers
+func (p RatingPolicy) RequiresLogin(rating string) bool {
+	return p[models.RatingKey(rating)].LoginRequired
+}
+
+// ratingPolicy loads the deployment's policy, cached. A policy that can't
+// be loaded is treated as empty so reading and posting keep working.
+func (ws *WorkService) ratingPolicy(ctx context.Context) RatingPolicy {
+	policy := RatingPolicy{}
+
+	var err error
+	if ws.cache != nil {
+		err = ws.cache.GetOrSet(ctx, ratingPolicyCacheKey, &policy, cache.MediumTTL, func() (interface{}, error) {
+			return ws.fetchRatingPolicyFromDB(ctx)
+		})
+	} else {
+		policy, err = ws.fetchRatingPolicyFromDB(ctx)
+	}
+
+	if err != nil {
+		log.Printf("Failed to load content rating policy: %v", err)
+		return RatingPolicy{}
+	}
+	return policy
+}
+
+func (ws *WorkService) fetchRatingPolicyFromDB(ctx context.Context) (RatingPolicy, error) {
+	rows, err := ws.db.QueryContext(ctx, "SELECT rating, allowed, login_required FROM content_rating_policy")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policy := RatingPolicy{}
+	for rows.Next() {
+		var rule RatingRule
+		if err := rows.Scan(&rule.Rating, &rule.Allowed, &rule.LoginRequired); err != nil {
+			return nil, err
+		}
+		policy[rule.Rating] = rule
+	}
+	return policy, rows.Err()
+}
+
+// rejectDisallowedRating answers a post or edit whose rating the deployment
+// doesn't allow, returning true if it did
+func (ws *WorkService) rejectDisallowedRating(c *gin.Context, rating string) bool {
+	if ws.ratingPolicy(c.Request.Context()).Allows(rating) {
+		return false
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":  "This archive does not accept works with this rating",
+		"code":   "RATING_NOT_ALLOWED",
+		"rating": rating,
+	})
+	return true
+}
+
+// rejectLoggedOutReader answers a logged-out request for a work whose
+// rating needs a login, returning true if it did
+func (ws *WorkService) rejectLoggedOutReader(c *gin.Context, rating string) bool {
+	if _, hasUser := c.Get("user_id"); hasUser {
+		return false
+	}
+	if !ws.ratingPolicy(c.Request.Context()).RequiresLogin(rating) {
+		return false
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":  "Log in to read works with this rating",
+		"code":   "LOGIN_REQUIRED",
+		"rating": rating,
+	})
+	return true
+}
+
+// rejectLoggedOutWorkReader is rejectLoggedOutReader for handlers that
+// haven't loaded the work, such as chapter reads
+func (ws *WorkService) rejectLoggedOutWorkReader(c *gin.Context, workID uuid.UUID) bool {
+	if _, hasUser := c.Get("user_id"); hasUser {
+		return false
+	}
+	var rating string
+	ws.db.QueryRowContext(c.Request.Context(), "SELECT COALESCE(rating, '') FROM works WHERE id = $1", workID).Scan(&rating)
+	return ws.rejectLoggedOutReader(c, rating)
+}
+
+// loginGatedRatingsSQL is a condition on works alias w that leaves out
+// ratings needing a login, for logged-out listings; empty when nothing is
+// gated. The spellings come from the fixed rating table, not the request.
+func (ws *WorkService) loginGatedRatingsSQL(ctx context.Context) string {
+	spellings := []string{}
+	for key, rule := range ws.ratingPolicy(ctx) {
+		if rule.LoginRequired {
+			for _, s := range models.RatingSpellings(key) {
+				spellings = append(spellings, pq.QuoteLiteral(s))
+			}
+		}
+	}
+	if len(spellings) == 0 {
+		return ""
+	}
+	sort.Strings(spellings)
+	return " AND LOWER(COALESCE(w.rating, '')) NOT IN (" + strings.Join(spellings, ", ") + ")"
+}
+
+// GetRatingPolicy lists the deployment's rating rules:
+// GET /api/v1/admin/rating-policy
+func (ws *WorkService) GetRatingPolicy(c *gin.Context) {
+	policy, err := ws.fetchRatingPolicyFromDB(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rating policy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policy": sortedRatingRules(policy)})
+}
+
+// UpdateRatingPolicy changes one or more rating rules:
+// PUT /api/v1/admin/rating-policy
+// {"rules": [{"rating": "explicit", "allowed": false}, {"rating": "mature", "login_required": true}]}
+func (ws *WorkService) UpdateRatingPolicy(c *gin.Context) {
+	var req struct {
+		Rules []struct {
+			Rating        string `json:"rating" binding:"required"`
+			Allowed       *bool  `json:"allowed"`
+			LoginRequired *bool  `json:"login_required"`
+		} `json:"rules" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	adminID, _ := userID.(string)
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	for _, rule := range req.Rules {
+		key, ok := models.LookupRatingKey(rule.Rating)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown rating", "rating": rule.Rating})
+			return
+		}
+		_, err := tx.Exec(`
+			INSERT INTO content_rating_policy (rating, allowed, login_required, updated_by, updated_at)
+			VALUES ($1, COALESCE($2, true), COALESCE($3, false), NULLIF($4, '')::uuid, NOW())
+			ON CONFLICT (rating) DO UPDATE SET
+				allowed = COALESCE($2, content_rating_policy.allowed),
+				login_required = COALESCE($3, content_rating_policy.login_required),
+				updated_by = EXCLUDED.updated_by,
+				updated_at = NOW()`,
+			key, rule.Allowed, rule.LoginRequired, adminID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rating policy"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rating policy"})
+		return
+	}
+
+	if ws.cache != nil {
+		if err := ws.cache.Delete(c.Request.Context(), ratingPolicyCacheKey); err != nil {
+			log.Printf("Failed to invalidate rating policy cache: %v", err)
+		}
+	}
+
+	policy, err := ws.fetchRatingPolicyFromDB(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rating policy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Rating policy updated", "policy": sortedRatingRules(policy)})
+}
+
+// sortedRatingRules lists a policy's rules in a stable order
+func sortedRatingRules(policy RatingPolicy) []RatingRule {
+	rules := make([]RatingRule, 0, len(policy))
+	for _, rule := range policy {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Rating < rules[j].Rating })
+	return rules
+}
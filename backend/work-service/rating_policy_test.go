@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRatingPolicy(t *testing.T) {
+	policy := RatingPolicy{
+		"explicit": {Rating: "explicit", Allowed: false, LoginRequired: true},
+		"mature":   {Rating: "mature", Allowed: true, LoginRequired: true},
+		"general":  {Rating: "general", Allowed: true},
+	}
+
+	assert.False(t, policy.Allows("Explicit"), "stored label should match the explicit rule")
+	assert.True(t, policy.Allows("Mature"))
+	assert.True(t, policy.Allows("Teen And Up Audiences"), "ratings without a rule are allowed")
+
+	assert.True(t, policy.RequiresLogin("mature"))
+	assert.True(t, policy.RequiresLogin("EXPLICIT"))
+	assert.False(t, policy.RequiresLogin("General Audiences"))
+	assert.False(t, policy.RequiresLogin(""), "an unrated work falls under not_rated, which has no rule")
+
+	assert.True(t, RatingPolicy{}.Allows("Explicit"), "an empty policy allows everything")
+}
+
+func TestSortedRatingRules(t *testing.T) {
+	rules := sortedRatingRules(RatingPolicy{
+		"teen":     {Rating: "teen"},
+		"explicit": {Rating: "explicit"},
+		"general":  {Rating: "general"},
+	})
+
+	ratings := []string{}
+	for _, rule := range rules {
+		ratings = append(ratings, rule.Rating)
+	}
+	assert.Equal(t, []string{"explicit", "general", "teen"}, ratings)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/models"
+)
+
+// =============================================================================
+// READING HISTORY
+// Signed-in readers' visits to works. The history lets readers find works
+// again and, for readers who opt in, feeds the search service's personalized
+// ranking with the fandoms and tags they read most.
+// =============================================================================
+
+// readingAffinitiesCacheKeyPrefix is where the search service caches the
+// fandoms and tags derived from a reader's history; clearing the history
+// drops it so personalized ranking forgets at once
+const readingAffinitiesCacheKeyPrefix = "search_reading_affinities:"
+
+// recordReading adds a view of a work to the signed-in reader's history.
+// Views within an hour of the last one don't count as a new visit. It
+// returns immediately; the write happens in the background.
+func (ws *WorkService) recordReading(c *gin.Context, workID uuid.UUID) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return
+	}
+	id, ok := userID.(string)
+	if !ok || id == "" {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := ws.db.ExecContext(ctx, `
+			INSERT INTO reading_history (user_id, work_id) VALUES ($1, $2)
+			ON CONFLICT (user_id, work_id) DO UPDATE SET
+				visits = reading_history.visits +
+					CASE WHEN reading_history.last_read_at < NOW() - INTERVAL '1 hour' THEN 1 ELSE 0 END,
+				last_read_at = NOW()`, id, workID)
+		if err != nil {
+			log.Printf("Failed to record reading history for work %s: %v", workID, err)
+		}
+	}()
+}
+
+// GetReadingHistory lists the current user's history, most recently read
+// first
+func (ws *WorkService) GetReadingHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	page, limit := sharePageParams(c)
+
+	rows, err := ws.db.QueryContext(c.Request.Context(), `
+		SELECT h.work_id, w.title, COALESCE(`+workAuthorNamesSQL("w")+`, ''),
+			h.first_read_at, h.last_read_at, h.visits
+		FROM reading_history h
+		JOIN works w ON w.id = h.work_id
+		WHERE h.user_id = $1 AND w.status = 'posted'
+		ORDER BY h.last_read_at DESC
+		LIMIT $2 OFFSET $3`, userID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reading history"})
+		return
+	}
+	defer rows.Close()
+
+	history := []models.ReadingHistoryEntry{}
+	for rows.Next() {
+		var h models.ReadingHistoryEntry
+		if err := rows.Scan(&h.WorkID, &h.Title, &h.Authors, &h.FirstReadAt, &h.LastReadAt, &h.Visits); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reading history"})
+			return
+		}
+		history = append(history, h)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history, "page": page, "limit": limit})
+}
+
+// DeleteReadingHistoryEntry removes one work from the current user's history
+func (ws *WorkService) DeleteReadingHistoryEntry(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := ws.db.ExecContext(c.Request.Context(),
+		"DELETE FROM reading_history WHERE user_id = $1 AND work_id = $2", userID, workID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reading history"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work is not in your reading history"})
+		return
+	}
+	ws.forgetReadingAffinities(c.Request.Context(), userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Work removed from reading history"})
+}
+
+// ClearReadingHistory empties the current user's history
+func (ws *WorkService) ClearReadingHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := ws.db.ExecContext(c.Request.Context(),
+		"DELETE FROM reading_history WHERE user_id = $1", userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear reading history"})
+		return
+	}
+	ws.forgetReadingAffinities(c.Request.Context(), userID)
+
+	removed, _ := result.RowsAffected()
+	c.JSON(http.StatusOK, gin.H{"message": "Reading history cleared", "removed": removed})
+}
+
+func (ws *WorkService) forgetReadingAffinities(ctx context.Context, userID interface{}) {
+	if ws.redis == nil {
+		return
+	}
+	id, _ := userID.(string)
+	if err := ws.redis.Del(ctx, readingAffinitiesCacheKeyPrefix+id).Err(); err != nil {
+		log.Printf("Failed to drop reading affinities for %s: %v", id, err)
+	}
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/cache"
+	"nuclear-ao3/shared/models"
+)
+
+// Related works: translations, remixes and "inspired by" links
+
+var errInvalidRelatedWork = errors.New("invalid related work")
+
+var validRelations = map[string]bool{
+	models.RelationTranslation: true,
+	models.RelationRemix:       true,
+	models.RelationInspiredBy:  true,
+}
+
+func relatedWorksCacheKey(workID uuid.UUID) string {
+	return fmt.Sprintf("work_related:%s", workID.String())
+}
+
+// workAuthorNamesSQL lists the approved pseuds on a work aliased as w,
+// hiding them for anonymous works. NULL when the join found no work.
+func workAuthorNamesSQL(w string) string {
+	return `CASE WHEN ` + w + `.id IS NULL THEN NULL WHEN ` + w + `.is_anonymous THEN 'Anonymous' ELSE (
+		SELECT string_agg(p.name, ', ') FROM creatorships c JOIN pseuds p ON c.pseud_id = p.id
+		WHERE c.creation_id = ` + w + `.id AND c.creation_type = 'Work' AND c.approved = true) END`
+}
+
+var relatedWorkSelect = `
+	SELECT r.id, r.work_id, cw.title, COALESCE(` + workAuthorNamesSQL("cw") + `, ''),
+		r.parent_work_id, COALESCE(r.parent_url, ''), COALESCE(pw.title, r.parent_title, ''),
+		COALESCE(` + workAuthorNamesSQL("pw") + `, r.parent_author, ''),
+		COALESCE(pw.language, r.parent_language, ''), r.relation, r.status, r.created_at, r.reviewed_at
+	FROM related_works r
+	JOIN works cw ON r.work_id = cw.id
+	LEFT JOIN works pw ON r.parent_work_id = pw.id`
+
+func (ws *WorkService) queryRelatedWorks(ctx context.Context, where string, args ...interface{}) ([]models.RelatedWork, error) {
+	rows, err := ws.db.QueryContext(ctx, relatedWorkSelect+" WHERE "+where+" ORDER BY r.created_at", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	related := []models.RelatedWork{}
+	for rows.Next() {
+		var r models.RelatedWork
+		var parentID uuid.NullUUID
+		if err := rows.Scan(&r.ID, &r.WorkID, &r.WorkTitle, &r.WorkAuthor, &parentID, &r.ParentURL,
+			&r.ParentTitle, &r.ParentAuthor, &r.ParentLanguage, &r.Relation, &r.Status,
+			&r.CreatedAt, &r.ReviewedAt); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			r.ParentWorkID = &parentID.UUID
+		}
+		related = append(related, r)
+	}
+	return related, rows.Err()
+}
+
+// getRelatedWorks returns a work's approved relations in both directions,
+// served from cache when available. Draft works on either side are left
+// out. Failures are logged and yield empty lists.
+func (ws *WorkService) getRelatedWorks(ctx context.Context, workID uuid.UUID) models.RelatedWorks {
+	related := models.RelatedWorks{Parents: []models.RelatedWork{}, Children: []models.RelatedWork{}}
+
+	var err error
+	if ws.cache != nil {
+		err = ws.cache.GetOrSet(ctx, relatedWorksCacheKey(workID), &related, cache.MediumTTL, func() (interface{}, error) {
+			return ws.fetchRelatedWorksFromDB(ctx, workID)
+		}, workCacheTag(workID))
+	} else {
+		related, err = ws.fetchRelatedWorksFromDB(ctx, workID)
+	}
+
+	if err != nil {
+		log.Printf("Failed to load related works for work %s: %v", workID, err)
+		return models.RelatedWorks{Parents: []models.RelatedWork{}, Children: []models.RelatedWork{}}
+	}
+	return related
+}
+
+func (ws *WorkService) fetchRelatedWorksFromDB(ctx context.Context, workID uuid.UUID) (models.RelatedWorks, error) {
+	var related models.RelatedWorks
+	var err error
+
+	related.Parents, err = ws.queryRelatedWorks(ctx,
+		"r.work_id = $1 AND r.status = 'approved' AND (pw.id IS NULL OR pw.status NOT IN ('draft', 'unlisted'))", workID)
+	if err != nil {
+		return related, err
+	}
+	related.Children, err = ws.queryRelatedWorks(ctx,
+		"r.parent_work_id = $1 AND r.status = 'approved' AND cw.status NOT IN ('draft', 'unlisted')", workID)
+	return related, err
+}
+
+// invalidateRelatedWorks drops cached relations for the given works
+func (ws *WorkService) invalidateRelatedWorks(ctx context.Context, workIDs ...uuid.UUID) {
+	if ws.cache == nil {
+		return
+	}
+	for _, workID := range workIDs {
+		if err := ws.cache.Delete(ctx, relatedWorksCacheKey(workID)); err != nil {
+			log.Printf("Failed to invalidate related works for work %s: %v", workID, err)
+		}
+	}
+}
+
+// validateRelatedWorkInput checks a declared parent names exactly one of a
+// local work or an http(s) URL, and that external parents have a title
+func validateRelatedWorkInput(in models.RelatedWorkInput) error {
+	if !validRelations[in.Relation] {
+		return fmt.Errorf("%w: relation must be translation, remix or inspired_by", errInvalidRelatedWork)
+	}
+
+	in.URL = strings.TrimSpace(in.URL)
+	if (in.ParentWorkID == nil) == (in.URL == "") {
+		return fmt.Errorf("%w: give either parent_work_id or url", errInvalidRelatedWork)
+	}
+	if in.URL != "" {
+		u, err := url.Parse(in.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: url must be an http(s) link", errInvalidRelatedWork)
+		}
+		if strings.TrimSpace(in.Title) == "" {
+			return fmt.Errorf("%w: external works need a title", errInvalidRelatedWork)
+		}
+	}
+	return nil
+}
+
+// isWorkCreator reports whether the user holds a creatorship on the work
+func isWorkCreator(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, workID, userID uuid.UUID) (bool, error) {
+	var isCreator bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM creatorships c
+			JOIN pseuds p ON c.pseud_id = p.id
+			WHERE c.creation_id = $1 AND c.creation_type = 'Work' AND p.user_id = $2
+		)`, workID, userID).Scan(&isCreator)
+	return isCreator, err
+}
+
+// linkRelatedWorks records the parents a work declares. Links to another
+// local work stay pending until its author approves, unless the poster is
+// also an author of the parent; external links are approved immediately.
+// Links that already exist are skipped.
+func (ws *WorkService) linkRelatedWorks(tx *sql.Tx, workID, userID uuid.UUID, inputs []models.RelatedWorkInput) ([]models.RelatedWork, error) {
+	linked := []models.RelatedWork{}
+	for _, in := range inputs {
+		if err := validateRelatedWorkInput(in); err != nil {
+			return nil, err
+		}
+
+		r := models.RelatedWork{
+			WorkID:         workID,
+			ParentWorkID:   in.ParentWorkID,
+			ParentURL:      strings.TrimSpace(in.URL),
+			ParentTitle:    strings.TrimSpace(in.Title),
+			ParentAuthor:   strings.TrimSpace(in.Author),
+			ParentLanguage: in.Language,
+			Relation:       in.Relation,
+			Status:         "approved",
+		}
+
+		var insert string
+		if in.ParentWorkID != nil {
+			if *in.ParentWorkID == workID {
+				return nil, fmt.Errorf("%w: a work cannot be related to itself", errInvalidRelatedWork)
+			}
+
+			var status string
+			err := tx.QueryRow("SELECT title, status FROM works WHERE id = $1", *in.ParentWorkID).Scan(&r.ParentTitle, &status)
+			if err == sql.ErrNoRows || (err == nil && status == "draft") {
+				return nil, fmt.Errorf("%w: work %s not found", errInvalidRelatedWork, *in.ParentWorkID)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			ownParent, err := isWorkCreator(tx, *in.ParentWorkID, userID)
+			if err != nil {
+				return nil, err
+			}
+			if !ownParent {
+				r.Status = "pending"
+			}
+
+			// Titles and authors of local parents are read live from the work
+			r.ParentAuthor, r.ParentLanguage = "", ""
+			insert = `
+				INSERT INTO related_works (work_id, parent_work_id, relation, status, requested_by, reviewed_at)
+				VALUES ($1, $2, $3, $4, $5, CASE WHEN $4 = 'approved' THEN NOW() END)
+				ON CONFLICT (work_id, parent_work_id) WHERE parent_work_id IS NOT NULL DO NOTHING
+				RETURNING id, created_at`
+			err = tx.QueryRow(insert, workID, *in.ParentWorkID, r.Relation, r.Status, userID).Scan(&r.ID, &r.CreatedAt)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			insert = `
+				INSERT INTO related_works (work_id, parent_url, parent_title, parent_author, parent_language,
+					relation, status, requested_by, reviewed_at)
+				VALUES ($1, $2, $3, $4, $5, $6, 'approved', $7, NOW())
+				ON CONFLICT (work_id, parent_url) WHERE parent_url IS NOT NULL DO NOTHING
+				RETURNING id, created_at`
+			err := tx.QueryRow(insert, workID, r.ParentURL, r.ParentTitle, r.ParentAuthor, r.ParentLanguage,
+				r.Relation, userID).Scan(&r.ID, &r.CreatedAt)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		linked = append(linked, r)
+	}
+	return linked, nil
+}
+
+// GetRelatedWorks lists a work's approved relations
+func (ws *WorkService) GetRelatedWorks(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"related_works": ws.getRelatedWorks(c.Request.Context(), workID)})
+}
+
+// AddRelatedWorks declares parents for an existing work
+func (ws *WorkService) AddRelatedWorks(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		RelatedWorks []models.RelatedWorkInput `json:"related_works" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	isCreator, err := isWorkCreator(ws.db, workID, userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify work ownership"})
+		return
+	}
+	if !isCreator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only add related works to your own work"})
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	linked, err := ws.linkRelatedWorks(tx, workID, userUUID, req.RelatedWorks)
+	if errors.Is(err, errInvalidRelatedWork) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add related works"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add related works"})
+		return
+	}
+
+	affected := []uuid.UUID{workID}
+	for _, r := range linked {
+		if r.ParentWorkID != nil {
+			affected = append(affected, *r.ParentWorkID)
+		}
+	}
+	ws.invalidateRelatedWorks(c.Request.Context(), affected...)
+
+	c.JSON(http.StatusCreated, gin.H{"related_works": linked})
+}
+
+// GetRelatedWorkRequests lists pending links to the caller's works
+func (ws *WorkService) GetRelatedWorkRequests(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	requests, err := ws.queryRelatedWorks(c.Request.Context(), `r.status = 'pending' AND r.parent_work_id IN (
+		SELECT c.creation_id FROM creatorships c JOIN pseuds p ON c.pseud_id = p.id
+		WHERE c.creation_type = 'Work' AND p.user_id = $1)`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch related work requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// loadRelatedWorkForUser fetches a relation along with whether the user
+// authors its derived work and its parent work
+func (ws *WorkService) loadRelatedWorkForUser(c *gin.Context) (*models.RelatedWork, bool, bool, bool) {
+	relationID, err := uuid.Parse(c.Param("relation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid relation ID"})
+		return nil, false, false, false
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false, false, false
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false, false, false
+	}
+
+	related, err := ws.queryRelatedWorks(c.Request.Context(), "r.id = $1", relationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch related work"})
+		return nil, false, false, false
+	}
+	if len(related) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Related work not found"})
+		return nil, false, false, false
+	}
+	r := &related[0]
+
+	ownsWork, err := isWorkCreator(ws.db, r.WorkID, userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify work ownership"})
+		return nil, false, false, false
+	}
+	ownsParent := false
+	if r.ParentWorkID != nil {
+		ownsParent, err = isWorkCreator(ws.db, *r.ParentWorkID, userUUID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify work ownership"})
+			return nil, false, false, false
+		}
+	}
+	return r, ownsWork, ownsParent, true
+}
+
+// ApproveRelatedWork lets the original author accept a link to their work
+func (ws *WorkService) ApproveRelatedWork(c *gin.Context) {
+	ws.reviewRelatedWork(c, "approved")
+}
+
+// RejectRelatedWork lets the original author decline a link to their work
+func (ws *WorkService) RejectRelatedWork(c *gin.Context) {
+	ws.reviewRelatedWork(c, "rejected")
+}
+
+func (ws *WorkService) reviewRelatedWork(c *gin.Context, status string) {
+	r, _, ownsParent, ok := ws.loadRelatedWorkForUser(c)
+	if !ok {
+		return
+	}
+	if !ownsParent {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the original work's author can review this link"})
+		return
+	}
+	if r.Status == status {
+		c.JSON(http.StatusOK, gin.H{"related_work": r})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	err := ws.db.QueryRow(`
+		UPDATE related_works SET status = $2, reviewed_by = $3, reviewed_at = NOW()
+		WHERE id = $1 RETURNING reviewed_at`, r.ID, status, userID).Scan(&r.ReviewedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review related work"})
+		return
+	}
+	r.Status = status
+
+	ws.invalidateRelatedWorks(c.Request.Context(), r.WorkID, *r.ParentWorkID)
+
+	c.JSON(http.StatusOK, gin.H{"related_work": r})
+}
+
+// DeleteRelatedWork removes a link; authors on either side may do so
+func (ws *WorkService) DeleteRelatedWork(c *gin.Context) {
+	r, ownsWork, ownsParent, ok := ws.loadRelatedWorkForUser(c)
+	if !ok {
+		return
+	}
+	if !ownsWork && !ownsParent {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only remove links involving your own works"})
+		return
+	}
+
+	if _, err := ws.db.Exec("DELETE FROM related_works WHERE id = $1", r.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove related work"})
+		return
+	}
+
+	affected := []uuid.UUID{r.WorkID}
+	if r.ParentWorkID != nil {
+		affected = append(affected, *r.ParentWorkID)
+	}
+	ws.invalidateRelatedWorks(c.Request.Context(), affected...)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Related work removed"})
+}
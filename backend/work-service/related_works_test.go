@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"nuclear-ao3/shared/models"
+)
+
+func TestValidateRelatedWorkInput(t *testing.T) {
+	parent := uuid.New()
+
+	tests := []struct {
+		name  string
+		input models.RelatedWorkInput
+		valid bool
+	}{
+		{"local translation", models.RelatedWorkInput{ParentWorkID: &parent, Relation: models.RelationTranslation}, true},
+		{"external remix", models.RelatedWorkInput{URL: "https://example.com/fic/1", Title: "Original", Relation: models.RelationRemix}, true},
+		{"unknown relation", models.RelatedWorkInput{ParentWorkID: &parent, Relation: "sequel"}, false},
+		{"no parent", models.RelatedWorkInput{Relation: models.RelationInspiredBy}, false},
+		{"both parents", models.RelatedWorkInput{ParentWorkID: &parent, URL: "https://example.com", Title: "x", Relation: models.RelationRemix}, false},
+		{"external without title", models.RelatedWorkInput{URL: "https://example.com/fic/1", Relation: models.RelationRemix}, false},
+		{"non-http url", models.RelatedWorkInput{URL: "javascript:alert(1)", Title: "x", Relation: models.RelationRemix}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRelatedWorkInput(tt.input)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, errInvalidRelatedWork), "expected errInvalidRelatedWork, got %v", err)
+			}
+		})
+	}
+}
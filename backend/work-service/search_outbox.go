@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+
+	"nuclear-ao3/shared/middleware"
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/searchstream"
+)
+
+// Search outbox: triggers on works and work_statistics record every change
+// in search_outbox inside the changing transaction (migration 038). The
+// publisher moves pending events onto the search stream, retrying with
+// backoff and dead-lettering events that keep failing.
+
+const (
+	searchOutboxBatchSize   = 100
+	searchOutboxMaxAttempts = 8
+	// searchOutboxRetention is how long published events are kept
+	searchOutboxRetention = 7 * 24 * time.Hour
+	// searchStreamMaxLen caps the stream; the indexer trims nothing itself
+	searchStreamMaxLen = 100000
+)
+
+// searchOutboxEntry is one pending outbox row
+type searchOutboxEntry struct {
+	ID        int64
+	WorkID    uuid.UUID
+	EventType string
+	Attempts  int
+}
+
+// outboxBackoff is the wait before retrying an outbox publish that has failed
+// attempts times: 5s, 10s, 20s... capped at 10 minutes
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 8 {
+		attempts = 8
+	}
+	backoff := 5 * time.Second << uint(attempts-1)
+	if backoff > 10*time.Minute {
+		backoff = 10 * time.Minute
+	}
+	return backoff
+}
+
+// latestSearchEvents keeps each work's newest event. Older events for the
+// same work are superseded: the newest carries the work's current state.
+func latestSearchEvents(entries []searchOutboxEntry) (latest []searchOutboxEntry, superseded []int64) {
+	newest := map[uuid.UUID]int{}
+	for i, e := range entries {
+		newest[e.WorkID] = i
+	}
+	for i, e := range entries {
+		if newest[e.WorkID] == i {
+			latest = append(latest, e)
+		} else {
+			superseded = append(superseded, e.ID)
+		}
+	}
+	return latest, superseded
+}
+
+// enqueueSearchReindex records an upsert for works whose search document
+// changed without their works row changing, such as a new byline
+func enqueueSearchReindex(ctx context.Context, tx *sql.Tx, workIDs ...uuid.UUID) error {
+	for _, workID := range workIDs {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO search_outbox (work_id, event_type) VALUES ($1, $2)",
+			workID, searchstream.EventUpsert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSearchOutboxPublisher publishes pending outbox events every interval
+// until ctx is cancelled, draining a backlog without waiting between batches
+func (ws *WorkService) startSearchOutboxPublisher(ctx context.Context, interval time.Duration) {
+	if ws.redis == nil {
+		log.Println("Search outbox publisher disabled: no Redis connection")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Search outbox publisher started, polling every %s", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Search outbox publisher stopped")
+			return
+		case <-ticker.C:
+			for {
+				count, err := ws.publishSearchOutbox(ctx)
+				if err != nil {
+					log.Printf("Search outbox publish failed: %v", err)
+				}
+				if err != nil || count < searchOutboxBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// publishSearchOutbox publishes one batch of due events, returning how many
+// rows it handled. Rows are locked with SKIP LOCKED so several work-service
+// instances can publish side by side.
+func (ws *WorkService) publishSearchOutbox(ctx context.Context) (int, error) {
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, work_id, event_type, attempts FROM search_outbox
+		WHERE published_at IS NULL AND dead_lettered_at IS NULL
+			AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, searchOutboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	entries := []searchOutboxEntry{}
+	for rows.Next() {
+		var e searchOutboxEntry
+		if err := rows.Scan(&e.ID, &e.WorkID, &e.EventType, &e.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	latest, published := latestSearchEvents(entries)
+	for _, e := range latest {
+		publishErr := ws.publishSearchEvent(ctx, e)
+		if publishErr == nil {
+			published = append(published, e.ID)
+			continue
+		}
+
+		attempts := e.Attempts + 1
+		log.Printf("Failed to publish search event %d for work %s (attempt %d): %v", e.ID, e.WorkID, attempts, publishErr)
+		_, err := tx.ExecContext(ctx, `
+			UPDATE search_outbox SET
+				attempts = $2, last_error = $3, next_attempt_at = NOW() + $4::interval,
+				dead_lettered_at = CASE WHEN $2 >= $5 THEN NOW() END
+			WHERE id = $1`,
+			e.ID, attempts, publishErr.Error(), fmt.Sprintf("%d seconds", int(outboxBackoff(attempts).Seconds())),
+			searchOutboxMaxAttempts)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(published) > 0 {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE search_outbox SET published_at = NOW(), last_error = NULL WHERE id = ANY($1)",
+			pq.Array(published)); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// searchableWork reports whether a work belongs in search: not a draft,
+// unlisted, hidden by moderators, or waiting for its collection's reveal
+func searchableWork(work *models.Work) bool {
+	switch work.Status {
+	case "draft", "hidden", "deleted", "unlisted":
+		return false
+	}
+	return !work.InUnrevealedCollection
+}
+
+// publishSearchEvent adds one event to the search stream. Upserts carry the
+// work's current search document; works that are gone or not searchable
+// are published as deletes.
+func (ws *WorkService) publishSearchEvent(ctx context.Context, e searchOutboxEntry) error {
+	event := searchstream.WorkEvent{OutboxID: e.ID, WorkID: e.WorkID.String(), Type: searchstream.EventDelete}
+
+	if e.EventType == searchstream.EventUpsert {
+		work, err := ws.getWorkByID(e.WorkID)
+		if err != nil {
+			var exists bool
+			if err := ws.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM works WHERE id = $1)", e.WorkID).Scan(&exists); err != nil {
+				return err
+			}
+			if exists {
+				return err
+			}
+		} else if searchableWork(work) {
+			document, err := json.Marshal(workSearchDocument(e.WorkID, work))
+			if err != nil {
+				return err
+			}
+			event.Type = searchstream.EventUpsert
+			event.Document = document
+		}
+	}
+
+	return ws.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: searchstream.Stream,
+		MaxLen: searchStreamMaxLen,
+		Approx: true,
+		Values: event.Values(),
+	}).Err()
+}
+
+// purgePublishedSearchOutbox deletes published events past retention
+func (ws *WorkService) purgePublishedSearchOutbox(ctx context.Context) (int, error) {
+	result, err := ws.db.ExecContext(ctx,
+		"DELETE FROM search_outbox WHERE published_at < $1", time.Now().Add(-searchOutboxRetention))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// GetSearchOutboxStatus reports the outbox backlog and recent dead letters:
+// GET /api/v1/admin/search-outbox
+func (ws *WorkService) GetSearchOutboxStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var pending, dead int
+	var oldestPending sql.NullTime
+	err := ws.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE published_at IS NULL AND dead_lettered_at IS NULL),
+			COUNT(*) FILTER (WHERE dead_lettered_at IS NOT NULL),
+			MIN(created_at) FILTER (WHERE published_at IS NULL AND dead_lettered_at IS NULL)
+		FROM search_outbox`).Scan(&pending, &dead, &oldestPending)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search outbox status"})
+		return
+	}
+
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT id, work_id, event_type, attempts, COALESCE(last_error, ''), created_at, dead_lettered_at
+		FROM search_outbox WHERE dead_lettered_at IS NOT NULL
+		ORDER BY dead_lettered_at DESC LIMIT 50`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dead letters"})
+		return
+	}
+	defer rows.Close()
+
+	deadLetters := []gin.H{}
+	for rows.Next() {
+		var id int64
+		var workID uuid.UUID
+		var eventType, lastError string
+		var attempts int
+		var createdAt, deadAt time.Time
+		if err := rows.Scan(&id, &workID, &eventType, &attempts, &lastError, &createdAt, &deadAt); err != nil {
+			continue
+		}
+		deadLetters = append(deadLetters, gin.H{
+			"id": id, "work_id": workID, "event_type": eventType, "attempts": attempts,
+			"last_error": lastError, "created_at": createdAt, "dead_lettered_at": deadAt,
+		})
+	}
+
+	status := gin.H{"pending": pending, "dead_lettered": dead, "dead_letters": deadLetters}
+	if oldestPending.Valid {
+		status["oldest_pending_at"] = oldestPending.Time
+	}
+	if ws.redis != nil {
+		if length, err := ws.redis.XLen(ctx, searchstream.Stream).Result(); err == nil {
+			status["stream_length"] = length
+		}
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// ReplaySearchOutbox re-queues events for publishing:
+// POST /api/v1/admin/search-outbox/replay
+// {"dead_letters": true} retries dead-lettered events,
+// {"work_ids": [...]} reindexes the given works, {"all": true} every work.
+// With ?dry_run=true it only counts what would be queued.
+func (ws *WorkService) ReplaySearchOutbox(c *gin.Context) {
+	var req struct {
+		DeadLetters bool        `json:"dead_letters"`
+		WorkIDs     []uuid.UUID `json:"work_ids"`
+		All         bool        `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	if !req.DeadLetters && len(req.WorkIDs) == 0 && !req.All {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Choose dead_letters, work_ids or all"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if middleware.IsDryRun(c) {
+		ws.countSearchReplay(c, req.DeadLetters, req.WorkIDs, req.All)
+		return
+	}
+	counts := gin.H{}
+
+	if req.DeadLetters {
+		result, err := ws.db.ExecContext(ctx, `
+			UPDATE search_outbox SET dead_lettered_at = NULL, attempts = 0, next_attempt_at = NULL
+			WHERE dead_lettered_at IS NOT NULL`)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay dead letters"})
+			return
+		}
+		n, _ := result.RowsAffected()
+		counts["dead_letters"] = n
+	}
+
+	var result sql.Result
+	var err error
+	switch {
+	case req.All:
+		result, err = ws.db.ExecContext(ctx, `
+			INSERT INTO search_outbox (work_id, event_type)
+			SELECT id, $1 FROM works ORDER BY updated_at DESC`, searchstream.EventUpsert)
+	case len(req.WorkIDs) > 0:
+		result, err = ws.db.ExecContext(ctx, `
+			INSERT INTO search_outbox (work_id, event_type)
+			SELECT id, $1 FROM works WHERE id = ANY($2::uuid[])`, searchstream.EventUpsert, pq.Array(uuidStrings(req.WorkIDs)))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue reindex"})
+		return
+	}
+	if result != nil {
+		n, _ := result.RowsAffected()
+		counts["works"] = n
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Search events queued", "queued": counts})
+}
+
+// countSearchReplay reports what a replay would queue without queueing it
+func (ws *WorkService) countSearchReplay(c *gin.Context, deadLetters bool, workIDs []uuid.UUID, all bool) {
+	ctx := c.Request.Context()
+	counts := gin.H{}
+
+	if deadLetters {
+		var n int64
+		if err := ws.db.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM search_outbox WHERE dead_lettered_at IS NOT NULL").Scan(&n); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count dead letters"})
+			return
+		}
+		counts["dead_letters"] = n
+	}
+
+	if all || len(workIDs) > 0 {
+		var n int64
+		var err error
+		if all {
+			err = ws.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM works").Scan(&n)
+		} else {
+			err = ws.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM works WHERE id = ANY($1::uuid[])",
+				pq.Array(uuidStrings(workIDs))).Scan(&n)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count works"})
+			return
+		}
+		counts["works"] = n
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dry_run": true, "message": "Search events would be queued", "queued": counts})
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strs
+}
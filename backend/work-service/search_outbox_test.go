@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestLatestSearchEvents(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	entries := []searchOutboxEntry{
+		{ID: 1, WorkID: a, EventType: "upsert"},
+		{ID: 2, WorkID: b, EventType: "upsert"},
+		{ID: 3, WorkID: a, EventType: "upsert"},
+		{ID: 4, WorkID: a, EventType: "delete"},
+	}
+
+	latest, superseded := latestSearchEvents(entries)
+
+	assert.Equal(t, []int64{1, 3}, superseded)
+	assert.Len(t, latest, 2)
+	assert.Equal(t, int64(2), latest[0].ID)
+	assert.Equal(t, int64(4), latest[1].ID, "a work's newest event wins, even a delete")
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, 5*time.Second, outboxBackoff(1))
+	assert.Equal(t, 20*time.Second, outboxBackoff(3))
+	assert.Equal(t, 10*time.Minute, outboxBackoff(8))
+	assert.Equal(t, 10*time.Minute, outboxBackoff(50))
+}
+
+func TestSearchableWork(t *testing.T) {
+	assert.True(t, searchableWork(&models.Work{Status: "posted"}))
+	assert.False(t, searchableWork(&models.Work{Status: "draft"}))
+	assert.False(t, searchableWork(&models.Work{Status: "unlisted"}))
+	assert.False(t, searchableWork(&models.Work{Status: "posted", InUnrevealedCollection: true}))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/cache"
+	"nuclear-ao3/shared/models"
+)
+
+// Series navigation for work and chapter responses
+
+func seriesNavigationCacheKey(workID uuid.UUID) string {
+	return fmt.Sprintf("work_series_nav:%s", workID.String())
+}
+
+// getSeriesNavigation returns every series the work belongs to with its
+// previous and next works, served from cache when available. Failures are
+// logged and yield an empty list so navigation never breaks a work page.
+func (ws *WorkService) getSeriesNavigation(ctx context.Context, workID uuid.UUID) []models.SeriesNavigation {
+	nav := []models.SeriesNavigation{}
+
+	var err error
+	if ws.cache != nil {
+		err = ws.cache.GetOrSet(ctx, seriesNavigationCacheKey(workID), &nav, cache.MediumTTL, func() (interface{}, error) {
+			return ws.fetchSeriesNavigationFromDB(ctx, workID)
+		}, workCacheTag(workID))
+	} else {
+		nav, err = ws.fetchSeriesNavigationFromDB(ctx, workID)
+	}
+
+	if err != nil {
+		log.Printf("Failed to load series navigation for work %s: %v", workID, err)
+		return []models.SeriesNavigation{}
+	}
+	return nav
+}
+
+// fetchSeriesNavigationFromDB skips draft, unlisted and trashed works when
+// picking neighbours, so readers are never linked to something they cannot
+// open
+func (ws *WorkService) fetchSeriesNavigationFromDB(ctx context.Context, workID uuid.UUID) ([]models.SeriesNavigation, error) {
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT s.id, s.title, sw.position, s.work_count, s.is_complete,
+			(SELECT p.work_id FROM series_works p
+				JOIN works pw ON p.work_id = pw.id
+				WHERE p.series_id = s.id AND p.position < sw.position AND pw.status NOT IN ('draft', 'unlisted') AND pw.deleted_at IS NULL
+				ORDER BY p.position DESC LIMIT 1),
+			(SELECT n.work_id FROM series_works n
+				JOIN works nw ON n.work_id = nw.id
+				WHERE n.series_id = s.id AND n.position > sw.position AND nw.status NOT IN ('draft', 'unlisted') AND nw.deleted_at IS NULL
+				ORDER BY n.position ASC LIMIT 1)
+		FROM series_works sw
+		JOIN series s ON sw.series_id = s.id
+		WHERE sw.work_id = $1
+		ORDER BY s.title`, workID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nav := []models.SeriesNavigation{}
+	for rows.Next() {
+		var n models.SeriesNavigation
+		var prev, next uuid.NullUUID
+		if err := rows.Scan(&n.SeriesID, &n.Title, &n.Position, &n.WorkCount, &n.IsComplete, &prev, &next); err != nil {
+			return nil, err
+		}
+		if prev.Valid {
+			n.PrevWorkID = &prev.UUID
+		}
+		if next.Valid {
+			n.NextWorkID = &next.UUID
+		}
+		nav = append(nav, n)
+	}
+	return nav, rows.Err()
+}
+
+// invalidateSeriesNavigation drops cached navigation for the given works.
+// Membership changes move neighbours too, so callers pass every work in the
+// affected series.
+func (ws *WorkService) invalidateSeriesNavigation(ctx context.Context, workIDs ...uuid.UUID) {
+	if ws.cache == nil {
+		return
+	}
+	for _, workID := range workIDs {
+		if err := ws.cache.Delete(ctx, seriesNavigationCacheKey(workID)); err != nil {
+			log.Printf("Failed to invalidate series navigation for work %s: %v", workID, err)
+		}
+	}
+}
+
+// seriesMateIDs lists the works sharing a series with the given work,
+// which link to it as a neighbour
+func (ws *WorkService) seriesMateIDs(ctx context.Context, workID uuid.UUID) []uuid.UUID {
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT DISTINCT o.work_id FROM series_works sw
+		JOIN series_works o ON o.series_id = sw.series_id
+		WHERE sw.work_id = $1`, workID)
+	if err != nil {
+		log.Printf("Failed to list series mates of work %s: %v", workID, err)
+		return nil
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// seriesWorkIDs lists the works currently in a series
+func (ws *WorkService) seriesWorkIDs(ctx context.Context, seriesID uuid.UUID) []uuid.UUID {
+	rows, err := ws.db.QueryContext(ctx, "SELECT work_id FROM series_works WHERE series_id = $1", seriesID)
+	if err != nil {
+		log.Printf("Failed to list works in series %s: %v", seriesID, err)
+		return nil
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
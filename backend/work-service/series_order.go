@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Series membership lives in series_works alone: a work can be in any
+// number of series, with its own position in each. An author reorders a
+// series by sending every work in it in the new order.
+
+// ReorderSeriesRequest lists every work in a series in its new order
+type ReorderSeriesRequest struct {
+	WorkIDs []uuid.UUID `json:"work_ids" binding:"required"`
+}
+
+// validateSeriesOrder checks that order is the series' current works,
+// each exactly once
+func validateSeriesOrder(current, order []uuid.UUID) error {
+	if len(order) != len(current) {
+		return fmt.Errorf("work_ids must list all %d works in the series", len(current))
+	}
+	members := make(map[uuid.UUID]bool, len(current))
+	for _, id := range current {
+		members[id] = true
+	}
+	seen := make(map[uuid.UUID]bool, len(order))
+	for _, id := range order {
+		if !members[id] {
+			return fmt.Errorf("work %s is not in this series", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("work %s is listed more than once", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// ReorderSeries sets the order of the works in a series
+func (ws *WorkService) ReorderSeries(c *gin.Context) {
+	seriesID, err := uuid.Parse(c.Param("series_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req ReorderSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the series so concurrent adds and removes wait for the reorder
+	var ownerID uuid.UUID
+	err = tx.QueryRowContext(ctx, "SELECT user_id FROM series WHERE id = $1 FOR UPDATE", seriesID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify series ownership"})
+		return
+	}
+	if ownerID.String() != userID.(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only reorder your own series"})
+		return
+	}
+
+	current, err := seriesMembers(tx, seriesID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load series works"})
+		return
+	}
+	if err := validateSeriesOrder(current, req.WorkIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Positions swap places mid-statement, so uniqueness is checked at commit
+	if _, err := tx.ExecContext(ctx, "SET CONSTRAINTS series_works_position_unique DEFERRED"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder series"})
+		return
+	}
+	ids := make([]string, len(req.WorkIDs))
+	for i, id := range req.WorkIDs {
+		ids[i] = id.String()
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE series_works sw SET position = o.position
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS o(work_id, position)
+		WHERE sw.series_id = $1 AND sw.work_id = o.work_id`, seriesID, pq.Array(ids))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder series"})
+		return
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE series SET updated_at = $1 WHERE id = $2", time.Now(), seriesID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder series"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	ws.invalidateSeriesNavigation(ctx, req.WorkIDs...)
+
+	positions := make([]gin.H, len(req.WorkIDs))
+	for i, id := range req.WorkIDs {
+		positions[i] = gin.H{"work_id": id, "position": i + 1}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Series reordered", "works": positions})
+}
+
+// seriesMembers lists the works in a series in their current order
+func seriesMembers(tx *sql.Tx, seriesID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := tx.Query("SELECT work_id FROM series_works WHERE series_id = $1 ORDER BY position", seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSeriesOrder(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	current := []uuid.UUID{a, b, c}
+
+	assert.NoError(t, validateSeriesOrder(current, []uuid.UUID{c, a, b}))
+	assert.NoError(t, validateSeriesOrder(nil, []uuid.UUID{}), "an empty series has nothing to reorder")
+
+	assert.Error(t, validateSeriesOrder(current, []uuid.UUID{c, a}), "every work must be placed")
+	assert.Error(t, validateSeriesOrder(current, []uuid.UUID{c, a, a}), "a work can't take two positions")
+	assert.Error(t, validateSeriesOrder(current, []uuid.UUID{c, a, uuid.New()}), "only works in the series can be placed")
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// SHARE LINKS
+// An unlisted work is published but never listed anywhere; it is read
+// through a share link, the work's URL with ?share=<token>. The token signs
+// the work and its current share_token_nonce with WORK_SHARE_SECRET, so
+// issuing a new link rotates the old one out and revoking clears the nonce.
+// Links are only issued when WORK_SHARE_SECRET is set.
+// =============================================================================
+
+// errInvalidShareToken is a share token that is malformed or wasn't signed
+// with our secret
+var errInvalidShareToken = errors.New("invalid share token")
+
+// ShareLink is an unlisted work's current share link
+type ShareLink struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// workShareSecret signs share links; empty turns them off
+func workShareSecret() string {
+	return getEnv("WORK_SHARE_SECRET", "")
+}
+
+// newShareNonce is a fresh random nonce for a work's share link
+func newShareNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// signShareToken is the base64url payload "v1:<work>:<nonce>", a dot, and
+// its base64url HMAC-SHA256
+func signShareToken(secret string, workID uuid.UUID, nonce string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte("v1:" + workID.String() + ":" + nonce))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(shareTokenMAC(secret, encoded))
+}
+
+// parseShareToken checks a share token's signature and returns the work and
+// nonce it was issued for
+func parseShareToken(secret, token string) (uuid.UUID, string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, "", errInvalidShareToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, shareTokenMAC(secret, encoded)) {
+		return uuid.Nil, "", errInvalidShareToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return uuid.Nil, "", errInvalidShareToken
+	}
+	parts := strings.Split(string(payload), ":")
+	if len(parts) != 3 || parts[0] != "v1" || parts[2] == "" {
+		return uuid.Nil, "", errInvalidShareToken
+	}
+	workID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return uuid.Nil, "", errInvalidShareToken
+	}
+	return workID, parts[2], nil
+}
+
+func shareTokenMAC(secret, encoded string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("share:" + encoded))
+	return mac.Sum(nil)
+}
+
+// shareLinkURL is the reader-facing link for a share token
+func shareLinkURL(workID uuid.UUID, token string) string {
+	base := strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:3000"), "/")
+	return base + "/works/" + workID.String() + "?share=" + url.QueryEscape(token)
+}
+
+// shareLinkGrantsAccess reports whether the request carries a current share
+// link for an unlisted work. Restricted works still need a login.
+func (ws *WorkService) shareLinkGrantsAccess(c *gin.Context, workID uuid.UUID) bool {
+	token := c.Query("share")
+	secret := workShareSecret()
+	if token == "" || secret == "" {
+		return false
+	}
+	tokenWorkID, nonce, err := parseShareToken(secret, token)
+	if err != nil || tokenWorkID != workID {
+		return false
+	}
+
+	var current sql.NullString
+	var restricted bool
+	err = ws.db.QueryRowContext(c.Request.Context(), `
+		SELECT share_token_nonce, COALESCE(restricted, false) FROM works
+		WHERE id = $1 AND status = 'unlisted' AND deleted_at IS NULL`, workID).Scan(&current, &restricted)
+	if err != nil || !current.Valid {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(current.String), []byte(nonce)) != 1 {
+		return false
+	}
+	_, signedIn := c.Get("user_id")
+	return signedIn || !restricted
+}
+
+// rejectUnsharedWorkReader answers 404 and returns true when the work is
+// unlisted and the reader is neither one of its authors nor holding its
+// share link
+func (ws *WorkService) rejectUnsharedWorkReader(c *gin.Context, workID uuid.UUID) bool {
+	ctx := c.Request.Context()
+	var unlisted bool
+	ws.db.QueryRowContext(ctx, "SELECT status = 'unlisted' FROM works WHERE id = $1", workID).Scan(&unlisted)
+	if !unlisted {
+		return false
+	}
+	if userID := ws.getUserIDFromContext(c); userID != nil {
+		if isCreator, err := ws.isWorkCreator(ctx, workID, *userID); err == nil && isCreator {
+			return false
+		}
+	}
+	if ws.shareLinkGrantsAccess(c, workID) {
+		return false
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+	return true
+}
+
+// requireShareLinkAuthor reads the work a share link endpoint is for and
+// checks the caller is one of its authors, answering the request itself
+// otherwise
+func (ws *WorkService) requireShareLinkAuthor(c *gin.Context) (uuid.UUID, bool) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return uuid.Nil, false
+	}
+	userID := ws.getUserIDFromContext(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return uuid.Nil, false
+	}
+	isCreator, err := ws.isWorkCreator(c.Request.Context(), workID, *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ownership"})
+		return uuid.Nil, false
+	}
+	if !isCreator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the work's authors can manage its share link"})
+		return uuid.Nil, false
+	}
+	if workShareSecret() == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Share links are not configured"})
+		return uuid.Nil, false
+	}
+	return workID, true
+}
+
+// GetWorkShareLink returns an unlisted work's current share link, if it has
+// one
+func (ws *WorkService) GetWorkShareLink(c *gin.Context) {
+	workID, ok := ws.requireShareLinkAuthor(c)
+	if !ok {
+		return
+	}
+
+	var status string
+	var nonce sql.NullString
+	var createdAt sql.NullTime
+	err := ws.db.QueryRowContext(c.Request.Context(), `
+		SELECT status, share_token_nonce, share_token_created_at FROM works
+		WHERE id = $1 AND deleted_at IS NULL`, workID).Scan(&status, &nonce, &createdAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch share link"})
+		return
+	}
+
+	response := gin.H{"status": status, "share_link": nil}
+	if nonce.Valid {
+		token := signShareToken(workShareSecret(), workID, nonce.String)
+		response["share_link"] = ShareLink{Token: token, URL: shareLinkURL(workID, token), CreatedAt: createdAt.Time}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateWorkShareLink issues a new share link for an unlisted work; any
+// earlier link stops working
+func (ws *WorkService) CreateWorkShareLink(c *gin.Context) {
+	workID, ok := ws.requireShareLinkAuthor(c)
+	if !ok {
+		return
+	}
+
+	nonce, err := newShareNonce()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	var createdAt time.Time
+	err = ws.db.QueryRowContext(c.Request.Context(), `
+		UPDATE works SET share_token_nonce = $2, share_token_created_at = NOW()
+		WHERE id = $1 AND status = 'unlisted' AND deleted_at IS NULL
+		RETURNING share_token_created_at`, workID, nonce).Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only unlisted works have share links"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	token := signShareToken(workShareSecret(), workID, nonce)
+	c.JSON(http.StatusCreated, gin.H{
+		"share_link": ShareLink{Token: token, URL: shareLinkURL(workID, token), CreatedAt: createdAt},
+		"message":    "Share link created; earlier links no longer work",
+	})
+}
+
+// RevokeWorkShareLink stops a work's share link working, leaving the work
+// readable only by its authors until a new link is issued
+func (ws *WorkService) RevokeWorkShareLink(c *gin.Context) {
+	workID, ok := ws.requireShareLinkAuthor(c)
+	if !ok {
+		return
+	}
+
+	_, err := ws.db.ExecContext(c.Request.Context(), `
+		UPDATE works SET share_token_nonce = NULL, share_token_created_at = NULL
+		WHERE id = $1`, workID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked"})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestShareTokenRoundTrip(t *testing.T) {
+	workID := uuid.New()
+	token := signShareToken("secret", workID, "abc123")
+
+	gotWork, gotNonce, err := parseShareToken("secret", token)
+	require.NoError(t, err)
+	assert.Equal(t, workID, gotWork)
+	assert.Equal(t, "abc123", gotNonce)
+}
+
+func TestParseShareTokenRejectsForgeries(t *testing.T) {
+	workID := uuid.New()
+	token := signShareToken("secret", workID, "abc123")
+
+	_, _, err := parseShareToken("other secret", token)
+	assert.ErrorIs(t, err, errInvalidShareToken, "a token signed with another secret")
+
+	// Pointing a valid signature at another work's payload
+	encoded, signature, _ := strings.Cut(token, ".")
+	other := signShareToken("secret", uuid.New(), "abc123")
+	otherEncoded, _, _ := strings.Cut(other, ".")
+	_, _, err = parseShareToken("secret", otherEncoded+"."+signature)
+	assert.ErrorIs(t, err, errInvalidShareToken)
+
+	for _, bad := range []string{"", "no-dot", encoded + ".", "." + signature, encoded + ".!!"} {
+		_, _, err = parseShareToken("secret", bad)
+		assert.ErrorIs(t, err, errInvalidShareToken, bad)
+	}
+}
+
+func TestNewShareNonce(t *testing.T) {
+	first, err := newShareNonce()
+	require.NoError(t, err)
+	second, err := newShareNonce()
+	require.NoError(t, err)
+
+	assert.Len(t, first, 32)
+	assert.NotEqual(t, first, second, "a new link must not reuse the old nonce")
+}
+
+func TestShareLinkURL(t *testing.T) {
+	t.Setenv("FRONTEND_URL", "https://archive.example/")
+	workID := uuid.MustParse("11111111-2222-3333-4444-555555555555")
+	assert.Equal(t, "https://archive.example/works/11111111-2222-3333-4444-555555555555?share=a.b",
+		shareLinkURL(workID, "a.b"))
+}
+
+func TestShareLinkGrantsAccessNeedsAToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("WORK_SHARE_SECRET", "secret")
+	ws := &WorkService{}
+	workID := uuid.New()
+
+	// Tokens that fail before the work is looked up
+	for _, query := range []string{"", "?share=garbage", "?share=" + signShareToken("secret", uuid.New(), "n")} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/api/v1/works/"+workID.String()+query, nil)
+		assert.False(t, ws.shareLinkGrantsAccess(c, workID), query)
+	}
+}
+
+func TestCanViewWorkHidesUnlistedWorks(t *testing.T) {
+	ws := &WorkService{}
+	author := uuid.New()
+	work := &models.Work{UserID: author, Status: "unlisted"}
+
+	assert.True(t, ws.canViewWork(context.Background(), work, &author))
+	assert.False(t, ws.canViewWork(context.Background(), work, nil), "readers need the share link")
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/models"
+)
+
+// Share target and bookmarklet ingestion: a URL shared from the browser
+// becomes a marked-for-later entry when it points at a work here, and an
+// external bookmark otherwise.
+
+var errInvalidShareURL = errors.New("invalid share URL")
+
+// maxExternalBookmarkTitle matches external_bookmarks.title
+const maxExternalBookmarkTitle = 500
+
+// sharedURLPattern finds a URL inside shared text
+var sharedURLPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// shareTarget is where a shared URL points. A work on this archive is
+// identified by its UUID or, for imported works, its legacy numeric ID.
+type shareTarget struct {
+	URL      string
+	WorkID   *uuid.UUID
+	LegacyID int
+}
+
+func (t shareTarget) internal() bool {
+	return t.WorkID != nil || t.LegacyID > 0
+}
+
+// sharedURL picks the URL out of a share. Some apps leave the url field
+// empty and put the link in the text instead.
+func sharedURL(rawURL, text string) string {
+	if rawURL = strings.TrimSpace(rawURL); rawURL != "" {
+		return rawURL
+	}
+	return sharedURLPattern.FindString(text)
+}
+
+// parseShareTarget checks that raw is an http(s) URL and works out whether
+// it links to a work on one of archiveHosts
+func parseShareTarget(raw string, archiveHosts []string) (shareTarget, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return shareTarget{}, errInvalidShareURL
+	}
+	u.Fragment = ""
+	target := shareTarget{URL: u.String()}
+
+	if !isArchiveHost(u.Hostname(), archiveHosts) {
+		return target, nil
+	}
+	// /works/{id}, optionally followed by /chapters/... and the like
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 || (segments[0] != "works" && segments[0] != "work") {
+		return target, nil
+	}
+	if id, err := uuid.Parse(segments[1]); err == nil {
+		target.WorkID = &id
+	} else if n, err := strconv.Atoi(segments[1]); err == nil && n > 0 {
+		target.LegacyID = n
+	}
+	return target, nil
+}
+
+// isArchiveHost reports whether host is one of ours, ignoring a www. prefix
+func isArchiveHost(host string, archiveHosts []string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for _, h := range archiveHosts {
+		if host == strings.TrimPrefix(strings.ToLower(h), "www.") {
+			return true
+		}
+	}
+	return false
+}
+
+// externalBookmarkTitle is the shared title, or the link's host when the
+// share didn't include one
+func externalBookmarkTitle(title, rawURL string) string {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			title = u.Hostname()
+		}
+	}
+	if utf8.RuneCountInString(title) > maxExternalBookmarkTitle {
+		title = string([]rune(title)[:maxExternalBookmarkTitle])
+	}
+	return title
+}
+
+// resolveSharedWork finds the work a share target links to.
+// sql.ErrNoRows means there isn't one.
+func (ws *WorkService) resolveSharedWork(ctx context.Context, target shareTarget) (uuid.UUID, error) {
+	var workID uuid.UUID
+	var err error
+	if target.WorkID != nil {
+		err = ws.db.QueryRowContext(ctx, "SELECT id FROM works WHERE id = $1", *target.WorkID).Scan(&workID)
+	} else {
+		err = ws.db.QueryRowContext(ctx, "SELECT id FROM works WHERE legacy_id = $1", target.LegacyID).Scan(&workID)
+	}
+	return workID, err
+}
+
+// ShareToArchive takes a URL and title from a browser share target or
+// bookmarklet and saves it for the current user
+func (ws *WorkService) ShareToArchive(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		URL   string `json:"url" form:"url"`
+		Title string `json:"title" form:"title"`
+		Text  string `json:"text" form:"text"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	target, err := parseShareTarget(sharedURL(req.URL, req.Text), archiveHosts(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid http or https URL is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if target.internal() {
+		ws.markSharedWorkForLater(c, userUUID, target)
+		return
+	}
+
+	var bookmark models.ExternalBookmark
+	var inserted bool
+	err = ws.db.QueryRowContext(ctx, `
+		INSERT INTO external_bookmarks (user_id, url, title)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, url) DO UPDATE SET title = EXCLUDED.title, updated_at = NOW()
+		RETURNING id, user_id, url, title, COALESCE(notes, ''), created_at, updated_at, (xmax = 0)`,
+		userUUID, target.URL, externalBookmarkTitle(req.Title, target.URL)).Scan(
+		&bookmark.ID, &bookmark.UserID, &bookmark.URL, &bookmark.Title, &bookmark.Notes,
+		&bookmark.CreatedAt, &bookmark.UpdatedAt, &inserted)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save external bookmark"})
+		return
+	}
+
+	status := http.StatusOK
+	if inserted {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{
+		"saved_as":          "external_bookmark",
+		"external_bookmark": bookmark,
+		"already_saved":     !inserted,
+	})
+}
+
+// markSharedWorkForLater adds a shared link to one of our works to the
+// user's marked-for-later list. Works the user can't see are reported as
+// not found so the endpoint doesn't reveal them.
+func (ws *WorkService) markSharedWorkForLater(c *gin.Context, userID uuid.UUID, target shareTarget) {
+	ctx := c.Request.Context()
+	workID, err := ws.resolveSharedWork(ctx, target)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up work"})
+		}
+		return
+	}
+
+	var canView bool
+	if err := ws.db.QueryRowContext(ctx, "SELECT can_user_view_work($1, $2)", workID, userID).Scan(&canView); err != nil || !canView {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	}
+
+	result, err := ws.db.ExecContext(ctx, `
+		INSERT INTO marked_for_later (user_id, work_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, work_id) DO NOTHING`, userID, workID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark work for later"})
+		return
+	}
+	added, _ := result.RowsAffected()
+
+	status := http.StatusOK
+	if added > 0 {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{
+		"saved_as":      "marked_for_later",
+		"work_id":       workID,
+		"already_saved": added == 0,
+	})
+}
+
+// GetMarkedForLater lists the current user's marked-for-later works, newest first
+func (ws *WorkService) GetMarkedForLater(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	page, limit := sharePageParams(c)
+
+	rows, err := ws.db.QueryContext(c.Request.Context(), `
+		SELECT m.work_id, w.title, COALESCE(`+workAuthorNamesSQL("w")+`, ''), m.created_at
+		FROM marked_for_later m
+		JOIN works w ON w.id = m.work_id
+		WHERE m.user_id = $1 AND w.status = 'posted'
+		ORDER BY m.created_at DESC
+		LIMIT $2 OFFSET $3`, userID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch marked for later works"})
+		return
+	}
+	defer rows.Close()
+
+	works := []models.MarkedForLater{}
+	for rows.Next() {
+		var m models.MarkedForLater
+		if err := rows.Scan(&m.WorkID, &m.Title, &m.Authors, &m.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch marked for later works"})
+			return
+		}
+		works = append(works, m)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"works": works, "page": page, "limit": limit})
+}
+
+// UnmarkForLater removes a work from the current user's marked-for-later list
+func (ws *WorkService) UnmarkForLater(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := ws.db.ExecContext(c.Request.Context(),
+		"DELETE FROM marked_for_later WHERE user_id = $1 AND work_id = $2", userID, workID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unmark work"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work is not marked for later"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Work removed from marked for later"})
+}
+
+// GetExternalBookmarks lists the current user's external bookmarks, newest first
+func (ws *WorkService) GetExternalBookmarks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	page, limit := sharePageParams(c)
+
+	rows, err := ws.db.QueryContext(c.Request.Context(), `
+		SELECT id, user_id, url, title, COALESCE(notes, ''), created_at, updated_at
+		FROM external_bookmarks
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`, userID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch external bookmarks"})
+		return
+	}
+	defer rows.Close()
+
+	bookmarks := []models.ExternalBookmark{}
+	for rows.Next() {
+		var b models.ExternalBookmark
+		if err := rows.Scan(&b.ID, &b.UserID, &b.URL, &b.Title, &b.Notes, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch external bookmarks"})
+			return
+		}
+		bookmarks = append(bookmarks, b)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"external_bookmarks": bookmarks, "page": page, "limit": limit})
+}
+
+// UpdateExternalBookmark changes an external bookmark's title or notes
+func (ws *WorkService) UpdateExternalBookmark(c *gin.Context) {
+	bookmarkID, err := uuid.Parse(c.Param("bookmark_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Title *string `json:"title"`
+		Notes *string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if req.Title != nil && strings.TrimSpace(*req.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title cannot be empty"})
+		return
+	}
+
+	var title sql.NullString
+	if req.Title != nil {
+		title = sql.NullString{String: externalBookmarkTitle(*req.Title, ""), Valid: true}
+	}
+	var notes sql.NullString
+	if req.Notes != nil {
+		notes = sql.NullString{String: *req.Notes, Valid: true}
+	}
+
+	var b models.ExternalBookmark
+	err = ws.db.QueryRowContext(c.Request.Context(), `
+		UPDATE external_bookmarks
+		SET title = COALESCE($3, title), notes = COALESCE($4, notes), updated_at = $5
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, user_id, url, title, COALESCE(notes, ''), created_at, updated_at`,
+		bookmarkID, userID, title, notes, time.Now()).Scan(
+		&b.ID, &b.UserID, &b.URL, &b.Title, &b.Notes, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"external_bookmark": b})
+}
+
+// DeleteExternalBookmark removes one of the current user's external bookmarks
+func (ws *WorkService) DeleteExternalBookmark(c *gin.Context) {
+	bookmarkID, err := uuid.Parse(c.Param("bookmark_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := ws.db.ExecContext(c.Request.Context(),
+		"DELETE FROM external_bookmarks WHERE id = $1 AND user_id = $2", bookmarkID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bookmark"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark deleted successfully"})
+}
+
+// sharePageParams reads page and limit, defaulting to the first 20
+func sharePageParams(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return page, limit
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseShareTarget(t *testing.T) {
+	hosts := []string{"api.nuclear-ao3.test", "nuclear-ao3.test"}
+	workID := uuid.New()
+
+	target, err := parseShareTarget("https://www.nuclear-ao3.test/works/"+workID.String()+"/chapters/2#part", hosts)
+	assert.NoError(t, err)
+	assert.True(t, target.internal())
+	assert.Equal(t, workID, *target.WorkID)
+
+	target, err = parseShareTarget("https://nuclear-ao3.test/works/12345", hosts)
+	assert.NoError(t, err)
+	assert.Equal(t, 12345, target.LegacyID)
+
+	target, err = parseShareTarget("https://nuclear-ao3.test/tags/Angst/works", hosts)
+	assert.NoError(t, err)
+	assert.False(t, target.internal(), "archive pages that aren't works are external bookmarks")
+
+	target, err = parseShareTarget("https://example.com/works/"+workID.String()+"#top", hosts)
+	assert.NoError(t, err)
+	assert.False(t, target.internal())
+	assert.Equal(t, "https://example.com/works/"+workID.String(), target.URL)
+
+	for _, bad := range []string{"", "javascript:alert(1)", "ftp://example.com/fic", "https://"} {
+		_, err := parseShareTarget(bad, hosts)
+		assert.ErrorIs(t, err, errInvalidShareURL, bad)
+	}
+}
+
+func TestSharedURL(t *testing.T) {
+	assert.Equal(t, "https://example.com/a", sharedURL(" https://example.com/a ", "ignored https://example.com/b"))
+	assert.Equal(t, "https://example.com/b", sharedURL("", "Check this out: https://example.com/b"))
+	assert.Equal(t, "", sharedURL("", "no link here"))
+}
+
+func TestExternalBookmarkTitle(t *testing.T) {
+	assert.Equal(t, "A Fic", externalBookmarkTitle("  A Fic ", "https://example.com/a"))
+	assert.Equal(t, "example.com", externalBookmarkTitle("", "https://example.com/a"))
+}
@@ -206,6 +206,14 @@ func (suite *StatisticsTestSuite) TestGetMyStats_Success() {
 	assert.GreaterOrEqual(suite.T(), int(stats["total_comments"].(float64)), 5)
 	assert.GreaterOrEqual(suite.T(), int(stats["total_bookmarks"].(float64)), 3)
 
+	// Rollups carry a full trend line and a fandom breakdown
+	trend, ok := stats["trend"].([]interface{})
+	assert.True(suite.T(), ok)
+	assert.Len(suite.T(), trend, userStatsTrendDays)
+	_, ok = stats["works_by_fandom"].([]interface{})
+	assert.True(suite.T(), ok)
+	assert.NotEmpty(suite.T(), stats["refreshed_at"])
+
 	// Check top works
 	topWorks, ok := stats["top_works"].([]interface{})
 	assert.True(suite.T(), ok)
@@ -0,0 +1,803 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+)
+
+// =============================================================================
+// TAG SETS
+// A tag set is a curated list of fandoms, characters and relationships. Its
+// owner adds tags directly, or opens nominations and approves the tags
+// users nominate. Gift exchanges can restrict sign-ups to one or more tag
+// sets, so every prompt asks for something the exchange has agreed to run.
+// =============================================================================
+
+// errTagSetNotFound is a challenge configured with a tag set that doesn't
+// exist
+var errTagSetNotFound = errors.New("tag set not found")
+
+// TagSetRequest creates or updates a tag set
+type TagSetRequest struct {
+	Title            string                     `json:"title" binding:"required,max=255"`
+	Description      string                     `json:"description"`
+	NominationsOpen  bool                       `json:"nominations_open"`
+	NominationLimits map[string]models.TagLimit `json:"nomination_limits"`
+}
+
+// TagSetTagInput is a tag added to or nominated for a tag set
+type TagSetTagInput struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Fandom string `json:"fandom"`
+}
+
+const tagSetColumns = `ts.id, ts.title, ts.description, ts.owner_id, ts.nominations_open, ts.nomination_limits,
+	(SELECT COUNT(*) FROM tag_set_tags tst WHERE tst.tag_set_id = ts.id), ts.created_at, ts.updated_at`
+
+func scanTagSet(scanner interface{ Scan(...interface{}) error }) (*models.TagSet, error) {
+	var s models.TagSet
+	var limits []byte
+	err := scanner.Scan(&s.ID, &s.Title, &s.Description, &s.OwnerID, &s.NominationsOpen, &limits,
+		&s.TagCount, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.NominationLimits = make(map[string]models.TagLimit)
+	if len(limits) > 0 {
+		if err := json.Unmarshal(limits, &s.NominationLimits); err != nil {
+			return nil, err
+		}
+	}
+	return &s, nil
+}
+
+func (ws *WorkService) getTagSet(tagSetID uuid.UUID) (*models.TagSet, error) {
+	return scanTagSet(ws.db.QueryRow("SELECT "+tagSetColumns+" FROM tag_sets ts WHERE ts.id = $1", tagSetID))
+}
+
+// validTagSetType reports whether a tag set can hold tags of tagType
+func validTagSetType(tagType string) bool {
+	for _, t := range models.TagSetTagTypes {
+		if t == tagType {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTagSetTags trims the tags and checks each has a name and a type
+// a tag set can hold. Repeats of the same tag are dropped.
+func normalizeTagSetTags(tags []TagSetTagInput) ([]TagSetTagInput, error) {
+	normalized := make([]TagSetTagInput, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		t.Type = strings.TrimSpace(t.Type)
+		t.Name = strings.TrimSpace(t.Name)
+		t.Fandom = strings.TrimSpace(t.Fandom)
+		if t.Name == "" {
+			return nil, fmt.Errorf("every tag needs a name")
+		}
+		if !validTagSetType(t.Type) {
+			return nil, fmt.Errorf("%q: tag sets hold only %s tags", t.Name, strings.Join(models.TagSetTagTypes, ", "))
+		}
+		key := t.Type + ":" + strings.ToLower(t.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, t)
+	}
+	return normalized, nil
+}
+
+// validateNominationLimits checks one user's nominations against the tag
+// set's per-type limits
+func validateNominationLimits(nominations []TagSetTagInput, limits map[string]models.TagLimit) error {
+	counts := make(map[string]int)
+	for _, n := range nominations {
+		counts[n.Type]++
+	}
+	for _, tagType := range models.TagSetTagTypes {
+		limit, ok := limits[tagType]
+		if !ok {
+			continue
+		}
+		if counts[tagType] < limit.Min {
+			return fmt.Errorf("at least %d %s nomination(s) required", limit.Min, tagType)
+		}
+		if limit.Max > 0 && counts[tagType] > limit.Max {
+			return fmt.Errorf("at most %d %s nomination(s) allowed", limit.Max, tagType)
+		}
+	}
+	return nil
+}
+
+// tagsOutsideTagSets lists the tags a restricted challenge won't accept:
+// fandoms, characters and relationships that aren't in any of its tag sets.
+// allowed maps lowercased names to their type in the tag sets; tagTypes
+// gives the type of tags the archive knows.
+func tagsOutsideTagSets(tags []string, tagTypes, allowed map[string]string) []string {
+	outside := []string{}
+	for _, tag := range tags {
+		name := strings.ToLower(strings.TrimSpace(tag))
+		if _, ok := allowed[name]; ok {
+			continue
+		}
+		if validTagSetType(tagTypes[name]) {
+			outside = append(outside, tag)
+		}
+	}
+	return outside
+}
+
+// challengeTagSetTags maps the lowercased names of every tag in a
+// challenge's tag sets to its type. It is empty when sign-ups aren't
+// restricted to tag sets.
+func (ws *WorkService) challengeTagSetTags(collectionID uuid.UUID) (map[string]string, error) {
+	rows, err := ws.db.Query(`
+		SELECT DISTINCT LOWER(tst.name::text), tst.tag_type
+		FROM challenge_tag_sets cts
+		JOIN tag_set_tags tst ON tst.tag_set_id = cts.tag_set_id
+		WHERE cts.collection_id = $1`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	allowed := make(map[string]string)
+	for rows.Next() {
+		var name, tagType string
+		if err := rows.Scan(&name, &tagType); err != nil {
+			return nil, err
+		}
+		allowed[name] = tagType
+	}
+	return allowed, rows.Err()
+}
+
+// challengeTagSetIDs lists the tag sets a challenge's sign-ups are
+// restricted to
+func (ws *WorkService) challengeTagSetIDs(collectionID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := ws.db.Query("SELECT tag_set_id FROM challenge_tag_sets WHERE collection_id = $1 ORDER BY tag_set_id", collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// requireTagSetOwner writes an error response and returns false unless the
+// current user owns the tag set
+func (ws *WorkService) requireTagSetOwner(c *gin.Context, tagSetID uuid.UUID) (*models.TagSet, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	tagSet, err := ws.getTagSet(tagSetID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag set not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tag set"})
+		return nil, false
+	}
+	if tagSet.OwnerID.String() != userID.(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the tag set's owner can do this"})
+		return nil, false
+	}
+	return tagSet, true
+}
+
+func parseTagSetID(c *gin.Context) (uuid.UUID, bool) {
+	tagSetID, err := uuid.Parse(c.Param("tag_set_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag set ID"})
+		return uuid.Nil, false
+	}
+	return tagSetID, true
+}
+
+// validNominationLimits checks limits only name types a tag set holds
+func validNominationLimits(limits map[string]models.TagLimit) bool {
+	for tagType, limit := range limits {
+		if !validTagSetType(tagType) || limit.Min < 0 || (limit.Max > 0 && limit.Max < limit.Min) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListTagSets lists tag sets, newest first
+func (ws *WorkService) ListTagSets(c *gin.Context) {
+	limit, offset := 20, 0
+	fmt.Sscan(c.DefaultQuery("limit", "20"), &limit)
+	fmt.Sscan(c.DefaultQuery("offset", "0"), &offset)
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := ws.db.Query("SELECT "+tagSetColumns+" FROM tag_sets ts ORDER BY ts.created_at DESC, ts.id LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tag sets"})
+		return
+	}
+	defer rows.Close()
+
+	tagSets := []*models.TagSet{}
+	for rows.Next() {
+		s, err := scanTagSet(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read tag sets"})
+			return
+		}
+		tagSets = append(tagSets, s)
+	}
+	c.JSON(http.StatusOK, gin.H{"tag_sets": tagSets, "limit": limit, "offset": offset})
+}
+
+// GetTagSet returns a tag set with its tags grouped by type
+func (ws *WorkService) GetTagSet(c *gin.Context) {
+	tagSetID, ok := parseTagSetID(c)
+	if !ok {
+		return
+	}
+
+	tagSet, err := ws.getTagSet(tagSetID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag set not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tag set"})
+		return
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT tag_type, name, fandom FROM tag_set_tags
+		WHERE tag_set_id = $1
+		ORDER BY tag_type, fandom NULLS FIRST, name`, tagSetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tag set tags"})
+		return
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]models.TagSetTag)
+	for _, tagType := range models.TagSetTagTypes {
+		tags[tagType] = []models.TagSetTag{}
+	}
+	for rows.Next() {
+		var t models.TagSetTag
+		if err := rows.Scan(&t.Type, &t.Name, &t.Fandom); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read tag set tags"})
+			return
+		}
+		tags[t.Type] = append(tags[t.Type], t)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tag_set": tagSet, "tags": tags})
+}
+
+// CreateTagSet creates a tag set owned by the caller
+func (ws *WorkService) CreateTagSet(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req TagSetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if !validNominationLimits(req.NominationLimits) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid nomination limits"})
+		return
+	}
+	if req.NominationLimits == nil {
+		req.NominationLimits = map[string]models.TagLimit{}
+	}
+	limitsJSON, _ := json.Marshal(req.NominationLimits)
+
+	var tagSetID uuid.UUID
+	err := ws.db.QueryRow(`
+		INSERT INTO tag_sets (title, description, owner_id, nominations_open, nomination_limits)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`, strings.TrimSpace(req.Title), req.Description, userID, req.NominationsOpen, limitsJSON).Scan(&tagSetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag set"})
+		return
+	}
+
+	tagSet, err := ws.getTagSet(tagSetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tag set"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"tag_set": tagSet})
+}
+
+// UpdateTagSet changes a tag set's details, and opens or closes nominations
+func (ws *WorkService) UpdateTagSet(c *gin.Context) {
+	tagSetID, ok := parseTagSetID(c)
+	if !ok {
+		return
+	}
+
+	var req TagSetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if !validNominationLimits(req.NominationLimits) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid nomination limits"})
+		return
+	}
+	if _, ok := ws.requireTagSetOwner(c, tagSetID); !ok {
+		return
+	}
+	if req.NominationLimits == nil {
+		req.NominationLimits = map[string]models.TagLimit{}
+	}
+	limitsJSON, _ := json.Marshal(req.NominationLimits)
+
+	_, err := ws.db.Exec(`
+		UPDATE tag_sets
+		SET title = $2, description = $3, nominations_open = $4, nomination_limits = $5, updated_at = NOW()
+		WHERE id = $1`, tagSetID, strings.TrimSpace(req.Title), req.Description, req.NominationsOpen, limitsJSON)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag set"})
+		return
+	}
+
+	tagSet, err := ws.getTagSet(tagSetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tag set"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tag_set": tagSet})
+}
+
+// DeleteTagSet deletes a tag set no challenge is using
+func (ws *WorkService) DeleteTagSet(c *gin.Context) {
+	tagSetID, ok := parseTagSetID(c)
+	if !ok {
+		return
+	}
+	if _, ok := ws.requireTagSetOwner(c, tagSetID); !ok {
+		return
+	}
+
+	var inUse int
+	if err := ws.db.QueryRow("SELECT COUNT(*) FROM challenge_tag_sets WHERE tag_set_id = $1", tagSetID).Scan(&inUse); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check tag set use"})
+		return
+	}
+	if inUse > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("This tag set restricts sign-ups for %d challenge(s); remove it from them first", inUse)})
+		return
+	}
+
+	if _, err := ws.db.Exec("DELETE FROM tag_sets WHERE id = $1", tagSetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag set"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tag set deleted"})
+}
+
+// AddTagSetTags adds tags to a tag set directly (owner only)
+func (ws *WorkService) AddTagSetTags(c *gin.Context) {
+	tagSetID, ok := parseTagSetID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Tags []TagSetTagInput `json:"tags" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	tags, err := normalizeTagSetTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := ws.requireTagSetOwner(c, tagSetID); !ok {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, t := range tags {
+		res, err := tx.Exec(`
+			INSERT INTO tag_set_tags (tag_set_id, tag_type, name, fandom, added_by)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+			ON CONFLICT (tag_set_id, tag_type, name) DO NOTHING`, tagSetID, t.Type, t.Name, t.Fandom, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add tags"})
+			return
+		}
+		n, _ := res.RowsAffected()
+		added += int(n)
+	}
+	if _, err := tx.Exec("UPDATE tag_sets SET updated_at = NOW() WHERE id = $1", tagSetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add tags"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"added": added, "already_present": len(tags) - added})
+}
+
+// RemoveTagSetTag removes one tag from a tag set (owner only)
+func (ws *WorkService) RemoveTagSetTag(c *gin.Context) {
+	tagSetID, ok := parseTagSetID(c)
+	if !ok {
+		return
+	}
+	tagType, name := c.Query("type"), strings.TrimSpace(c.Query("name"))
+	if !validTagSetType(tagType) || name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type and name are required"})
+		return
+	}
+	if _, ok := ws.requireTagSetOwner(c, tagSetID); !ok {
+		return
+	}
+
+	res, err := ws.db.Exec("DELETE FROM tag_set_tags WHERE tag_set_id = $1 AND tag_type = $2 AND name = $3", tagSetID, tagType, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove tag"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not in this tag set"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tag removed"})
+}
+
+// SubmitTagSetNominations replaces the caller's pending nominations while
+// nominations are open. Nominations already reviewed are kept as they are.
+func (ws *WorkService) SubmitTagSetNominations(c *gin.Context) {
+	tagSetID, ok := parseTagSetID(c)
+	if !ok {
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Nominations []TagSetTagInput `json:"nominations"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	nominations, err := normalizeTagSetTags(req.Nominations)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagSet, err := ws.getTagSet(tagSetID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag set not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tag set"})
+		return
+	}
+	if !tagSet.NominationsOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "Nominations are closed"})
+		return
+	}
+	if err := validateNominationLimits(nominations, tagSet.NominationLimits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM tag_set_nominations WHERE tag_set_id = $1 AND nominator_id = $2 AND status = 'pending'", tagSetID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save nominations"})
+		return
+	}
+	for _, n := range nominations {
+		_, err := tx.Exec(`
+			INSERT INTO tag_set_nominations (tag_set_id, nominator_id, tag_type, name, fandom)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+			ON CONFLICT (tag_set_id, nominator_id, tag_type, name) DO NOTHING`,
+			tagSetID, userID, n.Type, n.Name, n.Fandom)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save nominations"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save nominations"})
+		return
+	}
+
+	mine, err := ws.loadTagSetNominations("tag_set_id = $1 AND nominator_id = $2", tagSetID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch nominations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"nominations": mine})
+}
+
+func (ws *WorkService) loadTagSetNominations(where string, args ...interface{}) ([]models.TagSetNomination, error) {
+	rows, err := ws.db.Query(`
+		SELECT id, tag_set_id, nominator_id, tag_type, name, fandom, status, reviewed_at, created_at
+		FROM tag_set_nominations
+		WHERE `+where+`
+		ORDER BY tag_type, name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nominations := []models.TagSetNomination{}
+	for rows.Next() {
+		var n models.TagSetNomination
+		if err := rows.Scan(&n.ID, &n.TagSetID, &n.NominatorID, &n.Type, &n.Name, &n.Fandom, &n.Status, &n.ReviewedAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		nominations = append(nominations, n)
+	}
+	return nominations, rows.Err()
+}
+
+// GetMyTagSetNominations returns the caller's nominations for a tag set
+func (ws *WorkService) GetMyTagSetNominations(c *gin.Context) {
+	tagSetID, ok := parseTagSetID(c)
+	if !ok {
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	mine, err := ws.loadTagSetNominations("tag_set_id = $1 AND nominator_id = $2", tagSetID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch nominations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"nominations": mine})
+}
+
+// ListTagSetNominations lists nominated tags with how many users nominated
+// each, most nominated first (owner only)
+func (ws *WorkService) ListTagSetNominations(c *gin.Context) {
+	tagSetID, ok := parseTagSetID(c)
+	if !ok {
+		return
+	}
+	status := c.DefaultQuery("status", models.NominationStatusPending)
+	if status != models.NominationStatusPending && status != models.NominationStatusApproved && status != models.NominationStatusRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if _, ok := ws.requireTagSetOwner(c, tagSetID); !ok {
+		return
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT tag_type, name, MIN(fandom), COUNT(*)
+		FROM tag_set_nominations
+		WHERE tag_set_id = $1 AND status = $2
+		GROUP BY tag_type, name
+		ORDER BY COUNT(*) DESC, tag_type, name`, tagSetID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch nominations"})
+		return
+	}
+	defer rows.Close()
+
+	type nominatedTag struct {
+		models.TagSetTag
+		Nominations int `json:"nominations"`
+	}
+	nominated := []nominatedTag{}
+	for rows.Next() {
+		var n nominatedTag
+		if err := rows.Scan(&n.Type, &n.Name, &n.Fandom, &n.Nominations); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read nominations"})
+			return
+		}
+		nominated = append(nominated, n)
+	}
+	c.JSON(http.StatusOK, gin.H{"nominations": nominated, "status": status})
+}
+
+// ReviewTagSetNominations approves nominated tags into the tag set, or
+// rejects them, for every user who nominated them (owner only)
+func (ws *WorkService) ReviewTagSetNominations(c *gin.Context) {
+	tagSetID, ok := parseTagSetID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Approve []TagSetTagInput `json:"approve"`
+		Reject  []TagSetTagInput `json:"reject"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	approve, err := normalizeTagSetTags(req.Approve)
+	if err == nil {
+		req.Reject, err = normalizeTagSetTags(req.Reject)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(approve)+len(req.Reject) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to review"})
+		return
+	}
+	if _, ok := ws.requireTagSetOwner(c, tagSetID); !ok {
+		return
+	}
+	userID, _ := c.Get("user_id")
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	review := func(t TagSetTagInput, status string) (int64, error) {
+		res, err := tx.Exec(`
+			UPDATE tag_set_nominations
+			SET status = $4, reviewed_by = $5, reviewed_at = NOW()
+			WHERE tag_set_id = $1 AND tag_type = $2 AND name = $3 AND status = 'pending'`,
+			tagSetID, t.Type, t.Name, status, userID)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+
+	reviewed := map[string][]string{models.NominationStatusApproved: {}, models.NominationStatusRejected: {}}
+	for _, t := range approve {
+		n, err := review(t, models.NominationStatusApproved)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review nominations"})
+			return
+		}
+		if n == 0 {
+			continue
+		}
+		// The fandom most nominators gave goes with the tag
+		_, err = tx.Exec(`
+			INSERT INTO tag_set_tags (tag_set_id, tag_type, name, fandom, added_by)
+			SELECT $1, $2, $3, (
+				SELECT fandom FROM tag_set_nominations
+				WHERE tag_set_id = $1 AND tag_type = $2 AND name = $3 AND fandom IS NOT NULL
+				GROUP BY fandom ORDER BY COUNT(*) DESC, fandom LIMIT 1
+			), $4
+			ON CONFLICT (tag_set_id, tag_type, name) DO NOTHING`, tagSetID, t.Type, t.Name, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add approved tags"})
+			return
+		}
+		reviewed[models.NominationStatusApproved] = append(reviewed[models.NominationStatusApproved], t.Name)
+	}
+	for _, t := range req.Reject {
+		n, err := review(t, models.NominationStatusRejected)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review nominations"})
+			return
+		}
+		if n > 0 {
+			reviewed[models.NominationStatusRejected] = append(reviewed[models.NominationStatusRejected], t.Name)
+		}
+	}
+	if _, err := tx.Exec("UPDATE tag_sets SET updated_at = NOW() WHERE id = $1", tagSetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review nominations"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review nominations"})
+		return
+	}
+
+	for _, names := range reviewed {
+		sort.Strings(names)
+	}
+	c.JSON(http.StatusOK, gin.H{"approved": reviewed[models.NominationStatusApproved], "rejected": reviewed[models.NominationStatusRejected]})
+}
+
+// setChallengeTagSets replaces the tag sets a challenge's sign-ups are
+// restricted to
+func setChallengeTagSets(tx *sql.Tx, collectionID uuid.UUID, tagSetIDs []uuid.UUID) error {
+	if _, err := tx.Exec("DELETE FROM challenge_tag_sets WHERE collection_id = $1", collectionID); err != nil {
+		return err
+	}
+	if len(tagSetIDs) == 0 {
+		return nil
+	}
+	ids := make([]string, len(tagSetIDs))
+	for i, id := range tagSetIDs {
+		ids[i] = id.String()
+	}
+	res, err := tx.Exec(`
+		INSERT INTO challenge_tag_sets (collection_id, tag_set_id)
+		SELECT $1, id FROM tag_sets WHERE id = ANY($2::uuid[])`, collectionID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); int(n) != len(uniqueUUIDs(tagSetIDs)) {
+		return errTagSetNotFound
+	}
+	return nil
+}
+
+// uniqueUUIDs drops repeated IDs, keeping the first of each
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
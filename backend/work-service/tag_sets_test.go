@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestNormalizeTagSetTags(t *testing.T) {
+	tags, err := normalizeTagSetTags([]TagSetTagInput{
+		{Type: "fandom", Name: " Good Omens "},
+		{Type: "character", Name: "Crowley", Fandom: "Good Omens"},
+		{Type: "fandom", Name: "good omens"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []TagSetTagInput{
+		{Type: "fandom", Name: "Good Omens"},
+		{Type: "character", Name: "Crowley", Fandom: "Good Omens"},
+	}, tags)
+
+	_, err = normalizeTagSetTags([]TagSetTagInput{{Type: "freeform", Name: "Fluff"}})
+	assert.Error(t, err)
+	_, err = normalizeTagSetTags([]TagSetTagInput{{Type: "fandom", Name: "  "}})
+	assert.Error(t, err)
+}
+
+func TestValidateNominationLimits(t *testing.T) {
+	limits := map[string]models.TagLimit{
+		"fandom":    {Min: 1, Max: 2},
+		"character": {Max: 1},
+	}
+	nominations := []TagSetTagInput{
+		{Type: "fandom", Name: "Good Omens"},
+		{Type: "character", Name: "Crowley"},
+		{Type: "relationship", Name: "Aziraphale/Crowley"},
+	}
+	assert.NoError(t, validateNominationLimits(nominations, limits))
+
+	err := validateNominationLimits(nominations[1:], limits)
+	assert.EqualError(t, err, "at least 1 fandom nomination(s) required")
+
+	tooMany := append(nominations, TagSetTagInput{Type: "character", Name: "Aziraphale"})
+	assert.EqualError(t, validateNominationLimits(tooMany, limits), "at most 1 character nomination(s) allowed")
+}
+
+func TestTagsOutsideTagSets(t *testing.T) {
+	allowed := map[string]string{"good omens": "fandom", "crowley": "character"}
+	tagTypes := map[string]string{
+		"good omens": "fandom",
+		"crowley":    "character",
+		"sherlock":   "fandom",
+		"fluff":      "freeform",
+	}
+
+	// Freeforms and tags the archive doesn't know aren't restricted
+	outside := tagsOutsideTagSets([]string{"Good Omens", "Crowley", "Fluff", "Brand New Tag", "Sherlock"}, tagTypes, allowed)
+	assert.Equal(t, []string{"Sherlock"}, outside)
+	assert.Empty(t, tagsOutsideTagSets([]string{" CROWLEY "}, tagTypes, allowed))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/middleware"
+)
+
+// =============================================================================
+// TAG SUGGESTIONS
+// When a work is posted or edited, tags that aren't canonical are checked
+// against tag-service's synonyms, and the author is offered the canonical
+// ("did you mean 'Hurt/Comfort'?"). Suggestions are advisory: the work is
+// saved with the tags as typed, and if tag-service is slow or down the work
+// is saved without them.
+// =============================================================================
+
+// tagSuggestionTimeout bounds how long saving a work waits for suggestions
+const tagSuggestionTimeout = 1500 * time.Millisecond
+
+// tagSuggestionFields maps the work fields that take free-text tags to the
+// tag type each holds
+var tagSuggestionFields = []struct {
+	Field string
+	Type  string
+}{
+	{"fandoms", "fandom"},
+	{"characters", "character"},
+	{"relationships", "relationship"},
+	{"freeform_tags", "freeform"},
+}
+
+// TagSuggestion offers a canonical tag in place of one the author typed
+type TagSuggestion struct {
+	Field         string    `json:"field"`
+	Tag           string    `json:"tag"`
+	CanonicalID   uuid.UUID `json:"canonical_id"`
+	CanonicalName string    `json:"canonical_name"`
+	Reason        string    `json:"reason"`
+	Message       string    `json:"message"`
+}
+
+// tagSuggestionQuery is one tag sent to tag-service
+type tagSuggestionQuery struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// canonicalSuggestion is tag-service's answer for one tag
+type canonicalSuggestion struct {
+	Name          string    `json:"name"`
+	Type          string    `json:"type"`
+	CanonicalID   uuid.UUID `json:"canonical_id"`
+	CanonicalName string    `json:"canonical_name"`
+	Reason        string    `json:"reason"`
+}
+
+// SuggestCanonicalTags asks tag-service for canonicals of the given tags
+func (tc *TagServiceClient) SuggestCanonicalTags(ctx context.Context, tags []tagSuggestionQuery) ([]canonicalSuggestion, error) {
+	body, err := json.Marshal(map[string]interface{}{"tags": tags})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tc.baseURL+"/api/v1/internal/tags/suggestions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.ServiceTokenHeader, os.Getenv(middleware.ServiceTokenEnv))
+
+	resp, err := tc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tag-service returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Suggestions []canonicalSuggestion `json:"suggestions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Suggestions, nil
+}
+
+// workTagFields collects a work's free-text tags by field
+func workTagFields(fandoms, characters, relationships, freeformTags []string) map[string][]string {
+	return map[string][]string{
+		"fandoms":       fandoms,
+		"characters":    characters,
+		"relationships": relationships,
+		"freeform_tags": freeformTags,
+	}
+}
+
+// tagSuggestionQueries lists the tags to check, by field
+func tagSuggestionQueries(fields map[string][]string) []tagSuggestionQuery {
+	queries := []tagSuggestionQuery{}
+	for _, f := range tagSuggestionFields {
+		for _, name := range fields[f.Field] {
+			if name = strings.TrimSpace(name); name != "" {
+				queries = append(queries, tagSuggestionQuery{Name: name, Type: f.Type})
+			}
+		}
+	}
+	return queries
+}
+
+// attachTagSuggestions matches tag-service's answers back to the fields the
+// tags were typed in, with a message for the author
+func attachTagSuggestions(fields map[string][]string, suggestions []canonicalSuggestion) []TagSuggestion {
+	byTag := map[tagSuggestionQuery]canonicalSuggestion{}
+	for _, s := range suggestions {
+		byTag[tagSuggestionQuery{Name: s.Name, Type: s.Type}] = s
+	}
+
+	attached := []TagSuggestion{}
+	for _, f := range tagSuggestionFields {
+		for _, name := range fields[f.Field] {
+			name = strings.TrimSpace(name)
+			s, ok := byTag[tagSuggestionQuery{Name: name, Type: f.Type}]
+			if !ok {
+				continue
+			}
+			message := fmt.Sprintf("Did you mean the canonical tag '%s'?", s.CanonicalName)
+			if s.Reason == "synonym" {
+				message = fmt.Sprintf("'%s' is a synonym of the canonical tag '%s'; using the canonical helps readers find your work.", name, s.CanonicalName)
+			}
+			attached = append(attached, TagSuggestion{
+				Field:         f.Field,
+				Tag:           name,
+				CanonicalID:   s.CanonicalID,
+				CanonicalName: s.CanonicalName,
+				Reason:        s.Reason,
+				Message:       message,
+			})
+		}
+	}
+	return attached
+}
+
+// tagSuggestions returns canonical suggestions for a work's tags, or none if
+// tag-service can't answer in time
+func (ws *WorkService) tagSuggestions(ctx context.Context, fields map[string][]string) []TagSuggestion {
+	queries := tagSuggestionQueries(fields)
+	if len(queries) == 0 {
+		return []TagSuggestion{}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tagSuggestionTimeout)
+	defer cancel()
+	client := NewTagServiceClient(getEnv("TAG_SERVICE_URL", "http://tag-service:8083"))
+	suggestions, err := client.SuggestCanonicalTags(ctx, queries)
+	if err != nil {
+		log.Printf("Tag suggestions unavailable: %v", err)
+		return []TagSuggestion{}
+	}
+	return attachTagSuggestions(fields, suggestions)
+}
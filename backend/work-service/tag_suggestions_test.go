@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagSuggestionQueries(t *testing.T) {
+	fields := workTagFields([]string{"Harry Potter"}, nil, []string{" Harry/Draco ", ""}, []string{"hurt comfort"})
+	assert.Equal(t, []tagSuggestionQuery{
+		{Name: "Harry Potter", Type: "fandom"},
+		{Name: "Harry/Draco", Type: "relationship"},
+		{Name: "hurt comfort", Type: "freeform"},
+	}, tagSuggestionQueries(fields))
+
+	assert.Empty(t, tagSuggestionQueries(workTagFields(nil, nil, nil, nil)))
+}
+
+func TestAttachTagSuggestions(t *testing.T) {
+	hcID, drarryID := uuid.New(), uuid.New()
+	fields := workTagFields(nil, nil, []string{"Harry/Draco"}, []string{"hurt comfort", "Fluff"})
+	attached := attachTagSuggestions(fields, []canonicalSuggestion{
+		{Name: "hurt comfort", Type: "freeform", CanonicalID: hcID, CanonicalName: "Hurt/Comfort", Reason: "similar"},
+		{Name: "Harry/Draco", Type: "relationship", CanonicalID: drarryID, CanonicalName: "Draco Malfoy/Harry Potter", Reason: "synonym"},
+		// An answer for a tag in a different field is not attached
+		{Name: "Fluff", Type: "character", CanonicalID: uuid.New(), CanonicalName: "Fluff (Character)", Reason: "similar"},
+	})
+
+	assert.Len(t, attached, 2)
+	assert.Equal(t, "relationships", attached[0].Field)
+	assert.Equal(t, drarryID, attached[0].CanonicalID)
+	assert.Contains(t, attached[0].Message, "is a synonym of the canonical tag 'Draco Malfoy/Harry Potter'")
+
+	assert.Equal(t, "freeform_tags", attached[1].Field)
+	assert.Equal(t, "hurt comfort", attached[1].Tag)
+	assert.Equal(t, "Did you mean the canonical tag 'Hurt/Comfort'?", attached[1].Message)
+}
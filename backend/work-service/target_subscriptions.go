@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+)
+
+// subscriptionRepository returns the shared subscription store, built on
+// the service's database when one wasn't injected
+func (ws *WorkService) subscriptionRepository() notifications.SubscriptionRepository {
+	if ws.subscriptions != nil {
+		return ws.subscriptions
+	}
+	return notifications.NewPostgresSubscriptionRepository(ws.db)
+}
+
+// defaultSubscriptionEvents are the events a one-click subscription listens for
+func defaultSubscriptionEvents(subType models.SubscriptionType) []models.NotificationEvent {
+	switch subType {
+	case models.SubscriptionWork:
+		return []models.NotificationEvent{models.EventWorkUpdated, models.EventWorkCompleted}
+	case models.SubscriptionAuthor:
+		return []models.NotificationEvent{models.EventNewWork, models.EventWorkUpdated}
+	case models.SubscriptionSeries:
+		return []models.NotificationEvent{models.EventSeriesUpdated, models.EventWorkUpdated}
+	default:
+		return []models.NotificationEvent{models.EventNewWork}
+	}
+}
+
+// SubscribeToWork subscribes the current user to a work's updates
+func (ws *WorkService) SubscribeToWork(c *gin.Context) {
+	ws.subscribeToTarget(c, models.SubscriptionWork, "work_id")
+}
+
+// UnsubscribeFromWork removes the current user's work subscription
+func (ws *WorkService) UnsubscribeFromWork(c *gin.Context) {
+	ws.unsubscribeFromTarget(c, models.SubscriptionWork, "work_id")
+}
+
+// SubscribeToUser subscribes the current user to everything an author posts
+func (ws *WorkService) SubscribeToUser(c *gin.Context) {
+	ws.subscribeToTarget(c, models.SubscriptionAuthor, "user_id")
+}
+
+// UnsubscribeFromUser removes the current user's author subscription
+func (ws *WorkService) UnsubscribeFromUser(c *gin.Context) {
+	ws.unsubscribeFromTarget(c, models.SubscriptionAuthor, "user_id")
+}
+
+// SubscribeToSeries subscribes the current user to a series
+func (ws *WorkService) SubscribeToSeries(c *gin.Context) {
+	ws.subscribeToTarget(c, models.SubscriptionSeries, "series_id")
+}
+
+// UnsubscribeFromSeries removes the current user's series subscription
+func (ws *WorkService) UnsubscribeFromSeries(c *gin.Context) {
+	ws.unsubscribeFromTarget(c, models.SubscriptionSeries, "series_id")
+}
+
+// subscriptionTargetName looks up the title or username a subscription
+// points at, returning sql.ErrNoRows if the target doesn't exist
+func (ws *WorkService) subscriptionTargetName(subType models.SubscriptionType, targetID uuid.UUID) (string, error) {
+	var name string
+	var err error
+	switch subType {
+	case models.SubscriptionWork:
+		err = ws.db.QueryRow("SELECT title FROM works WHERE id = $1 AND status != 'draft'", targetID).Scan(&name)
+	case models.SubscriptionAuthor:
+		err = ws.db.QueryRow("SELECT username FROM users WHERE id = $1", targetID).Scan(&name)
+	case models.SubscriptionSeries:
+		err = ws.db.QueryRow("SELECT title FROM series WHERE id = $1", targetID).Scan(&name)
+	default:
+		err = fmt.Errorf("unsupported subscription type %q", subType)
+	}
+	return name, err
+}
+
+func (ws *WorkService) subscribeToTarget(c *gin.Context, subType models.SubscriptionType, param string) {
+	targetID, err := uuid.Parse(c.Param(param))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if subType == models.SubscriptionAuthor && targetID == userUUID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot subscribe to yourself"})
+		return
+	}
+
+	targetName, err := ws.subscriptionTargetName(subType, targetID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription target not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscription target"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	repo := ws.subscriptionRepository()
+
+	existing, err := repo.FindByUserAndTarget(ctx, userUUID, targetID, subType)
+	if err == nil {
+		if !existing.IsActive {
+			existing.IsActive = true
+			existing.TargetName = targetName
+			if err := repo.UpdateSubscription(ctx, existing); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subscription"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"subscription": existing})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check subscription"})
+		return
+	}
+
+	now := time.Now()
+	subscription := &models.Subscription{
+		ID:         uuid.New(),
+		UserID:     userUUID,
+		Type:       subType,
+		TargetID:   targetID,
+		TargetName: targetName,
+		Events:     defaultSubscriptionEvents(subType),
+		Frequency:  models.FrequencyImmediate,
+		IsActive:   true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := repo.CreateSubscription(ctx, subscription); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subscription"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"subscription": subscription})
+}
+
+func (ws *WorkService) unsubscribeFromTarget(c *gin.Context, subType models.SubscriptionType, param string) {
+	targetID, err := uuid.Parse(c.Param(param))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	repo := ws.subscriptionRepository()
+
+	existing, err := repo.FindByUserAndTarget(ctx, userUUID, targetID, subType)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check subscription"})
+		return
+	}
+	if err := repo.DeleteSubscription(ctx, existing.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed"})
+}
+
+// notifyChapterPosted tells subscribers of the work, its authors and its
+// series that a chapter went up, in the transaction posting it. The
+// notification service resolves the subscribers from the author and series
+// IDs on the event.
+func notifyChapterPosted(ctx context.Context, tx *sql.Tx, workID uuid.UUID, chapterNumber int, actorID uuid.UUID) error {
+	var title, rating, status string
+	var wordCount int
+	var isComplete bool
+	var fandoms, freeforms []string
+	err := tx.QueryRowContext(ctx, `
+		SELECT title, COALESCE(rating, ''), COALESCE(status, ''), COALESCE(word_count, 0),
+			COALESCE(is_complete, false), COALESCE(fandoms, '{}'), COALESCE(freeform_tags, '{}')
+		FROM works WHERE id = $1`, workID).Scan(
+		&title, &rating, &status, &wordCount, &isComplete, pq.Array(&fandoms), pq.Array(&freeforms))
+	if err != nil {
+		return fmt.Errorf("load work %s for chapter notification: %w", workID, err)
+	}
+	// Nobody can read a draft work, so there's nothing to announce yet
+	if status == "draft" {
+		return nil
+	}
+
+	authorIDs, err := uuidColumn(ctx, tx, `
+		SELECT user_id FROM works WHERE id = $1 AND user_id IS NOT NULL
+		UNION
+		SELECT p.user_id FROM creatorships cr
+		JOIN pseuds p ON cr.pseud_id = p.id
+		WHERE cr.creation_id = $1 AND cr.creation_type = 'Work' AND cr.approved = true`, workID)
+	if err != nil {
+		return fmt.Errorf("load authors of work %s for chapter notification: %w", workID, err)
+	}
+	seriesIDs, err := uuidColumn(ctx, tx, "SELECT series_id FROM series_works WHERE work_id = $1", workID)
+	if err != nil {
+		return fmt.Errorf("load series of work %s for chapter notification: %w", workID, err)
+	}
+
+	event := notifications.EventData{
+		Type:        models.EventWorkUpdated,
+		SourceID:    workID,
+		SourceType:  "work",
+		Title:       title,
+		Description: fmt.Sprintf("Chapter %d has been posted", chapterNumber),
+		ActionURL:   fmt.Sprintf("/works/%s", workID),
+		ActorID:     &actorID,
+		AuthorIDs:   authorIDs,
+		SeriesIDs:   seriesIDs,
+		Tags:        append(fandoms, freeforms...),
+		Rating:      rating,
+		WordCount:   wordCount,
+		IsCompleted: isComplete,
+		ExtraData:   map[string]interface{}{"chapter_number": chapterNumber},
+	}
+
+	return sendNotificationEvent(ctx, tx, event)
+}
+
+// notifyWorkUpdated tells the work's subscribers that it was edited, in
+// the transaction making the edit. Works readers can't find aren't
+// announced.
+func notifyWorkUpdated(ctx context.Context, tx *sql.Tx, workID uuid.UUID, actorID *uuid.UUID) error {
+	var title, status string
+	err := tx.QueryRowContext(ctx, "SELECT title, COALESCE(status, '') FROM works WHERE id = $1", workID).
+		Scan(&title, &status)
+	if err != nil {
+		return fmt.Errorf("load work %s for update notification: %w", workID, err)
+	}
+	if status == "draft" || status == "hidden" || status == "unlisted" {
+		return nil
+	}
+
+	return sendNotificationEvent(ctx, tx, notifications.EventData{
+		Type:        models.EventWorkUpdated,
+		SourceID:    workID,
+		SourceType:  "work",
+		Title:       title,
+		Description: "Work has been updated",
+		ActionURL:   fmt.Sprintf("/works/%s", workID),
+		ActorID:     actorID,
+	})
+}
+
+// notifyWorkPublished tells the authors' subscribers and the followers of
+// the work's tags that a work went up, in the transaction posting it. Tags
+// are resolved to their canonical tags first, so following "Harry Potter"
+// hears about works tagged "HP".
+func notifyWorkPublished(ctx context.Context, tx *sql.Tx, workID uuid.UUID, actorID *uuid.UUID) error {
+	var title, summary, rating string
+	var wordCount int
+	var isComplete, anonymous, unrevealed bool
+	var fandoms, freeforms []string
+	err := tx.QueryRowContext(ctx, `
+		SELECT title, COALESCE(summary, ''), COALESCE(rating, ''), COALESCE(word_count, 0),
+			COALESCE(is_complete, false), COALESCE(is_anonymous, false) OR COALESCE(in_anon_collection, false),
+			COALESCE(in_unrevealed_collection, false), COALESCE(fandoms, '{}'), COALESCE(freeform_tags, '{}')
+		FROM works WHERE id = $1`, workID).Scan(
+		&title, &summary, &rating, &wordCount, &isComplete, &anonymous, &unrevealed, pq.Array(&fandoms), pq.Array(&freeforms))
+	if err != nil {
+		return fmt.Errorf("load work %s for publish notification: %w", workID, err)
+	}
+	// An unrevealed work is announced when its collection reveals it
+	if unrevealed {
+		return nil
+	}
+
+	// Subscribers to an anonymous work's authors would learn who wrote it
+	authorIDs := []uuid.UUID{}
+	if !anonymous {
+		authorIDs, err = uuidColumn(ctx, tx, `
+			SELECT user_id FROM works WHERE id = $1 AND user_id IS NOT NULL
+			UNION
+			SELECT p.user_id FROM creatorships cr
+			JOIN pseuds p ON cr.pseud_id = p.id
+			WHERE cr.creation_id = $1 AND cr.creation_type = 'Work' AND cr.approved = true`, workID)
+		if err != nil {
+			return fmt.Errorf("load authors of work %s for publish notification: %w", workID, err)
+		}
+	}
+	tagIDs, err := canonicalWorkTagIDs(ctx, tx, workID)
+	if err != nil {
+		return fmt.Errorf("load tags of work %s for publish notification: %w", workID, err)
+	}
+
+	event := notifications.EventData{
+		Type:        models.EventNewWork,
+		SourceID:    workID,
+		SourceType:  "work",
+		Title:       title,
+		Description: summary,
+		ActionURL:   fmt.Sprintf("/works/%s", workID),
+		ActorID:     actorID,
+		AuthorIDs:   authorIDs,
+		TagIDs:      tagIDs,
+		Tags:        append(fandoms, freeforms...),
+		Rating:      rating,
+		WordCount:   wordCount,
+		IsCompleted: isComplete,
+	}
+
+	return sendNotificationEvent(ctx, tx, event)
+}
+
+// canonicalWorkTagIDs lists the canonical tags a work carries, resolving
+// each synonym to its canonical tag and leaving out unwrangled tags
+func canonicalWorkTagIDs(ctx context.Context, tx *sql.Tx, workID uuid.UUID) ([]uuid.UUID, error) {
+	return uuidColumn(ctx, tx, `
+		SELECT DISTINCT canon.id
+		FROM work_tags wt
+		JOIN tags t ON t.id = wt.tag_id
+		LEFT JOIN tags named ON t.canonical_name IS NOT NULL AND named.name = t.canonical_name
+		LEFT JOIN tag_relationships syn ON syn.child_tag_id = t.id AND syn.relationship_type = 'synonym'
+		JOIN tags canon ON canon.id = COALESCE(named.id, syn.parent_tag_id, t.id)
+		WHERE wt.work_id = $1 AND COALESCE(canon.is_canonical, false)`, workID)
+}
+
+// uuidColumn runs a query returning a single UUID column
+func uuidColumn(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]uuid.UUID, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// USER BLOCKS
+// A block in user_blocks keeps the blocked user away from the blocker: a
+// full or comments block stops them commenting on the blocker's works or
+// replying to the blocker's comments, a full or works block stops them
+// gifting the blocker works, and notification-service drops notifications
+// about anything they do for users blocking them.
+// =============================================================================
+
+// errBlockedCode is the error code answering an action a block forbids
+const errBlockedCode = "BLOCKED"
+
+// BlockedUser is one entry of a user's block list
+type BlockedUser struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	BlockType string    `json:"block_type"`
+	Reason    string    `json:"reason"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// rejectBlockedCommenter answers a comment from a user blocked by one of the
+// work's creators, or by the author of the comment being replied to,
+// returning true if it did
+func (ws *WorkService) rejectBlockedCommenter(c *gin.Context, commenterID, workID, chapterID, parentID *uuid.UUID) bool {
+	if commenterID == nil {
+		return false
+	}
+	var blocked bool
+	err := ws.db.QueryRow(`
+		WITH target AS (
+			SELECT COALESCE($2::uuid, (SELECT work_id FROM chapters WHERE id = $3::uuid)) AS work_id
+		)
+		SELECT EXISTS(
+			SELECT 1 FROM user_blocks b
+			WHERE b.blocked_id = $1 AND b.block_type IN ('full', 'comments')
+				AND (b.blocker_id IN (SELECT w.user_id FROM works w, target t WHERE w.id = t.work_id)
+					OR b.blocker_id IN (
+						SELECT p.user_id FROM creatorships cr
+						JOIN pseuds p ON p.id = cr.pseud_id, target t
+						WHERE cr.creation_id = t.work_id AND cr.creation_type = 'Work' AND cr.approved = true)
+					OR b.blocker_id IN (SELECT user_id FROM comments WHERE id = $4::uuid))
+		)`, *commenterID, workID, chapterID, parentID).Scan(&blocked)
+	if err != nil || !blocked {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "You can't comment here because the creator has blocked you",
+		"code":  errBlockedCode,
+	})
+	return true
+}
+
+// rejectBlockedGift answers a gift to a pseud whose user blocks the gifter,
+// returning true if it did
+func (ws *WorkService) rejectBlockedGift(c *gin.Context, gifterID uuid.UUID, pseudID *uuid.UUID) bool {
+	if pseudID == nil {
+		return false
+	}
+	var blocked bool
+	err := ws.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM user_blocks b
+			JOIN pseuds p ON p.user_id = b.blocker_id
+			WHERE p.id = $1 AND b.blocked_id = $2 AND b.block_type IN ('full', 'works')
+		)`, *pseudID, gifterID).Scan(&blocked)
+	if err != nil || !blocked {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "You can't gift works to this user",
+		"code":  errBlockedCode,
+	})
+	return true
+}
+
+// GetBlockedUsers lists the users the caller blocks, newest first:
+// GET /api/v1/my/blocked-users?page=1&limit=20
+func (ws *WorkService) GetBlockedUsers(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var total int
+	if err := ws.db.QueryRow("SELECT COUNT(*) FROM user_blocks WHERE blocker_id = $1", userID).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count blocked users"})
+		return
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT b.blocked_id, u.username, b.block_type, COALESCE(b.reason, ''), b.created_at
+		FROM user_blocks b JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC, b.blocked_id
+		LIMIT $2 OFFSET $3`, userID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocked users"})
+		return
+	}
+	defer rows.Close()
+
+	blocked := []BlockedUser{}
+	for rows.Next() {
+		var user BlockedUser
+		if err := rows.Scan(&user.UserID, &user.Username, &user.BlockType, &user.Reason, &user.BlockedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read blocked users"})
+			return
+		}
+		blocked = append(blocked, user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"blocked_users": blocked,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + limit - 1) / limit,
+		},
+	})
+}
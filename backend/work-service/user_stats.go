@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"nuclear-ao3/shared/statsstream"
+)
+
+// =============================================================================
+// USER DASHBOARD STATISTICS
+// GetMyStats reads per-user rollups. A rollup is built on the first visit and
+// then refreshed by the work scheduler while the user keeps coming back.
+// =============================================================================
+
+const (
+	// userStatsTrendDays is the length of the dashboard's trend line
+	userStatsTrendDays = 30
+	// userStatsRefreshBatch caps how many rollups one scheduler pass rebuilds
+	userStatsRefreshBatch = 50
+	// userStatsActiveWindow stops refreshing rollups nobody is looking at
+	userStatsActiveWindow = 30 * 24 * time.Hour
+	// userStatsTopFandoms caps the fandom breakdown on the dashboard
+	userStatsTopFandoms = 25
+)
+
+// userStatsStaleAfter is how old a rollup may get before the scheduler
+// rebuilds it (USER_STATS_REFRESH_INTERVAL, default 15m)
+func userStatsStaleAfter() time.Duration {
+	interval, err := time.ParseDuration(getEnv("USER_STATS_REFRESH_INTERVAL", "15m"))
+	if err != nil || interval <= 0 {
+		return 15 * time.Minute
+	}
+	return interval
+}
+
+// userWorksCTE selects the works a user ($1) is an approved creator of
+const userWorksCTE = `
+	WITH user_works AS (
+		SELECT DISTINCT w.id FROM works w
+		JOIN creatorships cr ON w.id = cr.creation_id AND cr.creation_type = 'Work'
+		JOIN pseuds p ON cr.pseud_id = p.id
+		WHERE p.user_id = $1 AND cr.approved = true
+	)`
+
+// FandomStats is one fandom's share of a user's posted works
+type FandomStats struct {
+	Fandom    string `json:"fandom"`
+	Works     int    `json:"works"`
+	WordCount int64  `json:"word_count"`
+}
+
+// DailyStats is one day of the dashboard trend line
+type DailyStats struct {
+	Date      string `json:"date"`
+	Hits      int    `json:"hits"`
+	Kudos     int    `json:"kudos"`
+	Comments  int    `json:"comments"`
+	Bookmarks int    `json:"bookmarks"`
+}
+
+// SubscriberStats counts active subscriptions to a user and their content
+type SubscriberStats struct {
+	Works  int `json:"works"`
+	Author int `json:"author"`
+	Series int `json:"series"`
+}
+
+// TopWorkStats is one of a user's best performing works
+type TopWorkStats struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	Hits      int       `json:"hits"`
+	Kudos     int       `json:"kudos"`
+	Comments  int       `json:"comments"`
+	Bookmarks int       `json:"bookmarks"`
+}
+
+// UserDashboardStats is the response body of GET /my/stats
+type UserDashboardStats struct {
+	UserID uuid.UUID `json:"user_id"`
+
+	// Work counts
+	TotalWorks     int `json:"total_works"`
+	PublishedWorks int `json:"published_works"`
+	DraftWorks     int `json:"draft_works"`
+	CompleteWorks  int `json:"complete_works"`
+
+	// Content statistics
+	TotalWordCount     int64 `json:"total_word_count"`
+	PublishedWordCount int64 `json:"published_word_count"`
+	TotalChapters      int   `json:"total_chapters"`
+	AverageWordCount   int64 `json:"average_word_count"`
+
+	// Engagement statistics
+	TotalHits          int64           `json:"total_hits"`
+	TotalKudos         int             `json:"total_kudos"`
+	TotalComments      int             `json:"total_comments"`
+	TotalBookmarks     int             `json:"total_bookmarks"`
+	TotalSubscriptions int             `json:"total_subscriptions"`
+	Subscribers        SubscriberStats `json:"subscribers"`
+
+	// Series and collection statistics
+	TotalSeries      int `json:"total_series"`
+	TotalCollections int `json:"total_collections"`
+
+	// Activity metrics
+	FirstPublished *time.Time `json:"first_published"`
+	LastPublished  *time.Time `json:"last_published"`
+	LastUpdated    *time.Time `json:"last_updated"`
+
+	WorksByFandom []FandomStats  `json:"works_by_fandom"`
+	Trend         []DailyStats   `json:"trend"`
+	TopWorks      []TopWorkStats `json:"top_works"`
+	RefreshedAt   time.Time      `json:"refreshed_at"`
+
+	// Where readers came from over the trend window
+	Referrers map[string]int            `json:"referrers"`
+	Countries []statsstream.CountryHits `json:"countries"`
+}
+
+// refreshUserStats rebuilds one user's rollups in a single transaction
+func (ws *WorkService) refreshUserStats(ctx context.Context, userID uuid.UUID) error {
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, userWorksCTE+`
+		INSERT INTO user_stats_rollups (
+			user_id, total_works, published_works, draft_works, complete_works,
+			total_word_count, published_word_count, total_chapters,
+			total_hits, total_kudos, total_comments, total_bookmarks,
+			work_subscribers, author_subscribers, series_subscribers,
+			total_series, total_collections,
+			first_published, last_published, last_updated, refreshed_at
+		)
+		SELECT $1,
+			COUNT(w.id),
+			COUNT(w.id) FILTER (WHERE w.status = 'posted'),
+			COUNT(w.id) FILTER (WHERE w.status = 'draft'),
+			COUNT(w.id) FILTER (WHERE w.is_complete = true),
+			COALESCE(SUM(w.word_count), 0),
+			COALESCE(SUM(w.word_count) FILTER (WHERE w.status = 'posted'), 0),
+			COALESCE(SUM(w.chapter_count), 0),
+			COALESCE(SUM(w.hit_count), 0),
+			COALESCE(SUM(w.kudos_count), 0),
+			COALESCE(SUM(w.comment_count), 0),
+			COALESCE(SUM(w.bookmark_count), 0),
+			(SELECT COUNT(*) FROM subscriptions s
+				WHERE s.type = 'work' AND s.is_active = true AND s.target_id IN (SELECT id FROM user_works)),
+			(SELECT COUNT(*) FROM subscriptions s
+				WHERE s.type IN ('author', 'user') AND s.is_active = true AND s.target_id = $1),
+			(SELECT COUNT(*) FROM subscriptions s JOIN series se ON se.id = s.target_id
+				WHERE s.type = 'series' AND s.is_active = true AND se.user_id = $1),
+			(SELECT COUNT(*) FROM series WHERE user_id = $1),
+			(SELECT COUNT(*) FROM collections WHERE user_id = $1),
+			MIN(w.published_at) FILTER (WHERE w.status = 'posted'),
+			MAX(w.published_at) FILTER (WHERE w.status = 'posted'),
+			MAX(w.updated_at) FILTER (WHERE w.status = 'posted'),
+			NOW()
+		FROM works w
+		WHERE w.id IN (SELECT id FROM user_works)
+		ON CONFLICT (user_id) DO UPDATE SET
+			total_works = EXCLUDED.total_works,
+			published_works = EXCLUDED.published_works,
+			draft_works = EXCLUDED.draft_works,
+			complete_works = EXCLUDED.complete_works,
+			total_word_count = EXCLUDED.total_word_count,
+			published_word_count = EXCLUDED.published_word_count,
+			total_chapters = EXCLUDED.total_chapters,
+			total_hits = EXCLUDED.total_hits,
+			total_kudos = EXCLUDED.total_kudos,
+			total_comments = EXCLUDED.total_comments,
+			total_bookmarks = EXCLUDED.total_bookmarks,
+			work_subscribers = EXCLUDED.work_subscribers,
+			author_subscribers = EXCLUDED.author_subscribers,
+			series_subscribers = EXCLUDED.series_subscribers,
+			total_series = EXCLUDED.total_series,
+			total_collections = EXCLUDED.total_collections,
+			first_published = EXCLUDED.first_published,
+			last_published = EXCLUDED.last_published,
+			last_updated = EXCLUDED.last_updated,
+			refreshed_at = EXCLUDED.refreshed_at`, userID)
+	if err != nil {
+		return fmt.Errorf("refresh totals: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_fandom_rollups WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("clear fandom rollups: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, userWorksCTE+`
+		INSERT INTO user_fandom_rollups (user_id, fandom, works, word_count)
+		SELECT $1, f.fandom, COUNT(DISTINCT w.id), COALESCE(SUM(w.word_count), 0)
+		FROM works w
+		CROSS JOIN LATERAL unnest(w.fandoms) AS f(fandom)
+		WHERE w.id IN (SELECT id FROM user_works) AND w.status = 'posted'
+		GROUP BY f.fandom`, userID)
+	if err != nil {
+		return fmt.Errorf("refresh fandom rollups: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_daily_rollups WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("clear daily rollups: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, userWorksCTE+`,
+	days AS (
+		SELECT generate_series(CURRENT_DATE - ($2::int - 1), CURRENT_DATE, INTERVAL '1 day')::date AS day
+	)
+		INSERT INTO user_daily_rollups (user_id, stat_date, hits, kudos, comments, bookmarks)
+		SELECT $1, d.day, COALESCE(h.n, 0), COALESCE(k.n, 0), COALESCE(cm.n, 0), COALESCE(b.n, 0)
+		FROM days d
+		LEFT JOIN (
+			SELECT hit_date AS day, SUM(hits) AS n FROM work_daily_hits
+			WHERE work_id IN (SELECT id FROM user_works) AND hit_date > CURRENT_DATE - $2::int
+			GROUP BY hit_date
+		) h ON h.day = d.day
+		LEFT JOIN (
+			SELECT created_at::date AS day, COUNT(*) AS n FROM kudos
+			WHERE work_id IN (SELECT id FROM user_works) AND created_at > CURRENT_DATE - $2::int
+			GROUP BY 1
+		) k ON k.day = d.day
+		LEFT JOIN (
+			SELECT created_at::date AS day, COUNT(*) AS n FROM comments
+			WHERE (work_id IN (SELECT id FROM user_works)
+				OR chapter_id IN (SELECT id FROM chapters WHERE work_id IN (SELECT id FROM user_works)))
+			AND is_deleted = false AND created_at > CURRENT_DATE - $2::int
+			GROUP BY 1
+		) cm ON cm.day = d.day
+		LEFT JOIN (
+			SELECT created_at::date AS day, COUNT(*) AS n FROM bookmarks
+			WHERE work_id IN (SELECT id FROM user_works) AND created_at > CURRENT_DATE - $2::int
+			GROUP BY 1
+		) b ON b.day = d.day`, userID, userStatsTrendDays)
+	if err != nil {
+		return fmt.Errorf("refresh daily rollups: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_hit_source_rollups WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("clear hit source rollups: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, userWorksCTE+`,
+	recent AS (
+		SELECT referrer_class, country, hits FROM work_source_daily_hits
+		WHERE work_id IN (SELECT id FROM user_works) AND hit_date > CURRENT_DATE - $2::int
+	)
+		INSERT INTO user_hit_source_rollups (user_id, dimension, source, hits)
+		SELECT $1, 'referrer', referrer_class, SUM(hits) FROM recent GROUP BY referrer_class
+		UNION ALL
+		SELECT $1, 'country', country, SUM(hits) FROM recent GROUP BY country`, userID, userStatsTrendDays)
+	if err != nil {
+		return fmt.Errorf("refresh hit source rollups: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// refreshStaleUserStats rebuilds the stalest rollups of users who have
+// visited their dashboard recently
+func (ws *WorkService) refreshStaleUserStats(ctx context.Context) (int, error) {
+	now := time.Now()
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT user_id FROM user_stats_rollups
+		WHERE refreshed_at < $1 AND last_viewed_at > $2
+		ORDER BY refreshed_at
+		LIMIT $3`, now.Add(-userStatsStaleAfter()), now.Add(-userStatsActiveWindow), userStatsRefreshBatch)
+	if err != nil {
+		return 0, err
+	}
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	refreshed := 0
+	for _, id := range userIDs {
+		if err := ws.refreshUserStats(ctx, id); err != nil {
+			log.Printf("Failed to refresh dashboard stats for user %s: %v", id, err)
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// loadUserStats reads a user's rollups from db, returning sql.ErrNoRows if
+// none have been built yet
+func (ws *WorkService) loadUserStats(ctx context.Context, db *sql.DB, userID uuid.UUID) (*UserDashboardStats, error) {
+	stats := &UserDashboardStats{UserID: userID}
+	var firstPublished, lastPublished, lastUpdated sql.NullTime
+	err := db.QueryRowContext(ctx, `
+		SELECT total_works, published_works, draft_works, complete_works,
+			total_word_count, published_word_count, total_chapters,
+			total_hits, total_kudos, total_comments, total_bookmarks,
+			work_subscribers, author_subscribers, series_subscribers,
+			total_series, total_collections,
+			first_published, last_published, last_updated, refreshed_at
+		FROM user_stats_rollups WHERE user_id = $1`, userID).Scan(
+		&stats.TotalWorks, &stats.PublishedWorks, &stats.DraftWorks, &stats.CompleteWorks,
+		&stats.TotalWordCount, &stats.PublishedWordCount, &stats.TotalChapters,
+		&stats.TotalHits, &stats.TotalKudos, &stats.TotalComments, &stats.TotalBookmarks,
+		&stats.Subscribers.Works, &stats.Subscribers.Author, &stats.Subscribers.Series,
+		&stats.TotalSeries, &stats.TotalCollections,
+		&firstPublished, &lastPublished, &lastUpdated, &stats.RefreshedAt)
+	if err != nil {
+		return nil, err
+	}
+	if firstPublished.Valid {
+		stats.FirstPublished = &firstPublished.Time
+	}
+	if lastPublished.Valid {
+		stats.LastPublished = &lastPublished.Time
+	}
+	if lastUpdated.Valid {
+		stats.LastUpdated = &lastUpdated.Time
+	}
+	if stats.TotalWorks > 0 {
+		stats.AverageWordCount = stats.TotalWordCount / int64(stats.TotalWorks)
+	}
+	stats.TotalSubscriptions = stats.Subscribers.Works + stats.Subscribers.Author + stats.Subscribers.Series
+
+	stats.WorksByFandom = []FandomStats{}
+	rows, err := db.QueryContext(ctx, `
+		SELECT fandom, works, word_count FROM user_fandom_rollups
+		WHERE user_id = $1
+		ORDER BY works DESC, word_count DESC, fandom
+		LIMIT $2`, userID, userStatsTopFandoms)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var f FandomStats
+		if err := rows.Scan(&f.Fandom, &f.Works, &f.WordCount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.WorksByFandom = append(stats.WorksByFandom, f)
+	}
+	rows.Close()
+
+	stats.Trend = []DailyStats{}
+	rows, err = db.QueryContext(ctx, `
+		SELECT TO_CHAR(stat_date, 'YYYY-MM-DD'), hits, kudos, comments, bookmarks
+		FROM user_daily_rollups
+		WHERE user_id = $1
+		ORDER BY stat_date`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var d DailyStats
+		if err := rows.Scan(&d.Date, &d.Hits, &d.Kudos, &d.Comments, &d.Bookmarks); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.Trend = append(stats.Trend, d)
+	}
+	rows.Close()
+
+	stats.Referrers = statsstream.NewReferrerCounts()
+	countries := map[string]int{}
+	rows, err = db.QueryContext(ctx, `
+		SELECT dimension, source, hits FROM user_hit_source_rollups
+		WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var dimension, source string
+		var hits int
+		if err := rows.Scan(&dimension, &source, &hits); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if dimension == "country" {
+			countries[source] += hits
+		} else {
+			stats.Referrers[source] += hits
+		}
+	}
+	rows.Close()
+	stats.Countries = statsstream.ReportableCountries(countries)
+
+	return stats, nil
+}
+
+// GetMyStats returns the current user's dashboard statistics from their
+// rollups, building them on the first visit
+func (ws *WorkService) GetMyStats(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	// Rollups lag their works anyway, so they can come from the replica;
+	// ones built just now are read back from the primary
+	stats, err := ws.loadUserStats(ctx, ws.readDB(), userUUID)
+	if err == sql.ErrNoRows {
+		if err := ws.refreshUserStats(ctx, userUUID); err != nil {
+			log.Printf("Failed to build dashboard stats for user %s: %v", userUUID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work statistics"})
+			return
+		}
+		stats, err = ws.loadUserStats(ctx, ws.db, userUUID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work statistics"})
+		return
+	}
+
+	// Keeps the rollup on the refresh schedule; written at most hourly
+	_, err = ws.db.ExecContext(ctx, `
+		UPDATE user_stats_rollups SET last_viewed_at = NOW()
+		WHERE user_id = $1 AND last_viewed_at < NOW() - INTERVAL '1 hour'`, userUUID)
+	if err != nil {
+		log.Printf("Failed to mark dashboard stats viewed for user %s: %v", userUUID, err)
+	}
+
+	// Top works are read from the works' own counters, which stay current
+	// between refreshes
+	stats.TopWorks = []TopWorkStats{}
+	rows, err := ws.db.QueryContext(ctx, userWorksCTE+`
+		SELECT w.id, w.title,
+			COALESCE(w.hit_count, 0), COALESCE(w.kudos_count, 0),
+			COALESCE(w.comment_count, 0), COALESCE(w.bookmark_count, 0)
+		FROM works w
+		WHERE w.id IN (SELECT id FROM user_works) AND w.status = 'posted'
+		ORDER BY (COALESCE(w.hit_count, 0) + COALESCE(w.kudos_count, 0) +
+		         COALESCE(w.comment_count, 0) + COALESCE(w.bookmark_count, 0)) DESC
+		LIMIT 5`, userUUID)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var w TopWorkStats
+			if err := rows.Scan(&w.ID, &w.Title, &w.Hits, &w.Kudos, &w.Comments, &w.Bookmarks); err == nil {
+				stats.TopWorks = append(stats.TopWorks, w)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
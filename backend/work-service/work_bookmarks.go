@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+)
+
+// GetWorkBookmarks lists a work's public bookmarks with their notes and tags,
+// newest first. Private bookmarks are never listed or counted, and the work
+// must be one the viewer can see.
+func (ws *WorkService) GetWorkBookmarks(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	visibleQuery := "SELECT EXISTS(SELECT 1 FROM works w WHERE w.id = $1"
+	args := []interface{}{workID}
+	if userID, exists := c.Get("user_id"); exists {
+		visibleQuery += " AND can_user_view_work(w.id, $2)"
+		args = append(args, userID)
+	} else {
+		visibleQuery += " AND w.restricted = false AND w.status = 'posted'" + ws.loginGatedRatingsSQL(ctx)
+	}
+	var visible bool
+	if err := ws.db.QueryRowContext(ctx, visibleQuery+")", args...).Scan(&visible); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work"})
+		return
+	}
+	if !visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	}
+
+	page, limit := sharePageParams(c)
+
+	var total int
+	if err := ws.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM bookmarks
+		WHERE work_id = $1 AND NOT COALESCE(is_private, false)`, workID).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmarks"})
+		return
+	}
+
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT b.id, b.user_id, u.username, COALESCE(b.notes, ''), COALESCE(b.tags, '{}'),
+			COALESCE(b.is_rec, false), b.created_at, b.updated_at
+		FROM bookmarks b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.work_id = $1 AND NOT COALESCE(b.is_private, false)
+		ORDER BY b.created_at DESC
+		LIMIT $2 OFFSET $3`, workID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmarks"})
+		return
+	}
+	defer rows.Close()
+
+	bookmarks := []models.PublicBookmark{}
+	for rows.Next() {
+		var b models.PublicBookmark
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Username, &b.Notes, pq.Array(&b.Tags),
+			&b.IsRec, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmarks"})
+			return
+		}
+		bookmarks = append(bookmarks, b)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bookmarks": bookmarks,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + limit - 1) / limit,
+		},
+	})
+}
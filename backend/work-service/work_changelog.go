@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+)
+
+// =============================================================================
+// WORK METADATA CHANGELOG
+// Edits to a work's title, summary, notes, rating and tags are recorded field
+// by field, in the same transaction as the edit, so co-authors can see who
+// changed what. Only the work's approved creators can read the changelog.
+// =============================================================================
+
+// workMetadata is the part of a work the changelog tracks
+type workMetadata struct {
+	Title         string
+	Summary       string
+	Notes         string
+	Rating        string
+	Category      []string
+	Warnings      []string
+	Fandoms       []string
+	Characters    []string
+	Relationships []string
+	FreeformTags  []string
+}
+
+// metadataChange is one changed field waiting to be recorded
+type metadataChange struct {
+	field    string
+	oldValue interface{}
+	newValue interface{}
+}
+
+// loadWorkMetadataForUpdate reads a work's tracked fields and locks the row
+// until tx ends, so the recorded old values are the ones the edit replaced.
+// A trashed work can't be edited and reads as sql.ErrNoRows.
+func loadWorkMetadataForUpdate(ctx context.Context, tx *sql.Tx, workID uuid.UUID) (workMetadata, error) {
+	var m workMetadata
+	err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(title, ''), COALESCE(summary, ''), COALESCE(notes, ''), COALESCE(rating, ''),
+			COALESCE(category, '{}'), COALESCE(warnings, '{}'), COALESCE(fandoms, '{}'),
+			COALESCE(characters, '{}'), COALESCE(relationships, '{}'), COALESCE(freeform_tags, '{}')
+		FROM works WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, workID).Scan(
+		&m.Title, &m.Summary, &m.Notes, &m.Rating,
+		pq.Array(&m.Category), pq.Array(&m.Warnings), pq.Array(&m.Fandoms),
+		pq.Array(&m.Characters), pq.Array(&m.Relationships), pq.Array(&m.FreeformTags))
+	return m, err
+}
+
+// diffWorkMetadata lists the tracked fields an update request changes. Tag
+// fields count as changed only when tags were added or removed, not when
+// the same tags were sent in another order.
+func diffWorkMetadata(before workMetadata, req models.UpdateWorkRequest) []metadataChange {
+	var changes []metadataChange
+	text := func(field, old string, updated *string) {
+		if updated != nil && *updated != old {
+			changes = append(changes, metadataChange{field, old, *updated})
+		}
+	}
+	tags := func(field string, old, updated []string) {
+		if updated != nil && !sameTags(old, updated) {
+			if old == nil {
+				old = []string{}
+			}
+			changes = append(changes, metadataChange{field, old, updated})
+		}
+	}
+
+	text("title", before.Title, req.Title)
+	text("summary", before.Summary, req.Summary)
+	text("notes", before.Notes, req.Notes)
+	text("rating", before.Rating, req.Rating)
+	tags("category", before.Category, req.Category)
+	tags("warnings", before.Warnings, req.Warnings)
+	tags("fandoms", before.Fandoms, req.Fandoms)
+	tags("characters", before.Characters, req.Characters)
+	tags("relationships", before.Relationships, req.Relationships)
+	tags("freeform_tags", before.FreeformTags, req.FreeformTags)
+	return changes
+}
+
+// sameTags reports whether two tag lists hold the same tags, ignoring order
+// and repeats
+func sameTags(a, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, tag := range a {
+		set[tag] = true
+	}
+	seen := make(map[string]bool, len(b))
+	for _, tag := range b {
+		if !set[tag] {
+			return false
+		}
+		seen[tag] = true
+	}
+	return len(seen) == len(set)
+}
+
+// recordWorkMetadataChanges saves the changes of one edit as a change set
+func recordWorkMetadataChanges(ctx context.Context, tx *sql.Tx, workID uuid.UUID, actorID *uuid.UUID, changes []metadataChange, at time.Time) error {
+	changeSetID := uuid.New()
+	for _, change := range changes {
+		oldValue, err := json.Marshal(change.oldValue)
+		if err != nil {
+			return err
+		}
+		newValue, err := json.Marshal(change.newValue)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO work_metadata_changes
+				(id, work_id, change_set_id, actor_id, field, old_value, new_value, changed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			uuid.New(), workID, changeSetID, actorID, change.field, oldValue, newValue, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetWorkChangelog lists a work's metadata changes, newest first, to its
+// approved creators
+func (ws *WorkService) GetWorkChangelog(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	isCreator, err := ws.isWorkCreator(ctx, workID, userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ownership"})
+		return
+	}
+	if !isCreator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the work's creators can see its changelog"})
+		return
+	}
+
+	page, limit := sharePageParams(c)
+	rows, err := ws.db.QueryContext(ctx, `
+		SELECT ch.id, ch.change_set_id, ch.actor_id, COALESCE(u.username, ''),
+			ch.field, ch.old_value, ch.new_value, ch.changed_at
+		FROM work_metadata_changes ch
+		LEFT JOIN users u ON u.id = ch.actor_id
+		WHERE ch.work_id = $1
+		ORDER BY ch.changed_at DESC, ch.field
+		LIMIT $2 OFFSET $3`, workID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch changelog"})
+		return
+	}
+	defer rows.Close()
+
+	changes := []models.WorkMetadataChange{}
+	for rows.Next() {
+		change := models.WorkMetadataChange{WorkID: workID}
+		var oldValue, newValue []byte
+		if err := rows.Scan(&change.ID, &change.ChangeSetID, &change.ActorID, &change.ActorName,
+			&change.Field, &oldValue, &newValue, &change.ChangedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch changelog"})
+			return
+		}
+		change.OldValue, change.NewValue = oldValue, newValue
+		changes = append(changes, change)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"changes": changes, "page": page, "limit": limit})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestSameTags(t *testing.T) {
+	assert.True(t, sameTags([]string{"Fluff", "Angst"}, []string{"Angst", "Fluff"}))
+	assert.True(t, sameTags(nil, []string{}))
+	assert.True(t, sameTags([]string{"Fluff"}, []string{"Fluff", "Fluff"}))
+	assert.False(t, sameTags([]string{"Fluff", "Angst"}, []string{"Fluff"}))
+	assert.False(t, sameTags([]string{"Fluff"}, []string{"Fluff", "Angst"}))
+}
+
+func TestDiffWorkMetadata(t *testing.T) {
+	before := workMetadata{
+		Title:        "Old Title",
+		Summary:      "A summary",
+		Rating:       "teen",
+		Fandoms:      []string{"Good Omens"},
+		FreeformTags: []string{"Fluff", "Angst"},
+	}
+	title, summary := "New Title", "A summary"
+
+	changes := diffWorkMetadata(before, models.UpdateWorkRequest{
+		Title:        &title,
+		Summary:      &summary,
+		FreeformTags: []string{"Angst", "Fluff"},
+		Characters:   []string{"Crowley"},
+	})
+
+	assert.Equal(t, []metadataChange{
+		{"title", "Old Title", "New Title"},
+		{"characters", []string{}, []string{"Crowley"}},
+	}, changes, "unchanged values and reordered tags are not changes")
+
+	assert.Empty(t, diffWorkMetadata(before, models.UpdateWorkRequest{}))
+}
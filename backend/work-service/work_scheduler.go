@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"nuclear-ao3/shared/models"
+	"nuclear-ao3/shared/notifications"
+)
+
+// scheduledWorkBatchSize caps how many works one scheduler pass touches so
+// a backlog drains over several ticks instead of one long transaction.
+const scheduledWorkBatchSize = 100
+
+// scheduledJob is one kind of time-based change to works. Each job claims
+// its due works itself and reports how many it handled.
+type scheduledJob struct {
+	name string
+	run  func(ctx context.Context) (int, error)
+}
+
+// validateUnpublishAt checks a requested unpublish time against now.
+func validateUnpublishAt(unpublishAt, now time.Time) error {
+	if !unpublishAt.After(now) {
+		return errors.New("unpublish_at must be in the future")
+	}
+	return nil
+}
+
+// scheduledWorkJobs lists the jobs run on every scheduler tick.
+func (ws *WorkService) scheduledWorkJobs() []scheduledJob {
+	return []scheduledJob{
+		{name: "unpublish", run: ws.unpublishDueWorks},
+		{name: "collection reveal", run: ws.revealDueCollections},
+		{name: "dashboard stats refresh", run: ws.refreshStaleUserStats},
+		{name: "search outbox cleanup", run: ws.purgePublishedSearchOutbox},
+		{name: "notification outbox cleanup", run: ws.purgePublishedNotificationOutbox},
+		{name: "trash purge", run: ws.purgeExpiredTrash},
+	}
+}
+
+// startWorkScheduler runs the scheduled work jobs every interval until ctx
+// is cancelled.
+func (ws *WorkService) startWorkScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Work scheduler started, running every %s", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Work scheduler stopped")
+			return
+		case <-ticker.C:
+			ws.runScheduledWorkJobs(ctx)
+		}
+	}
+}
+
+// runScheduledWorkJobs runs each job once, logging failures so one broken
+// job doesn't hold up the others.
+func (ws *WorkService) runScheduledWorkJobs(ctx context.Context) {
+	for _, job := range ws.scheduledWorkJobs() {
+		count, err := job.run(ctx)
+		if err != nil {
+			log.Printf("Scheduled %s job failed: %v", job.name, err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("Scheduled %s job processed %d works", job.name, count)
+		}
+	}
+}
+
+// unpublishDueWorks returns works whose unpublish_at has passed to draft,
+// then refreshes caches and search and tells their authors.
+func (ws *WorkService) unpublishDueWorks(ctx context.Context) (int, error) {
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, title FROM works
+		WHERE unpublish_at IS NOT NULL AND unpublish_at <= NOW()
+		ORDER BY unpublish_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, scheduledWorkBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	titles := make(map[uuid.UUID]string)
+	ids := []string{}
+	for rows.Next() {
+		var id uuid.UUID
+		var title string
+		if err := rows.Scan(&id, &title); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		titles[id] = title
+		ids = append(ids, id.String())
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE works
+		SET status = 'draft', is_draft = true, unpublish_at = NULL,
+			unpublished_at = NOW(), updated_at = NOW(), version = version + 1
+		WHERE id = ANY($1::uuid[])`, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+
+	creators, err := workCreatorIDs(ctx, tx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	for workID, title := range titles {
+		err := sendNotificationEvent(ctx, tx, notifications.EventData{
+			Type:         models.EventWorkUnpublished,
+			SourceID:     workID,
+			SourceType:   "work",
+			Title:        "Your work has been unpublished",
+			Description:  fmt.Sprintf("%s reached its scheduled unpublish time and is now a draft", title),
+			ActionURL:    fmt.Sprintf("/works/%s", workID),
+			RecipientIDs: creators[workID],
+			ExtraData:    map[string]interface{}{"work_title": title},
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for workID := range titles {
+		if ws.redis != nil {
+			ws.redis.Del(ctx, fmt.Sprintf("work:%s", workID))
+		}
+		ws.InvalidateWorkCache(workID)
+		for _, userID := range creators[workID] {
+			ws.InvalidateUserCache(userID)
+		}
+	}
+
+	return len(ids), nil
+}
+
+// workCreatorIDs returns the approved creators of each of the given works.
+func workCreatorIDs(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}, workIDs []string) (map[uuid.UUID][]uuid.UUID, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT DISTINCT c.creation_id, p.user_id
+		FROM creatorships c
+		JOIN pseuds p ON c.pseud_id = p.id
+		WHERE c.creation_id = ANY($1::uuid[]) AND c.creation_type = 'Work' AND c.approved = true`,
+		pq.Array(workIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	creators := make(map[uuid.UUID][]uuid.UUID)
+	for rows.Next() {
+		var workID, userID uuid.UUID
+		if err := rows.Scan(&workID, &userID); err != nil {
+			return nil, err
+		}
+		creators[workID] = append(creators[workID], userID)
+	}
+	return creators, rows.Err()
+}
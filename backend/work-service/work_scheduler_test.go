@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUnpublishAt(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, validateUnpublishAt(now.Add(time.Hour), now))
+	assert.Error(t, validateUnpublishAt(now, now), "unpublish time must be strictly in the future")
+	assert.Error(t, validateUnpublishAt(now.Add(-time.Minute), now))
+}
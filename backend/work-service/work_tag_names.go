@@ -0,0 +1,34 @@
+package main
+
+import "fmt"
+
+// workTagNamesColumns are the tag name columns withWorkTagNames adds to a
+// work listing: fandoms, characters, relationships and freeform tags
+const workTagNamesColumns = `
+	COALESCE(tag_names.fandoms, '{}'), COALESCE(tag_names.characters, '{}'),
+	COALESCE(tag_names.relationships, '{}'), COALESCE(tag_names.freeform_tags, '{}')`
+
+// workTagNamesJoin aggregates a page's work_tags into one row of name
+// arrays per work. Warnings live on the work itself.
+const workTagNamesJoin = `
+	LEFT JOIN LATERAL (
+		SELECT
+			array_agg(t.name ORDER BY t.name) FILTER (WHERE t.type = 'fandom') AS fandoms,
+			array_agg(t.name ORDER BY t.name) FILTER (WHERE t.type = 'character') AS characters,
+			array_agg(t.name ORDER BY t.name) FILTER (WHERE t.type = 'relationship') AS relationships,
+			array_agg(t.name ORDER BY t.name) FILTER (WHERE t.type IN ('freeform', 'additional')) AS freeform_tags
+		FROM work_tags wt
+		JOIN tags t ON t.id = wt.tag_id
+		WHERE wt.work_id = page.id
+	) tag_names ON true`
+
+// withWorkTagNames wraps a page query so each work comes back with its tag
+// names in the same round trip. The page query selects the work ID as id
+// and its position in the page as position; tags are only aggregated for
+// the works on the page, which keeps their order.
+func withWorkTagNames(pageQuery string) string {
+	return fmt.Sprintf(`
+	SELECT page.*,%s
+	FROM (%s) page%s
+	ORDER BY page.position`, workTagNamesColumns, pageQuery, workTagNamesJoin)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// listingDriver is a database/sql driver answering SearchWorks' queries
+// from memory and counting them, so a listing's round trips can be checked
+// without Postgres
+type listingDriver struct {
+	mu       sync.Mutex
+	works    int
+	queries  []string
+	prepares int
+}
+
+func (d *listingDriver) Open(string) (driver.Conn, error) { return listingConn{d}, nil }
+
+func (d *listingDriver) queryCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.queries)
+}
+
+type listingConn struct{ d *listingDriver }
+
+func (c listingConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	c.d.prepares++
+	c.d.mu.Unlock()
+	return listingStmt{c.d, query}, nil
+}
+func (c listingConn) Close() error { return nil }
+func (c listingConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions aren't supported")
+}
+
+type listingStmt struct {
+	d     *listingDriver
+	query string
+}
+
+func (s listingStmt) Close() error  { return nil }
+func (s listingStmt) NumInput() int { return -1 }
+func (s listingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("exec isn't supported")
+}
+
+func (s listingStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	s.d.queries = append(s.d.queries, s.query)
+	works := s.d.works
+	s.d.mu.Unlock()
+
+	switch {
+	case strings.Contains(s.query, "tag_names"):
+		rows := &listingRows{columns: 26}
+		now := time.Now()
+		for i := 0; i < works; i++ {
+			rows.values = append(rows.values, []driver.Value{
+				uuid.New().String(), fmt.Sprintf("Work %d", i), "A summary", uuid.New().String(), "author",
+				"en", "General Audiences", "Gen", "No Archive Warnings Apply",
+				int64(1000), int64(1), nil, true, "complete", now, now, now,
+				int64(10), int64(2), int64(1), int64(0),
+				int64(i + 1), []byte(`{"Good Omens"}`), []byte(`{Aziraphale,Crowley}`),
+				[]byte(`{Aziraphale/Crowley}`), []byte(`{Fluff,"Slow Burn"}`),
+			})
+		}
+		return rows, nil
+	case strings.Contains(s.query, "COUNT(*)"):
+		return &listingRows{columns: 1, values: [][]driver.Value{{int64(works)}}}, nil
+	}
+	return &listingRows{columns: 3}, nil
+}
+
+type listingRows struct {
+	columns int
+	values  [][]driver.Value
+}
+
+func (r *listingRows) Columns() []string { return make([]string, r.columns) }
+func (r *listingRows) Close() error      { return nil }
+func (r *listingRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// newListingService is a WorkService whose database lists the given number
+// of works
+func newListingService(t testing.TB, works int) (*WorkService, *listingDriver) {
+	d := &listingDriver{works: works}
+	name := fmt.Sprintf("listing-%s", uuid.New())
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &WorkService{db: db}, d
+}
+
+func searchWorksRequest(ws *WorkService) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/works", ws.SearchWorks)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/works?limit=50", nil))
+	return w
+}
+
+func TestSearchWorksLoadsTagsWithoutQueryPerWork(t *testing.T) {
+	small, smallDB := newListingService(t, 1)
+	large, largeDB := newListingService(t, 50)
+
+	assert.Equal(t, http.StatusOK, searchWorksRequest(small).Code)
+	w := searchWorksRequest(large)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, smallDB.queryCount(), largeDB.queryCount(), "a longer page shouldn't cost more queries")
+
+	var body struct {
+		Works []struct {
+			Fandoms       []string `json:"fandoms"`
+			Relationships []string `json:"relationships"`
+			FreeformTags  []string `json:"freeform_tags"`
+		} `json:"works"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	if assert.Len(t, body.Works, 50) {
+		assert.Equal(t, []string{"Good Omens"}, body.Works[0].Fandoms)
+		assert.Equal(t, []string{"Aziraphale/Crowley"}, body.Works[0].Relationships)
+		assert.Equal(t, []string{"Fluff", "Slow Burn"}, body.Works[49].FreeformTags)
+	}
+}
+
+func TestWithWorkTagNamesKeepsPageOrder(t *testing.T) {
+	query := withWorkTagNames("SELECT w.id, 1 AS position FROM works w LIMIT 20")
+	assert.Contains(t, query, "FROM (SELECT w.id, 1 AS position FROM works w LIMIT 20) page")
+	assert.Contains(t, query, "wt.work_id = page.id", "tags are only aggregated for the page")
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(query), "ORDER BY page.position"))
+}
+
+func BenchmarkSearchWorksPage(b *testing.B) {
+	ws, d := newListingService(b, 50)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		searchWorksRequest(ws)
+	}
+	b.ReportMetric(float64(d.queryCount())/float64(b.N), "queries/op")
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Deleting a work moves it to its author's trash. A trashed work has status
+// "deleted", so search drops it and the work page 404s, and every listing
+// skips rows with deleted_at set. The author can restore it from
+// /my/trash until the trash window runs out; the scheduler then purges it
+// with the full cascading delete.
+
+// defaultWorkTrashDays is how long a deleted work can be restored
+const defaultWorkTrashDays = 30
+
+// workTrashRetention is how long a deleted work stays in the trash before
+// it is purged (WORK_TRASH_DAYS, default 30)
+func workTrashRetention() time.Duration {
+	days, err := strconv.Atoi(getEnv("WORK_TRASH_DAYS", strconv.Itoa(defaultWorkTrashDays)))
+	if err != nil || days <= 0 {
+		days = defaultWorkTrashDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// trashPurgeAt is when a work deleted at deletedAt is purged
+func trashPurgeAt(deletedAt time.Time, retention time.Duration) time.Time {
+	return deletedAt.Add(retention)
+}
+
+// TrashedWork is one work in its author's trash
+type TrashedWork struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	WordCount int       `json:"word_count"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// trashUserID reads the signed-in user for the trash endpoints
+func trashUserID(c *gin.Context) (uuid.UUID, bool) {
+	value, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return uuid.Nil, false
+	}
+	userID, err := uuid.Parse(fmt.Sprint(value))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// lockLiveWork locks a work for the rest of tx, failing with sql.ErrNoRows
+// if it doesn't exist or is in the trash. Edits take it first so they can't
+// land on a work that is being trashed.
+func lockLiveWork(ctx context.Context, tx *sql.Tx, workID uuid.UUID) error {
+	var id uuid.UUID
+	return tx.QueryRowContext(ctx, "SELECT id FROM works WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", workID).Scan(&id)
+}
+
+// DeleteWork moves a work to its author's trash
+func (ws *WorkService) DeleteWork(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+
+	userID, ok := trashUserID(c)
+	if !ok {
+		return
+	}
+
+	// Verify ownership using creatorship system
+	var isAuthor bool
+	err = ws.db.QueryRowContext(c.Request.Context(), `
+		SELECT EXISTS(
+			SELECT 1 FROM creatorships c
+			JOIN pseuds p ON c.pseud_id = p.id
+			WHERE c.creation_id = $1 AND c.creation_type = 'Work'
+			AND c.approved = true AND p.user_id = $2
+		)`, workID, userID).Scan(&isAuthor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ownership"})
+		return
+	}
+	if !isAuthor {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to delete this work"})
+		return
+	}
+
+	var deletedAt time.Time
+	err = ws.db.QueryRowContext(c.Request.Context(), `
+		UPDATE works
+		SET status_before_delete = status, status = 'deleted',
+			deleted_at = NOW(), deleted_by = $2, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING deleted_at`, workID, userID).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete work"})
+		return
+	}
+
+	// Clear everything cached from the work, and the series links to it
+	ws.InvalidateWorkCache(workID)
+	ws.InvalidateUserCache(userID)
+	ws.invalidateSeriesNavigation(c.Request.Context(), ws.seriesMateIDs(c.Request.Context(), workID)...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Work moved to trash",
+		"purge_at": trashPurgeAt(deletedAt, workTrashRetention()),
+	})
+}
+
+// GetMyTrash lists the signed-in user's deleted works that can still be
+// restored, most recently deleted first
+func (ws *WorkService) GetMyTrash(c *gin.Context) {
+	userID, ok := trashUserID(c)
+	if !ok {
+		return
+	}
+
+	retention := workTrashRetention()
+	rows, err := ws.db.QueryContext(c.Request.Context(), `
+		SELECT DISTINCT w.id, w.title, COALESCE(w.status_before_delete, 'draft'),
+			COALESCE(w.word_count, 0), w.deleted_at
+		FROM works w
+		JOIN creatorships cr ON w.id = cr.creation_id AND cr.creation_type = 'Work'
+		JOIN pseuds p ON cr.pseud_id = p.id
+		WHERE p.user_id = $1 AND cr.approved = true
+		AND w.deleted_at IS NOT NULL AND w.deleted_at > $2
+		ORDER BY w.deleted_at DESC`, userID, time.Now().Add(-retention))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch your trash"})
+		return
+	}
+	defer rows.Close()
+
+	works := []TrashedWork{}
+	for rows.Next() {
+		var work TrashedWork
+		if err := rows.Scan(&work.ID, &work.Title, &work.Status, &work.WordCount, &work.DeletedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan trashed work"})
+			return
+		}
+		work.PurgeAt = trashPurgeAt(work.DeletedAt, retention)
+		works = append(works, work)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch your trash"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"works": works, "retention_days": int(retention.Hours() / 24)})
+}
+
+// RestoreWork takes a work out of the trash, back to the status it had
+// when it was deleted
+func (ws *WorkService) RestoreWork(c *gin.Context) {
+	workID, err := uuid.Parse(c.Param("work_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work ID"})
+		return
+	}
+
+	userID, ok := trashUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	isAuthor, err := ws.isWorkCreator(ctx, workID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ownership"})
+		return
+	}
+	if !isAuthor {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found in trash"})
+		return
+	}
+
+	var status string
+	err = ws.db.QueryRowContext(ctx, `
+		UPDATE works
+		SET status = COALESCE(status_before_delete, 'draft'), status_before_delete = NULL,
+			deleted_at = NULL, deleted_by = NULL, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NOT NULL AND deleted_at > $2
+		RETURNING status`, workID, time.Now().Add(-workTrashRetention())).Scan(&status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work not found in trash"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore work"})
+		return
+	}
+
+	ws.InvalidateWorkCache(workID)
+	ws.InvalidateUserCache(userID)
+	ws.invalidateSeriesNavigation(ctx, ws.seriesMateIDs(ctx, workID)...)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Work restored", "status": status})
+}
+
+// purgeWork permanently deletes a work and everything attached to it
+func purgeWork(ctx context.Context, tx *sql.Tx, workID uuid.UUID) error {
+	tables := []string{"gifts", "creatorships", "work_comments", "work_kudos", "bookmarks", "work_statistics", "chapters", "works"}
+	for _, table := range tables {
+		var query string
+		switch table {
+		case "works":
+			query = "DELETE FROM works WHERE id = $1"
+		case "creatorships":
+			query = "DELETE FROM creatorships WHERE creation_id = $1 AND creation_type = 'Work'"
+		default:
+			query = fmt.Sprintf("DELETE FROM %s WHERE work_id = $1", table)
+		}
+		if _, err := tx.ExecContext(ctx, query, workID); err != nil {
+			return fmt.Errorf("purge %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// purgeExpiredTrash permanently deletes works whose trash window has run
+// out, a batch per scheduler tick
+func (ws *WorkService) purgeExpiredTrash(ctx context.Context) (int, error) {
+	tx, err := ws.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id FROM works
+		WHERE deleted_at IS NOT NULL AND deleted_at <= $1
+		ORDER BY deleted_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, time.Now().Add(-workTrashRetention()), scheduledWorkBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	for _, id := range ids {
+		if err := purgeWork(ctx, tx, id); err != nil {
+			return 0, fmt.Errorf("work %s: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		ws.InvalidateWorkCache(id)
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"nuclear-ao3/shared/models"
+)
+
+func TestWorkTrashRetention(t *testing.T) {
+	t.Setenv("WORK_TRASH_DAYS", "")
+	assert.Equal(t, 30*24*time.Hour, workTrashRetention())
+
+	t.Setenv("WORK_TRASH_DAYS", "7")
+	assert.Equal(t, 7*24*time.Hour, workTrashRetention())
+
+	t.Setenv("WORK_TRASH_DAYS", "0")
+	assert.Equal(t, 30*24*time.Hour, workTrashRetention(), "an empty trash window falls back to the default")
+
+	t.Setenv("WORK_TRASH_DAYS", "soon")
+	assert.Equal(t, 30*24*time.Hour, workTrashRetention())
+}
+
+func TestTrashPurgeAt(t *testing.T) {
+	deletedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC), trashPurgeAt(deletedAt, 30*24*time.Hour))
+}
+
+func TestCanViewWorkHidesTrashedWorks(t *testing.T) {
+	ws := &WorkService{}
+	author := uuid.New()
+	work := &models.Work{UserID: author, Status: "deleted"}
+
+	assert.False(t, ws.canViewWork(context.Background(), work, nil))
+	assert.False(t, ws.canViewWork(context.Background(), work, &author), "trashed works are reached through the trash, not the work page")
+
+	work.Status = "published"
+	assert.True(t, ws.canViewWork(context.Background(), work, nil))
+}
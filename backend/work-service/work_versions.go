@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"nuclear-ao3/shared/middleware"
+)
+
+// Works and chapters carry a version that every author edit bumps. An
+// update names the version it was made against, as an If-Match header
+// (If-Match: "3") or a "version" field, and only applies if that is still
+// the current version. Otherwise the editor gets a 409 with the latest
+// version to merge against, so co-authors editing at the same time can't
+// silently overwrite each other. The ETag a work or chapter is read with
+// starts with its version, so clients can send it straight back as If-Match.
+
+var (
+	errVersionRequired = errors.New("If-Match header or version is required")
+	errInvalidIfMatch  = errors.New("If-Match must be a work or chapter version")
+)
+
+// versionETag is the ETag of a work or chapter response: its version, then
+// a hash of the rest of the response so readers still revalidate when
+// something outside the version changes, like its series
+func versionETag(version int, parts ...interface{}) string {
+	hash := middleware.ETag(parts...)
+	if hash == "" {
+		return ""
+	}
+	return fmt.Sprintf(`"%d-%s"`, version, strings.Trim(hash, `"`))
+}
+
+// parseIfMatch reads a version from an If-Match header: a bare or quoted
+// version, or an ETag from versionETag
+func parseIfMatch(header string) (int, bool) {
+	value := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	value = strings.Trim(value, `"`)
+	if i := strings.IndexByte(value, '-'); i > 0 {
+		value = value[:i]
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+// expectedVersion is the version an update was made against. If-Match
+// takes precedence over the body's version.
+func expectedVersion(ifMatch string, bodyVersion *int) (int, error) {
+	if ifMatch != "" {
+		version, ok := parseIfMatch(ifMatch)
+		if !ok {
+			return 0, errInvalidIfMatch
+		}
+		return version, nil
+	}
+	if bodyVersion == nil {
+		return 0, errVersionRequired
+	}
+	if *bodyVersion < 1 {
+		return 0, errInvalidIfMatch
+	}
+	return *bodyVersion, nil
+}
+
+// requireVersion reads the version an update was made against, answering
+// the request itself when there is none
+func requireVersion(c *gin.Context, bodyVersion *int) (int, bool) {
+	version, err := expectedVersion(c.GetHeader("If-Match"), bodyVersion)
+	if errors.Is(err, errVersionRequired) {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	return version, true
+}
+
+// respondVersionConflict refuses an update made against a stale version,
+// returning what it would have overwritten. latestKey names the latest
+// work or chapter in the response.
+func respondVersionConflict(c *gin.Context, expected, current int, latestKey string, latest interface{}) {
+	response := gin.H{
+		"error":            "This was changed by someone else since you started editing",
+		"expected_version": expected,
+		"current_version":  current,
+	}
+	if latest != nil {
+		response[latestKey] = latest
+	}
+	c.JSON(http.StatusConflict, response)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	for header, want := range map[string]int{`3`: 3, `"3"`: 3, `W/"12"`: 12, ` "7" `: 7} {
+		version, ok := parseIfMatch(header)
+		assert.True(t, ok, header)
+		assert.Equal(t, want, version, header)
+	}
+	for _, header := range []string{``, `*`, `"abc"`, `"0"`, `"-2"`} {
+		_, ok := parseIfMatch(header)
+		assert.False(t, ok, header)
+	}
+}
+
+func TestVersionETagIsAcceptedAsIfMatch(t *testing.T) {
+	etag := versionETag(7, "work", "series")
+	assert.Regexp(t, `^"7-[0-9a-f]{32}"$`, etag)
+	assert.NotEqual(t, etag, versionETag(7, "work", "other series"), "changes outside the version still change the ETag")
+
+	for _, header := range []string{etag, "W/" + etag} {
+		version, ok := parseIfMatch(header)
+		assert.True(t, ok, header)
+		assert.Equal(t, 7, version, header)
+	}
+}
+
+func TestExpectedVersion(t *testing.T) {
+	body := 4
+
+	version, err := expectedVersion(`"5"`, &body)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, version, "If-Match takes precedence over the body")
+
+	version, err = expectedVersion("", &body)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, version)
+
+	_, err = expectedVersion("", nil)
+	assert.ErrorIs(t, err, errVersionRequired)
+
+	_, err = expectedVersion("*", &body)
+	assert.ErrorIs(t, err, errInvalidIfMatch)
+
+	zero := 0
+	_, err = expectedVersion("", &zero)
+	assert.ErrorIs(t, err, errInvalidIfMatch)
+}
+
+func TestRequireVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/v1/works/1", nil)
+	_, ok := requireVersion(c, nil)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/v1/works/1", nil)
+	c.Request.Header.Set("If-Match", `"2"`)
+	version, ok := requireVersion(c, nil)
+	assert.True(t, ok)
+	assert.Equal(t, 2, version)
+}
+
+func TestRespondVersionConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	respondVersionConflict(c, 2, 3, "work", gin.H{"title": "Latest"})
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"current_version":3`)
+	assert.Contains(t, w.Body.String(), `"work":{"title":"Latest"}`)
+}
@@ -24,7 +24,6 @@ interface Work {
   title: string;
   summary: string;
   status: string;
-  series_id?: string;
   position?: number;
 }
 
@@ -84,11 +83,9 @@ export default function EditSeriesPage() {
       // Set current works in series
       setSeriesWorks(worksResponse.works || []);
       
-      // Filter available works (not in any series, or in this series)
-      const available = availableWorksResponse.works?.filter((work: Work) => 
-        !work.series_id || work.series_id === seriesId
-      ) || [];
-      setAvailableWorks(available);
+      // A work can be in any number of series; ones already in this
+      // series are filtered out when listed
+      setAvailableWorks(availableWorksResponse.works || []);
       
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to load series');
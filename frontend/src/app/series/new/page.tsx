@@ -10,7 +10,6 @@ interface Work {
   title: string;
   summary: string;
   status: string;
-  series_id?: string;
 }
 
 export default function CreateSeriesPage() {
@@ -41,9 +40,8 @@ export default function CreateSeriesPage() {
       }
 
       const response = await getMyWorks(authToken);
-      // Filter works that are not already in a series
-      const availableWorks = response.works?.filter((work: Work) => !work.series_id) || [];
-      setAvailableWorks(availableWorks);
+      // A work can be in any number of series
+      setAvailableWorks(response.works || []);
     } catch (err) {
       console.error('Failed to fetch works:', err);
       setError('Failed to load your works');
@@ -37,6 +37,7 @@ interface WorkData {
     published_at?: string;
     updated_at: string;
     created_at: string;
+    version: number;
   };
   authors?: Array<{
     pseud_id: string;
@@ -172,7 +173,7 @@ export default function EditWorkPage() {
     setError(null);
 
     try {
-      await updateWork(workId, workForm);
+      await updateWork(workId, { ...workForm, version: workData?.work.version });
       
       // Refresh work data
       const updatedWork = await getWork(workId);
@@ -217,7 +218,7 @@ export default function EditWorkPage() {
     setError(null);
 
     try {
-      await updateChapter(workId, selectedChapter.id, chapterForm);
+      await updateChapter(workId, selectedChapter.id, { ...chapterForm, version: selectedChapter.version });
       
       // Refresh chapters
       const chaptersResponse = await getWorkChapters(workId);
@@ -478,6 +479,7 @@ export default function EditWorkPage() {
                   >
                     <option value="draft">Draft</option>
                     <option value="posted">Published</option>
+                    <option value="unlisted">Unlisted (share link only)</option>
                     <option value="hidden">Hidden</option>
                   </select>
                 </div>
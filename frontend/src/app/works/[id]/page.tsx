@@ -2,7 +2,7 @@
 
 import { useState, useEffect } from 'react';
 import Link from 'next/link';
-import { useParams } from 'next/navigation';
+import { useParams, useSearchParams } from 'next/navigation';
 import Comments from '@/components/Comments';
 import KudosButton from '@/components/KudosButton';
 import { BookmarkButton } from '@/components/BookmarkButton';
@@ -12,10 +12,10 @@ import { SubscriptionButton } from '@/components/SubscriptionButton';
 import RespectfulExportButton from '@/components/RespectfulExportButton';
 import ChapterNavigation from '@/components/ChapterNavigation';
 import ReaderControls from '@/components/ReaderControls';
-import { getWork, getWorkChapters, Gift } from '@/lib/api';
+import { getWork, getWorkChapters, Gift, AgeGate, AgeGateError, acknowledgeAdultContent } from '@/lib/api';
 import { useAuth } from '@/lib/auth';
 import { useReaderPreferences } from '@/hooks/useReaderPreferences';
-import { useReadingProgress } from '@/hooks/useReadingProgress';
+import { useReadingProgress, formatReadingTime } from '@/hooks/useReadingProgress';
 
 interface Work {
   id: string;
@@ -30,6 +30,7 @@ interface Work {
   relationships: string[];
   freeform_tags: string[];
   word_count: number;
+  estimated_reading_minutes?: number;
   chapter_count: number;
   max_chapters?: number;
   is_complete: boolean;
@@ -62,6 +63,7 @@ interface Chapter {
   end_notes: string;
   content: string;
   word_count: number;
+  estimated_reading_minutes?: number;
   status: string;
   published_at: string;
   created_at: string;
@@ -71,6 +73,8 @@ interface Chapter {
 export default function WorkPage() {
   const params = useParams();
   const workId = params.id as string;
+  // Unlisted works are read through their share link's token
+  const shareToken = useSearchParams().get('share') || undefined;
   const { user, token } = useAuth();
   
   const [work, setWork] = useState<Work | null>(null);
@@ -79,11 +83,16 @@ export default function WorkPage() {
   const [currentChapter, setCurrentChapter] = useState<number>(1);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
+  const [ageGate, setAgeGate] = useState<AgeGate | null>(null);
   const [showReaderControls, setShowReaderControls] = useState(false);
   const [gifts, setGifts] = useState<Gift[]>([]);
 
   const { preferences } = useReaderPreferences();
-  const { progress, saveProgress } = useReadingProgress(workId, chapters.length);
+  const { progress, saveProgress } = useReadingProgress(
+    workId,
+    chapters.length,
+    chapters.map(ch => ch.estimated_reading_minutes ?? 0)
+  );
 
   // Check if current user is an author of this work
   const isAuthor = user && authors.some(author => author.user_id === user.id);
@@ -105,11 +114,13 @@ export default function WorkPage() {
       }
 
       const token = localStorage.getItem('auth_token') || localStorage.getItem('token');
-      const data = await getWork(workId, token || undefined);
+      const data = await getWork(workId, token || undefined, shareToken);
       setWork(data.work);
       setAuthors(data.authors || []);
     } catch (err) {
-      if (err instanceof Error && err.message.includes('400')) {
+      if (err instanceof AgeGateError) {
+        setAgeGate(err.ageGate);
+      } else if (err instanceof Error && err.message.includes('400')) {
         setError('Work not found. Please check the Work ID.');
       } else {
         setError(err instanceof Error ? err.message : 'Failed to load work');
@@ -122,7 +133,7 @@ export default function WorkPage() {
   const fetchChapters = async () => {
     try {
       const token = localStorage.getItem('token');
-      const data = await getWorkChapters(workId, token || undefined);
+      const data = await getWorkChapters(workId, token || undefined, shareToken);
       setChapters(data.chapters || []);
     } catch (err) {
       console.error('Failed to load chapters:', err);
@@ -163,6 +174,45 @@ export default function WorkPage() {
     );
   }
 
+  const handleAgeGateAcknowledge = () => {
+    acknowledgeAdultContent();
+    setAgeGate(null);
+    setLoading(true);
+    fetchWork();
+    fetchChapters();
+  };
+
+  if (ageGate) {
+    return (
+      <div className="max-w-4xl mx-auto px-4 py-8">
+        <div className="bg-amber-50 border border-amber-200 text-amber-900 px-6 py-5 rounded">
+          <h1 className="text-xl font-semibold mb-2">{ageGate.title}</h1>
+          {ageGate.rating && <p className="text-sm mb-4">Rating: {ageGate.rating}</p>}
+          {ageGate.can_acknowledge ? (
+            <>
+              <p className="mb-4">
+                This work contains adult content. Please confirm you are {ageGate.minimum_age} or over to continue.
+              </p>
+              <div className="flex gap-3">
+                <button
+                  onClick={handleAgeGateAcknowledge}
+                  className="px-4 py-2 bg-orange-600 text-white rounded hover:bg-orange-700"
+                >
+                  Yes, I am {ageGate.minimum_age} or over
+                </button>
+                <Link href="/works" className="px-4 py-2 border border-amber-300 rounded hover:bg-amber-100">
+                  No, go back
+                </Link>
+              </div>
+            </>
+          ) : (
+            <p>This work is restricted to readers aged {ageGate.minimum_age} or over.</p>
+          )}
+        </div>
+      </div>
+    );
+  }
+
   if (error || !work) {
     return (
       <div className="max-w-4xl mx-auto px-4 py-8">
@@ -283,6 +333,12 @@ export default function WorkPage() {
                 <span className="text-slate-700">Words:</span>
                 <span className="ml-1 font-medium">{work.word_count.toLocaleString()}</span>
               </div>
+              {work.estimated_reading_minutes ? (
+                <div>
+                  <span className="text-slate-700">Reading time:</span>
+                  <span className="ml-1 font-medium">{formatReadingTime(work.estimated_reading_minutes)}</span>
+                </div>
+              ) : null}
               <div>
                 <span className="text-slate-700">Chapters:</span>
                 <span className="ml-1 font-medium">
@@ -116,6 +116,8 @@ interface SearchFilters {
   minComments?: number;
   minBookmarks?: number;
   hideOrphaned?: boolean;
+  // Also match the query against chapter text
+  searchContent?: boolean;
   // Sorting
   sort?: string;
 }
@@ -197,6 +199,7 @@ const SearchForm = React.memo(function SearchForm({
     minComments: undefined,
     minBookmarks: undefined,
     hideOrphaned: false,
+    searchContent: false,
     ...initialFilters
   });
   
@@ -245,6 +248,7 @@ const SearchForm = React.memo(function SearchForm({
         status: searchParams.filters.status,
         relationshipCount: searchParams.filters.relationship_count,
         tagProminence: searchParams.filters.tag_prominence,
+        searchContent: searchParams.filters.search_content,
         sort: searchParams.filters.sort || 'quality_score',
       };
 
@@ -348,7 +352,8 @@ const SearchForm = React.memo(function SearchForm({
         min_kudos: filters.minKudos,
         min_comments: filters.minComments,
         min_bookmarks: filters.minBookmarks,
-        hide_orphaned: filters.hideOrphaned
+        hide_orphaned: filters.hideOrphaned,
+        search_content: filters.searchContent
       },
       options: {
         exclude_poorly_tagged: filters.excludePoorlyTagged,
@@ -429,6 +434,7 @@ const SearchForm = React.memo(function SearchForm({
       minComments: undefined,
       minBookmarks: undefined,
       hideOrphaned: false,
+      searchContent: false,
       sort: 'quality_score'
     });
     setTagInputs({
@@ -582,6 +588,15 @@ const SearchForm = React.memo(function SearchForm({
               <div id={`${formId}-title-help`} className="mt-1 text-xs text-gray-500">
                 Enter keywords from the work title
               </div>
+              <label className="flex items-center mt-2">
+                <input
+                  type="checkbox"
+                  checked={filters.searchContent || false}
+                  onChange={(e) => handleInputChange('searchContent', e.target.checked)}
+                  className="rounded border-gray-300 text-blue-600 focus:ring-blue-500"
+                />
+                <span className="ml-2 text-sm text-gray-700">Search within work text</span>
+              </label>
             </div>
 
             <div>
@@ -695,6 +710,7 @@ const SearchForm = React.memo(function SearchForm({
                     <option value="updated_at">Recently Updated</option>
                     <option value="published_at">Recently Published</option>
                     <option value="kudos">Most Kudos</option>
+                    <option value="kudos_per_hit">Kudos per Hit</option>
                     <option value="hits">Most Hits</option>
                     <option value="comments">Most Comments</option>
                     <option value="bookmarks">Most Bookmarks</option>
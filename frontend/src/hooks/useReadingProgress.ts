@@ -8,7 +8,37 @@ interface ReadingProgress {
   lastRead: number; // timestamp
 }
 
-export function useReadingProgress(workId: string, totalChapters: number) {
+// Percent of the work read, weighted by each chapter's estimated reading
+// time so a long chapter counts for more than a short one. Falls back to
+// counting chapters when reading times aren't known.
+export function percentComplete(
+  chapterIndex: number,
+  chapterFraction: number,
+  totalChapters: number,
+  chapterMinutes: number[] = []
+): number {
+  const fraction = Math.min(1, Math.max(0, chapterFraction));
+  const totalMinutes = chapterMinutes.reduce((sum, m) => sum + m, 0);
+
+  if (chapterMinutes.length !== totalChapters || totalMinutes <= 0) {
+    return totalChapters > 0 ? Math.min(100, ((chapterIndex + fraction) / totalChapters) * 100) : 0;
+  }
+
+  const minutesBefore = chapterMinutes.slice(0, chapterIndex).reduce((sum, m) => sum + m, 0);
+  const minutesRead = minutesBefore + fraction * (chapterMinutes[chapterIndex] ?? 0);
+  return Math.min(100, (minutesRead / totalMinutes) * 100);
+}
+
+// "45 min" or "3 hr 20 min"
+export function formatReadingTime(minutes: number): string {
+  const rounded = Math.max(1, Math.round(minutes));
+  const hours = Math.floor(rounded / 60);
+  const rest = rounded % 60;
+  if (hours === 0) return `${rest} min`;
+  return rest === 0 ? `${hours} hr` : `${hours} hr ${rest} min`;
+}
+
+export function useReadingProgress(workId: string, totalChapters: number, chapterMinutes: number[] = []) {
   const [progress, setProgress] = useState<ReadingProgress | null>(null);
   const [scrollProgress, setScrollProgress] = useState(0);
 
@@ -43,12 +73,17 @@ export function useReadingProgress(workId: string, totalChapters: number) {
   }, []);
 
   // Save progress
-  const saveProgress = useCallback((chapterIndex: number, scrollPosition?: number) => {
+  const chapterMinutesKey = chapterMinutes.join(',');
+  const saveProgress = useCallback((chapterIndex: number, scrollPosition?: number, chapterFraction?: number) => {
+    const position = scrollPosition ?? window.scrollY;
+    const docHeight = document.documentElement.scrollHeight - window.innerHeight;
+    const fraction = chapterFraction ?? (docHeight > 0 ? position / docHeight : 0);
+
     const newProgress: ReadingProgress = {
       workId,
       chapterIndex,
-      scrollPosition: scrollPosition ?? window.scrollY,
-      totalProgress: ((chapterIndex + 1) / totalChapters) * 100,
+      scrollPosition: position,
+      totalProgress: percentComplete(chapterIndex, fraction, totalChapters, chapterMinutes),
       lastRead: Date.now()
     };
 
@@ -58,7 +93,8 @@ export function useReadingProgress(workId: string, totalChapters: number) {
     if (typeof window !== 'undefined') {
       localStorage.setItem(key, JSON.stringify(newProgress));
     }
-  }, [workId, totalChapters]);
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [workId, totalChapters, chapterMinutesKey]);
 
   // Auto-save progress when chapter changes or scroll position changes significantly
   useEffect(() => {
@@ -73,7 +109,7 @@ export function useReadingProgress(workId: string, totalChapters: number) {
 
   // Mark chapter as complete
   const markChapterComplete = useCallback((chapterIndex: number) => {
-    saveProgress(chapterIndex, 0);
+    saveProgress(chapterIndex, 0, 1);
   }, [saveProgress]);
 
   // Get reading statistics
@@ -82,15 +118,19 @@ export function useReadingProgress(workId: string, totalChapters: number) {
 
     const chaptersRead = progress.chapterIndex + 1;
     const remainingChapters = totalChapters - chaptersRead;
-    
+    const overallProgress = percentComplete(progress.chapterIndex, scrollProgress / 100, totalChapters, chapterMinutes);
+    const totalMinutes = chapterMinutes.reduce((sum, m) => sum + m, 0);
+
     return {
       chaptersRead,
       remainingChapters,
-      overallProgress: progress.totalProgress,
+      overallProgress,
+      minutesRemaining: Math.ceil(totalMinutes * (1 - overallProgress / 100)),
       currentChapterProgress: scrollProgress,
       lastReadDate: new Date(progress.lastRead),
     };
-  }, [progress, totalChapters, scrollProgress]);
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [progress, totalChapters, scrollProgress, chapterMinutesKey]);
 
   return {
     progress,
@@ -38,6 +38,7 @@ export interface SearchParams {
   minComments?: number;        // Minimum comments count
   minBookmarks?: number;       // Minimum bookmarks count
   hideOrphaned?: boolean;      // Hide orphaned works
+  searchContent?: boolean;     // Also match the query against chapter text
   sort?: 'title' | 'updated_at' | 'created_at' | 'published_at' | 'word_count' | 'hits' | 'kudos' | 'comments' | 'bookmarks' | 'relevance' | 'quality_score' | 'engagement_rate' | 'comment_quality' | 'discovery_boost';
   order?: 'asc' | 'desc';
   page?: number;
@@ -157,6 +158,9 @@ export async function searchWorks(query: string, searchParams?: SearchParams) {
     if (searchParams?.status && searchParams.status !== 'all') {
       searchUrl.searchParams.append('status', searchParams.status);
     }
+    if (searchParams?.searchContent) {
+      searchUrl.searchParams.append('search_content', 'true');
+    }
 
     const response = await fetch(searchUrl.toString(), {
       method: 'GET',
@@ -308,7 +312,61 @@ export async function getWorks(searchParams?: SearchParams) {
   }
 }
 
-export async function getWork(id: string, authToken?: string) {
+// Age gate: works restricted to adults answer 451 with an interstitial
+// until the reader confirms their age, which is remembered in this browser
+export interface AgeGate {
+  work_id: string;
+  title: string;
+  rating: string;
+  minimum_age: number;
+  can_acknowledge: boolean;
+  acknowledge_header?: string;
+  acknowledge_cookie?: string;
+}
+
+const ADULT_CONTENT_ACKNOWLEDGED_KEY = 'adult_content_acknowledged';
+
+export class AgeGateError extends Error {
+  code: string;
+  ageGate: AgeGate;
+
+  constructor(message: string, code: string, ageGate: AgeGate) {
+    super(message);
+    this.name = 'AgeGateError';
+    this.code = code;
+    this.ageGate = ageGate;
+  }
+}
+
+// Remember that the reader has confirmed they are old enough for adult works
+export function acknowledgeAdultContent() {
+  localStorage.setItem(ADULT_CONTENT_ACKNOWLEDGED_KEY, 'true');
+}
+
+function adultContentHeaders(): Record<string, string> {
+  if (typeof window === 'undefined' || localStorage.getItem(ADULT_CONTENT_ACKNOWLEDGED_KEY) !== 'true') {
+    return {};
+  }
+  return { 'X-Adult-Content-Acknowledged': 'true' };
+}
+
+async function throwIfAgeGated(response: Response) {
+  if (response.status !== 451) {
+    return;
+  }
+  const data = await response.json().catch(() => ({}));
+  if (data.age_gate) {
+    throw new AgeGateError(data.error || 'This work is restricted to adults', data.code, data.age_gate);
+  }
+}
+
+// shareQuery passes an unlisted work's share token on to the API
+function shareQuery(shareToken?: string) {
+  return shareToken ? `?share=${encodeURIComponent(shareToken)}` : '';
+}
+
+// shareToken is the ?share= token of an unlisted work's share link
+export async function getWork(id: string, authToken?: string, shareToken?: string) {
   try {
     const headers: Record<string, string> = {
       'Accept': 'application/json',
@@ -318,13 +376,15 @@ export async function getWork(id: string, authToken?: string) {
     if (authToken) {
       headers['Authorization'] = `Bearer ${authToken}`;
     }
+    Object.assign(headers, adultContentHeaders());
 
-    const response = await fetch(`${API_GATEWAY_URL}/api/v1/works/${id}`, {
+    const response = await fetch(`${API_GATEWAY_URL}/api/v1/works/${id}${shareQuery(shareToken)}`, {
       method: 'GET',
       headers
     });
     
     if (!response.ok) {
+      await throwIfAgeGated(response);
       throw new Error(`API responded with status ${response.status}: ${response.statusText}`);
     }
     
@@ -398,6 +458,7 @@ export interface UpdateWorkRequest {
   is_anonymous?: boolean;
   in_anon_collection?: boolean;
   in_unrevealed_collection?: boolean;
+  version?: number; // The version being edited; a stale one gets a 409
 }
 
 // Create a new work
@@ -559,6 +620,7 @@ export interface Chapter {
   published_at?: string;
   updated_at: string;
   created_at: string;
+  version: number;
 }
 
 export interface CreateChapterRequest {
@@ -576,10 +638,60 @@ export interface UpdateChapterRequest {
   end_notes?: string;
   content?: string;
   status?: 'draft' | 'posted';
+  version?: number; // The version being edited; a stale one gets a 409
+}
+
+// Share links for unlisted works
+export interface ShareLink {
+  token: string;
+  url: string;
+  created_at: string;
+}
+
+async function shareLinkRequest(workId: string, method: string, authToken: string | undefined, action: string) {
+  try {
+    const headers: Record<string, string> = {
+      'Accept': 'application/json',
+    };
+    
+    if (authToken) {
+      headers['Authorization'] = `Bearer ${authToken}`;
+    }
+
+    const response = await fetch(`${API_GATEWAY_URL}/api/v1/works/${workId}/share-link`, {
+      method,
+      headers,
+    });
+    
+    if (!response.ok) {
+      const errorData = await response.json().catch(() => ({}));
+      throw new Error(errorData.error || `API responded with status ${response.status}: ${response.statusText}`);
+    }
+    
+    return await response.json();
+  } catch (error) {
+    console.error(`${action} error:`, error instanceof Error ? error.message : String(error));
+    throw error;
+  }
+}
+
+// Get an unlisted work's current share link ({ status, share_link })
+export async function getWorkShareLink(workId: string, authToken?: string) {
+  return shareLinkRequest(workId, 'GET', authToken, 'Get share link');
+}
+
+// Issue a new share link; the previous one stops working
+export async function createWorkShareLink(workId: string, authToken?: string) {
+  return shareLinkRequest(workId, 'POST', authToken, 'Create share link');
+}
+
+// Revoke a work's share link
+export async function revokeWorkShareLink(workId: string, authToken?: string) {
+  return shareLinkRequest(workId, 'DELETE', authToken, 'Revoke share link');
 }
 
 // Get work chapters
-export async function getWorkChapters(workId: string, authToken?: string) {
+export async function getWorkChapters(workId: string, authToken?: string, shareToken?: string) {
   try {
     const headers: Record<string, string> = {
       'Accept': 'application/json',
@@ -588,13 +700,15 @@ export async function getWorkChapters(workId: string, authToken?: string) {
     if (authToken) {
       headers['Authorization'] = `Bearer ${authToken}`;
     }
+    Object.assign(headers, adultContentHeaders());
 
-    const response = await fetch(`${API_GATEWAY_URL}/api/v1/works/${workId}/chapters`, {
+    const response = await fetch(`${API_GATEWAY_URL}/api/v1/works/${workId}/chapters${shareQuery(shareToken)}`, {
       method: 'GET',
       headers,
     });
     
     if (!response.ok) {
+      await throwIfAgeGated(response);
       const errorData = await response.json().catch(() => ({}));
       throw new Error(errorData.error || `API responded with status ${response.status}: ${response.statusText}`);
     }
@@ -607,7 +721,7 @@ export async function getWorkChapters(workId: string, authToken?: string) {
 }
 
 // Get single chapter
-export async function getChapter(workId: string, chapterId: string, authToken?: string) {
+export async function getChapter(workId: string, chapterId: string, authToken?: string, shareToken?: string) {
   try {
     const headers: Record<string, string> = {
       'Accept': 'application/json',
@@ -616,13 +730,15 @@ export async function getChapter(workId: string, chapterId: string, authToken?:
     if (authToken) {
       headers['Authorization'] = `Bearer ${authToken}`;
     }
+    Object.assign(headers, adultContentHeaders());
 
-    const response = await fetch(`${API_GATEWAY_URL}/api/v1/works/${workId}/chapters/${chapterId}`, {
+    const response = await fetch(`${API_GATEWAY_URL}/api/v1/works/${workId}/chapters/${chapterId}${shareQuery(shareToken)}`, {
       method: 'GET',
       headers,
     });
     
     if (!response.ok) {
+      await throwIfAgeGated(response);
       const errorData = await response.json().catch(() => ({}));
       throw new Error(errorData.error || `API responded with status ${response.status}: ${response.statusText}`);
     }
@@ -1544,6 +1660,36 @@ export async function removeWorkFromSeries(seriesId: string, workId: string, aut
   }
 }
 
+// Reorder a series; workIds must list every work in it, in the new order
+export async function reorderSeries(seriesId: string, workIds: string[], authToken?: string) {
+  try {
+    const headers: Record<string, string> = {
+      'Accept': 'application/json',
+      'Content-Type': 'application/json',
+    };
+    
+    if (authToken) {
+      headers['Authorization'] = `Bearer ${authToken}`;
+    }
+
+    const response = await fetch(`${API_GATEWAY_URL}/api/v1/series/${seriesId}/reorder`, {
+      method: 'PUT',
+      headers,
+      body: JSON.stringify({ work_ids: workIds }),
+    });
+    
+    if (!response.ok) {
+      const errorData = await response.json().catch(() => ({}));
+      throw new Error(errorData.error || `API responded with status ${response.status}: ${response.statusText}`);
+    }
+    
+    return await response.json();
+  } catch (error) {
+    console.error('Reorder series error:', error instanceof Error ? error.message : String(error));
+    throw error;
+  }
+}
+
 // Collection management interfaces and functions
 export interface Collection {
   id: string;
@@ -1756,6 +1902,7 @@ export interface Bookmark {
   notes: string;
   tags: string[];
   is_private: boolean;
+  is_rec: boolean; // Recommended, not just saved
   created_at: string;
   updated_at: string;
   work?: Record<string, unknown>; // Work details when fetching bookmarks
@@ -1765,17 +1912,20 @@ export interface CreateBookmarkRequest {
   notes?: string;
   tags?: string[];
   is_private?: boolean;
+  is_rec?: boolean;
 }
 
 export interface UpdateBookmarkRequest {
   notes?: string;
   tags?: string[];
   is_private?: boolean;
+  is_rec?: boolean;
 }
 
 export interface BookmarkSearchParams {
   q?: string;     // Search query
   tag?: string;   // Filter by bookmark tag
+  rec?: boolean;  // Only recs
   page?: number;
   limit?: number;
 }
@@ -1884,6 +2034,7 @@ export async function getMyBookmarks(searchParams?: BookmarkSearchParams, authTo
     if (searchParams) {
       if (searchParams.q) params.append('q', searchParams.q);
       if (searchParams.tag) params.append('tag', searchParams.tag);
+      if (searchParams.rec) params.append('rec', 'true');
       if (searchParams.page) params.append('page', searchParams.page.toString());
       if (searchParams.limit) params.append('limit', searchParams.limit.toString());
     }
@@ -1924,6 +2075,7 @@ export async function getUserBookmarks(userId: string, searchParams?: BookmarkSe
     if (searchParams) {
       if (searchParams.q) params.append('q', searchParams.q);
       if (searchParams.tag) params.append('tag', searchParams.tag);
+      if (searchParams.rec) params.append('rec', 'true');
       if (searchParams.page) params.append('page', searchParams.page.toString());
       if (searchParams.limit) params.append('limit', searchParams.limit.toString());
     }
@@ -1948,6 +2100,94 @@ export async function getUserBookmarks(userId: string, searchParams?: BookmarkSe
   }
 }
 
+// Bookmark lists: named public lists a user groups their bookmarks into
+export interface BookmarkList {
+  id: string;
+  user_id: string;
+  name: string;
+  description: string;
+  bookmark_count: number;
+  rec_count: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface BookmarkListRequest {
+  name: string;
+  description?: string;
+}
+
+async function bookmarkListRequest(path: string, method: string, body: unknown, authToken: string | undefined, action: string) {
+  try {
+    const headers: Record<string, string> = {
+      'Accept': 'application/json',
+    };
+    if (body !== undefined) {
+      headers['Content-Type'] = 'application/json';
+    }
+    
+    if (authToken) {
+      headers['Authorization'] = `Bearer ${authToken}`;
+    }
+
+    const response = await fetch(`${API_GATEWAY_URL}/api/v1${path}`, {
+      method,
+      headers,
+      body: body !== undefined ? JSON.stringify(body) : undefined,
+    });
+    
+    if (!response.ok) {
+      const errorData = await response.json().catch(() => ({}));
+      throw new Error(errorData.error || `API responded with status ${response.status}: ${response.statusText}`);
+    }
+    
+    return await response.json();
+  } catch (error) {
+    console.error(`${action} error:`, error instanceof Error ? error.message : String(error));
+    throw error;
+  }
+}
+
+// Get a user's bookmark lists
+export async function getUserBookmarkLists(userId: string, authToken?: string) {
+  return bookmarkListRequest(`/users/${userId}/bookmark-lists`, 'GET', undefined, authToken, 'Get bookmark lists');
+}
+
+// Get a bookmark list's works feed
+export async function getBookmarkListWorks(listId: string, params?: { rec?: boolean; page?: number; limit?: number }, authToken?: string) {
+  const query = new URLSearchParams();
+  if (params?.rec) query.append('rec', 'true');
+  if (params?.page) query.append('page', params.page.toString());
+  if (params?.limit) query.append('limit', params.limit.toString());
+  const queryString = query.toString();
+  return bookmarkListRequest(`/bookmark-lists/${listId}/works${queryString ? '?' + queryString : ''}`, 'GET', undefined, authToken, 'Get bookmark list works');
+}
+
+// Create a bookmark list
+export async function createBookmarkList(list: BookmarkListRequest, authToken?: string) {
+  return bookmarkListRequest('/bookmark-lists', 'POST', list, authToken, 'Create bookmark list');
+}
+
+// Rename a bookmark list or change its description
+export async function updateBookmarkList(listId: string, list: BookmarkListRequest, authToken?: string) {
+  return bookmarkListRequest(`/bookmark-lists/${listId}`, 'PUT', list, authToken, 'Update bookmark list');
+}
+
+// Delete a bookmark list, keeping its bookmarks
+export async function deleteBookmarkList(listId: string, authToken?: string) {
+  return bookmarkListRequest(`/bookmark-lists/${listId}`, 'DELETE', undefined, authToken, 'Delete bookmark list');
+}
+
+// Add one of your bookmarks to a list
+export async function addBookmarkToList(listId: string, bookmarkId: string, authToken?: string) {
+  return bookmarkListRequest(`/bookmark-lists/${listId}/bookmarks`, 'POST', { bookmark_id: bookmarkId }, authToken, 'Add bookmark to list');
+}
+
+// Take a bookmark out of a list
+export async function removeBookmarkFromList(listId: string, bookmarkId: string, authToken?: string) {
+  return bookmarkListRequest(`/bookmark-lists/${listId}/bookmarks/${bookmarkId}`, 'DELETE', undefined, authToken, 'Remove bookmark from list');
+}
+
 // Check if work is bookmarked by current user
 export async function isWorkBookmarked(workId: string, authToken?: string) {
   try {
@@ -1,4 +1,12 @@
 // Nuclear AO3 API Types
+// One entry of a work's required-tag square, in server-defined order:
+// rating, warnings, categories, completion
+export interface RequiredTag {
+  type: 'rating' | 'warning' | 'category' | 'completion';
+  key: string;
+  label: string;
+}
+
 export interface Work {
   id: string;
   title: string;
@@ -10,6 +18,8 @@ export interface Work {
   word_count: number;
   chapter_count: number;
   is_complete: boolean;
+  required_tags?: RequiredTag[];
+  estimated_reading_minutes?: number;
   published_at?: string;
   created_at: string;
   updated_at: string;
@@ -35,7 +45,7 @@ export interface WorkSearchParams {
   tag?: string[];
   page?: number;
   limit?: number;
-  sort?: 'updated_at' | 'title' | 'word_count' | 'kudos' | 'hits';
+  sort?: 'updated_at' | 'published_at' | 'title' | 'word_count' | 'kudos' | 'hits' | 'comments' | 'bookmarks' | 'kudos_per_hit';
   order?: 'asc' | 'desc';
 }
 
@@ -61,6 +71,7 @@ export interface Chapter {
   summary: string;
   content: string;
   word_count: number;
+  estimated_reading_minutes?: number;
   published_at?: string;
   created_at: string;
   updated_at: string;
@@ -23,6 +23,12 @@ export interface Comment {
   updated_at: string;
   edited_at?: string;
   replies?: Comment[];
+  mentions?: CommentMention[];
+}
+
+export interface CommentMention {
+  user_id: string;
+  username: string;
 }
 
 export interface CommentCreateRequest {
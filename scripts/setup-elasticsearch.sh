@@ -18,6 +18,12 @@ done
 
 echo "✅ Elasticsearch is ready!"
 
+# The works index analyses languages without an analyzer of their own with ICU
+if ! curl -s "$ES_HOST/_cat/plugins?h=component" | grep -q "analysis-icu"; then
+    echo "❌ The analysis-icu plugin is missing; run the image built from backend/shared/elasticsearch/Dockerfile"
+    exit 1
+fi
+
 # Function to create index with mapping
 create_index() {
     local index_name=$1
@@ -46,9 +52,17 @@ create_index() {
 # Create indices
 echo "📚 Creating optimized indices..."
 
-create_index "works" "works"
+# Works are served through the "works" alias so the search service can
+# rebuild into a new index and swap it in without downtime
+create_index "works_v1" "works"
+curl -X POST "$ES_HOST/_aliases" \
+    -H "Content-Type: application/json" \
+    -d '{"actions": [{"add": {"index": "works_v1", "alias": "works"}}]}' \
+    -s | jq .
 create_index "tags" "tags" 
 create_index "users" "users"
+create_index "chapters" "chapters"
+create_index "suggestions" "suggestions"
 
 # Create index templates for time-based indices
 echo "📋 Creating index templates..."